@@ -1,8 +1,10 @@
 package main
 
 import (
+  "bufio"
   "context"
   "flag"
+  "fmt"
   "log"
   "os"
   "strings"
@@ -10,6 +12,7 @@ import (
 
   "lightningos-light/internal/config"
   "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/lndmock"
   "lightningos-light/internal/reports"
   "lightningos-light/internal/server"
 
@@ -25,6 +28,25 @@ func main() {
     case "reports-backfill":
       runReportsBackfill(os.Args[2:])
       return
+    case "reports-export":
+      runReportsExport(os.Args[2:])
+      return
+    case "reports-verify":
+      runReportsVerify(os.Args[2:])
+      return
+    case "node-import":
+      runNodeImport(os.Args[2:])
+      return
+    case "sudo-policy":
+      runSudoPolicy(os.Args[2:])
+      return
+    case "secrets-decrypt":
+      runSecretsDecrypt(os.Args[2:])
+      return
+    case "polkit-rules":
+      // The rules for privilege_mode: polkit.
+      fmt.Print(server.PolkitRules())
+      return
     }
   }
 
@@ -34,6 +56,7 @@ func main() {
 func runServer(args []string) {
   fs := flag.NewFlagSet("lightningos-manager", flag.ExitOnError)
   configPath := fs.String("config", "/etc/lightningos/config.yaml", "Path to config.yaml")
+  mock := fs.Bool("mock", false, "Serve a deterministic in-memory LND instead of connecting to a real node")
   _ = fs.Parse(args)
 
   cfg, err := config.Load(*configPath)
@@ -42,7 +65,15 @@ func runServer(args []string) {
   }
 
   logger := log.New(os.Stdout, "", log.LstdFlags)
-  srv := server.New(cfg, logger)
+  var srv *server.Server
+  if *mock {
+    logger.Printf("mock mode: serving in-memory LND data")
+    node := lndmock.Start(logger)
+    defer node.Stop()
+    srv = server.NewWithLND(cfg, logger, lndclient.NewWithDialer(cfg, logger, node.Dial))
+  } else {
+    srv = server.New(cfg, logger)
+  }
 
   if err := srv.Run(); err != nil {
     logger.Fatalf("server exited: %v", err)
@@ -52,6 +83,7 @@ func runServer(args []string) {
 func runReports(args []string) {
   fs := flag.NewFlagSet("reports-run", flag.ExitOnError)
   configPath := fs.String("config", "/etc/lightningos/config.yaml", "Path to config.yaml")
+  tzName := fs.String("tz", "", "Reporting timezone (IANA name), defaults to reports.timezone in config.yaml")
   dateStr := fs.String("date", "", "Report date (YYYY-MM-DD), defaults to yesterday")
   _ = fs.Parse(args)
 
@@ -81,7 +113,7 @@ func runReports(args []string) {
     logger.Fatalf("reports-run failed: %v", err)
   }
 
-  loc := time.Local
+  loc := reportsLocation(cfg, *tzName, logger)
   reportDate := time.Now().In(loc).AddDate(0, 0, -1)
   if strings.TrimSpace(*dateStr) != "" {
     parsed, err := reports.ParseDate(*dateStr, loc)
@@ -108,9 +140,13 @@ func runReports(args []string) {
 func runReportsBackfill(args []string) {
   fs := flag.NewFlagSet("reports-backfill", flag.ExitOnError)
   configPath := fs.String("config", "/etc/lightningos/config.yaml", "Path to config.yaml")
+  tzName := fs.String("tz", "", "Reporting timezone (IANA name), defaults to reports.timezone in config.yaml")
   fromStr := fs.String("from", "", "Start date (YYYY-MM-DD)")
   toStr := fs.String("to", "", "End date (YYYY-MM-DD)")
   maxDays := fs.Int("max-days", 0, "Override max range in days (0 uses default limit)")
+  dryRun := fs.Bool("dry-run", false, "List the days that would be computed and which are already stored")
+  workers := fs.Int("workers", 2, "Days computed in parallel")
+  restart := fs.Bool("restart", false, "Ignore saved progress and recompute the whole range")
   _ = fs.Parse(args)
 
   if strings.TrimSpace(*fromStr) == "" || strings.TrimSpace(*toStr) == "" {
@@ -143,7 +179,7 @@ func runReportsBackfill(args []string) {
   }
   schemaCancel()
 
-  loc := time.Local
+  loc := reportsLocation(cfg, *tzName, logger)
   startDate, err := reports.ParseDate(*fromStr, loc)
   if err != nil {
     logger.Fatalf("reports-backfill failed: invalid --from date")
@@ -164,31 +200,220 @@ func runReportsBackfill(args []string) {
     logger.Fatalf("reports-backfill failed: range too large (max %d days)", limit)
   }
 
-  logger.Printf("reports: backfill %s -> %s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
-
-  startLocal := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, loc)
-  endLocal := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 0, loc)
-  rebalanceByDay, err := reports.FetchRebalanceFeesByDay(context.Background(), lnd, uint64(startLocal.UTC().Unix()), uint64(endLocal.UTC().Unix()), loc)
-  if err != nil {
-    logger.Fatalf("reports-backfill failed: %v", err)
+  opts := reports.BackfillOptions{
+    Start: startDate,
+    End: endDate,
+    Loc: loc,
+    Workers: *workers,
+    Restart: *restart,
+    DayTimeout: reportsRunTimeout(),
   }
-  for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
-    dayCtx, dayCancel := context.WithTimeout(context.Background(), reportsRunTimeout())
-    dayKey := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
-    override := rebalanceByDay[dayKey]
-    row, err := svc.RunDaily(dayCtx, day, loc, &override)
-    dayCancel()
+
+  if *dryRun {
+    stateCtx, stateCancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer stateCancel()
+    pending, err := svc.BackfillPending(stateCtx, opts)
+    if err != nil {
+      logger.Fatalf("reports-backfill failed: %v", err)
+    }
+    if len(pending) == 0 {
+      logger.Printf("reports: backfill %s already completed (use --restart to recompute)", reports.BackfillRangeKey(startDate, endDate))
+      return
+    }
+    stored, err := reports.StoredDays(stateCtx, pool, pending[0], endDate)
     if err != nil {
-      logger.Fatalf("reports-backfill failed on %s: %v", day.Format("2006-01-02"), err)
+      logger.Fatalf("reports-backfill failed: %v", err)
     }
+    existing := 0
+    for _, day := range pending {
+      key := day.Format("2006-01-02")
+      state := "compute"
+      if stored[key] {
+        state = "recompute (stored)"
+        existing++
+      }
+      fmt.Printf("%s  %s\n", key, state)
+    }
+    logger.Printf("reports: dry run, %d days to compute (%d already stored)", len(pending), existing)
+    return
+  }
+
+  err = svc.Backfill(context.Background(), opts, func(p reports.BackfillProgress) {
     logger.Printf(
-      "reports: stored %s (revenue %d sats, cost %d sats, net %d sats)",
-      row.ReportDate.Format("2006-01-02"),
-      row.Metrics.ForwardFeeRevenueSat,
-      row.Metrics.RebalanceFeeCostSat,
-      row.Metrics.NetRoutingProfitSat,
+      "reports: [%d/%d] stored %s (revenue %d sats, cost %d sats, net %d sats)",
+      p.Done,
+      p.Total,
+      p.Row.ReportDate.Format("2006-01-02"),
+      p.Row.Metrics.ForwardFeeRevenueSat,
+      p.Row.Metrics.RebalanceFeeCostSat,
+      p.Row.Metrics.NetRoutingProfitSat,
     )
+  })
+  if err != nil {
+    logger.Fatalf("reports-backfill failed on %v (rerun the same range to resume)", err)
+  }
+}
+
+func runReportsExport(args []string) {
+  fs := flag.NewFlagSet("reports-export", flag.ExitOnError)
+  fromStr := fs.String("from", "", "Start date (YYYY-MM-DD), defaults to the first stored day")
+  toStr := fs.String("to", "", "End date (YYYY-MM-DD), defaults to the last stored day")
+  format := fs.String("format", "json", "Output format: json or csv")
+  outPath := fs.String("out", "", "Output file (defaults to stdout)")
+  _ = fs.Parse(args)
+
+  // Export output may go to stdout, so progress and errors go to stderr.
+  logger := log.New(os.Stderr, "", log.LstdFlags)
+  outFormat := strings.ToLower(strings.TrimSpace(*format))
+  if outFormat != "json" && outFormat != "csv" {
+    logger.Fatalf("reports-export failed: --format must be json or csv")
+  }
+  if (strings.TrimSpace(*fromStr) == "") != (strings.TrimSpace(*toStr) == "") {
+    logger.Fatalf("reports-export failed: --from and --to must be used together")
+  }
+
+  dsn, err := server.ResolveNotificationsDSN(logger)
+  if err != nil {
+    logger.Fatalf("reports-export failed: %v", err)
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), reportsRunTimeout())
+  defer cancel()
+
+  pool, err := pgxpool.New(ctx, dsn)
+  if err != nil {
+    logger.Fatalf("reports-export failed: %v", err)
+  }
+  defer pool.Close()
+
+  if err := reports.EnsureSchema(ctx, pool); err != nil {
+    logger.Fatalf("reports-export failed: %v", err)
+  }
+
+  var rows []reports.Row
+  if strings.TrimSpace(*fromStr) == "" {
+    rows, err = reports.FetchAll(ctx, pool)
+  } else {
+    loc := time.Local
+    startDate, parseErr := reports.ParseDate(*fromStr, loc)
+    if parseErr != nil {
+      logger.Fatalf("reports-export failed: invalid --from date")
+    }
+    endDate, parseErr := reports.ParseDate(*toStr, loc)
+    if parseErr != nil {
+      logger.Fatalf("reports-export failed: invalid --to date")
+    }
+    if endDate.Before(startDate) {
+      logger.Fatalf("reports-export failed: invalid range")
+    }
+    rows, err = reports.FetchRange(ctx, pool, startDate, endDate)
+  }
+  if err != nil {
+    logger.Fatalf("reports-export failed: %v", err)
+  }
+
+  out := os.Stdout
+  if strings.TrimSpace(*outPath) != "" {
+    file, err := os.Create(*outPath)
+    if err != nil {
+      logger.Fatalf("reports-export failed: %v", err)
+    }
+    defer file.Close()
+    out = file
+  }
+
+  if outFormat == "csv" {
+    err = reports.WriteCSV(out, rows)
+  } else {
+    err = reports.WriteJSON(out, rows)
+  }
+  if err != nil {
+    logger.Fatalf("reports-export failed: %v", err)
+  }
+  logger.Printf("reports: exported %d days", len(rows))
+}
+
+func runReportsVerify(args []string) {
+  fs := flag.NewFlagSet("reports-verify", flag.ExitOnError)
+  configPath := fs.String("config", "/etc/lightningos/config.yaml", "Path to config.yaml")
+  tzName := fs.String("tz", "", "Reporting timezone (IANA name), defaults to reports.timezone in config.yaml")
+  dateStr := fs.String("date", "", "Report date (YYYY-MM-DD), defaults to yesterday")
+  fix := fs.Bool("fix", false, "Store the recomputed metrics when drift is found")
+  _ = fs.Parse(args)
+
+  cfg, err := config.Load(*configPath)
+  if err != nil {
+    log.Fatalf("config load failed: %v", err)
+  }
+
+  logger := log.New(os.Stdout, "", log.LstdFlags)
+  dsn, err := server.ResolveNotificationsDSN(logger)
+  if err != nil {
+    logger.Fatalf("reports-verify failed: %v", err)
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), reportsRunTimeout())
+  defer cancel()
+
+  pool, err := pgxpool.New(ctx, dsn)
+  if err != nil {
+    logger.Fatalf("reports-verify failed: %v", err)
+  }
+  defer pool.Close()
+
+  lnd := lndclient.New(cfg, logger)
+  svc := reports.NewService(pool, lnd, logger)
+  if err := svc.EnsureSchema(ctx); err != nil {
+    logger.Fatalf("reports-verify failed: %v", err)
+  }
+
+  loc := reportsLocation(cfg, *tzName, logger)
+  reportDate := time.Now().In(loc).AddDate(0, 0, -1)
+  if strings.TrimSpace(*dateStr) != "" {
+    parsed, err := reports.ParseDate(*dateStr, loc)
+    if err != nil {
+      logger.Fatalf("reports-verify failed: invalid date")
+    }
+    reportDate = parsed
+  }
+
+  result, err := svc.Verify(ctx, reportDate, loc)
+  if err != nil {
+    logger.Fatalf("reports-verify failed: %v", err)
   }
+  day := result.Date.Format("2006-01-02")
+  if result.Stored == nil {
+    logger.Printf("reports: %s is not stored (computed %d forwards, revenue %d msat)", day, result.Computed.ForwardCount, result.Computed.ForwardFeeRevenueMsat)
+  } else if len(result.Diffs) == 0 {
+    logger.Printf("reports: %s matches LND", day)
+    return
+  } else {
+    logger.Printf("reports: %s drifted from LND", day)
+    for _, diff := range result.Diffs {
+      fmt.Printf("  %-26s stored=%d computed=%d delta=%+d\n", diff.Field, diff.Stored, diff.Computed, diff.Computed-diff.Stored)
+    }
+  }
+
+  if !*fix {
+    os.Exit(1)
+  }
+  if err := svc.Repair(ctx, result); err != nil {
+    logger.Fatalf("reports-verify failed: %v", err)
+  }
+  logger.Printf("reports: stored recomputed %s", day)
+}
+
+
+func reportsLocation(cfg *config.Config, override string, logger *log.Logger) *time.Location {
+  name := strings.TrimSpace(override)
+  if name == "" {
+    return cfg.ReportsLocation()
+  }
+  loc, err := time.LoadLocation(name)
+  if err != nil {
+    logger.Fatalf("invalid --tz %q: %v", name, err)
+  }
+  return loc
 }
 
 func reportsRunTimeout() time.Duration {
@@ -201,3 +426,106 @@ func reportsRunTimeout() time.Duration {
   }
   return 2 * time.Minute
 }
+
+func runNodeImport(args []string) {
+  fs := flag.NewFlagSet("node-import", flag.ExitOnError)
+  configPath := fs.String("config", "/etc/lightningos/config.yaml", "Path to config.yaml")
+  root := fs.String("source", "/", "Root of the old node's filesystem or data disk")
+  distro := fs.String("from", "", "Source distro: umbrel, raspiblitz or mynode (default: autodetect)")
+  withBitcoin := fs.Bool("with-bitcoin", false, "Also copy bitcoind blocks, chainstate and indexes to /data/bitcoin")
+  dryRun := fs.Bool("dry-run", false, "Only report what would be imported")
+  offline := fs.Bool("source-offline", false, "Confirm the old node is shut down and will never be started again")
+  _ = fs.Parse(args)
+
+  cfg, err := config.Load(*configPath)
+  if err != nil {
+    log.Fatalf("config load failed: %v", err)
+  }
+
+  logger := log.New(os.Stdout, "", log.LstdFlags)
+  if !*dryRun && !*offline {
+    logger.Fatalf("node-import: running the old node after the import loses funds; stop it for good and pass --source-offline")
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), 12*time.Hour)
+  defer cancel()
+
+  opts := server.NodeImportOptions{
+    Root: *root,
+    Distro: strings.ToLower(strings.TrimSpace(*distro)),
+    WithBitcoin: *withBitcoin,
+    DryRun: *dryRun,
+  }
+  if err := server.RunNodeImport(ctx, cfg, opts, logger); err != nil {
+    logger.Fatalf("node-import failed: %v", err)
+  }
+}
+
+// runSudoPolicy prints the sudoers rules the manager needs; with --check it
+// lists which of them sudo allows now and exits 1 when any is missing.
+func runSudoPolicy(args []string) {
+  fs := flag.NewFlagSet("sudo-policy", flag.ExitOnError)
+  configPath := fs.String("config", "/etc/lightningos/config.yaml", "Path to config.yaml")
+  check := fs.Bool("check", false, "Check each rule against the current sudo rules")
+  _ = fs.Parse(args)
+
+  cfg, err := config.Load(*configPath)
+  if err != nil {
+    log.Fatalf("config load failed: %v", err)
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+  defer cancel()
+  report := server.BuildSudoPolicy(ctx, cfg)
+  if !*check {
+    fmt.Print(report.Sudoers)
+    return
+  }
+  for _, rule := range report.Rules {
+    state := "ok"
+    if !rule.Allowed {
+      state = "missing"
+    }
+    if rule.Error != "" {
+      state += " (" + rule.Error + ")"
+    }
+    fmt.Printf("%-8s %s\n", state, rule.Line)
+  }
+  if report.Missing > 0 {
+    fmt.Printf("%d of %d rules missing for %s; write the output of sudo-policy to %s\n", report.Missing, len(report.Rules), report.User, report.Path)
+    os.Exit(1)
+  }
+}
+
+// runSecretsDecrypt turns a secrets export from the dashboard back into
+// secrets.env. The passphrase is read from the first line of stdin.
+func runSecretsDecrypt(args []string) {
+  fs := flag.NewFlagSet("secrets-decrypt", flag.ExitOnError)
+  in := fs.String("in", "", "Path to the lightningos-secrets-*.env.enc export or an encrypted Postgres dump")
+  out := fs.String("out", "", "Where to write secrets.env (default: stdout)")
+  _ = fs.Parse(args)
+  if *in == "" {
+    log.Fatalf("secrets-decrypt: -in is required")
+  }
+
+  blob, err := os.ReadFile(*in)
+  if err != nil {
+    log.Fatalf("secrets-decrypt: %v", err)
+  }
+  fmt.Fprint(os.Stderr, "Passphrase: ")
+  line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+  if err != nil && line == "" {
+    log.Fatalf("secrets-decrypt: no passphrase on stdin")
+  }
+  fmt.Fprintln(os.Stderr)
+  plain, err := server.OpenSecretsExport(blob, strings.TrimRight(line, "\r\n"))
+  if err != nil {
+    log.Fatalf("secrets-decrypt: %v", err)
+  }
+  if *out == "" {
+    _, _ = os.Stdout.Write(plain)
+    return
+  }
+  if err := os.WriteFile(*out, plain, 0o600); err != nil {
+    log.Fatalf("secrets-decrypt: %v", err)
+  }
+}
@@ -12,6 +12,7 @@ GOTTY_VERSION="${GOTTY_VERSION:-1.0.1}"
 GOTTY_URL="https://github.com/yudai/gotty/releases/download/v${GOTTY_VERSION}/gotty_linux_amd64.tar.gz"
 POSTGRES_VERSION="${POSTGRES_VERSION:-latest}"
 LND_FIX_PERMS_SCRIPT="/usr/local/sbin/lightningos-fix-lnd-perms"
+SSH_ACCESS_SCRIPT="/usr/local/sbin/lightningos-ssh-access"
 
 CURRENT_STEP=""
 LOG_FILE="/var/log/lightningos-install-existing.log"
@@ -334,26 +335,42 @@ install_lnd_fix_perms_script() {
   else
     print_warn "Missing helper script: $src"
   fi
+  local ssh_src="$REPO_ROOT/scripts/ssh-access.sh"
+  if [[ -f "$ssh_src" ]]; then
+    install -m 0755 "$ssh_src" "$SSH_ACCESS_SCRIPT"
+    print_ok "SSH access helper installed"
+  else
+    print_warn "Missing helper script: $ssh_src"
+  fi
 }
 
 configure_sudoers() {
   print_step "Configuring sudoers"
-  local systemctl_path apt_get_path apt_path dpkg_path docker_path docker_compose_path systemd_run_path smartctl_path ufw_path
+  local systemctl_path apt_get_path apt_path dpkg_path docker_path docker_compose_path podman_path podman_compose_path systemd_run_path smartctl_path ufw_path nft_path
   systemctl_path=$(command -v systemctl || true)
   apt_get_path=$(command -v apt-get || true)
   apt_path=$(command -v apt || true)
   dpkg_path=$(command -v dpkg || true)
   docker_path=$(command -v docker || true)
   docker_compose_path=$(command -v docker-compose || true)
+  podman_path=$(command -v podman || true)
+  podman_compose_path=$(command -v podman-compose || true)
   systemd_run_path=$(command -v systemd-run || true)
   smartctl_path=$(command -v smartctl || true)
   ufw_path=$(command -v ufw || true)
+  nft_path=$(command -v nft || true)
   if [[ -z "$docker_path" ]]; then
     docker_path="/usr/bin/docker"
   fi
   if [[ -z "$docker_compose_path" ]]; then
     docker_compose_path="/usr/bin/docker-compose"
   fi
+  if [[ -z "$podman_path" ]]; then
+    podman_path="/usr/bin/podman"
+  fi
+  if [[ -z "$podman_compose_path" ]]; then
+    podman_compose_path="/usr/bin/podman-compose"
+  fi
   if [[ -z "$systemd_run_path" ]]; then
     systemd_run_path="/usr/bin/systemd-run"
   fi
@@ -365,15 +382,18 @@ configure_sudoers() {
     return
   fi
   local system_cmds
-  system_cmds="${systemctl_path} restart lnd, ${systemctl_path} restart lightningos-manager, ${systemctl_path} restart postgresql, ${systemctl_path} reboot, ${systemctl_path} poweroff, ${LND_FIX_PERMS_SCRIPT}, ${smartctl_path} *"
+  system_cmds="${systemctl_path} restart lnd, ${systemctl_path} stop lnd, ${systemctl_path} start lnd, ${systemctl_path} restart lightningos-manager, ${systemctl_path} restart postgresql, ${systemctl_path} reboot, ${systemctl_path} poweroff, ${LND_FIX_PERMS_SCRIPT}, ${SSH_ACCESS_SCRIPT} *, ${smartctl_path} *"
   local app_cmds=()
   [[ -n "$apt_get_path" ]] && app_cmds+=("${apt_get_path} *")
   [[ -n "$apt_path" ]] && app_cmds+=("${apt_path} *")
   [[ -n "$dpkg_path" ]] && app_cmds+=("${dpkg_path} *")
   [[ -n "$docker_path" ]] && app_cmds+=("${docker_path} *")
   [[ -n "$docker_compose_path" ]] && app_cmds+=("${docker_compose_path} *")
+  [[ -n "$podman_path" ]] && app_cmds+=("${podman_path} *")
+  [[ -n "$podman_compose_path" ]] && app_cmds+=("${podman_compose_path} *")
   [[ -n "$systemd_run_path" ]] && app_cmds+=("${systemd_run_path} *")
   [[ -n "$ufw_path" ]] && app_cmds+=("${ufw_path} *")
+  [[ -n "$nft_path" ]] && app_cmds+=("${nft_path} list ruleset")
   local app_cmds_line
   app_cmds_line=$(IFS=", "; echo "${app_cmds[*]}")
   if [[ -z "$app_cmds_line" ]]; then
@@ -3,10 +3,18 @@ package config
 import (
   "fmt"
   "os"
+  "regexp"
+  "strings"
+  "time"
 
   "gopkg.in/yaml.v3"
 )
 
+// DefaultNodeID identifies the primary node configured under lnd.
+const DefaultNodeID = "default"
+
+var nodeIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
 type Config struct {
   Server ServerConfig `yaml:"server"`
   LND    LNDConfig    `yaml:"lnd"`
@@ -14,6 +22,350 @@ type Config struct {
   Postgres PostgresConfig `yaml:"postgres"`
   UI UIConfig `yaml:"ui"`
   Features FeaturesConfig `yaml:"features"`
+  Nodes []NodeConfig `yaml:"nodes"`
+  Network string `yaml:"network"`
+  Reports ReportsConfig `yaml:"reports"`
+  Timeouts TimeoutsConfig `yaml:"timeouts"`
+  CrashReporting CrashReportingConfig `yaml:"crash_reporting"`
+  FeeGuard FeeGuardConfig `yaml:"fee_guard"`
+  Liquidity LiquidityConfig `yaml:"liquidity"`
+  PublicStatus PublicStatusConfig `yaml:"public_status"`
+  Mempool MempoolConfig `yaml:"mempool"`
+  Backups BackupsConfig `yaml:"backups"`
+  LNDDBCheck LNDDBCheckConfig `yaml:"lnd_db_check"`
+  BitcoinEvents BitcoinEventsConfig `yaml:"bitcoin_events"`
+  InboundExperiments InboundExperimentsConfig `yaml:"inbound_experiments"`
+  Apps AppsConfig `yaml:"apps"`
+  Intrusion IntrusionConfig `yaml:"intrusion"`
+  // Path is the file the config was loaded from, used to reload it.
+  Path string `yaml:"-"`
+}
+
+type ReportsConfig struct {
+  // Timezone is an IANA zone name (e.g. America/Sao_Paulo) that sets the day
+  // boundaries of stored reports. Empty uses the system local time.
+  Timezone string `yaml:"timezone"`
+}
+
+// CrashReportingConfig opts in to sending recovered panics to a self-hosted
+// Sentry-compatible server. Crashes are always kept in the local crash log.
+type CrashReportingConfig struct {
+  SentryDSN string `yaml:"sentry_dsn"`
+  Environment string `yaml:"environment"`
+}
+
+// DefaultMaxSatPerVbyte caps fee rates when fee_guard leaves a cap unset.
+const DefaultMaxSatPerVbyte = 1000
+
+// FeeGuardConfig caps the sat_per_vbyte accepted on channel opens, closes
+// and on-chain sends, so a typo like 5000 is refused unless the request
+// explicitly overrides it. Zero uses DefaultMaxSatPerVbyte; a negative cap
+// disables the check for that operation.
+type FeeGuardConfig struct {
+  MaxOpenSatPerVbyte int64 `yaml:"max_open_sat_per_vbyte"`
+  MaxCloseSatPerVbyte int64 `yaml:"max_close_sat_per_vbyte"`
+  MaxSendSatPerVbyte int64 `yaml:"max_send_sat_per_vbyte"`
+}
+
+// Max returns the cap for open, close or send, or 0 when it is disabled.
+func (c FeeGuardConfig) Max(operation string) int64 {
+  value := int64(0)
+  switch operation {
+  case "open":
+    value = c.MaxOpenSatPerVbyte
+  case "close":
+    value = c.MaxCloseSatPerVbyte
+  case "send":
+    value = c.MaxSendSatPerVbyte
+  }
+  if value < 0 {
+    return 0
+  }
+  if value == 0 {
+    return DefaultMaxSatPerVbyte
+  }
+  return value
+}
+
+// DefaultMinReceivableSat is the receivable amount below which the health
+// check warns when liquidity leaves it unset.
+const DefaultMinReceivableSat = 100000
+
+// LiquidityConfig sets when inbound liquidity is reported as low. Zero uses
+// DefaultMinReceivableSat; a negative value turns the warning off.
+type LiquidityConfig struct {
+  MinReceivableSat int64 `yaml:"min_receivable_sat"`
+}
+
+// MinReceivable returns the warning threshold, or 0 when it is off.
+func (c LiquidityConfig) MinReceivable() int64 {
+  if c.MinReceivableSat < 0 {
+    return 0
+  }
+  if c.MinReceivableSat == 0 {
+    return DefaultMinReceivableSat
+  }
+  return c.MinReceivableSat
+}
+
+// DefaultPublicStatusCacheSeconds is how long the public status page is
+// cached when public_status leaves it unset.
+const DefaultPublicStatusCacheSeconds = 60
+
+// PublicStatusConfig turns on the unauthenticated status page. It is off
+// unless enabled is set.
+type PublicStatusConfig struct {
+  Enabled bool `yaml:"enabled"`
+  CacheSeconds int `yaml:"cache_seconds"`
+}
+
+// CacheTTL returns how long a status snapshot is served, at least 10s.
+func (c PublicStatusConfig) CacheTTL() time.Duration {
+  seconds := c.CacheSeconds
+  if seconds <= 0 {
+    seconds = DefaultPublicStatusCacheSeconds
+  }
+  if seconds < 10 {
+    seconds = 10
+  }
+  return time.Duration(seconds) * time.Second
+}
+
+// DefaultMempoolRequestsPerMinute caps calls to each mempool instance when
+// mempool leaves it unset.
+const DefaultMempoolRequestsPerMinute = 60
+
+// MempoolConfig points explorer lookups (fee rates, node sockets, rankings)
+// at self-hosted mempool instances. URLs are tried in order, each the base
+// for the configured network (e.g. https://mempool.lan or
+// https://mempool.lan/testnet); the public mempool.space follows unless
+// public_fallback is false.
+type MempoolConfig struct {
+  URLs []string `yaml:"urls"`
+  PublicFallback *bool `yaml:"public_fallback"`
+  RequestsPerMinute int `yaml:"requests_per_minute"`
+}
+
+// PublicFallbackEnabled reports whether mempool.space is used after the
+// configured instances.
+func (c MempoolConfig) PublicFallbackEnabled() bool {
+  return c.PublicFallback == nil || *c.PublicFallback
+}
+
+// RequestRate returns the per-instance limit in requests per minute.
+func (c MempoolConfig) RequestRate() int {
+  if c.RequestsPerMinute <= 0 {
+    return DefaultMempoolRequestsPerMinute
+  }
+  return c.RequestsPerMinute
+}
+
+// Default backup ages, in hours, past which the health check warns.
+const (
+  DefaultSCBBackupMaxAgeHours = 48
+  DefaultSecretsBackupMaxAgeHours = 30 * 24
+  DefaultPostgresBackupMaxAgeHours = 7 * 24
+)
+
+// BackupsConfig sets how old the latest SCB export, secrets backup and
+// Postgres dump may get. Past the age the health check warns, past twice
+// the age it errors. Zero uses the default; a negative age turns the check
+// off for that backup.
+type BackupsConfig struct {
+  SCBMaxAgeHours int `yaml:"scb_max_age_hours"`
+  SecretsMaxAgeHours int `yaml:"secrets_max_age_hours"`
+  PostgresMaxAgeHours int `yaml:"postgres_max_age_hours"`
+}
+
+// MaxAge returns the threshold for scb, secrets or postgres, or 0 when that
+// check is off.
+func (c BackupsConfig) MaxAge(kind string) time.Duration {
+  hours, fallback := 0, 0
+  switch kind {
+  case "scb":
+    hours, fallback = c.SCBMaxAgeHours, DefaultSCBBackupMaxAgeHours
+  case "secrets":
+    hours, fallback = c.SecretsMaxAgeHours, DefaultSecretsBackupMaxAgeHours
+  case "postgres":
+    hours, fallback = c.PostgresMaxAgeHours, DefaultPostgresBackupMaxAgeHours
+  }
+  if hours < 0 {
+    return 0
+  }
+  if hours == 0 {
+    hours = fallback
+  }
+  return time.Duration(hours) * time.Hour
+}
+
+// LNDDBCheckConfig schedules a weekly integrity check of LND's database. It
+// is off unless enabled; weekday (sunday to saturday) and hour (0-23, local
+// time) set the maintenance window, Sunday 04:00 by default.
+type LNDDBCheckConfig struct {
+  Enabled bool `yaml:"enabled"`
+  Weekday string `yaml:"weekday"`
+  Hour *int `yaml:"hour"`
+}
+
+// Window returns the weekday and hour the check starts in.
+func (c LNDDBCheckConfig) Window() (time.Weekday, int) {
+  day := time.Sunday
+  for d := time.Sunday; d <= time.Saturday; d++ {
+    if strings.EqualFold(strings.TrimSpace(c.Weekday), d.String()) {
+      day = d
+    }
+  }
+  hour := 4
+  if c.Hour != nil && *c.Hour >= 0 && *c.Hour <= 23 {
+    hour = *c.Hour
+  }
+  return day, hour
+}
+
+// DefaultBitcoinBehindBlocks is how many blocks the node may trail the
+// explorers before the behind alert fires.
+const DefaultBitcoinBehindBlocks = 3
+
+// BitcoinEventsConfig controls the events raised from the active bitcoind.
+// New-block notifications are off unless enabled. behind_blocks sets how far
+// the node may trail the mempool explorers; zero uses the default and a
+// negative value turns the alert off.
+type BitcoinEventsConfig struct {
+  BlockNotifications bool `yaml:"block_notifications"`
+  BehindBlocks int `yaml:"behind_blocks"`
+}
+
+// BehindThreshold returns the lag in blocks that raises the alert, or 0
+// when the alert is off.
+func (c BitcoinEventsConfig) BehindThreshold() int64 {
+  if c.BehindBlocks < 0 {
+    return 0
+  }
+  if c.BehindBlocks == 0 {
+    return DefaultBitcoinBehindBlocks
+  }
+  return int64(c.BehindBlocks)
+}
+
+// Defaults for the inbound fee experiments.
+const (
+  DefaultInboundDiscountPpm = 100
+  DefaultInboundTrialDays = 7
+  DefaultInboundDrainedPercent = 20
+)
+
+// InboundExperimentsConfig runs inbound fee discount experiments on their
+// own: the drained channels (local balance under drained_percent of the
+// capacity) are split in two groups, one gets an inbound fee of
+// -discount_ppm for trial_days and the other stays as the control. Off
+// unless enabled; zero values use the defaults.
+type InboundExperimentsConfig struct {
+  Enabled bool `yaml:"enabled"`
+  DiscountPpm int64 `yaml:"discount_ppm"`
+  TrialDays int `yaml:"trial_days"`
+  DrainedPercent int `yaml:"drained_percent"`
+}
+
+// Discount returns the discount in ppm, as a positive number.
+func (c InboundExperimentsConfig) Discount() int64 {
+  if c.DiscountPpm < 0 {
+    return -c.DiscountPpm
+  }
+  if c.DiscountPpm == 0 {
+    return DefaultInboundDiscountPpm
+  }
+  return c.DiscountPpm
+}
+
+// Trial returns the length of the trial window.
+func (c InboundExperimentsConfig) Trial() time.Duration {
+  days := c.TrialDays
+  if days <= 0 {
+    days = DefaultInboundTrialDays
+  }
+  return time.Duration(days) * 24 * time.Hour
+}
+
+// DrainedShare returns the local balance share under which a channel counts
+// as drained.
+func (c InboundExperimentsConfig) DrainedShare() float64 {
+  percent := c.DrainedPercent
+  if percent <= 0 || percent >= 100 {
+    percent = DefaultInboundDrainedPercent
+  }
+  return float64(percent) / 100
+}
+
+// DefaultAppsCatalogRefreshMinutes is how long a fetched apps registry is
+// used before it is fetched again.
+const DefaultAppsCatalogRefreshMinutes = 360
+
+// AppsConfig points the apps catalog at a remote registry, a signed JSON
+// document listing apps and their versions. A registry is only used when
+// its signature checks out against catalog_public_key (base64 ed25519);
+// without catalog_url the catalog lists the built-in apps.
+type AppsConfig struct {
+  CatalogURL string `yaml:"catalog_url"`
+  CatalogPublicKey string `yaml:"catalog_public_key"`
+  CatalogRefreshMinutes int `yaml:"catalog_refresh_minutes"`
+  // ContainerRuntime is auto (default), docker or podman.
+  ContainerRuntime string `yaml:"container_runtime"`
+}
+
+// CatalogRefresh returns how long a fetched registry stays fresh.
+func (c AppsConfig) CatalogRefresh() time.Duration {
+  minutes := c.CatalogRefreshMinutes
+  if minutes <= 0 {
+    minutes = DefaultAppsCatalogRefreshMinutes
+  }
+  return time.Duration(minutes) * time.Minute
+}
+
+// Defaults for the intrusion monitor.
+const (
+  DefaultIntrusionBurstAttempts = 10
+  DefaultIntrusionBurstMinutes = 10
+  DefaultIntrusionBlockMinutes = 60
+)
+
+// IntrusionConfig sets when failed logins from one address (to the manager
+// or over SSH) count as a burst, which raises a notification:
+// burst_attempts within burst_minutes. With auto_block a burst also blocks
+// the address in ufw for block_minutes. Zero values use the defaults.
+type IntrusionConfig struct {
+  BurstAttempts int `yaml:"burst_attempts"`
+  BurstMinutes int `yaml:"burst_minutes"`
+  AutoBlock bool `yaml:"auto_block"`
+  BlockMinutes int `yaml:"block_minutes"`
+}
+
+// Burst returns the attempts and the window that make a burst.
+func (c IntrusionConfig) Burst() (int, time.Duration) {
+  attempts, minutes := c.BurstAttempts, c.BurstMinutes
+  if attempts <= 0 {
+    attempts = DefaultIntrusionBurstAttempts
+  }
+  if minutes <= 0 {
+    minutes = DefaultIntrusionBurstMinutes
+  }
+  return attempts, time.Duration(minutes) * time.Minute
+}
+
+// BlockFor returns how long an automatic block lasts.
+func (c IntrusionConfig) BlockFor() time.Duration {
+  minutes := c.BlockMinutes
+  if minutes <= 0 {
+    minutes = DefaultIntrusionBlockMinutes
+  }
+  return time.Duration(minutes) * time.Minute
+}
+
+// NodeConfig describes an additional LND node managed alongside the primary
+// one configured under lnd.
+type NodeConfig struct {
+  ID string `yaml:"id"`
+  Name string `yaml:"name"`
+  LND LNDConfig `yaml:"lnd"`
 }
 
 type ServerConfig struct {
@@ -21,6 +373,26 @@ type ServerConfig struct {
   Port    int    `yaml:"port"`
   TLSCert string `yaml:"tls_cert"`
   TLSKey  string `yaml:"tls_key"`
+  // BasePath publishes the manager under a sub-path (e.g. /node) behind a
+  // reverse proxy.
+  BasePath string `yaml:"base_path"`
+  // TrustedProxies lists proxy IPs/CIDRs whose X-Forwarded-* headers are
+  // honored.
+  TrustedProxies []string `yaml:"trusted_proxies"`
+  // RateLimit caps API requests per client IP.
+  RateLimit RateLimitConfig `yaml:"rate_limit"`
+  // PrivilegeMode is how the manager gets root: sudo (default) or polkit,
+  // which runs it unprivileged and controls services through systemd's
+  // D-Bus API under polkit rules.
+  PrivilegeMode string `yaml:"privilege_mode"`
+}
+
+// RateLimitConfig is a per-client token bucket. Zero values use the
+// defaults (20 requests/s, burst of 100); a negative RequestsPerSecond
+// disables the limiter.
+type RateLimitConfig struct {
+  RequestsPerSecond float64 `yaml:"requests_per_second"`
+  Burst int `yaml:"burst"`
 }
 
 type LNDConfig struct {
@@ -33,6 +405,13 @@ type BitcoinRemoteConfig struct {
   RPCHost   string `yaml:"rpchost"`
   ZMQRawBlock string `yaml:"zmq_rawblock"`
   ZMQRawTx    string `yaml:"zmq_rawtx"`
+  // RPCProxy is a socks5:// URL the manager reaches rpchost through. Onion
+  // hosts use Tor's 127.0.0.1:9050 when it is empty.
+  RPCProxy string `yaml:"rpc_proxy"`
+  // RPCTLSFingerprint pins the SHA-256 of the remote RPC certificate (hex,
+  // colons allowed). When set, rpchost is always dialed over HTTPS and a
+  // self-signed certificate is accepted only if it matches.
+  RPCTLSFingerprint string `yaml:"rpc_tls_fingerprint"`
 }
 
 type PostgresConfig struct {
@@ -41,6 +420,12 @@ type PostgresConfig struct {
 
 type UIConfig struct {
   StaticDir string `yaml:"static_dir"`
+  // ServeFromDisk serves static_dir even when the binary embeds the UI, so a
+  // fresh `npm run build` shows up without recompiling.
+  ServeFromDisk bool `yaml:"serve_from_disk"`
+  // Language is the default language (en or pt-BR) of API messages when the
+  // client sends no usable Accept-Language header.
+  Language string `yaml:"language"`
 }
 
 type FeaturesConfig struct {
@@ -72,10 +457,102 @@ func Load(path string) (*Config, error) {
   if cfg.UI.StaticDir == "" {
     cfg.UI.StaticDir = "/opt/lightningos/ui"
   }
+  cfg.Network = strings.ToLower(strings.TrimSpace(cfg.Network))
+  if cfg.Network == "" {
+    cfg.Network = "mainnet"
+  }
+  switch cfg.Network {
+  case "mainnet", "testnet", "signet", "regtest":
+  default:
+    return nil, fmt.Errorf("unsupported network %q (use mainnet, testnet, signet or regtest)", cfg.Network)
+  }
+  // Configs written before other networks were supported name the mainnet
+  // macaroon, so that path follows the network too.
+  if macaroon := strings.TrimSpace(cfg.LND.AdminMacaroonPath); macaroon == "" || macaroon == DefaultAdminMacaroonPath("mainnet") {
+    cfg.LND.AdminMacaroonPath = DefaultAdminMacaroonPath(cfg.Network)
+  }
+
+  cfg.Reports.Timezone = strings.TrimSpace(cfg.Reports.Timezone)
+  if cfg.Reports.Timezone != "" {
+    if _, err := time.LoadLocation(cfg.Reports.Timezone); err != nil {
+      return nil, fmt.Errorf("invalid reports timezone %q: %w", cfg.Reports.Timezone, err)
+    }
+  }
+
+  cfg.Apps.ContainerRuntime = strings.ToLower(strings.TrimSpace(cfg.Apps.ContainerRuntime))
+  switch cfg.Apps.ContainerRuntime {
+  case "", "auto", "docker", "podman":
+  default:
+    return nil, fmt.Errorf("unsupported apps container_runtime %q (use auto, docker or podman)", cfg.Apps.ContainerRuntime)
+  }
+
+  cfg.Server.PrivilegeMode = strings.ToLower(strings.TrimSpace(cfg.Server.PrivilegeMode))
+  switch cfg.Server.PrivilegeMode {
+  case "", "sudo", "polkit":
+  default:
+    return nil, fmt.Errorf("unsupported server privilege_mode %q (use sudo or polkit)", cfg.Server.PrivilegeMode)
+  }
+
+  if err := cfg.Timeouts.applyDefaults(); err != nil {
+    return nil, err
+  }
 
   if cfg.Server.TLSCert == "" || cfg.Server.TLSKey == "" {
     return nil, fmt.Errorf("server TLS cert/key required")
   }
+  if err := validateNodes(cfg.Nodes); err != nil {
+    return nil, err
+  }
 
+  cfg.Path = path
   return &cfg, nil
 }
+
+// DefaultAdminMacaroonPath is where LND keeps its admin macaroon for network.
+func DefaultAdminMacaroonPath(network string) string {
+  return fmt.Sprintf("/data/lnd/data/chain/bitcoin/%s/admin.macaroon", network)
+}
+
+// ReportsLocation returns the reporting timezone, falling back to the system
+// local time when none is configured.
+func (c *Config) ReportsLocation() *time.Location {
+  if c == nil || c.Reports.Timezone == "" {
+    return time.Local
+  }
+  loc, err := time.LoadLocation(c.Reports.Timezone)
+  if err != nil {
+    return time.Local
+  }
+  return loc
+}
+
+// ForNode returns a copy of the config pointing at the given node's LND.
+func (c *Config) ForNode(node NodeConfig) *Config {
+  copied := *c
+  copied.LND = node.LND
+  return &copied
+}
+
+func validateNodes(nodes []NodeConfig) error {
+  seen := map[string]bool{DefaultNodeID: true}
+  for _, node := range nodes {
+    id := strings.TrimSpace(node.ID)
+    if id == "" {
+      return fmt.Errorf("node id required")
+    }
+    if !nodeIDPattern.MatchString(id) {
+      return fmt.Errorf("invalid node id %q (use lowercase letters, digits and dashes)", id)
+    }
+    if seen[id] {
+      return fmt.Errorf("duplicate node id %q", id)
+    }
+    seen[id] = true
+    if strings.TrimSpace(node.LND.GRPCHost) == "" {
+      return fmt.Errorf("node %q: lnd grpc_host required", id)
+    }
+    if strings.TrimSpace(node.LND.TLSCertPath) == "" || strings.TrimSpace(node.LND.AdminMacaroonPath) == "" {
+      return fmt.Errorf("node %q: lnd tls_cert_path and admin_macaroon_path required", id)
+    }
+  }
+  return nil
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+  "fmt"
+  "os"
+  "strings"
+  "time"
+
+  "gopkg.in/yaml.v3"
+)
+
+// TimeoutsConfig sets how long API handlers wait on LND, Bitcoin and the
+// database. Handlers pick a class that matches the work they do; endpoints
+// lists per-route overrides keyed by the route pattern, optionally prefixed
+// with the method (e.g. "POST /api/wallet/pay": 90s).
+type TimeoutsConfig struct {
+  // Probe covers quick status checks that should fail fast.
+  Probe time.Duration `yaml:"probe"`
+  // Short covers single database queries and light LND calls.
+  Short time.Duration `yaml:"short"`
+  // Medium covers handlers that fan out to a few backends.
+  Medium time.Duration `yaml:"medium"`
+  // LNDRPC is the default for LND reads and simple updates.
+  LNDRPC time.Duration `yaml:"lnd_rpc"`
+  // Long covers slow RPCs such as channel opens and closes.
+  Long time.Duration `yaml:"long"`
+  // LNDWrite covers payments and on-chain sends.
+  LNDWrite time.Duration `yaml:"lnd_write"`
+  // Health is the budget of /api/health: the LND, Bitcoin and Postgres
+  // checks run at once and any still running then are reported as timed
+  // out.
+  Health time.Duration `yaml:"health"`
+  Endpoints map[string]time.Duration `yaml:"endpoints"`
+  // RetryReads retries idempotent LND reads once when they hit a transient
+  // deadline or unavailable error. Defaults to true.
+  RetryReads *bool `yaml:"retry_reads"`
+  // Adaptive stretches the lnd_rpc and long deadlines of a route whose
+  // recent requests were slow, up to AdaptiveMax, so a large node's
+  // ListChannels gets the time it needs. Defaults to true.
+  Adaptive *bool `yaml:"adaptive"`
+  AdaptiveMax time.Duration `yaml:"adaptive_max"`
+}
+
+// DefaultTimeouts returns the built-in timeouts.
+func DefaultTimeouts() TimeoutsConfig {
+  retry := true
+  adaptive := true
+  return TimeoutsConfig{
+    Probe: 4 * time.Second,
+    Short: 6 * time.Second,
+    Medium: 12 * time.Second,
+    LNDRPC: 15 * time.Second,
+    Long: 30 * time.Second,
+    LNDWrite: 45 * time.Second,
+    Health: 3 * time.Second,
+    RetryReads: &retry,
+    Adaptive: &adaptive,
+    AdaptiveMax: 90 * time.Second,
+  }
+}
+
+// RetryReadsEnabled reports whether idempotent LND reads are retried.
+func (t TimeoutsConfig) RetryReadsEnabled() bool {
+  return t.RetryReads == nil || *t.RetryReads
+}
+
+// AdaptiveEnabled reports whether slow routes get stretched deadlines.
+func (t TimeoutsConfig) AdaptiveEnabled() bool {
+  return t.Adaptive == nil || *t.Adaptive
+}
+
+func (t *TimeoutsConfig) applyDefaults() error {
+  defaults := DefaultTimeouts()
+  fields := []struct {
+    name string
+    value *time.Duration
+    fallback time.Duration
+  }{
+    {"probe", &t.Probe, defaults.Probe},
+    {"short", &t.Short, defaults.Short},
+    {"medium", &t.Medium, defaults.Medium},
+    {"lnd_rpc", &t.LNDRPC, defaults.LNDRPC},
+    {"long", &t.Long, defaults.Long},
+    {"lnd_write", &t.LNDWrite, defaults.LNDWrite},
+    {"health", &t.Health, defaults.Health},
+    {"adaptive_max", &t.AdaptiveMax, defaults.AdaptiveMax},
+  }
+  for _, field := range fields {
+    if *field.value < 0 {
+      return fmt.Errorf("timeouts.%s must not be negative", field.name)
+    }
+    if *field.value == 0 {
+      *field.value = field.fallback
+    }
+  }
+  if t.RetryReads == nil {
+    t.RetryReads = defaults.RetryReads
+  }
+  if t.Adaptive == nil {
+    t.Adaptive = defaults.Adaptive
+  }
+  normalized := map[string]time.Duration{}
+  for key, value := range t.Endpoints {
+    if value <= 0 {
+      return fmt.Errorf("timeouts.endpoints %q must be positive", key)
+    }
+    normalized[normalizeEndpointKey(key)] = value
+  }
+  t.Endpoints = normalized
+  return nil
+}
+
+func normalizeEndpointKey(key string) string {
+  fields := strings.Fields(key)
+  if len(fields) == 2 {
+    return strings.ToUpper(fields[0]) + " " + fields[1]
+  }
+  return strings.TrimSpace(key)
+}
+
+// Endpoint returns the override for a route, preferring a "METHOD pattern"
+// entry over a bare pattern.
+func (t TimeoutsConfig) Endpoint(method, pattern string) (time.Duration, bool) {
+  if value, ok := t.Endpoints[strings.ToUpper(method)+" "+pattern]; ok {
+    return value, true
+  }
+  value, ok := t.Endpoints[pattern]
+  return value, ok
+}
+
+// LoadTimeouts re-reads only the timeouts section of a config file, for live
+// reload.
+func LoadTimeouts(path string) (TimeoutsConfig, error) {
+  b, err := os.ReadFile(path)
+  if err != nil {
+    return TimeoutsConfig{}, err
+  }
+  var partial struct {
+    Timeouts TimeoutsConfig `yaml:"timeouts"`
+  }
+  if err := yaml.Unmarshal(b, &partial); err != nil {
+    return TimeoutsConfig{}, err
+  }
+  if err := partial.Timeouts.applyDefaults(); err != nil {
+    return TimeoutsConfig{}, err
+  }
+  return partial.Timeouts, nil
+}
@@ -0,0 +1,101 @@
+// Package i18n translates the messages handlers send back to the UI (errors,
+// warnings, health issues). Messages are written in English in the code and
+// looked up in per-language catalogs; anything missing stays in English.
+package i18n
+
+import (
+  "sort"
+  "strconv"
+  "strings"
+)
+
+const (
+  English = "en"
+  Portuguese = "pt-BR"
+)
+
+var catalogs = map[string]map[string]string{
+  Portuguese: portuguese,
+}
+
+// Supported reports whether lang has a catalog (or is the source language).
+func Supported(lang string) bool {
+  if lang == English {
+    return true
+  }
+  _, ok := catalogs[lang]
+  return ok
+}
+
+// Normalize maps tags like "pt", "pt_br" or "PT-br" to a supported language,
+// or returns "" when there is none.
+func Normalize(tag string) string {
+  tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+  switch {
+  case tag == "":
+    return ""
+  case tag == "en" || strings.HasPrefix(tag, "en-"):
+    return English
+  case tag == "pt" || strings.HasPrefix(tag, "pt-"):
+    return Portuguese
+  }
+  return ""
+}
+
+// Resolve picks the best language from an Accept-Language header, falling
+// back to fallback (then English) when nothing matches.
+func Resolve(acceptLanguage string, fallback string) string {
+  type candidate struct {
+    lang string
+    q float64
+  }
+  candidates := []candidate{}
+  for _, part := range strings.Split(acceptLanguage, ",") {
+    fields := strings.Split(part, ";")
+    lang := Normalize(fields[0])
+    if lang == "" {
+      continue
+    }
+    q := 1.0
+    for _, param := range fields[1:] {
+      param = strings.TrimSpace(param)
+      if strings.HasPrefix(param, "q=") {
+        if parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+          q = parsed
+        }
+      }
+    }
+    if q > 0 {
+      candidates = append(candidates, candidate{lang: lang, q: q})
+    }
+  }
+  if len(candidates) > 0 {
+    sort.SliceStable(candidates, func(i, j int) bool {
+      return candidates[i].q > candidates[j].q
+    })
+    return candidates[0].lang
+  }
+  if lang := Normalize(fallback); lang != "" {
+    return lang
+  }
+  return English
+}
+
+// Translate returns message in lang. Messages of the form "prefix: detail"
+// get the prefix translated when the full message is not in the catalog, so
+// wrapped errors still read naturally.
+func Translate(lang string, message string) string {
+  catalog := catalogs[lang]
+  if catalog == nil || message == "" {
+    return message
+  }
+  if translated, ok := catalog[message]; ok {
+    return translated
+  }
+  if idx := strings.Index(message, ": "); idx > 0 {
+    if translated, ok := catalog[message[:idx]]; ok {
+      return translated + message[idx:]
+    }
+  }
+  return message
+}
@@ -0,0 +1,39 @@
+package i18n
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+  cases := []struct {
+    header string
+    fallback string
+    want string
+  }{
+    {"pt-BR,pt;q=0.9,en;q=0.8", "", Portuguese},
+    {"en-US,en;q=0.9,pt;q=0.8", Portuguese, English},
+    {"de-DE,pt;q=0.5", "", Portuguese},
+    {"fr-FR", "pt", Portuguese},
+    {"en;q=0.2,pt-PT;q=0.7", "", Portuguese},
+    {"pt;q=0", "", English},
+    {"", "", English},
+  }
+  for _, tc := range cases {
+    if got := Resolve(tc.header, tc.fallback); got != tc.want {
+      t.Fatalf("Resolve(%q, %q) = %q, want %q", tc.header, tc.fallback, got, tc.want)
+    }
+  }
+}
+
+func TestTranslate(t *testing.T) {
+  if got := Translate(Portuguese, "invalid json"); got != "JSON inválido" {
+    t.Fatalf("unexpected translation: %q", got)
+  }
+  if got := Translate(Portuguese, "LND error: channel busy"); got != "Erro do LND: channel busy" {
+    t.Fatalf("expected prefix translation, got %q", got)
+  }
+  if got := Translate(Portuguese, "something new"); got != "something new" {
+    t.Fatalf("expected untranslated message, got %q", got)
+  }
+  if got := Translate(English, "invalid json"); got != "invalid json" {
+    t.Fatalf("english must pass through, got %q", got)
+  }
+}
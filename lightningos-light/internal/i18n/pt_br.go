@@ -0,0 +1,492 @@
+package i18n
+
+var portuguese = map[string]string{
+  // Request validation
+  "invalid json": "JSON inválido",
+  "invalid payload": "conteúdo inválido",
+  "invalid range": "intervalo inválido",
+  "not found": "não encontrado",
+  "authentication required": "autenticação necessária",
+  "too many failed logins, try again later": "muitas tentativas de login falharam, tente novamente mais tarde",
+  "credentials cannot be changed with a client certificate": "as credenciais não podem ser alteradas com um certificado de cliente",
+  "current_password required": "current_password obrigatório",
+  "current password is incorrect": "senha atual incorreta",
+  "too many requests, slow down": "muitas requisições, vá mais devagar",
+  "unknown node": "node desconhecido",
+  "unsupported action": "ação não suportada",
+  "unsupported service": "serviço não suportado",
+  "stream not supported": "streaming não suportado",
+  "from and to are required": "from e to são obrigatórios",
+  "from must be YYYY-MM-DD": "from deve estar no formato AAAA-MM-DD",
+  "to must be YYYY-MM-DD": "to deve estar no formato AAAA-MM-DD",
+  "since must be RFC3339": "since deve estar no formato RFC3339",
+  "limit must be between 1 and 100": "limit deve estar entre 1 e 100",
+  "id, event_key or since required": "informe id, event_key ou since",
+
+  // LND status and health
+  "LND wallet locked": "Carteira do LND bloqueada",
+  "LND macaroon unreadable (check permissions)": "Macaroon do LND ilegível (verifique as permissões)",
+  "LND macaroon missing": "Macaroon do LND ausente",
+  "LND macaroon error": "Erro no macaroon do LND",
+  "LND TLS cert unreadable (check permissions)": "Certificado TLS do LND ilegível (verifique as permissões)",
+  "LND TLS cert missing": "Certificado TLS do LND ausente",
+  "LND TLS error": "Erro de TLS do LND",
+  "LND gRPC connection refused": "Conexão gRPC com o LND recusada",
+  "LND gRPC timeout (retrying)": "Tempo esgotado no gRPC do LND (tentando novamente)",
+  "LND not reachable": "LND inacessível",
+  "LND error": "Erro do LND",
+  "LND warming up after restart": "LND aquecendo após reinício",
+  "LND restarting": "LND reiniciando",
+  "LND waiting to start": "LND aguardando para iniciar",
+  "LND starting up": "LND inicializando",
+  "LND wallet not created": "Carteira do LND não criada",
+  "LND is starting, try again shortly": "O LND está iniciando, tente novamente em instantes",
+  "LND GetInfo timeout (gRPC reachable)": "Tempo esgotado no GetInfo do LND (gRPC acessível)",
+  "LND restart is taking longer than expected. Check status in a moment.": "O reinício do LND está demorando mais que o esperado. Verifique o status em instantes.",
+  "Update sent. LND is syncing; policy may already be updated.": "Atualização enviada. O LND está sincronizando; a política pode já estar atualizada.",
+  "Bitcoin RPC unreachable": "RPC do Bitcoin inacessível",
+  "Bitcoin ZMQ unreachable": "ZMQ do Bitcoin inacessível",
+  "Bitcoin remote check failed": "Falha na verificação do Bitcoin remoto",
+  "Postgres inactive": "Postgres inativo",
+  "Notification poller stalled": "Monitor de notificações travado",
+  "Health check timed out": "Tempo esgotado na verificação de saúde",
+  "Health check failed": "Falha na verificação de saúde",
+  "bitcoin status error": "erro no status do Bitcoin",
+  "bitcoin local status error": "erro no status do Bitcoin local",
+  "system stats error": "erro nas estatísticas do sistema",
+  "smartctl error": "erro no smartctl",
+
+  // Wallet
+  "wallet already exists": "a carteira já existe",
+  "wallet_password required": "wallet_password é obrigatório",
+  "wallet_password and seed_words required": "wallet_password e seed_words são obrigatórios",
+  "wallet unlock setup failed": "falha ao configurar o desbloqueio da carteira",
+  "unlock failed": "falha ao desbloquear",
+  "enabled or wipe_password required": "informe enabled ou wipe_password",
+  "cannot enable auto-unlock while wiping the password": "não é possível ativar o desbloqueio automático ao apagar a senha",
+  "no stored wallet password: unlock the wallet once to store it": "nenhuma senha da carteira salva: desbloqueie a carteira uma vez para salvá-la",
+  "failed to store auto-unlock state": "falha ao salvar o estado do desbloqueio automático",
+  "failed to wipe wallet password": "falha ao apagar a senha da carteira",
+  "wallet password file is readable by other users": "o arquivo de senha da carteira pode ser lido por outros usuários",
+  "lnd.conf uses the password file but no password is stored": "o lnd.conf usa o arquivo de senha, mas nenhuma senha está salva",
+  "password stored but lnd.conf does not use it": "senha salva, mas o lnd.conf não a utiliza",
+  "init wallet failed": "falha ao inicializar a carteira",
+  "address required": "address é obrigatório",
+  "amount_sat must be positive": "amount_sat deve ser positivo",
+  "amount_sat must be positive for lightning address": "amount_sat deve ser positivo para lightning address",
+  "sat_per_vbyte must be zero or positive": "sat_per_vbyte deve ser zero ou positivo",
+  "payment_request required": "payment_request é obrigatório",
+  "invoice failed": "falha ao criar a invoice",
+  "outgoing and incoming channels are required": "os canais de saída e de entrada são obrigatórios",
+  "outgoing and incoming channels must differ": "os canais de saída e de entrada devem ser diferentes",
+  "amount must be positive": "o valor deve ser positivo",
+  "max fee ppm out of range": "max_fee_ppm fora do intervalo",
+  "memo too long": "memo longo demais",
+  "expiry_seconds out of range": "expiry_seconds fora do intervalo",
+  "format must be svg or png": "format deve ser svg ou png",
+  "failed to render qr code": "falha ao gerar o QR code",
+  "message required": "mensagem é obrigatória",
+  "min_confs must be positive": "min_confs deve ser positivo",
+  "too many addresses": "endereços demais",
+  "failed to build proof of reserves": "falha ao gerar a prova de reservas",
+  "invalid pos token": "token de PDV inválido",
+  "failed to load pos registers": "falha ao carregar os caixas de PDV",
+  "failed to save pos registers": "falha ao salvar os caixas de PDV",
+  "failed to create pos register": "falha ao criar o caixa de PDV",
+  "too many pos registers": "caixas de PDV demais",
+  "pos register not found": "caixa de PDV não encontrado",
+  "max_amount_sat must be zero or positive": "max_amount_sat deve ser zero ou positivo",
+  "amount above register limit": "valor acima do limite do caixa",
+  "failed to record pos invoice": "falha ao registrar a invoice do PDV",
+  "pos invoice not found": "invoice do PDV não encontrada",
+  "failed to load pos invoice": "falha ao carregar a invoice do PDV",
+  "failed to load pos totals": "falha ao carregar os totais do PDV",
+  "scheduled payments unavailable": "pagamentos agendados indisponíveis",
+  "invalid lightning address": "lightning address inválido",
+  "invalid pubkey": "pubkey inválida",
+  "kind must be lightning_address or keysend": "kind deve ser lightning_address ou keysend",
+  "every must be weekly or monthly": "every deve ser weekly ou monthly",
+  "message too long": "mensagem longa demais",
+  "max_total_sat must be zero or positive": "max_total_sat deve ser zero ou positivo",
+  "max_total_sat below amount_sat": "max_total_sat menor que amount_sat",
+  "start_at in the past": "start_at no passado",
+  "too many scheduled payments": "pagamentos agendados demais",
+  "invalid schedule id": "id de agendamento inválido",
+  "scheduled payment not found": "pagamento agendado não encontrado",
+  "failed to load scheduled payments": "falha ao carregar os pagamentos agendados",
+  "failed to save scheduled payment": "falha ao salvar o pagamento agendado",
+  "failed to delete scheduled payment": "falha ao excluir o pagamento agendado",
+  "failed to load scheduled payment runs": "falha ao carregar o histórico de pagamentos agendados",
+  "limit out of range": "limit fora do intervalo",
+  "invalid start": "start inválido",
+  "invalid end": "end inválido",
+  "start must be before end": "start deve ser anterior a end",
+  "invalid cursor": "cursor inválido",
+  "status must be succeeded, failed or in_flight": "status deve ser succeeded, failed ou in_flight",
+  "invalid chan_id": "chan_id inválido",
+  "invalid peer pubkey": "pubkey do peer inválida",
+  "invalid tag": "tag inválida",
+  "too many tags": "tags demais",
+  "notes too long": "notas longas demais",
+  "target_local_pct must be between 0 and 100": "target_local_pct deve estar entre 0 e 100",
+  "failed to load peer metadata": "falha ao carregar os dados dos peers",
+  "failed to save peer metadata": "falha ao salvar os dados dos peers",
+  "invalid address": "endereço inválido",
+  "kind must be lightning_address, node or onchain": "kind deve ser lightning_address, node ou onchain",
+  "label too long": "rótulo longo demais",
+  "client certificates cannot change the payment allowlist": "certificados de cliente não podem alterar a lista de destinos permitidos",
+  "client certificates cannot change SSH access": "certificados de cliente não podem alterar o acesso SSH",
+  "client certificates cannot issue client certificates": "certificados de cliente não podem emitir certificados de cliente",
+  "failed to load payment allowlist": "falha ao carregar a lista de destinos permitidos",
+  "failed to save payment allowlist": "falha ao salvar a lista de destinos permitidos",
+  "too many allowed destinations": "destinos permitidos demais",
+  "allowed destination not found": "destino permitido não encontrado",
+  "destination not in payment allowlist": "destino fora da lista de destinos permitidos",
+  "payment allowlist unavailable": "lista de destinos permitidos indisponível",
+
+  // Channels and peers
+  "peer_pubkey required": "peer_pubkey é obrigatório",
+  "invalid peer_pubkey": "peer_pubkey inválido",
+  "peer_address required": "peer_address é obrigatório",
+  "peer host must include host:port": "o host do peer deve incluir host:porta",
+  "pubkey required": "pubkey é obrigatório",
+  "pubkey and host required": "pubkey e host são obrigatórios",
+  "no reachable socket found": "nenhum socket alcançável encontrado",
+  "internal error": "erro interno",
+  "failed to load crashes": "falha ao carregar os registros de falhas",
+  "console disabled": "console desativado",
+  "console PIN not set": "PIN do console não definido",
+  "invalid console PIN": "PIN do console inválido",
+  "too many wrong console PINs, try again later": "muitos PINs do console incorretos, tente novamente mais tarde",
+  "console PIN must be at least 8 characters": "o PIN do console deve ter pelo menos 8 caracteres",
+  "failed to store console settings": "falha ao salvar as configurações do console",
+  "unknown console command": "comando de console desconhecido",
+  "pattern must be 1-80 characters": "o padrão deve ter de 1 a 80 caracteres",
+  "pattern contains invalid characters": "o padrão contém caracteres inválidos",
+  "job not found": "tarefa não encontrada",
+  "a job of this kind is already running": "uma tarefa deste tipo já está em execução",
+  "failed to start job": "falha ao iniciar a tarefa",
+  "failed to load jobs": "falha ao carregar as tarefas",
+  "failed to load forward failures": "falha ao carregar as falhas de encaminhamento",
+  "hours out of range": "hours fora do intervalo",
+  "days out of range": "days fora do intervalo",
+  "max_parts out of range": "max_parts fora do intervalo",
+  "failed to load liquidity history": "falha ao carregar o histórico de liquidez",
+  "Inbound liquidity low": "Liquidez de entrada baixa",
+  "Backup missing": "Backup ausente",
+  "Backup stale": "Backup desatualizado",
+  "invalid limit": "limite inválido",
+  "invalid hours": "horas inválidas",
+  "invalid since_id": "since_id inválido",
+  "invalid min_amount_sat": "min_amount_sat inválido",
+  "invalid Last-Event-ID": "Last-Event-ID inválido",
+  "interrupted by a manager restart": "interrompida por um reinício do gerenciador",
+  "channel_point required": "channel_point é obrigatório",
+  "channel_point required unless apply_all=true": "channel_point é obrigatório, a menos que apply_all=true",
+  "use apply_all or channel_point, not both": "use apply_all ou channel_point, não os dois",
+  "selected channel not found": "canal selecionado não encontrado",
+  "local_funding_sat must be positive": "local_funding_sat deve ser positivo",
+  "sat_per_vbyte required: LND could not estimate a fee rate": "sat_per_vbyte é obrigatório: o LND não conseguiu estimar uma taxa",
+  "funding fee is approximate: LND could not select coins for this amount": "a taxa de abertura é aproximada: o LND não conseguiu selecionar moedas para este valor",
+  "not enough confirmed on-chain funds for this channel": "saldo on-chain confirmado insuficiente para este canal",
+  "fees must be zero or positive": "as taxas devem ser zero ou positivas",
+  "at least one fee field is required": "informe ao menos um campo de taxa",
+  "days must be between 1 and 180": "days deve estar entre 1 e 180",
+  "channel not found": "canal não encontrado",
+  "an inbound experiment is already running": "um experimento de taxa de entrada já está em andamento",
+  "no inbound experiment is running": "nenhum experimento de taxa de entrada em andamento",
+  "an experiment needs at least two drained channels": "um experimento precisa de pelo menos dois canais drenados",
+  "forward history unavailable: the estimate needs the notifications database": "histórico de encaminhamentos indisponível: a estimativa precisa do banco de notificações",
+  "no forwards left through this channel in the window": "nenhum encaminhamento saiu por este canal no período",
+  "fee rate more than doubles: expect less flow than the estimate": "a taxa mais que dobra: espere menos fluxo do que o estimado",
+  "inbound fees apply to forwards arriving through this channel and are not part of the estimate": "taxas de entrada valem para encaminhamentos que chegam por este canal e não entram na estimativa",
+  "channel sizes must be positive": "os tamanhos de canal devem ser positivos",
+  "min channel must be lower than max": "o canal mínimo deve ser menor que o máximo",
+  "direction must be in or out": "direction deve ser in ou out",
+  "splicing is not supported by this LND version": "splicing não é suportado por esta versão do LND",
+  "LND does not advertise the splice feature": "o LND não anuncia o recurso de splice",
+  "the manager has no splice RPC for this LND version yet": "o gerenciador ainda não tem RPC de splice para esta versão do LND",
+
+  // lnd.conf and config files
+  "failed to read lnd.conf": "falha ao ler o lnd.conf",
+  "failed to write lnd.conf": "falha ao gravar o lnd.conf",
+  "failed to update lnd.conf": "falha ao atualizar o lnd.conf",
+  "failed to read bitcoin.conf": "falha ao ler o bitcoin.conf",
+  "failed to write bitcoin.conf": "falha ao gravar o bitcoin.conf",
+  "failed to read file": "falha ao ler o arquivo",
+  "failed to restore file": "falha ao restaurar o arquivo",
+  "failed to prepare secrets": "falha ao preparar os segredos",
+  "path is not a managed config file": "o caminho não é um arquivo de configuração gerenciado",
+  "no baseline recorded for this file": "nenhuma referência registrada para este arquivo",
+  "file too large to diff": "arquivo grande demais para comparar",
+  "alias too long": "alias muito longo",
+  "alias must be 1 to 32 characters": "o alias deve ter de 1 a 32 caracteres",
+  "color must be hex (#RRGGBB)": "a cor deve ser hexadecimal (#RRGGBB)",
+  "lnd restart failed": "falha ao reiniciar o LND",
+  "lnd restart failed, rollback applied": "falha ao reiniciar o LND, alterações revertidas",
+  "restart failed": "falha ao reiniciar",
+  "bitcoin restart failed": "falha ao reiniciar o Bitcoin",
+  "elements restart failed": "falha ao reiniciar o Elements",
+  "system action failed": "falha na ação do sistema",
+
+  // Wizard
+  "failed to store wizard state": "falha ao salvar o estado do assistente",
+  "step cannot be skipped": "esta etapa não pode ser pulada",
+  "mode must be tor, hybrid or clearnet": "mode deve ser tor, hybrid ou clearnet",
+  "external_ip required for hybrid mode": "external_ip é obrigatório no modo hybrid",
+  "external_ip required for clearnet mode": "external_ip é obrigatório no modo clearnet",
+  "target must be telegram or local": "target deve ser telegram ou local",
+  "telegram backup not configured": "backup no Telegram não configurado",
+  "telegram backup is not configured": "backup no Telegram não está configurado",
+  "failed to load push topics": "falha ao carregar os tópicos de push",
+  "failed to store push topics": "falha ao salvar os tópicos de push",
+  "push topic not found": "tópico de push não encontrado",
+  "invalid broker url": "URL do broker inválida",
+  "broker required": "broker obrigatório",
+  "invalid topic prefix": "prefixo de tópico inválido",
+  "failed to store mqtt config": "falha ao salvar a configuração MQTT",
+  "mqtt test failed": "teste MQTT falhou",
+  "invalid notification type": "tipo de notificação inválido",
+  "invalid push server url": "URL do servidor de push inválida",
+  "invalid ntfy topic": "tópico ntfy inválido",
+  "invalid push endpoint url": "URL do endpoint de push inválida",
+  "provider must be ntfy or unifiedpush": "provider deve ser ntfy ou unifiedpush",
+  "push test failed": "teste de push falhou",
+  "failed to store backup target": "falha ao salvar o destino do backup",
+  "username required (no colons)": "usuário obrigatório (sem dois-pontos)",
+  "password must be at least 10 characters": "a senha deve ter pelo menos 10 caracteres",
+
+  // Bitcoin and Elements
+  "source must be local or remote": "source deve ser local ou remote",
+  "rpcuser and rpcpass required": "rpcuser e rpcpass são obrigatórios",
+  "invalid rpc user": "usuário rpc inválido",
+  "failed to generate password": "falha ao gerar a senha",
+  "failed to generate rpcauth": "falha ao gerar o rpcauth",
+  "remote RPC credentials missing": "credenciais do RPC remoto ausentes",
+  "mode must be full or pruned": "mode deve ser full ou pruned",
+  "prune_size_gb required for pruned mode": "prune_size_gb é obrigatório no modo pruned",
+  "local bitcoin is not fully synced": "o Bitcoin local não está totalmente sincronizado",
+  "Bitcoin Core is not installed": "O Bitcoin Core não está instalado",
+  "Elements is not installed": "O Elements não está instalado",
+  "failed to store mainchain source": "falha ao salvar a fonte da mainchain",
+  "mempool fee fetch failed": "falha ao buscar as taxas da mempool",
+  "mempool connectivity fetch failed": "falha ao verificar a conexão com a mempool",
+
+  // LND database
+  "channel.db not found": "channel.db não encontrado",
+  "compaction is only available for the bolt backend": "a compactação só está disponível para o backend bolt",
+  "compaction already running": "compactação já em andamento",
+  "not enough free disk to compact": "espaço livre insuficiente para compactar",
+  "migration already running": "migração já em andamento",
+  "postgres migration is running": "migração para o Postgres em andamento",
+  "an LND database job is already running": "já existe uma tarefa no banco do LND em andamento",
+  "confirm must be true: LND is stopped during the migration": "confirm deve ser true: o LND fica parado durante a migração",
+
+  // Postgres, reports and notifications
+  "pg_dump not installed": "pg_dump não instalado",
+  "backup already running": "backup já em andamento",
+  "failed to read secrets": "falha ao ler os segredos",
+  "secrets export is not available to client certificates": "a exportação dos segredos não está disponível para certificados de cliente",
+  "not a LightningOS secrets export": "não é uma exportação de segredos do LightningOS",
+  "scb escrow not configured": "custódia remota do SCB não configurada",
+  "failed to store scb escrow config": "falha ao salvar a configuração da custódia do SCB",
+  "scb escrow push failed": "falha ao enviar o SCB para a custódia",
+  "failed to fetch scb escrow": "falha ao baixar o SCB da custódia",
+  "url must be an http(s) address": "url deve ser um endereço http(s)",
+  "passphrase required": "frase-senha obrigatória",
+  "failed to derive key": "falha ao derivar a chave",
+  "wrong passphrase or corrupted blob": "frase-senha incorreta ou arquivo corrompido",
+  "not a LightningOS SCB escrow blob": "não é um arquivo de custódia de SCB do LightningOS",
+  "restore the wallet from its seed and unlock it first": "restaure a carteira pela seed e desbloqueie-a primeiro",
+  "the backup belongs to another node": "o backup pertence a outro nó",
+  "channel backup restore is not available to client certificates": "a restauração do backup de canais não está disponível para certificados de cliente",
+  "channel backups can only be restored during setup": "backups de canais só podem ser restaurados durante a configuração",
+  "the node already has channels; restoring a backup would force-close them": "o nó já tem canais; restaurar um backup forçaria o fechamento deles",
+  "channel backup restore failed": "falha ao restaurar o backup de canais",
+  "compaction is running": "compactação em andamento",
+  "no matching database configured": "nenhum banco correspondente configurado",
+  "failed to load reports": "falha ao carregar os relatórios",
+  "failed to load report summary": "falha ao carregar o resumo do relatório",
+  "live report unavailable": "relatório ao vivo indisponível",
+  "failed to update report timeout": "falha ao atualizar o tempo limite do relatório",
+  "failed to update live timeout": "falha ao atualizar o tempo limite ao vivo",
+  "failed to update live lookback": "falha ao atualizar a janela ao vivo",
+  "failed to update anomaly trailing days": "falha ao atualizar os dias de referência de anomalias",
+  "failed to update anomaly revenue threshold": "falha ao atualizar o limite de receita para anomalias",
+  "failed to update anomaly rebalance threshold": "falha ao atualizar o limite de rebalanceamento para anomalias",
+  "failed to load rebalance pairs": "falha ao carregar os pares de rebalanceamento",
+  "notifications disabled": "notificações desativadas",
+  "notification not found": "notificação não encontrada",
+  "rule not found": "regra não encontrada",
+  "sat_per_vbyte above cap": "sat_per_vbyte acima do limite",
+  "target_conf and max_fee_per_vbyte must be zero or positive": "target_conf e max_fee_per_vbyte devem ser zero ou positivos",
+  "use either sat_per_vbyte or target_conf": "use sat_per_vbyte ou target_conf, não ambos",
+  "delivery_address, target_conf and max_fee_per_vbyte only apply to cooperative closes": "delivery_address, target_conf e max_fee_per_vbyte só valem para fechamentos cooperativos",
+  "sat_per_vbyte above max_fee_per_vbyte": "sat_per_vbyte acima de max_fee_per_vbyte",
+  "timed out waiting for LND to accept the close": "tempo esgotado aguardando o LND aceitar o fechamento",
+  "lost track of the close, it goes on in LND": "o acompanhamento do fechamento foi perdido, ele continua no LND",
+  "channel template not found": "modelo de canal não encontrado",
+  "failed to load channel templates": "falha ao carregar os modelos de canal",
+  "failed to store channel templates": "falha ao salvar os modelos de canal",
+  "channel group not found": "grupo de canais não encontrado",
+  "failed to load channel groups": "falha ao carregar os grupos de canais",
+  "failed to store channel groups": "falha ao salvar os grupos de canais",
+  "too many channel groups": "grupos de canais demais",
+  "too many group members": "membros demais no grupo",
+  "invalid notification id": "id de notificação inválido",
+  "invalid payment reference": "referência de pagamento inválida",
+  "invalid transaction reference": "referência de transação inválida",
+  "kind must be notification, payment or transaction": "kind deve ser notification, payment ou transaction",
+  "note too long": "nota longa demais",
+  "failed to load annotations": "falha ao carregar as anotações",
+  "failed to save annotation": "falha ao salvar a anotação",
+  "failed to load journal": "falha ao carregar o diário",
+  "failed to save journal entry": "falha ao salvar o lançamento",
+  "failed to delete journal entry": "falha ao excluir o lançamento",
+  "journal entry not found": "lançamento não encontrado",
+  "invalid journal entry id": "id de lançamento inválido",
+  "fee_sat must be zero or positive": "fee_sat deve ser zero ou positivo",
+  "memo required": "memo obrigatório",
+  "category too long": "categoria longa demais",
+  "range too large": "intervalo grande demais",
+  "too many rows": "linhas demais",
+  "format must be csv or json": "format deve ser csv ou json",
+  "failed to enable privacy mode": "falha ao ativar o modo privacidade",
+  "failed to render status page": "falha ao gerar a página de status",
+  "uptime tracking needs postgres": "o acompanhamento de disponibilidade requer postgres",
+  "month must be YYYY-MM": "month deve estar no formato AAAA-MM",
+  "failed to load uptime": "falha ao carregar a disponibilidade",
+  "months out of range": "months fora do intervalo",
+  "from must be RFC3339": "from deve estar no formato RFC3339",
+  "to must be RFC3339": "to deve estar no formato RFC3339",
+  "window too large": "janela grande demais",
+  "from must be before to": "from deve ser anterior a to",
+  "too many services": "serviços demais",
+  "lines out of range": "lines fora do intervalo",
+  "pattern too long": "padrão longo demais",
+  "invalid regular expression": "expressão regular inválida",
+  "until must be RFC3339": "until deve estar no formato RFC3339",
+  "since must be before until": "since deve ser anterior a until",
+  "level must be debug, info, warn or error": "level deve ser debug, info, warn ou error",
+  "failed to build log archive": "falha ao gerar o arquivo de logs",
+  "bandwidth tracking needs postgres": "o acompanhamento de tráfego requer postgres",
+  "failed to load bandwidth": "falha ao carregar o tráfego",
+  "latency tracking needs postgres": "o acompanhamento de latência requer postgres",
+  "failed to load latency": "falha ao carregar a latência",
+  "price unavailable": "cotação indisponível",
+  "price history needs postgres": "o histórico de cotações requer postgres",
+  "failed to load prices": "falha ao carregar as cotações",
+  "currency must be a 3-letter ISO 4217 code": "currency deve ser um código ISO 4217 de 3 letras",
+  "purpose too long": "finalidade muito longa",
+  "invalid channel point": "channel point inválido",
+  "group needs tags, peers or channels": "o grupo precisa de tags, peers ou canais",
+  "channel is still open": "o canal ainda está aberto",
+  "peer is not connected; pass peer_address": "o par não está conectado; informe peer_address",
+  "peer unknown for this channel; pass peer_address": "par desconhecido para este canal; informe peer_address",
+  "name required": "nome obrigatório",
+  "name too long": "nome muito longo",
+  "alerts unavailable: database not configured": "alertas indisponíveis: banco de dados não configurado",
+  "failed to load alert rules": "falha ao carregar as regras de alerta",
+  "failed to save alert rule": "falha ao salvar a regra de alerta",
+  "failed to delete alert rule": "falha ao excluir a regra de alerta",
+  "unknown alert metric": "métrica de alerta desconhecida",
+  "operator must be one of > >= < <= == !=": "o operador deve ser um de > >= < <= == !=",
+  "invalid threshold": "limite inválido",
+  "for_seconds must be between 0 and 7 days": "for_seconds deve estar entre 0 e 7 dias",
+  "window_seconds must be between 1 and 30 days": "window_seconds deve estar entre 1 e 30 dias",
+  "rule needs at least one action": "a regra precisa de pelo menos uma ação",
+  "action type must be notify, webhook or job": "o tipo de ação deve ser notify, webhook ou job",
+  "webhook url must be http or https": "a URL do webhook deve ser http ou https",
+  "job kind not allowed in alerts": "tipo de job não permitido em alertas",
+  "invalid job params": "parâmetros de job inválidos",
+  "invalid rule id": "id de regra inválido",
+  "bot_token required": "bot_token é obrigatório",
+  "chat_id required": "chat_id é obrigatório",
+  "bot_token and chat_id required": "bot_token e chat_id são obrigatórios",
+  "failed to store telegram config": "falha ao salvar a configuração do Telegram",
+
+  // Client certificates
+  "name must be 1 to 64 characters": "o nome deve ter de 1 a 64 caracteres",
+  "days must be between 1 and 3650": "days deve estar entre 1 e 3650",
+  "failed to prepare client CA": "falha ao preparar a CA de clientes",
+  "failed to issue client certificate": "falha ao emitir o certificado de cliente",
+  "failed to read client certificates": "falha ao ler os certificados de cliente",
+  "failed to store client certificate": "falha ao salvar o certificado de cliente",
+  "failed to build pairing bundle": "falha ao gerar o pacote de pareamento",
+  "client certificate not found": "certificado de cliente não encontrado",
+
+  // Two-factor auth
+  "totp code required": "código TOTP obrigatório",
+  "invalid totp code": "código TOTP inválido",
+  "totp already enabled": "TOTP já está ativado",
+  "totp not enabled": "TOTP não está ativado",
+  "no totp enrollment pending": "nenhum cadastro de TOTP pendente",
+  "failed to create totp secret": "falha ao criar o segredo TOTP",
+  "failed to store totp secret": "falha ao salvar o segredo TOTP",
+  "failed to disable totp": "falha ao desativar o TOTP",
+
+  // Preferences
+  "failed to load preferences": "falha ao carregar as preferências",
+  "failed to store preferences": "falha ao salvar as preferências",
+  "unit must be sats or btc": "unit deve ser sats ou btc",
+  "fiat_currency must be a 3-letter ISO 4217 code": "fiat_currency deve ser um código ISO 4217 de 3 letras",
+  "date_format must be iso, dmy or mdy": "date_format deve ser iso, dmy ou mdy",
+  "timezone must be an IANA zone name": "timezone deve ser um fuso horário IANA",
+
+  // Chat, apps and integrations
+  "chat unavailable": "chat indisponível",
+  "failed to load chat messages": "falha ao carregar as mensagens do chat",
+  "failed to load chat inbox": "falha ao carregar a caixa de entrada do chat",
+  "missing app id": "id do app ausente",
+  "app not found": "app não encontrado",
+  "days must be between 1 and 730": "days deve estar entre 1 e 730",
+  "lndg is not installed": "o LNDg não está instalado",
+  "lndg app unavailable": "app LNDg indisponível",
+  "lndg-db container not running": "o container lndg-db não está em execução",
+  "catalog signature does not match": "a assinatura do catálogo não confere",
+  "invalid catalog public key": "chave pública do catálogo inválida",
+  "invalid catalog payload": "conteúdo do catálogo inválido",
+  "catalog app missing id": "app do catálogo sem id",
+  "catalog too large": "catálogo grande demais",
+  "max_retries must be between 0 and 20": "max_retries deve estar entre 0 e 20",
+  "backoff_sec must be between 0 and 1800": "backoff_sec deve estar entre 0 e 1800",
+  "failed to store restart policy": "falha ao salvar a política de reinício",
+  "failed to prepare app data": "falha ao preparar os dados do app",
+  "reset not supported for this app": "reset não suportado para este app",
+  "admin password unavailable": "senha de administrador indisponível",
+  "admin password not available for this app": "senha de administrador não disponível para este app",
+  "amboss health check unavailable": "verificação de saúde da Amboss indisponível",
+
+  // Firewall
+  "firewall status failed": "falha ao ler o firewall",
+  "failed to list listening ports": "falha ao listar as portas abertas",
+  "action must be allow, deny or delete": "action deve ser allow, deny ou delete",
+  "proto must be tcp or udp": "proto deve ser tcp ou udp",
+  "port is not one of the node's ports": "a porta não é uma das portas do nó",
+  "invalid from": "origem inválida",
+  "firewall changes need ufw": "alterações no firewall exigem o ufw",
+  "unknown firewall profile": "perfil de firewall desconhecido",
+  "ufw allow failed": "falha no ufw allow",
+  "ufw deny failed": "falha no ufw deny",
+  "ufw delete failed": "falha ao remover a regra do ufw",
+  "ufw enable failed": "falha ao ativar o ufw",
+
+  // Intrusion attempts
+  "invalid ip": "ip inválido",
+  "minutes must be 1-43200": "minutes deve ser entre 1 e 43200",
+  "lan and loopback addresses are never blocked": "endereços da LAN e de loopback nunca são bloqueados",
+  "block not found": "bloqueio não encontrado",
+
+  // SSH access
+  "ssh access failed": "falha no acesso ssh",
+  "key must be one public key line": "a chave deve ser uma única linha de chave pública",
+  "invalid ssh public key": "chave pública ssh inválida",
+  "key options are not supported": "opções de chave não são suportadas",
+  "ssh key already added": "chave ssh já adicionada",
+  "fingerprint required": "fingerprint obrigatório",
+  "ssh key not found": "chave ssh não encontrada",
+  "add an ssh key before turning password logins off": "adicione uma chave ssh antes de desativar o login por senha",
+  "cannot remove the last ssh key while password logins are off": "não é possível remover a última chave ssh com o login por senha desativado",
+  "enabled required": "enabled obrigatório",
+  "sshd still uses the old password setting; check /etc/ssh/sshd_config": "o sshd ainda usa a configuração de senha anterior; verifique /etc/ssh/sshd_config",
+}
@@ -0,0 +1,112 @@
+package lndclient
+
+import (
+  "context"
+  "encoding/hex"
+
+  "google.golang.org/grpc"
+  "google.golang.org/protobuf/encoding/protowire"
+)
+
+// walletrpc is not generated either; ListAccounts is decoded by hand like
+// the HTLC event stream.
+
+const listAccountsMethod = "/walletrpc.WalletKit/ListAccounts"
+
+const (
+  AddressTypeUnknown = "unknown"
+  AddressTypeP2WKH = "p2wkh"
+  AddressTypeNP2WKH = "np2wkh"
+  // AddressTypeHybridNP2WKH receives to nested addresses and takes change to
+  // native segwit ones.
+  AddressTypeHybridNP2WKH = "hybrid_np2wkh"
+  AddressTypeP2TR = "p2tr"
+)
+
+// WalletAccount is one on-chain wallet account (walletrpc.Account).
+// ExtendedPublicKey is in SLIP-132 form (xpub, ypub, zpub or their testnet
+// versions) and is empty for the default imported account.
+type WalletAccount struct {
+  Name string `json:"name"`
+  AddressType string `json:"address_type"`
+  ExtendedPublicKey string `json:"extended_public_key"`
+  MasterKeyFingerprint string `json:"master_key_fingerprint,omitempty"`
+  DerivationPath string `json:"derivation_path"`
+  ExternalKeyCount uint32 `json:"external_key_count"`
+  InternalKeyCount uint32 `json:"internal_key_count"`
+  WatchOnly bool `json:"watch_only"`
+}
+
+func (c *Client) ListAccounts(ctx context.Context) ([]WalletAccount, error) {
+  conn, err := c.dial(ctx, true)
+  if err != nil {
+    return nil, err
+  }
+  defer conn.Close()
+
+  req := []byte{}
+  var resp []byte
+  if err := conn.Invoke(ctx, listAccountsMethod, &req, &resp, grpc.ForceCodec(rawCodec{})); err != nil {
+    return nil, err
+  }
+  return ParseListAccounts(resp)
+}
+
+// ParseListAccounts decodes a serialized walletrpc.ListAccountsResponse.
+func ParseListAccounts(raw []byte) ([]WalletAccount, error) {
+  accounts := []WalletAccount{}
+  err := walkFields(raw, func(num protowire.Number, typ protowire.Type, value uint64, payload []byte) error {
+    if num != 1 || typ != protowire.BytesType {
+      return nil
+    }
+    account, err := parseWalletAccount(payload)
+    if err != nil {
+      return err
+    }
+    accounts = append(accounts, account)
+    return nil
+  })
+  return accounts, err
+}
+
+func parseWalletAccount(raw []byte) (WalletAccount, error) {
+  account := WalletAccount{AddressType: AddressTypeUnknown}
+  err := walkFields(raw, func(num protowire.Number, typ protowire.Type, value uint64, payload []byte) error {
+    switch num {
+    case 1:
+      account.Name = string(payload)
+    case 2:
+      account.AddressType = walletAddressType(value)
+    case 3:
+      account.ExtendedPublicKey = string(payload)
+    case 4:
+      if len(payload) > 0 {
+        account.MasterKeyFingerprint = hex.EncodeToString(payload)
+      }
+    case 5:
+      account.DerivationPath = string(payload)
+    case 6:
+      account.ExternalKeyCount = uint32(value)
+    case 7:
+      account.InternalKeyCount = uint32(value)
+    case 8:
+      account.WatchOnly = value != 0
+    }
+    return nil
+  })
+  return account, err
+}
+
+func walletAddressType(value uint64) string {
+  switch value {
+  case 1:
+    return AddressTypeP2WKH
+  case 2:
+    return AddressTypeNP2WKH
+  case 3:
+    return AddressTypeHybridNP2WKH
+  case 4:
+    return AddressTypeP2TR
+  }
+  return AddressTypeUnknown
+}
@@ -0,0 +1,62 @@
+package lndclient
+
+import (
+  "testing"
+
+  "google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseListAccounts(t *testing.T) {
+  var account []byte
+  account = protowire.AppendTag(account, 1, protowire.BytesType)
+  account = protowire.AppendString(account, "default")
+  account = protowire.AppendTag(account, 2, protowire.VarintType)
+  account = protowire.AppendVarint(account, 1)
+  account = protowire.AppendTag(account, 3, protowire.BytesType)
+  account = protowire.AppendString(account, "zpub6r")
+  account = protowire.AppendTag(account, 4, protowire.BytesType)
+  account = protowire.AppendBytes(account, []byte{0x73, 0xc5, 0xda, 0x0a})
+  account = protowire.AppendTag(account, 5, protowire.BytesType)
+  account = protowire.AppendString(account, "m/84'/0'/0'")
+  account = protowire.AppendTag(account, 6, protowire.VarintType)
+  account = protowire.AppendVarint(account, 12)
+  account = protowire.AppendTag(account, 7, protowire.VarintType)
+  account = protowire.AppendVarint(account, 4)
+
+  var imported []byte
+  imported = protowire.AppendTag(imported, 1, protowire.BytesType)
+  imported = protowire.AppendString(imported, "imported")
+  imported = protowire.AppendTag(imported, 2, protowire.VarintType)
+  imported = protowire.AppendVarint(imported, 9)
+  imported = protowire.AppendTag(imported, 8, protowire.VarintType)
+  imported = protowire.AppendVarint(imported, 1)
+
+  var raw []byte
+  raw = protowire.AppendTag(raw, 1, protowire.BytesType)
+  raw = protowire.AppendBytes(raw, account)
+  raw = protowire.AppendTag(raw, 1, protowire.BytesType)
+  raw = protowire.AppendBytes(raw, imported)
+
+  accounts, err := ParseListAccounts(raw)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(accounts) != 2 {
+    t.Fatalf("accounts %+v", accounts)
+  }
+  want := WalletAccount{
+    Name: "default",
+    AddressType: AddressTypeP2WKH,
+    ExtendedPublicKey: "zpub6r",
+    MasterKeyFingerprint: "73c5da0a",
+    DerivationPath: "m/84'/0'/0'",
+    ExternalKeyCount: 12,
+    InternalKeyCount: 4,
+  }
+  if accounts[0] != want {
+    t.Fatalf("account %+v, want %+v", accounts[0], want)
+  }
+  if accounts[1].AddressType != AddressTypeUnknown || !accounts[1].WatchOnly {
+    t.Fatalf("imported account %+v", accounts[1])
+  }
+}
@@ -0,0 +1,65 @@
+package lndclient
+
+import (
+  "context"
+
+  "google.golang.org/grpc"
+)
+
+// API is the part of the LND client the manager depends on. Server, the
+// notifiers and the reports service take an API so tests can hand them a
+// fake (see internal/lndfake) instead of a running node.
+type API interface {
+  // DialLightning opens a raw gRPC connection for streams and RPCs the
+  // client does not wrap.
+  DialLightning(ctx context.Context) (*grpc.ClientConn, error)
+
+  GetStatus(ctx context.Context) (Status, error)
+  Reachable(ctx context.Context) bool
+  CachedPubkey() string
+  GetBalances(ctx context.Context) (BalanceSummary, error)
+  ExportAllChannelBackups(ctx context.Context) ([]byte, error)
+  SignMessage(ctx context.Context, message string) (string, error)
+  SignMessageWithAddress(ctx context.Context, address string, message string) (string, error)
+
+  GenSeed(ctx context.Context, seedPassphrase string) ([]string, error)
+  InitWallet(ctx context.Context, walletPassword string, seedWords []string) error
+  UnlockWallet(ctx context.Context, walletPassword string) error
+  StopDaemon(ctx context.Context) error
+
+  DecodeInvoice(ctx context.Context, payReq string) (DecodedInvoice, error)
+  CreateInvoice(ctx context.Context, amountSat int64, memo string, expirySeconds int64) (CreatedInvoice, error)
+  PayInvoice(ctx context.Context, paymentRequest string, outgoingChanID uint64) error
+  SendKeysendMessage(ctx context.Context, pubkeyHex string, amountSat int64, message string) (string, error)
+  ListRecent(ctx context.Context, limit int) ([]RecentActivity, error)
+
+  NewAddress(ctx context.Context) (string, error)
+  SendCoins(ctx context.Context, address string, amountSat int64, satPerVbyte int64, sendAll bool) (string, error)
+  ListOnchain(ctx context.Context, limit int) ([]RecentActivity, error)
+  ListOnchainTransactions(ctx context.Context, limit int) ([]OnchainTransaction, error)
+  ListOnchainUtxos(ctx context.Context, minConfs int32, maxConfs int32) ([]OnchainUtxo, error)
+  ListAccounts(ctx context.Context) ([]WalletAccount, error)
+
+  ListPeers(ctx context.Context) ([]PeerInfo, error)
+  ConnectPeer(ctx context.Context, pubkey string, host string, perm bool) error
+  DisconnectPeer(ctx context.Context, pubkey string) error
+
+  ListChannels(ctx context.Context) ([]ChannelInfo, error)
+  ListPendingChannels(ctx context.Context) ([]PendingChannelInfo, error)
+  GetChannelPolicy(ctx context.Context, channelPoint string) (ChannelPolicy, error)
+  OpenChannel(ctx context.Context, pubkeyHex string, localFundingSat int64, closeAddress string, private bool, satPerVbyte int64) (string, error)
+  EstimateFundingFee(ctx context.Context, amountSat int64, targetConf int32) (FeeEstimate, error)
+  CloseChannel(ctx context.Context, req CloseChannelRequest) (CloseResult, error)
+  UpdateChannelFees(ctx context.Context, channelPoint string, applyAll bool, baseFeeMsat int64, feeRatePpm int64, timeLockDelta int64, inboundEnabled bool, inboundBaseMsat int64, inboundFeeRatePpm int64) error
+  SpliceCapability(ctx context.Context) (SpliceCapability, error)
+  Splice(ctx context.Context, req SpliceRequest) (SpliceResult, error)
+
+  Lifecycle() Lifecycle
+  Ready() bool
+  MarkRestarting()
+  OnStateChange(fn func(prev, next Lifecycle))
+  WaitReady(ctx context.Context) error
+  WatchState(ctx context.Context)
+}
+
+var _ API = (*Client)(nil)
@@ -36,12 +36,27 @@ type Client struct {
   infoCache infoSnapshot
   infoCacheAt time.Time
   infoCacheValid bool
+  dialer Dialer
+  stateMu sync.Mutex
+  lifecycle Lifecycle
+  stateWatching bool
+  readyCh chan struct{}
+  stateListeners []func(prev, next Lifecycle)
 }
 
+// Dialer opens a gRPC connection in place of the TLS+macaroon dial to LND.
+type Dialer func(ctx context.Context) (*grpc.ClientConn, error)
+
 func New(cfg *config.Config, logger *log.Logger) *Client {
   return &Client{cfg: cfg, logger: logger}
 }
 
+// NewWithDialer returns a client that reaches LND through dialer, such as the
+// in-memory node used by mock mode.
+func NewWithDialer(cfg *config.Config, logger *log.Logger, dialer Dialer) *Client {
+  return &Client{cfg: cfg, logger: logger, dialer: dialer}
+}
+
 const (
   statusCacheOK = 30 * time.Second
   statusCacheErr = 45 * time.Second
@@ -79,6 +94,8 @@ type infoSnapshot struct {
   Version string
   Pubkey string
   URI string
+  Alias string
+  BestHeaderTime time.Time
 }
 
 type DecodedInvoice struct {
@@ -105,6 +122,9 @@ func (m macaroonCredential) RequireTransportSecurity() bool {
 }
 
 func (c *Client) dial(ctx context.Context, withMacaroon bool) (*grpc.ClientConn, error) {
+  if c.dialer != nil {
+    return c.dialer(ctx)
+  }
   tlsCert, err := os.ReadFile(c.cfg.LND.TLSCertPath)
   if err != nil {
     return nil, err
@@ -118,6 +138,7 @@ func (c *Client) dial(ctx context.Context, withMacaroon bool) (*grpc.ClientConn,
   opts := []grpc.DialOption{
     grpc.WithTransportCredentials(creds),
     grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxGRPCMsgSize)),
+    grpc.WithChainUnaryInterceptor(retryReadsInterceptor),
   }
 
   if withMacaroon {
@@ -167,6 +188,18 @@ func (c *Client) GetStatus(ctx context.Context) (Status, error) {
   return status, err
 }
 
+// Reachable reports whether LND answers RPCs, bypassing the status cache. A
+// locked wallet counts as reachable.
+func (c *Client) Reachable(ctx context.Context) bool {
+  conn, err := c.dial(ctx, true)
+  if err != nil {
+    return false
+  }
+  defer conn.Close()
+  _, err = lnrpc.NewLightningClient(conn).GetInfo(ctx, &lnrpc.GetInfoRequest{})
+  return err == nil || isWalletLocked(err)
+}
+
 func (c *Client) CachedPubkey() string {
   c.statusMu.Lock()
   cached := c.infoCache
@@ -371,6 +404,10 @@ func (c *Client) getStatusUncached(ctx context.Context) (Status, error) {
     status.BlockHeight = int64(info.BlockHeight)
     status.Version = info.Version
     status.Pubkey = info.IdentityPubkey
+    status.Alias = info.Alias
+    if info.BestHeaderTimestamp > 0 {
+      status.BestHeaderTime = time.Unix(info.BestHeaderTimestamp, 0).UTC()
+    }
     status.InfoKnown = true
     status.InfoStale = false
     status.InfoAgeSeconds = 0
@@ -386,6 +423,8 @@ func (c *Client) getStatusUncached(ctx context.Context) (Status, error) {
       Version: status.Version,
       Pubkey: status.Pubkey,
       URI: status.URI,
+      Alias: status.Alias,
+      BestHeaderTime: status.BestHeaderTime,
     }
     c.infoCacheAt = now
     c.infoCacheValid = true
@@ -399,6 +438,8 @@ func (c *Client) getStatusUncached(ctx context.Context) (Status, error) {
     status.Version = cachedInfo.Version
     status.Pubkey = cachedInfo.Pubkey
     status.URI = cachedInfo.URI
+    status.Alias = cachedInfo.Alias
+    status.BestHeaderTime = cachedInfo.BestHeaderTime
     status.InfoKnown = true
     status.InfoStale = true
     status.InfoAgeSeconds = int64(now.Sub(cachedAt).Seconds())
@@ -1010,6 +1051,10 @@ func (c *Client) ListChannels(ctx context.Context) ([]ChannelInfo, error) {
       CapacitySat: ch.Capacity,
       LocalBalanceSat: ch.LocalBalance,
       RemoteBalanceSat: ch.RemoteBalance,
+      LocalReserveSat: int64(ch.GetLocalConstraints().GetChanReserveSat()),
+      RemoteReserveSat: int64(ch.GetRemoteConstraints().GetChanReserveSat()),
+      LocalMaxInFlightSat: int64(ch.GetLocalConstraints().GetMaxPendingAmtMsat() / 1000),
+      RemoteMaxInFlightSat: int64(ch.GetRemoteConstraints().GetMaxPendingAmtMsat() / 1000),
       BaseFeeMsat: baseFeeMsat,
       FeeRatePpm: feeRatePpm,
       InboundFeeRatePpm: inboundFeeRatePpm,
@@ -1191,6 +1236,8 @@ func (c *Client) ListPeers(ctx context.Context) ([]PeerInfo, error) {
       SyncType: peer.SyncType.String(),
       LastError: lastErr,
       LastErrorTime: lastErrTime,
+      FlapCount: peer.FlapCount,
+      LastFlapNs: peer.LastFlapNs,
     })
   }
 
@@ -1228,6 +1275,20 @@ func (c *Client) DisconnectPeer(ctx context.Context, pubkey string) error {
   return err
 }
 
+// StopDaemon asks LND to shut down cleanly; it returns once the request is
+// accepted, the process exits after closing its databases.
+func (c *Client) StopDaemon(ctx context.Context) error {
+  conn, err := c.dial(ctx, true)
+  if err != nil {
+    return err
+  }
+  defer conn.Close()
+
+  client := lnrpc.NewLightningClient(conn)
+  _, err = client.StopDaemon(ctx, &lnrpc.StopRequest{})
+  return err
+}
+
 func (c *Client) OpenChannel(ctx context.Context, pubkeyHex string, localFundingSat int64, closeAddress string, private bool, satPerVbyte int64) (string, error) {
   pubkeyHex = strings.TrimSpace(pubkeyHex)
   if pubkeyHex == "" {
@@ -1264,36 +1325,159 @@ func (c *Client) OpenChannel(ctx context.Context, pubkeyHex string, localFunding
   return channelPointString(resp), nil
 }
 
-func (c *Client) CloseChannel(ctx context.Context, channelPoint string, force bool, satPerVbyte int64) error {
-  cp, err := parseChannelPoint(channelPoint)
+// fundingEstimateAddresses pay to the all-zero script hash. They have the
+// same P2WSH output type as a channel funding output and are only used to
+// size a funding transaction, never to send.
+var fundingEstimateAddresses = map[string]string{
+  "mainnet": "bc1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqthqst8",
+  "testnet": "tb1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqulkl3g",
+  "signet": "tb1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqulkl3g",
+  "regtest": "bcrt1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqq3xueyj",
+}
+
+type FeeEstimate struct {
+  FeeSat int64
+  SatPerVbyte int64
+}
+
+// EstimateFundingFee runs LND's coin selection for a funding output of
+// amountSat without broadcasting anything and returns the fee at LND's own
+// rate for targetConf.
+func (c *Client) EstimateFundingFee(ctx context.Context, amountSat int64, targetConf int32) (FeeEstimate, error) {
+  network := c.cfg.Network
+  if network == "" {
+    network = "mainnet"
+  }
+  addr, ok := fundingEstimateAddresses[network]
+  if !ok {
+    return FeeEstimate{}, fmt.Errorf("unsupported network %q", network)
+  }
+
+  conn, err := c.dial(ctx, true)
   if err != nil {
-    return err
+    return FeeEstimate{}, err
+  }
+  defer conn.Close()
+
+  client := lnrpc.NewLightningClient(conn)
+  resp, err := client.EstimateFee(ctx, &lnrpc.EstimateFeeRequest{
+    AddrToAmount: map[string]int64{addr: amountSat},
+    TargetConf: targetConf,
+  })
+  if err != nil {
+    return FeeEstimate{}, err
+  }
+  return FeeEstimate{FeeSat: resp.FeeSat, SatPerVbyte: int64(resp.SatPerVbyte)}, nil
+}
+
+type CloseChannelRequest struct {
+  ChannelPoint string
+  Force bool
+  // SatPerVbyte and TargetConf are alternatives; both zero lets LND pick.
+  SatPerVbyte int64
+  TargetConf int32
+  // DeliveryAddress and MaxFeePerVbyte only apply to cooperative closes.
+  DeliveryAddress string
+  MaxFeePerVbyte int64
+  // WaitForTxid keeps the stream open until LND reports the closing
+  // transaction instead of returning as soon as the close is accepted.
+  WaitForTxid bool
+  // OnUpdate, when set, follows the close until the closing transaction
+  // confirms and is called for every step on the way.
+  OnUpdate func(CloseUpdate)
+}
+
+type CloseResult struct {
+  ClosingTxid string
+  FeePerVbyte int64
+  PendingHTLCs int32
+}
+
+const (
+  CloseStagePending = "pending"
+  CloseStageBroadcast = "broadcast"
+  CloseStageConfirmed = "confirmed"
+)
+
+// CloseUpdate is one step of a close: accepted (pending), closing transaction
+// in the mempool (broadcast), then confirmed.
+type CloseUpdate struct {
+  Stage string `json:"stage"`
+  ClosingTxid string `json:"closing_txid,omitempty"`
+  FeePerVbyte int64 `json:"fee_per_vbyte,omitempty"`
+  PendingHTLCs int32 `json:"pending_htlcs,omitempty"`
+}
+
+func (c *Client) CloseChannel(ctx context.Context, req CloseChannelRequest) (CloseResult, error) {
+  cp, err := parseChannelPoint(req.ChannelPoint)
+  if err != nil {
+    return CloseResult{}, err
   }
 
   conn, err := c.dial(ctx, true)
   if err != nil {
-    return err
+    return CloseResult{}, err
   }
   defer conn.Close()
 
   client := lnrpc.NewLightningClient(conn)
-  req := &lnrpc.CloseChannelRequest{
+  // With NoWait LND answers at once and keeps streaming, which is what a
+  // tracked close wants to report the pending step.
+  rpcReq := &lnrpc.CloseChannelRequest{
     ChannelPoint: cp,
-    Force: force,
-    NoWait: true,
+    Force: req.Force,
+    TargetConf: req.TargetConf,
+    DeliveryAddress: req.DeliveryAddress,
+    NoWait: req.OnUpdate != nil || !req.WaitForTxid,
   }
-  if satPerVbyte > 0 {
-    req.SatPerVbyte = uint64(satPerVbyte)
+  if req.SatPerVbyte > 0 {
+    rpcReq.SatPerVbyte = uint64(req.SatPerVbyte)
+  }
+  if req.MaxFeePerVbyte > 0 {
+    rpcReq.MaxFeePerVbyte = uint64(req.MaxFeePerVbyte)
   }
-  stream, err := client.CloseChannel(ctx, req)
+  stream, err := client.CloseChannel(ctx, rpcReq)
   if err != nil {
-    return err
+    return CloseResult{}, err
   }
-  _, err = stream.Recv()
-  if err != nil && !errors.Is(err, io.EOF) {
-    return err
+
+  var result CloseResult
+  for {
+    update, err := stream.Recv()
+    if errors.Is(err, io.EOF) {
+      return result, nil
+    }
+    if err != nil {
+      return result, err
+    }
+    var step CloseUpdate
+    switch u := update.Update.(type) {
+    case *lnrpc.CloseStatusUpdate_CloseInstant:
+      result.PendingHTLCs = u.CloseInstant.NumPendingHtlcs
+      step = CloseUpdate{Stage: CloseStagePending, PendingHTLCs: result.PendingHTLCs}
+    case *lnrpc.CloseStatusUpdate_ClosePending:
+      result.ClosingTxid = txidFromBytes(u.ClosePending.Txid)
+      result.FeePerVbyte = u.ClosePending.FeePerVbyte
+      step = CloseUpdate{Stage: CloseStageBroadcast, ClosingTxid: result.ClosingTxid, FeePerVbyte: result.FeePerVbyte}
+    case *lnrpc.CloseStatusUpdate_ChanClose:
+      if txid := txidFromBytes(u.ChanClose.ClosingTxid); txid != "" {
+        result.ClosingTxid = txid
+      }
+      step = CloseUpdate{Stage: CloseStageConfirmed, ClosingTxid: result.ClosingTxid, FeePerVbyte: result.FeePerVbyte}
+    default:
+      continue
+    }
+    if req.OnUpdate != nil {
+      req.OnUpdate(step)
+      if step.Stage == CloseStageConfirmed {
+        return result, nil
+      }
+      continue
+    }
+    if step.Stage != CloseStagePending || !req.WaitForTxid {
+      return result, nil
+    }
   }
-  return nil
 }
 
 func (c *Client) UpdateChannelFees(ctx context.Context, channelPoint string, applyAll bool, baseFeeMsat int64, feeRatePpm int64, timeLockDelta int64, inboundEnabled bool, inboundBaseMsat int64, inboundFeeRatePpm int64) error {
@@ -1434,8 +1618,11 @@ type Status struct {
   SyncedToChain bool
   SyncedToGraph bool
   BlockHeight int64
+  // BestHeaderTime is the timestamp of the best block header LND knows.
+  BestHeaderTime time.Time
   Version string
   Pubkey string
+  Alias string
   URI string
   InfoKnown bool
   InfoStale bool
@@ -1456,6 +1643,12 @@ type ChannelInfo struct {
   CapacitySat int64 `json:"capacity_sat"`
   LocalBalanceSat int64 `json:"local_balance_sat"`
   RemoteBalanceSat int64 `json:"remote_balance_sat"`
+  // The reserve each side must leave in the channel and the most each side
+  // may have in flight, as negotiated at open (local: what we may send).
+  LocalReserveSat int64 `json:"local_reserve_sat"`
+  RemoteReserveSat int64 `json:"remote_reserve_sat"`
+  LocalMaxInFlightSat int64 `json:"local_max_in_flight_sat"`
+  RemoteMaxInFlightSat int64 `json:"remote_max_in_flight_sat"`
   BaseFeeMsat *int64 `json:"base_fee_msat,omitempty"`
   FeeRatePpm *int64 `json:"fee_rate_ppm,omitempty"`
   InboundFeeRatePpm *int64 `json:"inbound_fee_rate_ppm,omitempty"`
@@ -1474,6 +1667,8 @@ type PeerInfo struct {
   SyncType string `json:"sync_type"`
   LastError string `json:"last_error"`
   LastErrorTime int64 `json:"last_error_time,omitempty"`
+  FlapCount int32 `json:"flap_count"`
+  LastFlapNs int64 `json:"last_flap_ns,omitempty"`
 }
 
 type PendingChannelInfo struct {
@@ -0,0 +1,258 @@
+package lndclient
+
+import (
+  "context"
+  "fmt"
+  "strings"
+
+  "lightningos-light/lnrpc"
+
+  "google.golang.org/grpc"
+  "google.golang.org/protobuf/encoding/protowire"
+)
+
+// routerrpc is not part of the generated lnrpc package, and the HTLC event
+// stream is the only Router RPC used here, so its messages are decoded by
+// hand. Only the fields below are read; everything else is skipped.
+
+const subscribeHTLCEventsMethod = "/routerrpc.Router/SubscribeHtlcEvents"
+
+const (
+  HTLCEventUnknown = "unknown"
+  HTLCEventSend = "send"
+  HTLCEventReceive = "receive"
+  HTLCEventForward = "forward"
+)
+
+const (
+  HTLCForward = "forward"
+  HTLCForwardFail = "forward_fail"
+  HTLCSettle = "settle"
+  HTLCLinkFail = "link_fail"
+)
+
+// HTLCEvent is one routerrpc.HtlcEvent. Kind says which of the event's
+// variants was set; FailureReason is only filled for link failures.
+type HTLCEvent struct {
+  IncomingChannelID uint64
+  OutgoingChannelID uint64
+  IncomingHTLCID uint64
+  OutgoingHTLCID uint64
+  TimestampNs uint64
+  EventType string
+  Kind string
+  IncomingAmtMsat uint64
+  OutgoingAmtMsat uint64
+  FailureReason string
+  FailureString string
+}
+
+// HTLCEventStream reads a SubscribeHtlcEvents stream.
+type HTLCEventStream struct {
+  stream grpc.ClientStream
+}
+
+// rawCodec hands gRPC the message bytes untouched so they can be decoded
+// with protowire.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+  raw, ok := v.(*[]byte)
+  if !ok {
+    return nil, fmt.Errorf("raw codec: unexpected %T", v)
+  }
+  return *raw, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+  raw, ok := v.(*[]byte)
+  if !ok {
+    return fmt.Errorf("raw codec: unexpected %T", v)
+  }
+  *raw = append((*raw)[:0], data...)
+  return nil
+}
+
+func (rawCodec) Name() string {
+  return "proto"
+}
+
+// SubscribeHTLCEvents opens the Router HTLC event stream on a connection from
+// DialLightning. The stream ends with ctx.
+func SubscribeHTLCEvents(ctx context.Context, conn *grpc.ClientConn) (*HTLCEventStream, error) {
+  stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, subscribeHTLCEventsMethod, grpc.ForceCodec(rawCodec{}))
+  if err != nil {
+    return nil, err
+  }
+  empty := []byte{}
+  if err := stream.SendMsg(&empty); err != nil {
+    return nil, err
+  }
+  if err := stream.CloseSend(); err != nil {
+    return nil, err
+  }
+  return &HTLCEventStream{stream: stream}, nil
+}
+
+func (s *HTLCEventStream) Recv() (HTLCEvent, error) {
+  var raw []byte
+  if err := s.stream.RecvMsg(&raw); err != nil {
+    return HTLCEvent{}, err
+  }
+  return ParseHTLCEvent(raw)
+}
+
+// ParseHTLCEvent decodes a serialized routerrpc.HtlcEvent.
+func ParseHTLCEvent(raw []byte) (HTLCEvent, error) {
+  var evt HTLCEvent
+  evt.EventType = HTLCEventUnknown
+  err := walkFields(raw, func(num protowire.Number, typ protowire.Type, value uint64, payload []byte) error {
+    switch num {
+    case 1:
+      evt.IncomingChannelID = value
+    case 2:
+      evt.OutgoingChannelID = value
+    case 3:
+      evt.IncomingHTLCID = value
+    case 4:
+      evt.OutgoingHTLCID = value
+    case 5:
+      evt.TimestampNs = value
+    case 6:
+      evt.EventType = htlcEventType(value)
+    case 7:
+      evt.Kind = HTLCForward
+      return parseHTLCInfoHolder(payload, &evt)
+    case 8:
+      evt.Kind = HTLCForwardFail
+    case 9:
+      evt.Kind = HTLCSettle
+    case 10:
+      evt.Kind = HTLCLinkFail
+      return parseLinkFail(payload, &evt)
+    }
+    return nil
+  })
+  return evt, err
+}
+
+// parseHTLCInfoHolder reads ForwardEvent{info = 1}.
+func parseHTLCInfoHolder(raw []byte, evt *HTLCEvent) error {
+  return walkFields(raw, func(num protowire.Number, typ protowire.Type, value uint64, payload []byte) error {
+    if num == 1 && typ == protowire.BytesType {
+      return parseHTLCInfo(payload, evt)
+    }
+    return nil
+  })
+}
+
+func parseHTLCInfo(raw []byte, evt *HTLCEvent) error {
+  return walkFields(raw, func(num protowire.Number, typ protowire.Type, value uint64, payload []byte) error {
+    switch num {
+    case 3:
+      evt.IncomingAmtMsat = value
+    case 4:
+      evt.OutgoingAmtMsat = value
+    }
+    return nil
+  })
+}
+
+// parseLinkFail reads LinkFailEvent{info = 1, wire_failure = 2,
+// failure_detail = 3, failure_string = 4}.
+func parseLinkFail(raw []byte, evt *HTLCEvent) error {
+  var wire, detail uint64
+  err := walkFields(raw, func(num protowire.Number, typ protowire.Type, value uint64, payload []byte) error {
+    switch num {
+    case 1:
+      return parseHTLCInfo(payload, evt)
+    case 2:
+      wire = value
+    case 3:
+      detail = value
+    case 4:
+      evt.FailureString = string(payload)
+    }
+    return nil
+  })
+  evt.FailureReason = htlcFailureReason(wire, detail)
+  return err
+}
+
+func walkFields(raw []byte, fn func(num protowire.Number, typ protowire.Type, value uint64, payload []byte) error) error {
+  for len(raw) > 0 {
+    num, typ, n := protowire.ConsumeTag(raw)
+    if n < 0 {
+      return protowire.ParseError(n)
+    }
+    raw = raw[n:]
+    var (
+      value uint64
+      payload []byte
+    )
+    switch typ {
+    case protowire.VarintType:
+      value, n = protowire.ConsumeVarint(raw)
+    case protowire.BytesType:
+      payload, n = protowire.ConsumeBytes(raw)
+    default:
+      n = protowire.ConsumeFieldValue(num, typ, raw)
+    }
+    if n < 0 {
+      return protowire.ParseError(n)
+    }
+    raw = raw[n:]
+    if err := fn(num, typ, value, payload); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func htlcEventType(value uint64) string {
+  switch value {
+  case 1:
+    return HTLCEventSend
+  case 2:
+    return HTLCEventReceive
+  case 3:
+    return HTLCEventForward
+  }
+  return HTLCEventUnknown
+}
+
+// htlcFailureDetails mirrors routerrpc.FailureDetail, which says why our own
+// link refused the HTLC more precisely than the wire code.
+var htlcFailureDetails = map[uint64]string{
+  2: "onion_decode",
+  3: "link_not_eligible",
+  4: "on_chain_timeout",
+  5: "htlc_exceeds_max",
+  6: "insufficient_balance",
+  7: "incomplete_forward",
+  8: "htlc_add_failed",
+  9: "forwards_disabled",
+  10: "invoice_canceled",
+  11: "invoice_underpaid",
+  12: "invoice_expiry_too_soon",
+  13: "invoice_not_open",
+  14: "mpp_invoice_timeout",
+  15: "address_mismatch",
+  16: "set_total_mismatch",
+  17: "set_total_too_low",
+  18: "set_overpaid",
+  19: "unknown_invoice",
+  20: "invalid_keysend",
+  21: "mpp_in_progress",
+  22: "circular_route",
+}
+
+func htlcFailureReason(wire uint64, detail uint64) string {
+  if reason, ok := htlcFailureDetails[detail]; ok {
+    return reason
+  }
+  if name, ok := lnrpc.Failure_FailureCode_name[int32(wire)]; ok && wire != 0 {
+    return strings.ToLower(name)
+  }
+  return "unknown"
+}
@@ -0,0 +1,60 @@
+package lndclient
+
+import (
+  "testing"
+
+  "google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseHTLCEventLinkFail(t *testing.T) {
+  var info []byte
+  info = protowire.AppendTag(info, 3, protowire.VarintType)
+  info = protowire.AppendVarint(info, 2_001_000)
+  info = protowire.AppendTag(info, 4, protowire.VarintType)
+  info = protowire.AppendVarint(info, 2_000_000)
+
+  var fail []byte
+  fail = protowire.AppendTag(fail, 1, protowire.BytesType)
+  fail = protowire.AppendBytes(fail, info)
+  fail = protowire.AppendTag(fail, 2, protowire.VarintType)
+  fail = protowire.AppendVarint(fail, 15)
+  fail = protowire.AppendTag(fail, 3, protowire.VarintType)
+  fail = protowire.AppendVarint(fail, 6)
+  fail = protowire.AppendTag(fail, 4, protowire.BytesType)
+  fail = protowire.AppendString(fail, "insufficient bandwidth")
+
+  var raw []byte
+  raw = protowire.AppendTag(raw, 1, protowire.VarintType)
+  raw = protowire.AppendVarint(raw, 111)
+  raw = protowire.AppendTag(raw, 2, protowire.VarintType)
+  raw = protowire.AppendVarint(raw, 222)
+  raw = protowire.AppendTag(raw, 3, protowire.VarintType)
+  raw = protowire.AppendVarint(raw, 7)
+  raw = protowire.AppendTag(raw, 6, protowire.VarintType)
+  raw = protowire.AppendVarint(raw, 3)
+  // An unknown field must be skipped, not break parsing.
+  raw = protowire.AppendTag(raw, 99, protowire.Fixed64Type)
+  raw = protowire.AppendFixed64(raw, 1)
+  raw = protowire.AppendTag(raw, 10, protowire.BytesType)
+  raw = protowire.AppendBytes(raw, fail)
+
+  evt, err := ParseHTLCEvent(raw)
+  if err != nil {
+    t.Fatalf("parse: %v", err)
+  }
+  if evt.IncomingChannelID != 111 || evt.OutgoingChannelID != 222 || evt.IncomingHTLCID != 7 || evt.EventType != HTLCEventForward {
+    t.Fatalf("unexpected ids: %+v", evt)
+  }
+  if evt.Kind != HTLCLinkFail || evt.FailureReason != "insufficient_balance" || evt.OutgoingAmtMsat != 2_000_000 || evt.FailureString != "insufficient bandwidth" {
+    t.Fatalf("unexpected failure: %+v", evt)
+  }
+}
+
+func TestHTLCFailureReasonFallsBackToWireCode(t *testing.T) {
+  if got := htlcFailureReason(12, 1); got != "fee_insufficient" {
+    t.Fatalf("wire code reason %q", got)
+  }
+  if got := htlcFailureReason(0, 0); got != "unknown" {
+    t.Fatalf("empty reason %q", got)
+  }
+}
@@ -56,7 +56,8 @@ func (c *Client) SendKeysendMessage(ctx context.Context, pubkeyHex string, amoun
     },
   })
   if err != nil {
-    return "", err
+    // The payment may still be in flight; its hash lets the caller look.
+    return hex.EncodeToString(hash[:]), err
   }
   if res != nil && strings.TrimSpace(res.PaymentError) != "" {
     return "", errors.New(strings.TrimSpace(res.PaymentError))
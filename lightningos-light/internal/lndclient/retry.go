@@ -0,0 +1,95 @@
+package lndclient
+
+import (
+  "context"
+  "math/rand"
+  "sync/atomic"
+  "time"
+
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/status"
+)
+
+// idempotentReads are the LND calls that are safe to send twice.
+var idempotentReads = map[string]bool{
+  "/lnrpc.Lightning/ChannelBalance": true,
+  "/lnrpc.Lightning/ClosedChannels": true,
+  "/lnrpc.Lightning/DecodePayReq": true,
+  "/lnrpc.Lightning/EstimateFee": true,
+  "/lnrpc.Lightning/FeeReport": true,
+  "/lnrpc.Lightning/ForwardingHistory": true,
+  "/lnrpc.Lightning/GetChanInfo": true,
+  "/lnrpc.Lightning/GetInfo": true,
+  "/lnrpc.Lightning/GetNetworkInfo": true,
+  "/lnrpc.Lightning/GetNodeInfo": true,
+  "/lnrpc.Lightning/GetRecoveryInfo": true,
+  "/lnrpc.Lightning/GetTransactions": true,
+  "/lnrpc.Lightning/ListChannels": true,
+  "/lnrpc.Lightning/ListInvoices": true,
+  "/lnrpc.Lightning/ListPayments": true,
+  "/lnrpc.Lightning/ListPeers": true,
+  "/lnrpc.Lightning/ListUnspent": true,
+  "/lnrpc.Lightning/LookupInvoice": true,
+  "/lnrpc.Lightning/PendingChannels": true,
+  "/lnrpc.Lightning/QueryRoutes": true,
+  "/lnrpc.Lightning/WalletBalance": true,
+}
+
+const (
+  // retryMinBudget is the least time left on the caller's deadline for a
+  // retry to be worth sending.
+  retryMinBudget = time.Second
+  retryJitterMin = 50 * time.Millisecond
+  retryJitterMax = 250 * time.Millisecond
+)
+
+var readRetryEnabled atomic.Bool
+
+func init() {
+  readRetryEnabled.Store(true)
+}
+
+// SetReadRetry turns the single retry of idempotent reads on or off; the
+// server calls it when timeouts.retry_reads is reloaded.
+func SetReadRetry(enabled bool) {
+  readRetryEnabled.Store(enabled)
+}
+
+// transientRPCError is a call that never reached LND or lost its connection.
+// A timed-out call is not retried: the caller's deadline is spent.
+func transientRPCError(err error) bool {
+  return status.Code(err) == codes.Unavailable
+}
+
+func retryJitter() time.Duration {
+  return retryJitterMin + time.Duration(rand.Int63n(int64(retryJitterMax-retryJitterMin)))
+}
+
+// retryReadsInterceptor retries an idempotent read once when LND is
+// unavailable (connection refused or dropped). The first attempt gets the
+// caller's whole deadline, so slow reads on a large node still finish; the
+// retry is only sent while at least retryMinBudget of it is left. Writes are
+// never retried.
+func retryReadsInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+  if !readRetryEnabled.Load() || !idempotentReads[method] {
+    return invoker(ctx, method, req, reply, cc, opts...)
+  }
+
+  err := invoker(ctx, method, req, reply, cc, opts...)
+  if err == nil || !transientRPCError(err) || ctx.Err() != nil {
+    return err
+  }
+  if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < retryMinBudget+retryJitterMax {
+    return err
+  }
+
+  timer := time.NewTimer(retryJitter())
+  defer timer.Stop()
+  select {
+  case <-ctx.Done():
+    return err
+  case <-timer.C:
+  }
+  return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,88 @@
+package lndclient
+
+import (
+  "context"
+  "testing"
+  "time"
+
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/status"
+)
+
+func TestRetryReadsInterceptor(t *testing.T) {
+  calls := 0
+  flaky := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+    calls++
+    if calls == 1 {
+      return status.Error(codes.Unavailable, "connection refused")
+    }
+    return nil
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+
+  if err := retryReadsInterceptor(ctx, "/lnrpc.Lightning/GetInfo", nil, nil, nil, flaky); err != nil {
+    t.Fatalf("expected read to succeed on retry: %v", err)
+  }
+  if calls != 2 {
+    t.Fatalf("expected 2 attempts, got %d", calls)
+  }
+
+  calls = 0
+  if err := retryReadsInterceptor(ctx, "/lnrpc.Lightning/SendCoins", nil, nil, nil, flaky); status.Code(err) != codes.Unavailable {
+    t.Fatalf("writes must not be retried: %v", err)
+  }
+  if calls != 1 {
+    t.Fatalf("expected 1 attempt for a write, got %d", calls)
+  }
+
+  calls = 0
+  SetReadRetry(false)
+  defer SetReadRetry(true)
+  if err := retryReadsInterceptor(ctx, "/lnrpc.Lightning/GetInfo", nil, nil, nil, flaky); err == nil || calls != 1 {
+    t.Fatalf("retry disabled: expected a single failed attempt, got %d calls, err %v", calls, err)
+  }
+}
+
+func TestRetryReadsInterceptorKeepsPermanentErrors(t *testing.T) {
+  calls := 0
+  invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+    calls++
+    return status.Error(codes.InvalidArgument, "bad request")
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  if err := retryReadsInterceptor(ctx, "/lnrpc.Lightning/DecodePayReq", nil, nil, nil, invoker); status.Code(err) != codes.InvalidArgument || calls != 1 {
+    t.Fatalf("permanent errors must not be retried: %d calls, err %v", calls, err)
+  }
+}
+
+func TestRetryReadsInterceptorBudget(t *testing.T) {
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  want, _ := ctx.Deadline()
+  calls := 0
+  invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+    calls++
+    if got, _ := ctx.Deadline(); !got.Equal(want) {
+      t.Fatalf("attempt deadline %v, want the caller's %v", got, want)
+    }
+    return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+  }
+  if err := retryReadsInterceptor(ctx, "/lnrpc.Lightning/ForwardingHistory", nil, nil, nil, invoker); status.Code(err) != codes.DeadlineExceeded || calls != 1 {
+    t.Fatalf("timeouts must not be retried: %d calls, err %v", calls, err)
+  }
+
+  short, cancelShort := context.WithTimeout(context.Background(), retryMinBudget/2)
+  defer cancelShort()
+  calls = 0
+  unavailable := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+    calls++
+    return status.Error(codes.Unavailable, "connection refused")
+  }
+  if err := retryReadsInterceptor(short, "/lnrpc.Lightning/GetInfo", nil, nil, nil, unavailable); err == nil || calls != 1 {
+    t.Fatalf("no retry without budget: %d calls, err %v", calls, err)
+  }
+}
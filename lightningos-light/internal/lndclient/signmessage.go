@@ -6,6 +6,9 @@ import (
   "strings"
 
   "lightningos-light/lnrpc"
+
+  "google.golang.org/grpc"
+  "google.golang.org/protobuf/encoding/protowire"
 )
 
 func (c *Client) SignMessage(ctx context.Context, message string) (string, error) {
@@ -34,3 +37,49 @@ func (c *Client) SignMessage(ctx context.Context, message string) (string, error
   }
   return signature, nil
 }
+
+const signMessageWithAddrMethod = "/walletrpc.WalletKit/SignMessageWithAddr"
+
+// SignMessageWithAddress signs message with the key of one of the wallet's
+// addresses (walletrpc.SignMessageWithAddr): a compact signature for
+// p2wkh and np2wkh addresses, BIP-322 for p2tr. The signature is base64.
+func (c *Client) SignMessageWithAddress(ctx context.Context, address string, message string) (string, error) {
+  address = strings.TrimSpace(address)
+  if address == "" {
+    return "", errors.New("address required")
+  }
+  if message == "" {
+    return "", errors.New("message required")
+  }
+
+  conn, err := c.dial(ctx, true)
+  if err != nil {
+    return "", err
+  }
+  defer conn.Close()
+
+  var req []byte
+  req = protowire.AppendTag(req, 1, protowire.BytesType)
+  req = protowire.AppendString(req, message)
+  req = protowire.AppendTag(req, 2, protowire.BytesType)
+  req = protowire.AppendString(req, address)
+  var resp []byte
+  if err := conn.Invoke(ctx, signMessageWithAddrMethod, &req, &resp, grpc.ForceCodec(rawCodec{})); err != nil {
+    return "", err
+  }
+
+  signature := ""
+  err = walkFields(resp, func(num protowire.Number, typ protowire.Type, value uint64, payload []byte) error {
+    if num == 1 {
+      signature = strings.TrimSpace(string(payload))
+    }
+    return nil
+  })
+  if err != nil {
+    return "", err
+  }
+  if signature == "" {
+    return "", errors.New("empty signature")
+  }
+  return signature, nil
+}
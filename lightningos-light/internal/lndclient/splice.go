@@ -0,0 +1,73 @@
+package lndclient
+
+import (
+  "context"
+  "errors"
+  "strings"
+
+  "lightningos-light/lnrpc"
+)
+
+// ErrSpliceUnsupported is returned while LND has no splice RPC. The lnrpc
+// package is generated from LND's protos; once they carry splicing, Splice
+// calls it and SpliceCapability reports it.
+var ErrSpliceUnsupported = errors.New("splicing is not supported by this LND version")
+
+// spliceRPCAvailable marks whether the generated lnrpc includes a splice RPC.
+const spliceRPCAvailable = false
+
+type SpliceCapability struct {
+  Supported bool `json:"supported"`
+  FeatureAdvertised bool `json:"feature_advertised"`
+  LNDVersion string `json:"lnd_version"`
+  Reason string `json:"reason,omitempty"`
+}
+
+type SpliceRequest struct {
+  ChannelPoint string
+  // AmountSat is added to the channel when positive (splice-in) and taken
+  // out to Address when negative (splice-out).
+  AmountSat int64
+  Address string
+  SatPerVbyte int64
+}
+
+type SpliceResult struct {
+  Txid string
+}
+
+// SpliceCapability reports whether this node can splice: LND must advertise
+// the splice feature bit and expose an RPC to drive it.
+func (c *Client) SpliceCapability(ctx context.Context) (SpliceCapability, error) {
+  conn, err := c.dial(ctx, true)
+  if err != nil {
+    return SpliceCapability{}, err
+  }
+  defer conn.Close()
+
+  info, err := lnrpc.NewLightningClient(conn).GetInfo(ctx, &lnrpc.GetInfoRequest{})
+  if err != nil {
+    return SpliceCapability{}, err
+  }
+  capability := SpliceCapability{LNDVersion: info.Version}
+  for _, feature := range info.Features {
+    if feature != nil && strings.Contains(strings.ToLower(feature.Name), "splice") {
+      capability.FeatureAdvertised = true
+      break
+    }
+  }
+  switch {
+  case !capability.FeatureAdvertised:
+    capability.Reason = "LND does not advertise the splice feature"
+  case !spliceRPCAvailable:
+    capability.Reason = "the manager has no splice RPC for this LND version yet"
+  default:
+    capability.Supported = true
+  }
+  return capability, nil
+}
+
+// Splice resizes a channel without closing it.
+func (c *Client) Splice(ctx context.Context, req SpliceRequest) (SpliceResult, error) {
+  return SpliceResult{}, ErrSpliceUnsupported
+}
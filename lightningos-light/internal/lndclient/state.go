@@ -0,0 +1,216 @@
+package lndclient
+
+import (
+  "context"
+  "time"
+
+  "lightningos-light/lnrpc"
+)
+
+// Lifecycle states, following LND's State service plus the states the
+// manager infers itself.
+const (
+  // StateUnknown means no state has been observed yet (or the watcher is
+  // not running).
+  StateUnknown = "unknown"
+  // StateDown means the State service cannot be reached.
+  StateDown = "down"
+  // StateRestarting is set when the manager restarts LND and lasts until
+  // LND reports a state again (or restartGrace passes).
+  StateRestarting = "restarting"
+  StateWaitingToStart = "waiting_to_start"
+  StateNonExisting = "non_existing"
+  StateLocked = "locked"
+  StateUnlocked = "unlocked"
+  StateRPCActive = "rpc_active"
+  StateServerActive = "server_active"
+)
+
+const (
+  restartGrace = 3 * time.Minute
+  stateRetryMin = 2 * time.Second
+  stateRetryMax = 15 * time.Second
+)
+
+// Lifecycle is where LND is in its startup sequence.
+type Lifecycle struct {
+  State string `json:"state"`
+  Since time.Time `json:"since"`
+}
+
+// Starting reports whether LND is on its way up: restarting, waiting to
+// start, or unlocked but not yet serving every RPC.
+func (l Lifecycle) Starting() bool {
+  switch l.State {
+  case StateRestarting, StateWaitingToStart, StateUnlocked, StateRPCActive:
+    return true
+  }
+  return false
+}
+
+func walletStateName(state lnrpc.WalletState) string {
+  switch state {
+  case lnrpc.WalletState_NON_EXISTING:
+    return StateNonExisting
+  case lnrpc.WalletState_LOCKED:
+    return StateLocked
+  case lnrpc.WalletState_UNLOCKED:
+    return StateUnlocked
+  case lnrpc.WalletState_RPC_ACTIVE:
+    return StateRPCActive
+  case lnrpc.WalletState_SERVER_ACTIVE:
+    return StateServerActive
+  case lnrpc.WalletState_WAITING_TO_START:
+    return StateWaitingToStart
+  }
+  return StateUnknown
+}
+
+// Lifecycle returns the last observed LND state.
+func (c *Client) Lifecycle() Lifecycle {
+  c.stateMu.Lock()
+  defer c.stateMu.Unlock()
+  if !c.stateWatching {
+    return Lifecycle{State: StateUnknown}
+  }
+  return c.lifecycle
+}
+
+// Ready reports whether LND serves the full Lightning API. Without a running
+// watcher nothing is known, so callers are not held back.
+func (c *Client) Ready() bool {
+  c.stateMu.Lock()
+  defer c.stateMu.Unlock()
+  return !c.stateWatching || c.lifecycle.State == StateServerActive
+}
+
+// MarkRestarting records that the manager is restarting LND, so the stream
+// dropping is reported as a restart instead of an outage.
+func (c *Client) MarkRestarting() {
+  c.setState(StateRestarting, time.Now())
+}
+
+// OnStateChange registers fn to run after every state transition.
+func (c *Client) OnStateChange(fn func(prev, next Lifecycle)) {
+  c.stateMu.Lock()
+  c.stateListeners = append(c.stateListeners, fn)
+  c.stateMu.Unlock()
+}
+
+func (c *Client) setState(state string, now time.Time) {
+  c.stateMu.Lock()
+  if state == StateDown && c.lifecycle.State == StateRestarting && now.Sub(c.lifecycle.Since) < restartGrace {
+    c.stateMu.Unlock()
+    return
+  }
+  if c.readyCh == nil {
+    c.readyCh = make(chan struct{})
+  }
+  if state == c.lifecycle.State {
+    c.stateMu.Unlock()
+    return
+  }
+  prev := c.lifecycle
+  wasReady := prev.State == StateServerActive
+  c.lifecycle = Lifecycle{State: state, Since: now}
+  switch {
+  case state == StateServerActive && !wasReady:
+    close(c.readyCh)
+  case state != StateServerActive && wasReady:
+    c.readyCh = make(chan struct{})
+  }
+  next := c.lifecycle
+  listeners := append([]func(prev, next Lifecycle){}, c.stateListeners...)
+  c.stateMu.Unlock()
+
+  if c.logger != nil {
+    c.logger.Printf("lnd: state %s", state)
+  }
+  for _, fn := range listeners {
+    fn(prev, next)
+  }
+}
+
+// WaitReady blocks until LND reaches SERVER_ACTIVE or ctx ends.
+func (c *Client) WaitReady(ctx context.Context) error {
+  for {
+    c.stateMu.Lock()
+    if !c.stateWatching || c.lifecycle.State == StateServerActive {
+      c.stateMu.Unlock()
+      return nil
+    }
+    ready := c.readyCh
+    c.stateMu.Unlock()
+    select {
+    case <-ctx.Done():
+      return ctx.Err()
+    case <-ready:
+    }
+  }
+}
+
+// WatchState follows LND's State service until ctx ends, reconnecting with
+// backoff while LND is down. It is a no-op when already running.
+func (c *Client) WatchState(ctx context.Context) {
+  c.stateMu.Lock()
+  if c.stateWatching {
+    c.stateMu.Unlock()
+    return
+  }
+  c.stateWatching = true
+  if c.lifecycle.State == "" {
+    c.lifecycle = Lifecycle{State: StateUnknown, Since: time.Now()}
+  }
+  if c.readyCh == nil {
+    c.readyCh = make(chan struct{})
+  }
+  c.stateMu.Unlock()
+
+  go func() {
+    backoff := stateRetryMin
+    for {
+      if c.followState(ctx) {
+        backoff = stateRetryMin
+      }
+      if ctx.Err() != nil {
+        return
+      }
+      c.setState(StateDown, time.Now())
+      select {
+      case <-ctx.Done():
+        return
+      case <-time.After(backoff):
+      }
+      backoff *= 2
+      if backoff > stateRetryMax {
+        backoff = stateRetryMax
+      }
+    }
+  }()
+}
+
+// followState streams state updates until the stream breaks. It reports
+// whether any update was received.
+func (c *Client) followState(ctx context.Context) bool {
+  // The State service is available before the wallet is unlocked and needs
+  // no macaroon.
+  conn, err := c.dial(ctx, false)
+  if err != nil {
+    return false
+  }
+  defer conn.Close()
+
+  stream, err := lnrpc.NewStateClient(conn).SubscribeState(ctx, &lnrpc.SubscribeStateRequest{})
+  if err != nil {
+    return false
+  }
+  received := false
+  for {
+    update, err := stream.Recv()
+    if err != nil {
+      return received
+    }
+    received = true
+    c.setState(walletStateName(update.State), time.Now())
+  }
+}
@@ -0,0 +1,57 @@
+package lndclient
+
+import (
+  "context"
+  "testing"
+  "time"
+)
+
+func TestLifecycleTransitions(t *testing.T) {
+  c := &Client{}
+  if !c.Ready() || c.Lifecycle().State != StateUnknown {
+    t.Fatalf("a client without a watcher must not gate callers")
+  }
+
+  c.stateWatching = true
+  now := time.Now()
+  c.setState(StateLocked, now)
+  if c.Ready() {
+    t.Fatalf("locked wallet reported ready")
+  }
+
+  c.setState(StateServerActive, now)
+  ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+  defer cancel()
+  if err := c.WaitReady(ctx); err != nil {
+    t.Fatalf("wait ready: %v", err)
+  }
+
+  c.MarkRestarting()
+  if c.Ready() || !c.Lifecycle().Starting() {
+    t.Fatalf("restart not tracked: %+v", c.Lifecycle())
+  }
+  // The stream dropping during a restart is expected, not an outage.
+  c.setState(StateDown, time.Now())
+  if state := c.Lifecycle().State; state != StateRestarting {
+    t.Fatalf("expected restarting during grace, got %s", state)
+  }
+  c.setState(StateDown, time.Now().Add(restartGrace+time.Second))
+  if state := c.Lifecycle().State; state != StateDown {
+    t.Fatalf("expected down after grace, got %s", state)
+  }
+
+  short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+  defer cancelShort()
+  if err := c.WaitReady(short); err == nil {
+    t.Fatalf("wait ready returned while LND is down")
+  }
+
+  done := make(chan error, 1)
+  go func() { done <- c.WaitReady(ctx) }()
+  c.setState(StateUnlocked, time.Now())
+  c.setState(StateRPCActive, time.Now())
+  c.setState(StateServerActive, time.Now())
+  if err := <-done; err != nil {
+    t.Fatalf("wait ready after restart: %v", err)
+  }
+}
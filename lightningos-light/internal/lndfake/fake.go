@@ -0,0 +1,681 @@
+// Package lndfake is an in-memory lndclient.API for unit tests. Methods
+// answer from the exported fields, record every call, and fail with the
+// error set in Errors for that method name. Calls that change a real node
+// (connect, open, close, fee updates) change the fake's data too, so a test
+// can check the effect through the read methods.
+package lndfake
+
+import (
+  "context"
+  "crypto/sha256"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+
+  "google.golang.org/grpc"
+)
+
+// ErrNoConn is returned by DialLightning when no Dial func is set.
+var ErrNoConn = errors.New("lndfake: no gRPC connection configured")
+
+// Call is one recorded method call.
+type Call struct {
+  Method string
+  Args []any
+}
+
+// Fake implements lndclient.API. Set the fields before handing it out; use
+// the methods (or Lock/Unlock) to change them while it is in use.
+type Fake struct {
+  mu sync.Mutex
+
+  Status lndclient.Status
+  Balances lndclient.BalanceSummary
+  Channels []lndclient.ChannelInfo
+  Pending []lndclient.PendingChannelInfo
+  Peers []lndclient.PeerInfo
+  Policies map[string]lndclient.ChannelPolicy
+  Utxos []lndclient.OnchainUtxo
+  Accounts []lndclient.WalletAccount
+  OnchainTxs []lndclient.OnchainTransaction
+  Recent []lndclient.RecentActivity
+  Onchain []lndclient.RecentActivity
+  // Invoices decodes payment requests; CreateInvoice adds to it.
+  Invoices map[string]lndclient.DecodedInvoice
+  Seed []string
+  WalletPassword string
+  Backup []byte
+  Addresses []string
+  FundingFee lndclient.FeeEstimate
+  SpliceCaps lndclient.SpliceCapability
+  // CloseConfirmed holds tracked closes (CloseChannelRequest.OnUpdate)
+  // after the broadcast step until it is closed; nil confirms at once.
+  CloseConfirmed chan struct{}
+  State lndclient.Lifecycle
+  // Dial backs DialLightning, e.g. with a connection to internal/lndmock.
+  Dial func(ctx context.Context) (*grpc.ClientConn, error)
+  // Errors makes the named method (e.g. "ListChannels") fail.
+  Errors map[string]error
+
+  calls []Call
+  listeners []func(prev, next lndclient.Lifecycle)
+  readyCh chan struct{}
+  nextTx int
+}
+
+var _ lndclient.API = (*Fake)(nil)
+
+// New returns a synced, unlocked node with no channels or peers.
+func New() *Fake {
+  return &Fake{
+    Status: lndclient.Status{
+      ServiceActive: true,
+      WalletState: "unlocked",
+      SyncedToChain: true,
+      SyncedToGraph: true,
+      BlockHeight: 850000,
+      Version: "0.18.4-beta fake",
+      Pubkey: "02" + strings.Repeat("ab", 32),
+      InfoKnown: true,
+    },
+    Policies: map[string]lndclient.ChannelPolicy{},
+    Invoices: map[string]lndclient.DecodedInvoice{},
+    Errors: map[string]error{},
+    State: lndclient.Lifecycle{State: lndclient.StateServerActive, Since: time.Now()},
+  }
+}
+
+func (f *Fake) Lock() {
+  f.mu.Lock()
+}
+
+func (f *Fake) Unlock() {
+  f.mu.Unlock()
+}
+
+// record notes the call and returns the injected error, if any. The caller
+// must hold f.mu.
+func (f *Fake) record(method string, args ...any) error {
+  f.calls = append(f.calls, Call{Method: method, Args: args})
+  if f.Errors == nil {
+    return nil
+  }
+  return f.Errors[method]
+}
+
+func (f *Fake) call(method string, args ...any) error {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  return f.record(method, args...)
+}
+
+// Calls returns the recorded calls to method, or every call when method is
+// empty.
+func (f *Fake) Calls(method string) []Call {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  out := []Call{}
+  for _, c := range f.calls {
+    if method == "" || c.Method == method {
+      out = append(out, c)
+    }
+  }
+  return out
+}
+
+// Fail makes method return err from now on; a nil err clears it.
+func (f *Fake) Fail(method string, err error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if f.Errors == nil {
+    f.Errors = map[string]error{}
+  }
+  if err == nil {
+    delete(f.Errors, method)
+    return
+  }
+  f.Errors[method] = err
+}
+
+// SetState moves the lifecycle to state and runs the OnStateChange
+// listeners, like the real client's state watcher.
+func (f *Fake) SetState(state string) {
+  f.mu.Lock()
+  prev := f.State
+  if prev.State == state {
+    f.mu.Unlock()
+    return
+  }
+  next := lndclient.Lifecycle{State: state, Since: time.Now()}
+  f.State = next
+  if state == lndclient.StateServerActive && f.readyCh != nil {
+    close(f.readyCh)
+    f.readyCh = nil
+  }
+  listeners := append([]func(prev, next lndclient.Lifecycle){}, f.listeners...)
+  f.mu.Unlock()
+  for _, fn := range listeners {
+    fn(prev, next)
+  }
+}
+
+func (f *Fake) fakeTxid() string {
+  f.nextTx++
+  sum := sha256.Sum256([]byte(fmt.Sprintf("lndfake-tx-%d", f.nextTx)))
+  return hex.EncodeToString(sum[:])
+}
+
+func (f *Fake) DialLightning(ctx context.Context) (*grpc.ClientConn, error) {
+  f.mu.Lock()
+  err := f.record("DialLightning")
+  dial := f.Dial
+  f.mu.Unlock()
+  if err != nil {
+    return nil, err
+  }
+  if dial == nil {
+    return nil, ErrNoConn
+  }
+  return dial(ctx)
+}
+
+func (f *Fake) GetStatus(ctx context.Context) (lndclient.Status, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("GetStatus"); err != nil {
+    return lndclient.Status{}, err
+  }
+  status := f.Status
+  status.ChannelsActive, status.ChannelsInactive = 0, 0
+  for _, ch := range f.Channels {
+    if ch.Active {
+      status.ChannelsActive++
+    } else {
+      status.ChannelsInactive++
+    }
+  }
+  return status, nil
+}
+
+func (f *Fake) Reachable(ctx context.Context) bool {
+  return f.call("Reachable") == nil
+}
+
+func (f *Fake) CachedPubkey() string {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  return f.Status.Pubkey
+}
+
+func (f *Fake) GetBalances(ctx context.Context) (lndclient.BalanceSummary, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("GetBalances"); err != nil {
+    return lndclient.BalanceSummary{}, err
+  }
+  return f.Balances, nil
+}
+
+func (f *Fake) ExportAllChannelBackups(ctx context.Context) ([]byte, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ExportAllChannelBackups"); err != nil {
+    return nil, err
+  }
+  return append([]byte(nil), f.Backup...), nil
+}
+
+func (f *Fake) SignMessage(ctx context.Context, message string) (string, error) {
+  if err := f.call("SignMessage", message); err != nil {
+    return "", err
+  }
+  sum := sha256.Sum256([]byte(message))
+  return "fakesig" + hex.EncodeToString(sum[:8]), nil
+}
+
+func (f *Fake) SignMessageWithAddress(ctx context.Context, address string, message string) (string, error) {
+  if err := f.call("SignMessageWithAddress", address, message); err != nil {
+    return "", err
+  }
+  sum := sha256.Sum256([]byte(address + "\x00" + message))
+  return "fakeaddrsig" + hex.EncodeToString(sum[:8]), nil
+}
+
+func (f *Fake) GenSeed(ctx context.Context, seedPassphrase string) ([]string, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("GenSeed"); err != nil {
+    return nil, err
+  }
+  return append([]string(nil), f.Seed...), nil
+}
+
+func (f *Fake) InitWallet(ctx context.Context, walletPassword string, seedWords []string) error {
+  f.mu.Lock()
+  if err := f.record("InitWallet", len(seedWords)); err != nil {
+    f.mu.Unlock()
+    return err
+  }
+  f.WalletPassword = walletPassword
+  f.Status.WalletState = "unlocked"
+  f.mu.Unlock()
+  f.SetState(lndclient.StateServerActive)
+  return nil
+}
+
+func (f *Fake) UnlockWallet(ctx context.Context, walletPassword string) error {
+  f.mu.Lock()
+  if err := f.record("UnlockWallet"); err != nil {
+    f.mu.Unlock()
+    return err
+  }
+  if f.WalletPassword != "" && walletPassword != f.WalletPassword {
+    f.mu.Unlock()
+    return errors.New("invalid passphrase for master public key")
+  }
+  f.Status.WalletState = "unlocked"
+  f.mu.Unlock()
+  f.SetState(lndclient.StateServerActive)
+  return nil
+}
+
+func (f *Fake) StopDaemon(ctx context.Context) error {
+  if err := f.call("StopDaemon"); err != nil {
+    return err
+  }
+  f.mu.Lock()
+  f.Status.ServiceActive = false
+  f.mu.Unlock()
+  f.SetState(lndclient.StateDown)
+  return nil
+}
+
+func (f *Fake) DecodeInvoice(ctx context.Context, payReq string) (lndclient.DecodedInvoice, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("DecodeInvoice", payReq); err != nil {
+    return lndclient.DecodedInvoice{}, err
+  }
+  inv, ok := f.Invoices[strings.TrimSpace(payReq)]
+  if !ok {
+    return lndclient.DecodedInvoice{}, errors.New("invalid payment request")
+  }
+  return inv, nil
+}
+
+func (f *Fake) CreateInvoice(ctx context.Context, amountSat int64, memo string, expirySeconds int64) (lndclient.CreatedInvoice, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("CreateInvoice", amountSat, memo, expirySeconds); err != nil {
+    return lndclient.CreatedInvoice{}, err
+  }
+  hash := f.fakeTxid()
+  payReq := fmt.Sprintf("lnbcfake%d%s", amountSat, hash[:16])
+  if f.Invoices == nil {
+    f.Invoices = map[string]lndclient.DecodedInvoice{}
+  }
+  f.Invoices[payReq] = lndclient.DecodedInvoice{
+    AmountSat: amountSat,
+    AmountMsat: amountSat * 1000,
+    Memo: memo,
+    Destination: f.Status.Pubkey,
+    PaymentHash: hash,
+    Expiry: expirySeconds,
+    Timestamp: time.Now().Unix(),
+  }
+  return lndclient.CreatedInvoice{PaymentRequest: payReq, PaymentHash: hash}, nil
+}
+
+func (f *Fake) PayInvoice(ctx context.Context, paymentRequest string, outgoingChanID uint64) error {
+  return f.call("PayInvoice", paymentRequest, outgoingChanID)
+}
+
+func (f *Fake) SendKeysendMessage(ctx context.Context, pubkeyHex string, amountSat int64, message string) (string, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("SendKeysendMessage", pubkeyHex, amountSat, message); err != nil {
+    return "", err
+  }
+  return f.fakeTxid(), nil
+}
+
+func (f *Fake) ListRecent(ctx context.Context, limit int) ([]lndclient.RecentActivity, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ListRecent", limit); err != nil {
+    return nil, err
+  }
+  return limitSlice(f.Recent, limit), nil
+}
+
+func (f *Fake) NewAddress(ctx context.Context) (string, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("NewAddress"); err != nil {
+    return "", err
+  }
+  if len(f.Addresses) > 0 {
+    addr := f.Addresses[0]
+    f.Addresses = f.Addresses[1:]
+    return addr, nil
+  }
+  return "bcrt1qfake" + f.fakeTxid()[:30], nil
+}
+
+func (f *Fake) SendCoins(ctx context.Context, address string, amountSat int64, satPerVbyte int64, sendAll bool) (string, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("SendCoins", address, amountSat, satPerVbyte, sendAll); err != nil {
+    return "", err
+  }
+  return f.fakeTxid(), nil
+}
+
+func (f *Fake) ListOnchain(ctx context.Context, limit int) ([]lndclient.RecentActivity, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ListOnchain", limit); err != nil {
+    return nil, err
+  }
+  return limitSlice(f.Onchain, limit), nil
+}
+
+func (f *Fake) ListOnchainTransactions(ctx context.Context, limit int) ([]lndclient.OnchainTransaction, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ListOnchainTransactions", limit); err != nil {
+    return nil, err
+  }
+  return limitSlice(f.OnchainTxs, limit), nil
+}
+
+func (f *Fake) ListOnchainUtxos(ctx context.Context, minConfs int32, maxConfs int32) ([]lndclient.OnchainUtxo, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ListOnchainUtxos", minConfs, maxConfs); err != nil {
+    return nil, err
+  }
+  out := []lndclient.OnchainUtxo{}
+  for _, u := range f.Utxos {
+    if u.Confirmations < int64(minConfs) || (maxConfs > 0 && u.Confirmations > int64(maxConfs)) {
+      continue
+    }
+    out = append(out, u)
+  }
+  return out, nil
+}
+
+func (f *Fake) ListAccounts(ctx context.Context) ([]lndclient.WalletAccount, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ListAccounts"); err != nil {
+    return nil, err
+  }
+  return append([]lndclient.WalletAccount{}, f.Accounts...), nil
+}
+
+func (f *Fake) ListPeers(ctx context.Context) ([]lndclient.PeerInfo, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ListPeers"); err != nil {
+    return nil, err
+  }
+  return append([]lndclient.PeerInfo{}, f.Peers...), nil
+}
+
+func (f *Fake) ConnectPeer(ctx context.Context, pubkey string, host string, perm bool) error {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ConnectPeer", pubkey, host, perm); err != nil {
+    return err
+  }
+  for _, p := range f.Peers {
+    if p.PubKey == pubkey {
+      return fmt.Errorf("already connected to peer: %s", pubkey)
+    }
+  }
+  f.Peers = append(f.Peers, lndclient.PeerInfo{PubKey: pubkey, Address: host})
+  return nil
+}
+
+func (f *Fake) DisconnectPeer(ctx context.Context, pubkey string) error {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("DisconnectPeer", pubkey); err != nil {
+    return err
+  }
+  for i, p := range f.Peers {
+    if p.PubKey == pubkey {
+      f.Peers = append(f.Peers[:i], f.Peers[i+1:]...)
+      return nil
+    }
+  }
+  return fmt.Errorf("peer %s is not connected", pubkey)
+}
+
+func (f *Fake) ListChannels(ctx context.Context) ([]lndclient.ChannelInfo, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ListChannels"); err != nil {
+    return nil, err
+  }
+  return append([]lndclient.ChannelInfo{}, f.Channels...), nil
+}
+
+func (f *Fake) ListPendingChannels(ctx context.Context) ([]lndclient.PendingChannelInfo, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("ListPendingChannels"); err != nil {
+    return nil, err
+  }
+  return append([]lndclient.PendingChannelInfo{}, f.Pending...), nil
+}
+
+func (f *Fake) GetChannelPolicy(ctx context.Context, channelPoint string) (lndclient.ChannelPolicy, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("GetChannelPolicy", channelPoint); err != nil {
+    return lndclient.ChannelPolicy{}, err
+  }
+  policy, ok := f.Policies[channelPoint]
+  if !ok {
+    return lndclient.ChannelPolicy{}, errors.New("channel not found")
+  }
+  return policy, nil
+}
+
+func (f *Fake) OpenChannel(ctx context.Context, pubkeyHex string, localFundingSat int64, closeAddress string, private bool, satPerVbyte int64) (string, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("OpenChannel", pubkeyHex, localFundingSat, closeAddress, private, satPerVbyte); err != nil {
+    return "", err
+  }
+  channelPoint := f.fakeTxid() + ":0"
+  f.Pending = append(f.Pending, lndclient.PendingChannelInfo{
+    ChannelPoint: channelPoint,
+    RemotePubkey: pubkeyHex,
+    CapacitySat: localFundingSat,
+    LocalBalanceSat: localFundingSat,
+    Status: "opening",
+    Private: private,
+  })
+  return channelPoint, nil
+}
+
+func (f *Fake) EstimateFundingFee(ctx context.Context, amountSat int64, targetConf int32) (lndclient.FeeEstimate, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("EstimateFundingFee", amountSat, targetConf); err != nil {
+    return lndclient.FeeEstimate{}, err
+  }
+  return f.FundingFee, nil
+}
+
+func (f *Fake) CloseChannel(ctx context.Context, req lndclient.CloseChannelRequest) (lndclient.CloseResult, error) {
+  result, err := f.closeChannel(req)
+  if err != nil || req.OnUpdate == nil {
+    return result, err
+  }
+  req.OnUpdate(lndclient.CloseUpdate{Stage: lndclient.CloseStagePending})
+  req.OnUpdate(lndclient.CloseUpdate{Stage: lndclient.CloseStageBroadcast, ClosingTxid: result.ClosingTxid, FeePerVbyte: result.FeePerVbyte})
+  if f.CloseConfirmed != nil {
+    select {
+    case <-f.CloseConfirmed:
+    case <-ctx.Done():
+      return result, ctx.Err()
+    }
+  }
+  f.mu.Lock()
+  for i, item := range f.Pending {
+    if item.ChannelPoint == req.ChannelPoint {
+      f.Pending = append(f.Pending[:i], f.Pending[i+1:]...)
+      break
+    }
+  }
+  f.mu.Unlock()
+  req.OnUpdate(lndclient.CloseUpdate{Stage: lndclient.CloseStageConfirmed, ClosingTxid: result.ClosingTxid, FeePerVbyte: result.FeePerVbyte})
+  return result, nil
+}
+
+func (f *Fake) closeChannel(req lndclient.CloseChannelRequest) (lndclient.CloseResult, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("CloseChannel", req); err != nil {
+    return lndclient.CloseResult{}, err
+  }
+  for i, ch := range f.Channels {
+    if ch.ChannelPoint != req.ChannelPoint {
+      continue
+    }
+    f.Channels = append(f.Channels[:i], f.Channels[i+1:]...)
+    txid := f.fakeTxid()
+    status := "closing"
+    if req.Force {
+      status = "force_closing"
+    }
+    f.Pending = append(f.Pending, lndclient.PendingChannelInfo{
+      ChannelPoint: req.ChannelPoint,
+      RemotePubkey: ch.RemotePubkey,
+      PeerAlias: ch.PeerAlias,
+      CapacitySat: ch.CapacitySat,
+      LocalBalanceSat: ch.LocalBalanceSat,
+      RemoteBalanceSat: ch.RemoteBalanceSat,
+      Status: status,
+      ClosingTxid: txid,
+      Private: ch.Private,
+    })
+    return lndclient.CloseResult{ClosingTxid: txid, FeePerVbyte: req.SatPerVbyte}, nil
+  }
+  return lndclient.CloseResult{}, errors.New("channel not found")
+}
+
+func (f *Fake) UpdateChannelFees(ctx context.Context, channelPoint string, applyAll bool, baseFeeMsat int64, feeRatePpm int64, timeLockDelta int64, inboundEnabled bool, inboundBaseMsat int64, inboundFeeRatePpm int64) error {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("UpdateChannelFees", channelPoint, applyAll, baseFeeMsat, feeRatePpm, timeLockDelta, inboundEnabled, inboundBaseMsat, inboundFeeRatePpm); err != nil {
+    return err
+  }
+  points := []string{channelPoint}
+  if applyAll {
+    points = points[:0]
+    for _, ch := range f.Channels {
+      points = append(points, ch.ChannelPoint)
+    }
+  }
+  if f.Policies == nil {
+    f.Policies = map[string]lndclient.ChannelPolicy{}
+  }
+  for _, point := range points {
+    policy := lndclient.ChannelPolicy{
+      ChannelPoint: point,
+      BaseFeeMsat: baseFeeMsat,
+      FeeRatePpm: feeRatePpm,
+      TimeLockDelta: timeLockDelta,
+    }
+    if inboundEnabled {
+      policy.InboundBaseMsat = inboundBaseMsat
+      policy.InboundFeeRatePpm = inboundFeeRatePpm
+    }
+    f.Policies[point] = policy
+  }
+  return nil
+}
+
+func (f *Fake) SpliceCapability(ctx context.Context) (lndclient.SpliceCapability, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("SpliceCapability"); err != nil {
+    return lndclient.SpliceCapability{}, err
+  }
+  return f.SpliceCaps, nil
+}
+
+func (f *Fake) Splice(ctx context.Context, req lndclient.SpliceRequest) (lndclient.SpliceResult, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.record("Splice", req); err != nil {
+    return lndclient.SpliceResult{}, err
+  }
+  if !f.SpliceCaps.Supported {
+    return lndclient.SpliceResult{}, lndclient.ErrSpliceUnsupported
+  }
+  return lndclient.SpliceResult{Txid: f.fakeTxid()}, nil
+}
+
+func (f *Fake) Lifecycle() lndclient.Lifecycle {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  return f.State
+}
+
+func (f *Fake) Ready() bool {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  return f.State.State == lndclient.StateServerActive
+}
+
+func (f *Fake) MarkRestarting() {
+  f.call("MarkRestarting")
+  f.SetState(lndclient.StateRestarting)
+}
+
+func (f *Fake) OnStateChange(fn func(prev, next lndclient.Lifecycle)) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  f.listeners = append(f.listeners, fn)
+}
+
+func (f *Fake) WaitReady(ctx context.Context) error {
+  f.mu.Lock()
+  if f.State.State == lndclient.StateServerActive {
+    f.mu.Unlock()
+    return nil
+  }
+  if f.readyCh == nil {
+    f.readyCh = make(chan struct{})
+  }
+  ready := f.readyCh
+  f.mu.Unlock()
+  select {
+  case <-ctx.Done():
+    return ctx.Err()
+  case <-ready:
+    return nil
+  }
+}
+
+// WatchState is a no-op; tests drive the lifecycle with SetState.
+func (f *Fake) WatchState(ctx context.Context) {}
+
+func limitSlice[T any](items []T, limit int) []T {
+  out := append([]T{}, items...)
+  if limit > 0 && len(out) > limit {
+    out = out[:limit]
+  }
+  return out
+}
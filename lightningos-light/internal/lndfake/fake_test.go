@@ -0,0 +1,68 @@
+package lndfake
+
+import (
+  "context"
+  "errors"
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestFakeTracksChanges(t *testing.T) {
+  ctx := context.Background()
+  f := New()
+  f.Channels = []lndclient.ChannelInfo{{ChannelPoint: "aa:0", RemotePubkey: "02aa", Active: true, CapacitySat: 1000}}
+
+  if err := f.ConnectPeer(ctx, "02bb", "10.0.0.2:9735", true); err != nil {
+    t.Fatalf("connect: %v", err)
+  }
+  if err := f.ConnectPeer(ctx, "02bb", "10.0.0.2:9735", true); err == nil {
+    t.Fatal("expected duplicate connect to fail")
+  }
+  if _, err := f.CloseChannel(ctx, lndclient.CloseChannelRequest{ChannelPoint: "aa:0", Force: true}); err != nil {
+    t.Fatalf("close: %v", err)
+  }
+  pending, _ := f.ListPendingChannels(ctx)
+  if len(f.Channels) != 0 || len(pending) != 1 || pending[0].Status != "force_closing" {
+    t.Fatalf("close not reflected: channels=%v pending=%v", f.Channels, pending)
+  }
+
+  inv, err := f.CreateInvoice(ctx, 2100, "coffee", 3600)
+  if err != nil {
+    t.Fatalf("invoice: %v", err)
+  }
+  decoded, err := f.DecodeInvoice(ctx, inv.PaymentRequest)
+  if err != nil || decoded.AmountSat != 2100 || decoded.PaymentHash != inv.PaymentHash {
+    t.Fatalf("decode: %+v, %v", decoded, err)
+  }
+
+  f.Fail("ListPeers", errors.New("boom"))
+  if _, err := f.ListPeers(ctx); err == nil {
+    t.Fatal("expected injected error")
+  }
+  if got := len(f.Calls("ConnectPeer")); got != 2 {
+    t.Fatalf("ConnectPeer calls = %d", got)
+  }
+}
+
+func TestFakeLifecycle(t *testing.T) {
+  f := New()
+  var seen []string
+  f.OnStateChange(func(prev, next lndclient.Lifecycle) { seen = append(seen, next.State) })
+  f.MarkRestarting()
+  if f.Ready() {
+    t.Fatal("restarting node must not be ready")
+  }
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  if err := f.WaitReady(ctx); err == nil {
+    t.Fatal("expected WaitReady to honor the context")
+  }
+  f.SetState(lndclient.StateServerActive)
+  if err := f.WaitReady(context.Background()); err != nil {
+    t.Fatalf("WaitReady: %v", err)
+  }
+  if len(seen) != 2 || seen[0] != lndclient.StateRestarting || seen[1] != lndclient.StateServerActive {
+    t.Fatalf("unexpected transitions: %v", seen)
+  }
+}
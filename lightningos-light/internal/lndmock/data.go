@@ -0,0 +1,258 @@
+package lndmock
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "fmt"
+  "math/rand"
+  "sort"
+  "time"
+
+  "lightningos-light/lnrpc"
+)
+
+const (
+  mockSeed = 21000000
+  mockBlockHeight = 880000
+  mockHistoryDays = 30
+)
+
+var mockAliases = []string{
+  "ACINQ", "WalletOfSatoshi", "Kraken", "LNBig", "Boltz", "River", "Breez", "Voltage",
+}
+
+type peerSeed struct {
+  Pubkey string
+  Alias string
+  Address string
+}
+
+// dataset is the deterministic state served by the fake node. Every run with
+// the same seed produces the same peers, channels and history.
+type dataset struct {
+  pubkey string
+  alias string
+  peers []peerSeed
+  channels []*lnrpc.Channel
+  closed []*lnrpc.ChannelCloseSummary
+  payments []*lnrpc.Payment
+  invoices []*lnrpc.Invoice
+  forwards []*lnrpc.ForwardingEvent
+  transactions []*lnrpc.Transaction
+  utxos []*lnrpc.Utxo
+  policies map[string]*lnrpc.RoutingPolicy
+  onchainSat int64
+}
+
+func mockHash(parts ...any) string {
+  sum := sha256.Sum256([]byte(fmt.Sprint(parts...)))
+  return hex.EncodeToString(sum[:])
+}
+
+func mockPubkey(parts ...any) string {
+  return "02" + mockHash(parts...)
+}
+
+func newDataset(now time.Time) *dataset {
+  rng := rand.New(rand.NewSource(mockSeed))
+  start := now.Add(-mockHistoryDays * 24 * time.Hour)
+  d := &dataset{
+    pubkey: mockPubkey("lightningos-mock-node"),
+    alias: "LightningOS Mock",
+    policies: map[string]*lnrpc.RoutingPolicy{},
+    onchainSat: 2500000,
+  }
+
+  for i, alias := range mockAliases {
+    pubkey := mockPubkey("peer", i)
+    d.peers = append(d.peers, peerSeed{
+      Pubkey: pubkey,
+      Alias: alias,
+      Address: fmt.Sprintf("10.21.%d.%d:9735", i/250, i%250+1),
+    })
+    capacity := int64(1000000 + rng.Intn(9)*500000)
+    local := capacity * int64(20+rng.Intn(60)) / 100
+    chanPoint := fmt.Sprintf("%s:%d", mockHash("funding", i), i%2)
+    ch := &lnrpc.Channel{
+      Active: i != len(mockAliases)-1,
+      RemotePubkey: pubkey,
+      ChannelPoint: chanPoint,
+      ChanId: uint64(mockBlockHeight-1000+i*37)<<40 | uint64(i+1)<<16 | uint64(i%2),
+      Capacity: capacity,
+      LocalBalance: local,
+      RemoteBalance: capacity - local - 3000,
+      CommitFee: 3000,
+      CsvDelay: 144,
+      Initiator: i%3 != 0,
+      Lifetime: int64(mockHistoryDays * 24 * 3600),
+      Uptime: int64(mockHistoryDays * 24 * 3600),
+      PeerAlias: alias,
+    }
+    d.channels = append(d.channels, ch)
+    d.policies[chanPoint] = &lnrpc.RoutingPolicy{
+      TimeLockDelta: 80,
+      MinHtlc: 1000,
+      FeeBaseMsat: 1000,
+      FeeRateMilliMsat: int64(100 + rng.Intn(8)*50),
+      MaxHtlcMsat: uint64(capacity) * 990,
+    }
+    d.transactions = append(d.transactions, &lnrpc.Transaction{
+      TxHash: mockHash("funding", i),
+      Amount: -capacity,
+      NumConfirmations: int32(1000 - i*37),
+      BlockHeight: int32(mockBlockHeight - 1000 + i*37),
+      TimeStamp: start.Add(time.Duration(i) * 6 * time.Hour).Unix(),
+      TotalFees: int64(1500 + rng.Intn(1500)),
+      Label: "mock channel open",
+    })
+  }
+
+  for i := 0; i < 2; i++ {
+    d.closed = append(d.closed, &lnrpc.ChannelCloseSummary{
+      ChannelPoint: fmt.Sprintf("%s:0", mockHash("closed", i)),
+      ChanId: uint64(mockBlockHeight-5000+i*11) << 40,
+      ClosingTxHash: mockHash("closing", i),
+      RemotePubkey: mockPubkey("closed-peer", i),
+      Capacity: 2000000,
+      CloseHeight: uint32(mockBlockHeight - 200 + i*50),
+      SettledBalance: int64(400000 + i*300000),
+      CloseType: lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE,
+    })
+    d.transactions = append(d.transactions, &lnrpc.Transaction{
+      TxHash: mockHash("closing", i),
+      Amount: int64(400000 + i*300000),
+      NumConfirmations: int32(200 - i*50),
+      BlockHeight: int32(mockBlockHeight - 200 + i*50),
+      TimeStamp: start.Add(time.Duration(10+i*5) * 24 * time.Hour).Unix(),
+      Label: "mock channel close",
+    })
+  }
+
+  for i := 0; i < 3; i++ {
+    amount := int64(500000 + i*250000)
+    txid := mockHash("deposit", i)
+    d.transactions = append(d.transactions, &lnrpc.Transaction{
+      TxHash: txid,
+      Amount: amount,
+      NumConfirmations: int32(3000 - i*100),
+      BlockHeight: int32(mockBlockHeight - 3000 + i*100),
+      TimeStamp: start.Add(time.Duration(i) * time.Hour).Unix(),
+      DestAddresses: []string{fmt.Sprintf("bc1qmock%032d", i)},
+    })
+    d.utxos = append(d.utxos, &lnrpc.Utxo{
+      AddressType: lnrpc.AddressType_WITNESS_PUBKEY_HASH,
+      Address: fmt.Sprintf("bc1qmock%032d", i),
+      AmountSat: amount,
+      Outpoint: &lnrpc.OutPoint{TxidStr: txid, OutputIndex: 0},
+      Confirmations: int64(3000 - i*100),
+    })
+  }
+
+  invoiceTimes := mockTimes(rng, start, 40)
+  for i, at := range invoiceTimes {
+    d.invoices = append(d.invoices, mockSettledInvoice(i, at, int64(1000+rng.Intn(200000))))
+  }
+
+  for i, at := range mockTimes(rng, start, 40) {
+    peer := d.peers[rng.Intn(len(d.peers))]
+    ch := d.channels[rng.Intn(len(d.channels))]
+    amount := int64(1000 + rng.Intn(150000))
+    feeMsat := amount * int64(1+rng.Intn(5))
+    d.payments = append(d.payments, mockPayment(uint64(i+1), at, amount, feeMsat, ch.ChanId, peer.Pubkey))
+  }
+
+  for i := 0; i < 400; i++ {
+    at := start.Add(time.Duration(rng.Int63n(int64(mockHistoryDays * 24 * time.Hour))))
+    in := d.channels[rng.Intn(len(d.channels))]
+    out := d.channels[rng.Intn(len(d.channels))]
+    if in == out {
+      continue
+    }
+    amountMsat := uint64(10000+rng.Intn(2000000)) * 1000
+    feeMsat := amountMsat * uint64(d.policies[out.ChannelPoint].FeeRateMilliMsat) / 1000000 + 1000
+    inID := uint64(i * 2)
+    outID := uint64(i*2 + 1)
+    d.forwards = append(d.forwards, &lnrpc.ForwardingEvent{
+      Timestamp: uint64(at.Unix()),
+      TimestampNs: uint64(at.UnixNano()),
+      ChanIdIn: in.ChanId,
+      ChanIdOut: out.ChanId,
+      AmtIn: (amountMsat + feeMsat) / 1000,
+      AmtOut: amountMsat / 1000,
+      AmtInMsat: amountMsat + feeMsat,
+      AmtOutMsat: amountMsat,
+      Fee: feeMsat / 1000,
+      FeeMsat: feeMsat,
+      PeerAliasIn: in.PeerAlias,
+      PeerAliasOut: out.PeerAlias,
+      IncomingHtlcId: &inID,
+      OutgoingHtlcId: &outID,
+    })
+  }
+  sortForwards(d.forwards)
+  return d
+}
+
+func mockSettledInvoice(index int, at time.Time, amount int64) *lnrpc.Invoice {
+  hash, _ := hex.DecodeString(mockHash("invoice", index))
+  return &lnrpc.Invoice{
+    Memo: fmt.Sprintf("mock invoice #%d", index+1),
+    RHash: hash,
+    Value: amount,
+    ValueMsat: amount * 1000,
+    Settled: true,
+    CreationDate: at.Add(-time.Minute).Unix(),
+    SettleDate: at.Unix(),
+    PaymentRequest: fmt.Sprintf("lnbcmock%d", index+1),
+    AddIndex: uint64(index + 1),
+    SettleIndex: uint64(index + 1),
+    AmtPaid: amount * 1000,
+    AmtPaidSat: amount,
+    AmtPaidMsat: amount * 1000,
+    State: lnrpc.Invoice_SETTLED,
+  }
+}
+
+func mockPayment(index uint64, at time.Time, amount int64, feeMsat int64, chanID uint64, dest string) *lnrpc.Payment {
+  route := &lnrpc.Route{
+    TotalAmt: amount + feeMsat/1000,
+    TotalAmtMsat: amount*1000 + feeMsat,
+    TotalFees: feeMsat / 1000,
+    TotalFeesMsat: feeMsat,
+    Hops: []*lnrpc.Hop{
+      {ChanId: chanID, AmtToForward: amount, AmtToForwardMsat: amount * 1000, FeeMsat: feeMsat, PubKey: dest},
+    },
+  }
+  return &lnrpc.Payment{
+    PaymentHash: mockHash("payment", index),
+    Value: amount,
+    ValueSat: amount,
+    ValueMsat: amount * 1000,
+    CreationDate: at.Unix(),
+    CreationTimeNs: at.UnixNano(),
+    Fee: feeMsat / 1000,
+    FeeSat: feeMsat / 1000,
+    FeeMsat: feeMsat,
+    PaymentPreimage: mockHash("preimage", index),
+    Status: lnrpc.Payment_SUCCEEDED,
+    PaymentIndex: index,
+    Htlcs: []*lnrpc.HTLCAttempt{
+      {Status: lnrpc.HTLCAttempt_SUCCEEDED, Route: route, AttemptTimeNs: at.UnixNano(), ResolveTimeNs: at.UnixNano()},
+    },
+  }
+}
+
+func mockTimes(rng *rand.Rand, start time.Time, count int) []time.Time {
+  times := make([]time.Time, 0, count)
+  for i := 0; i < count; i++ {
+    times = append(times, start.Add(time.Duration(rng.Int63n(int64(mockHistoryDays * 24 * time.Hour)))))
+  }
+  sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+  return times
+}
+
+func sortForwards(items []*lnrpc.ForwardingEvent) {
+  sort.Slice(items, func(i, j int) bool {
+    return items[i].TimestampNs < items[j].TimestampNs
+  })
+}
@@ -0,0 +1,776 @@
+// Package lndmock serves a deterministic in-memory LND over an in-process
+// gRPC connection so the manager can run without a real node or bitcoind.
+package lndmock
+
+import (
+  "context"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "log"
+  "net"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/lnrpc"
+
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/credentials/insecure"
+  "google.golang.org/grpc/status"
+  "google.golang.org/grpc/test/bufconn"
+)
+
+const (
+  mockVersion = "0.18.4-beta mock"
+  mockInvoiceInterval = 45 * time.Second
+)
+
+var mockSeedWords = strings.Fields("abandon ability able about above absent absorb abstract absurd abuse access accident account accuse achieve acid acoustic acquire across act action actor actress actual")
+
+// Node is a running fake LND.
+type Node struct {
+  logger *log.Logger
+  listener *bufconn.Listener
+  grpc *grpc.Server
+
+  mu sync.Mutex
+  data *dataset
+  unlocked bool
+  nextIndex uint64
+  invoiceSubs map[chan *lnrpc.Invoice]struct{}
+  txSubs map[chan *lnrpc.Transaction]struct{}
+  channelSubs map[chan *lnrpc.ChannelEventUpdate]struct{}
+  stop chan struct{}
+}
+
+type lightningServer struct {
+  lnrpc.UnimplementedLightningServer
+  node *Node
+}
+
+type unlockerServer struct {
+  lnrpc.UnimplementedWalletUnlockerServer
+  node *Node
+}
+
+type stateServer struct {
+  lnrpc.UnimplementedStateServer
+  node *Node
+}
+
+// Start launches the fake node and a generator that settles a new invoice
+// periodically so live views have something to show.
+func Start(logger *log.Logger) *Node {
+  n := &Node{
+    logger: logger,
+    listener: bufconn.Listen(1 << 20),
+    grpc: grpc.NewServer(),
+    data: newDataset(time.Now().UTC()),
+    unlocked: true,
+    invoiceSubs: map[chan *lnrpc.Invoice]struct{}{},
+    txSubs: map[chan *lnrpc.Transaction]struct{}{},
+    channelSubs: map[chan *lnrpc.ChannelEventUpdate]struct{}{},
+    stop: make(chan struct{}),
+  }
+  n.nextIndex = uint64(len(n.data.invoices))
+  lnrpc.RegisterLightningServer(n.grpc, &lightningServer{node: n})
+  lnrpc.RegisterWalletUnlockerServer(n.grpc, &unlockerServer{node: n})
+  lnrpc.RegisterStateServer(n.grpc, &stateServer{node: n})
+  go func() {
+    if err := n.grpc.Serve(n.listener); err != nil && n.logger != nil {
+      n.logger.Printf("lnd mock stopped: %v", err)
+    }
+  }()
+  go n.runGenerator()
+  return n
+}
+
+// Dial opens a client connection to the fake node.
+func (n *Node) Dial(ctx context.Context) (*grpc.ClientConn, error) {
+  return grpc.DialContext(ctx, "bufnet",
+    grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+      return n.listener.DialContext(ctx)
+    }),
+    grpc.WithTransportCredentials(insecure.NewCredentials()),
+  )
+}
+
+func (n *Node) Stop() {
+  close(n.stop)
+  n.grpc.Stop()
+}
+
+func (n *Node) runGenerator() {
+  ticker := time.NewTicker(mockInvoiceInterval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-n.stop:
+      return
+    case now := <-ticker.C:
+      n.mu.Lock()
+      index := int(n.nextIndex)
+      n.nextIndex++
+      invoice := mockSettledInvoice(index, now.UTC(), int64(1000+(index*7919)%50000))
+      n.data.invoices = append(n.data.invoices, invoice)
+      subs := make([]chan *lnrpc.Invoice, 0, len(n.invoiceSubs))
+      for ch := range n.invoiceSubs {
+        subs = append(subs, ch)
+      }
+      n.mu.Unlock()
+      for _, ch := range subs {
+        select {
+        case ch <- invoice:
+        default:
+        }
+      }
+    }
+  }
+}
+
+func (n *Node) requireUnlocked() error {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  if !n.unlocked {
+    return status.Error(codes.Unavailable, "wallet locked, unlock it to enable full RPC access")
+  }
+  return nil
+}
+
+func (n *Node) walletState() lnrpc.WalletState {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  if !n.unlocked {
+    return lnrpc.WalletState_LOCKED
+  }
+  return lnrpc.WalletState_SERVER_ACTIVE
+}
+
+func (n *Node) findChannel(chanPoint string, chanID uint64) *lnrpc.Channel {
+  for _, ch := range n.data.channels {
+    if (chanPoint != "" && ch.ChannelPoint == chanPoint) || (chanID != 0 && ch.ChanId == chanID) {
+      return ch
+    }
+  }
+  return nil
+}
+
+func (n *Node) aliasFor(pubkey string) string {
+  if pubkey == n.data.pubkey {
+    return n.data.alias
+  }
+  for _, peer := range n.data.peers {
+    if peer.Pubkey == pubkey {
+      return peer.Alias
+    }
+  }
+  return ""
+}
+
+func channelPointString(cp *lnrpc.ChannelPoint) string {
+  if cp == nil {
+    return ""
+  }
+  txid := cp.GetFundingTxidStr()
+  if txid == "" {
+    raw := cp.GetFundingTxidBytes()
+    reversed := make([]byte, len(raw))
+    for i := range raw {
+      reversed[len(raw)-1-i] = raw[i]
+    }
+    txid = hex.EncodeToString(reversed)
+  }
+  return fmt.Sprintf("%s:%d", txid, cp.OutputIndex)
+}
+
+func (s *lightningServer) GetInfo(ctx context.Context, req *lnrpc.GetInfoRequest) (*lnrpc.GetInfoResponse, error) {
+  if err := s.node.requireUnlocked(); err != nil {
+    return nil, err
+  }
+  n := s.node
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  var active, inactive uint32
+  for _, ch := range n.data.channels {
+    if ch.Active {
+      active++
+    } else {
+      inactive++
+    }
+  }
+  return &lnrpc.GetInfoResponse{
+    Version: mockVersion,
+    IdentityPubkey: n.data.pubkey,
+    Alias: n.data.alias,
+    Color: "#f7931a",
+    NumActiveChannels: active,
+    NumInactiveChannels: inactive,
+    NumPeers: uint32(len(n.data.peers)),
+    BlockHeight: mockBlockHeight,
+    SyncedToChain: true,
+    SyncedToGraph: true,
+    Uris: []string{n.data.pubkey + "@127.0.0.1:9735"},
+  }, nil
+}
+
+func (s *lightningServer) WalletBalance(ctx context.Context, req *lnrpc.WalletBalanceRequest) (*lnrpc.WalletBalanceResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  return &lnrpc.WalletBalanceResponse{
+    TotalBalance: s.node.data.onchainSat,
+    ConfirmedBalance: s.node.data.onchainSat,
+  }, nil
+}
+
+func (s *lightningServer) ChannelBalance(ctx context.Context, req *lnrpc.ChannelBalanceRequest) (*lnrpc.ChannelBalanceResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  var local, remote int64
+  for _, ch := range s.node.data.channels {
+    local += ch.LocalBalance
+    remote += ch.RemoteBalance
+  }
+  return &lnrpc.ChannelBalanceResponse{
+    Balance: local,
+    LocalBalance: &lnrpc.Amount{Sat: uint64(local), Msat: uint64(local) * 1000},
+    RemoteBalance: &lnrpc.Amount{Sat: uint64(remote), Msat: uint64(remote) * 1000},
+    UnsettledLocalBalance: &lnrpc.Amount{},
+  }, nil
+}
+
+func (s *lightningServer) ListChannels(ctx context.Context, req *lnrpc.ListChannelsRequest) (*lnrpc.ListChannelsResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  resp := &lnrpc.ListChannelsResponse{}
+  for _, ch := range s.node.data.channels {
+    if (req.ActiveOnly && !ch.Active) || (req.InactiveOnly && ch.Active) {
+      continue
+    }
+    resp.Channels = append(resp.Channels, ch)
+  }
+  return resp, nil
+}
+
+func (s *lightningServer) PendingChannels(ctx context.Context, req *lnrpc.PendingChannelsRequest) (*lnrpc.PendingChannelsResponse, error) {
+  return &lnrpc.PendingChannelsResponse{}, nil
+}
+
+func (s *lightningServer) ClosedChannels(ctx context.Context, req *lnrpc.ClosedChannelsRequest) (*lnrpc.ClosedChannelsResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  return &lnrpc.ClosedChannelsResponse{Channels: s.node.data.closed}, nil
+}
+
+func (s *lightningServer) ListPeers(ctx context.Context, req *lnrpc.ListPeersRequest) (*lnrpc.ListPeersResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  resp := &lnrpc.ListPeersResponse{}
+  for i, peer := range s.node.data.peers {
+    resp.Peers = append(resp.Peers, &lnrpc.Peer{
+      PubKey: peer.Pubkey,
+      Address: peer.Address,
+      BytesSent: uint64(100000 * (i + 1)),
+      BytesRecv: uint64(120000 * (i + 1)),
+      PingTime: int64(20000 + i*5000),
+      Inbound: i%2 == 1,
+    })
+  }
+  return resp, nil
+}
+
+func (s *lightningServer) ConnectPeer(ctx context.Context, req *lnrpc.ConnectPeerRequest) (*lnrpc.ConnectPeerResponse, error) {
+  if req.Addr == nil || req.Addr.Pubkey == "" {
+    return nil, status.Error(codes.InvalidArgument, "pubkey required")
+  }
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  for _, peer := range s.node.data.peers {
+    if peer.Pubkey == req.Addr.Pubkey {
+      return nil, status.Error(codes.AlreadyExists, "already connected to peer")
+    }
+  }
+  s.node.data.peers = append(s.node.data.peers, peerSeed{Pubkey: req.Addr.Pubkey, Address: req.Addr.Host})
+  return &lnrpc.ConnectPeerResponse{}, nil
+}
+
+func (s *lightningServer) DisconnectPeer(ctx context.Context, req *lnrpc.DisconnectPeerRequest) (*lnrpc.DisconnectPeerResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  peers := s.node.data.peers[:0]
+  found := false
+  for _, peer := range s.node.data.peers {
+    if peer.Pubkey == req.PubKey {
+      found = true
+      continue
+    }
+    peers = append(peers, peer)
+  }
+  if !found {
+    return nil, status.Error(codes.NotFound, "peer is not connected")
+  }
+  s.node.data.peers = peers
+  return &lnrpc.DisconnectPeerResponse{}, nil
+}
+
+func (s *lightningServer) GetNodeInfo(ctx context.Context, req *lnrpc.NodeInfoRequest) (*lnrpc.NodeInfo, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  alias := s.node.aliasFor(req.PubKey)
+  if alias == "" {
+    return nil, status.Error(codes.NotFound, "unable to find node")
+  }
+  return &lnrpc.NodeInfo{
+    Node: &lnrpc.LightningNode{PubKey: req.PubKey, Alias: alias},
+    NumChannels: 1,
+  }, nil
+}
+
+func (s *lightningServer) GetChanInfo(ctx context.Context, req *lnrpc.ChanInfoRequest) (*lnrpc.ChannelEdge, error) {
+  n := s.node
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  ch := n.findChannel(req.ChanPoint, req.ChanId)
+  if ch == nil {
+    return nil, status.Error(codes.NotFound, "edge not found")
+  }
+  policy := n.data.policies[ch.ChannelPoint]
+  return &lnrpc.ChannelEdge{
+    ChannelId: ch.ChanId,
+    ChanPoint: ch.ChannelPoint,
+    Node1Pub: n.data.pubkey,
+    Node2Pub: ch.RemotePubkey,
+    Capacity: ch.Capacity,
+    Node1Policy: policy,
+    Node2Policy: &lnrpc.RoutingPolicy{TimeLockDelta: 40, MinHtlc: 1000, FeeBaseMsat: 0, FeeRateMilliMsat: 250},
+  }, nil
+}
+
+func (s *lightningServer) DecodePayReq(ctx context.Context, req *lnrpc.PayReqString) (*lnrpc.PayReq, error) {
+  payReq := strings.TrimSpace(req.PayReq)
+  if payReq == "" {
+    return nil, status.Error(codes.InvalidArgument, "invalid payment request")
+  }
+  n := s.node
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  for _, inv := range n.data.invoices {
+    if inv.PaymentRequest == payReq {
+      return &lnrpc.PayReq{
+        Destination: n.data.pubkey,
+        PaymentHash: hex.EncodeToString(inv.RHash),
+        NumSatoshis: inv.Value,
+        NumMsat: inv.ValueMsat,
+        Timestamp: inv.CreationDate,
+        Expiry: 3600,
+        Description: inv.Memo,
+      }, nil
+    }
+  }
+  peer := n.data.peers[len(payReq)%len(n.data.peers)]
+  return &lnrpc.PayReq{
+    Destination: peer.Pubkey,
+    PaymentHash: mockHash("payreq", payReq),
+    NumSatoshis: 1000,
+    NumMsat: 1000000,
+    Timestamp: time.Now().Unix(),
+    Expiry: 3600,
+    Description: "mock payment to " + peer.Alias,
+  }, nil
+}
+
+func (s *lightningServer) AddInvoice(ctx context.Context, req *lnrpc.Invoice) (*lnrpc.AddInvoiceResponse, error) {
+  n := s.node
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  n.nextIndex++
+  hash, _ := hex.DecodeString(mockHash("added-invoice", n.nextIndex))
+  invoice := &lnrpc.Invoice{
+    Memo: req.Memo,
+    RHash: hash,
+    Value: req.Value,
+    ValueMsat: req.Value * 1000,
+    CreationDate: time.Now().Unix(),
+    PaymentRequest: fmt.Sprintf("lnbcmock%d", n.nextIndex),
+    AddIndex: n.nextIndex,
+    State: lnrpc.Invoice_OPEN,
+  }
+  n.data.invoices = append(n.data.invoices, invoice)
+  return &lnrpc.AddInvoiceResponse{RHash: hash, PaymentRequest: invoice.PaymentRequest, AddIndex: invoice.AddIndex}, nil
+}
+
+func (s *lightningServer) SendPaymentSync(ctx context.Context, req *lnrpc.SendRequest) (*lnrpc.SendResponse, error) {
+  decoded, err := s.DecodePayReq(ctx, &lnrpc.PayReqString{PayReq: req.PaymentRequest})
+  if err != nil {
+    return nil, err
+  }
+  amount := decoded.NumSatoshis
+  if req.Amt > 0 {
+    amount = req.Amt
+  }
+  n := s.node
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  ch := n.data.channels[0]
+  if ch.LocalBalance < amount {
+    return &lnrpc.SendResponse{PaymentError: "insufficient local balance"}, nil
+  }
+  ch.LocalBalance -= amount
+  ch.RemoteBalance += amount
+  pay := mockPayment(uint64(len(n.data.payments)+1), time.Now().UTC(), amount, 1000, ch.ChanId, decoded.Destination)
+  pay.PaymentHash = decoded.PaymentHash
+  pay.PaymentRequest = req.PaymentRequest
+  n.data.payments = append(n.data.payments, pay)
+  hash, _ := hex.DecodeString(decoded.PaymentHash)
+  preimage, _ := hex.DecodeString(pay.PaymentPreimage)
+  return &lnrpc.SendResponse{PaymentHash: hash, PaymentPreimage: preimage, PaymentRoute: pay.Htlcs[0].Route}, nil
+}
+
+func (s *lightningServer) NewAddress(ctx context.Context, req *lnrpc.NewAddressRequest) (*lnrpc.NewAddressResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  s.node.nextIndex++
+  return &lnrpc.NewAddressResponse{Address: fmt.Sprintf("bc1qmock%032d", s.node.nextIndex)}, nil
+}
+
+func (s *lightningServer) SendCoins(ctx context.Context, req *lnrpc.SendCoinsRequest) (*lnrpc.SendCoinsResponse, error) {
+  n := s.node
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  amount := req.Amount
+  if req.SendAll {
+    amount = n.data.onchainSat
+  }
+  if amount <= 0 || amount > n.data.onchainSat {
+    return nil, status.Error(codes.FailedPrecondition, "insufficient funds available to construct transaction")
+  }
+  n.data.onchainSat -= amount
+  n.nextIndex++
+  txid := mockHash("send", n.nextIndex)
+  tx := &lnrpc.Transaction{
+    TxHash: txid,
+    Amount: -amount,
+    TimeStamp: time.Now().Unix(),
+    TotalFees: 250,
+    DestAddresses: []string{req.Addr},
+    Label: req.Label,
+  }
+  n.data.transactions = append(n.data.transactions, tx)
+  for ch := range n.txSubs {
+    select {
+    case ch <- tx:
+    default:
+    }
+  }
+  return &lnrpc.SendCoinsResponse{Txid: txid}, nil
+}
+
+func (s *lightningServer) ListUnspent(ctx context.Context, req *lnrpc.ListUnspentRequest) (*lnrpc.ListUnspentResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  return &lnrpc.ListUnspentResponse{Utxos: s.node.data.utxos}, nil
+}
+
+func (s *lightningServer) GetTransactions(ctx context.Context, req *lnrpc.GetTransactionsRequest) (*lnrpc.TransactionDetails, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  return &lnrpc.TransactionDetails{Transactions: s.node.data.transactions}, nil
+}
+
+func (s *lightningServer) ListPayments(ctx context.Context, req *lnrpc.ListPaymentsRequest) (*lnrpc.ListPaymentsResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  resp := &lnrpc.ListPaymentsResponse{TotalNumPayments: uint64(len(s.node.data.payments))}
+  items := s.node.data.payments
+  if req.Reversed {
+    for i := len(items) - 1; i >= 0; i-- {
+      if req.IndexOffset > 0 && items[i].PaymentIndex >= req.IndexOffset {
+        continue
+      }
+      resp.Payments = append(resp.Payments, items[i])
+      if req.MaxPayments > 0 && uint64(len(resp.Payments)) >= req.MaxPayments {
+        break
+      }
+    }
+  } else {
+    for _, pay := range items {
+      if pay.PaymentIndex <= req.IndexOffset {
+        continue
+      }
+      resp.Payments = append(resp.Payments, pay)
+      if req.MaxPayments > 0 && uint64(len(resp.Payments)) >= req.MaxPayments {
+        break
+      }
+    }
+  }
+  if len(resp.Payments) > 0 {
+    resp.FirstIndexOffset = resp.Payments[0].PaymentIndex
+    resp.LastIndexOffset = resp.Payments[len(resp.Payments)-1].PaymentIndex
+  }
+  return resp, nil
+}
+
+func (s *lightningServer) ListInvoices(ctx context.Context, req *lnrpc.ListInvoiceRequest) (*lnrpc.ListInvoiceResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  resp := &lnrpc.ListInvoiceResponse{}
+  for _, inv := range s.node.data.invoices {
+    if inv.AddIndex <= req.IndexOffset || (req.PendingOnly && inv.State != lnrpc.Invoice_OPEN) {
+      continue
+    }
+    resp.Invoices = append(resp.Invoices, inv)
+    if req.NumMaxInvoices > 0 && uint64(len(resp.Invoices)) >= req.NumMaxInvoices {
+      break
+    }
+  }
+  if len(resp.Invoices) > 0 {
+    resp.FirstIndexOffset = resp.Invoices[0].AddIndex
+    resp.LastIndexOffset = resp.Invoices[len(resp.Invoices)-1].AddIndex
+  }
+  return resp, nil
+}
+
+func (s *lightningServer) ForwardingHistory(ctx context.Context, req *lnrpc.ForwardingHistoryRequest) (*lnrpc.ForwardingHistoryResponse, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  end := req.EndTime
+  if end == 0 {
+    end = uint64(time.Now().Unix())
+  }
+  limit := int(req.NumMaxEvents)
+  if limit <= 0 {
+    limit = 100
+  }
+  var matched []*lnrpc.ForwardingEvent
+  for _, fwd := range s.node.data.forwards {
+    if fwd.Timestamp >= req.StartTime && fwd.Timestamp < end {
+      matched = append(matched, fwd)
+    }
+  }
+  resp := &lnrpc.ForwardingHistoryResponse{LastOffsetIndex: req.IndexOffset}
+  for i := int(req.IndexOffset); i < len(matched) && len(resp.ForwardingEvents) < limit; i++ {
+    resp.ForwardingEvents = append(resp.ForwardingEvents, matched[i])
+    resp.LastOffsetIndex = uint32(i + 1)
+  }
+  return resp, nil
+}
+
+func (s *lightningServer) SignMessage(ctx context.Context, req *lnrpc.SignMessageRequest) (*lnrpc.SignMessageResponse, error) {
+  return &lnrpc.SignMessageResponse{Signature: "mock" + mockHash("sign", string(req.Msg))}, nil
+}
+
+func (s *lightningServer) ExportAllChannelBackups(ctx context.Context, req *lnrpc.ChanBackupExportRequest) (*lnrpc.ChanBackupSnapshot, error) {
+  s.node.mu.Lock()
+  defer s.node.mu.Unlock()
+  return &lnrpc.ChanBackupSnapshot{
+    MultiChanBackup: &lnrpc.MultiChanBackup{MultiChanBackup: []byte(mockHash("backup", len(s.node.data.channels)))},
+  }, nil
+}
+
+func (s *lightningServer) UpdateChannelPolicy(ctx context.Context, req *lnrpc.PolicyUpdateRequest) (*lnrpc.PolicyUpdateResponse, error) {
+  n := s.node
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  target := channelPointString(req.GetChanPoint())
+  for chanPoint, policy := range n.data.policies {
+    if !req.GetGlobal() && chanPoint != target {
+      continue
+    }
+    policy.FeeBaseMsat = req.BaseFeeMsat
+    if req.FeeRatePpm > 0 {
+      policy.FeeRateMilliMsat = int64(req.FeeRatePpm)
+    }
+    if req.TimeLockDelta > 0 {
+      policy.TimeLockDelta = req.TimeLockDelta
+    }
+    if req.InboundFee != nil {
+      policy.InboundFeeBaseMsat = req.InboundFee.BaseFeeMsat
+      policy.InboundFeeRateMilliMsat = req.InboundFee.FeeRatePpm
+    }
+  }
+  return &lnrpc.PolicyUpdateResponse{}, nil
+}
+
+func (s *lightningServer) OpenChannelSync(ctx context.Context, req *lnrpc.OpenChannelRequest) (*lnrpc.ChannelPoint, error) {
+  n := s.node
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  if req.LocalFundingAmount <= 0 || req.LocalFundingAmount > n.data.onchainSat {
+    return nil, status.Error(codes.FailedPrecondition, "not enough witness outputs to create funding transaction")
+  }
+  pubkey := req.NodePubkeyString
+  if pubkey == "" {
+    pubkey = hex.EncodeToString(req.NodePubkey)
+  }
+  n.data.onchainSat -= req.LocalFundingAmount
+  index := len(n.data.channels)
+  txid := mockHash("funding", index)
+  chanPoint := fmt.Sprintf("%s:0", txid)
+  n.data.channels = append(n.data.channels, &lnrpc.Channel{
+    Active: true,
+    RemotePubkey: pubkey,
+    ChannelPoint: chanPoint,
+    ChanId: uint64(mockBlockHeight)<<40 | uint64(index+1)<<16,
+    Capacity: req.LocalFundingAmount,
+    LocalBalance: req.LocalFundingAmount - req.PushSat - 3000,
+    RemoteBalance: req.PushSat,
+    CommitFee: 3000,
+    Initiator: true,
+    Private: req.Private,
+    PeerAlias: n.aliasFor(pubkey),
+  })
+  n.data.policies[chanPoint] = &lnrpc.RoutingPolicy{TimeLockDelta: 80, MinHtlc: 1000, FeeBaseMsat: 1000, FeeRateMilliMsat: 100}
+  return &lnrpc.ChannelPoint{FundingTxid: &lnrpc.ChannelPoint_FundingTxidStr{FundingTxidStr: txid}}, nil
+}
+
+func (s *lightningServer) CloseChannel(req *lnrpc.CloseChannelRequest, stream lnrpc.Lightning_CloseChannelServer) error {
+  n := s.node
+  chanPoint := channelPointString(req.ChannelPoint)
+  n.mu.Lock()
+  var closing *lnrpc.Channel
+  channels := n.data.channels[:0]
+  for _, ch := range n.data.channels {
+    if ch.ChannelPoint == chanPoint {
+      closing = ch
+      continue
+    }
+    channels = append(channels, ch)
+  }
+  if closing == nil {
+    n.mu.Unlock()
+    return status.Error(codes.NotFound, "unable to find channel")
+  }
+  n.data.channels = channels
+  closingTxid := mockHash("closing", chanPoint)
+  n.data.closed = append(n.data.closed, &lnrpc.ChannelCloseSummary{
+    ChannelPoint: chanPoint,
+    ChanId: closing.ChanId,
+    ClosingTxHash: closingTxid,
+    RemotePubkey: closing.RemotePubkey,
+    Capacity: closing.Capacity,
+    SettledBalance: closing.LocalBalance,
+    CloseType: lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE,
+  })
+  n.data.onchainSat += closing.LocalBalance
+  n.mu.Unlock()
+
+  txid, _ := hex.DecodeString(closingTxid)
+  return stream.Send(&lnrpc.CloseStatusUpdate{
+    Update: &lnrpc.CloseStatusUpdate_ClosePending{ClosePending: &lnrpc.PendingUpdate{Txid: txid}},
+  })
+}
+
+func (s *lightningServer) SubscribeInvoices(req *lnrpc.InvoiceSubscription, stream lnrpc.Lightning_SubscribeInvoicesServer) error {
+  n := s.node
+  ch := make(chan *lnrpc.Invoice, 16)
+  n.mu.Lock()
+  var backlog []*lnrpc.Invoice
+  for _, inv := range n.data.invoices {
+    if inv.State == lnrpc.Invoice_SETTLED && inv.SettleIndex > req.SettleIndex {
+      backlog = append(backlog, inv)
+    }
+  }
+  n.invoiceSubs[ch] = struct{}{}
+  n.mu.Unlock()
+  defer func() {
+    n.mu.Lock()
+    delete(n.invoiceSubs, ch)
+    n.mu.Unlock()
+  }()
+
+  for _, inv := range backlog {
+    if err := stream.Send(inv); err != nil {
+      return err
+    }
+  }
+  for {
+    select {
+    case <-stream.Context().Done():
+      return stream.Context().Err()
+    case inv := <-ch:
+      if err := stream.Send(inv); err != nil {
+        return err
+      }
+    }
+  }
+}
+
+func (s *lightningServer) SubscribeTransactions(req *lnrpc.GetTransactionsRequest, stream lnrpc.Lightning_SubscribeTransactionsServer) error {
+  n := s.node
+  ch := make(chan *lnrpc.Transaction, 16)
+  n.mu.Lock()
+  n.txSubs[ch] = struct{}{}
+  n.mu.Unlock()
+  defer func() {
+    n.mu.Lock()
+    delete(n.txSubs, ch)
+    n.mu.Unlock()
+  }()
+  for {
+    select {
+    case <-stream.Context().Done():
+      return stream.Context().Err()
+    case tx := <-ch:
+      if err := stream.Send(tx); err != nil {
+        return err
+      }
+    }
+  }
+}
+
+func (s *lightningServer) SubscribeChannelEvents(req *lnrpc.ChannelEventSubscription, stream lnrpc.Lightning_SubscribeChannelEventsServer) error {
+  <-stream.Context().Done()
+  return stream.Context().Err()
+}
+
+func (s *unlockerServer) GenSeed(ctx context.Context, req *lnrpc.GenSeedRequest) (*lnrpc.GenSeedResponse, error) {
+  return &lnrpc.GenSeedResponse{CipherSeedMnemonic: mockSeedWords}, nil
+}
+
+func (s *unlockerServer) InitWallet(ctx context.Context, req *lnrpc.InitWalletRequest) (*lnrpc.InitWalletResponse, error) {
+  if len(req.WalletPassword) < 8 {
+    return nil, errors.New("password must have at least 8 characters")
+  }
+  s.node.mu.Lock()
+  s.node.unlocked = true
+  s.node.mu.Unlock()
+  return &lnrpc.InitWalletResponse{}, nil
+}
+
+func (s *unlockerServer) UnlockWallet(ctx context.Context, req *lnrpc.UnlockWalletRequest) (*lnrpc.UnlockWalletResponse, error) {
+  s.node.mu.Lock()
+  s.node.unlocked = true
+  s.node.mu.Unlock()
+  return &lnrpc.UnlockWalletResponse{}, nil
+}
+
+func (s *stateServer) GetState(ctx context.Context, req *lnrpc.GetStateRequest) (*lnrpc.GetStateResponse, error) {
+  return &lnrpc.GetStateResponse{State: s.node.walletState()}, nil
+}
+
+// SubscribeState sends the current state, then every change until the
+// client goes away.
+func (s *stateServer) SubscribeState(req *lnrpc.SubscribeStateRequest, stream lnrpc.State_SubscribeStateServer) error {
+  last := s.node.walletState()
+  if err := stream.Send(&lnrpc.SubscribeStateResponse{State: last}); err != nil {
+    return err
+  }
+  ticker := time.NewTicker(500 * time.Millisecond)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-stream.Context().Done():
+      return nil
+    case <-s.node.stop:
+      return nil
+    case <-ticker.C:
+      if current := s.node.walletState(); current != last {
+        last = current
+        if err := stream.Send(&lnrpc.SubscribeStateResponse{State: current}); err != nil {
+          return err
+        }
+      }
+    }
+  }
+}
@@ -0,0 +1,69 @@
+package lndmock
+
+import (
+  "context"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+)
+
+func TestMockNodeServesClient(t *testing.T) {
+  node := Start(nil)
+  defer node.Stop()
+
+  client := lndclient.NewWithDialer(&config.Config{}, nil, node.Dial)
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+
+  status, err := client.GetStatus(ctx)
+  if err != nil {
+    t.Fatalf("status: %v", err)
+  }
+  if status.WalletState != "unlocked" || status.ChannelsActive == 0 || status.Pubkey == "" {
+    t.Fatalf("unexpected status: %+v", status)
+  }
+
+  channels, err := client.ListChannels(ctx)
+  if err != nil {
+    t.Fatalf("channels: %v", err)
+  }
+  if len(channels) != len(mockAliases) {
+    t.Fatalf("expected %d channels, got %d", len(mockAliases), len(channels))
+  }
+}
+
+func TestMockNodeReportsState(t *testing.T) {
+  node := Start(nil)
+  defer node.Stop()
+
+  client := lndclient.NewWithDialer(&config.Config{}, nil, node.Dial)
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+
+  client.WatchState(ctx)
+  if err := client.WaitReady(ctx); err != nil {
+    t.Fatalf("wait ready: %v", err)
+  }
+  if state := client.Lifecycle().State; state != lndclient.StateServerActive {
+    t.Fatalf("expected server_active, got %s", state)
+  }
+}
+
+func TestDatasetDeterministic(t *testing.T) {
+  now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+  a := newDataset(now)
+  b := newDataset(now)
+  if len(a.forwards) == 0 || len(a.forwards) != len(b.forwards) {
+    t.Fatalf("forward count mismatch: %d vs %d", len(a.forwards), len(b.forwards))
+  }
+  for i := range a.forwards {
+    if a.forwards[i].FeeMsat != b.forwards[i].FeeMsat || a.forwards[i].ChanIdOut != b.forwards[i].ChanIdOut {
+      t.Fatalf("forward %d differs between runs", i)
+    }
+  }
+  if a.channels[0].LocalBalance != b.channels[0].LocalBalance {
+    t.Fatalf("channel balances differ between runs")
+  }
+}
@@ -0,0 +1,421 @@
+// Package mqtt is a small MQTT 3.1.1 publisher: connect (optionally over
+// TLS, with a last will), publish at QoS 0 or 1, keep the session alive and
+// disconnect. It covers what the manager needs to feed a broker such as
+// Mosquitto for Home Assistant, without pulling in a full client library.
+package mqtt
+
+import (
+  "bufio"
+  "context"
+  "crypto/tls"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "io"
+  "net"
+  "net/url"
+  "sync"
+  "time"
+)
+
+const (
+  packetConnect = 1
+  packetConnAck = 2
+  packetPublish = 3
+  packetPubAck = 4
+  packetPingReq = 12
+  packetPingResp = 13
+  packetDisconnect = 14
+
+  defaultKeepAlive = 60 * time.Second
+  maxRemainingLength = 268435455
+)
+
+// ErrClosed is returned by Publish after the connection went away.
+var ErrClosed = errors.New("mqtt: connection closed")
+
+// Message is one PUBLISH. QoS 2 is not supported.
+type Message struct {
+  Topic string
+  Payload []byte
+  QoS byte
+  Retain bool
+}
+
+// Options configures Dial. Broker is a URL: mqtt://host:1883 (or tcp://)
+// for plain TCP, mqtts://host:8883 (or ssl://, tls://) for TLS.
+type Options struct {
+  Broker string
+  ClientID string
+  Username string
+  Password string
+  KeepAlive time.Duration
+  // InsecureSkipVerify accepts any broker certificate, for brokers on the
+  // LAN with a self-signed cert.
+  InsecureSkipVerify bool
+  Will *Message
+}
+
+// Client is a connected session. Publish is safe for concurrent use.
+type Client struct {
+  conn net.Conn
+  keepAlive time.Duration
+
+  writeMu sync.Mutex
+  mu sync.Mutex
+  nextID uint16
+  acks map[uint16]chan struct{}
+  err error
+  done chan struct{}
+  closeOnce sync.Once
+}
+
+// ConnectError is a CONNACK refusal.
+type ConnectError struct {
+  Code byte
+}
+
+func (e ConnectError) Error() string {
+  reasons := map[byte]string{
+    1: "unacceptable protocol version",
+    2: "client identifier rejected",
+    3: "server unavailable",
+    4: "bad user name or password",
+    5: "not authorized",
+  }
+  if reason, ok := reasons[e.Code]; ok {
+    return "mqtt: connection refused: " + reason
+  }
+  return fmt.Sprintf("mqtt: connection refused (code %d)", e.Code)
+}
+
+func brokerAddress(raw string) (string, bool, error) {
+  u, err := url.Parse(raw)
+  if err != nil || u.Host == "" {
+    return "", false, fmt.Errorf("mqtt: invalid broker url %q", raw)
+  }
+  useTLS := false
+  port := "1883"
+  switch u.Scheme {
+  case "mqtt", "tcp":
+  case "mqtts", "ssl", "tls":
+    useTLS = true
+    port = "8883"
+  default:
+    return "", false, fmt.Errorf("mqtt: unsupported scheme %q", u.Scheme)
+  }
+  host := u.Host
+  if u.Port() == "" {
+    host = net.JoinHostPort(u.Hostname(), port)
+  }
+  return host, useTLS, nil
+}
+
+// ValidateBroker checks a broker URL without connecting.
+func ValidateBroker(raw string) error {
+  _, _, err := brokerAddress(raw)
+  return err
+}
+
+// Dial connects and completes the MQTT handshake.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+  addr, useTLS, err := brokerAddress(opts.Broker)
+  if err != nil {
+    return nil, err
+  }
+  if opts.ClientID == "" {
+    return nil, errors.New("mqtt: client id required")
+  }
+  keepAlive := opts.KeepAlive
+  if keepAlive <= 0 {
+    keepAlive = defaultKeepAlive
+  }
+
+  var dialer net.Dialer
+  conn, err := dialer.DialContext(ctx, "tcp", addr)
+  if err != nil {
+    return nil, err
+  }
+  if useTLS {
+    host, _, _ := net.SplitHostPort(addr)
+    tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: opts.InsecureSkipVerify})
+    if err := tlsConn.HandshakeContext(ctx); err != nil {
+      conn.Close()
+      return nil, err
+    }
+    conn = tlsConn
+  }
+  if deadline, ok := ctx.Deadline(); ok {
+    _ = conn.SetDeadline(deadline)
+  }
+
+  if _, err := conn.Write(encodeConnect(opts, keepAlive)); err != nil {
+    conn.Close()
+    return nil, err
+  }
+  reader := bufio.NewReader(conn)
+  kind, body, err := readPacket(reader)
+  if err != nil {
+    conn.Close()
+    return nil, err
+  }
+  if kind != packetConnAck || len(body) != 2 {
+    conn.Close()
+    return nil, fmt.Errorf("mqtt: expected CONNACK, got packet type %d", kind)
+  }
+  if body[1] != 0 {
+    conn.Close()
+    return nil, ConnectError{Code: body[1]}
+  }
+  _ = conn.SetDeadline(time.Time{})
+
+  c := &Client{
+    conn: conn,
+    keepAlive: keepAlive,
+    acks: map[uint16]chan struct{}{},
+    done: make(chan struct{}),
+  }
+  go c.readLoop(reader)
+  go c.pingLoop()
+  return c, nil
+}
+
+// Done is closed when the connection is lost or closed.
+func (c *Client) Done() <-chan struct{} {
+  return c.done
+}
+
+// Err is the reason the connection ended, once Done is closed.
+func (c *Client) Err() error {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  return c.err
+}
+
+// Publish sends m. At QoS 1 it waits for the broker's PUBACK.
+func (c *Client) Publish(ctx context.Context, m Message) error {
+  if m.QoS > 1 {
+    return errors.New("mqtt: QoS 2 not supported")
+  }
+  var id uint16
+  var ack chan struct{}
+  if m.QoS == 1 {
+    c.mu.Lock()
+    c.nextID++
+    if c.nextID == 0 {
+      c.nextID = 1
+    }
+    id = c.nextID
+    ack = make(chan struct{})
+    c.acks[id] = ack
+    c.mu.Unlock()
+    defer func() {
+      c.mu.Lock()
+      delete(c.acks, id)
+      c.mu.Unlock()
+    }()
+  }
+  packet, err := encodePublish(m, id)
+  if err != nil {
+    return err
+  }
+  if err := c.write(ctx, packet); err != nil {
+    return err
+  }
+  if ack == nil {
+    return nil
+  }
+  select {
+  case <-ack:
+    return nil
+  case <-c.done:
+    return ErrClosed
+  case <-ctx.Done():
+    return ctx.Err()
+  }
+}
+
+// Close sends DISCONNECT, so the broker does not publish the will, and
+// closes the connection.
+func (c *Client) Close() error {
+  select {
+  case <-c.done:
+    return nil
+  default:
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  _ = c.write(ctx, []byte{packetDisconnect << 4, 0})
+  cancel()
+  c.shutdown(ErrClosed)
+  return nil
+}
+
+func (c *Client) write(ctx context.Context, packet []byte) error {
+  select {
+  case <-c.done:
+    return ErrClosed
+  default:
+  }
+  c.writeMu.Lock()
+  defer c.writeMu.Unlock()
+  deadline, ok := ctx.Deadline()
+  if !ok {
+    deadline = time.Now().Add(c.keepAlive)
+  }
+  _ = c.conn.SetWriteDeadline(deadline)
+  if _, err := c.conn.Write(packet); err != nil {
+    c.shutdown(err)
+    return err
+  }
+  return nil
+}
+
+func (c *Client) shutdown(err error) {
+  c.closeOnce.Do(func() {
+    c.mu.Lock()
+    c.err = err
+    c.mu.Unlock()
+    close(c.done)
+    c.conn.Close()
+  })
+}
+
+func (c *Client) readLoop(reader *bufio.Reader) {
+  for {
+    // The broker must answer a PINGREQ within the keep-alive, so silence
+    // for one and a half periods means the link is dead.
+    _ = c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+    kind, body, err := readPacket(reader)
+    if err != nil {
+      c.shutdown(err)
+      return
+    }
+    if kind == packetPubAck && len(body) >= 2 {
+      id := binary.BigEndian.Uint16(body)
+      c.mu.Lock()
+      if ack, ok := c.acks[id]; ok {
+        close(ack)
+        delete(c.acks, id)
+      }
+      c.mu.Unlock()
+    }
+  }
+}
+
+func (c *Client) pingLoop() {
+  ticker := time.NewTicker(c.keepAlive / 2)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-c.done:
+      return
+    case <-ticker.C:
+      ctx, cancel := context.WithTimeout(context.Background(), c.keepAlive/2)
+      err := c.write(ctx, []byte{packetPingReq << 4, 0})
+      cancel()
+      if err != nil {
+        return
+      }
+    }
+  }
+}
+
+func appendString(buf []byte, s string) []byte {
+  buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+  return append(buf, s...)
+}
+
+func appendRemainingLength(buf []byte, n int) []byte {
+  for {
+    b := byte(n % 128)
+    n /= 128
+    if n > 0 {
+      b |= 0x80
+    }
+    buf = append(buf, b)
+    if n == 0 {
+      return buf
+    }
+  }
+}
+
+func encodeConnect(opts Options, keepAlive time.Duration) []byte {
+  var flags byte = 0x02 // clean session
+  body := appendString(nil, "MQTT")
+  body = append(body, 4) // protocol level 3.1.1
+  if opts.Will != nil {
+    flags |= 0x04 | (opts.Will.QoS&0x03)<<3
+    if opts.Will.Retain {
+      flags |= 0x20
+    }
+  }
+  if opts.Username != "" {
+    flags |= 0x80
+    if opts.Password != "" {
+      flags |= 0x40
+    }
+  }
+  body = append(body, flags)
+  body = binary.BigEndian.AppendUint16(body, uint16(keepAlive/time.Second))
+  body = appendString(body, opts.ClientID)
+  if opts.Will != nil {
+    body = appendString(body, opts.Will.Topic)
+    body = binary.BigEndian.AppendUint16(body, uint16(len(opts.Will.Payload)))
+    body = append(body, opts.Will.Payload...)
+  }
+  if opts.Username != "" {
+    body = appendString(body, opts.Username)
+    if opts.Password != "" {
+      body = appendString(body, opts.Password)
+    }
+  }
+  packet := appendRemainingLength([]byte{packetConnect << 4}, len(body))
+  return append(packet, body...)
+}
+
+func encodePublish(m Message, id uint16) ([]byte, error) {
+  if m.Topic == "" || len(m.Topic) > 65535 {
+    return nil, errors.New("mqtt: invalid topic")
+  }
+  header := byte(packetPublish<<4) | (m.QoS&0x03)<<1
+  if m.Retain {
+    header |= 0x01
+  }
+  body := appendString(nil, m.Topic)
+  if m.QoS > 0 {
+    body = binary.BigEndian.AppendUint16(body, id)
+  }
+  body = append(body, m.Payload...)
+  if len(body) > maxRemainingLength {
+    return nil, errors.New("mqtt: payload too large")
+  }
+  packet := appendRemainingLength([]byte{header}, len(body))
+  return append(packet, body...), nil
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+  header, err := r.ReadByte()
+  if err != nil {
+    return 0, nil, err
+  }
+  length, multiplier := 0, 1
+  for i := 0; ; i++ {
+    if i == 4 {
+      return 0, nil, errors.New("mqtt: malformed remaining length")
+    }
+    b, err := r.ReadByte()
+    if err != nil {
+      return 0, nil, err
+    }
+    length += int(b&0x7f) * multiplier
+    if b&0x80 == 0 {
+      break
+    }
+    multiplier *= 128
+  }
+  body := make([]byte, length)
+  if _, err := io.ReadFull(r, body); err != nil {
+    return 0, nil, err
+  }
+  return header >> 4, body, nil
+}
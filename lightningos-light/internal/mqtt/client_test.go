@@ -0,0 +1,111 @@
+package mqtt
+
+import (
+  "bufio"
+  "bytes"
+  "context"
+  "encoding/binary"
+  "errors"
+  "net"
+  "testing"
+  "time"
+)
+
+// fakeBroker accepts one connection, answers CONNACK with code and acks
+// QoS 1 publishes. Received packets are sent on the returned channel.
+func fakeBroker(t *testing.T, code byte) (string, <-chan [2]any) {
+  t.Helper()
+  ln, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { ln.Close() })
+  packets := make(chan [2]any, 16)
+  go func() {
+    conn, err := ln.Accept()
+    if err != nil {
+      return
+    }
+    defer conn.Close()
+    r := bufio.NewReader(conn)
+    for {
+      kind, body, err := readPacket(r)
+      if err != nil {
+        close(packets)
+        return
+      }
+      packets <- [2]any{kind, body}
+      switch kind {
+      case packetConnect:
+        conn.Write([]byte{packetConnAck << 4, 2, 0, code})
+      case packetPublish:
+        topicLen := int(binary.BigEndian.Uint16(body))
+        if len(body) >= topicLen+4 {
+          id := body[2+topicLen : 4+topicLen]
+          conn.Write([]byte{packetPubAck << 4, 2, id[0], id[1]})
+        }
+      }
+    }
+  }()
+  return "mqtt://" + ln.Addr().String(), packets
+}
+
+func TestPublishQoS1(t *testing.T) {
+  broker, packets := fakeBroker(t, 0)
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  c, err := Dial(ctx, Options{
+    Broker: broker,
+    ClientID: "lightningos-test",
+    Username: "ha",
+    Password: "secret",
+    Will: &Message{Topic: "lightningos/node/availability", Payload: []byte("offline"), Retain: true},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  connect := <-packets
+  body := connect[1].([]byte)
+  // Flags: clean session, will, will retain, password and user name.
+  if connect[0].(byte) != packetConnect || body[7] != 0x02|0x04|0x20|0x40|0x80 {
+    t.Fatalf("unexpected CONNECT flags %08b", body[7])
+  }
+
+  if err := c.Publish(ctx, Message{Topic: "lightningos/node/state", Payload: []byte(`{"ok":true}`), QoS: 1, Retain: true}); err != nil {
+    t.Fatal(err)
+  }
+  publish := <-packets
+  if publish[0].(byte) != packetPublish {
+    t.Fatalf("expected PUBLISH, got %v", publish[0])
+  }
+  c.Close()
+  if disconnect := <-packets; disconnect[0].(byte) != packetDisconnect {
+    t.Fatalf("expected DISCONNECT, got %v", disconnect[0])
+  }
+  if !errors.Is(c.Publish(ctx, Message{Topic: "x"}), ErrClosed) {
+    t.Fatal("publish after close succeeded")
+  }
+}
+
+func TestDialRefused(t *testing.T) {
+  broker, _ := fakeBroker(t, 4)
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  _, err := Dial(ctx, Options{Broker: broker, ClientID: "x"})
+  var refused ConnectError
+  if !errors.As(err, &refused) || refused.Code != 4 {
+    t.Fatalf("expected bad credentials, got %v", err)
+  }
+}
+
+func TestRemainingLength(t *testing.T) {
+  for _, n := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152} {
+    buf := appendRemainingLength([]byte{packetPublish << 4}, n)
+    buf = append(buf, make([]byte, n)...)
+    kind, body, err := readPacket(bufio.NewReader(bytes.NewReader(buf)))
+    if err != nil || kind != packetPublish || len(body) != n {
+      t.Fatalf("length %d: kind=%d len=%d err=%v", n, kind, len(body), err)
+    }
+  }
+}
+
@@ -0,0 +1,546 @@
+// Package qrcode encodes byte strings as QR Code model 2 symbols (ISO/IEC
+// 18004) and renders them as SVG or PNG. It only knows byte mode, which is
+// all a BIP21 URI or a BOLT11 invoice needs, and picks the smallest version
+// that fits, without pulling in a full QR library.
+package qrcode
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+  "image"
+  "image/color"
+  "image/png"
+  "strings"
+)
+
+// Level is the error correction level.
+type Level int
+
+const (
+  Low Level = iota
+  Medium
+  Quartile
+  High
+)
+
+const (
+  minVersion = 1
+  maxVersion = 40
+)
+
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// eccCodewordsPerBlock and eccBlocks come from table 9 of the standard,
+// indexed by level and version; index 0 is unused.
+var eccCodewordsPerBlock = [4][41]int{
+  {-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+  {-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+  {-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+  {-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var eccBlocks = [4][41]int{
+  {-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+  {-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+  {-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+  {-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// formatLevelBits is the two-bit level indicator of the format information.
+var formatLevelBits = [4]int{1, 0, 3, 2}
+
+// Code is an encoded symbol. Dark(x, y) reads a module; the quiet zone is
+// not included in Size.
+type Code struct {
+  Version int
+  Level Level
+  Size int
+  modules [][]bool
+  function [][]bool
+}
+
+// Encode builds the smallest symbol holding data at the given level.
+func Encode(data []byte, level Level) (*Code, error) {
+  if level < Low || level > High {
+    return nil, fmt.Errorf("qrcode: invalid level %d", level)
+  }
+  version := 0
+  for v := minVersion; v <= maxVersion; v++ {
+    if byteModeBits(v, len(data)) <= dataCodewords(v, level)*8 {
+      version = v
+      break
+    }
+  }
+  if version == 0 {
+    return nil, ErrTooLong
+  }
+
+  capacity := dataCodewords(version, level) * 8
+  bits := &bitBuffer{}
+  bits.append(0x4, 4)
+  bits.append(len(data), countBits(version))
+  for _, b := range data {
+    bits.append(int(b), 8)
+  }
+  bits.append(0, min(4, capacity-bits.len()))
+  bits.append(0, (8-bits.len()%8)%8)
+  for pad := 0xEC; bits.len() < capacity; pad ^= 0xEC ^ 0x11 {
+    bits.append(pad, 8)
+  }
+
+  c := newCode(version, level)
+  c.drawFunctionPatterns()
+  c.drawCodewords(c.addECCAndInterleave(bits.bytes()))
+
+  best, bestPenalty := 0, -1
+  for mask := 0; mask < 8; mask++ {
+    c.applyMask(mask)
+    c.drawFormatBits(mask)
+    if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+      best, bestPenalty = mask, penalty
+    }
+    c.applyMask(mask)
+  }
+  c.applyMask(best)
+  c.drawFormatBits(best)
+  return c, nil
+}
+
+// Dark reports whether the module at column x, row y is dark.
+func (c *Code) Dark(x, y int) bool {
+  return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// SVG renders the symbol with a quiet zone of border modules, one user unit
+// per module, so it scales to whatever size the page gives it.
+func (c *Code) SVG(border int) string {
+  if border < 0 {
+    border = 0
+  }
+  dim := c.Size + border*2
+  var b strings.Builder
+  fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, dim, dim)
+  b.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/><path fill="#000000" d="`)
+  for y := 0; y < c.Size; y++ {
+    for x := 0; x < c.Size; x++ {
+      if c.modules[y][x] {
+        fmt.Fprintf(&b, "M%d,%dh1v1h-1z", x+border, y+border)
+      }
+    }
+  }
+  b.WriteString(`"/></svg>`)
+  return b.String()
+}
+
+// PNG renders the symbol scale pixels per module with a quiet zone of border
+// modules.
+func (c *Code) PNG(scale, border int) ([]byte, error) {
+  if scale < 1 {
+    scale = 1
+  }
+  if border < 0 {
+    border = 0
+  }
+  dim := (c.Size + border*2) * scale
+  img := image.NewPaletted(image.Rect(0, 0, dim, dim), color.Palette{color.White, color.Black})
+  for y := 0; y < dim; y++ {
+    for x := 0; x < dim; x++ {
+      if c.Dark(x/scale-border, y/scale-border) {
+        img.SetColorIndex(x, y, 1)
+      }
+    }
+  }
+  var buf bytes.Buffer
+  if err := png.Encode(&buf, img); err != nil {
+    return nil, err
+  }
+  return buf.Bytes(), nil
+}
+
+func newCode(version int, level Level) *Code {
+  size := version*4 + 17
+  c := &Code{Version: version, Level: level, Size: size}
+  c.modules = make([][]bool, size)
+  c.function = make([][]bool, size)
+  for i := range c.modules {
+    c.modules[i] = make([]bool, size)
+    c.function[i] = make([]bool, size)
+  }
+  return c
+}
+
+func countBits(version int) int {
+  if version <= 9 {
+    return 8
+  }
+  return 16
+}
+
+func byteModeBits(version int, n int) int {
+  if n >= 1<<countBits(version) {
+    return 1 << 30
+  }
+  return 4 + countBits(version) + n*8
+}
+
+// rawDataModules counts the modules left for data and ECC once the function
+// patterns and format/version information are placed.
+func rawDataModules(version int) int {
+  result := (16*version+128)*version + 64
+  if version >= 2 {
+    align := version/7 + 2
+    result -= (25*align-10)*align - 55
+    if version >= 7 {
+      result -= 36
+    }
+  }
+  return result
+}
+
+func dataCodewords(version int, level Level) int {
+  return rawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*eccBlocks[level][version]
+}
+
+func (c *Code) set(x, y int, dark bool) {
+  c.modules[y][x] = dark
+  c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+  for i := 0; i < c.Size; i++ {
+    c.set(6, i, i%2 == 0)
+    c.set(i, 6, i%2 == 0)
+  }
+  c.drawFinder(3, 3)
+  c.drawFinder(c.Size-4, 3)
+  c.drawFinder(3, c.Size-4)
+
+  positions := alignmentPositions(c.Version)
+  last := len(positions) - 1
+  for i, x := range positions {
+    for j, y := range positions {
+      if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+        continue
+      }
+      for dy := -2; dy <= 2; dy++ {
+        for dx := -2; dx <= 2; dx++ {
+          c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+        }
+      }
+    }
+  }
+
+  // Reserve the format area now; the real bits go in once the mask is known.
+  c.drawFormatBits(0)
+  c.drawVersion()
+}
+
+// drawFinder draws a finder pattern and its separator centred on (x, y).
+func (c *Code) drawFinder(x, y int) {
+  for dy := -4; dy <= 4; dy++ {
+    for dx := -4; dx <= 4; dx++ {
+      xx, yy := x+dx, y+dy
+      if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+        continue
+      }
+      dist := max(abs(dx), abs(dy))
+      c.set(xx, yy, dist != 2 && dist != 4)
+    }
+  }
+}
+
+func alignmentPositions(version int) []int {
+  if version == 1 {
+    return nil
+  }
+  count := version/7 + 2
+  step := 26
+  if version != 32 {
+    step = (version*4 + count*2 + 1) / (count*2 - 2) * 2
+  }
+  positions := make([]int, count)
+  positions[0] = 6
+  for i, pos := count-1, version*4+10; i >= 1; i, pos = i-1, pos-step {
+    positions[i] = pos
+  }
+  return positions
+}
+
+func (c *Code) drawFormatBits(mask int) {
+  data := formatLevelBits[c.Level]<<3 | mask
+  rem := data
+  for i := 0; i < 10; i++ {
+    rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+  }
+  bits := (data<<10 | rem) ^ 0x5412
+
+  for i := 0; i <= 5; i++ {
+    c.set(8, i, bit(bits, i))
+  }
+  c.set(8, 7, bit(bits, 6))
+  c.set(8, 8, bit(bits, 7))
+  c.set(7, 8, bit(bits, 8))
+  for i := 9; i < 15; i++ {
+    c.set(14-i, 8, bit(bits, i))
+  }
+
+  for i := 0; i < 8; i++ {
+    c.set(c.Size-1-i, 8, bit(bits, i))
+  }
+  for i := 8; i < 15; i++ {
+    c.set(8, c.Size-15+i, bit(bits, i))
+  }
+  c.set(8, c.Size-8, true)
+}
+
+func (c *Code) drawVersion() {
+  if c.Version < 7 {
+    return
+  }
+  rem := c.Version
+  for i := 0; i < 12; i++ {
+    rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+  }
+  bits := c.Version<<12 | rem
+  for i := 0; i < 18; i++ {
+    a, b := c.Size-11+i%3, i/3
+    c.set(a, b, bit(bits, i))
+    c.set(b, a, bit(bits, i))
+  }
+}
+
+// addECCAndInterleave splits the data into blocks, appends each block's
+// Reed-Solomon codewords and interleaves them in transmission order.
+func (c *Code) addECCAndInterleave(data []byte) []byte {
+  blocks := eccBlocks[c.Level][c.Version]
+  eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+  raw := rawDataModules(c.Version) / 8
+  shortBlocks := blocks - raw%blocks
+  shortLen := raw / blocks
+
+  divisor := rsDivisor(eccLen)
+  all := make([][]byte, blocks)
+  for i, k := 0, 0; i < blocks; i++ {
+    n := shortLen - eccLen
+    if i >= shortBlocks {
+      n++
+    }
+    block := append([]byte{}, data[k:k+n]...)
+    k += n
+    ecc := rsRemainder(block, divisor)
+    if i < shortBlocks {
+      block = append(block, 0)
+    }
+    all[i] = append(block, ecc...)
+  }
+
+  result := make([]byte, 0, raw)
+  for i := 0; i <= shortLen; i++ {
+    for j, block := range all {
+      // Short blocks carry a placeholder where long blocks have their
+      // extra data codeword.
+      if i != shortLen-eccLen || j >= shortBlocks {
+        result = append(result, block[i])
+      }
+    }
+  }
+  return result
+}
+
+// drawCodewords places the bits in the two-column zigzag from the bottom
+// right corner, skipping function modules and the vertical timing column.
+func (c *Code) drawCodewords(data []byte) {
+  i := 0
+  for right := c.Size - 1; right >= 1; right -= 2 {
+    if right == 6 {
+      right = 5
+    }
+    for vert := 0; vert < c.Size; vert++ {
+      for j := 0; j < 2; j++ {
+        x := right - j
+        y := vert
+        if (right+1)&2 == 0 {
+          y = c.Size - 1 - vert
+        }
+        if !c.function[y][x] && i < len(data)*8 {
+          c.modules[y][x] = bit(int(data[i>>3]), 7-i&7)
+          i++
+        }
+      }
+    }
+  }
+}
+
+// applyMask flips the data modules under mask; applying it twice undoes it.
+func (c *Code) applyMask(mask int) {
+  for y := 0; y < c.Size; y++ {
+    for x := 0; x < c.Size; x++ {
+      if c.function[y][x] {
+        continue
+      }
+      var flip bool
+      switch mask {
+      case 0:
+        flip = (x+y)%2 == 0
+      case 1:
+        flip = y%2 == 0
+      case 2:
+        flip = x%3 == 0
+      case 3:
+        flip = (x+y)%3 == 0
+      case 4:
+        flip = (x/3+y/2)%2 == 0
+      case 5:
+        flip = x*y%2+x*y%3 == 0
+      case 6:
+        flip = (x*y%2+x*y%3)%2 == 0
+      case 7:
+        flip = ((x+y)%2+x*y%3)%2 == 0
+      }
+      if flip {
+        c.modules[y][x] = !c.modules[y][x]
+      }
+    }
+  }
+}
+
+// finderLike is the 1:1:3:1:1 pattern with four light modules on one side,
+// penalised by rule 3 of the mask evaluation.
+var finderLike = [2][11]bool{
+  {true, false, true, true, true, false, true, false, false, false, false},
+  {false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores the symbol with the four mask evaluation rules; lower is
+// easier to scan.
+func (c *Code) penalty() int {
+  score := 0
+  line := func(get func(i int) bool) {
+    run := 1
+    for i := 1; i <= c.Size; i++ {
+      if i < c.Size && get(i) == get(i-1) {
+        run++
+        continue
+      }
+      if run >= 5 {
+        score += 3 + run - 5
+      }
+      run = 1
+    }
+    for i := 0; i+11 <= c.Size; i++ {
+      for _, pattern := range finderLike {
+        match := true
+        for k, dark := range pattern {
+          if get(i+k) != dark {
+            match = false
+            break
+          }
+        }
+        if match {
+          score += 40
+        }
+      }
+    }
+  }
+  for y := 0; y < c.Size; y++ {
+    line(func(i int) bool { return c.modules[y][i] })
+  }
+  for x := 0; x < c.Size; x++ {
+    line(func(i int) bool { return c.modules[i][x] })
+  }
+
+  dark := 0
+  for y := 0; y < c.Size; y++ {
+    for x := 0; x < c.Size; x++ {
+      if c.modules[y][x] {
+        dark++
+      }
+      if x+1 < c.Size && y+1 < c.Size {
+        m := c.modules[y][x]
+        if m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+          score += 3
+        }
+      }
+    }
+  }
+  total := c.Size * c.Size
+  k := (abs(dark*20-total*10)+total-1)/total - 1
+  return score + k*10
+}
+
+// rsDivisor returns the generator polynomial of the given degree, highest
+// coefficient first with the leading 1 dropped.
+func rsDivisor(degree int) []byte {
+  result := make([]byte, degree)
+  result[degree-1] = 1
+  root := byte(1)
+  for i := 0; i < degree; i++ {
+    for j := range result {
+      result[j] = gfMul(result[j], root)
+      if j+1 < len(result) {
+        result[j] ^= result[j+1]
+      }
+    }
+    root = gfMul(root, 0x02)
+  }
+  return result
+}
+
+func rsRemainder(data []byte, divisor []byte) []byte {
+  result := make([]byte, len(divisor))
+  for _, b := range data {
+    factor := b ^ result[0]
+    copy(result, result[1:])
+    result[len(result)-1] = 0
+    for i, d := range divisor {
+      result[i] ^= gfMul(d, factor)
+    }
+  }
+  return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+  z := 0
+  for i := 7; i >= 0; i-- {
+    z = (z << 1) ^ ((z >> 7) * 0x11D)
+    z ^= int((y>>i)&1) * int(x)
+  }
+  return byte(z)
+}
+
+type bitBuffer struct {
+  bits []bool
+}
+
+func (b *bitBuffer) append(value, n int) {
+  for i := n - 1; i >= 0; i-- {
+    b.bits = append(b.bits, (value>>i)&1 != 0)
+  }
+}
+
+func (b *bitBuffer) len() int {
+  return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+  out := make([]byte, len(b.bits)/8)
+  for i, set := range b.bits {
+    if set {
+      out[i>>3] |= 1 << (7 - i&7)
+    }
+  }
+  return out
+}
+
+func bit(value, i int) bool {
+  return (value>>i)&1 != 0
+}
+
+func abs(x int) int {
+  if x < 0 {
+    return -x
+  }
+  return x
+}
@@ -0,0 +1,226 @@
+package qrcode
+
+import (
+  "bytes"
+  "image/png"
+  "strings"
+  "testing"
+)
+
+// readSymbol undoes Encode from the modules alone: it reads the format
+// information, unmasks, collects the codewords, checks every block's
+// Reed-Solomon codewords and returns the byte mode payload.
+func readSymbol(t *testing.T, c *Code) []byte {
+  t.Helper()
+  format := 0
+  for i := 14; i >= 9; i-- {
+    format = format<<1 | b2i(c.Dark(14-i, 8))
+  }
+  format = format<<1 | b2i(c.Dark(7, 8))
+  format = format<<1 | b2i(c.Dark(8, 8))
+  format = format<<1 | b2i(c.Dark(8, 7))
+  for i := 5; i >= 0; i-- {
+    format = format<<1 | b2i(c.Dark(8, i))
+  }
+  format ^= 0x5412
+  second := 0
+  for i := 14; i >= 8; i-- {
+    second = second<<1 | b2i(c.Dark(8, c.Size-15+i))
+  }
+  for i := 7; i >= 0; i-- {
+    second = second<<1 | b2i(c.Dark(c.Size-1-i, 8))
+  }
+  if second^0x5412 != format {
+    t.Fatalf("format copies differ: %015b %015b", format, second^0x5412)
+  }
+  if formatLevelBits[c.Level] != format>>13 {
+    t.Fatalf("format level %d, want %d", format>>13, formatLevelBits[c.Level])
+  }
+  if !c.Dark(8, c.Size-8) {
+    t.Fatal("dark module missing")
+  }
+
+  // Work on a copy rebuilt from the function layout.
+  probe := newCode(c.Version, c.Level)
+  probe.drawFunctionPatterns()
+  for y := 0; y < c.Size; y++ {
+    copy(probe.modules[y], c.modules[y])
+  }
+  probe.applyMask(format >> 10 & 7)
+
+  raw := rawDataModules(c.Version) / 8
+  codewords := make([]byte, raw)
+  i := 0
+  for right := c.Size - 1; right >= 1; right -= 2 {
+    if right == 6 {
+      right = 5
+    }
+    for vert := 0; vert < c.Size; vert++ {
+      for j := 0; j < 2; j++ {
+        x, y := right-j, vert
+        if (right+1)&2 == 0 {
+          y = c.Size - 1 - vert
+        }
+        if !probe.function[y][x] && i < raw*8 {
+          if probe.modules[y][x] {
+            codewords[i>>3] |= 1 << (7 - i&7)
+          }
+          i++
+        }
+      }
+    }
+  }
+
+  blocks := eccBlocks[c.Level][c.Version]
+  eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+  shortBlocks := blocks - raw%blocks
+  shortLen := raw / blocks
+  all := make([][]byte, blocks)
+  k := 0
+  for i := 0; i <= shortLen; i++ {
+    for j := range all {
+      if i == shortLen-eccLen && j < shortBlocks {
+        continue
+      }
+      all[j] = append(all[j], codewords[k])
+      k++
+    }
+  }
+  data := []byte{}
+  divisor := rsDivisor(eccLen)
+  for _, block := range all {
+    n := len(block) - eccLen
+    if !bytes.Equal(rsRemainder(block[:n], divisor), block[n:]) {
+      t.Fatalf("block ecc mismatch")
+    }
+    data = append(data, block[:n]...)
+  }
+
+  if data[0]>>4 != 0x4 {
+    t.Fatalf("mode %x, want byte mode", data[0]>>4)
+  }
+  reader := &bitReader{data: data, pos: 4}
+  length := reader.read(countBits(c.Version))
+  out := make([]byte, length)
+  for i := range out {
+    out[i] = byte(reader.read(8))
+  }
+  return out
+}
+
+type bitReader struct {
+  data []byte
+  pos int
+}
+
+func (r *bitReader) read(n int) int {
+  value := 0
+  for i := 0; i < n; i++ {
+    value = value<<1 | int(r.data[r.pos>>3]>>(7-r.pos&7)&1)
+    r.pos++
+  }
+  return value
+}
+
+func b2i(v bool) int {
+  if v {
+    return 1
+  }
+  return 0
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+  invoice := "lnbc10u1pjexample" + strings.Repeat("qpzry9x8gf2tvdw0s3jn54khce6mua7l", 10)
+  for _, tc := range []struct {
+    text string
+    level Level
+  }{
+    {"hello", Low},
+    {"bitcoin:bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq?amount=0.0001", Medium},
+    {"bitcoin:bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq?amount=0.0001&lightning=" + invoice, Medium},
+    {strings.Repeat("x", 1200), Quartile},
+    {strings.Repeat("y", 90), High},
+  } {
+    c, err := Encode([]byte(tc.text), tc.level)
+    if err != nil {
+      t.Fatalf("encode %d bytes: %v", len(tc.text), err)
+    }
+    if c.Size != c.Version*4+17 {
+      t.Fatalf("size %d for version %d", c.Size, c.Version)
+    }
+    if got := string(readSymbol(t, c)); got != tc.text {
+      t.Fatalf("round trip version %d: got %q", c.Version, got)
+    }
+  }
+}
+
+func TestEncodePicksSmallestVersion(t *testing.T) {
+  // Version 1-L holds 19 data codewords: 12 header bits leave room for 17 bytes.
+  c, _ := Encode([]byte(strings.Repeat("a", 17)), Low)
+  if c.Version != 1 {
+    t.Fatalf("17 bytes: version %d", c.Version)
+  }
+  c, _ = Encode([]byte(strings.Repeat("a", 18)), Low)
+  if c.Version != 2 {
+    t.Fatalf("18 bytes: version %d", c.Version)
+  }
+  // 40-L holds 2956 data codewords, 2953 bytes after the 16-bit count.
+  if c, err := Encode(make([]byte, 2953), Low); err != nil || c.Version != 40 {
+    t.Fatalf("2953 bytes: %v", err)
+  }
+  if _, err := Encode(make([]byte, 2954), Low); err != ErrTooLong {
+    t.Fatalf("2954 bytes: %v", err)
+  }
+}
+
+func TestVersionInformation(t *testing.T) {
+  // Version 7 carries the published version block 0x07C94.
+  c, err := Encode(make([]byte, 120), Medium)
+  if err != nil || c.Version != 7 {
+    t.Fatalf("version %d: %v", c.Version, err)
+  }
+  got := 0
+  for i := 17; i >= 0; i-- {
+    got = got<<1 | b2i(c.Dark(c.Size-11+i%3, i/3))
+  }
+  if got != 0x07C94 {
+    t.Fatalf("version bits %05x", got)
+  }
+}
+
+func TestRender(t *testing.T) {
+  c, err := Encode([]byte("hello"), Medium)
+  if err != nil {
+    t.Fatal(err)
+  }
+  svg := c.SVG(4)
+  if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `viewBox="0 0 29 29"`) || !strings.Contains(svg, "M4,4h1v1h-1z") {
+    t.Fatalf("unexpected svg %s", svg)
+  }
+  data, err := c.PNG(2, 4)
+  if err != nil {
+    t.Fatal(err)
+  }
+  img, err := png.Decode(bytes.NewReader(data))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if b := img.Bounds(); b.Dx() != 58 || b.Dy() != 58 {
+    t.Fatalf("png bounds %v", b)
+  }
+  if r, _, _, _ := img.At(8, 8).RGBA(); r != 0 {
+    t.Fatal("finder corner not dark")
+  }
+  if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+    t.Fatal("quiet zone not light")
+  }
+}
+
+func TestReedSolomon(t *testing.T) {
+  // "HELLO WORLD" at 1-M, the worked example of the standard's annex.
+  data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+  want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+  if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+    t.Fatalf("ecc %v, want %v", got, want)
+  }
+}
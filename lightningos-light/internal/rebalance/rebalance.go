@@ -0,0 +1,209 @@
+package rebalance
+
+import (
+  "context"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "strings"
+
+  "lightningos-light/lnrpc"
+
+  "google.golang.org/grpc/status"
+)
+
+// A rebalance is a circular payment to ourselves: an invoice of our own is
+// paid out through one channel and forced back in through another by
+// pinning the last hop to that channel's peer. The route fee is what it
+// costs, capped at MaxFeePPM of the amount.
+const (
+  MaxFeePPMLimit = 10000
+  invoiceExpirySeconds = 3600
+)
+
+var (
+  ErrInvalidChannel = errors.New("outgoing and incoming channels are required")
+  ErrSameChannel = errors.New("outgoing and incoming channels must differ")
+  ErrInvalidAmount = errors.New("amount must be positive")
+  ErrInvalidFeePPM = errors.New("max fee ppm out of range")
+)
+
+type Request struct {
+  OutgoingChanID uint64 `json:"outgoing_chan_id"`
+  IncomingChanID uint64 `json:"incoming_chan_id"`
+  AmountSat int64 `json:"amount_sat"`
+  MaxFeePPM int64 `json:"max_fee_ppm"`
+}
+
+func (r Request) Validate() error {
+  switch {
+  case r.OutgoingChanID == 0 || r.IncomingChanID == 0:
+    return ErrInvalidChannel
+  case r.OutgoingChanID == r.IncomingChanID:
+    return ErrSameChannel
+  case r.AmountSat <= 0:
+    return ErrInvalidAmount
+  case r.MaxFeePPM <= 0 || r.MaxFeePPM > MaxFeePPMLimit:
+    return ErrInvalidFeePPM
+  }
+  return nil
+}
+
+// FeeLimitMsat is the most the route may cost.
+func (r Request) FeeLimitMsat() int64 {
+  return r.AmountSat * r.MaxFeePPM / 1000
+}
+
+type Result struct {
+  PaymentHash string `json:"payment_hash"`
+  OutgoingChanID uint64 `json:"outgoing_chan_id"`
+  IncomingChanID uint64 `json:"incoming_chan_id"`
+  OutgoingPeer string `json:"outgoing_peer"`
+  IncomingPeer string `json:"incoming_peer"`
+  AmountSat int64 `json:"amount_sat"`
+  FeeMsat int64 `json:"fee_msat"`
+  FeePPM int64 `json:"fee_ppm"`
+  Hops int `json:"hops"`
+  Memo string `json:"memo"`
+}
+
+// Stage names what Run is doing, for progress reports.
+type Stage string
+
+const (
+  StageChecking Stage = "checking"
+  StageInvoicing Stage = "invoicing"
+  StagePaying Stage = "paying"
+)
+
+type Engine struct {
+  client lnrpc.LightningClient
+  // OnStage, when set, is called as Run moves through its stages.
+  OnStage func(Stage)
+}
+
+func New(client lnrpc.LightningClient) *Engine {
+  return &Engine{client: client}
+}
+
+func (e *Engine) stage(stage Stage) {
+  if e.OnStage != nil {
+    e.OnStage(stage)
+  }
+}
+
+// Run makes one attempt. LND picks the route; a payment that fails (no
+// route within the fee budget, a hop out of liquidity) comes back as an
+// error and leaves both channels as they were.
+func (e *Engine) Run(ctx context.Context, req Request) (Result, error) {
+  if err := req.Validate(); err != nil {
+    return Result{}, err
+  }
+
+  e.stage(StageChecking)
+  channels, err := e.client.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+  if err != nil {
+    return Result{}, fmt.Errorf("list channels failed: %s", rpcMessage(err))
+  }
+  var out, in *lnrpc.Channel
+  for _, ch := range channels.Channels {
+    switch ch.ChanId {
+    case req.OutgoingChanID:
+      out = ch
+    case req.IncomingChanID:
+      in = ch
+    }
+  }
+  if err := checkChannels(req, out, in); err != nil {
+    return Result{}, err
+  }
+
+  e.stage(StageInvoicing)
+  memo := fmt.Sprintf("Rebalance %d -> %d", req.OutgoingChanID, req.IncomingChanID)
+  invoice, err := e.client.AddInvoice(ctx, &lnrpc.Invoice{
+    Memo: memo,
+    Value: req.AmountSat,
+    Expiry: invoiceExpirySeconds,
+  })
+  if err != nil {
+    return Result{}, fmt.Errorf("invoice failed: %s", rpcMessage(err))
+  }
+  lastHop, err := hex.DecodeString(in.RemotePubkey)
+  if err != nil {
+    return Result{}, fmt.Errorf("invalid peer pubkey for channel %d", in.ChanId)
+  }
+
+  e.stage(StagePaying)
+  resp, err := e.client.SendPaymentSync(ctx, &lnrpc.SendRequest{
+    PaymentRequest: invoice.PaymentRequest,
+    OutgoingChanId: req.OutgoingChanID,
+    LastHopPubkey: lastHop,
+    AllowSelfPayment: true,
+    FeeLimit: &lnrpc.FeeLimit{Limit: &lnrpc.FeeLimit_FixedMsat{FixedMsat: req.FeeLimitMsat()}},
+  })
+  if err != nil {
+    return Result{}, fmt.Errorf("payment failed: %s", rpcMessage(err))
+  }
+  if msg := strings.TrimSpace(resp.PaymentError); msg != "" {
+    return Result{}, fmt.Errorf("payment failed: %s", msg)
+  }
+
+  result := Result{
+    PaymentHash: strings.ToLower(hex.EncodeToString(invoice.RHash)),
+    OutgoingChanID: req.OutgoingChanID,
+    IncomingChanID: req.IncomingChanID,
+    OutgoingPeer: out.RemotePubkey,
+    IncomingPeer: in.RemotePubkey,
+    AmountSat: req.AmountSat,
+    Memo: memo,
+  }
+  if route := resp.PaymentRoute; route != nil {
+    result.FeeMsat = route.TotalFeesMsat
+    if result.FeeMsat == 0 {
+      result.FeeMsat = route.TotalFees * 1000
+    }
+    result.Hops = len(route.Hops)
+  }
+  result.FeePPM = result.FeeMsat * 1000 / req.AmountSat
+  return result, nil
+}
+
+// checkChannels refuses what LND would only fail on after trying routes:
+// a missing or inactive channel, or not enough balance on either side.
+func checkChannels(req Request, out, in *lnrpc.Channel) error {
+  if out == nil {
+    return fmt.Errorf("outgoing channel %d not found", req.OutgoingChanID)
+  }
+  if in == nil {
+    return fmt.Errorf("incoming channel %d not found", req.IncomingChanID)
+  }
+  if !out.Active {
+    return fmt.Errorf("outgoing channel %d is inactive", out.ChanId)
+  }
+  if !in.Active {
+    return fmt.Errorf("incoming channel %d is inactive", in.ChanId)
+  }
+  var reserve int64
+  if out.LocalConstraints != nil {
+    reserve = int64(out.LocalConstraints.ChanReserveSat)
+  }
+  if out.LocalBalance-reserve < req.AmountSat {
+    return fmt.Errorf("outgoing channel %d has %d sats to send", out.ChanId, max(out.LocalBalance-reserve, 0))
+  }
+  var remoteReserve int64
+  if in.RemoteConstraints != nil {
+    remoteReserve = int64(in.RemoteConstraints.ChanReserveSat)
+  }
+  if in.RemoteBalance-remoteReserve < req.AmountSat {
+    return fmt.Errorf("incoming channel %d has %d sats to receive", in.ChanId, max(in.RemoteBalance-remoteReserve, 0))
+  }
+  return nil
+}
+
+// rpcMessage is LND's own description of a gRPC error.
+func rpcMessage(err error) string {
+  if st, ok := status.FromError(err); ok && st.Message() != "" {
+    return st.Message()
+  }
+  return err.Error()
+}
@@ -0,0 +1,156 @@
+package rebalance
+
+import (
+  "context"
+  "errors"
+  "strings"
+  "testing"
+
+  "lightningos-light/lnrpc"
+
+  "google.golang.org/grpc"
+)
+
+const (
+  peerA = "02aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+  peerB = "03bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+type fakeLightning struct {
+  lnrpc.LightningClient
+  channels []*lnrpc.Channel
+  sent *lnrpc.SendRequest
+  resp *lnrpc.SendResponse
+}
+
+func (f *fakeLightning) ListChannels(ctx context.Context, in *lnrpc.ListChannelsRequest, opts ...grpc.CallOption) (*lnrpc.ListChannelsResponse, error) {
+  return &lnrpc.ListChannelsResponse{Channels: f.channels}, nil
+}
+
+func (f *fakeLightning) AddInvoice(ctx context.Context, in *lnrpc.Invoice, opts ...grpc.CallOption) (*lnrpc.AddInvoiceResponse, error) {
+  return &lnrpc.AddInvoiceResponse{PaymentRequest: "lnbc1test", RHash: []byte{0xab, 0xcd}}, nil
+}
+
+func (f *fakeLightning) SendPaymentSync(ctx context.Context, in *lnrpc.SendRequest, opts ...grpc.CallOption) (*lnrpc.SendResponse, error) {
+  f.sent = in
+  return f.resp, nil
+}
+
+func testChannels() []*lnrpc.Channel {
+  return []*lnrpc.Channel{
+    {ChanId: 1, RemotePubkey: peerA, Active: true, LocalBalance: 900000, RemoteBalance: 100000},
+    {ChanId: 2, RemotePubkey: peerB, Active: true, LocalBalance: 100000, RemoteBalance: 900000},
+  }
+}
+
+func TestRequestValidate(t *testing.T) {
+  cases := []struct {
+    req Request
+    want error
+  }{
+    {Request{OutgoingChanID: 1, IncomingChanID: 2, AmountSat: 1000, MaxFeePPM: 500}, nil},
+    {Request{IncomingChanID: 2, AmountSat: 1000, MaxFeePPM: 500}, ErrInvalidChannel},
+    {Request{OutgoingChanID: 1, IncomingChanID: 1, AmountSat: 1000, MaxFeePPM: 500}, ErrSameChannel},
+    {Request{OutgoingChanID: 1, IncomingChanID: 2, MaxFeePPM: 500}, ErrInvalidAmount},
+    {Request{OutgoingChanID: 1, IncomingChanID: 2, AmountSat: 1000}, ErrInvalidFeePPM},
+    {Request{OutgoingChanID: 1, IncomingChanID: 2, AmountSat: 1000, MaxFeePPM: MaxFeePPMLimit + 1}, ErrInvalidFeePPM},
+  }
+  for _, tc := range cases {
+    if err := tc.req.Validate(); !errors.Is(err, tc.want) {
+      t.Errorf("Validate(%+v) = %v, want %v", tc.req, err, tc.want)
+    }
+  }
+  if got := (Request{AmountSat: 100000, MaxFeePPM: 250}).FeeLimitMsat(); got != 25000 {
+    t.Errorf("FeeLimitMsat = %d, want 25000", got)
+  }
+}
+
+func TestRunPaysThroughBothChannels(t *testing.T) {
+  fake := &fakeLightning{
+    channels: testChannels(),
+    resp: &lnrpc.SendResponse{PaymentRoute: &lnrpc.Route{TotalFeesMsat: 12000, Hops: make([]*lnrpc.Hop, 3)}},
+  }
+  var stages []Stage
+  engine := New(fake)
+  engine.OnStage = func(stage Stage) { stages = append(stages, stage) }
+
+  result, err := engine.Run(context.Background(), Request{OutgoingChanID: 1, IncomingChanID: 2, AmountSat: 50000, MaxFeePPM: 500})
+  if err != nil {
+    t.Fatalf("Run: %v", err)
+  }
+  if fake.sent.OutgoingChanId != 1 || !fake.sent.AllowSelfPayment {
+    t.Fatalf("unexpected send request: %+v", fake.sent)
+  }
+  if got := fake.sent.FeeLimit.GetFixedMsat(); got != 25000 {
+    t.Fatalf("fee limit = %d msat, want 25000", got)
+  }
+  if len(fake.sent.LastHopPubkey) != 33 || fake.sent.LastHopPubkey[0] != 0x03 {
+    t.Fatalf("last hop should be the incoming peer, got %x", fake.sent.LastHopPubkey)
+  }
+  if result.PaymentHash != "abcd" || result.FeeMsat != 12000 || result.FeePPM != 240 || result.Hops != 3 {
+    t.Fatalf("unexpected result: %+v", result)
+  }
+  if result.IncomingPeer != peerB || result.OutgoingPeer != peerA {
+    t.Fatalf("unexpected peers: %+v", result)
+  }
+  if len(stages) != 3 || stages[2] != StagePaying {
+    t.Fatalf("stages = %v", stages)
+  }
+}
+
+func TestRunRefusesWithoutBalance(t *testing.T) {
+  cases := map[string]struct {
+    req Request
+    edit func([]*lnrpc.Channel)
+    want string
+  }{
+    "missing outgoing": {
+      req: Request{OutgoingChanID: 9, IncomingChanID: 2, AmountSat: 1000, MaxFeePPM: 100},
+      want: "outgoing channel 9 not found",
+    },
+    "inactive incoming": {
+      req: Request{OutgoingChanID: 1, IncomingChanID: 2, AmountSat: 1000, MaxFeePPM: 100},
+      edit: func(chs []*lnrpc.Channel) { chs[1].Active = false },
+      want: "incoming channel 2 is inactive",
+    },
+    "outgoing reserve": {
+      req: Request{OutgoingChanID: 1, IncomingChanID: 2, AmountSat: 895000, MaxFeePPM: 100},
+      edit: func(chs []*lnrpc.Channel) { chs[0].LocalConstraints = &lnrpc.ChannelConstraints{ChanReserveSat: 10000} },
+      want: "outgoing channel 1 has 890000 sats to send",
+    },
+    "outgoing short": {
+      req: Request{OutgoingChanID: 2, IncomingChanID: 1, AmountSat: 150000, MaxFeePPM: 100},
+      want: "outgoing channel 2 has 100000 sats to send",
+    },
+    "incoming short": {
+      req: Request{OutgoingChanID: 1, IncomingChanID: 2, AmountSat: 200000, MaxFeePPM: 100},
+      edit: func(chs []*lnrpc.Channel) { chs[1].RemoteBalance = 150000 },
+      want: "incoming channel 2 has 150000 sats to receive",
+    },
+  }
+  for name, tc := range cases {
+    channels := testChannels()
+    if tc.edit != nil {
+      tc.edit(channels)
+    }
+    fake := &fakeLightning{channels: channels}
+    _, err := New(fake).Run(context.Background(), tc.req)
+    if err == nil || err.Error() != tc.want {
+      t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+    }
+    if fake.sent != nil {
+      t.Errorf("%s: payment sent despite the check", name)
+    }
+  }
+}
+
+func TestRunReportsPaymentError(t *testing.T) {
+  fake := &fakeLightning{
+    channels: testChannels(),
+    resp: &lnrpc.SendResponse{PaymentError: "no_route"},
+  }
+  _, err := New(fake).Run(context.Background(), Request{OutgoingChanID: 1, IncomingChanID: 2, AmountSat: 1000, MaxFeePPM: 100})
+  if err == nil || !strings.Contains(err.Error(), "no_route") {
+    t.Fatalf("err = %v, want the payment error", err)
+  }
+}
@@ -0,0 +1,87 @@
+package reports
+
+import "time"
+
+const (
+  AnomalyRevenueDrop = "revenue_drop"
+  AnomalyRebalanceSpike = "rebalance_spike"
+)
+
+// AnomalyThresholds control when a stored day is flagged against the average
+// of the days before it. Percentages are whole numbers (50 means 50%).
+type AnomalyThresholds struct {
+  TrailingDays int
+  MinTrailingDays int
+  RevenueDropPct int
+  RebalanceSpikePct int
+  // MinBaselineSat ignores days whose baseline is too small to be meaningful.
+  MinBaselineSat int64
+}
+
+func DefaultAnomalyThresholds() AnomalyThresholds {
+  return AnomalyThresholds{
+    TrailingDays: 7,
+    MinTrailingDays: 3,
+    RevenueDropPct: 50,
+    RebalanceSpikePct: 200,
+    MinBaselineSat: 10,
+  }
+}
+
+type Anomaly struct {
+  Kind string
+  Date time.Time
+  CurrentMsat int64
+  BaselineMsat int64
+  ChangePct int64
+}
+
+// DetectAnomalies compares a day against the trailing days that precede it.
+func DetectAnomalies(current Row, trailing []Row, thresholds AnomalyThresholds) []Anomaly {
+  if len(trailing) < thresholds.MinTrailingDays || len(trailing) == 0 {
+    return nil
+  }
+  var revenueMsat, rebalanceMsat int64
+  for _, row := range trailing {
+    revenueMsat += row.Metrics.ForwardFeeRevenueMsat
+    rebalanceMsat += row.Metrics.RebalanceFeeCostMsat
+  }
+  days := int64(len(trailing))
+  baselineRevenue := revenueMsat / days
+  baselineRebalance := rebalanceMsat / days
+  minBaselineMsat := thresholds.MinBaselineSat * 1000
+
+  var items []Anomaly
+  if thresholds.RevenueDropPct > 0 && baselineRevenue >= minBaselineMsat && baselineRevenue > 0 {
+    change := percentChange(current.Metrics.ForwardFeeRevenueMsat, baselineRevenue)
+    if -change >= int64(thresholds.RevenueDropPct) {
+      items = append(items, Anomaly{
+        Kind: AnomalyRevenueDrop,
+        Date: current.ReportDate,
+        CurrentMsat: current.Metrics.ForwardFeeRevenueMsat,
+        BaselineMsat: baselineRevenue,
+        ChangePct: change,
+      })
+    }
+  }
+  if thresholds.RebalanceSpikePct > 0 && baselineRebalance >= minBaselineMsat && baselineRebalance > 0 {
+    change := percentChange(current.Metrics.RebalanceFeeCostMsat, baselineRebalance)
+    if change >= int64(thresholds.RebalanceSpikePct) {
+      items = append(items, Anomaly{
+        Kind: AnomalyRebalanceSpike,
+        Date: current.ReportDate,
+        CurrentMsat: current.Metrics.RebalanceFeeCostMsat,
+        BaselineMsat: baselineRebalance,
+        ChangePct: change,
+      })
+    }
+  }
+  return items
+}
+
+func percentChange(current int64, baseline int64) int64 {
+  if baseline == 0 {
+    return 0
+  }
+  return (current - baseline) * 100 / baseline
+}
@@ -0,0 +1,41 @@
+package reports
+
+import (
+  "testing"
+  "time"
+)
+
+func anomalyRow(day int, revenueMsat int64, rebalanceMsat int64) Row {
+  return Row{
+    ReportDate: time.Date(2026, 1, day, 0, 0, 0, 0, time.UTC),
+    Metrics: Metrics{ForwardFeeRevenueMsat: revenueMsat, RebalanceFeeCostMsat: rebalanceMsat},
+  }
+}
+
+func TestDetectAnomalies(t *testing.T) {
+  trailing := []Row{
+    anomalyRow(1, 100000, 20000),
+    anomalyRow(2, 100000, 20000),
+    anomalyRow(3, 100000, 20000),
+  }
+  thresholds := DefaultAnomalyThresholds()
+
+  if items := DetectAnomalies(anomalyRow(4, 90000, 25000), trailing, thresholds); len(items) != 0 {
+    t.Fatalf("expected no anomalies, got %+v", items)
+  }
+
+  items := DetectAnomalies(anomalyRow(4, 30000, 70000), trailing, thresholds)
+  if len(items) != 2 {
+    t.Fatalf("expected 2 anomalies, got %+v", items)
+  }
+  if items[0].Kind != AnomalyRevenueDrop || items[0].ChangePct != -70 || items[0].BaselineMsat != 100000 {
+    t.Fatalf("unexpected revenue anomaly: %+v", items[0])
+  }
+  if items[1].Kind != AnomalyRebalanceSpike || items[1].ChangePct != 250 {
+    t.Fatalf("unexpected rebalance anomaly: %+v", items[1])
+  }
+
+  if items := DetectAnomalies(anomalyRow(4, 0, 0), trailing[:2], thresholds); len(items) != 0 {
+    t.Fatalf("expected no anomalies without enough history, got %+v", items)
+  }
+}
@@ -0,0 +1,250 @@
+package reports
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sync"
+  "time"
+
+  "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BackfillRangeKey identifies a backfill run so an interrupted run over the
+// same range can resume where it stopped.
+func BackfillRangeKey(startDate, endDate time.Time) string {
+  return startDate.Format("2006-01-02") + ".." + endDate.Format("2006-01-02")
+}
+
+// LoadBackfillProgress returns the last day of the range that completed along
+// with every day before it.
+func LoadBackfillProgress(ctx context.Context, db *pgxpool.Pool, rangeKey string, loc *time.Location) (time.Time, bool, error) {
+  if db == nil {
+    return time.Time{}, false, nil
+  }
+  var last time.Time
+  err := db.QueryRow(ctx, `select last_completed from reports_backfill_state where range_key=$1`, rangeKey).Scan(&last)
+  if errors.Is(err, pgx.ErrNoRows) {
+    return time.Time{}, false, nil
+  }
+  if err != nil {
+    return time.Time{}, false, err
+  }
+  return time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, loc), true, nil
+}
+
+func SaveBackfillProgress(ctx context.Context, db *pgxpool.Pool, rangeKey string, lastCompleted time.Time) error {
+  if db == nil {
+    return nil
+  }
+  _, err := db.Exec(ctx, `
+insert into reports_backfill_state (range_key, last_completed, updated_at)
+values ($1, $2, now())
+on conflict (range_key) do update set last_completed = excluded.last_completed, updated_at = now()
+`, rangeKey, normalizeReportDate(lastCompleted))
+  return err
+}
+
+func ClearBackfillProgress(ctx context.Context, db *pgxpool.Pool, rangeKey string) error {
+  if db == nil {
+    return nil
+  }
+  _, err := db.Exec(ctx, `delete from reports_backfill_state where range_key=$1`, rangeKey)
+  return err
+}
+
+// StoredDays returns the days in the range that already have a stored report,
+// keyed by YYYY-MM-DD.
+func StoredDays(ctx context.Context, db *pgxpool.Pool, startDate, endDate time.Time) (map[string]bool, error) {
+  rows, err := FetchRange(ctx, db, startDate, endDate)
+  if err != nil {
+    return nil, err
+  }
+  stored := make(map[string]bool, len(rows))
+  for _, row := range rows {
+    stored[row.ReportDate.Format("2006-01-02")] = true
+  }
+  return stored, nil
+}
+
+// BackfillCursor tracks out-of-order completions from parallel workers and
+// only advances past a day once every earlier day has finished, so the saved
+// progress never skips a day that is still in flight.
+type BackfillCursor struct {
+  next time.Time
+  done map[string]bool
+}
+
+func NewBackfillCursor(first time.Time) *BackfillCursor {
+  return &BackfillCursor{next: first, done: map[string]bool{}}
+}
+
+// Complete marks a day as finished and returns the new last contiguous day,
+// or false when the contiguous prefix did not move.
+func (c *BackfillCursor) Complete(day time.Time) (time.Time, bool) {
+  c.done[day.Format("2006-01-02")] = true
+  var last time.Time
+  advanced := false
+  for c.done[c.next.Format("2006-01-02")] {
+    delete(c.done, c.next.Format("2006-01-02"))
+    last = c.next
+    advanced = true
+    c.next = c.next.AddDate(0, 0, 1)
+  }
+  return last, advanced
+}
+
+// MaxBackfillWorkers caps how many days are computed in parallel.
+const MaxBackfillWorkers = 8
+
+// BackfillOptions describes one backfill run over [Start, End].
+type BackfillOptions struct {
+  Start time.Time
+  End time.Time
+  Loc *time.Location
+  Workers int
+  Restart bool
+  DayTimeout time.Duration
+}
+
+// BackfillProgress is reported after every stored day.
+type BackfillProgress struct {
+  Done int
+  Total int
+  Row Row
+}
+
+// BackfillPending lists the days still to compute, skipping the prefix an
+// earlier run over the same range already completed (unless Restart is set).
+func (s *Service) BackfillPending(ctx context.Context, opts BackfillOptions) ([]time.Time, error) {
+  firstDay := opts.Start
+  if !opts.Restart {
+    last, ok, err := LoadBackfillProgress(ctx, s.db, BackfillRangeKey(opts.Start, opts.End), opts.Loc)
+    if err != nil {
+      return nil, err
+    }
+    if ok {
+      firstDay = last.AddDate(0, 0, 1)
+    }
+  }
+  pending := []time.Time{}
+  for day := firstDay; !day.After(opts.End); day = day.AddDate(0, 0, 1) {
+    pending = append(pending, day)
+  }
+  return pending, nil
+}
+
+// Backfill computes and stores the daily reports of the range, saving
+// progress as it goes so an interrupted run resumes where it stopped. The
+// first failing day stops the run.
+func (s *Service) Backfill(ctx context.Context, opts BackfillOptions, progress func(BackfillProgress)) error {
+  rangeKey := BackfillRangeKey(opts.Start, opts.End)
+  if opts.Restart {
+    if err := ClearBackfillProgress(ctx, s.db, rangeKey); err != nil {
+      return err
+    }
+  }
+  pending, err := s.BackfillPending(ctx, opts)
+  if err != nil {
+    return err
+  }
+  if len(pending) == 0 {
+    s.logger.Printf("reports: backfill %s already completed", rangeKey)
+    return nil
+  }
+  firstDay := pending[0]
+  if !firstDay.Equal(opts.Start) {
+    s.logger.Printf("reports: resuming backfill after %s", firstDay.AddDate(0, 0, -1).Format("2006-01-02"))
+  }
+
+  workerCount := opts.Workers
+  if workerCount < 1 {
+    workerCount = 1
+  }
+  if workerCount > MaxBackfillWorkers {
+    workerCount = MaxBackfillWorkers
+  }
+  dayTimeout := opts.DayTimeout
+  if dayTimeout <= 0 {
+    dayTimeout = 2 * time.Minute
+  }
+  s.logger.Printf("reports: backfill %s -> %s (%d days, %d workers)", firstDay.Format("2006-01-02"), opts.End.Format("2006-01-02"), len(pending), workerCount)
+
+  loc := opts.Loc
+  startLocal := time.Date(firstDay.Year(), firstDay.Month(), firstDay.Day(), 0, 0, 0, 0, loc)
+  endLocal := time.Date(opts.End.Year(), opts.End.Month(), opts.End.Day(), 23, 59, 59, 0, loc)
+  rebalanceByDay, err := FetchRebalanceFeesByDay(ctx, s.lnd, uint64(startLocal.UTC().Unix()), uint64(endLocal.UTC().Unix()), loc)
+  if err != nil {
+    return err
+  }
+
+  runCtx, cancel := context.WithCancel(ctx)
+  defer cancel()
+
+  type dayResult struct {
+    day time.Time
+    row Row
+    err error
+  }
+  days := make(chan time.Time)
+  results := make(chan dayResult)
+  var wg sync.WaitGroup
+  for i := 0; i < workerCount; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for day := range days {
+        dayCtx, dayCancel := context.WithTimeout(runCtx, dayTimeout)
+        dayKey := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+        override := rebalanceByDay[dayKey]
+        row, err := s.RunDaily(dayCtx, day, loc, &override)
+        dayCancel()
+        results <- dayResult{day: day, row: row, err: err}
+      }
+    }()
+  }
+  go func() {
+    defer close(days)
+    for _, day := range pending {
+      select {
+      case <-runCtx.Done():
+        return
+      case days <- day:
+      }
+    }
+  }()
+  go func() {
+    wg.Wait()
+    close(results)
+  }()
+
+  cursor := NewBackfillCursor(firstDay)
+  completed := 0
+  var firstErr error
+  for result := range results {
+    if firstErr != nil {
+      continue
+    }
+    if result.err != nil {
+      firstErr = fmt.Errorf("%s: %w", result.day.Format("2006-01-02"), result.err)
+      cancel()
+      continue
+    }
+    completed++
+    if progress != nil {
+      progress(BackfillProgress{Done: completed, Total: len(pending), Row: result.row})
+    }
+    if last, ok := cursor.Complete(result.day); ok {
+      saveCtx, saveCancel := context.WithTimeout(context.Background(), 10*time.Second)
+      if err := SaveBackfillProgress(saveCtx, s.db, rangeKey, last); err != nil {
+        s.logger.Printf("reports: failed to save backfill progress: %v", err)
+      }
+      saveCancel()
+    }
+  }
+  if firstErr != nil {
+    return firstErr
+  }
+  return ctx.Err()
+}
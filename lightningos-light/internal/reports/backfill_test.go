@@ -0,0 +1,23 @@
+package reports
+
+import (
+  "testing"
+  "time"
+)
+
+func TestBackfillCursor(t *testing.T) {
+  first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+  cursor := NewBackfillCursor(first)
+
+  if _, ok := cursor.Complete(first.AddDate(0, 0, 1)); ok {
+    t.Fatalf("cursor advanced past an unfinished day")
+  }
+  last, ok := cursor.Complete(first)
+  if !ok || !sameDate(last, first.AddDate(0, 0, 1)) {
+    t.Fatalf("expected cursor at jan 2, got %v (%v)", last, ok)
+  }
+  last, ok = cursor.Complete(first.AddDate(0, 0, 2))
+  if !ok || !sameDate(last, first.AddDate(0, 0, 2)) {
+    t.Fatalf("expected cursor at jan 3, got %v (%v)", last, ok)
+  }
+}
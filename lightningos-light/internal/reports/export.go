@@ -0,0 +1,111 @@
+package reports
+
+import (
+  "encoding/csv"
+  "encoding/json"
+  "io"
+  "strconv"
+)
+
+// ExportRecord is the flat, stable representation of a stored day used by
+// reports-export. Field names mirror the reports_daily columns.
+type ExportRecord struct {
+  Date string `json:"date"`
+  ForwardFeeRevenueSat int64 `json:"forward_fee_revenue_sats"`
+  ForwardFeeRevenueMsat int64 `json:"forward_fee_revenue_msat"`
+  RebalanceFeeCostSat int64 `json:"rebalance_fee_cost_sats"`
+  RebalanceFeeCostMsat int64 `json:"rebalance_fee_cost_msat"`
+  NetRoutingProfitSat int64 `json:"net_routing_profit_sats"`
+  NetRoutingProfitMsat int64 `json:"net_routing_profit_msat"`
+  ForwardCount int64 `json:"forward_count"`
+  RebalanceCount int64 `json:"rebalance_count"`
+  RoutedVolumeSat int64 `json:"routed_volume_sats"`
+  RoutedVolumeMsat int64 `json:"routed_volume_msat"`
+  OnchainBalanceSat *int64 `json:"onchain_balance_sats"`
+  LightningBalanceSat *int64 `json:"lightning_balance_sats"`
+  TotalBalanceSat *int64 `json:"total_balance_sats"`
+}
+
+var exportCSVHeader = []string{
+  "date",
+  "forward_fee_revenue_sats",
+  "forward_fee_revenue_msat",
+  "rebalance_fee_cost_sats",
+  "rebalance_fee_cost_msat",
+  "net_routing_profit_sats",
+  "net_routing_profit_msat",
+  "forward_count",
+  "rebalance_count",
+  "routed_volume_sats",
+  "routed_volume_msat",
+  "onchain_balance_sats",
+  "lightning_balance_sats",
+  "total_balance_sats",
+}
+
+func NewExportRecord(row Row) ExportRecord {
+  m := row.Metrics
+  return ExportRecord{
+    Date: row.ReportDate.Format("2006-01-02"),
+    ForwardFeeRevenueSat: m.ForwardFeeRevenueSat,
+    ForwardFeeRevenueMsat: m.ForwardFeeRevenueMsat,
+    RebalanceFeeCostSat: m.RebalanceFeeCostSat,
+    RebalanceFeeCostMsat: m.RebalanceFeeCostMsat,
+    NetRoutingProfitSat: m.NetRoutingProfitSat,
+    NetRoutingProfitMsat: m.NetRoutingProfitMsat,
+    ForwardCount: m.ForwardCount,
+    RebalanceCount: m.RebalanceCount,
+    RoutedVolumeSat: m.RoutedVolumeSat,
+    RoutedVolumeMsat: m.RoutedVolumeMsat,
+    OnchainBalanceSat: m.OnchainBalanceSat,
+    LightningBalanceSat: m.LightningBalanceSat,
+    TotalBalanceSat: m.TotalBalanceSat,
+  }
+}
+
+func WriteJSON(w io.Writer, rows []Row) error {
+  records := make([]ExportRecord, 0, len(rows))
+  for _, row := range rows {
+    records = append(records, NewExportRecord(row))
+  }
+  enc := json.NewEncoder(w)
+  enc.SetIndent("", "  ")
+  return enc.Encode(records)
+}
+
+func WriteCSV(w io.Writer, rows []Row) error {
+  writer := csv.NewWriter(w)
+  if err := writer.Write(exportCSVHeader); err != nil {
+    return err
+  }
+  for _, row := range rows {
+    rec := NewExportRecord(row)
+    if err := writer.Write([]string{
+      rec.Date,
+      strconv.FormatInt(rec.ForwardFeeRevenueSat, 10),
+      strconv.FormatInt(rec.ForwardFeeRevenueMsat, 10),
+      strconv.FormatInt(rec.RebalanceFeeCostSat, 10),
+      strconv.FormatInt(rec.RebalanceFeeCostMsat, 10),
+      strconv.FormatInt(rec.NetRoutingProfitSat, 10),
+      strconv.FormatInt(rec.NetRoutingProfitMsat, 10),
+      strconv.FormatInt(rec.ForwardCount, 10),
+      strconv.FormatInt(rec.RebalanceCount, 10),
+      strconv.FormatInt(rec.RoutedVolumeSat, 10),
+      strconv.FormatInt(rec.RoutedVolumeMsat, 10),
+      csvOptionalInt(rec.OnchainBalanceSat),
+      csvOptionalInt(rec.LightningBalanceSat),
+      csvOptionalInt(rec.TotalBalanceSat),
+    }); err != nil {
+      return err
+    }
+  }
+  writer.Flush()
+  return writer.Error()
+}
+
+func csvOptionalInt(value *int64) string {
+  if value == nil {
+    return ""
+  }
+  return strconv.FormatInt(*value, 10)
+}
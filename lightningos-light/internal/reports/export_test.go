@@ -0,0 +1,43 @@
+package reports
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestWriteCSV(t *testing.T) {
+  balance := int64(1500)
+  rows := []Row{{
+    ReportDate: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+    Metrics: Metrics{ForwardFeeRevenueSat: 12, ForwardFeeRevenueMsat: 12345, ForwardCount: 3, TotalBalanceSat: &balance},
+  }}
+  var buf bytes.Buffer
+  if err := WriteCSV(&buf, rows); err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+  if len(lines) != 2 {
+    t.Fatalf("expected header and one row, got %d lines", len(lines))
+  }
+  want := "2026-01-15,12,12345,0,0,0,0,3,0,0,0,,,1500"
+  if lines[1] != want {
+    t.Fatalf("unexpected row: %q", lines[1])
+  }
+}
+
+func TestDiffMetrics(t *testing.T) {
+  stored := Metrics{ForwardCount: 10, ForwardFeeRevenueMsat: 5000, RoutedVolumeMsat: 900000}
+  computed := Metrics{ForwardCount: 12, ForwardFeeRevenueMsat: 5000, RoutedVolumeMsat: 950000}
+  diffs := DiffMetrics(stored, computed)
+  if len(diffs) != 2 {
+    t.Fatalf("expected 2 diffs, got %+v", diffs)
+  }
+  if diffs[0].Field != "forward_count" || diffs[0].Stored != 10 || diffs[0].Computed != 12 {
+    t.Fatalf("unexpected diff: %+v", diffs[0])
+  }
+  if len(DiffMetrics(stored, stored)) != 0 {
+    t.Fatalf("expected no diffs for identical metrics")
+  }
+}
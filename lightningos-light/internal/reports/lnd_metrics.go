@@ -3,6 +3,7 @@ package reports
 import (
   "context"
   "fmt"
+  "sort"
   "strings"
 
   "lightningos-light/internal/lndclient"
@@ -17,13 +18,14 @@ const (
 type RebalanceOverride struct {
   FeeMsat int64
   Count int64
+  Pairs []RebalancePair
 }
 
-func ComputeMetrics(ctx context.Context, lnd *lndclient.Client, tr TimeRange, memoMatch bool, override *RebalanceOverride) (Metrics, error) {
+func ComputeMetrics(ctx context.Context, lnd lndclient.API, tr TimeRange, memoMatch bool, override *RebalanceOverride) (Metrics, error) {
   if lnd == nil {
     return Metrics{}, fmt.Errorf("lnd client unavailable")
   }
-  forwardRevenueMsat, forwardCount, routedVolumeMsat, err := fetchForwardingMetrics(ctx, lnd, tr.StartUnix(), tr.EndUnixInclusive())
+  forwardRevenueMsat, forwardCount, routedVolumeMsat, channelForwards, err := fetchForwardingMetrics(ctx, lnd, tr.StartUnix(), tr.EndUnixInclusive())
   if err != nil {
     return Metrics{}, err
   }
@@ -35,11 +37,13 @@ func ComputeMetrics(ctx context.Context, lnd *lndclient.Client, tr TimeRange, me
 
   rebalanceCostMsat := int64(0)
   rebalanceCount := int64(0)
+  var rebalancePairs []RebalancePair
   if override != nil {
     rebalanceCostMsat = override.FeeMsat
     rebalanceCount = override.Count
+    rebalancePairs = override.Pairs
   } else {
-    rebalanceCostMsat, rebalanceCount, err = fetchRebalanceMetrics(ctx, lnd, tr.StartUnix(), tr.EndUnixInclusive(), pubkey, memoMatch)
+    rebalanceCostMsat, rebalanceCount, rebalancePairs, err = fetchRebalanceMetrics(ctx, lnd, tr.StartUnix(), tr.EndUnixInclusive(), pubkey, memoMatch)
     if err != nil {
       return Metrics{}, err
     }
@@ -57,14 +61,16 @@ func ComputeMetrics(ctx context.Context, lnd *lndclient.Client, tr TimeRange, me
     RebalanceCount: rebalanceCount,
     RoutedVolumeSat: routedVolumeMsat / 1000,
     RoutedVolumeMsat: routedVolumeMsat,
+    RebalancePairs: rebalancePairs,
+    ChannelForwards: channelForwards,
   }
   return metrics, nil
 }
 
-func fetchForwardingMetrics(ctx context.Context, lnd *lndclient.Client, startUnix uint64, endUnix uint64) (int64, int64, int64, error) {
+func fetchForwardingMetrics(ctx context.Context, lnd lndclient.API, startUnix uint64, endUnix uint64) (int64, int64, int64, []ChannelForward, error) {
   conn, err := lnd.DialLightning(ctx)
   if err != nil {
-    return 0, 0, 0, err
+    return 0, 0, 0, nil, err
   }
   defer conn.Close()
 
@@ -74,6 +80,7 @@ func fetchForwardingMetrics(ctx context.Context, lnd *lndclient.Client, startUni
   var revenueMsat int64
   var routedVolumeMsat int64
   var count int64
+  byChannel := map[uint64]ChannelForward{}
 
   for {
     resp, err := client.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{
@@ -83,7 +90,7 @@ func fetchForwardingMetrics(ctx context.Context, lnd *lndclient.Client, startUni
       NumMaxEvents: forwardingPageSize,
     })
     if err != nil {
-      return 0, 0, 0, err
+      return 0, 0, 0, nil, err
     }
     if resp == nil || len(resp.ForwardingEvents) == 0 {
       break
@@ -93,9 +100,17 @@ func fetchForwardingMetrics(ctx context.Context, lnd *lndclient.Client, startUni
       if evt == nil {
         continue
       }
-      revenueMsat += extractForwardFeeMsat(evt)
-      routedVolumeMsat += extractForwardAmountMsat(evt)
+      feeMsat := extractForwardFeeMsat(evt)
+      amountMsat := extractForwardAmountMsat(evt)
+      revenueMsat += feeMsat
+      routedVolumeMsat += amountMsat
       count++
+      channel := byChannel[evt.ChanIdOut]
+      channel.ChanID = evt.ChanIdOut
+      channel.Count++
+      channel.AmountMsat += amountMsat
+      channel.FeeMsat += feeMsat
+      byChannel[evt.ChanIdOut] = channel
     }
 
     if resp.LastOffsetIndex <= offset {
@@ -107,13 +122,18 @@ func fetchForwardingMetrics(ctx context.Context, lnd *lndclient.Client, startUni
     }
   }
 
-  return revenueMsat, count, routedVolumeMsat, nil
+  channels := make([]ChannelForward, 0, len(byChannel))
+  for _, channel := range byChannel {
+    channels = append(channels, channel)
+  }
+  sort.Slice(channels, func(i, j int) bool { return channels[i].ChanID < channels[j].ChanID })
+  return revenueMsat, count, routedVolumeMsat, channels, nil
 }
 
-func fetchRebalanceMetrics(ctx context.Context, lnd *lndclient.Client, startUnix uint64, endUnix uint64, ourPubkey string, memoMatch bool) (int64, int64, error) {
+func fetchRebalanceMetrics(ctx context.Context, lnd lndclient.API, startUnix uint64, endUnix uint64, ourPubkey string, memoMatch bool) (int64, int64, []RebalancePair, error) {
   conn, err := lnd.DialLightning(ctx)
   if err != nil {
-    return 0, 0, err
+    return 0, 0, nil, err
   }
   defer conn.Close()
 
@@ -123,6 +143,7 @@ func fetchRebalanceMetrics(ctx context.Context, lnd *lndclient.Client, startUnix
   var offset uint64
   var totalFeeMsat int64
   var rebalanceCount int64
+  pairs := rebalancePairSet{}
 
   for {
     req := &lnrpc.ListPaymentsRequest{
@@ -135,7 +156,7 @@ func fetchRebalanceMetrics(ctx context.Context, lnd *lndclient.Client, startUnix
 
     resp, err := client.ListPayments(ctx, req)
     if err != nil {
-      return 0, 0, err
+      return 0, 0, nil, err
     }
     if resp == nil || len(resp.Payments) == 0 {
       break
@@ -158,6 +179,7 @@ func fetchRebalanceMetrics(ctx context.Context, lnd *lndclient.Client, startUnix
         feeMsat := extractPaymentFeeMsat(pay)
         totalFeeMsat += feeMsat
         rebalanceCount++
+        pairs.add(pay, feeMsat)
       }
     }
 
@@ -171,10 +193,10 @@ func fetchRebalanceMetrics(ctx context.Context, lnd *lndclient.Client, startUnix
     }
   }
 
-  return totalFeeMsat, rebalanceCount, nil
+  return totalFeeMsat, rebalanceCount, pairs.top(rebalancePairsTopN), nil
 }
 
-func fetchNodePubkey(ctx context.Context, lnd *lndclient.Client) (string, error) {
+func fetchNodePubkey(ctx context.Context, lnd lndclient.API) (string, error) {
   cached := strings.TrimSpace(lnd.CachedPubkey())
   if cached != "" {
     return cached, nil
@@ -195,7 +217,7 @@ type decodedPayReq struct {
   Ready bool
 }
 
-func extractDestinationAndDescription(ctx context.Context, lnd *lndclient.Client, pay *lnrpc.Payment, cache map[string]decodedPayReq) (string, string) {
+func extractDestinationAndDescription(ctx context.Context, lnd lndclient.API, pay *lnrpc.Payment, cache map[string]decodedPayReq) (string, string) {
   if pay == nil {
     return "", ""
   }
@@ -0,0 +1,202 @@
+package reports
+
+import (
+  "context"
+  "sort"
+  "time"
+
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LNDg keeps its own history in its Postgres: the rebalances its
+// rebalancer completed and a copy of LND's forwards. The importer stores
+// them per local day next to reports_daily so the reports can say which
+// part of the rebalance cost LNDg's automation spent.
+//
+// The native rows stay the source of truth for forwards: LNDg's forwards
+// are the same LND events. LNDg's rebalances are circular payments the
+// native detector counts as well, so a day's consolidated rebalance cost is
+// the larger of the two figures rather than their sum.
+
+// LNDgBucket is one short slice of LNDg history, summed.
+type LNDgBucket struct {
+  At time.Time
+  Count int64
+  FeeMsat int64
+  AmountMsat int64
+}
+
+// LNDgDay is what LNDg recorded for one local day.
+type LNDgDay struct {
+  ReportDate time.Time
+  RebalanceCount int64
+  RebalanceFeeMsat int64
+  RebalanceAmountMsat int64
+  ForwardCount int64
+  ForwardFeeMsat int64
+}
+
+// ConsolidatedRow is a native day with LNDg's share of it. Metrics hold the
+// consolidated figures; LNDg is nil for days without LNDg history.
+type ConsolidatedRow struct {
+  ReportDate time.Time
+  Metrics Metrics
+  LNDg *LNDgDay
+}
+
+const lndgSchema = `
+create table if not exists reports_daily_lndg (
+  report_date date primary key,
+  rebalance_count integer not null default 0,
+  rebalance_fee_msat bigint not null default 0,
+  rebalance_amount_msat bigint not null default 0,
+  forward_count integer not null default 0,
+  forward_fee_msat bigint not null default 0,
+  imported_at timestamptz not null default now()
+);
+`
+
+// GroupLNDgDays sums the rebalance and forward buckets into local days,
+// oldest first.
+func GroupLNDgDays(rebalances []LNDgBucket, forwards []LNDgBucket, loc *time.Location) []LNDgDay {
+  days := map[time.Time]*LNDgDay{}
+  day := func(at time.Time) *LNDgDay {
+    key := dateOnly(at, loc)
+    item, ok := days[key]
+    if !ok {
+      item = &LNDgDay{ReportDate: key}
+      days[key] = item
+    }
+    return item
+  }
+  for _, bucket := range rebalances {
+    item := day(bucket.At)
+    item.RebalanceCount += bucket.Count
+    item.RebalanceFeeMsat += bucket.FeeMsat
+    item.RebalanceAmountMsat += bucket.AmountMsat
+  }
+  for _, bucket := range forwards {
+    item := day(bucket.At)
+    item.ForwardCount += bucket.Count
+    item.ForwardFeeMsat += bucket.FeeMsat
+  }
+  items := make([]LNDgDay, 0, len(days))
+  for _, item := range days {
+    items = append(items, *item)
+  }
+  sort.Slice(items, func(i, j int) bool { return items[i].ReportDate.Before(items[j].ReportDate) })
+  return items
+}
+
+// Consolidate applies a day of LNDg history to the native row.
+func Consolidate(row Row, lndg *LNDgDay) ConsolidatedRow {
+  out := ConsolidatedRow{ReportDate: row.ReportDate, Metrics: row.Metrics, LNDg: lndg}
+  if lndg == nil {
+    return out
+  }
+  metrics := &out.Metrics
+  fillMsatFromSat(metrics)
+  if lndg.RebalanceFeeMsat > metrics.RebalanceFeeCostMsat {
+    metrics.RebalanceFeeCostMsat = lndg.RebalanceFeeMsat
+    metrics.RebalanceFeeCostSat = lndg.RebalanceFeeMsat / 1000
+  }
+  if lndg.RebalanceCount > metrics.RebalanceCount {
+    metrics.RebalanceCount = lndg.RebalanceCount
+  }
+  metrics.NetRoutingProfitMsat = metrics.ForwardFeeRevenueMsat - metrics.RebalanceFeeCostMsat
+  metrics.NetRoutingProfitSat = metrics.NetRoutingProfitMsat / 1000
+  return out
+}
+
+func UpsertLNDgDays(ctx context.Context, db *pgxpool.Pool, days []LNDgDay) error {
+  if db == nil || len(days) == 0 {
+    return nil
+  }
+  tx, err := db.Begin(ctx)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback(ctx)
+  for _, day := range days {
+    if _, err := tx.Exec(ctx, `
+insert into reports_daily_lndg (report_date, rebalance_count, rebalance_fee_msat, rebalance_amount_msat, forward_count, forward_fee_msat)
+values ($1, $2, $3, $4, $5, $6)
+on conflict (report_date) do update set
+  rebalance_count = excluded.rebalance_count,
+  rebalance_fee_msat = excluded.rebalance_fee_msat,
+  rebalance_amount_msat = excluded.rebalance_amount_msat,
+  forward_count = excluded.forward_count,
+  forward_fee_msat = excluded.forward_fee_msat,
+  imported_at = now()
+`, normalizeReportDate(day.ReportDate), day.RebalanceCount, day.RebalanceFeeMsat, day.RebalanceAmountMsat, day.ForwardCount, day.ForwardFeeMsat); err != nil {
+      return err
+    }
+  }
+  return tx.Commit(ctx)
+}
+
+func FetchLNDgRange(ctx context.Context, db *pgxpool.Pool, startDate, endDate time.Time) ([]LNDgDay, error) {
+  if db == nil {
+    return []LNDgDay{}, nil
+  }
+  rows, err := db.Query(ctx, `
+select report_date, rebalance_count, rebalance_fee_msat, rebalance_amount_msat, forward_count, forward_fee_msat
+from reports_daily_lndg
+where report_date >= $1 and report_date <= $2
+order by report_date asc
+`, normalizeReportDate(startDate), normalizeReportDate(endDate))
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  items := []LNDgDay{}
+  for rows.Next() {
+    var day LNDgDay
+    if err := rows.Scan(&day.ReportDate, &day.RebalanceCount, &day.RebalanceFeeMsat, &day.RebalanceAmountMsat, &day.ForwardCount, &day.ForwardFeeMsat); err != nil {
+      return nil, err
+    }
+    items = append(items, day)
+  }
+  return items, rows.Err()
+}
+
+// LNDgImportedAt returns when LNDg history was last imported, if ever.
+func LNDgImportedAt(ctx context.Context, db *pgxpool.Pool) (*time.Time, error) {
+  if db == nil {
+    return nil, nil
+  }
+  var at *time.Time
+  err := db.QueryRow(ctx, `select max(imported_at) from reports_daily_lndg`).Scan(&at)
+  return at, err
+}
+
+// Consolidated loads the range with LNDg's history applied.
+func (s *Service) Consolidated(ctx context.Context, key string, now time.Time, loc *time.Location) ([]ConsolidatedRow, DateRange, error) {
+  rows, dr, err := s.Range(ctx, key, now, loc)
+  if err != nil || len(rows) == 0 {
+    return []ConsolidatedRow{}, dr, err
+  }
+  lndg, err := FetchLNDgRange(ctx, s.db, rows[0].ReportDate, rows[len(rows)-1].ReportDate)
+  if err != nil {
+    return nil, dr, err
+  }
+  byDate := map[string]*LNDgDay{}
+  for i := range lndg {
+    byDate[lndg[i].ReportDate.Format("2006-01-02")] = &lndg[i]
+  }
+  items := make([]ConsolidatedRow, 0, len(rows))
+  for _, row := range rows {
+    items = append(items, Consolidate(row, byDate[row.ReportDate.Format("2006-01-02")]))
+  }
+  return items, dr, nil
+}
+
+// ImportLNDg stores days of LNDg history.
+func (s *Service) ImportLNDg(ctx context.Context, days []LNDgDay) error {
+  return UpsertLNDgDays(ctx, s.db, days)
+}
+
+// LNDgImportedAt returns when LNDg history was last imported, if ever.
+func (s *Service) LNDgImportedAt(ctx context.Context) (*time.Time, error) {
+  return LNDgImportedAt(ctx, s.db)
+}
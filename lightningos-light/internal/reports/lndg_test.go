@@ -0,0 +1,54 @@
+package reports
+
+import (
+  "testing"
+  "time"
+)
+
+func TestGroupLNDgDaysUsesLocalDays(t *testing.T) {
+  loc := time.FixedZone("BRT", -3*3600)
+  rebalances := []LNDgBucket{
+    {At: time.Date(2026, 5, 2, 1, 0, 0, 0, time.UTC), Count: 2, FeeMsat: 3000, AmountMsat: 1_000_000},
+    {At: time.Date(2026, 5, 2, 4, 0, 0, 0, time.UTC), Count: 1, FeeMsat: 500, AmountMsat: 200_000},
+  }
+  forwards := []LNDgBucket{
+    {At: time.Date(2026, 5, 2, 2, 45, 0, 0, time.UTC), Count: 5, FeeMsat: 7000},
+  }
+  days := GroupLNDgDays(rebalances, forwards, loc)
+  if len(days) != 2 {
+    t.Fatalf("expected 2 local days, got %d", len(days))
+  }
+  first, second := days[0], days[1]
+  if first.ReportDate.Day() != 1 || first.RebalanceCount != 2 || first.RebalanceFeeMsat != 3000 || first.ForwardCount != 5 {
+    t.Fatalf("first day: %+v", first)
+  }
+  if second.ReportDate.Day() != 2 || second.RebalanceCount != 1 || second.ForwardCount != 0 {
+    t.Fatalf("second day: %+v", second)
+  }
+}
+
+func TestConsolidateTakesLargerRebalanceCost(t *testing.T) {
+  row := Row{Metrics: Metrics{
+    ForwardFeeRevenueMsat: 50_000,
+    RebalanceFeeCostMsat: 10_000,
+    NetRoutingProfitMsat: 40_000,
+    RebalanceCount: 1,
+  }}
+
+  covered := Consolidate(row, &LNDgDay{RebalanceCount: 1, RebalanceFeeMsat: 8_000, ForwardCount: 3})
+  if covered.Metrics.RebalanceFeeCostMsat != 10_000 || covered.Metrics.NetRoutingProfitMsat != 40_000 {
+    t.Fatalf("native already covers LNDg, got %+v", covered.Metrics)
+  }
+
+  missed := Consolidate(row, &LNDgDay{RebalanceCount: 3, RebalanceFeeMsat: 25_000})
+  if missed.Metrics.RebalanceFeeCostMsat != 25_000 || missed.Metrics.RebalanceCount != 3 || missed.Metrics.NetRoutingProfitMsat != 25_000 || missed.Metrics.NetRoutingProfitSat != 25 {
+    t.Fatalf("expected LNDg's cost, got %+v", missed.Metrics)
+  }
+  if row.Metrics.RebalanceFeeCostMsat != 10_000 {
+    t.Fatalf("native row changed")
+  }
+
+  if plain := Consolidate(row, nil); plain.LNDg != nil || plain.Metrics.RebalanceFeeCostMsat != 10_000 {
+    t.Fatalf("no LNDg history: %+v", plain)
+  }
+}
@@ -0,0 +1,115 @@
+package reports
+
+import (
+  "context"
+  "sort"
+  "time"
+
+  "lightningos-light/lnrpc"
+
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+// rebalancePairsTopN caps how many corridors are stored per day.
+const rebalancePairsTopN = 10
+
+// RebalancePair is the rebalance spend on one liquidity corridor: liquidity
+// leaves through OutChanID and comes back through InChanID.
+type RebalancePair struct {
+  OutChanID uint64
+  InChanID uint64
+  FeeMsat int64
+  AmountMsat int64
+  Count int64
+}
+
+type rebalancePairKey struct {
+  out uint64
+  in uint64
+}
+
+type rebalancePairSet map[rebalancePairKey]RebalancePair
+
+func (set rebalancePairSet) add(pay *lnrpc.Payment, feeMsat int64) {
+  endpoints := ExtractRouteEndpoints(pay)
+  if endpoints.FirstChanID == 0 || endpoints.LastChanID == 0 {
+    return
+  }
+  key := rebalancePairKey{out: endpoints.FirstChanID, in: endpoints.LastChanID}
+  pair := set[key]
+  pair.OutChanID = key.out
+  pair.InChanID = key.in
+  pair.FeeMsat += feeMsat
+  pair.AmountMsat += pay.ValueMsat
+  pair.Count++
+  set[key] = pair
+}
+
+func (set rebalancePairSet) top(limit int) []RebalancePair {
+  items := make([]RebalancePair, 0, len(set))
+  for _, pair := range set {
+    items = append(items, pair)
+  }
+  return topRebalancePairs(items, limit)
+}
+
+func topRebalancePairs(items []RebalancePair, limit int) []RebalancePair {
+  sort.Slice(items, func(i, j int) bool {
+    if items[i].FeeMsat != items[j].FeeMsat {
+      return items[i].FeeMsat > items[j].FeeMsat
+    }
+    if items[i].OutChanID != items[j].OutChanID {
+      return items[i].OutChanID < items[j].OutChanID
+    }
+    return items[i].InChanID < items[j].InChanID
+  })
+  if limit > 0 && len(items) > limit {
+    items = items[:limit]
+  }
+  return items
+}
+
+// MergeRebalancePairs sums corridors across days and returns the most
+// expensive ones.
+func MergeRebalancePairs(items []RebalancePair, limit int) []RebalancePair {
+  merged := rebalancePairSet{}
+  for _, item := range items {
+    key := rebalancePairKey{out: item.OutChanID, in: item.InChanID}
+    pair := merged[key]
+    pair.OutChanID = key.out
+    pair.InChanID = key.in
+    pair.FeeMsat += item.FeeMsat
+    pair.AmountMsat += item.AmountMsat
+    pair.Count += item.Count
+    merged[key] = pair
+  }
+  return merged.top(limit)
+}
+
+func FetchRebalancePairs(ctx context.Context, db *pgxpool.Pool, startDate, endDate time.Time) ([]RebalancePair, error) {
+  if db == nil {
+    return nil, nil
+  }
+  rows, err := db.Query(ctx, `
+select out_chan_id, in_chan_id, fee_msat, amount_msat, rebalance_count
+from reports_daily_rebalance_pairs
+where report_date >= $1 and report_date <= $2
+`, normalizeReportDate(startDate), normalizeReportDate(endDate))
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  var items []RebalancePair
+  for rows.Next() {
+    var outID, inID int64
+    var pair RebalancePair
+    if err := rows.Scan(&outID, &inID, &pair.FeeMsat, &pair.AmountMsat, &pair.Count); err != nil {
+      return nil, err
+    }
+    pair.OutChanID = uint64(outID)
+    pair.InChanID = uint64(inID)
+    items = append(items, pair)
+  }
+  return items, rows.Err()
+}
@@ -0,0 +1,55 @@
+package reports
+
+import (
+  "testing"
+
+  "lightningos-light/lnrpc"
+)
+
+func rebalancePayment(out uint64, in uint64, valueMsat int64) *lnrpc.Payment {
+  return &lnrpc.Payment{
+    Status: lnrpc.Payment_SUCCEEDED,
+    ValueMsat: valueMsat,
+    Htlcs: []*lnrpc.HTLCAttempt{
+      {
+        Status: lnrpc.HTLCAttempt_SUCCEEDED,
+        Route: &lnrpc.Route{
+          Hops: []*lnrpc.Hop{
+            {ChanId: out, PubKey: "peer"},
+            {ChanId: in, PubKey: "our-node"},
+          },
+        },
+      },
+    },
+  }
+}
+
+func TestRebalancePairSetTop(t *testing.T) {
+  set := rebalancePairSet{}
+  set.add(rebalancePayment(1, 2, 100000), 500)
+  set.add(rebalancePayment(1, 2, 200000), 700)
+  set.add(rebalancePayment(3, 4, 50000), 900)
+  set.add(rebalancePayment(5, 6, 50000), 10)
+
+  top := set.top(2)
+  if len(top) != 2 {
+    t.Fatalf("expected 2 pairs, got %d", len(top))
+  }
+  if top[0].OutChanID != 1 || top[0].InChanID != 2 || top[0].FeeMsat != 1200 || top[0].Count != 2 || top[0].AmountMsat != 300000 {
+    t.Fatalf("unexpected top pair: %+v", top[0])
+  }
+  if top[1].OutChanID != 3 || top[1].FeeMsat != 900 {
+    t.Fatalf("unexpected second pair: %+v", top[1])
+  }
+}
+
+func TestMergeRebalancePairs(t *testing.T) {
+  merged := MergeRebalancePairs([]RebalancePair{
+    {OutChanID: 1, InChanID: 2, FeeMsat: 100, Count: 1},
+    {OutChanID: 3, InChanID: 4, FeeMsat: 150, Count: 1},
+    {OutChanID: 1, InChanID: 2, FeeMsat: 100, Count: 2},
+  }, 0)
+  if len(merged) != 2 || merged[0].OutChanID != 1 || merged[0].FeeMsat != 200 || merged[0].Count != 3 {
+    t.Fatalf("unexpected merge result: %+v", merged)
+  }
+}
@@ -12,7 +12,7 @@ import (
 const rebalanceScanPageSize = 5000
 const rebalanceScanMaxPages = 200000
 
-func FetchRebalanceFeesByDay(ctx context.Context, lnd *lndclient.Client, startUnix uint64, endUnix uint64, loc *time.Location) (map[time.Time]RebalanceOverride, error) {
+func FetchRebalanceFeesByDay(ctx context.Context, lnd lndclient.API, startUnix uint64, endUnix uint64, loc *time.Location) (map[time.Time]RebalanceOverride, error) {
   if lnd == nil {
     return nil, fmt.Errorf("lnd client unavailable")
   }
@@ -33,6 +33,7 @@ func FetchRebalanceFeesByDay(ctx context.Context, lnd *lndclient.Client, startUn
 
   client := lnrpc.NewLightningClient(conn)
   results := make(map[time.Time]RebalanceOverride)
+  pairsByDay := make(map[time.Time]rebalancePairSet)
 
   var indexOffset uint64
   var pages int
@@ -98,6 +99,10 @@ func FetchRebalanceFeesByDay(ctx context.Context, lnd *lndclient.Client, startUn
       current.FeeMsat += feeMsat
       current.Count++
       results[dayKey] = current
+      if pairsByDay[dayKey] == nil {
+        pairsByDay[dayKey] = rebalancePairSet{}
+      }
+      pairsByDay[dayKey].add(pay, feeMsat)
     }
 
     if maxTs < int64(startUnix) {
@@ -122,5 +127,10 @@ func FetchRebalanceFeesByDay(ctx context.Context, lnd *lndclient.Client, startUn
     }
   }
 
+  for dayKey, pairs := range pairsByDay {
+    current := results[dayKey]
+    current.Pairs = pairs.top(rebalancePairsTopN)
+    results[dayKey] = current
+  }
   return results, nil
 }
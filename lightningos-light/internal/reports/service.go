@@ -6,6 +6,7 @@ import (
   "sync"
   "time"
 
+  "lightningos-light/internal/config"
   "lightningos-light/internal/lndclient"
 
   "github.com/jackc/pgx/v5/pgxpool"
@@ -15,8 +16,9 @@ const defaultLiveTTL = 60 * time.Second
 
 type Service struct {
   db *pgxpool.Pool
-  lnd *lndclient.Client
+  lnd lndclient.API
   logger *log.Logger
+  nodeID string
 
   liveTTL time.Duration
   liveMu sync.Mutex
@@ -28,17 +30,25 @@ type liveSnapshot struct {
   Range TimeRange
   Metrics Metrics
   LookbackHours int
+  Location string
 }
 
-func NewService(db *pgxpool.Pool, lnd *lndclient.Client, logger *log.Logger) *Service {
+func NewService(db *pgxpool.Pool, lnd lndclient.API, logger *log.Logger) *Service {
   return &Service{
     db: db,
     lnd: lnd,
     logger: logger,
+    nodeID: config.DefaultNodeID,
     liveTTL: defaultLiveTTL,
   }
 }
 
+// SetNodeID names the node whose LND the service reads; stored per-channel
+// rows carry it.
+func (s *Service) SetNodeID(id string) {
+  s.nodeID = id
+}
+
 func (s *Service) EnsureSchema(ctx context.Context) error {
   return EnsureSchema(ctx, s.db)
 }
@@ -53,7 +63,7 @@ func (s *Service) RunDaily(ctx context.Context, reportDate time.Time, loc *time.
     metrics = s.attachBalances(ctx, metrics)
   }
 
-  row := Row{ReportDate: dateOnly(reportDate, loc), Metrics: metrics}
+  row := Row{ReportDate: dateOnly(reportDate, loc), NodeID: s.nodeID, Metrics: metrics}
   if err := UpsertDaily(ctx, s.db, row); err != nil {
     return Row{}, err
   }
@@ -86,6 +96,23 @@ func (s *Service) Summary(ctx context.Context, key string, now time.Time, loc *t
   return summary, dr, err
 }
 
+// RebalancePairs returns the most expensive rebalance corridors over a range.
+func (s *Service) RebalancePairs(ctx context.Context, key string, now time.Time, loc *time.Location, limit int) ([]RebalancePair, error) {
+  dr, err := ResolveRangeWindow(now, loc, key)
+  if err != nil {
+    return nil, err
+  }
+  if dr.All {
+    dr.StartDate = time.Date(2009, 1, 3, 0, 0, 0, 0, time.UTC)
+    dr.EndDate = dateOnly(now, loc)
+  }
+  items, err := FetchRebalancePairs(ctx, s.db, dr.StartDate, dr.EndDate)
+  if err != nil {
+    return nil, err
+  }
+  return MergeRebalancePairs(items, limit), nil
+}
+
 func (s *Service) CustomRange(ctx context.Context, startDate, endDate time.Time) ([]Row, error) {
   return FetchRange(ctx, s.db, startDate, endDate)
 }
@@ -100,7 +127,7 @@ func (s *Service) Live(ctx context.Context, now time.Time, loc *time.Location, l
   }
   s.liveMu.Lock()
   cached := s.liveCache
-  if time.Now().Before(cached.ExpiresAt) && cached.LookbackHours == lookbackHours {
+  if time.Now().Before(cached.ExpiresAt) && cached.LookbackHours == lookbackHours && cached.Location == loc.String() {
     s.liveMu.Unlock()
     return cached.Range, cached.Metrics, nil
   }
@@ -119,6 +146,7 @@ func (s *Service) Live(ctx context.Context, now time.Time, loc *time.Location, l
     Range: tr,
     Metrics: metrics,
     LookbackHours: lookbackHours,
+    Location: loc.String(),
   }
   s.liveMu.Unlock()
 
@@ -4,6 +4,8 @@ import (
   "context"
   "time"
 
+  "lightningos-light/internal/config"
+
   "github.com/jackc/pgx/v5/pgtype"
   "github.com/jackc/pgx/v5/pgxpool"
 )
@@ -36,8 +38,42 @@ alter table reports_daily add column if not exists forward_fee_revenue_msat bigi
 alter table reports_daily add column if not exists rebalance_fee_cost_msat bigint not null default 0;
 alter table reports_daily add column if not exists net_routing_profit_msat bigint not null default 0;
 alter table reports_daily add column if not exists routed_volume_msat bigint not null default 0;
+
+create table if not exists reports_daily_rebalance_pairs (
+  report_date date not null,
+  out_chan_id bigint not null,
+  in_chan_id bigint not null,
+  fee_msat bigint not null default 0,
+  amount_msat bigint not null default 0,
+  rebalance_count integer not null default 0,
+  primary key (report_date, out_chan_id, in_chan_id)
+);
+
+alter table reports_daily_rebalance_pairs add column if not exists node_id text not null default 'default';
+
+create table if not exists reports_daily_channel_forwards (
+  report_date date not null,
+  node_id text not null default 'default',
+  chan_id bigint not null,
+  forward_count integer not null default 0,
+  amount_msat bigint not null default 0,
+  fee_msat bigint not null default 0,
+  primary key (report_date, node_id, chan_id)
+);
+
+create table if not exists reports_backfill_state (
+  range_key text primary key,
+  last_completed date not null,
+  updated_at timestamptz not null default now()
+);
 `)
-  return err
+  if err != nil {
+    return err
+  }
+  if _, err := db.Exec(ctx, lndgSchema); err != nil {
+    return err
+  }
+  return ensureViews(ctx, db)
 }
 
 func UpsertDaily(ctx context.Context, db *pgxpool.Pool, row Row) error {
@@ -45,8 +81,43 @@ func UpsertDaily(ctx context.Context, db *pgxpool.Pool, row Row) error {
     return nil
   }
   query, args := buildUpsertDaily(row)
-  _, err := db.Exec(ctx, query, args...)
-  return err
+  tx, err := db.Begin(ctx)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback(ctx)
+
+  if _, err := tx.Exec(ctx, query, args...); err != nil {
+    return err
+  }
+  reportDate := normalizeReportDate(row.ReportDate)
+  nodeID := row.NodeID
+  if nodeID == "" {
+    nodeID = config.DefaultNodeID
+  }
+  if _, err := tx.Exec(ctx, `delete from reports_daily_rebalance_pairs where report_date=$1`, reportDate); err != nil {
+    return err
+  }
+  for _, pair := range row.Metrics.RebalancePairs {
+    if _, err := tx.Exec(ctx, `
+insert into reports_daily_rebalance_pairs (report_date, out_chan_id, in_chan_id, fee_msat, amount_msat, rebalance_count, node_id)
+values ($1, $2, $3, $4, $5, $6, $7)
+`, reportDate, int64(pair.OutChanID), int64(pair.InChanID), pair.FeeMsat, pair.AmountMsat, pair.Count, nodeID); err != nil {
+      return err
+    }
+  }
+  if _, err := tx.Exec(ctx, `delete from reports_daily_channel_forwards where report_date=$1 and node_id=$2`, reportDate, nodeID); err != nil {
+    return err
+  }
+  for _, channel := range row.Metrics.ChannelForwards {
+    if _, err := tx.Exec(ctx, `
+insert into reports_daily_channel_forwards (report_date, node_id, chan_id, forward_count, amount_msat, fee_msat)
+values ($1, $2, $3, $4, $5, $6)
+`, reportDate, nodeID, int64(channel.ChanID), channel.Count, channel.AmountMsat, channel.FeeMsat); err != nil {
+      return err
+    }
+  }
+  return tx.Commit(ctx)
 }
 
 func buildUpsertDaily(row Row) (string, []any) {
@@ -16,10 +16,26 @@ type Metrics struct {
   OnchainBalanceSat *int64
   LightningBalanceSat *int64
   TotalBalanceSat *int64
+  // RebalancePairs holds the most expensive rebalance corridors of the
+  // period. It is stored alongside daily rows but not loaded with them.
+  RebalancePairs []RebalancePair
+  // ChannelForwards splits the forwards by outgoing channel; stored like
+  // RebalancePairs.
+  ChannelForwards []ChannelForward
+}
+
+// ChannelForward is the forwarding done out of one channel in a period.
+type ChannelForward struct {
+  ChanID uint64
+  Count int64
+  AmountMsat int64
+  FeeMsat int64
 }
 
 type Row struct {
   ReportDate time.Time
+  // NodeID is the node the row was computed for; empty is the primary.
+  NodeID string
   Metrics Metrics
 }
 
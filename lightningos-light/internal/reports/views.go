@@ -0,0 +1,122 @@
+package reports
+
+import (
+  "context"
+
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The views below are a public contract for dashboards (Grafana's Postgres
+// datasource points straight at them). Columns may be appended but never
+// renamed, retyped or reordered: create or replace view refuses that anyway,
+// and saved dashboards would break. Every view has a `time` column at
+// midnight UTC of `day` so Grafana's time macros work without casts.
+
+const revenueDailyView = `
+create or replace view revenue_daily (
+  day,
+  time,
+  forward_fee_revenue_sats,
+  rebalance_fee_cost_sats,
+  net_routing_profit_sats,
+  forward_count,
+  rebalance_count,
+  routed_volume_sats,
+  effective_fee_ppm
+) as
+select
+  report_date,
+  report_date::timestamp at time zone 'UTC',
+  forward_fee_revenue_msat / 1000.0,
+  rebalance_fee_cost_msat / 1000.0,
+  net_routing_profit_msat / 1000.0,
+  forward_count,
+  rebalance_count,
+  routed_volume_msat / 1000.0,
+  case when routed_volume_msat > 0
+    then forward_fee_revenue_msat * 1000000.0 / routed_volume_msat
+    else 0 end
+from reports_daily;
+`
+
+const balanceHistoryView = `
+create or replace view balance_history (
+  day,
+  time,
+  onchain_sats,
+  lightning_sats,
+  total_sats
+) as
+select
+  report_date,
+  report_date::timestamp at time zone 'UTC',
+  onchain_balance_sats,
+  lightning_balance_sats,
+  total_balance_sats
+from reports_daily
+where total_balance_sats is not null;
+`
+
+// channelFlowsDailyView joins the forwards and rebalance corridors stored with
+// the daily reports, per node and channel. Both are bucketed by report_date,
+// the day in the reports time zone, so a channel's forwards and rebalances
+// line up on the same day. Only the top rebalance corridors of each day are
+// stored, so rebalance totals can fall short of revenue_daily's.
+const channelFlowsDailyView = `
+create or replace view channel_flows_daily (
+  day,
+  time,
+  node_id,
+  chan_id,
+  forward_out_count,
+  forward_out_sats,
+  forward_fee_sats,
+  rebalance_out_count,
+  rebalance_out_sats,
+  rebalance_in_count,
+  rebalance_in_sats,
+  rebalance_fee_sats
+) as
+select
+  day,
+  day::timestamp at time zone 'UTC',
+  node_id,
+  chan_id,
+  sum(forward_out_count)::integer,
+  (sum(forward_out_msat) / 1000)::bigint,
+  sum(forward_fee_msat) / 1000.0,
+  sum(rebalance_out_count)::integer,
+  sum(rebalance_out_msat) / 1000.0,
+  sum(rebalance_in_count)::integer,
+  sum(rebalance_in_msat) / 1000.0,
+  sum(rebalance_fee_msat) / 1000.0
+from (
+  select report_date as day, node_id, chan_id,
+    forward_count as forward_out_count, amount_msat as forward_out_msat,
+    fee_msat as forward_fee_msat,
+    0 as rebalance_out_count, 0::bigint as rebalance_out_msat,
+    0 as rebalance_in_count, 0::bigint as rebalance_in_msat,
+    0::bigint as rebalance_fee_msat
+  from reports_daily_channel_forwards
+  union all
+  select report_date, node_id, out_chan_id,
+    0, 0, 0,
+    rebalance_count, amount_msat,
+    0, 0,
+    fee_msat
+  from reports_daily_rebalance_pairs
+  union all
+  select report_date, node_id, in_chan_id,
+    0, 0, 0,
+    0, 0,
+    rebalance_count, amount_msat,
+    0
+  from reports_daily_rebalance_pairs
+) flows
+group by day, node_id, chan_id;
+`
+
+func ensureViews(ctx context.Context, db *pgxpool.Pool) error {
+  _, err := db.Exec(ctx, revenueDailyView+balanceHistoryView+channelFlowsDailyView)
+  return err
+}
@@ -0,0 +1,617 @@
+package server
+
+import (
+  "context"
+  "encoding/csv"
+  "errors"
+  "fmt"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/go-chi/chi/v5"
+  "github.com/jackc/pgx/v5"
+)
+
+// The activity journal completes the accounting trail LND cannot see:
+// notes on notifications, payments and on-chain transactions, and manual
+// entries for money that moved outside the node ("reimbursed from cold
+// storage"). Both go into the activity export next to the notifications.
+const (
+  annotationKindNotification = "notification"
+  annotationKindPayment = "payment"
+  annotationKindTransaction = "transaction"
+
+  maxAnnotationNote = 1000
+  maxJournalCategory = 40
+  maxJournalMemo = 500
+  activityExportMaxDays = 366
+  activityExportMaxRows = 50000
+)
+
+type activityAnnotation struct {
+  Kind string `json:"kind"`
+  Ref string `json:"ref"`
+  Note string `json:"note"`
+  UpdatedAt time.Time `json:"updated_at"`
+}
+
+type journalEntry struct {
+  ID int64 `json:"id"`
+  OccurredAt time.Time `json:"occurred_at"`
+  // Direction is in or out; AmountSat is never negative.
+  Direction string `json:"direction"`
+  AmountSat int64 `json:"amount_sat"`
+  FeeSat int64 `json:"fee_sat"`
+  Category string `json:"category,omitempty"`
+  Memo string `json:"memo"`
+  CreatedAt time.Time `json:"created_at"`
+  UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (n *Notifier) ensureJournalSchema(ctx context.Context) error {
+  _, err := n.db.Exec(ctx, `
+create table if not exists activity_annotations (
+  node_id text not null,
+  kind text not null,
+  ref text not null,
+  note text not null,
+  updated_at timestamptz not null default now(),
+  primary key (node_id, kind, ref)
+);
+
+create table if not exists journal_entries (
+  id bigserial primary key,
+  node_id text not null,
+  occurred_at timestamptz not null,
+  direction text not null,
+  amount_sat bigint not null,
+  fee_sat bigint not null default 0,
+  category text not null default '',
+  memo text not null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+create index if not exists journal_entries_node_occurred_idx on journal_entries (node_id, occurred_at);
+`)
+  return err
+}
+
+// normalizeAnnotationTarget checks the reference an annotation hangs on:
+// a notification id, a payment hash or a txid.
+func normalizeAnnotationTarget(kind, ref string) (string, string, error) {
+  kind = strings.ToLower(strings.TrimSpace(kind))
+  ref = strings.ToLower(strings.TrimSpace(ref))
+  switch kind {
+  case annotationKindNotification:
+    if id, err := strconv.ParseInt(ref, 10, 64); err != nil || id <= 0 {
+      return "", "", errors.New("invalid notification id")
+    }
+  case annotationKindPayment, annotationKindTransaction:
+    if len(ref) != 64 || !isHexString(ref) {
+      return "", "", fmt.Errorf("invalid %s reference", kind)
+    }
+  default:
+    return "", "", errors.New("kind must be notification, payment or transaction")
+  }
+  return kind, ref, nil
+}
+
+func isHexString(value string) bool {
+  for _, c := range value {
+    if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+      return false
+    }
+  }
+  return value != ""
+}
+
+func (n *Notifier) listAnnotations(ctx context.Context) ([]activityAnnotation, error) {
+  rows, err := n.db.Query(ctx, `
+select kind, ref, note, updated_at
+from activity_annotations
+where node_id=$1
+order by updated_at desc`, n.nodeKey())
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  items := []activityAnnotation{}
+  for rows.Next() {
+    var item activityAnnotation
+    if err := rows.Scan(&item.Kind, &item.Ref, &item.Note, &item.UpdatedAt); err != nil {
+      return nil, err
+    }
+    items = append(items, item)
+  }
+  return items, rows.Err()
+}
+
+// setAnnotation stores a note; an empty note removes it.
+func (n *Notifier) setAnnotation(ctx context.Context, kind, ref, note string) error {
+  if note == "" {
+    _, err := n.db.Exec(ctx, `delete from activity_annotations where node_id=$1 and kind=$2 and ref=$3`, n.nodeKey(), kind, ref)
+    return err
+  }
+  _, err := n.db.Exec(ctx, `
+insert into activity_annotations (node_id, kind, ref, note, updated_at)
+values ($1, $2, $3, $4, now())
+on conflict (node_id, kind, ref) do update set note=excluded.note, updated_at=excluded.updated_at`,
+    n.nodeKey(), kind, ref, note)
+  return err
+}
+
+// annotationIndex looks up the note of a notification: its own note first,
+// then the note on its payment hash, then on its txid.
+type annotationIndex map[string]string
+
+func newAnnotationIndex(items []activityAnnotation) annotationIndex {
+  index := annotationIndex{}
+  for _, item := range items {
+    index[item.Kind+":"+item.Ref] = item.Note
+  }
+  return index
+}
+
+func (idx annotationIndex) noteFor(evt Notification) string {
+  if note, ok := idx[annotationKindNotification+":"+strconv.FormatInt(evt.ID, 10)]; ok {
+    return note
+  }
+  if evt.PaymentHash != "" {
+    if note, ok := idx[annotationKindPayment+":"+strings.ToLower(evt.PaymentHash)]; ok {
+      return note
+    }
+  }
+  if evt.Txid != "" {
+    if note, ok := idx[annotationKindTransaction+":"+strings.ToLower(evt.Txid)]; ok {
+      return note
+    }
+  }
+  return ""
+}
+
+// attachAnnotations fills Note on the listed notifications. Notes are an
+// extra, so a failed lookup leaves the list as it is.
+func (n *Notifier) attachAnnotations(ctx context.Context, items []Notification) {
+  if n.db == nil || len(items) == 0 {
+    return
+  }
+  annotations, err := n.listAnnotations(ctx)
+  if err != nil || len(annotations) == 0 {
+    return
+  }
+  index := newAnnotationIndex(annotations)
+  for i := range items {
+    items[i].Note = index.noteFor(items[i])
+  }
+}
+
+func (e *journalEntry) normalize() error {
+  e.Direction = strings.ToLower(strings.TrimSpace(e.Direction))
+  e.Category = strings.TrimSpace(e.Category)
+  e.Memo = strings.TrimSpace(e.Memo)
+  if e.Direction != "in" && e.Direction != "out" {
+    return errors.New("direction must be in or out")
+  }
+  if e.AmountSat <= 0 {
+    return errors.New("amount_sat must be positive")
+  }
+  if e.FeeSat < 0 {
+    return errors.New("fee_sat must be zero or positive")
+  }
+  if e.Memo == "" {
+    return errors.New("memo required")
+  }
+  if len(e.Memo) > maxJournalMemo {
+    return fmt.Errorf("memo too long: max %d bytes", maxJournalMemo)
+  }
+  if len(e.Category) > maxJournalCategory {
+    return fmt.Errorf("category too long: max %d bytes", maxJournalCategory)
+  }
+  if e.OccurredAt.IsZero() {
+    e.OccurredAt = time.Now()
+  }
+  e.OccurredAt = e.OccurredAt.UTC()
+  return nil
+}
+
+func scanJournalEntry(row pgx.Row) (journalEntry, error) {
+  var e journalEntry
+  err := row.Scan(&e.ID, &e.OccurredAt, &e.Direction, &e.AmountSat, &e.FeeSat, &e.Category, &e.Memo, &e.CreatedAt, &e.UpdatedAt)
+  return e, err
+}
+
+const journalColumns = `id, occurred_at, direction, amount_sat, fee_sat, category, memo, created_at, updated_at`
+
+func (n *Notifier) listJournal(ctx context.Context, from, to time.Time) ([]journalEntry, error) {
+  rows, err := n.db.Query(ctx, `
+select `+journalColumns+`
+from journal_entries
+where node_id=$1 and occurred_at >= $2 and occurred_at < $3
+order by occurred_at, id`, n.nodeKey(), from, to)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  items := []journalEntry{}
+  for rows.Next() {
+    e, err := scanJournalEntry(rows)
+    if err != nil {
+      return nil, err
+    }
+    items = append(items, e)
+  }
+  return items, rows.Err()
+}
+
+// saveJournalEntry inserts the entry, or updates it when it has an id. The
+// bool is false when the id is unknown.
+func (n *Notifier) saveJournalEntry(ctx context.Context, e journalEntry) (journalEntry, bool, error) {
+  var saved journalEntry
+  var err error
+  if e.ID == 0 {
+    saved, err = scanJournalEntry(n.db.QueryRow(ctx, `
+insert into journal_entries (node_id, occurred_at, direction, amount_sat, fee_sat, category, memo)
+values ($1, $2, $3, $4, $5, $6, $7)
+returning `+journalColumns, n.nodeKey(), e.OccurredAt, e.Direction, e.AmountSat, e.FeeSat, e.Category, e.Memo))
+  } else {
+    saved, err = scanJournalEntry(n.db.QueryRow(ctx, `
+update journal_entries
+set occurred_at=$3, direction=$4, amount_sat=$5, fee_sat=$6, category=$7, memo=$8, updated_at=now()
+where node_id=$1 and id=$2
+returning `+journalColumns, n.nodeKey(), e.ID, e.OccurredAt, e.Direction, e.AmountSat, e.FeeSat, e.Category, e.Memo))
+  }
+  if errors.Is(err, pgx.ErrNoRows) {
+    return journalEntry{}, false, nil
+  }
+  if err != nil {
+    return journalEntry{}, false, err
+  }
+  return saved, true, nil
+}
+
+func (n *Notifier) deleteJournalEntry(ctx context.Context, id int64) (bool, error) {
+  tag, err := n.db.Exec(ctx, `delete from journal_entries where node_id=$1 and id=$2`, n.nodeKey(), id)
+  if err != nil {
+    return false, err
+  }
+  return tag.RowsAffected() > 0, nil
+}
+
+func (n *Notifier) listNotificationsBetween(ctx context.Context, from, to time.Time, limit int) ([]Notification, error) {
+  rows, err := n.db.Query(ctx, `
+select id, occurred_at, type, action, direction, status, amount_sat, fee_sat, fee_msat,
+  peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo, event_key
+from notifications
+where node_id=$1 and occurred_at >= $2 and occurred_at < $3
+order by occurred_at, id
+limit $4`, n.nodeKey(), from, to, limit)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  items := []Notification{}
+  for rows.Next() {
+    evt, err := scanNotification(rows)
+    if err != nil {
+      return nil, err
+    }
+    items = append(items, evt)
+  }
+  return items, rows.Err()
+}
+
+// activityExportRow is one line of the export: a notification with its
+// note, or a manual journal entry (source "journal").
+type activityExportRow struct {
+  Source string `json:"source"`
+  ID int64 `json:"id"`
+  OccurredAt time.Time `json:"occurred_at"`
+  Type string `json:"type"`
+  Action string `json:"action,omitempty"`
+  Direction string `json:"direction"`
+  Status string `json:"status,omitempty"`
+  AmountSat int64 `json:"amount_sat"`
+  FeeMsat int64 `json:"fee_msat"`
+  Peer string `json:"peer,omitempty"`
+  Reference string `json:"reference,omitempty"`
+  Memo string `json:"memo,omitempty"`
+  Note string `json:"note,omitempty"`
+}
+
+var activityExportHeader = []string{"source", "id", "occurred_at", "type", "action", "direction", "status", "amount_sat", "fee_msat", "peer", "reference", "memo", "note"}
+
+// buildActivityExport merges notifications and journal entries by time.
+func buildActivityExport(items []Notification, journal []journalEntry, index annotationIndex) []activityExportRow {
+  rows := make([]activityExportRow, 0, len(items)+len(journal))
+  for _, evt := range items {
+    ref := evt.PaymentHash
+    if ref == "" {
+      ref = evt.Txid
+    }
+    if ref == "" {
+      ref = evt.ChannelPoint
+    }
+    peer := evt.PeerAlias
+    if peer == "" {
+      peer = evt.PeerPubkey
+    }
+    rows = append(rows, activityExportRow{
+      Source: "notification",
+      ID: evt.ID,
+      OccurredAt: evt.OccurredAt.UTC(),
+      Type: evt.Type,
+      Action: evt.Action,
+      Direction: evt.Direction,
+      Status: evt.Status,
+      AmountSat: evt.AmountSat,
+      FeeMsat: evt.FeeMsat,
+      Peer: peer,
+      Reference: ref,
+      Memo: evt.Memo,
+      Note: index.noteFor(evt),
+    })
+  }
+  for _, e := range journal {
+    rows = append(rows, activityExportRow{
+      Source: "journal",
+      ID: e.ID,
+      OccurredAt: e.OccurredAt.UTC(),
+      Type: "journal",
+      Action: e.Category,
+      Direction: e.Direction,
+      AmountSat: e.AmountSat,
+      FeeMsat: e.FeeSat * 1000,
+      Memo: e.Memo,
+    })
+  }
+  sort.SliceStable(rows, func(i, j int) bool { return rows[i].OccurredAt.Before(rows[j].OccurredAt) })
+  return rows
+}
+
+func writeActivityCSV(w *csv.Writer, rows []activityExportRow) error {
+  if err := w.Write(activityExportHeader); err != nil {
+    return err
+  }
+  for _, row := range rows {
+    record := []string{
+      row.Source,
+      strconv.FormatInt(row.ID, 10),
+      row.OccurredAt.Format(time.RFC3339),
+      row.Type,
+      row.Action,
+      row.Direction,
+      row.Status,
+      strconv.FormatInt(row.AmountSat, 10),
+      strconv.FormatInt(row.FeeMsat, 10),
+      row.Peer,
+      row.Reference,
+      row.Memo,
+      row.Note,
+    }
+    if err := w.Write(record); err != nil {
+      return err
+    }
+  }
+  w.Flush()
+  return w.Error()
+}
+
+// activityRange reads from and to (YYYY-MM-DD, UTC, both included). Both
+// default to the last 30 days.
+func activityRange(r *http.Request) (time.Time, time.Time, error) {
+  today := time.Now().UTC().Truncate(24 * time.Hour)
+  from, to := today.AddDate(0, 0, -29), today
+  if raw := strings.TrimSpace(r.URL.Query().Get("from")); raw != "" {
+    parsed, err := time.Parse("2006-01-02", raw)
+    if err != nil {
+      return from, to, errors.New("from must be YYYY-MM-DD")
+    }
+    from = parsed
+  }
+  if raw := strings.TrimSpace(r.URL.Query().Get("to")); raw != "" {
+    parsed, err := time.Parse("2006-01-02", raw)
+    if err != nil {
+      return from, to, errors.New("to must be YYYY-MM-DD")
+    }
+    to = parsed
+  }
+  if to.Before(from) {
+    return from, to, errors.New("invalid range")
+  }
+  if to.Sub(from) >= activityExportMaxDays*24*time.Hour {
+    return from, to, fmt.Errorf("range too large: max %d days", activityExportMaxDays)
+  }
+  return from, to.AddDate(0, 0, 1), nil
+}
+
+func (s *Server) journalNotifier(w http.ResponseWriter, r *http.Request) *Notifier {
+  notifier := s.notifierFor(r)
+  if notifier == nil || notifier.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return nil
+  }
+  return notifier
+}
+
+func (s *Server) handleActivityAnnotationsList(w http.ResponseWriter, r *http.Request) {
+  notifier := s.journalNotifier(w, r)
+  if notifier == nil {
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  items, err := notifier.listAnnotations(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load annotations: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handleActivityAnnotationsSave sets the note on a notification, payment or
+// transaction; an empty note removes it.
+func (s *Server) handleActivityAnnotationsSave(w http.ResponseWriter, r *http.Request) {
+  notifier := s.journalNotifier(w, r)
+  if notifier == nil {
+    return
+  }
+  var req activityAnnotation
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  kind, ref, err := normalizeAnnotationTarget(req.Kind, req.Ref)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  note := strings.TrimSpace(req.Note)
+  if len(note) > maxAnnotationNote {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("note too long: max %d bytes", maxAnnotationNote))
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  if err := notifier.setAnnotation(ctx, kind, ref, note); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save annotation: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, activityAnnotation{Kind: kind, Ref: ref, Note: note, UpdatedAt: time.Now().UTC()})
+}
+
+func (s *Server) handleJournalList(w http.ResponseWriter, r *http.Request) {
+  notifier := s.journalNotifier(w, r)
+  if notifier == nil {
+    return
+  }
+  from, to, err := activityRange(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  items, err := notifier.listJournal(ctx, from, to)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load journal: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handleJournalSave creates a manual entry, or updates the one named by id.
+func (s *Server) handleJournalSave(w http.ResponseWriter, r *http.Request) {
+  notifier := s.journalNotifier(w, r)
+  if notifier == nil {
+    return
+  }
+  var req journalEntry
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if err := req.normalize(); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  saved, found, err := notifier.saveJournalEntry(ctx, req)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save journal entry: %v", err))
+    return
+  }
+  if !found {
+    writeError(w, http.StatusNotFound, "journal entry not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, saved)
+}
+
+func (s *Server) handleJournalDelete(w http.ResponseWriter, r *http.Request) {
+  notifier := s.journalNotifier(w, r)
+  if notifier == nil {
+    return
+  }
+  id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+  if err != nil || id <= 0 {
+    writeError(w, http.StatusBadRequest, "invalid journal entry id")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  found, err := notifier.deleteJournalEntry(ctx, id)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to delete journal entry: %v", err))
+    return
+  }
+  if !found {
+    writeError(w, http.StatusNotFound, "journal entry not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleActivityExport exports the notifications of a date range with their
+// notes, merged with the journal entries, as CSV (default) or JSON.
+func (s *Server) handleActivityExport(w http.ResponseWriter, r *http.Request) {
+  notifier := s.journalNotifier(w, r)
+  if notifier == nil {
+    return
+  }
+  from, to, err := activityRange(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+  if format == "" {
+    format = "csv"
+  }
+  if format != "csv" && format != "json" {
+    writeError(w, http.StatusBadRequest, "format must be csv or json")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  items, err := notifier.listNotificationsBetween(ctx, from, to, activityExportMaxRows+1)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load notifications: %v", err))
+    return
+  }
+  if len(items) > activityExportMaxRows {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("too many rows: max %d, narrow the range", activityExportMaxRows))
+    return
+  }
+  journal, err := notifier.listJournal(ctx, from, to)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load journal: %v", err))
+    return
+  }
+  annotations, err := notifier.listAnnotations(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load annotations: %v", err))
+    return
+  }
+  rows := buildActivityExport(items, journal, newAnnotationIndex(annotations))
+
+  name := fmt.Sprintf("lightningos-activity-%s-%s.%s", from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"), format)
+  w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+  if format == "json" {
+    writeJSON(w, http.StatusOK, map[string]any{
+      "from": from,
+      "to": to,
+      "items": rows,
+    })
+    return
+  }
+  w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+  w.WriteHeader(http.StatusOK)
+  if err := writeActivityCSV(csv.NewWriter(w), rows); err != nil {
+    s.logger.Printf("activity export: write failed: %v", err)
+  }
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+  "bytes"
+  "encoding/csv"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestNormalizeAnnotationTarget(t *testing.T) {
+  hash := strings.Repeat("AB", 32)
+  kind, ref, err := normalizeAnnotationTarget(" Payment ", hash)
+  if err != nil || kind != annotationKindPayment || ref != strings.ToLower(hash) {
+    t.Fatalf("got %s %s: %v", kind, ref, err)
+  }
+  if _, _, err := normalizeAnnotationTarget("notification", "0"); err == nil {
+    t.Fatal("accepted notification id 0")
+  }
+  if _, _, err := normalizeAnnotationTarget("transaction", "abc"); err == nil {
+    t.Fatal("accepted a short txid")
+  }
+  if _, _, err := normalizeAnnotationTarget("invoice", hash); err == nil {
+    t.Fatal("accepted an unknown kind")
+  }
+}
+
+func TestBuildActivityExport(t *testing.T) {
+  base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  hash := strings.Repeat("aa", 32)
+  txid := strings.Repeat("bb", 32)
+  items := []Notification{
+    {ID: 1, OccurredAt: base, Type: "lightning", Direction: "out", Status: "SUCCEEDED", AmountSat: 1000, FeeMsat: 2000, PaymentHash: hash},
+    {ID: 2, OccurredAt: base.Add(2 * time.Hour), Type: "onchain", Direction: "in", AmountSat: 50000, Txid: txid, Memo: "deposit"},
+    {ID: 3, OccurredAt: base.Add(3 * time.Hour), Type: "onchain", Direction: "out", AmountSat: 7000, Txid: txid},
+  }
+  journal := []journalEntry{{ID: 9, OccurredAt: base.Add(time.Hour), Direction: "in", AmountSat: 20000, FeeSat: 3, Category: "reimbursement", Memo: "reimbursed from cold storage"}}
+  index := newAnnotationIndex([]activityAnnotation{
+    {Kind: annotationKindPayment, Ref: hash, Note: "hosting bill"},
+    {Kind: annotationKindTransaction, Ref: txid, Note: "exchange withdrawal"},
+    {Kind: annotationKindNotification, Ref: "3", Note: "sweep"},
+  })
+
+  rows := buildActivityExport(items, journal, index)
+  if len(rows) != 4 || rows[1].Source != "journal" || rows[1].FeeMsat != 3000 {
+    t.Fatalf("rows %+v", rows)
+  }
+  if rows[0].Note != "hosting bill" || rows[2].Note != "exchange withdrawal" || rows[3].Note != "sweep" {
+    t.Fatalf("notes %q %q %q", rows[0].Note, rows[2].Note, rows[3].Note)
+  }
+
+  var buf bytes.Buffer
+  if err := writeActivityCSV(csv.NewWriter(&buf), rows); err != nil {
+    t.Fatal(err)
+  }
+  records, err := csv.NewReader(&buf).ReadAll()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(records) != 5 || records[0][0] != "source" || records[2][11] != "reimbursed from cold storage" {
+    t.Fatalf("csv %v", records)
+  }
+}
+
+func TestActivityRange(t *testing.T) {
+  from, to, err := activityRange(httptest.NewRequest("GET", "/?from=2026-01-01&to=2026-01-31", nil))
+  if err != nil || !from.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+    t.Fatalf("range %s %s: %v", from, to, err)
+  }
+  if _, _, err := activityRange(httptest.NewRequest("GET", "/?from=2026-02-01&to=2026-01-01", nil)); err == nil {
+    t.Fatal("accepted a reversed range")
+  }
+  if _, _, err := activityRange(httptest.NewRequest("GET", "/?from=2024-01-01&to=2026-01-01", nil)); err == nil {
+    t.Fatal("accepted two years")
+  }
+}
@@ -0,0 +1,669 @@
+package server
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "math"
+  "net/http"
+  "net/url"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+
+  "github.com/go-chi/chi/v5"
+  "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+  alertEvalInterval = 30 * time.Second
+  alertStartDelay = time.Minute
+  alertWebhookTimeout = 10 * time.Second
+  alertMaxFor = 7 * 24 * time.Hour
+  alertMaxWindow = 30 * 24 * time.Hour
+  alertMaxActions = 5
+
+  alertStatusOK = "ok"
+  alertStatusPending = "pending"
+  alertStatusFiring = "firing"
+)
+
+// alertMetrics are what a rule can watch. The notification_* metrics count
+// stored notifications over window_seconds, optionally of one type.
+var alertMetrics = []struct {
+  Name string `json:"name"`
+  Description string `json:"description"`
+}{
+  {"onchain_balance_sat", "Confirmed on-chain wallet balance"},
+  {"lightning_balance_sat", "Local balance across open channels"},
+  {"total_balance_sat", "On-chain plus lightning balance"},
+  {"channels_active", "Active channels"},
+  {"channels_inactive", "Inactive channels"},
+  {"channels_pending", "Pending channels"},
+  {"synced_to_chain", "1 when LND is synced to chain, else 0"},
+  {"health_issues", "Issues reported by /api/health"},
+  {"notification_count", "Notifications in the window"},
+  {"notification_amount_sat", "Sum of notification amounts in the window"},
+}
+
+// alertJobKinds are the jobs an alert may start. Anything that moves funds
+// or stops services stays manual.
+var alertJobKinds = map[string]bool{
+  jobKindBoostPeers: true,
+}
+
+// AlertRule fires its actions once metric compared to threshold has held for
+// for_seconds, and resolves when it stops holding.
+type AlertRule struct {
+  ID int64 `json:"id"`
+  Name string `json:"name"`
+  Enabled bool `json:"enabled"`
+  Metric string `json:"metric"`
+  Operator string `json:"operator"`
+  Threshold float64 `json:"threshold"`
+  ForSeconds int64 `json:"for_seconds"`
+  NotificationType string `json:"notification_type,omitempty"`
+  WindowSeconds int64 `json:"window_seconds,omitempty"`
+  Actions []AlertAction `json:"actions"`
+  State AlertState `json:"state"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+// AlertAction is notify (a notification of type alert, which also reaches
+// push topics), webhook (POST of the alert as JSON) or job.
+type AlertAction struct {
+  Type string `json:"type"`
+  URL string `json:"url,omitempty"`
+  JobKind string `json:"job_kind,omitempty"`
+  JobParams json.RawMessage `json:"job_params,omitempty"`
+}
+
+type AlertState struct {
+  Status string `json:"status"`
+  Value *float64 `json:"value,omitempty"`
+  PendingSince *time.Time `json:"pending_since,omitempty"`
+  FiredAt *time.Time `json:"fired_at,omitempty"`
+  EvaluatedAt *time.Time `json:"evaluated_at,omitempty"`
+  Error string `json:"error,omitempty"`
+}
+
+type alertRuleRequest struct {
+  Name string `json:"name"`
+  Enabled *bool `json:"enabled"`
+  Metric string `json:"metric"`
+  Operator string `json:"operator"`
+  Threshold float64 `json:"threshold"`
+  ForSeconds int64 `json:"for_seconds"`
+  NotificationType string `json:"notification_type"`
+  WindowSeconds int64 `json:"window_seconds"`
+  Actions []AlertAction `json:"actions"`
+}
+
+func alertMetricKnown(name string) bool {
+  for _, metric := range alertMetrics {
+    if metric.Name == name {
+      return true
+    }
+  }
+  return false
+}
+
+func alertWindowed(metric string) bool {
+  return strings.HasPrefix(metric, "notification_")
+}
+
+func compareAlert(op string, value, threshold float64) bool {
+  switch op {
+  case ">":
+    return value > threshold
+  case ">=":
+    return value >= threshold
+  case "<":
+    return value < threshold
+  case "<=":
+    return value <= threshold
+  case "==":
+    return value == threshold
+  case "!=":
+    return value != threshold
+  }
+  return false
+}
+
+// describe renders the condition the way a user would write it, e.g.
+// "channels_inactive > 3 for 1h0m0s".
+func (r AlertRule) describe() string {
+  cond := fmt.Sprintf("%s %s %s", r.Metric, r.Operator, strconv.FormatFloat(r.Threshold, 'f', -1, 64))
+  if alertWindowed(r.Metric) {
+    scope := "all"
+    if r.NotificationType != "" {
+      scope = r.NotificationType
+    }
+    cond = fmt.Sprintf("%s(%s, %s) %s %s", r.Metric, scope, time.Duration(r.WindowSeconds)*time.Second,
+      r.Operator, strconv.FormatFloat(r.Threshold, 'f', -1, 64))
+  }
+  if r.ForSeconds > 0 {
+    cond += fmt.Sprintf(" for %s", time.Duration(r.ForSeconds)*time.Second)
+  }
+  return cond
+}
+
+func parseAlertRule(req alertRuleRequest) (AlertRule, error) {
+  rule := AlertRule{
+    Name: strings.TrimSpace(req.Name),
+    Enabled: true,
+    Metric: strings.ToLower(strings.TrimSpace(req.Metric)),
+    Operator: strings.TrimSpace(req.Operator),
+    Threshold: req.Threshold,
+    ForSeconds: req.ForSeconds,
+    NotificationType: strings.ToLower(strings.TrimSpace(req.NotificationType)),
+    WindowSeconds: req.WindowSeconds,
+    Actions: req.Actions,
+  }
+  if req.Enabled != nil {
+    rule.Enabled = *req.Enabled
+  }
+  if !alertMetricKnown(rule.Metric) {
+    return AlertRule{}, fmt.Errorf("unknown alert metric: %s", rule.Metric)
+  }
+  switch rule.Operator {
+  case ">", ">=", "<", "<=", "==", "!=":
+  default:
+    return AlertRule{}, errors.New("operator must be one of > >= < <= == !=")
+  }
+  if math.IsNaN(rule.Threshold) || math.IsInf(rule.Threshold, 0) {
+    return AlertRule{}, errors.New("invalid threshold")
+  }
+  if rule.ForSeconds < 0 || time.Duration(rule.ForSeconds)*time.Second > alertMaxFor {
+    return AlertRule{}, errors.New("for_seconds must be between 0 and 7 days")
+  }
+  if alertWindowed(rule.Metric) {
+    if rule.WindowSeconds <= 0 || time.Duration(rule.WindowSeconds)*time.Second > alertMaxWindow {
+      return AlertRule{}, errors.New("window_seconds must be between 1 and 30 days")
+    }
+    if rule.NotificationType != "" && !notificationRuleTypes[rule.NotificationType] {
+      return AlertRule{}, fmt.Errorf("unknown notification type: %s", rule.NotificationType)
+    }
+  } else {
+    rule.NotificationType = ""
+    rule.WindowSeconds = 0
+  }
+  if len(rule.Actions) == 0 {
+    return AlertRule{}, errors.New("rule needs at least one action")
+  }
+  if len(rule.Actions) > alertMaxActions {
+    return AlertRule{}, fmt.Errorf("at most %d actions per rule", alertMaxActions)
+  }
+  for i := range rule.Actions {
+    action := &rule.Actions[i]
+    action.Type = strings.ToLower(strings.TrimSpace(action.Type))
+    switch action.Type {
+    case "notify":
+      action.URL, action.JobKind, action.JobParams = "", "", nil
+    case "webhook":
+      action.URL = strings.TrimSpace(action.URL)
+      u, err := url.Parse(action.URL)
+      if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return AlertRule{}, errors.New("webhook url must be http or https")
+      }
+      action.JobKind, action.JobParams = "", nil
+    case "job":
+      action.JobKind = strings.TrimSpace(action.JobKind)
+      if !alertJobKinds[action.JobKind] {
+        return AlertRule{}, fmt.Errorf("job kind not allowed in alerts: %s", action.JobKind)
+      }
+      if len(action.JobParams) > 0 && !json.Valid(action.JobParams) {
+        return AlertRule{}, errors.New("invalid job params")
+      }
+      action.URL = ""
+    default:
+      return AlertRule{}, errors.New("action type must be notify, webhook or job")
+    }
+  }
+  if rule.Name == "" {
+    rule.Name = rule.describe()
+  }
+  return rule, nil
+}
+
+func (s *Server) ensureAlertsSchema(ctx context.Context) error {
+  if s.db == nil {
+    return errors.New("db not configured")
+  }
+  _, err := s.db.Exec(ctx, `
+create table if not exists alert_rules (
+  id bigserial primary key,
+  name text not null default '',
+  enabled boolean not null default true,
+  metric text not null,
+  operator text not null,
+  threshold double precision not null default 0,
+  for_seconds bigint not null default 0,
+  notification_type text not null default '',
+  window_seconds bigint not null default 0,
+  actions jsonb not null default '[]',
+  status text not null default 'ok',
+  last_value double precision,
+  pending_since timestamptz,
+  fired_at timestamptz,
+  evaluated_at timestamptz,
+  last_error text not null default '',
+  created_at timestamptz not null default now()
+);
+`)
+  return err
+}
+
+const alertRuleColumns = `id, name, enabled, metric, operator, threshold, for_seconds, notification_type,
+  window_seconds, actions::text, status, last_value, pending_since, fired_at, evaluated_at, last_error, created_at`
+
+func scanAlertRules(rows pgx.Rows) ([]AlertRule, error) {
+  defer rows.Close()
+  rules := []AlertRule{}
+  for rows.Next() {
+    var rule AlertRule
+    var actions string
+    var value pgtype.Float8
+    var pendingSince, firedAt, evaluatedAt pgtype.Timestamptz
+    if err := rows.Scan(&rule.ID, &rule.Name, &rule.Enabled, &rule.Metric, &rule.Operator, &rule.Threshold,
+      &rule.ForSeconds, &rule.NotificationType, &rule.WindowSeconds, &actions, &rule.State.Status, &value,
+      &pendingSince, &firedAt, &evaluatedAt, &rule.State.Error, &rule.CreatedAt); err != nil {
+      return nil, err
+    }
+    if err := json.Unmarshal([]byte(actions), &rule.Actions); err != nil {
+      return nil, err
+    }
+    if value.Valid {
+      v := value.Float64
+      rule.State.Value = &v
+    }
+    rule.State.PendingSince = optionalTime(pendingSince)
+    rule.State.FiredAt = optionalTime(firedAt)
+    rule.State.EvaluatedAt = optionalTime(evaluatedAt)
+    rules = append(rules, rule)
+  }
+  return rules, rows.Err()
+}
+
+func optionalTime(ts pgtype.Timestamptz) *time.Time {
+  if !ts.Valid {
+    return nil
+  }
+  t := ts.Time.UTC()
+  return &t
+}
+
+func (s *Server) listAlertRules(ctx context.Context) ([]AlertRule, error) {
+  rows, err := s.db.Query(ctx, `select `+alertRuleColumns+` from alert_rules order by id asc`)
+  if err != nil {
+    return nil, err
+  }
+  return scanAlertRules(rows)
+}
+
+func (s *Server) saveAlertState(ctx context.Context, rule AlertRule) error {
+  _, err := s.db.Exec(ctx, `
+update alert_rules set status=$2, last_value=$3, pending_since=$4, fired_at=$5, evaluated_at=$6, last_error=$7
+where id=$1`, rule.ID, rule.State.Status, rule.State.Value, rule.State.PendingSince, rule.State.FiredAt,
+    rule.State.EvaluatedAt, rule.State.Error)
+  return err
+}
+
+// alertSampler reads each source at most once per evaluation round, however
+// many rules use it.
+type alertSampler struct {
+  s *Server
+  status *lndclient.Status
+  statusErr error
+  pending *int
+  health *healthResponse
+}
+
+func (a *alertSampler) lndStatus() (lndclient.Status, error) {
+  if a.status == nil && a.statusErr == nil {
+    ctx, cancel := a.s.taskContext("alerts/sample", timeoutLNDRPC)
+    status, err := a.s.lnd.GetStatus(ctx)
+    cancel()
+    a.status, a.statusErr = &status, err
+  }
+  return *a.status, a.statusErr
+}
+
+func (a *alertSampler) value(rule AlertRule) (float64, error) {
+  switch rule.Metric {
+  case "onchain_balance_sat", "lightning_balance_sat", "total_balance_sat", "channels_active", "channels_inactive", "synced_to_chain":
+    status, err := a.lndStatus()
+    if err != nil {
+      return 0, err
+    }
+    switch rule.Metric {
+    case "onchain_balance_sat":
+      return float64(status.OnchainSat), nil
+    case "lightning_balance_sat":
+      return float64(status.LightningSat), nil
+    case "total_balance_sat":
+      return float64(status.OnchainSat + status.LightningSat), nil
+    case "channels_active":
+      return float64(status.ChannelsActive), nil
+    case "channels_inactive":
+      return float64(status.ChannelsInactive), nil
+    }
+    if status.SyncedToChain {
+      return 1, nil
+    }
+    return 0, nil
+  case "channels_pending":
+    if a.pending == nil {
+      ctx, cancel := a.s.taskContext("alerts/sample", timeoutLNDRPC)
+      pending, err := a.s.lnd.ListPendingChannels(ctx)
+      cancel()
+      if err != nil {
+        return 0, err
+      }
+      count := len(pending)
+      a.pending = &count
+    }
+    return float64(*a.pending), nil
+  case "health_issues":
+    if a.health == nil {
+      health := a.s.healthReport(func(class timeoutClass) (context.Context, context.CancelFunc) {
+        return a.s.taskContext("alerts/sample", class)
+      })
+      a.health = &health
+    }
+    return float64(len(a.health.Issues)), nil
+  case "notification_count", "notification_amount_sat":
+    if a.s.notifier == nil {
+      return 0, errors.New("notifications disabled")
+    }
+    ctx, cancel := a.s.taskContext("alerts/sample", timeoutShort)
+    defer cancel()
+    since := time.Now().Add(-time.Duration(rule.WindowSeconds) * time.Second)
+    var count int64
+    var amount int64
+    err := a.s.db.QueryRow(ctx, `
+select count(*), coalesce(sum(abs(amount_sat)), 0)::bigint
+from notifications
+where node_id=$1 and occurred_at >= $2 and ($3 = '' or type = $3)`,
+      a.s.notifier.nodeKey(), since, rule.NotificationType).Scan(&count, &amount)
+    if err != nil {
+      return 0, err
+    }
+    if rule.Metric == "notification_count" {
+      return float64(count), nil
+    }
+    return float64(amount), nil
+  }
+  return 0, fmt.Errorf("unknown alert metric: %s", rule.Metric)
+}
+
+// advanceAlert moves a rule through ok -> pending -> firing -> ok and reports
+// whether it just fired or just resolved.
+func advanceAlert(state AlertState, holds bool, hold time.Duration, now time.Time) (AlertState, bool, bool) {
+  if !holds {
+    resolved := state.Status == alertStatusFiring
+    state.Status = alertStatusOK
+    state.PendingSince = nil
+    return state, false, resolved
+  }
+  if state.Status == alertStatusFiring {
+    return state, false, false
+  }
+  if state.PendingSince == nil {
+    since := now
+    state.PendingSince = &since
+  }
+  if now.Sub(*state.PendingSince) < hold {
+    state.Status = alertStatusPending
+    return state, false, false
+  }
+  fired := now
+  state.Status = alertStatusFiring
+  state.FiredAt = &fired
+  return state, true, false
+}
+
+func (s *Server) initAlerts() {
+  if s.db == nil {
+    return
+  }
+  ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
+  defer cancel()
+  if err := s.ensureAlertsSchema(ctx); err != nil {
+    s.logger.Printf("alerts: failed to init schema: %v", err)
+    return
+  }
+  goSafe("alerts", s.runAlerts)
+}
+
+// runAlerts evaluates every enabled rule each round and runs the actions of
+// rules that start or stop firing.
+func (s *Server) runAlerts() {
+  if !s.wait(alertStartDelay) {
+    return
+  }
+  for {
+    s.evaluateAlerts()
+    if !s.wait(alertEvalInterval) {
+      return
+    }
+  }
+}
+
+func (s *Server) evaluateAlerts() {
+  ctx, cancel := s.taskContext("alerts/evaluate", timeoutShort)
+  rules, err := s.listAlertRules(ctx)
+  cancel()
+  if err != nil {
+    s.logger.Printf("alerts: failed to load rules: %v", err)
+    return
+  }
+  sampler := &alertSampler{s: s}
+  for _, rule := range rules {
+    if !rule.Enabled {
+      continue
+    }
+    now := time.Now().UTC()
+    rule.State.EvaluatedAt = &now
+    value, err := sampler.value(rule)
+    if err != nil {
+      // An unreadable metric keeps the previous state rather than resolving
+      // an alert because LND happened to be restarting.
+      rule.State.Error = err.Error()
+    } else {
+      rule.State.Value = &value
+      rule.State.Error = ""
+      var fired, resolved bool
+      holds := compareAlert(rule.Operator, value, rule.Threshold)
+      rule.State, fired, resolved = advanceAlert(rule.State, holds, time.Duration(rule.ForSeconds)*time.Second, now)
+      if fired || resolved {
+        if errs := s.runAlertActions(rule, value, fired); len(errs) > 0 {
+          rule.State.Error = strings.Join(errs, "; ")
+        }
+      }
+    }
+    ctx, cancel := s.taskContext("alerts/evaluate", timeoutShort)
+    if err := s.saveAlertState(ctx, rule); err != nil {
+      s.logger.Printf("alerts: failed to save state of rule %d: %v", rule.ID, err)
+    }
+    cancel()
+  }
+}
+
+func (s *Server) runAlertActions(rule AlertRule, value float64, fired bool) []string {
+  state := "resolved"
+  if fired {
+    state = "firing"
+  }
+  s.logger.Printf("alerts: rule %d (%s) %s at %v", rule.ID, rule.Name, state, value)
+  var errs []string
+  for _, action := range rule.Actions {
+    var err error
+    switch action.Type {
+    case "notify":
+      err = s.alertNotify(rule, value, fired)
+    case "webhook":
+      err = s.alertWebhook(action.URL, rule, value, state)
+    case "job":
+      if fired {
+        var params any
+        if len(action.JobParams) > 0 {
+          params = action.JobParams
+        }
+        _, err = s.jobs.submit(config.DefaultNodeID, action.JobKind, "", params)
+      }
+    }
+    if err != nil {
+      s.logger.Printf("alerts: rule %d %s action failed: %v", rule.ID, action.Type, err)
+      errs = append(errs, fmt.Sprintf("%s: %v", action.Type, err))
+    }
+  }
+  return errs
+}
+
+// alertNotify keys the notification by firing, so resolving updates the same
+// row instead of adding another.
+func (s *Server) alertNotify(rule AlertRule, value float64, fired bool) error {
+  if s.notifier == nil {
+    return errors.New("notifications disabled")
+  }
+  status := "FIRING"
+  if !fired {
+    status = "RESOLVED"
+  }
+  var firedAt int64
+  if rule.State.FiredAt != nil {
+    firedAt = rule.State.FiredAt.Unix()
+  }
+  evt := Notification{
+    OccurredAt: time.Now().UTC(),
+    Type: "alert",
+    Action: "alert",
+    Direction: "neutral",
+    Status: status,
+    Memo: fmt.Sprintf("%s: %s (value %s)", rule.Name, rule.describe(), strconv.FormatFloat(value, 'f', -1, 64)),
+  }
+  ctx, cancel := s.taskContext("alerts/notify", timeoutShort)
+  defer cancel()
+  _, err := s.notifier.upsertNotification(ctx, fmt.Sprintf("alert:%d:%d", rule.ID, firedAt), evt)
+  return err
+}
+
+func (s *Server) alertWebhook(target string, rule AlertRule, value float64, state string) error {
+  payload, err := json.Marshal(map[string]any{
+    "rule_id": rule.ID,
+    "name": rule.Name,
+    "condition": rule.describe(),
+    "state": state,
+    "value": value,
+    "fired_at": rule.State.FiredAt,
+    "at": time.Now().UTC(),
+  })
+  if err != nil {
+    return err
+  }
+  ctx, cancel := context.WithTimeout(s.root, alertWebhookTimeout)
+  defer cancel()
+  req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Content-Type", "application/json")
+  req.Header.Set("User-Agent", "lightningos-alerts")
+  resp, err := http.DefaultClient.Do(req)
+  if err != nil {
+    return err
+  }
+  resp.Body.Close()
+  if resp.StatusCode >= 300 {
+    return fmt.Errorf("webhook returned %s", resp.Status)
+  }
+  return nil
+}
+
+func (s *Server) alertsReady(w http.ResponseWriter) bool {
+  if s.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "alerts unavailable: database not configured")
+    return false
+  }
+  return true
+}
+
+func (s *Server) handleAlertMetrics(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, map[string]any{"items": alertMetrics})
+}
+
+func (s *Server) handleAlertRulesList(w http.ResponseWriter, r *http.Request) {
+  if !s.alertsReady(w) {
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  rules, err := s.listAlertRules(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load alert rules: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": rules})
+}
+
+func (s *Server) handleAlertRulesCreate(w http.ResponseWriter, r *http.Request) {
+  if !s.alertsReady(w) {
+    return
+  }
+  var req alertRuleRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  rule, err := parseAlertRule(req)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  actions, _ := json.Marshal(rule.Actions)
+
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  rule.State.Status = alertStatusOK
+  err = s.db.QueryRow(ctx, `
+insert into alert_rules (name, enabled, metric, operator, threshold, for_seconds, notification_type, window_seconds, actions)
+values ($1,$2,$3,$4,$5,$6,$7,$8,$9::jsonb)
+returning id, created_at`, rule.Name, rule.Enabled, rule.Metric, rule.Operator, rule.Threshold, rule.ForSeconds,
+    rule.NotificationType, rule.WindowSeconds, string(actions),
+  ).Scan(&rule.ID, &rule.CreatedAt)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save alert rule: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) handleAlertRulesDelete(w http.ResponseWriter, r *http.Request) {
+  if !s.alertsReady(w) {
+    return
+  }
+  id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+  if err != nil || id <= 0 {
+    writeError(w, http.StatusBadRequest, "invalid rule id")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  tag, err := s.db.Exec(ctx, `delete from alert_rules where id=$1`, id)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to delete alert rule: %v", err))
+    return
+  }
+  if tag.RowsAffected() == 0 {
+    writeError(w, http.StatusNotFound, "rule not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+  rule, err := parseAlertRule(alertRuleRequest{
+    Metric: "channels_inactive",
+    Operator: ">",
+    Threshold: 3,
+    ForSeconds: 3600,
+    WindowSeconds: 60,
+    Actions: []AlertAction{{Type: "Notify", URL: "http://ignored"}},
+  })
+  if err != nil {
+    t.Fatalf("parse: %v", err)
+  }
+  if rule.Name != "channels_inactive > 3 for 1h0m0s" {
+    t.Fatalf("default name %q", rule.Name)
+  }
+  if rule.WindowSeconds != 0 || rule.Actions[0].Type != "notify" || rule.Actions[0].URL != "" {
+    t.Fatalf("rule not normalized: %+v", rule)
+  }
+
+  bad := []alertRuleRequest{
+    {Metric: "cpu", Operator: ">", Actions: []AlertAction{{Type: "notify"}}},
+    {Metric: "channels_active", Operator: "=>", Actions: []AlertAction{{Type: "notify"}}},
+    {Metric: "channels_active", Operator: "<"},
+    {Metric: "notification_count", Operator: ">", Actions: []AlertAction{{Type: "notify"}}},
+    {Metric: "channels_active", Operator: "<", Actions: []AlertAction{{Type: "webhook", URL: "ftp://host"}}},
+    {Metric: "channels_active", Operator: "<", Actions: []AlertAction{{Type: "job", JobKind: jobKindSystemPower}}},
+  }
+  for _, req := range bad {
+    if _, err := parseAlertRule(req); err == nil {
+      t.Errorf("accepted %+v", req)
+    }
+  }
+
+  windowed, err := parseAlertRule(alertRuleRequest{
+    Metric: "notification_count",
+    Operator: ">=",
+    Threshold: 10,
+    NotificationType: "forward",
+    WindowSeconds: 3600,
+    Actions: []AlertAction{{Type: "webhook", URL: "https://hooks.example.com/x"}},
+  })
+  if err != nil {
+    t.Fatalf("parse windowed: %v", err)
+  }
+  if !strings.Contains(windowed.Name, "notification_count(forward, 1h0m0s)") {
+    t.Fatalf("windowed name %q", windowed.Name)
+  }
+}
+
+func TestAdvanceAlert(t *testing.T) {
+  start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  hold := 10 * time.Minute
+  state := AlertState{Status: alertStatusOK}
+
+  state, fired, resolved := advanceAlert(state, true, hold, start)
+  if state.Status != alertStatusPending || fired || resolved {
+    t.Fatalf("expected pending, got %+v", state)
+  }
+  state, fired, _ = advanceAlert(state, true, hold, start.Add(5*time.Minute))
+  if state.Status != alertStatusPending || fired {
+    t.Fatalf("fired before the hold elapsed: %+v", state)
+  }
+  state, fired, _ = advanceAlert(state, true, hold, start.Add(10*time.Minute))
+  if state.Status != alertStatusFiring || !fired || state.FiredAt == nil {
+    t.Fatalf("expected firing, got %+v", state)
+  }
+  state, fired, _ = advanceAlert(state, true, hold, start.Add(20*time.Minute))
+  if fired {
+    t.Fatal("fired twice for one episode")
+  }
+  state, _, resolved = advanceAlert(state, false, hold, start.Add(30*time.Minute))
+  if state.Status != alertStatusOK || !resolved || state.PendingSince != nil {
+    t.Fatalf("expected resolved, got %+v", state)
+  }
+
+  state, fired, _ = advanceAlert(AlertState{Status: alertStatusOK}, true, 0, start)
+  if !fired {
+    t.Fatal("rule without a hold did not fire immediately")
+  }
+}
@@ -36,8 +36,11 @@ type ambossHealthPayload struct {
 }
 
 type AmbossHealthChecker struct {
-  lnd *lndclient.Client
+  lnd lndclient.API
   logger *log.Logger
+  // taskContext bounds a check under the server's root context; without
+  // it (tests) a check gets a plain timeout.
+  taskContext func(name string, class timeoutClass) (context.Context, context.CancelFunc)
 
   mu sync.Mutex
   enabled bool
@@ -53,7 +56,7 @@ type AmbossHealthChecker struct {
   wake chan struct{}
 }
 
-func NewAmbossHealthChecker(lnd *lndclient.Client, logger *log.Logger) *AmbossHealthChecker {
+func NewAmbossHealthChecker(lnd lndclient.API, logger *log.Logger) *AmbossHealthChecker {
   enabled := readAmbossEnabled()
   interval := readAmbossInterval()
   return &AmbossHealthChecker{
@@ -81,7 +84,7 @@ func (a *AmbossHealthChecker) Start() {
 	}
 	a.mu.Unlock()
 
-	go a.run(interval)
+	goSafe("amboss/health", func() { a.run(interval) })
 	if enabled {
 		a.trigger()
 	}
@@ -207,7 +210,13 @@ func (a *AmbossHealthChecker) tick() {
     a.mu.Unlock()
   }()
 
-  ctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
+  var ctx context.Context
+  var cancel context.CancelFunc
+  if a.taskContext != nil {
+    ctx, cancel = a.taskContext("amboss/health", timeoutShort)
+  } else {
+    ctx, cancel = context.WithTimeout(context.Background(), 6*time.Second)
+  }
   err := a.sendHealthCheck(ctx)
   cancel()
 
@@ -0,0 +1,189 @@
+package server
+
+import (
+  "crypto/subtle"
+  "errors"
+  "net/http"
+  "os"
+  "strings"
+  "sync"
+  "time"
+
+  "golang.org/x/crypto/bcrypt"
+)
+
+const (
+  apiAuthUserKey = "API_AUTH_USER"
+  apiAuthHashKey = "API_AUTH_HASH"
+  apiAuthCacheTTL = 30 * time.Second
+  apiAuthMinPassword = 10
+  apiAuthMaxFailures = 10
+  apiAuthFailureWindow = 5 * time.Minute
+)
+
+type apiCredentials struct {
+  user string
+  hash string
+}
+
+// apiAuth caches the credentials from secrets.env and the last verified
+// password so bcrypt does not run on every request.
+var apiAuth struct {
+  mu sync.Mutex
+  creds apiCredentials
+  loadedAt time.Time
+  verified map[string]string
+}
+
+// apiAuthFailures counts failed logins per client IP (behind a trusted proxy,
+// the forwarded client) to slow down password guessing.
+var apiAuthFailures struct {
+  mu sync.Mutex
+  byIP map[string][]time.Time
+}
+
+func recentAuthFailures(ip string, now time.Time) []time.Time {
+  recent := []time.Time{}
+  for _, at := range apiAuthFailures.byIP[ip] {
+    if now.Sub(at) < apiAuthFailureWindow {
+      recent = append(recent, at)
+    }
+  }
+  return recent
+}
+
+func apiAuthLimited(ip string, now time.Time) bool {
+  apiAuthFailures.mu.Lock()
+  defer apiAuthFailures.mu.Unlock()
+  return len(recentAuthFailures(ip, now)) >= apiAuthMaxFailures
+}
+
+func recordAPIAuthResult(ip string, ok bool, now time.Time) {
+  apiAuthFailures.mu.Lock()
+  defer apiAuthFailures.mu.Unlock()
+  if apiAuthFailures.byIP == nil {
+    apiAuthFailures.byIP = map[string][]time.Time{}
+  }
+  if ok {
+    delete(apiAuthFailures.byIP, ip)
+    return
+  }
+  apiAuthFailures.byIP[ip] = append(recentAuthFailures(ip, now), now)
+}
+
+func loadAPICredentials() apiCredentials {
+  apiAuth.mu.Lock()
+  defer apiAuth.mu.Unlock()
+  if time.Since(apiAuth.loadedAt) < apiAuthCacheTTL {
+    return apiAuth.creds
+  }
+  user, _ := readEnvFileValue(secretsPath, apiAuthUserKey)
+  hash, _ := readEnvFileValue(secretsPath, apiAuthHashKey)
+  creds := apiCredentials{user: strings.TrimSpace(user), hash: strings.TrimSpace(hash)}
+  if creds != apiAuth.creds {
+    apiAuth.verified = nil
+  }
+  apiAuth.creds = creds
+  apiAuth.loadedAt = time.Now()
+  return creds
+}
+
+func apiAuthConfigured() bool {
+  creds := loadAPICredentials()
+  return creds.user != "" && creds.hash != ""
+}
+
+func storeAPICredentials(user string, password string) error {
+  user = strings.TrimSpace(user)
+  if user == "" || strings.Contains(user, ":") {
+    return errors.New("username required (no colons)")
+  }
+  if len(password) < apiAuthMinPassword {
+    return errors.New("password must be at least 10 characters")
+  }
+  hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+  if err != nil {
+    return err
+  }
+  if err := ensureSecretsDir(); err != nil {
+    return err
+  }
+  if err := writeEnvFileValue(secretsPath, apiAuthUserKey, user); err != nil {
+    return err
+  }
+  if err := writeEnvFileValue(secretsPath, apiAuthHashKey, string(hash)); err != nil {
+    return err
+  }
+  apiAuth.mu.Lock()
+  apiAuth.loadedAt = time.Time{}
+  apiAuth.mu.Unlock()
+  return nil
+}
+
+func checkAPICredentials(creds apiCredentials, user string, password string) bool {
+  if subtle.ConstantTimeCompare([]byte(user), []byte(creds.user)) != 1 {
+    return false
+  }
+  apiAuth.mu.Lock()
+  cached, ok := apiAuth.verified[user]
+  apiAuth.mu.Unlock()
+  if ok && subtle.ConstantTimeCompare([]byte(cached), []byte(password)) == 1 {
+    return true
+  }
+  if bcrypt.CompareHashAndPassword([]byte(creds.hash), []byte(password)) != nil {
+    return false
+  }
+  apiAuth.mu.Lock()
+  apiAuth.verified = map[string]string{user: password}
+  apiAuth.mu.Unlock()
+  return true
+}
+
+// requireAuth enforces HTTP basic auth (or a manager-issued client cert) on
+// the UI and API once credentials were set (wizard auth step). Without
+// credentials the manager stays open, as it is expected to be reached over
+// LAN or VPN only.
+func (s *Server) requireAuth() func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      // PoS terminals carry a register token instead; see requirePOSToken.
+      if isPOSTerminalPath(r.URL.Path) {
+        next.ServeHTTP(w, r)
+        return
+      }
+      // The public status page is opt-in and answers 404 while off.
+      if isPublicStatusPath(r.URL.Path) {
+        next.ServeHTTP(w, r)
+        return
+      }
+      creds := loadAPICredentials()
+      if creds.user == "" || creds.hash == "" || os.Getenv("API_AUTH_DISABLED") == "1" {
+        next.ServeHTTP(w, r)
+        return
+      }
+      // A client cert issued by the manager is an alternative to the password.
+      if _, ok := clientCertName(r); ok {
+        next.ServeHTTP(w, r)
+        return
+      }
+      ip := clientIP(r)
+      now := time.Now()
+      if apiAuthLimited(ip, now) {
+        writeError(w, http.StatusTooManyRequests, "too many failed logins, try again later")
+        return
+      }
+      user, password, ok := r.BasicAuth()
+      if !ok || !checkAPICredentials(creds, user, password) {
+        if ok {
+          recordAPIAuthResult(ip, false, now)
+          s.recordAuthFailure(ip, user, now)
+        }
+        w.Header().Set("WWW-Authenticate", `Basic realm="LightningOS", charset="UTF-8"`)
+        writeError(w, http.StatusUnauthorized, "authentication required")
+        return
+      }
+      recordAPIAuthResult(ip, true, now)
+      next.ServeHTTP(w, r)
+    })
+  }
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+  "crypto/hmac"
+  "crypto/rand"
+  "crypto/sha1"
+  "crypto/subtle"
+  "encoding/base32"
+  "encoding/binary"
+  "fmt"
+  "net/http"
+  "net/url"
+  "os"
+  "strings"
+  "sync"
+  "time"
+)
+
+// TOTP (RFC 6238: SHA-1, 6 digits, 30s steps) is an optional second factor
+// on top of the password or client cert. Once enabled, sensitive actions
+// (wallet send and pay, channel close, power actions) need a current code
+// in the X-TOTP-Code header. A code works once; after it the same client
+// may run further sensitive actions for totpGrace without a new one. Wrong
+// codes count towards the failed-login limit. API_AUTH_DISABLED=1 turns
+// the check off along with the password, for recovery.
+const (
+  apiTOTPSecretKey = "API_TOTP_SECRET"
+  apiTOTPPendingKey = "API_TOTP_PENDING"
+  totpHeader = "X-TOTP-Code"
+  totpIssuer = "LightningOS"
+  totpStep = 30 * time.Second
+  totpDigits = 6
+  // totpSkew accepts the previous and next code, for clock drift.
+  totpSkew = 1
+  totpGrace = 5 * time.Minute
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// apiTOTP caches the secrets from secrets.env and tracks used steps and
+// the clients inside their grace period.
+var apiTOTP struct {
+  mu sync.Mutex
+  secret string
+  pending string
+  loadedAt time.Time
+  lastStep int64
+  grace map[string]time.Time
+}
+
+func newTOTPSecret() (string, error) {
+  buf := make([]byte, 20)
+  if _, err := rand.Read(buf); err != nil {
+    return "", err
+  }
+  return totpEncoding.EncodeToString(buf), nil
+}
+
+func totpCode(key []byte, step int64) string {
+  var msg [8]byte
+  binary.BigEndian.PutUint64(msg[:], uint64(step))
+  mac := hmac.New(sha1.New, key)
+  mac.Write(msg[:])
+  sum := mac.Sum(nil)
+  offset := sum[len(sum)-1] & 0x0f
+  value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+  return fmt.Sprintf("%06d", value%1_000_000)
+}
+
+func totpStepAt(now time.Time) int64 {
+  return now.Unix() / int64(totpStep/time.Second)
+}
+
+// matchTOTP returns the step a code belongs to, within totpSkew of now.
+func matchTOTP(secret string, code string, now time.Time) (int64, bool) {
+  code = strings.TrimSpace(code)
+  if len(code) != totpDigits {
+    return 0, false
+  }
+  key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+  if err != nil || len(key) == 0 {
+    return 0, false
+  }
+  current := totpStepAt(now)
+  for step := current - totpSkew; step <= current+totpSkew; step++ {
+    if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+      return step, true
+    }
+  }
+  return 0, false
+}
+
+func totpURI(secret string, account string) string {
+  label := url.PathEscape(totpIssuer + ":" + account)
+  query := url.Values{}
+  query.Set("secret", secret)
+  query.Set("issuer", totpIssuer)
+  query.Set("algorithm", "SHA1")
+  query.Set("digits", fmt.Sprintf("%d", totpDigits))
+  query.Set("period", fmt.Sprintf("%d", int(totpStep/time.Second)))
+  return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func loadTOTPSecretsLocked() {
+  if time.Since(apiTOTP.loadedAt) < apiAuthCacheTTL {
+    return
+  }
+  secret, _ := readEnvFileValue(secretsPath, apiTOTPSecretKey)
+  pending, _ := readEnvFileValue(secretsPath, apiTOTPPendingKey)
+  apiTOTP.secret = strings.TrimSpace(secret)
+  apiTOTP.pending = strings.TrimSpace(pending)
+  apiTOTP.loadedAt = time.Now()
+}
+
+func totpEnabled() bool {
+  apiTOTP.mu.Lock()
+  defer apiTOTP.mu.Unlock()
+  loadTOTPSecretsLocked()
+  return apiTOTP.secret != ""
+}
+
+// useTOTP checks a code against the enabled (or, with pending, the
+// enrolling) secret and burns its step so it cannot be replayed.
+func useTOTP(code string, pending bool, now time.Time) bool {
+  apiTOTP.mu.Lock()
+  defer apiTOTP.mu.Unlock()
+  loadTOTPSecretsLocked()
+  secret := apiTOTP.secret
+  if pending {
+    secret = apiTOTP.pending
+  }
+  if secret == "" {
+    return false
+  }
+  step, ok := matchTOTP(secret, code, now)
+  if !ok || step <= apiTOTP.lastStep {
+    return false
+  }
+  apiTOTP.lastStep = step
+  return true
+}
+
+func totpClientKey(r *http.Request) string {
+  user, _, _ := r.BasicAuth()
+  if name, ok := clientCertName(r); ok {
+    user = "cert:" + name
+  }
+  return clientIP(r) + "|" + user
+}
+
+func totpInGrace(key string, now time.Time) bool {
+  apiTOTP.mu.Lock()
+  defer apiTOTP.mu.Unlock()
+  until, ok := apiTOTP.grace[key]
+  if ok && now.After(until) {
+    delete(apiTOTP.grace, key)
+    return false
+  }
+  return ok
+}
+
+func grantTOTPGrace(key string, now time.Time) {
+  apiTOTP.mu.Lock()
+  defer apiTOTP.mu.Unlock()
+  if apiTOTP.grace == nil {
+    apiTOTP.grace = map[string]time.Time{}
+  }
+  apiTOTP.grace[key] = now.Add(totpGrace)
+}
+
+func resetTOTPState() {
+  apiTOTP.mu.Lock()
+  defer apiTOTP.mu.Unlock()
+  apiTOTP.loadedAt = time.Time{}
+  apiTOTP.grace = nil
+}
+
+// requireTOTP guards a sensitive route while TOTP is enabled.
+func (s *Server) requireTOTP(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if os.Getenv("API_AUTH_DISABLED") == "1" || !totpEnabled() {
+      next.ServeHTTP(w, r)
+      return
+    }
+    key := totpClientKey(r)
+    now := time.Now()
+    if totpInGrace(key, now) {
+      next.ServeHTTP(w, r)
+      return
+    }
+    ip := clientIP(r)
+    if apiAuthLimited(ip, now) {
+      writeError(w, http.StatusTooManyRequests, "too many failed logins, try again later")
+      return
+    }
+    code := r.Header.Get(totpHeader)
+    if code == "" {
+      writeError(w, http.StatusForbidden, "totp code required")
+      return
+    }
+    if !useTOTP(code, false, now) {
+      recordAPIAuthResult(ip, false, now)
+      writeError(w, http.StatusForbidden, "invalid totp code")
+      return
+    }
+    grantTOTPGrace(key, now)
+    next.ServeHTTP(w, r)
+  })
+}
+
+func (s *Server) handleTOTPStatus(w http.ResponseWriter, r *http.Request) {
+  apiTOTP.mu.Lock()
+  loadTOTPSecretsLocked()
+  enabled := apiTOTP.secret != ""
+  pending := apiTOTP.pending != ""
+  apiTOTP.mu.Unlock()
+  writeJSON(w, http.StatusOK, map[string]bool{"enabled": enabled, "pending": pending})
+}
+
+// handleTOTPEnroll creates a new pending secret; it replaces any earlier
+// pending one and only takes effect once a code from it is verified.
+func (s *Server) handleTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+  if totpEnabled() {
+    writeError(w, http.StatusConflict, "totp already enabled")
+    return
+  }
+  secret, err := newTOTPSecret()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to create totp secret")
+    return
+  }
+  if err := ensureSecretsDir(); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store totp secret")
+    return
+  }
+  if err := writeEnvFileValue(secretsPath, apiTOTPPendingKey, secret); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store totp secret")
+    return
+  }
+  resetTOTPState()
+  account := loadAPICredentials().user
+  if account == "" {
+    account = "admin"
+  }
+  writeJSON(w, http.StatusOK, map[string]string{
+    "secret": secret,
+    "otpauth_uri": totpURI(secret, account),
+  })
+}
+
+// handleTOTPVerify confirms the enrollment with a code from the pending
+// secret and enables TOTP.
+func (s *Server) handleTOTPVerify(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Code string `json:"code"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  apiTOTP.mu.Lock()
+  loadTOTPSecretsLocked()
+  pending := apiTOTP.pending
+  apiTOTP.mu.Unlock()
+  if pending == "" {
+    writeError(w, http.StatusConflict, "no totp enrollment pending")
+    return
+  }
+  now := time.Now()
+  ip := clientIP(r)
+  if apiAuthLimited(ip, now) {
+    writeError(w, http.StatusTooManyRequests, "too many failed logins, try again later")
+    return
+  }
+  if !useTOTP(req.Code, true, now) {
+    recordAPIAuthResult(ip, false, now)
+    writeError(w, http.StatusBadRequest, "invalid totp code")
+    return
+  }
+  if err := writeEnvFileValue(secretsPath, apiTOTPSecretKey, pending); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store totp secret")
+    return
+  }
+  if err := removeEnvFileValue(secretsPath, apiTOTPPendingKey); err != nil {
+    s.logger.Printf("totp: failed to clear pending secret: %v", err)
+  }
+  resetTOTPState()
+  grantTOTPGrace(totpClientKey(r), now)
+  writeJSON(w, http.StatusOK, map[string]bool{"enabled": true})
+}
+
+// handleTOTPDisable turns TOTP off; it needs a current code, not the grace
+// period.
+func (s *Server) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Code string `json:"code"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if !totpEnabled() {
+    writeError(w, http.StatusConflict, "totp not enabled")
+    return
+  }
+  now := time.Now()
+  ip := clientIP(r)
+  if apiAuthLimited(ip, now) {
+    writeError(w, http.StatusTooManyRequests, "too many failed logins, try again later")
+    return
+  }
+  if !useTOTP(req.Code, false, now) {
+    recordAPIAuthResult(ip, false, now)
+    writeError(w, http.StatusForbidden, "invalid totp code")
+    return
+  }
+  if err := removeEnvFileValue(secretsPath, apiTOTPSecretKey); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to disable totp")
+    return
+  }
+  resetTOTPState()
+  writeJSON(w, http.StatusOK, map[string]bool{"enabled": false})
+}
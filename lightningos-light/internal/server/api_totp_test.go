@@ -0,0 +1,144 @@
+package server
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+  key := []byte("12345678901234567890")
+  cases := map[int64]string{
+    59: "287082",
+    1111111109: "081804",
+    1234567890: "005924",
+    2000000000: "279037",
+  }
+  for unix, want := range cases {
+    if got := totpCode(key, totpStepAt(time.Unix(unix, 0))); got != want {
+      t.Fatalf("code at %d = %s, want %s", unix, got, want)
+    }
+  }
+}
+
+func TestMatchTOTPWindow(t *testing.T) {
+  secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+  now := time.Unix(1111111109, 0)
+  step := totpStepAt(now)
+  if _, ok := matchTOTP(secret, "081804", now); !ok {
+    t.Fatalf("current code rejected")
+  }
+  if got, ok := matchTOTP(secret, "081804", now.Add(totpStep)); !ok || got != step {
+    t.Fatalf("previous step rejected: %d %v", got, ok)
+  }
+  if _, ok := matchTOTP(secret, "081804", now.Add(2*totpStep)); ok {
+    t.Fatalf("code accepted two steps late")
+  }
+  if _, ok := matchTOTP(secret, "81804", now); ok {
+    t.Fatalf("short code accepted")
+  }
+  if uri := totpURI(secret, "admin"); !strings.HasPrefix(uri, "otpauth://totp/LightningOS:admin?") || !strings.Contains(uri, "secret="+secret) {
+    t.Fatalf("unexpected uri %s", uri)
+  }
+}
+
+func enableTestTOTP(t *testing.T) []byte {
+  t.Helper()
+  t.Setenv("API_AUTH_DISABLED", "")
+  key := []byte("12345678901234567890")
+  apiTOTP.mu.Lock()
+  apiTOTP.secret = totpEncoding.EncodeToString(key)
+  apiTOTP.pending = ""
+  apiTOTP.loadedAt = time.Now().Add(time.Hour)
+  apiTOTP.lastStep = 0
+  apiTOTP.grace = nil
+  apiTOTP.mu.Unlock()
+  t.Cleanup(func() {
+    apiTOTP.mu.Lock()
+    apiTOTP.secret = ""
+    apiTOTP.loadedAt = time.Time{}
+    apiTOTP.lastStep = 0
+    apiTOTP.grace = nil
+    apiTOTP.mu.Unlock()
+  })
+  return key
+}
+
+func TestRequireTOTP(t *testing.T) {
+  key := enableTestTOTP(t)
+  s := &Server{}
+  handler := s.requireTOTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusNoContent)
+  }))
+  call := func(remote string, code string) int {
+    req := httptest.NewRequest(http.MethodPost, "/api/wallet/send", nil)
+    req.RemoteAddr = remote
+    if code != "" {
+      req.Header.Set(totpHeader, code)
+    }
+    rec := httptest.NewRecorder()
+    handler.ServeHTTP(rec, req)
+    return rec.Code
+  }
+
+  if got := call("192.0.2.10:1000", ""); got != http.StatusForbidden {
+    t.Fatalf("missing code: status %d", got)
+  }
+  if got := call("192.0.2.10:1000", "000000"); got != http.StatusForbidden {
+    t.Fatalf("wrong code: status %d", got)
+  }
+  code := totpCode(key, totpStepAt(time.Now()))
+  if got := call("192.0.2.10:1000", code); got != http.StatusNoContent {
+    t.Fatalf("valid code: status %d", got)
+  }
+  if got := call("192.0.2.10:1001", ""); got != http.StatusNoContent {
+    t.Fatalf("grace period not applied: status %d", got)
+  }
+  if got := call("192.0.2.11:1000", code); got != http.StatusForbidden {
+    t.Fatalf("replayed code accepted from another client: status %d", got)
+  }
+}
+
+func TestSensitiveRoutesRequireTOTP(t *testing.T) {
+  enableTestTOTP(t)
+  s, _ := newFakeLNDServer(t)
+  for _, route := range []struct{ method, path string }{
+    {http.MethodPost, "/api/payments/scheduled"},
+    {http.MethodPost, "/api/payments/scheduled/1"},
+    {http.MethodPost, "/api/payments/scheduled/1/run"},
+    {http.MethodPost, "/api/payments/allowlist"},
+    {http.MethodDelete, "/api/payments/allowlist/a"},
+    {http.MethodPost, "/api/ln/rebalance"},
+    {http.MethodPost, "/api/lnops/channel/open"},
+    {http.MethodPost, "/api/lnops/channel/reopen"},
+    {http.MethodPost, "/api/lnops/channel/splice"},
+    {http.MethodPost, "/api/lnops/channel/templates/a/open"},
+    {http.MethodPost, "/api/auth/client-certs"},
+    {http.MethodPost, "/api/wizard/auth"},
+    {http.MethodPost, "/api/lnd/config/raw"},
+    {http.MethodPost, "/api/backups/secrets"},
+    {http.MethodPost, "/api/wizard/scb-restore"},
+    {http.MethodPost, "/api/nodes/default/ln/rebalance"},
+    {http.MethodPost, "/api/nodes/default/lnops/channel/open"},
+    {http.MethodPost, "/api/nodes/default/lnops/channel/fees"},
+    {http.MethodPost, "/api/wallet/auto-unlock"},
+    {http.MethodPost, "/api/console/config"},
+    {http.MethodPost, "/api/apps/lndg/reset-admin"},
+    {http.MethodGet, "/api/apps/lndg/admin-password"},
+    {http.MethodPost, "/api/pos/registers"},
+    {http.MethodPost, "/api/pos/registers/a/rotate"},
+    {http.MethodPost, "/api/backups/scb-escrow"},
+    {http.MethodPost, "/api/mqtt"},
+    {http.MethodPost, "/api/lnops/channel/fees"},
+    {http.MethodPost, "/api/lnops/inbound-experiments/start"},
+    {http.MethodPost, "/api/postgres/backup"},
+    {http.MethodPost, "/api/auth/client-certs/ab/revoke"},
+  } {
+    rec := serveAPI(s, route.method, route.path, "{}")
+    if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "totp code required") {
+      t.Errorf("%s %s: status %d: %s", route.method, route.path, rec.Code, rec.Body.String())
+    }
+  }
+}
@@ -8,7 +8,6 @@ import (
   "path/filepath"
   "strings"
 
-  "lightningos-light/internal/system"
 )
 
 type bitcoinCorePaths struct {
@@ -35,6 +34,7 @@ func bitcoincoreDefinition() appDefinition {
     Name: "Bitcoin Core",
     Description: "Run a local Bitcoin Core node with Docker.",
     Port: 0,
+    Probe: &appProbe{Kind: "tcp", Port: 8332},
   }
 }
 
@@ -88,7 +88,7 @@ func bitcoinCoreAppPaths() bitcoinCorePaths {
 }
 
 func (s *Server) installBitcoinCore(ctx context.Context) error {
-  if err := ensureDocker(ctx); err != nil {
+  if err := ensureContainerRuntime(ctx); err != nil {
     return err
   }
   if err := ensureBitcoinCoreImage(ctx); err != nil {
@@ -231,9 +231,8 @@ func syncBitcoinCoreConfig(ctx context.Context, paths bitcoinCorePaths) error {
     return err
   }
   cmd := "if [ ! -f /home/bitcoin/.bitcoin/bitcoin.conf ]; then cp /tmp/bitcoin.conf /home/bitcoin/.bitcoin/bitcoin.conf; chmod 640 /home/bitcoin/.bitcoin/bitcoin.conf; fi"
-  out, err := system.RunCommandWithSudo(
+  out, err := containerCLI(
     ctx,
-    "docker",
     "run",
     "--rm",
     "--entrypoint",
@@ -259,10 +258,10 @@ func syncBitcoinCoreConfig(ctx context.Context, paths bitcoinCorePaths) error {
 }
 
 func ensureBitcoinCoreImage(ctx context.Context) error {
-  if _, err := system.RunCommandWithSudo(ctx, "docker", "image", "inspect", bitcoinCoreImage); err == nil {
+  if _, err := containerCLI(ctx, "image", "inspect", bitcoinCoreImage); err == nil {
     return nil
   }
-  out, err := system.RunCommandWithSudo(ctx, "docker", "pull", bitcoinCoreImage)
+  out, err := containerCLI(ctx, "pull", bitcoinCoreImage)
   if err != nil {
     msg := strings.TrimSpace(out)
     if msg == "" {
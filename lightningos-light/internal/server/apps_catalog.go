@@ -0,0 +1,309 @@
+package server
+
+import (
+  "context"
+  "crypto/ed25519"
+  "encoding/base64"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/config"
+)
+
+// The apps catalog lists the built-in apps together with the apps and
+// versions of a remote registry, so new apps and releases can be announced
+// without a manager release. The registry is a JSON envelope
+// {"payload": base64(JSON), "signature": base64(ed25519 over the payload
+// bytes)}; one that does not verify against apps.catalog_public_key is
+// ignored. The last verified registry is kept on disk for when the URL is
+// unreachable. Apps only the registry knows are listed but cannot be
+// installed until a manager release adds their handler.
+const (
+  appsCatalogCachePath = "/var/lib/lightningos/apps-catalog.json"
+  appsCatalogFetchTimeout = 20 * time.Second
+  appsCatalogMaxBytes = 1 << 20
+  // appsCatalogRetry spaces out fetches after one failed.
+  appsCatalogRetry = 10 * time.Minute
+)
+
+var errAppsCatalogSignature = errors.New("catalog signature does not match")
+
+type appsRegistryEnvelope struct {
+  Payload string `json:"payload"`
+  Signature string `json:"signature"`
+}
+
+type appsRegistry struct {
+  GeneratedAt time.Time `json:"generated_at"`
+  Apps []appsRegistryApp `json:"apps"`
+}
+
+type appsRegistryApp struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  Description string `json:"description"`
+  Homepage string `json:"homepage,omitempty"`
+  // Versions are listed newest first.
+  Versions []appsRegistryVersion `json:"versions"`
+}
+
+type appsRegistryVersion struct {
+  Version string `json:"version"`
+  ReleasedAt *time.Time `json:"released_at,omitempty"`
+  Notes string `json:"notes,omitempty"`
+}
+
+type appCatalogEntry struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  Description string `json:"description"`
+  Homepage string `json:"homepage,omitempty"`
+  Builtin bool `json:"builtin"`
+  Installable bool `json:"installable"`
+  Installed bool `json:"installed"`
+  BundledVersion string `json:"bundled_version,omitempty"`
+  LatestVersion string `json:"latest_version,omitempty"`
+  Versions []appsRegistryVersion `json:"versions"`
+}
+
+// verifyAppsRegistry checks the envelope against the base64 ed25519 key
+// and decodes the payload.
+func verifyAppsRegistry(raw []byte, publicKey string) (appsRegistry, error) {
+  var registry appsRegistry
+  key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+  if err != nil || len(key) != ed25519.PublicKeySize {
+    return registry, errors.New("invalid catalog public key")
+  }
+  var envelope appsRegistryEnvelope
+  if err := json.Unmarshal(raw, &envelope); err != nil {
+    return registry, fmt.Errorf("invalid catalog: %w", err)
+  }
+  payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+  if err != nil {
+    return registry, errors.New("invalid catalog payload")
+  }
+  signature, err := base64.StdEncoding.DecodeString(envelope.Signature)
+  if err != nil || !ed25519.Verify(ed25519.PublicKey(key), payload, signature) {
+    return registry, errAppsCatalogSignature
+  }
+  if err := json.Unmarshal(payload, &registry); err != nil {
+    return registry, fmt.Errorf("invalid catalog payload: %w", err)
+  }
+  for _, app := range registry.Apps {
+    if strings.TrimSpace(app.ID) == "" {
+      return registry, errors.New("catalog app missing id")
+    }
+  }
+  return registry, nil
+}
+
+// mergeAppsCatalog lists the built-in apps first, with the registry's
+// versions and homepage added, then the apps only the registry has. The
+// built-in name and description win: they describe what this manager
+// installs.
+func mergeAppsCatalog(builtin []appInfo, defs []appDefinition, registry *appsRegistry) []appCatalogEntry {
+  remote := map[string]appsRegistryApp{}
+  if registry != nil {
+    for _, app := range registry.Apps {
+      remote[app.ID] = app
+    }
+  }
+  entries := make([]appCatalogEntry, 0, len(builtin))
+  for i, info := range builtin {
+    entry := appCatalogEntry{
+      ID: info.ID,
+      Name: info.Name,
+      Description: info.Description,
+      Builtin: true,
+      Installable: true,
+      Installed: info.Installed,
+      BundledVersion: defs[i].Version,
+      Versions: []appsRegistryVersion{},
+    }
+    if app, ok := remote[info.ID]; ok {
+      entry.Homepage = app.Homepage
+      if len(app.Versions) > 0 {
+        entry.Versions = app.Versions
+      }
+      delete(remote, info.ID)
+    }
+    if len(entry.Versions) > 0 {
+      entry.LatestVersion = entry.Versions[0].Version
+    }
+    entries = append(entries, entry)
+  }
+  if registry == nil {
+    return entries
+  }
+  for _, app := range registry.Apps {
+    if _, ok := remote[app.ID]; !ok {
+      continue
+    }
+    delete(remote, app.ID)
+    entry := appCatalogEntry{
+      ID: app.ID,
+      Name: app.Name,
+      Description: app.Description,
+      Homepage: app.Homepage,
+      Versions: app.Versions,
+    }
+    if entry.Versions == nil {
+      entry.Versions = []appsRegistryVersion{}
+    }
+    if len(entry.Versions) > 0 {
+      entry.LatestVersion = entry.Versions[0].Version
+    }
+    entries = append(entries, entry)
+  }
+  return entries
+}
+
+type appsCatalogCache struct {
+  mu sync.Mutex
+  path string
+  http *http.Client
+  loaded bool
+  registry *appsRegistry
+  fetchedAt *time.Time
+  checkedAt time.Time
+  lastError string
+}
+
+func newAppsCatalogCache(path string) *appsCatalogCache {
+  return &appsCatalogCache{path: path, http: &http.Client{Timeout: appsCatalogFetchTimeout}}
+}
+
+type appsCatalogStatus struct {
+  URL string `json:"url,omitempty"`
+  Configured bool `json:"configured"`
+  GeneratedAt *time.Time `json:"generated_at,omitempty"`
+  FetchedAt *time.Time `json:"fetched_at,omitempty"`
+  Error string `json:"error,omitempty"`
+}
+
+// get returns the registry, fetching it when the cached one is stale or
+// force is set. A failed fetch keeps the previous registry.
+func (c *appsCatalogCache) get(ctx context.Context, cfg config.AppsConfig, force bool) (*appsRegistry, appsCatalogStatus) {
+  status := appsCatalogStatus{URL: strings.TrimSpace(cfg.CatalogURL)}
+  if status.URL == "" {
+    return nil, status
+  }
+  status.Configured = true
+
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  if !c.loaded {
+    c.loaded = true
+    c.loadLocked(cfg.CatalogPublicKey)
+  }
+  wait := cfg.CatalogRefresh()
+  if c.lastError != "" && appsCatalogRetry < wait {
+    wait = appsCatalogRetry
+  }
+  if force || time.Since(c.checkedAt) >= wait {
+    c.checkedAt = time.Now()
+    if err := c.fetchLocked(ctx, status.URL, cfg.CatalogPublicKey); err != nil {
+      c.lastError = err.Error()
+    } else {
+      c.lastError = ""
+    }
+  }
+  status.FetchedAt = c.fetchedAt
+  status.Error = c.lastError
+  if c.registry != nil && !c.registry.GeneratedAt.IsZero() {
+    generatedAt := c.registry.GeneratedAt
+    status.GeneratedAt = &generatedAt
+  }
+  return c.registry, status
+}
+
+// loadLocked reads the last verified registry, checking it again in case
+// the key changed since.
+func (c *appsCatalogCache) loadLocked(publicKey string) {
+  raw, err := os.ReadFile(c.path)
+  if err != nil {
+    return
+  }
+  registry, err := verifyAppsRegistry(raw, publicKey)
+  if err != nil {
+    return
+  }
+  c.registry = &registry
+  if info, err := os.Stat(c.path); err == nil {
+    modTime := info.ModTime().UTC()
+    c.fetchedAt = &modTime
+    c.checkedAt = modTime
+  }
+}
+
+func (c *appsCatalogCache) fetchLocked(ctx context.Context, url string, publicKey string) error {
+  ctx, cancel := context.WithTimeout(ctx, appsCatalogFetchTimeout)
+  defer cancel()
+  req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+  if err != nil {
+    return err
+  }
+  resp, err := c.http.Do(req)
+  if err != nil {
+    return err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return fmt.Errorf("catalog fetch failed: http status %d", resp.StatusCode)
+  }
+  raw, err := io.ReadAll(io.LimitReader(resp.Body, appsCatalogMaxBytes+1))
+  if err != nil {
+    return err
+  }
+  if len(raw) > appsCatalogMaxBytes {
+    return errors.New("catalog too large")
+  }
+  registry, err := verifyAppsRegistry(raw, publicKey)
+  if err != nil {
+    return err
+  }
+  now := time.Now().UTC()
+  c.registry = &registry
+  c.fetchedAt = &now
+  if err := os.MkdirAll(filepath.Dir(c.path), 0o750); err == nil {
+    _ = writeFileAtomic(c.path, raw, 0o640)
+  }
+  return nil
+}
+
+// handleAppsCatalog serves GET /api/apps/catalog; ?refresh=1 fetches the
+// registry even when the cached one is fresh.
+func (s *Server) handleAppsCatalog(w http.ResponseWriter, r *http.Request) {
+  apps, err := s.appRegistry()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+  infos := make([]appInfo, 0, len(apps))
+  defs := make([]appDefinition, 0, len(apps))
+  for _, app := range apps {
+    def := app.Definition()
+    info, infoErr := app.Info(r.Context())
+    if infoErr != nil && info.ID == "" {
+      info = newAppInfo(def)
+    }
+    infos = append(infos, info)
+    defs = append(defs, def)
+  }
+  registry, status := s.appsCatalog.get(r.Context(), s.cfg.Apps, r.URL.Query().Get("refresh") == "1")
+  if status.Error != "" {
+    status.Error = localize(w, status.Error)
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "apps": mergeAppsCatalog(infos, defs, registry),
+    "registry": status,
+  })
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+  "context"
+  "crypto/ed25519"
+  "encoding/base64"
+  "encoding/json"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "testing"
+
+  "lightningos-light/internal/config"
+)
+
+func signedAppsRegistry(t *testing.T, priv ed25519.PrivateKey, registry appsRegistry) []byte {
+  t.Helper()
+  payload, err := json.Marshal(registry)
+  if err != nil {
+    t.Fatal(err)
+  }
+  raw, err := json.Marshal(appsRegistryEnvelope{
+    Payload: base64.StdEncoding.EncodeToString(payload),
+    Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, payload)),
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  return raw
+}
+
+func TestVerifyAppsRegistry(t *testing.T) {
+  pub, priv, _ := ed25519.GenerateKey(nil)
+  otherPub, _, _ := ed25519.GenerateKey(nil)
+  key := base64.StdEncoding.EncodeToString(pub)
+  raw := signedAppsRegistry(t, priv, appsRegistry{Apps: []appsRegistryApp{{ID: "rtl", Name: "RTL"}}})
+
+  registry, err := verifyAppsRegistry(raw, key)
+  if err != nil || len(registry.Apps) != 1 || registry.Apps[0].ID != "rtl" {
+    t.Fatalf("verify: %+v %v", registry, err)
+  }
+  if _, err := verifyAppsRegistry(raw, base64.StdEncoding.EncodeToString(otherPub)); !errors.Is(err, errAppsCatalogSignature) {
+    t.Fatalf("expected signature error with another key, got %v", err)
+  }
+
+  var envelope appsRegistryEnvelope
+  _ = json.Unmarshal(raw, &envelope)
+  envelope.Payload = base64.StdEncoding.EncodeToString([]byte(`{"apps":[{"id":"evil"}]}`))
+  tampered, _ := json.Marshal(envelope)
+  if _, err := verifyAppsRegistry(tampered, key); !errors.Is(err, errAppsCatalogSignature) {
+    t.Fatalf("expected signature error on a tampered payload, got %v", err)
+  }
+  if _, err := verifyAppsRegistry(raw, "not-a-key"); err == nil {
+    t.Fatalf("expected error for an invalid key")
+  }
+}
+
+func TestMergeAppsCatalog(t *testing.T) {
+  defs := []appDefinition{
+    {ID: "lndg", Name: "LNDg", Description: "built-in"},
+    {ID: "elements", Name: "Elements", Version: "23.3.1"},
+  }
+  infos := []appInfo{newAppInfo(defs[0]), newAppInfo(defs[1])}
+  infos[0].Installed = true
+  registry := &appsRegistry{Apps: []appsRegistryApp{
+    {ID: "rtl", Name: "RTL", Description: "remote only", Versions: []appsRegistryVersion{{Version: "0.15.2"}, {Version: "0.15.1"}}},
+    {ID: "lndg", Name: "Renamed", Description: "remote", Homepage: "https://example.com", Versions: []appsRegistryVersion{{Version: "1.10.0"}}},
+  }}
+
+  entries := mergeAppsCatalog(infos, defs, registry)
+  if len(entries) != 3 {
+    t.Fatalf("expected 3 entries, got %d", len(entries))
+  }
+  lndg := entries[0]
+  if lndg.Name != "LNDg" || lndg.Description != "built-in" || !lndg.Installed || !lndg.Installable || lndg.LatestVersion != "1.10.0" || lndg.Homepage == "" {
+    t.Fatalf("lndg entry: %+v", lndg)
+  }
+  if elements := entries[1]; elements.BundledVersion != "23.3.1" || elements.LatestVersion != "" || elements.Versions == nil {
+    t.Fatalf("elements entry: %+v", elements)
+  }
+  if rtl := entries[2]; rtl.ID != "rtl" || rtl.Builtin || rtl.Installable || rtl.LatestVersion != "0.15.2" {
+    t.Fatalf("rtl entry: %+v", rtl)
+  }
+  if got := mergeAppsCatalog(infos, defs, nil); len(got) != 2 {
+    t.Fatalf("expected built-in apps only, got %d", len(got))
+  }
+}
+
+func TestAppsCatalogCacheKeepsLastGoodRegistry(t *testing.T) {
+  pub, priv, _ := ed25519.GenerateKey(nil)
+  raw := signedAppsRegistry(t, priv, appsRegistry{Apps: []appsRegistryApp{{ID: "rtl", Name: "RTL"}}})
+  broken := false
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if broken {
+      w.Write([]byte(`{"payload":"e30=","signature":"AAAA"}`))
+      return
+    }
+    w.Write(raw)
+  }))
+  defer srv.Close()
+
+  cfg := config.AppsConfig{CatalogURL: srv.URL, CatalogPublicKey: base64.StdEncoding.EncodeToString(pub)}
+  cache := newAppsCatalogCache(filepath.Join(t.TempDir(), "apps-catalog.json"))
+  registry, status := cache.get(context.Background(), cfg, false)
+  if registry == nil || status.Error != "" || status.FetchedAt == nil {
+    t.Fatalf("first fetch: %+v %+v", registry, status)
+  }
+
+  broken = true
+  registry, status = cache.get(context.Background(), cfg, true)
+  if registry == nil || len(registry.Apps) != 1 || status.Error == "" {
+    t.Fatalf("bad signature must keep the previous registry: %+v %+v", registry, status)
+  }
+
+  // A fresh cache starts from the copy on disk without fetching.
+  reloaded := newAppsCatalogCache(cache.path)
+  registry, status = reloaded.get(context.Background(), cfg, false)
+  if registry == nil || status.Error != "" {
+    t.Fatalf("expected the registry from disk: %+v %+v", registry, status)
+  }
+}
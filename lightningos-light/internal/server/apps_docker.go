@@ -16,25 +16,27 @@ import (
   "lightningos-light/internal/system"
 )
 
-func ensureDocker(ctx context.Context) error {
+type dockerRuntime struct{}
+
+func (dockerRuntime) Name() string { return containerRuntimeDocker }
+
+func (dockerRuntime) CLI() string { return "docker" }
+
+func (dockerRuntime) DefaultNetwork() string { return "bridge" }
+
+func (d dockerRuntime) Ensure(ctx context.Context) error {
   if _, err := exec.LookPath("docker"); err == nil {
-    if _, infoErr := system.RunCommandWithSudo(ctx, "docker", "info"); infoErr == nil {
-      if err := ensureCompose(ctx); err != nil {
-        return err
-      }
-      return nil
+    if _, infoErr := runContainerCommand(ctx, "docker", "info"); infoErr == nil {
+      return d.ensureCompose(ctx)
     }
     if _, startErr := system.RunCommandWithSudo(ctx, "systemctl", "enable", "--now", "docker"); startErr == nil || isDockerActive(ctx) {
-      if err := ensureCompose(ctx); err != nil {
-        return err
-      }
-      return nil
+      return d.ensureCompose(ctx)
     }
   }
   if err := installDocker(ctx); err != nil {
     return err
   }
-  return ensureCompose(ctx)
+  return d.ensureCompose(ctx)
 }
 
 func installDocker(ctx context.Context) error {
@@ -62,24 +64,24 @@ func isDockerActive(ctx context.Context) bool {
   return strings.TrimSpace(out) == "active"
 }
 
-func ensureCompose(ctx context.Context) error {
-  if _, _, err := resolveCompose(ctx); err == nil {
+func (d dockerRuntime) ensureCompose(ctx context.Context) error {
+  if _, _, err := d.resolveCompose(ctx); err == nil {
     return nil
   }
   _, err := runApt(ctx, "install", "-y", "docker-compose-plugin")
-  if err != nil && strings.Contains(err.Error(), "passwordless sudo") {
+  if isSudoDenied(err) {
     return err
   }
   _, err = runApt(ctx, "install", "-y", "docker-compose")
-  if err != nil && strings.Contains(err.Error(), "passwordless sudo") {
+  if isSudoDenied(err) {
     return err
   }
   if err := installComposePluginBinary(ctx); err != nil {
-    if strings.Contains(err.Error(), "passwordless sudo") {
+    if isSudoDenied(err) {
       return err
     }
   }
-  if _, _, err := resolveCompose(ctx); err != nil {
+  if _, _, err := d.resolveCompose(ctx); err != nil {
     return err
   }
   return nil
@@ -93,8 +95,8 @@ func runApt(ctx context.Context, args ...string) (string, error) {
     if err == nil {
       return out, nil
     }
-    if strings.Contains(out, "password is required") {
-      return out, errors.New("docker install needs passwordless sudo for lightningos (re-run install.sh or add /etc/sudoers.d/lightningos)")
+    if isSudoDenied(err) {
+      return out, err
     }
     if strings.Contains(out, "Could not get lock") || strings.Contains(out, "dpkg frontend lock") || strings.Contains(out, "dpkg/lock") {
       time.Sleep(3 * time.Second)
@@ -112,7 +114,7 @@ func runAptOnce(ctx context.Context, args ...string) (string, error) {
   if err == nil {
     return out, nil
   }
-  if strings.Contains(out, "password is required") {
+  if isSudoDenied(err) {
     return out, err
   }
   fallbackOut, fallbackErr := system.RunCommandWithSudo(ctx, "apt-get", args...)
@@ -125,80 +127,82 @@ func runAptOnce(ctx context.Context, args ...string) (string, error) {
   return fallbackOut, fallbackErr
 }
 
-func composeBaseArgs(appRoot string, composePath string) []string {
+func (d dockerRuntime) Compose(ctx context.Context, appRoot string, composePath string) (string, []string, error) {
+  cmd, baseArgs, err := d.resolveCompose(ctx)
+  if err != nil {
+    return "", nil, err
+  }
   envPath := filepath.Join(appRoot, ".env")
-  args := []string{}
   if fileExists(envPath) {
-    args = append(args, "--env-file", envPath)
+    baseArgs = append(baseArgs, "--env-file", envPath)
   }
-  args = append(args, "--project-directory", appRoot, "-f", composePath)
-  return args
+  baseArgs = append(baseArgs, "--project-directory", appRoot, "-f", composePath)
+  return cmd, baseArgs, nil
 }
 
-func runCompose(ctx context.Context, appRoot string, composePath string, args ...string) error {
-  cmd, baseArgs, err := resolveCompose(ctx)
+func (d dockerRuntime) ServiceRunning(ctx context.Context, appRoot string, composePath string, service string) (bool, error) {
+  cmd, baseArgs, err := d.Compose(ctx, appRoot, composePath)
   if err != nil {
-    return err
-  }
-  fullArgs := append(baseArgs, composeBaseArgs(appRoot, composePath)...)
-  fullArgs = append(fullArgs, args...)
-  if _, err := system.RunCommandWithSudo(ctx, cmd, fullArgs...); err != nil {
-    return err
+    return false, err
   }
-  return nil
-}
-
-func getComposeStatus(ctx context.Context, appRoot string, composePath string, service string) (string, error) {
-  cmd, baseArgs, err := resolveCompose(ctx)
+  out, err := runContainerCommand(ctx, cmd, append(baseArgs, "ps", "--services", "--filter", "status=running")...)
   if err != nil {
-    return "unknown", err
-  }
-  fullArgs := append(baseArgs, composeBaseArgs(appRoot, composePath)...)
-  fullArgs = append(fullArgs, "ps", "--services", "--filter", "status=running")
-  out, err := system.RunCommandWithSudo(ctx, cmd, fullArgs...)
-  if err != nil {
-    return "unknown", err
+    return false, err
   }
   for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
     if strings.TrimSpace(line) == service {
-      return "running", nil
+      return true, nil
     }
   }
-  return "stopped", nil
+  return false, nil
 }
 
-func composeContainerID(ctx context.Context, appRoot string, composePath string, service string) (string, error) {
-  cmd, baseArgs, err := resolveCompose(ctx)
+func (d dockerRuntime) ContainerID(ctx context.Context, appRoot string, composePath string, service string) (string, error) {
+  cmd, baseArgs, err := d.Compose(ctx, appRoot, composePath)
   if err != nil {
     return "", err
   }
-  fullArgs := append(baseArgs, composeBaseArgs(appRoot, composePath)...)
-  fullArgs = append(fullArgs, "ps", "-q", service)
-  out, err := system.RunCommandWithSudo(ctx, cmd, fullArgs...)
+  out, err := runContainerCommand(ctx, cmd, append(baseArgs, "ps", "-q", service)...)
   if err != nil {
     return "", err
   }
   return strings.TrimSpace(out), nil
 }
 
+func (dockerRuntime) NetworkGateway(ctx context.Context, network string) (string, error) {
+  return inspectNetwork(ctx, "docker", network, "{{(index .IPAM.Config 0).Gateway}}")
+}
+
+// NetworkInterface is docker0 for the default bridge and br-<id> for the
+// networks compose creates.
+func (dockerRuntime) NetworkInterface(ctx context.Context, network string) (string, error) {
+  if network == "bridge" {
+    return "docker0", nil
+  }
+  id, err := inspectNetwork(ctx, "docker", network, "{{.Id}}")
+  if err != nil {
+    return "", err
+  }
+  if len(id) > 12 {
+    id = id[:12]
+  }
+  return "br-" + id, nil
+}
+
 type composeRelease struct {
   TagName string `json:"tag_name"`
 }
 
-func resolveCompose(ctx context.Context) (string, []string, error) {
-  out, err := system.RunCommandWithSudo(ctx, "docker", "compose", "version")
-  if err == nil {
+func (dockerRuntime) resolveCompose(ctx context.Context) (string, []string, error) {
+  if _, err := runContainerCommand(ctx, "docker", "compose", "version"); err == nil {
     return "docker", []string{"compose"}, nil
+  } else if isSudoDenied(err) {
+    return "", nil, err
   }
-  if strings.Contains(out, "password is required") || strings.Contains(err.Error(), "password is required") {
-    return "", nil, errors.New("docker compose requires passwordless sudo for lightningos")
-  }
-  out, err = system.RunCommandWithSudo(ctx, "docker-compose", "version")
-  if err == nil {
+  if _, err := runContainerCommand(ctx, "docker-compose", "version"); err == nil {
     return "docker-compose", []string{}, nil
-  }
-  if strings.Contains(out, "password is required") || strings.Contains(err.Error(), "password is required") {
-    return "", nil, errors.New("docker-compose requires passwordless sudo for lightningos")
+  } else if isSudoDenied(err) {
+    return "", nil, err
   }
   return "", nil, errors.New("docker compose not available (install docker-compose-plugin or docker-compose)")
 }
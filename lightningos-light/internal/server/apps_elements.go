@@ -65,6 +65,7 @@ func elementsDefinition() appDefinition {
     Name: "Elements",
     Description: "Run a Liquid Elements node (native binary).",
     Port: 0,
+    Version: elementsVersion,
   }
 }
 
@@ -1,9 +1,13 @@
 package server
 
 import (
+  "context"
+  "errors"
   "net/http"
 
   "github.com/go-chi/chi/v5"
+
+  "lightningos-light/internal/config"
 )
 
 func (s *Server) handleAppsList(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +27,10 @@ func (s *Server) handleAppsList(w http.ResponseWriter, r *http.Request) {
         info.Status = "unknown"
       }
     }
+    if info.Installed {
+      health := s.appHealth.get(info.ID)
+      info.Health = &health
+    }
     resp = append(resp, info)
   }
   writeJSON(w, http.StatusOK, resp)
@@ -43,11 +51,12 @@ func (s *Server) handleAppInstall(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusNotFound, "app not found")
     return
   }
-  if err := app.Install(r.Context()); err != nil {
-    writeError(w, http.StatusInternalServerError, err.Error())
+  job, err := s.jobs.submit(config.DefaultNodeID, jobKindAppInstall, appID, appJobParams{AppID: appID})
+  if err != nil {
+    writeJobSubmitError(w, err)
     return
   }
-  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+  writeJobStarted(w, job)
 }
 
 func (s *Server) handleAppUninstall(w http.ResponseWriter, r *http.Request) {
@@ -65,11 +74,12 @@ func (s *Server) handleAppUninstall(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusNotFound, "app not found")
     return
   }
-  if err := app.Uninstall(r.Context()); err != nil {
-    writeError(w, http.StatusInternalServerError, err.Error())
+  job, err := s.jobs.submit(config.DefaultNodeID, jobKindAppUninstall, appID, appJobParams{AppID: appID})
+  if err != nil {
+    writeJobSubmitError(w, err)
     return
   }
-  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+  writeJobStarted(w, job)
 }
 
 func (s *Server) handleAppStart(w http.ResponseWriter, r *http.Request) {
@@ -91,6 +101,7 @@ func (s *Server) handleAppStart(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusInternalServerError, err.Error())
     return
   }
+  s.setAppStoppedByUser(appID, false)
   writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
@@ -109,6 +120,8 @@ func (s *Server) handleAppStop(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusNotFound, "app not found")
     return
   }
+  // Marked before stopping, so the monitor does not restart it meanwhile.
+  s.setAppStoppedByUser(appID, true)
   if err := app.Stop(r.Context()); err != nil {
     writeError(w, http.StatusInternalServerError, err.Error())
     return
@@ -156,3 +169,33 @@ func (s *Server) handleAppAdminPassword(w http.ResponseWriter, r *http.Request)
 
   writeJSON(w, http.StatusOK, map[string]string{"password": password})
 }
+
+type appJobParams struct {
+  AppID string `json:"app_id"`
+}
+
+func (s *Server) runAppJob(ctx context.Context, job *jobHandle, install bool) (any, error) {
+  var params appJobParams
+  if err := job.Params(&params); err != nil {
+    return nil, err
+  }
+  app, err := s.appByID(params.AppID)
+  if err != nil {
+    return nil, err
+  }
+  if app == nil {
+    return nil, errors.New("app not found")
+  }
+  if install {
+    err = app.Install(ctx)
+    if err == nil {
+      s.setAppStoppedByUser(params.AppID, false)
+    }
+  } else {
+    err = app.Uninstall(ctx)
+  }
+  if err != nil {
+    return nil, err
+  }
+  return map[string]any{"ok": true, "app_id": params.AppID}, nil
+}
@@ -0,0 +1,421 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "net"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sync"
+  "time"
+
+  "github.com/go-chi/chi/v5"
+)
+
+// The health monitor probes every installed app and, for apps whose
+// restart policy allows it, restarts the ones that crashed or stopped
+// answering. Restarts back off exponentially and stop after max_retries
+// until the app has stayed healthy for a few backoff periods. An app the user stopped stays
+// stopped: handleAppStop marks it, and Start or Install clears the mark.
+const (
+  appHealthStatePath = "/var/lib/lightningos/app-health.json"
+  appHealthPoll = 30 * time.Second
+  appProbeTimeout = 5 * time.Second
+  // A running app must fail this many probes in a row before it counts as
+  // hung, so one slow answer does not restart it.
+  appProbeFailures = 2
+  appRestartDefaultRetries = 5
+  appRestartDefaultBackoffSec = 30
+  appRestartMaxRetries = 20
+  appRestartMaxBackoff = 30 * time.Minute
+  // An app must stay healthy this many backoff periods before its restarts
+  // are forgiven, so one that comes up and crashes again keeps backing off.
+  appHealthStableBackoffs = 3
+)
+
+type appProbe struct {
+  Kind string
+  Port int
+  Path string
+}
+
+type appRestartPolicy struct {
+  AutoRestart bool `json:"auto_restart"`
+  MaxRetries int `json:"max_retries"`
+  BackoffSec int `json:"backoff_sec"`
+}
+
+func (p appRestartPolicy) withDefaults() appRestartPolicy {
+  if p.MaxRetries <= 0 {
+    p.MaxRetries = appRestartDefaultRetries
+  }
+  if p.BackoffSec <= 0 {
+    p.BackoffSec = appRestartDefaultBackoffSec
+  }
+  return p
+}
+
+// backoff is the wait after the nth restart: the base doubles each time, up
+// to appRestartMaxBackoff.
+func (p appRestartPolicy) backoff(n int) time.Duration {
+  wait := time.Duration(p.BackoffSec) * time.Second
+  for i := 1; i < n && wait < appRestartMaxBackoff; i++ {
+    wait *= 2
+  }
+  if wait > appRestartMaxBackoff {
+    wait = appRestartMaxBackoff
+  }
+  return wait
+}
+
+type appHealth struct {
+  // State is healthy, unhealthy, stopped or unknown.
+  State string `json:"state"`
+  CheckedAt *time.Time `json:"checked_at,omitempty"`
+  LastOKAt *time.Time `json:"last_ok_at,omitempty"`
+  // HealthySince is the start of the current run of healthy probes.
+  HealthySince *time.Time `json:"healthy_since,omitempty"`
+  Failures int `json:"failures"`
+  LastError string `json:"last_error,omitempty"`
+  // Restarts counts the restarts since the app was last stable.
+  Restarts int `json:"restarts"`
+  LastRestartAt *time.Time `json:"last_restart_at,omitempty"`
+  NextRestartAt *time.Time `json:"next_restart_at,omitempty"`
+  GaveUp bool `json:"gave_up"`
+  StoppedByUser bool `json:"stopped_by_user"`
+  Policy appRestartPolicy `json:"policy"`
+}
+
+// nextAppHealth folds one check into the state and reports whether the app
+// should be restarted now. status is the app's Info status and probeErr the
+// probe result, which only matters while the app runs.
+func nextAppHealth(prev appHealth, status string, probeErr error, now time.Time) (appHealth, bool) {
+  h := prev
+  h.Policy = h.Policy.withDefaults()
+  h.CheckedAt = &now
+  switch status {
+  case "running":
+    if probeErr == nil {
+      if h.State != "healthy" || h.HealthySince == nil {
+        h.HealthySince = &now
+      }
+      h.State = "healthy"
+      h.LastOKAt = &now
+      h.Failures = 0
+      h.LastError = ""
+      stable := time.Duration(appHealthStableBackoffs) * h.Policy.backoff(h.Restarts)
+      if h.Restarts > 0 && now.Sub(*h.HealthySince) >= stable {
+        h.Restarts = 0
+        h.NextRestartAt = nil
+        h.GaveUp = false
+      }
+      return h, false
+    }
+    h.HealthySince = nil
+    h.State = "unhealthy"
+    h.Failures++
+    h.LastError = probeErr.Error()
+    if h.Failures < appProbeFailures {
+      return h, false
+    }
+  case "stopped":
+    h.HealthySince = nil
+    h.State = "stopped"
+    if h.StoppedByUser {
+      return h, false
+    }
+    h.Failures++
+    h.LastError = "app is not running"
+  default:
+    h.State = "unknown"
+    return h, false
+  }
+
+  if !h.Policy.AutoRestart || h.GaveUp {
+    return h, false
+  }
+  if h.Restarts >= h.Policy.MaxRetries {
+    h.GaveUp = true
+    h.NextRestartAt = nil
+    return h, false
+  }
+  if h.NextRestartAt != nil && now.Before(*h.NextRestartAt) {
+    return h, false
+  }
+  h.Restarts++
+  h.LastRestartAt = &now
+  next := now.Add(h.Policy.backoff(h.Restarts))
+  h.NextRestartAt = &next
+  return h, true
+}
+
+// probeApp checks that the app answers on its port. Any HTTP answer below
+// 500 counts, redirects and login pages included.
+func probeApp(ctx context.Context, probe appProbe) error {
+  addr := net.JoinHostPort("127.0.0.1", fmt.Sprintf("%d", probe.Port))
+  ctx, cancel := context.WithTimeout(ctx, appProbeTimeout)
+  defer cancel()
+  if probe.Kind != "http" {
+    var dialer net.Dialer
+    conn, err := dialer.DialContext(ctx, "tcp", addr)
+    if err != nil {
+      return err
+    }
+    return conn.Close()
+  }
+  req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+probe.Path, nil)
+  if err != nil {
+    return err
+  }
+  client := &http.Client{
+    CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+  }
+  resp, err := client.Do(req)
+  if err != nil {
+    return err
+  }
+  resp.Body.Close()
+  if resp.StatusCode >= http.StatusInternalServerError {
+    return fmt.Errorf("http status %d", resp.StatusCode)
+  }
+  return nil
+}
+
+// appHealthRecord is what survives a restart of the manager: the policy
+// and whether the user stopped the app.
+type appHealthRecord struct {
+  Policy appRestartPolicy `json:"policy"`
+  StoppedByUser bool `json:"stopped_by_user"`
+}
+
+type appHealthTracker struct {
+  mu sync.Mutex
+  path string
+  loaded bool
+  apps map[string]appHealth
+}
+
+func newAppHealthTracker(path string) *appHealthTracker {
+  return &appHealthTracker{path: path, apps: map[string]appHealth{}}
+}
+
+func (t *appHealthTracker) loadLocked() {
+  if t.loaded {
+    return
+  }
+  t.loaded = true
+  raw, err := os.ReadFile(t.path)
+  if err != nil {
+    return
+  }
+  records := map[string]appHealthRecord{}
+  if json.Unmarshal(raw, &records) != nil {
+    return
+  }
+  for id, record := range records {
+    t.apps[id] = appHealth{State: "unknown", Policy: record.Policy.withDefaults(), StoppedByUser: record.StoppedByUser}
+  }
+}
+
+func (t *appHealthTracker) saveLocked() error {
+  records := map[string]appHealthRecord{}
+  for id, h := range t.apps {
+    records[id] = appHealthRecord{Policy: h.Policy, StoppedByUser: h.StoppedByUser}
+  }
+  if err := os.MkdirAll(filepath.Dir(t.path), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.Marshal(records)
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(t.path, raw, 0o640)
+}
+
+func (t *appHealthTracker) get(id string) appHealth {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  t.loadLocked()
+  h, ok := t.apps[id]
+  if !ok {
+    h = appHealth{State: "unknown"}
+  }
+  h.Policy = h.Policy.withDefaults()
+  return h
+}
+
+// update runs fn on the app's state and stores the result; persist also
+// writes the policy file.
+func (t *appHealthTracker) update(id string, persist bool, fn func(h appHealth) appHealth) (appHealth, error) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  t.loadLocked()
+  h, ok := t.apps[id]
+  if !ok {
+    h = appHealth{State: "unknown"}
+  }
+  h = fn(h)
+  t.apps[id] = h
+  if !persist {
+    return h, nil
+  }
+  return h, t.saveLocked()
+}
+
+// setAppStoppedByUser records a start or stop from the UI. A start also gives
+// a gave-up app a fresh set of retries.
+func (s *Server) setAppStoppedByUser(id string, stopped bool) {
+  _, err := s.appHealth.update(id, true, func(h appHealth) appHealth {
+    h.StoppedByUser = stopped
+    if !stopped {
+      h.Restarts = 0
+      h.GaveUp = false
+      h.NextRestartAt = nil
+    }
+    return h
+  })
+  if err != nil {
+    s.logger.Printf("apps health: failed to store state: %v", err)
+  }
+}
+
+func (s *Server) initAppHealth() {
+  goSafe("apps/health", s.runAppHealth)
+}
+
+func (s *Server) runAppHealth() {
+  for {
+    if !s.wait(appHealthPoll) {
+      return
+    }
+    apps, err := s.appRegistry()
+    if err != nil {
+      continue
+    }
+    busy, powering := s.appJobsBusy()
+    if powering {
+      continue
+    }
+    for _, app := range apps {
+      id := app.Definition().ID
+      if busy[id] {
+        continue
+      }
+      s.checkAppHealth(app)
+    }
+  }
+}
+
+// appJobsBusy lists the apps with an install or uninstall job running, and
+// reports a running power job, whose app stops are not crashes.
+func (s *Server) appJobsBusy() (map[string]bool, bool) {
+  busy := map[string]bool{}
+  for _, job := range s.jobs.running() {
+    switch job.Kind {
+    case jobKindSystemPower:
+      return busy, true
+    case jobKindAppInstall, jobKindAppUninstall:
+      busy[job.Key] = true
+    }
+  }
+  return busy, false
+}
+
+func (s *Server) checkAppHealth(app appHandler) {
+  def := app.Definition()
+  ctx, cancel := s.taskContext("apps/health", timeoutMedium)
+  info, err := app.Info(ctx)
+  status := info.Status
+  if err != nil {
+    status = "unknown"
+  }
+  if !info.Installed {
+    cancel()
+    return
+  }
+  var probeErr error
+  if status == "running" && def.Probe != nil {
+    probeErr = probeApp(ctx, *def.Probe)
+  }
+  cancel()
+
+  restart := false
+  gaveUp := false
+  now := time.Now().UTC()
+  h, _ := s.appHealth.update(def.ID, false, func(prev appHealth) appHealth {
+    h, ok := nextAppHealth(prev, status, probeErr, now)
+    restart = ok
+    gaveUp = h.GaveUp && !prev.GaveUp
+    return h
+  })
+  if gaveUp {
+    s.logger.Printf("apps health: %s still down after %d restarts, giving up", def.ID, h.Restarts)
+  }
+  if !restart {
+    return
+  }
+
+  s.logger.Printf("apps health: restarting %s (attempt %d/%d): %s", def.ID, h.Restarts, h.Policy.MaxRetries, h.LastError)
+  restartCtx, restartCancel := s.taskContext("apps/restart", timeoutLong)
+  defer restartCancel()
+  if err := restartApp(restartCtx, app, status); err != nil {
+    s.logger.Printf("apps health: failed to restart %s: %v", def.ID, err)
+    s.appHealth.update(def.ID, false, func(h appHealth) appHealth {
+      h.LastError = "restart failed: " + err.Error()
+      return h
+    })
+  }
+}
+
+func restartApp(ctx context.Context, app appHandler, status string) error {
+  if status == "running" {
+    if err := app.Stop(ctx); err != nil {
+      return err
+    }
+  }
+  return app.Start(ctx)
+}
+
+func (s *Server) handleAppRestartPolicy(w http.ResponseWriter, r *http.Request) {
+  appID := chi.URLParam(r, "id")
+  if appID == "" {
+    writeError(w, http.StatusBadRequest, "missing app id")
+    return
+  }
+  app, err := s.appByID(appID)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+  if app == nil {
+    writeError(w, http.StatusNotFound, "app not found")
+    return
+  }
+  var req appRestartPolicy
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if req.MaxRetries < 0 || req.MaxRetries > appRestartMaxRetries {
+    writeError(w, http.StatusBadRequest, "max_retries must be between 0 and 20")
+    return
+  }
+  if req.BackoffSec < 0 || time.Duration(req.BackoffSec)*time.Second > appRestartMaxBackoff {
+    writeError(w, http.StatusBadRequest, "backoff_sec must be between 0 and 1800")
+    return
+  }
+  h, err := s.appHealth.update(appID, true, func(h appHealth) appHealth {
+    h.Policy = req.withDefaults()
+    h.Restarts = 0
+    h.GaveUp = false
+    h.NextRestartAt = nil
+    return h
+  })
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store restart policy")
+    return
+  }
+  writeJSON(w, http.StatusOK, h)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "net"
+  "net/http"
+  "net/http/httptest"
+  "strconv"
+  "testing"
+  "time"
+)
+
+func TestNextAppHealthRestartsWithBackoff(t *testing.T) {
+  now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  h := appHealth{Policy: appRestartPolicy{AutoRestart: true, MaxRetries: 2, BackoffSec: 60}}
+
+  h, restart := nextAppHealth(h, "running", nil, now)
+  if restart || h.State != "healthy" {
+    t.Fatalf("healthy app: state %s restart %v", h.State, restart)
+  }
+  hung := errors.New("timeout")
+  h, restart = nextAppHealth(h, "running", hung, now.Add(30*time.Second))
+  if restart || h.State != "unhealthy" {
+    t.Fatalf("one failed probe must not restart: state %s restart %v", h.State, restart)
+  }
+  h, restart = nextAppHealth(h, "running", hung, now.Add(time.Minute))
+  if !restart || h.Restarts != 1 {
+    t.Fatalf("expected first restart, got restart %v restarts %d", restart, h.Restarts)
+  }
+  // Within the 60s backoff nothing happens; after it, the next wait doubles.
+  h, restart = nextAppHealth(h, "stopped", nil, now.Add(90*time.Second))
+  if restart {
+    t.Fatalf("restart inside the backoff")
+  }
+  h, restart = nextAppHealth(h, "stopped", nil, now.Add(2*time.Minute))
+  if !restart || h.Restarts != 2 {
+    t.Fatalf("expected second restart, got restart %v restarts %d", restart, h.Restarts)
+  }
+  if got := h.NextRestartAt.Sub(now.Add(2 * time.Minute)); got != 2*time.Minute {
+    t.Fatalf("second backoff %s, want 2m", got)
+  }
+  h, restart = nextAppHealth(h, "stopped", nil, now.Add(time.Hour))
+  if restart || !h.GaveUp {
+    t.Fatalf("expected to give up after max_retries, got restart %v gave_up %v", restart, h.GaveUp)
+  }
+  // One healthy probe is not enough: an app that comes back and crashes
+  // again stays given up.
+  h, restart = nextAppHealth(h, "running", nil, now.Add(2*time.Hour))
+  if restart || !h.GaveUp || h.Restarts != 2 || h.Failures != 0 {
+    t.Fatalf("a single healthy check must not reset the restarts: %+v", h)
+  }
+  h, _ = nextAppHealth(h, "stopped", nil, now.Add(2*time.Hour+time.Minute))
+  if h.HealthySince != nil || !h.GaveUp {
+    t.Fatalf("a crash must end the healthy run: %+v", h)
+  }
+  // Healthy for three backoff periods (3 x 2m) resets the counters.
+  h, _ = nextAppHealth(h, "running", nil, now.Add(3*time.Hour))
+  h, restart = nextAppHealth(h, "running", nil, now.Add(3*time.Hour+5*time.Minute))
+  if restart || !h.GaveUp {
+    t.Fatalf("reset before the stable window: %+v", h)
+  }
+  h, restart = nextAppHealth(h, "running", nil, now.Add(3*time.Hour+6*time.Minute))
+  if restart || h.GaveUp || h.Restarts != 0 || h.NextRestartAt != nil {
+    t.Fatalf("stable app must reset the counters: %+v", h)
+  }
+}
+
+func TestNextAppHealthLeavesStoppedApps(t *testing.T) {
+  now := time.Now()
+  stopped := appHealth{StoppedByUser: true, Policy: appRestartPolicy{AutoRestart: true}}
+  if _, restart := nextAppHealth(stopped, "stopped", nil, now); restart {
+    t.Fatalf("restarted an app the user stopped")
+  }
+  manual := appHealth{Policy: appRestartPolicy{AutoRestart: false}}
+  h, restart := nextAppHealth(manual, "stopped", nil, now)
+  if restart || h.State != "stopped" {
+    t.Fatalf("restarted without auto_restart: state %s restart %v", h.State, restart)
+  }
+  if _, restart := nextAppHealth(appHealth{Policy: appRestartPolicy{AutoRestart: true}}, "unknown", nil, now); restart {
+    t.Fatalf("restarted on unknown status")
+  }
+}
+
+func TestAppRestartBackoffCap(t *testing.T) {
+  policy := appRestartPolicy{BackoffSec: 600}
+  if got := policy.backoff(1); got != 10*time.Minute {
+    t.Fatalf("backoff(1) = %s", got)
+  }
+  if got := policy.backoff(10); got != appRestartMaxBackoff {
+    t.Fatalf("backoff(10) = %s, want cap", got)
+  }
+}
+
+func TestProbeApp(t *testing.T) {
+  status := http.StatusFound
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if status == http.StatusFound {
+      w.Header().Set("Location", "/login")
+    }
+    w.WriteHeader(status)
+  }))
+  defer srv.Close()
+  _, portRaw, _ := net.SplitHostPort(srv.Listener.Addr().String())
+  port, _ := strconv.Atoi(portRaw)
+
+  ctx := context.Background()
+  if err := probeApp(ctx, appProbe{Kind: "http", Port: port, Path: "/"}); err != nil {
+    t.Fatalf("redirect should count as healthy: %v", err)
+  }
+  status = http.StatusBadGateway
+  if err := probeApp(ctx, appProbe{Kind: "http", Port: port, Path: "/"}); err == nil {
+    t.Fatalf("expected 502 to fail the probe")
+  }
+  if err := probeApp(ctx, appProbe{Kind: "tcp", Port: port}); err != nil {
+    t.Fatalf("tcp probe: %v", err)
+  }
+  srv.Close()
+  if err := probeApp(ctx, appProbe{Kind: "tcp", Port: port}); err == nil {
+    t.Fatalf("expected closed port to fail the probe")
+  }
+}
@@ -42,6 +42,7 @@ func lndgDefinition() appDefinition {
     Name: "LNDg",
     Description: "Advanced analytics, automation, and insights for your LND node.",
     Port: 8889,
+    Probe: &appProbe{Kind: "http", Port: 8889, Path: "/"},
   }
 }
 
@@ -64,6 +65,8 @@ func (a lndgApp) Info(ctx context.Context) (appInfo, error) {
     return info, err
   }
   info.Status = status
+  sync := lndgSyncStatus(ctx, status == "running", paths.LogPath)
+  info.Sync = &sync
   return info, nil
 }
 
@@ -104,7 +107,7 @@ func lndgAppPaths() lndgPaths {
 }
 
 func (s *Server) installLndg(ctx context.Context) error {
-  if err := ensureDocker(ctx); err != nil {
+  if err := ensureContainerRuntime(ctx); err != nil {
     return err
   }
   paths := lndgAppPaths()
@@ -295,9 +298,8 @@ user.save()
 print("ok")
 PY`
 
-  _, err = system.RunCommandWithSudo(
+  _, err = containerCLI(
     ctx,
-    "docker",
     "exec",
     "-i",
     "-e",
@@ -458,7 +460,7 @@ func ensureLndgEnv(ctx context.Context, paths lndgPaths) error {
     "LNDG_ADMIN_USER=lndg-admin",
     "LNDG_ADMIN_PASSWORD=" + adminPassword,
     "LNDG_DB_PASSWORD=" + dbPassword,
-    "LNDG_NETWORK=mainnet",
+    "LNDG_NETWORK=" + activeNetwork().Name,
     "LNDG_RPC_SERVER=host.docker.internal:10009",
     "LNDG_LND_DIR=/root/.lnd",
     "LNDG_GIT_REF=" + gitRef,
@@ -503,7 +505,7 @@ func syncLndgDbPassword(ctx context.Context, paths lndgPaths) error {
   var lastErr error
   var lastOut string
   for attempt := 0; attempt < 10; attempt++ {
-    out, err := system.RunCommandWithSudo(ctx, "docker", "exec", "-i", containerID, "sh", "-c", cmd)
+    out, err := containerCLI(ctx, "exec", "-i", containerID, "sh", "-c", cmd)
     if err == nil {
       return nil
     }
@@ -533,34 +535,47 @@ func ensureLndgGrpcAccess(ctx context.Context) error {
   if len(gateways) == 0 {
     return errors.New("unable to determine docker gateway IPs")
   }
+  changed, err := updateLndGrpcConf(gateways)
+  if err != nil || !changed {
+    return err
+  }
+  _, _ = system.RunCommandWithSudo(ctx, "rm", "-f", "/data/lnd/tls.cert", "/data/lnd/tls.key")
+  if _, err := system.RunCommandWithSudo(ctx, "systemctl", "restart", "lnd"); err != nil {
+    return fmt.Errorf("failed to restart lnd: %w", err)
+  }
+  return nil
+}
+
+func updateLndGrpcConf(gateways []string) (bool, error) {
+  unlock := lockConfigFile(lndConfPath)
+  defer unlock()
   content, err := os.ReadFile(lndConfPath)
   if err != nil {
-    return fmt.Errorf("failed to read lnd.conf: %w", err)
+    return false, fmt.Errorf("failed to read lnd.conf: %w", err)
   }
   lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
   lines, changed := updateLndGrpcOptions(lines, gateways)
   if !changed {
-    return nil
+    return false, nil
   }
-  if err := os.WriteFile(lndConfPath, []byte(strings.Join(lines, "\n")+"\n"), 0640); err != nil {
-    return fmt.Errorf("failed to update lnd.conf: %w", err)
+  if err := writeFileAtomic(lndConfPath, []byte(strings.Join(lines, "\n")+"\n"), 0640); err != nil {
+    return false, fmt.Errorf("failed to update lnd.conf: %w", err)
   }
-  _, _ = system.RunCommandWithSudo(ctx, "rm", "-f", "/data/lnd/tls.cert", "/data/lnd/tls.key")
-  if _, err := system.RunCommandWithSudo(ctx, "systemctl", "restart", "lnd"); err != nil {
-    return fmt.Errorf("failed to restart lnd: %w", err)
-  }
-  return nil
+  return true, nil
 }
 
+// dockerGatewayIP returns the host's address on the runtime's default
+// network.
 func dockerGatewayIP(ctx context.Context) (string, error) {
-  out, err := system.RunCommandWithSudo(ctx, "docker", "network", "inspect", "bridge", "--format", "{{(index .IPAM.Config 0).Gateway}}")
-  if err == nil {
-    ip := strings.TrimSpace(out)
-    if ip != "" && ip != "<no value>" {
-      return ip, nil
-    }
+  rt := activeContainerRuntime()
+  if ip, err := rt.NetworkGateway(ctx, rt.DefaultNetwork()); err == nil {
+    return ip, nil
+  }
+  iface, err := rt.NetworkInterface(ctx, rt.DefaultNetwork())
+  if err != nil {
+    iface = rt.DefaultNetwork() + "0"
   }
-  out, err = system.RunCommandWithSudo(ctx, "ip", "-4", "addr", "show", "docker0")
+  out, err := system.RunCommandWithSudo(ctx, "ip", "-4", "addr", "show", iface)
   if err == nil {
     fields := strings.Fields(out)
     for i, token := range fields {
@@ -572,19 +587,15 @@ func dockerGatewayIP(ctx context.Context) (string, error) {
       }
     }
   }
-  return "", errors.New("unable to determine docker bridge gateway IP")
+  return "", fmt.Errorf("unable to determine %s bridge gateway IP", rt.Name())
 }
 
 func lndgNetworkGatewayIP(ctx context.Context) (string, error) {
-  out, err := system.RunCommandWithSudo(ctx, "docker", "network", "inspect", "lndg_default", "--format", "{{(index .IPAM.Config 0).Gateway}}")
-  if err != nil {
-    return "", err
-  }
-  ip := strings.TrimSpace(out)
-  if ip == "" || ip == "<no value>" {
+  ip, err := activeContainerRuntime().NetworkGateway(ctx, "lndg_default")
+  if errors.Is(err, errNoNetworkValue) {
     return "", errors.New("lndg_default network gateway not found")
   }
-  return ip, nil
+  return ip, err
 }
 
 func ensureLndgUfwAccess(ctx context.Context) error {
@@ -637,18 +648,7 @@ func ensureLndgUfwAccess(ctx context.Context) error {
 }
 
 func lndgBridgeName(ctx context.Context) (string, error) {
-  out, err := system.RunCommandWithSudo(ctx, "docker", "network", "inspect", "lndg_default", "--format", "{{.Id}}")
-  if err != nil {
-    return "", err
-  }
-  id := strings.TrimSpace(out)
-  if id == "" || id == "<no value>" {
-    return "", errors.New("lndg_default network id not found")
-  }
-  if len(id) > 12 {
-    id = id[:12]
-  }
-  return "br-" + id, nil
+  return activeContainerRuntime().NetworkInterface(ctx, "lndg_default")
 }
 
 func updateLndGrpcOptions(lines []string, gateways []string) ([]string, bool) {
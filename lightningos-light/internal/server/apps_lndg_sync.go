@@ -0,0 +1,159 @@
+package server
+
+import (
+  "context"
+  "io"
+  "os"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+// LNDg's controller pulls channels, forwards, payments and invoices from
+// LND in a loop and logs each round as "<%c timestamp> : [Tag] : message".
+// Its sync state comes from that log (the latest line without an error and
+// the latest error) and from the newest row LNDg wrote, so a quiet log
+// still shows when data last arrived.
+const (
+  lndgLogTailBytes = 256 * 1024
+  lndgSyncStaleAfter = time.Hour
+  lndgSyncCacheTTL = time.Minute
+)
+
+const lndgLatestDataQuery = `
+select coalesce(extract(epoch from greatest(
+  (select max(forward_date) from gui_forwards),
+  (select max(creation_date) from gui_payments),
+  (select max(creation_date) from gui_invoices)
+))::bigint, 0)`
+
+type appSyncStatus struct {
+  // State is ok, stale, error, stopped or unknown.
+  State string `json:"state"`
+  LastSyncAt *time.Time `json:"last_sync_at,omitempty"`
+  LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+  LastError string `json:"last_error,omitempty"`
+  CheckedAt time.Time `json:"checked_at"`
+}
+
+type lndgLogSummary struct {
+  LastOKAt *time.Time
+  LastErrorAt *time.Time
+  LastError string
+}
+
+// parseLndgControllerLog reads timestamped controller lines. The container
+// runs in UTC; lines without a timestamp (tracebacks, Django's request log)
+// are skipped.
+func parseLndgControllerLog(raw string) lndgLogSummary {
+  var summary lndgLogSummary
+  for _, line := range strings.Split(raw, "\n") {
+    parts := strings.SplitN(strings.TrimSpace(line), " : ", 3)
+    if len(parts) < 2 {
+      continue
+    }
+    at, err := time.ParseInLocation(time.ANSIC, strings.TrimSpace(parts[0]), time.UTC)
+    if err != nil {
+      continue
+    }
+    message := strings.TrimSpace(parts[len(parts)-1])
+    lower := strings.ToLower(message)
+    if strings.Contains(lower, "error") || strings.Contains(lower, "exception") || strings.Contains(lower, "failed") {
+      summary.LastErrorAt = &at
+      summary.LastError = message
+      continue
+    }
+    summary.LastOKAt = &at
+  }
+  return summary
+}
+
+// lndgSyncState folds the log summary and the newest data row into a state.
+// An error newer than the last good round is an error; no good round for
+// lndgSyncStaleAfter is stale.
+func lndgSyncState(running bool, summary lndgLogSummary, latestData *time.Time, now time.Time) appSyncStatus {
+  status := appSyncStatus{
+    LastErrorAt: summary.LastErrorAt,
+    LastError: summary.LastError,
+    CheckedAt: now,
+  }
+  status.LastSyncAt = summary.LastOKAt
+  if latestData != nil && (status.LastSyncAt == nil || latestData.After(*status.LastSyncAt)) {
+    status.LastSyncAt = latestData
+  }
+  switch {
+  case !running:
+    status.State = "stopped"
+  case status.LastErrorAt != nil && (status.LastSyncAt == nil || status.LastErrorAt.After(*status.LastSyncAt)):
+    status.State = "error"
+  case status.LastSyncAt == nil:
+    status.State = "unknown"
+  case now.Sub(*status.LastSyncAt) > lndgSyncStaleAfter:
+    status.State = "stale"
+  default:
+    status.State = "ok"
+  }
+  return status
+}
+
+func readFileTail(path string, limit int64) (string, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return "", err
+  }
+  defer file.Close()
+  info, err := file.Stat()
+  if err != nil {
+    return "", err
+  }
+  if info.Size() > limit {
+    if _, err := file.Seek(info.Size()-limit, io.SeekStart); err != nil {
+      return "", err
+    }
+  }
+  raw, err := io.ReadAll(file)
+  return string(raw), err
+}
+
+// lndgLatestData asks LNDg's database for the newest row it wrote.
+func lndgLatestData(ctx context.Context) *time.Time {
+  ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+  defer cancel()
+  out, err := queryLNDg(ctx, lndgLatestDataQuery)
+  if err != nil {
+    return nil
+  }
+  epoch, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+  if err != nil || epoch <= 0 {
+    return nil
+  }
+  at := time.Unix(epoch, 0).UTC()
+  return &at
+}
+
+// The app list polls, so the status is cached for lndgSyncCacheTTL.
+var lndgSyncCache struct {
+  mu sync.Mutex
+  status *appSyncStatus
+}
+
+func lndgSyncStatus(ctx context.Context, running bool, logPath string) appSyncStatus {
+  lndgSyncCache.mu.Lock()
+  defer lndgSyncCache.mu.Unlock()
+  now := time.Now().UTC()
+  if cached := lndgSyncCache.status; cached != nil && now.Sub(cached.CheckedAt) < lndgSyncCacheTTL && (cached.State == "stopped") == !running {
+    return *cached
+  }
+  var summary lndgLogSummary
+  if raw, err := readFileTail(logPath, lndgLogTailBytes); err == nil {
+    summary = parseLndgControllerLog(raw)
+  }
+  var latestData *time.Time
+  if running {
+    latestData = lndgLatestData(ctx)
+  }
+  status := lndgSyncState(running, summary, latestData, now)
+  lndgSyncCache.status = &status
+  return status
+}
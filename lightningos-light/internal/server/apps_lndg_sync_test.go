@@ -0,0 +1,57 @@
+package server
+
+import (
+  "testing"
+  "time"
+)
+
+func TestParseLndgControllerLog(t *testing.T) {
+  raw := `Mon Mar  2 10:00:00 2026 : [Data] : Starting jobs
+[02/Mar/2026 10:00:05] "GET / HTTP/1.1" 200 1234
+Mon Mar  2 10:00:20 2026 : [Data] : Error processing background data: <_InactiveRpcError of RPC that terminated>
+Traceback (most recent call last):
+Mon Mar  2 10:00:40 2026 : [Rebalancer] : Rebalance completed
+`
+  summary := parseLndgControllerLog(raw)
+  if summary.LastOKAt == nil || !summary.LastOKAt.Equal(time.Date(2026, 3, 2, 10, 0, 40, 0, time.UTC)) {
+    t.Fatalf("last ok: %v", summary.LastOKAt)
+  }
+  if summary.LastErrorAt == nil || !summary.LastErrorAt.Equal(time.Date(2026, 3, 2, 10, 0, 20, 0, time.UTC)) {
+    t.Fatalf("last error: %v", summary.LastErrorAt)
+  }
+  if summary.LastError == "" || summary.LastError[:5] != "Error" {
+    t.Fatalf("last error message: %q", summary.LastError)
+  }
+}
+
+func TestLndgSyncState(t *testing.T) {
+  now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+  at := func(d time.Duration) *time.Time {
+    v := now.Add(-d)
+    return &v
+  }
+  cases := []struct {
+    name string
+    running bool
+    summary lndgLogSummary
+    data *time.Time
+    want string
+  }{
+    {"fresh log", true, lndgLogSummary{LastOKAt: at(time.Minute)}, nil, "ok"},
+    {"fresh data, quiet log", true, lndgLogSummary{}, at(10 * time.Minute), "ok"},
+    {"error after last sync", true, lndgLogSummary{LastOKAt: at(5 * time.Minute), LastErrorAt: at(time.Minute)}, nil, "error"},
+    {"recovered", true, lndgLogSummary{LastOKAt: at(time.Minute), LastErrorAt: at(5 * time.Minute)}, nil, "ok"},
+    {"stale", true, lndgLogSummary{LastOKAt: at(2 * time.Hour)}, at(3 * time.Hour), "stale"},
+    {"nothing yet", true, lndgLogSummary{}, nil, "unknown"},
+    {"stopped", false, lndgLogSummary{LastOKAt: at(time.Minute)}, nil, "stopped"},
+  }
+  for _, tc := range cases {
+    if got := lndgSyncState(tc.running, tc.summary, tc.data, now); got.State != tc.want {
+      t.Errorf("%s: state %s, want %s", tc.name, got.State, tc.want)
+    }
+  }
+  status := lndgSyncState(true, lndgLogSummary{LastOKAt: at(time.Hour)}, at(time.Minute), now)
+  if !status.LastSyncAt.Equal(*at(time.Minute)) {
+    t.Fatalf("last sync should be the newer of log and data: %v", status.LastSyncAt)
+  }
+}
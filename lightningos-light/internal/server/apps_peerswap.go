@@ -59,6 +59,8 @@ func peerswapDefinition() appDefinition {
     Name: "Peerswap",
     Description: "Peerswap daemon with psweb UI (requires Elements).",
     Port: pswebPort,
+    Version: peerswapVersion,
+    Probe: &appProbe{Kind: "http", Port: pswebPort, Path: "/"},
   }
 }
 
@@ -345,7 +347,7 @@ func peerswapConfigDefaults(ctx context.Context) (peerswapConfigValues, error) {
   }
   return peerswapConfigValues{
     LndTLSPath: "/data/lnd/tls.cert",
-    LndMacaroonPath: "/data/lnd/data/chain/bitcoin/mainnet/admin.macaroon",
+    LndMacaroonPath: lndAdminMacaroonPath(),
     ElementsRPCUser: user,
     ElementsRPCPass: pass,
     ElementsRPCHost: "http://127.0.0.1",
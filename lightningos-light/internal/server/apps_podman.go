@@ -0,0 +1,97 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "os/exec"
+  "path/filepath"
+  "strings"
+)
+
+// podmanRuntime runs the apps rootful through sudo, like Docker, with
+// podman-compose. Podman has no daemon to start, and podman-compose's ps
+// does not filter by service, so containers are found by the compose
+// labels podman-compose sets. The project name is passed explicitly so it
+// matches the one Docker compose derives from the app directory.
+type podmanRuntime struct{}
+
+func (podmanRuntime) Name() string { return containerRuntimePodman }
+
+func (podmanRuntime) CLI() string { return "podman" }
+
+func (podmanRuntime) DefaultNetwork() string { return "podman" }
+
+func (p podmanRuntime) Ensure(ctx context.Context) error {
+  if _, err := exec.LookPath("podman"); err != nil {
+    if _, err := runApt(ctx, "update"); err != nil {
+      return err
+    }
+    if out, err := runApt(ctx, "install", "-y", "podman"); err != nil {
+      return fmt.Errorf("podman install failed: %s", strings.TrimSpace(out))
+    }
+  }
+  if out, err := runContainerCommand(ctx, "podman", "info"); err != nil {
+    if isSudoDenied(err) {
+      return err
+    }
+    return fmt.Errorf("podman not usable: %s", strings.TrimSpace(out))
+  }
+  if _, err := p.resolveCompose(ctx); err == nil {
+    return nil
+  }
+  if _, err := runApt(ctx, "install", "-y", "podman-compose"); isSudoDenied(err) {
+    return err
+  }
+  _, err := p.resolveCompose(ctx)
+  return err
+}
+
+func (podmanRuntime) resolveCompose(ctx context.Context) (string, error) {
+  if _, err := runContainerCommand(ctx, "podman-compose", "version"); err != nil {
+    if isSudoDenied(err) {
+      return "", err
+    }
+    return "", errors.New("podman-compose not available (install podman-compose)")
+  }
+  return "podman-compose", nil
+}
+
+// Compose runs from the compose file's directory, which is the app root.
+func (p podmanRuntime) Compose(ctx context.Context, appRoot string, composePath string) (string, []string, error) {
+  cmd, err := p.resolveCompose(ctx)
+  if err != nil {
+    return "", nil, err
+  }
+  args := []string{"-p", composeProjectName(appRoot)}
+  envPath := filepath.Join(appRoot, ".env")
+  if fileExists(envPath) {
+    args = append(args, "--env-file", envPath)
+  }
+  args = append(args, "-f", composePath)
+  return cmd, args, nil
+}
+
+func (p podmanRuntime) ServiceRunning(ctx context.Context, appRoot string, composePath string, service string) (bool, error) {
+  id, err := p.ContainerID(ctx, appRoot, composePath, service)
+  return id != "", err
+}
+
+func (podmanRuntime) ContainerID(ctx context.Context, appRoot string, composePath string, service string) (string, error) {
+  out, err := runContainerCommand(ctx, "podman", "ps", "-q",
+    "--filter", "label=com.docker.compose.project="+composeProjectName(appRoot),
+    "--filter", "label=com.docker.compose.service="+service)
+  if err != nil {
+    return "", err
+  }
+  return firstLine(out), nil
+}
+
+func (podmanRuntime) NetworkGateway(ctx context.Context, network string) (string, error) {
+  gateways, err := inspectNetwork(ctx, "podman", network, "{{range .Subnets}}{{println .Gateway}}{{end}}")
+  return firstLine(gateways), err
+}
+
+func (podmanRuntime) NetworkInterface(ctx context.Context, network string) (string, error) {
+  return inspectNetwork(ctx, "podman", network, "{{.NetworkInterface}}")
+}
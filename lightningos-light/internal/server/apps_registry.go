@@ -55,6 +55,14 @@ func validateAppRegistry(apps []appHandler) error {
       }
       ports[def.Port] = true
     }
+    if probe := def.Probe; probe != nil {
+      if probe.Kind != "http" && probe.Kind != "tcp" {
+        return fmt.Errorf("app %s has unsupported probe %q", def.ID, probe.Kind)
+      }
+      if probe.Port <= 0 || probe.Port > 65535 {
+        return fmt.Errorf("app %s has invalid probe port %d", def.ID, probe.Port)
+      }
+    }
   }
   return nil
 }
@@ -12,6 +12,11 @@ type appDefinition struct {
   Name string
   Description string
   Port int
+  // Version is the release the manager installs, when it pins one.
+  Version string
+  // Probe, when set, is how the health monitor tells a running app that
+  // answers from one that hangs.
+  Probe *appProbe
 }
 
 type appInfo struct {
@@ -22,6 +27,9 @@ type appInfo struct {
   Status string `json:"status"`
   Port int `json:"port"`
   AdminPasswordPath string `json:"admin_password_path,omitempty"`
+  Health *appHealth `json:"health,omitempty"`
+  // Sync is set for apps that copy data from LND (LNDg).
+  Sync *appSyncStatus `json:"sync,omitempty"`
 }
 
 type appHandler interface {
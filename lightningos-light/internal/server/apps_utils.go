@@ -32,7 +32,7 @@ func ensureFileWithChange(path string, content string) (bool, error) {
 }
 
 func writeFile(path string, content string, mode os.FileMode) error {
-  if err := os.WriteFile(path, []byte(content), mode); err != nil {
+  if err := writeFileAtomic(path, []byte(content), mode); err != nil {
     return fmt.Errorf("failed to write %s: %w", path, err)
   }
   return nil
@@ -76,12 +76,18 @@ func appendEnvLine(path string, key string, value string) error {
   if value == "" {
     return nil
   }
-  file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+  unlock := lockConfigFile(path)
+  defer unlock()
+  content, err := os.ReadFile(path)
   if err != nil {
     return fmt.Errorf("failed to update %s: %w", path, err)
   }
-  defer file.Close()
-  if _, err := file.WriteString(fmt.Sprintf("%s=%s\n", key, value)); err != nil {
+  updated := string(content)
+  if updated != "" && !strings.HasSuffix(updated, "\n") {
+    updated += "\n"
+  }
+  updated += fmt.Sprintf("%s=%s\n", key, value)
+  if err := writeFileAtomic(path, []byte(updated), 0600); err != nil {
     return fmt.Errorf("failed to update %s: %w", path, err)
   }
   return nil
@@ -122,6 +128,8 @@ func mergeUnique(base []string, extra []string) []string {
 }
 
 func setEnvValue(path string, key string, value string) error {
+  unlock := lockConfigFile(path)
+  defer unlock()
   content, err := os.ReadFile(path)
   if err != nil {
     return fmt.Errorf("failed to read %s: %w", path, err)
@@ -134,7 +142,7 @@ func setEnvValue(path string, key string, value string) error {
     lines = append(lines, line)
   }
   lines = append(lines, fmt.Sprintf("%s=%s", key, value))
-  if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+  if err := writeFileAtomic(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
     return fmt.Errorf("failed to update %s: %w", path, err)
   }
   return nil
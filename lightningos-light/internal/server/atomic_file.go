@@ -0,0 +1,108 @@
+package server
+
+import (
+  "errors"
+  "os"
+  "path/filepath"
+  "syscall"
+)
+
+// writeFileAtomic replaces path without ever leaving a truncated file behind:
+// the data goes to a temp file in the same directory, is fsynced and renamed
+// over the target. An existing file keeps its owner, group and mode; mode only
+// applies to new files.
+//
+// When the directory is not writable for us (e.g. /data/lnd owned by lnd with
+// only lnd.conf group-writable) or the original owner cannot be restored, the
+// file is rewritten in place and fsynced instead.
+//
+// Managed config files also get their drift baseline updated.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+  if err := replaceFile(path, data, mode); err != nil {
+    return err
+  }
+  if isManagedConfigFile(path) {
+    recordConfigBaseline(path, data)
+  }
+  return nil
+}
+
+func replaceFile(path string, data []byte, mode os.FileMode) error {
+  uid, gid := -1, -1
+  if info, err := os.Stat(path); err == nil {
+    mode = info.Mode().Perm()
+    if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+      uid, gid = int(stat.Uid), int(stat.Gid)
+    }
+  } else if !errors.Is(err, os.ErrNotExist) {
+    return err
+  }
+
+  dir := filepath.Dir(path)
+  tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+  if err != nil {
+    if errors.Is(err, os.ErrPermission) && uid != -1 {
+      return writeFileInPlace(path, data)
+    }
+    return err
+  }
+  tmpPath := tmp.Name()
+  cleanup := func() {
+    _ = tmp.Close()
+    _ = os.Remove(tmpPath)
+  }
+
+  if uid != -1 && (uid != os.Geteuid() || gid != os.Getegid()) {
+    if err := tmp.Chown(uid, gid); err != nil {
+      cleanup()
+      return writeFileInPlace(path, data)
+    }
+  }
+  if err := tmp.Chmod(mode); err != nil {
+    cleanup()
+    return err
+  }
+  if _, err := tmp.Write(data); err != nil {
+    cleanup()
+    return err
+  }
+  if err := tmp.Sync(); err != nil {
+    cleanup()
+    return err
+  }
+  if err := tmp.Close(); err != nil {
+    _ = os.Remove(tmpPath)
+    return err
+  }
+  if err := os.Rename(tmpPath, path); err != nil {
+    _ = os.Remove(tmpPath)
+    return err
+  }
+  syncDir(dir)
+  return nil
+}
+
+func writeFileInPlace(path string, data []byte) error {
+  f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+  if err != nil {
+    return err
+  }
+  if _, err := f.Write(data); err != nil {
+    _ = f.Close()
+    return err
+  }
+  if err := f.Sync(); err != nil {
+    _ = f.Close()
+    return err
+  }
+  return f.Close()
+}
+
+func syncDir(dir string) {
+  d, err := os.Open(dir)
+  if err != nil {
+    return
+  }
+  _ = d.Sync()
+  _ = d.Close()
+}
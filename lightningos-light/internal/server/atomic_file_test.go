@@ -0,0 +1,47 @@
+package server
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestWriteFileAtomicPreservesMode(t *testing.T) {
+  dir := t.TempDir()
+  path := filepath.Join(dir, "lnd.conf")
+  if err := os.WriteFile(path, []byte("old"), 0o640); err != nil {
+    t.Fatalf("seed: %v", err)
+  }
+  if err := writeFileAtomic(path, []byte("new"), 0o600); err != nil {
+    t.Fatalf("write: %v", err)
+  }
+  raw, err := os.ReadFile(path)
+  if err != nil || string(raw) != "new" {
+    t.Fatalf("unexpected content %q (%v)", raw, err)
+  }
+  info, err := os.Stat(path)
+  if err != nil {
+    t.Fatalf("stat: %v", err)
+  }
+  if info.Mode().Perm() != 0o640 {
+    t.Fatalf("expected mode 0640, got %v", info.Mode().Perm())
+  }
+  entries, _ := os.ReadDir(dir)
+  if len(entries) != 1 {
+    t.Fatalf("temp file left behind: %d entries", len(entries))
+  }
+}
+
+func TestWriteFileAtomicNewFile(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "secrets.env")
+  if err := writeFileAtomic(path, []byte("A=1\n"), 0o660); err != nil {
+    t.Fatalf("write: %v", err)
+  }
+  info, err := os.Stat(path)
+  if err != nil {
+    t.Fatalf("stat: %v", err)
+  }
+  if info.Mode().Perm() != 0o660 {
+    t.Fatalf("expected mode 0660, got %v", info.Mode().Perm())
+  }
+}
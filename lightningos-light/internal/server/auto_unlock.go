@@ -0,0 +1,360 @@
+package server
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "syscall"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+const autoUnlockStatePath = "/var/lib/lightningos/auto-unlock.json"
+
+var autoUnlockMu sync.Mutex
+
+// autoUnlockResult is the outcome of the last wallet unlock, either done by
+// LND itself from the password file or through the unlock endpoint.
+type autoUnlockResult struct {
+  At time.Time `json:"at"`
+  OK bool `json:"ok"`
+  Source string `json:"source"`
+  Message string `json:"message,omitempty"`
+}
+
+// autoUnlockState is kept apart from lnd.conf so editing the config never
+// turns auto-unlock back on after the user disabled it.
+type autoUnlockState struct {
+  Disabled bool `json:"disabled"`
+  LastResult *autoUnlockResult `json:"last_result,omitempty"`
+}
+
+type autoUnlockFileStatus struct {
+  Path string `json:"path"`
+  Exists bool `json:"exists"`
+  Mode string `json:"mode,omitempty"`
+  UID int `json:"uid"`
+  GID int `json:"gid"`
+  PermsOK bool `json:"perms_ok"`
+}
+
+type autoUnlockStatus struct {
+  Enabled bool `json:"enabled"`
+  Disabled bool `json:"disabled"`
+  PasswordStored bool `json:"password_stored"`
+  ConfConfigured bool `json:"conf_configured"`
+  PasswordFile autoUnlockFileStatus `json:"password_file"`
+  LastResult *autoUnlockResult `json:"last_result,omitempty"`
+  Issues []string `json:"issues"`
+}
+
+func readAutoUnlockState() autoUnlockState {
+  state := autoUnlockState{}
+  raw, err := os.ReadFile(autoUnlockStatePath)
+  if err != nil {
+    return state
+  }
+  _ = json.Unmarshal(raw, &state)
+  return state
+}
+
+func updateAutoUnlockState(fn func(*autoUnlockState)) error {
+  autoUnlockMu.Lock()
+  defer autoUnlockMu.Unlock()
+  state := readAutoUnlockState()
+  fn(&state)
+  if err := os.MkdirAll(filepath.Dir(autoUnlockStatePath), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.MarshalIndent(state, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(autoUnlockStatePath, raw, 0o640)
+}
+
+func recordUnlockResult(source string, ok bool, message string) {
+  result := &autoUnlockResult{At: time.Now().UTC(), OK: ok, Source: source, Message: message}
+  _ = updateAutoUnlockState(func(state *autoUnlockState) {
+    state.LastResult = result
+  })
+}
+
+// autoUnlockActive reports whether LND should unlock itself: a password is
+// stored and the user has not turned the feature off.
+func autoUnlockActive() bool {
+  return !readAutoUnlockState().Disabled && walletPasswordAvailable()
+}
+
+// storeWalletUnlock saves the password for auto-unlock after an init or a
+// manual unlock, unless the user turned auto-unlock off.
+func storeWalletUnlock(password string) error {
+  trimmed := strings.TrimSpace(password)
+  if trimmed == "" {
+    return errors.New("wallet password required")
+  }
+  if readAutoUnlockState().Disabled {
+    return nil
+  }
+  if err := storeWalletPassword(trimmed); err != nil {
+    return err
+  }
+  return ensureWalletUnlockConfig()
+}
+
+func storeWalletPassword(password string) error {
+  if _, err := os.Stat(lndPasswordPath); err != nil {
+    if os.IsNotExist(err) {
+      return fmt.Errorf("password file missing: %s", lndPasswordPath)
+    }
+    return err
+  }
+  return writeFileAtomic(lndPasswordPath, []byte(password), 0660)
+}
+
+func walletPasswordAvailable() bool {
+  info, err := os.Stat(lndPasswordPath)
+  if err != nil || info.Size() == 0 {
+    return false
+  }
+  content, err := os.ReadFile(lndPasswordPath)
+  if err != nil {
+    return false
+  }
+  return strings.TrimSpace(string(content)) != ""
+}
+
+func ensureWalletUnlockConfig() error {
+  if err := os.MkdirAll(filepath.Dir(lndConfPath), 0750); err != nil {
+    return err
+  }
+  unlock := lockConfigFile(lndConfPath)
+  defer unlock()
+  raw, _ := os.ReadFile(lndConfPath)
+  updated := ensureUnlockLines(string(raw))
+  return writeFileAtomic(lndConfPath, []byte(updated), 0660)
+}
+
+func ensureUnlockLines(raw string) string {
+  lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+  start := -1
+  end := len(lines)
+
+  for i, line := range lines {
+    trimmed := strings.TrimSpace(line)
+    if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+      if strings.EqualFold(trimmed, "[Application Options]") {
+        start = i
+        continue
+      }
+      if start != -1 && i > start {
+        end = i
+        break
+      }
+    }
+  }
+
+  if start == -1 {
+    lines = append(lines, "[Application Options]")
+    start = len(lines) - 1
+    end = len(lines)
+  }
+
+  hasPass := false
+  hasAllow := false
+  for i := start + 1; i < end; i++ {
+    trimmed := strings.TrimSpace(lines[i])
+    if strings.HasPrefix(trimmed, "wallet-unlock-password-file=") {
+      lines[i] = "wallet-unlock-password-file=" + lndPasswordPath
+      hasPass = true
+    }
+    if strings.HasPrefix(trimmed, "wallet-unlock-allow-create=") {
+      lines[i] = "wallet-unlock-allow-create=true"
+      hasAllow = true
+    }
+  }
+
+  extra := []string{}
+  if !hasPass {
+    extra = append(extra, "wallet-unlock-password-file="+lndPasswordPath)
+  }
+  if !hasAllow {
+    extra = append(extra, "wallet-unlock-allow-create=true")
+  }
+  if len(extra) > 0 {
+    lines = append(lines[:end], append(extra, lines[end:]...)...)
+  }
+
+  return strings.Join(lines, "\n")
+}
+
+// unlockConfConfigured reports whether lnd.conf points LND at the password
+// file.
+func unlockConfConfigured(raw string) bool {
+  for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+    parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+    if len(parts) == 2 && strings.TrimSpace(parts[0]) == "wallet-unlock-password-file" && strings.TrimSpace(parts[1]) == lndPasswordPath {
+      return true
+    }
+  }
+  return false
+}
+
+func removeWalletUnlockConfig() error {
+  unlock := lockConfigFile(lndConfPath)
+  defer unlock()
+  raw, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    if os.IsNotExist(err) {
+      return nil
+    }
+    return err
+  }
+  updated := removeLNDConfOptions(string(raw), "wallet-unlock-password-file", "wallet-unlock-allow-create")
+  if updated == string(raw) {
+    return nil
+  }
+  return writeFileAtomic(lndConfPath, []byte(updated), 0660)
+}
+
+func passwordFileStatus() autoUnlockFileStatus {
+  status := autoUnlockFileStatus{Path: lndPasswordPath, UID: -1, GID: -1}
+  info, err := os.Stat(lndPasswordPath)
+  if err != nil {
+    return status
+  }
+  status.Exists = true
+  status.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+  if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+    status.UID = int(stat.Uid)
+    status.GID = int(stat.Gid)
+  }
+  status.PermsOK = info.Mode().Perm()&0o007 == 0
+  return status
+}
+
+func currentAutoUnlockStatus() autoUnlockStatus {
+  state := readAutoUnlockState()
+  raw, _ := os.ReadFile(lndConfPath)
+  status := autoUnlockStatus{
+    Disabled: state.Disabled,
+    PasswordStored: walletPasswordAvailable(),
+    ConfConfigured: unlockConfConfigured(string(raw)),
+    PasswordFile: passwordFileStatus(),
+    LastResult: state.LastResult,
+    Issues: []string{},
+  }
+  status.Enabled = status.PasswordStored && status.ConfConfigured && !status.Disabled
+  if status.PasswordFile.Exists && !status.PasswordFile.PermsOK {
+    status.Issues = append(status.Issues, "wallet password file is readable by other users")
+  }
+  if status.ConfConfigured && !status.PasswordStored {
+    status.Issues = append(status.Issues, "lnd.conf uses the password file but no password is stored")
+  }
+  if status.PasswordStored && !status.ConfConfigured && !status.Disabled {
+    status.Issues = append(status.Issues, "password stored but lnd.conf does not use it")
+  }
+  return status
+}
+
+// observeAutoUnlock records whether LND unlocked itself when it comes back
+// from a (re)start with auto-unlock on.
+func (s *Server) observeAutoUnlock(prev, next lndclient.Lifecycle) {
+  switch prev.State {
+  case lndclient.StateDown, lndclient.StateRestarting, lndclient.StateWaitingToStart:
+  default:
+    return
+  }
+  if !autoUnlockActive() {
+    return
+  }
+  switch next.State {
+  case lndclient.StateUnlocked, lndclient.StateRPCActive, lndclient.StateServerActive:
+    recordUnlockResult("lnd", true, "")
+  case lndclient.StateLocked:
+    recordUnlockResult("lnd", false, "LND started with the wallet locked")
+    s.logger.Printf("auto-unlock: LND started with the wallet locked")
+  }
+}
+
+func (s *Server) handleAutoUnlockGet(w http.ResponseWriter, r *http.Request) {
+  status := currentAutoUnlockStatus()
+  for i := range status.Issues {
+    status.Issues[i] = localize(w, status.Issues[i])
+  }
+  writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleAutoUnlockPost(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Enabled *bool `json:"enabled"`
+    WipePassword bool `json:"wipe_password"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if req.Enabled == nil && !req.WipePassword {
+    writeError(w, http.StatusBadRequest, "enabled or wipe_password required")
+    return
+  }
+  if req.WipePassword && req.Enabled != nil && *req.Enabled {
+    writeError(w, http.StatusBadRequest, "cannot enable auto-unlock while wiping the password")
+    return
+  }
+
+  if req.Enabled != nil && *req.Enabled {
+    if !walletPasswordAvailable() {
+      writeError(w, http.StatusBadRequest, "no stored wallet password: unlock the wallet once to store it")
+      return
+    }
+    if err := ensureWalletUnlockConfig(); err != nil {
+      s.logger.Printf("auto-unlock enable failed: %v", err)
+      writeError(w, http.StatusInternalServerError, "failed to update lnd.conf")
+      return
+    }
+    if err := updateAutoUnlockState(func(state *autoUnlockState) { state.Disabled = false }); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to store auto-unlock state")
+      return
+    }
+    writeJSON(w, http.StatusOK, currentAutoUnlockStatus())
+    return
+  }
+
+  // Disable first: LND must not be left pointing at an empty password file.
+  if err := removeWalletUnlockConfig(); err != nil {
+    s.logger.Printf("auto-unlock disable failed: %v", err)
+    writeError(w, http.StatusInternalServerError, "failed to update lnd.conf")
+    return
+  }
+  if err := updateAutoUnlockState(func(state *autoUnlockState) { state.Disabled = true }); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store auto-unlock state")
+    return
+  }
+  if req.WipePassword {
+    if err := wipeWalletPassword(); err != nil {
+      s.logger.Printf("auto-unlock wipe failed: %v", err)
+      writeError(w, http.StatusInternalServerError, "failed to wipe wallet password")
+      return
+    }
+  }
+  writeJSON(w, http.StatusOK, currentAutoUnlockStatus())
+}
+
+// wipeWalletPassword empties the password file. The file itself stays, with
+// its owner and mode, so it can be filled again later.
+func wipeWalletPassword() error {
+  if _, err := os.Stat(lndPasswordPath); err != nil {
+    if os.IsNotExist(err) {
+      return nil
+    }
+    return err
+  }
+  return writeFileAtomic(lndPasswordPath, nil, 0660)
+}
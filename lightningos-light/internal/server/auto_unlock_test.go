@@ -0,0 +1,25 @@
+package server
+
+import "testing"
+
+func TestUnlockConfRoundTrip(t *testing.T) {
+  raw := "[Application Options]\nalias=node\n\n[Bitcoin]\nbitcoin.mainnet=true\n"
+  if unlockConfConfigured(raw) {
+    t.Fatalf("plain config reported as configured")
+  }
+
+  enabled := ensureUnlockLines(raw)
+  if !unlockConfConfigured(enabled) {
+    t.Fatalf("unlock lines not detected:\n%s", enabled)
+  }
+
+  disabled := removeLNDConfOptions(enabled, "wallet-unlock-password-file", "wallet-unlock-allow-create")
+  if unlockConfConfigured(disabled) || disabled != raw {
+    t.Fatalf("unexpected config after disabling:\n%q", disabled)
+  }
+
+  other := "[Application Options]\nwallet-unlock-password-file=/tmp/elsewhere.txt\n"
+  if unlockConfConfigured(other) {
+    t.Fatalf("a different password file must not count as the managed one")
+  }
+}
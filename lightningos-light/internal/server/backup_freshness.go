@@ -0,0 +1,366 @@
+package server
+
+import (
+  "context"
+  "crypto/rand"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+
+  "golang.org/x/crypto/chacha20poly1305"
+)
+
+// Backup freshness covers the three things a dead disk takes with it: the
+// channel backup (SCB), secrets.env and the Postgres dumps. The manager
+// exports the SCB to disk once a day on its own; Telegram sends, secrets
+// downloads and local exports are recorded in backupStatePath, and dumps
+// are dated by their files. A backup older than its backups.*_max_age_hours
+// is a WARN, older than twice that an ERR, and both raise a notification.
+const (
+  backupStatePath = "/var/lib/lightningos/backup-state.json"
+  scbBackupDir = "/var/lib/lightningos/backups/scb"
+  scbBackupKeep = 7
+  scbExportInterval = 24 * time.Hour
+  backupCheckStartDelay = 2 * time.Minute
+  backupCheckInterval = time.Hour
+  secretsExportMagic = "LOSSEC01"
+)
+
+var backupKinds = []string{"scb", "secrets", "postgres"}
+
+var backupLabels = map[string]string{
+  "scb": "SCB export",
+  "secrets": "secrets backup",
+  "postgres": "Postgres dump",
+}
+
+var backupStateMu sync.Mutex
+
+type backupFreshness struct {
+  Kind string `json:"kind"`
+  LastAt *time.Time `json:"last_at,omitempty"`
+  AgeSec int64 `json:"age_sec,omitempty"`
+  MaxAgeSec int64 `json:"max_age_sec"`
+  Status string `json:"status"`
+}
+
+func readBackupState() map[string]time.Time {
+  state := map[string]time.Time{}
+  raw, err := os.ReadFile(backupStatePath)
+  if err != nil {
+    return state
+  }
+  _ = json.Unmarshal(raw, &state)
+  return state
+}
+
+// recordBackup notes a successful backup of kind, keeping the newest time.
+func recordBackup(kind string, at time.Time) {
+  backupStateMu.Lock()
+  defer backupStateMu.Unlock()
+  state := readBackupState()
+  if at.Before(state[kind]) {
+    return
+  }
+  state[kind] = at.UTC()
+  raw, err := json.Marshal(state)
+  if err != nil {
+    return
+  }
+  if err := os.MkdirAll(filepath.Dir(backupStatePath), 0o750); err != nil {
+    return
+  }
+  _ = writeFileAtomic(backupStatePath, raw, 0o600)
+}
+
+// lastPostgresDump returns the newest dump of the database backed up least
+// recently, zero when one has none. ok is false without Postgres.
+func lastPostgresDump() (time.Time, bool) {
+  entries := postgresDSNEntries()
+  if len(entries) == 0 {
+    return time.Time{}, false
+  }
+  var oldest time.Time
+  for _, entry := range entries {
+    dbName := databaseNameFromDSN(entry.DSN)
+    if dbName == "" {
+      continue
+    }
+    backups := postgresBackups(dbName)
+    if len(backups) == 0 {
+      return time.Time{}, true
+    }
+    if oldest.IsZero() || backups[0].CreatedAt.Before(oldest) {
+      oldest = backups[0].CreatedAt
+    }
+  }
+  return oldest, true
+}
+
+// backupLevel grades a backup taken at last (zero: never) against maxAge.
+func backupLevel(last time.Time, maxAge time.Duration, now time.Time) string {
+  switch {
+  case maxAge <= 0:
+    return "OFF"
+  case last.IsZero():
+    return "WARN"
+  case now.Sub(last) > 2*maxAge:
+    return "ERR"
+  case now.Sub(last) > maxAge:
+    return "WARN"
+  }
+  return "OK"
+}
+
+func (s *Server) backupFreshness(now time.Time) []backupFreshness {
+  state := readBackupState()
+  items := make([]backupFreshness, 0, len(backupKinds))
+  for _, kind := range backupKinds {
+    last := state[kind]
+    maxAge := s.cfg.Backups.MaxAge(kind)
+    if kind == "postgres" {
+      dumped, ok := lastPostgresDump()
+      if !ok {
+        maxAge = 0
+      }
+      last = dumped
+    }
+    item := backupFreshness{
+      Kind: kind,
+      MaxAgeSec: int64(maxAge / time.Second),
+      Status: backupLevel(last, maxAge, now),
+    }
+    if !last.IsZero() {
+      at := last.UTC()
+      item.LastAt = &at
+      item.AgeSec = int64(now.Sub(last) / time.Second)
+    }
+    items = append(items, item)
+  }
+  return items
+}
+
+func backupIssue(item backupFreshness) (healthIssue, bool) {
+  if item.Status != "WARN" && item.Status != "ERR" {
+    return healthIssue{}, false
+  }
+  label := backupLabels[item.Kind]
+  message := "Backup missing: no " + label + " yet"
+  if item.LastAt != nil {
+    message = fmt.Sprintf("Backup stale: %s is %s old (limit %s)", label, formatBackupAge(item.AgeSec), formatBackupAge(item.MaxAgeSec))
+  }
+  return healthIssue{Component: "backups", Level: item.Status, Message: message}, true
+}
+
+func formatBackupAge(seconds int64) string {
+  hours := seconds / 3600
+  if hours > 48 {
+    return fmt.Sprintf("%dd", hours/24)
+  }
+  return fmt.Sprintf("%dh", hours)
+}
+
+func (s *Server) checkBackupHealth() []healthIssue {
+  issues := []healthIssue{}
+  for _, item := range s.backupFreshness(time.Now()) {
+    if issue, ok := backupIssue(item); ok {
+      issues = append(issues, issue)
+    }
+  }
+  return issues
+}
+
+// exportLocalSCB writes the current channel backup under scbBackupDir,
+// keeping the last scbBackupKeep exports.
+func (s *Server) exportLocalSCB(ctx context.Context) error {
+  data, err := s.lnd.ExportAllChannelBackups(ctx)
+  if err != nil {
+    return err
+  }
+  if err := os.MkdirAll(scbBackupDir, 0o700); err != nil {
+    return err
+  }
+  now := time.Now().UTC()
+  name := fmt.Sprintf("channel-%s.backup", now.Format("20060102-150405"))
+  if err := writeFileAtomic(filepath.Join(scbBackupDir, name), data, 0o600); err != nil {
+    return err
+  }
+  if entries, err := os.ReadDir(scbBackupDir); err == nil {
+    names := []string{}
+    for _, entry := range entries {
+      if strings.HasPrefix(entry.Name(), "channel-") && strings.HasSuffix(entry.Name(), ".backup") {
+        names = append(names, entry.Name())
+      }
+    }
+    // The stamp sorts by time, so the oldest come first.
+    sort.Strings(names)
+    for i := 0; i < len(names)-scbBackupKeep; i++ {
+      _ = os.Remove(filepath.Join(scbBackupDir, names[i]))
+    }
+  }
+  recordBackup("scb", now)
+  return nil
+}
+
+func (s *Server) initBackupChecks() {
+  goSafe("backups", s.runBackupChecks)
+}
+
+// runBackupChecks exports the SCB when the last export is a day old and
+// notifies once per stale backup and level.
+func (s *Server) runBackupChecks() {
+  if !s.wait(backupCheckStartDelay) {
+    return
+  }
+  for {
+    if _, down := lndLifecycleIssue(s.lnd.Lifecycle()); !down {
+      if time.Since(readBackupState()["scb"]) >= scbExportInterval {
+        ctx, cancel := s.taskContext("backups/scb_export", timeoutLong)
+        if err := s.exportLocalSCB(ctx); err != nil {
+          s.logger.Printf("backups: scb export failed: %v", err)
+        }
+        cancel()
+      }
+    }
+    for _, item := range s.backupFreshness(time.Now()) {
+      s.notifyStaleBackup(item)
+    }
+    if !s.wait(backupCheckInterval) {
+      return
+    }
+  }
+}
+
+func (s *Server) notifyStaleBackup(item backupFreshness) {
+  issue, ok := backupIssue(item)
+  if !ok || s.notifier == nil {
+    return
+  }
+  status := "WARNING"
+  if issue.Level == "ERR" {
+    status = "ERROR"
+  }
+  var lastUnix int64
+  if item.LastAt != nil {
+    lastUnix = item.LastAt.Unix()
+  }
+  evt := Notification{
+    OccurredAt: time.Now().UTC(),
+    Type: "backup",
+    Action: "stale",
+    Direction: "neutral",
+    Status: status,
+    Memo: issue.Message,
+  }
+  ctx, cancel := s.taskContext("backups/notify", timeoutShort)
+  defer cancel()
+  key := fmt.Sprintf("backup:%s:%s:%d", item.Kind, issue.Level, lastUnix)
+  if _, err := s.notifier.upsertNotification(ctx, key, evt); err != nil {
+    s.logger.Printf("backups: notification failed: %v", err)
+  }
+}
+
+func (s *Server) handleBackupsStatus(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, map[string]any{"items": s.backupFreshness(time.Now())})
+}
+
+// sealSecretsExport encrypts secrets.env like the SCB escrow does, under
+// its own magic: secretsExportMagic, salt, nonce, then the sealed file.
+func sealSecretsExport(data []byte, passphrase string) ([]byte, error) {
+  salt := make([]byte, scbEscrowSaltSize)
+  if _, err := rand.Read(salt); err != nil {
+    return nil, err
+  }
+  key, err := deriveSCBEscrowKey(passphrase, salt)
+  if err != nil {
+    return nil, err
+  }
+  aead, err := chacha20poly1305.NewX(key)
+  if err != nil {
+    return nil, err
+  }
+  nonce := make([]byte, chacha20poly1305.NonceSizeX)
+  if _, err := rand.Read(nonce); err != nil {
+    return nil, err
+  }
+  header := append(append([]byte(secretsExportMagic), salt...), nonce...)
+  additional := append([]byte(nil), header...)
+  return aead.Seal(header, nonce, data, additional), nil
+}
+
+// OpenSecretsExport decrypts a secrets export with its passphrase, for
+// lightningos-manager secrets-decrypt.
+func OpenSecretsExport(blob []byte, passphrase string) ([]byte, error) {
+  headerSize := len(secretsExportMagic) + scbEscrowSaltSize + chacha20poly1305.NonceSizeX
+  if len(blob) <= headerSize || string(blob[:len(secretsExportMagic)]) != secretsExportMagic {
+    return nil, errors.New("not a LightningOS secrets export")
+  }
+  header := blob[:headerSize]
+  salt := header[len(secretsExportMagic) : len(secretsExportMagic)+scbEscrowSaltSize]
+  nonce := header[len(secretsExportMagic)+scbEscrowSaltSize:]
+  key, err := deriveSCBEscrowKey(passphrase, salt)
+  if err != nil {
+    return nil, err
+  }
+  aead, err := chacha20poly1305.NewX(key)
+  if err != nil {
+    return nil, err
+  }
+  plain, err := aead.Open(nil, nonce, blob[headerSize:], header)
+  if err != nil {
+    return nil, errors.New("wrong passphrase or corrupted blob")
+  }
+  return plain, nil
+}
+
+// handleBackupSecrets downloads secrets.env encrypted with the passphrase
+// in the body, which counts as the secrets backup. The file holds the
+// password hash, the TOTP secret and the RPC passwords, so client
+// certificates (automation) never get it.
+func (s *Server) handleBackupSecrets(w http.ResponseWriter, r *http.Request) {
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "secrets export is not available to client certificates")
+    return
+  }
+  var req struct {
+    Passphrase string `json:"passphrase"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if req.Passphrase == "" {
+    writeError(w, http.StatusBadRequest, "passphrase required")
+    return
+  }
+  if len(req.Passphrase) < scbEscrowMinPassphrase {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("passphrase must have at least %d characters", scbEscrowMinPassphrase))
+    return
+  }
+  unlock := lockConfigFile(secretsPath)
+  data, err := os.ReadFile(secretsPath)
+  unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to read secrets")
+    return
+  }
+  sealed, err := sealSecretsExport(data, req.Passphrase)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to prepare secrets")
+    return
+  }
+  now := time.Now().UTC()
+  recordBackup("secrets", now)
+  w.Header().Set("Content-Type", "application/octet-stream")
+  w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="lightningos-secrets-%s.env.enc"`, now.Format("20060102")))
+  w.Header().Set("Cache-Control", "no-store")
+  w.WriteHeader(http.StatusOK)
+  _, _ = w.Write(sealed)
+}
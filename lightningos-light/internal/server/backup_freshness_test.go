@@ -0,0 +1,73 @@
+package server
+
+import (
+  "bytes"
+  "net/http"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+)
+
+func TestBackupLevel(t *testing.T) {
+  now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+  maxAge := 48 * time.Hour
+  cases := []struct {
+    last time.Time
+    maxAge time.Duration
+    want string
+  }{
+    {now.Add(-time.Hour), maxAge, "OK"},
+    {now.Add(-50 * time.Hour), maxAge, "WARN"},
+    {now.Add(-97 * time.Hour), maxAge, "ERR"},
+    {time.Time{}, maxAge, "WARN"},
+    {time.Time{}, 0, "OFF"},
+  }
+  for _, tc := range cases {
+    if got := backupLevel(tc.last, tc.maxAge, now); got != tc.want {
+      t.Errorf("backupLevel(%v, %v) = %s, want %s", tc.last, tc.maxAge, got, tc.want)
+    }
+  }
+
+  cfg := config.BackupsConfig{SecretsMaxAgeHours: -1, PostgresMaxAgeHours: 12}
+  if cfg.MaxAge("scb") != 48*time.Hour || cfg.MaxAge("secrets") != 0 || cfg.MaxAge("postgres") != 12*time.Hour {
+    t.Fatalf("max ages = %v %v %v", cfg.MaxAge("scb"), cfg.MaxAge("secrets"), cfg.MaxAge("postgres"))
+  }
+
+  last := now.Add(-73 * time.Hour)
+  issue, ok := backupIssue(backupFreshness{Kind: "scb", LastAt: &last, AgeSec: 73 * 3600, MaxAgeSec: 48 * 3600, Status: "WARN"})
+  if !ok || issue.Component != "backups" || issue.Message != "Backup stale: SCB export is 3d old (limit 48h)" {
+    t.Fatalf("issue = %+v", issue)
+  }
+  if _, ok := backupIssue(backupFreshness{Kind: "postgres", Status: "OFF"}); ok {
+    t.Fatal("a disabled check raised an issue")
+  }
+}
+
+func TestSecretsExportRoundTrip(t *testing.T) {
+  data := []byte("API_AUTH_HASH=x\nAPI_TOTP_SECRET=y\n")
+  blob, err := sealSecretsExport(data, "correct horse battery")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if bytes.Contains(blob, []byte("API_TOTP_SECRET")) {
+    t.Fatal("export holds the secrets in the clear")
+  }
+  plain, err := OpenSecretsExport(blob, "correct horse battery")
+  if err != nil || !bytes.Equal(plain, data) {
+    t.Fatalf("open = %q, %v", plain, err)
+  }
+  if _, err := OpenSecretsExport(blob, "wrong horse battery"); err == nil {
+    t.Fatal("a wrong passphrase decrypted the export")
+  }
+}
+
+func TestBackupSecretsNeedsPassphrase(t *testing.T) {
+  s, _ := newFakeLNDServer(t)
+  for _, body := range []string{`{}`, `{"passphrase":"short"}`} {
+    rec := serveAPI(s, http.MethodPost, "/api/backups/secrets", body)
+    if rec.Code != http.StatusBadRequest {
+      t.Fatalf("%s: status %d: %s", body, rec.Code, rec.Body.String())
+    }
+  }
+}
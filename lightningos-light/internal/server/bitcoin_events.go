@@ -0,0 +1,234 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+  "sync"
+  "time"
+)
+
+// The chain tip watcher follows the active bitcoind (local or remote). It
+// polls getblockchaininfo every chainTipPollInterval and right away when
+// the rawblock ZMQ subscription sees a message. A new tip raises a "bitcoin"
+// block notification when bitcoin_events.block_notifications is on; a node
+// trailing the mempool explorers by bitcoin_events.behind_blocks for two
+// polls in a row raises a behind alert. Both reach SSE subscribers like any
+// other notification.
+const (
+  chainTipStartDelay = time.Minute
+  chainTipPollInterval = 30 * time.Second
+  chainTipZMQInterval = 5 * time.Second
+  chainBehindPolls = 2
+)
+
+type chainTip struct {
+  Height int64 `json:"height"`
+  Hash string `json:"hash,omitempty"`
+  SeenAt *time.Time `json:"seen_at,omitempty"`
+  Source string `json:"source"`
+  ExplorerHeight int64 `json:"explorer_height,omitempty"`
+  ExplorerCheckedAt *time.Time `json:"explorer_checked_at,omitempty"`
+  BehindBlocks int64 `json:"behind_blocks"`
+}
+
+type chainTipTracker struct {
+  mu sync.Mutex
+  tip chainTip
+  zmqEndpoint string
+  zmqLast time.Time
+  behindPolls int
+}
+
+func newChainTipTracker() *chainTipTracker {
+  return &chainTipTracker{}
+}
+
+func (t *chainTipTracker) snapshot() chainTip {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  return t.tip
+}
+
+// observe records the tip the node reports and returns how many blocks it
+// moved forward. The first sample, a reorg at the same height and an
+// unchanged tip all return 0.
+func (t *chainTipTracker) observe(source string, height int64, hash string, now time.Time) int64 {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  t.tip.Source = source
+  if hash == "" || hash == t.tip.Hash {
+    return 0
+  }
+  prev := t.tip.Height
+  seen := now.UTC()
+  t.tip.Height = height
+  t.tip.Hash = hash
+  t.tip.SeenAt = &seen
+  if prev == 0 || height <= prev {
+    return 0
+  }
+  return height - prev
+}
+
+// recordExplorer notes the explorers' tip and reports whether the behind
+// alert should fire: once per episode, after chainBehindPolls lagging polls.
+func (t *chainTipTracker) recordExplorer(height int64, threshold int64, now time.Time) bool {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  checked := now.UTC()
+  t.tip.ExplorerHeight = height
+  t.tip.ExplorerCheckedAt = &checked
+  lag := height - t.tip.Height
+  if lag < 0 {
+    lag = 0
+  }
+  t.tip.BehindBlocks = lag
+  if threshold <= 0 || lag < threshold {
+    t.behindPolls = 0
+    return false
+  }
+  t.behindPolls++
+  return t.behindPolls == chainBehindPolls
+}
+
+// zmqSignalled reports whether rawblock saw a message since the last call.
+func (t *chainTipTracker) zmqSignalled() bool {
+  t.mu.Lock()
+  endpoint := t.zmqEndpoint
+  t.mu.Unlock()
+  if endpoint == "" {
+    return false
+  }
+  last := zmqStatus(endpoint, "rawblock").LastMessageAt
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  if !last.After(t.zmqLast) {
+    return false
+  }
+  t.zmqLast = last
+  return true
+}
+
+func (t *chainTipTracker) setZMQEndpoint(endpoint string) {
+  t.mu.Lock()
+  t.zmqEndpoint = endpoint
+  t.mu.Unlock()
+}
+
+// activeBitcoinStatus reports on the bitcoind LND currently uses.
+func (s *Server) activeBitcoinStatus(ctx context.Context) (bitcoinStatus, error) {
+  if readBitcoinSource() == "local" {
+    return s.bitcoinLocalStatusActive(ctx)
+  }
+  return s.bitcoinStatus(ctx)
+}
+
+func (s *Server) initChainTipEvents() {
+  goSafe("bitcoin/events", s.runChainTipEvents)
+}
+
+func (s *Server) runChainTipEvents() {
+  if !s.wait(chainTipStartDelay) {
+    return
+  }
+  var lastPoll time.Time
+  for {
+    if time.Since(lastPoll) >= chainTipPollInterval || s.chainTip.zmqSignalled() {
+      lastPoll = time.Now()
+      s.pollChainTip()
+    }
+    if !s.wait(chainTipZMQInterval) {
+      return
+    }
+  }
+}
+
+func (s *Server) pollChainTip() {
+  ctx, cancel := s.taskContext("bitcoin/events", timeoutMedium)
+  defer cancel()
+  status, err := s.activeBitcoinStatus(ctx)
+  if err != nil || !status.RPCOk {
+    return
+  }
+  s.chainTip.setZMQEndpoint(status.ZMQRawBlock)
+  now := time.Now()
+  if moved := s.chainTip.observe(status.Mode, status.Blocks, status.BestBlockHash, now); moved > 0 && s.cfg.BitcoinEvents.BlockNotifications {
+    s.notifyNewBlock(ctx, status.Blocks, status.BestBlockHash, moved)
+  }
+  threshold := s.cfg.BitcoinEvents.BehindThreshold()
+  if threshold == 0 || status.InitialBlockDownload || s.mempool == nil {
+    return
+  }
+  blocks, err := s.mempool.recentBlocks(ctx)
+  if err != nil || len(blocks) == 0 {
+    return
+  }
+  if s.chainTip.recordExplorer(blocks[0].Height, threshold, now) {
+    s.notifyChainBehind(ctx, status.Blocks, blocks[0].Height)
+  }
+}
+
+// newBlockMemo describes the tip with the explorer's fee stats when they
+// are known (block is nil otherwise).
+func newBlockMemo(height int64, moved int64, block *mempoolBlock) string {
+  memo := fmt.Sprintf("Block %d", height)
+  if moved > 1 {
+    memo = fmt.Sprintf("%d new blocks, tip %d", moved, height)
+  }
+  if block == nil {
+    return memo
+  }
+  memo += fmt.Sprintf(": %d txs, median fee %.1f sat/vB", block.TxCount, block.Extras.MedianFee)
+  if n := len(block.Extras.FeeRange); n > 0 {
+    memo += fmt.Sprintf(" (%.1f-%.1f)", block.Extras.FeeRange[0], block.Extras.FeeRange[n-1])
+  }
+  return memo + fmt.Sprintf(", fees %d sat", block.Extras.TotalFees)
+}
+
+func (s *Server) notifyNewBlock(ctx context.Context, height int64, hash string, moved int64) {
+  if s.notifier == nil {
+    return
+  }
+  var stats *mempoolBlock
+  var feeSat int64
+  if s.mempool != nil {
+    if block, err := s.mempool.block(ctx, height); err == nil && block.ID == hash {
+      stats = &block
+      feeSat = block.Extras.TotalFees
+    }
+  }
+  evt := Notification{
+    OccurredAt: time.Now().UTC(),
+    Type: "bitcoin",
+    Action: "block",
+    Direction: "neutral",
+    Status: "CONFIRMED",
+    FeeSat: feeSat,
+    Memo: newBlockMemo(height, moved, stats),
+  }
+  if _, err := s.notifier.upsertNotification(ctx, fmt.Sprintf("bitcoin:block:%s", hash), evt); err != nil {
+    s.logger.Printf("bitcoin events: block notification failed: %v", err)
+  }
+}
+
+func (s *Server) notifyChainBehind(ctx context.Context, height int64, explorerHeight int64) {
+  if s.notifier == nil {
+    return
+  }
+  evt := Notification{
+    OccurredAt: time.Now().UTC(),
+    Type: "bitcoin",
+    Action: "behind",
+    Direction: "neutral",
+    Status: "WARNING",
+    Memo: fmt.Sprintf("Bitcoin node at block %d, explorers at %d (%d behind)", height, explorerHeight, explorerHeight-height),
+  }
+  if _, err := s.notifier.upsertNotification(ctx, fmt.Sprintf("bitcoin:behind:%d", height), evt); err != nil {
+    s.logger.Printf("bitcoin events: behind notification failed: %v", err)
+  }
+}
+
+func (s *Server) handleBitcoinTip(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, s.chainTip.snapshot())
+}
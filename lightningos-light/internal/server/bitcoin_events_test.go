@@ -0,0 +1,73 @@
+package server
+
+import (
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+)
+
+func TestChainTipObserve(t *testing.T) {
+  tracker := newChainTipTracker()
+  now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+  if moved := tracker.observe("local", 840000, "aa", now); moved != 0 {
+    t.Fatalf("first sample moved = %d", moved)
+  }
+  if moved := tracker.observe("local", 840000, "aa", now); moved != 0 {
+    t.Fatalf("unchanged tip moved = %d", moved)
+  }
+  if moved := tracker.observe("local", 840002, "bb", now); moved != 2 {
+    t.Fatalf("moved = %d, want 2", moved)
+  }
+  if moved := tracker.observe("local", 840002, "cc", now); moved != 0 {
+    t.Fatalf("reorg at same height moved = %d", moved)
+  }
+  if tip := tracker.snapshot(); tip.Height != 840002 || tip.Hash != "cc" || tip.Source != "local" {
+    t.Fatalf("tip = %+v", tip)
+  }
+}
+
+func TestChainTipBehindFiresOncePerEpisode(t *testing.T) {
+  tracker := newChainTipTracker()
+  now := time.Now()
+  tracker.observe("remote", 100, "aa", now)
+  fired := []bool{}
+  for _, explorer := range []int64{104, 104, 105, 100, 104, 104} {
+    fired = append(fired, tracker.recordExplorer(explorer, 3, now))
+  }
+  want := []bool{false, true, false, false, false, true}
+  for i := range want {
+    if fired[i] != want[i] {
+      t.Fatalf("fired = %v, want %v", fired, want)
+    }
+  }
+  if tip := tracker.snapshot(); tip.BehindBlocks != 4 || tip.ExplorerHeight != 104 {
+    t.Fatalf("tip = %+v", tip)
+  }
+  if tracker.recordExplorer(200, 0, now) {
+    t.Fatal("alert fired while disabled")
+  }
+
+  cfg := config.BitcoinEventsConfig{}
+  if cfg.BehindThreshold() != config.DefaultBitcoinBehindBlocks {
+    t.Fatalf("default threshold = %d", cfg.BehindThreshold())
+  }
+  cfg.BehindBlocks = -1
+  if cfg.BehindThreshold() != 0 {
+    t.Fatalf("disabled threshold = %d", cfg.BehindThreshold())
+  }
+}
+
+func TestNewBlockMemo(t *testing.T) {
+  if got := newBlockMemo(840000, 1, nil); got != "Block 840000" {
+    t.Fatalf("memo = %q", got)
+  }
+  block := mempoolBlock{Height: 840000, TxCount: 3050}
+  block.Extras.MedianFee = 12.4
+  block.Extras.FeeRange = []float64{2, 5, 300}
+  block.Extras.TotalFees = 25000000
+  want := "2 new blocks, tip 840000: 3050 txs, median fee 12.4 sat/vB (2.0-300.0), fees 25000000 sat"
+  if got := newBlockMemo(840000, 2, &block); got != want {
+    t.Fatalf("memo = %q, want %q", got, want)
+  }
+}
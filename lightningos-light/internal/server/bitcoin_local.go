@@ -15,7 +15,6 @@ import (
   "sync"
   "time"
 
-  "lightningos-light/internal/system"
 )
 
 const (
@@ -115,7 +114,7 @@ func (s *Server) handleBitcoinLocalStatus(w http.ResponseWriter, r *http.Request
   }
   resp.Installed = true
 
-  ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
   status, err := getComposeStatus(ctx, paths.Root, paths.ComposePath, "bitcoind")
@@ -174,7 +173,7 @@ func (s *Server) handleBitcoinLocalConfigGet(w http.ResponseWriter, r *http.Requ
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
   raw, err := readBitcoinCoreConfig(ctx, paths)
@@ -225,7 +224,7 @@ func (s *Server) handleBitcoinLocalConfigPost(w http.ResponseWriter, r *http.Req
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
   defer cancel()
 
   raw, err := readBitcoinCoreConfig(ctx, paths)
@@ -383,7 +382,7 @@ func execBitcoinCLI(ctx context.Context, paths bitcoinCorePaths, args ...string)
     "-rpcwait",
     "-rpcwaittimeout=5",
   }, args...)
-  out, err := system.RunCommandWithSudo(ctx, "docker", cliArgs...)
+  out, err := containerCLI(ctx, cliArgs...)
   if err != nil {
     return "", err
   }
@@ -400,7 +399,7 @@ func readBitcoinCoreConfig(ctx context.Context, paths bitcoinCorePaths) (string,
 
   containerID, err := composeContainerID(ctx, paths.Root, paths.ComposePath, "bitcoind")
   if err == nil && containerID != "" {
-    out, execErr := system.RunCommandWithSudo(ctx, "docker", "exec", "-i", containerID, "sh", "-c", "cat "+bitcoinCoreConfigPathInContainer)
+    out, execErr := containerCLI(ctx, "exec", "-i", containerID, "sh", "-c", "cat "+bitcoinCoreConfigPathInContainer)
     if execErr == nil {
       return sanitizeBitcoinCoreConfig(out), nil
     }
@@ -409,9 +408,8 @@ func readBitcoinCoreConfig(ctx context.Context, paths bitcoinCorePaths) (string,
   if err := ensureBitcoinCoreImage(ctx); err != nil {
     return "", err
   }
-  out, err := system.RunCommandWithSudo(
+  out, err := containerCLI(
     ctx,
-    "docker",
     "run",
     "--rm",
     "--entrypoint",
@@ -459,9 +457,8 @@ func writeBitcoinCoreConfig(ctx context.Context, paths bitcoinCorePaths, content
   if err := ensureBitcoinCoreImage(ctx); err != nil {
     return err
   }
-  out, err := system.RunCommandWithSudo(
+  out, err := containerCLI(
     ctx,
-    "docker",
     "run",
     "--rm",
     "--entrypoint",
@@ -0,0 +1,168 @@
+package server
+
+import (
+  "crypto/hmac"
+  "crypto/rand"
+  "crypto/sha256"
+  "encoding/base64"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+)
+
+// bitcoind writes a fresh .cookie ("__cookie__:<hex>") on every start when
+// no rpcuser/rpcpassword is configured. Cookies are cached by path and
+// re-read whenever the file's mtime or size changes, so a restarted node is
+// picked up on the next call without restarting the manager.
+const bitcoinCookieUser = "__cookie__"
+
+type bitcoinCookie struct {
+  modTime time.Time
+  size int64
+  user string
+  pass string
+}
+
+var (
+  bitcoinCookieMu sync.Mutex
+  bitcoinCookies = map[string]bitcoinCookie{}
+)
+
+func readBitcoinCookie(path string) (string, string, error) {
+  info, err := os.Stat(path)
+  if err != nil {
+    return "", "", err
+  }
+  bitcoinCookieMu.Lock()
+  defer bitcoinCookieMu.Unlock()
+  if cached, ok := bitcoinCookies[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+    return cached.user, cached.pass, nil
+  }
+  raw, err := os.ReadFile(path)
+  if err != nil {
+    return "", "", err
+  }
+  user, pass, ok := strings.Cut(strings.TrimSpace(string(raw)), ":")
+  if !ok || user == "" || pass == "" {
+    return "", "", errors.New("invalid bitcoin cookie file")
+  }
+  bitcoinCookies[path] = bitcoinCookie{modTime: info.ModTime(), size: info.Size(), user: user, pass: pass}
+  return user, pass, nil
+}
+
+// bitcoinCookiePath finds the cookie for the bitcoin.conf in raw living in
+// dataDir: rpccookiefile when set, otherwise .cookie in the network's
+// subdirectory.
+func bitcoinCookiePath(dataDir string, raw string) string {
+  cookieFile := ""
+  network := ""
+  for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+    trimmed := strings.TrimSpace(line)
+    if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+      continue
+    }
+    if strings.HasPrefix(trimmed, "[") {
+      break
+    }
+    key, value, ok := strings.Cut(trimmed, "=")
+    if !ok {
+      continue
+    }
+    key = strings.TrimSpace(key)
+    value = strings.TrimSpace(value)
+    switch key {
+    case "rpccookiefile":
+      cookieFile = value
+    case "chain":
+      network = value
+    case "testnet", "signet", "regtest":
+      if value == "1" {
+        network = key
+      }
+    }
+  }
+  dir := dataDir
+  switch network {
+  case "test", "testnet":
+    dir = filepath.Join(dataDir, "testnet3")
+  case "signet", "regtest":
+    dir = filepath.Join(dataDir, network)
+  }
+  if cookieFile == "" {
+    return filepath.Join(dir, ".cookie")
+  }
+  if filepath.IsAbs(cookieFile) {
+    return cookieFile
+  }
+  return filepath.Join(dir, cookieFile)
+}
+
+// generateRPCAuth builds a bitcoin.conf rpcauth value the way Bitcoin
+// Core's share/rpcauth/rpcauth.py does: a random hex salt and the
+// HMAC-SHA256 of the password keyed by that salt.
+func generateRPCAuth(user, password string) (string, error) {
+  salt := make([]byte, 16)
+  if _, err := rand.Read(salt); err != nil {
+    return "", err
+  }
+  return rpcAuthLine(user, password, hex.EncodeToString(salt)), nil
+}
+
+func rpcAuthLine(user, password, salt string) string {
+  mac := hmac.New(sha256.New, []byte(salt))
+  mac.Write([]byte(password))
+  return fmt.Sprintf("%s:%s$%s", user, salt, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func generateRPCPassword() (string, error) {
+  buf := make([]byte, 32)
+  if _, err := rand.Read(buf); err != nil {
+    return "", err
+  }
+  return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// handleBitcoinRPCAuth returns an rpcauth line for a remote bitcoin.conf.
+// The password is generated when none is given and is never stored; save
+// it through the wizard once the remote node accepts it.
+func (s *Server) handleBitcoinRPCAuth(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    User string `json:"user"`
+    Password string `json:"password"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  user := strings.TrimSpace(req.User)
+  if user == "" || strings.ContainsAny(user, ":$ \t\r\n") {
+    writeError(w, http.StatusBadRequest, "invalid rpc user")
+    return
+  }
+  password := strings.TrimSpace(req.Password)
+  if password == "" {
+    generated, err := generateRPCPassword()
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to generate password")
+      return
+    }
+    password = generated
+  }
+  auth, err := generateRPCAuth(user, password)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to generate rpcauth")
+    return
+  }
+  w.Header().Set("Cache-Control", "no-store")
+  writeJSON(w, http.StatusOK, map[string]string{
+    "user": user,
+    "password": password,
+    "rpcauth": "rpcauth=" + auth,
+  })
+}
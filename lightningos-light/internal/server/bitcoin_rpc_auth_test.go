@@ -0,0 +1,70 @@
+package server
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestRPCAuthLine(t *testing.T) {
+  got := rpcAuthLine("lightningos", "hunter2", "cb77f0957de88ff388cf817ddbc7273")
+  want := "lightningos:cb77f0957de88ff388cf817ddbc7273$643441f3b505f7761662443d439265df618d1cc4303203497cb48b83ed46338d"
+  if got != want {
+    t.Fatalf("rpcAuthLine = %s, want %s", got, want)
+  }
+  generated, err := generateRPCAuth("lightningos", "hunter2")
+  if err != nil {
+    t.Fatal(err)
+  }
+  user, rest, _ := strings.Cut(generated, ":")
+  salt, _, _ := strings.Cut(rest, "$")
+  if user != "lightningos" || len(salt) != 32 || generated != rpcAuthLine("lightningos", "hunter2", salt) {
+    t.Fatalf("generateRPCAuth = %s", generated)
+  }
+}
+
+func TestBitcoinCookiePath(t *testing.T) {
+  cases := map[string]string{
+    "rpcport=8332\n": "/data/bitcoin/.cookie",
+    "testnet=1\n": "/data/bitcoin/testnet3/.cookie",
+    "chain=signet\n": "/data/bitcoin/signet/.cookie",
+    "rpccookiefile=auth/rpc.cookie\n": "/data/bitcoin/auth/rpc.cookie",
+    "rpccookiefile=/run/bitcoind/.cookie\n[test]\nrpccookiefile=x\n": "/run/bitcoind/.cookie",
+  }
+  for raw, want := range cases {
+    if got := bitcoinCookiePath("/data/bitcoin", raw); got != want {
+      t.Errorf("bitcoinCookiePath(%q) = %s, want %s", raw, got, want)
+    }
+  }
+}
+
+func TestReadBitcoinCookieRotation(t *testing.T) {
+  path := filepath.Join(t.TempDir(), ".cookie")
+  if err := os.WriteFile(path, []byte("__cookie__:first\n"), 0o600); err != nil {
+    t.Fatal(err)
+  }
+  user, pass, err := readBitcoinCookie(path)
+  if err != nil || user != bitcoinCookieUser || pass != "first" {
+    t.Fatalf("readBitcoinCookie = %s %s %v", user, pass, err)
+  }
+
+  if err := os.WriteFile(path, []byte("__cookie__:second"), 0o600); err != nil {
+    t.Fatal(err)
+  }
+  later := time.Now().Add(time.Minute)
+  if err := os.Chtimes(path, later, later); err != nil {
+    t.Fatal(err)
+  }
+  if _, pass, _ := readBitcoinCookie(path); pass != "second" {
+    t.Fatalf("rotated cookie pass = %s", pass)
+  }
+
+  if err := os.WriteFile(path, []byte("garbage"), 0o600); err != nil {
+    t.Fatal(err)
+  }
+  if _, _, err := readBitcoinCookie(path); err == nil {
+    t.Fatal("expected error for invalid cookie")
+  }
+}
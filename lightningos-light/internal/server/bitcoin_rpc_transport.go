@@ -0,0 +1,177 @@
+package server
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "crypto/tls"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "net"
+  "net/http"
+  "net/url"
+  "strings"
+  "sync/atomic"
+
+  "golang.org/x/net/proxy"
+
+  "lightningos-light/internal/config"
+)
+
+// The remote bitcoind is often a relative's node across the internet:
+// reachable as an onion service or over HTTPS with a self-signed
+// certificate. bitcoin_remote.rpc_proxy and rpc_tls_fingerprint give the
+// manager's RPC calls to rpchost their own client; local calls keep using
+// the default one. LND dials bitcoind by itself and needs tor.active in
+// lnd.conf for an onion rpchost.
+const defaultTorProxy = "socks5h://127.0.0.1:9050"
+
+type bitcoinRPCRoute struct {
+  host string
+  pinned bool
+  client *http.Client
+}
+
+var (
+  bitcoinRemoteRoute atomic.Pointer[bitcoinRPCRoute]
+  bitcoinTorClient atomic.Pointer[http.Client]
+)
+
+// bitcoinRPCHostKey reduces an rpchost ("host:port" or a URL) to the
+// lowercase host:port it is dialed at.
+func bitcoinRPCHostKey(raw string) string {
+  raw = strings.TrimSpace(raw)
+  if raw == "" {
+    return ""
+  }
+  if !strings.Contains(raw, "://") {
+    raw = "http://" + raw
+  }
+  parsed, err := url.Parse(raw)
+  if err != nil {
+    return ""
+  }
+  return strings.ToLower(parsed.Host)
+}
+
+func isOnionHost(hostport string) bool {
+  host := hostport
+  if h, _, err := net.SplitHostPort(hostport); err == nil {
+    host = h
+  }
+  return strings.HasSuffix(strings.ToLower(host), ".onion")
+}
+
+func parseCertFingerprint(raw string) ([]byte, error) {
+  cleaned := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(raw)))
+  pin, err := hex.DecodeString(cleaned)
+  if err != nil || len(pin) != sha256.Size {
+    return nil, errors.New("rpc_tls_fingerprint must be a SHA-256 hex digest")
+  }
+  return pin, nil
+}
+
+// newBitcoinRPCClient builds a client that dials through proxyURL (empty:
+// direct) and, with a pin, trusts only the certificate whose SHA-256
+// matches it.
+func newBitcoinRPCClient(proxyURL string, pin []byte) (*http.Client, error) {
+  transport := http.DefaultTransport.(*http.Transport).Clone()
+  if proxyURL != "" {
+    parsed, err := url.Parse(proxyURL)
+    if err != nil {
+      return nil, fmt.Errorf("invalid rpc_proxy: %w", err)
+    }
+    if parsed.Scheme != "socks5" && parsed.Scheme != "socks5h" {
+      return nil, errors.New("rpc_proxy must be a socks5:// URL")
+    }
+    dialer, err := proxy.FromURL(parsed, proxy.Direct)
+    if err != nil {
+      return nil, fmt.Errorf("invalid rpc_proxy: %w", err)
+    }
+    contextDialer, ok := dialer.(proxy.ContextDialer)
+    if !ok {
+      return nil, errors.New("rpc_proxy dialer does not support contexts")
+    }
+    transport.Proxy = nil
+    transport.DialContext = contextDialer.DialContext
+  }
+  if len(pin) > 0 {
+    transport.TLSClientConfig = &tls.Config{
+      MinVersion: tls.VersionTLS12,
+      // The chain is not verified: the pin below is the trust anchor.
+      InsecureSkipVerify: true,
+      VerifyConnection: func(state tls.ConnectionState) error {
+        if len(state.PeerCertificates) == 0 {
+          return errors.New("bitcoin rpc: no server certificate")
+        }
+        sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+        if !bytes.Equal(sum[:], pin) {
+          return fmt.Errorf("bitcoin rpc: certificate fingerprint %s does not match the pinned one", hex.EncodeToString(sum[:]))
+        }
+        return nil
+      },
+    }
+  }
+  return &http.Client{Transport: transport}, nil
+}
+
+// configureBitcoinRPC sets up the route to the remote rpchost. Without a
+// proxy, a pin or an onion host the default client is kept.
+func configureBitcoinRPC(cfg config.BitcoinRemoteConfig) error {
+  bitcoinRemoteRoute.Store(nil)
+  host := bitcoinRPCHostKey(cfg.RPCHost)
+  proxyURL := strings.TrimSpace(cfg.RPCProxy)
+  if proxyURL == "" && isOnionHost(host) {
+    proxyURL = defaultTorProxy
+  }
+  var pin []byte
+  if strings.TrimSpace(cfg.RPCTLSFingerprint) != "" {
+    parsed, err := parseCertFingerprint(cfg.RPCTLSFingerprint)
+    if err != nil {
+      return err
+    }
+    pin = parsed
+  }
+  if host == "" || (proxyURL == "" && pin == nil) {
+    return nil
+  }
+  client, err := newBitcoinRPCClient(proxyURL, pin)
+  if err != nil {
+    return err
+  }
+  bitcoinRemoteRoute.Store(&bitcoinRPCRoute{host: host, pinned: pin != nil, client: client})
+  return nil
+}
+
+func remoteBitcoinRoute(host string) *bitcoinRPCRoute {
+  route := bitcoinRemoteRoute.Load()
+  if route == nil || route.host != bitcoinRPCHostKey(host) {
+    return nil
+  }
+  return route
+}
+
+// bitcoinRPCClient picks the client for an RPC URL: the remote route when
+// it targets rpchost, Tor for any other onion host, the default otherwise.
+func bitcoinRPCClient(rawURL string) *http.Client {
+  if route := remoteBitcoinRoute(rawURL); route != nil {
+    return route.client
+  }
+  if isOnionHost(bitcoinRPCHostKey(rawURL)) {
+    if client := bitcoinTorClient.Load(); client != nil {
+      return client
+    }
+    client, err := newBitcoinRPCClient(defaultTorProxy, nil)
+    if err == nil {
+      bitcoinTorClient.Store(client)
+      return client
+    }
+  }
+  return http.DefaultClient
+}
+
+// bitcoinRPCPinned reports whether host must be dialed over HTTPS.
+func bitcoinRPCPinned(host string) bool {
+  route := remoteBitcoinRoute(host)
+  return route != nil && route.pinned
+}
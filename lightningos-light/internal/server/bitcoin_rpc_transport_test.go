@@ -0,0 +1,68 @@
+package server
+
+import (
+  "context"
+  "crypto/sha256"
+  "encoding/hex"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+
+  "lightningos-light/internal/config"
+)
+
+func TestBitcoinRPCHostKey(t *testing.T) {
+  cases := map[string]string{
+    "Node.example:8332": "node.example:8332",
+    "https://node.example:8332/": "node.example:8332",
+    "abc.onion:8332": "abc.onion:8332",
+    "": "",
+  }
+  for raw, want := range cases {
+    if got := bitcoinRPCHostKey(raw); got != want {
+      t.Errorf("bitcoinRPCHostKey(%q) = %q, want %q", raw, got, want)
+    }
+  }
+  if !isOnionHost("abc.onion:8332") || isOnionHost("onion.example:8332") {
+    t.Fatal("isOnionHost mismatch")
+  }
+  if _, err := parseCertFingerprint("AB:CD"); err == nil {
+    t.Fatal("expected error for short fingerprint")
+  }
+}
+
+func TestBitcoinRPCPinnedCertificate(t *testing.T) {
+  srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    _, _ = w.Write([]byte(`{"result":{"chain":"main","blocks":840000},"error":null}`))
+  }))
+  defer srv.Close()
+  t.Cleanup(func() { bitcoinRemoteRoute.Store(nil) })
+
+  host := strings.TrimPrefix(srv.URL, "https://")
+  sum := sha256.Sum256(srv.Certificate().Raw)
+  pin := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+  if err := configureBitcoinRPC(config.BitcoinRemoteConfig{RPCHost: host, RPCTLSFingerprint: pin}); err != nil {
+    t.Fatal(err)
+  }
+  if !bitcoinRPCPinned(host) {
+    t.Fatal("expected host to be pinned")
+  }
+  info, err := fetchBitcoinInfo(context.Background(), host, "user", "pass")
+  if err != nil || info.Blocks != 840000 {
+    t.Fatalf("pinned fetch = %+v, %v", info, err)
+  }
+
+  wrong := strings.Repeat("00", sha256.Size)
+  if err := configureBitcoinRPC(config.BitcoinRemoteConfig{RPCHost: host, RPCTLSFingerprint: wrong}); err != nil {
+    t.Fatal(err)
+  }
+  if _, err := fetchBitcoinInfo(context.Background(), host, "user", "pass"); err == nil || !strings.Contains(err.Error(), "does not match") {
+    t.Fatalf("expected pin mismatch, got %v", err)
+  }
+
+  if err := configureBitcoinRPC(config.BitcoinRemoteConfig{RPCHost: host, RPCProxy: "http://127.0.0.1:8080"}); err == nil {
+    t.Fatal("expected error for non-socks proxy")
+  }
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "math"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+// The sync tracker samples the local bitcoind's verificationprogress while
+// it is in initial block download, so the UI can show a speed and an ETA
+// instead of a bare percentage. The rate comes from the last hour of
+// samples: early blocks verify much faster than recent ones, so an average
+// since the start would promise too much. Samples live in bitcoinSyncPath
+// and survive restarts; a node that went backwards (reindex, new data dir)
+// starts a new history.
+const (
+  bitcoinSyncPath = "/var/lib/lightningos/bitcoin-sync.json"
+  bitcoinSyncInterval = 5 * time.Minute
+  bitcoinSyncRateWindow = time.Hour
+  bitcoinSyncMinSpan = 10 * time.Minute
+  bitcoinSyncMaxSamples = 4000
+  bitcoinSyncDefaultHours = 48
+  bitcoinSyncMaxHours = 30 * 24
+)
+
+type bitcoinSyncSample struct {
+  At time.Time `json:"at"`
+  Blocks int64 `json:"blocks"`
+  Headers int64 `json:"headers"`
+  Progress float64 `json:"progress"`
+}
+
+type bitcoinSyncState struct {
+  StartedAt *time.Time `json:"started_at,omitempty"`
+  CompletedAt *time.Time `json:"completed_at,omitempty"`
+  Samples []bitcoinSyncSample `json:"samples"`
+}
+
+type bitcoinSyncView struct {
+  Available bool `json:"available"`
+  Syncing bool `json:"syncing"`
+  Blocks int64 `json:"blocks"`
+  Headers int64 `json:"headers"`
+  Progress float64 `json:"progress"`
+  ProgressPerHour float64 `json:"progress_per_hour"`
+  BlocksPerHour float64 `json:"blocks_per_hour"`
+  ETASec int64 `json:"eta_sec,omitempty"`
+  ETAAt *time.Time `json:"eta_at,omitempty"`
+  StartedAt *time.Time `json:"started_at,omitempty"`
+  CompletedAt *time.Time `json:"completed_at,omitempty"`
+  Samples []bitcoinSyncSample `json:"samples"`
+}
+
+type bitcoinSyncTracker struct {
+  path string
+  mu sync.Mutex
+  state bitcoinSyncState
+  loaded bool
+}
+
+func newBitcoinSyncTracker(path string) *bitcoinSyncTracker {
+  return &bitcoinSyncTracker{path: path}
+}
+
+func (t *bitcoinSyncTracker) loadLocked() {
+  if t.loaded {
+    return
+  }
+  t.loaded = true
+  raw, err := os.ReadFile(t.path)
+  if err != nil {
+    return
+  }
+  _ = json.Unmarshal(raw, &t.state)
+}
+
+func (t *bitcoinSyncTracker) saveLocked() error {
+  raw, err := json.Marshal(t.state)
+  if err != nil {
+    return err
+  }
+  if err := os.MkdirAll(filepath.Dir(t.path), 0o750); err != nil {
+    return err
+  }
+  return writeFileAtomic(t.path, raw, 0o640)
+}
+
+// record adds a sample while the node is syncing and closes the history
+// once it caught up.
+func (t *bitcoinSyncTracker) record(sample bitcoinSyncSample, syncing bool) error {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  t.loadLocked()
+  samples := t.state.Samples
+  if n := len(samples); n > 0 && sample.Blocks < samples[n-1].Blocks {
+    t.state = bitcoinSyncState{}
+    samples = nil
+  }
+  if !syncing {
+    if t.state.StartedAt == nil || t.state.CompletedAt != nil {
+      return nil
+    }
+    at := sample.At.UTC()
+    t.state.CompletedAt = &at
+    t.state.Samples = append(samples, sample)
+    return t.saveLocked()
+  }
+  if t.state.StartedAt == nil || t.state.CompletedAt != nil {
+    at := sample.At.UTC()
+    t.state = bitcoinSyncState{StartedAt: &at}
+    samples = nil
+  }
+  samples = append(samples, sample)
+  if len(samples) > bitcoinSyncMaxSamples {
+    samples = thinSyncSamples(samples)
+  }
+  t.state.Samples = samples
+  return t.saveLocked()
+}
+
+// thinSyncSamples drops every other sample of the older half, keeping the
+// recent ones the rate is computed from at full resolution.
+func thinSyncSamples(samples []bitcoinSyncSample) []bitcoinSyncSample {
+  half := len(samples) / 2
+  out := make([]bitcoinSyncSample, 0, len(samples))
+  for i := 0; i < half; i += 2 {
+    out = append(out, samples[i])
+  }
+  return append(out, samples[half:]...)
+}
+
+// syncRate returns the progress and blocks gained per hour over the last
+// bitcoinSyncRateWindow, falling back to the whole history while it is
+// shorter than bitcoinSyncMinSpan.
+func syncRate(samples []bitcoinSyncSample) (float64, float64) {
+  n := len(samples)
+  if n < 2 {
+    return 0, 0
+  }
+  last := samples[n-1]
+  first := samples[0]
+  for i := n - 2; i >= 0; i-- {
+    if last.At.Sub(samples[i].At) > bitcoinSyncRateWindow {
+      break
+    }
+    first = samples[i]
+  }
+  span := last.At.Sub(first.At)
+  if span < bitcoinSyncMinSpan {
+    first = samples[0]
+    span = last.At.Sub(first.At)
+  }
+  if span <= 0 {
+    return 0, 0
+  }
+  hours := span.Hours()
+  return (last.Progress - first.Progress) / hours, float64(last.Blocks-first.Blocks) / hours
+}
+
+func (t *bitcoinSyncTracker) view(since time.Time) bitcoinSyncView {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  t.loadLocked()
+  view := bitcoinSyncView{
+    StartedAt: t.state.StartedAt,
+    CompletedAt: t.state.CompletedAt,
+    Samples: []bitcoinSyncSample{},
+  }
+  samples := t.state.Samples
+  if len(samples) == 0 {
+    return view
+  }
+  view.Available = true
+  view.Syncing = t.state.CompletedAt == nil
+  last := samples[len(samples)-1]
+  view.Blocks = last.Blocks
+  view.Headers = last.Headers
+  view.Progress = last.Progress
+  for _, sample := range samples {
+    if !sample.At.Before(since) {
+      view.Samples = append(view.Samples, sample)
+    }
+  }
+  if !view.Syncing {
+    return view
+  }
+  view.ProgressPerHour, view.BlocksPerHour = syncRate(samples)
+  if view.ProgressPerHour > 0 {
+    remaining := (1 - last.Progress) / view.ProgressPerHour
+    view.ETASec = int64(math.Round(remaining * 3600))
+    eta := last.At.Add(time.Duration(view.ETASec) * time.Second).UTC()
+    view.ETAAt = &eta
+  }
+  return view
+}
+
+// localBitcoinChainInfo asks the local bitcoind for getblockchaininfo,
+// through bitcoin-cli in the app container or RPC for a bitcoind installed
+// outside the app store.
+func localBitcoinChainInfo(ctx context.Context) (bitcoinInfo, error) {
+  paths := bitcoinCoreAppPaths()
+  if fileExists(paths.ComposePath) {
+    out, err := execBitcoinCLI(ctx, paths, "getblockchaininfo")
+    if err != nil {
+      return bitcoinInfo{}, err
+    }
+    var info bitcoinInfo
+    err = json.Unmarshal([]byte(out), &info)
+    return info, err
+  }
+  if readBitcoinSource() != "local" {
+    return bitcoinInfo{}, errors.New("local bitcoin not configured")
+  }
+  cfg, _, err := readBitcoinLocalRPCConfig(ctx)
+  if err != nil {
+    return bitcoinInfo{}, err
+  }
+  return fetchBitcoinInfo(ctx, cfg.Host, cfg.User, cfg.Pass)
+}
+
+func (s *Server) initBitcoinSync() {
+  goSafe("bitcoin/sync", s.runBitcoinSync)
+}
+
+func (s *Server) runBitcoinSync() {
+  for {
+    ctx, cancel := s.taskContext("bitcoin/sync", timeoutMedium)
+    info, err := localBitcoinChainInfo(ctx)
+    cancel()
+    if err == nil && info.Blocks > 0 {
+      sample := bitcoinSyncSample{
+        At: time.Now().UTC(),
+        Blocks: info.Blocks,
+        Headers: info.Headers,
+        Progress: info.VerificationProgress,
+      }
+      if err := s.bitcoinSync.record(sample, info.InitialBlockDownload); err != nil {
+        s.logger.Printf("bitcoin sync: failed to store sample: %v", err)
+      }
+    }
+    if !s.wait(bitcoinSyncInterval) {
+      return
+    }
+  }
+}
+
+// handleBitcoinSync reports the local sync with ?hours= of history
+// (default 48, at most 30 days).
+func (s *Server) handleBitcoinSync(w http.ResponseWriter, r *http.Request) {
+  hours := bitcoinSyncDefaultHours
+  if raw := strings.TrimSpace(r.URL.Query().Get("hours")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed <= 0 || parsed > bitcoinSyncMaxHours {
+      writeError(w, http.StatusBadRequest, "invalid hours")
+      return
+    }
+    hours = parsed
+  }
+  since := time.Now().Add(-time.Duration(hours) * time.Hour)
+  writeJSON(w, http.StatusOK, s.bitcoinSync.view(since))
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func TestBitcoinSyncTracker(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "bitcoin-sync.json")
+  tracker := newBitcoinSyncTracker(path)
+  start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+  if err := tracker.record(bitcoinSyncSample{At: start, Blocks: 840000, Headers: 840000, Progress: 0.9999}, false); err != nil {
+    t.Fatal(err)
+  }
+  if view := tracker.view(time.Time{}); view.Available {
+    t.Fatalf("synced node without history should have no view: %+v", view)
+  }
+
+  // Fast early blocks, then two slower hours: the ETA follows the last hour.
+  samples := []bitcoinSyncSample{
+    {At: start, Blocks: 100000, Headers: 840000, Progress: 0.10},
+    {At: start.Add(time.Hour), Blocks: 300000, Headers: 840000, Progress: 0.30},
+    {At: start.Add(2 * time.Hour), Blocks: 350000, Headers: 840000, Progress: 0.35},
+    {At: start.Add(3 * time.Hour), Blocks: 400000, Headers: 840000, Progress: 0.40},
+  }
+  for _, sample := range samples {
+    if err := tracker.record(sample, true); err != nil {
+      t.Fatal(err)
+    }
+  }
+  view := tracker.view(start.Add(90 * time.Minute))
+  if !view.Syncing || len(view.Samples) != 2 || view.Blocks != 400000 {
+    t.Fatalf("view = %+v", view)
+  }
+  if view.BlocksPerHour != 50000 || view.ETASec != 12*3600 {
+    t.Fatalf("rate = %v blocks/h, eta = %ds", view.BlocksPerHour, view.ETASec)
+  }
+  if view.ETAAt == nil || !view.ETAAt.Equal(start.Add(15*time.Hour)) {
+    t.Fatalf("eta_at = %v", view.ETAAt)
+  }
+
+  // The history is on disk and survives a restart.
+  reloaded := newBitcoinSyncTracker(path)
+  if view := reloaded.view(time.Time{}); len(view.Samples) != 4 || view.StartedAt == nil || !view.StartedAt.Equal(start) {
+    t.Fatalf("reloaded view = %+v", view)
+  }
+
+  if err := reloaded.record(bitcoinSyncSample{At: start.Add(20 * time.Hour), Blocks: 840000, Headers: 840000, Progress: 1}, false); err != nil {
+    t.Fatal(err)
+  }
+  done := reloaded.view(time.Time{})
+  if done.Syncing || done.CompletedAt == nil || done.ETASec != 0 {
+    t.Fatalf("completed view = %+v", done)
+  }
+
+  // A reindex starts a new history.
+  if err := reloaded.record(bitcoinSyncSample{At: start.Add(30 * time.Hour), Blocks: 1000, Headers: 840000, Progress: 0.01}, true); err != nil {
+    t.Fatal(err)
+  }
+  if view := reloaded.view(time.Time{}); len(view.Samples) != 1 || view.CompletedAt != nil || !view.Syncing {
+    t.Fatalf("reindex view = %+v", view)
+  }
+}
+
+func TestThinSyncSamples(t *testing.T) {
+  samples := make([]bitcoinSyncSample, 10)
+  for i := range samples {
+    samples[i].Blocks = int64(i)
+  }
+  thinned := thinSyncSamples(samples)
+  want := []int64{0, 2, 4, 5, 6, 7, 8, 9}
+  if len(thinned) != len(want) {
+    t.Fatalf("thinned = %+v", thinned)
+  }
+  for i, blocks := range want {
+    if thinned[i].Blocks != blocks {
+      t.Fatalf("thinned = %+v", thinned)
+    }
+  }
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "net/http"
+  "strings"
+  "sync"
+
+  "lightningos-light/internal/lndclient"
+)
+
+const (
+  jobKindBoostPeers = "boost_peers"
+  boostPeersWorkers = 4
+)
+
+type boostPeersRequest struct {
+  Limit int `json:"limit"`
+}
+
+type boostPeerResult struct {
+  Pubkey string `json:"pubkey"`
+  Alias string `json:"alias"`
+  Socket string `json:"socket,omitempty"`
+  Status string `json:"status"`
+  Error string `json:"error,omitempty"`
+}
+
+type boostPeersResponse struct {
+  Requested int `json:"requested"`
+  Attempted int `json:"attempted"`
+  Connected int `json:"connected"`
+  Skipped int `json:"skipped"`
+  Failed int `json:"failed"`
+  Results []boostPeerResult `json:"results"`
+}
+
+// boostTracker collects the outcome of one boost-peers job across its
+// workers.
+type boostTracker struct {
+  mu sync.Mutex
+  job *jobHandle
+  done int
+  counts boostPeersResponse
+  results []boostPeerResult
+  existing map[string]bool
+}
+
+func newBoostTracker(job *jobHandle) *boostTracker {
+  return &boostTracker{job: job, existing: map[string]bool{}}
+}
+
+// claim reserves a pubkey so two workers never dial the same node.
+func (t *boostTracker) claim(pubkey string) bool {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  if t.existing[pubkey] {
+    return false
+  }
+  t.existing[pubkey] = true
+  return true
+}
+
+func (t *boostTracker) setTotal(total int) {
+  t.mu.Lock()
+  t.counts.Requested = total
+  t.results = make([]boostPeerResult, total)
+  t.mu.Unlock()
+  t.publish()
+}
+
+func (t *boostTracker) record(index int, result boostPeerResult, attempted bool) {
+  t.mu.Lock()
+  t.results[index] = result
+  t.done++
+  if attempted {
+    t.counts.Attempted++
+  }
+  switch result.Status {
+  case "connected":
+    t.counts.Connected++
+  case "failed":
+    t.counts.Failed++
+  default:
+    t.counts.Skipped++
+  }
+  t.mu.Unlock()
+  t.publish()
+}
+
+// snapshot returns the counts with the results finished so far, in ranking
+// order.
+func (t *boostTracker) snapshot() (int, boostPeersResponse) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  resp := t.counts
+  resp.Results = []boostPeerResult{}
+  for _, result := range t.results {
+    if result.Status != "" {
+      resp.Results = append(resp.Results, result)
+    }
+  }
+  return t.done, resp
+}
+
+func (t *boostTracker) publish() {
+  if t.job == nil {
+    return
+  }
+  done, resp := t.snapshot()
+  t.job.Progress(done, resp.Requested, resp)
+}
+
+func (s *Server) handleLNBoostPeers(w http.ResponseWriter, r *http.Request) {
+  var req boostPeersRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+
+  limit := req.Limit
+  if limit <= 0 {
+    limit = boostPeersDefaultLimit
+  }
+  if limit > boostPeersMaxLimit {
+    limit = boostPeersMaxLimit
+  }
+
+  job, err := s.jobs.submit(s.nodeIDFor(r), jobKindBoostPeers, "", boostPeersRequest{Limit: limit})
+  if err != nil {
+    writeJobSubmitError(w, err)
+    return
+  }
+  writeJobStarted(w, job)
+}
+
+// runBoostPeersJob connects to the best connected nodes of the network,
+// boostPeersWorkers at a time. Peers it already has are skipped, so running
+// it again after a restart is harmless.
+func (s *Server) runBoostPeersJob(ctx context.Context, job *jobHandle) (any, error) {
+  var req boostPeersRequest
+  if err := job.Params(&req); err != nil {
+    return nil, err
+  }
+  limit := req.Limit
+  lnd, ok := s.lndForNode(job.NodeID())
+  if !ok {
+    return nil, unknownNodeError(job.NodeID())
+  }
+  if err := lnd.WaitReady(ctx); err != nil {
+    return nil, err
+  }
+
+  peersCtx, peersCancel := s.operationContext(ctx, timeoutLNDRPC)
+  peers, err := lnd.ListPeers(peersCtx)
+  peersCancel()
+  if err != nil {
+    return nil, errors.New(lndDetailedErrorMessage(err))
+  }
+  tracker := newBoostTracker(job)
+  for _, peer := range peers {
+    if peer.PubKey != "" {
+      tracker.existing[peer.PubKey] = true
+    }
+  }
+
+  rankingCtx, rankingCancel := s.operationContext(ctx, timeoutMedium)
+  ranking, err := s.mempool.connectivity(rankingCtx)
+  rankingCancel()
+  if err != nil {
+    s.logger.Printf("mempool connectivity fetch failed: %v", err)
+    return nil, errors.New("mempool connectivity fetch failed")
+  }
+  if limit > len(ranking) {
+    limit = len(ranking)
+  }
+  tracker.setTotal(limit)
+
+  reach := currentSocketReachability()
+  queue := make(chan int)
+  var wg sync.WaitGroup
+  for i := 0; i < boostPeersWorkers; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for index := range queue {
+        result, attempted := s.boostPeer(ctx, lnd, tracker, ranking[index], reach)
+        tracker.record(index, result, attempted)
+      }
+    }()
+  }
+feed:
+  for i := 0; i < limit; i++ {
+    select {
+    case <-ctx.Done():
+      break feed
+    case queue <- i:
+    }
+  }
+  close(queue)
+  wg.Wait()
+
+  _, resp := tracker.snapshot()
+  return resp, nil
+}
+
+// boostPeer looks up one ranked node and connects to its best reachable
+// socket, trying the next one when the first fails.
+func (s *Server) boostPeer(ctx context.Context, lnd lndclient.API, tracker *boostTracker, node mempoolConnectivityNode, reach socketReachability) (boostPeerResult, bool) {
+  pubkey := strings.TrimSpace(node.PublicKey)
+  alias := strings.TrimSpace(node.Alias)
+  if pubkey == "" {
+    return boostPeerResult{Alias: alias, Status: "skipped", Error: "missing pubkey"}, false
+  }
+  if !tracker.claim(pubkey) {
+    return boostPeerResult{Pubkey: pubkey, Alias: alias, Status: "skipped", Error: "already connected"}, false
+  }
+
+  infoCtx, infoCancel := s.operationContext(ctx, timeoutMedium)
+  info, err := s.mempool.nodeInfo(infoCtx, pubkey)
+  infoCancel()
+  if err != nil {
+    return boostPeerResult{Pubkey: pubkey, Alias: alias, Status: "failed", Error: "mempool node lookup failed"}, false
+  }
+  if alias == "" {
+    alias = strings.TrimSpace(info.Alias)
+  }
+  sockets := rankPeerSockets(info.Sockets, reach)
+  if len(sockets) == 0 {
+    return boostPeerResult{Pubkey: pubkey, Alias: alias, Status: "skipped", Error: "no reachable socket found"}, false
+  }
+  if len(sockets) > boostPeerSocketAttempts {
+    sockets = sockets[:boostPeerSocketAttempts]
+  }
+
+  socket := ""
+  for _, candidate := range sockets {
+    socket = candidate
+    connectCtx, connectCancel := s.operationContext(ctx, timeoutLNDRPC)
+    err = lnd.ConnectPeer(connectCtx, pubkey, socket, true)
+    connectCancel()
+    if err == nil || isAlreadyConnected(err) || ctx.Err() != nil {
+      break
+    }
+  }
+  switch {
+  case err == nil:
+    return boostPeerResult{Pubkey: pubkey, Alias: alias, Socket: socket, Status: "connected"}, true
+  case isAlreadyConnected(err):
+    return boostPeerResult{Pubkey: pubkey, Alias: alias, Socket: socket, Status: "skipped", Error: "already connected"}, true
+  default:
+    return boostPeerResult{Pubkey: pubkey, Alias: alias, Socket: socket, Status: "failed", Error: err.Error()}, true
+  }
+}
@@ -0,0 +1,26 @@
+package server
+
+import "testing"
+
+func TestBoostTracker(t *testing.T) {
+  tracker := newBoostTracker(nil)
+  tracker.existing["pk0"] = true
+  tracker.setTotal(3)
+
+  if tracker.claim("pk0") {
+    t.Fatalf("an existing peer must not be claimed")
+  }
+  if !tracker.claim("pk1") || tracker.claim("pk1") {
+    t.Fatalf("a pubkey must only be claimed once")
+  }
+  tracker.record(2, boostPeerResult{Pubkey: "pk1", Status: "connected"}, true)
+  tracker.record(0, boostPeerResult{Status: "skipped", Error: "missing pubkey"}, false)
+
+  done, resp := tracker.snapshot()
+  if done != 2 || resp.Requested != 3 || resp.Attempted != 1 || resp.Connected != 1 || resp.Skipped != 1 {
+    t.Fatalf("unexpected counts: done=%d %+v", done, resp)
+  }
+  if len(resp.Results) != 2 || resp.Results[0].Error != "missing pubkey" || resp.Results[1].Pubkey != "pk1" {
+    t.Fatalf("results must keep ranking order: %+v", resp.Results)
+  }
+}
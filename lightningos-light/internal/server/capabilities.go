@@ -0,0 +1,156 @@
+package server
+
+import (
+  "context"
+  "net/http"
+  "os"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/system"
+)
+
+// Capabilities are the groups of system work that need root. The report
+// says which ones the manager can do as it runs now: as root everything
+// works; in sudo mode a capability needs all of its sudoers rules; in
+// polkit mode the service and power capabilities need the polkit rules and
+// the rest are unavailable, so those features are degraded.
+const (
+  capabilityLNDService = "lnd_service"
+  capabilityManagerRestart = "manager_restart"
+  capabilityPostgresRestart = "postgres_restart"
+  capabilityPower = "power"
+  capabilityLNDFiles = "lnd_files"
+  capabilityDiskHealth = "disk_health"
+  capabilityFirewall = "firewall"
+  capabilityApps = "apps"
+  capabilitySSH = "ssh"
+)
+
+var capabilityFeatures = []struct {
+  key string
+  affects string
+}{
+  {capabilityLNDService, "LND restarts, Postgres migration, node import, rescans and the database check"},
+  {capabilityManagerRestart, "restarting the manager from the UI"},
+  {capabilityPostgresRestart, "restarting Postgres from the UI"},
+  {capabilityPower, "reboot and shutdown"},
+  {capabilityLNDFiles, "LND file permission fixes and TLS regeneration for LNDg"},
+  {capabilityDiskHealth, "SMART disk health"},
+  {capabilityFirewall, "firewall status and rules, the LAN only profile and the LNDg firewall rule"},
+  {capabilityApps, "installing, updating and running apps"},
+  {capabilitySSH, "SSH keys and password logins"},
+}
+
+type polkitCheck struct {
+  action string
+  details map[string]string
+}
+
+func unitCheck(unit string, verb string) polkitCheck {
+  return polkitCheck{action: system.PolkitActionManageUnits, details: map[string]string{"unit": unit + ".service", "verb": verb}}
+}
+
+// polkitChecks are the polkit actions behind the capabilities polkit mode
+// keeps.
+var polkitChecks = map[string][]polkitCheck{
+  capabilityLNDService: {unitCheck("lnd", "start"), unitCheck("lnd", "stop"), unitCheck("lnd", "restart")},
+  capabilityManagerRestart: {unitCheck("lightningos-manager", "restart")},
+  capabilityPostgresRestart: {unitCheck("postgresql", "restart")},
+  capabilityPower: {{action: system.PolkitActionReboot}, {action: system.PolkitActionPowerOff}},
+}
+
+// polkitUnits are the units the polkit rules let the manager control.
+func polkitUnits() []string {
+  return []string{"lnd", "lightningos-manager", "postgresql", elementsServiceName, peerswapServiceName, pswebServiceName}
+}
+
+// PolkitRules renders the polkit rules for the manager's user.
+func PolkitRules() string {
+  return system.RenderPolkitRules(managerUser(), polkitUnits())
+}
+
+type capabilityStatus struct {
+  Feature string `json:"feature"`
+  Affects string `json:"affects"`
+  Available bool `json:"available"`
+  Reason string `json:"reason,omitempty"`
+}
+
+// CapabilityReport lists the capabilities and the degraded ones by name.
+type CapabilityReport struct {
+  Mode string `json:"mode"`
+  User string `json:"user"`
+  Features []capabilityStatus `json:"features"`
+  Degraded []string `json:"degraded"`
+}
+
+func BuildCapabilities(ctx context.Context, cfg *config.Config) CapabilityReport {
+  report := CapabilityReport{
+    Mode: system.PrivilegeMode(),
+    User: managerUser(),
+    Features: make([]capabilityStatus, 0, len(capabilityFeatures)),
+    Degraded: []string{},
+  }
+  var check func(feature string) (bool, string)
+  switch {
+  case os.Geteuid() == 0:
+    report.Mode, report.User = "root", "root"
+    check = func(string) (bool, string) { return true, "" }
+  case report.Mode == system.PrivilegePolkit:
+    check = func(feature string) (bool, string) { return polkitCapability(ctx, feature) }
+  default:
+    policy := BuildSudoPolicy(ctx, cfg)
+    check = func(feature string) (bool, string) { return sudoCapability(policy, feature) }
+  }
+  for _, feature := range capabilityFeatures {
+    status := capabilityStatus{Feature: feature.key, Affects: feature.affects}
+    status.Available, status.Reason = check(feature.key)
+    if !status.Available {
+      report.Degraded = append(report.Degraded, feature.key)
+    }
+    report.Features = append(report.Features, status)
+  }
+  return report
+}
+
+// sudoCapability needs every sudoers rule of the feature.
+func sudoCapability(policy SudoPolicyReport, feature string) (bool, string) {
+  for _, rule := range policy.Rules {
+    if rule.Feature != feature || rule.Allowed {
+      continue
+    }
+    if rule.Error != "" {
+      return false, rule.Error
+    }
+    return false, "missing sudoers rule: " + rule.Line
+  }
+  return true, ""
+}
+
+func polkitCapability(ctx context.Context, feature string) (bool, string) {
+  checks, ok := polkitChecks[feature]
+  if !ok {
+    return false, "needs root, not available in polkit mode"
+  }
+  for _, c := range checks {
+    allowed, err := system.PolkitAllowed(ctx, c.action, c.details)
+    if err != nil {
+      return false, err.Error()
+    }
+    if !allowed {
+      missing := c.action
+      if unit := c.details["unit"]; unit != "" {
+        missing += " (" + c.details["verb"] + " " + unit + ")"
+      }
+      return false, "polkit does not allow " + missing
+    }
+  }
+  return true, ""
+}
+
+// handleCapabilities serves GET /api/system/capabilities.
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  writeJSON(w, http.StatusOK, BuildCapabilities(ctx, s.cfg))
+}
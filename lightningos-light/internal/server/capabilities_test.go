@@ -0,0 +1,59 @@
+package server
+
+import (
+  "strings"
+  "testing"
+
+  "lightningos-light/internal/system"
+)
+
+func TestSudoCapability(t *testing.T) {
+  policy := SudoPolicyReport{Rules: []sudoRuleStatus{
+    {sudoRule: sudoRule{Feature: capabilityLNDService}, Line: "/usr/bin/systemctl restart lnd", Allowed: true},
+    {sudoRule: sudoRule{Feature: capabilityLNDService}, Line: "/usr/bin/systemctl stop lnd", Allowed: false},
+    {sudoRule: sudoRule{Feature: capabilityPower}, Line: "/usr/bin/systemctl reboot", Allowed: true},
+    {sudoRule: sudoRule{Feature: capabilityDiskHealth}, Line: "/usr/sbin/smartctl -a *", Error: "sudo -l failed: boom"},
+  }}
+
+  if ok, reason := sudoCapability(policy, capabilityLNDService); ok || reason != "missing sudoers rule: /usr/bin/systemctl stop lnd" {
+    t.Fatalf("lnd_service = %v %q", ok, reason)
+  }
+  if ok, reason := sudoCapability(policy, capabilityPower); !ok || reason != "" {
+    t.Fatalf("power = %v %q", ok, reason)
+  }
+  if ok, reason := sudoCapability(policy, capabilityDiskHealth); ok || reason != "sudo -l failed: boom" {
+    t.Fatalf("disk_health = %v %q", ok, reason)
+  }
+}
+
+func TestSudoRulesCoverCapabilities(t *testing.T) {
+  known := map[string]bool{}
+  for _, feature := range capabilityFeatures {
+    known[feature.key] = true
+  }
+  for _, rule := range sudoPolicyRules(dockerRuntime{}, func(name string) string { return name }) {
+    if !known[rule.Feature] {
+      t.Errorf("rule %q has unknown feature %q", rule.sudoers(), rule.Feature)
+    }
+  }
+  for feature := range polkitChecks {
+    if !known[feature] {
+      t.Errorf("polkit check for unknown feature %q", feature)
+    }
+  }
+}
+
+func TestPolkitRules(t *testing.T) {
+  rules := system.RenderPolkitRules("lightningos", polkitUnits())
+  for _, want := range []string{
+    `subject.user !== "lightningos"`,
+    `action.id === "org.freedesktop.systemd1.manage-units"`,
+    `"lnd.service", "lightningos-manager.service", "postgresql.service"`,
+    `"lightningos-elements.service"`,
+    `"org.freedesktop.login1.power-off-multiple-sessions"`,
+  } {
+    if !strings.Contains(rules, want) {
+      t.Errorf("rules missing %s:\n%s", want, rules)
+    }
+  }
+}
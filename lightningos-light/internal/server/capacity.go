@@ -0,0 +1,138 @@
+package server
+
+import (
+  "fmt"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+
+  "lightningos-light/internal/lndclient"
+)
+
+// capacityDefaultParts matches LND's default max_parts for MPP payments.
+const (
+  capacityDefaultParts = 16
+  capacityMaxParts = 128
+)
+
+type channelCapacity struct {
+  ChannelPoint string `json:"channel_point"`
+  PeerAlias string `json:"peer_alias,omitempty"`
+  Private bool `json:"private"`
+  SendableSat int64 `json:"sendable_sat"`
+  ReceivableSat int64 `json:"receivable_sat"`
+}
+
+// capacityEstimate is the largest single payment the node can send or
+// receive right now. With MPP a payment is split over at most max_parts
+// channels, so the estimate sums the best max_parts channels; without MPP
+// it is the best single channel. Fees, the HTLC minimums and what happens
+// beyond our peers are not accounted for, so treat these as upper bounds.
+type capacityEstimate struct {
+  MaxParts int `json:"max_parts"`
+  ActiveChannels int `json:"active_channels"`
+  MaxSendableSat int64 `json:"max_sendable_sat"`
+  MaxReceivableSat int64 `json:"max_receivable_sat"`
+  MaxSingleSendableSat int64 `json:"max_single_sendable_sat"`
+  MaxSingleReceivableSat int64 `json:"max_single_receivable_sat"`
+  // PublicReceivableSat leaves out private channels, which payers only find
+  // when the invoice carries route hints for them.
+  PublicReceivableSat int64 `json:"public_receivable_sat"`
+  LocalReserveSat int64 `json:"local_reserve_sat"`
+  RemoteReserveSat int64 `json:"remote_reserve_sat"`
+  Channels []channelCapacity `json:"channels"`
+}
+
+// channelSendable is what one HTLC can take out of the channel: our balance
+// above the reserve, capped by the in-flight limit.
+func channelSendable(ch lndclient.ChannelInfo) int64 {
+  return capAmount(ch.LocalBalanceSat-ch.LocalReserveSat, ch.LocalMaxInFlightSat)
+}
+
+func channelReceivable(ch lndclient.ChannelInfo) int64 {
+  return capAmount(ch.RemoteBalanceSat-ch.RemoteReserveSat, ch.RemoteMaxInFlightSat)
+}
+
+func capAmount(amount int64, limit int64) int64 {
+  if amount < 0 {
+    return 0
+  }
+  if limit > 0 && amount > limit {
+    return limit
+  }
+  return amount
+}
+
+func estimateCapacity(channels []lndclient.ChannelInfo, maxParts int) capacityEstimate {
+  if maxParts <= 0 {
+    maxParts = capacityDefaultParts
+  }
+  est := capacityEstimate{MaxParts: maxParts, Channels: []channelCapacity{}}
+  sendable, receivable, publicReceivable := []int64{}, []int64{}, []int64{}
+  for _, ch := range channels {
+    if !ch.Active {
+      continue
+    }
+    est.ActiveChannels++
+    est.LocalReserveSat += ch.LocalReserveSat
+    est.RemoteReserveSat += ch.RemoteReserveSat
+    item := channelCapacity{
+      ChannelPoint: ch.ChannelPoint,
+      PeerAlias: ch.PeerAlias,
+      Private: ch.Private,
+      SendableSat: channelSendable(ch),
+      ReceivableSat: channelReceivable(ch),
+    }
+    est.Channels = append(est.Channels, item)
+    sendable = append(sendable, item.SendableSat)
+    receivable = append(receivable, item.ReceivableSat)
+    if !ch.Private {
+      publicReceivable = append(publicReceivable, item.ReceivableSat)
+    }
+  }
+  est.MaxSendableSat, est.MaxSingleSendableSat = sumLargest(sendable, maxParts)
+  est.MaxReceivableSat, est.MaxSingleReceivableSat = sumLargest(receivable, maxParts)
+  est.PublicReceivableSat, _ = sumLargest(publicReceivable, maxParts)
+  sort.Slice(est.Channels, func(i, j int) bool {
+    return est.Channels[i].ChannelPoint < est.Channels[j].ChannelPoint
+  })
+  return est
+}
+
+// sumLargest returns the sum of the n largest amounts and the largest one.
+func sumLargest(amounts []int64, n int) (int64, int64) {
+  sort.Slice(amounts, func(i, j int) bool { return amounts[i] > amounts[j] })
+  var total int64
+  for i, amount := range amounts {
+    if i >= n {
+      break
+    }
+    total += amount
+  }
+  if len(amounts) == 0 {
+    return 0, 0
+  }
+  return total, amounts[0]
+}
+
+func (s *Server) handleLNCapacity(w http.ResponseWriter, r *http.Request) {
+  maxParts := capacityDefaultParts
+  if raw := strings.TrimSpace(r.URL.Query().Get("max_parts")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > capacityMaxParts {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("max_parts out of range: 1 to %d", capacityMaxParts))
+      return
+    }
+    maxParts = parsed
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  channels, err := s.lndFor(r).ListChannels(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  writeJSON(w, http.StatusOK, estimateCapacity(channels, maxParts))
+}
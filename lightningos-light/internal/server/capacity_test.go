@@ -0,0 +1,43 @@
+package server
+
+import (
+  "net/http"
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestEstimateCapacity(t *testing.T) {
+  channels := []lndclient.ChannelInfo{
+    {ChannelPoint: "aa:0", Active: true, LocalBalanceSat: 600000, RemoteBalanceSat: 400000, LocalReserveSat: 10000, RemoteReserveSat: 10000},
+    {ChannelPoint: "bb:0", Active: true, LocalBalanceSat: 300000, RemoteBalanceSat: 700000, LocalReserveSat: 10000, RemoteReserveSat: 10000, RemoteMaxInFlightSat: 500000},
+    {ChannelPoint: "cc:0", Active: true, Private: true, LocalBalanceSat: 5000, RemoteBalanceSat: 200000, LocalReserveSat: 10000, RemoteReserveSat: 2000},
+    {ChannelPoint: "dd:0", Active: false, LocalBalanceSat: 9000000},
+  }
+  est := estimateCapacity(channels, 0)
+  if est.MaxParts != capacityDefaultParts || est.ActiveChannels != 3 {
+    t.Fatalf("unexpected estimate %+v", est)
+  }
+  // The private channel's local balance is below its reserve.
+  if est.MaxSendableSat != 590000+290000 || est.MaxSingleSendableSat != 590000 {
+    t.Fatalf("sendable %d single %d", est.MaxSendableSat, est.MaxSingleSendableSat)
+  }
+  // bb:0 is capped by the peer's in-flight limit.
+  if est.MaxReceivableSat != 390000+500000+198000 || est.MaxSingleReceivableSat != 500000 || est.PublicReceivableSat != 890000 {
+    t.Fatalf("receivable %+v", est)
+  }
+
+  if est := estimateCapacity(channels, 1); est.MaxSendableSat != est.MaxSingleSendableSat || est.MaxReceivableSat != 500000 {
+    t.Fatalf("single part estimate %+v", est)
+  }
+}
+
+func TestLNCapacityRejectsBadParts(t *testing.T) {
+  s, _ := newFakeLNDServer(t)
+  if rec := serveAPI(s, http.MethodGet, "/api/lnops/capacity?max_parts=0", ""); rec.Code != http.StatusBadRequest {
+    t.Fatalf("max_parts=0 accepted: %d", rec.Code)
+  }
+  if rec := serveAPI(s, http.MethodGet, "/api/lnops/capacity?max_parts=4", ""); rec.Code != http.StatusOK {
+    t.Fatalf("capacity: %d %s", rec.Code, rec.Body.String())
+  }
+}
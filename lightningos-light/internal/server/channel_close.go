@@ -0,0 +1,181 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+// A channel_close job walks these steps in its done/total counters.
+const (
+  closeStepPending = 1
+  closeStepBroadcast = 2
+  closeStepConfirmed = 3
+)
+
+// closeJobKey lets a force close start while a cooperative close of the same
+// channel is still being followed.
+func closeJobKey(req channelCloseParams) string {
+  if req.Force {
+    return req.ChannelPoint + ":force"
+  }
+  return req.ChannelPoint
+}
+
+func closeStep(stage string) int {
+  switch stage {
+  case lndclient.CloseStageBroadcast:
+    return closeStepBroadcast
+  case lndclient.CloseStageConfirmed:
+    return closeStepConfirmed
+  }
+  return closeStepPending
+}
+
+// runChannelCloseJob starts the close and keeps reading LND's close stream
+// until the closing transaction confirms, publishing each step as progress
+// and as a notification. LND must accept the close within one RPC timeout
+// (the write timeout with wait_for_txid); after that the job waits as long
+// as the confirmation takes. Cancelling the job only stops following the
+// close, it does not undo it.
+func (s *Server) runChannelCloseJob(ctx context.Context, job *jobHandle) (any, error) {
+  var req channelCloseParams
+  if err := job.Params(&req); err != nil {
+    return nil, err
+  }
+  lnd, ok := s.lndForNode(job.NodeID())
+  if !ok {
+    return nil, unknownNodeError(job.NodeID())
+  }
+  channel := s.closingChannel(ctx, lnd, req.ChannelPoint)
+
+  class := timeoutLNDRPC
+  if req.WaitForTxid {
+    class = timeoutLNDWrite
+  }
+  streamCtx, cancel := context.WithCancel(ctx)
+  defer cancel()
+  acceptTimer := time.AfterFunc(class.duration(s.timeouts()), cancel)
+  defer acceptTimer.Stop()
+
+  var (
+    mu sync.Mutex
+    last lndclient.CloseUpdate
+  )
+  result, err := lnd.CloseChannel(streamCtx, lndclient.CloseChannelRequest{
+    ChannelPoint: req.ChannelPoint,
+    Force: req.Force,
+    SatPerVbyte: req.SatPerVbyte,
+    TargetConf: req.TargetConf,
+    DeliveryAddress: req.DeliveryAddress,
+    MaxFeePerVbyte: req.MaxFeePerVbyte,
+    WaitForTxid: req.WaitForTxid,
+    OnUpdate: func(update lndclient.CloseUpdate) {
+      acceptTimer.Stop()
+      mu.Lock()
+      if update.ClosingTxid == "" {
+        update.ClosingTxid = last.ClosingTxid
+      }
+      last = update
+      mu.Unlock()
+      job.Progress(closeStep(update.Stage), closeStepConfirmed, update)
+      s.notifyCloseStep(job.NodeID(), req, channel, update)
+    },
+  })
+  mu.Lock()
+  reached := last
+  mu.Unlock()
+  if err != nil {
+    switch {
+    case reached.Stage == "" && ctx.Err() == nil && streamCtx.Err() != nil:
+      return nil, errors.New("timed out waiting for LND to accept the close")
+    case reached.Stage != "" && ctx.Err() == nil:
+      return nil, fmt.Errorf("lost track of the close, it goes on in LND: stream ended after the %s step: %s", reached.Stage, lndDetailedErrorMessage(err))
+    }
+    return nil, errors.New(lndDetailedErrorMessage(err))
+  }
+
+  resp := map[string]any{"ok": true, "channel_point": req.ChannelPoint, "stage": reached.Stage}
+  if result.ClosingTxid != "" {
+    resp["closing_txid"] = result.ClosingTxid
+  }
+  if result.FeePerVbyte > 0 {
+    resp["fee_per_vbyte"] = result.FeePerVbyte
+  }
+  if result.PendingHTLCs > 0 {
+    resp["pending_htlcs"] = result.PendingHTLCs
+  }
+  return resp, nil
+}
+
+// closingChannel looks the channel up before it leaves the open list, so the
+// notifications can name the peer and the balance. Best effort.
+func (s *Server) closingChannel(ctx context.Context, lnd lndclient.API, channelPoint string) lndclient.ChannelInfo {
+  ctx, cancel := s.operationContext(ctx, timeoutLNDRPC)
+  defer cancel()
+  channels, err := lnd.ListChannels(ctx)
+  if err != nil {
+    return lndclient.ChannelInfo{ChannelPoint: channelPoint}
+  }
+  for _, ch := range channels {
+    if ch.ChannelPoint == channelPoint {
+      return ch
+    }
+  }
+  return lndclient.ChannelInfo{ChannelPoint: channelPoint}
+}
+
+// notifyCloseStep records a step of a followed close. The pending and
+// confirmed steps share their keys with the pending-channel poller and the
+// channel event subscription, so each close still ends up as one row per
+// step whichever side sees it first.
+func (s *Server) notifyCloseStep(nodeID string, req channelCloseParams, ch lndclient.ChannelInfo, update lndclient.CloseUpdate) {
+  n, ok := s.notifierForNode(nodeID)
+  if !ok || n == nil {
+    return
+  }
+  evt := Notification{
+    OccurredAt: time.Now().UTC(),
+    Type: "channel",
+    Direction: "neutral",
+    AmountSat: ch.LocalBalanceSat,
+    PeerPubkey: ch.RemotePubkey,
+    PeerAlias: ch.PeerAlias,
+    ChannelID: int64(ch.ChannelID),
+    ChannelPoint: req.ChannelPoint,
+    Txid: update.ClosingTxid,
+  }
+  if evt.PeerAlias == "" && evt.PeerPubkey != "" {
+    evt.PeerAlias = n.lookupNodeAlias(evt.PeerPubkey)
+  }
+  var key string
+  switch update.Stage {
+  case lndclient.CloseStagePending:
+    key = "channel:closing:" + req.ChannelPoint
+    n.markPendingNotification(key)
+    evt.Action, evt.Status = "closing", "PENDING"
+    if req.Force {
+      evt.Status = "FORCE_CLOSING"
+    }
+  case lndclient.CloseStageBroadcast:
+    key = "channel:close_broadcast:" + req.ChannelPoint
+    evt.Action, evt.Status = "close_broadcast", "BROADCAST"
+    if update.FeePerVbyte > 0 {
+      evt.Memo = fmt.Sprintf("%d sat/vB", update.FeePerVbyte)
+    }
+  case lndclient.CloseStageConfirmed:
+    key = "channel:close:" + req.ChannelPoint
+    evt.Action, evt.Status = "close", "CLOSED"
+  default:
+    return
+  }
+  ctx, cancel := s.taskContext("channel_close/notify", timeoutShort)
+  defer cancel()
+  if _, err := n.upsertNotification(ctx, key, evt); err != nil {
+    s.logger.Printf("channel close: notification for %s %s failed: %v", req.ChannelPoint, update.Stage, err)
+  }
+}
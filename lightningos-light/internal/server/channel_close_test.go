@@ -0,0 +1,99 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "io"
+  "log"
+  "net/http"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/lndfake"
+)
+
+func TestCloseChannelOptions(t *testing.T) {
+  fake := lndfake.New()
+  fake.Channels = []lndclient.ChannelInfo{{ChannelPoint: "aa:0", RemotePubkey: "02aa", Active: true, CapacitySat: 100000}}
+  cfg := &config.Config{Network: "regtest"}
+  s := NewWithLND(cfg, log.New(io.Discard, "", 0), fake)
+  t.Cleanup(func() { setActiveNetwork("mainnet") })
+
+  for _, body := range []string{
+    `{"channel_point":"aa:0","sat_per_vbyte":5,"target_conf":6}`,
+    `{"channel_point":"aa:0","force":true,"delivery_address":"bcrt1qexample"}`,
+    `{"channel_point":"aa:0","sat_per_vbyte":30,"max_fee_per_vbyte":20}`,
+  } {
+    if rec := serveAPI(s, http.MethodPost, "/api/lnops/channel/close", body); rec.Code != http.StatusBadRequest {
+      t.Fatalf("%s accepted: %d %s", body, rec.Code, rec.Body.String())
+    }
+  }
+  if len(fake.Calls("CloseChannel")) != 0 {
+    t.Fatal("invalid close reached LND")
+  }
+
+  rec := serveAPI(s, http.MethodPost, "/api/lnops/channel/close",
+    `{"channel_point":"aa:0","target_conf":6,"delivery_address":"bcrt1qexample","max_fee_per_vbyte":20,"wait_for_txid":true}`)
+  if rec.Code != http.StatusOK {
+    t.Fatalf("close: %d %s", rec.Code, rec.Body.String())
+  }
+  var job struct {
+    Result map[string]any `json:"result"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+    t.Fatalf("decode: %v", err)
+  }
+  if txid, _ := job.Result["closing_txid"].(string); txid == "" {
+    t.Fatalf("no closing txid: %s", rec.Body.String())
+  }
+  calls := fake.Calls("CloseChannel")
+  if len(calls) != 1 {
+    t.Fatalf("close calls: %v", calls)
+  }
+  got := calls[0].Args[0].(lndclient.CloseChannelRequest)
+  if got.ChannelPoint != "aa:0" || got.TargetConf != 6 || got.DeliveryAddress != "bcrt1qexample" || got.MaxFeePerVbyte != 20 || !got.WaitForTxid || got.Force {
+    t.Fatalf("unexpected close request %+v", got)
+  }
+}
+
+func TestCloseChannelTrackedUntilConfirmed(t *testing.T) {
+  fake := lndfake.New()
+  fake.Channels = []lndclient.ChannelInfo{{ChannelPoint: "aa:0", RemotePubkey: "02aa", Active: true, CapacitySat: 100000}}
+  fake.CloseConfirmed = make(chan struct{})
+  cfg := &config.Config{Network: "regtest"}
+  s := NewWithLND(cfg, log.New(io.Discard, "", 0), fake)
+  t.Cleanup(func() { setActiveNetwork("mainnet") })
+
+  rec := serveAPI(s, http.MethodPost, "/api/lnops/channel/close", `{"channel_point":"aa:0","wait_for_txid":true}`)
+  if rec.Code != http.StatusAccepted {
+    t.Fatalf("close: %d %s", rec.Code, rec.Body.String())
+  }
+  var job Job
+  if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+    t.Fatalf("decode: %v", err)
+  }
+  var step lndclient.CloseUpdate
+  if err := json.Unmarshal(job.Progress, &step); err != nil {
+    t.Fatalf("progress: %v", err)
+  }
+  if job.Done != closeStepBroadcast || step.Stage != lndclient.CloseStageBroadcast || step.ClosingTxid == "" {
+    t.Fatalf("close not reported as broadcast: %s", rec.Body.String())
+  }
+
+  if rec := serveAPI(s, http.MethodPost, "/api/lnops/channel/close", `{"channel_point":"aa:0"}`); rec.Code != http.StatusConflict {
+    t.Fatalf("second coop close while tracking: %d", rec.Code)
+  }
+
+  close(fake.CloseConfirmed)
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  defer cancel()
+  finished := s.jobs.wait(ctx, job.ID)
+  if finished.State != jobStateDone || finished.Done != closeStepConfirmed {
+    t.Fatalf("close not confirmed: %+v", finished)
+  }
+  if pending, _ := fake.ListPendingChannels(ctx); len(pending) != 0 {
+    t.Fatalf("confirmed close still pending: %+v", pending)
+  }
+}
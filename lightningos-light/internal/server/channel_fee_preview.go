@@ -0,0 +1,208 @@
+package server
+
+import (
+  "context"
+  "net/http"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+const (
+  feePreviewDefaultDays = 30
+  feePreviewMaxDays = 180
+  // A ppm change by more than this factor gets a warning: peers and
+  // pathfinders react to big jumps, so past flow says little about it.
+  feePreviewJumpFactor = 2
+)
+
+type channelFeePolicy struct {
+  BaseFeeMsat int64 `json:"base_fee_msat"`
+  FeeRatePpm int64 `json:"fee_rate_ppm"`
+  TimeLockDelta int64 `json:"time_lock_delta"`
+  InboundBaseMsat int64 `json:"inbound_base_msat"`
+  InboundFeeRatePpm int64 `json:"inbound_fee_rate_ppm"`
+}
+
+// feeForward is the outgoing flow through one channel over the window.
+type feeForward struct {
+  Forwards int64
+  RoutedSat int64
+  EarnedMsat int64
+}
+
+type channelFeeFlow struct {
+  Days int `json:"days"`
+  Available bool `json:"available"`
+  Forwards int64 `json:"forwards"`
+  RoutedSat int64 `json:"routed_sat"`
+  RoutedSatPerDay int64 `json:"routed_sat_per_day"`
+  EarnedMsat int64 `json:"earned_msat"`
+  // ProjectedMsat is what the same forwards would have earned under the
+  // proposed outbound policy.
+  ProjectedMsat int64 `json:"projected_msat"`
+  ChangeMsat int64 `json:"change_msat"`
+  EffectivePpm int64 `json:"effective_ppm"`
+  ProjectedPpm int64 `json:"projected_ppm"`
+}
+
+type channelFeePreview struct {
+  ChannelPoint string `json:"channel_point"`
+  ChannelID uint64 `json:"channel_id"`
+  Current channelFeePolicy `json:"current"`
+  Proposed channelFeePolicy `json:"proposed"`
+  Flow channelFeeFlow `json:"flow"`
+  Warnings []string `json:"warnings"`
+}
+
+// buildChannelFeePreview compares the policies and replays the window's
+// forwards (nil when the history is unavailable) at the proposed fee. The
+// flow itself is assumed unchanged, which is the optimistic case for a
+// raise and the pessimistic one for a cut.
+func buildChannelFeePreview(current channelFeePolicy, proposed channelFeePolicy, forwards *feeForward, days int) channelFeePreview {
+  preview := channelFeePreview{
+    Current: current,
+    Proposed: proposed,
+    Flow: channelFeeFlow{Days: days},
+    Warnings: []string{},
+  }
+  if forwards == nil {
+    preview.Warnings = append(preview.Warnings, "forward history unavailable: the estimate needs the notifications database")
+  } else {
+    flow := &preview.Flow
+    flow.Available = true
+    flow.Forwards = forwards.Forwards
+    flow.RoutedSat = forwards.RoutedSat
+    flow.EarnedMsat = forwards.EarnedMsat
+    if days > 0 {
+      flow.RoutedSatPerDay = forwards.RoutedSat / int64(days)
+    }
+    flow.ProjectedMsat = forwards.Forwards*proposed.BaseFeeMsat + forwards.RoutedSat*1000*proposed.FeeRatePpm/1_000_000
+    flow.ChangeMsat = flow.ProjectedMsat - flow.EarnedMsat
+    if forwards.RoutedSat > 0 {
+      flow.EffectivePpm = forwards.EarnedMsat * 1000 / forwards.RoutedSat
+      flow.ProjectedPpm = flow.ProjectedMsat * 1000 / forwards.RoutedSat
+    }
+    if forwards.Forwards == 0 {
+      preview.Warnings = append(preview.Warnings, "no forwards left through this channel in the window")
+    }
+  }
+
+  if current.FeeRatePpm > 0 && proposed.FeeRatePpm > current.FeeRatePpm*feePreviewJumpFactor {
+    preview.Warnings = append(preview.Warnings, "fee rate more than doubles: expect less flow than the estimate")
+  }
+  if proposed.InboundBaseMsat != current.InboundBaseMsat || proposed.InboundFeeRatePpm != current.InboundFeeRatePpm {
+    preview.Warnings = append(preview.Warnings, "inbound fees apply to forwards arriving through this channel and are not part of the estimate")
+  }
+  return preview
+}
+
+// channelForwardsSince sums the forwards that left through chanID, as
+// recorded by the forward notifications. ok is false without a database.
+func (s *Server) channelForwardsSince(ctx context.Context, r *http.Request, chanID uint64, since time.Time) (feeForward, bool) {
+  var out feeForward
+  notifier := s.notifierFor(r)
+  if notifier == nil || notifier.db == nil {
+    return out, false
+  }
+  err := notifier.db.QueryRow(ctx, `
+select count(*), coalesce(sum(amount_sat), 0), coalesce(sum(fee_msat), 0)
+from notifications
+where type = 'forward' and node_id = $1 and occurred_at >= $2 and channel_id = $3
+`, notifier.nodeKey(), since, int64(chanID)).Scan(&out.Forwards, &out.RoutedSat, &out.EarnedMsat)
+  return out, err == nil
+}
+
+// handleLNChannelFeesPreview takes the body of POST /channel/fees for one
+// channel (plus days of history) and shows what the update would change
+// without applying it.
+func (s *Server) handleLNChannelFeesPreview(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    ChannelPoint string `json:"channel_point"`
+    BaseFeeMsat int64 `json:"base_fee_msat"`
+    FeeRatePpm int64 `json:"fee_rate_ppm"`
+    TimeLockDelta int64 `json:"time_lock_delta"`
+    InboundEnabled bool `json:"inbound_enabled"`
+    InboundBaseMsat int64 `json:"inbound_base_msat"`
+    InboundFeeRatePpm int64 `json:"inbound_fee_rate_ppm"`
+    Days int `json:"days"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  req.ChannelPoint = strings.TrimSpace(req.ChannelPoint)
+  if req.ChannelPoint == "" {
+    writeError(w, http.StatusBadRequest, "channel_point required")
+    return
+  }
+  if req.BaseFeeMsat < 0 || req.FeeRatePpm < 0 || req.TimeLockDelta < 0 {
+    writeError(w, http.StatusBadRequest, "fees must be zero or positive")
+    return
+  }
+  if req.Days == 0 {
+    req.Days = feePreviewDefaultDays
+  }
+  if req.Days < 0 || req.Days > feePreviewMaxDays {
+    writeError(w, http.StatusBadRequest, "days must be between 1 and 180")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  lnd := s.lndFor(r)
+
+  policy, err := lnd.GetChannelPolicy(ctx, req.ChannelPoint)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
+    return
+  }
+  channels, err := lnd.ListChannels(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  var channel *lndclient.ChannelInfo
+  for i := range channels {
+    if channels[i].ChannelPoint == req.ChannelPoint {
+      channel = &channels[i]
+      break
+    }
+  }
+  if channel == nil {
+    writeError(w, http.StatusNotFound, "channel not found")
+    return
+  }
+
+  current := channelFeePolicy{
+    BaseFeeMsat: policy.BaseFeeMsat,
+    FeeRatePpm: policy.FeeRatePpm,
+    TimeLockDelta: policy.TimeLockDelta,
+    InboundBaseMsat: policy.InboundBaseMsat,
+    InboundFeeRatePpm: policy.InboundFeeRatePpm,
+  }
+  // Without inbound_enabled the update leaves the inbound fee alone.
+  proposed := current
+  proposed.BaseFeeMsat = req.BaseFeeMsat
+  proposed.FeeRatePpm = req.FeeRatePpm
+  proposed.TimeLockDelta = req.TimeLockDelta
+  if req.InboundEnabled {
+    proposed.InboundBaseMsat = req.InboundBaseMsat
+    proposed.InboundFeeRatePpm = req.InboundFeeRatePpm
+  }
+
+  since := time.Now().UTC().Add(-time.Duration(req.Days) * 24 * time.Hour)
+  var forwards *feeForward
+  if sum, ok := s.channelForwardsSince(ctx, r, channel.ChannelID, since); ok {
+    forwards = &sum
+  }
+
+  preview := buildChannelFeePreview(current, proposed, forwards, req.Days)
+  preview.ChannelPoint = policy.ChannelPoint
+  preview.ChannelID = channel.ChannelID
+  for i := range preview.Warnings {
+    preview.Warnings[i] = localize(w, preview.Warnings[i])
+  }
+  writeJSON(w, http.StatusOK, preview)
+}
@@ -0,0 +1,33 @@
+package server
+
+import "testing"
+
+func TestBuildChannelFeePreview(t *testing.T) {
+  current := channelFeePolicy{BaseFeeMsat: 1000, FeeRatePpm: 100, TimeLockDelta: 40}
+  proposed := current
+  proposed.FeeRatePpm = 300
+  forwards := &feeForward{Forwards: 10, RoutedSat: 2_000_000, EarnedMsat: 210_000}
+
+  preview := buildChannelFeePreview(current, proposed, forwards, 30)
+  flow := preview.Flow
+  if !flow.Available || flow.RoutedSatPerDay != 66666 {
+    t.Fatalf("flow = %+v", flow)
+  }
+  // 10 forwards * 1000 msat + 2M sat at 300 ppm.
+  if flow.ProjectedMsat != 610_000 || flow.ChangeMsat != 400_000 {
+    t.Fatalf("projected = %d, change = %d", flow.ProjectedMsat, flow.ChangeMsat)
+  }
+  if flow.EffectivePpm != 105 || flow.ProjectedPpm != 305 {
+    t.Fatalf("effective = %d, projected = %d", flow.EffectivePpm, flow.ProjectedPpm)
+  }
+  if len(preview.Warnings) != 1 {
+    t.Fatalf("warnings = %v", preview.Warnings)
+  }
+
+  proposed = current
+  proposed.InboundFeeRatePpm = -50
+  preview = buildChannelFeePreview(current, proposed, nil, 7)
+  if preview.Flow.Available || len(preview.Warnings) != 2 {
+    t.Fatalf("preview = %+v", preview)
+  }
+}
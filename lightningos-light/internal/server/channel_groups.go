@@ -0,0 +1,413 @@
+package server
+
+import (
+  "context"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/reports"
+
+  "github.com/go-chi/chi/v5"
+)
+
+// Channel groups split the channels into portfolios ("merchant corridor",
+// "routing") and sum them up. Membership is a rule, not a list frozen at
+// save time: a channel is in a group when its peer carries one of the
+// group's tags, is one of its peers, or the channel itself is listed. A
+// channel can be in several groups.
+const (
+  maxChannelGroups = 50
+  maxChannelGroupName = 60
+  maxChannelGroupPurpose = 200
+  maxChannelGroupMembers = 500
+  channelGroupsDefaultDays = 30
+  channelGroupsMaxDays = 365
+)
+
+var (
+  channelGroupsPath = "/var/lib/lightningos/channel-groups.json"
+  channelGroupsMu sync.Mutex
+)
+
+type channelGroup struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  Purpose string `json:"purpose,omitempty"`
+  Tags []string `json:"tags"`
+  Peers []string `json:"peers"`
+  Channels []string `json:"channels"`
+  CreatedAt time.Time `json:"created_at"`
+  UpdatedAt time.Time `json:"updated_at"`
+}
+
+func loadChannelGroups() ([]channelGroup, error) {
+  raw, err := os.ReadFile(channelGroupsPath)
+  if errors.Is(err, os.ErrNotExist) {
+    return []channelGroup{}, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  groups := []channelGroup{}
+  if err := json.Unmarshal(raw, &groups); err != nil {
+    return nil, fmt.Errorf("invalid %s: %w", channelGroupsPath, err)
+  }
+  return groups, nil
+}
+
+func saveChannelGroups(groups []channelGroup) error {
+  if err := os.MkdirAll(filepath.Dir(channelGroupsPath), 0o750); err != nil {
+    return err
+  }
+  data, err := json.MarshalIndent(groups, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(channelGroupsPath, data, 0o600)
+}
+
+func (g *channelGroup) normalize() error {
+  g.Name = strings.TrimSpace(g.Name)
+  g.Purpose = strings.TrimSpace(g.Purpose)
+  if g.Name == "" {
+    return errors.New("name required")
+  }
+  if len(g.Name) > maxChannelGroupName {
+    return errors.New("name too long")
+  }
+  if len(g.Purpose) > maxChannelGroupPurpose {
+    return errors.New("purpose too long")
+  }
+  tags, err := normalizePeerTags(g.Tags)
+  if err != nil {
+    return err
+  }
+  g.Tags = tags
+  peers := []string{}
+  for _, peer := range g.Peers {
+    peer = strings.ToLower(strings.TrimSpace(peer))
+    if !isValidPubkeyHex(peer) {
+      return fmt.Errorf("invalid pubkey: %s", peer)
+    }
+    peers = append(peers, peer)
+  }
+  g.Peers = peers
+  channels := []string{}
+  for _, point := range g.Channels {
+    point = strings.ToLower(strings.TrimSpace(point))
+    if !isValidChannelPoint(point) {
+      return fmt.Errorf("invalid channel point: %s", point)
+    }
+    channels = append(channels, point)
+  }
+  g.Channels = channels
+  if len(g.Tags) == 0 && len(g.Peers) == 0 && len(g.Channels) == 0 {
+    return errors.New("group needs tags, peers or channels")
+  }
+  if len(g.Peers)+len(g.Channels) > maxChannelGroupMembers {
+    return fmt.Errorf("too many group members: max %d", maxChannelGroupMembers)
+  }
+  return nil
+}
+
+func isValidChannelPoint(point string) bool {
+  txid, index, ok := strings.Cut(point, ":")
+  if !ok || len(txid) != 64 {
+    return false
+  }
+  if _, err := hex.DecodeString(txid); err != nil {
+    return false
+  }
+  _, err := strconv.ParseUint(index, 10, 32)
+  return err == nil
+}
+
+func (g channelGroup) contains(ch lndclient.ChannelInfo, meta map[string]peerMetadata) bool {
+  point := strings.ToLower(ch.ChannelPoint)
+  for _, member := range g.Channels {
+    if member == point {
+      return true
+    }
+  }
+  pubkey := strings.ToLower(ch.RemotePubkey)
+  for _, peer := range g.Peers {
+    if peer == pubkey {
+      return true
+    }
+  }
+  for _, tag := range g.Tags {
+    if meta[pubkey].hasTag(tag) {
+      return true
+    }
+  }
+  return false
+}
+
+type channelGroupStats struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  Purpose string `json:"purpose,omitempty"`
+  Channels int `json:"channels"`
+  ActiveChannels int `json:"active_channels"`
+  CapacitySat int64 `json:"capacity_sat"`
+  LocalSat int64 `json:"local_sat"`
+  RemoteSat int64 `json:"remote_sat"`
+  LocalPct float64 `json:"local_pct"`
+  Forwards int64 `json:"forwards"`
+  RoutedSat int64 `json:"routed_sat"`
+  RevenueMsat int64 `json:"revenue_msat"`
+  RebalanceSpendMsat int64 `json:"rebalance_spend_msat"`
+  NetMsat int64 `json:"net_msat"`
+  ChannelPoints []string `json:"channel_points"`
+}
+
+// channelEarnings is what one channel made and cost over the window.
+// Forward fees count for the outgoing channel and rebalance fees for the
+// channel that was refilled, as the channel that earned or needed them.
+type channelEarnings struct {
+  Forwards int64
+  RoutedSat int64
+  RevenueMsat int64
+  RebalanceSpendMsat int64
+}
+
+func (st *channelGroupStats) add(ch lndclient.ChannelInfo, earned channelEarnings) {
+  st.Channels++
+  if ch.Active {
+    st.ActiveChannels++
+  }
+  st.CapacitySat += ch.CapacitySat
+  st.LocalSat += ch.LocalBalanceSat
+  st.RemoteSat += ch.RemoteBalanceSat
+  st.Forwards += earned.Forwards
+  st.RoutedSat += earned.RoutedSat
+  st.RevenueMsat += earned.RevenueMsat
+  st.RebalanceSpendMsat += earned.RebalanceSpendMsat
+  st.ChannelPoints = append(st.ChannelPoints, ch.ChannelPoint)
+}
+
+func (st *channelGroupStats) finish() {
+  if st.LocalSat+st.RemoteSat > 0 {
+    st.LocalPct = float64(st.LocalSat*1000/(st.LocalSat+st.RemoteSat)) / 10
+  }
+  st.NetMsat = st.RevenueMsat - st.RebalanceSpendMsat
+}
+
+// aggregateChannelGroups sums the open channels per group. Channels in no
+// group are summed as "ungrouped"; the total counts every channel once.
+func aggregateChannelGroups(groups []channelGroup, channels []lndclient.ChannelInfo, meta map[string]peerMetadata, earnings map[uint64]channelEarnings) ([]channelGroupStats, channelGroupStats, channelGroupStats) {
+  stats := make([]channelGroupStats, len(groups))
+  for i, g := range groups {
+    stats[i] = channelGroupStats{ID: g.ID, Name: g.Name, Purpose: g.Purpose, ChannelPoints: []string{}}
+  }
+  ungrouped := channelGroupStats{ID: "ungrouped", Name: "Ungrouped", ChannelPoints: []string{}}
+  total := channelGroupStats{ID: "total", Name: "Total", ChannelPoints: []string{}}
+  for _, ch := range channels {
+    earned := earnings[ch.ChannelID]
+    grouped := false
+    for i, g := range groups {
+      if g.contains(ch, meta) {
+        stats[i].add(ch, earned)
+        grouped = true
+      }
+    }
+    if !grouped {
+      ungrouped.add(ch, earned)
+    }
+    total.add(ch, earned)
+  }
+  for i := range stats {
+    stats[i].finish()
+  }
+  ungrouped.finish()
+  total.finish()
+  return stats, ungrouped, total
+}
+
+// channelEarningsSince reads forward revenue from the notifications and
+// rebalance spend from the daily reports. Either source may be missing;
+// the flags say which figures are real.
+func (s *Server) channelEarningsSince(ctx context.Context, r *http.Request, since time.Time) (map[uint64]channelEarnings, bool, bool) {
+  earnings := map[uint64]channelEarnings{}
+  revenueOK := false
+  if notifier := s.notifierFor(r); notifier != nil && notifier.db != nil {
+    rows, err := notifier.db.Query(ctx, `
+select channel_id, count(*), coalesce(sum(amount_sat), 0), coalesce(sum(fee_msat), 0)
+from notifications
+where type = 'forward' and node_id = $1 and occurred_at >= $2 and channel_id is not null
+group by channel_id
+`, notifier.nodeKey(), since)
+    if err == nil {
+      for rows.Next() {
+        var chanID int64
+        var earned channelEarnings
+        if err := rows.Scan(&chanID, &earned.Forwards, &earned.RoutedSat, &earned.RevenueMsat); err != nil {
+          break
+        }
+        earnings[uint64(chanID)] = earned
+      }
+      revenueOK = rows.Err() == nil
+      rows.Close()
+    }
+  }
+
+  // The daily reports only cover the primary node.
+  rebalanceOK := false
+  if s.db != nil && s.nodeIDFor(r) == config.DefaultNodeID {
+    pairs, err := reports.FetchRebalancePairs(ctx, s.db, since, time.Now().UTC())
+    if err == nil {
+      for _, pair := range pairs {
+        earned := earnings[pair.InChanID]
+        earned.RebalanceSpendMsat += pair.FeeMsat
+        earnings[pair.InChanID] = earned
+      }
+      rebalanceOK = true
+    }
+  }
+  return earnings, revenueOK, rebalanceOK
+}
+
+func (s *Server) handleChannelGroupsList(w http.ResponseWriter, r *http.Request) {
+  channelGroupsMu.Lock()
+  groups, err := loadChannelGroups()
+  channelGroupsMu.Unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel groups: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": groups})
+}
+
+// handleChannelGroupsSave creates a group, or replaces the one named by id.
+func (s *Server) handleChannelGroupsSave(w http.ResponseWriter, r *http.Request) {
+  var req channelGroup
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if err := req.normalize(); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  channelGroupsMu.Lock()
+  defer channelGroupsMu.Unlock()
+  groups, err := loadChannelGroups()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel groups: %v", err))
+    return
+  }
+  now := time.Now().UTC()
+  req.UpdatedAt = now
+  if req.ID != "" {
+    saved := false
+    for i := range groups {
+      if groups[i].ID == req.ID {
+        req.CreatedAt = groups[i].CreatedAt
+        groups[i] = req
+        saved = true
+        break
+      }
+    }
+    if !saved {
+      writeError(w, http.StatusNotFound, "channel group not found")
+      return
+    }
+  } else {
+    if len(groups) >= maxChannelGroups {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("too many channel groups: max %d", maxChannelGroups))
+      return
+    }
+    id, err := randomToken(6)
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store channel groups: %v", err))
+      return
+    }
+    req.ID = id
+    req.CreatedAt = now
+    groups = append(groups, req)
+  }
+  if err := saveChannelGroups(groups); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store channel groups: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, req)
+}
+
+func (s *Server) handleChannelGroupsDelete(w http.ResponseWriter, r *http.Request) {
+  id := chi.URLParam(r, "id")
+  channelGroupsMu.Lock()
+  defer channelGroupsMu.Unlock()
+  groups, err := loadChannelGroups()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel groups: %v", err))
+    return
+  }
+  kept := groups[:0]
+  for _, g := range groups {
+    if g.ID != id {
+      kept = append(kept, g)
+    }
+  }
+  if len(kept) == len(groups) {
+    writeError(w, http.StatusNotFound, "channel group not found")
+    return
+  }
+  if err := saveChannelGroups(kept); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store channel groups: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleChannelGroupsStats is the portfolio view: the open channels summed
+// per group over the last days.
+func (s *Server) handleChannelGroupsStats(w http.ResponseWriter, r *http.Request) {
+  days := channelGroupsDefaultDays
+  if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > channelGroupsMaxDays {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("days out of range: 1 to %d", channelGroupsMaxDays))
+      return
+    }
+    days = parsed
+  }
+  channelGroupsMu.Lock()
+  groups, err := loadChannelGroups()
+  channelGroupsMu.Unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel groups: %v", err))
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  channels, err := s.lndFor(r).ListChannels(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
+    return
+  }
+  since := time.Now().UTC().AddDate(0, 0, -days)
+  earnings, revenueOK, rebalanceOK := s.channelEarningsSince(ctx, r, since)
+  stats, ungrouped, total := aggregateChannelGroups(groups, channels, currentPeerMetadata(), earnings)
+  writeJSON(w, http.StatusOK, map[string]any{
+    "days": days,
+    "since": since,
+    "revenue_available": revenueOK,
+    "rebalance_available": rebalanceOK,
+    "groups": stats,
+    "ungrouped": ungrouped,
+    "total": total,
+  })
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+  "encoding/json"
+  "net/http"
+  "strings"
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestAggregateChannelGroups(t *testing.T) {
+  merchant := "02" + strings.Repeat("aa", 32)
+  router := "03" + strings.Repeat("bb", 32)
+  loner := "02" + strings.Repeat("cc", 32)
+  point := strings.Repeat("dd", 32) + ":1"
+  channels := []lndclient.ChannelInfo{
+    {ChannelID: 1, ChannelPoint: strings.Repeat("ee", 32) + ":0", RemotePubkey: merchant, CapacitySat: 1000, LocalBalanceSat: 800, RemoteBalanceSat: 200, Active: true},
+    {ChannelID: 2, ChannelPoint: point, RemotePubkey: router, CapacitySat: 2000, LocalBalanceSat: 500, RemoteBalanceSat: 1500},
+    {ChannelID: 3, ChannelPoint: strings.Repeat("ff", 32) + ":0", RemotePubkey: loner, CapacitySat: 500, LocalBalanceSat: 250, RemoteBalanceSat: 250, Active: true},
+  }
+  groups := []channelGroup{
+    {ID: "m", Name: "merchant corridor", Tags: []string{"merchant"}},
+    {ID: "r", Name: "routing", Channels: []string{point}, Peers: []string{merchant}},
+  }
+  meta := map[string]peerMetadata{merchant: {Pubkey: merchant, Tags: []string{"Merchant"}}}
+  earnings := map[uint64]channelEarnings{
+    1: {Forwards: 3, RoutedSat: 30000, RevenueMsat: 9000},
+    2: {Forwards: 1, RoutedSat: 10000, RevenueMsat: 1000, RebalanceSpendMsat: 4000},
+    3: {RebalanceSpendMsat: 500},
+  }
+
+  stats, ungrouped, total := aggregateChannelGroups(groups, channels, meta, earnings)
+  if stats[0].Channels != 1 || stats[0].RevenueMsat != 9000 || stats[0].LocalPct != 80 {
+    t.Fatalf("merchant %+v", stats[0])
+  }
+  routing := stats[1]
+  if routing.Channels != 2 || routing.ActiveChannels != 1 || routing.CapacitySat != 3000 {
+    t.Fatalf("routing %+v", routing)
+  }
+  if routing.RevenueMsat != 10000 || routing.RebalanceSpendMsat != 4000 || routing.NetMsat != 6000 {
+    t.Fatalf("routing earnings %+v", routing)
+  }
+  if ungrouped.Channels != 1 || ungrouped.NetMsat != -500 {
+    t.Fatalf("ungrouped %+v", ungrouped)
+  }
+  if total.Channels != 3 || total.CapacitySat != 3500 || total.Forwards != 4 {
+    t.Fatalf("total %+v", total)
+  }
+}
+
+func TestChannelGroupsCRUD(t *testing.T) {
+  saved := channelGroupsPath
+  channelGroupsPath = t.TempDir() + "/channel-groups.json"
+  t.Cleanup(func() { channelGroupsPath = saved })
+  s, fake := newFakeLNDServer(t)
+  peer := "02" + strings.Repeat("aa", 32)
+  fake.Channels = []lndclient.ChannelInfo{{ChannelID: 7, ChannelPoint: strings.Repeat("ab", 32) + ":0", RemotePubkey: peer, CapacitySat: 1000, LocalBalanceSat: 400, RemoteBalanceSat: 600, Active: true}}
+
+  if rec := serveAPI(s, http.MethodPost, "/api/lnops/groups", `{"name":"empty"}`); rec.Code != http.StatusBadRequest {
+    t.Fatalf("group without members: status = %d", rec.Code)
+  }
+  if rec := serveAPI(s, http.MethodPost, "/api/lnops/groups", `{"name":"bad","channels":["nope"]}`); rec.Code != http.StatusBadRequest {
+    t.Fatalf("bad channel point: status = %d", rec.Code)
+  }
+  rec := serveAPI(s, http.MethodPost, "/api/lnops/groups", `{"name":"lsp","peers":["`+strings.ToUpper(peer)+`"]}`)
+  if rec.Code != http.StatusOK {
+    t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+  }
+  var group channelGroup
+  if err := json.Unmarshal(rec.Body.Bytes(), &group); err != nil {
+    t.Fatal(err)
+  }
+  if group.ID == "" || group.Peers[0] != peer {
+    t.Fatalf("group %+v", group)
+  }
+  if rec := serveAPI(s, http.MethodPost, "/api/lnops/groups", `{"id":"missing","name":"x","peers":["`+peer+`"]}`); rec.Code != http.StatusNotFound {
+    t.Fatalf("replace unknown: status = %d", rec.Code)
+  }
+
+  rec = serveAPI(s, http.MethodGet, "/api/lnops/groups/stats?days=7", "")
+  if rec.Code != http.StatusOK {
+    t.Fatalf("stats status = %d: %s", rec.Code, rec.Body.String())
+  }
+  var stats struct {
+    Days int `json:"days"`
+    RevenueAvailable bool `json:"revenue_available"`
+    Groups []channelGroupStats `json:"groups"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+    t.Fatal(err)
+  }
+  if stats.Days != 7 || stats.RevenueAvailable || len(stats.Groups) != 1 || stats.Groups[0].LocalSat != 400 {
+    t.Fatalf("stats %+v", stats)
+  }
+  if rec := serveAPI(s, http.MethodGet, "/api/lnops/groups/stats?days=0", ""); rec.Code != http.StatusBadRequest {
+    t.Fatalf("days 0: status = %d", rec.Code)
+  }
+
+  if rec := serveAPI(s, http.MethodDelete, "/api/lnops/groups/"+group.ID, ""); rec.Code != http.StatusOK {
+    t.Fatalf("delete status = %d", rec.Code)
+  }
+  if rec := serveAPI(s, http.MethodDelete, "/api/lnops/groups/"+group.ID, ""); rec.Code != http.StatusNotFound {
+    t.Fatalf("second delete status = %d", rec.Code)
+  }
+}
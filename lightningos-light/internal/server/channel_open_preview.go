@@ -0,0 +1,172 @@
+package server
+
+import (
+  "net/http"
+
+  "lightningos-light/internal/lndclient"
+)
+
+const (
+  openPreviewTargetConf = 6
+  // fundingFallbackVbytes sizes a funding transaction with one P2WPKH input,
+  // the P2WSH funding output and a change output, for when LND cannot run
+  // coin selection (e.g. not enough funds yet).
+  fundingFallbackVbytes = 154
+  // cooperativeCloseVbytes spends the 2-of-2 funding output to two P2WPKH
+  // outputs.
+  cooperativeCloseVbytes = 169
+  // forceCloseVbytes is an anchor commitment without HTLCs (281 vB) plus
+  // the sweep of our output after the CSV delay (122 vB).
+  forceCloseVbytes = 403
+  // LND keeps 10k sats on-chain per anchor channel for fee bumping, up to
+  // 100k sats.
+  anchorReservePerChannelSat = 10000
+  anchorReserveMaxSat = 100000
+  channelReserveMinSat = 354
+)
+
+type channelOpenPreviewFunding struct {
+  Vbytes int64 `json:"vbytes"`
+  FeeSat int64 `json:"fee_sat"`
+  Estimated bool `json:"estimated"`
+}
+
+type channelOpenPreviewClose struct {
+  CooperativeVbytes int64 `json:"cooperative_vbytes"`
+  CooperativeFeeSat int64 `json:"cooperative_fee_sat"`
+  ForceVbytes int64 `json:"force_vbytes"`
+  ForceFeeSat int64 `json:"force_fee_sat"`
+}
+
+type channelOpenPreviewReserve struct {
+  ChannelReserveSat int64 `json:"channel_reserve_sat"`
+  WalletAnchorReserveSat int64 `json:"wallet_anchor_reserve_sat"`
+}
+
+type channelOpenPreview struct {
+  LocalFundingSat int64 `json:"local_funding_sat"`
+  SatPerVbyte int64 `json:"sat_per_vbyte"`
+  FeeRateSource string `json:"fee_rate_source"`
+  Funding channelOpenPreviewFunding `json:"funding"`
+  Close channelOpenPreviewClose `json:"close"`
+  Reserve channelOpenPreviewReserve `json:"reserve"`
+  // TotalCostSat is what the channel costs on-chain over its life with a
+  // cooperative close; WorstCaseCostSat assumes a force close instead.
+  TotalCostSat int64 `json:"total_cost_sat"`
+  WorstCaseCostSat int64 `json:"worst_case_cost_sat"`
+  RequiredOnchainSat int64 `json:"required_onchain_sat"`
+  OnchainConfirmedSat int64 `json:"onchain_confirmed_sat"`
+  SufficientFunds bool `json:"sufficient_funds"`
+  Warnings []string `json:"warnings"`
+}
+
+// buildChannelOpenPreview prices an open at satPerVbyte. estimate is LND's
+// coin selection dry run (nil when it failed) and channels the number of
+// open and pending channels before this one.
+func buildChannelOpenPreview(localFundingSat int64, satPerVbyte int64, estimate *lndclient.FeeEstimate, channels int, onchainConfirmedSat int64) channelOpenPreview {
+  preview := channelOpenPreview{
+    LocalFundingSat: localFundingSat,
+    SatPerVbyte: satPerVbyte,
+    FeeRateSource: "requested",
+    OnchainConfirmedSat: onchainConfirmedSat,
+    Warnings: []string{},
+  }
+  if satPerVbyte <= 0 && estimate != nil {
+    preview.SatPerVbyte = estimate.SatPerVbyte
+    preview.FeeRateSource = "lnd_estimate"
+  }
+  rate := preview.SatPerVbyte
+
+  vbytes := int64(fundingFallbackVbytes)
+  preview.Funding.Estimated = true
+  if estimate != nil && estimate.SatPerVbyte > 0 && estimate.FeeSat > 0 {
+    vbytes = (estimate.FeeSat + estimate.SatPerVbyte - 1) / estimate.SatPerVbyte
+    preview.Funding.Estimated = false
+  }
+  preview.Funding.Vbytes = vbytes
+  preview.Funding.FeeSat = vbytes * rate
+  if preview.Funding.Estimated {
+    preview.Warnings = append(preview.Warnings, "funding fee is approximate: LND could not select coins for this amount")
+  }
+
+  // Future fees are unknown; today's rate is the best available guess.
+  preview.Close = channelOpenPreviewClose{
+    CooperativeVbytes: cooperativeCloseVbytes,
+    CooperativeFeeSat: cooperativeCloseVbytes * rate,
+    ForceVbytes: forceCloseVbytes,
+    ForceFeeSat: forceCloseVbytes * rate,
+  }
+
+  reserve := localFundingSat / 100
+  if reserve < channelReserveMinSat {
+    reserve = channelReserveMinSat
+  }
+  preview.Reserve.ChannelReserveSat = reserve
+  if int64(channels+1)*anchorReservePerChannelSat <= anchorReserveMaxSat {
+    preview.Reserve.WalletAnchorReserveSat = anchorReservePerChannelSat
+  }
+
+  preview.TotalCostSat = preview.Funding.FeeSat + preview.Close.CooperativeFeeSat
+  preview.WorstCaseCostSat = preview.Funding.FeeSat + preview.Close.ForceFeeSat
+  preview.RequiredOnchainSat = localFundingSat + preview.Funding.FeeSat + preview.Reserve.WalletAnchorReserveSat
+  preview.SufficientFunds = onchainConfirmedSat >= preview.RequiredOnchainSat
+  if !preview.SufficientFunds {
+    preview.Warnings = append(preview.Warnings, "not enough confirmed on-chain funds for this channel")
+  }
+  return preview
+}
+
+func (s *Server) handleLNOpenChannelPreview(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    LocalFundingSat int64 `json:"local_funding_sat"`
+    SatPerVbyte int64 `json:"sat_per_vbyte"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if req.LocalFundingSat <= 0 {
+    writeError(w, http.StatusBadRequest, "local_funding_sat must be positive")
+    return
+  }
+  if req.SatPerVbyte < 0 {
+    writeError(w, http.StatusBadRequest, "sat_per_vbyte must be zero or positive")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  lnd := s.lndFor(r)
+
+  balances, err := lnd.GetBalances(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
+    return
+  }
+  channels, err := lnd.ListChannels(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  pending, _ := lnd.ListPendingChannels(ctx)
+  count := len(channels)
+  for _, item := range pending {
+    if item.Status == "opening" {
+      count++
+    }
+  }
+
+  var estimate *lndclient.FeeEstimate
+  if est, err := lnd.EstimateFundingFee(ctx, req.LocalFundingSat, openPreviewTargetConf); err == nil {
+    estimate = &est
+  } else if req.SatPerVbyte == 0 {
+    writeError(w, http.StatusBadRequest, "sat_per_vbyte required: LND could not estimate a fee rate")
+    return
+  }
+
+  preview := buildChannelOpenPreview(req.LocalFundingSat, req.SatPerVbyte, estimate, count, balances.OnchainConfirmedSat)
+  for i := range preview.Warnings {
+    preview.Warnings[i] = localize(w, preview.Warnings[i])
+  }
+  writeJSON(w, http.StatusOK, preview)
+}
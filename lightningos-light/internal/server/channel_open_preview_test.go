@@ -0,0 +1,42 @@
+package server
+
+import (
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestBuildChannelOpenPreview(t *testing.T) {
+  estimate := &lndclient.FeeEstimate{FeeSat: 1530, SatPerVbyte: 10}
+  preview := buildChannelOpenPreview(2_000_000, 4, estimate, 2, 3_000_000)
+  if preview.FeeRateSource != "requested" || preview.Funding.Vbytes != 153 || preview.Funding.FeeSat != 612 || preview.Funding.Estimated {
+    t.Fatalf("unexpected funding: %+v", preview)
+  }
+  if preview.Close.CooperativeFeeSat != cooperativeCloseVbytes*4 || preview.Close.ForceFeeSat != forceCloseVbytes*4 {
+    t.Fatalf("unexpected close costs: %+v", preview.Close)
+  }
+  if preview.Reserve.ChannelReserveSat != 20_000 || preview.Reserve.WalletAnchorReserveSat != anchorReservePerChannelSat {
+    t.Fatalf("unexpected reserves: %+v", preview.Reserve)
+  }
+  if preview.TotalCostSat != 612+cooperativeCloseVbytes*4 || !preview.SufficientFunds || preview.RequiredOnchainSat != 2_000_000+612+anchorReservePerChannelSat {
+    t.Fatalf("unexpected totals: %+v", preview)
+  }
+
+  // LND's own rate is used when none is requested; the anchor reserve is
+  // capped once ten channels exist.
+  preview = buildChannelOpenPreview(20_000, 0, estimate, 10, 21_000)
+  if preview.SatPerVbyte != 10 || preview.FeeRateSource != "lnd_estimate" {
+    t.Fatalf("expected LND fee rate, got %+v", preview)
+  }
+  if preview.Reserve.ChannelReserveSat != channelReserveMinSat || preview.Reserve.WalletAnchorReserveSat != 0 {
+    t.Fatalf("unexpected reserves: %+v", preview.Reserve)
+  }
+  if preview.SufficientFunds || len(preview.Warnings) != 1 {
+    t.Fatalf("expected an insufficient funds warning: %+v", preview)
+  }
+
+  preview = buildChannelOpenPreview(100_000, 2, nil, 0, 0)
+  if !preview.Funding.Estimated || preview.Funding.Vbytes != fundingFallbackVbytes || len(preview.Warnings) != 2 {
+    t.Fatalf("expected fallback funding size: %+v", preview)
+  }
+}
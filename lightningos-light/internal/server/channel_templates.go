@@ -0,0 +1,501 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+
+  "github.com/go-chi/chi/v5"
+  "github.com/jackc/pgx/v5"
+)
+
+const (
+  channelTemplatesPath = "/var/lib/lightningos/channel-templates.json"
+  maxChannelTemplates = 50
+  maxChannelTemplateName = 60
+
+  jobKindChannelFeeApply = "channel_fee_apply"
+  channelFeeApplyPoll = time.Minute
+  channelFeeApplyMaxWait = 14 * 24 * time.Hour
+)
+
+// channelFeeProfile is the policy applied once a channel opened from a
+// template is active; LND only accepts a policy for an open channel.
+type channelFeeProfile struct {
+  BaseFeeMsat int64 `json:"base_fee_msat"`
+  FeeRatePpm int64 `json:"fee_rate_ppm"`
+  TimeLockDelta int64 `json:"time_lock_delta,omitempty"`
+  InboundEnabled bool `json:"inbound_enabled,omitempty"`
+  InboundBaseMsat int64 `json:"inbound_base_msat,omitempty"`
+  InboundFeeRatePpm int64 `json:"inbound_fee_rate_ppm,omitempty"`
+}
+
+func (p channelFeeProfile) validate() error {
+  if p.BaseFeeMsat < 0 || p.FeeRatePpm < 0 || p.TimeLockDelta < 0 {
+    return errors.New("fees must be zero or positive")
+  }
+  return nil
+}
+
+// channelTemplate is a saved set of open parameters for repeat opens.
+type channelTemplate struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  LocalFundingSat int64 `json:"local_funding_sat"`
+  Private bool `json:"private"`
+  SatPerVbyte int64 `json:"sat_per_vbyte,omitempty"`
+  CloseAddress string `json:"close_address,omitempty"`
+  FeeProfile *channelFeeProfile `json:"fee_profile,omitempty"`
+  CreatedAt time.Time `json:"created_at"`
+  UpdatedAt time.Time `json:"updated_at"`
+}
+
+var channelTemplatesMu sync.Mutex
+
+func loadChannelTemplates() ([]channelTemplate, error) {
+  raw, err := os.ReadFile(channelTemplatesPath)
+  if errors.Is(err, os.ErrNotExist) {
+    return []channelTemplate{}, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  templates := []channelTemplate{}
+  if err := json.Unmarshal(raw, &templates); err != nil {
+    return nil, fmt.Errorf("invalid %s: %w", channelTemplatesPath, err)
+  }
+  return templates, nil
+}
+
+func saveChannelTemplates(templates []channelTemplate) error {
+  if err := os.MkdirAll(filepath.Dir(channelTemplatesPath), 0o750); err != nil {
+    return err
+  }
+  data, err := json.MarshalIndent(templates, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(channelTemplatesPath, data, 0o600)
+}
+
+func findChannelTemplate(id string) (channelTemplate, bool, error) {
+  channelTemplatesMu.Lock()
+  defer channelTemplatesMu.Unlock()
+  templates, err := loadChannelTemplates()
+  if err != nil {
+    return channelTemplate{}, false, err
+  }
+  for _, t := range templates {
+    if t.ID == id {
+      return t, true, nil
+    }
+  }
+  return channelTemplate{}, false, nil
+}
+
+func (t *channelTemplate) normalize() error {
+  t.Name = strings.TrimSpace(t.Name)
+  t.CloseAddress = strings.TrimSpace(t.CloseAddress)
+  if t.Name == "" {
+    return errors.New("name required")
+  }
+  if len(t.Name) > maxChannelTemplateName {
+    return errors.New("name too long")
+  }
+  if t.LocalFundingSat <= 0 {
+    return errors.New("local_funding_sat must be positive")
+  }
+  if t.SatPerVbyte < 0 {
+    return errors.New("sat_per_vbyte must be zero or positive")
+  }
+  if t.FeeProfile != nil {
+    if err := t.FeeProfile.validate(); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (s *Server) handleChannelTemplatesList(w http.ResponseWriter, r *http.Request) {
+  channelTemplatesMu.Lock()
+  templates, err := loadChannelTemplates()
+  channelTemplatesMu.Unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel templates: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": templates})
+}
+
+// handleChannelTemplatesSave creates a template, or replaces the one named
+// by id.
+func (s *Server) handleChannelTemplatesSave(w http.ResponseWriter, r *http.Request) {
+  var req channelTemplate
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if err := req.normalize(); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  channelTemplatesMu.Lock()
+  defer channelTemplatesMu.Unlock()
+  templates, err := loadChannelTemplates()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel templates: %v", err))
+    return
+  }
+  now := time.Now().UTC()
+  req.UpdatedAt = now
+  saved := false
+  if req.ID != "" {
+    for i := range templates {
+      if templates[i].ID == req.ID {
+        req.CreatedAt = templates[i].CreatedAt
+        templates[i] = req
+        saved = true
+        break
+      }
+    }
+    if !saved {
+      writeError(w, http.StatusNotFound, "channel template not found")
+      return
+    }
+  } else {
+    if len(templates) >= maxChannelTemplates {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d channel templates", maxChannelTemplates))
+      return
+    }
+    id, err := randomToken(6)
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store channel templates: %v", err))
+      return
+    }
+    req.ID = id
+    req.CreatedAt = now
+    templates = append(templates, req)
+  }
+  if err := saveChannelTemplates(templates); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store channel templates: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, req)
+}
+
+func (s *Server) handleChannelTemplatesDelete(w http.ResponseWriter, r *http.Request) {
+  id := chi.URLParam(r, "id")
+  channelTemplatesMu.Lock()
+  defer channelTemplatesMu.Unlock()
+  templates, err := loadChannelTemplates()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel templates: %v", err))
+    return
+  }
+  kept := templates[:0]
+  for _, t := range templates {
+    if t.ID != id {
+      kept = append(kept, t)
+    }
+  }
+  if len(kept) == len(templates) {
+    writeError(w, http.StatusNotFound, "channel template not found")
+    return
+  }
+  if err := saveChannelTemplates(kept); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store channel templates: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// channelOpenOverrides are the per-open fields that may differ from the
+// template.
+type channelOpenOverrides struct {
+  PeerAddress string `json:"peer_address"`
+  LocalFundingSat int64 `json:"local_funding_sat"`
+  SatPerVbyte *int64 `json:"sat_per_vbyte"`
+  AllowHighFee bool `json:"allow_high_fee"`
+}
+
+func (o channelOpenOverrides) apply(t channelTemplate) (channelTemplate, error) {
+  if o.LocalFundingSat < 0 {
+    return t, errors.New("local_funding_sat must be positive")
+  }
+  if o.LocalFundingSat > 0 {
+    t.LocalFundingSat = o.LocalFundingSat
+  }
+  if o.SatPerVbyte != nil {
+    if *o.SatPerVbyte < 0 {
+      return t, errors.New("sat_per_vbyte must be zero or positive")
+    }
+    t.SatPerVbyte = *o.SatPerVbyte
+  }
+  return t, nil
+}
+
+// openFromTemplate connects when host is set, opens the channel and, when the
+// template carries a fee profile, starts the job that applies it once the
+// channel is active. It returns the HTTP status to use on failure.
+func (s *Server) openFromTemplate(ctx context.Context, nodeID string, lnd lndclient.API, pubkey, host string, t channelTemplate) (map[string]any, int, error) {
+  if host != "" {
+    if err := lnd.ConnectPeer(ctx, pubkey, host, false); err != nil && !isAlreadyConnected(err) {
+      return nil, http.StatusInternalServerError, errors.New(lndRPCErrorMessage(err))
+    }
+  }
+  channelPoint, err := lnd.OpenChannel(ctx, pubkey, t.LocalFundingSat, t.CloseAddress, t.Private, t.SatPerVbyte)
+  if err != nil {
+    return nil, http.StatusInternalServerError, errors.New(lndDetailedErrorMessage(err))
+  }
+  resp := map[string]any{"channel_point": channelPoint, "template_id": t.ID}
+  if t.FeeProfile != nil {
+    job, err := s.jobs.submit(nodeID, jobKindChannelFeeApply, channelPoint, channelFeeApplyParams{
+      ChannelPoint: channelPoint,
+      Profile: *t.FeeProfile,
+      Until: time.Now().Add(channelFeeApplyMaxWait).UTC(),
+    })
+    if err != nil {
+      s.logger.Printf("channel templates: fee profile for %s not scheduled: %v", channelPoint, err)
+      resp["fee_profile_error"] = err.Error()
+    } else {
+      resp["fee_job_id"] = job.ID
+    }
+  }
+  return resp, http.StatusOK, nil
+}
+
+func (s *Server) handleChannelTemplateOpen(w http.ResponseWriter, r *http.Request) {
+  var req channelOpenOverrides
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  t, found, err := findChannelTemplate(chi.URLParam(r, "id"))
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel templates: %v", err))
+    return
+  }
+  if !found {
+    writeError(w, http.StatusNotFound, "channel template not found")
+    return
+  }
+  t, err = req.apply(t)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  if err := checkAutomatedCloseAddress(r, t.CloseAddress); err != nil {
+    writeError(w, http.StatusForbidden, err.Error())
+    return
+  }
+  if !s.guardFeeRate(w, r, feeGuardOpen, t.SatPerVbyte, req.AllowHighFee) {
+    return
+  }
+  pubkey, host, err := parsePeerAddress(req.PeerAddress)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  if !strings.Contains(host, ":") {
+    writeError(w, http.StatusBadRequest, "peer host must include host:port")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  resp, status, err := s.openFromTemplate(ctx, s.nodeIDFor(r), s.lndFor(r), pubkey, host, t)
+  if err != nil {
+    writeError(w, status, err.Error())
+    return
+  }
+  writeJSON(w, http.StatusOK, resp)
+}
+
+// closedChannelPeer finds who was on the other side of a channel: LND while
+// the close is pending, the stored channel notifications afterwards.
+func (s *Server) closedChannelPeer(ctx context.Context, r *http.Request, channelPoint string) (string, error) {
+  lnd := s.lndFor(r)
+  if pending, err := lnd.ListPendingChannels(ctx); err == nil {
+    for _, ch := range pending {
+      if ch.ChannelPoint == channelPoint && ch.RemotePubkey != "" {
+        return ch.RemotePubkey, nil
+      }
+    }
+  }
+  notifier := s.notifierFor(r)
+  if notifier == nil || notifier.db == nil {
+    return "", errors.New("peer unknown for this channel; pass peer_address")
+  }
+  var pubkey string
+  err := notifier.db.QueryRow(ctx, `
+select peer_pubkey from notifications
+where node_id=$1 and type='channel' and channel_point=$2 and coalesce(peer_pubkey, '') <> ''
+order by id desc limit 1`, notifier.nodeKey(), channelPoint).Scan(&pubkey)
+  if errors.Is(err, pgx.ErrNoRows) {
+    return "", errors.New("peer unknown for this channel; pass peer_address")
+  }
+  return pubkey, err
+}
+
+// handleChannelReopen opens a new channel to the peer of a closed (or
+// closing) channel, from a template or with explicit parameters.
+func (s *Server) handleChannelReopen(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    channelOpenOverrides
+    ChannelPoint string `json:"channel_point"`
+    TemplateID string `json:"template_id"`
+    Private bool `json:"private"`
+    CloseAddress string `json:"close_address"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  req.ChannelPoint = strings.TrimSpace(req.ChannelPoint)
+  if req.ChannelPoint == "" {
+    writeError(w, http.StatusBadRequest, "channel_point required")
+    return
+  }
+
+  t := channelTemplate{Private: req.Private, CloseAddress: strings.TrimSpace(req.CloseAddress)}
+  if id := strings.TrimSpace(req.TemplateID); id != "" {
+    found := false
+    var err error
+    t, found, err = findChannelTemplate(id)
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load channel templates: %v", err))
+      return
+    }
+    if !found {
+      writeError(w, http.StatusNotFound, "channel template not found")
+      return
+    }
+  }
+  t, err := req.apply(t)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  if t.LocalFundingSat <= 0 {
+    writeError(w, http.StatusBadRequest, "local_funding_sat must be positive")
+    return
+  }
+  if err := checkAutomatedCloseAddress(r, t.CloseAddress); err != nil {
+    writeError(w, http.StatusForbidden, err.Error())
+    return
+  }
+  if !s.guardFeeRate(w, r, feeGuardOpen, t.SatPerVbyte, req.AllowHighFee) {
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+
+  lnd := s.lndFor(r)
+  if channels, err := lnd.ListChannels(ctx); err == nil {
+    for _, ch := range channels {
+      if ch.ChannelPoint == req.ChannelPoint {
+        writeError(w, http.StatusConflict, "channel is still open")
+        return
+      }
+    }
+  }
+
+  var pubkey, host string
+  if strings.TrimSpace(req.PeerAddress) != "" {
+    pubkey, host, err = parsePeerAddress(req.PeerAddress)
+    if err != nil {
+      writeError(w, http.StatusBadRequest, err.Error())
+      return
+    }
+  } else {
+    pubkey, err = s.closedChannelPeer(ctx, r, req.ChannelPoint)
+    if err != nil {
+      writeError(w, http.StatusBadRequest, err.Error())
+      return
+    }
+    // Without an address the peer has to be connected already.
+    connected := false
+    if peers, err := lnd.ListPeers(ctx); err == nil {
+      for _, peer := range peers {
+        if peer.PubKey == pubkey {
+          connected = true
+          break
+        }
+      }
+    }
+    if !connected {
+      writeError(w, http.StatusConflict, "peer is not connected; pass peer_address")
+      return
+    }
+  }
+
+  resp, status, err := s.openFromTemplate(ctx, s.nodeIDFor(r), lnd, pubkey, host, t)
+  if err != nil {
+    writeError(w, status, err.Error())
+    return
+  }
+  resp["reopened_from"] = req.ChannelPoint
+  resp["peer_pubkey"] = pubkey
+  writeJSON(w, http.StatusOK, resp)
+}
+
+type channelFeeApplyParams struct {
+  ChannelPoint string `json:"channel_point"`
+  Profile channelFeeProfile `json:"profile"`
+  Until time.Time `json:"until"`
+}
+
+// runChannelFeeApplyJob waits for the channel to become active and sets the
+// template's policy on it. It is resumable, so a restart while the funding
+// transaction confirms does not lose the profile.
+func (s *Server) runChannelFeeApplyJob(ctx context.Context, job *jobHandle) (any, error) {
+  var params channelFeeApplyParams
+  if err := job.Params(&params); err != nil {
+    return nil, err
+  }
+  lnd, ok := s.lndForNode(job.NodeID())
+  if !ok {
+    return nil, unknownNodeError(job.NodeID())
+  }
+  for {
+    if !params.Until.IsZero() && time.Now().After(params.Until) {
+      return nil, errors.New("channel did not become active in time")
+    }
+    rpcCtx, cancel := s.operationContext(ctx, timeoutLNDRPC)
+    channels, err := lnd.ListChannels(rpcCtx)
+    cancel()
+    if err == nil {
+      for _, ch := range channels {
+        if ch.ChannelPoint != params.ChannelPoint || !ch.Active {
+          continue
+        }
+        p := params.Profile
+        rpcCtx, cancel := s.operationContext(ctx, timeoutLNDRPC)
+        err := lnd.UpdateChannelFees(rpcCtx, params.ChannelPoint, false, p.BaseFeeMsat, p.FeeRatePpm, p.TimeLockDelta,
+          p.InboundEnabled, p.InboundBaseMsat, p.InboundFeeRatePpm)
+        cancel()
+        if err != nil {
+          return nil, errors.New(lndRPCErrorMessage(err))
+        }
+        return map[string]any{"ok": true, "channel_point": params.ChannelPoint}, nil
+      }
+    }
+    job.Progress(0, 1, map[string]string{"waiting_for": "channel active"})
+    select {
+    case <-ctx.Done():
+      return nil, ctx.Err()
+    case <-time.After(channelFeeApplyPoll):
+    }
+  }
+}
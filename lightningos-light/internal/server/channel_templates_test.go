@@ -0,0 +1,80 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "net/http"
+  "strings"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestChannelOpenOverrides(t *testing.T) {
+  base := channelTemplate{ID: "t1", Name: "routing", LocalFundingSat: 2_000_000, SatPerVbyte: 5}
+  fee := int64(12)
+  got, err := channelOpenOverrides{LocalFundingSat: 3_000_000, SatPerVbyte: &fee}.apply(base)
+  if err != nil {
+    t.Fatalf("apply: %v", err)
+  }
+  if got.LocalFundingSat != 3_000_000 || got.SatPerVbyte != 12 || got.Name != "routing" {
+    t.Fatalf("overrides not applied: %+v", got)
+  }
+  if got, _ := (channelOpenOverrides{}).apply(base); got != base {
+    t.Fatalf("empty overrides changed the template: %+v", got)
+  }
+  negative := int64(-1)
+  if _, err := (channelOpenOverrides{SatPerVbyte: &negative}).apply(base); err == nil {
+    t.Fatal("negative sat_per_vbyte accepted")
+  }
+}
+
+func TestChannelReopenFromPendingClose(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  pubkey := "02" + strings.Repeat("ab", 32)
+  fake.Pending = []lndclient.PendingChannelInfo{{ChannelPoint: "aa:0", RemotePubkey: pubkey, Status: "closing"}}
+  fake.Peers = []lndclient.PeerInfo{{PubKey: pubkey, Address: "10.0.0.1:9735"}}
+
+  if rec := serveAPI(s, http.MethodPost, "/api/lnops/channel/reopen", `{"channel_point":"aa:0"}`); rec.Code != http.StatusBadRequest {
+    t.Fatalf("reopen without size: %d %s", rec.Code, rec.Body.String())
+  }
+  rec := serveAPI(s, http.MethodPost, "/api/lnops/channel/reopen", `{"channel_point":"aa:0","local_funding_sat":1000000,"private":true}`)
+  if rec.Code != http.StatusOK {
+    t.Fatalf("reopen: %d %s", rec.Code, rec.Body.String())
+  }
+  var resp map[string]any
+  _ = json.Unmarshal(rec.Body.Bytes(), &resp)
+  if resp["peer_pubkey"] != pubkey || resp["channel_point"] == "" {
+    t.Fatalf("unexpected response: %v", resp)
+  }
+  calls := fake.Calls("OpenChannel")
+  if len(calls) != 1 || calls[0].Args[0] != pubkey || calls[0].Args[1] != int64(1000000) || calls[0].Args[3] != true {
+    t.Fatalf("unexpected open: %+v", calls)
+  }
+  if len(fake.Calls("ConnectPeer")) != 0 {
+    t.Fatal("connected to an already connected peer")
+  }
+}
+
+func TestChannelFeeApplyJob(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  fake.Channels = []lndclient.ChannelInfo{{ChannelPoint: "bb:1", Active: true}}
+  job, err := s.jobs.submit("default", jobKindChannelFeeApply, "bb:1", channelFeeApplyParams{
+    ChannelPoint: "bb:1",
+    Profile: channelFeeProfile{BaseFeeMsat: 0, FeeRatePpm: 450},
+    Until: time.Now().Add(time.Hour),
+  })
+  if err != nil {
+    t.Fatalf("submit: %v", err)
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  if done := s.jobs.wait(ctx, job.ID); done.State != jobStateDone {
+    t.Fatalf("job ended %s: %s", done.State, done.Error)
+  }
+  calls := fake.Calls("UpdateChannelFees")
+  if len(calls) != 1 || calls[0].Args[0] != "bb:1" || calls[0].Args[3] != int64(450) {
+    t.Fatalf("unexpected fee update: %+v", calls)
+  }
+}
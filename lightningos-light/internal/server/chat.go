@@ -39,16 +39,18 @@ type ChatMessage struct {
 }
 
 type ChatService struct {
-  lnd *lndclient.Client
+  lnd lndclient.API
   logger *log.Logger
   store *chatStore
   mu sync.Mutex
   started bool
   stop chan struct{}
+  // ctx is the server's root context, set by Start.
+  ctx context.Context
   notifier *Notifier
 }
 
-func NewChatService(lnd *lndclient.Client, logger *log.Logger) *ChatService {
+func NewChatService(lnd lndclient.API, logger *log.Logger) *ChatService {
   return &ChatService{
     lnd: lnd,
     logger: logger,
@@ -62,17 +64,32 @@ func (c *ChatService) AttachNotifier(notifier *Notifier) {
   c.mu.Unlock()
 }
 
-func (c *ChatService) Start() {
+// Start follows keysend messages until ctx ends.
+func (c *ChatService) Start(ctx context.Context) {
   c.mu.Lock()
   if c.started {
     c.mu.Unlock()
     return
   }
   c.started = true
+  c.ctx = ctx
   c.stop = make(chan struct{})
   c.mu.Unlock()
+  go func() {
+    <-ctx.Done()
+    close(c.stop)
+  }()
 
-  go c.runInvoices()
+  goSafe("chat/invoices", c.runInvoices)
+}
+
+// context is the parent for the service's work: the root context once
+// started, Background before.
+func (c *ChatService) context() context.Context {
+  if c.ctx == nil {
+    return context.Background()
+  }
+  return c.ctx
 }
 
 func (c *ChatService) Messages(peerPubkey string, limit int) ([]ChatMessage, error) {
@@ -153,7 +170,7 @@ func (c *ChatService) recordKeysendNotification(msg ChatMessage) {
     PaymentHash: hash,
   }
 
-  ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+  ctx, cancel := context.WithTimeout(c.context(), 4*time.Second)
   _, _ = notifier.upsertNotification(ctx, fmt.Sprintf("payment:%s", hash), evt)
   cancel()
 }
@@ -168,7 +185,7 @@ func (c *ChatService) runInvoices() {
 
     settleIndex := c.store.loadCursor()
 
-    conn, err := c.lnd.DialLightning(context.Background())
+    conn, err := c.lnd.DialLightning(c.context())
     if err != nil {
       c.logger.Printf("chat: invoice stream dial failed: %v", err)
       time.Sleep(5 * time.Second)
@@ -176,7 +193,7 @@ func (c *ChatService) runInvoices() {
     }
 
     client := lnrpc.NewLightningClient(conn)
-    stream, err := client.SubscribeInvoices(context.Background(), &lnrpc.InvoiceSubscription{
+    stream, err := client.SubscribeInvoices(c.context(), &lnrpc.InvoiceSubscription{
       SettleIndex: settleIndex,
     })
     if err != nil {
@@ -238,7 +255,7 @@ func (c *ChatService) runInvoices() {
 }
 
 func (c *ChatService) lookupPeerByChanID(chanID uint64) (string, string) {
-  ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+  ctx, cancel := context.WithTimeout(c.context(), 4*time.Second)
   defer cancel()
 
   channels, err := c.lnd.ListChannels(ctx)
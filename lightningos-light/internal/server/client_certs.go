@@ -0,0 +1,472 @@
+package server
+
+import (
+  "archive/tar"
+  "bytes"
+  "compress/gzip"
+  "crypto/ecdsa"
+  "crypto/elliptic"
+  "crypto/rand"
+  "crypto/sha256"
+  "crypto/tls"
+  "crypto/x509"
+  "crypto/x509/pkix"
+  "encoding/hex"
+  "encoding/json"
+  "encoding/pem"
+  "errors"
+  "fmt"
+  "math/big"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/go-chi/chi/v5"
+)
+
+// Client certificates authenticate scripts and monitoring agents without a
+// password. The manager runs its own small CA; private keys of issued certs
+// only leave the server once, inside the pairing bundle.
+const (
+  clientCADir = "/var/lib/lightningos/client-ca"
+  clientCertsRegistryPath = "/var/lib/lightningos/client-certs.json"
+  clientCertDefaultDays = 365
+  clientCertMaxDays = 3650
+)
+
+var (
+  clientCACertPath = filepath.Join(clientCADir, "ca.crt")
+  clientCAKeyPath = filepath.Join(clientCADir, "ca.key")
+)
+
+type clientCertRecord struct {
+  Serial string `json:"serial"`
+  Name string `json:"name"`
+  Fingerprint string `json:"fingerprint"`
+  CreatedAt time.Time `json:"created_at"`
+  ExpiresAt time.Time `json:"expires_at"`
+  RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type clientCA struct {
+  cert *x509.Certificate
+  key *ecdsa.PrivateKey
+  certPEM []byte
+}
+
+var clientCertState struct {
+  mu sync.Mutex
+  pool *x509.CertPool
+  poolModTime time.Time
+  active map[string]bool
+  activeModTime time.Time
+}
+
+func encodeECKey(key *ecdsa.PrivateKey) ([]byte, error) {
+  der, err := x509.MarshalECPrivateKey(key)
+  if err != nil {
+    return nil, err
+  }
+  return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func randomSerial() (*big.Int, error) {
+  return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+}
+
+func newClientCA(now time.Time) (*clientCA, []byte, error) {
+  key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+  if err != nil {
+    return nil, nil, err
+  }
+  serial, err := randomSerial()
+  if err != nil {
+    return nil, nil, err
+  }
+  tmpl := &x509.Certificate{
+    SerialNumber: serial,
+    Subject: pkix.Name{CommonName: "LightningOS client CA"},
+    NotBefore: now.Add(-time.Hour),
+    NotAfter: now.AddDate(20, 0, 0),
+    KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+    BasicConstraintsValid: true,
+    IsCA: true,
+    MaxPathLenZero: true,
+  }
+  der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+  if err != nil {
+    return nil, nil, err
+  }
+  cert, err := x509.ParseCertificate(der)
+  if err != nil {
+    return nil, nil, err
+  }
+  keyPEM, err := encodeECKey(key)
+  if err != nil {
+    return nil, nil, err
+  }
+  certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+  return &clientCA{cert: cert, key: key, certPEM: certPEM}, keyPEM, nil
+}
+
+func parseClientCA(certPEM []byte, keyPEM []byte) (*clientCA, error) {
+  pair, err := tls.X509KeyPair(certPEM, keyPEM)
+  if err != nil {
+    return nil, err
+  }
+  cert, err := x509.ParseCertificate(pair.Certificate[0])
+  if err != nil {
+    return nil, err
+  }
+  key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+  if !ok {
+    return nil, errors.New("client CA key is not ECDSA")
+  }
+  return &clientCA{cert: cert, key: key, certPEM: certPEM}, nil
+}
+
+// loadOrCreateClientCA returns the manager's client CA, creating it the first
+// time a client cert is issued.
+func loadOrCreateClientCA() (*clientCA, error) {
+  certPEM, certErr := os.ReadFile(clientCACertPath)
+  keyPEM, keyErr := os.ReadFile(clientCAKeyPath)
+  if certErr == nil && keyErr == nil {
+    return parseClientCA(certPEM, keyPEM)
+  }
+  if !errors.Is(certErr, os.ErrNotExist) && certErr != nil {
+    return nil, certErr
+  }
+  ca, keyPEM, err := newClientCA(time.Now())
+  if err != nil {
+    return nil, err
+  }
+  if err := os.MkdirAll(clientCADir, 0o700); err != nil {
+    return nil, err
+  }
+  if err := writeFileAtomic(clientCAKeyPath, keyPEM, 0o600); err != nil {
+    return nil, err
+  }
+  if err := writeFileAtomic(clientCACertPath, ca.certPEM, 0o644); err != nil {
+    return nil, err
+  }
+  return ca, nil
+}
+
+func issueClientCert(ca *clientCA, name string, days int, now time.Time) (clientCertRecord, []byte, []byte, error) {
+  key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+  if err != nil {
+    return clientCertRecord{}, nil, nil, err
+  }
+  serial, err := randomSerial()
+  if err != nil {
+    return clientCertRecord{}, nil, nil, err
+  }
+  tmpl := &x509.Certificate{
+    SerialNumber: serial,
+    Subject: pkix.Name{CommonName: name, Organization: []string{"LightningOS client"}},
+    NotBefore: now.Add(-time.Hour),
+    NotAfter: now.AddDate(0, 0, days),
+    KeyUsage: x509.KeyUsageDigitalSignature,
+    ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+  }
+  der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+  if err != nil {
+    return clientCertRecord{}, nil, nil, err
+  }
+  keyPEM, err := encodeECKey(key)
+  if err != nil {
+    return clientCertRecord{}, nil, nil, err
+  }
+  sum := sha256.Sum256(der)
+  record := clientCertRecord{
+    Serial: serial.Text(16),
+    Name: name,
+    Fingerprint: hex.EncodeToString(sum[:]),
+    CreatedAt: now.UTC(),
+    ExpiresAt: tmpl.NotAfter.UTC(),
+  }
+  return record, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+func readClientCertRecords() ([]clientCertRecord, error) {
+  records := []clientCertRecord{}
+  raw, err := os.ReadFile(clientCertsRegistryPath)
+  if err != nil {
+    if errors.Is(err, os.ErrNotExist) {
+      return records, nil
+    }
+    return nil, err
+  }
+  if err := json.Unmarshal(raw, &records); err != nil {
+    return nil, err
+  }
+  return records, nil
+}
+
+func writeClientCertRecords(records []clientCertRecord) error {
+  raw, err := json.MarshalIndent(records, "", "  ")
+  if err != nil {
+    return err
+  }
+  if err := os.MkdirAll(filepath.Dir(clientCertsRegistryPath), 0o750); err != nil {
+    return err
+  }
+  return writeFileAtomic(clientCertsRegistryPath, raw, 0o600)
+}
+
+// clientCAPool is used in the TLS handshake; it is reloaded when the CA file
+// changes so the first issued cert works without a restart.
+func clientCAPool() *x509.CertPool {
+  clientCertState.mu.Lock()
+  defer clientCertState.mu.Unlock()
+  info, err := os.Stat(clientCACertPath)
+  if err != nil {
+    clientCertState.pool = nil
+    return nil
+  }
+  if clientCertState.pool != nil && info.ModTime().Equal(clientCertState.poolModTime) {
+    return clientCertState.pool
+  }
+  raw, err := os.ReadFile(clientCACertPath)
+  if err != nil {
+    return clientCertState.pool
+  }
+  pool := x509.NewCertPool()
+  if !pool.AppendCertsFromPEM(raw) {
+    return clientCertState.pool
+  }
+  clientCertState.pool = pool
+  clientCertState.poolModTime = info.ModTime()
+  return pool
+}
+
+// clientCertActive reports whether serial is in the registry and not
+// revoked; a cert signed by the CA but unknown to the registry is refused.
+func clientCertActive(serial string) bool {
+  clientCertState.mu.Lock()
+  defer clientCertState.mu.Unlock()
+  info, err := os.Stat(clientCertsRegistryPath)
+  if err != nil {
+    return false
+  }
+  if clientCertState.active == nil || !info.ModTime().Equal(clientCertState.activeModTime) {
+    records, err := readClientCertRecords()
+    if err != nil {
+      return false
+    }
+    active := map[string]bool{}
+    for _, record := range records {
+      if record.RevokedAt == nil {
+        active[record.Serial] = true
+      }
+    }
+    clientCertState.active = active
+    clientCertState.activeModTime = info.ModTime()
+  }
+  return clientCertState.active[serial]
+}
+
+// withClientCertTLS makes the TLS listener ask for a client cert signed by
+// the manager's client CA. Browsers without such a cert are not prompted and
+// connect as before.
+func withClientCertTLS(base *tls.Config) *tls.Config {
+  base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+    pool := clientCAPool()
+    if pool == nil {
+      return nil, nil
+    }
+    cfg := base.Clone()
+    cfg.GetConfigForClient = nil
+    cfg.ClientCAs = pool
+    cfg.ClientAuth = tls.VerifyClientCertIfGiven
+    return cfg, nil
+  }
+  return base
+}
+
+// clientCertName returns the name of a verified, unrevoked client cert on r.
+func clientCertName(r *http.Request) (string, bool) {
+  if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+    return "", false
+  }
+  cert := r.TLS.VerifiedChains[0][0]
+  if !clientCertActive(cert.SerialNumber.Text(16)) {
+    return "", false
+  }
+  return cert.Subject.CommonName, true
+}
+
+type pairingInfo struct {
+  URL string `json:"url"`
+  Name string `json:"name"`
+  Serial string `json:"serial"`
+  ExpiresAt time.Time `json:"expires_at"`
+}
+
+func buildPairingBundle(info pairingInfo, certPEM []byte, keyPEM []byte, caPEM []byte, serverCertPEM []byte) ([]byte, error) {
+  meta, err := json.MarshalIndent(info, "", "  ")
+  if err != nil {
+    return nil, err
+  }
+  readme := fmt.Sprintf(`LightningOS client certificate "%s"
+
+  curl --cert client.crt --key client.key --cacert server.crt %s/api/health
+
+Keep client.key private. Revoke the certificate in the manager if it leaks.
+`, info.Name, info.URL)
+  files := []struct {
+    name string
+    data []byte
+    mode int64
+  }{
+    {"client.crt", certPEM, 0o644},
+    {"client.key", keyPEM, 0o600},
+    {"client-ca.crt", caPEM, 0o644},
+    {"server.crt", serverCertPEM, 0o644},
+    {"pairing.json", meta, 0o644},
+    {"README.txt", []byte(readme), 0o644},
+  }
+
+  var buf bytes.Buffer
+  gz := gzip.NewWriter(&buf)
+  tw := tar.NewWriter(gz)
+  dir := "lightningos-" + info.Serial[:min(8, len(info.Serial))]
+  for _, file := range files {
+    if len(file.data) == 0 {
+      continue
+    }
+    hdr := &tar.Header{
+      Name: dir + "/" + file.name,
+      Mode: file.mode,
+      Size: int64(len(file.data)),
+      ModTime: time.Now(),
+    }
+    if err := tw.WriteHeader(hdr); err != nil {
+      return nil, err
+    }
+    if _, err := tw.Write(file.data); err != nil {
+      return nil, err
+    }
+  }
+  if err := tw.Close(); err != nil {
+    return nil, err
+  }
+  if err := gz.Close(); err != nil {
+    return nil, err
+  }
+  return buf.Bytes(), nil
+}
+
+var clientCertsMu sync.Mutex
+
+func (s *Server) handleClientCertsList(w http.ResponseWriter, r *http.Request) {
+  records, err := readClientCertRecords()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to read client certificates")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": records})
+}
+
+// handleClientCertsCreate issues a cert and answers with the pairing bundle
+// (tar.gz). The private key is not stored on the server.
+func (s *Server) handleClientCertsCreate(w http.ResponseWriter, r *http.Request) {
+  // A client certificate must not be able to mint itself a successor.
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "client certificates cannot issue client certificates")
+    return
+  }
+  var req struct {
+    Name string `json:"name"`
+    Days int `json:"days"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  name := strings.TrimSpace(req.Name)
+  if name == "" || len(name) > 64 {
+    writeError(w, http.StatusBadRequest, "name must be 1 to 64 characters")
+    return
+  }
+  days := req.Days
+  if days == 0 {
+    days = clientCertDefaultDays
+  }
+  if days < 1 || days > clientCertMaxDays {
+    writeError(w, http.StatusBadRequest, "days must be between 1 and 3650")
+    return
+  }
+
+  clientCertsMu.Lock()
+  defer clientCertsMu.Unlock()
+  ca, err := loadOrCreateClientCA()
+  if err != nil {
+    s.logger.Printf("client certs: CA unavailable: %v", err)
+    writeError(w, http.StatusInternalServerError, "failed to prepare client CA")
+    return
+  }
+  record, certPEM, keyPEM, err := issueClientCert(ca, name, days, time.Now())
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to issue client certificate")
+    return
+  }
+  records, err := readClientCertRecords()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to read client certificates")
+    return
+  }
+  if err := writeClientCertRecords(append(records, record)); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store client certificate")
+    return
+  }
+
+  serverCertPEM, _ := os.ReadFile(s.cfg.Server.TLSCert)
+  info := pairingInfo{URL: s.publicURL(r, ""), Name: record.Name, Serial: record.Serial, ExpiresAt: record.ExpiresAt}
+  bundle, err := buildPairingBundle(info, certPEM, keyPEM, ca.certPEM, serverCertPEM)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to build pairing bundle")
+    return
+  }
+  s.logger.Printf("client certs: issued %s (%s)", record.Serial, record.Name)
+  w.Header().Set("Content-Type", "application/gzip")
+  w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="lightningos-client-%s.tar.gz"`, record.Serial[:min(8, len(record.Serial))]))
+  w.Header().Set("X-Client-Cert-Serial", record.Serial)
+  w.WriteHeader(http.StatusOK)
+  _, _ = w.Write(bundle)
+}
+
+func (s *Server) handleClientCertsRevoke(w http.ResponseWriter, r *http.Request) {
+  serial := strings.ToLower(strings.TrimSpace(chi.URLParam(r, "serial")))
+
+  clientCertsMu.Lock()
+  defer clientCertsMu.Unlock()
+  records, err := readClientCertRecords()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to read client certificates")
+    return
+  }
+  found := false
+  for i := range records {
+    if records[i].Serial == serial {
+      found = true
+      if records[i].RevokedAt == nil {
+        now := time.Now().UTC()
+        records[i].RevokedAt = &now
+      }
+    }
+  }
+  if !found {
+    writeError(w, http.StatusNotFound, "client certificate not found")
+    return
+  }
+  if err := writeClientCertRecords(records); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store client certificate")
+    return
+  }
+  s.logger.Printf("client certs: revoked %s", serial)
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+  "archive/tar"
+  "bytes"
+  "compress/gzip"
+  "crypto/x509"
+  "encoding/pem"
+  "io"
+  "sort"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestIssueClientCert(t *testing.T) {
+  now := time.Now()
+  ca, caKeyPEM, err := newClientCA(now)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if _, err := parseClientCA(ca.certPEM, caKeyPEM); err != nil {
+    t.Fatalf("CA does not round-trip: %v", err)
+  }
+
+  record, certPEM, keyPEM, err := issueClientCert(ca, "grafana", 30, now)
+  if err != nil {
+    t.Fatal(err)
+  }
+  block, _ := pem.Decode(certPEM)
+  cert, err := x509.ParseCertificate(block.Bytes)
+  if err != nil {
+    t.Fatal(err)
+  }
+  pool := x509.NewCertPool()
+  pool.AddCert(ca.cert)
+  if _, err := cert.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+    t.Fatalf("client cert does not verify against the CA: %v", err)
+  }
+  if record.Serial != cert.SerialNumber.Text(16) || record.Name != "grafana" {
+    t.Fatalf("unexpected record: %+v", record)
+  }
+  if record.ExpiresAt.Sub(now) < 29*24*time.Hour {
+    t.Fatalf("unexpected expiry: %v", record.ExpiresAt)
+  }
+
+  info := pairingInfo{URL: "https://node.local:8443", Name: record.Name, Serial: record.Serial, ExpiresAt: record.ExpiresAt}
+  bundle, err := buildPairingBundle(info, certPEM, keyPEM, ca.certPEM, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  gz, err := gzip.NewReader(bytes.NewReader(bundle))
+  if err != nil {
+    t.Fatal(err)
+  }
+  tr := tar.NewReader(gz)
+  names := []string{}
+  for {
+    hdr, err := tr.Next()
+    if err == io.EOF {
+      break
+    }
+    if err != nil {
+      t.Fatal(err)
+    }
+    names = append(names, hdr.Name[strings.Index(hdr.Name, "/")+1:])
+  }
+  sort.Strings(names)
+  if strings.Join(names, ",") != "README.txt,client-ca.crt,client.crt,client.key,pairing.json" {
+    t.Fatalf("unexpected bundle contents: %v", names)
+  }
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+  "bufio"
+  "compress/gzip"
+  "net"
+  "net/http"
+  "strconv"
+  "strings"
+  "sync"
+)
+
+// compressMinBytes is the smallest body worth compressing; below roughly one
+// TCP segment the gzip framing costs more than it saves.
+const compressMinBytes = 1024
+
+// compressibleTypes are the media types gzip helps with. Images, archives
+// and event streams are sent as they are.
+var compressibleTypes = map[string]bool{
+  "application/json": true,
+  "application/javascript": true,
+  "text/plain": true,
+  "text/html": true,
+  "text/css": true,
+  "text/javascript": true,
+  "text/csv": true,
+  "image/svg+xml": true,
+}
+
+var gzipWriters = sync.Pool{
+  New: func() any {
+    gz, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+    return gz
+  },
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip, honoring
+// q=0 exclusions and the * wildcard. Brotli is not offered: it would need a
+// third-party encoder, and gzip already gets most of the gain on JSON.
+func acceptsGzip(header string) bool {
+  gzipQ, wildcardQ := -1.0, -1.0
+  for _, part := range strings.Split(header, ",") {
+    fields := strings.Split(part, ";")
+    coding := strings.ToLower(strings.TrimSpace(fields[0]))
+    q := 1.0
+    for _, param := range fields[1:] {
+      param = strings.TrimSpace(param)
+      if strings.HasPrefix(param, "q=") {
+        if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+          q = v
+        }
+      }
+    }
+    switch coding {
+    case "gzip", "x-gzip":
+      gzipQ = q
+    case "*":
+      wildcardQ = q
+    }
+  }
+  if gzipQ >= 0 {
+    return gzipQ > 0
+  }
+  return wildcardQ > 0
+}
+
+// compress gzips responses of compressible types once the body reaches
+// compressMinBytes. Small bodies, ranges and already encoded responses pass
+// through untouched.
+func compress(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    w.Header().Add("Vary", "Accept-Encoding")
+    if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) || r.Header.Get("Upgrade") != "" {
+      next.ServeHTTP(w, r)
+      return
+    }
+    cw := &compressWriter{ResponseWriter: w, status: http.StatusOK}
+    // Not deferred: after a panic the recoverer must still be able to send
+    // its 500 on the untouched writer.
+    next.ServeHTTP(cw, r)
+    cw.close()
+  })
+}
+
+// compressWriter buffers the start of the body until it knows whether the
+// response is big enough to compress.
+type compressWriter struct {
+  http.ResponseWriter
+  status int
+  headerSet bool
+  decided bool
+  buf []byte
+  gz *gzip.Writer
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+  if w.headerSet || w.decided {
+    return
+  }
+  w.status = status
+  w.headerSet = true
+  // Informational and bodiless responses go out as they are.
+  if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+    w.decide(false)
+  }
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+  if !w.decided {
+    if !w.eligible() {
+      w.decide(false)
+    } else {
+      w.buf = append(w.buf, p...)
+      if len(w.buf) < compressMinBytes {
+        return len(p), nil
+      }
+      buffered := w.buf
+      w.buf = nil
+      w.decide(true)
+      if _, err := w.gz.Write(buffered); err != nil {
+        return 0, err
+      }
+      return len(p), nil
+    }
+  }
+  if w.gz != nil {
+    return w.gz.Write(p)
+  }
+  return w.ResponseWriter.Write(p)
+}
+
+func (w *compressWriter) eligible() bool {
+  h := w.Header()
+  if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || w.status == http.StatusPartialContent {
+    return false
+  }
+  mediaType := h.Get("Content-Type")
+  if i := strings.Index(mediaType, ";"); i >= 0 {
+    mediaType = mediaType[:i]
+  }
+  mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+  // Without a Content-Type the server sniffs one from the body; compress only
+  // when the handler declared a compressible type.
+  return compressibleTypes[mediaType]
+}
+
+// decide commits the headers, switching to gzip when on is set, and sends any
+// buffered bytes uncompressed otherwise.
+func (w *compressWriter) decide(on bool) {
+  w.decided = true
+  if on {
+    h := w.Header()
+    h.Set("Content-Encoding", "gzip")
+    h.Del("Content-Length")
+    if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+      h.Set("ETag", "W/"+etag)
+    }
+    gz := gzipWriters.Get().(*gzip.Writer)
+    gz.Reset(w.ResponseWriter)
+    w.gz = gz
+  }
+  w.ResponseWriter.WriteHeader(w.status)
+  if len(w.buf) > 0 {
+    _, _ = w.ResponseWriter.Write(w.buf)
+    w.buf = nil
+  }
+}
+
+func (w *compressWriter) Flush() {
+  if !w.decided {
+    w.decide(false)
+  }
+  if w.gz != nil {
+    _ = w.gz.Flush()
+  }
+  if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+    flusher.Flush()
+  }
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+  if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+    return hijacker.Hijack()
+  }
+  return nil, nil, http.ErrNotSupported
+}
+
+func (w *compressWriter) close() {
+  if !w.decided {
+    if !w.headerSet && len(w.buf) == 0 {
+      return
+    }
+    w.decide(false)
+  }
+  if w.gz != nil {
+    _ = w.gz.Close()
+    gzipWriters.Put(w.gz)
+    w.gz = nil
+  }
+}
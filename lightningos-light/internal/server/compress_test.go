@@ -0,0 +1,81 @@
+package server
+
+import (
+  "compress/gzip"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+  cases := map[string]bool{
+    "": false,
+    "gzip": true,
+    "gzip, deflate, br": true,
+    "br;q=1.0, gzip;q=0.8": true,
+    "gzip;q=0": false,
+    "*": true,
+    "*;q=0.5, gzip;q=0": false,
+    "identity": false,
+    "deflate": false,
+  }
+  for header, want := range cases {
+    if got := acceptsGzip(header); got != want {
+      t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+    }
+  }
+}
+
+func TestCompress(t *testing.T) {
+  large := `{"items":"` + strings.Repeat("channel ", 500) + `"}`
+  handler := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    switch r.URL.Path {
+    case "/large":
+      writeJSON(w, http.StatusOK, map[string]string{"items": strings.Repeat("channel ", 500)})
+    case "/small":
+      writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+    case "/binary":
+      w.Header().Set("Content-Type", "application/gzip")
+      _, _ = w.Write([]byte(large))
+    }
+  }))
+  get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(http.MethodGet, path, nil)
+    if acceptEncoding != "" {
+      req.Header.Set("Accept-Encoding", acceptEncoding)
+    }
+    rec := httptest.NewRecorder()
+    handler.ServeHTTP(rec, req)
+    return rec
+  }
+
+  rec := get("/large", "gzip, br")
+  if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+    t.Fatalf("large JSON not compressed: %v", rec.Header())
+  }
+  gz, err := gzip.NewReader(rec.Body)
+  if err != nil {
+    t.Fatal(err)
+  }
+  body, err := io.ReadAll(gz)
+  if err != nil || !strings.Contains(string(body), "channel channel") {
+    t.Fatalf("bad gzip body (%v): %.40q", err, body)
+  }
+
+  for _, tc := range []struct {
+    path string
+    accept string
+  }{
+    {"/small", "gzip"},
+    {"/large", ""},
+    {"/large", "gzip;q=0"},
+    {"/binary", "gzip"},
+  } {
+    rec := get(tc.path, tc.accept)
+    if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() == 0 {
+      t.Fatalf("%s (%q): expected plain body, got %d %v", tc.path, tc.accept, rec.Code, rec.Header())
+    }
+  }
+}
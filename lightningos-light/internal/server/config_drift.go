@@ -0,0 +1,388 @@
+package server
+
+import (
+  "context"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/config"
+)
+
+const (
+  configBaselinePath = "/var/lib/lightningos/config-baseline.json"
+  configDriftPollInterval = 2 * time.Minute
+  configDriftStartDelay = time.Minute
+  configDriftMaxDiffLines = 2000
+)
+
+// configBaseline is the last content the manager wrote (or accepted) for a
+// managed file. Anything else on disk is drift, typically a manual SSH edit.
+type configBaseline struct {
+  Hash string `json:"hash"`
+  RecordedAt time.Time `json:"recorded_at"`
+  Content string `json:"content"`
+}
+
+type configDriftItem struct {
+  Path string `json:"path"`
+  Status string `json:"status"`
+  BaselineHash string `json:"baseline_hash,omitempty"`
+  CurrentHash string `json:"current_hash,omitempty"`
+  BaselineAt *time.Time `json:"baseline_at,omitempty"`
+  ModifiedAt *time.Time `json:"modified_at,omitempty"`
+}
+
+type configDiffLine struct {
+  Op string `json:"op"`
+  Text string `json:"text"`
+}
+
+var configBaselineMu sync.Mutex
+
+func managedConfigFiles() []string {
+  files := []string{lndConfPath, secretsPath}
+  if matches, err := filepath.Glob(filepath.Join(appsRoot, "*", ".env")); err == nil {
+    sort.Strings(matches)
+    files = append(files, matches...)
+  }
+  return files
+}
+
+func isManagedConfigFile(path string) bool {
+  clean := filepath.Clean(path)
+  if clean == lndConfPath || clean == secretsPath {
+    return true
+  }
+  return filepath.Base(clean) == ".env" && filepath.Dir(filepath.Dir(clean)) == appsRoot
+}
+
+func configHash(data []byte) string {
+  sum := sha256.Sum256(data)
+  return hex.EncodeToString(sum[:])
+}
+
+func readConfigBaselines() map[string]configBaseline {
+  baselines := map[string]configBaseline{}
+  raw, err := os.ReadFile(configBaselinePath)
+  if err != nil {
+    return baselines
+  }
+  _ = json.Unmarshal(raw, &baselines)
+  return baselines
+}
+
+func writeConfigBaselines(baselines map[string]configBaseline) error {
+  if err := os.MkdirAll(filepath.Dir(configBaselinePath), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.Marshal(baselines)
+  if err != nil {
+    return err
+  }
+  // The snapshots include secrets.env, so keep them private.
+  return writeFileAtomic(configBaselinePath, raw, 0o600)
+}
+
+// recordConfigBaseline is called after every managed write so the manager's
+// own edits never count as drift.
+func recordConfigBaseline(path string, data []byte) {
+  configBaselineMu.Lock()
+  defer configBaselineMu.Unlock()
+  baselines := readConfigBaselines()
+  baselines[filepath.Clean(path)] = configBaseline{
+    Hash: configHash(data),
+    RecordedAt: time.Now().UTC(),
+    Content: string(data),
+  }
+  _ = writeConfigBaselines(baselines)
+}
+
+// configDriftStatus compares managed files with their baselines. Files seen
+// for the first time are adopted as they are.
+func configDriftStatus() []configDriftItem {
+  configBaselineMu.Lock()
+  defer configBaselineMu.Unlock()
+  baselines := readConfigBaselines()
+  adopted := false
+  items := []configDriftItem{}
+  for _, path := range managedConfigFiles() {
+    item := configDriftItem{Path: path, Status: "ok"}
+    info, statErr := os.Stat(path)
+    data, readErr := os.ReadFile(path)
+    baseline, known := baselines[path]
+    switch {
+    case readErr != nil && errors.Is(readErr, os.ErrNotExist):
+      if !known {
+        continue
+      }
+      item.Status = "missing"
+    case readErr != nil:
+      item.Status = "unreadable"
+    default:
+      item.CurrentHash = configHash(data)
+      if !known {
+        baseline = configBaseline{Hash: item.CurrentHash, RecordedAt: time.Now().UTC(), Content: string(data)}
+        baselines[path] = baseline
+        adopted = true
+      } else if baseline.Hash != item.CurrentHash {
+        item.Status = "drifted"
+      }
+    }
+    if statErr == nil {
+      modified := info.ModTime().UTC()
+      item.ModifiedAt = &modified
+    }
+    if baseline.Hash != "" {
+      item.BaselineHash = baseline.Hash
+      recorded := baseline.RecordedAt
+      item.BaselineAt = &recorded
+    }
+    items = append(items, item)
+  }
+  if adopted {
+    _ = writeConfigBaselines(baselines)
+  }
+  return items
+}
+
+// runConfigDrift raises one notification per drifted file content.
+func (n *Notifier) runConfigDrift() {
+  if n.nodeKey() != config.DefaultNodeID {
+    return
+  }
+  wait := configDriftStartDelay
+  for {
+    select {
+    case <-n.stop:
+      return
+    case <-time.After(wait):
+    }
+    wait = configDriftPollInterval
+
+    ctx, cancel := context.WithTimeout(n.context(), 10*time.Second)
+    for _, item := range configDriftStatus() {
+      if item.Status != "drifted" && item.Status != "missing" {
+        continue
+      }
+      evt := Notification{
+        OccurredAt: time.Now().UTC(),
+        Type: "config",
+        Action: "drift",
+        Direction: "neutral",
+        Status: "WARNING",
+        Memo: fmt.Sprintf("%s changed outside the manager", item.Path),
+      }
+      if item.Status == "missing" {
+        evt.Memo = fmt.Sprintf("%s was removed outside the manager", item.Path)
+      }
+      key := fmt.Sprintf("config_drift:%s:%s", item.Path, shortHash(item.CurrentHash))
+      if _, err := n.upsertNotification(ctx, key, evt); err != nil {
+        n.logger.Printf("notifications: config drift notification failed: %v", err)
+      }
+    }
+    cancel()
+  }
+}
+
+func shortHash(hash string) string {
+  if len(hash) > 12 {
+    return hash[:12]
+  }
+  if hash == "" {
+    return "none"
+  }
+  return hash
+}
+
+// redactConfigLine hides values that look like credentials; the short hash
+// still shows whether a value changed.
+func redactConfigLine(path string, line string) string {
+  trimmed := strings.TrimSpace(line)
+  if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+    return line
+  }
+  parts := strings.SplitN(line, "=", 2)
+  if len(parts) != 2 {
+    return line
+  }
+  key := strings.ToLower(strings.TrimSpace(parts[0]))
+  sensitive := strings.HasSuffix(path, ".env")
+  for _, marker := range []string{"pass", "dsn", "secret", "token", "key", "macaroon"} {
+    if strings.Contains(key, marker) {
+      sensitive = true
+    }
+  }
+  if !sensitive || strings.TrimSpace(parts[1]) == "" {
+    return line
+  }
+  return fmt.Sprintf("%s=<redacted:%s>", parts[0], configHash([]byte(strings.TrimSpace(parts[1])))[:6])
+}
+
+// diffConfigLines is a plain LCS line diff; managed files are small.
+func diffConfigLines(before []string, after []string) []configDiffLine {
+  n, m := len(before), len(after)
+  lcs := make([][]int, n+1)
+  for i := range lcs {
+    lcs[i] = make([]int, m+1)
+  }
+  for i := n - 1; i >= 0; i-- {
+    for j := m - 1; j >= 0; j-- {
+      if before[i] == after[j] {
+        lcs[i][j] = lcs[i+1][j+1] + 1
+      } else if lcs[i+1][j] >= lcs[i][j+1] {
+        lcs[i][j] = lcs[i+1][j]
+      } else {
+        lcs[i][j] = lcs[i][j+1]
+      }
+    }
+  }
+  out := []configDiffLine{}
+  i, j := 0, 0
+  for i < n && j < m {
+    switch {
+    case before[i] == after[j]:
+      out = append(out, configDiffLine{Op: " ", Text: before[i]})
+      i++
+      j++
+    case lcs[i+1][j] >= lcs[i][j+1]:
+      out = append(out, configDiffLine{Op: "-", Text: before[i]})
+      i++
+    default:
+      out = append(out, configDiffLine{Op: "+", Text: after[j]})
+      j++
+    }
+  }
+  for ; i < n; i++ {
+    out = append(out, configDiffLine{Op: "-", Text: before[i]})
+  }
+  for ; j < m; j++ {
+    out = append(out, configDiffLine{Op: "+", Text: after[j]})
+  }
+  return out
+}
+
+func splitConfigLines(path string, content string) []string {
+  content = strings.TrimRight(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+  if content == "" {
+    return []string{}
+  }
+  lines := strings.Split(content, "\n")
+  for i, line := range lines {
+    lines[i] = redactConfigLine(path, line)
+  }
+  return lines
+}
+
+func managedConfigPathParam(path string) (string, bool) {
+  clean := filepath.Clean(strings.TrimSpace(path))
+  for _, managed := range managedConfigFiles() {
+    if managed == clean {
+      return clean, true
+    }
+  }
+  return "", false
+}
+
+func (s *Server) handleConfigDrift(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, map[string]any{"items": configDriftStatus()})
+}
+
+func (s *Server) handleConfigDriftDiff(w http.ResponseWriter, r *http.Request) {
+  path, ok := managedConfigPathParam(r.URL.Query().Get("path"))
+  if !ok {
+    writeError(w, http.StatusBadRequest, "path is not a managed config file")
+    return
+  }
+  configBaselineMu.Lock()
+  baseline, known := readConfigBaselines()[path]
+  configBaselineMu.Unlock()
+  if !known {
+    writeError(w, http.StatusNotFound, "no baseline recorded for this file")
+    return
+  }
+  current, err := os.ReadFile(path)
+  if err != nil && !errors.Is(err, os.ErrNotExist) {
+    writeError(w, http.StatusInternalServerError, "failed to read file")
+    return
+  }
+  before := splitConfigLines(path, baseline.Content)
+  after := splitConfigLines(path, string(current))
+  if len(before) > configDriftMaxDiffLines || len(after) > configDriftMaxDiffLines {
+    writeError(w, http.StatusBadRequest, "file too large to diff")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "path": path,
+    "baseline_at": baseline.RecordedAt,
+    "lines": diffConfigLines(before, after),
+  })
+}
+
+// handleConfigDriftAccept adopts the file as it is now.
+func (s *Server) handleConfigDriftAccept(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Path string `json:"path"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  path, ok := managedConfigPathParam(req.Path)
+  if !ok {
+    writeError(w, http.StatusBadRequest, "path is not a managed config file")
+    return
+  }
+  unlock := lockConfigFile(path)
+  data, err := os.ReadFile(path)
+  unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to read file")
+    return
+  }
+  recordConfigBaseline(path, data)
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleConfigDriftRestore re-normalizes the file to the managed baseline.
+func (s *Server) handleConfigDriftRestore(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Path string `json:"path"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  path, ok := managedConfigPathParam(req.Path)
+  if !ok {
+    writeError(w, http.StatusBadRequest, "path is not a managed config file")
+    return
+  }
+  configBaselineMu.Lock()
+  baseline, known := readConfigBaselines()[path]
+  configBaselineMu.Unlock()
+  if !known {
+    writeError(w, http.StatusNotFound, "no baseline recorded for this file")
+    return
+  }
+  unlock := lockConfigFile(path)
+  err := writeFileAtomic(path, []byte(baseline.Content), 0o660)
+  unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to restore file")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "ok": true,
+    "restart_required": path == lndConfPath,
+  })
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestDiffConfigLines(t *testing.T) {
+  before := []string{"[Application Options]", "alias=old", "color=#ff9900"}
+  after := []string{"[Application Options]", "alias=new", "color=#ff9900", "debuglevel=info"}
+  var got []string
+  for _, line := range diffConfigLines(before, after) {
+    got = append(got, line.Op+line.Text)
+  }
+  want := " [Application Options]|-alias=old|+alias=new| color=#ff9900|+debuglevel=info"
+  if strings.Join(got, "|") != want {
+    t.Fatalf("unexpected diff:\n%s", strings.Join(got, "\n"))
+  }
+}
+
+func TestRedactConfigLine(t *testing.T) {
+  if got := redactConfigLine("/data/lnd/lnd.conf", "alias=node"); got != "alias=node" {
+    t.Fatalf("alias should not be redacted: %s", got)
+  }
+  got := redactConfigLine("/data/lnd/lnd.conf", "bitcoind.rpcpass=hunter2")
+  if strings.Contains(got, "hunter2") || !strings.HasPrefix(got, "bitcoind.rpcpass=<redacted:") {
+    t.Fatalf("rpcpass not redacted: %s", got)
+  }
+  if got := redactConfigLine("/var/lib/lightningos/apps/lndg/.env", "LNDG_ADMIN=admin"); strings.Contains(got, "admin=admin") || strings.Contains(got, "=admin") {
+    t.Fatalf(".env values should be redacted: %s", got)
+  }
+  if redactConfigLine(secretsPath, "A=1") == redactConfigLine(secretsPath, "A=2") {
+    t.Fatalf("redacted values should still reveal changes")
+  }
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+  "path/filepath"
+  "sync"
+)
+
+// Config files (lnd.conf, secrets.env, app .env files) are edited by several
+// handlers with read-modify-write cycles. Every such cycle holds the file's
+// lock so concurrent API calls cannot interleave and drop each other's edits.
+// The helpers that lock must not be called while already holding the same
+// file's lock.
+var configLocks = struct {
+  mu sync.Mutex
+  files map[string]*sync.Mutex
+}{files: map[string]*sync.Mutex{}}
+
+// lockConfigFile locks path and returns the unlock function.
+func lockConfigFile(path string) func() {
+  key := filepath.Clean(path)
+  configLocks.mu.Lock()
+  lock, ok := configLocks.files[key]
+  if !ok {
+    lock = &sync.Mutex{}
+    configLocks.files[key] = lock
+  }
+  configLocks.mu.Unlock()
+  lock.Lock()
+  return lock.Unlock
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "testing"
+)
+
+func TestConcurrentEnvWritesKeepAllKeys(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "secrets.env")
+  var wg sync.WaitGroup
+  for i := 0; i < 20; i++ {
+    wg.Add(1)
+    go func(i int) {
+      defer wg.Done()
+      if err := writeEnvFileValue(path, fmt.Sprintf("KEY_%d", i), "x"); err != nil {
+        t.Errorf("write: %v", err)
+      }
+    }(i)
+  }
+  wg.Wait()
+  raw, err := os.ReadFile(path)
+  if err != nil {
+    t.Fatalf("read: %v", err)
+  }
+  for i := 0; i < 20; i++ {
+    if !strings.Contains(string(raw), fmt.Sprintf("KEY_%d=x\n", i)) {
+      t.Fatalf("KEY_%d lost:\n%s", i, raw)
+    }
+  }
+}
@@ -0,0 +1,367 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+  "unicode"
+
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/system"
+
+  "golang.org/x/crypto/bcrypt"
+)
+
+const (
+  consoleEnabledKey = "CONSOLE_ENABLED"
+  consolePinHashKey = "CONSOLE_PIN_HASH"
+  consoleMinPin = 8
+  consoleMaxFailures = 5
+  consoleGlobalMaxFailures = 20
+  consoleFailureWindow = 15 * time.Minute
+  consoleMaxOutput = 64 * 1024
+  consoleMaxPattern = 80
+  consoleDefaultLines = 1000
+  consoleMaxLines = 5000
+)
+
+// consoleArgs are the only inputs a console command accepts. They are
+// validated before anything runs; there is no free-form command line.
+type consoleArgs struct {
+  Service string
+  Pattern string
+  Lines int
+}
+
+type consoleCommand struct {
+  ID string `json:"id"`
+  Description string `json:"description"`
+  Args []string `json:"args,omitempty"`
+  run func(ctx context.Context, lnd lndclient.API, args consoleArgs) (string, error)
+}
+
+// consolePalette is the fixed set of diagnostics support can ask a user to
+// run without handing out SSH.
+var consolePalette = []consoleCommand{
+  {ID: "lnd_getinfo", Description: "LND node info (lncli getinfo)", run: consoleLNDGetInfo},
+  {ID: "lnd_peers", Description: "Connected LND peers (lncli listpeers)", run: consoleLNDPeers},
+  {ID: "service_status", Description: "systemctl status of a service", Args: []string{"service"}, run: consoleServiceStatus},
+  {ID: "journal_grep", Description: "Search a service journal", Args: []string{"service", "pattern", "lines"}, run: consoleJournalGrep},
+  {ID: "df", Description: "Disk usage (df -h)", run: consoleCommandOutput("df", "-h", "-x", "tmpfs", "-x", "devtmpfs", "-x", "squashfs")},
+  {ID: "free", Description: "Memory usage (free -m)", run: consoleCommandOutput("free", "-m")},
+  {ID: "uptime", Description: "Uptime and load (uptime)", run: consoleCommandOutput("uptime")},
+}
+
+func findConsoleCommand(id string) (consoleCommand, bool) {
+  for _, cmd := range consolePalette {
+    if cmd.ID == id {
+      return cmd, true
+    }
+  }
+  return consoleCommand{}, false
+}
+
+// validate checks the arguments cmd declares and drops the rest.
+func (cmd consoleCommand) validate(args consoleArgs) (consoleArgs, error) {
+  clean := consoleArgs{}
+  for _, name := range cmd.Args {
+    switch name {
+    case "service":
+      service := mapService(strings.TrimSpace(args.Service))
+      if service == "" {
+        return clean, errors.New("unsupported service")
+      }
+      clean.Service = service
+    case "pattern":
+      pattern := strings.TrimSpace(args.Pattern)
+      if pattern == "" || len(pattern) > consoleMaxPattern {
+        return clean, fmt.Errorf("pattern must be 1-%d characters", consoleMaxPattern)
+      }
+      for _, r := range pattern {
+        if !unicode.IsPrint(r) {
+          return clean, errors.New("pattern contains invalid characters")
+        }
+      }
+      clean.Pattern = pattern
+    case "lines":
+      clean.Lines = args.Lines
+      if clean.Lines <= 0 {
+        clean.Lines = consoleDefaultLines
+      }
+      if clean.Lines > consoleMaxLines {
+        clean.Lines = consoleMaxLines
+      }
+    }
+  }
+  return clean, nil
+}
+
+func consoleCommandOutput(name string, args ...string) func(context.Context, lndclient.API, consoleArgs) (string, error) {
+  return func(ctx context.Context, _ lndclient.API, _ consoleArgs) (string, error) {
+    return system.RunCommand(ctx, name, args...)
+  }
+}
+
+func consoleLNDGetInfo(ctx context.Context, lnd lndclient.API, _ consoleArgs) (string, error) {
+  status, err := lnd.GetStatus(ctx)
+  if err != nil {
+    return "", err
+  }
+  out, err := json.MarshalIndent(map[string]any{
+    "identity_pubkey": status.Pubkey,
+    "uri": status.URI,
+    "version": status.Version,
+    "wallet_state": status.WalletState,
+    "block_height": status.BlockHeight,
+    "synced_to_chain": status.SyncedToChain,
+    "synced_to_graph": status.SyncedToGraph,
+    "num_active_channels": status.ChannelsActive,
+    "num_inactive_channels": status.ChannelsInactive,
+  }, "", "  ")
+  return string(out), err
+}
+
+func consoleLNDPeers(ctx context.Context, lnd lndclient.API, _ consoleArgs) (string, error) {
+  peers, err := lnd.ListPeers(ctx)
+  if err != nil {
+    return "", err
+  }
+  var b strings.Builder
+  fmt.Fprintf(&b, "%d peers\n", len(peers))
+  for _, peer := range peers {
+    fmt.Fprintf(&b, "%s %s %s ping=%dus inbound=%t\n", peer.PubKey, peer.Alias, peer.Address, peer.PingTime, peer.Inbound)
+  }
+  return b.String(), nil
+}
+
+func consoleServiceStatus(ctx context.Context, _ lndclient.API, args consoleArgs) (string, error) {
+  out, err := system.RunCommand(ctx, "systemctl", "status", args.Service, "--no-pager", "--lines=0")
+  // systemctl status exits non-zero for inactive units; the output is the answer.
+  if err != nil && strings.TrimSpace(out) != "" {
+    return out, nil
+  }
+  return out, err
+}
+
+// consoleJournalGrep filters in Go with a plain substring match, so the
+// pattern never reaches journalctl's regex engine.
+func consoleJournalGrep(ctx context.Context, _ lndclient.API, args consoleArgs) (string, error) {
+  lines, err := system.JournalTail(ctx, args.Service, args.Lines)
+  if err != nil {
+    return "", err
+  }
+  return grepJournalLines(lines, args.Pattern), nil
+}
+
+// grepJournalLines redacts each line like the log download before matching,
+// so a pattern cannot probe a secret one character at a time either.
+func grepJournalLines(lines []string, pattern string) string {
+  needle := strings.ToLower(pattern)
+  var b strings.Builder
+  for _, line := range lines {
+    line = redactLogLine(line)
+    if strings.Contains(strings.ToLower(line), needle) {
+      b.WriteString(line)
+      b.WriteByte('\n')
+    }
+  }
+  return b.String()
+}
+
+// consoleGuard counts wrong PINs per client IP and across all clients, so a
+// distributed guess is throttled as well as a single noisy client.
+type consoleGuard struct {
+  mu sync.Mutex
+  byIP map[string][]time.Time
+  all []time.Time
+}
+
+var consoleFailures consoleGuard
+
+func recentTimes(times []time.Time, now time.Time) []time.Time {
+  recent := []time.Time{}
+  for _, at := range times {
+    if now.Sub(at) < consoleFailureWindow {
+      recent = append(recent, at)
+    }
+  }
+  return recent
+}
+
+func (g *consoleGuard) limited(ip string, now time.Time) bool {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  return len(recentTimes(g.byIP[ip], now)) >= consoleMaxFailures ||
+    len(recentTimes(g.all, now)) >= consoleGlobalMaxFailures
+}
+
+func (g *consoleGuard) record(ip string, ok bool, now time.Time) {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  if g.byIP == nil {
+    g.byIP = map[string][]time.Time{}
+  }
+  if ok {
+    delete(g.byIP, ip)
+    return
+  }
+  g.byIP[ip] = append(recentTimes(g.byIP[ip], now), now)
+  g.all = append(recentTimes(g.all, now), now)
+}
+
+func consoleEnabled() bool {
+  value, _ := readEnvFileValue(secretsPath, consoleEnabledKey)
+  return strings.TrimSpace(value) == "1"
+}
+
+func consolePinHash() string {
+  hash, _ := readEnvFileValue(secretsPath, consolePinHashKey)
+  return strings.TrimSpace(hash)
+}
+
+// checkConsolePin verifies pin against the stored hash, applying the
+// lockout. It returns the HTTP status and message to send on failure.
+func checkConsolePin(ip string, pin string, now time.Time) (int, string) {
+  if consoleFailures.limited(ip, now) {
+    return http.StatusTooManyRequests, "too many wrong console PINs, try again later"
+  }
+  hash := consolePinHash()
+  if hash == "" {
+    return http.StatusForbidden, "console PIN not set"
+  }
+  if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pin)) != nil {
+    consoleFailures.record(ip, false, now)
+    return http.StatusUnauthorized, "invalid console PIN"
+  }
+  consoleFailures.record(ip, true, now)
+  return 0, ""
+}
+
+func capConsoleOutput(out string) (string, bool) {
+  if len(out) <= consoleMaxOutput {
+    return out, false
+  }
+  return out[len(out)-consoleMaxOutput:], true
+}
+
+func (s *Server) handleConsoleGet(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, map[string]any{
+    "enabled": consoleEnabled(),
+    "pin_set": consolePinHash() != "",
+    "locked": consoleFailures.limited(clientIP(r), time.Now()),
+    "commands": consolePalette,
+  })
+}
+
+func (s *Server) handleConsoleConfig(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Enabled *bool `json:"enabled"`
+    Pin string `json:"pin"`
+    CurrentPin string `json:"current_pin"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  // Once a PIN exists, changing the console needs it, so the lockout cannot
+  // be sidestepped by resetting the PIN.
+  if consolePinHash() != "" {
+    if status, msg := checkConsolePin(clientIP(r), req.CurrentPin, time.Now()); status != 0 {
+      writeError(w, status, msg)
+      return
+    }
+  }
+  if err := ensureSecretsDir(); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store console settings")
+    return
+  }
+  if req.Pin != "" {
+    if len(req.Pin) < consoleMinPin {
+      writeError(w, http.StatusBadRequest, "console PIN must be at least 8 characters")
+      return
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(req.Pin), bcrypt.DefaultCost)
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to store console settings")
+      return
+    }
+    if err := writeEnvFileValue(secretsPath, consolePinHashKey, string(hash)); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to store console settings")
+      return
+    }
+  }
+  if req.Enabled != nil {
+    if *req.Enabled && consolePinHash() == "" {
+      writeError(w, http.StatusBadRequest, "console PIN not set")
+      return
+    }
+    value := "0"
+    if *req.Enabled {
+      value = "1"
+    }
+    if err := writeEnvFileValue(secretsPath, consoleEnabledKey, value); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to store console settings")
+      return
+    }
+  }
+  s.logger.Printf("console: settings changed from %s", clientIP(r))
+  writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func (s *Server) handleConsoleRun(w http.ResponseWriter, r *http.Request) {
+  if !consoleEnabled() {
+    writeError(w, http.StatusForbidden, "console disabled")
+    return
+  }
+  var req struct {
+    Command string `json:"command"`
+    Pin string `json:"pin"`
+    Service string `json:"service"`
+    Pattern string `json:"pattern"`
+    Lines int `json:"lines"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  ip := clientIP(r)
+  if status, msg := checkConsolePin(ip, req.Pin, time.Now()); status != 0 {
+    if status == http.StatusUnauthorized {
+      s.logger.Printf("console: wrong PIN from %s", ip)
+    }
+    writeError(w, status, msg)
+    return
+  }
+  cmd, ok := findConsoleCommand(strings.TrimSpace(req.Command))
+  if !ok {
+    writeError(w, http.StatusBadRequest, "unknown console command")
+    return
+  }
+  args, err := cmd.validate(consoleArgs{Service: req.Service, Pattern: req.Pattern, Lines: req.Lines})
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  s.logger.Printf("console: %s run from %s", cmd.ID, ip)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  started := time.Now()
+  out, runErr := cmd.run(ctx, s.lndFor(r), args)
+  out, truncated := capConsoleOutput(out)
+  resp := map[string]any{
+    "command": cmd.ID,
+    "output": out,
+    "truncated": truncated,
+    "duration_ms": time.Since(started).Milliseconds(),
+  }
+  if runErr != nil {
+    resp["error"] = runErr.Error()
+  }
+  writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+  "fmt"
+  "testing"
+  "time"
+)
+
+func TestConsoleCommandValidate(t *testing.T) {
+  grep, ok := findConsoleCommand("journal_grep")
+  if !ok {
+    t.Fatal("journal_grep missing from palette")
+  }
+  args, err := grep.validate(consoleArgs{Service: "lnd", Pattern: " htlc ", Lines: 99999})
+  if err != nil {
+    t.Fatalf("validate: %v", err)
+  }
+  if args.Service != "lnd" || args.Pattern != "htlc" || args.Lines != consoleMaxLines {
+    t.Fatalf("unexpected args: %+v", args)
+  }
+  if _, err := grep.validate(consoleArgs{Service: "sshd", Pattern: "x"}); err == nil {
+    t.Fatal("expected unsupported service to be rejected")
+  }
+  if _, err := grep.validate(consoleArgs{Service: "lnd", Pattern: "a\nb"}); err == nil {
+    t.Fatal("expected control characters to be rejected")
+  }
+
+  df, _ := findConsoleCommand("df")
+  args, err = df.validate(consoleArgs{Service: "lnd", Pattern: "; rm -rf /"})
+  if err != nil || args != (consoleArgs{}) {
+    t.Fatalf("undeclared args must be dropped, got %+v, %v", args, err)
+  }
+  if _, ok := findConsoleCommand("sh"); ok {
+    t.Fatal("unexpected command in palette")
+  }
+}
+
+func TestGrepJournalLinesRedacts(t *testing.T) {
+  lines := []string{
+    "loaded macaroon=0201036c6e6402f801",
+    "htlc settled",
+  }
+  if got := grepJournalLines(lines, "macaroon"); got != "loaded macaroon=<redacted>\n" {
+    t.Fatalf("unexpected output %q", got)
+  }
+  if got := grepJournalLines(lines, "0201036c"); got != "" {
+    t.Fatalf("pattern matched a redacted secret: %q", got)
+  }
+}
+
+func TestConsoleGuardLockout(t *testing.T) {
+  var g consoleGuard
+  now := time.Now()
+  for i := 0; i < consoleMaxFailures; i++ {
+    if g.limited("10.0.0.1", now) {
+      t.Fatalf("locked after %d failures", i)
+    }
+    g.record("10.0.0.1", false, now)
+  }
+  if !g.limited("10.0.0.1", now) {
+    t.Fatal("expected lockout")
+  }
+  if g.limited("10.0.0.2", now) {
+    t.Fatal("other clients must not be locked yet")
+  }
+  if g.limited("10.0.0.1", now.Add(consoleFailureWindow)) {
+    t.Fatal("lockout must expire with the window")
+  }
+
+  for i := 0; i < consoleGlobalMaxFailures; i++ {
+    g.record(fmt.Sprintf("10.1.0.%d", i), false, now)
+  }
+  if !g.limited("10.0.0.3", now) {
+    t.Fatal("expected global lockout")
+  }
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+  "regexp"
+  "strings"
+  "sync"
+
+  "lightningos-light/internal/system"
+)
+
+// The apps run on Docker or on Podman with podman-compose. Both CLIs take
+// the same arguments for what the manager runs directly (exec, run, logs,
+// inspect, image, pull); compose, networks and the engine's setup differ
+// and go through containerRuntime. apps.container_runtime picks one; auto
+// uses Docker when it is installed, else Podman when it is, else installs
+// Docker.
+const (
+  containerRuntimeAuto = "auto"
+  containerRuntimeDocker = "docker"
+  containerRuntimePodman = "podman"
+)
+
+type containerRuntime interface {
+  Name() string
+  // CLI is the command that takes docker-compatible arguments.
+  CLI() string
+  // Ensure installs and starts the engine and its compose tool if needed.
+  Ensure(ctx context.Context) error
+  // Compose returns the compose command with its leading arguments for an
+  // app, ready for a subcommand.
+  Compose(ctx context.Context, appRoot string, composePath string) (string, []string, error)
+  // ServiceRunning reports whether a compose service has a running
+  // container.
+  ServiceRunning(ctx context.Context, appRoot string, composePath string, service string) (bool, error)
+  // ContainerID returns the running container of a compose service, or "".
+  ContainerID(ctx context.Context, appRoot string, composePath string, service string) (string, error)
+  // DefaultNetwork is the network containers join without compose.
+  DefaultNetwork() string
+  NetworkGateway(ctx context.Context, network string) (string, error)
+  // NetworkInterface is the host bridge interface of a network.
+  NetworkInterface(ctx context.Context, network string) (string, error)
+}
+
+var containerRuntimeSetting struct {
+  mu sync.RWMutex
+  name string
+}
+
+func setContainerRuntime(name string) {
+  name = strings.ToLower(strings.TrimSpace(name))
+  if name == "" {
+    name = containerRuntimeAuto
+  }
+  containerRuntimeSetting.mu.Lock()
+  containerRuntimeSetting.name = name
+  containerRuntimeSetting.mu.Unlock()
+}
+
+func activeContainerRuntime() containerRuntime {
+  containerRuntimeSetting.mu.RLock()
+  name := containerRuntimeSetting.name
+  containerRuntimeSetting.mu.RUnlock()
+  return pickContainerRuntime(name, exec.LookPath)
+}
+
+// pickContainerRuntime resolves the setting. Podman's docker shim (the
+// podman-docker package) counts as Podman, since the Docker compose plugin
+// does not work through it.
+func pickContainerRuntime(name string, lookPath func(string) (string, error)) containerRuntime {
+  switch name {
+  case containerRuntimeDocker:
+    return dockerRuntime{}
+  case containerRuntimePodman:
+    return podmanRuntime{}
+  }
+  if path, err := lookPath("docker"); err == nil {
+    if isPodmanShim(path) {
+      return podmanRuntime{}
+    }
+    return dockerRuntime{}
+  }
+  if _, err := lookPath("podman"); err == nil {
+    return podmanRuntime{}
+  }
+  return dockerRuntime{}
+}
+
+func isPodmanShim(path string) bool {
+  file, err := os.Open(path)
+  if err != nil {
+    return false
+  }
+  defer file.Close()
+  head := make([]byte, 512)
+  n, _ := io.ReadFull(file, head)
+  return strings.HasPrefix(string(head[:n]), "#!") && strings.Contains(string(head[:n]), "podman")
+}
+
+// runContainerCommand runs a runtime or compose command through sudo the
+// way every app command does; a missing sudoers rule comes back as a
+// *system.SudoError naming it.
+func runContainerCommand(ctx context.Context, name string, args ...string) (string, error) {
+  return system.RunCommandWithSudo(ctx, name, args...)
+}
+
+// isSudoDenied reports whether err is sudo refusing a command, or the lack
+// of root in polkit mode, which no retry or fallback will fix.
+func isSudoDenied(err error) bool {
+  return system.IsPrivilegeDenied(err)
+}
+
+// containerCLI runs the active runtime's CLI.
+func containerCLI(ctx context.Context, args ...string) (string, error) {
+  return runContainerCommand(ctx, activeContainerRuntime().CLI(), args...)
+}
+
+func containerRuntimeInstalled() bool {
+  _, err := exec.LookPath(activeContainerRuntime().CLI())
+  return err == nil
+}
+
+func ensureContainerRuntime(ctx context.Context) error {
+  return activeContainerRuntime().Ensure(ctx)
+}
+
+func runCompose(ctx context.Context, appRoot string, composePath string, args ...string) error {
+  cmd, baseArgs, err := activeContainerRuntime().Compose(ctx, appRoot, composePath)
+  if err != nil {
+    return err
+  }
+  if _, err := runContainerCommand(ctx, cmd, append(baseArgs, args...)...); err != nil {
+    return err
+  }
+  return nil
+}
+
+func getComposeStatus(ctx context.Context, appRoot string, composePath string, service string) (string, error) {
+  running, err := activeContainerRuntime().ServiceRunning(ctx, appRoot, composePath, service)
+  if err != nil {
+    return "unknown", err
+  }
+  if running {
+    return "running", nil
+  }
+  return "stopped", nil
+}
+
+func composeContainerID(ctx context.Context, appRoot string, composePath string, service string) (string, error) {
+  return activeContainerRuntime().ContainerID(ctx, appRoot, composePath, service)
+}
+
+var composeProjectInvalid = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// composeProjectName is the project name compose derives from the app
+// directory, which also prefixes its network (lndg -> lndg_default).
+func composeProjectName(appRoot string) string {
+  name := strings.ToLower(strings.TrimRight(appRoot, "/"))
+  if idx := strings.LastIndex(name, "/"); idx >= 0 {
+    name = name[idx+1:]
+  }
+  return composeProjectInvalid.ReplaceAllString(name, "")
+}
+
+func firstLine(out string) string {
+  for _, line := range strings.Split(out, "\n") {
+    if line = strings.TrimSpace(line); line != "" {
+      return line
+    }
+  }
+  return ""
+}
+
+var errNoNetworkValue = errors.New("network value not found")
+
+// inspectNetwork reads one field of a network with a Go template.
+func inspectNetwork(ctx context.Context, cli string, network string, format string) (string, error) {
+  out, err := runContainerCommand(ctx, cli, "network", "inspect", network, "--format", format)
+  if err != nil {
+    return "", err
+  }
+  value := strings.TrimSpace(out)
+  if value == "" || value == "<no value>" {
+    return "", fmt.Errorf("%s: %w", network, errNoNetworkValue)
+  }
+  return value, nil
+}
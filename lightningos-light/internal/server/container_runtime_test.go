@@ -0,0 +1,59 @@
+package server
+
+import (
+  "errors"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestPickContainerRuntime(t *testing.T) {
+  dir := t.TempDir()
+  dockerBin := filepath.Join(dir, "docker")
+  if err := os.WriteFile(dockerBin, []byte("\x7fELF binary"), 0o755); err != nil {
+    t.Fatal(err)
+  }
+  shim := filepath.Join(dir, "docker-shim")
+  if err := os.WriteFile(shim, []byte("#!/bin/sh\nexec /usr/bin/podman \"$@\"\n"), 0o755); err != nil {
+    t.Fatal(err)
+  }
+  lookup := func(found map[string]string) func(string) (string, error) {
+    return func(name string) (string, error) {
+      if path, ok := found[name]; ok {
+        return path, nil
+      }
+      return "", errors.New("not found")
+    }
+  }
+
+  cases := []struct {
+    setting string
+    found map[string]string
+    want string
+  }{
+    {"auto", map[string]string{"docker": dockerBin, "podman": "/usr/bin/podman"}, "docker"},
+    {"auto", map[string]string{"podman": "/usr/bin/podman"}, "podman"},
+    {"auto", map[string]string{"docker": shim, "podman": "/usr/bin/podman"}, "podman"},
+    {"auto", map[string]string{}, "docker"},
+    {"podman", map[string]string{"docker": dockerBin}, "podman"},
+    {"docker", map[string]string{"podman": "/usr/bin/podman"}, "docker"},
+  }
+  for _, tc := range cases {
+    if got := pickContainerRuntime(tc.setting, lookup(tc.found)).Name(); got != tc.want {
+      t.Fatalf("%s with %v: got %s, want %s", tc.setting, tc.found, got, tc.want)
+    }
+  }
+}
+
+func TestComposeProjectName(t *testing.T) {
+  cases := map[string]string{
+    "/var/lib/lightningos/apps/lndg": "lndg",
+    "/var/lib/lightningos/apps/bitcoincore/": "bitcoincore",
+    "/opt/My App.v2": "myappv2",
+  }
+  for root, want := range cases {
+    if got := composeProjectName(root); got != want {
+      t.Fatalf("%s: got %q, want %q", root, got, want)
+    }
+  }
+}
@@ -0,0 +1,348 @@
+package server
+
+import (
+  "bytes"
+  "context"
+  "crypto/rand"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "net/http"
+  "net/url"
+  "runtime/debug"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+  crashDBRetention = 90 * 24 * time.Hour
+  crashPendingMax = 50
+  crashStackMax = 32 * 1024
+  crashRestartMin = 5 * time.Second
+  crashRestartMax = 5 * time.Minute
+)
+
+// crashRecord is a recovered panic with the goroutine's stack.
+type crashRecord struct {
+  ID int64 `json:"id"`
+  Source string `json:"source"`
+  Message string `json:"message"`
+  Stack string `json:"stack"`
+  Reported bool `json:"reported"`
+  OccurredAt time.Time `json:"occurred_at"`
+}
+
+// sentryDSN is the parsed form of https://<key>@host/<project>.
+type sentryDSN struct {
+  storeURL string
+  publicKey string
+}
+
+func parseSentryDSN(raw string) (*sentryDSN, error) {
+  raw = strings.TrimSpace(raw)
+  if raw == "" {
+    return nil, nil
+  }
+  u, err := url.Parse(raw)
+  if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User == nil || u.User.Username() == "" {
+    return nil, errors.New("invalid sentry_dsn (expected https://<key>@host/<project>)")
+  }
+  path := strings.Trim(u.Path, "/")
+  idx := strings.LastIndex(path, "/")
+  project := path[idx+1:]
+  if _, err := strconv.ParseUint(project, 10, 64); err != nil {
+    return nil, errors.New("invalid sentry_dsn (expected https://<key>@host/<project>)")
+  }
+  prefix := ""
+  if idx >= 0 {
+    prefix = "/" + path[:idx]
+  }
+  return &sentryDSN{
+    storeURL: fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+    publicKey: u.User.Username(),
+  }, nil
+}
+
+// crashRecorder keeps recovered panics in Postgres and, when a DSN is
+// configured, forwards them to a Sentry-compatible server. Crashes before the
+// database is attached are buffered in memory.
+type crashRecorder struct {
+  mu sync.Mutex
+  db *pgxpool.Pool
+  logger *log.Logger
+  sentry *sentryDSN
+  environment string
+  pending []crashRecord
+  client *http.Client
+}
+
+// crashLog is shared by the HTTP handlers, the notifiers and background jobs.
+var crashLog = &crashRecorder{logger: log.Default()}
+
+func (c *crashRecorder) configure(logger *log.Logger, dsn string, environment string) error {
+  parsed, err := parseSentryDSN(dsn)
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  if logger != nil {
+    c.logger = logger
+  }
+  c.sentry = parsed
+  c.environment = strings.TrimSpace(environment)
+  c.client = &http.Client{Timeout: 10 * time.Second}
+  return err
+}
+
+func (c *crashRecorder) attach(db *pgxpool.Pool) {
+  if db == nil {
+    return
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+  defer cancel()
+  _, err := db.Exec(ctx, `
+create table if not exists manager_crashes (
+  id bigserial primary key,
+  source text not null,
+  message text not null,
+  stack text not null,
+  reported boolean not null default false,
+  occurred_at timestamptz not null default now()
+);
+create index if not exists manager_crashes_occurred_idx on manager_crashes (occurred_at desc);
+`)
+  if err != nil {
+    c.logger.Printf("crashes: failed to init schema: %v", err)
+    return
+  }
+  if _, err := db.Exec(ctx, `delete from manager_crashes where occurred_at < $1`, time.Now().Add(-crashDBRetention)); err != nil {
+    c.logger.Printf("crashes: failed to prune: %v", err)
+  }
+
+  c.mu.Lock()
+  c.db = db
+  pending := c.pending
+  c.pending = nil
+  c.mu.Unlock()
+  for _, rec := range pending {
+    c.store(rec)
+  }
+}
+
+// record logs a recovered panic, stores it and reports it if enabled.
+func (c *crashRecorder) record(source string, recovered any, stack []byte) {
+  if len(stack) > crashStackMax {
+    stack = stack[:crashStackMax]
+  }
+  rec := crashRecord{
+    Source: source,
+    Message: fmt.Sprint(recovered),
+    Stack: string(stack),
+    OccurredAt: time.Now().UTC(),
+  }
+  c.logger.Printf("panic in %s: %s\n%s", source, rec.Message, rec.Stack)
+
+  c.mu.Lock()
+  if c.db == nil {
+    if len(c.pending) >= crashPendingMax {
+      c.pending = c.pending[1:]
+    }
+    c.pending = append(c.pending, rec)
+    c.mu.Unlock()
+    go c.report(rec)
+    return
+  }
+  c.mu.Unlock()
+  c.store(rec)
+}
+
+func (c *crashRecorder) store(rec crashRecord) {
+  c.mu.Lock()
+  db := c.db
+  c.mu.Unlock()
+  if db != nil {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    err := db.QueryRow(ctx, `
+insert into manager_crashes (source, message, stack, occurred_at) values ($1, $2, $3, $4)
+returning id`, rec.Source, rec.Message, rec.Stack, rec.OccurredAt).Scan(&rec.ID)
+    cancel()
+    if err != nil {
+      c.logger.Printf("crashes: failed to store crash: %v", err)
+    }
+  }
+  go c.report(rec)
+}
+
+// report sends rec to the Sentry store endpoint and flags the row on success.
+func (c *crashRecorder) report(rec crashRecord) {
+  c.mu.Lock()
+  dsn, environment, client, db := c.sentry, c.environment, c.client, c.db
+  c.mu.Unlock()
+  if dsn == nil || client == nil {
+    return
+  }
+  body, err := json.Marshal(sentryEvent(rec, environment))
+  if err != nil {
+    return
+  }
+  req, err := http.NewRequest(http.MethodPost, dsn.storeURL, bytes.NewReader(body))
+  if err != nil {
+    return
+  }
+  req.Header.Set("Content-Type", "application/json")
+  req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=lightningos-manager/1.0, sentry_key=%s", dsn.publicKey))
+  resp, err := client.Do(req)
+  if err != nil {
+    c.logger.Printf("crashes: report failed: %v", err)
+    return
+  }
+  resp.Body.Close()
+  if resp.StatusCode/100 != 2 {
+    c.logger.Printf("crashes: report failed: status %d", resp.StatusCode)
+    return
+  }
+  if db != nil && rec.ID > 0 {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    _, _ = db.Exec(ctx, `update manager_crashes set reported=true where id=$1`, rec.ID)
+  }
+}
+
+func sentryEvent(rec crashRecord, environment string) map[string]any {
+  id := make([]byte, 16)
+  _, _ = rand.Read(id)
+  event := map[string]any{
+    "event_id": hex.EncodeToString(id),
+    "timestamp": rec.OccurredAt.Format(time.RFC3339),
+    "platform": "go",
+    "level": "fatal",
+    "logger": "lightningos-manager",
+    "message": rec.Message,
+    "tags": map[string]string{"source": rec.Source},
+    "exception": map[string]any{
+      "values": []map[string]any{{"type": "panic", "value": rec.Message}},
+    },
+    "extra": map[string]string{"stack": rec.Stack},
+  }
+  if environment != "" {
+    event["environment"] = environment
+  }
+  return event
+}
+
+func (c *crashRecorder) list(ctx context.Context, limit int) ([]crashRecord, error) {
+  c.mu.Lock()
+  db := c.db
+  pending := append([]crashRecord(nil), c.pending...)
+  c.mu.Unlock()
+  if db == nil {
+    for i, j := 0, len(pending)-1; i < j; i, j = i+1, j-1 {
+      pending[i], pending[j] = pending[j], pending[i]
+    }
+    if len(pending) > limit {
+      pending = pending[:limit]
+    }
+    return pending, nil
+  }
+  rows, err := db.Query(ctx, `
+select id, source, message, stack, reported, occurred_at
+from manager_crashes order by occurred_at desc, id desc limit $1`, limit)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  items := []crashRecord{}
+  for rows.Next() {
+    var rec crashRecord
+    if err := rows.Scan(&rec.ID, &rec.Source, &rec.Message, &rec.Stack, &rec.Reported, &rec.OccurredAt); err != nil {
+      return nil, err
+    }
+    items = append(items, rec)
+  }
+  return items, rows.Err()
+}
+
+// capture is deferred at the top of a goroutine so a panic is recorded
+// instead of taking the whole manager down.
+func (c *crashRecorder) capture(source string) {
+  if recovered := recover(); recovered != nil {
+    c.record(source, recovered, debug.Stack())
+  }
+}
+
+// goSafe runs a long-lived loop in its own goroutine. A panic is recorded
+// and the loop is started again after a growing delay; a normal return ends
+// it.
+func goSafe(source string, fn func()) {
+  go func() {
+    delay := crashRestartMin
+    for {
+      if !runRecovered(source, fn) {
+        return
+      }
+      crashLog.logger.Printf("%s: restarting in %s after panic", source, delay)
+      time.Sleep(delay)
+      delay *= 2
+      if delay > crashRestartMax {
+        delay = crashRestartMax
+      }
+    }
+  }()
+}
+
+// runRecovered calls fn and reports whether it panicked.
+func runRecovered(source string, fn func()) (panicked bool) {
+  defer func() {
+    if recovered := recover(); recovered != nil {
+      panicked = true
+      crashLog.record(source, recovered, debug.Stack())
+    }
+  }()
+  fn()
+  return false
+}
+
+// recoverer replaces chi's Recoverer: the panic is stored in the crash log
+// with the route that triggered it and the client gets a plain 500.
+func (s *Server) recoverer() func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      defer func() {
+        recovered := recover()
+        if recovered == nil {
+          return
+        }
+        if recovered == http.ErrAbortHandler {
+          panic(recovered)
+        }
+        s.metrics.panics.Add(1)
+        crashLog.record(fmt.Sprintf("http %s %s", r.Method, r.URL.Path), recovered, debug.Stack())
+        if r.Header.Get("Connection") != "Upgrade" {
+          writeError(w, http.StatusInternalServerError, "internal error")
+        }
+      }()
+      next.ServeHTTP(w, r)
+    })
+  }
+}
+
+func (s *Server) handleCrashesList(w http.ResponseWriter, r *http.Request) {
+  limit := 50
+  if raw := r.URL.Query().Get("limit"); raw != "" {
+    if v, err := strconv.Atoi(raw); err == nil && v > 0 && v <= 500 {
+      limit = v
+    }
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  items, err := crashLog.list(ctx, limit)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load crashes")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+  "context"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+func TestParseSentryDSN(t *testing.T) {
+  dsn, err := parseSentryDSN("https://abc123@sentry.example.com/sub/42")
+  if err != nil {
+    t.Fatalf("parse: %v", err)
+  }
+  if dsn.storeURL != "https://sentry.example.com/sub/api/42/store/" || dsn.publicKey != "abc123" {
+    t.Fatalf("unexpected dsn: %+v", dsn)
+  }
+  dsn, err = parseSentryDSN("http://key@10.0.0.5:9000/7")
+  if err != nil || dsn.storeURL != "http://10.0.0.5:9000/api/7/store/" {
+    t.Fatalf("unexpected dsn: %+v, %v", dsn, err)
+  }
+  if dsn, err := parseSentryDSN(""); dsn != nil || err != nil {
+    t.Fatalf("empty dsn must disable reporting, got %+v, %v", dsn, err)
+  }
+  for _, raw := range []string{"https://sentry.example.com/1", "https://key@sentry.example.com/project", "ftp://key@host/1"} {
+    if _, err := parseSentryDSN(raw); err == nil {
+      t.Fatalf("expected %q to be rejected", raw)
+    }
+  }
+}
+
+func TestRecovererRecordsPanic(t *testing.T) {
+  crashLog.mu.Lock()
+  crashLog.pending = nil
+  crashLog.mu.Unlock()
+
+  s := &Server{metrics: newHTTPMetrics()}
+  handler := s.recoverer()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    panic("boom")
+  }))
+  rec := httptest.NewRecorder()
+  handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/test", nil))
+  if rec.Code != http.StatusInternalServerError {
+    t.Fatalf("status = %d", rec.Code)
+  }
+  if got := s.metrics.panics.Load(); got != 1 {
+    t.Fatalf("panics = %d", got)
+  }
+
+  items, err := crashLog.list(context.Background(), 10)
+  if err != nil || len(items) != 1 {
+    t.Fatalf("expected one crash, got %d (%v)", len(items), err)
+  }
+  if items[0].Source != "http GET /api/test" || items[0].Message != "boom" || items[0].Stack == "" {
+    t.Fatalf("unexpected crash: %+v", items[0])
+  }
+}
+
+func TestRunRecovered(t *testing.T) {
+  if runRecovered("test/ok", func() {}) {
+    t.Fatal("normal return reported as panic")
+  }
+  if !runRecovered("test/panic", func() { panic("loop died") }) {
+    t.Fatal("panic not reported")
+  }
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "math/big"
+  "net/http"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+// Watch-only export: the wallet's account xpubs as output descriptors, so an
+// auditor can follow the on-chain balance in Sparrow or Bitcoin Core without
+// any private key material.
+
+// descriptorLookahead is how far past the used keys a Bitcoin Core import
+// scans, matching LND's default recovery window.
+const descriptorLookahead = 2500
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// SLIP-132 key versions LND reports, and the plain xpub/tpub version
+// descriptors expect instead.
+var (
+  xpubVersion = []byte{0x04, 0x88, 0xb2, 0x1e}
+  tpubVersion = []byte{0x04, 0x35, 0x87, 0xcf}
+  slip132Versions = map[string][]byte{
+    "0488b21e": xpubVersion, // xpub
+    "049d7cb2": xpubVersion, // ypub
+    "04b24746": xpubVersion, // zpub
+    "043587cf": tpubVersion, // tpub
+    "044a5262": tpubVersion, // upub
+    "045f1cf6": tpubVersion, // vpub
+  }
+)
+
+func base58CheckDecode(value string) ([]byte, error) {
+  n := new(big.Int)
+  radix := big.NewInt(58)
+  for _, c := range value {
+    digit := strings.IndexRune(base58Alphabet, c)
+    if digit < 0 {
+      return nil, errors.New("invalid base58 character")
+    }
+    n.Mul(n, radix)
+    n.Add(n, big.NewInt(int64(digit)))
+  }
+  decoded := n.Bytes()
+  for _, c := range value {
+    if c != '1' {
+      break
+    }
+    decoded = append([]byte{0}, decoded...)
+  }
+  if len(decoded) < 5 {
+    return nil, errors.New("base58 value too short")
+  }
+  payload, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+  if !bytes.Equal(doubleSHA256(payload)[:4], checksum) {
+    return nil, errors.New("bad base58 checksum")
+  }
+  return payload, nil
+}
+
+func base58CheckEncode(payload []byte) string {
+  data := append(append([]byte{}, payload...), doubleSHA256(payload)[:4]...)
+  n := new(big.Int).SetBytes(data)
+  radix := big.NewInt(58)
+  mod := new(big.Int)
+  out := []byte{}
+  for n.Sign() > 0 {
+    n.DivMod(n, radix, mod)
+    out = append(out, base58Alphabet[mod.Int64()])
+  }
+  for _, b := range data {
+    if b != 0 {
+      break
+    }
+    out = append(out, '1')
+  }
+  for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+    out[i], out[j] = out[j], out[i]
+  }
+  return string(out)
+}
+
+func doubleSHA256(data []byte) []byte {
+  first := sha256.Sum256(data)
+  second := sha256.Sum256(first[:])
+  return second[:]
+}
+
+// standardExtendedKey rewrites a SLIP-132 ypub/zpub (or upub/vpub) as the
+// xpub (tpub) with the same key, the only form descriptors take.
+func standardExtendedKey(key string) (string, error) {
+  payload, err := base58CheckDecode(key)
+  if err != nil {
+    return "", err
+  }
+  if len(payload) != 78 {
+    return "", errors.New("not an extended public key")
+  }
+  version, ok := slip132Versions[hex.EncodeToString(payload[:4])]
+  if !ok {
+    return "", errors.New("not an extended public key")
+  }
+  out := append(append([]byte{}, version...), payload[4:]...)
+  return base58CheckEncode(out), nil
+}
+
+const (
+  descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+  descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// descriptorChecksum appends the BIP-380 checksum Bitcoin Core requires on
+// imported descriptors.
+func descriptorChecksum(desc string) (string, error) {
+  generator := [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+  chk := uint64(1)
+  step := func(value uint64) {
+    top := chk >> 35
+    chk = (chk&0x7ffffffff)<<5 ^ value
+    for i := 0; i < 5; i++ {
+      if top>>i&1 == 1 {
+        chk ^= generator[i]
+      }
+    }
+  }
+  class, count := uint64(0), 0
+  for _, c := range desc {
+    pos := strings.IndexRune(descriptorInputCharset, c)
+    if pos < 0 {
+      return "", fmt.Errorf("invalid descriptor character %q", c)
+    }
+    step(uint64(pos) & 31)
+    class = class*3 + uint64(pos)>>5
+    count++
+    if count == 3 {
+      step(class)
+      class, count = 0, 0
+    }
+  }
+  if count > 0 {
+    step(class)
+  }
+  for i := 0; i < 8; i++ {
+    step(0)
+  }
+  chk ^= 1
+  sum := make([]byte, 8)
+  for i := range sum {
+    sum[i] = descriptorChecksumCharset[chk>>(5*(7-i))&31]
+  }
+  return desc + "#" + string(sum), nil
+}
+
+type accountDescriptors struct {
+  Name string `json:"name"`
+  AddressType string `json:"address_type"`
+  DerivationPath string `json:"derivation_path"`
+  MasterKeyFingerprint string `json:"master_key_fingerprint,omitempty"`
+  ExtendedPublicKey string `json:"extended_public_key"`
+  Xpub string `json:"xpub"`
+  Receive string `json:"receive_descriptor"`
+  Change string `json:"change_descriptor"`
+  ExternalKeyCount uint32 `json:"external_key_count"`
+  InternalKeyCount uint32 `json:"internal_key_count"`
+}
+
+// coreImportRequest is one entry of Bitcoin Core's importdescriptors. The
+// entries are not active: a watch-only wallet only scans them, and active
+// descriptors of the same script type would replace each other. Timestamp 0
+// rescans the whole chain, since the wallet's birthday is unknown here.
+type coreImportRequest struct {
+  Desc string `json:"desc"`
+  Timestamp int64 `json:"timestamp"`
+  Internal bool `json:"internal"`
+  Range [2]uint32 `json:"range"`
+}
+
+// buildAccountDescriptors returns the receive and change descriptors of an
+// account. The key origin is only included when LND knows the master key
+// fingerprint; wallets watch the descriptors fine without it.
+func buildAccountDescriptors(account lndclient.WalletAccount) (accountDescriptors, error) {
+  out := accountDescriptors{
+    Name: account.Name,
+    AddressType: account.AddressType,
+    DerivationPath: account.DerivationPath,
+    MasterKeyFingerprint: account.MasterKeyFingerprint,
+    ExtendedPublicKey: account.ExtendedPublicKey,
+    ExternalKeyCount: account.ExternalKeyCount,
+    InternalKeyCount: account.InternalKeyCount,
+  }
+  xpub, err := standardExtendedKey(account.ExtendedPublicKey)
+  if err != nil {
+    return out, err
+  }
+  out.Xpub = xpub
+  key := xpub
+  if fp := account.MasterKeyFingerprint; fp != "" && fp != "00000000" && account.DerivationPath != "" {
+    path := strings.TrimPrefix(strings.TrimPrefix(account.DerivationPath, "m"), "/")
+    path = strings.ReplaceAll(path, "'", "h")
+    key = fmt.Sprintf("[%s/%s]%s", fp, path, xpub)
+  }
+
+  var receive, change string
+  switch account.AddressType {
+  case lndclient.AddressTypeP2WKH:
+    receive, change = "wpkh(%s/0/*)", "wpkh(%s/1/*)"
+  case lndclient.AddressTypeNP2WKH:
+    receive, change = "sh(wpkh(%s/0/*))", "sh(wpkh(%s/1/*))"
+  case lndclient.AddressTypeHybridNP2WKH:
+    receive, change = "sh(wpkh(%s/0/*))", "wpkh(%s/1/*)"
+  case lndclient.AddressTypeP2TR:
+    receive, change = "tr(%s/0/*)", "tr(%s/1/*)"
+  default:
+    return out, fmt.Errorf("unsupported address type %s", account.AddressType)
+  }
+  if out.Receive, err = descriptorChecksum(fmt.Sprintf(receive, key)); err != nil {
+    return out, err
+  }
+  if out.Change, err = descriptorChecksum(fmt.Sprintf(change, key)); err != nil {
+    return out, err
+  }
+  return out, nil
+}
+
+// handleWalletDescriptors exports the on-chain wallet as watch-only
+// descriptors, with a ready importdescriptors request for Bitcoin Core.
+// Accounts without an xpub (imported single keys) are listed as skipped.
+func (s *Server) handleWalletDescriptors(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  accounts, err := s.lndFor(r).ListAccounts(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
+    return
+  }
+
+  items := []accountDescriptors{}
+  skipped := []map[string]string{}
+  core := []coreImportRequest{}
+  for _, account := range accounts {
+    if account.ExtendedPublicKey == "" {
+      skipped = append(skipped, map[string]string{"name": account.Name, "address_type": account.AddressType, "reason": "no extended public key"})
+      continue
+    }
+    desc, err := buildAccountDescriptors(account)
+    if err != nil {
+      skipped = append(skipped, map[string]string{"name": account.Name, "address_type": account.AddressType, "reason": err.Error()})
+      continue
+    }
+    items = append(items, desc)
+    core = append(core,
+      coreImportRequest{Desc: desc.Receive, Range: [2]uint32{0, desc.ExternalKeyCount + descriptorLookahead}},
+      coreImportRequest{Desc: desc.Change, Internal: true, Range: [2]uint32{0, desc.InternalKeyCount + descriptorLookahead}},
+    )
+  }
+
+  if r.URL.Query().Get("download") == "1" {
+    w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="lightningos-descriptors-%s.json"`, time.Now().UTC().Format("20060102")))
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "network": s.cfg.Network,
+    "generated_at": time.Now().UTC(),
+    "accounts": items,
+    "skipped": skipped,
+    "bitcoin_core_import": core,
+  })
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+  // Example from BIP-380.
+  got, err := descriptorChecksum("raw(deadbeef)")
+  if err != nil || got != "raw(deadbeef)#89f8spxm" {
+    t.Fatalf("got %q: %v", got, err)
+  }
+  if _, err := descriptorChecksum("wpkh(é)"); err == nil {
+    t.Fatal("accepted a character outside the descriptor charset")
+  }
+}
+
+func TestStandardExtendedKey(t *testing.T) {
+  // BIP-84 account 0 of the "abandon ... about" mnemonic.
+  zpub := "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+  xpub, err := standardExtendedKey(zpub)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if xpub != "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V" {
+    t.Fatalf("xpub %s", xpub)
+  }
+  a, _ := base58CheckDecode(zpub)
+  b, _ := base58CheckDecode(xpub)
+  if !bytes.Equal(a[4:], b[4:]) {
+    t.Fatal("key changed")
+  }
+  if _, err := standardExtendedKey(zpub[:len(zpub)-1] + "t"); err == nil {
+    t.Fatal("accepted a bad checksum")
+  }
+}
+
+func TestBuildAccountDescriptors(t *testing.T) {
+  zpub := "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+  xpub, _ := standardExtendedKey(zpub)
+  desc, err := buildAccountDescriptors(lndclient.WalletAccount{
+    Name: "default",
+    AddressType: lndclient.AddressTypeHybridNP2WKH,
+    ExtendedPublicKey: zpub,
+    MasterKeyFingerprint: "73c5da0a",
+    DerivationPath: "m/49'/0'/0'",
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !strings.HasPrefix(desc.Receive, "sh(wpkh([73c5da0a/49h/0h/0h]"+xpub+"/0/*))#") {
+    t.Fatalf("receive %s", desc.Receive)
+  }
+  if !strings.HasPrefix(desc.Change, "wpkh([73c5da0a/49h/0h/0h]"+xpub+"/1/*)#") {
+    t.Fatalf("change %s", desc.Change)
+  }
+
+  // Without a fingerprint there is no key origin.
+  desc, err = buildAccountDescriptors(lndclient.WalletAccount{AddressType: lndclient.AddressTypeP2TR, ExtendedPublicKey: zpub, DerivationPath: "m/86'/0'/0'"})
+  if err != nil || !strings.HasPrefix(desc.Receive, "tr("+xpub+"/0/*)#") {
+    t.Fatalf("receive %s: %v", desc.Receive, err)
+  }
+  if _, err := buildAccountDescriptors(lndclient.WalletAccount{AddressType: lndclient.AddressTypeUnknown, ExtendedPublicKey: zpub}); err == nil {
+    t.Fatal("unknown address type accepted")
+  }
+}
@@ -5,7 +5,6 @@ import (
   "net/http"
   "os"
   "strings"
-  "time"
 
   "lightningos-light/internal/config"
 )
@@ -21,7 +20,7 @@ type elementsMainchainState struct {
 func (s *Server) handleElementsMainchainGet(w http.ResponseWriter, r *http.Request) {
   paths := elementsAppPaths()
   source := readElementsMainchainSource(paths)
-  ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
   host, port := elementsMainchainHostPort(ctx, paths, source, s.cfg)
@@ -59,7 +58,7 @@ func (s *Server) handleElementsMainchainPost(w http.ResponseWriter, r *http.Requ
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
   defer cancel()
 
   if source == "local" {
@@ -6,7 +6,6 @@ import (
   "errors"
   "net/http"
   "strings"
-  "time"
 )
 
 type elementsStatus struct {
@@ -57,7 +56,7 @@ func (s *Server) handleElementsStatus(w http.ResponseWriter, r *http.Request) {
   }
   resp.Installed = true
 
-  ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
   if raw, err := readElementsConfig(ctx, paths); err == nil {
@@ -0,0 +1,72 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+)
+
+const (
+  feeGuardOpen = "open"
+  feeGuardClose = "close"
+  feeGuardSend = "send"
+)
+
+// feeRateRejection is the body of a refused fee rate. Mempool holds the
+// current recommendation when the explorer answered, so the UI can offer a
+// sane value next to the error.
+type feeRateRejection struct {
+  Error string `json:"error"`
+  Code string `json:"code"`
+  Operation string `json:"operation"`
+  SatPerVbyte int64 `json:"sat_per_vbyte"`
+  MaxSatPerVbyte int64 `json:"max_sat_per_vbyte"`
+  Mempool *mempoolFeeRecommendation `json:"mempool,omitempty"`
+}
+
+// currentMempoolFees is best effort: a missing explorer (regtest) or a
+// timeout only leaves the context out of the error.
+func (s *Server) currentMempoolFees(ctx context.Context) *mempoolFeeRecommendation {
+  ctx, cancel := s.operationContext(ctx, timeoutProbe)
+  defer cancel()
+  fees, err := s.mempool.fees(ctx)
+  if err != nil {
+    return nil
+  }
+  return &fees
+}
+
+// guardFeeRate refuses sat_per_vbyte above the configured cap for operation
+// unless override is set, writing the error itself. It reports whether the
+// request may go on. Zero (let LND estimate) always passes.
+func (s *Server) guardFeeRate(w http.ResponseWriter, r *http.Request, operation string, satPerVbyte int64, override bool) bool {
+  if satPerVbyte < 0 {
+    writeError(w, http.StatusBadRequest, "sat_per_vbyte must be zero or positive")
+    return false
+  }
+  limit := s.cfg.FeeGuard.Max(operation)
+  if limit == 0 || satPerVbyte <= limit {
+    return true
+  }
+  if override {
+    s.logger.Printf("fee guard: %s at %d sat/vB above cap %d allowed by override", operation, satPerVbyte, limit)
+    return true
+  }
+
+  mempool := s.currentMempoolFees(r.Context())
+  hint := ""
+  if mempool != nil {
+    hint = fmt.Sprintf(" (mempool now: fastest %d, 30 min %d, 1 h %d sat/vB)", mempool.FastestFee, mempool.HalfHourFee, mempool.HourFee)
+  }
+  msg := fmt.Sprintf("sat_per_vbyte above cap: %d sat/vB for %s exceeds %d sat/vB%s; send allow_high_fee to override",
+    satPerVbyte, operation, limit, hint)
+  writeJSON(w, http.StatusBadRequest, feeRateRejection{
+    Error: localize(w, msg),
+    Code: "fee_rate_above_cap",
+    Operation: operation,
+    SatPerVbyte: satPerVbyte,
+    MaxSatPerVbyte: limit,
+    Mempool: mempool,
+  })
+  return false
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+  "encoding/json"
+  "io"
+  "log"
+  "net/http"
+  "testing"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndfake"
+)
+
+func TestGuardFeeRateOnSend(t *testing.T) {
+  // regtest has no mempool explorer, so the rejection carries no fee context
+  // and the test stays offline.
+  fake := lndfake.New()
+  cfg := &config.Config{Network: "regtest", FeeGuard: config.FeeGuardConfig{MaxSendSatPerVbyte: 200}}
+  s := NewWithLND(cfg, log.New(io.Discard, "", 0), fake)
+  t.Cleanup(func() { setActiveNetwork("mainnet") })
+
+  rec := serveAPI(s, http.MethodPost, "/api/wallet/send", `{"address":"bcrt1qexample","amount_sat":1000,"sat_per_vbyte":5000}`)
+  if rec.Code != http.StatusBadRequest {
+    t.Fatalf("absurd fee accepted: %d %s", rec.Code, rec.Body.String())
+  }
+  var body feeRateRejection
+  if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+    t.Fatalf("decode: %v", err)
+  }
+  if body.Code != "fee_rate_above_cap" || body.MaxSatPerVbyte != 200 || body.Operation != feeGuardSend || body.Mempool != nil {
+    t.Fatalf("unexpected rejection: %+v", body)
+  }
+  if len(fake.Calls("SendCoins")) != 0 {
+    t.Fatal("send went out despite the cap")
+  }
+
+  rec = serveAPI(s, http.MethodPost, "/api/wallet/send", `{"address":"bcrt1qexample","amount_sat":1000,"sat_per_vbyte":5000,"allow_high_fee":true}`)
+  if rec.Code != http.StatusOK {
+    t.Fatalf("override refused: %d %s", rec.Code, rec.Body.String())
+  }
+  if rec := serveAPI(s, http.MethodPost, "/api/wallet/send", `{"address":"bcrt1qexample","amount_sat":1000,"sat_per_vbyte":150}`); rec.Code != http.StatusOK {
+    t.Fatalf("fee under the cap refused: %d %s", rec.Code, rec.Body.String())
+  }
+}
+
+func TestFeeGuardConfigMax(t *testing.T) {
+  cfg := config.FeeGuardConfig{MaxOpenSatPerVbyte: 300, MaxCloseSatPerVbyte: -1}
+  if got := cfg.Max("open"); got != 300 {
+    t.Fatalf("open cap %d", got)
+  }
+  if got := cfg.Max("close"); got != 0 {
+    t.Fatalf("disabled close cap %d", got)
+  }
+  if got := cfg.Max("send"); got != config.DefaultMaxSatPerVbyte {
+    t.Fatalf("default send cap %d", got)
+  }
+}
@@ -0,0 +1,679 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net"
+  "net/http"
+  "os/exec"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+
+  "lightningos-light/internal/system"
+)
+
+// The firewall module shows and changes the rules for the ports the node
+// uses. ufw is managed; a host with only nftables is shown read-only. Each
+// port has a scope saying where it should be reachable from: Lightning's
+// P2P port publicly, the manager and the apps from the LAN, LND's APIs and
+// Tor only from the machine. A listening port reachable further than its
+// scope, or an unknown one reachable from anywhere, is reported as
+// unexpectedly exposed.
+const (
+  firewallBackendUFW = "ufw"
+  firewallBackendNFT = "nftables"
+  firewallBackendNone = "none"
+
+  firewallScopePublic = "public"
+  firewallScopeLAN = "lan"
+  firewallScopeLocal = "local"
+  // firewallScopeBlocked is an exposure only: listening, but the firewall
+  // lets nothing in.
+  firewallScopeBlocked = "blocked"
+
+  firewallProfileLANOnly = "lan_only"
+)
+
+// firewallLANSources are the private ranges "lan" stands for.
+var firewallLANSources = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+
+var errFirewallReadOnly = errors.New("firewall changes need ufw")
+
+type firewallPort struct {
+  Port int `json:"port"`
+  Proto string `json:"proto"`
+  Service string `json:"service"`
+  Scope string `json:"scope"`
+}
+
+type firewallRule struct {
+  Number int `json:"number,omitempty"`
+  To string `json:"to"`
+  Port int `json:"port,omitempty"`
+  Proto string `json:"proto,omitempty"`
+  Action string `json:"action"`
+  From string `json:"from"`
+  V6 bool `json:"v6,omitempty"`
+}
+
+type firewallExposure struct {
+  firewallPort
+  Listening bool `json:"listening"`
+  Bind []string `json:"bind,omitempty"`
+  Exposure string `json:"exposure,omitempty"`
+  Unexpected bool `json:"unexpected"`
+}
+
+type firewallStatus struct {
+  Backend string `json:"backend"`
+  Active bool `json:"active"`
+  DefaultIncoming string `json:"default_incoming,omitempty"`
+  Managed bool `json:"managed"`
+  Rules []firewallRule `json:"rules"`
+  Ports []firewallExposure `json:"ports"`
+  Exposed []firewallExposure `json:"exposed"`
+}
+
+// firewallPorts lists the ports the node uses and where each should be
+// reachable from.
+func (s *Server) firewallPorts() []firewallPort {
+  ports := []firewallPort{
+    {Port: 9735, Proto: "tcp", Service: "Lightning P2P", Scope: firewallScopePublic},
+    {Port: s.cfg.Server.Port, Proto: "tcp", Service: "LightningOS manager", Scope: firewallScopeLAN},
+    {Port: 22, Proto: "tcp", Service: "SSH", Scope: firewallScopeLAN},
+    {Port: 10009, Proto: "tcp", Service: "LND gRPC", Scope: firewallScopeLocal},
+    {Port: 8080, Proto: "tcp", Service: "LND REST", Scope: firewallScopeLocal},
+    {Port: 9050, Proto: "tcp", Service: "Tor SOCKS", Scope: firewallScopeLocal},
+    {Port: 9051, Proto: "tcp", Service: "Tor control", Scope: firewallScopeLocal},
+  }
+  if apps, err := s.appRegistry(); err == nil {
+    for _, app := range apps {
+      if def := app.Definition(); def.Port > 0 {
+        ports = append(ports, firewallPort{Port: def.Port, Proto: "tcp", Service: def.Name, Scope: firewallScopeLAN})
+      }
+    }
+  }
+  return ports
+}
+
+func firewallBackend() string {
+  if _, err := exec.LookPath("ufw"); err == nil {
+    return firewallBackendUFW
+  }
+  if _, err := exec.LookPath("nft"); err == nil {
+    return firewallBackendNFT
+  }
+  return firewallBackendNone
+}
+
+var ufwNumberedRule = regexp.MustCompile(`^\[\s*(\d+)\]\s+(.*)$`)
+var columnSplit = regexp.MustCompile(`\s{2,}`)
+
+// parseUFWStatus reads `ufw status verbose` for the state and default
+// policy, and `ufw status numbered` for the rules.
+func parseUFWStatus(verbose string, numbered string) (bool, string, []firewallRule) {
+  active := false
+  defaultIncoming := ""
+  for _, line := range strings.Split(verbose, "\n") {
+    line = strings.TrimSpace(line)
+    switch {
+    case strings.HasPrefix(line, "Status:"):
+      active = strings.TrimSpace(strings.TrimPrefix(line, "Status:")) == "active"
+    case strings.HasPrefix(line, "Default:"):
+      if policy, _, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "Default:")), " (incoming)"); ok {
+        defaultIncoming = policy
+      }
+    }
+  }
+  rules := []firewallRule{}
+  for _, line := range strings.Split(numbered, "\n") {
+    match := ufwNumberedRule.FindStringSubmatch(strings.TrimSpace(line))
+    if match == nil {
+      continue
+    }
+    cols := columnSplit.Split(strings.TrimSpace(match[2]), -1)
+    if len(cols) < 3 {
+      continue
+    }
+    number, _ := strconv.Atoi(match[1])
+    rule := firewallRule{Number: number, To: cols[0], Action: cols[1], From: strings.Join(cols[2:], " ")}
+    if strings.HasSuffix(rule.To, "(v6)") {
+      rule.V6 = true
+      rule.To = strings.TrimSpace(strings.TrimSuffix(rule.To, "(v6)"))
+      rule.From = strings.TrimSpace(strings.TrimSuffix(rule.From, "(v6)"))
+    }
+    rule.Port, rule.Proto = parseRulePort(rule.To)
+    rules = append(rules, rule)
+  }
+  return active, defaultIncoming, rules
+}
+
+// parseRulePort takes the port of a rule's To column: "9735/tcp", "8443",
+// "10009/tcp on docker0". Ranges, lists and app profiles give 0.
+func parseRulePort(to string) (int, string) {
+  field := strings.Fields(to)
+  if len(field) == 0 {
+    return 0, ""
+  }
+  portPart, proto, _ := strings.Cut(field[0], "/")
+  port, err := strconv.Atoi(portPart)
+  if err != nil || port <= 0 || port > 65535 {
+    return 0, ""
+  }
+  return port, proto
+}
+
+var nftPortList = regexp.MustCompile(`dport\s+(\{[^}]*\}|\d+)`)
+var nftSaddr = regexp.MustCompile(`saddr\s+(\S+)`)
+
+// parseNFTRuleset reads the input hook's policy and its accept rules on
+// destination ports from `nft list ruleset`.
+func parseNFTRuleset(out string) (bool, string, []firewallRule) {
+  active := false
+  defaultIncoming := ""
+  rules := []firewallRule{}
+  inInput := false
+  for _, line := range strings.Split(out, "\n") {
+    line = strings.TrimSpace(line)
+    if strings.HasPrefix(line, "chain ") {
+      inInput = false
+      continue
+    }
+    if strings.Contains(line, "hook input") {
+      inInput = true
+      active = true
+      if idx := strings.Index(line, "policy "); idx >= 0 {
+        defaultIncoming = strings.TrimSuffix(strings.Fields(line[idx+len("policy "):])[0], ";")
+      }
+      continue
+    }
+    if !inInput || !strings.HasSuffix(line, "accept") {
+      continue
+    }
+    match := nftPortList.FindStringSubmatch(line)
+    if match == nil {
+      continue
+    }
+    proto := "tcp"
+    if strings.HasPrefix(line, "udp ") || strings.Contains(line, " udp dport") {
+      proto = "udp"
+    }
+    from := "Anywhere"
+    if saddr := nftSaddr.FindStringSubmatch(line); saddr != nil {
+      from = saddr[1]
+    }
+    for _, raw := range strings.FieldsFunc(strings.Trim(match[1], "{}"), func(r rune) bool { return r == ',' || r == ' ' }) {
+      if port, err := strconv.Atoi(raw); err == nil {
+        rules = append(rules, firewallRule{To: fmt.Sprintf("%d/%s", port, proto), Port: port, Proto: proto, Action: "ACCEPT", From: from})
+      }
+    }
+  }
+  return active, defaultIncoming, rules
+}
+
+// parseListeners reads `ss -H -ltn` into port -> bound addresses.
+func parseListeners(out string) map[int][]string {
+  listeners := map[int][]string{}
+  for _, line := range strings.Split(out, "\n") {
+    fields := strings.Fields(line)
+    if len(fields) < 4 {
+      continue
+    }
+    local := fields[3]
+    idx := strings.LastIndex(local, ":")
+    if idx < 0 {
+      continue
+    }
+    port, err := strconv.Atoi(local[idx+1:])
+    if err != nil {
+      continue
+    }
+    host := strings.Trim(local[:idx], "[]")
+    if iface := strings.Index(host, "%"); iface >= 0 {
+      host = host[:iface]
+    }
+    listeners[port] = append(listeners[port], host)
+  }
+  return listeners
+}
+
+func isLoopbackBind(host string) bool {
+  if host == "localhost" {
+    return true
+  }
+  ip := net.ParseIP(host)
+  return ip != nil && ip.IsLoopback()
+}
+
+// isLANSource reports whether a rule's From column only covers private
+// addresses.
+func isLANSource(from string) bool {
+  from = strings.TrimSpace(from)
+  if ip, network, err := net.ParseCIDR(from); err == nil {
+    ones, _ := network.Mask.Size()
+    return ip.IsPrivate() && ((ip.To4() != nil && ones >= 8) || (ip.To4() == nil && ones >= 7))
+  }
+  if ip := net.ParseIP(from); ip != nil {
+    return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()
+  }
+  return false
+}
+
+func isAllowRule(rule firewallRule) bool {
+  action := strings.ToUpper(rule.Action)
+  return strings.HasPrefix(action, "ALLOW") || strings.HasPrefix(action, "LIMIT") || action == "ACCEPT"
+}
+
+// portExposure works out how far a listening port reaches.
+func portExposure(binds []string, proto string, port int, active bool, defaultIncoming string, rules []firewallRule) string {
+  local := true
+  for _, host := range binds {
+    if !isLoopbackBind(host) {
+      local = false
+    }
+  }
+  if local {
+    return firewallScopeLocal
+  }
+  if !active || defaultIncoming == "allow" || defaultIncoming == "accept" {
+    return firewallScopePublic
+  }
+  exposure := firewallScopeBlocked
+  for _, rule := range rules {
+    if rule.Port != port || !isAllowRule(rule) || (rule.Proto != "" && rule.Proto != proto) {
+      continue
+    }
+    if strings.Contains(rule.To, " on ") || isLANSource(rule.From) {
+      exposure = firewallScopeLAN
+      continue
+    }
+    return firewallScopePublic
+  }
+  return exposure
+}
+
+func scopeRank(scope string) int {
+  switch scope {
+  case firewallScopePublic:
+    return 3
+  case firewallScopeLAN:
+    return 2
+  case firewallScopeLocal:
+    return 1
+  default:
+    return 0
+  }
+}
+
+// buildFirewallExposure matches the listening ports against the known ones
+// and the rules. Unknown listening ports are only listed when they are
+// reachable from anywhere.
+func buildFirewallExposure(ports []firewallPort, listeners map[int][]string, active bool, defaultIncoming string, rules []firewallRule) []firewallExposure {
+  known := map[int]bool{}
+  items := []firewallExposure{}
+  for _, port := range ports {
+    item := firewallExposure{firewallPort: port}
+    if binds, ok := listeners[port.Port]; ok {
+      item.Listening = true
+      item.Bind = binds
+      item.Exposure = portExposure(binds, port.Proto, port.Port, active, defaultIncoming, rules)
+      item.Unexpected = scopeRank(item.Exposure) > scopeRank(port.Scope)
+    }
+    known[port.Port] = true
+    items = append(items, item)
+  }
+  unknown := make([]int, 0, len(listeners))
+  for port := range listeners {
+    if !known[port] {
+      unknown = append(unknown, port)
+    }
+  }
+  sort.Ints(unknown)
+  for _, port := range unknown {
+    exposure := portExposure(listeners[port], "tcp", port, active, defaultIncoming, rules)
+    if exposure != firewallScopePublic {
+      continue
+    }
+    items = append(items, firewallExposure{
+      firewallPort: firewallPort{Port: port, Proto: "tcp", Service: "unknown", Scope: firewallScopeLocal},
+      Listening: true,
+      Bind: listeners[port],
+      Exposure: exposure,
+      Unexpected: true,
+    })
+  }
+  return items
+}
+
+func (s *Server) firewallStatus(ctx context.Context) (firewallStatus, error) {
+  status := firewallStatus{Backend: firewallBackend(), Rules: []firewallRule{}}
+  switch status.Backend {
+  case firewallBackendUFW:
+    verbose, err := system.RunCommandWithSudo(ctx, "ufw", "status", "verbose")
+    if err != nil {
+      return status, err
+    }
+    numbered, err := system.RunCommandWithSudo(ctx, "ufw", "status", "numbered")
+    if err != nil {
+      return status, err
+    }
+    status.Active, status.DefaultIncoming, status.Rules = parseUFWStatus(verbose, numbered)
+    status.Managed = true
+  case firewallBackendNFT:
+    out, err := system.RunCommandWithSudo(ctx, "nft", "list", "ruleset")
+    if err != nil {
+      return status, err
+    }
+    status.Active, status.DefaultIncoming, status.Rules = parseNFTRuleset(out)
+  }
+
+  listenOut, err := system.RunCommand(ctx, "ss", "-H", "-ltn")
+  if err != nil {
+    return status, fmt.Errorf("failed to list listening ports: %w", err)
+  }
+  status.Ports = buildFirewallExposure(s.firewallPorts(), parseListeners(listenOut), status.Active, status.DefaultIncoming, status.Rules)
+  status.Exposed = []firewallExposure{}
+  for _, item := range status.Ports {
+    if item.Unexpected {
+      status.Exposed = append(status.Exposed, item)
+    }
+  }
+  return status, nil
+}
+
+func (s *Server) handleFirewallGet(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  status, err := s.firewallStatus(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "firewall status failed: "+err.Error())
+    return
+  }
+  writeJSON(w, http.StatusOK, status)
+}
+
+type firewallRuleRequest struct {
+  Action string `json:"action"`
+  Port int `json:"port"`
+  Proto string `json:"proto"`
+  // From is any, lan, or an address or CIDR.
+  From string `json:"from"`
+}
+
+// firewallSources expands a From value into ufw sources.
+func firewallSources(from string) ([]string, error) {
+  switch from = strings.ToLower(strings.TrimSpace(from)); from {
+  case "", "any", "anywhere":
+    return []string{"any"}, nil
+  case "lan":
+    return firewallLANSources, nil
+  }
+  if _, network, err := net.ParseCIDR(from); err == nil {
+    return []string{network.String()}, nil
+  }
+  if ip := net.ParseIP(from); ip != nil {
+    return []string{ip.String()}, nil
+  }
+  return nil, errors.New("invalid from")
+}
+
+// ufwAdd adds an allow or deny rule for port from source. ufw applies the
+// first matching rule, so a deny goes ahead of all others like
+// firewallBlock's; appended, an earlier allow would shadow it.
+func ufwAdd(ctx context.Context, action string, source string, port int, proto string) error {
+  args := []string{action, "from", source, "to", "any", "port", strconv.Itoa(port), "proto", proto}
+  if action == "deny" {
+    args = append([]string{"prepend"}, args...)
+  }
+  out, err := system.RunCommandWithSudo(ctx, "ufw", args...)
+  if err != nil {
+    if isSudoDenied(err) {
+      return err
+    }
+    return fmt.Errorf("ufw %s failed: %s", action, strings.TrimSpace(out))
+  }
+  return nil
+}
+
+// firewallBlock denies everything from ip ahead of all other rules, so an
+// earlier allow cannot let it back in.
+func firewallBlock(ctx context.Context, ip string) error {
+  if firewallBackend() != firewallBackendUFW {
+    return errFirewallReadOnly
+  }
+  out, err := system.RunCommandWithSudo(ctx, "ufw", "prepend", "deny", "from", ip)
+  if err != nil {
+    if isSudoDenied(err) {
+      return err
+    }
+    return fmt.Errorf("ufw deny failed: %s", strings.TrimSpace(out))
+  }
+  return nil
+}
+
+func firewallUnblock(ctx context.Context, ip string) error {
+  if firewallBackend() != firewallBackendUFW {
+    return errFirewallReadOnly
+  }
+  out, err := system.RunCommandWithSudo(ctx, "ufw", "--force", "delete", "deny", "from", ip)
+  if err != nil {
+    if isSudoDenied(err) {
+      return err
+    }
+    return fmt.Errorf("ufw delete failed: %s", strings.TrimSpace(out))
+  }
+  return nil
+}
+
+// ufwDelete removes rules by number, highest first so the numbers of the
+// rest do not shift.
+func ufwDelete(ctx context.Context, rules []firewallRule) error {
+  sort.Slice(rules, func(i, j int) bool { return rules[i].Number > rules[j].Number })
+  for _, rule := range rules {
+    out, err := system.RunCommandWithSudo(ctx, "ufw", "--force", "delete", strconv.Itoa(rule.Number))
+    if err != nil {
+      if isSudoDenied(err) {
+        return err
+      }
+      return fmt.Errorf("ufw delete failed: %s", strings.TrimSpace(out))
+    }
+  }
+  return nil
+}
+
+// matchingRules picks the numbered rules for a port, from any of sources
+// (all of them when sources is nil). Interface rules, like the one letting
+// LNDg reach LND, are never picked.
+func matchingRules(rules []firewallRule, port int, proto string, sources map[string]bool) []firewallRule {
+  picked := []firewallRule{}
+  for _, rule := range rules {
+    if rule.Number == 0 || rule.Port != port || strings.Contains(rule.To, " on ") {
+      continue
+    }
+    if rule.Proto != "" && rule.Proto != proto {
+      continue
+    }
+    from := strings.ToLower(rule.From)
+    if from == "anywhere" {
+      from = "any"
+    }
+    if sources != nil && !sources[from] {
+      continue
+    }
+    picked = append(picked, rule)
+  }
+  return picked
+}
+
+func (s *Server) handleFirewallRule(w http.ResponseWriter, r *http.Request) {
+  var req firewallRuleRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  req.Action = strings.ToLower(strings.TrimSpace(req.Action))
+  if req.Action != "allow" && req.Action != "deny" && req.Action != "delete" {
+    writeError(w, http.StatusBadRequest, "action must be allow, deny or delete")
+    return
+  }
+  req.Proto = strings.ToLower(strings.TrimSpace(req.Proto))
+  if req.Proto == "" {
+    req.Proto = "tcp"
+  }
+  if req.Proto != "tcp" && req.Proto != "udp" {
+    writeError(w, http.StatusBadRequest, "proto must be tcp or udp")
+    return
+  }
+  managed := false
+  for _, port := range s.firewallPorts() {
+    managed = managed || port.Port == req.Port
+  }
+  if !managed {
+    writeError(w, http.StatusBadRequest, "port is not one of the node's ports")
+    return
+  }
+  sources, err := firewallSources(req.From)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  if firewallBackend() != firewallBackendUFW {
+    writeError(w, http.StatusConflict, errFirewallReadOnly.Error())
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  switch req.Action {
+  case "delete":
+    var status firewallStatus
+    if status, err = s.firewallStatus(ctx); err != nil {
+      writeError(w, http.StatusInternalServerError, "firewall status failed: "+err.Error())
+      return
+    }
+    var only map[string]bool
+    if strings.TrimSpace(req.From) != "" {
+      only = map[string]bool{}
+      for _, source := range sources {
+        only[source] = true
+      }
+    }
+    err = ufwDelete(ctx, matchingRules(status.Rules, req.Port, req.Proto, only))
+  default:
+    for _, source := range sources {
+      if err = ufwAdd(ctx, req.Action, source, req.Port, req.Proto); err != nil {
+        break
+      }
+    }
+  }
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+  s.handleFirewallGet(w, r)
+}
+
+type firewallProfileRequest struct {
+  Profile string `json:"profile"`
+  // Enable turns ufw on when it is off, after allowing SSH from the LAN.
+  Enable bool `json:"enable"`
+}
+
+// applyLANOnly limits the manager, SSH and the apps to the LAN and removes
+// every rule opening LND's APIs or Tor. Lightning's P2P port is left as it
+// is: whether peers can reach it is a separate choice.
+func (s *Server) applyLANOnly(ctx context.Context, enable bool) error {
+  status, err := s.firewallStatus(ctx)
+  if err != nil {
+    return err
+  }
+  lan := map[string]bool{}
+  for _, source := range firewallLANSources {
+    lan[source] = true
+  }
+  var remove []firewallRule
+  type addition struct {
+    port int
+    source string
+  }
+  var add []addition
+  for _, port := range s.firewallPorts() {
+    switch port.Scope {
+    case firewallScopeLAN:
+      remove = append(remove, matchingRules(status.Rules, port.Port, port.Proto, map[string]bool{"any": true})...)
+      existing := map[string]bool{}
+      for _, rule := range matchingRules(status.Rules, port.Port, port.Proto, lan) {
+        existing[strings.ToLower(rule.From)] = true
+      }
+      for _, source := range firewallLANSources {
+        if !existing[source] {
+          add = append(add, addition{port: port.Port, source: source})
+        }
+      }
+    case firewallScopeLocal:
+      remove = append(remove, matchingRules(status.Rules, port.Port, port.Proto, nil)...)
+    }
+  }
+  // Add before removing, so SSH and the manager stay reachable throughout.
+  for _, item := range add {
+    if err := ufwAdd(ctx, "allow", item.source, item.port, "tcp"); err != nil {
+      return err
+    }
+  }
+  if len(remove) > 0 {
+    // Adding rules renumbers them; read them again.
+    status, err = s.firewallStatus(ctx)
+    if err != nil {
+      return err
+    }
+    wanted := map[string]bool{}
+    for _, rule := range remove {
+      wanted[fmt.Sprintf("%s|%s|%s|%t", rule.To, rule.Action, rule.From, rule.V6)] = true
+    }
+    var current []firewallRule
+    for _, rule := range status.Rules {
+      if wanted[fmt.Sprintf("%s|%s|%s|%t", rule.To, rule.Action, rule.From, rule.V6)] {
+        current = append(current, rule)
+      }
+    }
+    if err := ufwDelete(ctx, current); err != nil {
+      return err
+    }
+  }
+  if enable && !status.Active {
+    out, err := system.RunCommandWithSudo(ctx, "ufw", "--force", "enable")
+    if err != nil {
+      if isSudoDenied(err) {
+        return err
+      }
+      return fmt.Errorf("ufw enable failed: %s", strings.TrimSpace(out))
+    }
+  }
+  return nil
+}
+
+func (s *Server) handleFirewallProfile(w http.ResponseWriter, r *http.Request) {
+  var req firewallProfileRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if strings.TrimSpace(req.Profile) != firewallProfileLANOnly {
+    writeError(w, http.StatusBadRequest, "unknown firewall profile")
+    return
+  }
+  if firewallBackend() != firewallBackendUFW {
+    writeError(w, http.StatusConflict, errFirewallReadOnly.Error())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  if err := s.applyLANOnly(ctx, req.Enable); err != nil {
+    writeError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+  s.handleFirewallGet(w, r)
+}
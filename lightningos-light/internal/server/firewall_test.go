@@ -0,0 +1,149 @@
+package server
+
+import (
+  "testing"
+)
+
+const ufwVerbose = `Status: active
+Logging: on (low)
+Default: deny (incoming), allow (outgoing), disabled (routed)
+New profiles: skip
+`
+
+const ufwNumbered = `Status: active
+
+     To                         Action      From
+     --                         ------      ----
+[ 1] 22/tcp                     ALLOW IN    Anywhere
+[ 2] 9735/tcp                   ALLOW IN    Anywhere
+[ 3] 8443/tcp                   ALLOW IN    192.168.0.0/16
+[ 4] 10009/tcp on br-1a2b3c     ALLOW IN    Anywhere
+[ 5] 8080                       ALLOW IN    Anywhere
+[ 6] 22/tcp (v6)                ALLOW IN    Anywhere (v6)
+`
+
+func TestParseUFWStatus(t *testing.T) {
+  active, policy, rules := parseUFWStatus(ufwVerbose, ufwNumbered)
+  if !active || policy != "deny" {
+    t.Fatalf("active=%v policy=%q", active, policy)
+  }
+  if len(rules) != 6 {
+    t.Fatalf("got %d rules: %+v", len(rules), rules)
+  }
+  if r := rules[2]; r.Number != 3 || r.Port != 8443 || r.Proto != "tcp" || r.From != "192.168.0.0/16" {
+    t.Fatalf("rule 3 = %+v", r)
+  }
+  if r := rules[3]; r.Port != 10009 || r.To != "10009/tcp on br-1a2b3c" {
+    t.Fatalf("rule 4 = %+v", r)
+  }
+  if r := rules[4]; r.Port != 8080 || r.Proto != "" {
+    t.Fatalf("rule 5 = %+v", r)
+  }
+  if r := rules[5]; !r.V6 || r.To != "22/tcp" || r.From != "Anywhere" {
+    t.Fatalf("rule 6 = %+v", r)
+  }
+}
+
+func TestParseNFTRuleset(t *testing.T) {
+  out := `table inet filter {
+	chain input {
+		type filter hook input priority filter; policy drop;
+		ct state established,related accept
+		iif "lo" accept
+		tcp dport { 22, 9735 } accept
+		ip saddr 192.168.0.0/16 tcp dport 8443 counter packets 0 bytes 0 accept
+	}
+	chain forward {
+		type filter hook forward priority filter; policy drop;
+		tcp dport 9999 accept
+	}
+}
+`
+  active, policy, rules := parseNFTRuleset(out)
+  if !active || policy != "drop" {
+    t.Fatalf("active=%v policy=%q", active, policy)
+  }
+  if len(rules) != 3 {
+    t.Fatalf("got %d rules: %+v", len(rules), rules)
+  }
+  if rules[1].Port != 9735 || rules[1].From != "Anywhere" || rules[2].Port != 8443 || rules[2].From != "192.168.0.0/16" {
+    t.Fatalf("rules = %+v", rules)
+  }
+}
+
+func TestFirewallExposure(t *testing.T) {
+  listeners := parseListeners(`LISTEN 0      4096         0.0.0.0:9735       0.0.0.0:*
+LISTEN 0      4096       127.0.0.1:10009      0.0.0.0:*
+LISTEN 0      4096           [::1]:10009         [::]:*
+LISTEN 0      4096               *:8443             *:*
+LISTEN 0      4096         0.0.0.0:8080       0.0.0.0:*
+LISTEN 0      128          0.0.0.0:22         0.0.0.0:*
+LISTEN 0      4096   127.0.0.53%lo:53         0.0.0.0:*
+LISTEN 0      511          0.0.0.0:3000       0.0.0.0:*
+LISTEN 0      511          0.0.0.0:5000       0.0.0.0:*
+`)
+  ports := []firewallPort{
+    {Port: 9735, Proto: "tcp", Scope: firewallScopePublic},
+    {Port: 8443, Proto: "tcp", Scope: firewallScopeLAN},
+    {Port: 22, Proto: "tcp", Scope: firewallScopeLAN},
+    {Port: 10009, Proto: "tcp", Scope: firewallScopeLocal},
+    {Port: 8080, Proto: "tcp", Scope: firewallScopeLocal},
+    {Port: 9050, Proto: "tcp", Scope: firewallScopeLocal},
+  }
+  _, policy, rules := parseUFWStatus(ufwVerbose, ufwNumbered)
+  rules = append(rules, firewallRule{To: "3000/tcp", Port: 3000, Proto: "tcp", Action: "ALLOW IN", From: "Anywhere"})
+  items := buildFirewallExposure(ports, listeners, true, policy, rules)
+
+  want := map[int]struct {
+    exposure string
+    unexpected bool
+  }{
+    9735: {firewallScopePublic, false},
+    8443: {firewallScopeLAN, false},
+    22: {firewallScopePublic, true},
+    10009: {firewallScopeLocal, false},
+    8080: {firewallScopePublic, true},
+    9050: {"", false},
+    3000: {firewallScopePublic, true},
+  }
+  if len(items) != len(want) {
+    t.Fatalf("got %d items: %+v", len(items), items)
+  }
+  for _, item := range items {
+    w, ok := want[item.Port]
+    if !ok || item.Exposure != w.exposure || item.Unexpected != w.unexpected {
+      t.Errorf("port %d: exposure=%q unexpected=%v, want %+v", item.Port, item.Exposure, item.Unexpected, w)
+    }
+  }
+
+  // With the firewall off, anything not on loopback is reachable.
+  for _, item := range buildFirewallExposure(ports, listeners, false, "", nil) {
+    if item.Port == 8443 && (item.Exposure != firewallScopePublic || !item.Unexpected) {
+      t.Fatalf("8443 with firewall off = %+v", item)
+    }
+  }
+}
+
+func TestMatchingRulesAndSources(t *testing.T) {
+  _, _, rules := parseUFWStatus(ufwVerbose, ufwNumbered)
+  picked := matchingRules(rules, 22, "tcp", map[string]bool{"any": true})
+  if len(picked) != 2 || picked[0].Number != 1 || picked[1].Number != 6 {
+    t.Fatalf("ssh rules = %+v", picked)
+  }
+  if picked := matchingRules(rules, 10009, "tcp", nil); len(picked) != 0 {
+    t.Fatalf("interface rule picked: %+v", picked)
+  }
+  if picked := matchingRules(rules, 8080, "tcp", nil); len(picked) != 1 {
+    t.Fatalf("8080 rules = %+v", picked)
+  }
+
+  if sources, _ := firewallSources("lan"); len(sources) != len(firewallLANSources) {
+    t.Fatalf("lan sources = %v", sources)
+  }
+  if sources, _ := firewallSources("192.168.1.7/24"); len(sources) != 1 || sources[0] != "192.168.1.0/24" {
+    t.Fatalf("cidr sources = %v", sources)
+  }
+  if _, err := firewallSources("example.com"); err == nil {
+    t.Fatal("expected invalid from")
+  }
+}
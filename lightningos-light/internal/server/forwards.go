@@ -0,0 +1,288 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/lnrpc"
+)
+
+// GET /api/ln/forwards pages through LND's forwarding history. LND indexes
+// forwards within the queried time range, so the cursor is "<index>:<end>"
+// and carries the end of the first page's range along; new forwards then
+// do not shift the pages. Filters are applied here, so a page scans as many
+// of LND's pages as it takes to fill it, and the totals always cover every
+// matching forward of the range.
+const (
+  forwardsDefaultLimit = 100
+  forwardsMaxLimit = 1000
+  forwardsDefaultRange = 7 * 24 * time.Hour
+  forwardsScanPageSize = 5000
+)
+
+type forwardItem struct {
+  Timestamp time.Time `json:"timestamp"`
+  ChanIDIn uint64 `json:"chan_id_in"`
+  ChanIDOut uint64 `json:"chan_id_out"`
+  PeerIn string `json:"peer_in,omitempty"`
+  PeerOut string `json:"peer_out,omitempty"`
+  PeerAliasIn string `json:"peer_alias_in,omitempty"`
+  PeerAliasOut string `json:"peer_alias_out,omitempty"`
+  AmtInMsat uint64 `json:"amt_in_msat"`
+  AmtOutMsat uint64 `json:"amt_out_msat"`
+  FeeMsat uint64 `json:"fee_msat"`
+}
+
+type forwardTotals struct {
+  Count int64 `json:"count"`
+  AmountInMsat uint64 `json:"amount_in_msat"`
+  AmountOutMsat uint64 `json:"amount_out_msat"`
+  FeeMsat uint64 `json:"fee_msat"`
+}
+
+type forwardsQuery struct {
+  Start time.Time
+  End time.Time
+  // Channels keeps forwards through any of these channels, in or out;
+  // nil keeps all.
+  Channels map[uint64]bool
+  Offset uint32
+  Limit int
+}
+
+type forwardsPage struct {
+  Items []forwardItem
+  // NextCursor is the index to continue from, or 0 at the end.
+  NextCursor uint32
+  Totals forwardTotals
+}
+
+type forwardsFetch func(ctx context.Context, offset uint32) (*lnrpc.ForwardingHistoryResponse, error)
+
+func (q forwardsQuery) match(fwd *lnrpc.ForwardingEvent) bool {
+  return q.Channels == nil || q.Channels[fwd.ChanIdIn] || q.Channels[fwd.ChanIdOut]
+}
+
+// scanForwards reads the whole range once: matching forwards at or after
+// the cursor fill the page, and every matching forward adds to the totals.
+func scanForwards(ctx context.Context, fetch forwardsFetch, q forwardsQuery, peers map[uint64]string) (forwardsPage, error) {
+  page := forwardsPage{Items: []forwardItem{}}
+  var offset uint32
+  for {
+    res, err := fetch(ctx, offset)
+    if err != nil {
+      return page, err
+    }
+    for i, fwd := range res.ForwardingEvents {
+      if !q.match(fwd) {
+        continue
+      }
+      amtIn, amtOut := fwd.AmtInMsat, fwd.AmtOutMsat
+      if amtIn == 0 {
+        amtIn = fwd.AmtIn * 1000
+      }
+      if amtOut == 0 {
+        amtOut = fwd.AmtOut * 1000
+      }
+      feeMsat := fwd.FeeMsat
+      if feeMsat == 0 {
+        feeMsat = fwd.Fee * 1000
+      }
+      page.Totals.Count++
+      page.Totals.AmountInMsat += amtIn
+      page.Totals.AmountOutMsat += amtOut
+      page.Totals.FeeMsat += feeMsat
+
+      index := offset + uint32(i)
+      if index < q.Offset {
+        continue
+      }
+      if len(page.Items) == q.Limit {
+        if page.NextCursor == 0 {
+          page.NextCursor = index
+        }
+        continue
+      }
+      occurredAt, _, _ := normalizeForwardTimestamp(fwd)
+      page.Items = append(page.Items, forwardItem{
+        Timestamp: occurredAt,
+        ChanIDIn: fwd.ChanIdIn,
+        ChanIDOut: fwd.ChanIdOut,
+        PeerIn: peers[fwd.ChanIdIn],
+        PeerOut: peers[fwd.ChanIdOut],
+        PeerAliasIn: fwd.PeerAliasIn,
+        PeerAliasOut: fwd.PeerAliasOut,
+        AmtInMsat: amtIn,
+        AmtOutMsat: amtOut,
+        FeeMsat: feeMsat,
+      })
+    }
+    if len(res.ForwardingEvents) == 0 || res.LastOffsetIndex <= offset {
+      return page, nil
+    }
+    offset = res.LastOffsetIndex
+  }
+}
+
+// parseForwardsTime takes unix seconds or RFC 3339.
+func parseForwardsTime(raw string) (time.Time, error) {
+  if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+    return time.Unix(unix, 0).UTC(), nil
+  }
+  return time.Parse(time.RFC3339, raw)
+}
+
+func parseForwardsCursor(raw string) (uint32, time.Time, bool) {
+  index, end, ok := strings.Cut(raw, ":")
+  if !ok {
+    return 0, time.Time{}, false
+  }
+  offset, err := strconv.ParseUint(index, 10, 32)
+  if err != nil {
+    return 0, time.Time{}, false
+  }
+  unix, err := strconv.ParseInt(end, 10, 64)
+  if err != nil || unix <= 0 {
+    return 0, time.Time{}, false
+  }
+  return uint32(offset), time.Unix(unix, 0).UTC(), true
+}
+
+// forwardPeers maps open and closed channels to the peer's pubkey, so
+// forwards through channels closed since are still attributed.
+func forwardPeers(ctx context.Context, client lnrpc.LightningClient) (map[uint64]string, error) {
+  peers := map[uint64]string{}
+  open, err := client.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+  if err != nil {
+    return nil, err
+  }
+  for _, ch := range open.Channels {
+    peers[ch.ChanId] = ch.RemotePubkey
+  }
+  closed, err := client.ClosedChannels(ctx, &lnrpc.ClosedChannelsRequest{})
+  if err != nil {
+    return nil, err
+  }
+  for _, ch := range closed.Channels {
+    if ch.ChanId != 0 {
+      peers[ch.ChanId] = ch.RemotePubkey
+    }
+  }
+  return peers, nil
+}
+
+func (s *Server) handleLNForwards(w http.ResponseWriter, r *http.Request) {
+  query := r.URL.Query()
+  now := time.Now().UTC()
+  q := forwardsQuery{End: now, Limit: forwardsDefaultLimit}
+  if raw := strings.TrimSpace(query.Get("end")); raw != "" {
+    end, err := parseForwardsTime(raw)
+    if err != nil {
+      writeError(w, http.StatusBadRequest, "invalid end")
+      return
+    }
+    q.End = end
+  }
+  if raw := strings.TrimSpace(query.Get("cursor")); raw != "" {
+    offset, end, ok := parseForwardsCursor(raw)
+    if !ok {
+      writeError(w, http.StatusBadRequest, "invalid cursor")
+      return
+    }
+    q.Offset, q.End = offset, end
+  }
+  q.Start = q.End.Add(-forwardsDefaultRange)
+  if raw := strings.TrimSpace(query.Get("start")); raw != "" {
+    start, err := parseForwardsTime(raw)
+    if err != nil {
+      writeError(w, http.StatusBadRequest, "invalid start")
+      return
+    }
+    q.Start = start
+  }
+  if !q.Start.Before(q.End) {
+    writeError(w, http.StatusBadRequest, "start must be before end")
+    return
+  }
+  if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+    limit, err := strconv.Atoi(raw)
+    if err != nil || limit <= 0 || limit > forwardsMaxLimit {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("limit out of range: 1 to %d", forwardsMaxLimit))
+      return
+    }
+    q.Limit = limit
+  }
+  var chanID uint64
+  if raw := strings.TrimSpace(query.Get("chan_id")); raw != "" {
+    parsed, err := strconv.ParseUint(raw, 10, 64)
+    if err != nil || parsed == 0 {
+      writeError(w, http.StatusBadRequest, "invalid chan_id")
+      return
+    }
+    chanID = parsed
+  }
+  peer := strings.ToLower(strings.TrimSpace(query.Get("peer")))
+  if peer != "" && !isValidPubkeyHex(peer) {
+    writeError(w, http.StatusBadRequest, "invalid peer pubkey")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  conn, err := s.lndFor(r).DialLightning(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  defer conn.Close()
+  client := lnrpc.NewLightningClient(conn)
+
+  peers, err := forwardPeers(ctx, client)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  if chanID != 0 || peer != "" {
+    q.Channels = map[uint64]bool{}
+    if chanID != 0 && (peer == "" || peers[chanID] == peer) {
+      q.Channels[chanID] = true
+    }
+    if peer != "" && chanID == 0 {
+      for id, pubkey := range peers {
+        if pubkey == peer {
+          q.Channels[id] = true
+        }
+      }
+    }
+  }
+
+  fetch := func(ctx context.Context, offset uint32) (*lnrpc.ForwardingHistoryResponse, error) {
+    return client.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{
+      StartTime: uint64(q.Start.Unix()),
+      EndTime: uint64(q.End.Unix()),
+      IndexOffset: offset,
+      NumMaxEvents: forwardsScanPageSize,
+      PeerAliasLookup: true,
+    })
+  }
+  page, err := scanForwards(ctx, fetch, q, peers)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  nextCursor := ""
+  if page.NextCursor != 0 {
+    nextCursor = fmt.Sprintf("%d:%d", page.NextCursor, q.End.Unix())
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "start": q.Start,
+    "end": q.End,
+    "items": page.Items,
+    "next_cursor": nextCursor,
+    "totals": page.Totals,
+  })
+}
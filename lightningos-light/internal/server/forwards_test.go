@@ -0,0 +1,92 @@
+package server
+
+import (
+  "context"
+  "testing"
+  "time"
+
+  "lightningos-light/lnrpc"
+)
+
+func forwardsFetcher(events []*lnrpc.ForwardingEvent, pageSize int) forwardsFetch {
+  return func(ctx context.Context, offset uint32) (*lnrpc.ForwardingHistoryResponse, error) {
+    res := &lnrpc.ForwardingHistoryResponse{LastOffsetIndex: offset}
+    for i := int(offset); i < len(events) && len(res.ForwardingEvents) < pageSize; i++ {
+      res.ForwardingEvents = append(res.ForwardingEvents, events[i])
+      res.LastOffsetIndex = uint32(i + 1)
+    }
+    return res, nil
+  }
+}
+
+func TestScanForwardsPagesAndTotals(t *testing.T) {
+  base := uint64(time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC).Unix())
+  events := []*lnrpc.ForwardingEvent{}
+  for i := 0; i < 7; i++ {
+    chanIn := uint64(1)
+    if i%2 == 1 {
+      chanIn = 2
+    }
+    events = append(events, &lnrpc.ForwardingEvent{
+      Timestamp: base + uint64(i),
+      ChanIdIn: chanIn,
+      ChanIdOut: 3,
+      AmtInMsat: 1_001_000,
+      AmtOutMsat: 1_000_000,
+      FeeMsat: 1_000,
+    })
+  }
+  peers := map[uint64]string{1: "02aa", 2: "02bb", 3: "02cc"}
+  fetch := forwardsFetcher(events, 3)
+
+  // Channel 1 carries forwards 0, 2, 4 and 6.
+  q := forwardsQuery{Channels: map[uint64]bool{1: true}, Limit: 2}
+  page, err := scanForwards(context.Background(), fetch, q, peers)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(page.Items) != 2 || page.Items[1].Timestamp.Unix() != int64(base+2) || page.Items[0].PeerIn != "02aa" {
+    t.Fatalf("unexpected first page: %+v", page.Items)
+  }
+  if page.NextCursor != 4 {
+    t.Fatalf("next cursor %d, want 4", page.NextCursor)
+  }
+  if page.Totals.Count != 4 || page.Totals.FeeMsat != 4_000 || page.Totals.AmountOutMsat != 4_000_000 {
+    t.Fatalf("totals cover the whole range: %+v", page.Totals)
+  }
+
+  q.Offset = page.NextCursor
+  page, err = scanForwards(context.Background(), fetch, q, peers)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(page.Items) != 2 || page.Items[0].Timestamp.Unix() != int64(base+4) || page.NextCursor != 0 {
+    t.Fatalf("unexpected last page: %+v cursor %d", page.Items, page.NextCursor)
+  }
+  if page.Totals.Count != 4 {
+    t.Fatalf("totals changed between pages: %+v", page.Totals)
+  }
+}
+
+func TestScanForwardsFallsBackToSats(t *testing.T) {
+  events := []*lnrpc.ForwardingEvent{{Timestamp: 1, AmtIn: 1001, AmtOut: 1000, Fee: 1}}
+  page, err := scanForwards(context.Background(), forwardsFetcher(events, 10), forwardsQuery{Limit: 10}, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(page.Items) != 1 || page.Items[0].AmtOutMsat != 1_000_000 || page.Items[0].FeeMsat != 1_000 {
+    t.Fatalf("unexpected item: %+v", page.Items)
+  }
+}
+
+func TestParseForwardsCursor(t *testing.T) {
+  offset, end, ok := parseForwardsCursor("250:1777593600")
+  if !ok || offset != 250 || end.Unix() != 1777593600 {
+    t.Fatalf("got %d %v %v", offset, end, ok)
+  }
+  for _, raw := range []string{"250", "x:1", "1:0", ":"} {
+    if _, _, ok := parseForwardsCursor(raw); ok {
+      t.Fatalf("accepted %q", raw)
+    }
+  }
+}
@@ -19,6 +19,8 @@ import (
 
   "github.com/jackc/pgx/v5/pgxpool"
 
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
   "lightningos-light/internal/system"
 )
 
@@ -26,14 +28,10 @@ const (
   secretsPath = "/etc/lightningos/secrets.env"
   lndConfPath = "/data/lnd/lnd.conf"
   lndPasswordPath = "/data/lnd/password.txt"
-  lndWalletDBPath = "/data/lnd/data/chain/bitcoin/mainnet/wallet.db"
-  lndAdminMacaroonPath = "/data/lnd/data/chain/bitcoin/mainnet/admin.macaroon"
   lndFixPermsScript = "/usr/local/sbin/lightningos-fix-lnd-perms"
-  mempoolBaseURL = "https://mempool.space/api/v1/lightning"
   boostPeersDefaultLimit = 25
   boostPeersMaxLimit = 100
-  lndRPCTimeout = 15 * time.Second
-  lndWarmupPeriod = 90 * time.Second
+  boostPeerSocketAttempts = 2
 )
 
 type healthIssue struct {
@@ -45,72 +43,139 @@ type healthIssue struct {
 type healthResponse struct {
   Status string `json:"status"`
   Issues []healthIssue `json:"issues"`
+  Checks []healthCheck `json:"checks"`
+  Pollers []pollerStatus `json:"pollers,omitempty"`
   Timestamp string `json:"timestamp"`
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-  issues := []healthIssue{}
-  status := "OK"
+  resp := s.healthReport(func(class timeoutClass) (context.Context, context.CancelFunc) {
+    return s.requestContext(r, class)
+  })
+  for i := range resp.Issues {
+    resp.Issues[i].Message = localize(w, resp.Issues[i].Message)
+  }
+  writeJSON(w, http.StatusOK, resp)
+}
 
-  lndCtx, lndCancel := context.WithTimeout(r.Context(), lndRPCTimeout)
-  defer lndCancel()
-  lndStatus, err := s.lnd.GetStatus(lndCtx)
-  if err != nil {
-    if isTimeoutError(err) {
-      if s.lndWarmupActive() {
-        issues = append(issues, healthIssue{Component: "lnd", Level: "WARN", Message: "LND warming up after restart (GetInfo timeout)"})
-        status = elevate(status, "WARN")
-      } else {
-        probeCtx, probeCancel := context.WithTimeout(r.Context(), 3*time.Second)
-        defer probeCancel()
-        if _, peerErr := s.lnd.ListPeers(probeCtx); peerErr == nil {
-          issues = append(issues, healthIssue{Component: "lnd", Level: "WARN", Message: "LND GetInfo timeout (gRPC reachable)"})
-          status = elevate(status, "WARN")
-        } else {
-          issues = append(issues, healthIssue{Component: "lnd", Level: "ERR", Message: lndStatusMessage(err)})
-          status = elevate(status, "ERR")
-        }
+// healthReport checks LND, bitcoind, Postgres, backups and the notifier loops. ctxFor
+// bounds each probe, so handlers and background publishers can share it;
+// the backend checks run at once under the timeouts.health budget.
+func (s *Server) healthReport(ctxFor func(timeoutClass) (context.Context, context.CancelFunc)) healthResponse {
+  start := time.Now()
+  budget := s.timeouts().Health
+  if budget <= 0 {
+    budget = config.DefaultTimeouts().Health
+  }
+  deadline := start.Add(budget)
+  // within bounds a check by its class and by the shared budget.
+  within := func(class timeoutClass, until time.Time) (context.Context, context.CancelFunc) {
+    ctx, cancel := ctxFor(class)
+    bounded, cancelBounded := context.WithDeadline(ctx, until)
+    return bounded, func() {
+      cancelBounded()
+      cancel()
+    }
+  }
+
+  lndIssue, lndDown := lndLifecycleIssue(s.lnd.Lifecycle())
+  checks := []healthCheckRun{
+    {component: "lnd", run: func() []healthIssue {
+      if lndDown {
+        return []healthIssue{lndIssue}
       }
-    } else {
-      issues = append(issues, healthIssue{Component: "lnd", Level: "ERR", Message: lndStatusMessage(err)})
-      status = elevate(status, "ERR")
+      // GetInfo gets two thirds of the budget so the fallback probe has
+      // time to tell a slow LND from an unreachable one.
+      return s.checkLNDHealth(within, start.Add(budget*2/3), deadline)
+    }},
+    {component: "bitcoin", run: func() []healthIssue {
+      ctx, cancel := within(timeoutProbe, deadline)
+      defer cancel()
+      return s.checkBitcoinHealth(ctx)
+    }},
+    {component: "postgres", run: func() []healthIssue {
+      ctx, cancel := within(timeoutProbe, deadline)
+      defer cancel()
+      if !system.SystemctlIsActive(ctx, "postgresql") {
+        return []healthIssue{{Component: "postgres", Level: "ERR", Message: "Postgres inactive"}}
+      }
+      return nil
+    }},
+    {component: "backups", run: s.checkBackupHealth},
+  }
+  results := runHealthChecks(checks, deadline.Add(healthGrace))
+
+  issues := []healthIssue{}
+  status := "OK"
+  for _, result := range results {
+    for _, issue := range result.issues {
+      issues = append(issues, issue)
+      status = elevate(status, issue.Level)
     }
-  } else if lndStatus.WalletState == "locked" {
-    issues = append(issues, healthIssue{Component: "lnd", Level: "ERR", Message: "LND wallet locked"})
-    status = elevate(status, "ERR")
   }
 
-  btcCtx, btcCancel := context.WithTimeout(r.Context(), 3*time.Second)
-  defer btcCancel()
-  bitcoin, err := s.bitcoinStatus(btcCtx)
-  if err != nil {
-    issues = append(issues, healthIssue{Component: "bitcoin", Level: "WARN", Message: "Bitcoin remote check failed"})
-    status = elevate(status, "WARN")
-  } else {
-    if !bitcoin.RPCOk {
-      issues = append(issues, healthIssue{Component: "bitcoin", Level: "ERR", Message: "Bitcoin RPC unreachable"})
-      status = elevate(status, "ERR")
+  // Loops wait while LND is down, so their lag only means something once
+  // LND is up.
+  pollers := s.notifier.pollerStatuses()
+  if !lndDown {
+    for _, poller := range pollers {
+      if poller.Stalled {
+        issues = append(issues, healthIssue{Component: "notifications", Level: "WARN", Message: "Notification poller stalled: " + poller.Name})
+        status = elevate(status, "WARN")
+      }
     }
-    if !bitcoin.ZMQRawBlockOk || !bitcoin.ZMQRawTxOk {
-      issues = append(issues, healthIssue{Component: "bitcoin", Level: "WARN", Message: "Bitcoin ZMQ unreachable"})
-      status = elevate(status, "WARN")
+    if issue, ok := s.liquidityIssue(config.DefaultNodeID); ok {
+      issues = append(issues, issue)
+      status = elevate(status, issue.Level)
     }
   }
 
-  pgCtx, pgCancel := context.WithTimeout(r.Context(), 3*time.Second)
-  defer pgCancel()
-  if !system.SystemctlIsActive(pgCtx, "postgresql") {
-    issues = append(issues, healthIssue{Component: "postgres", Level: "ERR", Message: "Postgres inactive"})
-    status = elevate(status, "ERR")
+  checkViews := make([]healthCheck, len(results))
+  for i, result := range results {
+    checkViews[i] = result.view()
   }
-
-  resp := healthResponse{
+  return healthResponse{
     Status: status,
     Issues: issues,
+    Checks: checkViews,
+    Pollers: pollers,
     Timestamp: time.Now().UTC().Format(time.RFC3339),
   }
+}
 
-  writeJSON(w, http.StatusOK, resp)
+func (s *Server) checkLNDHealth(within func(timeoutClass, time.Time) (context.Context, context.CancelFunc), statusUntil, deadline time.Time) []healthIssue {
+  lndCtx, lndCancel := within(timeoutLNDRPC, statusUntil)
+  defer lndCancel()
+  lndStatus, err := s.lnd.GetStatus(lndCtx)
+  if err != nil {
+    if isTimeoutError(err) {
+      probeCtx, probeCancel := within(timeoutProbe, deadline)
+      defer probeCancel()
+      if _, peerErr := s.lnd.ListPeers(probeCtx); peerErr == nil {
+        return []healthIssue{{Component: "lnd", Level: "WARN", Message: "LND GetInfo timeout (gRPC reachable)"}}
+      }
+    }
+    return []healthIssue{{Component: "lnd", Level: "ERR", Message: lndStatusMessage(err)}}
+  }
+  if lndStatus.WalletState == "locked" {
+    return []healthIssue{{Component: "lnd", Level: "ERR", Message: "LND wallet locked"}}
+  }
+  return nil
+}
+
+func (s *Server) checkBitcoinHealth(ctx context.Context) []healthIssue {
+  bitcoin, err := s.bitcoinStatus(ctx)
+  if err != nil {
+    return []healthIssue{{Component: "bitcoin", Level: "WARN", Message: "Bitcoin remote check failed"}}
+  }
+  issues := []healthIssue{}
+  if !bitcoin.RPCOk {
+    issues = append(issues, healthIssue{Component: "bitcoin", Level: "ERR", Message: "Bitcoin RPC unreachable"})
+  }
+  if !bitcoin.ZMQRawBlockOk || !bitcoin.ZMQRawTxOk {
+    issues = append(issues, healthIssue{Component: "bitcoin", Level: "WARN", Message: "Bitcoin ZMQ unreachable"})
+  }
+  return issues
 }
 
 func elevate(current string, next string) string {
@@ -196,7 +261,7 @@ func lndDetailedErrorMessage(err error) string {
 }
 
 func (s *Server) handleSystem(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutProbe)
   defer cancel()
 
   stats, err := system.GetSystemStats(ctx)
@@ -204,11 +269,14 @@ func (s *Server) handleSystem(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusInternalServerError, "system stats error")
     return
   }
-  writeJSON(w, http.StatusOK, stats)
+  writeJSON(w, http.StatusOK, struct {
+    system.SystemStats
+    Watchdog sdWatchdogStatus `json:"watchdog"`
+  }{stats, s.sdWatchdog.status()})
 }
 
 func (s *Server) handleDisk(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
   disks, err := system.ReadDiskSmart(ctx)
@@ -226,6 +294,9 @@ type postgresResponse struct {
   Connections int64 `json:"connections"`
   Version string `json:"version"`
   Databases []postgresDatabase `json:"databases,omitempty"`
+  Storage *postgresStorage `json:"storage,omitempty"`
+  LongQueries []postgresLongQuery `json:"long_queries"`
+  Backup postgresBackupStatus `json:"backup"`
 }
 
 type postgresDatabase struct {
@@ -234,15 +305,20 @@ type postgresDatabase struct {
   SizeMB int64 `json:"size_mb"`
   Connections int64 `json:"connections"`
   Available bool `json:"available"`
+  LastBackupAt *time.Time `json:"last_backup_at,omitempty"`
+  LastBackupSizeBytes int64 `json:"last_backup_size_bytes,omitempty"`
+  BackupCount int `json:"backup_count"`
 }
 
 func (s *Server) handlePostgres(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutProbe)
   defer cancel()
 
   entries := postgresDSNEntries()
   databases := make([]postgresDatabase, 0, len(entries))
   version := ""
+  var storage *postgresStorage
+  longQueries := []postgresLongQuery{}
 
   for _, entry := range entries {
     dbName := databaseNameFromDSN(entry.DSN)
@@ -253,6 +329,12 @@ func (s *Server) handlePostgres(w http.ResponseWriter, r *http.Request) {
       Name: dbName,
       Source: entry.Source,
     }
+    if backups := postgresBackups(dbName); len(backups) > 0 {
+      last := backups[0].CreatedAt
+      db.LastBackupAt = &last
+      db.LastBackupSizeBytes = backups[0].SizeBytes
+      db.BackupCount = len(backups)
+    }
     pool, err := pgxpool.New(ctx, entry.DSN)
     if err == nil {
       db.Available = true
@@ -267,6 +349,12 @@ func (s *Server) handlePostgres(w http.ResponseWriter, r *http.Request) {
       if version == "" {
         _ = pool.QueryRow(ctx, "show server_version").Scan(&version)
       }
+      // Storage and activity are cluster-wide, so the first database is enough.
+      if storage == nil {
+        stats := postgresStorageStats(ctx, pool)
+        storage = &stats
+        longQueries = append(longQueries, postgresLongQueries(ctx, pool)...)
+      }
       pool.Close()
     }
     databases = append(databases, db)
@@ -277,6 +365,9 @@ func (s *Server) handlePostgres(w http.ResponseWriter, r *http.Request) {
     DBName: s.cfg.Postgres.DBName,
     Databases: databases,
     Version: version,
+    Storage: storage,
+    LongQueries: longQueries,
+    Backup: s.pgBackup.status(),
   }
 
   if len(databases) > 0 {
@@ -324,6 +415,10 @@ type bitcoinStatus struct {
   RPCOk bool `json:"rpc_ok"`
   ZMQRawBlockOk bool `json:"zmq_rawblock_ok"`
   ZMQRawTxOk bool `json:"zmq_rawtx_ok"`
+  ZMQRawBlockLastAt *time.Time `json:"zmq_rawblock_last_at,omitempty"`
+  ZMQRawTxLastAt *time.Time `json:"zmq_rawtx_last_at,omitempty"`
+  ZMQRawBlockError string `json:"zmq_rawblock_error,omitempty"`
+  ZMQRawTxError string `json:"zmq_rawtx_error,omitempty"`
   Version int `json:"version,omitempty"`
   Subversion string `json:"subversion,omitempty"`
   Chain string `json:"chain,omitempty"`
@@ -353,27 +448,6 @@ type mempoolFeeRecommendation struct {
   MinimumFee int `json:"minimumFee"`
 }
 
-type boostPeersRequest struct {
-  Limit int `json:"limit"`
-}
-
-type boostPeerResult struct {
-  Pubkey string `json:"pubkey"`
-  Alias string `json:"alias"`
-  Socket string `json:"socket,omitempty"`
-  Status string `json:"status"`
-  Error string `json:"error,omitempty"`
-}
-
-type boostPeersResponse struct {
-  Requested int `json:"requested"`
-  Attempted int `json:"attempted"`
-  Connected int `json:"connected"`
-  Skipped int `json:"skipped"`
-  Failed int `json:"failed"`
-  Results []boostPeerResult `json:"results"`
-}
-
 type bitcoinRPCConfig struct {
   Host string
   User string
@@ -383,7 +457,7 @@ type bitcoinRPCConfig struct {
 }
 
 func (s *Server) handleBitcoin(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutProbe)
   defer cancel()
 
   status, err := s.bitcoinStatus(ctx)
@@ -395,7 +469,7 @@ func (s *Server) handleBitcoin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleBitcoinActive(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutProbe)
   defer cancel()
 
   source := readBitcoinSource()
@@ -458,7 +532,7 @@ func (s *Server) handleBitcoinSourcePost(w http.ResponseWriter, r *http.Request)
   }
   if err != nil && source != "local" {
     localCfg = bitcoinRPCConfig{
-      Host: "127.0.0.1:8332",
+      Host: localBitcoinRPCHost(),
       ZMQBlock: "tcp://127.0.0.1:28332",
       ZMQTx: "tcp://127.0.0.1:28333",
     }
@@ -475,7 +549,7 @@ func (s *Server) handleBitcoinSourcePost(w http.ResponseWriter, r *http.Request)
 
   needsBitcoinRestart := source == "local" && localUpdated
   if source == "local" && !needsBitcoinRestart {
-    rpcCtx, rpcCancel := context.WithTimeout(r.Context(), 4*time.Second)
+    rpcCtx, rpcCancel := s.requestContext(r, timeoutProbe)
     defer rpcCancel()
     if _, err := fetchBitcoinInfo(rpcCtx, localCfg.Host, localCfg.User, localCfg.Pass); err != nil {
       var statusErr rpcStatusError
@@ -487,7 +561,7 @@ func (s *Server) handleBitcoinSourcePost(w http.ResponseWriter, r *http.Request)
 
   if needsBitcoinRestart {
     paths := bitcoinCoreAppPaths()
-    ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+    ctx, cancel := s.requestContext(r, timeoutMedium)
     defer cancel()
     if err := runCompose(ctx, paths.Root, paths.ComposePath, "restart", "bitcoind"); err != nil {
       writeError(w, http.StatusInternalServerError, "bitcoin restart failed")
@@ -495,14 +569,14 @@ func (s *Server) handleBitcoinSourcePost(w http.ResponseWriter, r *http.Request)
     }
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutLong)
   defer cancel()
   if err := system.SystemctlRestart(ctx, "lnd"); err != nil {
     if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
       s.markLNDRestart()
       writeJSON(w, http.StatusOK, map[string]any{
         "ok": true,
-        "warning": "LND restart is taking longer than expected. Check status in a moment.",
+        "warning": localize(w, "LND restart is taking longer than expected. Check status in a moment."),
       })
       return
     }
@@ -552,8 +626,7 @@ func (s *Server) bitcoinStatus(ctx context.Context) (bitcoinStatus, error) {
     }
   }
 
-  status.ZMQRawBlockOk = testTCP(s.cfg.BitcoinRemote.ZMQRawBlock)
-  status.ZMQRawTxOk = testTCP(s.cfg.BitcoinRemote.ZMQRawTx)
+  applyZMQStatus(&status)
 
   return status, nil
 }
@@ -562,7 +635,7 @@ func (s *Server) bitcoinLocalStatusActive(ctx context.Context) (bitcoinStatus, e
   paths := bitcoinCoreAppPaths()
   status := bitcoinStatus{
     Mode: "local",
-    RPCHost: "127.0.0.1:8332",
+    RPCHost: localBitcoinRPCHost(),
     ZMQRawBlock: "tcp://127.0.0.1:28332",
     ZMQRawTx: "tcp://127.0.0.1:28333",
   }
@@ -595,8 +668,7 @@ func (s *Server) bitcoinLocalStatusActive(ctx context.Context) (bitcoinStatus, e
         }
       }
     }
-    status.ZMQRawBlockOk = testTCP(status.ZMQRawBlock)
-    status.ZMQRawTxOk = testTCP(status.ZMQRawTx)
+    applyZMQStatus(&status)
     return status, nil
   }
   info, netInfo, err := fetchBitcoinLocalInfo(ctx, paths)
@@ -611,8 +683,7 @@ func (s *Server) bitcoinLocalStatusActive(ctx context.Context) (bitcoinStatus, e
     status.Version = netInfo.Version
     status.Subversion = netInfo.Subversion
   }
-  status.ZMQRawBlockOk = testTCP(status.ZMQRawBlock)
-  status.ZMQRawTxOk = testTCP(status.ZMQRawTx)
+  applyZMQStatus(&status)
   return status, nil
 }
 
@@ -632,13 +703,16 @@ func readBitcoinLocalRPCConfig(ctx context.Context) (bitcoinRPCConfig, bool, err
     return bitcoinRPCConfig{}, false, fmt.Errorf("failed to read local bitcoin.conf: %w", err)
   }
   user, pass, zmqBlock, zmqTx := parseBitcoinCoreRPCConfig(raw)
+  if user == "" || pass == "" {
+    user, pass, _ = readBitcoinCookie(bitcoinCookiePath(paths.DataDir, raw))
+  }
   if user == "" || pass == "" {
     return bitcoinRPCConfig{}, false, errors.New("local RPC credentials missing")
   }
   zmqBlock = normalizeLocalZMQ(zmqBlock, "tcp://127.0.0.1:28332")
   zmqTx = normalizeLocalZMQ(zmqTx, "tcp://127.0.0.1:28333")
   return bitcoinRPCConfig{
-    Host: "127.0.0.1:8332",
+    Host: localBitcoinRPCHost(),
     User: user,
     Pass: pass,
     ZMQBlock: zmqBlock,
@@ -653,13 +727,16 @@ func readBitcoinConfRPCConfig(path string) (bitcoinRPCConfig, bool) {
   }
   normalized := strings.ReplaceAll(string(raw), "\r\n", "\n")
   user, pass, zmqBlock, zmqTx := parseBitcoinCoreRPCConfig(normalized)
+  if strings.TrimSpace(user) == "" || strings.TrimSpace(pass) == "" {
+    user, pass, _ = readBitcoinCookie(bitcoinCookiePath(filepath.Dir(path), normalized))
+  }
   if strings.TrimSpace(user) == "" || strings.TrimSpace(pass) == "" {
     return bitcoinRPCConfig{}, false
   }
   zmqBlock = normalizeLocalZMQ(zmqBlock, "tcp://127.0.0.1:28332")
   zmqTx = normalizeLocalZMQ(zmqTx, "tcp://127.0.0.1:28333")
 
-  host := "127.0.0.1:8332"
+  host := localBitcoinRPCHost()
   if port, ok := parseBitcoinRPCPortFromConf(normalized); ok {
     host = fmt.Sprintf("127.0.0.1:%d", port)
   }
@@ -708,7 +785,7 @@ func readBitcoindRPCConfigFromLNDConf() (bitcoinRPCConfig, bool) {
 
   inBitcoind := false
   cfg := bitcoinRPCConfig{
-    Host: "127.0.0.1:8332",
+    Host: localBitcoinRPCHost(),
     ZMQBlock: "tcp://127.0.0.1:28332",
     ZMQTx: "tcp://127.0.0.1:28333",
   }
@@ -737,7 +814,7 @@ func readBitcoindRPCConfigFromLNDConf() (bitcoinRPCConfig, bool) {
       if val != "" {
         host := strings.TrimPrefix(val, "tcp://")
         if !strings.Contains(host, ":") {
-          host = host + ":8332"
+          host = fmt.Sprintf("%s:%d", host, activeNetwork().RPCPort)
         }
         cfg.Host = host
       }
@@ -768,6 +845,7 @@ func readBitcoinSecrets() (string, string) {
   }
   var user string
   var pass string
+  var cookie string
   for _, line := range strings.Split(string(content), "\n") {
     if strings.HasPrefix(line, "BITCOIN_RPC_USER=") {
       user = strings.TrimPrefix(line, "BITCOIN_RPC_USER=")
@@ -775,6 +853,16 @@ func readBitcoinSecrets() (string, string) {
     if strings.HasPrefix(line, "BITCOIN_RPC_PASS=") {
       pass = strings.TrimPrefix(line, "BITCOIN_RPC_PASS=")
     }
+    if strings.HasPrefix(line, "BITCOIN_RPC_COOKIE=") {
+      cookie = strings.TrimPrefix(line, "BITCOIN_RPC_COOKIE=")
+    }
+  }
+  // A cookie file wins over stored credentials: it is rotated on every
+  // bitcoind restart, so a user/pass copied from it goes stale.
+  if cookie = strings.TrimSpace(cookie); cookie != "" {
+    if cookieUser, cookiePass, err := readBitcoinCookie(cookie); err == nil {
+      return cookieUser, cookiePass
+    }
   }
   return strings.TrimSpace(user), strings.TrimSpace(pass)
 }
@@ -816,6 +904,7 @@ type lndStatusResponse struct {
   InfoKnown bool `json:"info_known"`
   InfoStale bool `json:"info_stale"`
   InfoAgeSeconds int64 `json:"info_age_seconds"`
+  Lifecycle lndclient.Lifecycle `json:"lifecycle"`
   Channels struct {
     Active int `json:"active"`
     Inactive int `json:"inactive"`
@@ -827,13 +916,15 @@ type lndStatusResponse struct {
 }
 
 func (s *Server) handleLNDStatus(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
   resp := lndStatusResponse{}
   resp.ServiceActive = system.SystemctlIsActive(ctx, "lnd")
 
-  status, err := s.lnd.GetStatus(ctx)
+  lnd := s.lndFor(r)
+  resp.Lifecycle = lnd.Lifecycle()
+  status, err := lnd.GetStatus(ctx)
   _ = err
   resp.WalletState = status.WalletState
   resp.SyncedToChain = status.SyncedToChain
@@ -853,12 +944,6 @@ func (s *Server) handleLNDStatus(w http.ResponseWriter, r *http.Request) {
   writeJSON(w, http.StatusOK, resp)
 }
 
-func (s *Server) handleWizardStatus(w http.ResponseWriter, r *http.Request) {
-  writeJSON(w, http.StatusOK, map[string]any{
-    "wallet_exists": walletExists(),
-  })
-}
-
 type wizardBitcoinReq struct {
   RPCUser string `json:"rpcuser"`
   RPCPass string `json:"rpcpass"`
@@ -877,7 +962,7 @@ func (s *Server) handleWizardBitcoinRemote(w http.ResponseWriter, r *http.Reques
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
   defer cancel()
 
   info, err := fetchBitcoinInfo(ctx, s.cfg.BitcoinRemote.RPCHost, user, pass)
@@ -908,6 +993,7 @@ func (s *Server) handleWizardBitcoinRemote(w http.ResponseWriter, r *http.Reques
   }
 
   _ = storeBitcoinSource("remote")
+  _ = markWizardStep("bitcoin", "done")
 
   _ = system.SystemctlRestart(ctx, "lnd")
 
@@ -929,7 +1015,7 @@ func (s *Server) handleCreateWallet(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
   seedPassphrase := strings.TrimSpace(req.SeedPassphrase)
@@ -947,7 +1033,7 @@ func walletExists() bool {
   if walletPasswordAvailable() {
     return true
   }
-  info, err := os.Stat(lndWalletDBPath)
+  info, err := os.Stat(lndWalletDBPath())
   if err != nil {
     return false
   }
@@ -972,7 +1058,7 @@ func (s *Server) handleInitWallet(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
   defer cancel()
 
   if err := s.lnd.InitWallet(ctx, req.WalletPassword, req.SeedWords); err != nil {
@@ -985,6 +1071,7 @@ func (s *Server) handleInitWallet(w http.ResponseWriter, r *http.Request) {
     return
   }
   s.scheduleLNDPermissionsFix("init wallet")
+  _ = markWizardStep("wallet", "done")
 
   writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
@@ -1002,13 +1089,15 @@ func (s *Server) handleUnlockWallet(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
   if err := s.lnd.UnlockWallet(ctx, req.WalletPassword); err != nil {
+    recordUnlockResult("manual", false, "unlock failed")
     writeError(w, http.StatusInternalServerError, "unlock failed")
     return
   }
+  recordUnlockResult("manual", true, "")
   if err := storeWalletUnlock(req.WalletPassword); err != nil {
     s.logger.Printf("wallet unlock setup failed: %v", err)
     writeError(w, http.StatusInternalServerError, "wallet unlock setup failed")
@@ -1034,7 +1123,7 @@ func (s *Server) handleRestart(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
   if err := system.SystemctlRestart(ctx, service); err != nil {
@@ -1048,38 +1137,6 @@ func (s *Server) handleRestart(w http.ResponseWriter, r *http.Request) {
   writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
-func (s *Server) handleSystemAction(w http.ResponseWriter, r *http.Request) {
-  var req struct {
-    Action string `json:"action"`
-  }
-  if err := readJSON(r, &req); err != nil {
-    writeError(w, http.StatusBadRequest, "invalid json")
-    return
-  }
-
-  action := strings.ToLower(strings.TrimSpace(req.Action))
-  switch action {
-  case "restart":
-    action = "reboot"
-  case "shutdown":
-    action = "poweroff"
-  }
-  if action != "reboot" && action != "poweroff" {
-    writeError(w, http.StatusBadRequest, "unsupported action")
-    return
-  }
-
-  ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
-  defer cancel()
-
-  if err := system.SystemctlPower(ctx, action); err != nil {
-    writeError(w, http.StatusInternalServerError, "system action failed")
-    return
-  }
-
-  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
-}
-
 func mapService(name string) string {
   switch name {
   case "lnd":
@@ -1117,6 +1174,10 @@ func parsePeerAddress(address string) (string, string, error) {
 }
 
 func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+  if isLogSearch(r) {
+    s.handleLogSearch(w, r)
+    return
+  }
   service := r.URL.Query().Get("service")
   linesRaw := r.URL.Query().Get("lines")
 
@@ -1133,7 +1194,7 @@ func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutProbe)
   defer cancel()
 
   out, err := system.JournalTail(ctx, service, lines)
@@ -1170,23 +1231,30 @@ func (s *Server) handleLNDConfigGet(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleLNChannels(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  channels, err := s.lnd.ListChannels(ctx)
+  channels, err := s.lndFor(r).ListChannels(ctx)
   if err != nil {
     writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
     return
   }
 
-  pending, pendingErr := s.lnd.ListPendingChannels(ctx)
+  pending, pendingErr := s.lndFor(r).ListPendingChannels(ctx)
   if pendingErr != nil {
     pending = nil
   }
 
+  meta := currentPeerMetadata()
+  _, tagged := peerTagFilter(r, meta)
+  views := []channelView{}
   active := 0
   inactive := 0
   for _, ch := range channels {
+    if !tagged(ch.RemotePubkey) {
+      continue
+    }
+    views = append(views, newChannelView(ch, meta))
     if ch.Active {
       active++
     } else {
@@ -1196,7 +1264,12 @@ func (s *Server) handleLNChannels(w http.ResponseWriter, r *http.Request) {
 
   pendingOpen := 0
   pendingClose := 0
+  pendingViews := []lndclient.PendingChannelInfo{}
   for _, ch := range pending {
+    if !tagged(ch.RemotePubkey) {
+      continue
+    }
+    pendingViews = append(pendingViews, ch)
     if ch.Status == "opening" {
       pendingOpen++
       continue
@@ -1209,22 +1282,39 @@ func (s *Server) handleLNChannels(w http.ResponseWriter, r *http.Request) {
     "inactive_count": inactive,
     "pending_open_count": pendingOpen,
     "pending_close_count": pendingClose,
-    "channels": channels,
-    "pending_channels": pending,
+    "pending_splices": pendingSplices(s.nodeIDFor(r)),
+    "channels": views,
+    "pending_channels": pendingViews,
   })
 }
 
 func (s *Server) handleLNPeers(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  peers, err := s.lnd.ListPeers(ctx)
+  peers, err := s.lndFor(r).ListPeers(ctx)
   if err != nil {
     writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
     return
   }
 
-  writeJSON(w, http.StatusOK, map[string]any{"peers": peers})
+  meta := currentPeerMetadata()
+  _, tagged := peerTagFilter(r, meta)
+  qualities := map[string]*peerQuality{}
+  if s.db != nil && s.nodeIDFor(r) == config.DefaultNodeID {
+    if loaded, err := s.peerQualities(ctx); err == nil {
+      qualities = loaded
+    }
+  }
+  views := []peerView{}
+  for _, peer := range peers {
+    if tagged(peer.PubKey) {
+      view := newPeerView(peer, meta)
+      view.Quality = qualities[peer.PubKey]
+      views = append(views, view)
+    }
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"peers": views})
 }
 
 func (s *Server) handleLNConnectPeer(w http.ResponseWriter, r *http.Request) {
@@ -1250,25 +1340,42 @@ func (s *Server) handleLNConnectPeer(w http.ResponseWriter, r *http.Request) {
     pubkey = parsedPubkey
     host = parsedHost
   }
-  if pubkey == "" || host == "" {
+  if pubkey == "" {
     writeError(w, http.StatusBadRequest, "pubkey and host required")
     return
   }
+  // Without a host, pick the best socket the graph explorer knows for the
+  // peer, skipping families this node cannot reach.
+  if host == "" {
+    lookupCtx, lookupCancel := s.requestContext(r, timeoutMedium)
+    info, err := s.mempool.nodeInfo(lookupCtx, pubkey)
+    lookupCancel()
+    if err != nil {
+      writeError(w, http.StatusBadRequest, "pubkey and host required")
+      return
+    }
+    sockets := rankPeerSockets(info.Sockets, currentSocketReachability())
+    if len(sockets) == 0 {
+      writeError(w, http.StatusBadRequest, "no reachable socket found")
+      return
+    }
+    host = sockets[0]
+  }
 
   perm := true
   if req.Perm != nil {
     perm = *req.Perm
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  if err := s.lnd.ConnectPeer(ctx, pubkey, host, perm); err != nil {
+  if err := s.lndFor(r).ConnectPeer(ctx, pubkey, host, perm); err != nil {
     writeError(w, http.StatusInternalServerError, peerConnectErrorMessage(err))
     return
   }
 
-  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+  writeJSON(w, http.StatusOK, map[string]any{"ok": true, "host": host})
 }
 
 func (s *Server) handleLNDisconnectPeer(w http.ResponseWriter, r *http.Request) {
@@ -1285,10 +1392,10 @@ func (s *Server) handleLNDisconnectPeer(w http.ResponseWriter, r *http.Request)
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  if err := s.lnd.DisconnectPeer(ctx, pubkey); err != nil {
+  if err := s.lndFor(r).DisconnectPeer(ctx, pubkey); err != nil {
     writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
     return
   }
@@ -1296,208 +1403,6 @@ func (s *Server) handleLNDisconnectPeer(w http.ResponseWriter, r *http.Request)
   writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
-func (s *Server) handleLNBoostPeers(w http.ResponseWriter, r *http.Request) {
-  var req boostPeersRequest
-  if err := readJSON(r, &req); err != nil {
-    writeError(w, http.StatusBadRequest, "invalid json")
-    return
-  }
-
-  limit := req.Limit
-  if limit <= 0 {
-    limit = boostPeersDefaultLimit
-  }
-  if limit > boostPeersMaxLimit {
-    limit = boostPeersMaxLimit
-  }
-
-  peersCtx, peersCancel := context.WithTimeout(r.Context(), lndRPCTimeout)
-  peers, err := s.lnd.ListPeers(peersCtx)
-  peersCancel()
-  if err != nil {
-    writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
-    return
-  }
-
-  existing := map[string]bool{}
-  for _, peer := range peers {
-    if peer.PubKey != "" {
-      existing[peer.PubKey] = true
-    }
-  }
-
-  rankingCtx, rankingCancel := context.WithTimeout(r.Context(), 10*time.Second)
-  ranking, err := fetchMempoolConnectivity(rankingCtx)
-  rankingCancel()
-  if err != nil {
-    s.logger.Printf("mempool connectivity fetch failed: %v", err)
-    writeError(w, http.StatusInternalServerError, "mempool connectivity fetch failed")
-    return
-  }
-
-  if limit > len(ranking) {
-    limit = len(ranking)
-  }
-
-  resp := boostPeersResponse{
-    Requested: limit,
-  }
-  results := make([]boostPeerResult, 0, limit)
-
-  for i := 0; i < limit; i++ {
-    node := ranking[i]
-    pubkey := strings.TrimSpace(node.PublicKey)
-    alias := strings.TrimSpace(node.Alias)
-    if pubkey == "" {
-      results = append(results, boostPeerResult{
-        Alias: alias,
-        Status: "skipped",
-        Error: "missing pubkey",
-      })
-      resp.Skipped++
-      continue
-    }
-    if existing[pubkey] {
-      results = append(results, boostPeerResult{
-        Pubkey: pubkey,
-        Alias: alias,
-        Status: "skipped",
-        Error: "already connected",
-      })
-      resp.Skipped++
-      continue
-    }
-
-    infoCtx, infoCancel := context.WithTimeout(r.Context(), 8*time.Second)
-    info, err := fetchMempoolNodeInfo(infoCtx, pubkey)
-    infoCancel()
-    if err != nil {
-      results = append(results, boostPeerResult{
-        Pubkey: pubkey,
-        Alias: alias,
-        Status: "failed",
-        Error: "mempool node lookup failed",
-      })
-      resp.Failed++
-      continue
-    }
-    if alias == "" {
-      alias = strings.TrimSpace(info.Alias)
-    }
-    socket := firstSocket(info.Sockets)
-    if socket == "" {
-      results = append(results, boostPeerResult{
-        Pubkey: pubkey,
-        Alias: alias,
-        Status: "skipped",
-        Error: "no socket found",
-      })
-      resp.Skipped++
-      continue
-    }
-
-    connectCtx, connectCancel := context.WithTimeout(r.Context(), lndRPCTimeout)
-    err = s.lnd.ConnectPeer(connectCtx, pubkey, socket, true)
-    connectCancel()
-    resp.Attempted++
-    if err != nil {
-      if isAlreadyConnected(err) {
-        results = append(results, boostPeerResult{
-          Pubkey: pubkey,
-          Alias: alias,
-          Socket: socket,
-          Status: "skipped",
-          Error: "already connected",
-        })
-        resp.Skipped++
-      } else {
-        results = append(results, boostPeerResult{
-          Pubkey: pubkey,
-          Alias: alias,
-          Socket: socket,
-          Status: "failed",
-          Error: err.Error(),
-        })
-        resp.Failed++
-      }
-      continue
-    }
-
-    existing[pubkey] = true
-    results = append(results, boostPeerResult{
-      Pubkey: pubkey,
-      Alias: alias,
-      Socket: socket,
-      Status: "connected",
-    })
-    resp.Connected++
-  }
-
-  resp.Results = results
-  writeJSON(w, http.StatusOK, resp)
-}
-
-func fetchMempoolConnectivity(ctx context.Context) ([]mempoolConnectivityNode, error) {
-  var nodes []mempoolConnectivityNode
-  url := mempoolBaseURL + "/nodes/rankings/connectivity"
-  if err := fetchMempoolJSON(ctx, url, &nodes); err != nil {
-    return nil, err
-  }
-  return nodes, nil
-}
-
-func fetchMempoolNodeInfo(ctx context.Context, pubkey string) (mempoolNodeInfo, error) {
-  var info mempoolNodeInfo
-  url := mempoolBaseURL + "/nodes/" + pubkey
-  if err := fetchMempoolJSON(ctx, url, &info); err != nil {
-    return mempoolNodeInfo{}, err
-  }
-  return info, nil
-}
-
-func fetchMempoolJSON(ctx context.Context, url string, dst any) error {
-  req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-  if err != nil {
-    return err
-  }
-  client := &http.Client{Timeout: 10 * time.Second}
-  resp, err := client.Do(req)
-  if err != nil {
-    return err
-  }
-  defer resp.Body.Close()
-  if resp.StatusCode != http.StatusOK {
-    body, _ := io.ReadAll(resp.Body)
-    msg := strings.TrimSpace(string(body))
-    if msg == "" {
-      msg = resp.Status
-    }
-    return fmt.Errorf("mempool api error: %s", msg)
-  }
-  return json.NewDecoder(resp.Body).Decode(dst)
-}
-
-func firstSocket(raw string) string {
-  if raw == "" {
-    return ""
-  }
-  parts := strings.Split(raw, ",")
-  if len(parts) == 0 {
-    return ""
-  }
-  socket := strings.TrimSpace(parts[0])
-  if socket == "" {
-    return ""
-  }
-  if strings.Contains(socket, "@") {
-    pieces := strings.SplitN(socket, "@", 2)
-    if len(pieces) == 2 {
-      socket = strings.TrimSpace(pieces[1])
-    }
-  }
-  return socket
-}
-
 func isAlreadyConnected(err error) bool {
   if err == nil {
     return false
@@ -1533,6 +1438,7 @@ func (s *Server) handleLNOpenChannel(w http.ResponseWriter, r *http.Request) {
     CloseAddress string `json:"close_address"`
     Private bool `json:"private"`
     SatPerVbyte int64 `json:"sat_per_vbyte"`
+    AllowHighFee bool `json:"allow_high_fee"`
   }
   if err := readJSON(r, &req); err != nil {
     writeError(w, http.StatusBadRequest, "invalid json")
@@ -1550,8 +1456,12 @@ func (s *Server) handleLNOpenChannel(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusBadRequest, "local_funding_sat must be positive")
     return
   }
-  if req.SatPerVbyte < 0 {
-    writeError(w, http.StatusBadRequest, "sat_per_vbyte must be zero or positive")
+  req.CloseAddress = strings.TrimSpace(req.CloseAddress)
+  if err := checkAutomatedCloseAddress(r, req.CloseAddress); err != nil {
+    writeError(w, http.StatusForbidden, err.Error())
+    return
+  }
+  if !s.guardFeeRate(w, r, feeGuardOpen, req.SatPerVbyte, req.AllowHighFee) {
     return
   }
 
@@ -1565,15 +1475,15 @@ func (s *Server) handleLNOpenChannel(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  if err := s.lnd.ConnectPeer(ctx, pubkey, host, false); err != nil && !isAlreadyConnected(err) {
+  if err := s.lndFor(r).ConnectPeer(ctx, pubkey, host, false); err != nil && !isAlreadyConnected(err) {
     writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
     return
   }
 
-  channelPoint, err := s.lnd.OpenChannel(ctx, pubkey, req.LocalFundingSat, req.CloseAddress, req.Private, req.SatPerVbyte)
+  channelPoint, err := s.lndFor(r).OpenChannel(ctx, pubkey, req.LocalFundingSat, req.CloseAddress, req.Private, req.SatPerVbyte)
   if err != nil {
     writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
     return
@@ -1583,47 +1493,95 @@ func (s *Server) handleLNOpenChannel(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleMempoolFees(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutProbe)
   defer cancel()
 
-  url := "https://mempool.space/api/v1/fees/recommended"
-  var fees mempoolFeeRecommendation
-  if err := fetchMempoolJSON(ctx, url, &fees); err != nil {
+  fees, err := s.mempool.fees(ctx)
+  if err == s.mempool.unavailable {
+    writeError(w, http.StatusServiceUnavailable, err.Error())
+    return
+  }
+  if err != nil {
     writeError(w, http.StatusInternalServerError, "mempool fee fetch failed")
     return
   }
   writeJSON(w, http.StatusOK, fees)
 }
 
-func (s *Server) handleLNCloseChannel(w http.ResponseWriter, r *http.Request) {
-  var req struct {
-    ChannelPoint string `json:"channel_point"`
-    Force bool `json:"force"`
-    SatPerVbyte int64 `json:"sat_per_vbyte"`
+type channelCloseParams struct {
+  ChannelPoint string `json:"channel_point"`
+  Force bool `json:"force"`
+  SatPerVbyte int64 `json:"sat_per_vbyte"`
+  AllowHighFee bool `json:"allow_high_fee,omitempty"`
+  // TargetConf asks LND to price the close for confirmation within that
+  // many blocks; it replaces sat_per_vbyte.
+  TargetConf int32 `json:"target_conf,omitempty"`
+  DeliveryAddress string `json:"delivery_address,omitempty"`
+  MaxFeePerVbyte int64 `json:"max_fee_per_vbyte,omitempty"`
+  // WaitForTxid waits for the closing transaction so the result carries its
+  // txid, instead of returning once LND accepted the close.
+  WaitForTxid bool `json:"wait_for_txid,omitempty"`
+}
+
+func (req channelCloseParams) validate() string {
+  if req.ChannelPoint == "" {
+    return "channel_point required"
+  }
+  if req.TargetConf < 0 || req.MaxFeePerVbyte < 0 {
+    return "target_conf and max_fee_per_vbyte must be zero or positive"
+  }
+  if req.TargetConf > 0 && req.SatPerVbyte > 0 {
+    return "use either sat_per_vbyte or target_conf"
+  }
+  if req.Force && (req.TargetConf > 0 || req.DeliveryAddress != "" || req.MaxFeePerVbyte > 0) {
+    return "delivery_address, target_conf and max_fee_per_vbyte only apply to cooperative closes"
+  }
+  if req.MaxFeePerVbyte > 0 && req.SatPerVbyte > req.MaxFeePerVbyte {
+    return "sat_per_vbyte above max_fee_per_vbyte"
   }
+  return ""
+}
+
+func (s *Server) handleLNCloseChannel(w http.ResponseWriter, r *http.Request) {
+  var req channelCloseParams
   if err := readJSON(r, &req); err != nil {
     writeError(w, http.StatusBadRequest, "invalid json")
     return
   }
-  if strings.TrimSpace(req.ChannelPoint) == "" {
-    writeError(w, http.StatusBadRequest, "channel_point required")
+  req.ChannelPoint = strings.TrimSpace(req.ChannelPoint)
+  req.DeliveryAddress = strings.TrimSpace(req.DeliveryAddress)
+  if msg := req.validate(); msg != "" {
+    writeError(w, http.StatusBadRequest, msg)
     return
   }
-
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
-  defer cancel()
-
-  if req.SatPerVbyte < 0 {
-    writeError(w, http.StatusBadRequest, "sat_per_vbyte must be zero or positive")
+  if err := checkAutomatedCloseAddress(r, req.DeliveryAddress); err != nil {
+    writeError(w, http.StatusForbidden, err.Error())
     return
   }
-
-  if err := s.lnd.CloseChannel(ctx, req.ChannelPoint, req.Force, req.SatPerVbyte); err != nil {
-    writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
+  if !s.guardFeeRate(w, r, feeGuardClose, req.SatPerVbyte, req.AllowHighFee) {
+    return
+  }
+  // The negotiated coop fee can climb up to max_fee_per_vbyte, so the cap
+  // applies to it as well.
+  if !s.guardFeeRate(w, r, feeGuardClose, req.MaxFeePerVbyte, req.AllowHighFee) {
     return
   }
 
-  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+  job, err := s.jobs.submit(s.nodeIDFor(r), jobKindChannelClose, closeJobKey(req), req)
+  if err != nil {
+    writeJobSubmitError(w, err)
+    return
+  }
+  // The job follows the close until it confirms; the request only waits for
+  // LND to accept it, or for the closing txid when asked to. Waiting for the
+  // txid involves the peer, so it gets the longer write deadline.
+  step, class := closeStepPending, timeoutLNDRPC
+  if req.WaitForTxid {
+    step, class = closeStepBroadcast, timeoutLNDWrite
+  }
+  ctx, cancel := s.requestContext(r, class)
+  defer cancel()
+  writeJobStarted(w, s.jobs.waitUntil(ctx, job.ID, func(job Job) bool { return job.Done >= step }))
 }
 
 func (s *Server) handleLNUpdateFees(w http.ResponseWriter, r *http.Request) {
@@ -1658,14 +1616,14 @@ func (s *Server) handleLNUpdateFees(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  if err := s.lnd.UpdateChannelFees(ctx, req.ChannelPoint, req.ApplyAll, req.BaseFeeMsat, req.FeeRatePpm, req.TimeLockDelta, req.InboundEnabled, req.InboundBaseMsat, req.InboundFeeRatePpm); err != nil {
+  if err := s.lndFor(r).UpdateChannelFees(ctx, req.ChannelPoint, req.ApplyAll, req.BaseFeeMsat, req.FeeRatePpm, req.TimeLockDelta, req.InboundEnabled, req.InboundBaseMsat, req.InboundFeeRatePpm); err != nil {
     if isTimeoutError(err) {
       writeJSON(w, http.StatusOK, map[string]any{
         "ok": true,
-        "warning": "Update sent. LND is syncing; policy may already be updated.",
+        "warning": localize(w, "Update sent. LND is syncing; policy may already be updated."),
       })
       return
     }
@@ -1683,10 +1641,10 @@ func (s *Server) handleLNChannelFees(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  policy, err := s.lnd.GetChannelPolicy(ctx, channelPoint)
+  policy, err := s.lndFor(r).GetChannelPolicy(ctx, channelPoint)
   if err != nil {
     writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
     return
@@ -1771,7 +1729,7 @@ func (s *Server) handleChatSend(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutLong)
   defer cancel()
 
   msg, err := s.chat.SendMessage(ctx, peerPubkey, message)
@@ -1861,22 +1819,26 @@ func (s *Server) handleLNDConfigPost(w http.ResponseWriter, r *http.Request) {
     return
   }
 
+  unlock := lockConfigFile(lndConfPath)
   raw, err := os.ReadFile(lndConfPath)
   if err != nil {
+    unlock()
     writeError(w, http.StatusInternalServerError, "failed to read lnd.conf")
     return
   }
   updated := updateLNDConfOptions(string(raw), req.Alias, req.Color, req.MinChannelSizeSat, req.MaxChannelSizeSat)
-  if walletPasswordAvailable() {
+  if autoUnlockActive() {
     updated = ensureUnlockLines(updated)
   }
-  if err := os.WriteFile(lndConfPath, []byte(updated), 0660); err != nil {
+  err = writeFileAtomic(lndConfPath, []byte(updated), 0660)
+  unlock()
+  if err != nil {
     writeError(w, http.StatusInternalServerError, "failed to write lnd.conf")
     return
   }
 
   if req.ApplyNow {
-    ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+    ctx, cancel := s.requestContext(r, timeoutShort)
     defer cancel()
     if system.SystemctlRestart(ctx, "lnd") == nil {
       s.markLNDRestart()
@@ -1896,25 +1858,28 @@ func (s *Server) handleLNDConfigRaw(w http.ResponseWriter, r *http.Request) {
     return
   }
 
+  // Held through the restart so the rollback cannot clobber a newer edit.
+  unlock := lockConfigFile(lndConfPath)
+  defer unlock()
   prev, _ := os.ReadFile(lndConfPath)
   updated := req.RawUserConf
-  if walletPasswordAvailable() {
+  if autoUnlockActive() {
     updated = ensureUnlockLines(updated)
   }
-  if err := os.WriteFile(lndConfPath, []byte(updated), 0660); err != nil {
+  if err := writeFileAtomic(lndConfPath, []byte(updated), 0660); err != nil {
     writeError(w, http.StatusInternalServerError, "failed to write lnd.conf")
     return
   }
 
   warning := ""
   if req.ApplyNow {
-    ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+    ctx, cancel := s.requestContext(r, timeoutLong)
     defer cancel()
     if err := system.SystemctlRestart(ctx, "lnd"); err != nil {
       if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
         warning = "LND restart is taking longer than expected. Check status in a moment."
       } else {
-        _ = os.WriteFile(lndConfPath, prev, 0660)
+        _ = writeFileAtomic(lndConfPath, prev, 0660)
         writeError(w, http.StatusInternalServerError, "lnd restart failed, rollback applied")
         return
       }
@@ -1924,7 +1889,7 @@ func (s *Server) handleLNDConfigRaw(w http.ResponseWriter, r *http.Request) {
 
   resp := map[string]any{"ok": true}
   if warning != "" {
-    resp["warning"] = warning
+    resp["warning"] = localize(w, warning)
   }
   writeJSON(w, http.StatusOK, resp)
 }
@@ -2017,19 +1982,7 @@ func isHexColor(value string) bool {
 }
 
 func (s *Server) markLNDRestart() {
-  s.lndRestartMu.Lock()
-  s.lastLNDRestart = time.Now()
-  s.lndRestartMu.Unlock()
-}
-
-func (s *Server) lndWarmupActive() bool {
-  s.lndRestartMu.RLock()
-  last := s.lastLNDRestart
-  s.lndRestartMu.RUnlock()
-  if last.IsZero() {
-    return false
-  }
-  return time.Since(last) <= lndWarmupPeriod
+  s.lnd.MarkRestarting()
 }
 
 func (s *Server) handleOnchainUtxos(w http.ResponseWriter, r *http.Request) {
@@ -2066,10 +2019,10 @@ func (s *Server) handleOnchainUtxos(w http.ResponseWriter, r *http.Request) {
     }
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  items, err := s.lnd.ListOnchainUtxos(ctx, minConfs, maxConfs)
+  items, err := s.lndFor(r).ListOnchainUtxos(ctx, minConfs, maxConfs)
   if err != nil {
     writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
     return
@@ -2111,10 +2064,10 @@ func (s *Server) handleOnchainTransactions(w http.ResponseWriter, r *http.Reques
     maxConfs = minConfs
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  items, err := s.lnd.ListOnchainTransactions(ctx, 0)
+  items, err := s.lndFor(r).ListOnchainTransactions(ctx, 0)
   if err != nil {
     writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
     return
@@ -2147,12 +2100,12 @@ func (s *Server) handleOnchainTransactions(w http.ResponseWriter, r *http.Reques
 }
 
 func (s *Server) handleWalletSummary(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  balances, err := s.lnd.GetBalances(ctx)
+  balances, err := s.lndFor(r).GetBalances(ctx)
   if err != nil {
-    if isTimeoutError(err) && s.lndWarmupActive() {
+    if isTimeoutError(err) && s.lndFor(r).Lifecycle().Starting() {
       writeJSON(w, http.StatusOK, map[string]any{
         "balances": map[string]int64{
           "onchain_sat": 0,
@@ -2163,7 +2116,7 @@ func (s *Server) handleWalletSummary(w http.ResponseWriter, r *http.Request) {
           "lightning_unsettled_local_sat": 0,
         },
         "activity": []any{},
-        "warning": "LND warming up after restart",
+        "warning": localize(w, "LND warming up after restart"),
       })
       return
     }
@@ -2171,9 +2124,9 @@ func (s *Server) handleWalletSummary(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  lightningActivity, _ := s.lnd.ListRecent(ctx, walletActivityFetchLimit)
+  lightningActivity, _ := s.lndFor(r).ListRecent(ctx, walletActivityFetchLimit)
 
-  onchainActivity, _ := s.lnd.ListOnchain(ctx, walletActivityFetchLimit)
+  onchainActivity, _ := s.lndFor(r).ListOnchain(ctx, walletActivityFetchLimit)
 
   activity := append(lightningActivity, onchainActivity...)
 
@@ -2195,10 +2148,10 @@ func (s *Server) handleWalletSummary(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleWalletAddress(w http.ResponseWriter, r *http.Request) {
-  ctx, cancel := context.WithTimeout(r.Context(), lndRPCTimeout)
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
   defer cancel()
 
-  addr, err := s.lnd.NewAddress(ctx)
+  addr, err := s.lndFor(r).NewAddress(ctx)
   if err != nil {
     writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
     return
@@ -2224,10 +2177,10 @@ func (s *Server) handleWalletInvoice(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
-  invoice, err := s.lnd.CreateInvoice(ctx, req.AmountSat, req.Memo, 3600)
+  invoice, err := s.lndFor(r).CreateInvoice(ctx, req.AmountSat, req.Memo, 3600)
   if err != nil {
     writeError(w, http.StatusInternalServerError, "invoice failed")
     return
@@ -2254,10 +2207,10 @@ func (s *Server) handleWalletDecode(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
-  decoded, err := s.lnd.DecodeInvoice(ctx, paymentRequest)
+  decoded, err := s.lndFor(r).DecodeInvoice(ctx, paymentRequest)
   if err != nil {
     msg := err.Error()
     lower := strings.ToLower(msg)
@@ -2300,11 +2253,19 @@ func (s *Server) handleWalletPay(w http.ResponseWriter, r *http.Request) {
   if strings.HasPrefix(strings.ToLower(cleaned), "lightning:") {
     cleaned = cleaned[len("lightning:"):]
   }
-  if isLightningAddress(cleaned) {
+  automated := isAutomatedRequest(r)
+  viaAddress := isLightningAddress(cleaned)
+  if viaAddress {
     if req.AmountSat <= 0 {
       writeError(w, http.StatusBadRequest, "amount_sat must be positive for lightning address")
       return
     }
+    if automated {
+      if err := checkAutomatedDestination(allowKindLightningAddress, cleaned); err != nil {
+        writeError(w, http.StatusForbidden, err.Error())
+        return
+      }
+    }
     resolved, err := resolveLightningAddress(r.Context(), cleaned, req.AmountSat, req.Comment)
     if err != nil {
       writeError(w, http.StatusBadRequest, fmt.Sprintf("lightning address error: %v", err))
@@ -2315,13 +2276,13 @@ func (s *Server) handleWalletPay(w http.ResponseWriter, r *http.Request) {
     paymentRequest = cleaned
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutLNDWrite)
   defer cancel()
 
   outgoingChanID := uint64(0)
   selectedPoint := strings.ToLower(strings.TrimSpace(req.ChannelPoint))
   if selectedPoint != "" {
-    channels, err := s.lnd.ListChannels(ctx)
+    channels, err := s.lndFor(r).ListChannels(ctx)
     if err != nil {
       writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
       return
@@ -2339,11 +2300,23 @@ func (s *Server) handleWalletPay(w http.ResponseWriter, r *http.Request) {
   }
 
   paymentHash := ""
-  if decoded, err := s.lnd.DecodeInvoice(ctx, paymentRequest); err == nil {
+  decoded, decodeErr := s.lndFor(r).DecodeInvoice(ctx, paymentRequest)
+  if decodeErr == nil {
     paymentHash = decoded.PaymentHash
   }
+  // Automation may only pay invoices of allowed nodes.
+  if automated && !viaAddress {
+    if decodeErr != nil {
+      writeError(w, http.StatusBadRequest, "Invalid invoice")
+      return
+    }
+    if err := checkAutomatedDestination(allowKindNode, decoded.Destination); err != nil {
+      writeError(w, http.StatusForbidden, err.Error())
+      return
+    }
+  }
 
-  if err := s.lnd.PayInvoice(ctx, paymentRequest, outgoingChanID); err != nil {
+  if err := s.lndFor(r).PayInvoice(ctx, paymentRequest, outgoingChanID); err != nil {
     if paymentHash != "" {
       s.recordWalletActivity(paymentHash)
     }
@@ -2371,6 +2344,7 @@ func (s *Server) handleWalletSend(w http.ResponseWriter, r *http.Request) {
     AmountSat int64 `json:"amount_sat"`
     SatPerVbyte int64 `json:"sat_per_vbyte"`
     SweepAll bool `json:"sweep_all"`
+    AllowHighFee bool `json:"allow_high_fee"`
   }
   if err := readJSON(r, &req); err != nil {
     writeError(w, http.StatusBadRequest, "invalid json")
@@ -2388,11 +2362,20 @@ func (s *Server) handleWalletSend(w http.ResponseWriter, r *http.Request) {
   if req.SweepAll {
     req.AmountSat = 0
   }
+  if isAutomatedRequest(r) {
+    if err := checkAutomatedDestination(allowKindOnchain, address); err != nil {
+      writeError(w, http.StatusForbidden, err.Error())
+      return
+    }
+  }
+  if !s.guardFeeRate(w, r, feeGuardSend, req.SatPerVbyte, req.AllowHighFee) {
+    return
+  }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutLNDWrite)
   defer cancel()
 
-  txid, err := s.lnd.SendCoins(ctx, address, req.AmountSat, req.SatPerVbyte, req.SweepAll)
+  txid, err := s.lndFor(r).SendCoins(ctx, address, req.AmountSat, req.SatPerVbyte, req.SweepAll)
   if err != nil {
     msg := lndRPCErrorMessage(err)
     if isTimeoutError(err) {
@@ -2502,7 +2485,7 @@ func doBitcoinRPC(ctx context.Context, url, user, pass, method string) ([]byte,
   req.SetBasicAuth(user, pass)
   req.Header.Set("Content-Type", "application/json")
 
-  resp, err := http.DefaultClient.Do(req)
+  resp, err := bitcoinRPCClient(url).Do(req)
   if err != nil {
     return nil, err
   }
@@ -2524,6 +2507,9 @@ func fetchBitcoinRPC(ctx context.Context, host, user, pass, method string) ([]by
   if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
     return doBitcoinRPC(ctx, host, user, pass, method)
   }
+  if bitcoinRPCPinned(host) {
+    return doBitcoinRPC(ctx, "https://"+host, user, pass, method)
+  }
 
   body, err := doBitcoinRPC(ctx, "http://"+host, user, pass, method)
   if err == nil {
@@ -2576,6 +2562,8 @@ func storeBitcoinSecrets(user, pass string) error {
   pass = strings.TrimSpace(pass)
   _ = os.Setenv("BITCOIN_RPC_USER", user)
   _ = os.Setenv("BITCOIN_RPC_PASS", pass)
+  unlock := lockConfigFile(secretsPath)
+  defer unlock()
   content, _ := os.ReadFile(secretsPath)
   lines := []string{}
   if len(content) > 0 {
@@ -2605,7 +2593,7 @@ func storeBitcoinSecrets(user, pass string) error {
   if err := os.MkdirAll(filepath.Dir(secretsPath), 0750); err != nil {
     return err
   }
-  return os.WriteFile(secretsPath, []byte(strings.Join(lines, "\n")), 0660)
+  return writeFileAtomic(secretsPath, []byte(strings.Join(lines, "\n")), 0660)
 }
 
 func readBitcoinSource() string {
@@ -2751,6 +2739,8 @@ func storeBitcoinSource(source string) error {
     return errors.New("invalid bitcoin source")
   }
   _ = os.Setenv("BITCOIN_SOURCE", trimmed)
+  unlock := lockConfigFile(secretsPath)
+  defer unlock()
   content, _ := os.ReadFile(secretsPath)
   lines := []string{}
   if len(content) > 0 {
@@ -2769,7 +2759,7 @@ func storeBitcoinSource(source string) error {
   if err := os.MkdirAll(filepath.Dir(secretsPath), 0750); err != nil {
     return err
   }
-  return os.WriteFile(secretsPath, []byte(strings.Join(lines, "\n")), 0660)
+  return writeFileAtomic(secretsPath, []byte(strings.Join(lines, "\n")), 0660)
 }
 
 func normalizeLocalZMQ(value string, fallback string) string {
@@ -2793,9 +2783,8 @@ func dockerContainerGateways(ctx context.Context, containerID string) []string {
   if containerID == "" {
     return []string{}
   }
-  out, err := system.RunCommandWithSudo(
+  out, err := containerCLI(
     ctx,
-    "docker",
     "inspect",
     "-f",
     "{{range $k,$v := .NetworkSettings.Networks}}{{println $v.Gateway}}{{end}}",
@@ -2828,7 +2817,7 @@ func updateLNDConfRPC(ctx context.Context, user, pass, host, zmqBlock, zmqTx str
   localCfg, _, err := readBitcoinLocalRPCConfig(ctx)
   if err != nil {
     localCfg = bitcoinRPCConfig{
-      Host: "127.0.0.1:8332",
+      Host: localBitcoinRPCHost(),
       ZMQBlock: "tcp://127.0.0.1:28332",
       ZMQTx: "tcp://127.0.0.1:28333",
     }
@@ -2837,6 +2826,8 @@ func updateLNDConfRPC(ctx context.Context, user, pass, host, zmqBlock, zmqTx str
 }
 
 func updateLNDConfBitcoinSource(active string, remoteCfg bitcoinRPCConfig, localCfg bitcoinRPCConfig) error {
+  unlock := lockConfigFile(lndConfPath)
+  defer unlock()
   content, err := os.ReadFile(lndConfPath)
   if err != nil {
     return err
@@ -2960,18 +2951,7 @@ func updateLNDConfBitcoinSource(active string, remoteCfg bitcoinRPCConfig, local
     lines = append(lines[:end], append(block, lines[end:]...)...)
   }
 
-  return os.WriteFile(lndConfPath, []byte(strings.Join(lines, "\n")), 0660)
-}
-
-func storeWalletUnlock(password string) error {
-  trimmed := strings.TrimSpace(password)
-  if trimmed == "" {
-    return errors.New("wallet password required")
-  }
-  if err := storeWalletPassword(trimmed); err != nil {
-    return err
-  }
-  return ensureWalletUnlockConfig()
+  return writeFileAtomic(lndConfPath, []byte(strings.Join(lines, "\n")), 0660)
 }
 
 func (s *Server) scheduleLNDPermissionsFix(reason string) {
@@ -2980,7 +2960,7 @@ func (s *Server) scheduleLNDPermissionsFix(reason string) {
   }
   go func() {
     waitCtx, waitCancel := context.WithTimeout(context.Background(), 12*time.Second)
-    waitForFile(waitCtx, lndAdminMacaroonPath)
+    waitForFile(waitCtx, lndAdminMacaroonPath())
     waitCancel()
     runCtx, runCancel := context.WithTimeout(context.Background(), 6*time.Second)
     defer runCancel()
@@ -3005,86 +2985,3 @@ func waitForFile(ctx context.Context, path string) {
   }
 }
 
-func storeWalletPassword(password string) error {
-  if _, err := os.Stat(lndPasswordPath); err != nil {
-    if os.IsNotExist(err) {
-      return fmt.Errorf("password file missing: %s", lndPasswordPath)
-    }
-    return err
-  }
-  return os.WriteFile(lndPasswordPath, []byte(password), 0660)
-}
-
-func walletPasswordAvailable() bool {
-  info, err := os.Stat(lndPasswordPath)
-  if err != nil || info.Size() == 0 {
-    return false
-  }
-  content, err := os.ReadFile(lndPasswordPath)
-  if err != nil {
-    return false
-  }
-  return strings.TrimSpace(string(content)) != ""
-}
-
-func ensureWalletUnlockConfig() error {
-  if err := os.MkdirAll(filepath.Dir(lndConfPath), 0750); err != nil {
-    return err
-  }
-  raw, _ := os.ReadFile(lndConfPath)
-  updated := ensureUnlockLines(string(raw))
-  return os.WriteFile(lndConfPath, []byte(updated), 0660)
-}
-
-func ensureUnlockLines(raw string) string {
-  lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
-  start := -1
-  end := len(lines)
-
-  for i, line := range lines {
-    trimmed := strings.TrimSpace(line)
-    if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
-      if strings.EqualFold(trimmed, "[Application Options]") {
-        start = i
-        continue
-      }
-      if start != -1 && i > start {
-        end = i
-        break
-      }
-    }
-  }
-
-  if start == -1 {
-    lines = append(lines, "[Application Options]")
-    start = len(lines) - 1
-    end = len(lines)
-  }
-
-  hasPass := false
-  hasAllow := false
-  for i := start + 1; i < end; i++ {
-    trimmed := strings.TrimSpace(lines[i])
-    if strings.HasPrefix(trimmed, "wallet-unlock-password-file=") {
-      lines[i] = "wallet-unlock-password-file=" + lndPasswordPath
-      hasPass = true
-    }
-    if strings.HasPrefix(trimmed, "wallet-unlock-allow-create=") {
-      lines[i] = "wallet-unlock-allow-create=true"
-      hasAllow = true
-    }
-  }
-
-  extra := []string{}
-  if !hasPass {
-    extra = append(extra, "wallet-unlock-password-file="+lndPasswordPath)
-  }
-  if !hasAllow {
-    extra = append(extra, "wallet-unlock-allow-create=true")
-  }
-  if len(extra) > 0 {
-    lines = append(lines[:end], append(extra, lines[end:]...)...)
-  }
-
-  return strings.Join(lines, "\n")
-}
@@ -0,0 +1,92 @@
+package server
+
+import (
+  "time"
+)
+
+// healthGrace is how long past the budget the report waits for checks to
+// notice their cancelled context before giving up on them.
+const healthGrace = 250 * time.Millisecond
+
+type healthCheck struct {
+  Component string `json:"component"`
+  Status string `json:"status"`
+  DurationMs int64 `json:"duration_ms"`
+  TimedOut bool `json:"timed_out,omitempty"`
+}
+
+type healthCheckRun struct {
+  component string
+  run func() []healthIssue
+}
+
+type healthCheckResult struct {
+  component string
+  issues []healthIssue
+  elapsed time.Duration
+  timedOut bool
+}
+
+func (r healthCheckResult) view() healthCheck {
+  status := "OK"
+  for _, issue := range r.issues {
+    status = elevate(status, issue.Level)
+  }
+  return healthCheck{
+    Component: r.component,
+    Status: status,
+    DurationMs: r.elapsed.Milliseconds(),
+    TimedOut: r.timedOut,
+  }
+}
+
+// runHealthChecks runs the checks at once and returns their results in the
+// order given. A check still running at giveUp is reported as a WARN: a
+// slow answer is not an outage, and uptime tracking only counts errors.
+func runHealthChecks(checks []healthCheckRun, giveUp time.Time) []healthCheckResult {
+  start := time.Now()
+  type done struct {
+    index int
+    issues []healthIssue
+    elapsed time.Duration
+  }
+  // Buffered so checks that finish after giveUp do not block forever.
+  finished := make(chan done, len(checks))
+  for i, check := range checks {
+    i, check := i, check
+    go func() {
+      var issues []healthIssue
+      if runRecovered("health_"+check.component, func() { issues = check.run() }) {
+        issues = []healthIssue{{Component: check.component, Level: "WARN", Message: "Health check failed: " + check.component}}
+      }
+      finished <- done{index: i, issues: issues, elapsed: time.Since(start)}
+    }()
+  }
+
+  results := make([]healthCheckResult, len(checks))
+  pending := len(checks)
+  timer := time.NewTimer(time.Until(giveUp))
+  defer timer.Stop()
+  for pending > 0 {
+    select {
+    case d := <-finished:
+      results[d.index] = healthCheckResult{component: checks[d.index].component, issues: d.issues, elapsed: d.elapsed}
+      pending--
+    case <-timer.C:
+      elapsed := time.Since(start)
+      for i, check := range checks {
+        if results[i].component != "" {
+          continue
+        }
+        results[i] = healthCheckResult{
+          component: check.component,
+          issues: []healthIssue{{Component: check.component, Level: "WARN", Message: "Health check timed out: " + check.component}},
+          elapsed: elapsed,
+          timedOut: true,
+        }
+      }
+      return results
+    }
+  }
+  return results
+}
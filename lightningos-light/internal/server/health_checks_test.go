@@ -0,0 +1,56 @@
+package server
+
+import (
+  "testing"
+  "time"
+)
+
+func TestRunHealthChecks(t *testing.T) {
+  release := make(chan struct{})
+  defer close(release)
+  checks := []healthCheckRun{
+    {component: "lnd", run: func() []healthIssue {
+      <-release
+      return nil
+    }},
+    {component: "bitcoin", run: func() []healthIssue {
+      time.Sleep(20 * time.Millisecond)
+      return []healthIssue{{Component: "bitcoin", Level: "ERR", Message: "Bitcoin RPC unreachable"}}
+    }},
+    {component: "postgres", run: func() []healthIssue { return nil }},
+  }
+  start := time.Now()
+  results := runHealthChecks(checks, start.Add(200*time.Millisecond))
+  if elapsed := time.Since(start); elapsed > time.Second {
+    t.Fatalf("checks took %s, want them bounded by the budget", elapsed)
+  }
+  if len(results) != 3 {
+    t.Fatalf("got %d results, want 3", len(results))
+  }
+  views := make([]healthCheck, len(results))
+  for i, result := range results {
+    views[i] = result.view()
+  }
+  if views[0].Component != "lnd" || !views[0].TimedOut || views[0].Status != "WARN" {
+    t.Fatalf("lnd = %+v, want a timed out WARN", views[0])
+  }
+  if len(results[0].issues) != 1 || results[0].issues[0].Message != "Health check timed out: lnd" {
+    t.Fatalf("lnd issues = %+v", results[0].issues)
+  }
+  if views[1].Component != "bitcoin" || views[1].TimedOut || views[1].Status != "ERR" || views[1].DurationMs < 20 {
+    t.Fatalf("bitcoin = %+v, want ERR after at least 20ms", views[1])
+  }
+  if views[2].Component != "postgres" || views[2].Status != "OK" {
+    t.Fatalf("postgres = %+v, want OK", views[2])
+  }
+}
+
+func TestRunHealthChecksPanic(t *testing.T) {
+  checks := []healthCheckRun{
+    {component: "postgres", run: func() []healthIssue { panic("boom") }},
+  }
+  results := runHealthChecks(checks, time.Now().Add(time.Second))
+  if results[0].timedOut || results[0].view().Status != "WARN" {
+    t.Fatalf("result = %+v, want a WARN from the recovered panic", results[0])
+  }
+}
@@ -28,5 +28,5 @@ func normalizePaymentRequest(value string) string {
 }
 
 func writeError(w http.ResponseWriter, status int, message string) {
-  writeJSON(w, status, map[string]string{"error": message})
+  writeJSON(w, status, map[string]string{"error": localize(w, message)})
 }
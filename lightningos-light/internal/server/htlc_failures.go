@@ -0,0 +1,363 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/status"
+)
+
+const (
+  htlcFailuresDefaultHours = 7 * 24
+  htlcFailuresMaxHours = 90 * 24
+  // htlcForwardsTracked bounds the forwards remembered to price a later
+  // downstream failure; LND only reports the amount on the forward itself.
+  htlcForwardsTracked = 10000
+  htlcChannelsRefresh = time.Minute
+  htlcUnimplementedRetry = 10 * time.Minute
+)
+
+// htlcFailureDownstream is the reason of a forward that left our node and
+// failed further along the route; LND does not say why.
+const htlcFailureDownstream = "downstream"
+
+type htlcForwardKey struct {
+  chanID uint64
+  htlcID uint64
+}
+
+// htlcFailureRecorder turns the HTLC event stream into htlc_failures rows,
+// naming both peers of each failed forward.
+type htlcFailureRecorder struct {
+  n *Notifier
+  mu sync.Mutex
+  forwards map[htlcForwardKey]uint64
+  peers map[uint64]string
+  peersAt time.Time
+}
+
+func (n *Notifier) ensureHTLCFailuresSchema(ctx context.Context) error {
+  _, err := n.db.Exec(ctx, `
+create table if not exists htlc_failures (
+  id bigserial primary key,
+  node_id text not null default 'default',
+  occurred_at timestamptz not null,
+  in_chan_id bigint not null,
+  out_chan_id bigint not null,
+  in_peer text not null default '',
+  out_peer text not null default '',
+  reason text not null,
+  amount_msat bigint not null default 0
+);
+create index if not exists htlc_failures_node_occurred_idx on htlc_failures (node_id, occurred_at desc);
+`)
+  return err
+}
+
+func (n *Notifier) runHTLCFailures() {
+  watch := n.watchdog.watch("htlc_failures", streamMaxQuiet)
+  recorder := &htlcFailureRecorder{n: n, forwards: map[htlcForwardKey]uint64{}}
+  for {
+    select {
+    case <-n.stop:
+      return
+    default:
+    }
+    if !n.waitLNDReady() {
+      return
+    }
+
+    streamCtx, done := watch.begin(n.context())
+    conn, err := n.lnd.DialLightning(streamCtx)
+    if err != nil {
+      n.logger.Printf("notifications: htlc stream dial failed: %v", err)
+      watch.fail(err)
+      done()
+      time.Sleep(5 * time.Second)
+      continue
+    }
+
+    stream, err := lndclient.SubscribeHTLCEvents(streamCtx, conn)
+    if err != nil {
+      n.logger.Printf("notifications: htlc stream subscribe failed: %v", err)
+      watch.fail(err)
+      conn.Close()
+      done()
+      time.Sleep(5 * time.Second)
+      continue
+    }
+    watch.beat()
+
+    retry := 2 * time.Second
+    for {
+      evt, err := stream.Recv()
+      if err != nil {
+        n.logger.Printf("notifications: htlc stream ended: %v", err)
+        watch.fail(err)
+        _ = conn.Close()
+        done()
+        // A backend without the Router service will not grow one soon.
+        if status.Code(err) == codes.Unimplemented {
+          retry = htlcUnimplementedRetry
+        }
+        break
+      }
+      watch.beat()
+      recorder.handle(evt)
+    }
+
+    select {
+    case <-n.stop:
+      return
+    case <-time.After(retry):
+    }
+  }
+}
+
+func (h *htlcFailureRecorder) handle(evt lndclient.HTLCEvent) {
+  if evt.EventType != lndclient.HTLCEventForward {
+    return
+  }
+  key := htlcForwardKey{chanID: evt.IncomingChannelID, htlcID: evt.IncomingHTLCID}
+  var (
+    reason string
+    amountMsat uint64
+  )
+  h.mu.Lock()
+  switch evt.Kind {
+  case lndclient.HTLCForward:
+    if len(h.forwards) >= htlcForwardsTracked {
+      h.forwards = map[htlcForwardKey]uint64{}
+    }
+    h.forwards[key] = evt.OutgoingAmtMsat
+  case lndclient.HTLCSettle:
+    delete(h.forwards, key)
+  case lndclient.HTLCForwardFail:
+    reason, amountMsat = htlcFailureDownstream, h.forwards[key]
+    delete(h.forwards, key)
+  case lndclient.HTLCLinkFail:
+    reason, amountMsat = evt.FailureReason, evt.OutgoingAmtMsat
+    if amountMsat == 0 {
+      amountMsat = evt.IncomingAmtMsat
+    }
+    delete(h.forwards, key)
+  }
+  h.mu.Unlock()
+  if reason == "" {
+    return
+  }
+
+  occurredAt := time.Now().UTC()
+  if evt.TimestampNs > 0 {
+    occurredAt = time.Unix(0, int64(evt.TimestampNs)).UTC()
+  }
+  inPeer, outPeer := h.peerFor(evt.IncomingChannelID), h.peerFor(evt.OutgoingChannelID)
+  ctx, cancel := context.WithTimeout(h.n.context(), 5*time.Second)
+  defer cancel()
+  _, err := h.n.db.Exec(ctx, `
+insert into htlc_failures (node_id, occurred_at, in_chan_id, out_chan_id, in_peer, out_peer, reason, amount_msat)
+values ($1, $2, $3, $4, $5, $6, $7, $8)
+`, h.n.nodeKey(), occurredAt, int64(evt.IncomingChannelID), int64(evt.OutgoingChannelID), inPeer, outPeer, reason, int64(amountMsat))
+  if err != nil {
+    h.n.logger.Printf("notifications: failed to store htlc failure: %v", err)
+  }
+}
+
+// peerFor maps a channel id to the remote pubkey, reloading the channel list
+// at most once a minute when an id is unknown. Closed channels stay blank.
+func (h *htlcFailureRecorder) peerFor(chanID uint64) string {
+  if chanID == 0 {
+    return ""
+  }
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  if peer, ok := h.peers[chanID]; ok {
+    return peer
+  }
+  if time.Since(h.peersAt) < htlcChannelsRefresh {
+    return ""
+  }
+  h.peersAt = time.Now()
+  ctx, cancel := context.WithTimeout(h.n.context(), 6*time.Second)
+  defer cancel()
+  channels, err := h.n.lnd.ListChannels(ctx)
+  if err != nil {
+    return ""
+  }
+  h.peers = map[uint64]string{}
+  for _, ch := range channels {
+    h.peers[ch.ChannelID] = ch.RemotePubkey
+  }
+  return h.peers[chanID]
+}
+
+type forwardFailureCell struct {
+  InPeer string `json:"in_peer"`
+  OutPeer string `json:"out_peer"`
+  Failures int64 `json:"failures"`
+  AmountSat int64 `json:"amount_sat"`
+  Reasons map[string]int64 `json:"reasons"`
+}
+
+type forwardFailurePeer struct {
+  Pubkey string `json:"pubkey"`
+  Alias string `json:"alias,omitempty"`
+  InFailures int64 `json:"in_failures"`
+  OutFailures int64 `json:"out_failures"`
+}
+
+type forwardFailureMatrix struct {
+  Since time.Time `json:"since"`
+  Hours int `json:"hours"`
+  TotalFailures int64 `json:"total_failures"`
+  TotalAmountSat int64 `json:"total_amount_sat"`
+  Reasons map[string]int64 `json:"reasons"`
+  Peers []forwardFailurePeer `json:"peers"`
+  Cells []forwardFailureCell `json:"cells"`
+}
+
+type forwardFailureRow struct {
+  InPeer string
+  OutPeer string
+  Reason string
+  Failures int64
+  AmountMsat int64
+}
+
+// buildForwardFailureMatrix folds (in peer, out peer, reason) counts into a
+// sparse matrix, busiest corridors first. A blank peer is a closed channel.
+func buildForwardFailureMatrix(rows []forwardFailureRow) ([]forwardFailureCell, []forwardFailurePeer, map[string]int64) {
+  cells := map[[2]string]*forwardFailureCell{}
+  peers := map[string]*forwardFailurePeer{}
+  reasons := map[string]int64{}
+  peer := func(pubkey string) *forwardFailurePeer {
+    if p := peers[pubkey]; p != nil {
+      return p
+    }
+    p := &forwardFailurePeer{Pubkey: pubkey}
+    peers[pubkey] = p
+    return p
+  }
+  for _, row := range rows {
+    key := [2]string{row.InPeer, row.OutPeer}
+    cell := cells[key]
+    if cell == nil {
+      cell = &forwardFailureCell{InPeer: row.InPeer, OutPeer: row.OutPeer, Reasons: map[string]int64{}}
+      cells[key] = cell
+    }
+    cell.Failures += row.Failures
+    cell.AmountSat += row.AmountMsat / 1000
+    cell.Reasons[row.Reason] += row.Failures
+    reasons[row.Reason] += row.Failures
+    peer(row.InPeer).InFailures += row.Failures
+    peer(row.OutPeer).OutFailures += row.Failures
+  }
+
+  outCells := make([]forwardFailureCell, 0, len(cells))
+  for _, cell := range cells {
+    outCells = append(outCells, *cell)
+  }
+  sort.Slice(outCells, func(i, j int) bool {
+    if outCells[i].Failures != outCells[j].Failures {
+      return outCells[i].Failures > outCells[j].Failures
+    }
+    if outCells[i].InPeer != outCells[j].InPeer {
+      return outCells[i].InPeer < outCells[j].InPeer
+    }
+    return outCells[i].OutPeer < outCells[j].OutPeer
+  })
+  outPeers := make([]forwardFailurePeer, 0, len(peers))
+  for _, p := range peers {
+    outPeers = append(outPeers, *p)
+  }
+  sort.Slice(outPeers, func(i, j int) bool {
+    ti, tj := outPeers[i].InFailures+outPeers[i].OutFailures, outPeers[j].InFailures+outPeers[j].OutFailures
+    if ti != tj {
+      return ti > tj
+    }
+    return outPeers[i].Pubkey < outPeers[j].Pubkey
+  })
+  return outCells, outPeers, reasons
+}
+
+func (s *Server) handleForwardFailures(w http.ResponseWriter, r *http.Request) {
+  notifier := s.notifierFor(r)
+  if notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return
+  }
+  hours := htlcFailuresDefaultHours
+  if raw := strings.TrimSpace(r.URL.Query().Get("hours")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed <= 0 || parsed > htlcFailuresMaxHours {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("hours out of range: 1 to %d", htlcFailuresMaxHours))
+      return
+    }
+    hours = parsed
+  }
+  since := time.Now().UTC().Add(-time.Duration(hours) * time.Hour)
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  dbRows, err := notifier.db.Query(ctx, `
+select in_peer, out_peer, reason, count(*), coalesce(sum(amount_msat), 0)
+from htlc_failures
+where node_id = $1 and occurred_at >= $2
+group by in_peer, out_peer, reason
+`, notifier.nodeKey(), since)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load forward failures")
+    return
+  }
+  defer dbRows.Close()
+  rows := []forwardFailureRow{}
+  for dbRows.Next() {
+    var row forwardFailureRow
+    if err := dbRows.Scan(&row.InPeer, &row.OutPeer, &row.Reason, &row.Failures, &row.AmountMsat); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to load forward failures")
+      return
+    }
+    rows = append(rows, row)
+  }
+  if err := dbRows.Err(); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load forward failures")
+    return
+  }
+
+  // A tag keeps the corridors with a tagged peer on either side.
+  tag, tagged := peerTagFilter(r, currentPeerMetadata())
+  if tag != "" {
+    kept := rows[:0]
+    for _, row := range rows {
+      if tagged(row.InPeer) || tagged(row.OutPeer) {
+        kept = append(kept, row)
+      }
+    }
+    rows = kept
+  }
+  cells, peers, reasons := buildForwardFailureMatrix(rows)
+  aliases := map[string]string{}
+  if channels, err := s.lndFor(r).ListChannels(ctx); err == nil {
+    for _, ch := range channels {
+      aliases[ch.RemotePubkey] = ch.PeerAlias
+    }
+  }
+  result := forwardFailureMatrix{Since: since, Hours: hours, Reasons: reasons, Peers: peers, Cells: cells}
+  for i := range result.Peers {
+    result.Peers[i].Alias = aliases[result.Peers[i].Pubkey]
+  }
+  for _, cell := range cells {
+    result.TotalFailures += cell.Failures
+    result.TotalAmountSat += cell.AmountSat
+  }
+  writeJSON(w, http.StatusOK, result)
+}
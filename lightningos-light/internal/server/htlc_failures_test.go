@@ -0,0 +1,42 @@
+package server
+
+import (
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestBuildForwardFailureMatrix(t *testing.T) {
+  cells, peers, reasons := buildForwardFailureMatrix([]forwardFailureRow{
+    {InPeer: "02aa", OutPeer: "02bb", Reason: "insufficient_balance", Failures: 5, AmountMsat: 5_000_000},
+    {InPeer: "02aa", OutPeer: "02bb", Reason: htlcFailureDownstream, Failures: 2, AmountMsat: 1_000_000},
+    {InPeer: "02cc", OutPeer: "02aa", Reason: "fee_insufficient", Failures: 1, AmountMsat: 10_000},
+  })
+  if len(cells) != 2 || cells[0].InPeer != "02aa" || cells[0].OutPeer != "02bb" || cells[0].Failures != 7 || cells[0].AmountSat != 6000 {
+    t.Fatalf("unexpected cells: %+v", cells)
+  }
+  if cells[0].Reasons["insufficient_balance"] != 5 || cells[0].Reasons[htlcFailureDownstream] != 2 {
+    t.Fatalf("unexpected cell reasons: %+v", cells[0].Reasons)
+  }
+  if len(peers) != 3 || peers[0].Pubkey != "02aa" || peers[0].InFailures != 7 || peers[0].OutFailures != 1 {
+    t.Fatalf("unexpected peers: %+v", peers)
+  }
+  if reasons["fee_insufficient"] != 1 || reasons["insufficient_balance"] != 5 {
+    t.Fatalf("unexpected reasons: %+v", reasons)
+  }
+}
+
+func TestHTLCFailureRecorderIgnoresOtherEvents(t *testing.T) {
+  // Without a database handle any insert would panic, so these must all be
+  // filtered before reaching it.
+  h := &htlcFailureRecorder{n: &Notifier{}, forwards: map[htlcForwardKey]uint64{}}
+  h.handle(lndclient.HTLCEvent{EventType: lndclient.HTLCEventReceive, Kind: lndclient.HTLCLinkFail, FailureReason: "unknown_invoice"})
+  h.handle(lndclient.HTLCEvent{EventType: lndclient.HTLCEventForward, Kind: lndclient.HTLCForward, IncomingChannelID: 1, IncomingHTLCID: 9, OutgoingAmtMsat: 1000})
+  if h.forwards[htlcForwardKey{chanID: 1, htlcID: 9}] != 1000 {
+    t.Fatalf("forward not remembered: %+v", h.forwards)
+  }
+  h.handle(lndclient.HTLCEvent{EventType: lndclient.HTLCEventForward, Kind: lndclient.HTLCSettle, IncomingChannelID: 1, IncomingHTLCID: 9})
+  if len(h.forwards) != 0 {
+    t.Fatalf("settled forward still tracked: %+v", h.forwards)
+  }
+}
@@ -0,0 +1,519 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/lnrpc"
+)
+
+// An inbound experiment tests whether a negative inbound fee brings flow
+// back into drained channels. The drained channels are split in two
+// alternating groups: the treatment gets the discount for the trial window,
+// the control keeps its policy. Each group's inbound flow (forwards that
+// arrived through it) during the trial is compared with the same length of
+// time before it, and the treatment's change minus the control's is the
+// lift. Only the primary node runs experiments; the running one lives in
+// inboundExperimentsPath with the policies to restore, so a restart picks
+// it up.
+const (
+  inboundExperimentsPath = "/var/lib/lightningos/inbound-experiments.json"
+  inboundExperimentsPoll = 15 * time.Minute
+  inboundExperimentsHistory = 20
+  inboundForwardsPageSize = 10000
+  // inboundRestorePending marks a finished trial whose treatment fees could
+  // not all be put back; it stays running and is retried every poll.
+  inboundRestorePending = "restore_pending"
+)
+
+var inboundExperimentsMu sync.Mutex
+
+var (
+  errInboundExperimentRunning = errors.New("an inbound experiment is already running")
+  errInboundExperimentIdle = errors.New("no inbound experiment is running")
+  errInboundExperimentChannels = errors.New("an experiment needs at least two drained channels")
+  errInboundExperimentRestore = errors.New("failed to restore the inbound fees")
+)
+
+type inboundFlow struct {
+  Forwards int64 `json:"forwards"`
+  InboundSat int64 `json:"inbound_sat"`
+  FeeMsat int64 `json:"fee_msat"`
+}
+
+func (f *inboundFlow) add(other inboundFlow) {
+  f.Forwards += other.Forwards
+  f.InboundSat += other.InboundSat
+  f.FeeMsat += other.FeeMsat
+}
+
+type inboundExperimentChannel struct {
+  ChannelPoint string `json:"channel_point"`
+  ChannelID uint64 `json:"channel_id"`
+  PeerAlias string `json:"peer_alias,omitempty"`
+  Group string `json:"group"`
+  LocalShare float64 `json:"local_share"`
+  // The inbound fee before the trial, put back when it ends.
+  InboundBaseMsat int64 `json:"inbound_base_msat"`
+  InboundFeeRatePpm int64 `json:"inbound_fee_rate_ppm"`
+  Baseline inboundFlow `json:"baseline"`
+  Trial *inboundFlow `json:"trial,omitempty"`
+  // Restored is set once the treatment fee is back (or the channel closed).
+  Restored bool `json:"restored,omitempty"`
+}
+
+type inboundGroupResult struct {
+  Channels int `json:"channels"`
+  Baseline inboundFlow `json:"baseline"`
+  Trial inboundFlow `json:"trial"`
+  // ChangePct compares inbound sats per day; nil without a baseline.
+  ChangePct *float64 `json:"change_pct,omitempty"`
+}
+
+type inboundExperimentResult struct {
+  Treatment inboundGroupResult `json:"treatment"`
+  Control inboundGroupResult `json:"control"`
+  LiftPct *float64 `json:"lift_pct,omitempty"`
+}
+
+type inboundExperiment struct {
+  ID string `json:"id"`
+  Trigger string `json:"trigger"`
+  StartedAt time.Time `json:"started_at"`
+  EndsAt time.Time `json:"ends_at"`
+  EndedAt *time.Time `json:"ended_at,omitempty"`
+  Stopped bool `json:"stopped,omitempty"`
+  DiscountPpm int64 `json:"discount_ppm"`
+  // BaselineSec is the length of the window before the start the trial is
+  // compared with.
+  BaselineSec int64 `json:"baseline_sec"`
+  Channels []inboundExperimentChannel `json:"channels"`
+  Result *inboundExperimentResult `json:"result,omitempty"`
+  Error string `json:"error,omitempty"`
+  // State is inboundRestorePending while fees are left to restore.
+  State string `json:"state,omitempty"`
+  RestoreError string `json:"restore_error,omitempty"`
+}
+
+type inboundExperimentsState struct {
+  Running *inboundExperiment `json:"running,omitempty"`
+  History []inboundExperiment `json:"history"`
+}
+
+func readInboundExperiments() inboundExperimentsState {
+  state := inboundExperimentsState{}
+  raw, err := os.ReadFile(inboundExperimentsPath)
+  if err == nil {
+    _ = json.Unmarshal(raw, &state)
+  }
+  if state.History == nil {
+    state.History = []inboundExperiment{}
+  }
+  return state
+}
+
+func writeInboundExperiments(state inboundExperimentsState) error {
+  if err := os.MkdirAll(filepath.Dir(inboundExperimentsPath), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.Marshal(state)
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(inboundExperimentsPath, raw, 0o640)
+}
+
+// pickInboundExperimentChannels returns the active public channels whose
+// local share is under drainedShare, most drained first, alternately in the
+// treatment and control group. Channels that already carry an inbound fee
+// are left alone: someone set it on purpose.
+func pickInboundExperimentChannels(channels []lndclient.ChannelInfo, drainedShare float64) []inboundExperimentChannel {
+  picked := []inboundExperimentChannel{}
+  for _, ch := range channels {
+    if !ch.Active || ch.Private || ch.CapacitySat <= 0 {
+      continue
+    }
+    if ch.InboundFeeRatePpm != nil && *ch.InboundFeeRatePpm != 0 {
+      continue
+    }
+    share := float64(ch.LocalBalanceSat) / float64(ch.CapacitySat)
+    if share >= drainedShare {
+      continue
+    }
+    picked = append(picked, inboundExperimentChannel{
+      ChannelPoint: ch.ChannelPoint,
+      ChannelID: ch.ChannelID,
+      PeerAlias: ch.PeerAlias,
+      LocalShare: round3(share),
+    })
+  }
+  sort.SliceStable(picked, func(i, j int) bool {
+    if picked[i].LocalShare != picked[j].LocalShare {
+      return picked[i].LocalShare < picked[j].LocalShare
+    }
+    return picked[i].ChannelID < picked[j].ChannelID
+  })
+  for i := range picked {
+    picked[i].Group = "treatment"
+    if i%2 == 1 {
+      picked[i].Group = "control"
+    }
+  }
+  return picked
+}
+
+// flowChangePct compares two flows per unit of time, in percent.
+func flowChangePct(baseline int64, baselineDur time.Duration, trial int64, trialDur time.Duration) *float64 {
+  if baseline <= 0 || baselineDur <= 0 || trialDur <= 0 {
+    return nil
+  }
+  before := float64(baseline) / baselineDur.Hours()
+  after := float64(trial) / trialDur.Hours()
+  pct := round3((after/before - 1) * 100)
+  return &pct
+}
+
+// summarizeInboundExperiment sums the groups once every channel has its
+// trial flow, for a trial that ran until endedAt.
+func summarizeInboundExperiment(exp inboundExperiment, endedAt time.Time) inboundExperimentResult {
+  result := inboundExperimentResult{}
+  for _, ch := range exp.Channels {
+    group := &result.Control
+    if ch.Group == "treatment" {
+      group = &result.Treatment
+    }
+    group.Channels++
+    group.Baseline.add(ch.Baseline)
+    if ch.Trial != nil {
+      group.Trial.add(*ch.Trial)
+    }
+  }
+  baselineDur := time.Duration(exp.BaselineSec) * time.Second
+  trialDur := endedAt.Sub(exp.StartedAt)
+  for _, group := range []*inboundGroupResult{&result.Treatment, &result.Control} {
+    group.ChangePct = flowChangePct(group.Baseline.InboundSat, baselineDur, group.Trial.InboundSat, trialDur)
+  }
+  if result.Treatment.ChangePct != nil && result.Control.ChangePct != nil {
+    lift := round3(*result.Treatment.ChangePct - *result.Control.ChangePct)
+    result.LiftPct = &lift
+  }
+  return result
+}
+
+// inboundFlowByChannel sums the forwards between start and end by the
+// channel they arrived through.
+func (s *Server) inboundFlowByChannel(ctx context.Context, start, end time.Time) (map[uint64]inboundFlow, error) {
+  conn, err := s.lnd.DialLightning(ctx)
+  if err != nil {
+    return nil, err
+  }
+  defer conn.Close()
+  client := lnrpc.NewLightningClient(conn)
+  flows := map[uint64]inboundFlow{}
+  var offset uint32
+  for {
+    res, err := client.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{
+      StartTime: uint64(start.Unix()),
+      EndTime: uint64(end.Unix()),
+      IndexOffset: offset,
+      NumMaxEvents: inboundForwardsPageSize,
+    })
+    if err != nil {
+      return nil, err
+    }
+    for _, fwd := range res.ForwardingEvents {
+      flow := flows[fwd.ChanIdIn]
+      flow.Forwards++
+      flow.InboundSat += int64(fwd.AmtIn)
+      flow.FeeMsat += int64(fwd.FeeMsat)
+      flows[fwd.ChanIdIn] = flow
+    }
+    if len(res.ForwardingEvents) < inboundForwardsPageSize || res.LastOffsetIndex <= offset {
+      return flows, nil
+    }
+    offset = res.LastOffsetIndex
+  }
+}
+
+// setInboundFee changes only the inbound part of the channel's policy.
+func (s *Server) setInboundFee(ctx context.Context, channelPoint string, baseMsat, ratePpm int64) error {
+  policy, err := s.lnd.GetChannelPolicy(ctx, channelPoint)
+  if err != nil {
+    return err
+  }
+  return s.lnd.UpdateChannelFees(ctx, channelPoint, false, policy.BaseFeeMsat, policy.FeeRatePpm, policy.TimeLockDelta, true, baseMsat, ratePpm)
+}
+
+func (s *Server) startInboundExperiment(ctx context.Context, trigger string) (inboundExperiment, error) {
+  inboundExperimentsMu.Lock()
+  defer inboundExperimentsMu.Unlock()
+  state := readInboundExperiments()
+  if state.Running != nil {
+    return inboundExperiment{}, errInboundExperimentRunning
+  }
+  cfg := s.cfg.InboundExperiments
+
+  channels, err := s.lnd.ListChannels(ctx)
+  if err != nil {
+    return inboundExperiment{}, err
+  }
+  picked := pickInboundExperimentChannels(channels, cfg.DrainedShare())
+  if len(picked) < 2 {
+    return inboundExperiment{}, errInboundExperimentChannels
+  }
+  now := time.Now().UTC()
+  trial := cfg.Trial()
+  baseline, err := s.inboundFlowByChannel(ctx, now.Add(-trial), now)
+  if err != nil {
+    return inboundExperiment{}, fmt.Errorf("failed to read forwarding history: %w", err)
+  }
+  for i := range picked {
+    picked[i].Baseline = baseline[picked[i].ChannelID]
+    if picked[i].Group != "treatment" {
+      continue
+    }
+    policy, err := s.lnd.GetChannelPolicy(ctx, picked[i].ChannelPoint)
+    if err != nil {
+      return inboundExperiment{}, err
+    }
+    picked[i].InboundBaseMsat = policy.InboundBaseMsat
+    picked[i].InboundFeeRatePpm = policy.InboundFeeRatePpm
+  }
+  id, err := randomToken(6)
+  if err != nil {
+    return inboundExperiment{}, err
+  }
+  exp := inboundExperiment{
+    ID: id,
+    Trigger: trigger,
+    StartedAt: now,
+    EndsAt: now.Add(trial),
+    DiscountPpm: cfg.Discount(),
+    BaselineSec: int64(trial / time.Second),
+    Channels: picked,
+  }
+  // Stored before any policy changes, so whatever happens next can be
+  // undone.
+  state.Running = &exp
+  if err := writeInboundExperiments(state); err != nil {
+    return inboundExperiment{}, err
+  }
+  for _, ch := range exp.Channels {
+    if ch.Group != "treatment" {
+      continue
+    }
+    if err := s.setInboundFee(ctx, ch.ChannelPoint, ch.InboundBaseMsat, -exp.DiscountPpm); err != nil {
+      applyErr := fmt.Errorf("failed to set the inbound fee on %s: %s", ch.ChannelPoint, lndRPCErrorMessage(err))
+      state.Running.Error = applyErr.Error()
+      _ = writeInboundExperiments(state)
+      if _, finishErr := s.finishInboundExperimentLocked(ctx, true); finishErr != nil {
+        s.logger.Printf("inbound experiments: failed to roll back: %v", finishErr)
+      }
+      return inboundExperiment{}, applyErr
+    }
+  }
+  return exp, nil
+}
+
+// finishInboundExperimentLocked restores the treatment channels, measures
+// the trial and moves the experiment to the history. A channel that closed
+// meanwhile keeps no trial flow. When a fee cannot be restored the
+// experiment stays running as restore_pending, with a notification, and the
+// next call (the poller's or a stop) tries the rest again; the trial window
+// still ends at the first attempt.
+func (s *Server) finishInboundExperimentLocked(ctx context.Context, stopped bool) (inboundExperiment, error) {
+  state := readInboundExperiments()
+  if state.Running == nil {
+    return inboundExperiment{}, errInboundExperimentIdle
+  }
+  exp := *state.Running
+  if exp.EndedAt == nil {
+    endedAt := time.Now().UTC()
+    if endedAt.After(exp.EndsAt) {
+      endedAt = exp.EndsAt
+    }
+    exp.EndedAt = &endedAt
+    exp.Stopped = stopped
+  }
+  endedAt := *exp.EndedAt
+
+  open := map[uint64]bool{}
+  channels, listErr := s.lnd.ListChannels(ctx)
+  for _, ch := range channels {
+    open[ch.ChannelID] = true
+  }
+  failed := []string{}
+  for i := range exp.Channels {
+    ch := &exp.Channels[i]
+    if ch.Group != "treatment" || ch.Restored {
+      continue
+    }
+    if listErr == nil && !open[ch.ChannelID] {
+      // Closed: there is no policy left to restore.
+      ch.Restored = true
+      continue
+    }
+    if err := s.setInboundFee(ctx, ch.ChannelPoint, ch.InboundBaseMsat, ch.InboundFeeRatePpm); err != nil {
+      failed = append(failed, fmt.Sprintf("%s: %s", ch.ChannelPoint, lndRPCErrorMessage(err)))
+      continue
+    }
+    ch.Restored = true
+  }
+  if len(failed) > 0 {
+    exp.State = inboundRestorePending
+    exp.RestoreError = strings.Join(failed, "; ")
+    state.Running = &exp
+    if err := writeInboundExperiments(state); err != nil {
+      return exp, err
+    }
+    s.notifyInboundRestoreFailed(exp)
+    return exp, fmt.Errorf("%w on %s", errInboundExperimentRestore, exp.RestoreError)
+  }
+  exp.State = ""
+  exp.RestoreError = ""
+
+  trial, err := s.inboundFlowByChannel(ctx, exp.StartedAt, endedAt)
+  if err != nil {
+    // The fees are back; keep that, and measure on the next try.
+    state.Running = &exp
+    _ = writeInboundExperiments(state)
+    return inboundExperiment{}, fmt.Errorf("failed to read forwarding history: %w", err)
+  }
+  for i := range exp.Channels {
+    ch := &exp.Channels[i]
+    if listErr == nil && !open[ch.ChannelID] && trial[ch.ChannelID].Forwards == 0 {
+      continue
+    }
+    flow := trial[ch.ChannelID]
+    ch.Trial = &flow
+  }
+  result := summarizeInboundExperiment(exp, endedAt)
+  exp.Result = &result
+
+  state.Running = nil
+  state.History = append([]inboundExperiment{exp}, state.History...)
+  if len(state.History) > inboundExperimentsHistory {
+    state.History = state.History[:inboundExperimentsHistory]
+  }
+  return exp, writeInboundExperiments(state)
+}
+
+// notifyInboundRestoreFailed raises one notification per experiment while
+// its fees are left to restore.
+func (s *Server) notifyInboundRestoreFailed(exp inboundExperiment) {
+  if s.notifier == nil {
+    return
+  }
+  evt := Notification{
+    OccurredAt: time.Now().UTC(),
+    Type: "inbound_experiment",
+    Action: "restore_failed",
+    Direction: "neutral",
+    Status: "ERROR",
+    Memo: "Inbound fee not restored on " + exp.RestoreError,
+  }
+  ctx, cancel := s.taskContext("lnops/inbound_experiments/notify", timeoutShort)
+  defer cancel()
+  if _, err := s.notifier.upsertNotification(ctx, "inbound_experiment:restore_failed:"+exp.ID, evt); err != nil {
+    s.logger.Printf("inbound experiments: notification failed: %v", err)
+  }
+}
+
+func (s *Server) finishInboundExperiment(ctx context.Context, stopped bool) (inboundExperiment, error) {
+  inboundExperimentsMu.Lock()
+  defer inboundExperimentsMu.Unlock()
+  return s.finishInboundExperimentLocked(ctx, stopped)
+}
+
+func (s *Server) initInboundExperiments() {
+  goSafe("lnops/inbound_experiments", s.runInboundExperiments)
+}
+
+// runInboundExperiments ends trials that are due and, when enabled, starts
+// the next one once a trial length passed since the last, so its baseline
+// does not overlap the previous discount.
+func (s *Server) runInboundExperiments() {
+  for {
+    if !s.wait(inboundExperimentsPoll) {
+      return
+    }
+    state := readInboundExperiments()
+    now := time.Now()
+    ctx, cancel := s.taskContext("lnops/inbound_experiments", timeoutLong)
+    if state.Running != nil {
+      if !now.Before(state.Running.EndsAt) || state.Running.State == inboundRestorePending {
+        if _, err := s.finishInboundExperiment(ctx, false); err != nil {
+          s.logger.Printf("inbound experiments: failed to finish: %v", err)
+        }
+      }
+    } else if cfg := s.cfg.InboundExperiments; cfg.Enabled {
+      due := len(state.History) == 0 || state.History[0].EndedAt == nil || now.Sub(*state.History[0].EndedAt) >= cfg.Trial()
+      if due {
+        if _, err := s.startInboundExperiment(ctx, "auto"); err != nil {
+          s.logger.Printf("inbound experiments: failed to start: %v", err)
+        }
+      }
+    }
+    cancel()
+  }
+}
+
+func writeInboundExperimentError(w http.ResponseWriter, err error) {
+  switch {
+  case errors.Is(err, errInboundExperimentRunning), errors.Is(err, errInboundExperimentIdle):
+    writeError(w, http.StatusConflict, err.Error())
+  case errors.Is(err, errInboundExperimentChannels):
+    writeError(w, http.StatusBadRequest, err.Error())
+  default:
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+  }
+}
+
+func (s *Server) handleInboundExperimentsGet(w http.ResponseWriter, r *http.Request) {
+  cfg := s.cfg.InboundExperiments
+  state := readInboundExperiments()
+  writeJSON(w, http.StatusOK, map[string]any{
+    "config": map[string]any{
+      "enabled": cfg.Enabled,
+      "discount_ppm": cfg.Discount(),
+      "trial_days": int(cfg.Trial() / (24 * time.Hour)),
+      "drained_share": cfg.DrainedShare(),
+    },
+    "running": state.Running,
+    "history": state.History,
+  })
+}
+
+func (s *Server) handleInboundExperimentsStart(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  exp, err := s.startInboundExperiment(ctx, "manual")
+  if err != nil {
+    writeInboundExperimentError(w, err)
+    return
+  }
+  writeJSON(w, http.StatusOK, exp)
+}
+
+// handleInboundExperimentsStop ends the running trial early; the result
+// compares per-day flow, so a shorter trial still counts.
+func (s *Server) handleInboundExperimentsStop(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  exp, err := s.finishInboundExperiment(ctx, true)
+  if err != nil {
+    writeInboundExperimentError(w, err)
+    return
+  }
+  writeJSON(w, http.StatusOK, exp)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+  "testing"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestPickInboundExperimentChannels(t *testing.T) {
+  zero, set := int64(0), int64(-50)
+  channels := []lndclient.ChannelInfo{
+    {ChannelID: 1, Active: true, CapacitySat: 1000000, LocalBalanceSat: 100000},
+    {ChannelID: 2, Active: true, CapacitySat: 1000000, LocalBalanceSat: 50000, InboundFeeRatePpm: &zero},
+    {ChannelID: 3, Active: true, CapacitySat: 1000000, LocalBalanceSat: 500000},
+    {ChannelID: 4, Active: false, CapacitySat: 1000000, LocalBalanceSat: 0},
+    {ChannelID: 5, Active: true, Private: true, CapacitySat: 1000000, LocalBalanceSat: 0},
+    {ChannelID: 6, Active: true, CapacitySat: 1000000, LocalBalanceSat: 0, InboundFeeRatePpm: &set},
+    {ChannelID: 7, Active: true, CapacitySat: 2000000, LocalBalanceSat: 300000},
+  }
+  picked := pickInboundExperimentChannels(channels, 0.2)
+  want := []struct {
+    id uint64
+    group string
+  }{{2, "treatment"}, {1, "control"}, {7, "treatment"}}
+  if len(picked) != len(want) {
+    t.Fatalf("picked = %+v", picked)
+  }
+  for i, w := range want {
+    if picked[i].ChannelID != w.id || picked[i].Group != w.group {
+      t.Fatalf("picked[%d] = %+v, want %d in %s", i, picked[i], w.id, w.group)
+    }
+  }
+}
+
+func TestSummarizeInboundExperiment(t *testing.T) {
+  start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+  trial := func(sat int64) *inboundFlow { return &inboundFlow{Forwards: 1, InboundSat: sat} }
+  exp := inboundExperiment{
+    StartedAt: start,
+    BaselineSec: int64(7 * 24 * time.Hour / time.Second),
+    Channels: []inboundExperimentChannel{
+      {ChannelID: 1, Group: "treatment", Baseline: inboundFlow{InboundSat: 100000}, Trial: trial(150000)},
+      {ChannelID: 2, Group: "control", Baseline: inboundFlow{InboundSat: 200000}, Trial: trial(220000)},
+      {ChannelID: 3, Group: "treatment", Baseline: inboundFlow{InboundSat: 100000}},
+    },
+  }
+  // Stopped after half the baseline length: per-day flow is what counts.
+  result := summarizeInboundExperiment(exp, start.Add(84*time.Hour))
+  if result.Treatment.Channels != 2 || result.Treatment.Trial.InboundSat != 150000 {
+    t.Fatalf("treatment = %+v", result.Treatment)
+  }
+  if result.Treatment.ChangePct == nil || *result.Treatment.ChangePct != 50 {
+    t.Fatalf("treatment change = %v", result.Treatment.ChangePct)
+  }
+  if result.Control.ChangePct == nil || *result.Control.ChangePct != 120 {
+    t.Fatalf("control change = %v", result.Control.ChangePct)
+  }
+  if result.LiftPct == nil || *result.LiftPct != -70 {
+    t.Fatalf("lift = %v", result.LiftPct)
+  }
+
+  exp.Channels[1].Baseline = inboundFlow{}
+  if result := summarizeInboundExperiment(exp, start.Add(84*time.Hour)); result.Control.ChangePct != nil || result.LiftPct != nil {
+    t.Fatalf("result without control baseline = %+v", result)
+  }
+}
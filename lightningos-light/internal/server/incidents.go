@@ -0,0 +1,479 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/system"
+)
+
+// Incidents put what the node records separately side by side: uptime
+// outages, spikes of failed forwards and peers flapping open windows, which
+// are padded and merged when they overlap. The timeline for a window then
+// lists the notifications, health transitions, failure counts, peer flaps
+// and warning log lines in it, oldest first, so one read shows what
+// happened around an LND restart.
+const (
+  incidentDefaultDays = 7
+  incidentMaxDays = 31
+  incidentPadding = 10 * time.Minute
+  incidentTimelineMaxWindow = 24 * time.Hour
+  incidentTimelineMaxNotifications = 500
+  incidentLogMaxLines = 2000
+  incidentLogMaxEntries = 300
+
+  // A failure bucket is a spike when it has at least
+  // incidentFailureSpikeMin failures and incidentFailureSpikeFactor times
+  // the median bucket of the period.
+  incidentFailureBucket = 10 * time.Minute
+  incidentFailureSpikeMin = 20
+  incidentFailureSpikeFactor = 3
+
+  // Peers flapping together: at least incidentFlapPeers peers whose last
+  // flap falls within incidentFlapWindow.
+  incidentFlapPeers = 3
+  incidentFlapWindow = 10 * time.Minute
+)
+
+// incidentSignal is one thing that went wrong, before merging.
+type incidentSignal struct {
+  Kind string `json:"kind"`
+  StartedAt time.Time `json:"started_at"`
+  EndedAt time.Time `json:"ended_at"`
+  Ongoing bool `json:"ongoing,omitempty"`
+  Summary string `json:"summary"`
+}
+
+type incident struct {
+  StartedAt time.Time `json:"started_at"`
+  EndedAt time.Time `json:"ended_at"`
+  Ongoing bool `json:"ongoing"`
+  Kinds []string `json:"kinds"`
+  Signals []incidentSignal `json:"signals"`
+  // TimelineFrom and TimelineTo are the padded window to ask the timeline
+  // endpoint for.
+  TimelineFrom time.Time `json:"timeline_from"`
+  TimelineTo time.Time `json:"timeline_to"`
+}
+
+// detectIncidents merges signals whose windows, padded on both sides, touch.
+// The result is newest first.
+func detectIncidents(signals []incidentSignal, pad time.Duration) []incident {
+  sorted := append([]incidentSignal{}, signals...)
+  sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartedAt.Before(sorted[j].StartedAt) })
+  incidents := []incident{}
+  for _, sig := range sorted {
+    if n := len(incidents); n > 0 && !sig.StartedAt.Add(-pad).After(incidents[n-1].TimelineTo) {
+      last := &incidents[n-1]
+      last.Signals = append(last.Signals, sig)
+      if sig.EndedAt.After(last.EndedAt) {
+        last.EndedAt = sig.EndedAt
+        last.TimelineTo = sig.EndedAt.Add(pad)
+      }
+      last.Ongoing = last.Ongoing || sig.Ongoing
+      if !containsString(last.Kinds, sig.Kind) {
+        last.Kinds = append(last.Kinds, sig.Kind)
+      }
+      continue
+    }
+    incidents = append(incidents, incident{
+      StartedAt: sig.StartedAt,
+      EndedAt: sig.EndedAt,
+      Ongoing: sig.Ongoing,
+      Kinds: []string{sig.Kind},
+      Signals: []incidentSignal{sig},
+      TimelineFrom: sig.StartedAt.Add(-pad),
+      TimelineTo: sig.EndedAt.Add(pad),
+    })
+  }
+  for i, j := 0, len(incidents)-1; i < j; i, j = i+1, j-1 {
+    incidents[i], incidents[j] = incidents[j], incidents[i]
+  }
+  return incidents
+}
+
+func containsString(items []string, value string) bool {
+  for _, item := range items {
+    if item == value {
+      return true
+    }
+  }
+  return false
+}
+
+// outageSignals turns down intervals into signals; open ones run to now.
+func outageSignals(intervals []uptimeInterval, now time.Time) []incidentSignal {
+  signals := []incidentSignal{}
+  for _, iv := range intervals {
+    if iv.State != uptimeStateDown {
+      continue
+    }
+    sig := incidentSignal{Kind: iv.Component + "_down", StartedAt: iv.StartedAt, EndedAt: now, Ongoing: iv.EndedAt == nil}
+    if iv.EndedAt != nil {
+      sig.EndedAt = *iv.EndedAt
+    }
+    sig.Summary = iv.Component + " down"
+    if iv.Reason != "" {
+      sig.Summary += ": " + iv.Reason
+    }
+    signals = append(signals, sig)
+  }
+  return signals
+}
+
+type failureBucket struct {
+  At time.Time `json:"at"`
+  Failures int64 `json:"failures"`
+}
+
+// failureSpikeSignals compares each bucket with the median of all buckets
+// in [from, to), empty ones included. Adjacent spiking buckets become one
+// signal.
+func failureSpikeSignals(buckets []failureBucket, from, to time.Time) []incidentSignal {
+  total := int(to.Sub(from) / incidentFailureBucket)
+  if total <= 0 || len(buckets) == 0 {
+    return []incidentSignal{}
+  }
+  counts := make([]int64, 0, total)
+  for _, b := range buckets {
+    counts = append(counts, b.Failures)
+  }
+  for len(counts) < total {
+    counts = append(counts, 0)
+  }
+  sort.Slice(counts, func(i, j int) bool { return counts[i] < counts[j] })
+  median := counts[len(counts)/2]
+
+  sorted := append([]failureBucket{}, buckets...)
+  sort.Slice(sorted, func(i, j int) bool { return sorted[i].At.Before(sorted[j].At) })
+  signals := []incidentSignal{}
+  var current *incidentSignal
+  var failures int64
+  for _, b := range sorted {
+    if b.Failures < incidentFailureSpikeMin || b.Failures < incidentFailureSpikeFactor*median {
+      continue
+    }
+    end := b.At.Add(incidentFailureBucket)
+    if current != nil && !b.At.After(current.EndedAt) {
+      current.EndedAt = end
+      failures += b.Failures
+      current.Summary = fmt.Sprintf("%d failed forwards", failures)
+      continue
+    }
+    signals = append(signals, incidentSignal{Kind: "forward_failures", StartedAt: b.At, EndedAt: end})
+    current = &signals[len(signals)-1]
+    failures = b.Failures
+    current.Summary = fmt.Sprintf("%d failed forwards", failures)
+  }
+  return signals
+}
+
+// peerFlapSignals finds peers flapping together. LND only keeps the last
+// flap of each peer, so this sees the most recent cluster and not older
+// ones.
+func peerFlapSignals(peers []lndclient.PeerInfo, from, to time.Time) []incidentSignal {
+  flaps := []time.Time{}
+  for _, peer := range peers {
+    if peer.LastFlapNs <= 0 {
+      continue
+    }
+    at := time.Unix(0, peer.LastFlapNs).UTC()
+    if !at.Before(from) && at.Before(to) {
+      flaps = append(flaps, at)
+    }
+  }
+  sort.Slice(flaps, func(i, j int) bool { return flaps[i].Before(flaps[j]) })
+  signals := []incidentSignal{}
+  for start := 0; start < len(flaps); {
+    end := start
+    for end+1 < len(flaps) && flaps[end+1].Sub(flaps[start]) <= incidentFlapWindow {
+      end++
+    }
+    if count := end - start + 1; count >= incidentFlapPeers {
+      signals = append(signals, incidentSignal{
+        Kind: "peer_flaps",
+        StartedAt: flaps[start],
+        EndedAt: flaps[end],
+        Summary: fmt.Sprintf("%d peers reconnected", count),
+      })
+      start = end + 1
+      continue
+    }
+    start++
+  }
+  return signals
+}
+
+// timelineEntry is one line of an incident timeline. Source is
+// notification, health, forward_failures, peer or log.
+type timelineEntry struct {
+  At time.Time `json:"at"`
+  Source string `json:"source"`
+  Component string `json:"component,omitempty"`
+  Level string `json:"level"`
+  Summary string `json:"summary"`
+  Notification *Notification `json:"notification,omitempty"`
+}
+
+type incidentLog struct {
+  Service string
+  Entries []system.JournalEntry
+}
+
+func notificationLevel(evt Notification) string {
+  status := strings.ToUpper(evt.Status)
+  switch {
+  case evt.Type == "alert":
+    return "warn"
+  case strings.Contains(status, "FAIL"):
+    return "warn"
+  case evt.Type == "channel" && (evt.Action == "close" || evt.Action == "closing"):
+    return "warn"
+  }
+  return "info"
+}
+
+func notificationSummary(evt Notification) string {
+  parts := []string{evt.Type}
+  if evt.Action != "" {
+    parts = append(parts, evt.Action)
+  }
+  if evt.Status != "" {
+    parts = append(parts, strings.ToLower(evt.Status))
+  }
+  summary := strings.Join(parts, " ")
+  if evt.PeerAlias != "" {
+    summary += " (" + evt.PeerAlias + ")"
+  }
+  if evt.Memo != "" {
+    summary += ": " + evt.Memo
+  }
+  return summary
+}
+
+// buildIncidentTimeline merges every source into one list over [from, to).
+// Health transitions are the starts of uptime intervals in the window.
+func buildIncidentTimeline(notifications []Notification, intervals []uptimeInterval, buckets []failureBucket, peers []lndclient.PeerInfo, logs []incidentLog, from, to time.Time) []timelineEntry {
+  in := func(at time.Time) bool { return !at.Before(from) && at.Before(to) }
+  entries := []timelineEntry{}
+  for i := range notifications {
+    evt := notifications[i]
+    if !in(evt.OccurredAt) {
+      continue
+    }
+    entries = append(entries, timelineEntry{At: evt.OccurredAt, Source: "notification", Level: notificationLevel(evt), Summary: notificationSummary(evt), Notification: &notifications[i]})
+  }
+  for _, iv := range intervals {
+    if !in(iv.StartedAt) {
+      continue
+    }
+    entry := timelineEntry{At: iv.StartedAt, Source: "health", Component: iv.Component, Level: "info", Summary: iv.Component + " " + iv.State}
+    if iv.State == uptimeStateDown {
+      entry.Level = "error"
+      if iv.Reason != "" {
+        entry.Summary += ": " + iv.Reason
+      }
+    }
+    entries = append(entries, entry)
+  }
+  for _, b := range buckets {
+    if !in(b.At) || b.Failures == 0 {
+      continue
+    }
+    level := "info"
+    if b.Failures >= incidentFailureSpikeMin {
+      level = "warn"
+    }
+    entries = append(entries, timelineEntry{At: b.At, Source: "forward_failures", Level: level, Summary: fmt.Sprintf("%d failed forwards in %d minutes", b.Failures, int(incidentFailureBucket/time.Minute))})
+  }
+  for _, peer := range peers {
+    if peer.LastFlapNs <= 0 {
+      continue
+    }
+    at := time.Unix(0, peer.LastFlapNs).UTC()
+    if !in(at) {
+      continue
+    }
+    name := peer.Alias
+    if name == "" {
+      name = peer.PubKey
+    }
+    entries = append(entries, timelineEntry{At: at, Source: "peer", Component: peer.PubKey, Level: "warn", Summary: fmt.Sprintf("%s reconnected (%d flaps)", name, peer.FlapCount)})
+  }
+  for _, log := range logs {
+    kept := []timelineEntry{}
+    for _, line := range log.Entries {
+      level := parseLogSeverity(log.Service, line.Message)
+      if logSeverityRank[level] < logSeverityRank["warn"] || !in(line.Time) {
+        continue
+      }
+      kept = append(kept, timelineEntry{At: line.Time, Source: "log", Component: log.Service, Level: level, Summary: strings.TrimSpace(line.Message)})
+    }
+    // A noisy service keeps its latest lines, closest to the recovery.
+    if len(kept) > incidentLogMaxEntries {
+      kept = kept[len(kept)-incidentLogMaxEntries:]
+    }
+    entries = append(entries, kept...)
+  }
+  sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+  return entries
+}
+
+func (n *Notifier) failureBuckets(ctx context.Context, from, to time.Time) ([]failureBucket, error) {
+  rows, err := n.db.Query(ctx, `
+select to_timestamp(floor(extract(epoch from occurred_at) / $4) * $4) as bucket, count(*)
+from htlc_failures
+where node_id = $1 and occurred_at >= $2 and occurred_at < $3
+group by bucket
+order by bucket`, n.nodeKey(), from, to, int64(incidentFailureBucket/time.Second))
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  buckets := []failureBucket{}
+  for rows.Next() {
+    var b failureBucket
+    if err := rows.Scan(&b.At, &b.Failures); err != nil {
+      return nil, err
+    }
+    b.At = b.At.UTC()
+    buckets = append(buckets, b)
+  }
+  return buckets, rows.Err()
+}
+
+// handleIncidents lists the incidents of the last days (?days=7, at most
+// 31), newest first. Sources that cannot be read are named in unavailable.
+func (s *Server) handleIncidents(w http.ResponseWriter, r *http.Request) {
+  days := incidentDefaultDays
+  if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > incidentMaxDays {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("days out of range: 1 to %d", incidentMaxDays))
+      return
+    }
+    days = parsed
+  }
+  now := time.Now().UTC()
+  from := now.AddDate(0, 0, -days)
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  signals := []incidentSignal{}
+  unavailable := []string{}
+  if s.db != nil {
+    if intervals, err := s.uptimeIntervals(ctx, from, now); err == nil {
+      signals = append(signals, outageSignals(intervals, now)...)
+    } else {
+      unavailable = append(unavailable, "health")
+    }
+  } else {
+    unavailable = append(unavailable, "health")
+  }
+  if notifier := s.notifierFor(r); notifier != nil && notifier.db != nil {
+    if buckets, err := notifier.failureBuckets(ctx, from, now); err == nil {
+      signals = append(signals, failureSpikeSignals(buckets, from, now)...)
+    } else {
+      unavailable = append(unavailable, "forward_failures")
+    }
+  } else {
+    unavailable = append(unavailable, "forward_failures")
+  }
+  if peers, err := s.lndFor(r).ListPeers(ctx); err == nil {
+    signals = append(signals, peerFlapSignals(peers, from, now)...)
+  } else {
+    unavailable = append(unavailable, "peers")
+  }
+
+  writeJSON(w, http.StatusOK, map[string]any{
+    "from": from,
+    "to": now,
+    "items": detectIncidents(signals, incidentPadding),
+    "unavailable": unavailable,
+  })
+}
+
+func parseTimelineWindow(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+  q := r.URL.Query()
+  from, err := time.Parse(time.RFC3339, strings.TrimSpace(q.Get("from")))
+  if err != nil {
+    return time.Time{}, time.Time{}, errors.New("from must be RFC3339")
+  }
+  to := now
+  if raw := strings.TrimSpace(q.Get("to")); raw != "" {
+    to, err = time.Parse(time.RFC3339, raw)
+    if err != nil {
+      return time.Time{}, time.Time{}, errors.New("to must be RFC3339")
+    }
+  }
+  if !to.After(from) {
+    return time.Time{}, time.Time{}, errors.New("from must be before to")
+  }
+  if to.Sub(from) > incidentTimelineMaxWindow {
+    return time.Time{}, time.Time{}, fmt.Errorf("window too large: max %d hours", int(incidentTimelineMaxWindow/time.Hour))
+  }
+  return from.UTC(), to.UTC(), nil
+}
+
+// handleIncidentTimeline returns everything recorded in [from, to) (RFC3339,
+// at most 24 hours) as one timeline.
+func (s *Server) handleIncidentTimeline(w http.ResponseWriter, r *http.Request) {
+  from, to, err := parseTimelineWindow(r, time.Now().UTC())
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+
+  unavailable := []string{}
+  var notifications []Notification
+  var buckets []failureBucket
+  if notifier := s.notifierFor(r); notifier != nil && notifier.db != nil {
+    if notifications, err = notifier.listNotificationsBetween(ctx, from, to, incidentTimelineMaxNotifications); err == nil {
+      notifier.attachAnnotations(ctx, notifications)
+    } else {
+      unavailable = append(unavailable, "notifications")
+    }
+    if buckets, err = notifier.failureBuckets(ctx, from, to); err != nil {
+      unavailable = append(unavailable, "forward_failures")
+    }
+  } else {
+    unavailable = append(unavailable, "notifications", "forward_failures")
+  }
+  var intervals []uptimeInterval
+  if s.db != nil {
+    if intervals, err = s.uptimeIntervals(ctx, from, to); err != nil {
+      unavailable = append(unavailable, "health")
+    }
+  } else {
+    unavailable = append(unavailable, "health")
+  }
+  peers, err := s.lndFor(r).ListPeers(ctx)
+  if err != nil {
+    unavailable = append(unavailable, "peers")
+  }
+  logs := []incidentLog{}
+  for _, service := range []string{"lnd", "lightningos-manager"} {
+    entries, err := system.JournalRange(ctx, service, from, to, incidentLogMaxLines)
+    if err != nil {
+      unavailable = append(unavailable, "logs:"+service)
+      continue
+    }
+    logs = append(logs, incidentLog{Service: service, Entries: entries})
+  }
+
+  writeJSON(w, http.StatusOK, map[string]any{
+    "from": from,
+    "to": to,
+    "items": buildIncidentTimeline(notifications, intervals, buckets, peers, logs, from, to),
+    "unavailable": unavailable,
+  })
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+  "net/http/httptest"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/system"
+)
+
+func TestDetectIncidents(t *testing.T) {
+  base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  ended := base.Add(5 * time.Minute)
+  signals := outageSignals([]uptimeInterval{
+    {Component: "lnd", State: uptimeStateUp, StartedAt: base.Add(-time.Hour), EndedAt: &base},
+    {Component: "lnd", State: uptimeStateDown, StartedAt: base, EndedAt: &ended, Reason: "restarting"},
+  }, base.Add(2*time.Hour))
+  from := base.Add(-24 * time.Hour)
+  signals = append(signals, failureSpikeSignals([]failureBucket{
+    {At: base.Add(10 * time.Minute), Failures: 40},
+    {At: base.Add(20 * time.Minute), Failures: 25},
+    {At: base.Add(-6 * time.Hour), Failures: 5},
+  }, from, base.Add(time.Hour))...)
+  flap := func(at time.Time) lndclient.PeerInfo { return lndclient.PeerInfo{LastFlapNs: at.UnixNano()} }
+  signals = append(signals, peerFlapSignals([]lndclient.PeerInfo{
+    flap(base.Add(-3 * time.Hour)), flap(base.Add(-3*time.Hour + time.Minute)), flap(base.Add(-3*time.Hour + 2*time.Minute)),
+    flap(base.Add(time.Minute)),
+  }, from, base.Add(time.Hour))...)
+
+  incidents := detectIncidents(signals, incidentPadding)
+  if len(incidents) != 2 {
+    t.Fatalf("incidents %+v", incidents)
+  }
+  latest := incidents[0]
+  if !latest.StartedAt.Equal(base) || !latest.EndedAt.Equal(base.Add(30*time.Minute)) || len(latest.Kinds) != 2 || latest.Kinds[1] != "forward_failures" {
+    t.Fatalf("latest %+v", latest)
+  }
+  if latest.Signals[1].Summary != "65 failed forwards" || !latest.TimelineFrom.Equal(base.Add(-incidentPadding)) {
+    t.Fatalf("signals %+v", latest.Signals)
+  }
+  if incidents[1].Kinds[0] != "peer_flaps" || incidents[1].Signals[0].Summary != "3 peers reconnected" {
+    t.Fatalf("flaps %+v", incidents[1])
+  }
+}
+
+func TestBuildIncidentTimeline(t *testing.T) {
+  base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  from, to := base.Add(-time.Hour), base.Add(time.Hour)
+  timeline := buildIncidentTimeline(
+    []Notification{{ID: 1, OccurredAt: base.Add(3 * time.Minute), Type: "channel", Action: "closing", PeerAlias: "bob"}},
+    []uptimeInterval{{Component: "lnd", State: uptimeStateDown, StartedAt: base, Reason: "restarting"}},
+    []failureBucket{{At: base.Add(10 * time.Minute), Failures: 30}},
+    []lndclient.PeerInfo{{PubKey: "02aa", Alias: "alice", FlapCount: 4, LastFlapNs: base.Add(2 * time.Minute).UnixNano()}},
+    []incidentLog{{Service: "lnd", Entries: []system.JournalEntry{
+      {Time: base.Add(time.Minute), Message: "2026-03-01 12:01:00.000 [ERR] SRVR: unable to connect"},
+      {Time: base.Add(time.Minute), Message: "2026-03-01 12:01:00.000 [INF] SRVR: connected"},
+      {Time: base.Add(2 * time.Hour), Message: "[ERR] outside the window"},
+    }}},
+    from, to,
+  )
+  want := []string{"health", "log", "peer", "notification", "forward_failures"}
+  if len(timeline) != len(want) {
+    t.Fatalf("timeline %+v", timeline)
+  }
+  for i, source := range want {
+    if timeline[i].Source != source {
+      t.Fatalf("entry %d: %s, want %s", i, timeline[i].Source, source)
+    }
+  }
+  if timeline[0].Summary != "lnd down: restarting" || timeline[2].Summary != "alice reconnected (4 flaps)" || timeline[3].Level != "warn" || timeline[3].Summary != "channel closing (bob)" {
+    t.Fatalf("timeline %+v", timeline)
+  }
+}
+
+func TestParseTimelineWindow(t *testing.T) {
+  now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  from, to, err := parseTimelineWindow(httptest.NewRequest("GET", "/?from=2026-03-01T10:00:00Z", nil), now)
+  if err != nil || !from.Equal(now.Add(-2*time.Hour)) || !to.Equal(now) {
+    t.Fatalf("window %s %s: %v", from, to, err)
+  }
+  if _, _, err := parseTimelineWindow(httptest.NewRequest("GET", "/?from=2026-02-27T10:00:00Z", nil), now); err == nil {
+    t.Fatal("accepted two days")
+  }
+  if _, _, err := parseTimelineWindow(httptest.NewRequest("GET", "/?from=yesterday", nil), now); err == nil {
+    t.Fatal("accepted a bad time")
+  }
+}
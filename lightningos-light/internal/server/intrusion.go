@@ -0,0 +1,560 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net"
+  "net/http"
+  "os"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/system"
+
+  "github.com/go-chi/chi/v5"
+)
+
+// The intrusion monitor collects failed logins: wrong passwords on the
+// manager's basic auth, and failed SSH logins read from journald every
+// minute. An address with intrusion.burst_attempts failures within
+// intrusion.burst_minutes raises one notification per burst and, with
+// intrusion.auto_block, is denied in ufw for intrusion.block_minutes.
+// Blocks survive restarts in intrusionBlocksPath and are lifted by the
+// monitor when they expire. LAN and loopback addresses are never blocked,
+// so a mistyped password at home cannot lock the owner out.
+const (
+  intrusionBlocksPath = "/var/lib/lightningos/intrusion-blocks.json"
+  intrusionPoll = time.Minute
+  intrusionKeep = 24 * time.Hour
+  intrusionMaxAttempts = 5000
+  intrusionListMax = 200
+  intrusionMaxBlock = 30 * 24 * time.Hour
+
+  intrusionSourceManager = "manager"
+  intrusionSourceSSH = "ssh"
+)
+
+// sshUnits are the names the SSH daemon runs under: ssh on Debian and
+// Ubuntu, sshd elsewhere.
+var sshUnits = []string{"ssh", "sshd"}
+
+var errIntrusionLocal = errors.New("lan and loopback addresses are never blocked")
+
+type intrusionAttempt struct {
+  At time.Time `json:"at"`
+  Source string `json:"source"`
+  IP string `json:"ip"`
+  User string `json:"user,omitempty"`
+}
+
+type intrusionBlock struct {
+  IP string `json:"ip"`
+  Reason string `json:"reason"`
+  BlockedAt time.Time `json:"blocked_at"`
+  Until time.Time `json:"until"`
+}
+
+// intrusionAddress sums up the attempts from one address.
+type intrusionAddress struct {
+  IP string `json:"ip"`
+  Attempts int `json:"attempts"`
+  Sources []string `json:"sources"`
+  Users []string `json:"users"`
+  FirstAt time.Time `json:"first_at"`
+  LastAt time.Time `json:"last_at"`
+  BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+}
+
+type intrusionBurst struct {
+  IP string
+  Attempts int
+  Sources []string
+}
+
+type intrusionMonitor struct {
+  mu sync.Mutex
+  path string
+  loaded bool
+  attempts []intrusionAttempt
+  // reported holds when each address last raised a burst.
+  reported map[string]time.Time
+  blocks map[string]intrusionBlock
+  sshSince time.Time
+  sshErr string
+}
+
+func newIntrusionMonitor(path string) *intrusionMonitor {
+  return &intrusionMonitor{path: path, reported: map[string]time.Time{}, blocks: map[string]intrusionBlock{}}
+}
+
+// record adds attempts, dropping the ones older than intrusionKeep and the
+// oldest past intrusionMaxAttempts.
+func (m *intrusionMonitor) record(now time.Time, attempts ...intrusionAttempt) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.attempts = append(m.attempts, attempts...)
+  sort.SliceStable(m.attempts, func(i, j int) bool { return m.attempts[i].At.Before(m.attempts[j].At) })
+  cut := 0
+  for cut < len(m.attempts) && now.Sub(m.attempts[cut].At) > intrusionKeep {
+    cut++
+  }
+  if len(m.attempts)-cut > intrusionMaxAttempts {
+    cut = len(m.attempts) - intrusionMaxAttempts
+  }
+  m.attempts = append([]intrusionAttempt(nil), m.attempts[cut:]...)
+}
+
+// takeBursts returns the addresses with at least threshold attempts within
+// window before now that have not been reported within window, and marks
+// them reported.
+func (m *intrusionMonitor) takeBursts(now time.Time, threshold int, window time.Duration) []intrusionBurst {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  counts := map[string]*intrusionBurst{}
+  order := []string{}
+  for _, attempt := range m.attempts {
+    if now.Sub(attempt.At) > window || attempt.At.After(now) {
+      continue
+    }
+    burst, ok := counts[attempt.IP]
+    if !ok {
+      burst = &intrusionBurst{IP: attempt.IP}
+      counts[attempt.IP] = burst
+      order = append(order, attempt.IP)
+    }
+    burst.Attempts++
+    if !containsString(burst.Sources, attempt.Source) {
+      burst.Sources = append(burst.Sources, attempt.Source)
+    }
+  }
+  bursts := []intrusionBurst{}
+  for _, ip := range order {
+    burst := counts[ip]
+    if burst.Attempts < threshold {
+      continue
+    }
+    if at, ok := m.reported[ip]; ok && now.Sub(at) < window {
+      continue
+    }
+    m.reported[ip] = now
+    bursts = append(bursts, *burst)
+  }
+  for ip, at := range m.reported {
+    if now.Sub(at) > intrusionKeep {
+      delete(m.reported, ip)
+    }
+  }
+  return bursts
+}
+
+// summary returns the newest attempts (up to intrusionListMax) and the
+// attempts since since grouped by address, most attempts first.
+func (m *intrusionMonitor) summary(since time.Time) ([]intrusionAttempt, []intrusionAddress) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.loadLocked()
+  recent := []intrusionAttempt{}
+  byIP := map[string]*intrusionAddress{}
+  for i := len(m.attempts) - 1; i >= 0; i-- {
+    attempt := m.attempts[i]
+    if attempt.At.Before(since) {
+      break
+    }
+    if len(recent) < intrusionListMax {
+      recent = append(recent, attempt)
+    }
+    addr, ok := byIP[attempt.IP]
+    if !ok {
+      addr = &intrusionAddress{IP: attempt.IP, Sources: []string{}, Users: []string{}, LastAt: attempt.At}
+      byIP[attempt.IP] = addr
+    }
+    addr.Attempts++
+    addr.FirstAt = attempt.At
+    if !containsString(addr.Sources, attempt.Source) {
+      addr.Sources = append(addr.Sources, attempt.Source)
+    }
+    if attempt.User != "" && len(addr.Users) < 10 && !containsString(addr.Users, attempt.User) {
+      addr.Users = append(addr.Users, attempt.User)
+    }
+  }
+  addresses := make([]intrusionAddress, 0, len(byIP))
+  for _, addr := range byIP {
+    if block, ok := m.blocks[addr.IP]; ok {
+      until := block.Until
+      addr.BlockedUntil = &until
+    }
+    addresses = append(addresses, *addr)
+  }
+  sort.Slice(addresses, func(i, j int) bool {
+    if addresses[i].Attempts != addresses[j].Attempts {
+      return addresses[i].Attempts > addresses[j].Attempts
+    }
+    return addresses[i].LastAt.After(addresses[j].LastAt)
+  })
+  return recent, addresses
+}
+
+func (m *intrusionMonitor) loadLocked() {
+  if m.loaded {
+    return
+  }
+  m.loaded = true
+  raw, err := os.ReadFile(m.path)
+  if err != nil {
+    return
+  }
+  var blocks []intrusionBlock
+  if json.Unmarshal(raw, &blocks) != nil {
+    return
+  }
+  for _, block := range blocks {
+    m.blocks[block.IP] = block
+  }
+}
+
+func (m *intrusionMonitor) saveLocked() error {
+  blocks := m.blockListLocked()
+  if err := os.MkdirAll(filepath.Dir(m.path), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.Marshal(blocks)
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(m.path, raw, 0o640)
+}
+
+func (m *intrusionMonitor) blockListLocked() []intrusionBlock {
+  blocks := make([]intrusionBlock, 0, len(m.blocks))
+  for _, block := range m.blocks {
+    blocks = append(blocks, block)
+  }
+  sort.Slice(blocks, func(i, j int) bool { return blocks[i].Until.Before(blocks[j].Until) })
+  return blocks
+}
+
+func (m *intrusionMonitor) blockList() []intrusionBlock {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.loadLocked()
+  return m.blockListLocked()
+}
+
+func (m *intrusionMonitor) setBlock(block intrusionBlock) error {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.loadLocked()
+  m.blocks[block.IP] = block
+  return m.saveLocked()
+}
+
+func (m *intrusionMonitor) blocked(ip string) bool {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.loadLocked()
+  _, ok := m.blocks[ip]
+  return ok
+}
+
+func (m *intrusionMonitor) removeBlock(ip string) (bool, error) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.loadLocked()
+  if _, ok := m.blocks[ip]; !ok {
+    return false, nil
+  }
+  delete(m.blocks, ip)
+  return true, m.saveLocked()
+}
+
+func (m *intrusionMonitor) expiredBlocks(now time.Time) []intrusionBlock {
+  expired := []intrusionBlock{}
+  for _, block := range m.blockList() {
+    if !now.Before(block.Until) {
+      expired = append(expired, block)
+    }
+  }
+  return expired
+}
+
+var sshFailed = regexp.MustCompile(`^Failed \S+ for (invalid user )?(.*) from (\S+) port \d+`)
+var sshInvalidUser = regexp.MustCompile(`^Invalid user (.*) from (\S+)(?: port \d+)?$`)
+
+// parseSSHFailure reads a failed login from an sshd log line. An unknown
+// user is logged once as "Invalid user" and again for each failed
+// password; only the first counts, so a connection trying one unknown user
+// is one attempt.
+func parseSSHFailure(message string) (string, string, bool) {
+  message = strings.TrimSpace(message)
+  var user, host string
+  if match := sshFailed.FindStringSubmatch(message); match != nil {
+    if match[1] != "" {
+      return "", "", false
+    }
+    user, host = match[2], match[3]
+  } else if match := sshInvalidUser.FindStringSubmatch(message); match != nil {
+    user, host = match[1], match[2]
+  } else {
+    return "", "", false
+  }
+  ip := net.ParseIP(host)
+  if ip == nil {
+    return "", "", false
+  }
+  return ip.String(), strings.TrimSpace(user), true
+}
+
+// neverBlocked reports whether ip is on the LAN or the machine itself.
+func neverBlocked(ip string) bool {
+  parsed := net.ParseIP(ip)
+  return parsed == nil || parsed.IsLoopback() || parsed.IsPrivate() || parsed.IsLinkLocalUnicast() || parsed.IsUnspecified()
+}
+
+// recordAuthFailure notes a wrong manager password.
+func (s *Server) recordAuthFailure(ip string, user string, now time.Time) {
+  if s.intrusions == nil || ip == "" {
+    return
+  }
+  s.intrusions.record(now, intrusionAttempt{At: now.UTC(), Source: intrusionSourceManager, IP: ip, User: user})
+}
+
+func (s *Server) initIntrusionMonitor() {
+  goSafe("security/intrusions", s.runIntrusionMonitor)
+}
+
+func (s *Server) runIntrusionMonitor() {
+  for {
+    now := time.Now()
+    s.pollSSHFailures(now)
+    s.checkIntrusionBursts(now)
+    s.liftExpiredBlocks(now)
+    if !s.wait(intrusionPoll) {
+      return
+    }
+  }
+}
+
+// pollSSHFailures reads the sshd journal since the last entry seen, going
+// back intrusionKeep on the first run.
+func (s *Server) pollSSHFailures(now time.Time) {
+  m := s.intrusions
+  m.mu.Lock()
+  since := m.sshSince
+  if since.IsZero() {
+    since = now.Add(-intrusionKeep)
+  }
+  m.mu.Unlock()
+
+  ctx, cancel := s.taskContext("security/ssh_journal", timeoutMedium)
+  defer cancel()
+  attempts := []intrusionAttempt{}
+  latest := since
+  var errs []string
+  for _, unit := range sshUnits {
+    entries, err := system.JournalRange(ctx, unit, since, time.Time{}, intrusionMaxAttempts)
+    if err != nil {
+      errs = append(errs, fmt.Sprintf("%s: %v", unit, err))
+      continue
+    }
+    for _, entry := range entries {
+      // --since is inclusive and to the second; skip what was already read.
+      if !entry.Time.After(since) {
+        continue
+      }
+      if entry.Time.After(latest) {
+        latest = entry.Time
+      }
+      if ip, user, ok := parseSSHFailure(entry.Message); ok {
+        attempts = append(attempts, intrusionAttempt{At: entry.Time, Source: intrusionSourceSSH, IP: ip, User: user})
+      }
+    }
+  }
+  if len(attempts) > 0 {
+    m.record(now, attempts...)
+  }
+  m.mu.Lock()
+  m.sshSince = latest
+  m.sshErr = ""
+  if len(errs) == len(sshUnits) {
+    m.sshErr = strings.Join(errs, "; ")
+  }
+  m.mu.Unlock()
+}
+
+func (s *Server) checkIntrusionBursts(now time.Time) {
+  threshold, window := s.cfg.Intrusion.Burst()
+  for _, burst := range s.intrusions.takeBursts(now, threshold, window) {
+    memo := fmt.Sprintf("%d failed logins from %s in %s (%s)", burst.Attempts, burst.IP, window, strings.Join(burst.Sources, ", "))
+    if s.cfg.Intrusion.AutoBlock {
+      if neverBlocked(burst.IP) {
+        memo += "; not blocked: LAN address"
+      } else {
+        ctx, cancel := s.taskContext("security/block", timeoutMedium)
+        block, err := s.blockAddress(ctx, burst.IP, s.cfg.Intrusion.BlockFor(), "burst", now)
+        cancel()
+        if err != nil {
+          s.logger.Printf("intrusions: failed to block %s: %v", burst.IP, err)
+          memo += "; block failed: " + err.Error()
+        } else {
+          memo += "; blocked until " + block.Until.Format(time.RFC3339)
+        }
+      }
+    }
+    s.notifyIntrusionBurst(burst, memo, now)
+  }
+}
+
+func (s *Server) notifyIntrusionBurst(burst intrusionBurst, memo string, now time.Time) {
+  if s.notifier == nil {
+    return
+  }
+  evt := Notification{
+    OccurredAt: now.UTC(),
+    Type: "security",
+    Action: "intrusion_burst",
+    Direction: "neutral",
+    Status: "WARNING",
+    Memo: memo,
+  }
+  ctx, cancel := s.taskContext("security/notify", timeoutShort)
+  defer cancel()
+  key := fmt.Sprintf("intrusion:%s:%d", burst.IP, now.Unix())
+  if _, err := s.notifier.upsertNotification(ctx, key, evt); err != nil {
+    s.logger.Printf("intrusions: notification failed: %v", err)
+  }
+}
+
+// blockAddress denies ip in the firewall for d and records the block.
+func (s *Server) blockAddress(ctx context.Context, ip string, d time.Duration, reason string, now time.Time) (intrusionBlock, error) {
+  if neverBlocked(ip) {
+    return intrusionBlock{}, errIntrusionLocal
+  }
+  if err := firewallBlock(ctx, ip); err != nil {
+    return intrusionBlock{}, err
+  }
+  block := intrusionBlock{IP: ip, Reason: reason, BlockedAt: now.UTC(), Until: now.Add(d).UTC()}
+  return block, s.intrusions.setBlock(block)
+}
+
+// unblockAddress lifts a block made by the monitor; found is false for an
+// address it did not block.
+func (s *Server) unblockAddress(ctx context.Context, ip string) (bool, error) {
+  if !s.intrusions.blocked(ip) {
+    return false, nil
+  }
+  if err := firewallUnblock(ctx, ip); err != nil {
+    return true, err
+  }
+  return s.intrusions.removeBlock(ip)
+}
+
+func (s *Server) liftExpiredBlocks(now time.Time) {
+  for _, block := range s.intrusions.expiredBlocks(now) {
+    ctx, cancel := s.taskContext("security/unblock", timeoutMedium)
+    if _, err := s.unblockAddress(ctx, block.IP); err != nil {
+      s.logger.Printf("intrusions: failed to lift the block of %s: %v", block.IP, err)
+    }
+    cancel()
+  }
+}
+
+// handleIntrusions serves GET /api/security/intrusions.
+func (s *Server) handleIntrusions(w http.ResponseWriter, r *http.Request) {
+  threshold, window := s.cfg.Intrusion.Burst()
+  hours := 24
+  if raw := strings.TrimSpace(r.URL.Query().Get("hours")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed <= 0 || parsed > 24 {
+      writeError(w, http.StatusBadRequest, "hours out of range: 1 to 24")
+      return
+    }
+    hours = parsed
+  }
+  recent, addresses := s.intrusions.summary(time.Now().Add(-time.Duration(hours) * time.Hour))
+  s.intrusions.mu.Lock()
+  sshErr := s.intrusions.sshErr
+  s.intrusions.mu.Unlock()
+  writeJSON(w, http.StatusOK, map[string]any{
+    "hours": hours,
+    "burst_attempts": threshold,
+    "burst_window_sec": int64(window / time.Second),
+    "auto_block": s.cfg.Intrusion.AutoBlock,
+    "ssh_error": sshErr,
+    "attempts": recent,
+    "addresses": addresses,
+    "blocks": s.intrusions.blockList(),
+  })
+}
+
+type intrusionBlockRequest struct {
+  IP string `json:"ip"`
+  Minutes int `json:"minutes"`
+}
+
+// handleIntrusionBlock serves POST /api/security/intrusions/blocks.
+func (s *Server) handleIntrusionBlock(w http.ResponseWriter, r *http.Request) {
+  var req intrusionBlockRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  ip := net.ParseIP(strings.TrimSpace(req.IP))
+  if ip == nil {
+    writeError(w, http.StatusBadRequest, "invalid ip")
+    return
+  }
+  d := s.cfg.Intrusion.BlockFor()
+  if req.Minutes > 0 {
+    d = time.Duration(req.Minutes) * time.Minute
+  }
+  if req.Minutes < 0 || d > intrusionMaxBlock {
+    writeError(w, http.StatusBadRequest, "minutes must be 1-43200")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  block, err := s.blockAddress(ctx, ip.String(), d, "manual", time.Now())
+  switch {
+  case errors.Is(err, errIntrusionLocal):
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  case errors.Is(err, errFirewallReadOnly):
+    writeError(w, http.StatusConflict, err.Error())
+    return
+  case err != nil:
+    writeError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+  writeJSON(w, http.StatusOK, block)
+}
+
+// handleIntrusionUnblock serves DELETE /api/security/intrusions/blocks/{ip}.
+func (s *Server) handleIntrusionUnblock(w http.ResponseWriter, r *http.Request) {
+  ip := net.ParseIP(strings.TrimSpace(chi.URLParam(r, "ip")))
+  if ip == nil {
+    writeError(w, http.StatusBadRequest, "invalid ip")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  found, err := s.unblockAddress(ctx, ip.String())
+  switch {
+  case errors.Is(err, errFirewallReadOnly):
+    writeError(w, http.StatusConflict, err.Error())
+    return
+  case err != nil:
+    writeError(w, http.StatusInternalServerError, err.Error())
+    return
+  case !found:
+    writeError(w, http.StatusNotFound, "block not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
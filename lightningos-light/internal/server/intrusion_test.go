@@ -0,0 +1,94 @@
+package server
+
+import (
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func TestParseSSHFailure(t *testing.T) {
+  cases := []struct {
+    message string
+    ip string
+    user string
+    ok bool
+  }{
+    {"Failed password for root from 203.0.113.9 port 52144 ssh2", "203.0.113.9", "root", true},
+    {"Failed publickey for admin from 2001:db8::7 port 40022 ssh2: ED25519 SHA256:abc", "2001:db8::7", "admin", true},
+    {"Invalid user oracle from 198.51.100.4 port 33210", "198.51.100.4", "oracle", true},
+    {"Invalid user  from 198.51.100.4 port 33210", "198.51.100.4", "", true},
+    // Counted by the Invalid user line already.
+    {"Failed password for invalid user oracle from 198.51.100.4 port 33210 ssh2", "", "", false},
+    {"Accepted publickey for admin from 192.168.1.20 port 50000 ssh2", "", "", false},
+    {"Connection closed by authenticating user root 203.0.113.9 port 52144 [preauth]", "", "", false},
+  }
+  for _, c := range cases {
+    ip, user, ok := parseSSHFailure(c.message)
+    if ip != c.ip || user != c.user || ok != c.ok {
+      t.Errorf("%q = %q %q %v", c.message, ip, user, ok)
+    }
+  }
+}
+
+func TestIntrusionBursts(t *testing.T) {
+  m := newIntrusionMonitor(filepath.Join(t.TempDir(), "blocks.json"))
+  now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+  for i := 0; i < 5; i++ {
+    m.record(now, intrusionAttempt{At: now.Add(-time.Duration(i) * time.Minute), Source: intrusionSourceSSH, IP: "203.0.113.9", User: "root"})
+  }
+  m.record(now,
+    intrusionAttempt{At: now.Add(-time.Minute), Source: intrusionSourceManager, IP: "203.0.113.9", User: "admin"},
+    intrusionAttempt{At: now.Add(-20 * time.Minute), Source: intrusionSourceSSH, IP: "198.51.100.4"},
+    intrusionAttempt{At: now.Add(-30 * time.Hour), Source: intrusionSourceSSH, IP: "198.51.100.4"},
+  )
+
+  bursts := m.takeBursts(now, 5, 10*time.Minute)
+  if len(bursts) != 1 || bursts[0].IP != "203.0.113.9" || bursts[0].Attempts != 6 || len(bursts[0].Sources) != 2 {
+    t.Fatalf("bursts = %+v", bursts)
+  }
+  // Reported once per window.
+  if bursts := m.takeBursts(now.Add(time.Minute), 5, 10*time.Minute); len(bursts) != 0 {
+    t.Fatalf("reported again: %+v", bursts)
+  }
+
+  recent, addresses := m.summary(now.Add(-24 * time.Hour))
+  if len(recent) != 7 {
+    t.Fatalf("recent = %d, the attempt older than a day should be gone", len(recent))
+  }
+  if len(addresses) != 2 || addresses[0].IP != "203.0.113.9" || addresses[0].Attempts != 6 || len(addresses[0].Users) != 2 {
+    t.Fatalf("addresses = %+v", addresses)
+  }
+}
+
+func TestIntrusionBlocksPersist(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "blocks.json")
+  now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+  m := newIntrusionMonitor(path)
+  if err := m.setBlock(intrusionBlock{IP: "203.0.113.9", Reason: "burst", BlockedAt: now, Until: now.Add(time.Hour)}); err != nil {
+    t.Fatal(err)
+  }
+
+  reloaded := newIntrusionMonitor(path)
+  if !reloaded.blocked("203.0.113.9") {
+    t.Fatal("block not reloaded")
+  }
+  if expired := reloaded.expiredBlocks(now.Add(30 * time.Minute)); len(expired) != 0 {
+    t.Fatalf("expired early: %+v", expired)
+  }
+  if expired := reloaded.expiredBlocks(now.Add(time.Hour)); len(expired) != 1 {
+    t.Fatalf("expired = %+v", expired)
+  }
+}
+
+func TestNeverBlocked(t *testing.T) {
+  for _, ip := range []string{"127.0.0.1", "192.168.1.20", "10.1.2.3", "fd00::1", "fe80::1", "::1", "bogus"} {
+    if !neverBlocked(ip) {
+      t.Errorf("%s would be blocked", ip)
+    }
+  }
+  for _, ip := range []string{"203.0.113.9", "2001:db8::7"} {
+    if neverBlocked(ip) {
+      t.Errorf("%s would not be blocked", ip)
+    }
+  }
+}
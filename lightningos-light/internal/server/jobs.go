@@ -0,0 +1,703 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "net/http"
+  "runtime/debug"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/go-chi/chi/v5"
+  "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+  jobStateRunning = "running"
+  jobStateDone = "done"
+  jobStateFailed = "failed"
+  jobStateCancelled = "cancelled"
+  jobStateInterrupted = "interrupted"
+
+  jobFlushInterval = 2 * time.Second
+  jobMemoryRetention = time.Hour
+  jobDBRetention = 30 * 24 * time.Hour
+  jobListDefaultLimit = 50
+  jobListMaxLimit = 500
+)
+
+const (
+  jobKindChannelClose = "channel_close"
+  jobKindAppInstall = "app_install"
+  jobKindAppUninstall = "app_uninstall"
+  jobKindReportsBackfill = "reports_backfill"
+)
+
+var errJobRunning = errors.New("a job of this kind is already running")
+
+// Job is a long operation run in the background. The request that starts it
+// gets the id back and follows it through GET /api/jobs/{id}; jobs are kept
+// in Postgres so they are still listed after a manager restart, and
+// resumable kinds are started again.
+type Job struct {
+  ID string `json:"id"`
+  NodeID string `json:"node_id"`
+  Kind string `json:"kind"`
+  Key string `json:"key,omitempty"`
+  State string `json:"state"`
+  Params json.RawMessage `json:"params,omitempty"`
+  Done int `json:"done"`
+  Total int `json:"total"`
+  Progress json.RawMessage `json:"progress,omitempty"`
+  Result json.RawMessage `json:"result,omitempty"`
+  Error string `json:"error,omitempty"`
+  CreatedAt time.Time `json:"created_at"`
+  UpdatedAt time.Time `json:"updated_at"`
+  FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (j Job) finished() bool {
+  return j.State != jobStateRunning
+}
+
+// jobKind runs one kind of job. Resumable kinds are started again with the
+// same params when the manager comes back while they were running; the
+// others are marked interrupted.
+type jobKind struct {
+  run func(ctx context.Context, job *jobHandle) (any, error)
+  resumable bool
+}
+
+type liveJob struct {
+  job Job
+  cancel context.CancelFunc
+  changed chan struct{}
+  flushedAt time.Time
+  persistMu sync.Mutex
+  persistedAt time.Time
+}
+
+type jobManager struct {
+  // root is the server's root context: jobs run under it and stop with it.
+  root context.Context
+  mu sync.Mutex
+  db *pgxpool.Pool
+  logger *log.Logger
+  kinds map[string]jobKind
+  live map[string]*liveJob
+}
+
+// jobHandle is what a running job sees of itself.
+type jobHandle struct {
+  m *jobManager
+  id string
+  nodeID string
+  params json.RawMessage
+}
+
+func newJobManager(root context.Context, logger *log.Logger) *jobManager {
+  return &jobManager{
+    root: root,
+    logger: logger,
+    kinds: map[string]jobKind{},
+    live: map[string]*liveJob{},
+  }
+}
+
+// registerJobKinds lists every kind of job the manager knows how to run.
+func (s *Server) registerJobKinds() {
+  s.jobs.register(jobKindBoostPeers, jobKind{run: s.runBoostPeersJob, resumable: true})
+  s.jobs.register(jobKindChannelClose, jobKind{run: s.runChannelCloseJob})
+  s.jobs.register(jobKindChannelFeeApply, jobKind{run: s.runChannelFeeApplyJob, resumable: true})
+  s.jobs.register(jobKindAppInstall, jobKind{run: func(ctx context.Context, job *jobHandle) (any, error) {
+    return s.runAppJob(ctx, job, true)
+  }})
+  s.jobs.register(jobKindAppUninstall, jobKind{run: func(ctx context.Context, job *jobHandle) (any, error) {
+    return s.runAppJob(ctx, job, false)
+  }})
+  s.jobs.register(jobKindReportsBackfill, jobKind{run: s.runReportsBackfillJob, resumable: true})
+  s.jobs.register(jobKindSystemPower, jobKind{run: s.runSystemPowerJob})
+  s.jobs.register(jobKindLNDDBCheck, jobKind{run: s.runLNDDBCheckJob})
+  s.jobs.register(jobKindLNDRescan, jobKind{run: s.runLNDRescanJob})
+  s.jobs.register(jobKindLNDgImport, jobKind{run: s.runLNDgImportJob})
+  s.jobs.register(jobKindRebalance, jobKind{run: s.runRebalanceJob})
+}
+
+func (m *jobManager) register(kind string, def jobKind) {
+  m.mu.Lock()
+  m.kinds[kind] = def
+  m.mu.Unlock()
+}
+
+func (h *jobHandle) NodeID() string {
+  return h.nodeID
+}
+
+func (h *jobHandle) Params(dst any) error {
+  if len(h.params) == 0 {
+    return nil
+  }
+  return json.Unmarshal(h.params, dst)
+}
+
+// Progress publishes how far the job got; detail is optional and replaces
+// the previous one. Postgres is written at most every couple of seconds.
+func (h *jobHandle) Progress(done int, total int, detail any) {
+  var raw json.RawMessage
+  if detail != nil {
+    if encoded, err := json.Marshal(detail); err == nil {
+      raw = encoded
+    }
+  }
+  h.m.mu.Lock()
+  lj := h.m.live[h.id]
+  if lj == nil {
+    h.m.mu.Unlock()
+    return
+  }
+  lj.job.Done = done
+  lj.job.Total = total
+  if raw != nil {
+    lj.job.Progress = raw
+  }
+  lj.job.UpdatedAt = time.Now().UTC()
+  h.m.notifyLocked(lj)
+  flush := time.Since(lj.flushedAt) >= jobFlushInterval
+  if flush {
+    lj.flushedAt = time.Now()
+  }
+  snapshot := lj.job
+  h.m.mu.Unlock()
+  if flush {
+    h.m.persistLive(lj, snapshot)
+  }
+}
+
+func (m *jobManager) notifyLocked(lj *liveJob) {
+  close(lj.changed)
+  lj.changed = make(chan struct{})
+}
+
+func (m *jobManager) ensureSchema(ctx context.Context) error {
+  _, err := m.db.Exec(ctx, `
+create table if not exists manager_jobs (
+  id text primary key,
+  node_id text not null default '',
+  kind text not null,
+  job_key text not null default '',
+  state text not null,
+  params jsonb,
+  done integer not null default 0,
+  total integer not null default 0,
+  progress jsonb,
+  result jsonb,
+  error text not null default '',
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  finished_at timestamptz
+);
+create index if not exists manager_jobs_node_created_idx on manager_jobs (node_id, created_at desc);
+`)
+  return err
+}
+
+func nullableJSON(raw json.RawMessage) any {
+  if len(raw) == 0 {
+    return nil
+  }
+  return string(raw)
+}
+
+// persistLive writes a snapshot of a live job unless a newer one was already
+// written, so a slow progress flush cannot overwrite the final state.
+func (m *jobManager) persistLive(lj *liveJob, job Job) {
+  lj.persistMu.Lock()
+  defer lj.persistMu.Unlock()
+  if job.UpdatedAt.Before(lj.persistedAt) {
+    return
+  }
+  lj.persistedAt = job.UpdatedAt
+  m.persist(job)
+}
+
+func (m *jobManager) persist(job Job) {
+  m.mu.Lock()
+  db := m.db
+  m.mu.Unlock()
+  if db == nil {
+    return
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  _, err := db.Exec(ctx, `
+insert into manager_jobs (id, node_id, kind, job_key, state, params, done, total, progress, result, error, created_at, updated_at, finished_at)
+values ($1, $2, $3, $4, $5, $6::jsonb, $7, $8, $9::jsonb, $10::jsonb, $11, $12, $13, $14)
+on conflict (id) do update set state=excluded.state, done=excluded.done, total=excluded.total,
+  progress=excluded.progress, result=excluded.result, error=excluded.error,
+  updated_at=excluded.updated_at, finished_at=excluded.finished_at
+`, job.ID, job.NodeID, job.Kind, job.Key, job.State, nullableJSON(job.Params), job.Done, job.Total,
+    nullableJSON(job.Progress), nullableJSON(job.Result), job.Error, job.CreatedAt, job.UpdatedAt, job.FinishedAt)
+  if err != nil {
+    m.logger.Printf("jobs: failed to store %s: %v", job.ID, err)
+  }
+}
+
+// submit starts a job of kind for the node. A key scopes the "one at a time"
+// rule: while a job with the same node and key runs, another is refused.
+func (m *jobManager) submit(nodeID string, kind string, key string, params any) (Job, error) {
+  raw, err := json.Marshal(params)
+  if err != nil {
+    return Job{}, err
+  }
+  id, err := randomToken(12)
+  if err != nil {
+    return Job{}, err
+  }
+  now := time.Now().UTC()
+  job := Job{
+    ID: id,
+    NodeID: nodeID,
+    Kind: kind,
+    Key: key,
+    State: jobStateRunning,
+    Params: raw,
+    CreatedAt: now,
+    UpdatedAt: now,
+  }
+  if err := m.launch(job); err != nil {
+    return Job{}, err
+  }
+  return job, nil
+}
+
+func (m *jobManager) launch(job Job) error {
+  m.mu.Lock()
+  def, ok := m.kinds[job.Kind]
+  if !ok {
+    m.mu.Unlock()
+    return fmt.Errorf("unknown job kind %s", job.Kind)
+  }
+  m.pruneLocked()
+  for _, lj := range m.live {
+    if lj.job.State == jobStateRunning && lj.job.NodeID == job.NodeID && lj.job.Kind == job.Kind && lj.job.Key == job.Key {
+      m.mu.Unlock()
+      return errJobRunning
+    }
+  }
+  ctx, cancel := context.WithCancel(m.root)
+  lj := &liveJob{job: job, cancel: cancel, changed: make(chan struct{}), flushedAt: time.Now()}
+  m.live[job.ID] = lj
+  m.mu.Unlock()
+
+  m.persistLive(lj, job)
+  handle := &jobHandle{m: m, id: job.ID, nodeID: job.NodeID, params: job.Params}
+  go m.execute(ctx, lj, def, handle)
+  return nil
+}
+
+func (m *jobManager) execute(ctx context.Context, lj *liveJob, def jobKind, handle *jobHandle) {
+  var (
+    result any
+    err error
+  )
+  func() {
+    defer func() {
+      if recovered := recover(); recovered != nil {
+        err = fmt.Errorf("job panicked: %v", recovered)
+        crashLog.record("job/"+lj.job.Kind, recovered, debug.Stack())
+      }
+    }()
+    result, err = def.run(ctx, handle)
+  }()
+  if m.root.Err() != nil {
+    // Shutting down: the row stays running, so the next start resumes the
+    // job or marks it interrupted.
+    lj.cancel()
+    m.mu.Lock()
+    delete(m.live, lj.job.ID)
+    m.mu.Unlock()
+    return
+  }
+
+  state := jobStateDone
+  errMsg := ""
+  switch {
+  case ctx.Err() != nil:
+    state = jobStateCancelled
+  case err != nil:
+    state = jobStateFailed
+    errMsg = err.Error()
+  }
+  lj.cancel()
+
+  var raw json.RawMessage
+  if result != nil {
+    if encoded, encErr := json.Marshal(result); encErr == nil {
+      raw = encoded
+    }
+  }
+  now := time.Now().UTC()
+  m.mu.Lock()
+  lj.job.State = state
+  lj.job.Error = errMsg
+  lj.job.Result = raw
+  lj.job.UpdatedAt = now
+  lj.job.FinishedAt = &now
+  m.notifyLocked(lj)
+  snapshot := lj.job
+  m.mu.Unlock()
+  m.persistLive(lj, snapshot)
+  if state == jobStateFailed {
+    m.logger.Printf("jobs: %s %s failed: %s", snapshot.Kind, snapshot.ID, errMsg)
+  }
+}
+
+func (m *jobManager) pruneLocked() {
+  for id, lj := range m.live {
+    if lj.job.finished() && lj.job.FinishedAt != nil && time.Since(*lj.job.FinishedAt) > jobMemoryRetention {
+      delete(m.live, id)
+    }
+  }
+}
+
+// attach starts persisting to Postgres, drops old rows and picks up the jobs
+// that were running when the manager stopped.
+func (m *jobManager) attach(db *pgxpool.Pool) {
+  if db == nil {
+    return
+  }
+  m.mu.Lock()
+  m.db = db
+  m.mu.Unlock()
+
+  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+  defer cancel()
+  if err := m.ensureSchema(ctx); err != nil {
+    m.logger.Printf("jobs: failed to init schema: %v", err)
+    m.mu.Lock()
+    m.db = nil
+    m.mu.Unlock()
+    return
+  }
+  if _, err := db.Exec(ctx, `delete from manager_jobs where finished_at is not null and finished_at < $1`, time.Now().Add(-jobDBRetention)); err != nil {
+    m.logger.Printf("jobs: failed to prune: %v", err)
+  }
+
+  rows, err := db.Query(ctx, `select `+jobColumns+` from manager_jobs where state=$1`, jobStateRunning)
+  if err != nil {
+    m.logger.Printf("jobs: failed to load unfinished jobs: %v", err)
+    return
+  }
+  unfinished, err := scanJobs(rows)
+  if err != nil {
+    m.logger.Printf("jobs: failed to load unfinished jobs: %v", err)
+    return
+  }
+  for _, job := range unfinished {
+    m.mu.Lock()
+    def, known := m.kinds[job.Kind]
+    _, running := m.live[job.ID]
+    m.mu.Unlock()
+    if running {
+      continue
+    }
+    if known && def.resumable {
+      m.logger.Printf("jobs: resuming %s %s", job.Kind, job.ID)
+      job.UpdatedAt = time.Now().UTC()
+      if err := m.launch(job); err == nil {
+        continue
+      }
+    }
+    now := time.Now().UTC()
+    job.State = jobStateInterrupted
+    job.Error = "interrupted by a manager restart"
+    job.UpdatedAt = now
+    job.FinishedAt = &now
+    m.persist(job)
+  }
+}
+
+const jobColumns = `id, node_id, kind, job_key, state, coalesce(params::text, ''), done, total,
+  coalesce(progress::text, ''), coalesce(result::text, ''), error, created_at, updated_at, finished_at`
+
+func scanJobs(rows pgx.Rows) ([]Job, error) {
+  defer rows.Close()
+  items := []Job{}
+  for rows.Next() {
+    var job Job
+    var params, progress, result string
+    if err := rows.Scan(&job.ID, &job.NodeID, &job.Kind, &job.Key, &job.State, &params, &job.Done, &job.Total,
+      &progress, &result, &job.Error, &job.CreatedAt, &job.UpdatedAt, &job.FinishedAt); err != nil {
+      return nil, err
+    }
+    if params != "" {
+      job.Params = json.RawMessage(params)
+    }
+    if progress != "" {
+      job.Progress = json.RawMessage(progress)
+    }
+    if result != "" {
+      job.Result = json.RawMessage(result)
+    }
+    items = append(items, job)
+  }
+  return items, rows.Err()
+}
+
+// get returns the job of the node, preferring the in-memory copy of a job
+// that is still running.
+func (m *jobManager) get(ctx context.Context, nodeID string, id string) (Job, bool, error) {
+  m.mu.Lock()
+  lj := m.live[id]
+  db := m.db
+  var snapshot Job
+  if lj != nil {
+    snapshot = lj.job
+  }
+  m.mu.Unlock()
+  if lj != nil {
+    return snapshot, snapshot.NodeID == nodeID, nil
+  }
+  if db == nil {
+    return Job{}, false, nil
+  }
+  rows, err := db.Query(ctx, `select `+jobColumns+` from manager_jobs where id=$1 and node_id=$2`, id, nodeID)
+  if err != nil {
+    return Job{}, false, err
+  }
+  items, err := scanJobs(rows)
+  if err != nil || len(items) == 0 {
+    return Job{}, false, err
+  }
+  return items[0], true, nil
+}
+
+func (m *jobManager) list(ctx context.Context, nodeID string, kind string, limit int) ([]Job, error) {
+  byID := map[string]Job{}
+  m.mu.Lock()
+  db := m.db
+  for id, lj := range m.live {
+    if lj.job.NodeID == nodeID && (kind == "" || lj.job.Kind == kind) {
+      byID[id] = lj.job
+    }
+  }
+  m.mu.Unlock()
+  if db != nil {
+    rows, err := db.Query(ctx, `select `+jobColumns+` from manager_jobs
+where node_id=$1 and ($2 = '' or kind=$2) order by created_at desc limit $3`, nodeID, kind, limit)
+    if err != nil {
+      return nil, err
+    }
+    stored, err := scanJobs(rows)
+    if err != nil {
+      return nil, err
+    }
+    for _, job := range stored {
+      if _, live := byID[job.ID]; !live {
+        byID[job.ID] = job
+      }
+    }
+  }
+  items := make([]Job, 0, len(byID))
+  for _, job := range byID {
+    items = append(items, job)
+  }
+  sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+  if len(items) > limit {
+    items = items[:limit]
+  }
+  return items, nil
+}
+
+// running returns the jobs still running on any node.
+func (m *jobManager) running() []Job {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  items := []Job{}
+  for _, lj := range m.live {
+    if lj.job.State == jobStateRunning {
+      items = append(items, lj.job)
+    }
+  }
+  sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+  return items
+}
+
+func (m *jobManager) cancel(nodeID string, id string) (Job, bool) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  lj := m.live[id]
+  if lj == nil || lj.job.NodeID != nodeID {
+    return Job{}, false
+  }
+  lj.cancel()
+  return lj.job, true
+}
+
+// watch returns the current state of a live job and a channel closed on its
+// next change.
+func (m *jobManager) watch(id string) (Job, <-chan struct{}, bool) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  lj := m.live[id]
+  if lj == nil {
+    return Job{}, nil, false
+  }
+  return lj.job, lj.changed, true
+}
+
+// wait blocks until the job finishes or ctx ends and returns its latest
+// state, so quick jobs can still answer within the request that started them.
+func (m *jobManager) wait(ctx context.Context, id string) Job {
+  return m.waitUntil(ctx, id, func(Job) bool { return false })
+}
+
+// waitUntil is wait for jobs that outlive their request: it also returns as
+// soon as reached reports the job far enough along.
+func (m *jobManager) waitUntil(ctx context.Context, id string, reached func(Job) bool) Job {
+  for {
+    job, changed, ok := m.watch(id)
+    if !ok || job.finished() || reached(job) {
+      return job
+    }
+    select {
+    case <-ctx.Done():
+      return job
+    case <-changed:
+    }
+  }
+}
+
+// writeJobStarted answers a request that started a job: 200 with the job
+// when it already finished, 202 while it still runs. A failed job is reported
+// as an error so callers keep seeing the operation's message.
+func writeJobStarted(w http.ResponseWriter, job Job) {
+  switch job.State {
+  case jobStateRunning:
+    writeJSON(w, http.StatusAccepted, job)
+  case jobStateFailed:
+    writeError(w, http.StatusInternalServerError, job.Error)
+  default:
+    writeJSON(w, http.StatusOK, job)
+  }
+}
+
+func writeJobSubmitError(w http.ResponseWriter, err error) {
+  if errors.Is(err, errJobRunning) {
+    writeError(w, http.StatusConflict, err.Error())
+    return
+  }
+  writeError(w, http.StatusInternalServerError, "failed to start job")
+}
+
+func (s *Server) handleJobsList(w http.ResponseWriter, r *http.Request) {
+  limit := jobListDefaultLimit
+  if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed <= 0 {
+      writeError(w, http.StatusBadRequest, "invalid limit")
+      return
+    }
+    limit = parsed
+  }
+  if limit > jobListMaxLimit {
+    limit = jobListMaxLimit
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  items, err := s.jobs.list(ctx, s.nodeIDFor(r), strings.TrimSpace(r.URL.Query().Get("kind")), limit)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load jobs")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Server) handleJobGet(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  job, ok, err := s.jobs.get(ctx, s.nodeIDFor(r), chi.URLParam(r, "id"))
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load jobs")
+    return
+  }
+  if !ok {
+    writeError(w, http.StatusNotFound, "job not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) handleJobCancel(w http.ResponseWriter, r *http.Request) {
+  job, ok := s.jobs.cancel(s.nodeIDFor(r), chi.URLParam(r, "id"))
+  if !ok {
+    writeError(w, http.StatusNotFound, "job not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, job)
+}
+
+// handleJobStream sends the job on every change and closes once it has
+// finished.
+func (s *Server) handleJobStream(w http.ResponseWriter, r *http.Request) {
+  id := chi.URLParam(r, "id")
+  nodeID := s.nodeIDFor(r)
+  job, _, live := s.jobs.watch(id)
+  if !live || job.NodeID != nodeID {
+    ctx, cancel := s.requestContext(r, timeoutShort)
+    stored, ok, err := s.jobs.get(ctx, nodeID, id)
+    cancel()
+    if err != nil || !ok {
+      writeError(w, http.StatusNotFound, "job not found")
+      return
+    }
+    job = stored
+  }
+  flusher, ok := w.(http.Flusher)
+  if !ok {
+    writeError(w, http.StatusInternalServerError, "stream not supported")
+    return
+  }
+
+  w.Header().Set("Content-Type", "text/event-stream")
+  w.Header().Set("Cache-Control", "no-cache")
+  w.Header().Set("Connection", "keep-alive")
+
+  ticker := time.NewTicker(25 * time.Second)
+  defer ticker.Stop()
+
+  for {
+    var changed <-chan struct{}
+    if current, ch, ok := s.jobs.watch(id); ok {
+      job, changed = current, ch
+    }
+    if payload, err := json.Marshal(job); err == nil {
+      _, _ = fmt.Fprintf(w, "data: %s\n\n", payload)
+      flusher.Flush()
+    }
+    if job.finished() || changed == nil {
+      _, _ = w.Write([]byte("event: done\ndata: {}\n\n"))
+      flusher.Flush()
+      return
+    }
+  wait:
+    for {
+      select {
+      case <-r.Context().Done():
+        return
+      case <-changed:
+        break wait
+      case <-ticker.C:
+        _, _ = w.Write([]byte("event: heartbeat\ndata: {}\n\n"))
+        flusher.Flush()
+      }
+    }
+  }
+}
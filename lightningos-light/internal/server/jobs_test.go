@@ -0,0 +1,97 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "io"
+  "log"
+  "testing"
+  "time"
+)
+
+func TestJobManagerLifecycle(t *testing.T) {
+  m := newJobManager(context.Background(), log.New(io.Discard, "", 0))
+  release := make(chan struct{})
+  m.register("test", jobKind{run: func(ctx context.Context, job *jobHandle) (any, error) {
+    var params struct {
+      Fail bool `json:"fail"`
+    }
+    if err := job.Params(&params); err != nil {
+      return nil, err
+    }
+    job.Progress(1, 2, map[string]string{"step": "first"})
+    select {
+    case <-ctx.Done():
+      return nil, ctx.Err()
+    case <-release:
+    }
+    if params.Fail {
+      return nil, errors.New("boom")
+    }
+    return map[string]int{"answer": 42}, nil
+  }})
+
+  if _, err := m.submit("main", "missing", "", nil); err == nil {
+    t.Fatalf("unknown kinds must be refused")
+  }
+
+  job, err := m.submit("main", "test", "a", map[string]bool{"fail": false})
+  if err != nil {
+    t.Fatalf("submit: %v", err)
+  }
+  if _, err := m.submit("main", "test", "a", nil); !errors.Is(err, errJobRunning) {
+    t.Fatalf("expected errJobRunning, got %v", err)
+  }
+  if _, ok, _ := m.get(context.Background(), "other", job.ID); ok {
+    t.Fatalf("job visible from another node")
+  }
+
+  close(release)
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  defer cancel()
+  done := m.wait(ctx, job.ID)
+  if done.State != jobStateDone || done.Done != 1 || done.Total != 2 || done.FinishedAt == nil {
+    t.Fatalf("unexpected final job: %+v", done)
+  }
+  var result map[string]int
+  if err := json.Unmarshal(done.Result, &result); err != nil || result["answer"] != 42 {
+    t.Fatalf("unexpected result %s: %v", done.Result, err)
+  }
+
+  failing, err := m.submit("main", "test", "a", map[string]bool{"fail": true})
+  if err != nil {
+    t.Fatalf("a finished job must not block a new one: %v", err)
+  }
+  if failed := m.wait(ctx, failing.ID); failed.State != jobStateFailed || failed.Error != "boom" {
+    t.Fatalf("unexpected failed job: %+v", failed)
+  }
+
+  items, err := m.list(context.Background(), "main", "test", 10)
+  if err != nil || len(items) != 2 || items[0].ID != failing.ID {
+    t.Fatalf("unexpected list: %+v, %v", items, err)
+  }
+}
+
+func TestJobManagerCancel(t *testing.T) {
+  m := newJobManager(context.Background(), log.New(io.Discard, "", 0))
+  m.register("wait", jobKind{run: func(ctx context.Context, job *jobHandle) (any, error) {
+    <-ctx.Done()
+    return nil, ctx.Err()
+  }})
+  job, err := m.submit("main", "wait", "", nil)
+  if err != nil {
+    t.Fatalf("submit: %v", err)
+  }
+  if _, ok := m.cancel("other", job.ID); ok {
+    t.Fatalf("jobs of another node must not be cancellable")
+  }
+  if _, ok := m.cancel("main", job.ID); !ok {
+    t.Fatalf("cancel failed")
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  defer cancel()
+  if final := m.wait(ctx, job.ID); final.State != jobStateCancelled {
+    t.Fatalf("expected a cancelled job, got %+v", final)
+  }
+}
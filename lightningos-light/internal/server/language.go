@@ -0,0 +1,49 @@
+package server
+
+import (
+  "bufio"
+  "net"
+  "net/http"
+
+  "lightningos-light/internal/i18n"
+)
+
+// withLanguage resolves the response language from Accept-Language (the UI
+// sends its selected language) with ui.language from config.yaml as fallback.
+func (s *Server) withLanguage() func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      lang := i18n.Resolve(r.Header.Get("Accept-Language"), s.cfg.UI.Language)
+      w.Header().Set("Content-Language", lang)
+      w.Header().Add("Vary", "Accept-Language")
+      next.ServeHTTP(&languageWriter{ResponseWriter: w, lang: lang}, r)
+    })
+  }
+}
+
+type languageWriter struct {
+  http.ResponseWriter
+  lang string
+}
+
+func (w *languageWriter) Flush() {
+  if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+    flusher.Flush()
+  }
+}
+
+func (w *languageWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+  if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+    return hijacker.Hijack()
+  }
+  return nil, nil, http.ErrNotSupported
+}
+
+// localize translates a user-facing message into the language of the request
+// being answered on w.
+func localize(w http.ResponseWriter, message string) string {
+  if lw, ok := w.(*languageWriter); ok {
+    return i18n.Translate(lw.lang, message)
+  }
+  return message
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "math"
+  "net/http"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+const (
+  liquiditySampleInterval = 5 * time.Minute
+  liquiditySnapshotInterval = time.Hour
+  liquidityHistoryDefaultDays = 30
+  liquidityHistoryMaxDays = 365
+  liquidityRetention = 365 * 24 * time.Hour
+)
+
+// liquidityReport scores how well a node's liquidity sits for both routing
+// directions. Only active channels count; an inactive channel can neither
+// send nor receive right now.
+type liquidityReport struct {
+  NodeID string `json:"node_id"`
+  TakenAt time.Time `json:"taken_at"`
+  // Score is 0-100: half from how close inbound_share is to 50%, a quarter
+  // each from how evenly inbound and outbound are spread over channels.
+  Score float64 `json:"score"`
+  Channels int `json:"channels"`
+  InboundSat int64 `json:"inbound_sat"`
+  OutboundSat int64 `json:"outbound_sat"`
+  InboundShare float64 `json:"inbound_share"`
+  InboundSpread float64 `json:"inbound_spread"`
+  OutboundSpread float64 `json:"outbound_spread"`
+  // Receivable and sendable come from estimateCapacity with LND's default
+  // MPP split; the single_* values are the most one channel can carry.
+  MaxReceivableSat int64 `json:"max_receivable_sat"`
+  MaxSendableSat int64 `json:"max_sendable_sat"`
+  MaxSingleReceivableSat int64 `json:"max_single_receivable_sat"`
+  MaxSingleSendableSat int64 `json:"max_single_sendable_sat"`
+}
+
+// liquidityTracker keeps the latest report per node for the health check.
+type liquidityTracker struct {
+  mu sync.Mutex
+  latest map[string]liquidityReport
+  savedAt map[string]time.Time
+}
+
+func newLiquidityTracker() *liquidityTracker {
+  return &liquidityTracker{latest: map[string]liquidityReport{}, savedAt: map[string]time.Time{}}
+}
+
+func (t *liquidityTracker) get(nodeID string) (liquidityReport, bool) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  report, ok := t.latest[nodeID]
+  return report, ok
+}
+
+// set stores the report and says whether a history snapshot is due.
+func (t *liquidityTracker) set(report liquidityReport) bool {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  t.latest[report.NodeID] = report
+  if report.TakenAt.Sub(t.savedAt[report.NodeID]) < liquiditySnapshotInterval {
+    return false
+  }
+  t.savedAt[report.NodeID] = report.TakenAt
+  return true
+}
+
+func computeLiquidity(nodeID string, channels []lndclient.ChannelInfo, now time.Time) liquidityReport {
+  report := liquidityReport{NodeID: nodeID, TakenAt: now}
+  inbound, outbound := []int64{}, []int64{}
+  for _, ch := range channels {
+    if !ch.Active {
+      continue
+    }
+    report.Channels++
+    report.InboundSat += ch.RemoteBalanceSat
+    report.OutboundSat += ch.LocalBalanceSat
+    inbound = append(inbound, ch.RemoteBalanceSat)
+    outbound = append(outbound, ch.LocalBalanceSat)
+  }
+  capacity := estimateCapacity(channels, capacityDefaultParts)
+  report.MaxReceivableSat = capacity.MaxReceivableSat
+  report.MaxSendableSat = capacity.MaxSendableSat
+  report.MaxSingleReceivableSat = capacity.MaxSingleReceivableSat
+  report.MaxSingleSendableSat = capacity.MaxSingleSendableSat
+  if total := report.InboundSat + report.OutboundSat; total > 0 {
+    report.InboundShare = round3(float64(report.InboundSat) / float64(total))
+  }
+  report.InboundSpread = round3(liquiditySpread(inbound))
+  report.OutboundSpread = round3(liquiditySpread(outbound))
+  if report.Channels > 0 {
+    balance := 1 - math.Abs(report.InboundShare-0.5)*2
+    report.Score = math.Round((50*balance+25*report.InboundSpread+25*report.OutboundSpread)*10) / 10
+  }
+  return report
+}
+
+// liquiditySpread is 1 minus the normalised Herfindahl index of the amounts:
+// 1 when they are equal across channels, 0 when one channel holds it all.
+func liquiditySpread(amounts []int64) float64 {
+  if len(amounts) < 2 {
+    return 0
+  }
+  var total float64
+  for _, amount := range amounts {
+    total += float64(amount)
+  }
+  if total <= 0 {
+    return 0
+  }
+  var hhi float64
+  for _, amount := range amounts {
+    share := float64(amount) / total
+    hhi += share * share
+  }
+  floor := 1 / float64(len(amounts))
+  return math.Max(0, (1-hhi)/(1-floor))
+}
+
+func round3(value float64) float64 {
+  return math.Round(value*1000) / 1000
+}
+
+// liquidityIssue warns when a node with channels can receive less than the
+// configured floor.
+func (s *Server) liquidityIssue(nodeID string) (healthIssue, bool) {
+  min := s.cfg.Liquidity.MinReceivable()
+  report, ok := s.liquidity.get(nodeID)
+  if min == 0 || !ok || report.Channels == 0 || report.MaxReceivableSat >= min {
+    return healthIssue{}, false
+  }
+  return healthIssue{
+    Component: "liquidity",
+    Level: "WARN",
+    Message: fmt.Sprintf("Inbound liquidity low: can receive %d sat, below %d sat", report.MaxReceivableSat, min),
+  }, true
+}
+
+func (s *Server) ensureLiquiditySchema(ctx context.Context) error {
+  _, err := s.db.Exec(ctx, `
+create table if not exists liquidity_snapshots (
+  node_id text not null,
+  taken_at timestamptz not null,
+  score double precision not null,
+  channels integer not null,
+  inbound_sat bigint not null,
+  outbound_sat bigint not null,
+  inbound_share double precision not null,
+  inbound_spread double precision not null,
+  outbound_spread double precision not null,
+  max_receivable_sat bigint not null,
+  max_sendable_sat bigint not null,
+  max_single_receivable_sat bigint not null,
+  max_single_sendable_sat bigint not null,
+  primary key (node_id, taken_at)
+);
+`)
+  return err
+}
+
+func (s *Server) initLiquidity() {
+  if s.db != nil {
+    ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
+    if err := s.ensureLiquiditySchema(ctx); err != nil {
+      s.logger.Printf("liquidity: failed to init schema, history disabled: %v", err)
+    }
+    cancel()
+  }
+  goSafe("liquidity", s.runLiquidity)
+}
+
+// runLiquidity refreshes every node's report for the health check and keeps
+// an hourly history in Postgres.
+func (s *Server) runLiquidity() {
+  for {
+    for _, nodeID := range s.nodeIDs {
+      s.sampleLiquidity(nodeID)
+    }
+    if !s.wait(liquiditySampleInterval) {
+      return
+    }
+  }
+}
+
+func (s *Server) sampleLiquidity(nodeID string) {
+  lnd, ok := s.lndForNode(nodeID)
+  if !ok || !lnd.Ready() {
+    return
+  }
+  ctx, cancel := s.taskContext("liquidity", timeoutLNDRPC)
+  channels, err := lnd.ListChannels(ctx)
+  cancel()
+  if err != nil {
+    s.logger.Printf("liquidity: %s: list channels failed: %v", nodeID, err)
+    return
+  }
+  report := computeLiquidity(nodeID, channels, time.Now().UTC())
+  if !s.liquidity.set(report) || s.db == nil {
+    return
+  }
+  ctx, cancel = s.taskContext("liquidity", timeoutShort)
+  defer cancel()
+  _, err = s.db.Exec(ctx, `
+insert into liquidity_snapshots (node_id, taken_at, score, channels, inbound_sat, outbound_sat, inbound_share,
+  inbound_spread, outbound_spread, max_receivable_sat, max_sendable_sat, max_single_receivable_sat, max_single_sendable_sat)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+on conflict do nothing
+`, report.NodeID, report.TakenAt, report.Score, report.Channels, report.InboundSat, report.OutboundSat, report.InboundShare,
+    report.InboundSpread, report.OutboundSpread, report.MaxReceivableSat, report.MaxSendableSat,
+    report.MaxSingleReceivableSat, report.MaxSingleSendableSat)
+  if err != nil {
+    s.logger.Printf("liquidity: failed to store snapshot: %v", err)
+    return
+  }
+  _, _ = s.db.Exec(ctx, `delete from liquidity_snapshots where taken_at < $1`, time.Now().Add(-liquidityRetention))
+}
+
+func (s *Server) liquidityHistory(ctx context.Context, nodeID string, since time.Time) ([]liquidityReport, error) {
+  rows, err := s.db.Query(ctx, `
+select node_id, taken_at, score, channels, inbound_sat, outbound_sat, inbound_share, inbound_spread, outbound_spread,
+  max_receivable_sat, max_sendable_sat, max_single_receivable_sat, max_single_sendable_sat
+from liquidity_snapshots
+where node_id = $1 and taken_at >= $2
+order by taken_at
+`, nodeID, since)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  items := []liquidityReport{}
+  for rows.Next() {
+    var item liquidityReport
+    if err := rows.Scan(&item.NodeID, &item.TakenAt, &item.Score, &item.Channels, &item.InboundSat, &item.OutboundSat,
+      &item.InboundShare, &item.InboundSpread, &item.OutboundSpread, &item.MaxReceivableSat, &item.MaxSendableSat,
+      &item.MaxSingleReceivableSat, &item.MaxSingleSendableSat); err != nil {
+      return nil, err
+    }
+    items = append(items, item)
+  }
+  return items, rows.Err()
+}
+
+func (s *Server) handleLiquidity(w http.ResponseWriter, r *http.Request) {
+  days := liquidityHistoryDefaultDays
+  if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 0 || parsed > liquidityHistoryMaxDays {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("days out of range: 0 to %d", liquidityHistoryMaxDays))
+      return
+    }
+    days = parsed
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  nodeID := s.nodeIDFor(r)
+  channels, err := s.lndFor(r).ListChannels(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  report := computeLiquidity(nodeID, channels, time.Now().UTC())
+
+  resp := map[string]any{
+    "current": report,
+    "min_receivable_sat": s.cfg.Liquidity.MinReceivable(),
+    "low_inbound": false,
+    "history": []liquidityReport{},
+  }
+  if min := s.cfg.Liquidity.MinReceivable(); min > 0 && report.Channels > 0 && report.MaxReceivableSat < min {
+    resp["low_inbound"] = true
+  }
+  if s.db != nil && days > 0 {
+    history, err := s.liquidityHistory(ctx, nodeID, time.Now().UTC().AddDate(0, 0, -days))
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to load liquidity history")
+      return
+    }
+    resp["history"] = history
+  }
+  writeJSON(w, http.StatusOK, resp)
+}
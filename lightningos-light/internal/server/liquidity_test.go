@@ -0,0 +1,79 @@
+package server
+
+import (
+  "encoding/json"
+  "io"
+  "log"
+  "net/http"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/lndfake"
+)
+
+func TestComputeLiquidity(t *testing.T) {
+  now := time.Now().UTC()
+  report := computeLiquidity("default", []lndclient.ChannelInfo{
+    {Active: true, LocalBalanceSat: 500000, RemoteBalanceSat: 500000},
+    {Active: true, LocalBalanceSat: 500000, RemoteBalanceSat: 500000},
+    {Active: false, LocalBalanceSat: 9000000, RemoteBalanceSat: 0},
+  }, now)
+  if report.Channels != 2 || report.InboundShare != 0.5 || report.Score != 100 {
+    t.Fatalf("balanced node scored %+v", report)
+  }
+  if report.MaxReceivableSat != 1000000 || report.MaxSingleReceivableSat != 500000 || report.MaxSendableSat != 1000000 {
+    t.Fatalf("unexpected estimates %+v", report)
+  }
+
+  report = computeLiquidity("default", []lndclient.ChannelInfo{
+    {Active: true, LocalBalanceSat: 1000000, RemoteBalanceSat: 0},
+    {Active: true, LocalBalanceSat: 1000000, RemoteBalanceSat: 0},
+  }, now)
+  if report.InboundShare != 0 || report.InboundSpread != 0 || report.Score != 25 {
+    t.Fatalf("outbound-only node scored %+v", report)
+  }
+
+  if got := liquiditySpread([]int64{100, 0, 0}); got != 0 {
+    t.Fatalf("concentrated spread %v", got)
+  }
+}
+
+func TestLiquidityLowInboundWarning(t *testing.T) {
+  fake := lndfake.New()
+  fake.Channels = []lndclient.ChannelInfo{{ChannelPoint: "aa:0", Active: true, CapacitySat: 1000000, LocalBalanceSat: 990000, RemoteBalanceSat: 10000}}
+  cfg := &config.Config{Network: "regtest"}
+  s := NewWithLND(cfg, log.New(io.Discard, "", 0), fake)
+  t.Cleanup(func() { setActiveNetwork("mainnet") })
+
+  if _, ok := s.liquidityIssue(config.DefaultNodeID); ok {
+    t.Fatal("warning before any sample")
+  }
+  s.sampleLiquidity(config.DefaultNodeID)
+  issue, ok := s.liquidityIssue(config.DefaultNodeID)
+  if !ok || issue.Component != "liquidity" || issue.Level != "WARN" {
+    t.Fatalf("low inbound not flagged: %+v", issue)
+  }
+
+  rec := serveAPI(s, http.MethodGet, "/api/lnops/liquidity", "")
+  if rec.Code != http.StatusOK {
+    t.Fatalf("liquidity: %d %s", rec.Code, rec.Body.String())
+  }
+  var body struct {
+    Current liquidityReport `json:"current"`
+    LowInbound bool `json:"low_inbound"`
+    MinReceivableSat int64 `json:"min_receivable_sat"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+    t.Fatalf("decode: %v", err)
+  }
+  if !body.LowInbound || body.MinReceivableSat != config.DefaultMinReceivableSat || body.Current.MaxReceivableSat != 10000 {
+    t.Fatalf("unexpected body: %s", rec.Body.String())
+  }
+
+  s.cfg.Liquidity.MinReceivableSat = -1
+  if _, ok := s.liquidityIssue(config.DefaultNodeID); ok {
+    t.Fatal("disabled warning still raised")
+  }
+}
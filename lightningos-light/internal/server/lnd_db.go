@@ -0,0 +1,443 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strings"
+  "sync"
+  "syscall"
+  "time"
+
+  "lightningos-light/internal/system"
+
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+  lndDBSamplesPath = "/var/lib/lightningos/lnd-db-size.json"
+  lndDBSampleInterval = time.Hour
+  lndDBSampleRetention = 30 * 24 * time.Hour
+  lndDBGrowthWindow = 7 * 24 * time.Hour
+  lndDBCompactTimeout = 45 * time.Minute
+  lndDBCompactPoll = 10 * time.Second
+  // LND writes the compacted copy next to channel.db before swapping it in.
+  lndDBCompactTempFile = "temp-dont-use.db"
+)
+
+var lndDBBackendPattern = regexp.MustCompile(`(?m)^\s*db\.backend\s*=\s*(\S+)`)
+
+type lndDBSample struct {
+  At time.Time `json:"at"`
+  Bytes int64 `json:"bytes"`
+}
+
+type lndDBCompaction struct {
+  mu sync.Mutex
+  phase string
+  startedAt time.Time
+  finishedAt time.Time
+  sizeBefore int64
+  sizeAfter int64
+  err string
+}
+
+type lndDBCompactionStatus struct {
+  Phase string `json:"phase"`
+  StartedAt *time.Time `json:"started_at,omitempty"`
+  FinishedAt *time.Time `json:"finished_at,omitempty"`
+  SizeBeforeBytes int64 `json:"size_before_bytes,omitempty"`
+  SizeAfterBytes int64 `json:"size_after_bytes,omitempty"`
+  Error string `json:"error,omitempty"`
+}
+
+type lndDBResponse struct {
+  Backend string `json:"backend"`
+  Path string `json:"path,omitempty"`
+  SizeBytes int64 `json:"size_bytes"`
+  GrowthBytesPerDay *int64 `json:"growth_bytes_per_day,omitempty"`
+  DiskFreeBytes int64 `json:"disk_free_bytes,omitempty"`
+  DeadTuples *int64 `json:"dead_tuples,omitempty"`
+  Samples []lndDBSample `json:"samples"`
+  CanCompact bool `json:"can_compact"`
+  Compaction lndDBCompactionStatus `json:"compaction"`
+  Warnings []string `json:"warnings,omitempty"`
+}
+
+func (c *lndDBCompaction) status() lndDBCompactionStatus {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  status := lndDBCompactionStatus{
+    Phase: c.phase,
+    SizeBeforeBytes: c.sizeBefore,
+    SizeAfterBytes: c.sizeAfter,
+    Error: c.err,
+  }
+  if status.Phase == "" {
+    status.Phase = "idle"
+  }
+  if !c.startedAt.IsZero() {
+    started := c.startedAt
+    status.StartedAt = &started
+  }
+  if !c.finishedAt.IsZero() {
+    finished := c.finishedAt
+    status.FinishedAt = &finished
+  }
+  return status
+}
+
+func (c *lndDBCompaction) setPhase(phase string) {
+  c.mu.Lock()
+  c.phase = phase
+  c.mu.Unlock()
+}
+
+func (c *lndDBCompaction) running() bool {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  return c.phase != "" && c.phase != "done" && c.phase != "failed"
+}
+
+// lndDBBackend reads db.backend from lnd.conf; LND defaults to bolt.
+func lndDBBackend() string {
+  raw, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    return "bolt"
+  }
+  matches := lndDBBackendPattern.FindAllStringSubmatch(string(raw), -1)
+  if len(matches) == 0 {
+    return "bolt"
+  }
+  return strings.ToLower(matches[len(matches)-1][1])
+}
+
+func (s *Server) lndDBSize(ctx context.Context, backend string) (int64, *int64, error) {
+  if backend != "postgres" {
+    info, err := os.Stat(lndChannelDBPath())
+    if err != nil {
+      return 0, nil, err
+    }
+    return info.Size(), nil, nil
+  }
+  dsn := strings.TrimSpace(os.Getenv("LND_PG_DSN"))
+  if dsn == "" || isPlaceholderDSN(dsn) {
+    return 0, nil, fmt.Errorf("LND_PG_DSN not configured")
+  }
+  pool, err := pgxpool.New(ctx, dsn)
+  if err != nil {
+    return 0, nil, err
+  }
+  defer pool.Close()
+  var size int64
+  if err := pool.QueryRow(ctx, "select pg_database_size(current_database())").Scan(&size); err != nil {
+    return 0, nil, err
+  }
+  var dead int64
+  if err := pool.QueryRow(ctx, "select coalesce(sum(n_dead_tup), 0) from pg_stat_user_tables").Scan(&dead); err != nil {
+    return size, nil, nil
+  }
+  return size, &dead, nil
+}
+
+func readLNDDBSamples() []lndDBSample {
+  raw, err := os.ReadFile(lndDBSamplesPath)
+  if err != nil {
+    return nil
+  }
+  var samples []lndDBSample
+  if err := json.Unmarshal(raw, &samples); err != nil {
+    return nil
+  }
+  return samples
+}
+
+func writeLNDDBSamples(samples []lndDBSample) error {
+  if err := os.MkdirAll(filepath.Dir(lndDBSamplesPath), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.Marshal(samples)
+  if err != nil {
+    return err
+  }
+  return os.WriteFile(lndDBSamplesPath, raw, 0o640)
+}
+
+// lndDBGrowthPerDay extrapolates daily growth from the oldest sample inside
+// the growth window.
+func lndDBGrowthPerDay(samples []lndDBSample, current int64, now time.Time) *int64 {
+  for _, sample := range samples {
+    age := now.Sub(sample.At)
+    if age > lndDBGrowthWindow || age < 12*time.Hour {
+      continue
+    }
+    growth := int64(float64(current-sample.Bytes) / age.Hours() * 24)
+    return &growth
+  }
+  return nil
+}
+
+func (s *Server) recordLNDDBSample() {
+  ctx, cancel := context.WithTimeout(s.root, 10*time.Second)
+  defer cancel()
+  size, _, err := s.lndDBSize(ctx, lndDBBackend())
+  if err != nil || size <= 0 {
+    return
+  }
+  now := time.Now().UTC()
+  kept := []lndDBSample{}
+  for _, sample := range readLNDDBSamples() {
+    if now.Sub(sample.At) <= lndDBSampleRetention {
+      kept = append(kept, sample)
+    }
+  }
+  kept = append(kept, lndDBSample{At: now, Bytes: size})
+  if err := writeLNDDBSamples(kept); err != nil && s.logger != nil {
+    s.logger.Printf("lnd db: failed to store size sample: %v", err)
+  }
+}
+
+func (s *Server) startLNDDBSampler() {
+  go func() {
+    for {
+      if !s.lndDBCompact.running() {
+        s.recordLNDDBSample()
+      }
+      if !s.wait(lndDBSampleInterval) {
+        return
+      }
+    }
+  }()
+}
+
+func diskFreeBytes(path string) int64 {
+  var stat syscall.Statfs_t
+  if err := syscall.Statfs(path, &stat); err != nil {
+    return 0
+  }
+  return int64(stat.Bavail) * int64(stat.Bsize)
+}
+
+func (s *Server) handleLNDDB(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+
+  backend := lndDBBackend()
+  resp := lndDBResponse{
+    Backend: backend,
+    Samples: readLNDDBSamples(),
+    Compaction: s.lndDBCompact.status(),
+  }
+  if resp.Samples == nil {
+    resp.Samples = []lndDBSample{}
+  }
+  size, dead, err := s.lndDBSize(ctx, backend)
+  if err != nil {
+    resp.Warnings = append(resp.Warnings, fmt.Sprintf("size unavailable: %v", err))
+  }
+  resp.SizeBytes = size
+  resp.DeadTuples = dead
+  if size > 0 {
+    resp.GrowthBytesPerDay = lndDBGrowthPerDay(resp.Samples, size, time.Now().UTC())
+  }
+  if backend == "bolt" {
+    resp.Path = lndChannelDBPath()
+    resp.DiskFreeBytes = diskFreeBytes(filepath.Dir(resp.Path))
+    resp.CanCompact = size > 0 && resp.DiskFreeBytes > size
+    if size > 0 && !resp.CanCompact {
+      resp.Warnings = append(resp.Warnings, "not enough free disk to compact (needs the size of channel.db)")
+    }
+  } else {
+    resp.Warnings = append(resp.Warnings, "compaction is only available for the bolt backend; postgres relies on autovacuum")
+  }
+  writeJSON(w, http.StatusOK, resp)
+}
+
+// handleLNDDBCompact runs a one-off bolt compaction: LND compacts channel.db
+// on startup when db.bolt.auto-compact is set, so the flow enables it with a
+// zero min age, restarts LND, waits for it to come back and restores lnd.conf.
+func (s *Server) handleLNDDBCompact(w http.ResponseWriter, r *http.Request) {
+  if s.lndPGMigrate.active() {
+    writeError(w, http.StatusConflict, "postgres migration is running")
+    return
+  }
+  if lndDBBackend() != "bolt" {
+    writeError(w, http.StatusBadRequest, "compaction is only available for the bolt backend")
+    return
+  }
+  info, err := os.Stat(lndChannelDBPath())
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "channel.db not found")
+    return
+  }
+  if diskFreeBytes(filepath.Dir(lndChannelDBPath())) <= info.Size() {
+    writeError(w, http.StatusBadRequest, "not enough free disk to compact")
+    return
+  }
+  prevConf, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to read lnd.conf")
+    return
+  }
+
+  s.lndDBCompact.mu.Lock()
+  if s.lndDBCompact.phase != "" && s.lndDBCompact.phase != "done" && s.lndDBCompact.phase != "failed" {
+    s.lndDBCompact.mu.Unlock()
+    writeError(w, http.StatusConflict, "compaction already running")
+    return
+  }
+  s.lndDBCompact.phase = "preparing"
+  s.lndDBCompact.startedAt = time.Now().UTC()
+  s.lndDBCompact.finishedAt = time.Time{}
+  s.lndDBCompact.sizeBefore = info.Size()
+  s.lndDBCompact.sizeAfter = 0
+  s.lndDBCompact.err = ""
+  s.lndDBCompact.mu.Unlock()
+
+  go s.runLNDDBCompaction(prevConf)
+  writeJSON(w, http.StatusAccepted, s.lndDBCompact.status())
+}
+
+func (s *Server) runLNDDBCompaction(prevConf []byte) {
+  defer crashLog.capture("lnd/db_compaction")
+  fail := func(msg string) {
+    s.logger.Printf("lnd db compaction failed: %s", msg)
+    s.lndDBCompact.mu.Lock()
+    s.lndDBCompact.phase = "failed"
+    s.lndDBCompact.err = msg
+    s.lndDBCompact.finishedAt = time.Now().UTC()
+    s.lndDBCompact.mu.Unlock()
+  }
+
+  updated := setLNDConfSectionOptions(string(prevConf), "bolt", map[string]string{
+    "db.bolt.auto-compact": "true",
+    "db.bolt.auto-compact-min-age": "0",
+  })
+  unlock := lockConfigFile(lndConfPath)
+  err := writeFileAtomic(lndConfPath, []byte(updated), 0660)
+  unlock()
+  if err != nil {
+    fail("failed to write lnd.conf")
+    return
+  }
+  // The original lnd.conf comes back whatever happens, so later restarts do
+  // not compact again. If someone edited it meanwhile, only the compaction
+  // options are dropped.
+  defer func() {
+    unlock := lockConfigFile(lndConfPath)
+    defer unlock()
+    restored := prevConf
+    if current, err := os.ReadFile(lndConfPath); err == nil && string(current) != updated {
+      restored = []byte(removeLNDConfOptions(string(current), "db.bolt.auto-compact", "db.bolt.auto-compact-min-age"))
+    }
+    if err := writeFileAtomic(lndConfPath, restored, 0660); err != nil {
+      s.logger.Printf("lnd db compaction: failed to restore lnd.conf: %v", err)
+    }
+  }()
+
+  s.lndDBCompact.setPhase("restarting")
+  restartCtx, restartCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+  err = system.SystemctlRestart(restartCtx, "lnd")
+  restartCancel()
+  if err != nil {
+    fail(fmt.Sprintf("failed to restart lnd: %v", err))
+    return
+  }
+  s.markLNDRestart()
+
+  s.lndDBCompact.setPhase("compacting")
+  tempPath := filepath.Join(filepath.Dir(lndChannelDBPath()), lndDBCompactTempFile)
+  deadline := time.Now().Add(lndDBCompactTimeout)
+  for {
+    if time.Now().After(deadline) {
+      fail("timed out waiting for lnd to come back")
+      return
+    }
+    time.Sleep(lndDBCompactPoll)
+    if _, err := os.Stat(tempPath); err == nil {
+      continue
+    }
+    pingCtx, pingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+    up := s.lnd.Reachable(pingCtx)
+    pingCancel()
+    if up {
+      break
+    }
+  }
+
+  var after int64
+  if info, err := os.Stat(lndChannelDBPath()); err == nil {
+    after = info.Size()
+  }
+  s.lndDBCompact.mu.Lock()
+  s.lndDBCompact.phase = "done"
+  s.lndDBCompact.sizeAfter = after
+  s.lndDBCompact.finishedAt = time.Now().UTC()
+  before := s.lndDBCompact.sizeBefore
+  s.lndDBCompact.mu.Unlock()
+  s.logger.Printf("lnd db compaction finished: %d -> %d bytes", before, after)
+  s.recordLNDDBSample()
+}
+
+// setLNDConfSectionOptions sets key=value pairs inside a lnd.conf section,
+// creating the section when missing.
+func setLNDConfSectionOptions(raw string, section string, values map[string]string) string {
+  header := "[" + section + "]"
+  lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+  start := -1
+  end := len(lines)
+  for i, line := range lines {
+    trimmed := strings.TrimSpace(line)
+    if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+      if strings.EqualFold(trimmed, header) {
+        start = i
+        continue
+      }
+      if start != -1 && i > start {
+        end = i
+        break
+      }
+    }
+  }
+  if start == -1 {
+    lines = append(lines, "", header)
+    start = len(lines) - 1
+    end = len(lines)
+  }
+
+  seen := map[string]bool{}
+  for i := start + 1; i < end; i++ {
+    parts := strings.SplitN(strings.TrimSpace(lines[i]), "=", 2)
+    if len(parts) != 2 {
+      continue
+    }
+    key := strings.TrimSpace(parts[0])
+    if value, ok := values[key]; ok {
+      lines[i] = key + "=" + value
+      seen[key] = true
+    }
+  }
+  keys := make([]string, 0, len(values))
+  for key := range values {
+    if !seen[key] {
+      keys = append(keys, key)
+    }
+  }
+  sort.Strings(keys)
+  extra := make([]string, 0, len(keys))
+  for _, key := range keys {
+    extra = append(extra, key+"="+values[key])
+  }
+  for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+    end--
+  }
+  if len(extra) > 0 {
+    lines = append(lines[:end], append(extra, lines[end:]...)...)
+  }
+  return strings.Join(lines, "\n")
+}
@@ -0,0 +1,389 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/system"
+
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The LND database integrity check catches silent corruption while a
+// backup is still good. With bolt it stops LND, runs `bbolt check` on
+// channel.db and starts LND again; with Postgres LND keeps running and the
+// check looks for invalid indexes, checksum failures and, when the amcheck
+// extension is installed, broken btree indexes. It runs as a job, by hand
+// or weekly in the lnd_db_check window.
+const (
+  jobKindLNDDBCheck = "lnd_db_check"
+  lndDBCheckStatePath = "/var/lib/lightningos/lnd-db-check.json"
+  lndDBCheckTimeout = 30 * time.Minute
+  lndDBCheckStartTimeout = 10 * time.Minute
+  lndDBCheckPoll = 10 * time.Second
+  lndDBCheckSchedulePoll = 10 * time.Minute
+  lndDBCheckMaxOutput = 50
+)
+
+type lndDBCheckParams struct {
+  Trigger string `json:"trigger"`
+}
+
+type lndDBCheckProgress struct {
+  Step string `json:"step"`
+}
+
+type lndDBCheckResult struct {
+  Backend string `json:"backend"`
+  OK bool `json:"ok"`
+  Problems []string `json:"problems"`
+  Output []string `json:"output,omitempty"`
+  DurationSec int64 `json:"duration_sec"`
+  LNDDowntimeSec int64 `json:"lnd_downtime_sec,omitempty"`
+}
+
+type lndDBCheckState struct {
+  LastAt time.Time `json:"last_at"`
+  Trigger string `json:"trigger"`
+  OK bool `json:"ok"`
+  Problems []string `json:"problems,omitempty"`
+  Error string `json:"error,omitempty"`
+}
+
+func readLNDDBCheckState() (lndDBCheckState, bool) {
+  var state lndDBCheckState
+  raw, err := os.ReadFile(lndDBCheckStatePath)
+  if err != nil || json.Unmarshal(raw, &state) != nil {
+    return lndDBCheckState{}, false
+  }
+  return state, true
+}
+
+func writeLNDDBCheckState(state lndDBCheckState) error {
+  if err := os.MkdirAll(filepath.Dir(lndDBCheckStatePath), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.Marshal(state)
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(lndDBCheckStatePath, raw, 0o640)
+}
+
+// lndDBCheckDue reports whether now falls in the window and the last check
+// is old enough that this window has not had one yet.
+func lndDBCheckDue(cfg config.LNDDBCheckConfig, last time.Time, now time.Time) bool {
+  if !cfg.Enabled {
+    return false
+  }
+  day, hour := cfg.Window()
+  if now.Weekday() != day || now.Hour() != hour {
+    return false
+  }
+  return now.Sub(last) > 24*time.Hour
+}
+
+// nextLNDDBCheck is the start of the next window after now.
+func nextLNDDBCheck(cfg config.LNDDBCheckConfig, now time.Time) time.Time {
+  day, hour := cfg.Window()
+  next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+  next = next.AddDate(0, 0, (int(day)-int(now.Weekday())+7)%7)
+  if !next.After(now) {
+    next = next.AddDate(0, 0, 7)
+  }
+  return next
+}
+
+func (s *Server) lndDBMaintenanceBusy() error {
+  if s.lndPGMigrate.active() {
+    return errors.New("postgres migration is running")
+  }
+  if s.lndDBCompact.running() {
+    return errors.New("compaction is running")
+  }
+  return nil
+}
+
+func (s *Server) initLNDDBCheck() {
+  goSafe("lnd/db_check", s.runLNDDBCheckSchedule)
+}
+
+func (s *Server) runLNDDBCheckSchedule() {
+  for {
+    if !s.wait(lndDBCheckSchedulePoll) {
+      return
+    }
+    state, _ := readLNDDBCheckState()
+    if !lndDBCheckDue(s.cfg.LNDDBCheck, state.LastAt, time.Now()) || s.lndDBMaintenanceBusy() != nil {
+      continue
+    }
+    if _, err := s.jobs.submit(config.DefaultNodeID, jobKindLNDDBCheck, "", lndDBCheckParams{Trigger: "schedule"}); err != nil && !errors.Is(err, errJobRunning) {
+      s.logger.Printf("lnd db check: failed to start: %v", err)
+    }
+  }
+}
+
+func (s *Server) runLNDDBCheckJob(ctx context.Context, job *jobHandle) (any, error) {
+  var params lndDBCheckParams
+  if err := job.Params(&params); err != nil {
+    return nil, err
+  }
+  if err := s.lndDBMaintenanceBusy(); err != nil {
+    return nil, err
+  }
+  ctx, cancel := context.WithTimeout(ctx, lndDBCheckTimeout)
+  defer cancel()
+
+  start := time.Now()
+  backend := lndDBBackend()
+  var result lndDBCheckResult
+  var err error
+  if backend == "postgres" {
+    job.Progress(0, 1, lndDBCheckProgress{Step: "checking"})
+    result, err = checkLNDPostgres(ctx)
+    job.Progress(1, 1, lndDBCheckProgress{Step: "checking"})
+  } else {
+    result, err = s.checkLNDBolt(ctx, job)
+  }
+  result.Backend = backend
+  result.DurationSec = int64(time.Since(start) / time.Second)
+
+  state := lndDBCheckState{LastAt: time.Now().UTC(), Trigger: params.Trigger, OK: err == nil && result.OK, Problems: result.Problems}
+  if err != nil {
+    state.Error = err.Error()
+  }
+  if writeErr := writeLNDDBCheckState(state); writeErr != nil {
+    s.logger.Printf("lnd db check: failed to store state: %v", writeErr)
+  }
+  if err != nil {
+    return nil, err
+  }
+  if !result.OK {
+    s.logger.Printf("lnd db check: %s found %d problem(s): %s", backend, len(result.Problems), strings.Join(result.Problems, "; "))
+    s.notifyLNDDBCheck(result)
+  }
+  return result, nil
+}
+
+// checkLNDBolt stops LND for the length of `bbolt check`; LND is started
+// again whatever the check finds.
+func (s *Server) checkLNDBolt(ctx context.Context, job *jobHandle) (lndDBCheckResult, error) {
+  result := lndDBCheckResult{Problems: []string{}}
+  bboltPath, err := exec.LookPath("bbolt")
+  if err != nil {
+    return result, errors.New("bbolt CLI not installed (go install go.etcd.io/bbolt/cmd/bbolt@latest)")
+  }
+  dbPath := lndChannelDBPath()
+  if _, err := os.Stat(dbPath); err != nil {
+    return result, errors.New("channel.db not found")
+  }
+  steps := 4
+  job.Progress(0, steps, lndDBCheckProgress{Step: "stopping_lnd"})
+  // An LND someone stopped on purpose stays stopped.
+  wasActive := system.SystemctlIsActive(ctx, "lnd")
+  stopCtx, stopCancel := context.WithTimeout(ctx, powerLNDStopTimeout)
+  err = s.stopLNDCleanly(stopCtx)
+  stopCancel()
+  if err != nil {
+    return result, fmt.Errorf("lnd did not stop: %w", err)
+  }
+  stoppedAt := time.Now()
+  restarted := false
+  restart := func() error {
+    restarted = true
+    if !wasActive {
+      return nil
+    }
+    startCtx, startCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+    defer startCancel()
+    return system.SystemctlStart(startCtx, "lnd")
+  }
+  defer func() {
+    if !restarted {
+      if err := restart(); err != nil {
+        s.logger.Printf("lnd db check: failed to start lnd: %v", err)
+      }
+    }
+  }()
+
+  job.Progress(1, steps, lndDBCheckProgress{Step: "checking"})
+  out, checkErr := exec.CommandContext(ctx, bboltPath, "check", dbPath).CombinedOutput()
+  lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+  if len(lines) > lndDBCheckMaxOutput {
+    lines = lines[len(lines)-lndDBCheckMaxOutput:]
+  }
+  result.Output = lines
+  if ctx.Err() != nil {
+    return result, fmt.Errorf("check did not finish: %w", ctx.Err())
+  }
+  if checkErr != nil {
+    var exitErr *exec.ExitError
+    if !errors.As(checkErr, &exitErr) {
+      return result, checkErr
+    }
+    for _, line := range lines {
+      if line = strings.TrimSpace(line); line != "" {
+        result.Problems = append(result.Problems, line)
+      }
+    }
+    if len(result.Problems) == 0 {
+      result.Problems = append(result.Problems, "bbolt check failed: "+checkErr.Error())
+    }
+  }
+  result.OK = len(result.Problems) == 0
+
+  job.Progress(2, steps, lndDBCheckProgress{Step: "starting_lnd"})
+  if err := restart(); err != nil {
+    return result, fmt.Errorf("failed to start lnd: %w", err)
+  }
+  job.Progress(3, steps, lndDBCheckProgress{Step: "waiting_lnd"})
+  deadline := time.Now().Add(lndDBCheckStartTimeout)
+  for wasActive && time.Now().Before(deadline) {
+    pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+    up := s.lnd.Reachable(pingCtx)
+    pingCancel()
+    if up {
+      break
+    }
+    time.Sleep(lndDBCheckPoll)
+  }
+  result.LNDDowntimeSec = int64(time.Since(stoppedAt) / time.Second)
+  job.Progress(steps, steps, lndDBCheckProgress{Step: "done"})
+  return result, nil
+}
+
+func checkLNDPostgres(ctx context.Context) (lndDBCheckResult, error) {
+  result := lndDBCheckResult{Problems: []string{}}
+  dsn := strings.TrimSpace(os.Getenv("LND_PG_DSN"))
+  if dsn == "" || isPlaceholderDSN(dsn) {
+    return result, errors.New("LND_PG_DSN not configured")
+  }
+  pool, err := pgxpool.New(ctx, dsn)
+  if err != nil {
+    return result, err
+  }
+  defer pool.Close()
+
+  rows, err := pool.Query(ctx, `
+select c.relname from pg_index i
+join pg_class c on c.oid = i.indexrelid
+join pg_namespace n on n.oid = c.relnamespace
+where not i.indisvalid and n.nspname not in ('pg_catalog', 'information_schema')`)
+  if err != nil {
+    return result, err
+  }
+  for rows.Next() {
+    var name string
+    if err := rows.Scan(&name); err != nil {
+      rows.Close()
+      return result, err
+    }
+    result.Problems = append(result.Problems, "invalid index "+name)
+  }
+  rows.Close()
+  if err := rows.Err(); err != nil {
+    return result, err
+  }
+
+  // checksum_failures needs Postgres 12 and data checksums; older servers
+  // and clusters without them just skip it.
+  var failures int64
+  if err := pool.QueryRow(ctx, `select coalesce(checksum_failures, 0) from pg_stat_database where datname = current_database()`).Scan(&failures); err == nil && failures > 0 {
+    result.Problems = append(result.Problems, fmt.Sprintf("%d data checksum failure(s)", failures))
+  }
+
+  var amcheck bool
+  _ = pool.QueryRow(ctx, `select exists (select 1 from pg_extension where extname = 'amcheck')`).Scan(&amcheck)
+  if amcheck {
+    indexRows, err := pool.Query(ctx, `
+select c.oid::regclass::text from pg_index i
+join pg_class c on c.oid = i.indexrelid
+join pg_am am on am.oid = c.relam
+join pg_namespace n on n.oid = c.relnamespace
+where am.amname = 'btree' and i.indisvalid and n.nspname not in ('pg_catalog', 'information_schema')`)
+    if err != nil {
+      return result, err
+    }
+    indexes := []string{}
+    for indexRows.Next() {
+      var name string
+      if err := indexRows.Scan(&name); err == nil {
+        indexes = append(indexes, name)
+      }
+    }
+    indexRows.Close()
+    for _, index := range indexes {
+      if _, err := pool.Exec(ctx, `select bt_index_check($1::regclass)`, index); err != nil {
+        if ctx.Err() != nil {
+          return result, ctx.Err()
+        }
+        result.Problems = append(result.Problems, fmt.Sprintf("index %s: %v", index, err))
+      }
+    }
+    result.Output = append(result.Output, fmt.Sprintf("amcheck: %d btree indexes checked", len(indexes)))
+  } else {
+    result.Output = append(result.Output, "amcheck extension not installed; btree indexes not checked")
+  }
+  result.OK = len(result.Problems) == 0
+  return result, nil
+}
+
+func (s *Server) notifyLNDDBCheck(result lndDBCheckResult) {
+  if s.notifier == nil {
+    return
+  }
+  now := time.Now().UTC()
+  evt := Notification{
+    OccurredAt: now,
+    Type: "lnd_db",
+    Action: "integrity_check",
+    Direction: "neutral",
+    Status: "ERROR",
+    Memo: fmt.Sprintf("LND %s database check found %d problem(s): %s", result.Backend, len(result.Problems), result.Problems[0]),
+  }
+  ctx, cancel := s.taskContext("lnd/db_check/notify", timeoutShort)
+  defer cancel()
+  if _, err := s.notifier.upsertNotification(ctx, "lnd_db_check:"+now.Format(time.RFC3339), evt); err != nil {
+    s.logger.Printf("lnd db check: notification failed: %v", err)
+  }
+}
+
+func (s *Server) handleLNDDBCheckGet(w http.ResponseWriter, r *http.Request) {
+  cfg := s.cfg.LNDDBCheck
+  day, hour := cfg.Window()
+  schedule := map[string]any{
+    "enabled": cfg.Enabled,
+    "weekday": strings.ToLower(day.String()),
+    "hour": hour,
+  }
+  if cfg.Enabled {
+    schedule["next_at"] = nextLNDDBCheck(cfg, time.Now())
+  }
+  resp := map[string]any{"backend": lndDBBackend(), "schedule": schedule}
+  if state, ok := readLNDDBCheckState(); ok {
+    resp["last"] = state
+  }
+  writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleLNDDBCheckPost(w http.ResponseWriter, r *http.Request) {
+  if err := s.lndDBMaintenanceBusy(); err != nil {
+    writeError(w, http.StatusConflict, err.Error())
+    return
+  }
+  job, err := s.jobs.submit(config.DefaultNodeID, jobKindLNDDBCheck, "", lndDBCheckParams{Trigger: "manual"})
+  if err != nil {
+    writeJobSubmitError(w, err)
+    return
+  }
+  writeJobStarted(w, job)
+}
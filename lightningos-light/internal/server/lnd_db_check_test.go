@@ -0,0 +1,41 @@
+package server
+
+import (
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+)
+
+func TestLNDDBCheckSchedule(t *testing.T) {
+  hour := 2
+  cfg := config.LNDDBCheckConfig{Enabled: true, Weekday: "Wednesday", Hour: &hour}
+  // 2026-03-04 is a Wednesday.
+  inWindow := time.Date(2026, 3, 4, 2, 30, 0, 0, time.UTC)
+  if !lndDBCheckDue(cfg, time.Time{}, inWindow) {
+    t.Fatal("a first check in the window is due")
+  }
+  if lndDBCheckDue(cfg, inWindow.Add(-20*time.Minute), inWindow) {
+    t.Fatal("the window ran twice")
+  }
+  if !lndDBCheckDue(cfg, inWindow.AddDate(0, 0, -7), inWindow) {
+    t.Fatal("last week's check blocks this week's")
+  }
+  if lndDBCheckDue(cfg, time.Time{}, inWindow.Add(time.Hour)) {
+    t.Fatal("due outside the window")
+  }
+  cfg.Enabled = false
+  if lndDBCheckDue(cfg, time.Time{}, inWindow) {
+    t.Fatal("due while disabled")
+  }
+
+  if next := nextLNDDBCheck(cfg, inWindow); !next.Equal(time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC)) {
+    t.Fatalf("next after the window = %v", next)
+  }
+  if next := nextLNDDBCheck(cfg, time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2026, 3, 4, 2, 0, 0, 0, time.UTC)) {
+    t.Fatalf("next from monday = %v", next)
+  }
+  if day, h := (config.LNDDBCheckConfig{}).Window(); day != time.Sunday || h != 4 {
+    t.Fatalf("default window = %v %d", day, h)
+  }
+}
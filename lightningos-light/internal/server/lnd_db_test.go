@@ -0,0 +1,41 @@
+package server
+
+import (
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestLNDDBGrowthPerDay(t *testing.T) {
+  now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+  samples := []lndDBSample{
+    {At: now.Add(-10 * 24 * time.Hour), Bytes: 100},
+    {At: now.Add(-2 * 24 * time.Hour), Bytes: 1000},
+    {At: now.Add(-time.Hour), Bytes: 1900},
+  }
+  growth := lndDBGrowthPerDay(samples, 2000, now)
+  if growth == nil || *growth != 500 {
+    t.Fatalf("expected 500 bytes/day, got %v", growth)
+  }
+  if lndDBGrowthPerDay(samples[2:], 2000, now) != nil {
+    t.Fatalf("expected no growth estimate from recent samples only")
+  }
+}
+
+func TestSetLNDConfSectionOptions(t *testing.T) {
+  raw := "[Application Options]\nalias=node\n\n[bolt]\ndb.bolt.auto-compact=false\n\n[tor]\ntor.active=true\n"
+  got := setLNDConfSectionOptions(raw, "bolt", map[string]string{
+    "db.bolt.auto-compact": "true",
+    "db.bolt.auto-compact-min-age": "0",
+  })
+  if !strings.Contains(got, "[bolt]\ndb.bolt.auto-compact=true\ndb.bolt.auto-compact-min-age=0\n\n[tor]") {
+    t.Fatalf("unexpected bolt section:\n%s", got)
+  }
+
+  got = setLNDConfSectionOptions("[Application Options]\nalias=node\n", "bolt", map[string]string{
+    "db.bolt.auto-compact": "true",
+  })
+  if !strings.HasSuffix(got, "[bolt]\ndb.bolt.auto-compact=true") {
+    t.Fatalf("expected new bolt section, got:\n%s", got)
+  }
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+  "encoding/json"
+  "errors"
+  "io"
+  "log"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/lndfake"
+)
+
+func newFakeLNDServer(t *testing.T) (*Server, *lndfake.Fake) {
+  t.Helper()
+  fake := lndfake.New()
+  s := NewWithLND(&config.Config{Network: "mainnet"}, log.New(io.Discard, "", 0), fake)
+  return s, fake
+}
+
+func serveAPI(s *Server, method, path, body string) *httptest.ResponseRecorder {
+  req := httptest.NewRequest(method, path, strings.NewReader(body))
+  rec := httptest.NewRecorder()
+  s.routes().ServeHTTP(rec, req)
+  return rec
+}
+
+func TestLNPeersHandler(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  fake.Peers = []lndclient.PeerInfo{{PubKey: "02aa", Alias: "alice", Address: "10.0.0.1:9735"}}
+
+  rec := serveAPI(s, http.MethodGet, "/api/lnops/peers", "")
+  if rec.Code != http.StatusOK {
+    t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+  }
+  var resp struct {
+    Peers []lndclient.PeerInfo `json:"peers"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+    t.Fatalf("decode: %v", err)
+  }
+  if len(resp.Peers) != 1 || resp.Peers[0].Alias != "alice" {
+    t.Fatalf("unexpected peers: %+v", resp.Peers)
+  }
+
+  fake.Fail("ListPeers", errors.New("rpc error: code = Unavailable desc = connection refused"))
+  if rec := serveAPI(s, http.MethodGet, "/api/lnops/peers", ""); rec.Code != http.StatusInternalServerError {
+    t.Fatalf("status on LND error = %d", rec.Code)
+  }
+}
+
+func TestLNDisconnectPeerHandler(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  fake.Peers = []lndclient.PeerInfo{{PubKey: "02aa"}, {PubKey: "02bb"}}
+
+  if rec := serveAPI(s, http.MethodPost, "/api/lnops/peer/disconnect", `{"pubkey":""}`); rec.Code != http.StatusBadRequest {
+    t.Fatalf("empty pubkey status = %d", rec.Code)
+  }
+  rec := serveAPI(s, http.MethodPost, "/api/lnops/peer/disconnect", `{"pubkey":"02aa"}`)
+  if rec.Code != http.StatusOK {
+    t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+  }
+  if calls := fake.Calls("DisconnectPeer"); len(calls) != 1 || calls[0].Args[0] != "02aa" {
+    t.Fatalf("unexpected calls: %+v", calls)
+  }
+  if len(fake.Peers) != 1 || fake.Peers[0].PubKey != "02bb" {
+    t.Fatalf("peer not removed: %+v", fake.Peers)
+  }
+}
+
+func TestLNChannelsHandlerCounts(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  fake.Channels = []lndclient.ChannelInfo{
+    {ChannelPoint: "aa:0", Active: true},
+    {ChannelPoint: "bb:0", Active: false},
+    {ChannelPoint: "cc:1", Active: true},
+  }
+  fake.Pending = []lndclient.PendingChannelInfo{{ChannelPoint: "dd:0", Status: "opening"}}
+
+  rec := serveAPI(s, http.MethodGet, "/api/lnops/channels", "")
+  if rec.Code != http.StatusOK {
+    t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+  }
+  var resp map[string]any
+  if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+    t.Fatalf("decode: %v", err)
+  }
+  if resp["active_count"] != float64(2) || resp["inactive_count"] != float64(1) || resp["pending_open_count"] != float64(1) {
+    t.Fatalf("unexpected counts: %v", resp)
+  }
+}
+
+func TestRequireLNDReadyUsesLifecycle(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  fake.SetState(lndclient.StateLocked)
+  rec := serveAPI(s, http.MethodGet, "/api/lnops/peers", "")
+  if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+    t.Fatalf("locked status = %d", rec.Code)
+  }
+  if len(fake.Calls("ListPeers")) != 0 {
+    t.Fatal("handler must not reach LND while locked")
+  }
+
+  fake.SetState(lndclient.StateServerActive)
+  if rec := serveAPI(s, http.MethodGet, "/api/lnops/peers", ""); rec.Code != http.StatusOK {
+    t.Fatalf("ready status = %d", rec.Code)
+  }
+}
+
+func TestNotifierWaitsForLNDReady(t *testing.T) {
+  fake := lndfake.New()
+  fake.SetState(lndclient.StateLocked)
+  n := NewNotifier(nil, fake, log.New(io.Discard, "", 0))
+  n.stop = make(chan struct{})
+
+  ready := make(chan bool, 1)
+  go func() { ready <- n.waitLNDReady() }()
+  select {
+  case <-ready:
+    t.Fatal("notifier must wait while LND is locked")
+  case <-time.After(50 * time.Millisecond):
+  }
+  fake.SetState(lndclient.StateServerActive)
+  select {
+  case ok := <-ready:
+    if !ok {
+      t.Fatal("expected ready")
+    }
+  case <-time.After(time.Second):
+    t.Fatal("notifier did not resume when LND became ready")
+  }
+
+  fake.SetState(lndclient.StateLocked)
+  go func() { ready <- n.waitLNDReady() }()
+  close(n.stop)
+  select {
+  case ok := <-ready:
+    if ok {
+      t.Fatal("stopped notifier must not report ready")
+    }
+  case <-time.After(time.Second):
+    t.Fatal("notifier did not stop")
+  }
+}
@@ -0,0 +1,470 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "log"
+  "net/http"
+  "os"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/system"
+
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+  lndinitDefaultImage = "lightninglabs/lndinit:v0.1.26-beta-lnd-v0.19.0-beta"
+  lndPGDBUser = "lndpg"
+  lndPGDBName = "lnd"
+  lndPGMigrationTimeout = 6 * time.Hour
+  lndPGMigrationLogLines = 200
+  lndPGVerifyTimeout = 15 * time.Minute
+)
+
+// lndinitImage allows pinning a different lndinit build that matches the
+// installed LND version.
+func lndinitImage() string {
+  if image := strings.TrimSpace(os.Getenv("LNDINIT_IMAGE")); image != "" {
+    return image
+  }
+  return lndinitDefaultImage
+}
+
+type lndPGMigration struct {
+  mu sync.Mutex
+  phase string
+  startedAt time.Time
+  finishedAt time.Time
+  err string
+  note string
+  logs []string
+}
+
+type lndPGMigrationStatus struct {
+  Phase string `json:"phase"`
+  StartedAt *time.Time `json:"started_at,omitempty"`
+  FinishedAt *time.Time `json:"finished_at,omitempty"`
+  Error string `json:"error,omitempty"`
+  Note string `json:"note,omitempty"`
+  Logs []string `json:"logs"`
+}
+
+type lndPGMigrationCheck struct {
+  Backend string `json:"backend"`
+  DSNConfigured bool `json:"dsn_configured"`
+  CanProvision bool `json:"can_provision"`
+  TargetEmpty *bool `json:"target_empty,omitempty"`
+  DockerAvailable bool `json:"docker_available"`
+  ChannelDBBytes int64 `json:"channel_db_bytes"`
+  Image string `json:"image"`
+  Ready bool `json:"ready"`
+  Issues []string `json:"issues,omitempty"`
+  Migration lndPGMigrationStatus `json:"migration"`
+}
+
+func (m *lndPGMigration) status() lndPGMigrationStatus {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  status := lndPGMigrationStatus{
+    Phase: m.phase,
+    Error: m.err,
+    Note: m.note,
+    Logs: append([]string{}, m.logs...),
+  }
+  if status.Phase == "" {
+    status.Phase = "idle"
+  }
+  if !m.startedAt.IsZero() {
+    started := m.startedAt
+    status.StartedAt = &started
+  }
+  if !m.finishedAt.IsZero() {
+    finished := m.finishedAt
+    status.FinishedAt = &finished
+  }
+  return status
+}
+
+func (m *lndPGMigration) setPhase(phase string) {
+  m.mu.Lock()
+  m.phase = phase
+  m.mu.Unlock()
+  m.log("phase: " + phase)
+}
+
+func (m *lndPGMigration) log(line string) {
+  line = strings.TrimSpace(line)
+  if line == "" {
+    return
+  }
+  m.mu.Lock()
+  m.logs = append(m.logs, line)
+  if len(m.logs) > lndPGMigrationLogLines {
+    m.logs = m.logs[len(m.logs)-lndPGMigrationLogLines:]
+  }
+  m.mu.Unlock()
+}
+
+func (m *lndPGMigration) active() bool {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  return m.phase != "" && m.phase != "done" && m.phase != "failed"
+}
+
+func configuredLNDPGDSN() string {
+  dsn, err := readEnvFileValue(secretsPath, "LND_PG_DSN")
+  if err != nil || strings.TrimSpace(dsn) == "" {
+    dsn = os.Getenv("LND_PG_DSN")
+  }
+  dsn = strings.TrimSpace(dsn)
+  if dsn == "" || isPlaceholderDSN(dsn) {
+    return ""
+  }
+  return dsn
+}
+
+// lndPGTargetEmpty reports whether the LND database has no tables yet;
+// lndinit refuses to merge into an existing kv store.
+func lndPGTargetEmpty(ctx context.Context, dsn string) (bool, error) {
+  pool, err := pgxpool.New(ctx, dsn)
+  if err != nil {
+    return false, err
+  }
+  defer pool.Close()
+  var count int
+  if err := pool.QueryRow(ctx, "select count(*) from information_schema.tables where table_schema = 'public'").Scan(&count); err != nil {
+    return false, err
+  }
+  return count == 0, nil
+}
+
+func (s *Server) lndPGMigrationCheck(ctx context.Context) lndPGMigrationCheck {
+  check := lndPGMigrationCheck{
+    Backend: lndDBBackend(),
+    Image: lndinitImage(),
+    Migration: s.lndPGMigrate.status(),
+  }
+  if info, err := os.Stat(lndChannelDBPath()); err == nil {
+    check.ChannelDBBytes = info.Size()
+  }
+  check.DockerAvailable = containerRuntimeInstalled()
+
+  dsn := configuredLNDPGDSN()
+  check.DSNConfigured = dsn != ""
+  if dsn != "" {
+    empty, err := lndPGTargetEmpty(ctx, dsn)
+    if err != nil {
+      check.Issues = append(check.Issues, fmt.Sprintf("cannot reach LND postgres database: %v", err))
+    } else {
+      check.TargetEmpty = &empty
+      if !empty {
+        check.Issues = append(check.Issues, fmt.Sprintf("database %s already has tables", databaseNameFromDSN(dsn)))
+      }
+    }
+  } else {
+    adminDSN, err := ensureNotificationsAdminDSN(s.logger)
+    check.CanProvision = err == nil && strings.TrimSpace(adminDSN) != ""
+    if !check.CanProvision {
+      check.Issues = append(check.Issues, "LND_PG_DSN not set and no postgres admin DSN to provision one")
+    }
+  }
+
+  if check.Backend != "bolt" {
+    check.Issues = append(check.Issues, "LND is not using the bolt backend")
+  }
+  if check.ChannelDBBytes == 0 {
+    check.Issues = append(check.Issues, "channel.db not found")
+  }
+  if !check.DockerAvailable {
+    check.Issues = append(check.Issues, activeContainerRuntime().CLI()+" is required to run lndinit")
+  }
+  check.Ready = len(check.Issues) == 0
+  return check
+}
+
+func (s *Server) handleLNDPGMigrationGet(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  writeJSON(w, http.StatusOK, s.lndPGMigrationCheck(ctx))
+}
+
+func (s *Server) handleLNDPGMigrationPost(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Confirm bool `json:"confirm"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if !req.Confirm {
+    writeError(w, http.StatusBadRequest, "confirm must be true: LND is stopped during the migration")
+    return
+  }
+  if s.lndPGMigrate.active() || s.lndDBCompact.running() {
+    writeError(w, http.StatusConflict, "an LND database job is already running")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  check := s.lndPGMigrationCheck(ctx)
+  cancel()
+  if !check.Ready {
+    writeError(w, http.StatusBadRequest, strings.Join(check.Issues, "; "))
+    return
+  }
+
+  s.lndPGMigrate.mu.Lock()
+  if s.lndPGMigrate.phase != "" && s.lndPGMigrate.phase != "done" && s.lndPGMigrate.phase != "failed" {
+    s.lndPGMigrate.mu.Unlock()
+    writeError(w, http.StatusConflict, "migration already running")
+    return
+  }
+  s.lndPGMigrate.phase = "preparing"
+  s.lndPGMigrate.startedAt = time.Now().UTC()
+  s.lndPGMigrate.finishedAt = time.Time{}
+  s.lndPGMigrate.err = ""
+  s.lndPGMigrate.note = ""
+  s.lndPGMigrate.logs = nil
+  s.lndPGMigrate.mu.Unlock()
+
+  go s.runLNDPGMigration()
+  writeJSON(w, http.StatusAccepted, s.lndPGMigrate.status())
+}
+
+// runLNDPGMigration moves a bolt-backed LND to postgres: make sure the
+// database exists, stop LND, copy the kv data with lndinit migrate-db, point
+// lnd.conf at postgres and start LND again.
+func (s *Server) runLNDPGMigration() {
+  defer crashLog.capture("lnd/pg_migration")
+  m := &s.lndPGMigrate
+  ctx, cancel := context.WithTimeout(context.Background(), lndPGMigrationTimeout)
+  defer cancel()
+
+  fail := func(msg string) {
+    s.logger.Printf("lnd postgres migration failed: %s", msg)
+    m.log("error: " + msg)
+    m.mu.Lock()
+    m.phase = "failed"
+    m.err = msg
+    m.finishedAt = time.Now().UTC()
+    m.mu.Unlock()
+  }
+
+  pubkeyBefore := s.lnd.CachedPubkey()
+
+  dsn := configuredLNDPGDSN()
+  if dsn == "" {
+    m.setPhase("provisioning")
+    provisioned, err := provisionLNDPostgres(ctx, s.logger)
+    if err != nil {
+      fail(fmt.Sprintf("failed to provision LND database: %v", err))
+      return
+    }
+    dsn = provisioned
+  }
+
+  m.setPhase("pulling")
+  if err := ensureContainerRuntime(ctx); err != nil {
+    fail(err.Error())
+    return
+  }
+  image := lndinitImage()
+  if _, err := containerCLI(ctx, "image", "inspect", image); err != nil {
+    out, err := containerCLI(ctx, "pull", image)
+    if err != nil {
+      fail(fmt.Sprintf("failed to pull %s: %s", image, strings.TrimSpace(out)))
+      return
+    }
+  }
+
+  prevConf, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    fail("failed to read lnd.conf")
+    return
+  }
+  backupPath := fmt.Sprintf("%s.bolt-%s", lndConfPath, time.Now().UTC().Format("20060102-150405"))
+  if err := os.WriteFile(backupPath, prevConf, 0660); err != nil {
+    fail("failed to back up lnd.conf")
+    return
+  }
+  m.log("lnd.conf saved to " + backupPath)
+
+  m.setPhase("stopping")
+  if err := system.SystemctlStop(ctx, "lnd"); err != nil {
+    fail(fmt.Sprintf("failed to stop lnd: %v", err))
+    return
+  }
+
+  m.setPhase("migrating")
+  out, err := containerCLI(ctx, "run", "--rm",
+    "--network", "host",
+    "-v", "/data/lnd:/data/lnd",
+    "--entrypoint", "lndinit",
+    image,
+    "migrate-db",
+    "--network", activeNetwork().Name,
+    "--source.bolt.data-dir", "/data/lnd/data",
+    "--source.bolt.tower-dir", "/data/lnd/data/watchtower",
+    "--dest.backend", "postgres",
+    "--dest.postgres.dsn", dsn,
+  )
+  for _, line := range strings.Split(out, "\n") {
+    m.log(strings.ReplaceAll(line, dsn, "<dsn>"))
+  }
+  if err != nil {
+    // lndinit only tombstones the bolt files after a full copy, so LND can
+    // come back on bolt.
+    startCtx, startCancel := context.WithTimeout(context.Background(), time.Minute)
+    _ = system.SystemctlStart(startCtx, "lnd")
+    startCancel()
+    s.markLNDRestart()
+    fail("lndinit migrate-db failed; LND restarted on bolt")
+    return
+  }
+
+  m.setPhase("configuring")
+  if err := switchLNDConfToPostgres(dsn); err != nil {
+    fail(fmt.Sprintf("failed to write lnd.conf; the original is at %s", backupPath))
+    return
+  }
+
+  m.setPhase("starting")
+  if err := system.SystemctlStart(ctx, "lnd"); err != nil {
+    fail(fmt.Sprintf("failed to start lnd on postgres: %v", err))
+    return
+  }
+  s.markLNDRestart()
+
+  m.setPhase("verifying")
+  note, err := s.verifyLNDPGMigration(pubkeyBefore)
+  if err != nil {
+    fail(err.Error())
+    return
+  }
+  m.log(note)
+  m.mu.Lock()
+  m.phase = "done"
+  m.note = note
+  m.finishedAt = time.Now().UTC()
+  m.mu.Unlock()
+  s.logger.Printf("lnd postgres migration finished: %s", note)
+}
+
+// verifyLNDPGMigration waits for LND to answer on the new backend and, when
+// the wallet is already unlocked, checks the node identity did not change.
+func (s *Server) verifyLNDPGMigration(pubkeyBefore string) (string, error) {
+  deadline := time.Now().Add(lndPGVerifyTimeout)
+  for {
+    if time.Now().After(deadline) {
+      return "", fmt.Errorf("LND did not come back on postgres; check the LND logs")
+    }
+    time.Sleep(lndDBCompactPoll)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    up := s.lnd.Reachable(ctx)
+    if !up {
+      cancel()
+      continue
+    }
+    status, err := s.lnd.GetStatus(ctx)
+    cancel()
+    if err != nil || status.Pubkey == "" {
+      return "LND is running on postgres; unlock the wallet to finish verification", nil
+    }
+    if pubkeyBefore != "" && status.Pubkey != pubkeyBefore {
+      return "", fmt.Errorf("node pubkey changed after migration: %s", status.Pubkey)
+    }
+    return fmt.Sprintf("LND is running on postgres with %d channels", status.ChannelsActive+status.ChannelsInactive), nil
+  }
+}
+
+// provisionLNDPostgres creates the lnd role and database with the notifications
+// admin credentials and stores the resulting LND_PG_DSN.
+func provisionLNDPostgres(ctx context.Context, logger *log.Logger) (string, error) {
+  adminDSN, err := ensureNotificationsAdminDSN(logger)
+  if err != nil {
+    return "", err
+  }
+  password, err := randomPassword(32)
+  if err != nil {
+    return "", err
+  }
+  pool, err := pgxpool.New(ctx, adminDSN)
+  if err != nil {
+    return "", err
+  }
+  defer pool.Close()
+
+  var exists int
+  if err := pool.QueryRow(ctx, "select count(*) from pg_roles where rolname=$1", lndPGDBUser).Scan(&exists); err != nil {
+    return "", err
+  }
+  if exists == 0 {
+    if _, err := pool.Exec(ctx, fmt.Sprintf("create role %s with login password '%s'", lndPGDBUser, password)); err != nil {
+      return "", err
+    }
+  } else if _, err := pool.Exec(ctx, fmt.Sprintf("alter role %s with password '%s'", lndPGDBUser, password)); err != nil {
+    return "", err
+  }
+  if adminUser := adminUserFromDSN(adminDSN); adminUser != "" && adminUser != lndPGDBUser {
+    if _, err := pool.Exec(ctx, fmt.Sprintf("grant %s to %s", lndPGDBUser, adminUser)); err != nil {
+      logger.Printf("lnd postgres: failed to grant %s to %s: %v", lndPGDBUser, adminUser, err)
+    }
+  }
+  if err := pool.QueryRow(ctx, "select count(*) from pg_database where datname=$1", lndPGDBName).Scan(&exists); err != nil {
+    return "", err
+  }
+  if exists == 0 {
+    if _, err := pool.Exec(ctx, fmt.Sprintf("create database %s owner %s", lndPGDBName, lndPGDBUser)); err != nil {
+      return "", err
+    }
+  }
+
+  dsn := fmt.Sprintf("postgres://%s:%s@127.0.0.1:5432/%s?sslmode=disable", lndPGDBUser, password, lndPGDBName)
+  if err := ensureSecretsDir(); err != nil {
+    return "", err
+  }
+  if err := writeEnvFileValue(secretsPath, "LND_PG_DSN", dsn); err != nil {
+    return "", err
+  }
+  _ = os.Setenv("LND_PG_DSN", dsn)
+  logger.Printf("lnd postgres: provisioned database %s with user %s", lndPGDBName, lndPGDBUser)
+  return dsn, nil
+}
+
+func switchLNDConfToPostgres(dsn string) error {
+  unlock := lockConfigFile(lndConfPath)
+  defer unlock()
+  current, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    return err
+  }
+  raw := removeLNDConfOptions(string(current), "db.backend", "db.postgres.dsn", "db.postgres.timeout")
+  raw = setLNDConfSectionOptions(raw, "db", map[string]string{"db.backend": "postgres"})
+  raw = setLNDConfSectionOptions(raw, "postgres", map[string]string{
+    "db.postgres.dsn": dsn,
+    "db.postgres.timeout": "0",
+  })
+  return writeFileAtomic(lndConfPath, []byte(raw), 0660)
+}
+
+// removeLNDConfOptions drops every assignment of the given keys, whatever
+// section they live in.
+func removeLNDConfOptions(raw string, keys ...string) string {
+  drop := map[string]bool{}
+  for _, key := range keys {
+    drop[key] = true
+  }
+  lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+  kept := make([]string, 0, len(lines))
+  for _, line := range lines {
+    parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+    if len(parts) == 2 && drop[strings.TrimSpace(parts[0])] {
+      continue
+    }
+    kept = append(kept, line)
+  }
+  return strings.Join(kept, "\n")
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/system"
+  "lightningos-light/lnrpc"
+)
+
+// A chain rescan rebuilds LND's on-chain wallet history from the wallet
+// birthday, for after a restore or when a deposit never showed up. LND has
+// no RPC for it: the job sets reset-wallet-transactions in lnd.conf,
+// restarts LND and drops the option again as soon as LND is up, so a later
+// restart does not rescan once more. Progress is how far the wallet's
+// synced-to block (GetInfo best_header_timestamp) moved from where the
+// rescan started towards now.
+const (
+  jobKindLNDRescan = "lnd_rescan"
+  lndRescanStatePath = "/var/lib/lightningos/lnd-rescan.json"
+  lndRescanOption = "reset-wallet-transactions"
+  lndRescanTimeout = 12 * time.Hour
+  lndRescanStartTimeout = 10 * time.Minute
+  lndRescanPoll = 15 * time.Second
+)
+
+type lndRescanProgress struct {
+  Step string `json:"step"`
+  SyncedTo *time.Time `json:"synced_to,omitempty"`
+  Percent float64 `json:"percent,omitempty"`
+}
+
+type lndRescanResult struct {
+  DurationSec int64 `json:"duration_sec"`
+  LNDDowntimeSec int64 `json:"lnd_downtime_sec"`
+  BlockHeight int64 `json:"block_height"`
+}
+
+// lndRescanState marks the option as ours while it is in lnd.conf, so a
+// manager that died mid-job removes it on the next start.
+type lndRescanState struct {
+  OptionSet bool `json:"option_set"`
+  LastAt *time.Time `json:"last_at,omitempty"`
+  LastError string `json:"last_error,omitempty"`
+}
+
+func readLNDRescanState() lndRescanState {
+  var state lndRescanState
+  raw, err := os.ReadFile(lndRescanStatePath)
+  if err == nil {
+    _ = json.Unmarshal(raw, &state)
+  }
+  return state
+}
+
+func writeLNDRescanState(state lndRescanState) error {
+  if err := os.MkdirAll(filepath.Dir(lndRescanStatePath), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.Marshal(state)
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(lndRescanStatePath, raw, 0o640)
+}
+
+// removeLNDConfOption drops key from every section of lnd.conf.
+func removeLNDConfOption(raw string, key string) string {
+  lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+  out := make([]string, 0, len(lines))
+  for _, line := range lines {
+    parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+    if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+      continue
+    }
+    out = append(out, line)
+  }
+  return strings.Join(out, "\n")
+}
+
+func setLNDRescanOption(enabled bool) error {
+  unlock := lockConfigFile(lndConfPath)
+  defer unlock()
+  raw, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    return err
+  }
+  updated := removeLNDConfOption(string(raw), lndRescanOption)
+  if enabled {
+    updated = setLNDConfSectionOptions(updated, "Application Options", map[string]string{lndRescanOption: "true"})
+  }
+  return writeFileAtomic(lndConfPath, []byte(updated), 0660)
+}
+
+// rescanPercent places syncedTo between the wallet's position when the
+// rescan started and now.
+func rescanPercent(start, syncedTo, now time.Time) float64 {
+  total := now.Sub(start)
+  if total <= 0 || syncedTo.Before(start) {
+    return 0
+  }
+  done := syncedTo.Sub(start)
+  if done >= total {
+    return 100
+  }
+  return float64(done) / float64(total) * 100
+}
+
+func (s *Server) initLNDRescan() {
+  state := readLNDRescanState()
+  if !state.OptionSet {
+    return
+  }
+  if err := setLNDRescanOption(false); err != nil {
+    s.logger.Printf("lnd rescan: failed to remove %s from lnd.conf: %v", lndRescanOption, err)
+    return
+  }
+  state.OptionSet = false
+  if err := writeLNDRescanState(state); err != nil {
+    s.logger.Printf("lnd rescan: failed to store state: %v", err)
+  }
+}
+
+func (s *Server) runLNDRescanJob(ctx context.Context, job *jobHandle) (any, error) {
+  if err := s.lndDBMaintenanceBusy(); err != nil {
+    return nil, err
+  }
+  ctx, cancel := context.WithTimeout(ctx, lndRescanTimeout)
+  defer cancel()
+  start := time.Now()
+  result, err := s.lndRescan(ctx, job)
+  result.DurationSec = int64(time.Since(start) / time.Second)
+
+  state := readLNDRescanState()
+  at := time.Now().UTC()
+  state.LastAt = &at
+  state.LastError = ""
+  if err != nil {
+    state.LastError = err.Error()
+  }
+  if writeErr := writeLNDRescanState(state); writeErr != nil {
+    s.logger.Printf("lnd rescan: failed to store state: %v", writeErr)
+  }
+  if err != nil {
+    return nil, err
+  }
+  return result, nil
+}
+
+func (s *Server) lndRescan(ctx context.Context, job *jobHandle) (lndRescanResult, error) {
+  result := lndRescanResult{}
+  job.Progress(0, 100, lndRescanProgress{Step: "restarting_lnd"})
+  if err := writeLNDRescanState(lndRescanState{OptionSet: true}); err != nil {
+    return result, err
+  }
+  if err := setLNDRescanOption(true); err != nil {
+    return result, fmt.Errorf("failed to update lnd.conf: %w", err)
+  }
+  optionSet := true
+  clearOption := func() {
+    if !optionSet {
+      return
+    }
+    optionSet = false
+    if err := setLNDRescanOption(false); err != nil {
+      s.logger.Printf("lnd rescan: failed to remove %s from lnd.conf: %v", lndRescanOption, err)
+      return
+    }
+    state := readLNDRescanState()
+    state.OptionSet = false
+    _ = writeLNDRescanState(state)
+  }
+  defer clearOption()
+
+  stoppedAt := time.Now()
+  s.markLNDRestart()
+  restartCtx, restartCancel := s.operationContext(ctx, timeoutLong)
+  err := system.SystemctlRestart(restartCtx, "lnd")
+  restartCancel()
+  if err != nil {
+    return result, fmt.Errorf("lnd restart failed: %w", err)
+  }
+
+  // LND reads lnd.conf at start; once its gRPC port answers the option has
+  // done its job.
+  job.Progress(0, 100, lndRescanProgress{Step: "waiting_lnd"})
+  deadline := time.Now().Add(lndRescanStartTimeout)
+  for {
+    pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+    up := s.lnd.Reachable(pingCtx)
+    pingCancel()
+    if up {
+      break
+    }
+    if ctx.Err() != nil || time.Now().After(deadline) {
+      return result, errors.New("lnd did not come back after the restart")
+    }
+    time.Sleep(lndRescanPoll)
+  }
+  clearOption()
+
+  var rescanStart time.Time
+  for {
+    if ctx.Err() != nil {
+      return result, fmt.Errorf("rescan did not finish: %w", ctx.Err())
+    }
+    info, err := s.lndWalletInfo(ctx)
+    if err == nil {
+      if result.LNDDowntimeSec == 0 {
+        result.LNDDowntimeSec = int64(time.Since(stoppedAt) / time.Second)
+      }
+      result.BlockHeight = int64(info.BlockHeight)
+      if info.SyncedToChain {
+        job.Progress(100, 100, lndRescanProgress{Step: "done"})
+        return result, nil
+      }
+      progress := lndRescanProgress{Step: "rescanning"}
+      if info.BestHeaderTimestamp > 0 {
+        syncedTo := time.Unix(info.BestHeaderTimestamp, 0).UTC()
+        if rescanStart.IsZero() {
+          rescanStart = syncedTo
+        }
+        progress.SyncedTo = &syncedTo
+        progress.Percent = rescanPercent(rescanStart, syncedTo, time.Now())
+      }
+      job.Progress(int(progress.Percent), 100, progress)
+    } else {
+      // Locked until auto-unlock (or the user) unlocks the wallet.
+      job.Progress(0, 100, lndRescanProgress{Step: "waiting_unlock"})
+    }
+    time.Sleep(lndRescanPoll)
+  }
+}
+
+// lndWalletInfo asks LND directly: the cached status may predate the
+// restart.
+func (s *Server) lndWalletInfo(ctx context.Context) (*lnrpc.GetInfoResponse, error) {
+  ctx, cancel := s.operationContext(ctx, timeoutLNDRPC)
+  defer cancel()
+  conn, err := s.lnd.DialLightning(ctx)
+  if err != nil {
+    return nil, err
+  }
+  defer conn.Close()
+  return lnrpc.NewLightningClient(conn).GetInfo(ctx, &lnrpc.GetInfoRequest{})
+}
+
+func (s *Server) handleLNDRescanGet(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, readLNDRescanState())
+}
+
+// handleLNDRescanPost starts the rescan. LND is offline for the restart and
+// on-chain balances are incomplete until the job is done.
+func (s *Server) handleLNDRescanPost(w http.ResponseWriter, r *http.Request) {
+  if err := s.lndDBMaintenanceBusy(); err != nil {
+    writeError(w, http.StatusConflict, err.Error())
+    return
+  }
+  job, err := s.jobs.submit(config.DefaultNodeID, jobKindLNDRescan, "", struct{}{})
+  if err != nil {
+    writeJobSubmitError(w, err)
+    return
+  }
+  writeJobStarted(w, job)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+  "testing"
+  "time"
+)
+
+func TestLNDRescanOption(t *testing.T) {
+  raw := "[Application Options]\nalias=node\nreset-wallet-transactions=true\n\n[Bitcoin]\nbitcoin.mainnet=true\n"
+  cleared := removeLNDConfOption(raw, lndRescanOption)
+  want := "[Application Options]\nalias=node\n\n[Bitcoin]\nbitcoin.mainnet=true\n"
+  if cleared != want {
+    t.Fatalf("cleared = %q, want %q", cleared, want)
+  }
+  set := setLNDConfSectionOptions(cleared, "Application Options", map[string]string{lndRescanOption: "true"})
+  if set != raw {
+    t.Fatalf("set = %q, want %q", set, raw)
+  }
+}
+
+func TestRescanPercent(t *testing.T) {
+  start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  now := start.Add(100 * time.Hour)
+  cases := []struct {
+    syncedTo time.Time
+    want float64
+  }{
+    {start, 0},
+    {start.Add(25 * time.Hour), 25},
+    {now.Add(time.Hour), 100},
+    {start.Add(-time.Hour), 0},
+  }
+  for _, tc := range cases {
+    if got := rescanPercent(start, tc.syncedTo, now); got != tc.want {
+      t.Errorf("rescanPercent(%v) = %v, want %v", tc.syncedTo, got, tc.want)
+    }
+  }
+}
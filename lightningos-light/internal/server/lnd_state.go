@@ -0,0 +1,64 @@
+package server
+
+import (
+  "net/http"
+
+  "lightningos-light/internal/lndclient"
+)
+
+// startLNDStateWatchers follows the State service of every managed node so
+// handlers and pollers know whether LND is locked, starting or serving.
+func (s *Server) startLNDStateWatchers() {
+  s.lnd.OnStateChange(s.observeAutoUnlock)
+  for _, id := range s.nodeIDs {
+    if node := s.nodes[id]; node != nil && node.lnd != nil {
+      node.lnd.WatchState(s.root)
+    }
+  }
+}
+
+// lndLifecycleIssue turns a startup state into a health issue. Serving and
+// unreachable LND return false and are checked through GetInfo, which tells
+// TLS, macaroon and connection errors apart.
+func lndLifecycleIssue(l lndclient.Lifecycle) (healthIssue, bool) {
+  switch l.State {
+  case lndclient.StateRestarting:
+    return healthIssue{Component: "lnd", Level: "WARN", Message: "LND restarting"}, true
+  case lndclient.StateWaitingToStart:
+    return healthIssue{Component: "lnd", Level: "WARN", Message: "LND waiting to start"}, true
+  case lndclient.StateUnlocked, lndclient.StateRPCActive:
+    return healthIssue{Component: "lnd", Level: "WARN", Message: "LND starting up"}, true
+  case lndclient.StateLocked:
+    return healthIssue{Component: "lnd", Level: "ERR", Message: "LND wallet locked"}, true
+  case lndclient.StateNonExisting:
+    return healthIssue{Component: "lnd", Level: "ERR", Message: "LND wallet not created"}, true
+  }
+  return healthIssue{}, false
+}
+
+// lndNotReadyMessage explains why a request cannot reach LND yet; empty when
+// the request should go through.
+func lndNotReadyMessage(l lndclient.Lifecycle) string {
+  switch {
+  case l.State == lndclient.StateLocked:
+    return "LND wallet locked"
+  case l.State == lndclient.StateNonExisting:
+    return "LND wallet not created"
+  case l.Starting():
+    return "LND is starting, try again shortly"
+  }
+  return ""
+}
+
+// requireLNDReady answers 503 instead of letting a request time out against
+// an LND that is locked or still starting.
+func (s *Server) requireLNDReady(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if msg := lndNotReadyMessage(s.lndFor(r).Lifecycle()); msg != "" {
+      w.Header().Set("Retry-After", "5")
+      writeError(w, http.StatusServiceUnavailable, msg)
+      return
+    }
+    next.ServeHTTP(w, r)
+  })
+}
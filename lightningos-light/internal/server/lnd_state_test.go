@@ -0,0 +1,27 @@
+package server
+
+import (
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestLNDLifecycleIssue(t *testing.T) {
+  cases := map[string]string{
+    lndclient.StateLocked: "ERR",
+    lndclient.StateNonExisting: "ERR",
+    lndclient.StateRestarting: "WARN",
+    lndclient.StateRPCActive: "WARN",
+  }
+  for state, level := range cases {
+    issue, ok := lndLifecycleIssue(lndclient.Lifecycle{State: state})
+    if !ok || issue.Level != level {
+      t.Fatalf("%s: expected %s issue, got %+v (%v)", state, level, issue, ok)
+    }
+  }
+  for _, state := range []string{lndclient.StateServerActive, lndclient.StateDown, lndclient.StateUnknown} {
+    if _, ok := lndLifecycleIssue(lndclient.Lifecycle{State: state}); ok {
+      t.Fatalf("%s must fall through to the GetInfo check", state)
+    }
+  }
+}
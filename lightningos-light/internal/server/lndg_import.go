@@ -0,0 +1,306 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/reports"
+)
+
+// The LNDg import reads LNDg's own Postgres through psql in the lndg-db
+// container (the database is not published on the host) and stores its
+// daily rebalance and forward totals next to the native reports. History is
+// summed in 15 minute buckets on LNDg's side, so any timezone offset lands
+// the bucket in the right local day. Importing the same days again
+// overwrites them.
+const (
+  jobKindLNDgImport = "lndg_import"
+  lndgImportDefaultDays = 90
+  lndgImportMaxDays = 730
+  lndgImportBucketSec = 900
+  lndgImportTimeout = 10 * time.Minute
+)
+
+// LNDg stores LND's payment status: 2 is SUCCEEDED, in gui_rebalancer too.
+const lndgRebalancesQuery = `
+select floor(extract(epoch from p.creation_date) / %[1]d)::bigint * %[1]d,
+  count(*),
+  coalesce(sum(round(p.fee * 1000)), 0)::bigint,
+  coalesce(sum(round(p.value * 1000)), 0)::bigint
+from gui_rebalancer r
+join gui_payments p on p.payment_hash = r.payment_hash
+where r.status = 2 and p.status = 2 and p.creation_date >= to_timestamp(%[2]d)
+group by 1 order by 1`
+
+const lndgForwardsQuery = `
+select floor(extract(epoch from forward_date) / %[1]d)::bigint * %[1]d,
+  count(*),
+  coalesce(sum(round(fee * 1000)), 0)::bigint,
+  coalesce(sum(amt_out_msat), 0)::bigint
+from gui_forwards
+where forward_date >= to_timestamp(%[2]d)
+group by 1 order by 1`
+
+type lndgImportParams struct {
+  Days int `json:"days"`
+  TZ string `json:"tz,omitempty"`
+}
+
+type lndgImportProgress struct {
+  Step string `json:"step"`
+}
+
+// parseLNDgBuckets reads psql's unaligned output: epoch,count,fee_msat,
+// amount_msat per line.
+func parseLNDgBuckets(out string) ([]reports.LNDgBucket, error) {
+  buckets := []reports.LNDgBucket{}
+  for _, line := range strings.Split(out, "\n") {
+    line = strings.TrimSpace(line)
+    if line == "" {
+      continue
+    }
+    fields := strings.Split(line, ",")
+    if len(fields) != 4 {
+      return nil, fmt.Errorf("unexpected lndg row: %q", line)
+    }
+    values := make([]int64, len(fields))
+    for i, field := range fields {
+      value, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+      if err != nil {
+        return nil, fmt.Errorf("unexpected lndg row: %q", line)
+      }
+      values[i] = value
+    }
+    buckets = append(buckets, reports.LNDgBucket{
+      At: time.Unix(values[0], 0).UTC(),
+      Count: values[1],
+      FeeMsat: values[2],
+      AmountMsat: values[3],
+    })
+  }
+  return buckets, nil
+}
+
+// queryLNDg runs a query as the lndg user over the container's local
+// socket, which the postgres image trusts.
+func queryLNDg(ctx context.Context, query string) (string, error) {
+  paths := lndgAppPaths()
+  containerID, err := composeContainerID(ctx, paths.Root, paths.ComposePath, "lndg-db")
+  if err != nil {
+    return "", err
+  }
+  if containerID == "" {
+    return "", errors.New("lndg-db container not running")
+  }
+  out, err := containerCLI(ctx, "exec", containerID,
+    "psql", "-U", "lndg", "-d", "lndg", "-At", "-F", ",", "-v", "ON_ERROR_STOP=1", "-c", query)
+  if err != nil {
+    if msg := strings.TrimSpace(out); msg != "" {
+      return "", fmt.Errorf("lndg query failed: %s", msg)
+    }
+    return "", fmt.Errorf("lndg query failed: %w", err)
+  }
+  return out, nil
+}
+
+func (s *Server) runLNDgImportJob(ctx context.Context, job *jobHandle) (any, error) {
+  var params lndgImportParams
+  if err := job.Params(&params); err != nil {
+    return nil, err
+  }
+  svc, errMsg := s.reportsService()
+  if svc == nil {
+    return nil, errors.New(errMsg)
+  }
+  loc, _, err := s.reportsLocationNamed(params.TZ)
+  if err != nil {
+    return nil, err
+  }
+  ctx, cancel := context.WithTimeout(ctx, lndgImportTimeout)
+  defer cancel()
+
+  since := time.Now().Add(-time.Duration(params.Days) * 24 * time.Hour).Unix()
+  job.Progress(0, 3, lndgImportProgress{Step: "rebalances"})
+  out, err := queryLNDg(ctx, fmt.Sprintf(lndgRebalancesQuery, lndgImportBucketSec, since))
+  if err != nil {
+    return nil, err
+  }
+  rebalances, err := parseLNDgBuckets(out)
+  if err != nil {
+    return nil, err
+  }
+  job.Progress(1, 3, lndgImportProgress{Step: "forwards"})
+  out, err = queryLNDg(ctx, fmt.Sprintf(lndgForwardsQuery, lndgImportBucketSec, since))
+  if err != nil {
+    return nil, err
+  }
+  forwards, err := parseLNDgBuckets(out)
+  if err != nil {
+    return nil, err
+  }
+
+  job.Progress(2, 3, lndgImportProgress{Step: "storing"})
+  days := reports.GroupLNDgDays(rebalances, forwards, loc)
+  if err := svc.ImportLNDg(ctx, days); err != nil {
+    return nil, err
+  }
+  job.Progress(3, 3, lndgImportProgress{Step: "done"})
+
+  var rebalanceCount, rebalanceFeeMsat, forwardCount int64
+  for _, day := range days {
+    rebalanceCount += day.RebalanceCount
+    rebalanceFeeMsat += day.RebalanceFeeMsat
+    forwardCount += day.ForwardCount
+  }
+  return map[string]any{
+    "days_imported": len(days),
+    "rebalance_count": rebalanceCount,
+    "rebalance_fee_sats": float64(rebalanceFeeMsat) / 1000,
+    "forward_count": forwardCount,
+  }, nil
+}
+
+// handleReportsLNDgImport imports LNDg's history as a job.
+func (s *Server) handleReportsLNDgImport(w http.ResponseWriter, r *http.Request) {
+  svc, errMsg := s.reportsService()
+  if svc == nil {
+    msg := strings.TrimSpace(errMsg)
+    if msg == "" {
+      msg = "reports unavailable"
+    }
+    writeError(w, http.StatusServiceUnavailable, msg)
+    return
+  }
+  var req lndgImportParams
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if req.Days == 0 {
+    req.Days = lndgImportDefaultDays
+  }
+  if req.Days < 0 || req.Days > lndgImportMaxDays {
+    writeError(w, http.StatusBadRequest, "days must be between 1 and 730")
+    return
+  }
+  if _, _, err := s.reportsLocationNamed(req.TZ); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  app, err := s.appByID("lndg")
+  if err != nil || app == nil {
+    writeError(w, http.StatusInternalServerError, "lndg app unavailable")
+    return
+  }
+  if info, err := app.Info(r.Context()); err != nil || !info.Installed {
+    writeError(w, http.StatusConflict, "lndg is not installed")
+    return
+  }
+
+  job, err := s.jobs.submit(config.DefaultNodeID, jobKindLNDgImport, "", req)
+  if err != nil {
+    writeJobSubmitError(w, err)
+    return
+  }
+  writeJobStarted(w, job)
+}
+
+type reportConsolidatedItem struct {
+  reportSeriesItem
+  LNDgRebalanceCount int64 `json:"lndg_rebalance_count"`
+  LNDgRebalanceFeeCostSat float64 `json:"lndg_rebalance_fee_cost_sats"`
+  // OtherRebalanceFeeCostSat is the rebalance cost LNDg did not record:
+  // manual rebalances and other tools.
+  OtherRebalanceFeeCostSat float64 `json:"other_rebalance_fee_cost_sats"`
+  LNDgForwardCount int64 `json:"lndg_forward_count"`
+}
+
+type reportLNDgTotals struct {
+  RebalanceCount int64 `json:"rebalance_count"`
+  RebalanceFeeCostSat float64 `json:"rebalance_fee_cost_sats"`
+  OtherRebalanceFeeCostSat float64 `json:"other_rebalance_fee_cost_sats"`
+  ForwardCount int64 `json:"forward_count"`
+}
+
+// handleReportsConsolidated serves the stored daily reports with LNDg's
+// imported history applied, and LNDg's share of the rebalance cost.
+func (s *Server) handleReportsConsolidated(w http.ResponseWriter, r *http.Request) {
+  svc, errMsg := s.reportsService()
+  if svc == nil {
+    msg := strings.TrimSpace(errMsg)
+    if msg == "" {
+      msg = "reports unavailable"
+    }
+    writeError(w, http.StatusServiceUnavailable, msg)
+    return
+  }
+  loc, tzLabel, err := s.reportsLocation(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  key := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("range")))
+  if key == "" {
+    key = reports.RangeD1
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+
+  items, _, err := svc.Consolidated(ctx, key, time.Now(), loc)
+  if err != nil {
+    if strings.Contains(err.Error(), "invalid range") {
+      writeError(w, http.StatusBadRequest, err.Error())
+    } else {
+      writeError(w, http.StatusInternalServerError, "failed to load reports")
+    }
+    return
+  }
+  importedAt, err := svc.LNDgImportedAt(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load reports")
+    return
+  }
+
+  series := make([]reportConsolidatedItem, 0, len(items))
+  var totals reports.Metrics
+  var lndgTotals reportLNDgTotals
+  for _, item := range items {
+    row := reports.Row{ReportDate: item.ReportDate, Metrics: item.Metrics}
+    entry := reportConsolidatedItem{reportSeriesItem: mapSeries([]reports.Row{row})[0]}
+    entry.OtherRebalanceFeeCostSat = entry.RebalanceFeeCostSat
+    if item.LNDg != nil {
+      entry.LNDgRebalanceCount = item.LNDg.RebalanceCount
+      entry.LNDgRebalanceFeeCostSat = float64(item.LNDg.RebalanceFeeMsat) / 1000
+      entry.OtherRebalanceFeeCostSat = entry.RebalanceFeeCostSat - entry.LNDgRebalanceFeeCostSat
+      entry.LNDgForwardCount = item.LNDg.ForwardCount
+    }
+    series = append(series, entry)
+
+    totals.ForwardFeeRevenueMsat += item.Metrics.ForwardFeeRevenueMsat
+    totals.RebalanceFeeCostMsat += item.Metrics.RebalanceFeeCostMsat
+    totals.NetRoutingProfitMsat += item.Metrics.NetRoutingProfitMsat
+    totals.ForwardCount += item.Metrics.ForwardCount
+    totals.RebalanceCount += item.Metrics.RebalanceCount
+    totals.RoutedVolumeMsat += item.Metrics.RoutedVolumeMsat
+    lndgTotals.RebalanceCount += entry.LNDgRebalanceCount
+    lndgTotals.RebalanceFeeCostSat += entry.LNDgRebalanceFeeCostSat
+    lndgTotals.OtherRebalanceFeeCostSat += entry.OtherRebalanceFeeCostSat
+    lndgTotals.ForwardCount += entry.LNDgForwardCount
+  }
+
+  writeJSON(w, http.StatusOK, map[string]any{
+    "range": key,
+    "timezone": tzLabel,
+    "lndg_imported_at": importedAt,
+    "series": series,
+    "totals": metricsPayload(totals),
+    "lndg": lndgTotals,
+  })
+}
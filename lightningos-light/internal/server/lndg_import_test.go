@@ -0,0 +1,25 @@
+package server
+
+import (
+  "testing"
+  "time"
+)
+
+func TestParseLNDgBuckets(t *testing.T) {
+  buckets, err := parseLNDgBuckets("1777680000,2,3000,1000000\n1777680900,1,250,50000\n\n")
+  if err != nil {
+    t.Fatalf("parse: %v", err)
+  }
+  if len(buckets) != 2 {
+    t.Fatalf("expected 2 buckets, got %d", len(buckets))
+  }
+  if !buckets[0].At.Equal(time.Unix(1777680000, 0)) || buckets[0].Count != 2 || buckets[0].FeeMsat != 3000 || buckets[0].AmountMsat != 1000000 {
+    t.Fatalf("first bucket: %+v", buckets[0])
+  }
+  if buckets, err := parseLNDgBuckets(""); err != nil || len(buckets) != 0 {
+    t.Fatalf("empty output: %v %v", buckets, err)
+  }
+  if _, err := parseLNDgBuckets("ERROR:  relation \"gui_forwards\" does not exist"); err == nil {
+    t.Fatalf("expected an error for unexpected output")
+  }
+}
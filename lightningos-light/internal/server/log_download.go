@@ -0,0 +1,176 @@
+package server
+
+import (
+  "archive/tar"
+  "bytes"
+  "compress/gzip"
+  "fmt"
+  "io"
+  "net/http"
+  "regexp"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/system"
+)
+
+// A log download is a tar.gz with one file per service and a MANIFEST.txt,
+// meant to be attached to a support issue. Lines pass through
+// redactLogLine first, so credentials that services print (RPC passwords,
+// macaroons, tokens, extended private keys) stay on the node. The archive is
+// capped before compression; when a service has more, its newest lines are
+// kept and the manifest says so.
+const (
+  logDownloadDefaultWindow = 24 * time.Hour
+  logDownloadMaxWindow = 7 * 24 * time.Hour
+  logDownloadMaxBytes = 32 << 20
+  logDownloadScanLines = 500000
+)
+
+var (
+  // Values after a key that names a credential: "rpcpassword=x",
+  // "token: x", "Authorization: Bearer x".
+  logSecretAssignment = regexp.MustCompile(`(?i)((?:password|passwd|passphrase|secret|token|macaroon|privkey|private_key|seed|mnemonic|rpcauth|authorization|cookie)[\w.-]*"?\s*[:=]\s*"?)(?:bearer\s+)?[^\s",;&]+`)
+  logSecretBearer = regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/=-]{8,}`)
+  logSecretURLUser = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`)
+  // Serialized macaroons and other long hex blobs; pubkeys (66) and
+  // hashes (64) are shorter and stay readable.
+  logSecretHex = regexp.MustCompile(`\b[0-9a-fA-F]{100,}\b`)
+  logSecretExtendedKey = regexp.MustCompile(`\b[xyztuv]prv[1-9A-HJ-NP-Za-km-z]{100,}\b`)
+)
+
+func redactLogLine(line string) string {
+  line = logSecretAssignment.ReplaceAllString(line, "${1}<redacted>")
+  line = logSecretBearer.ReplaceAllString(line, "${1}<redacted>")
+  line = logSecretURLUser.ReplaceAllString(line, "${1}<redacted>@")
+  line = logSecretHex.ReplaceAllString(line, "<redacted>")
+  return logSecretExtendedKey.ReplaceAllString(line, "<redacted>")
+}
+
+// renderLogFile writes entries as "RFC3339 message" lines, redacted. When
+// they do not fit in budget bytes the oldest are dropped.
+func renderLogFile(entries []system.JournalEntry, budget int) ([]byte, int) {
+  lines := make([]string, 0, len(entries))
+  size, kept := 0, 0
+  for i := len(entries) - 1; i >= 0; i-- {
+    line := entries[i].Time.UTC().Format(time.RFC3339Nano) + " " + redactLogLine(entries[i].Message) + "\n"
+    if size+len(line) > budget {
+      break
+    }
+    lines = append(lines, line)
+    size += len(line)
+    kept++
+  }
+  var buf bytes.Buffer
+  buf.Grow(size)
+  for i := len(lines) - 1; i >= 0; i-- {
+    buf.WriteString(lines[i])
+  }
+  return buf.Bytes(), len(entries) - kept
+}
+
+type logArchiveFile struct {
+  Name string
+  Data []byte
+}
+
+func writeLogArchive(w io.Writer, dir string, files []logArchiveFile, modTime time.Time) error {
+  gz := gzip.NewWriter(w)
+  tw := tar.NewWriter(gz)
+  for _, file := range files {
+    hdr := &tar.Header{
+      Name: dir + "/" + file.Name,
+      Mode: 0o644,
+      Size: int64(len(file.Data)),
+      ModTime: modTime,
+    }
+    if err := tw.WriteHeader(hdr); err != nil {
+      return err
+    }
+    if _, err := tw.Write(file.Data); err != nil {
+      return err
+    }
+  }
+  if err := tw.Close(); err != nil {
+    return err
+  }
+  return gz.Close()
+}
+
+// handleLogDownload serves the logs of the chosen services over a window
+// (?services=lnd,bitcoind&since=&until=, RFC3339; the last 24 hours by
+// default, at most 7 days) as a redacted tar.gz. The search filters of
+// /api/logs apply too.
+func (s *Server) handleLogDownload(w http.ResponseWriter, r *http.Request) {
+  q, err := parseLogQuery(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  now := time.Now().UTC()
+  if q.Until.IsZero() {
+    q.Until = now
+  }
+  if q.Since.IsZero() {
+    q.Since = q.Until.Add(-logDownloadDefaultWindow)
+  }
+  if !q.Until.After(q.Since) {
+    writeError(w, http.StatusBadRequest, "since must be before until")
+    return
+  }
+  if q.Until.Sub(q.Since) > logDownloadMaxWindow {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("window too large: max %d days", int(logDownloadMaxWindow/(24*time.Hour))))
+    return
+  }
+  // The download holds every line, not the newest few.
+  q.Limit = 0
+
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+
+  var manifest strings.Builder
+  fmt.Fprintf(&manifest, "LightningOS log archive\ngenerated: %s\nwindow: %s to %s\n", now.Format(time.RFC3339), q.Since.Format(time.RFC3339), q.Until.Format(time.RFC3339))
+  if q.Substring != "" || q.Pattern != nil || q.MinLevel != "" {
+    fmt.Fprintf(&manifest, "filtered: q=%q re=%q level=%q\n", q.Substring, r.URL.Query().Get("re"), q.MinLevel)
+  }
+  manifest.WriteString("secrets (passwords, tokens, macaroons, private keys) are redacted\n\n")
+
+  files := []logArchiveFile{}
+  budget := logDownloadMaxBytes / len(q.Services)
+  for _, service := range q.Services {
+    lines, err := readServiceLog(ctx, service, q, logDownloadScanLines)
+    if err != nil {
+      if len(q.Services) == 1 {
+        writeError(w, http.StatusInternalServerError, fmt.Sprintf("log read failed: %v", err))
+        return
+      }
+      fmt.Fprintf(&manifest, "%s: unavailable: %v\n", service, err)
+      continue
+    }
+    entries, _ := mergeLogs(q, map[string][]system.JournalEntry{service: lines})
+    kept := make([]system.JournalEntry, len(entries))
+    for i, entry := range entries {
+      kept[i] = system.JournalEntry{Time: entry.Time, Message: entry.Message}
+    }
+    data, dropped := renderLogFile(kept, budget)
+    fmt.Fprintf(&manifest, "%s.log: %d lines", service, len(kept)-dropped)
+    if dropped > 0 || len(lines) >= logDownloadScanLines {
+      manifest.WriteString(", truncated to the newest lines")
+    }
+    manifest.WriteString("\n")
+    files = append(files, logArchiveFile{Name: service + ".log", Data: data})
+  }
+  files = append([]logArchiveFile{{Name: "MANIFEST.txt", Data: []byte(manifest.String())}}, files...)
+
+  // Everything is read by now, so failures above still get a JSON error;
+  // the archive itself is compressed straight into the response.
+  stamp := now.Format("20060102-1504")
+  s.logger.Printf("logs: archive of %s downloaded", strings.Join(q.Services, ","))
+  w.Header().Set("Content-Type", "application/gzip")
+  w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="lightningos-logs-%s.tar.gz"`, stamp))
+  w.Header().Set("Cache-Control", "no-store")
+  w.WriteHeader(http.StatusOK)
+  if err := writeLogArchive(w, "lightningos-logs-"+stamp, files, now); err != nil {
+    s.logger.Printf("logs: archive write failed: %v", err)
+  }
+}
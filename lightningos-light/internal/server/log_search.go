@@ -0,0 +1,313 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/system"
+)
+
+// Log search reads several services at once and filters in Go: a substring
+// (q), a regular expression (re, Go RE2 syntax, so no pattern can run
+// away), a time range and a minimum severity. Each service contributes at
+// most logSearchScanLines lines before filtering; the newest matches are
+// kept. bitcoind runs in a container, so its lines come from docker logs
+// instead of the journal.
+const (
+  logSearchDefaultLines = 200
+  logSearchMaxLines = 5000
+  logSearchScanLines = 20000
+  logSearchMaxPattern = 200
+  logSearchMaxServices = 6
+
+  bitcoindLogService = "bitcoind"
+)
+
+var logSeverityRank = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3}
+
+type logEntry struct {
+  Time time.Time `json:"time"`
+  Service string `json:"service"`
+  Level string `json:"level"`
+  Message string `json:"message"`
+}
+
+type logQuery struct {
+  Services []string
+  Substring string
+  Pattern *regexp.Regexp
+  Since time.Time
+  Until time.Time
+  MinLevel string
+  Limit int
+}
+
+var lndLogLevels = map[string]string{
+  "[TRC]": "debug",
+  "[DBG]": "debug",
+  "[INF]": "info",
+  "[WRN]": "warn",
+  "[ERR]": "error",
+  "[CRT]": "error",
+}
+
+// parseLogSeverity reads the level of a line: LND's "[ERR]" tags, the
+// "ERROR:" style of postgres and bitcoind, or, for lines without one, words
+// that mean trouble.
+func parseLogSeverity(service, message string) string {
+  if service == "lnd" {
+    // The first tag is the line's own; later ones are quoted text.
+    first, level := -1, ""
+    for tag, tagLevel := range lndLogLevels {
+      if i := strings.Index(message, tag); i >= 0 && (first < 0 || i < first) {
+        first, level = i, tagLevel
+      }
+    }
+    if level != "" {
+      return level
+    }
+  }
+  upper := strings.ToUpper(message)
+  for _, marker := range []string{"PANIC", "FATAL:", "ERROR:", "[ERROR]"} {
+    if strings.Contains(upper, marker) {
+      return "error"
+    }
+  }
+  for _, marker := range []string{"WARNING:", "[WARNING]"} {
+    if strings.Contains(upper, marker) {
+      return "warn"
+    }
+  }
+  if service == "lnd" {
+    return "info"
+  }
+  lower := strings.ToLower(message)
+  switch {
+  case strings.Contains(lower, "failed"), strings.Contains(lower, "error"), strings.Contains(lower, "timeout"):
+    return "warn"
+  case strings.Contains(upper, "DEBUG"):
+    return "debug"
+  }
+  return "info"
+}
+
+func (q logQuery) matches(entry logEntry) bool {
+  if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+    return false
+  }
+  if !q.Until.IsZero() && !entry.Time.Before(q.Until) {
+    return false
+  }
+  if q.MinLevel != "" && logSeverityRank[entry.Level] < logSeverityRank[q.MinLevel] {
+    return false
+  }
+  if q.Substring != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(q.Substring)) {
+    return false
+  }
+  if q.Pattern != nil && !q.Pattern.MatchString(entry.Message) {
+    return false
+  }
+  return true
+}
+
+// mergeLogs filters every service's lines, interleaves them by time and
+// keeps the newest limit matches. truncated tells whether any were dropped.
+func mergeLogs(q logQuery, sources map[string][]system.JournalEntry) ([]logEntry, bool) {
+  entries := []logEntry{}
+  for service, lines := range sources {
+    for _, line := range lines {
+      entry := logEntry{Time: line.Time, Service: service, Level: parseLogSeverity(service, line.Message), Message: line.Message}
+      if q.matches(entry) {
+        entries = append(entries, entry)
+      }
+    }
+  }
+  sort.SliceStable(entries, func(i, j int) bool {
+    if entries[i].Time.Equal(entries[j].Time) {
+      return entries[i].Service < entries[j].Service
+    }
+    return entries[i].Time.Before(entries[j].Time)
+  })
+  if q.Limit > 0 && len(entries) > q.Limit {
+    return entries[len(entries)-q.Limit:], true
+  }
+  return entries, false
+}
+
+// isLogSearch tells a search from the plain tail of one service, which keeps
+// its original response.
+func isLogSearch(r *http.Request) bool {
+  query := r.URL.Query()
+  for _, key := range []string{"services", "q", "re", "since", "until", "level"} {
+    if query.Has(key) {
+      return true
+    }
+  }
+  return false
+}
+
+func mapLogService(name string) string {
+  name = strings.TrimSpace(name)
+  if name == bitcoindLogService || name == "bitcoin" {
+    return bitcoindLogService
+  }
+  return mapService(name)
+}
+
+func parseLogQuery(r *http.Request) (logQuery, error) {
+  query := r.URL.Query()
+  q := logQuery{Limit: logSearchDefaultLines}
+  names := strings.Split(query.Get("services"), ",")
+  if !query.Has("services") {
+    names = []string{query.Get("service")}
+  }
+  for _, name := range names {
+    service := mapLogService(name)
+    if service == "" {
+      return q, errors.New("unsupported service")
+    }
+    if !containsString(q.Services, service) {
+      q.Services = append(q.Services, service)
+    }
+  }
+  if len(q.Services) > logSearchMaxServices {
+    return q, fmt.Errorf("too many services: max %d", logSearchMaxServices)
+  }
+  if raw := strings.TrimSpace(query.Get("lines")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > logSearchMaxLines {
+      return q, fmt.Errorf("lines out of range: 1 to %d", logSearchMaxLines)
+    }
+    q.Limit = parsed
+  }
+  q.Substring = strings.TrimSpace(query.Get("q"))
+  if len(q.Substring) > logSearchMaxPattern {
+    return q, fmt.Errorf("pattern too long: max %d characters", logSearchMaxPattern)
+  }
+  if raw := strings.TrimSpace(query.Get("re")); raw != "" {
+    if len(raw) > logSearchMaxPattern {
+      return q, fmt.Errorf("pattern too long: max %d characters", logSearchMaxPattern)
+    }
+    pattern, err := regexp.Compile(raw)
+    if err != nil {
+      return q, errors.New("invalid regular expression")
+    }
+    q.Pattern = pattern
+  }
+  for key, target := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+    raw := strings.TrimSpace(query.Get(key))
+    if raw == "" {
+      continue
+    }
+    parsed, err := time.Parse(time.RFC3339, raw)
+    if err != nil {
+      return q, fmt.Errorf("%s must be RFC3339", key)
+    }
+    *target = parsed.UTC()
+  }
+  if !q.Since.IsZero() && !q.Until.IsZero() && !q.Until.After(q.Since) {
+    return q, errors.New("since must be before until")
+  }
+  if level := strings.ToLower(strings.TrimSpace(query.Get("level"))); level != "" {
+    if level == "warning" {
+      level = "warn"
+    }
+    if _, ok := logSeverityRank[level]; !ok {
+      return q, errors.New("level must be debug, info, warn or error")
+    }
+    q.MinLevel = level
+  }
+  return q, nil
+}
+
+// readServiceLog reads up to max of one service's latest lines in the
+// query's time range.
+func readServiceLog(ctx context.Context, service string, q logQuery, max int) ([]system.JournalEntry, error) {
+  if service == bitcoindLogService {
+    return bitcoindLogRange(ctx, q.Since, q.Until, max)
+  }
+  return system.JournalRange(ctx, service, q.Since, q.Until, max)
+}
+
+func bitcoindLogRange(ctx context.Context, since, until time.Time, max int) ([]system.JournalEntry, error) {
+  paths := bitcoinCoreAppPaths()
+  containerID, err := composeContainerID(ctx, paths.Root, paths.ComposePath, "bitcoind")
+  if err != nil {
+    return nil, err
+  }
+  if containerID == "" {
+    return nil, errors.New("bitcoind container not running")
+  }
+  args := []string{"logs", "--timestamps", "--tail", strconv.Itoa(max)}
+  if !since.IsZero() {
+    args = append(args, "--since", since.Format(time.RFC3339))
+  }
+  if !until.IsZero() {
+    args = append(args, "--until", until.Format(time.RFC3339))
+  }
+  out, err := containerCLI(ctx, append(args, containerID)...)
+  if err != nil {
+    return nil, err
+  }
+  return parseDockerLogLines(out), nil
+}
+
+// parseDockerLogLines reads "docker logs --timestamps" output, where each
+// line starts with an RFC3339 timestamp. Lines without one are skipped.
+func parseDockerLogLines(out string) []system.JournalEntry {
+  entries := []system.JournalEntry{}
+  for _, line := range strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n") {
+    stamp, message, ok := strings.Cut(line, " ")
+    if !ok || strings.TrimSpace(message) == "" {
+      continue
+    }
+    at, err := time.Parse(time.RFC3339Nano, stamp)
+    if err != nil {
+      continue
+    }
+    entries = append(entries, system.JournalEntry{Time: at.UTC(), Message: message})
+  }
+  return entries
+}
+
+// handleLogSearch answers /api/logs when any search parameter is given.
+// A service that cannot be read fails the request when it is the only one
+// asked for, and is listed in unavailable otherwise.
+func (s *Server) handleLogSearch(w http.ResponseWriter, r *http.Request) {
+  q, err := parseLogQuery(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+
+  sources := map[string][]system.JournalEntry{}
+  unavailable := []string{}
+  for _, service := range q.Services {
+    lines, err := readServiceLog(ctx, service, q, logSearchScanLines)
+    if err != nil {
+      if len(q.Services) == 1 {
+        writeError(w, http.StatusInternalServerError, fmt.Sprintf("log read failed: %v", err))
+        return
+      }
+      unavailable = append(unavailable, service)
+      continue
+    }
+    sources[service] = lines
+  }
+  entries, truncated := mergeLogs(q, sources)
+  writeJSON(w, http.StatusOK, map[string]any{
+    "services": q.Services,
+    "entries": entries,
+    "truncated": truncated,
+    "unavailable": unavailable,
+  })
+}
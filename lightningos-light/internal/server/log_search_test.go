@@ -0,0 +1,72 @@
+package server
+
+import (
+  "net/http/httptest"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/system"
+)
+
+func TestParseLogSeverity(t *testing.T) {
+  cases := []struct {
+    service, message, want string
+  }{
+    {"lnd", "2026-03-01 12:00:00.000 [ERR] HSWC: failed: [INF] quoted", "error"},
+    {"lnd", "2026-03-01 12:00:00.000 [WRN] PEER: ping timeout", "warn"},
+    {"lnd", "2026-03-01 12:00:00.000 [INF] SRVR: failed to connect", "info"},
+    {"lnd", "2026-03-01 12:00:00.000 [DBG] CRTR: pruned", "debug"},
+    {"postgresql", "ERROR:  relation does not exist", "error"},
+    {"bitcoind", "Warning: disk space is low", "warn"},
+    {"lightningos-manager", "notifications: list failed: timeout", "warn"},
+    {"lightningos-manager", "server started", "info"},
+  }
+  for _, c := range cases {
+    if got := parseLogSeverity(c.service, c.message); got != c.want {
+      t.Errorf("%s %q: %s, want %s", c.service, c.message, got, c.want)
+    }
+  }
+}
+
+func TestMergeLogs(t *testing.T) {
+  base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  q, err := parseLogQuery(httptest.NewRequest("GET", "/api/logs?services=lnd,bitcoin&re=(?i)peer&level=warn&lines=2&since=2026-03-01T11:00:00Z", nil))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(q.Services) != 2 || q.Services[1] != bitcoindLogService {
+    t.Fatalf("services %v", q.Services)
+  }
+  entries, truncated := mergeLogs(q, map[string][]system.JournalEntry{
+    "lnd": {
+      {Time: base.Add(-2 * time.Hour), Message: "[ERR] PEER: too early"},
+      {Time: base, Message: "[WRN] PEER: ping timeout"},
+      {Time: base.Add(2 * time.Minute), Message: "[INF] PEER: connected"},
+      {Time: base.Add(3 * time.Minute), Message: "[ERR] PEER: disconnected"},
+    },
+    bitcoindLogService: {
+      {Time: base.Add(time.Minute), Message: "Warning: peer=7 misbehaving"},
+    },
+  })
+  if !truncated || len(entries) != 2 {
+    t.Fatalf("entries %+v, truncated %v", entries, truncated)
+  }
+  if entries[0].Service != bitcoindLogService || entries[1].Level != "error" {
+    t.Fatalf("entries %+v", entries)
+  }
+}
+
+func TestParseLogQueryRejects(t *testing.T) {
+  for _, query := range []string{"services=lnd,sshd", "service=lnd&re=(", "service=lnd&level=loud", "service=lnd&since=2026-03-02T00:00:00Z&until=2026-03-01T00:00:00Z", "service=lnd&lines=0"} {
+    if _, err := parseLogQuery(httptest.NewRequest("GET", "/api/logs?"+query, nil)); err == nil {
+      t.Errorf("accepted %s", query)
+    }
+  }
+}
+
+func TestParseDockerLogLines(t *testing.T) {
+  entries := parseDockerLogLines("2026-03-01T12:00:00.123456789Z UpdateTip: new best=000000\nnot a log line\n2026-03-01T12:00:01Z \n")
+  if len(entries) != 1 || entries[0].Message != "UpdateTip: new best=000000" || entries[0].Time.Nanosecond() != 123456789 {
+    t.Fatalf("entries %+v", entries)
+  }
+}
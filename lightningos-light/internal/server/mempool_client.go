@@ -0,0 +1,351 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/config"
+)
+
+// The mempool client is the one way the manager talks to mempool.space
+// style explorers. Answers are cached per path and callers asking for the
+// same path at once share one request. Each instance has its own rate limit;
+// one that throttles (429) or fails is skipped for a while and the next one
+// is tried. When every instance fails, a cached answer up to an hour old is
+// served instead of an error.
+const (
+  mempoolFetchTimeout = 10 * time.Second
+  mempoolStaleTTL = time.Hour
+  mempoolThrottleCooldown = time.Minute
+  mempoolFailureCooldown = 30 * time.Second
+  mempoolRateBurst = 10
+  mempoolMaxBody = 8 << 20
+  mempoolMaxCached = 1024
+
+  mempoolFeesTTL = 30 * time.Second
+  mempoolBlocksTTL = time.Minute
+  mempoolGraphTTL = 10 * time.Minute
+)
+
+var (
+  errMempoolThrottled = errors.New("mempool api rate limited")
+  errMempoolUnavailable = errors.New("mempool api unavailable")
+)
+
+// mempoolAnswerError is a definite answer from an instance (404 for an
+// unknown node, 400 for a bad request): asking another one would not help.
+type mempoolAnswerError struct {
+  msg string
+}
+
+func (e mempoolAnswerError) Error() string {
+  return "mempool api error: " + e.msg
+}
+
+type mempoolInstance struct {
+  base string
+  cooldownUntil time.Time
+  lastError string
+  requests int64
+  throttled int64
+}
+
+type mempoolCacheEntry struct {
+  body []byte
+  fetched time.Time
+}
+
+type mempoolCall struct {
+  done chan struct{}
+  body []byte
+  err error
+}
+
+type mempoolClient struct {
+  http *http.Client
+  limiter *rateLimiter
+  // unavailable is returned when no instance is configured (regtest).
+  unavailable error
+  now func() time.Time
+  // taskContext bounds a request under the server's root context; without
+  // it (tests) a request gets a plain timeout.
+  taskContext func(name string, class timeoutClass) (context.Context, context.CancelFunc)
+
+  mu sync.Mutex
+  instances []*mempoolInstance
+  cache map[string]mempoolCacheEntry
+  inflight map[string]*mempoolCall
+}
+
+func newMempoolClient(cfg config.MempoolConfig, network chainNetwork) *mempoolClient {
+  c := &mempoolClient{
+    http: &http.Client{Timeout: mempoolFetchTimeout},
+    limiter: newRateLimiter(float64(cfg.RequestRate())/60, mempoolRateBurst),
+    unavailable: errors.New("mempool explorer not available on " + network.Name),
+    now: time.Now,
+    cache: map[string]mempoolCacheEntry{},
+    inflight: map[string]*mempoolCall{},
+  }
+  bases := append([]string{}, cfg.URLs...)
+  if cfg.PublicFallbackEnabled() && network.MempoolURL != "" {
+    bases = append(bases, network.MempoolURL)
+  }
+  seen := map[string]bool{}
+  for _, base := range bases {
+    base = strings.TrimRight(strings.TrimSpace(base), "/")
+    base = strings.TrimSuffix(strings.TrimSuffix(base, "/api/v1"), "/api")
+    if base == "" || seen[base] {
+      continue
+    }
+    seen[base] = true
+    c.instances = append(c.instances, &mempoolInstance{base: base})
+  }
+  return c
+}
+
+// get decodes the answer for path (under /api/v1) into dst, from the cache
+// when it is younger than ttl.
+func (c *mempoolClient) get(ctx context.Context, path string, ttl time.Duration, dst any) error {
+  body, err := c.fetch(ctx, path, ttl)
+  if err != nil {
+    return err
+  }
+  return json.Unmarshal(body, dst)
+}
+
+func (c *mempoolClient) fetch(ctx context.Context, path string, ttl time.Duration) ([]byte, error) {
+  c.mu.Lock()
+  if len(c.instances) == 0 {
+    c.mu.Unlock()
+    return nil, c.unavailable
+  }
+  if entry, ok := c.cache[path]; ok && c.now().Sub(entry.fetched) < ttl {
+    c.mu.Unlock()
+    return entry.body, nil
+  }
+  call := c.inflight[path]
+  if call == nil {
+    call = &mempoolCall{done: make(chan struct{})}
+    c.inflight[path] = call
+    // The request outlives the caller that started it, so the others
+    // waiting on it are not cut short by its deadline.
+    go c.run(path, call)
+  }
+  c.mu.Unlock()
+  select {
+  case <-call.done:
+    return call.body, call.err
+  case <-ctx.Done():
+    return nil, ctx.Err()
+  }
+}
+
+func (c *mempoolClient) run(path string, call *mempoolCall) {
+  body, err := c.fetchFromInstances(path)
+  c.mu.Lock()
+  now := c.now()
+  if err == nil {
+    if len(c.cache) >= mempoolMaxCached {
+      for key, entry := range c.cache {
+        if now.Sub(entry.fetched) > mempoolStaleTTL {
+          delete(c.cache, key)
+        }
+      }
+    }
+    c.cache[path] = mempoolCacheEntry{body: body, fetched: now}
+  } else if entry, ok := c.cache[path]; ok && now.Sub(entry.fetched) < mempoolStaleTTL {
+    var answer mempoolAnswerError
+    if !errors.As(err, &answer) {
+      body, err = entry.body, nil
+    }
+  }
+  delete(c.inflight, path)
+  c.mu.Unlock()
+  call.body, call.err = body, err
+  close(call.done)
+}
+
+// fetchFromInstances asks each instance in order until one answers. When
+// the only obstacle is the local rate limit, it waits for the next token
+// rather than failing.
+func (c *mempoolClient) fetchFromInstances(path string) ([]byte, error) {
+  started := c.now()
+  for {
+    var lastErr error
+    var wait time.Duration
+    for _, inst := range c.instances {
+      now := c.now()
+      c.mu.Lock()
+      cooling := now.Before(inst.cooldownUntil)
+      c.mu.Unlock()
+      if cooling {
+        continue
+      }
+      if ok, next := c.limiter.allow(inst.base, now); !ok {
+        if wait == 0 || next < wait {
+          wait = next
+        }
+        continue
+      }
+      body, retryAfter, err := c.request(inst.base + "/api/v1" + path)
+      c.mu.Lock()
+      inst.requests++
+      var answer mempoolAnswerError
+      switch {
+      case err == nil:
+        inst.lastError = ""
+      case errors.As(err, &answer):
+      case errors.Is(err, errMempoolThrottled):
+        inst.throttled++
+        inst.cooldownUntil = now.Add(max(retryAfter, mempoolThrottleCooldown))
+        inst.lastError = err.Error()
+      default:
+        inst.cooldownUntil = now.Add(mempoolFailureCooldown)
+        inst.lastError = err.Error()
+      }
+      c.mu.Unlock()
+      if err == nil || errors.As(err, &answer) {
+        return body, err
+      }
+      lastErr = err
+    }
+    if wait > 0 && c.now().Add(wait).Sub(started) < mempoolFetchTimeout {
+      time.Sleep(wait)
+      continue
+    }
+    if lastErr == nil {
+      if wait > 0 {
+        return nil, errMempoolThrottled
+      }
+      return nil, errMempoolUnavailable
+    }
+    return nil, lastErr
+  }
+}
+
+func (c *mempoolClient) request(url string) ([]byte, time.Duration, error) {
+  var ctx context.Context
+  var cancel context.CancelFunc
+  if c.taskContext != nil {
+    ctx, cancel = c.taskContext("mempool/fetch", timeoutMedium)
+  } else {
+    ctx, cancel = context.WithTimeout(context.Background(), mempoolFetchTimeout)
+  }
+  defer cancel()
+  req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+  if err != nil {
+    return nil, 0, err
+  }
+  resp, err := c.http.Do(req)
+  if err != nil {
+    return nil, 0, err
+  }
+  defer resp.Body.Close()
+  body, err := io.ReadAll(io.LimitReader(resp.Body, mempoolMaxBody))
+  if err != nil {
+    return nil, 0, err
+  }
+  switch {
+  case resp.StatusCode == http.StatusTooManyRequests:
+    seconds, _ := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After")))
+    return nil, time.Duration(seconds) * time.Second, errMempoolThrottled
+  case resp.StatusCode >= 500:
+    return nil, 0, fmt.Errorf("mempool api error: %s", resp.Status)
+  case resp.StatusCode != http.StatusOK:
+    msg := strings.TrimSpace(string(body))
+    if msg == "" {
+      msg = resp.Status
+    }
+    return nil, 0, mempoolAnswerError{msg: msg}
+  }
+  return body, 0, nil
+}
+
+func (c *mempoolClient) fees(ctx context.Context) (mempoolFeeRecommendation, error) {
+  var fees mempoolFeeRecommendation
+  err := c.get(ctx, "/fees/recommended", mempoolFeesTTL, &fees)
+  return fees, err
+}
+
+func (c *mempoolClient) connectivity(ctx context.Context) ([]mempoolConnectivityNode, error) {
+  var nodes []mempoolConnectivityNode
+  err := c.get(ctx, "/lightning/nodes/rankings/connectivity", mempoolGraphTTL, &nodes)
+  return nodes, err
+}
+
+func (c *mempoolClient) nodeInfo(ctx context.Context, pubkey string) (mempoolNodeInfo, error) {
+  var info mempoolNodeInfo
+  err := c.get(ctx, "/lightning/nodes/"+pubkey, mempoolGraphTTL, &info)
+  return info, err
+}
+
+type mempoolBlock struct {
+  ID string `json:"id"`
+  Height int64 `json:"height"`
+  Timestamp int64 `json:"timestamp"`
+  TxCount int64 `json:"tx_count"`
+  Extras struct {
+    MedianFee float64 `json:"medianFee"`
+    FeeRange []float64 `json:"feeRange"`
+    TotalFees int64 `json:"totalFees"`
+  } `json:"extras"`
+}
+
+// recentBlocks returns the explorer's latest blocks, newest first.
+func (c *mempoolClient) recentBlocks(ctx context.Context) ([]mempoolBlock, error) {
+  var blocks []mempoolBlock
+  err := c.get(ctx, "/blocks", mempoolBlocksTTL, &blocks)
+  return blocks, err
+}
+
+// block returns the block at height as the explorer sees it.
+func (c *mempoolClient) block(ctx context.Context, height int64) (mempoolBlock, error) {
+  var blocks []mempoolBlock
+  if err := c.get(ctx, fmt.Sprintf("/blocks/%d", height), mempoolBlocksTTL, &blocks); err != nil {
+    return mempoolBlock{}, err
+  }
+  for _, block := range blocks {
+    if block.Height == height {
+      return block, nil
+    }
+  }
+  return mempoolBlock{}, mempoolAnswerError{msg: fmt.Sprintf("block %d not found", height)}
+}
+
+type mempoolInstanceStatus struct {
+  URL string `json:"url"`
+  Requests int64 `json:"requests"`
+  Throttled int64 `json:"throttled"`
+  CoolingDownUntil *time.Time `json:"cooling_down_until,omitempty"`
+  LastError string `json:"last_error,omitempty"`
+}
+
+func (c *mempoolClient) status() []mempoolInstanceStatus {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  now := c.now()
+  items := make([]mempoolInstanceStatus, 0, len(c.instances))
+  for _, inst := range c.instances {
+    item := mempoolInstanceStatus{URL: inst.base, Requests: inst.requests, Throttled: inst.throttled, LastError: inst.lastError}
+    if now.Before(inst.cooldownUntil) {
+      until := inst.cooldownUntil.UTC()
+      item.CoolingDownUntil = &until
+    }
+    items = append(items, item)
+  }
+  return items
+}
+
+// handleMempoolStatus lists the explorer instances in the order they are
+// tried, with their request counts and whether they are being skipped.
+func (s *Server) handleMempoolStatus(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, map[string]any{"instances": s.mempool.status()})
+}
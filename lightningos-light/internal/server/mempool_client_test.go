@@ -0,0 +1,123 @@
+package server
+
+import (
+  "context"
+  "net/http"
+  "net/http/httptest"
+  "sync"
+  "sync/atomic"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+)
+
+func TestMempoolClientFailover(t *testing.T) {
+  var throttledHits, okHits atomic.Int32
+  throttling := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    throttledHits.Add(1)
+    w.Header().Set("Retry-After", "120")
+    w.WriteHeader(http.StatusTooManyRequests)
+  }))
+  defer throttling.Close()
+  failing := atomic.Bool{}
+  healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    okHits.Add(1)
+    if failing.Load() {
+      w.WriteHeader(http.StatusBadGateway)
+      return
+    }
+    if r.URL.Path == "/api/v1/lightning/nodes/unknown" {
+      http.Error(w, "node not found", http.StatusNotFound)
+      return
+    }
+    w.Write([]byte(`{"fastestFee":12,"halfHourFee":8,"hourFee":5}`))
+  }))
+  defer healthy.Close()
+
+  disabled := false
+  c := newMempoolClient(config.MempoolConfig{URLs: []string{throttling.URL + "/api/v1/", healthy.URL}, PublicFallback: &disabled}, chainNetworks["mainnet"])
+  now := time.Now()
+  c.now = func() time.Time { return now }
+  ctx := context.Background()
+
+  fees, err := c.fees(ctx)
+  if err != nil || fees.FastestFee != 12 {
+    t.Fatalf("fees = %+v, %v", fees, err)
+  }
+  if throttledHits.Load() != 1 || okHits.Load() != 1 {
+    t.Fatalf("hits = %d throttled, %d ok", throttledHits.Load(), okHits.Load())
+  }
+  status := c.status()
+  if len(status) != 2 || status[0].CoolingDownUntil == nil || !status[0].CoolingDownUntil.Equal(now.Add(120*time.Second).UTC()) || status[0].Throttled != 1 {
+    t.Fatalf("status = %+v, want the throttled instance cooling down for Retry-After", status)
+  }
+
+  // Cached, then refetched from the healthy instance only while the other
+  // one cools down.
+  if _, err := c.fees(ctx); err != nil || okHits.Load() != 1 {
+    t.Fatalf("cached fees refetched: %d hits, %v", okHits.Load(), err)
+  }
+  now = now.Add(mempoolFeesTTL)
+  if _, err := c.fees(ctx); err != nil || okHits.Load() != 2 || throttledHits.Load() != 1 {
+    t.Fatalf("hits = %d throttled, %d ok, %v", throttledHits.Load(), okHits.Load(), err)
+  }
+
+  // A definite answer is not retried elsewhere.
+  if _, err := c.nodeInfo(ctx, "unknown"); err == nil || err.Error() != "mempool api error: node not found" {
+    t.Fatalf("unknown node: %v", err)
+  }
+
+  // With every instance failing, the last answer is still served.
+  failing.Store(true)
+  now = now.Add(mempoolFeesTTL)
+  if fees, err := c.fees(ctx); err != nil || fees.FastestFee != 12 {
+    t.Fatalf("stale fees = %+v, %v", fees, err)
+  }
+  now = now.Add(mempoolStaleTTL)
+  if _, err := c.fees(ctx); err == nil {
+    t.Fatal("fees older than the stale limit were served")
+  }
+}
+
+func TestMempoolClientCoalesces(t *testing.T) {
+  var hits atomic.Int32
+  release := make(chan struct{})
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    hits.Add(1)
+    <-release
+    w.Write([]byte(`[{"publicKey":"02aa","alias":"a"}]`))
+  }))
+  defer srv.Close()
+  c := newMempoolClient(config.MempoolConfig{URLs: []string{srv.URL}}, chainNetworks["regtest"])
+
+  var wg sync.WaitGroup
+  errs := make(chan error, 5)
+  for i := 0; i < 5; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      nodes, err := c.connectivity(context.Background())
+      if err == nil && len(nodes) != 1 {
+        t.Errorf("nodes = %+v", nodes)
+      }
+      errs <- err
+    }()
+  }
+  time.Sleep(50 * time.Millisecond)
+  close(release)
+  wg.Wait()
+  close(errs)
+  for err := range errs {
+    if err != nil {
+      t.Fatalf("connectivity: %v", err)
+    }
+  }
+  if hits.Load() != 1 {
+    t.Fatalf("%d requests, want one shared by all callers", hits.Load())
+  }
+
+  if _, err := newMempoolClient(config.MempoolConfig{}, chainNetworks["regtest"]).fees(context.Background()); err == nil {
+    t.Fatal("regtest without instances must have no explorer")
+  }
+}
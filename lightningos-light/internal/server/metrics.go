@@ -0,0 +1,217 @@
+package server
+
+import (
+  "fmt"
+  "math"
+  "net/http"
+  "runtime"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "sync/atomic"
+  "time"
+
+  "github.com/go-chi/chi/v5"
+)
+
+// httpLatencyBuckets are the histogram upper bounds in seconds. LND calls
+// dominate, so the range runs from fast cache hits to the long RPC timeouts.
+var httpLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type routeKey struct {
+  method string
+  route string
+}
+
+type routeMetrics struct {
+  count uint64
+  sum float64
+  buckets []uint64
+  codes map[int]uint64
+  // peak is the slowest recent request, decaying by half every
+  // latencyPeakHalfLife; adaptive deadlines are stretched from it.
+  peak time.Duration
+  peakAt time.Time
+}
+
+const latencyPeakHalfLife = 10 * time.Minute
+
+// httpMetrics aggregates per-route request counts and latencies for the
+// Prometheus endpoint.
+type httpMetrics struct {
+  mu sync.Mutex
+  routes map[routeKey]*routeMetrics
+  inFlight atomic.Int64
+  rateLimited atomic.Uint64
+  panics atomic.Uint64
+  started time.Time
+}
+
+func newHTTPMetrics() *httpMetrics {
+  return &httpMetrics{routes: map[routeKey]*routeMetrics{}, started: time.Now()}
+}
+
+func (m *httpMetrics) observe(method, route string, status int, elapsed time.Duration) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  key := routeKey{method: method, route: route}
+  rm := m.routes[key]
+  if rm == nil {
+    rm = &routeMetrics{buckets: make([]uint64, len(httpLatencyBuckets)), codes: map[int]uint64{}}
+    m.routes[key] = rm
+  }
+  seconds := elapsed.Seconds()
+  rm.count++
+  rm.sum += seconds
+  rm.codes[status]++
+  for i, bound := range httpLatencyBuckets {
+    if seconds <= bound {
+      rm.buckets[i]++
+    }
+  }
+  now := time.Now()
+  if elapsed >= decayedPeak(rm.peak, now.Sub(rm.peakAt)) {
+    rm.peak, rm.peakAt = elapsed, now
+  }
+}
+
+func decayedPeak(peak, age time.Duration) time.Duration {
+  if peak <= 0 || age < 0 {
+    return peak
+  }
+  return time.Duration(float64(peak) * math.Pow(0.5, float64(age)/float64(latencyPeakHalfLife)))
+}
+
+// recentPeak is the decayed slowest request of a route; 0 when unknown.
+func (m *httpMetrics) recentPeak(method, route string, now time.Time) time.Duration {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  rm := m.routes[routeKey{method: method, route: route}]
+  if rm == nil {
+    return 0
+  }
+  return decayedPeak(rm.peak, now.Sub(rm.peakAt))
+}
+
+// routePattern is the chi pattern that served r (e.g. /api/jobs/{id}), so
+// metrics stay bounded no matter which IDs clients request.
+func routePattern(r *http.Request) string {
+  if rctx := chi.RouteContext(r.Context()); rctx != nil {
+    if pattern := rctx.RoutePattern(); pattern != "" {
+      return pattern
+    }
+  }
+  return "unmatched"
+}
+
+// withMetrics times every request. Event streams are left out of the
+// latency histogram since they stay open for as long as the client wants.
+func (s *Server) withMetrics() func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      start := time.Now()
+      s.metrics.inFlight.Add(1)
+      ww := &responseWriter{ResponseWriter: w, status: 200}
+      defer func() {
+        s.metrics.inFlight.Add(-1)
+        if strings.HasPrefix(ww.Header().Get("Content-Type"), "text/event-stream") {
+          return
+        }
+        s.metrics.observe(r.Method, routePattern(r), ww.status, time.Since(start))
+      }()
+      next.ServeHTTP(ww, r)
+    })
+  }
+}
+
+func promLabel(value string) string {
+  return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func promFloat(v float64) string {
+  return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// render writes the metrics in the Prometheus text exposition format.
+func (m *httpMetrics) render(b *strings.Builder) {
+  m.mu.Lock()
+  keys := make([]routeKey, 0, len(m.routes))
+  for key := range m.routes {
+    keys = append(keys, key)
+  }
+  sort.Slice(keys, func(i, j int) bool {
+    if keys[i].route != keys[j].route {
+      return keys[i].route < keys[j].route
+    }
+    return keys[i].method < keys[j].method
+  })
+
+  b.WriteString("# HELP lightningos_http_requests_total HTTP requests by route, method and status code.\n")
+  b.WriteString("# TYPE lightningos_http_requests_total counter\n")
+  for _, key := range keys {
+    rm := m.routes[key]
+    codes := make([]int, 0, len(rm.codes))
+    for code := range rm.codes {
+      codes = append(codes, code)
+    }
+    sort.Ints(codes)
+    for _, code := range codes {
+      fmt.Fprintf(b, "lightningos_http_requests_total{route=\"%s\",method=\"%s\",code=\"%d\"} %d\n", promLabel(key.route), key.method, code, rm.codes[code])
+    }
+  }
+
+  b.WriteString("# HELP lightningos_http_request_duration_seconds HTTP request latency by route and method.\n")
+  b.WriteString("# TYPE lightningos_http_request_duration_seconds histogram\n")
+  for _, key := range keys {
+    rm := m.routes[key]
+    labels := fmt.Sprintf("route=\"%s\",method=\"%s\"", promLabel(key.route), key.method)
+    for i, bound := range httpLatencyBuckets {
+      fmt.Fprintf(b, "lightningos_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, promFloat(bound), rm.buckets[i])
+    }
+    fmt.Fprintf(b, "lightningos_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, rm.count)
+    fmt.Fprintf(b, "lightningos_http_request_duration_seconds_sum{%s} %s\n", labels, promFloat(rm.sum))
+    fmt.Fprintf(b, "lightningos_http_request_duration_seconds_count{%s} %d\n", labels, rm.count)
+  }
+  m.mu.Unlock()
+
+  b.WriteString("# HELP lightningos_http_requests_in_flight HTTP requests being served.\n")
+  b.WriteString("# TYPE lightningos_http_requests_in_flight gauge\n")
+  fmt.Fprintf(b, "lightningos_http_requests_in_flight %d\n", m.inFlight.Load())
+  b.WriteString("# HELP lightningos_http_rate_limited_total Requests rejected by the rate limiter.\n")
+  b.WriteString("# TYPE lightningos_http_rate_limited_total counter\n")
+  fmt.Fprintf(b, "lightningos_http_rate_limited_total %d\n", m.rateLimited.Load())
+  b.WriteString("# HELP lightningos_http_panics_total Handler panics recovered.\n")
+  b.WriteString("# TYPE lightningos_http_panics_total counter\n")
+  fmt.Fprintf(b, "lightningos_http_panics_total %d\n", m.panics.Load())
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+  var b strings.Builder
+  s.metrics.render(&b)
+
+  pollers := s.notifier.pollerStatuses()
+  if len(pollers) > 0 {
+    b.WriteString("# HELP lightningos_notifier_poller_lag_seconds Seconds since a notifier loop last made progress.\n")
+    b.WriteString("# TYPE lightningos_notifier_poller_lag_seconds gauge\n")
+    for _, p := range pollers {
+      fmt.Fprintf(&b, "lightningos_notifier_poller_lag_seconds{poller=\"%s\"} %d\n", promLabel(p.Name), p.LagSeconds)
+    }
+    b.WriteString("# HELP lightningos_notifier_poller_restarts_total Watchdog restarts of a notifier loop.\n")
+    b.WriteString("# TYPE lightningos_notifier_poller_restarts_total counter\n")
+    for _, p := range pollers {
+      fmt.Fprintf(&b, "lightningos_notifier_poller_restarts_total{poller=\"%s\"} %d\n", promLabel(p.Name), p.Restarts)
+    }
+  }
+
+  b.WriteString("# HELP lightningos_goroutines Goroutines in the manager.\n")
+  b.WriteString("# TYPE lightningos_goroutines gauge\n")
+  fmt.Fprintf(&b, "lightningos_goroutines %d\n", runtime.NumGoroutine())
+  b.WriteString("# HELP lightningos_uptime_seconds Seconds since the manager started.\n")
+  b.WriteString("# TYPE lightningos_uptime_seconds gauge\n")
+  fmt.Fprintf(&b, "lightningos_uptime_seconds %d\n", int64(time.Since(s.metrics.started)/time.Second))
+
+  w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+  w.WriteHeader(http.StatusOK)
+  _, _ = w.Write([]byte(b.String()))
+}
@@ -2,11 +2,86 @@ package server
 
 import (
   "bufio"
+  "context"
+  "crypto/rand"
+  "encoding/hex"
+  "math"
   "net"
   "net/http"
+  "strconv"
+  "sync"
   "time"
 )
 
+const (
+  defaultRateLimitRPS = 20
+  defaultRateLimitBurst = 100
+  rateLimitIdleTTL = 10 * time.Minute
+  maxRequestIDLen = 64
+)
+
+// middlewareStack is the chain every request passes through, outermost
+// first. Order matters: metrics sit outside the recoverer so a panic is
+// counted as the 500 it turns into, and compression sits outside
+// withLanguage so localize still sees its languageWriter.
+func (s *Server) middlewareStack() []func(http.Handler) http.Handler {
+  return []func(http.Handler) http.Handler{
+    s.withForwarded(),
+    withRequestID,
+    s.requestLogger(),
+    s.withMetrics(),
+    s.recoverer(),
+    compress,
+    s.withPrivacy(),
+    s.withLanguage(),
+    s.rateLimit(),
+    s.requireAuth(),
+  }
+}
+
+type requestIDKey struct{}
+
+// withRequestID keeps a caller-supplied X-Request-ID (e.g. from a reverse
+// proxy) when it looks sane and generates one otherwise, so a log line can
+// be matched to the response the client saw.
+func withRequestID(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    id := r.Header.Get("X-Request-ID")
+    if !validRequestID(id) {
+      id = newRequestID()
+    }
+    w.Header().Set("X-Request-ID", id)
+    next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+  })
+}
+
+func requestIDFrom(ctx context.Context) string {
+  id, _ := ctx.Value(requestIDKey{}).(string)
+  return id
+}
+
+func validRequestID(id string) bool {
+  if id == "" || len(id) > maxRequestIDLen {
+    return false
+  }
+  for _, c := range id {
+    switch {
+    case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+    default:
+      return false
+    }
+  }
+  return true
+}
+
+func newRequestID() string {
+  buf := make([]byte, 8)
+  if _, err := rand.Read(buf); err != nil {
+    return strconv.FormatInt(time.Now().UnixNano(), 36)
+  }
+  return hex.EncodeToString(buf)
+}
+
 func (s *Server) requestLogger() func(http.Handler) http.Handler {
   return func(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -16,7 +91,82 @@ func (s *Server) requestLogger() func(http.Handler) http.Handler {
       next.ServeHTTP(ww, r)
 
       duration := time.Since(start)
-      s.logger.Printf("method=%s path=%s status=%d duration_ms=%d", r.Method, r.URL.Path, ww.status, duration.Milliseconds())
+      s.logger.Printf("request_id=%s method=%s path=%s route=%s status=%d duration_ms=%d remote=%s", requestIDFrom(r.Context()), r.Method, r.URL.Path, routePattern(r), ww.status, duration.Milliseconds(), clientIP(r))
+    })
+  }
+}
+
+type tokenBucket struct {
+  tokens float64
+  last time.Time
+}
+
+// rateLimiter is a per-client token bucket. Buckets refill at rps up to
+// burst; idle ones are dropped so the map stays small.
+type rateLimiter struct {
+  mu sync.Mutex
+  rps float64
+  burst float64
+  buckets map[string]*tokenBucket
+  lastSweep time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+  if rps == 0 {
+    rps = defaultRateLimitRPS
+  }
+  if burst <= 0 {
+    burst = defaultRateLimitBurst
+  }
+  return &rateLimiter{rps: rps, burst: float64(burst), buckets: map[string]*tokenBucket{}}
+}
+
+// allow takes a token for key. When the bucket is empty it reports how long
+// until the next token.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  if now.Sub(l.lastSweep) > rateLimitIdleTTL {
+    for k, b := range l.buckets {
+      if now.Sub(b.last) > rateLimitIdleTTL {
+        delete(l.buckets, k)
+      }
+    }
+    l.lastSweep = now
+  }
+  b := l.buckets[key]
+  if b == nil {
+    b = &tokenBucket{tokens: l.burst, last: now}
+    l.buckets[key] = b
+  }
+  b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rps)
+  b.last = now
+  if b.tokens >= 1 {
+    b.tokens--
+    return true, 0
+  }
+  wait := time.Duration((1 - b.tokens) / l.rps * float64(time.Second))
+  return false, wait
+}
+
+// rateLimit caps requests per client IP (server.rate_limit in config.yaml).
+// A negative requests_per_second turns it off.
+func (s *Server) rateLimit() func(http.Handler) http.Handler {
+  cfg := s.cfg.Server.RateLimit
+  if cfg.RequestsPerSecond < 0 {
+    return func(next http.Handler) http.Handler { return next }
+  }
+  limiter := newRateLimiter(cfg.RequestsPerSecond, cfg.Burst)
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      ok, wait := limiter.allow(clientIP(r), time.Now())
+      if !ok {
+        s.metrics.rateLimited.Add(1)
+        w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+        writeError(w, http.StatusTooManyRequests, "too many requests, slow down")
+        return
+      }
+      next.ServeHTTP(w, r)
     })
   }
 }
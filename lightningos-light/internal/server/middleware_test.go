@@ -0,0 +1,108 @@
+package server
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestWithRequestID(t *testing.T) {
+  var seen string
+  handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    seen = requestIDFrom(r.Context())
+  }))
+
+  req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+  req.Header.Set("X-Request-ID", "proxy-42")
+  rec := httptest.NewRecorder()
+  handler.ServeHTTP(rec, req)
+  if seen != "proxy-42" || rec.Header().Get("X-Request-ID") != "proxy-42" {
+    t.Fatalf("caller id not kept: ctx=%q header=%q", seen, rec.Header().Get("X-Request-ID"))
+  }
+
+  req = httptest.NewRequest(http.MethodGet, "/api/health", nil)
+  req.Header.Set("X-Request-ID", "bad id\nwith newline")
+  rec = httptest.NewRecorder()
+  handler.ServeHTTP(rec, req)
+  if seen == "" || strings.Contains(seen, " ") || rec.Header().Get("X-Request-ID") != seen {
+    t.Fatalf("expected generated id, got ctx=%q header=%q", seen, rec.Header().Get("X-Request-ID"))
+  }
+}
+
+func TestRateLimiter(t *testing.T) {
+  l := newRateLimiter(2, 3)
+  now := time.Now()
+  for i := 0; i < 3; i++ {
+    if ok, _ := l.allow("10.0.0.1", now); !ok {
+      t.Fatalf("request %d rejected within burst", i)
+    }
+  }
+  ok, wait := l.allow("10.0.0.1", now)
+  if ok || wait <= 0 || wait > time.Second {
+    t.Fatalf("expected rejection with wait, got ok=%v wait=%s", ok, wait)
+  }
+  if ok, _ := l.allow("10.0.0.2", now); !ok {
+    t.Fatal("other client limited")
+  }
+  if ok, _ := l.allow("10.0.0.1", now.Add(600*time.Millisecond)); !ok {
+    t.Fatal("bucket did not refill")
+  }
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+  s, _ := newFakeLNDServer(t)
+  s.cfg.Server.RateLimit.RequestsPerSecond = 1
+  s.cfg.Server.RateLimit.Burst = 1
+  handler := s.routes()
+
+  serve := func() *httptest.ResponseRecorder {
+    rec := httptest.NewRecorder()
+    handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lnops/peers", nil))
+    return rec
+  }
+  if rec := serve(); rec.Code != http.StatusOK {
+    t.Fatalf("first request status = %d", rec.Code)
+  }
+  rec := serve()
+  if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+    t.Fatalf("expected 429 with Retry-After, got %d", rec.Code)
+  }
+  if s.metrics.rateLimited.Load() != 1 {
+    t.Fatalf("rate limited count = %d", s.metrics.rateLimited.Load())
+  }
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  fake.Peers = []lndclient.PeerInfo{{PubKey: "02aa"}}
+  handler := s.routes()
+
+  for i := 0; i < 2; i++ {
+    rec := httptest.NewRecorder()
+    handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/lnops/peers", nil))
+  }
+  rec := httptest.NewRecorder()
+  handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/jobs/abc123", nil))
+
+  rec = httptest.NewRecorder()
+  handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/metrics", nil))
+  if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+    t.Fatalf("status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+  }
+  body := rec.Body.String()
+  for _, want := range []string{
+    `lightningos_http_requests_total{route="/api/lnops/peers",method="GET",code="200"} 2`,
+    `lightningos_http_request_duration_seconds_count{route="/api/lnops/peers",method="GET"} 2`,
+    `lightningos_http_request_duration_seconds_bucket{route="/api/lnops/peers",method="GET",le="+Inf"} 2`,
+    `route="/api/jobs/{id}"`,
+    "# TYPE lightningos_http_request_duration_seconds histogram",
+  } {
+    if !strings.Contains(body, want) {
+      t.Fatalf("metrics missing %q:\n%s", want, body)
+    }
+  }
+}
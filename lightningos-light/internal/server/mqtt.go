@@ -0,0 +1,572 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/mqtt"
+)
+
+const (
+  mqttEnabledKey = "MQTT_ENABLED"
+  mqttBrokerKey = "MQTT_BROKER"
+  mqttUsernameKey = "MQTT_USERNAME"
+  mqttPasswordKey = "MQTT_PASSWORD"
+  mqttTopicPrefixKey = "MQTT_TOPIC_PREFIX"
+  mqttDiscoveryPrefixKey = "MQTT_DISCOVERY_PREFIX"
+  mqttTLSInsecureKey = "MQTT_TLS_INSECURE"
+
+  defaultMQTTTopicPrefix = "lightningos"
+  defaultMQTTDiscoveryPrefix = "homeassistant"
+  mqttStateInterval = time.Minute
+  mqttRetryMin = 10 * time.Second
+  mqttRetryMax = 5 * time.Minute
+)
+
+// mqttEventTypes are the Home Assistant event types a notification maps to.
+// HA needs the full list up front in the discovery config.
+var mqttEventTypes = []string{
+  "payment_received", "payment_sent", "onchain_received", "onchain_sent",
+  "forward", "rebalance", "keysend", "channel_opening", "channel_opened",
+  "channel_closing", "channel_closed", "force_close", "config_drift",
+  "report_anomaly", "alert", "scheduled_payment_failed", "backup_stale", "lnd_db_problem",
+  "new_block", "bitcoin_behind", "other",
+}
+
+type mqttConfig struct {
+  Enabled bool
+  Broker string
+  Username string
+  Password string
+  TopicPrefix string
+  DiscoveryPrefix string
+  TLSInsecure bool
+}
+
+func readMQTTConfig() mqttConfig {
+  read := func(key string) string {
+    value, _ := readEnvFileValue(secretsPath, key)
+    return strings.TrimSpace(value)
+  }
+  cfg := mqttConfig{
+    Enabled: read(mqttEnabledKey) == "1",
+    Broker: read(mqttBrokerKey),
+    Username: read(mqttUsernameKey),
+    Password: read(mqttPasswordKey),
+    TopicPrefix: strings.Trim(read(mqttTopicPrefixKey), "/"),
+    DiscoveryPrefix: strings.Trim(read(mqttDiscoveryPrefixKey), "/"),
+    TLSInsecure: read(mqttTLSInsecureKey) == "1",
+  }
+  if cfg.TopicPrefix == "" {
+    cfg.TopicPrefix = defaultMQTTTopicPrefix
+  }
+  if cfg.DiscoveryPrefix == "" {
+    cfg.DiscoveryPrefix = defaultMQTTDiscoveryPrefix
+  }
+  return cfg
+}
+
+// mqttEventType names a notification for Home Assistant automations, e.g.
+// force_close to blink a light.
+func mqttEventType(evt Notification) string {
+  switch evt.Type {
+  case "lightning":
+    if evt.Direction == "in" {
+      return "payment_received"
+    }
+    return "payment_sent"
+  case "onchain":
+    if evt.Direction == "in" {
+      return "onchain_received"
+    }
+    return "onchain_sent"
+  case "forward", "rebalance", "keysend", "alert":
+    return evt.Type
+  case "channel":
+    switch {
+    case evt.Status == "FORCE_CLOSING":
+      return "force_close"
+    case evt.Action == "opening":
+      return "channel_opening"
+    case evt.Action == "open":
+      return "channel_opened"
+    case evt.Action == "closing", evt.Action == "close_broadcast":
+      return "channel_closing"
+    case evt.Action == "close":
+      return "channel_closed"
+    }
+  case "config":
+    return "config_drift"
+  case "report":
+    return "report_anomaly"
+  case "backup":
+    return "backup_stale"
+  case "lnd_db":
+    return "lnd_db_problem"
+  case "security":
+    return "intrusion_burst"
+  case "bitcoin":
+    if evt.Action == "behind" {
+      return "bitcoin_behind"
+    }
+    return "new_block"
+  case "scheduled_payment":
+    return "scheduled_payment_failed"
+  }
+  return "other"
+}
+
+type mqttState struct {
+  Health string `json:"health"`
+  Issues []string `json:"issues"`
+  OnchainSat int64 `json:"onchain_sat"`
+  LightningSat int64 `json:"lightning_sat"`
+  ChannelsActive int `json:"channels_active"`
+  ChannelsInactive int `json:"channels_inactive"`
+  ChannelsPending int `json:"channels_pending"`
+  BlockHeight int64 `json:"block_height"`
+  SyncedToChain bool `json:"synced_to_chain"`
+  WalletState string `json:"wallet_state"`
+  UpdatedAt time.Time `json:"updated_at"`
+}
+
+type mqttStatus struct {
+  Connected bool `json:"connected"`
+  ConnectedAt *time.Time `json:"connected_at,omitempty"`
+  LastPublish *time.Time `json:"last_publish,omitempty"`
+  LastError string `json:"last_error,omitempty"`
+  Published uint64 `json:"published"`
+}
+
+// mqttBridge keeps one broker session and publishes node state every minute
+// plus each notification as it happens.
+type mqttBridge struct {
+  reload chan struct{}
+
+  mu sync.Mutex
+  connectedAt time.Time
+  lastPublish time.Time
+  lastError string
+  published uint64
+}
+
+func newMQTTBridge() *mqttBridge {
+  return &mqttBridge{reload: make(chan struct{}, 1)}
+}
+
+func (b *mqttBridge) status() mqttStatus {
+  b.mu.Lock()
+  defer b.mu.Unlock()
+  st := mqttStatus{Connected: !b.connectedAt.IsZero(), LastError: b.lastError, Published: b.published}
+  if !b.connectedAt.IsZero() {
+    at := b.connectedAt.UTC()
+    st.ConnectedAt = &at
+  }
+  if !b.lastPublish.IsZero() {
+    at := b.lastPublish.UTC()
+    st.LastPublish = &at
+  }
+  return st
+}
+
+func (b *mqttBridge) setError(err error) {
+  b.mu.Lock()
+  defer b.mu.Unlock()
+  b.connectedAt = time.Time{}
+  if err != nil {
+    b.lastError = err.Error()
+  }
+}
+
+func (b *mqttBridge) markPublished() {
+  b.mu.Lock()
+  b.lastPublish = time.Now()
+  b.published++
+  b.mu.Unlock()
+}
+
+func (b *mqttBridge) triggerReload() {
+  select {
+  case b.reload <- struct{}{}:
+  default:
+  }
+}
+
+// mqttNodeID is the stable id used in topics and HA unique ids.
+func (s *Server) mqttNodeID() string {
+  pubkey := s.lnd.CachedPubkey()
+  if len(pubkey) >= 12 {
+    return "lnos_" + pubkey[:12]
+  }
+  return "lnos_default"
+}
+
+func (s *Server) mqttBaseTopic(cfg mqttConfig) string {
+  return cfg.TopicPrefix + "/" + s.mqttNodeID()
+}
+
+// mqttDiscovery builds the retained Home Assistant discovery configs that
+// turn the node into a device with sensors and an event entity.
+func (s *Server) mqttDiscovery(cfg mqttConfig) []mqtt.Message {
+  nodeID := s.mqttNodeID()
+  base := s.mqttBaseTopic(cfg)
+  device := map[string]any{
+    "identifiers": []string{nodeID},
+    "name": "LightningOS " + strings.TrimPrefix(nodeID, "lnos_"),
+    "manufacturer": "LightningOS",
+    "model": "LightningOS Light",
+  }
+  common := func(name, key string) map[string]any {
+    return map[string]any{
+      "name": name,
+      "unique_id": nodeID + "_" + key,
+      "object_id": nodeID + "_" + key,
+      "availability_topic": base + "/availability",
+      "device": device,
+    }
+  }
+  sensors := []struct {
+    key string
+    name string
+    unit string
+    icon string
+  }{
+    {"onchain_sat", "On-chain balance", "sat", "mdi:bitcoin"},
+    {"lightning_sat", "Lightning balance", "sat", "mdi:lightning-bolt"},
+    {"channels_active", "Active channels", "", "mdi:transit-connection-variant"},
+    {"channels_inactive", "Inactive channels", "", "mdi:lan-disconnect"},
+    {"channels_pending", "Pending channels", "", "mdi:timer-sand"},
+    {"block_height", "Block height", "", "mdi:cube-outline"},
+  }
+
+  out := []mqtt.Message{}
+  add := func(component, key string, payload map[string]any) {
+    data, _ := json.Marshal(payload)
+    out = append(out, mqtt.Message{
+      Topic: fmt.Sprintf("%s/%s/%s/%s/config", cfg.DiscoveryPrefix, component, nodeID, key),
+      Payload: data,
+      QoS: 1,
+      Retain: true,
+    })
+  }
+  for _, sensor := range sensors {
+    payload := common(sensor.name, sensor.key)
+    payload["state_topic"] = base + "/state"
+    payload["value_template"] = "{{ value_json." + sensor.key + " }}"
+    payload["state_class"] = "measurement"
+    payload["icon"] = sensor.icon
+    if sensor.unit != "" {
+      payload["unit_of_measurement"] = sensor.unit
+    }
+    add("sensor", sensor.key, payload)
+  }
+  health := common("Health", "health")
+  health["state_topic"] = base + "/state"
+  health["value_template"] = "{{ value_json.health }}"
+  health["icon"] = "mdi:heart-pulse"
+  add("sensor", "health", health)
+
+  synced := common("Synced to chain", "synced")
+  synced["state_topic"] = base + "/state"
+  synced["value_template"] = "{{ 'ON' if value_json.synced_to_chain else 'OFF' }}"
+  add("binary_sensor", "synced", synced)
+
+  events := common("Node events", "events")
+  events["state_topic"] = base + "/event"
+  events["event_types"] = mqttEventTypes
+  add("event", "events", events)
+  return out
+}
+
+func (s *Server) mqttCollectState() mqttState {
+  ctxFor := func(class timeoutClass) (context.Context, context.CancelFunc) {
+    return s.taskContext("mqtt/bridge", class)
+  }
+  health := s.healthReport(ctxFor)
+  state := mqttState{Health: health.Status, Issues: []string{}, UpdatedAt: time.Now().UTC()}
+  for _, issue := range health.Issues {
+    state.Issues = append(state.Issues, issue.Message)
+  }
+  ctx, cancel := ctxFor(timeoutLNDRPC)
+  defer cancel()
+  if status, err := s.lnd.GetStatus(ctx); err == nil {
+    state.OnchainSat = status.OnchainSat
+    state.LightningSat = status.LightningSat
+    state.ChannelsActive = status.ChannelsActive
+    state.ChannelsInactive = status.ChannelsInactive
+    state.BlockHeight = status.BlockHeight
+    state.SyncedToChain = status.SyncedToChain
+    state.WalletState = status.WalletState
+  }
+  if pending, err := s.lnd.ListPendingChannels(ctx); err == nil {
+    state.ChannelsPending = len(pending)
+  }
+  return state
+}
+
+func (s *Server) mqttPublishJSON(client *mqtt.Client, topic string, value any, retain bool) error {
+  data, err := json.Marshal(value)
+  if err != nil {
+    return err
+  }
+  ctx, cancel := s.taskContext("mqtt/bridge", timeoutShort)
+  defer cancel()
+  if err := client.Publish(ctx, mqtt.Message{Topic: topic, Payload: data, QoS: 1, Retain: retain}); err != nil {
+    return err
+  }
+  s.mqtt.markPublished()
+  return nil
+}
+
+func (s *Server) mqttConnect(cfg mqttConfig) (*mqtt.Client, error) {
+  base := s.mqttBaseTopic(cfg)
+  ctx, cancel := s.taskContext("mqtt/bridge", timeoutShort)
+  defer cancel()
+  client, err := mqtt.Dial(ctx, mqtt.Options{
+    Broker: cfg.Broker,
+    ClientID: s.mqttNodeID(),
+    Username: cfg.Username,
+    Password: cfg.Password,
+    InsecureSkipVerify: cfg.TLSInsecure,
+    Will: &mqtt.Message{Topic: base + "/availability", Payload: []byte("offline"), QoS: 1, Retain: true},
+  })
+  if err != nil {
+    return nil, err
+  }
+  if err := client.Publish(ctx, mqtt.Message{Topic: base + "/availability", Payload: []byte("online"), QoS: 1, Retain: true}); err != nil {
+    client.Close()
+    return nil, err
+  }
+  for _, msg := range s.mqttDiscovery(cfg) {
+    if err := client.Publish(ctx, msg); err != nil {
+      client.Close()
+      return nil, err
+    }
+  }
+  return client, nil
+}
+
+// runMQTT is the bridge loop. Config changes from the API take effect
+// through reload; a lost broker is retried with backoff.
+func (s *Server) runMQTT() {
+  var client *mqtt.Client
+  var active mqttConfig
+  var sub *notificationSubscriber
+  retry := mqttRetryMin
+  disconnect := func() {
+    if client != nil {
+      client.Close()
+      client = nil
+    }
+    if sub != nil {
+      s.notifier.unsubscribe(sub)
+      sub = nil
+    }
+    s.mqtt.setError(nil)
+  }
+  defer disconnect()
+
+  ticker := time.NewTicker(mqttStateInterval)
+  defer ticker.Stop()
+  for {
+    cfg := readMQTTConfig()
+    if client != nil && cfg != active {
+      disconnect()
+    }
+    if cfg.Enabled && cfg.Broker != "" && client == nil {
+      c, err := s.mqttConnect(cfg)
+      if err != nil {
+        s.logger.Printf("mqtt: connect to %s failed: %v", cfg.Broker, err)
+        s.mqtt.setError(err)
+        select {
+        case <-time.After(retry):
+        case <-s.mqtt.reload:
+        case <-s.root.Done():
+          return
+        }
+        retry *= 2
+        if retry > mqttRetryMax {
+          retry = mqttRetryMax
+        }
+        continue
+      }
+      client, active, retry = c, cfg, mqttRetryMin
+      s.mqtt.mu.Lock()
+      s.mqtt.connectedAt = time.Now()
+      s.mqtt.lastError = ""
+      s.mqtt.mu.Unlock()
+      if s.notifier != nil {
+        sub = s.notifier.subscribe("mqtt", "local", subscriberFilter{})
+      }
+      s.logger.Printf("mqtt: connected to %s", cfg.Broker)
+      s.mqttPublishState(client, cfg)
+    }
+
+    var wake, dropped <-chan struct{}
+    var lost <-chan struct{}
+    if sub != nil {
+      wake, dropped = sub.wake, sub.done
+    }
+    if client != nil {
+      lost = client.Done()
+    }
+    select {
+    case <-s.root.Done():
+      return
+    case <-s.mqtt.reload:
+    case <-ticker.C:
+      if client != nil {
+        s.mqttPublishState(client, active)
+      }
+    case <-wake:
+      for _, evt := range sub.take() {
+        payload := map[string]any{"event_type": mqttEventType(evt), "notification": evt}
+        if err := s.mqttPublishJSON(client, s.mqttBaseTopic(active)+"/event", payload, false); err != nil {
+          s.logger.Printf("mqtt: publish event failed: %v", err)
+          break
+        }
+      }
+    case <-dropped:
+      sub = s.notifier.subscribe("mqtt", "local", subscriberFilter{})
+    case <-lost:
+      err := client.Err()
+      s.logger.Printf("mqtt: connection lost: %v", err)
+      client = nil
+      if sub != nil {
+        s.notifier.unsubscribe(sub)
+        sub = nil
+      }
+      s.mqtt.setError(err)
+    }
+  }
+}
+
+func (s *Server) mqttPublishState(client *mqtt.Client, cfg mqttConfig) {
+  if err := s.mqttPublishJSON(client, s.mqttBaseTopic(cfg)+"/state", s.mqttCollectState(), true); err != nil {
+    s.logger.Printf("mqtt: publish state failed: %v", err)
+  }
+}
+
+func (s *Server) handleMQTTGet(w http.ResponseWriter, r *http.Request) {
+  cfg := readMQTTConfig()
+  writeJSON(w, http.StatusOK, map[string]any{
+    "enabled": cfg.Enabled,
+    "broker": cfg.Broker,
+    "username": cfg.Username,
+    "password_set": cfg.Password != "",
+    "topic_prefix": cfg.TopicPrefix,
+    "discovery_prefix": cfg.DiscoveryPrefix,
+    "tls_insecure": cfg.TLSInsecure,
+    "base_topic": s.mqttBaseTopic(cfg),
+    "status": s.mqtt.status(),
+  })
+}
+
+func (s *Server) handleMQTTPost(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Enabled *bool `json:"enabled"`
+    Broker *string `json:"broker"`
+    Username *string `json:"username"`
+    Password *string `json:"password"`
+    TopicPrefix *string `json:"topic_prefix"`
+    DiscoveryPrefix *string `json:"discovery_prefix"`
+    TLSInsecure *bool `json:"tls_insecure"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  cfg := readMQTTConfig()
+  if req.Broker != nil {
+    cfg.Broker = strings.TrimSpace(*req.Broker)
+  }
+  if cfg.Broker != "" {
+    if err := mqtt.ValidateBroker(cfg.Broker); err != nil {
+      writeError(w, http.StatusBadRequest, "invalid broker url")
+      return
+    }
+  }
+  enabled := cfg.Enabled
+  if req.Enabled != nil {
+    enabled = *req.Enabled
+  }
+  if enabled && cfg.Broker == "" {
+    writeError(w, http.StatusBadRequest, "broker required")
+    return
+  }
+  for _, prefix := range []*string{req.TopicPrefix, req.DiscoveryPrefix} {
+    if prefix != nil && strings.ContainsAny(*prefix, "#+ ") {
+      writeError(w, http.StatusBadRequest, "invalid topic prefix")
+      return
+    }
+  }
+
+  if err := ensureSecretsDir(); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store mqtt config: %v", err))
+    return
+  }
+  values := map[string]*string{
+    mqttBrokerKey: &cfg.Broker,
+    mqttUsernameKey: req.Username,
+    mqttPasswordKey: req.Password,
+    mqttTopicPrefixKey: req.TopicPrefix,
+    mqttDiscoveryPrefixKey: req.DiscoveryPrefix,
+  }
+  flag := func(v bool) *string {
+    out := "0"
+    if v {
+      out = "1"
+    }
+    return &out
+  }
+  values[mqttEnabledKey] = flag(enabled)
+  if req.TLSInsecure != nil {
+    values[mqttTLSInsecureKey] = flag(*req.TLSInsecure)
+  }
+  for key, value := range values {
+    if value == nil {
+      continue
+    }
+    if err := writeEnvFileValue(secretsPath, key, strings.TrimSpace(*value)); err != nil {
+      writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store mqtt config: %v", err))
+      return
+    }
+  }
+  s.mqtt.triggerReload()
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleMQTTTest connects with the stored settings and publishes one
+// non-retained message, so broker credentials can be checked from the UI.
+func (s *Server) handleMQTTTest(w http.ResponseWriter, r *http.Request) {
+  cfg := readMQTTConfig()
+  if cfg.Broker == "" {
+    writeError(w, http.StatusBadRequest, "broker required")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  client, err := mqtt.Dial(ctx, mqtt.Options{
+    Broker: cfg.Broker,
+    ClientID: s.mqttNodeID() + "_test",
+    Username: cfg.Username,
+    Password: cfg.Password,
+    InsecureSkipVerify: cfg.TLSInsecure,
+  })
+  if err != nil {
+    writeError(w, http.StatusBadGateway, fmt.Sprintf("mqtt test failed: %v", err))
+    return
+  }
+  defer client.Close()
+  payload := fmt.Sprintf(`{"test":true,"at":%q}`, time.Now().UTC().Format(time.RFC3339))
+  if err := client.Publish(ctx, mqtt.Message{Topic: s.mqttBaseTopic(cfg) + "/test", Payload: []byte(payload), QoS: 1}); err != nil {
+    writeError(w, http.StatusBadGateway, fmt.Sprintf("mqtt test failed: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"ok": true, "topic": s.mqttBaseTopic(cfg) + "/test"})
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+  "encoding/json"
+  "strings"
+  "testing"
+)
+
+func TestMQTTEventType(t *testing.T) {
+  cases := []struct {
+    evt Notification
+    want string
+  }{
+    {Notification{Type: "lightning", Direction: "in"}, "payment_received"},
+    {Notification{Type: "lightning", Direction: "out"}, "payment_sent"},
+    {Notification{Type: "onchain", Direction: "in"}, "onchain_received"},
+    {Notification{Type: "channel", Action: "closing", Status: "FORCE_CLOSING"}, "force_close"},
+    {Notification{Type: "channel", Action: "open"}, "channel_opened"},
+    {Notification{Type: "config"}, "config_drift"},
+    {Notification{Type: "unknown"}, "other"},
+  }
+  for _, tc := range cases {
+    got := mqttEventType(tc.evt)
+    if got != tc.want {
+      t.Errorf("%+v: got %q, want %q", tc.evt, got, tc.want)
+    }
+    found := false
+    for _, known := range mqttEventTypes {
+      found = found || known == got
+    }
+    if !found {
+      t.Errorf("%q missing from mqttEventTypes", got)
+    }
+  }
+}
+
+func TestMQTTDiscovery(t *testing.T) {
+  s, _ := newFakeLNDServer(t)
+  cfg := mqttConfig{TopicPrefix: "lightningos", DiscoveryPrefix: "homeassistant"}
+  base := s.mqttBaseTopic(cfg)
+  msgs := s.mqttDiscovery(cfg)
+  if len(msgs) == 0 {
+    t.Fatal("no discovery configs")
+  }
+  var sawEvent bool
+  for _, msg := range msgs {
+    if !msg.Retain || !strings.HasPrefix(msg.Topic, "homeassistant/") || !strings.HasSuffix(msg.Topic, "/config") {
+      t.Fatalf("unexpected discovery message %q retain=%v", msg.Topic, msg.Retain)
+    }
+    var payload map[string]any
+    if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+      t.Fatalf("%s: %v", msg.Topic, err)
+    }
+    if payload["availability_topic"] != base+"/availability" {
+      t.Fatalf("%s: availability_topic %v", msg.Topic, payload["availability_topic"])
+    }
+    if strings.HasPrefix(msg.Topic, "homeassistant/event/") {
+      sawEvent = payload["state_topic"] == base+"/event"
+    }
+  }
+  if !sawEvent {
+    t.Fatal("event entity missing")
+  }
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+  "fmt"
+  "net/http"
+  "sync"
+)
+
+type chainNetwork struct {
+  Name string `json:"network"`
+  ChainDir string `json:"-"`
+  RPCPort int `json:"rpc_port"`
+  MempoolURL string `json:"mempool_url,omitempty"`
+  ExplorerTxURL string `json:"explorer_tx_url,omitempty"`
+  ExplorerAddressURL string `json:"explorer_address_url,omitempty"`
+  ExplorerNodeURL string `json:"explorer_node_url,omitempty"`
+  Mainnet bool `json:"mainnet"`
+}
+
+var chainNetworks = map[string]chainNetwork{
+  "mainnet": newChainNetwork("mainnet", 8332, "https://mempool.space"),
+  "testnet": newChainNetwork("testnet", 18332, "https://mempool.space/testnet"),
+  "signet": newChainNetwork("signet", 38332, "https://mempool.space/signet"),
+  "regtest": newChainNetwork("regtest", 18443, ""),
+}
+
+var (
+  activeNetworkMu sync.RWMutex
+  activeNetworkName = "mainnet"
+)
+
+func newChainNetwork(name string, rpcPort int, mempoolURL string) chainNetwork {
+  network := chainNetwork{
+    Name: name,
+    ChainDir: name,
+    RPCPort: rpcPort,
+    MempoolURL: mempoolURL,
+    Mainnet: name == "mainnet",
+  }
+  if mempoolURL != "" {
+    network.ExplorerTxURL = mempoolURL + "/tx/{txid}"
+    network.ExplorerAddressURL = mempoolURL + "/address/{address}"
+    network.ExplorerNodeURL = mempoolURL + "/lightning/node/{pubkey}"
+  }
+  return network
+}
+
+func setActiveNetwork(name string) {
+  if _, ok := chainNetworks[name]; !ok {
+    return
+  }
+  activeNetworkMu.Lock()
+  activeNetworkName = name
+  activeNetworkMu.Unlock()
+}
+
+func activeNetwork() chainNetwork {
+  activeNetworkMu.RLock()
+  defer activeNetworkMu.RUnlock()
+  return chainNetworks[activeNetworkName]
+}
+
+func lndChainPath(file string) string {
+  return fmt.Sprintf("/data/lnd/data/chain/bitcoin/%s/%s", activeNetwork().ChainDir, file)
+}
+
+func lndChannelDBPath() string {
+  return fmt.Sprintf("/data/lnd/data/graph/%s/channel.db", activeNetwork().ChainDir)
+}
+
+func lndWalletDBPath() string {
+  return lndChainPath("wallet.db")
+}
+
+func lndAdminMacaroonPath() string {
+  return lndChainPath("admin.macaroon")
+}
+
+func localBitcoinRPCHost() string {
+  return fmt.Sprintf("127.0.0.1:%d", activeNetwork().RPCPort)
+}
+
+func (s *Server) handleNetwork(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, activeNetwork())
+}
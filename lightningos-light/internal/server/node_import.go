@@ -0,0 +1,310 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "log"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/system"
+)
+
+const nodeImportBackupRoot = "/var/lib/lightningos/imports"
+
+// nodeImportLayout describes where another distro keeps its data, relative to
+// the root of its filesystem (or of its data disk when mounted elsewhere).
+type nodeImportLayout struct {
+  distro string
+  lndDir string
+  bitcoinDir string
+  marker string
+}
+
+var nodeImportLayouts = []nodeImportLayout{
+  {distro: "umbrel", lndDir: "home/umbrel/umbrel/app-data/lightning/data/lnd", bitcoinDir: "home/umbrel/umbrel/app-data/bitcoin/data/bitcoin"},
+  {distro: "umbrel", lndDir: "umbrel/app-data/lightning/data/lnd", bitcoinDir: "umbrel/app-data/bitcoin/data/bitcoin"},
+  {distro: "umbrel", lndDir: "app-data/lightning/data/lnd", bitcoinDir: "app-data/bitcoin/data/bitcoin"},
+  {distro: "umbrel", lndDir: "home/umbrel/umbrel/lnd", bitcoinDir: "home/umbrel/umbrel/bitcoin"},
+  {distro: "raspiblitz", lndDir: "mnt/hdd/lnd", bitcoinDir: "mnt/hdd/bitcoin", marker: "mnt/hdd/raspiblitz.conf"},
+  {distro: "raspiblitz", lndDir: "lnd", bitcoinDir: "bitcoin", marker: "raspiblitz.conf"},
+  {distro: "mynode", lndDir: "mnt/hdd/mynode/lnd", bitcoinDir: "mnt/hdd/mynode/bitcoin"},
+  {distro: "mynode", lndDir: "mynode/lnd", bitcoinDir: "mynode/bitcoin"},
+}
+
+// Options carried over from the old lnd.conf; everything else (bitcoind
+// connection, tor, paths) comes from this box.
+var nodeImportCarryOptions = map[string]string{
+  "alias": "Application Options",
+  "color": "Application Options",
+  "minchansize": "Application Options",
+  "maxchansize": "Application Options",
+  "bitcoin.basefee": "Bitcoin",
+  "bitcoin.feerate": "Bitcoin",
+  "bitcoin.timelockdelta": "Bitcoin",
+}
+
+type NodeImportSource struct {
+  Distro string `json:"distro"`
+  Root string `json:"root"`
+  LNDDir string `json:"lnd_dir"`
+  BitcoinDir string `json:"bitcoin_dir,omitempty"`
+  Networks []string `json:"networks"`
+  HasSCB bool `json:"has_scb"`
+  ChannelDBBytes int64 `json:"channel_db_bytes"`
+}
+
+type NodeImportOptions struct {
+  Root string
+  Distro string
+  WithBitcoin bool
+  DryRun bool
+}
+
+func dirExists(path string) bool {
+  info, err := os.Stat(path)
+  return err == nil && info.IsDir()
+}
+
+// DetectNodeImports finds LND installations of other distros under root.
+func DetectNodeImports(root string) []NodeImportSource {
+  found := []NodeImportSource{}
+  seen := map[string]bool{}
+  for _, layout := range nodeImportLayouts {
+    lndDir := filepath.Join(root, layout.lndDir)
+    if seen[lndDir] {
+      continue
+    }
+    if layout.marker != "" && !fileExists(filepath.Join(root, layout.marker)) {
+      continue
+    }
+    networks := []string{}
+    for name := range chainNetworks {
+      if fileExists(filepath.Join(lndDir, "data", "chain", "bitcoin", name, "wallet.db")) {
+        networks = append(networks, name)
+      }
+    }
+    if len(networks) == 0 {
+      continue
+    }
+    sort.Strings(networks)
+    seen[lndDir] = true
+    source := NodeImportSource{
+      Distro: layout.distro,
+      Root: root,
+      LNDDir: lndDir,
+      Networks: networks,
+    }
+    if dirExists(filepath.Join(root, layout.bitcoinDir, "blocks")) {
+      source.BitcoinDir = filepath.Join(root, layout.bitcoinDir)
+    }
+    network := activeNetwork().ChainDir
+    source.HasSCB = fileExists(filepath.Join(lndDir, "data", "chain", "bitcoin", network, "channel.backup"))
+    if info, err := os.Stat(filepath.Join(lndDir, "data", "graph", network, "channel.db")); err == nil {
+      source.ChannelDBBytes = info.Size()
+    }
+    found = append(found, source)
+  }
+  return found
+}
+
+// nodeImportRoots are the places an old node's disk usually shows up.
+func nodeImportRoots() []string {
+  roots := []string{"/"}
+  for _, pattern := range []string{"/mnt/*", "/media/*", "/media/*/*"} {
+    matches, _ := filepath.Glob(pattern)
+    for _, match := range matches {
+      if dirExists(match) {
+        roots = append(roots, match)
+      }
+    }
+  }
+  return roots
+}
+
+// legacyLNDOptions picks the carried-over options from an old lnd.conf.
+func legacyLNDOptions(raw string) map[string]string {
+  options := map[string]string{}
+  for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+    parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+    if len(parts) != 2 {
+      continue
+    }
+    key := strings.TrimSpace(parts[0])
+    value := strings.TrimSpace(parts[1])
+    if _, ok := nodeImportCarryOptions[key]; ok && value != "" {
+      options[key] = value
+    }
+  }
+  return options
+}
+
+// mergeImportedLNDConf applies the carried-over options and switches the
+// backend to bolt, which is what the imported channel.db uses.
+func mergeImportedLNDConf(current string, legacy map[string]string) string {
+  bySection := map[string]map[string]string{}
+  for key, value := range legacy {
+    section := nodeImportCarryOptions[key]
+    if bySection[section] == nil {
+      bySection[section] = map[string]string{}
+    }
+    bySection[section][key] = value
+  }
+  keys := []string{"db.backend", "db.use-native-sql"}
+  for key := range legacy {
+    keys = append(keys, key)
+  }
+  merged := removeLNDConfOptions(current, keys...)
+  for _, section := range []string{"Application Options", "Bitcoin"} {
+    if values := bySection[section]; len(values) > 0 {
+      merged = setLNDConfSectionOptions(merged, section, values)
+    }
+  }
+  return setLNDConfSectionOptions(merged, "db", map[string]string{"db.backend": "bolt"})
+}
+
+// RunNodeImport copies an LND node from another distro into /data/lnd,
+// rewrites lnd.conf and starts LND. It must run as root with the old node
+// shut down for good: running both would broadcast revoked states.
+func RunNodeImport(ctx context.Context, cfg *config.Config, opts NodeImportOptions, logger *log.Logger) error {
+  setActiveNetwork(cfg.Network)
+  candidates := []NodeImportSource{}
+  for _, source := range DetectNodeImports(opts.Root) {
+    if opts.Distro == "" || source.Distro == opts.Distro {
+      candidates = append(candidates, source)
+    }
+  }
+  if len(candidates) == 0 {
+    return fmt.Errorf("no umbrel, raspiblitz or mynode LND data found under %s", opts.Root)
+  }
+  if len(candidates) > 1 {
+    dirs := []string{}
+    for _, c := range candidates {
+      dirs = append(dirs, c.Distro+": "+c.LNDDir)
+    }
+    return fmt.Errorf("several installations found, pick one with --distro: %s", strings.Join(dirs, ", "))
+  }
+  source := candidates[0]
+  network := activeNetwork().ChainDir
+  networkFound := false
+  for _, name := range source.Networks {
+    if name == network {
+      networkFound = true
+    }
+  }
+  if !networkFound {
+    return fmt.Errorf("%s has no %s wallet (found %s)", source.LNDDir, network, strings.Join(source.Networks, ", "))
+  }
+  if fileExists(lndWalletDBPath()) {
+    return fmt.Errorf("a wallet already exists at %s; move /data/lnd/data away first", lndWalletDBPath())
+  }
+
+  logger.Printf("import: %s node at %s (%s, channel.db %d bytes, SCB %t)", source.Distro, source.LNDDir, network, source.ChannelDBBytes, source.HasSCB)
+  if opts.WithBitcoin {
+    if source.BitcoinDir == "" {
+      logger.Printf("import: no bitcoin block data found, skipping bitcoin")
+    } else {
+      logger.Printf("import: bitcoin data from %s to %s", source.BitcoinDir, bitcoinCoreAppPaths().DataDir)
+    }
+  }
+  if opts.DryRun {
+    logger.Printf("import: dry run, nothing changed")
+    return nil
+  }
+
+  stamp := time.Now().UTC().Format("20060102-150405")
+  backupDir := filepath.Join(nodeImportBackupRoot, stamp)
+  if err := os.MkdirAll(backupDir, 0o700); err != nil {
+    return err
+  }
+  if source.HasSCB {
+    scb := filepath.Join(source.LNDDir, "data", "chain", "bitcoin", network, "channel.backup")
+    if _, err := system.RunCommand(ctx, "cp", "-a", scb, backupDir); err != nil {
+      return fmt.Errorf("failed to save channel.backup: %w", err)
+    }
+    logger.Printf("import: channel.backup saved to %s", backupDir)
+  }
+
+  logger.Printf("import: stopping lnd")
+  if err := system.SystemctlStop(ctx, "lnd"); err != nil {
+    return err
+  }
+
+  logger.Printf("import: copying LND data")
+  if err := os.MkdirAll("/data/lnd/data", 0o750); err != nil {
+    return err
+  }
+  if out, err := system.RunCommand(ctx, "cp", "-a", filepath.Join(source.LNDDir, "data")+"/.", "/data/lnd/data/"); err != nil {
+    return fmt.Errorf("copy failed: %s", strings.TrimSpace(out))
+  }
+
+  if opts.WithBitcoin && source.BitcoinDir != "" {
+    dataDir := bitcoinCoreAppPaths().DataDir
+    if err := os.MkdirAll(dataDir, 0o750); err != nil {
+      return err
+    }
+    for _, dir := range []string{"blocks", "chainstate", "indexes"} {
+      src := filepath.Join(source.BitcoinDir, dir)
+      if !dirExists(src) {
+        continue
+      }
+      logger.Printf("import: copying bitcoin %s", dir)
+      if out, err := system.RunCommand(ctx, "cp", "-a", src, dataDir+"/"); err != nil {
+        return fmt.Errorf("bitcoin copy failed: %s", strings.TrimSpace(out))
+      }
+    }
+  }
+
+  legacy := map[string]string{}
+  if raw, err := os.ReadFile(filepath.Join(source.LNDDir, "lnd.conf")); err == nil {
+    legacy = legacyLNDOptions(string(raw))
+  }
+  unlock := lockConfigFile(lndConfPath)
+  current, err := os.ReadFile(lndConfPath)
+  if err == nil {
+    _ = os.WriteFile(filepath.Join(backupDir, "lnd.conf.before-import"), current, 0o600)
+    err = writeFileAtomic(lndConfPath, []byte(mergeImportedLNDConf(string(current), legacy)), 0660)
+  }
+  unlock()
+  if err != nil {
+    return fmt.Errorf("failed to update lnd.conf: %w", err)
+  }
+  logger.Printf("import: lnd.conf switched to bolt with %d carried options", len(legacy))
+
+  if out, err := system.RunCommand(ctx, lndFixPermsScript); err != nil {
+    logger.Printf("import: permissions fix failed: %s", strings.TrimSpace(out))
+  }
+
+  logger.Printf("import: starting lnd")
+  lnd := lndclient.New(cfg, logger)
+  if err := system.SystemctlStart(ctx, "lnd"); err != nil {
+    return err
+  }
+  deadline := time.Now().Add(3 * time.Minute)
+  for time.Now().Before(deadline) {
+    time.Sleep(5 * time.Second)
+    pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    up := lnd.Reachable(pingCtx)
+    cancel()
+    if up {
+      logger.Printf("import: lnd is up; unlock it with the wallet password from %s", source.Distro)
+      return nil
+    }
+  }
+  return errors.New("lnd did not answer after the import; check journalctl -u lnd")
+}
+
+func (s *Server) handleNodeImportDetect(w http.ResponseWriter, r *http.Request) {
+  found := []NodeImportSource{}
+  for _, root := range nodeImportRoots() {
+    found = append(found, DetectNodeImports(root)...)
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": found})
+}
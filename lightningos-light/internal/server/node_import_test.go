@@ -0,0 +1,67 @@
+package server
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func touchFile(t *testing.T, path string) {
+  t.Helper()
+  if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+    t.Fatal(err)
+  }
+  if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestDetectNodeImports(t *testing.T) {
+  root := t.TempDir()
+  umbrelLND := filepath.Join(root, "umbrel/app-data/lightning/data/lnd")
+  touchFile(t, filepath.Join(umbrelLND, "data/chain/bitcoin/mainnet/wallet.db"))
+  touchFile(t, filepath.Join(umbrelLND, "data/chain/bitcoin/mainnet/channel.backup"))
+  if err := os.MkdirAll(filepath.Join(root, "umbrel/app-data/bitcoin/data/bitcoin/blocks"), 0o755); err != nil {
+    t.Fatal(err)
+  }
+  // A blitz layout without raspiblitz.conf must not match.
+  touchFile(t, filepath.Join(root, "lnd/data/chain/bitcoin/mainnet/wallet.db"))
+
+  found := DetectNodeImports(root)
+  if len(found) != 1 {
+    t.Fatalf("expected one installation, got %+v", found)
+  }
+  got := found[0]
+  if got.Distro != "umbrel" || got.LNDDir != umbrelLND || !got.HasSCB || got.BitcoinDir == "" {
+    t.Fatalf("unexpected detection: %+v", got)
+  }
+  if len(got.Networks) != 1 || got.Networks[0] != "mainnet" {
+    t.Fatalf("unexpected networks: %v", got.Networks)
+  }
+
+  touchFile(t, filepath.Join(root, "raspiblitz.conf"))
+  if found := DetectNodeImports(root); len(found) != 2 {
+    t.Fatalf("expected raspiblitz disk to be detected, got %+v", found)
+  }
+}
+
+func TestMergeImportedLNDConf(t *testing.T) {
+  legacy := legacyLNDOptions("[Application Options]\nalias=old node\nlisten=0.0.0.0:9735\n\n[Bitcoin]\nbitcoin.basefee=0\nbitcoin.feerate=50\n")
+  if len(legacy) != 3 || legacy["alias"] != "old node" {
+    t.Fatalf("unexpected legacy options: %v", legacy)
+  }
+
+  current := "[Application Options]\nalias=brln\n\n[Bitcoin]\nbitcoin.mainnet=true\n\n[db]\ndb.backend=postgres\ndb.use-native-sql=true\n\n[postgres]\ndb.postgres.dsn=postgres://x\n"
+  got := mergeImportedLNDConf(current, legacy)
+  for _, want := range []string{"alias=old node", "bitcoin.basefee=0", "bitcoin.feerate=50", "db.backend=bolt"} {
+    if !strings.Contains(got, want) {
+      t.Fatalf("expected %q in:\n%s", want, got)
+    }
+  }
+  for _, unwanted := range []string{"alias=brln", "db.backend=postgres", "db.use-native-sql", "listen="} {
+    if strings.Contains(got, unwanted) {
+      t.Fatalf("did not expect %q in:\n%s", unwanted, got)
+    }
+  }
+}
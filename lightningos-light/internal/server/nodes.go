@@ -0,0 +1,231 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+  "strings"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+  "lightningos-light/internal/reports"
+
+  "github.com/go-chi/chi/v5"
+)
+
+type nodeContextKey struct{}
+
+type lightningNode struct {
+  ID string
+  Name string
+  lnd lndclient.API
+  notifier *Notifier
+  reports *reports.Service
+}
+
+type nodeInfo struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  GRPCHost string `json:"grpc_host"`
+  Primary bool `json:"primary"`
+  Notifications bool `json:"notifications"`
+}
+
+func (s *Server) initNodes() {
+  s.nodes = map[string]*lightningNode{}
+  s.nodeIDs = nil
+  s.addNode(&lightningNode{ID: config.DefaultNodeID, Name: "Primary", lnd: s.lnd})
+  for _, nodeCfg := range s.cfg.Nodes {
+    name := strings.TrimSpace(nodeCfg.Name)
+    if name == "" {
+      name = nodeCfg.ID
+    }
+    s.addNode(&lightningNode{
+      ID: nodeCfg.ID,
+      Name: name,
+      lnd: lndclient.New(s.cfg.ForNode(nodeCfg), s.logger),
+    })
+  }
+}
+
+func (s *Server) addNode(node *lightningNode) {
+  s.nodes[node.ID] = node
+  s.nodeIDs = append(s.nodeIDs, node.ID)
+}
+
+// withNode resolves the {nodeID} route parameter and scopes the request to it.
+func (s *Server) withNode(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    id := strings.TrimSpace(chi.URLParam(r, "nodeID"))
+    node, ok := s.nodes[id]
+    if !ok {
+      writeError(w, http.StatusNotFound, "unknown node")
+      return
+    }
+    ctx := context.WithValue(r.Context(), nodeContextKey{}, node)
+    next.ServeHTTP(w, r.WithContext(ctx))
+  })
+}
+
+func (s *Server) nodeFor(r *http.Request) *lightningNode {
+  if node, ok := r.Context().Value(nodeContextKey{}).(*lightningNode); ok && node != nil {
+    return node
+  }
+  return s.nodes[config.DefaultNodeID]
+}
+
+func (s *Server) lndFor(r *http.Request) lndclient.API {
+  if node := s.nodeFor(r); node != nil && node.lnd != nil {
+    return node.lnd
+  }
+  return s.lnd
+}
+
+// lndForNode is lndFor for work that outlives the request, like jobs. ok is
+// false for a node no longer in the config: the work has to fail rather than
+// spend the primary node's funds. An empty id (stored before nodes) is the
+// primary node.
+func (s *Server) lndForNode(id string) (lndclient.API, bool) {
+  if id == "" {
+    id = config.DefaultNodeID
+  }
+  if node := s.nodes[id]; node != nil && node.lnd != nil {
+    return node.lnd, true
+  }
+  return nil, false
+}
+
+func (s *Server) notifierFor(r *http.Request) *Notifier {
+  if node := s.nodeFor(r); node != nil && node.ID != config.DefaultNodeID {
+    return node.notifier
+  }
+  return s.notifier
+}
+
+// notifierForNode is notifierFor for work that outlives the request; ok is
+// false like lndForNode's. The notifier is nil when notifications are off.
+func (s *Server) notifierForNode(id string) (*Notifier, bool) {
+  if id == "" || id == config.DefaultNodeID {
+    return s.notifier, true
+  }
+  if node := s.nodes[id]; node != nil {
+    return node.notifier, true
+  }
+  return nil, false
+}
+
+func unknownNodeError(id string) error {
+  return fmt.Errorf("unknown node: %s", id)
+}
+
+// reportsFor returns the reports service for the request's node. Stored daily
+// reports are only produced for the primary node; other nodes get live metrics.
+func (s *Server) reportsFor(r *http.Request) (*reports.Service, string) {
+  node := s.nodeFor(r)
+  if node == nil || node.ID == config.DefaultNodeID {
+    return s.reportsService()
+  }
+  if node.reports == nil {
+    return nil, "reports unavailable for this node"
+  }
+  return node.reports, ""
+}
+
+// nodeNotifiers returns the notifiers of secondary nodes.
+func (s *Server) nodeNotifiers() []*Notifier {
+  var items []*Notifier
+  for _, id := range s.nodeIDs {
+    if node := s.nodes[id]; id != config.DefaultNodeID && node.notifier != nil {
+      items = append(items, node.notifier)
+    }
+  }
+  return items
+}
+
+func (s *Server) handleNodesList(w http.ResponseWriter, r *http.Request) {
+  items := make([]nodeInfo, 0, len(s.nodeIDs))
+  for _, id := range s.nodeIDs {
+    node := s.nodes[id]
+    grpcHost := s.cfg.LND.GRPCHost
+    for _, nodeCfg := range s.cfg.Nodes {
+      if nodeCfg.ID == id {
+        grpcHost = nodeCfg.LND.GRPCHost
+      }
+    }
+    notifier := node.notifier
+    if id == config.DefaultNodeID {
+      notifier = s.notifier
+    }
+    items = append(items, nodeInfo{
+      ID: id,
+      Name: node.Name,
+      GRPCHost: grpcHost,
+      Primary: id == config.DefaultNodeID,
+      Notifications: notifier != nil,
+    })
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// nodeRoutes registers the endpoints that can be scoped to a single node.
+func (s *Server) nodeRoutes(r chi.Router) {
+  r.Get("/lnd/status", s.handleLNDStatus)
+  r.Get("/notifications", s.handleNotificationsList)
+  r.Get("/notifications/stream", s.handleNotificationsStream)
+  r.Get("/notifications/subscribers", s.handleNotificationSubscribers)
+  r.Get("/activity/annotations", s.handleActivityAnnotationsList)
+  r.Post("/activity/annotations", s.handleActivityAnnotationsSave)
+  r.Get("/activity/journal", s.handleJournalList)
+  r.Post("/activity/journal", s.handleJournalSave)
+  r.Delete("/activity/journal/{id}", s.handleJournalDelete)
+  r.Get("/activity/export", s.handleActivityExport)
+  r.Get("/jobs", s.handleJobsList)
+  r.Get("/jobs/{id}", s.handleJobGet)
+  r.Get("/jobs/{id}/stream", s.handleJobStream)
+  r.Delete("/jobs/{id}", s.handleJobCancel)
+  r.Get("/reports/live", s.handleReportsLive)
+  r.With(s.requireLNDReady).Get("/ln/forwards", s.handleLNForwards)
+  r.With(s.requireLNDReady, s.requireTOTP).Post("/ln/rebalance", s.handleLNRebalance)
+  r.Get("/ln/rebalance/jobs", s.handleLNRebalanceJobs)
+  r.Get("/ln/rebalance/jobs/{id}", s.handleLNRebalanceJob)
+
+  r.Route("/onchain", func(r chi.Router) {
+    r.Use(s.requireLNDReady)
+    r.Get("/utxos", s.handleOnchainUtxos)
+    r.Get("/transactions", s.handleOnchainTransactions)
+  })
+
+  r.Route("/wallet", func(r chi.Router) {
+    r.Get("/summary", s.handleWalletSummary)
+    r.With(s.requireLNDReady).Get("/payments", s.handleWalletPayments)
+    r.With(s.requireLNDReady).Get("/descriptors", s.handleWalletDescriptors)
+    r.With(s.requireLNDReady).Post("/proof-of-reserves", s.handleProofOfReserves)
+    r.With(s.requireLNDReady).Post("/address", s.handleWalletAddress)
+    r.With(s.requireLNDReady).Post("/invoice", s.handleWalletInvoice)
+    r.With(s.requireLNDReady).Post("/payment-request", s.handleWalletPaymentRequest)
+    r.With(s.requireLNDReady).Post("/decode", s.handleWalletDecode)
+    r.With(s.requireLNDReady, s.requireTOTP).Post("/pay", s.handleWalletPay)
+    r.With(s.requireLNDReady, s.requireTOTP).Post("/send", s.handleWalletSend)
+  })
+
+  r.Route("/lnops", func(r chi.Router) {
+    r.Use(s.requireLNDReady)
+    r.Get("/channels", s.handleLNChannels)
+    r.Get("/peers", s.handleLNPeers)
+    r.Post("/peer", s.handleLNConnectPeer)
+    r.Post("/peer/disconnect", s.handleLNDisconnectPeer)
+    r.Post("/peers/boost", s.handleLNBoostPeers)
+    r.Get("/forward-failures", s.handleForwardFailures)
+    r.Get("/liquidity", s.handleLiquidity)
+    r.Get("/capacity", s.handleLNCapacity)
+    r.Get("/channel/fees", s.handleLNChannelFees)
+    r.With(s.requireTOTP).Post("/channel/open", s.handleLNOpenChannel)
+    r.Post("/channel/open/preview", s.handleLNOpenChannelPreview)
+    r.Get("/groups/stats", s.handleChannelGroupsStats)
+    r.Get("/splice", s.handleSpliceCapability)
+    r.With(s.requireTOTP).Post("/channel/splice", s.handleLNSplice)
+    r.With(s.requireTOTP).Post("/channel/close", s.handleLNCloseChannel)
+    r.With(s.requireTOTP).Post("/channel/fees", s.handleLNUpdateFees)
+    r.Post("/channel/fees/preview", s.handleLNChannelFeesPreview)
+  })
+}
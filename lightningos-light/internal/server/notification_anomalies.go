@@ -0,0 +1,131 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/reports"
+)
+
+const (
+  reportAnomalyPollInterval = time.Hour
+  reportAnomalyStartDelay = 2 * time.Minute
+  reportAnomalyCursorKey = "report_anomaly_last_day"
+)
+
+// reportAnomalyThresholds reads the detector thresholds from secrets.env,
+// falling back to the defaults for unset values.
+func reportAnomalyThresholds() reports.AnomalyThresholds {
+  thresholds := reports.DefaultAnomalyThresholds()
+  if val := readEnvInt(secretsPath, "REPORTS_ANOMALY_TRAILING_DAYS"); val != nil {
+    thresholds.TrailingDays = *val
+  }
+  if val := readEnvInt(secretsPath, "REPORTS_ANOMALY_REVENUE_DROP_PCT"); val != nil {
+    thresholds.RevenueDropPct = *val
+  }
+  if val := readEnvInt(secretsPath, "REPORTS_ANOMALY_REBALANCE_SPIKE_PCT"); val != nil {
+    thresholds.RebalanceSpikePct = *val
+  }
+  if thresholds.MinTrailingDays > thresholds.TrailingDays {
+    thresholds.MinTrailingDays = thresholds.TrailingDays
+  }
+  return thresholds
+}
+
+// runReportAnomalies watches stored daily reports and raises a notification
+// when a day falls outside the trailing average. Reports are stored by the
+// reports-run timer, so new days are picked up by polling.
+func (n *Notifier) runReportAnomalies() {
+  if n.nodeKey() != config.DefaultNodeID {
+    return
+  }
+  wait := reportAnomalyStartDelay
+  for {
+    select {
+    case <-n.stop:
+      return
+    case <-time.After(wait):
+    }
+    wait = reportAnomalyPollInterval
+
+    ctx, cancel := context.WithTimeout(n.context(), 20*time.Second)
+    if err := n.checkReportAnomalies(ctx, reportAnomalyThresholds()); err != nil {
+      n.logger.Printf("notifications: report anomaly check failed: %v", err)
+    }
+    cancel()
+  }
+}
+
+func (n *Notifier) checkReportAnomalies(ctx context.Context, thresholds reports.AnomalyThresholds) error {
+  cursor, err := n.getCursor(ctx, reportAnomalyCursorKey)
+  if err != nil {
+    return err
+  }
+
+  today := time.Now().UTC()
+  var since time.Time
+  if cursor != "" {
+    since, err = time.Parse("2006-01-02", cursor)
+    if err != nil {
+      return fmt.Errorf("invalid anomaly cursor %q", cursor)
+    }
+  } else {
+    // First run: only the most recent day is evaluated, history is not alerted.
+    since = today.AddDate(0, 0, -2)
+  }
+
+  rows, err := reports.FetchRange(ctx, n.db, since.AddDate(0, 0, -thresholds.TrailingDays), today)
+  if err != nil {
+    return err
+  }
+
+  for i, row := range rows {
+    day := row.ReportDate.Format("2006-01-02")
+    if day <= since.Format("2006-01-02") {
+      continue
+    }
+    start := row.ReportDate.AddDate(0, 0, -thresholds.TrailingDays)
+    var trailing []reports.Row
+    for _, prev := range rows[:i] {
+      if !prev.ReportDate.Before(start) {
+        trailing = append(trailing, prev)
+      }
+    }
+    for _, anomaly := range reports.DetectAnomalies(row, trailing, thresholds) {
+      evt := reportAnomalyNotification(anomaly, len(trailing), n.preferences())
+      if _, err := n.upsertNotification(ctx, fmt.Sprintf("report:%s:%s", day, anomaly.Kind), evt); err != nil {
+        return err
+      }
+    }
+    if err := n.setCursor(ctx, reportAnomalyCursorKey, day); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func reportAnomalyNotification(anomaly reports.Anomaly, trailingDays int, prefs UserPreferences) Notification {
+  day := prefs.formatDate(anomaly.Date)
+  current := prefs.formatSats(anomaly.CurrentMsat / 1000)
+  baseline := prefs.formatSats(anomaly.BaselineMsat / 1000)
+  memo := ""
+  switch anomaly.Kind {
+  case reports.AnomalyRevenueDrop:
+    memo = fmt.Sprintf("Forward revenue on %s was %s, %d%% below the %d-day average of %s",
+      day, current, -anomaly.ChangePct, trailingDays, baseline)
+  case reports.AnomalyRebalanceSpike:
+    memo = fmt.Sprintf("Rebalance costs on %s were %s, %d%% above the %d-day average of %s",
+      day, current, anomaly.ChangePct, trailingDays, baseline)
+  }
+  return Notification{
+    OccurredAt: time.Now().UTC(),
+    Type: "report",
+    Action: anomaly.Kind,
+    Direction: "neutral",
+    Status: "WARNING",
+    AmountSat: anomaly.CurrentMsat / 1000,
+    Memo: memo,
+  }
+}
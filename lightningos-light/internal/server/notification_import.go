@@ -0,0 +1,487 @@
+package server
+
+import (
+  "context"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/lnrpc"
+)
+
+const (
+  historyImportCursorKey = "history_import_done"
+  historyImportPageSize = 500
+  historyImportTimeout = 2 * time.Hour
+)
+
+type historyImportStatus struct {
+  Running bool `json:"running"`
+  StartedAt string `json:"started_at,omitempty"`
+  FinishedAt string `json:"finished_at,omitempty"`
+  CompletedAt string `json:"completed_at,omitempty"`
+  Stage string `json:"stage,omitempty"`
+  Imported map[string]int `json:"imported"`
+  LastError string `json:"last_error,omitempty"`
+}
+
+type historyImporter struct {
+  mu sync.Mutex
+  running bool
+  startedAt time.Time
+  finishedAt time.Time
+  stage string
+  imported map[string]int
+  lastError string
+}
+
+func (h *historyImporter) begin() bool {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  if h.running {
+    return false
+  }
+  h.running = true
+  h.startedAt = time.Now().UTC()
+  h.finishedAt = time.Time{}
+  h.stage = ""
+  h.imported = map[string]int{}
+  h.lastError = ""
+  return true
+}
+
+func (h *historyImporter) setStage(stage string) {
+  h.mu.Lock()
+  h.stage = stage
+  h.mu.Unlock()
+}
+
+func (h *historyImporter) add(kind string) {
+  h.mu.Lock()
+  h.imported[kind]++
+  h.mu.Unlock()
+}
+
+func (h *historyImporter) finish(err error) {
+  h.mu.Lock()
+  h.running = false
+  h.finishedAt = time.Now().UTC()
+  h.stage = ""
+  if err != nil {
+    h.lastError = err.Error()
+  }
+  h.mu.Unlock()
+}
+
+func (h *historyImporter) snapshot() historyImportStatus {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  status := historyImportStatus{
+    Running: h.running,
+    Stage: h.stage,
+    Imported: map[string]int{},
+    LastError: h.lastError,
+  }
+  for key, val := range h.imported {
+    status.Imported[key] = val
+  }
+  if !h.startedAt.IsZero() {
+    status.StartedAt = h.startedAt.Format(time.RFC3339)
+  }
+  if !h.finishedAt.IsZero() {
+    status.FinishedAt = h.finishedAt.Format(time.RFC3339)
+  }
+  return status
+}
+
+// StartHistoryImport backfills notifications from LND's history. It runs once
+// per install unless force is set; events already stored are kept as-is.
+// Only the last notificationRetentionDays are imported: older rows would be
+// pruned again on the next pass, and the daily reports keep the long-term
+// totals.
+func (n *Notifier) StartHistoryImport(force bool) error {
+  if n.db == nil {
+    return errors.New("notifications disabled")
+  }
+  if !force {
+    ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
+    done, err := n.getCursor(ctx, historyImportCursorKey)
+    cancel()
+    if err != nil {
+      return err
+    }
+    if strings.TrimSpace(done) != "" {
+      return errors.New("history already imported (use force to run again)")
+    }
+  }
+  if !n.importer.begin() {
+    return errors.New("history import already running")
+  }
+
+  // Rows are inserted with on conflict do nothing, so a run restarted after
+  // a panic picks up where the last one stopped.
+  goSafe("notifications/history_import", func() {
+    ctx, cancel := context.WithTimeout(n.context(), historyImportTimeout)
+    defer cancel()
+    err := n.importHistory(ctx)
+    if err == nil {
+      err = n.setCursor(ctx, historyImportCursorKey, time.Now().UTC().Format(time.RFC3339))
+    }
+    if err != nil {
+      n.logger.Printf("notifications: history import failed: %v", err)
+    } else {
+      n.logger.Printf("notifications: history import finished")
+    }
+    n.importer.finish(err)
+  })
+  return nil
+}
+
+func (n *Notifier) historyImportStatus(ctx context.Context) historyImportStatus {
+  status := n.importer.snapshot()
+  if done, err := n.getCursor(ctx, historyImportCursorKey); err == nil {
+    status.CompletedAt = strings.TrimSpace(done)
+  }
+  return status
+}
+
+func (n *Notifier) importHistory(ctx context.Context) error {
+  conn, err := n.lnd.DialLightning(ctx)
+  if err != nil {
+    return err
+  }
+  defer conn.Close()
+  client := lnrpc.NewLightningClient(conn)
+  cutoff := time.Now().AddDate(0, 0, -notificationRetentionDays)
+
+  n.importer.setStage("payments")
+  rebalanceHashes, err := n.importPayments(ctx, client, cutoff)
+  if err != nil {
+    return fmt.Errorf("payments: %w", err)
+  }
+  n.importer.setStage("invoices")
+  if err := n.importInvoices(ctx, client, cutoff, rebalanceHashes); err != nil {
+    return fmt.Errorf("invoices: %w", err)
+  }
+  n.importer.setStage("forwards")
+  if err := n.importForwards(ctx, client, cutoff); err != nil {
+    return fmt.Errorf("forwards: %w", err)
+  }
+  n.importer.setStage("onchain")
+  txTimes, err := n.importTransactions(ctx, client, cutoff)
+  if err != nil {
+    return fmt.Errorf("onchain: %w", err)
+  }
+  n.importer.setStage("channels")
+  if err := n.importClosedChannels(ctx, client, cutoff, txTimes); err != nil {
+    return fmt.Errorf("channels: %w", err)
+  }
+  return nil
+}
+
+// importNotification stores a historical event without overwriting live rows
+// and without broadcasting it to subscribers. Muted events are stored with
+// the flag, like live ones.
+func (n *Notifier) importNotification(ctx context.Context, eventKey string, evt Notification) (bool, error) {
+  tag, err := n.db.Exec(ctx, `
+insert into notifications (
+  event_key, occurred_at, type, action, direction, status, amount_sat, fee_sat, fee_msat,
+  peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo, node_id, muted
+) values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18)
+on conflict (event_key) do nothing
+`, n.scopedKey(eventKey), evt.OccurredAt, evt.Type, evt.Action, evt.Direction, evt.Status,
+    evt.AmountSat, evt.FeeSat, evt.FeeMsat, nullableString(evt.PeerPubkey), nullableString(evt.PeerAlias),
+    nullableInt(evt.ChannelID), nullableString(evt.ChannelPoint), nullableString(evt.Txid),
+    nullableString(evt.PaymentHash), nullableString(evt.Memo), n.nodeKey(), n.isMuted(evt),
+  )
+  if err != nil {
+    return false, err
+  }
+  return tag.RowsAffected() > 0, nil
+}
+
+func (n *Notifier) recordImport(ctx context.Context, kind string, eventKey string, evt Notification) error {
+  inserted, err := n.importNotification(ctx, eventKey, evt)
+  if err != nil {
+    return err
+  }
+  if inserted {
+    n.importer.add(kind)
+  }
+  return nil
+}
+
+func (n *Notifier) importPayments(ctx context.Context, client lnrpc.LightningClient, cutoff time.Time) (map[string]bool, error) {
+  rebalances := map[string]bool{}
+  var offset uint64
+  for {
+    res, err := client.ListPayments(ctx, &lnrpc.ListPaymentsRequest{
+      IndexOffset: offset,
+      MaxPayments: historyImportPageSize,
+    })
+    if err != nil {
+      return nil, err
+    }
+    for _, pay := range res.Payments {
+      if pay == nil || pay.Status != lnrpc.Payment_SUCCEEDED {
+        continue
+      }
+      hash := normalizeHash(pay.PaymentHash)
+      occurredAt := time.Unix(0, pay.CreationTimeNs).UTC()
+      if hash == "" || occurredAt.Before(cutoff) {
+        continue
+      }
+      feeMsat := paymentFeeMsat(pay)
+      evt := Notification{
+        OccurredAt: occurredAt,
+        Type: "lightning",
+        Action: "sent",
+        Direction: "out",
+        Status: pay.Status.String(),
+        AmountSat: pay.ValueSat,
+        FeeSat: feeMsat / 1000,
+        FeeMsat: feeMsat,
+        PaymentHash: hash,
+      }
+      if isKeysendPayment(pay) {
+        evt.Type = "keysend"
+        evt.PeerPubkey = keysendDestinationFromPayment(pay)
+        evt.Memo = keysendMessageFromPayment(pay)
+      } else if n.isSelfPayment(ctx, pay.PaymentRequest, pay) {
+        rebalances[hash] = true
+        evt = n.rebalanceEvent(ctx, pay, occurredAt)
+      } else if route := rebalanceRouteFromPayment(pay); route != nil {
+        if hops := route.GetHops(); len(hops) > 0 {
+          evt.PeerPubkey = strings.TrimSpace(hops[len(hops)-1].PubKey)
+        }
+      }
+      if evt.PeerAlias == "" && evt.PeerPubkey != "" {
+        evt.PeerAlias = n.lookupNodeAlias(evt.PeerPubkey)
+      }
+      if err := n.recordImport(ctx, evt.Type, fmt.Sprintf("payment:%s", hash), evt); err != nil {
+        return nil, err
+      }
+    }
+    if len(res.Payments) < historyImportPageSize || res.LastIndexOffset <= offset {
+      return rebalances, nil
+    }
+    offset = res.LastIndexOffset
+  }
+}
+
+func (n *Notifier) importInvoices(ctx context.Context, client lnrpc.LightningClient, cutoff time.Time, rebalances map[string]bool) error {
+  var offset uint64
+  for {
+    res, err := client.ListInvoices(ctx, &lnrpc.ListInvoiceRequest{
+      IndexOffset: offset,
+      NumMaxInvoices: historyImportPageSize,
+    })
+    if err != nil {
+      return err
+    }
+    for _, invoice := range res.Invoices {
+      if invoice == nil || invoice.State != lnrpc.Invoice_SETTLED {
+        continue
+      }
+      hash := normalizeHash(hex.EncodeToString(invoice.RHash))
+      occurredAt := time.Unix(invoice.SettleDate, 0).UTC()
+      if hash == "" || rebalances[hash] || occurredAt.Before(cutoff) {
+        continue
+      }
+      amount := invoice.AmtPaidSat
+      if amount == 0 {
+        amount = invoice.Value
+      }
+      evt := Notification{
+        OccurredAt: occurredAt,
+        Type: "lightning",
+        Action: "received",
+        Direction: "in",
+        Status: "SETTLED",
+        AmountSat: amount,
+        PaymentHash: hash,
+        Memo: strings.TrimSpace(invoice.Memo),
+      }
+      if invoice.IsKeysend {
+        evt.Type = "keysend"
+        evt.Memo = keysendMessageFromInvoice(invoice)
+        evt.PeerPubkey, evt.PeerAlias = n.keysendPeerFromInvoice(ctx, invoice)
+      }
+      if err := n.recordImport(ctx, evt.Type, fmt.Sprintf("invoice:%s", hash), evt); err != nil {
+        return err
+      }
+    }
+    if len(res.Invoices) < historyImportPageSize || res.LastIndexOffset <= offset {
+      return nil
+    }
+    offset = res.LastIndexOffset
+  }
+}
+
+func (n *Notifier) importForwards(ctx context.Context, client lnrpc.LightningClient, cutoff time.Time) error {
+  var offset uint32
+  endTime := uint64(time.Now().Unix())
+  for {
+    res, err := client.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{
+      StartTime: uint64(cutoff.Unix()),
+      EndTime: endTime,
+      IndexOffset: offset,
+      NumMaxEvents: historyImportPageSize,
+      PeerAliasLookup: true,
+    })
+    if err != nil {
+      return err
+    }
+    for _, fwd := range res.ForwardingEvents {
+      occurredAt, _, tsKey := normalizeForwardTimestamp(fwd)
+      evt := Notification{
+        OccurredAt: occurredAt,
+        Type: "forward",
+        Action: "forwarded",
+        Direction: "neutral",
+        Status: "SETTLED",
+        AmountSat: int64(fwd.AmtOut),
+        FeeSat: int64(fwd.Fee),
+        FeeMsat: int64(fwd.FeeMsat),
+        PeerAlias: strings.TrimSpace(fmt.Sprintf("%s -> %s", fwd.PeerAliasIn, fwd.PeerAliasOut)),
+        ChannelID: int64(fwd.ChanIdOut),
+      }
+      eventKey := fmt.Sprintf("forward:%d:%d:%d", fwd.IncomingHtlcId, fwd.OutgoingHtlcId, tsKey)
+      if err := n.recordImport(ctx, "forward", eventKey, evt); err != nil {
+        return err
+      }
+    }
+    if len(res.ForwardingEvents) < historyImportPageSize || res.LastOffsetIndex <= offset {
+      return nil
+    }
+    offset = res.LastOffsetIndex
+  }
+}
+
+func (n *Notifier) importTransactions(ctx context.Context, client lnrpc.LightningClient, cutoff time.Time) (map[string]time.Time, error) {
+  res, err := client.GetTransactions(ctx, &lnrpc.GetTransactionsRequest{})
+  if err != nil {
+    return nil, err
+  }
+  times := map[string]time.Time{}
+  for _, tx := range res.Transactions {
+    occurredAt := time.Unix(tx.TimeStamp, 0).UTC()
+    times[tx.TxHash] = occurredAt
+    if occurredAt.Before(cutoff) {
+      continue
+    }
+    amount := tx.Amount
+    direction := "in"
+    action := "receive"
+    if amount < 0 {
+      direction = "out"
+      action = "send"
+      amount = amount * -1
+    }
+    status := "PENDING"
+    if tx.NumConfirmations > 0 {
+      status = "CONFIRMED"
+    }
+    evt := Notification{
+      OccurredAt: occurredAt,
+      Type: "onchain",
+      Action: action,
+      Direction: direction,
+      Status: status,
+      AmountSat: amount,
+      FeeSat: tx.TotalFees,
+      Txid: tx.TxHash,
+    }
+    if err := n.recordImport(ctx, "onchain", fmt.Sprintf("onchain:%s", tx.TxHash), evt); err != nil {
+      return nil, err
+    }
+  }
+  return times, nil
+}
+
+func (n *Notifier) importClosedChannels(ctx context.Context, client lnrpc.LightningClient, cutoff time.Time, txTimes map[string]time.Time) error {
+  res, err := client.ClosedChannels(ctx, &lnrpc.ClosedChannelsRequest{})
+  if err != nil {
+    return err
+  }
+  for _, ch := range res.Channels {
+    if ch == nil || ch.ChannelPoint == "" {
+      continue
+    }
+    if openedAt, ok := txTimes[channelPointTxid(ch.ChannelPoint)]; ok && !openedAt.Before(cutoff) {
+      evt := Notification{
+        OccurredAt: openedAt,
+        Type: "channel",
+        Action: "open",
+        Direction: "neutral",
+        Status: "OPENED",
+        AmountSat: ch.Capacity,
+        PeerPubkey: ch.RemotePubkey,
+        ChannelID: int64(ch.ChanId),
+        ChannelPoint: ch.ChannelPoint,
+        Txid: channelPointTxid(ch.ChannelPoint),
+      }
+      if err := n.recordImport(ctx, "channel", fmt.Sprintf("channel:open:%s", ch.ChannelPoint), evt); err != nil {
+        return err
+      }
+    }
+    closedAt, ok := txTimes[ch.ClosingTxHash]
+    if !ok || closedAt.Before(cutoff) {
+      continue
+    }
+    evt := Notification{
+      OccurredAt: closedAt,
+      Type: "channel",
+      Action: "close",
+      Direction: "neutral",
+      Status: "CLOSED",
+      AmountSat: ch.SettledBalance,
+      PeerPubkey: ch.RemotePubkey,
+      ChannelID: int64(ch.ChanId),
+      ChannelPoint: ch.ChannelPoint,
+      Txid: ch.ClosingTxHash,
+    }
+    if evt.PeerPubkey != "" {
+      evt.PeerAlias = n.lookupNodeAlias(evt.PeerPubkey)
+    }
+    if err := n.recordImport(ctx, "channel", fmt.Sprintf("channel:close:%s", ch.ChannelPoint), evt); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (s *Server) handleNotificationsImportGet(w http.ResponseWriter, r *http.Request) {
+  if s.notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  writeJSON(w, http.StatusOK, s.notifier.historyImportStatus(ctx))
+}
+
+func (s *Server) handleNotificationsImportPost(w http.ResponseWriter, r *http.Request) {
+  if s.notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return
+  }
+  var req struct {
+    Force bool `json:"force"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if err := s.notifier.StartHistoryImport(req.Force); err != nil {
+    writeError(w, http.StatusConflict, err.Error())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  writeJSON(w, http.StatusAccepted, s.notifier.historyImportStatus(ctx))
+}
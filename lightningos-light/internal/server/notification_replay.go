@@ -0,0 +1,117 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+  "time"
+)
+
+const notificationReplayMax = 1000
+
+type notificationReplayRequest struct {
+  ID int64 `json:"id"`
+  EventKey string `json:"event_key"`
+  Since string `json:"since"`
+}
+
+func (n *Notifier) replayable(ctx context.Context, id int64, eventKey string, since time.Time) ([]Notification, error) {
+  if n.db == nil {
+    return nil, errors.New("notifications disabled")
+  }
+  query := `
+select id, occurred_at, type, action, direction, status, amount_sat, fee_sat, fee_msat,
+  peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo, event_key
+from notifications`
+  args := []any{n.nodeKey()}
+  switch {
+  case id > 0:
+    query += " where node_id=$1 and id=$2"
+    args = append(args, id)
+  case eventKey != "":
+    query += " where node_id=$1 and event_key=$2"
+    args = append(args, eventKey)
+  case !since.IsZero():
+    query += " where node_id=$1 and not muted and occurred_at >= $2 order by occurred_at asc, id asc limit $3"
+    args = append(args, since, notificationReplayMax)
+  default:
+    return nil, errors.New("id, event_key or since required")
+  }
+
+  rows, err := n.db.Query(ctx, query, args...)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  items := []Notification{}
+  for rows.Next() {
+    evt, err := scanNotification(rows)
+    if err != nil {
+      return nil, err
+    }
+    items = append(items, evt)
+  }
+  return items, rows.Err()
+}
+
+// replay re-emits stored notifications to live subscribers (SSE and MQTT)
+// without touching the stored rows; with push set they also go to the
+// matching push topics, unless a rule mutes them now. Replayed events carry
+// replayed=true so consumers can tell them apart from fresh ones and
+// de-duplicate on event_key.
+func (n *Notifier) replay(items []Notification, push bool) {
+  for _, evt := range items {
+    evt.Replayed = true
+    n.broadcast(evt)
+    if push && !n.isMuted(evt) {
+      n.sendPushes(evt, false)
+    }
+  }
+}
+
+func (s *Server) handleNotificationsReplay(w http.ResponseWriter, r *http.Request) {
+  if s.notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return
+  }
+  var req notificationReplayRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+
+  eventKey := strings.TrimSpace(req.EventKey)
+  var since time.Time
+  if raw := strings.TrimSpace(req.Since); raw != "" {
+    parsed, err := time.Parse(time.RFC3339, raw)
+    if err != nil {
+      writeError(w, http.StatusBadRequest, "since must be RFC3339")
+      return
+    }
+    since = parsed
+  }
+  if req.ID <= 0 && eventKey == "" && since.IsZero() {
+    writeError(w, http.StatusBadRequest, "id, event_key or since required")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+
+  items, err := s.notifier.replayable(ctx, req.ID, eventKey, since)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load notifications: %v", err))
+    return
+  }
+  if len(items) == 0 && (req.ID > 0 || eventKey != "") {
+    writeError(w, http.StatusNotFound, "notification not found")
+    return
+  }
+
+  // Phones only get a single event again; a range could be a thousand.
+  s.notifier.replay(items, since.IsZero())
+  writeJSON(w, http.StatusOK, map[string]any{"ok": true, "replayed": len(items)})
+}
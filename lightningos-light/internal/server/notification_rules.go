@@ -0,0 +1,329 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/go-chi/chi/v5"
+  "github.com/jackc/pgx/v5/pgtype"
+)
+
+var notificationRuleTypes = map[string]bool{
+  "lightning": true,
+  "keysend": true,
+  "onchain": true,
+  "channel": true,
+  "forward": true,
+  "rebalance": true,
+  "report": true,
+  "config": true,
+  "alert": true,
+}
+
+// notificationActivityTypes are the node's own activity. A rule without a
+// type only mutes these, so a catch-all threshold or snooze never silences
+// system alerts (intrusions, stale backups, drift, failed schedules).
+var notificationActivityTypes = map[string]bool{
+  "lightning": true,
+  "keysend": true,
+  "onchain": true,
+  "channel": true,
+  "forward": true,
+  "rebalance": true,
+}
+
+// NotificationRule mutes events that match every condition it sets.
+// Empty fields match anything (an empty type, any activity type); thresholds
+// only apply when positive. Muted events are stored but not delivered.
+type NotificationRule struct {
+  ID int64 `json:"id"`
+  Name string `json:"name"`
+  Enabled bool `json:"enabled"`
+  Type string `json:"type,omitempty"`
+  Action string `json:"action,omitempty"`
+  Direction string `json:"direction,omitempty"`
+  PeerPubkey string `json:"peer_pubkey,omitempty"`
+  BelowAmountSat int64 `json:"below_amount_sat,omitempty"`
+  BelowFeeMsat int64 `json:"below_fee_msat,omitempty"`
+  MutedUntil *time.Time `json:"muted_until,omitempty"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+type notificationRuleRequest struct {
+  Name string `json:"name"`
+  Enabled *bool `json:"enabled"`
+  Type string `json:"type"`
+  Action string `json:"action"`
+  Direction string `json:"direction"`
+  PeerPubkey string `json:"peer_pubkey"`
+  BelowAmountSat int64 `json:"below_amount_sat"`
+  BelowFeeMsat int64 `json:"below_fee_msat"`
+  MutedUntil string `json:"muted_until"`
+}
+
+func (r NotificationRule) matches(evt Notification, now time.Time) bool {
+  if !r.Enabled {
+    return false
+  }
+  if r.MutedUntil != nil && !now.Before(*r.MutedUntil) {
+    return false
+  }
+  if r.Type == "" && !notificationActivityTypes[evt.Type] {
+    return false
+  }
+  if r.Type != "" && !strings.EqualFold(r.Type, evt.Type) {
+    return false
+  }
+  if r.Action != "" && !strings.EqualFold(r.Action, evt.Action) {
+    return false
+  }
+  if r.Direction != "" && !strings.EqualFold(r.Direction, evt.Direction) {
+    return false
+  }
+  if r.PeerPubkey != "" && !strings.EqualFold(r.PeerPubkey, evt.PeerPubkey) {
+    return false
+  }
+  if r.BelowAmountSat > 0 && evt.AmountSat >= r.BelowAmountSat {
+    return false
+  }
+  if r.BelowFeeMsat > 0 && notificationFeeMsat(evt) >= r.BelowFeeMsat {
+    return false
+  }
+  return true
+}
+
+func notificationFeeMsat(evt Notification) int64 {
+  if evt.FeeMsat > 0 {
+    return evt.FeeMsat
+  }
+  return evt.FeeSat * 1000
+}
+
+func (n *Notifier) ensureRulesSchema(ctx context.Context) error {
+  _, err := n.db.Exec(ctx, `
+create table if not exists notification_rules (
+  id bigserial primary key,
+  name text not null default '',
+  enabled boolean not null default true,
+  type text not null default '',
+  action text not null default '',
+  direction text not null default '',
+  peer_pubkey text not null default '',
+  below_amount_sat bigint not null default 0,
+  below_fee_msat bigint not null default 0,
+  muted_until timestamptz,
+  created_at timestamptz not null default now()
+);
+`)
+  return err
+}
+
+func (n *Notifier) loadRules(ctx context.Context) error {
+  rules, err := n.listRules(ctx)
+  if err != nil {
+    return err
+  }
+  n.rulesMu.Lock()
+  n.rules = rules
+  n.rulesMu.Unlock()
+  return nil
+}
+
+func (n *Notifier) isMuted(evt Notification) bool {
+  n.rulesMu.RLock()
+  defer n.rulesMu.RUnlock()
+  now := time.Now()
+  for _, rule := range n.rules {
+    if rule.matches(evt, now) {
+      return true
+    }
+  }
+  return false
+}
+
+func (n *Notifier) listRules(ctx context.Context) ([]NotificationRule, error) {
+  if n.db == nil {
+    return nil, errors.New("notifications disabled")
+  }
+  rows, err := n.db.Query(ctx, `
+select id, name, enabled, type, action, direction, peer_pubkey, below_amount_sat, below_fee_msat, muted_until, created_at
+from notification_rules
+order by id asc`)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  rules := []NotificationRule{}
+  for rows.Next() {
+    var rule NotificationRule
+    var mutedUntil pgtype.Timestamptz
+    if err := rows.Scan(&rule.ID, &rule.Name, &rule.Enabled, &rule.Type, &rule.Action, &rule.Direction,
+      &rule.PeerPubkey, &rule.BelowAmountSat, &rule.BelowFeeMsat, &mutedUntil, &rule.CreatedAt); err != nil {
+      return nil, err
+    }
+    if mutedUntil.Valid {
+      until := mutedUntil.Time
+      rule.MutedUntil = &until
+    }
+    rules = append(rules, rule)
+  }
+  return rules, rows.Err()
+}
+
+func (n *Notifier) createRule(ctx context.Context, rule NotificationRule) (NotificationRule, error) {
+  var mutedUntil any
+  if rule.MutedUntil != nil {
+    mutedUntil = *rule.MutedUntil
+  }
+  err := n.db.QueryRow(ctx, `
+insert into notification_rules (name, enabled, type, action, direction, peer_pubkey, below_amount_sat, below_fee_msat, muted_until)
+values ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+returning id, created_at`, rule.Name, rule.Enabled, rule.Type, rule.Action, rule.Direction,
+    rule.PeerPubkey, rule.BelowAmountSat, rule.BelowFeeMsat, mutedUntil,
+  ).Scan(&rule.ID, &rule.CreatedAt)
+  if err != nil {
+    return NotificationRule{}, err
+  }
+  if err := n.loadRules(ctx); err != nil {
+    n.logger.Printf("notifications: reload rules failed: %v", err)
+  }
+  return rule, nil
+}
+
+func (n *Notifier) deleteRule(ctx context.Context, id int64) (bool, error) {
+  tag, err := n.db.Exec(ctx, "delete from notification_rules where id=$1", id)
+  if err != nil {
+    return false, err
+  }
+  if err := n.loadRules(ctx); err != nil {
+    n.logger.Printf("notifications: reload rules failed: %v", err)
+  }
+  return tag.RowsAffected() > 0, nil
+}
+
+func parseNotificationRule(req notificationRuleRequest) (NotificationRule, error) {
+  rule := NotificationRule{
+    Name: strings.TrimSpace(req.Name),
+    Enabled: true,
+    Type: strings.ToLower(strings.TrimSpace(req.Type)),
+    Action: strings.ToLower(strings.TrimSpace(req.Action)),
+    Direction: strings.ToLower(strings.TrimSpace(req.Direction)),
+    PeerPubkey: strings.ToLower(strings.TrimSpace(req.PeerPubkey)),
+    BelowAmountSat: req.BelowAmountSat,
+    BelowFeeMsat: req.BelowFeeMsat,
+  }
+  if req.Enabled != nil {
+    rule.Enabled = *req.Enabled
+  }
+  if rule.Type != "" && !notificationRuleTypes[rule.Type] {
+    return NotificationRule{}, fmt.Errorf("unknown notification type: %s", rule.Type)
+  }
+  switch rule.Direction {
+  case "", "in", "out", "neutral":
+  default:
+    return NotificationRule{}, errors.New("direction must be in, out or neutral")
+  }
+  if rule.PeerPubkey != "" && !isValidPubkeyHex(rule.PeerPubkey) {
+    return NotificationRule{}, errors.New("invalid peer pubkey")
+  }
+  if rule.BelowAmountSat < 0 || rule.BelowFeeMsat < 0 {
+    return NotificationRule{}, errors.New("thresholds must be positive")
+  }
+  if raw := strings.TrimSpace(req.MutedUntil); raw != "" {
+    until, err := time.Parse(time.RFC3339, raw)
+    if err != nil {
+      return NotificationRule{}, errors.New("muted_until must be RFC3339")
+    }
+    rule.MutedUntil = &until
+  }
+  if rule.Type == "" && rule.Action == "" && rule.Direction == "" && rule.PeerPubkey == "" &&
+    rule.BelowAmountSat == 0 && rule.BelowFeeMsat == 0 && rule.MutedUntil == nil {
+    return NotificationRule{}, errors.New("rule must set at least one condition")
+  }
+  return rule, nil
+}
+
+func (s *Server) handleNotificationRulesList(w http.ResponseWriter, r *http.Request) {
+  if s.notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+
+  rules, err := s.notifier.listRules(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load rules: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": rules})
+}
+
+func (s *Server) handleNotificationRulesCreate(w http.ResponseWriter, r *http.Request) {
+  if s.notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return
+  }
+  var req notificationRuleRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  rule, err := parseNotificationRule(req)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+
+  created, err := s.notifier.createRule(ctx, rule)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save rule: %v", err))
+    return
+  }
+  s.reloadNodeRules(ctx)
+  writeJSON(w, http.StatusOK, created)
+}
+
+func (s *Server) handleNotificationRulesDelete(w http.ResponseWriter, r *http.Request) {
+  if s.notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return
+  }
+  id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+  if err != nil || id <= 0 {
+    writeError(w, http.StatusBadRequest, "invalid rule id")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+
+  found, err := s.notifier.deleteRule(ctx, id)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to delete rule: %v", err))
+    return
+  }
+  s.reloadNodeRules(ctx)
+  if !found {
+    writeError(w, http.StatusNotFound, "rule not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) reloadNodeRules(ctx context.Context) {
+  for _, notifier := range s.nodeNotifiers() {
+    if err := notifier.loadRules(ctx); err != nil {
+      s.logger.Printf("notifications: reload rules for node %s failed: %v", notifier.nodeID, err)
+    }
+  }
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+  "testing"
+  "time"
+)
+
+func TestNotificationRuleMatches(t *testing.T) {
+  now := time.Now()
+  forward := Notification{Type: "forward", AmountSat: 500, FeeMsat: 1200}
+
+  t.Run("amount threshold", func(t *testing.T) {
+    rule := NotificationRule{Enabled: true, Type: "forward", BelowAmountSat: 1000}
+    if !rule.matches(forward, now) {
+      t.Fatalf("expected small forward to be muted")
+    }
+    big := forward
+    big.AmountSat = 5000
+    if rule.matches(big, now) {
+      t.Fatalf("expected large forward to pass")
+    }
+  })
+
+  t.Run("fee threshold falls back to sats", func(t *testing.T) {
+    rule := NotificationRule{Enabled: true, BelowFeeMsat: 2000}
+    evt := Notification{Type: "lightning", FeeSat: 3}
+    if rule.matches(evt, now) {
+      t.Fatalf("expected 3000 msat fee to pass")
+    }
+  })
+
+  t.Run("untyped rule spares system alerts", func(t *testing.T) {
+    rule := NotificationRule{Enabled: true, BelowAmountSat: 1000}
+    if !rule.matches(forward, now) {
+      t.Fatalf("expected small forward to be muted")
+    }
+    for _, typ := range []string{"backup", "security", "scheduled_payment", "alert"} {
+      if rule.matches(Notification{Type: typ}, now) {
+        t.Fatalf("untyped rule muted %s", typ)
+      }
+    }
+  })
+
+  t.Run("type mismatch", func(t *testing.T) {
+    rule := NotificationRule{Enabled: true, Type: "keysend"}
+    if rule.matches(forward, now) {
+      t.Fatalf("expected type mismatch to pass")
+    }
+  })
+
+  t.Run("disabled or expired", func(t *testing.T) {
+    rule := NotificationRule{Enabled: false, Type: "forward"}
+    if rule.matches(forward, now) {
+      t.Fatalf("expected disabled rule to pass")
+    }
+    past := now.Add(-time.Minute)
+    rule = NotificationRule{Enabled: true, Type: "forward", MutedUntil: &past}
+    if rule.matches(forward, now) {
+      t.Fatalf("expected expired mute to pass")
+    }
+  })
+}
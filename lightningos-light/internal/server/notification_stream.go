@@ -0,0 +1,384 @@
+package server
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+const (
+  // Each stream starts with a small queue and doubles it when the client
+  // falls behind. A client that still cannot keep up at the maximum is
+  // disconnected; it resumes from its Last-Event-ID without losing events.
+  subscriberInitialBuffer = 50
+  subscriberMaxBuffer = 1000
+  maxStreamReplay = 1000
+  streamHeartbeat = 25 * time.Second
+  maxSubscriberNameLen = 40
+)
+
+// subscriberFilter narrows what one stream receives.
+type subscriberFilter struct {
+  Types []string `json:"types,omitempty"`
+  MinAmountSat int64 `json:"min_amount_sat,omitempty"`
+  // IncludeMuted is for internal consumers such as the POS registers, which
+  // need every settlement whatever the user muted.
+  IncludeMuted bool `json:"include_muted,omitempty"`
+}
+
+func (f subscriberFilter) match(evt Notification) bool {
+  if evt.Muted && !f.IncludeMuted {
+    return false
+  }
+  if len(f.Types) > 0 {
+    found := false
+    for _, t := range f.Types {
+      if t == evt.Type {
+        found = true
+        break
+      }
+    }
+    if !found {
+      return false
+    }
+  }
+  if f.MinAmountSat > 0 {
+    amount := evt.AmountSat
+    if amount < 0 {
+      amount = -amount
+    }
+    if amount < f.MinAmountSat {
+      return false
+    }
+  }
+  return true
+}
+
+func parseSubscriberFilter(r *http.Request) (subscriberFilter, error) {
+  var f subscriberFilter
+  q := r.URL.Query()
+  for _, raw := range strings.Split(q.Get("types"), ",") {
+    if t := strings.ToLower(strings.TrimSpace(raw)); t != "" {
+      f.Types = append(f.Types, t)
+    }
+  }
+  if raw := strings.TrimSpace(q.Get("min_amount_sat")); raw != "" {
+    v, err := strconv.ParseInt(raw, 10, 64)
+    if err != nil || v < 0 {
+      return f, errors.New("invalid min_amount_sat")
+    }
+    f.MinAmountSat = v
+  }
+  return f, nil
+}
+
+// subscriberName is the identity a client gives itself (?client=ui) so slow
+// consumers can be told apart in /api/notifications/subscribers.
+func subscriberName(r *http.Request) string {
+  name := strings.TrimSpace(r.URL.Query().Get("client"))
+  if name == "" {
+    name = strings.TrimSpace(r.Header.Get("X-Client-Name"))
+  }
+  var b strings.Builder
+  for _, c := range name {
+    if b.Len() >= maxSubscriberNameLen {
+      break
+    }
+    switch {
+    case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+      b.WriteRune(c)
+    }
+  }
+  if b.Len() == 0 {
+    return "anonymous"
+  }
+  return b.String()
+}
+
+// notificationSubscriber is one open stream. broadcast queues events without
+// blocking; the stream handler drains the queue when woken.
+type notificationSubscriber struct {
+  id uint64
+  client string
+  remote string
+  filter subscriberFilter
+  connectedAt time.Time
+  wake chan struct{}
+  done chan struct{}
+
+  mu sync.Mutex
+  queue []Notification
+  limit int
+  delivered uint64
+  resizes int
+  closed bool
+}
+
+type subscriberStatus struct {
+  ID uint64 `json:"id"`
+  Client string `json:"client"`
+  Remote string `json:"remote"`
+  ConnectedAt time.Time `json:"connected_at"`
+  Filter subscriberFilter `json:"filter"`
+  Queued int `json:"queued"`
+  BufferLimit int `json:"buffer_limit"`
+  Resizes int `json:"resizes"`
+  Delivered uint64 `json:"delivered"`
+}
+
+// push queues evt, growing the buffer for a lagging client. It reports false
+// when the client is too far behind and has to be dropped.
+func (sub *notificationSubscriber) push(evt Notification) bool {
+  if !sub.filter.match(evt) {
+    return true
+  }
+  sub.mu.Lock()
+  defer sub.mu.Unlock()
+  if sub.closed {
+    return true
+  }
+  if len(sub.queue) >= sub.limit {
+    if sub.limit >= subscriberMaxBuffer {
+      return false
+    }
+    sub.limit *= 2
+    if sub.limit > subscriberMaxBuffer {
+      sub.limit = subscriberMaxBuffer
+    }
+    sub.resizes++
+  }
+  sub.queue = append(sub.queue, evt)
+  select {
+  case sub.wake <- struct{}{}:
+  default:
+  }
+  return true
+}
+
+func (sub *notificationSubscriber) take() []Notification {
+  sub.mu.Lock()
+  defer sub.mu.Unlock()
+  items := sub.queue
+  sub.queue = nil
+  sub.delivered += uint64(len(items))
+  return items
+}
+
+func (sub *notificationSubscriber) status() subscriberStatus {
+  sub.mu.Lock()
+  defer sub.mu.Unlock()
+  return subscriberStatus{
+    ID: sub.id,
+    Client: sub.client,
+    Remote: sub.remote,
+    ConnectedAt: sub.connectedAt.UTC(),
+    Filter: sub.filter,
+    Queued: len(sub.queue),
+    BufferLimit: sub.limit,
+    Resizes: sub.resizes,
+    Delivered: sub.delivered,
+  }
+}
+
+func (n *Notifier) subscribe(client, remote string, filter subscriberFilter) *notificationSubscriber {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  n.nextSubscriber++
+  sub := &notificationSubscriber{
+    id: n.nextSubscriber,
+    client: client,
+    remote: remote,
+    filter: filter,
+    connectedAt: time.Now(),
+    wake: make(chan struct{}, 1),
+    done: make(chan struct{}),
+    limit: subscriberInitialBuffer,
+  }
+  n.subscribers[sub] = struct{}{}
+  return sub
+}
+
+// unsubscribeLocked removes sub; the caller must hold n.mu.
+func (n *Notifier) unsubscribeLocked(sub *notificationSubscriber) {
+  if _, ok := n.subscribers[sub]; !ok {
+    return
+  }
+  delete(n.subscribers, sub)
+  sub.mu.Lock()
+  sub.closed = true
+  sub.mu.Unlock()
+  close(sub.done)
+}
+
+func (n *Notifier) unsubscribe(sub *notificationSubscriber) {
+  n.mu.Lock()
+  n.unsubscribeLocked(sub)
+  n.mu.Unlock()
+}
+
+func (n *Notifier) broadcast(evt Notification) {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  for sub := range n.subscribers {
+    if !sub.push(evt) {
+      n.logger.Printf("notifications: dropping slow stream client %s (%s), %d events queued", sub.client, sub.remote, subscriberMaxBuffer)
+      n.unsubscribeLocked(sub)
+    }
+  }
+}
+
+func (n *Notifier) subscriberStatuses() []subscriberStatus {
+  n.mu.Lock()
+  subs := make([]*notificationSubscriber, 0, len(n.subscribers))
+  for sub := range n.subscribers {
+    subs = append(subs, sub)
+  }
+  n.mu.Unlock()
+  out := make([]subscriberStatus, 0, len(subs))
+  for _, sub := range subs {
+    out = append(out, sub.status())
+  }
+  sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+  return out
+}
+
+// resumeID is where a reconnecting client left off: the Last-Event-ID header
+// EventSource sends by itself, or ?last_event_id= for clients that cannot set
+// headers.
+func resumeID(r *http.Request) (int64, error) {
+  raw := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+  if raw == "" {
+    raw = strings.TrimSpace(r.URL.Query().Get("last_event_id"))
+  }
+  if raw == "" {
+    return 0, nil
+  }
+  id, err := strconv.ParseInt(raw, 10, 64)
+  if err != nil || id < 0 {
+    return 0, errors.New("invalid Last-Event-ID")
+  }
+  return id, nil
+}
+
+func (s *Server) handleNotificationsStream(w http.ResponseWriter, r *http.Request) {
+  notifier := s.notifierFor(r)
+  if notifier == nil {
+    msg := strings.TrimSpace(s.notifierErr)
+    if msg == "" {
+      msg = "notifications disabled"
+    }
+    writeError(w, http.StatusServiceUnavailable, msg)
+    return
+  }
+
+  flusher, ok := w.(http.Flusher)
+  if !ok {
+    writeError(w, http.StatusInternalServerError, "stream not supported")
+    return
+  }
+  filter, err := parseSubscriberFilter(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  lastID, err := resumeID(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  // Subscribe before replaying so nothing stored in between is missed.
+  sub := notifier.subscribe(subscriberName(r), clientIP(r), filter)
+  defer notifier.unsubscribe(sub)
+
+  var replay []Notification
+  if lastID > 0 {
+    ctx, cancel := s.requestContext(r, timeoutShort)
+    replay, err = notifier.listSince(ctx, lastID, maxStreamReplay)
+    cancel()
+    if err != nil {
+      s.logger.Printf("notifications: stream resume from %d failed: %v", lastID, err)
+      writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load notifications: %v", err))
+      return
+    }
+  }
+
+  w.Header().Set("Content-Type", "text/event-stream")
+  w.Header().Set("Cache-Control", "no-cache")
+  w.Header().Set("Connection", "keep-alive")
+
+  // The event id is the highest notification id sent so far, so a resume
+  // never re-requests rows the client already has. Replayed rows that show up
+  // again unchanged in the live queue are skipped.
+  replayed := map[int64]string{}
+  send := func(evt Notification) {
+    payload, err := json.Marshal(evt)
+    if err != nil {
+      return
+    }
+    if evt.ID > lastID {
+      lastID = evt.ID
+    }
+    _, _ = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", lastID, payload)
+  }
+
+  // truncated means more was missed than one replay carries; the client
+  // should page through GET /api/notifications?since_id= for the rest.
+  ready, _ := json.Marshal(map[string]any{
+    "subscriber_id": sub.id,
+    "resumed_from": lastID,
+    "replayed": len(replay),
+    "truncated": len(replay) >= maxStreamReplay,
+  })
+  _, _ = fmt.Fprintf(w, "event: ready\ndata: %s\n\n", ready)
+  for _, evt := range replay {
+    if !filter.match(evt) {
+      continue
+    }
+    replayed[evt.ID] = evt.Status
+    send(evt)
+  }
+  flusher.Flush()
+
+  ticker := time.NewTicker(streamHeartbeat)
+  defer ticker.Stop()
+
+  for {
+    select {
+    case <-r.Context().Done():
+      return
+    case <-sub.done:
+      // Dropped as a slow consumer: tell the client, which reconnects and
+      // resumes from the last id it saw.
+      _, _ = w.Write([]byte("event: overflow\ndata: {}\n\n"))
+      flusher.Flush()
+      return
+    case <-sub.wake:
+      for _, evt := range sub.take() {
+        if status, ok := replayed[evt.ID]; ok && status == evt.Status {
+          continue
+        }
+        send(evt)
+      }
+      flusher.Flush()
+    case <-ticker.C:
+      _, _ = w.Write([]byte("event: heartbeat\ndata: {}\n\n"))
+      flusher.Flush()
+    }
+  }
+}
+
+func (s *Server) handleNotificationSubscribers(w http.ResponseWriter, r *http.Request) {
+  notifier := s.notifierFor(r)
+  if notifier == nil {
+    writeJSON(w, http.StatusOK, map[string]any{"items": []subscriberStatus{}})
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": notifier.subscriberStatuses()})
+}
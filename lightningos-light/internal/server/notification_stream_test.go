@@ -0,0 +1,123 @@
+package server
+
+import (
+  "bufio"
+  "context"
+  "encoding/json"
+  "io"
+  "log"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestSubscriberFilter(t *testing.T) {
+  f := subscriberFilter{Types: []string{"lightning"}, MinAmountSat: 1000}
+  if !f.match(Notification{Type: "lightning", AmountSat: 1500}) {
+    t.Fatal("matching event rejected")
+  }
+  if f.match(Notification{Type: "onchain", AmountSat: 5000}) {
+    t.Fatal("other type accepted")
+  }
+  if f.match(Notification{Type: "lightning", AmountSat: 999}) {
+    t.Fatal("small amount accepted")
+  }
+  if f.match(Notification{Type: "lightning", AmountSat: 1500, Muted: true}) {
+    t.Fatal("muted event accepted")
+  }
+  f.IncludeMuted = true
+  if !f.match(Notification{Type: "lightning", AmountSat: 1500, Muted: true}) {
+    t.Fatal("muted event rejected with include_muted")
+  }
+}
+
+func TestSubscriberBufferGrowsThenDrops(t *testing.T) {
+  n := NewNotifier(nil, nil, log.New(io.Discard, "", 0))
+  sub := n.subscribe("slow", "10.0.0.5", subscriberFilter{})
+  for i := 0; i < subscriberMaxBuffer; i++ {
+    n.broadcast(Notification{ID: int64(i + 1), Type: "lightning"})
+  }
+  st := sub.status()
+  if st.Queued != subscriberMaxBuffer || st.BufferLimit != subscriberMaxBuffer || st.Resizes == 0 {
+    t.Fatalf("unexpected status after growth: %+v", st)
+  }
+  select {
+  case <-sub.done:
+    t.Fatal("dropped before the buffer was full")
+  default:
+  }
+
+  n.broadcast(Notification{ID: subscriberMaxBuffer + 1, Type: "lightning"})
+  select {
+  case <-sub.done:
+  default:
+    t.Fatal("slow subscriber not dropped")
+  }
+  if len(n.subscriberStatuses()) != 0 {
+    t.Fatal("dropped subscriber still listed")
+  }
+}
+
+func TestNotificationsStreamFiltersAndIDs(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  s.notifier = NewNotifier(nil, fake, log.New(io.Discard, "", 0))
+  srv := httptest.NewServer(s.routes())
+  defer srv.Close()
+
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/notifications/stream?client=test-ui&types=lightning", nil)
+  resp, err := http.DefaultClient.Do(req)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer resp.Body.Close()
+  reader := bufio.NewReader(resp.Body)
+  readEvent := func() []string {
+    var lines []string
+    for {
+      line, err := reader.ReadString('\n')
+      if err != nil {
+        t.Fatalf("read: %v", err)
+      }
+      line = strings.TrimRight(line, "\n")
+      if line == "" {
+        return lines
+      }
+      lines = append(lines, line)
+    }
+  }
+  if ev := readEvent(); len(ev) == 0 || ev[0] != "event: ready" {
+    t.Fatalf("expected ready event, got %v", ev)
+  }
+
+  rec := httptest.NewRecorder()
+  s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/notifications/subscribers", nil))
+  var subs struct {
+    Items []subscriberStatus `json:"items"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &subs); err != nil || len(subs.Items) != 1 || subs.Items[0].Client != "test-ui" {
+    t.Fatalf("unexpected subscribers (%v): %s", err, rec.Body.String())
+  }
+
+  s.notifier.broadcast(Notification{ID: 41, Type: "onchain", AmountSat: 5000})
+  s.notifier.broadcast(Notification{ID: 42, Type: "lightning", AmountSat: 100})
+  ev := readEvent()
+  if len(ev) != 2 || ev[0] != "id: 42" || !strings.Contains(ev[1], `"id":42`) {
+    t.Fatalf("unexpected event: %v", ev)
+  }
+}
+
+func TestNotificationsStreamRejectsBadResumeID(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  s.notifier = NewNotifier(nil, fake, log.New(io.Discard, "", 0))
+  req := httptest.NewRequest(http.MethodGet, "/api/notifications/stream", nil)
+  req.Header.Set("Last-Event-ID", "abc")
+  rec := httptest.NewRecorder()
+  s.routes().ServeHTTP(rec, req)
+  if rec.Code != http.StatusBadRequest {
+    t.Fatalf("status = %d", rec.Code)
+  }
+}
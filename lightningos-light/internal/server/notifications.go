@@ -17,6 +17,7 @@ import (
   "sync"
   "time"
 
+  "lightningos-light/internal/config"
   "lightningos-light/internal/lndclient"
   "lightningos-light/lnrpc"
 
@@ -31,6 +32,9 @@ const (
   paymentsPollInterval = 15 * time.Second
   forwardsPollInterval = 30 * time.Second
   pendingChannelsPollInterval = 30 * time.Second
+  // pollMaxLag is how long a poll loop may go without a successful round
+  // before the watchdog restarts it.
+  pollMaxLag = 5 * time.Minute
   paymentsPendingMaxAge = 48 * time.Hour
 )
 
@@ -60,6 +64,13 @@ type Notification struct {
   Txid string `json:"txid,omitempty"`
   PaymentHash string `json:"payment_hash,omitempty"`
   Memo string `json:"memo,omitempty"`
+  EventKey string `json:"event_key,omitempty"`
+  Replayed bool `json:"replayed,omitempty"`
+  // Muted is set on a fresh event a rule matched: it is stored, but only
+  // subscribers that ask for muted events get it.
+  Muted bool `json:"muted,omitempty"`
+  // Note is the operator's annotation, set on list responses.
+  Note string `json:"note,omitempty"`
 }
 
 type rebalanceRouteInfo struct {
@@ -77,56 +88,93 @@ type pendingPaymentEntry struct {
 }
 
 type Notifier struct {
+  // ctx is the server's root context, set by Start.
+  ctx context.Context
+  nodeID string
   db *pgxpool.Pool
-  lnd *lndclient.Client
+  lnd lndclient.API
   logger *log.Logger
 
   mu sync.Mutex
   backupMu sync.Mutex
   pendingMu sync.Mutex
-  subscribers map[chan Notification]struct{}
+  pushMu sync.Mutex
+  subscribers map[*notificationSubscriber]struct{}
+  nextSubscriber uint64
   started bool
   stop chan struct{}
   lastCleanup time.Time
   backupSent map[string]time.Time
   pendingSent map[string]time.Time
+  pushSent map[string]time.Time
+
+  rulesMu sync.RWMutex
+  rules []NotificationRule
+  prefsMu sync.RWMutex
+  prefs UserPreferences
+  importer historyImporter
+  watchdog *pollerWatchdog
+}
+
+func NewNotifier(db *pgxpool.Pool, lnd lndclient.API, logger *log.Logger) *Notifier {
+  return NewNodeNotifier(config.DefaultNodeID, db, lnd, logger)
 }
 
-func NewNotifier(db *pgxpool.Pool, lnd *lndclient.Client, logger *log.Logger) *Notifier {
+// NewNodeNotifier creates a notifier whose events and cursors are scoped to nodeID.
+func NewNodeNotifier(nodeID string, db *pgxpool.Pool, lnd lndclient.API, logger *log.Logger) *Notifier {
   return &Notifier{
+    nodeID: nodeID,
     db: db,
     lnd: lnd,
     logger: logger,
-    subscribers: map[chan Notification]struct{}{},
+    subscribers: map[*notificationSubscriber]struct{}{},
     backupSent: map[string]time.Time{},
     pendingSent: map[string]time.Time{},
+    watchdog: newPollerWatchdog(),
   }
 }
 
-func (n *Notifier) Start() {
+// Start runs the pollers until ctx ends.
+func (n *Notifier) Start(ctx context.Context) {
   n.mu.Lock()
   if n.started {
     n.mu.Unlock()
     return
   }
   n.started = true
+  n.ctx = ctx
   n.stop = make(chan struct{})
   n.mu.Unlock()
+  go func() {
+    <-ctx.Done()
+    close(n.stop)
+  }()
 
-  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+  ctx, cancel := context.WithTimeout(n.context(), 10*time.Second)
   if err := n.ensureSchema(ctx); err != nil {
     n.logger.Printf("notifications disabled: failed to init schema: %v", err)
     cancel()
     return
   }
+  if err := n.loadRules(ctx); err != nil {
+    n.logger.Printf("notifications: failed to load rules: %v", err)
+  }
+  if err := n.loadPreferences(ctx); err != nil {
+    n.logger.Printf("notifications: failed to load preferences: %v", err)
+  }
   cancel()
 
-  go n.runInvoices()
-  go n.runPayments()
-  go n.runTransactions()
-  go n.runChannels()
-  go n.runPendingChannels()
-  go n.runForwards()
+  goSafe("notifier/"+n.nodeID+"/invoices", n.runInvoices)
+  goSafe("notifier/"+n.nodeID+"/payments", n.runPayments)
+  goSafe("notifier/"+n.nodeID+"/transactions", n.runTransactions)
+  goSafe("notifier/"+n.nodeID+"/channels", n.runChannels)
+  goSafe("notifier/"+n.nodeID+"/pending_channels", n.runPendingChannels)
+  goSafe("notifier/"+n.nodeID+"/forwards", n.runForwards)
+  goSafe("notifier/"+n.nodeID+"/htlc_failures", n.runHTLCFailures)
+  goSafe("notifier/"+n.nodeID+"/splices", n.runSplices)
+  goSafe("notifier/"+n.nodeID+"/report_anomalies", n.runReportAnomalies)
+  goSafe("notifier/"+n.nodeID+"/config_drift", n.runConfigDrift)
+  goSafe("notifier/"+n.nodeID+"/watchdog", n.runWatchdog)
 }
 
 func bootstrapNotificationsDSN(logger *log.Logger) (string, error) {
@@ -210,23 +258,6 @@ func bootstrapNotificationsDSN(logger *log.Logger) (string, error) {
   return dsn, nil
 }
 
-func (n *Notifier) Subscribe() chan Notification {
-  ch := make(chan Notification, 50)
-  n.mu.Lock()
-  n.subscribers[ch] = struct{}{}
-  n.mu.Unlock()
-  return ch
-}
-
-func (n *Notifier) Unsubscribe(ch chan Notification) {
-  n.mu.Lock()
-  if _, ok := n.subscribers[ch]; ok {
-    delete(n.subscribers, ch)
-    close(ch)
-  }
-  n.mu.Unlock()
-}
-
 func readEnvFileValue(path, key string) (string, error) {
   data, err := os.ReadFile(path)
   if err != nil {
@@ -244,6 +275,8 @@ func readEnvFileValue(path, key string) (string, error) {
 }
 
 func writeEnvFileValue(path, key, value string) error {
+  unlock := lockConfigFile(path)
+  defer unlock()
   data, err := os.ReadFile(path)
   if err != nil && !errors.Is(err, os.ErrNotExist) {
     return err
@@ -264,7 +297,7 @@ func writeEnvFileValue(path, key, value string) error {
     lines = append(lines, fmt.Sprintf("%s=%s", key, value))
   }
   output := strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
-  return os.WriteFile(path, []byte(output), 0o660)
+  return writeFileAtomic(path, []byte(output), 0o660)
 }
 
 func randomPassword(length int) (string, error) {
@@ -351,15 +384,29 @@ func isPlaceholderDSN(dsn string) bool {
   return strings.Contains(dsn, "CHANGE_ME")
 }
 
-func (n *Notifier) broadcast(evt Notification) {
-  n.mu.Lock()
-  defer n.mu.Unlock()
-  for ch := range n.subscribers {
-    select {
-    case ch <- evt:
-    default:
-    }
+// context is the parent for the notifier's work: the root context once
+// started, Background before.
+func (n *Notifier) context() context.Context {
+  if n.ctx == nil {
+    return context.Background()
+  }
+  return n.ctx
+}
+
+func (n *Notifier) nodeKey() string {
+  if n.nodeID == "" {
+    return config.DefaultNodeID
   }
+  return n.nodeID
+}
+
+// scopedKey prefixes event and cursor keys for secondary nodes so they never
+// collide with the primary node's rows.
+func (n *Notifier) scopedKey(key string) string {
+  if n.nodeKey() == config.DefaultNodeID {
+    return key
+  }
+  return n.nodeKey() + "/" + key
 }
 
 func (n *Notifier) ensureSchema(ctx context.Context) error {
@@ -390,10 +437,13 @@ create table if not exists notifications (
 );
 
 alter table notifications add column if not exists fee_msat bigint not null default 0;
+alter table notifications add column if not exists node_id text not null default 'default';
+alter table notifications add column if not exists muted boolean not null default false;
 
 create index if not exists notifications_occurred_at_idx on notifications (occurred_at desc);
 create index if not exists notifications_type_idx on notifications (type);
 create index if not exists notifications_payment_hash_idx on notifications (payment_hash);
+create index if not exists notifications_node_id_idx on notifications (node_id);
 
 create table if not exists notification_cursors (
   key text primary key,
@@ -401,19 +451,38 @@ create table if not exists notification_cursors (
   updated_at timestamptz not null default now()
 );
 `)
-  return err
+  if err != nil {
+    return err
+  }
+  if err := n.ensurePreferencesSchema(ctx); err != nil {
+    return err
+  }
+  if err := n.ensureHTLCFailuresSchema(ctx); err != nil {
+    return err
+  }
+  if err := n.ensurePOSSchema(ctx); err != nil {
+    return err
+  }
+  if err := n.ensureJournalSchema(ctx); err != nil {
+    return err
+  }
+  return n.ensureRulesSchema(ctx)
 }
 
 func (n *Notifier) upsertNotification(ctx context.Context, eventKey string, evt Notification) (Notification, error) {
   if eventKey == "" {
     return Notification{}, errors.New("event key required")
   }
+  eventKey = n.scopedKey(eventKey)
+  // A muted event is still stored, so reports, fee previews and alerts that
+  // read the notifications see it; only its delivery is held back.
+  muted := n.isMuted(evt)
 
   row := n.db.QueryRow(ctx, `
 insert into notifications (
   event_key, occurred_at, type, action, direction, status, amount_sat, fee_sat, fee_msat,
-  peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo
-) values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+  peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo, node_id, muted
+) values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18)
 on conflict (event_key) do update set
   occurred_at = excluded.occurred_at,
   type = excluded.type,
@@ -429,13 +498,14 @@ on conflict (event_key) do update set
   channel_point = excluded.channel_point,
   txid = excluded.txid,
   payment_hash = excluded.payment_hash,
-  memo = excluded.memo
+  memo = excluded.memo,
+  muted = excluded.muted
 returning id, occurred_at, type, action, direction, status, amount_sat, fee_sat,
-  fee_msat, peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo
+  fee_msat, peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo, event_key
 `, eventKey, evt.OccurredAt, evt.Type, evt.Action, evt.Direction, evt.Status,
     evt.AmountSat, evt.FeeSat, evt.FeeMsat, nullableString(evt.PeerPubkey), nullableString(evt.PeerAlias),
     nullableInt(evt.ChannelID), nullableString(evt.ChannelPoint), nullableString(evt.Txid),
-    nullableString(evt.PaymentHash), nullableString(evt.Memo),
+    nullableString(evt.PaymentHash), nullableString(evt.Memo), n.nodeKey(), muted,
   )
 
   var stored Notification
@@ -443,9 +513,13 @@ returning id, occurred_at, type, action, direction, status, amount_sat, fee_sat,
   if err != nil {
     return Notification{}, err
   }
+  stored.Muted = muted
 
   n.cleanupIfNeeded()
   n.broadcast(stored)
+  if !muted {
+    n.triggerPush(stored)
+  }
   return stored, nil
 }
 
@@ -462,10 +536,11 @@ func (n *Notifier) list(ctx context.Context, limit int) ([]Notification, error)
 
   rows, err := n.db.Query(ctx, `
 select id, occurred_at, type, action, direction, status, amount_sat, fee_sat, fee_msat,
-  peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo
+  peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo, event_key
 from notifications
+where node_id=$2 and not muted
 order by occurred_at desc, id desc
-limit $1`, limit)
+limit $1`, limit, n.nodeKey())
   if err != nil {
     return nil, err
   }
@@ -482,9 +557,42 @@ limit $1`, limit)
   return items, rows.Err()
 }
 
+// listSince returns up to limit notifications with an id above sinceID,
+// oldest first, so a client can resume exactly where it left off.
+func (n *Notifier) listSince(ctx context.Context, sinceID int64, limit int) ([]Notification, error) {
+  if n.db == nil {
+    return nil, errors.New("notifications disabled")
+  }
+  if limit <= 0 {
+    limit = 200
+  }
+
+  rows, err := n.db.Query(ctx, `
+select id, occurred_at, type, action, direction, status, amount_sat, fee_sat, fee_msat,
+  peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo, event_key
+from notifications
+where node_id=$3 and not muted and id > $1
+order by id asc
+limit $2`, sinceID, limit, n.nodeKey())
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  items := []Notification{}
+  for rows.Next() {
+    evt, err := scanNotification(rows)
+    if err != nil {
+      return nil, err
+    }
+    items = append(items, evt)
+  }
+  return items, rows.Err()
+}
+
 func (n *Notifier) getCursor(ctx context.Context, key string) (string, error) {
   var val string
-  err := n.db.QueryRow(ctx, "select value from notification_cursors where key=$1", key).Scan(&val)
+  err := n.db.QueryRow(ctx, "select value from notification_cursors where key=$1", n.scopedKey(key)).Scan(&val)
   if err == pgx.ErrNoRows {
     return "", nil
   }
@@ -496,7 +604,7 @@ func (n *Notifier) setCursor(ctx context.Context, key, val string) error {
 insert into notification_cursors (key, value, updated_at)
 values ($1, $2, now())
 on conflict (key) do update set value=excluded.value, updated_at=excluded.updated_at
-`, key, val)
+`, n.scopedKey(key), val)
   return err
 }
 
@@ -603,7 +711,7 @@ order by occurred_at desc limit 1`, normalized).Scan(&payID, &payFee, &payFeeMsa
     payFeeMsat = payFee * 1000
   }
   if payFeeMsat == 0 {
-    feeCtx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+    feeCtx, cancel := context.WithTimeout(n.context(), 4*time.Second)
     if pay, err := n.lookupPaymentByHash(feeCtx, normalized); err == nil && pay != nil {
       if feeMsat := paymentFeeMsat(pay); feeMsat != 0 {
         payFeeMsat = feeMsat
@@ -643,7 +751,7 @@ set type='rebalance',
   occurred_at=$6
 where id=$1
 returning id, occurred_at, type, action, direction, status, amount_sat, fee_sat,
-  fee_msat, peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo
+  fee_msat, peer_pubkey, peer_alias, channel_id, channel_point, txid, payment_hash, memo, event_key
 `, payID, invAmount, payFee, payFeeMsat, memoValue, invAt)
   updated, err := scanNotification(row)
   if err != nil {
@@ -660,6 +768,22 @@ returning id, occurred_at, type, action, direction, status, amount_sat, fee_sat,
   }
 
   n.broadcast(updated)
+  n.triggerPush(updated)
+}
+
+// waitLNDReady holds a poller while LND is locked or starting, so it does
+// not spin on errors. It returns false when the notifier stops.
+func (n *Notifier) waitLNDReady() bool {
+  ctx, cancel := context.WithCancel(n.context())
+  defer cancel()
+  go func() {
+    select {
+    case <-n.stop:
+      cancel()
+    case <-ctx.Done():
+    }
+  }()
+  return n.lnd.WaitReady(ctx) == nil
 }
 
 func (n *Notifier) cleanupIfNeeded() {
@@ -672,21 +796,26 @@ func (n *Notifier) cleanupIfNeeded() {
   n.lastCleanup = time.Now()
   n.mu.Unlock()
 
-  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+  ctx, cancel := context.WithTimeout(n.context(), 10*time.Second)
   defer cancel()
   cutoff := time.Now().AddDate(0, 0, -notificationRetentionDays)
   _, _ = n.db.Exec(ctx, "delete from notifications where occurred_at < $1", cutoff)
+  _, _ = n.db.Exec(ctx, "delete from htlc_failures where occurred_at < $1", cutoff)
 }
 
 func (n *Notifier) runInvoices() {
+  watch := n.watchdog.watch("invoices", streamMaxQuiet)
   for {
     select {
     case <-n.stop:
       return
     default:
     }
+    if !n.waitLNDReady() {
+      return
+    }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    ctx, cancel := context.WithTimeout(n.context(), 10*time.Second)
     cursorVal, _ := n.getCursor(ctx, "invoice_settle_index")
     cancel()
 
@@ -697,31 +826,40 @@ func (n *Notifier) runInvoices() {
       }
     }
 
-    conn, err := n.lnd.DialLightning(context.Background())
+    streamCtx, done := watch.begin(n.context())
+    conn, err := n.lnd.DialLightning(streamCtx)
     if err != nil {
       n.logger.Printf("notifications: invoice stream dial failed: %v", err)
+      watch.fail(err)
+      done()
       time.Sleep(5 * time.Second)
       continue
     }
 
     client := lnrpc.NewLightningClient(conn)
-    stream, err := client.SubscribeInvoices(context.Background(), &lnrpc.InvoiceSubscription{
+    stream, err := client.SubscribeInvoices(streamCtx, &lnrpc.InvoiceSubscription{
       SettleIndex: settleIndex,
     })
     if err != nil {
       n.logger.Printf("notifications: invoice stream subscribe failed: %v", err)
+      watch.fail(err)
       conn.Close()
+      done()
       time.Sleep(5 * time.Second)
       continue
     }
+    watch.beat()
 
     for {
       invoice, err := stream.Recv()
       if err != nil {
         n.logger.Printf("notifications: invoice stream ended: %v", err)
+        watch.fail(err)
         _ = conn.Close()
+        done()
         break
       }
+      watch.beat()
 
       if invoice.State != lnrpc.Invoice_SETTLED {
         continue
@@ -745,7 +883,7 @@ func (n *Notifier) runInvoices() {
       peerPubkey := ""
       peerAlias := ""
       memo := strings.TrimSpace(invoice.Memo)
-      ctxPeer, cancelPeer := context.WithTimeout(context.Background(), 4*time.Second)
+      ctxPeer, cancelPeer := context.WithTimeout(n.context(), 4*time.Second)
       peerPubkey, peerAlias = n.keysendPeerFromInvoice(ctxPeer, invoice)
       cancelPeer()
       if peerAlias == "" && peerPubkey != "" {
@@ -768,7 +906,7 @@ func (n *Notifier) runInvoices() {
         Memo: memo,
       }
 
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
       if n.isRebalanceHash(ctx, hash) {
         _ = n.setCursor(ctx, "invoice_settle_index", strconv.FormatUint(settleIndex, 10))
         cancel()
@@ -800,7 +938,7 @@ func (n *Notifier) runInvoices() {
 func (n *Notifier) runPayments() {
   pending := map[string]int64{}
   if n != nil {
-    ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+    ctx, cancel := context.WithTimeout(n.context(), 3*time.Second)
     pending = n.loadPendingPayments(ctx)
     cancel()
   }
@@ -835,7 +973,7 @@ func (n *Notifier) runPayments() {
     }
     isRebalance := false
     if !isKeysend {
-      ctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 6*time.Second)
       isRebalance = n.isSelfPayment(ctx, pay.PaymentRequest, pay)
       if !isRebalance && n.hasInvoiceHash(ctx, paymentHash) {
         isRebalance = true
@@ -854,7 +992,7 @@ func (n *Notifier) runPayments() {
     } else {
       trimmed := strings.TrimSpace(pay.PaymentRequest)
       if trimmed != "" {
-        ctxDecode, cancelDecode := context.WithTimeout(context.Background(), 4*time.Second)
+        ctxDecode, cancelDecode := context.WithTimeout(n.context(), 4*time.Second)
         if decoded, err := n.lnd.DecodeInvoice(ctxDecode, trimmed); err == nil {
           peerPubkey = strings.TrimSpace(decoded.Destination)
           memo = strings.TrimSpace(decoded.Memo)
@@ -893,7 +1031,7 @@ func (n *Notifier) runPayments() {
       Memo: memo,
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
     if isRebalance {
       evt = n.rebalanceEvent(ctx, pay, occurredAt)
     }
@@ -907,14 +1045,19 @@ func (n *Notifier) runPayments() {
     cancel()
   }
 
+  watch := n.watchdog.watch("payments", pollMaxLag)
   for {
     select {
     case <-n.stop:
       return
     case <-time.After(paymentsPollInterval):
     }
+    if !n.waitLNDReady() {
+      return
+    }
+    pollCtx, done := watch.begin(n.context())
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
     cursorVal, _ := n.getCursor(ctx, "payments_index")
     cancel()
 
@@ -925,14 +1068,16 @@ func (n *Notifier) runPayments() {
       }
     }
 
-    conn, err := n.lnd.DialLightning(context.Background())
+    conn, err := n.lnd.DialLightning(pollCtx)
     if err != nil {
       n.logger.Printf("notifications: payments poll dial failed: %v", err)
+      watch.fail(err)
+      done()
       continue
     }
 
     client := lnrpc.NewLightningClient(conn)
-    res, err := client.ListPayments(context.Background(), &lnrpc.ListPaymentsRequest{
+    res, err := client.ListPayments(pollCtx, &lnrpc.ListPaymentsRequest{
       IncludeIncomplete: true,
       IndexOffset: indexOffset,
       MaxPayments: 200,
@@ -941,6 +1086,8 @@ func (n *Notifier) runPayments() {
     _ = conn.Close()
     if err != nil {
       n.logger.Printf("notifications: payments poll failed: %v", err)
+      watch.fail(err)
+      done()
       continue
     }
 
@@ -988,7 +1135,7 @@ func (n *Notifier) runPayments() {
           pendingDirty = true
           continue
         }
-        ctxLookup, cancelLookup := context.WithTimeout(context.Background(), 6*time.Second)
+        ctxLookup, cancelLookup := context.WithTimeout(pollCtx, 6*time.Second)
         pay, err := n.lookupPaymentByHash(ctxLookup, hash)
         cancelLookup()
         if err != nil || pay == nil {
@@ -1014,49 +1161,64 @@ func (n *Notifier) runPayments() {
     }
 
     if maxIndex > indexOffset {
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
       _ = n.setCursor(ctx, "payments_index", strconv.FormatUint(maxIndex, 10))
       cancel()
     }
     if pendingDirty {
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
       n.storePendingPayments(ctx, pending)
       cancel()
     }
+    watch.beat()
+    done()
   }
 }
 
 func (n *Notifier) runTransactions() {
+  watch := n.watchdog.watch("transactions", streamMaxQuiet)
   for {
     select {
     case <-n.stop:
       return
     default:
     }
+    if !n.waitLNDReady() {
+      return
+    }
 
-    conn, err := n.lnd.DialLightning(context.Background())
+    streamCtx, done := watch.begin(n.context())
+    conn, err := n.lnd.DialLightning(streamCtx)
     if err != nil {
       n.logger.Printf("notifications: transaction stream dial failed: %v", err)
+      watch.fail(err)
+      done()
       time.Sleep(5 * time.Second)
       continue
     }
 
     client := lnrpc.NewLightningClient(conn)
-    stream, err := client.SubscribeTransactions(context.Background(), &lnrpc.GetTransactionsRequest{})
+    stream, err := client.SubscribeTransactions(streamCtx, &lnrpc.GetTransactionsRequest{})
     if err != nil {
       n.logger.Printf("notifications: transaction stream subscribe failed: %v", err)
+      watch.fail(err)
       conn.Close()
+      done()
       time.Sleep(5 * time.Second)
       continue
     }
+    watch.beat()
 
     for {
       tx, err := stream.Recv()
       if err != nil {
         n.logger.Printf("notifications: transaction stream ended: %v", err)
+        watch.fail(err)
         _ = conn.Close()
+        done()
         break
       }
+      watch.beat()
 
       amount := tx.Amount
       direction := "in"
@@ -1082,7 +1244,7 @@ func (n *Notifier) runTransactions() {
         Txid: tx.TxHash,
       }
 
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
       _, _ = n.upsertNotification(ctx, fmt.Sprintf("onchain:%s", tx.TxHash), evt)
       cancel()
     }
@@ -1092,36 +1254,49 @@ func (n *Notifier) runTransactions() {
 }
 
 func (n *Notifier) runChannels() {
+  watch := n.watchdog.watch("channels", streamMaxQuiet)
   for {
     select {
     case <-n.stop:
       return
     default:
     }
+    if !n.waitLNDReady() {
+      return
+    }
 
-    conn, err := n.lnd.DialLightning(context.Background())
+    streamCtx, done := watch.begin(n.context())
+    conn, err := n.lnd.DialLightning(streamCtx)
     if err != nil {
       n.logger.Printf("notifications: channel stream dial failed: %v", err)
+      watch.fail(err)
+      done()
       time.Sleep(5 * time.Second)
       continue
     }
 
     client := lnrpc.NewLightningClient(conn)
-    stream, err := client.SubscribeChannelEvents(context.Background(), &lnrpc.ChannelEventSubscription{})
+    stream, err := client.SubscribeChannelEvents(streamCtx, &lnrpc.ChannelEventSubscription{})
     if err != nil {
       n.logger.Printf("notifications: channel stream subscribe failed: %v", err)
+      watch.fail(err)
       conn.Close()
+      done()
       time.Sleep(5 * time.Second)
       continue
     }
+    watch.beat()
 
     for {
       update, err := stream.Recv()
       if err != nil {
         n.logger.Printf("notifications: channel stream ended: %v", err)
+        watch.fail(err)
         _ = conn.Close()
+        done()
         break
       }
+      watch.beat()
 
       evt, eventKey := n.channelEventToNotification(update)
       if eventKey == "" {
@@ -1130,7 +1305,7 @@ func (n *Notifier) runChannels() {
 
       n.maybeSendTelegramBackup(update)
 
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
       _, _ = n.upsertNotification(ctx, eventKey, evt)
       cancel()
     }
@@ -1146,8 +1321,11 @@ func (n *Notifier) runPendingChannels() {
       return
     case <-time.After(pendingChannelsPollInterval):
     }
+    if !n.waitLNDReady() {
+      return
+    }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
+    ctx, cancel := context.WithTimeout(n.context(), 6*time.Second)
     pending, err := n.lnd.ListPendingChannels(ctx)
     cancel()
     if err != nil {
@@ -1218,7 +1396,7 @@ func (n *Notifier) notifyPendingChannelClosing(item lndclient.PendingChannelInfo
     evt.PeerAlias = n.lookupNodeAlias(evt.PeerPubkey)
   }
 
-  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
   _, _ = n.upsertNotification(ctx, eventKey, evt)
   cancel()
 }
@@ -1339,7 +1517,7 @@ func (n *Notifier) channelEventToNotification(update *lnrpc.ChannelEventUpdate)
 }
 
 func (n *Notifier) lookupPendingChannel(channelPoint string, txid string) *lndclient.PendingChannelInfo {
-  ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+  ctx, cancel := context.WithTimeout(n.context(), 4*time.Second)
   defer cancel()
 
   pending, err := n.lnd.ListPendingChannels(ctx)
@@ -1395,7 +1573,7 @@ func (n *Notifier) lookupNodeAlias(pubkey string) string {
     return ""
   }
 
-  ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+  ctx, cancel := context.WithTimeout(n.context(), 4*time.Second)
   defer cancel()
 
   conn, err := n.lnd.DialLightning(ctx)
@@ -1418,14 +1596,19 @@ func (n *Notifier) runForwards() {
   backfill := strings.EqualFold(strings.TrimSpace(os.Getenv("NOTIFICATIONS_FORWARDS_BACKFILL")), "1") ||
     strings.EqualFold(strings.TrimSpace(os.Getenv("NOTIFICATIONS_FORWARDS_BACKFILL")), "true")
 
+  watch := n.watchdog.watch("forwards", pollMaxLag)
   for {
     select {
     case <-n.stop:
       return
     case <-time.After(forwardsPollInterval):
     }
+    if !n.waitLNDReady() {
+      return
+    }
+    pollCtx, done := watch.begin(n.context())
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
     cursorVal, _ := n.getCursor(ctx, "forwards_after")
     cancel()
 
@@ -1436,7 +1619,7 @@ func (n *Notifier) runForwards() {
       }
     }
     if after == 0 && !backfill {
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
       if latest, ok := n.latestForwardOccurredAt(ctx); ok {
         latestUnix := latest.Unix()
         now := time.Now().UTC().Unix()
@@ -1463,9 +1646,11 @@ func (n *Notifier) runForwards() {
       n.logger.Printf("notifications: forwards poll start (after=%d backfill=%t)", after, backfill)
     }
 
-    conn, err := n.lnd.DialLightning(context.Background())
+    conn, err := n.lnd.DialLightning(pollCtx)
     if err != nil {
       n.logger.Printf("notifications: forwards poll dial failed: %v", err)
+      watch.fail(err)
+      done()
       continue
     }
 
@@ -1474,7 +1659,7 @@ func (n *Notifier) runForwards() {
     if after > endTime+300 {
       n.logger.Printf("notifications: forwards cursor ahead of time (after=%d end=%d), resetting", after, endTime)
       after = 0
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
       _ = n.setCursor(ctx, "forwards_after", "0")
       cancel()
     }
@@ -1484,8 +1669,9 @@ func (n *Notifier) runForwards() {
 
     var indexOffset uint32
     processed := false
+    var pollErr error
     for {
-      reqCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+      reqCtx, cancel := context.WithTimeout(pollCtx, 10*time.Second)
       res, err := client.ForwardingHistory(reqCtx, &lnrpc.ForwardingHistoryRequest{
         StartTime: after,
         EndTime: endTime,
@@ -1496,6 +1682,7 @@ func (n *Notifier) runForwards() {
       cancel()
       if err != nil {
         n.logger.Printf("notifications: forwards poll failed: %v", err)
+        pollErr = err
         break
       }
       if debug {
@@ -1527,7 +1714,7 @@ func (n *Notifier) runForwards() {
           ChannelID: int64(fwd.ChanIdOut),
         }
         eventKey := fmt.Sprintf("forward:%d:%d:%d", fwd.IncomingHtlcId, fwd.OutgoingHtlcId, tsKey)
-        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+        ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
         _, _ = n.upsertNotification(ctx, eventKey, evt)
         cancel()
       }
@@ -1544,10 +1731,16 @@ func (n *Notifier) runForwards() {
     _ = conn.Close()
 
     if processed || after == 0 {
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
       _ = n.setCursor(ctx, "forwards_after", strconv.FormatUint(endTime, 10))
       cancel()
     }
+    if pollErr != nil {
+      watch.fail(pollErr)
+    } else {
+      watch.beat()
+    }
+    done()
   }
 }
 
@@ -1591,9 +1784,9 @@ func (n *Notifier) latestForwardOccurredAt(ctx context.Context) (time.Time, bool
   var occurredAt time.Time
   err := n.db.QueryRow(ctx, `
 select occurred_at from notifications
-where type='forward'
+where type='forward' and node_id=$1
 order by occurred_at desc
-limit 1`).Scan(&occurredAt)
+limit 1`, n.nodeKey()).Scan(&occurredAt)
   if err == pgx.ErrNoRows {
     return time.Time{}, false
   }
@@ -2084,6 +2277,7 @@ func scanNotification(scanner notificationRowScanner) (Notification, error) {
     &txid,
     &paymentHash,
     &memo,
+    &evt.EventKey,
   )
   if err != nil {
     return Notification{}, err
@@ -2113,7 +2307,8 @@ func scanNotification(scanner notificationRowScanner) (Notification, error) {
 }
 
 func (s *Server) handleNotificationsList(w http.ResponseWriter, r *http.Request) {
-  if s.notifier == nil {
+  notifier := s.notifierFor(r)
+  if notifier == nil {
     msg := strings.TrimSpace(s.notifierErr)
     if msg == "" {
       msg = "notifications disabled"
@@ -2129,62 +2324,51 @@ func (s *Server) handleNotificationsList(w http.ResponseWriter, r *http.Request)
     }
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutShort)
   defer cancel()
 
-  items, err := s.notifier.list(ctx, limit)
+  if raw := r.URL.Query().Get("since_id"); raw != "" {
+    sinceID, err := strconv.ParseInt(raw, 10, 64)
+    if err != nil || sinceID < 0 {
+      writeError(w, http.StatusBadRequest, "invalid since_id")
+      return
+    }
+    s.writeNotificationsSince(ctx, w, notifier, sinceID, limit)
+    return
+  }
+
+  items, err := notifier.list(ctx, limit)
   if err != nil {
     s.logger.Printf("notifications: list failed: %v", err)
     writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load notifications: %v", err))
     return
   }
+  notifier.attachAnnotations(ctx, items)
 
   writeJSON(w, http.StatusOK, map[string]any{"items": items})
 }
 
-func (s *Server) handleNotificationsStream(w http.ResponseWriter, r *http.Request) {
-  if s.notifier == nil {
-    msg := strings.TrimSpace(s.notifierErr)
-    if msg == "" {
-      msg = "notifications disabled"
-    }
-    writeError(w, http.StatusServiceUnavailable, msg)
-    return
+// writeNotificationsSince answers a catch-up request. has_more tells the
+// client to ask again with since_id=last_id.
+func (s *Server) writeNotificationsSince(ctx context.Context, w http.ResponseWriter, notifier *Notifier, sinceID int64, limit int) {
+  if limit <= 0 || limit > 1000 {
+    limit = 1000
   }
-
-  flusher, ok := w.(http.Flusher)
-  if !ok {
-    writeError(w, http.StatusInternalServerError, "stream not supported")
+  items, err := notifier.listSince(ctx, sinceID, limit+1)
+  if err != nil {
+    s.logger.Printf("notifications: list since %d failed: %v", sinceID, err)
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load notifications: %v", err))
     return
   }
-
-  w.Header().Set("Content-Type", "text/event-stream")
-  w.Header().Set("Cache-Control", "no-cache")
-  w.Header().Set("Connection", "keep-alive")
-
-  ch := s.notifier.Subscribe()
-  defer s.notifier.Unsubscribe(ch)
-
-  _, _ = w.Write([]byte("event: ready\ndata: {}\n\n"))
-  flusher.Flush()
-
-  ticker := time.NewTicker(25 * time.Second)
-  defer ticker.Stop()
-
-  for {
-    select {
-    case <-r.Context().Done():
-      return
-    case evt := <-ch:
-      payload, err := json.Marshal(evt)
-      if err != nil {
-        continue
-      }
-      _, _ = fmt.Fprintf(w, "data: %s\n\n", payload)
-      flusher.Flush()
-    case <-ticker.C:
-      _, _ = w.Write([]byte("event: heartbeat\ndata: {}\n\n"))
-      flusher.Flush()
-    }
+  hasMore := len(items) > limit
+  if hasMore {
+    items = items[:limit]
+  }
+  notifier.attachAnnotations(ctx, items)
+  lastID := sinceID
+  if len(items) > 0 {
+    lastID = items[len(items)-1].ID
   }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items, "last_id": lastID, "has_more": hasMore})
 }
+
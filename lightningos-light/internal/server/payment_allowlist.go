@@ -0,0 +1,243 @@
+package server
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/go-chi/chi/v5"
+)
+
+// The payment allowlist limits where automation may send funds: scheduled
+// payments, and pay or send requests authenticated by a client certificate
+// (scripts and integrations). Payments made by the admin are not limited.
+// With an empty list automation cannot pay anyone, so a leaked client
+// certificate cannot drain the node to an address of its choosing.
+const (
+  maxAllowedDestinations = 200
+  maxAllowedDestinationLabel = 60
+
+  allowKindLightningAddress = "lightning_address"
+  allowKindNode = "node"
+  allowKindOnchain = "onchain"
+)
+
+type allowedDestination struct {
+  ID string `json:"id"`
+  Kind string `json:"kind"`
+  Value string `json:"value"`
+  Label string `json:"label,omitempty"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+  paymentAllowlistPath = "/var/lib/lightningos/payment-allowlist.json"
+  paymentAllowlistMu sync.Mutex
+)
+
+func loadPaymentAllowlist() ([]allowedDestination, error) {
+  raw, err := os.ReadFile(paymentAllowlistPath)
+  if errors.Is(err, os.ErrNotExist) {
+    return []allowedDestination{}, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  items := []allowedDestination{}
+  if err := json.Unmarshal(raw, &items); err != nil {
+    return nil, fmt.Errorf("invalid %s: %w", paymentAllowlistPath, err)
+  }
+  return items, nil
+}
+
+func savePaymentAllowlist(items []allowedDestination) error {
+  if err := os.MkdirAll(filepath.Dir(paymentAllowlistPath), 0o750); err != nil {
+    return err
+  }
+  data, err := json.MarshalIndent(items, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(paymentAllowlistPath, data, 0o600)
+}
+
+// normalizeAllowedDestination returns value in the form payments are matched
+// against.
+func normalizeAllowedDestination(kind string, value string) (string, error) {
+  value = strings.TrimSpace(value)
+  switch kind {
+  case allowKindLightningAddress:
+    value = strings.ToLower(value)
+    value = strings.TrimPrefix(value, "lightning:")
+    if !isLightningAddress(value) {
+      return "", errors.New("invalid lightning address")
+    }
+  case allowKindNode:
+    value = strings.ToLower(value)
+    if !isValidPubkeyHex(value) {
+      return "", errors.New("invalid pubkey")
+    }
+  case allowKindOnchain:
+    value = strings.TrimPrefix(value, "bitcoin:")
+    if len(value) < 14 || len(value) > 90 || strings.IndexFunc(value, func(r rune) bool {
+      return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+    }) >= 0 {
+      return "", errors.New("invalid address")
+    }
+    // Bech32 addresses are case-insensitive; base58 ones are not.
+    lower := strings.ToLower(value)
+    if strings.HasPrefix(lower, "bc1") || strings.HasPrefix(lower, "tb1") || strings.HasPrefix(lower, "bcrt1") {
+      value = lower
+    }
+  default:
+    return "", errors.New("kind must be lightning_address, node or onchain")
+  }
+  return value, nil
+}
+
+func destinationAllowed(items []allowedDestination, kind string, value string) bool {
+  value, err := normalizeAllowedDestination(kind, value)
+  if err != nil {
+    return false
+  }
+  for _, item := range items {
+    if item.Kind == kind && item.Value == value {
+      return true
+    }
+  }
+  return false
+}
+
+// checkAutomatedDestination fails unless the allowlist has kind/value. The
+// list is read on every check, so removing an entry stops payments to it
+// right away.
+func checkAutomatedDestination(kind string, value string) error {
+  paymentAllowlistMu.Lock()
+  items, err := loadPaymentAllowlist()
+  paymentAllowlistMu.Unlock()
+  if err != nil {
+    return fmt.Errorf("payment allowlist unavailable: %w", err)
+  }
+  if !destinationAllowed(items, kind, value) {
+    return fmt.Errorf("destination not in payment allowlist: %s", value)
+  }
+  return nil
+}
+
+// checkAutomatedCloseAddress applies the allowlist (kind onchain) to the
+// address a channel's funds go to on close, when automation asks for one.
+// Without an address they go to the node's own wallet.
+func checkAutomatedCloseAddress(r *http.Request, address string) error {
+  if address == "" || !isAutomatedRequest(r) {
+    return nil
+  }
+  return checkAutomatedDestination(allowKindOnchain, address)
+}
+
+// isAutomatedRequest tells requests from scripts and integrations, which
+// sign in with a client certificate, from the admin's.
+func isAutomatedRequest(r *http.Request) bool {
+  _, ok := clientCertName(r)
+  return ok
+}
+
+func (s *Server) handlePaymentAllowlistList(w http.ResponseWriter, r *http.Request) {
+  paymentAllowlistMu.Lock()
+  items, err := loadPaymentAllowlist()
+  paymentAllowlistMu.Unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load payment allowlist: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Server) handlePaymentAllowlistAdd(w http.ResponseWriter, r *http.Request) {
+  // Otherwise a leaked certificate could allow its own destination.
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "client certificates cannot change the payment allowlist")
+    return
+  }
+  var req allowedDestination
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  req.Kind = strings.TrimSpace(req.Kind)
+  value, err := normalizeAllowedDestination(req.Kind, req.Value)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  label := strings.TrimSpace(req.Label)
+  if len(label) > maxAllowedDestinationLabel {
+    writeError(w, http.StatusBadRequest, "label too long")
+    return
+  }
+
+  paymentAllowlistMu.Lock()
+  defer paymentAllowlistMu.Unlock()
+  items, err := loadPaymentAllowlist()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load payment allowlist: %v", err))
+    return
+  }
+  for _, item := range items {
+    if item.Kind == req.Kind && item.Value == value {
+      writeJSON(w, http.StatusOK, item)
+      return
+    }
+  }
+  if len(items) >= maxAllowedDestinations {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("too many allowed destinations: max %d", maxAllowedDestinations))
+    return
+  }
+  id, err := randomToken(8)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to save payment allowlist")
+    return
+  }
+  item := allowedDestination{ID: id, Kind: req.Kind, Value: value, Label: label, CreatedAt: time.Now().UTC()}
+  items = append(items, item)
+  if err := savePaymentAllowlist(items); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save payment allowlist: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) handlePaymentAllowlistDelete(w http.ResponseWriter, r *http.Request) {
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "client certificates cannot change the payment allowlist")
+    return
+  }
+  id := chi.URLParam(r, "id")
+  paymentAllowlistMu.Lock()
+  defer paymentAllowlistMu.Unlock()
+  items, err := loadPaymentAllowlist()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load payment allowlist: %v", err))
+    return
+  }
+  kept := items[:0]
+  for _, item := range items {
+    if item.ID != id {
+      kept = append(kept, item)
+    }
+  }
+  if len(kept) == len(items) {
+    writeError(w, http.StatusNotFound, "allowed destination not found")
+    return
+  }
+  if err := savePaymentAllowlist(kept); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save payment allowlist: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+  "net/http"
+  "strings"
+  "testing"
+)
+
+func TestDestinationAllowed(t *testing.T) {
+  pubkey := "03" + strings.Repeat("cd", 32)
+  items := []allowedDestination{
+    {Kind: allowKindLightningAddress, Value: "tips@example.com"},
+    {Kind: allowKindNode, Value: pubkey},
+    {Kind: allowKindOnchain, Value: "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"},
+  }
+  for _, tc := range []struct {
+    kind string
+    value string
+    want bool
+  }{
+    {allowKindLightningAddress, "lightning:Tips@Example.com", true},
+    {allowKindLightningAddress, "other@example.com", false},
+    {allowKindNode, strings.ToUpper(pubkey), true},
+    {allowKindNode, "02" + strings.Repeat("cd", 32), false},
+    {allowKindOnchain, "BC1QAR0SRRR7XFKVY5L643LYDNW9RE59GTZZWF5MDQ", true},
+    {allowKindOnchain, "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdx", false},
+    // The kind must match too: a pubkey entry does not allow an address.
+    {allowKindOnchain, pubkey, false},
+  } {
+    if got := destinationAllowed(items, tc.kind, tc.value); got != tc.want {
+      t.Fatalf("%s %s: got %v", tc.kind, tc.value, got)
+    }
+  }
+}
+
+func TestPaymentAllowlistAdd(t *testing.T) {
+  saved := paymentAllowlistPath
+  paymentAllowlistPath = t.TempDir() + "/payment-allowlist.json"
+  t.Cleanup(func() { paymentAllowlistPath = saved })
+  s, _ := newFakeLNDServer(t)
+
+  rec := serveAPI(s, http.MethodPost, "/api/payments/allowlist", `{"kind":"node","value":"02ab"}`)
+  if rec.Code != http.StatusBadRequest {
+    t.Fatalf("bad pubkey: status = %d", rec.Code)
+  }
+  body := `{"kind":"lightning_address","value":"Tips@Example.com","label":"donations"}`
+  for i := 0; i < 2; i++ {
+    if rec := serveAPI(s, http.MethodPost, "/api/payments/allowlist", body); rec.Code != http.StatusOK {
+      t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+    }
+  }
+  items, err := loadPaymentAllowlist()
+  if err != nil || len(items) != 1 || items[0].Value != "tips@example.com" {
+    t.Fatalf("items %+v: %v", items, err)
+  }
+  if err := checkAutomatedDestination(allowKindLightningAddress, "tips@example.com"); err != nil {
+    t.Fatal(err)
+  }
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+  "encoding/base64"
+  "fmt"
+  "net/http"
+  "net/url"
+  "strconv"
+  "strings"
+
+  "lightningos-light/internal/qrcode"
+)
+
+const (
+  paymentRequestDefaultExpiry = 3600
+  paymentRequestMaxExpiry = 7 * 24 * 3600
+  // paymentRequestMaxMemo keeps the memo inside a BOLT11 description.
+  paymentRequestMaxMemo = 639
+  qrBorder = 4
+  qrPNGScale = 8
+)
+
+// bip21URI builds a unified payment string: an on-chain address with the
+// invoice in the lightning parameter, so any wallet can pay it one way or
+// the other.
+func bip21URI(address string, amountSat int64, memo string, invoice string) string {
+  params := []string{}
+  if amountSat > 0 {
+    params = append(params, "amount="+formatBTC(amountSat))
+  }
+  if memo != "" {
+    params = append(params, "message="+strings.ReplaceAll(url.QueryEscape(memo), "+", "%20"))
+  }
+  if invoice != "" {
+    params = append(params, "lightning="+invoice)
+  }
+  uri := "bitcoin:" + address
+  if len(params) > 0 {
+    uri += "?" + strings.Join(params, "&")
+  }
+  return uri
+}
+
+// formatBTC renders sats as a BIP21 amount: decimal BTC without trailing
+// zeros.
+func formatBTC(sats int64) string {
+  whole := strconv.FormatInt(sats/100000000, 10)
+  frac := strings.TrimRight(fmt.Sprintf("%08d", sats%100000000), "0")
+  if frac == "" {
+    return whole
+  }
+  return whole + "." + frac
+}
+
+// renderQR returns the QR code of text as a data URI, ready for an img tag.
+func renderQR(text string, format string) (string, error) {
+  code, err := qrcode.Encode([]byte(text), qrcode.Medium)
+  if err != nil {
+    return "", err
+  }
+  if format == "png" {
+    data, err := code.PNG(qrPNGScale, qrBorder)
+    if err != nil {
+      return "", err
+    }
+    return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+  }
+  return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(code.SVG(qrBorder))), nil
+}
+
+// handleWalletPaymentRequest creates an on-chain address and an invoice for
+// the same amount and returns them as one BIP21 URI with its QR code, so a
+// point of sale shows a single code. Nothing is returned unless both were
+// created; a leftover address or unpaid invoice costs nothing.
+func (s *Server) handleWalletPaymentRequest(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    AmountSat int64 `json:"amount_sat"`
+    Memo string `json:"memo"`
+    ExpirySeconds int64 `json:"expiry_seconds"`
+    Format string `json:"format"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  req.Memo = strings.TrimSpace(req.Memo)
+  req.Format = strings.ToLower(strings.TrimSpace(req.Format))
+  if req.AmountSat <= 0 {
+    writeError(w, http.StatusBadRequest, "amount_sat must be positive")
+    return
+  }
+  if len(req.Memo) > paymentRequestMaxMemo {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("memo too long: max %d bytes", paymentRequestMaxMemo))
+    return
+  }
+  if req.ExpirySeconds == 0 {
+    req.ExpirySeconds = paymentRequestDefaultExpiry
+  }
+  if req.ExpirySeconds < 60 || req.ExpirySeconds > paymentRequestMaxExpiry {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("expiry_seconds out of range: 60 to %d", paymentRequestMaxExpiry))
+    return
+  }
+  if req.Format == "" {
+    req.Format = "svg"
+  }
+  if req.Format != "svg" && req.Format != "png" {
+    writeError(w, http.StatusBadRequest, "format must be svg or png")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  lnd := s.lndFor(r)
+
+  address, err := lnd.NewAddress(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
+    return
+  }
+  invoice, err := lnd.CreateInvoice(ctx, req.AmountSat, req.Memo, req.ExpirySeconds)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "invoice failed")
+    return
+  }
+  if invoice.PaymentHash != "" {
+    s.recordWalletActivity(invoice.PaymentHash)
+  }
+
+  uri := bip21URI(address, req.AmountSat, req.Memo, invoice.PaymentRequest)
+  qr, err := renderQR(uri, req.Format)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to render qr code")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "address": address,
+    "payment_request": invoice.PaymentRequest,
+    "payment_hash": invoice.PaymentHash,
+    "amount_sat": req.AmountSat,
+    "expiry_seconds": req.ExpirySeconds,
+    "uri": uri,
+    "qr_format": req.Format,
+    "qr": qr,
+  })
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+  "encoding/base64"
+  "encoding/json"
+  "errors"
+  "net/http"
+  "strings"
+  "testing"
+)
+
+func TestBIP21URI(t *testing.T) {
+  got := bip21URI("bc1qexample", 123450, "coffee & cake", "lnbc1234")
+  want := "bitcoin:bc1qexample?amount=0.0012345&message=coffee%20%26%20cake&lightning=lnbc1234"
+  if got != want {
+    t.Fatalf("got %s", got)
+  }
+  if got := formatBTC(200000000); got != "2" {
+    t.Fatalf("whole btc %s", got)
+  }
+  if got := bip21URI("bc1qexample", 0, "", ""); got != "bitcoin:bc1qexample" {
+    t.Fatalf("bare uri %s", got)
+  }
+}
+
+func TestWalletPaymentRequest(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  saved := walletActivityPath
+  walletActivityPath = t.TempDir() + "/wallet-activity.json"
+  t.Cleanup(func() { walletActivityPath = saved })
+  fake.Addresses = []string{"bc1qshop"}
+
+  rec := serveAPI(s, http.MethodPost, "/api/wallet/payment-request", `{"amount_sat":2100,"memo":"table 4","format":"png"}`)
+  if rec.Code != http.StatusOK {
+    t.Fatalf("payment request: %d %s", rec.Code, rec.Body.String())
+  }
+  var resp struct {
+    Address string `json:"address"`
+    PaymentRequest string `json:"payment_request"`
+    URI string `json:"uri"`
+    QR string `json:"qr"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+    t.Fatalf("decode: %v", err)
+  }
+  if resp.Address != "bc1qshop" || !strings.HasPrefix(resp.URI, "bitcoin:bc1qshop?amount=0.000021&message=table%204&lightning=lnbcfake") {
+    t.Fatalf("unexpected uri %s", resp.URI)
+  }
+  png, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(resp.QR, "data:image/png;base64,"))
+  if err != nil || !strings.HasPrefix(string(png), "\x89PNG") {
+    t.Fatalf("qr is not a png data uri: %.40s", resp.QR)
+  }
+
+  fake.Fail("CreateInvoice", errors.New("boom"))
+  if rec := serveAPI(s, http.MethodPost, "/api/wallet/payment-request", `{"amount_sat":2100}`); rec.Code != http.StatusInternalServerError {
+    t.Fatalf("failed invoice returned %d", rec.Code)
+  }
+  if rec := serveAPI(s, http.MethodPost, "/api/wallet/payment-request", `{"amount_sat":2100,"format":"gif"}`); rec.Code != http.StatusBadRequest {
+    t.Fatalf("gif accepted: %d", rec.Code)
+  }
+}
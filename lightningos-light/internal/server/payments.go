@@ -0,0 +1,346 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/lnrpc"
+)
+
+// GET /api/wallet/payments pages through LND's payments, newest first. The
+// cursor is a payment index: the next page holds the payments before it, so
+// new payments do not shift the pages. The status filter is applied here,
+// so a page scans as many of LND's pages as it takes to fill it.
+const (
+  paymentsDefaultLimit = 50
+  paymentsMaxLimit = 500
+  paymentsScanPageSize = 1000
+
+  paymentStatusSucceeded = "succeeded"
+  paymentStatusFailed = "failed"
+  paymentStatusInFlight = "in_flight"
+)
+
+type paymentHop struct {
+  ChanID uint64 `json:"chan_id"`
+  PubKey string `json:"pub_key"`
+  AmtToForwardMsat int64 `json:"amt_to_forward_msat"`
+  FeeMsat int64 `json:"fee_msat"`
+  Expiry uint32 `json:"expiry"`
+}
+
+type paymentRoute struct {
+  TotalAmtMsat int64 `json:"total_amt_msat"`
+  TotalFeesMsat int64 `json:"total_fees_msat"`
+  TotalTimeLock uint32 `json:"total_time_lock"`
+  Hops []paymentHop `json:"hops"`
+}
+
+// paymentHTLCFailure is why the last failed attempt failed and where:
+// source_index 0 is this node, n the nth hop of the route.
+type paymentHTLCFailure struct {
+  Code string `json:"code"`
+  Message string `json:"message"`
+  SourceIndex uint32 `json:"source_index"`
+  SourcePubkey string `json:"source_pubkey,omitempty"`
+  ChanID uint64 `json:"chan_id,omitempty"`
+}
+
+type paymentItem struct {
+  PaymentIndex uint64 `json:"payment_index"`
+  PaymentHash string `json:"payment_hash"`
+  Status string `json:"status"`
+  CreatedAt time.Time `json:"created_at"`
+  ValueMsat int64 `json:"value_msat"`
+  FeeMsat int64 `json:"fee_msat"`
+  FeePPM int64 `json:"fee_ppm"`
+  PaymentRequest string `json:"payment_request,omitempty"`
+  Preimage string `json:"preimage,omitempty"`
+  Destination string `json:"destination,omitempty"`
+  Keysend bool `json:"keysend"`
+  Attempts int `json:"attempts"`
+  FailureReason string `json:"failure_reason,omitempty"`
+  FailureMessage string `json:"failure_message,omitempty"`
+  LastFailure *paymentHTLCFailure `json:"last_failure,omitempty"`
+  // Routes are the routes of the settled HTLCs (several for a multi-part
+  // payment), or of the ones in flight.
+  Routes []paymentRoute `json:"routes"`
+}
+
+type paymentsQuery struct {
+  // Status keeps payments with this status; empty keeps all.
+  Status string
+  // Before keeps payments with a lower index; 0 starts at the newest.
+  Before uint64
+  Limit int
+}
+
+type paymentsPage struct {
+  Items []paymentItem
+  // NextCursor is the index to continue before, or 0 at the end.
+  NextCursor uint64
+}
+
+type paymentsFetch func(ctx context.Context, before uint64) (*lnrpc.ListPaymentsResponse, error)
+
+// paymentFailureMessages explain LND's payment level failure reasons.
+var paymentFailureMessages = map[lnrpc.PaymentFailureReason]string{
+  lnrpc.PaymentFailureReason_FAILURE_REASON_TIMEOUT: "no route succeeded before the payment timed out",
+  lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE: "no route with enough liquidity to the destination",
+  lnrpc.PaymentFailureReason_FAILURE_REASON_ERROR: "a hop returned an error that cannot be retried",
+  lnrpc.PaymentFailureReason_FAILURE_REASON_INCORRECT_PAYMENT_DETAILS: "the destination rejected the payment: unknown or paid invoice, wrong amount or expired",
+  lnrpc.PaymentFailureReason_FAILURE_REASON_INSUFFICIENT_BALANCE: "not enough outbound liquidity",
+  lnrpc.PaymentFailureReason_FAILURE_REASON_CANCELED: "the payment was canceled",
+}
+
+// htlcFailureMessages explain the common failure codes of an attempt.
+var htlcFailureMessages = map[lnrpc.Failure_FailureCode]string{
+  lnrpc.Failure_INCORRECT_OR_UNKNOWN_PAYMENT_DETAILS: "the destination does not know the payment or the amount is wrong",
+  lnrpc.Failure_TEMPORARY_CHANNEL_FAILURE: "a channel on the route lacked liquidity",
+  lnrpc.Failure_UNKNOWN_NEXT_PEER: "a hop's next channel is unknown or its peer is offline",
+  lnrpc.Failure_CHANNEL_DISABLED: "a channel on the route is disabled",
+  lnrpc.Failure_FEE_INSUFFICIENT: "a hop asked for a higher fee than the route paid",
+  lnrpc.Failure_INCORRECT_CLTV_EXPIRY: "a hop asked for a different timelock",
+  lnrpc.Failure_EXPIRY_TOO_SOON: "the timelock was too close for a hop",
+  lnrpc.Failure_FINAL_EXPIRY_TOO_SOON: "the timelock was too close for the destination",
+  lnrpc.Failure_AMOUNT_BELOW_MINIMUM: "the amount is below a hop's minimum",
+  lnrpc.Failure_TEMPORARY_NODE_FAILURE: "a hop failed temporarily",
+  lnrpc.Failure_PERMANENT_NODE_FAILURE: "a hop failed permanently",
+  lnrpc.Failure_PERMANENT_CHANNEL_FAILURE: "a channel on the route failed permanently",
+  lnrpc.Failure_MPP_TIMEOUT: "the destination did not receive all parts in time",
+  lnrpc.Failure_UNREADABLE_FAILURE: "a hop returned an unreadable error",
+}
+
+func paymentStatus(status lnrpc.Payment_PaymentStatus) string {
+  switch status {
+  case lnrpc.Payment_SUCCEEDED:
+    return paymentStatusSucceeded
+  case lnrpc.Payment_FAILED:
+    return paymentStatusFailed
+  }
+  // Initiated payments have no attempt yet; to the user they are in flight.
+  return paymentStatusInFlight
+}
+
+// paymentFailureReason returns the reason as a lowercase code and a message.
+func paymentFailureReason(reason lnrpc.PaymentFailureReason) (string, string) {
+  if reason == lnrpc.PaymentFailureReason_FAILURE_REASON_NONE {
+    return "", ""
+  }
+  code := strings.ToLower(strings.TrimPrefix(reason.String(), "FAILURE_REASON_"))
+  message, ok := paymentFailureMessages[reason]
+  if !ok {
+    message = strings.ReplaceAll(code, "_", " ")
+  }
+  return code, message
+}
+
+func htlcFailure(attempt *lnrpc.HTLCAttempt) *paymentHTLCFailure {
+  failure := attempt.GetFailure()
+  if failure == nil {
+    return nil
+  }
+  code := strings.ToLower(failure.Code.String())
+  message, ok := htlcFailureMessages[failure.Code]
+  if !ok {
+    message = strings.ReplaceAll(code, "_", " ")
+  }
+  out := &paymentHTLCFailure{Code: code, Message: message, SourceIndex: failure.FailureSourceIndex}
+  hops := attempt.GetRoute().GetHops()
+  // Index 0 is this node; hop n-1 leads to the nth node, whose outgoing
+  // channel is hop n.
+  index := int(failure.FailureSourceIndex)
+  if index > 0 && index <= len(hops) {
+    out.SourcePubkey = hops[index-1].PubKey
+  }
+  if index < len(hops) {
+    out.ChanID = hops[index].ChanId
+  }
+  return out
+}
+
+func routeOf(route *lnrpc.Route) paymentRoute {
+  out := paymentRoute{
+    TotalAmtMsat: route.TotalAmtMsat,
+    TotalFeesMsat: route.TotalFeesMsat,
+    TotalTimeLock: route.TotalTimeLock,
+    Hops: make([]paymentHop, 0, len(route.Hops)),
+  }
+  for _, hop := range route.Hops {
+    if hop == nil {
+      continue
+    }
+    out.Hops = append(out.Hops, paymentHop{
+      ChanID: hop.ChanId,
+      PubKey: hop.PubKey,
+      AmtToForwardMsat: hop.AmtToForwardMsat,
+      FeeMsat: hop.FeeMsat,
+      Expiry: hop.Expiry,
+    })
+  }
+  return out
+}
+
+func paymentItemFrom(pay *lnrpc.Payment) paymentItem {
+  item := paymentItem{
+    PaymentIndex: pay.PaymentIndex,
+    PaymentHash: pay.PaymentHash,
+    Status: paymentStatus(pay.Status),
+    CreatedAt: time.Unix(0, pay.CreationTimeNs).UTC(),
+    ValueMsat: pay.ValueMsat,
+    FeeMsat: paymentFeeMsat(pay),
+    PaymentRequest: pay.PaymentRequest,
+    Keysend: isKeysendPayment(pay),
+    Attempts: len(pay.Htlcs),
+    Routes: []paymentRoute{},
+  }
+  if item.ValueMsat == 0 {
+    item.ValueMsat = pay.ValueSat * 1000
+  }
+  if pay.CreationTimeNs == 0 {
+    item.CreatedAt = time.Unix(pay.CreationDate, 0).UTC()
+  }
+  if item.ValueMsat > 0 {
+    item.FeePPM = item.FeeMsat * 1_000_000 / item.ValueMsat
+  }
+  if item.Status == paymentStatusSucceeded && strings.Trim(pay.PaymentPreimage, "0") != "" {
+    item.Preimage = pay.PaymentPreimage
+  }
+  item.FailureReason, item.FailureMessage = paymentFailureReason(pay.FailureReason)
+
+  var inFlight []paymentRoute
+  for _, attempt := range pay.Htlcs {
+    if attempt == nil {
+      continue
+    }
+    switch attempt.Status {
+    case lnrpc.HTLCAttempt_SUCCEEDED:
+      if attempt.Route != nil {
+        item.Routes = append(item.Routes, routeOf(attempt.Route))
+      }
+    case lnrpc.HTLCAttempt_IN_FLIGHT:
+      if attempt.Route != nil {
+        inFlight = append(inFlight, routeOf(attempt.Route))
+      }
+    case lnrpc.HTLCAttempt_FAILED:
+      if failure := htlcFailure(attempt); failure != nil {
+        item.LastFailure = failure
+      }
+    }
+  }
+  if len(item.Routes) == 0 && len(inFlight) > 0 {
+    item.Routes = inFlight
+  }
+  if len(item.Routes) > 0 {
+    if hops := item.Routes[0].Hops; len(hops) > 0 {
+      item.Destination = hops[len(hops)-1].PubKey
+    }
+  }
+  return item
+}
+
+// scanPayments walks back from q.Before, newest first, until the page is
+// full and one more matching payment shows there is a next page.
+func scanPayments(ctx context.Context, fetch paymentsFetch, q paymentsQuery) (paymentsPage, error) {
+  page := paymentsPage{Items: []paymentItem{}}
+  before := q.Before
+  for {
+    res, err := fetch(ctx, before)
+    if err != nil {
+      return page, err
+    }
+    // LND returns the page oldest first even when reading backwards.
+    for i := len(res.Payments) - 1; i >= 0; i-- {
+      pay := res.Payments[i]
+      if pay == nil || (q.Status != "" && paymentStatus(pay.Status) != q.Status) {
+        continue
+      }
+      if len(page.Items) == q.Limit {
+        page.NextCursor = page.Items[len(page.Items)-1].PaymentIndex
+        return page, nil
+      }
+      page.Items = append(page.Items, paymentItemFrom(pay))
+    }
+    if len(res.Payments) == 0 || res.FirstIndexOffset <= 1 || (before != 0 && res.FirstIndexOffset >= before) {
+      return page, nil
+    }
+    before = res.FirstIndexOffset
+  }
+}
+
+func parsePaymentStatus(raw string) (string, bool) {
+  switch strings.ToLower(strings.TrimSpace(raw)) {
+  case "":
+    return "", true
+  case paymentStatusSucceeded:
+    return paymentStatusSucceeded, true
+  case paymentStatusFailed:
+    return paymentStatusFailed, true
+  case paymentStatusInFlight, "in-flight", "inflight":
+    return paymentStatusInFlight, true
+  }
+  return "", false
+}
+
+func (s *Server) handleWalletPayments(w http.ResponseWriter, r *http.Request) {
+  query := r.URL.Query()
+  q := paymentsQuery{Limit: paymentsDefaultLimit}
+  status, ok := parsePaymentStatus(query.Get("status"))
+  if !ok {
+    writeError(w, http.StatusBadRequest, "status must be succeeded, failed or in_flight")
+    return
+  }
+  q.Status = status
+  if raw := strings.TrimSpace(query.Get("cursor")); raw != "" {
+    before, err := strconv.ParseUint(raw, 10, 64)
+    if err != nil || before == 0 {
+      writeError(w, http.StatusBadRequest, "invalid cursor")
+      return
+    }
+    q.Before = before
+  }
+  if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+    limit, err := strconv.Atoi(raw)
+    if err != nil || limit <= 0 || limit > paymentsMaxLimit {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("limit out of range: 1 to %d", paymentsMaxLimit))
+      return
+    }
+    q.Limit = limit
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  conn, err := s.lndFor(r).DialLightning(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  defer conn.Close()
+  client := lnrpc.NewLightningClient(conn)
+
+  fetch := func(ctx context.Context, before uint64) (*lnrpc.ListPaymentsResponse, error) {
+    return client.ListPayments(ctx, &lnrpc.ListPaymentsRequest{
+      IncludeIncomplete: true,
+      IndexOffset: before,
+      MaxPayments: paymentsScanPageSize,
+      Reversed: true,
+    })
+  }
+  page, err := scanPayments(ctx, fetch, q)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndRPCErrorMessage(err))
+    return
+  }
+  nextCursor := ""
+  if page.NextCursor != 0 {
+    nextCursor = strconv.FormatUint(page.NextCursor, 10)
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "items": page.Items,
+    "next_cursor": nextCursor,
+  })
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+  "context"
+  "testing"
+
+  "lightningos-light/lnrpc"
+)
+
+// paymentsFetcher answers like ListPayments with reversed set: up to
+// pageSize payments below before (all when 0), oldest first.
+func paymentsFetcher(payments []*lnrpc.Payment, pageSize int) paymentsFetch {
+  return func(ctx context.Context, before uint64) (*lnrpc.ListPaymentsResponse, error) {
+    end := len(payments)
+    if before != 0 && int(before)-1 < end {
+      end = int(before) - 1
+    }
+    start := end - pageSize
+    if start < 0 {
+      start = 0
+    }
+    res := &lnrpc.ListPaymentsResponse{Payments: payments[start:end]}
+    if len(res.Payments) > 0 {
+      res.FirstIndexOffset = res.Payments[0].PaymentIndex
+      res.LastIndexOffset = res.Payments[len(res.Payments)-1].PaymentIndex
+    }
+    return res, nil
+  }
+}
+
+func TestScanPaymentsFiltersAndPages(t *testing.T) {
+  payments := []*lnrpc.Payment{}
+  for i := 1; i <= 9; i++ {
+    status := lnrpc.Payment_SUCCEEDED
+    if i%3 == 0 {
+      status = lnrpc.Payment_FAILED
+    }
+    payments = append(payments, &lnrpc.Payment{PaymentIndex: uint64(i), Status: status, ValueMsat: 1_000_000, FeeMsat: 500})
+  }
+  fetch := paymentsFetcher(payments, 2)
+
+  page, err := scanPayments(context.Background(), fetch, paymentsQuery{Status: paymentStatusSucceeded, Limit: 4})
+  if err != nil {
+    t.Fatal(err)
+  }
+  // Newest first: 8, 7, 5, 4.
+  if len(page.Items) != 4 || page.Items[0].PaymentIndex != 8 || page.Items[3].PaymentIndex != 4 || page.NextCursor != 4 {
+    t.Fatalf("first page = %+v cursor %d", page.Items, page.NextCursor)
+  }
+  if page.Items[0].FeePPM != 500 {
+    t.Fatalf("fee ppm = %d", page.Items[0].FeePPM)
+  }
+
+  page, err = scanPayments(context.Background(), fetch, paymentsQuery{Status: paymentStatusSucceeded, Before: page.NextCursor, Limit: 4})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(page.Items) != 2 || page.Items[0].PaymentIndex != 2 || page.Items[1].PaymentIndex != 1 || page.NextCursor != 0 {
+    t.Fatalf("last page = %+v cursor %d", page.Items, page.NextCursor)
+  }
+
+  page, err = scanPayments(context.Background(), fetch, paymentsQuery{Status: paymentStatusFailed, Limit: 10})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(page.Items) != 3 || page.Items[0].PaymentIndex != 9 || page.NextCursor != 0 {
+    t.Fatalf("failed = %+v cursor %d", page.Items, page.NextCursor)
+  }
+}
+
+func TestPaymentItemFailureAndRoutes(t *testing.T) {
+  route := &lnrpc.Route{
+    TotalAmtMsat: 1_001_000,
+    TotalFeesMsat: 1_000,
+    Hops: []*lnrpc.Hop{
+      {ChanId: 11, PubKey: "02aa", AmtToForwardMsat: 1_000_000, FeeMsat: 1_000},
+      {ChanId: 22, PubKey: "02bb", AmtToForwardMsat: 1_000_000},
+    },
+  }
+  failed := &lnrpc.Payment{
+    PaymentIndex: 3,
+    Status: lnrpc.Payment_FAILED,
+    ValueMsat: 1_000_000,
+    FailureReason: lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE,
+    Htlcs: []*lnrpc.HTLCAttempt{
+      {Status: lnrpc.HTLCAttempt_FAILED, Route: route, Failure: &lnrpc.Failure{Code: lnrpc.Failure_TEMPORARY_CHANNEL_FAILURE, FailureSourceIndex: 1}},
+    },
+  }
+  item := paymentItemFrom(failed)
+  if item.Status != paymentStatusFailed || item.FailureReason != "no_route" || item.FailureMessage == "" || item.Attempts != 1 {
+    t.Fatalf("failed item = %+v", item)
+  }
+  if f := item.LastFailure; f == nil || f.Code != "temporary_channel_failure" || f.SourcePubkey != "02aa" || f.ChanID != 22 {
+    t.Fatalf("last failure = %+v", item.LastFailure)
+  }
+  if len(item.Routes) != 0 {
+    t.Fatalf("failed payment has routes: %+v", item.Routes)
+  }
+
+  settled := &lnrpc.Payment{
+    PaymentIndex: 4,
+    Status: lnrpc.Payment_SUCCEEDED,
+    ValueSat: 1_000,
+    FeeMsat: 1_000,
+    PaymentPreimage: "ab",
+    Htlcs: []*lnrpc.HTLCAttempt{
+      {Status: lnrpc.HTLCAttempt_FAILED, Route: route, Failure: &lnrpc.Failure{Code: lnrpc.Failure_UNKNOWN_NEXT_PEER}},
+      {Status: lnrpc.HTLCAttempt_SUCCEEDED, Route: route},
+    },
+  }
+  item = paymentItemFrom(settled)
+  if item.ValueMsat != 1_000_000 || item.FeePPM != 1_000 || item.Preimage != "ab" || item.Destination != "02bb" {
+    t.Fatalf("settled item = %+v", item)
+  }
+  if len(item.Routes) != 1 || len(item.Routes[0].Hops) != 2 || item.Routes[0].TotalFeesMsat != 1_000 {
+    t.Fatalf("routes = %+v", item.Routes)
+  }
+}
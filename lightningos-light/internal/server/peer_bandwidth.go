@@ -0,0 +1,327 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "math"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+// Peer bandwidth turns the byte counters of ListPeers into hourly totals
+// per peer, so a node on a metered link can see which peers cost the most
+// traffic (gossip, mostly). LND counts per connection: a counter that went
+// down means the peer reconnected, and the new value is all new traffic.
+// The first sample after a start only sets the baseline, so traffic while
+// the manager was down is not counted.
+const (
+  peerSampleInterval = 5 * time.Minute
+  peerBandwidthRetention = 400 * 24 * time.Hour
+  peerBandwidthMaxDays = 90
+)
+
+type peerCounters struct {
+  Sent uint64
+  Recv uint64
+}
+
+func (s *Server) ensurePeerBandwidthSchema(ctx context.Context) error {
+  if s.db == nil {
+    return errors.New("db not configured")
+  }
+  _, err := s.db.Exec(ctx, `
+create table if not exists peer_bandwidth (
+  pubkey text not null,
+  hour timestamptz not null,
+  alias text not null default '',
+  bytes_sent bigint not null default 0,
+  bytes_recv bigint not null default 0,
+  primary key (pubkey, hour)
+);
+create index if not exists peer_bandwidth_hour_idx on peer_bandwidth (hour);
+`)
+  return err
+}
+
+func (s *Server) initPeerSamples() {
+  if s.db == nil {
+    return
+  }
+  ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
+  defer cancel()
+  if err := s.ensurePeerBandwidthSchema(ctx); err != nil {
+    s.logger.Printf("peer samples: failed to init schema: %v", err)
+    return
+  }
+  if err := s.ensurePeerPingSchema(ctx); err != nil {
+    s.logger.Printf("peer samples: failed to init schema: %v", err)
+    return
+  }
+  goSafe("peer_samples", s.runPeerSamples)
+}
+
+// bandwidthDeltas returns the traffic since the previous sample and the
+// counters to compare the next one with. A nil prev is the baseline round.
+func bandwidthDeltas(prev map[string]peerCounters, peers []lndclient.PeerInfo) (map[string]peerCounters, map[string]peerCounters) {
+  next := map[string]peerCounters{}
+  deltas := map[string]peerCounters{}
+  for _, peer := range peers {
+    current := peerCounters{Sent: peer.BytesSent, Recv: peer.BytesRecv}
+    next[peer.PubKey] = current
+    if prev == nil {
+      continue
+    }
+    last, seen := prev[peer.PubKey]
+    delta := current
+    if seen && current.Sent >= last.Sent && current.Recv >= last.Recv {
+      delta = peerCounters{Sent: current.Sent - last.Sent, Recv: current.Recv - last.Recv}
+    }
+    if delta.Sent > 0 || delta.Recv > 0 {
+      deltas[peer.PubKey] = delta
+    }
+  }
+  return deltas, next
+}
+
+func (s *Server) runPeerSamples() {
+  var prev map[string]peerCounters
+  var lastCleanup time.Time
+  for {
+    ctx, cancel := s.taskContext("peer_samples", timeoutLNDRPC)
+    peers, err := s.lnd.ListPeers(ctx)
+    cancel()
+    if err == nil {
+      now := time.Now().UTC()
+      deltas, next := bandwidthDeltas(prev, peers)
+      prev = next
+      if err := s.storePeerBandwidth(now, peers, deltas); err != nil {
+        s.logger.Printf("peer samples: failed to store bandwidth: %v", err)
+      }
+      if err := s.storePeerPings(now, peers); err != nil {
+        s.logger.Printf("peer samples: failed to store pings: %v", err)
+      }
+      if now.Sub(lastCleanup) > 24*time.Hour {
+        s.cleanupPeerBandwidth(now)
+        s.cleanupPeerPings(now)
+        lastCleanup = now
+      }
+    }
+    if !s.wait(peerSampleInterval) {
+      return
+    }
+  }
+}
+
+func (s *Server) storePeerBandwidth(now time.Time, peers []lndclient.PeerInfo, deltas map[string]peerCounters) error {
+  if len(deltas) == 0 {
+    return nil
+  }
+  aliases := map[string]string{}
+  for _, peer := range peers {
+    aliases[peer.PubKey] = peer.Alias
+  }
+  ctx, cancel := s.taskContext("peer_samples", timeoutShort)
+  defer cancel()
+  hour := now.Truncate(time.Hour)
+  for pubkey, delta := range deltas {
+    if _, err := s.db.Exec(ctx, `
+insert into peer_bandwidth (pubkey, hour, alias, bytes_sent, bytes_recv)
+values ($1, $2, $3, $4, $5)
+on conflict (pubkey, hour) do update set
+  alias = excluded.alias,
+  bytes_sent = peer_bandwidth.bytes_sent + excluded.bytes_sent,
+  bytes_recv = peer_bandwidth.bytes_recv + excluded.bytes_recv`,
+      pubkey, hour, aliases[pubkey], int64(delta.Sent), int64(delta.Recv)); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (s *Server) cleanupPeerBandwidth(now time.Time) {
+  ctx, cancel := s.taskContext("peer_samples", timeoutMedium)
+  defer cancel()
+  if _, err := s.db.Exec(ctx, `delete from peer_bandwidth where hour < $1`, now.Add(-peerBandwidthRetention)); err != nil {
+    s.logger.Printf("peer samples: bandwidth cleanup failed: %v", err)
+  }
+}
+
+type bandwidthPoint struct {
+  At time.Time `json:"at"`
+  BytesSent int64 `json:"bytes_sent"`
+  BytesRecv int64 `json:"bytes_recv"`
+}
+
+type bandwidthPeer struct {
+  Pubkey string `json:"pubkey"`
+  Alias string `json:"alias"`
+  BytesSent int64 `json:"bytes_sent"`
+  BytesRecv int64 `json:"bytes_recv"`
+  SharePct float64 `json:"share_pct"`
+}
+
+// rankBandwidthPeers sorts peers by total traffic and sets each one's
+// share of it.
+func rankBandwidthPeers(peers []bandwidthPeer) (int64, int64) {
+  var sent, recv int64
+  for _, peer := range peers {
+    sent += peer.BytesSent
+    recv += peer.BytesRecv
+  }
+  for i := range peers {
+    if total := sent + recv; total > 0 {
+      peers[i].SharePct = math.Round(float64(peers[i].BytesSent+peers[i].BytesRecv)*1e4/float64(total)) / 100
+    }
+  }
+  sort.SliceStable(peers, func(i, j int) bool {
+    return peers[i].BytesSent+peers[i].BytesRecv > peers[j].BytesSent+peers[j].BytesRecv
+  })
+  return sent, recv
+}
+
+// handlePeerBandwidth returns the traffic of the last days (?days=7, at
+// most 90) as a series (hourly up to 7 days, daily beyond) and per peer.
+// ?pubkey= narrows the series to one peer.
+func (s *Server) handlePeerBandwidth(w http.ResponseWriter, r *http.Request) {
+  if s.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "bandwidth tracking needs postgres")
+    return
+  }
+  days := 7
+  if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > peerBandwidthMaxDays {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("days out of range: 1 to %d", peerBandwidthMaxDays))
+      return
+    }
+    days = parsed
+  }
+  pubkey := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("pubkey")))
+  bucket := "hour"
+  if days > 7 {
+    bucket = "day"
+  }
+  now := time.Now().UTC()
+  from := now.Add(-time.Duration(days) * 24 * time.Hour).Truncate(time.Hour)
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  rows, err := s.db.Query(ctx, `
+select date_trunc($3, hour at time zone 'UTC') at time zone 'UTC' as bucket, sum(bytes_sent)::bigint, sum(bytes_recv)::bigint
+from peer_bandwidth
+where hour >= $1 and ($2 = '' or pubkey = $2)
+group by bucket
+order by bucket`, from, pubkey, bucket)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+    return
+  }
+  series := []bandwidthPoint{}
+  for rows.Next() {
+    var point bandwidthPoint
+    if err := rows.Scan(&point.At, &point.BytesSent, &point.BytesRecv); err != nil {
+      rows.Close()
+      writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+      return
+    }
+    series = append(series, point)
+  }
+  rows.Close()
+  if rows.Err() != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+    return
+  }
+
+  rows, err = s.db.Query(ctx, `
+select pubkey, (array_agg(alias order by hour desc))[1], sum(bytes_sent)::bigint, sum(bytes_recv)::bigint
+from peer_bandwidth
+where hour >= $1
+group by pubkey`, from)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+    return
+  }
+  defer rows.Close()
+  peers := []bandwidthPeer{}
+  for rows.Next() {
+    var peer bandwidthPeer
+    if err := rows.Scan(&peer.Pubkey, &peer.Alias, &peer.BytesSent, &peer.BytesRecv); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+      return
+    }
+    peers = append(peers, peer)
+  }
+  if rows.Err() != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+    return
+  }
+  sent, recv := rankBandwidthPeers(peers)
+  writeJSON(w, http.StatusOK, map[string]any{
+    "from": from,
+    "to": now,
+    "bucket": bucket,
+    "series": series,
+    "peers": peers,
+    "total_sent": sent,
+    "total_recv": recv,
+  })
+}
+
+// handlePeerBandwidthMonthly returns the node's traffic per month (UTC) for
+// the last months (?months=12, at most 13), newest first.
+func (s *Server) handlePeerBandwidthMonthly(w http.ResponseWriter, r *http.Request) {
+  if s.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "bandwidth tracking needs postgres")
+    return
+  }
+  months := 12
+  if raw := strings.TrimSpace(r.URL.Query().Get("months")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > 13 {
+      writeError(w, http.StatusBadRequest, "months out of range: 1 to 13")
+      return
+    }
+    months = parsed
+  }
+  now := time.Now().UTC()
+  oldest := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -(months - 1), 0)
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  rows, err := s.db.Query(ctx, `
+select to_char(hour at time zone 'UTC', 'YYYY-MM') as month, sum(bytes_sent)::bigint, sum(bytes_recv)::bigint
+from peer_bandwidth
+where hour >= $1
+group by month
+order by month desc`, oldest)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+    return
+  }
+  defer rows.Close()
+  type monthTotal struct {
+    Month string `json:"month"`
+    BytesSent int64 `json:"bytes_sent"`
+    BytesRecv int64 `json:"bytes_recv"`
+  }
+  items := []monthTotal{}
+  for rows.Next() {
+    var item monthTotal
+    if err := rows.Scan(&item.Month, &item.BytesSent, &item.BytesRecv); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+      return
+    }
+    items = append(items, item)
+  }
+  if rows.Err() != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load bandwidth")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestBandwidthDeltas(t *testing.T) {
+  deltas, prev := bandwidthDeltas(nil, []lndclient.PeerInfo{{PubKey: "a", BytesSent: 100, BytesRecv: 1000}})
+  if len(deltas) != 0 {
+    t.Fatalf("baseline counted: %v", deltas)
+  }
+  deltas, _ = bandwidthDeltas(prev, []lndclient.PeerInfo{
+    {PubKey: "a", BytesSent: 150, BytesRecv: 1200},
+    {PubKey: "b", BytesSent: 10, BytesRecv: 20},
+  })
+  if deltas["a"] != (peerCounters{Sent: 50, Recv: 200}) || deltas["b"] != (peerCounters{Sent: 10, Recv: 20}) {
+    t.Fatalf("deltas %v", deltas)
+  }
+  // A reconnect restarts the counters.
+  deltas, _ = bandwidthDeltas(prev, []lndclient.PeerInfo{{PubKey: "a", BytesSent: 30, BytesRecv: 2000}})
+  if deltas["a"] != (peerCounters{Sent: 30, Recv: 2000}) {
+    t.Fatalf("reconnect %v", deltas)
+  }
+}
+
+func TestRankBandwidthPeers(t *testing.T) {
+  peers := []bandwidthPeer{{Pubkey: "a", BytesSent: 10, BytesRecv: 15}, {Pubkey: "b", BytesSent: 50, BytesRecv: 25}}
+  sent, recv := rankBandwidthPeers(peers)
+  if sent != 60 || recv != 40 || peers[0].Pubkey != "b" || peers[0].SharePct != 75 || peers[1].SharePct != 25 {
+    t.Fatalf("sent %d recv %d peers %+v", sent, recv, peers)
+  }
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "math"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+// Peer latency keeps the ping time LND reports for each connected peer,
+// taken with the bandwidth samples, for a week. The quality score sums up
+// the last day: slow or jittery pings, reconnects (LND's flap count going
+// up) and time not connected all take points off a hundred.
+const (
+  peerPingRetention = 7 * 24 * time.Hour
+  peerQualityWindow = 24 * time.Hour
+  peerQualityMinSamples = 3
+  peerPingMaxHours = 168
+)
+
+type pingSample struct {
+  At time.Time `json:"at"`
+  PingMs float64 `json:"ping_ms"`
+  FlapCount int32 `json:"flap_count"`
+}
+
+type peerQuality struct {
+  Score int `json:"score"`
+  // Grade is good (80 and up), fair (50 and up) or poor.
+  Grade string `json:"grade"`
+  Samples int `json:"samples"`
+  MedianPingMs float64 `json:"median_ping_ms"`
+  // JitterMs is the mean change between consecutive pings.
+  JitterMs float64 `json:"jitter_ms"`
+  Reconnects int `json:"reconnects"`
+  // PresencePct is the share of sampling rounds, since the first one in
+  // the window, that found the peer connected.
+  PresencePct float64 `json:"presence_pct"`
+}
+
+func (s *Server) ensurePeerPingSchema(ctx context.Context) error {
+  if s.db == nil {
+    return errors.New("db not configured")
+  }
+  _, err := s.db.Exec(ctx, `
+create table if not exists peer_pings (
+  pubkey text not null,
+  sampled_at timestamptz not null,
+  ping_us bigint not null,
+  flap_count integer not null default 0,
+  primary key (pubkey, sampled_at)
+);
+create index if not exists peer_pings_sampled_idx on peer_pings (sampled_at);
+`)
+  return err
+}
+
+func (s *Server) storePeerPings(now time.Time, peers []lndclient.PeerInfo) error {
+  ctx, cancel := s.taskContext("peer_samples", timeoutShort)
+  defer cancel()
+  for _, peer := range peers {
+    // LND reports 0 until the first ping of a connection is answered.
+    if peer.PingTime <= 0 {
+      continue
+    }
+    if _, err := s.db.Exec(ctx, `
+insert into peer_pings (pubkey, sampled_at, ping_us, flap_count)
+values ($1, $2, $3, $4)
+on conflict (pubkey, sampled_at) do nothing`, peer.PubKey, now, peer.PingTime, peer.FlapCount); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (s *Server) cleanupPeerPings(now time.Time) {
+  ctx, cancel := s.taskContext("peer_samples", timeoutMedium)
+  defer cancel()
+  if _, err := s.db.Exec(ctx, `delete from peer_pings where sampled_at < $1`, now.Add(-peerPingRetention)); err != nil {
+    s.logger.Printf("peer samples: ping cleanup failed: %v", err)
+  }
+}
+
+// buildPeerQuality scores samples (oldest first) taken every interval up to
+// now; nil when there are too few to judge.
+func buildPeerQuality(samples []pingSample, interval time.Duration, now time.Time) *peerQuality {
+  if len(samples) < peerQualityMinSamples {
+    return nil
+  }
+  q := &peerQuality{Samples: len(samples)}
+  pings := make([]float64, len(samples))
+  var jitter float64
+  for i, sample := range samples {
+    pings[i] = sample.PingMs
+    if i == 0 {
+      continue
+    }
+    jitter += math.Abs(sample.PingMs - samples[i-1].PingMs)
+    if up := sample.FlapCount - samples[i-1].FlapCount; up > 0 {
+      q.Reconnects += int(up)
+    }
+  }
+  sort.Float64s(pings)
+  q.MedianPingMs = math.Round(pings[len(pings)/2]*10) / 10
+  q.JitterMs = math.Round(jitter/float64(len(samples)-1)*10) / 10
+  expected := int(now.Sub(samples[0].At)/interval) + 1
+  q.PresencePct = math.Min(100, math.Round(float64(len(samples))*1000/float64(expected))/10)
+
+  penalty := clampFloat((q.MedianPingMs-100)/20, 0, 30) +
+    clampFloat(q.JitterMs/10, 0, 20) +
+    clampFloat(float64(10*q.Reconnects), 0, 30) +
+    (100-q.PresencePct)*0.2
+  q.Score = int(math.Round(clampFloat(100-penalty, 0, 100)))
+  switch {
+  case q.Score >= 80:
+    q.Grade = "good"
+  case q.Score >= 50:
+    q.Grade = "fair"
+  default:
+    q.Grade = "poor"
+  }
+  return q
+}
+
+func clampFloat(v, lo, hi float64) float64 {
+  return math.Max(lo, math.Min(hi, v))
+}
+
+func (s *Server) peerPingSamples(ctx context.Context, pubkey string, since time.Time) (map[string][]pingSample, error) {
+  rows, err := s.db.Query(ctx, `
+select pubkey, sampled_at, ping_us, flap_count
+from peer_pings
+where sampled_at >= $1 and ($2 = '' or pubkey = $2)
+order by pubkey, sampled_at`, since, pubkey)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  byPeer := map[string][]pingSample{}
+  for rows.Next() {
+    var key string
+    var sample pingSample
+    var pingUs int64
+    if err := rows.Scan(&key, &sample.At, &pingUs, &sample.FlapCount); err != nil {
+      return nil, err
+    }
+    sample.PingMs = float64(pingUs) / 1000
+    byPeer[key] = append(byPeer[key], sample)
+  }
+  return byPeer, rows.Err()
+}
+
+// peerQualities scores every peer sampled in the last day. Only the
+// primary node is sampled.
+func (s *Server) peerQualities(ctx context.Context) (map[string]*peerQuality, error) {
+  now := time.Now().UTC()
+  byPeer, err := s.peerPingSamples(ctx, "", now.Add(-peerQualityWindow))
+  if err != nil {
+    return nil, err
+  }
+  qualities := map[string]*peerQuality{}
+  for pubkey, samples := range byPeer {
+    if q := buildPeerQuality(samples, peerSampleInterval, now); q != nil {
+      qualities[pubkey] = q
+    }
+  }
+  return qualities, nil
+}
+
+// handlePeerLatency returns one peer's ping history (?pubkey=, ?hours=24, at
+// most a week) and its quality score over the last day.
+func (s *Server) handlePeerLatency(w http.ResponseWriter, r *http.Request) {
+  if s.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "latency tracking needs postgres")
+    return
+  }
+  pubkey := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("pubkey")))
+  if !isValidPubkeyHex(pubkey) {
+    writeError(w, http.StatusBadRequest, "invalid pubkey")
+    return
+  }
+  hours := 24
+  if raw := strings.TrimSpace(r.URL.Query().Get("hours")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > peerPingMaxHours {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("hours out of range: 1 to %d", peerPingMaxHours))
+      return
+    }
+    hours = parsed
+  }
+  now := time.Now().UTC()
+  since := now.Add(-time.Duration(hours) * time.Hour)
+  qualitySince := now.Add(-peerQualityWindow)
+  if qualitySince.Before(since) {
+    since = qualitySince
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  byPeer, err := s.peerPingSamples(ctx, pubkey, since)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load latency")
+    return
+  }
+  samples := []pingSample{}
+  recent := []pingSample{}
+  for _, sample := range byPeer[pubkey] {
+    if !sample.At.Before(now.Add(-time.Duration(hours) * time.Hour)) {
+      samples = append(samples, sample)
+    }
+    if !sample.At.Before(qualitySince) {
+      recent = append(recent, sample)
+    }
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "pubkey": pubkey,
+    "hours": hours,
+    "samples": samples,
+    "quality": buildPeerQuality(recent, peerSampleInterval, now),
+  })
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+  "testing"
+  "time"
+)
+
+func TestBuildPeerQuality(t *testing.T) {
+  now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  steady := []pingSample{}
+  for i := 11; i >= 0; i-- {
+    steady = append(steady, pingSample{At: now.Add(-time.Duration(i) * peerSampleInterval), PingMs: 80})
+  }
+  q := buildPeerQuality(steady, peerSampleInterval, now)
+  if q == nil || q.Score != 100 || q.Grade != "good" || q.PresencePct != 100 || q.JitterMs != 0 {
+    t.Fatalf("steady %+v", q)
+  }
+
+  // Half the rounds missing, pings swinging between 100 and 500 ms and two
+  // reconnects.
+  flaky := []pingSample{}
+  for i := 0; i < 6; i++ {
+    ping := 100.0
+    if i%2 == 1 {
+      ping = 500
+    }
+    flaky = append(flaky, pingSample{At: now.Add(-time.Duration(11-2*i) * peerSampleInterval), PingMs: ping, FlapCount: int32(i / 3 * 2)})
+  }
+  q = buildPeerQuality(flaky, peerSampleInterval, now)
+  if q == nil || q.Reconnects != 2 || q.JitterMs != 400 || q.PresencePct != 50 || q.Grade != "poor" {
+    t.Fatalf("flaky %+v", q)
+  }
+
+  if buildPeerQuality(steady[:2], peerSampleInterval, now) != nil {
+    t.Fatal("scored two samples")
+  }
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+
+  "github.com/go-chi/chi/v5"
+)
+
+// Peer metadata is what the operator knows about a peer that LND does not:
+// notes, tags ("LSP", "friend") and the local share of liquidity wanted in
+// its channels. It is keyed by pubkey, so it survives channel closes and is
+// shared by all managed nodes.
+const (
+  maxPeerNotes = 2000
+  maxPeerTags = 10
+  maxPeerTagLength = 24
+)
+
+var (
+  peerMetadataPath = "/var/lib/lightningos/peer-metadata.json"
+  peerMetadataMu sync.Mutex
+)
+
+type peerMetadata struct {
+  Pubkey string `json:"pubkey"`
+  Notes string `json:"notes,omitempty"`
+  Tags []string `json:"tags"`
+  // TargetLocalPct is the wanted local share of the peer's channel
+  // capacity, 0 to 100; nil when not set.
+  TargetLocalPct *int `json:"target_local_pct,omitempty"`
+  UpdatedAt time.Time `json:"updated_at"`
+}
+
+func loadPeerMetadata() (map[string]peerMetadata, error) {
+  raw, err := os.ReadFile(peerMetadataPath)
+  if errors.Is(err, os.ErrNotExist) {
+    return map[string]peerMetadata{}, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  items := []peerMetadata{}
+  if err := json.Unmarshal(raw, &items); err != nil {
+    return nil, fmt.Errorf("invalid %s: %w", peerMetadataPath, err)
+  }
+  byPubkey := make(map[string]peerMetadata, len(items))
+  for _, item := range items {
+    byPubkey[item.Pubkey] = item
+  }
+  return byPubkey, nil
+}
+
+func savePeerMetadata(byPubkey map[string]peerMetadata) error {
+  items := make([]peerMetadata, 0, len(byPubkey))
+  for _, item := range byPubkey {
+    items = append(items, item)
+  }
+  sort.Slice(items, func(i, j int) bool { return items[i].Pubkey < items[j].Pubkey })
+  if err := os.MkdirAll(filepath.Dir(peerMetadataPath), 0o750); err != nil {
+    return err
+  }
+  data, err := json.MarshalIndent(items, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(peerMetadataPath, data, 0o600)
+}
+
+// currentPeerMetadata is the store for merging into responses; a broken
+// file only drops the extra fields.
+func currentPeerMetadata() map[string]peerMetadata {
+  peerMetadataMu.Lock()
+  defer peerMetadataMu.Unlock()
+  byPubkey, err := loadPeerMetadata()
+  if err != nil {
+    return map[string]peerMetadata{}
+  }
+  return byPubkey
+}
+
+// normalizePeerTags trims tags and drops duplicates, comparing without case;
+// the first spelling is kept.
+func normalizePeerTags(tags []string) ([]string, error) {
+  out := []string{}
+  seen := map[string]bool{}
+  for _, tag := range tags {
+    tag = strings.TrimSpace(tag)
+    if tag == "" {
+      continue
+    }
+    if len(tag) > maxPeerTagLength || strings.ContainsAny(tag, ",\n\t") {
+      return nil, fmt.Errorf("invalid tag: %s", tag)
+    }
+    key := strings.ToLower(tag)
+    if seen[key] {
+      continue
+    }
+    seen[key] = true
+    out = append(out, tag)
+  }
+  if len(out) > maxPeerTags {
+    return nil, fmt.Errorf("too many tags: max %d", maxPeerTags)
+  }
+  return out, nil
+}
+
+func (m peerMetadata) hasTag(tag string) bool {
+  for _, t := range m.Tags {
+    if strings.EqualFold(t, tag) {
+      return true
+    }
+  }
+  return false
+}
+
+// peerTagFilter returns the ?tag= filter and whether the pubkey passes it.
+func peerTagFilter(r *http.Request, meta map[string]peerMetadata) (string, func(pubkey string) bool) {
+  tag := strings.TrimSpace(r.URL.Query().Get("tag"))
+  return tag, func(pubkey string) bool {
+    return tag == "" || meta[strings.ToLower(pubkey)].hasTag(tag)
+  }
+}
+
+type peerView struct {
+  lndclient.PeerInfo
+  Notes string `json:"notes,omitempty"`
+  Tags []string `json:"tags"`
+  TargetLocalPct *int `json:"target_local_pct,omitempty"`
+  // Quality is the connection score of the last day; primary node only.
+  Quality *peerQuality `json:"quality,omitempty"`
+}
+
+type channelView struct {
+  lndclient.ChannelInfo
+  PeerNotes string `json:"peer_notes,omitempty"`
+  PeerTags []string `json:"peer_tags"`
+  TargetLocalPct *int `json:"target_local_pct,omitempty"`
+  // TargetDeviationPct is the local share minus the target, in points:
+  // positive means more local balance than wanted.
+  TargetDeviationPct *int `json:"target_deviation_pct,omitempty"`
+}
+
+func newPeerView(peer lndclient.PeerInfo, meta map[string]peerMetadata) peerView {
+  m := meta[strings.ToLower(peer.PubKey)]
+  tags := m.Tags
+  if tags == nil {
+    tags = []string{}
+  }
+  return peerView{PeerInfo: peer, Notes: m.Notes, Tags: tags, TargetLocalPct: m.TargetLocalPct}
+}
+
+func newChannelView(ch lndclient.ChannelInfo, meta map[string]peerMetadata) channelView {
+  m := meta[strings.ToLower(ch.RemotePubkey)]
+  view := channelView{ChannelInfo: ch, PeerNotes: m.Notes, PeerTags: m.Tags, TargetLocalPct: m.TargetLocalPct}
+  if view.PeerTags == nil {
+    view.PeerTags = []string{}
+  }
+  if m.TargetLocalPct != nil && ch.CapacitySat > 0 {
+    deviation := int(ch.LocalBalanceSat*100/ch.CapacitySat) - *m.TargetLocalPct
+    view.TargetDeviationPct = &deviation
+  }
+  return view
+}
+
+// handlePeerMetadataList returns all stored metadata and the tags in use
+// with how many peers carry each.
+func (s *Server) handlePeerMetadataList(w http.ResponseWriter, r *http.Request) {
+  peerMetadataMu.Lock()
+  byPubkey, err := loadPeerMetadata()
+  peerMetadataMu.Unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load peer metadata: %v", err))
+    return
+  }
+  items := make([]peerMetadata, 0, len(byPubkey))
+  counts := map[string]int{}
+  spelling := map[string]string{}
+  for _, item := range byPubkey {
+    items = append(items, item)
+    for _, tag := range item.Tags {
+      key := strings.ToLower(tag)
+      if _, ok := spelling[key]; !ok {
+        spelling[key] = tag
+      }
+      counts[key]++
+    }
+  }
+  sort.Slice(items, func(i, j int) bool { return items[i].Pubkey < items[j].Pubkey })
+  type tagCount struct {
+    Tag string `json:"tag"`
+    Peers int `json:"peers"`
+  }
+  tags := []tagCount{}
+  for key, count := range counts {
+    tags = append(tags, tagCount{Tag: spelling[key], Peers: count})
+  }
+  sort.Slice(tags, func(i, j int) bool { return strings.ToLower(tags[i].Tag) < strings.ToLower(tags[j].Tag) })
+  writeJSON(w, http.StatusOK, map[string]any{"items": items, "tags": tags})
+}
+
+// handlePeerMetadataSave replaces the metadata of one peer. Empty notes, no
+// tags and no target remove the entry.
+func (s *Server) handlePeerMetadataSave(w http.ResponseWriter, r *http.Request) {
+  pubkey := strings.ToLower(strings.TrimSpace(chi.URLParam(r, "pubkey")))
+  if !isValidPubkeyHex(pubkey) {
+    writeError(w, http.StatusBadRequest, "invalid pubkey")
+    return
+  }
+  var req struct {
+    Notes string `json:"notes"`
+    Tags []string `json:"tags"`
+    TargetLocalPct *int `json:"target_local_pct"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  meta := peerMetadata{Pubkey: pubkey, Notes: strings.TrimSpace(req.Notes), TargetLocalPct: req.TargetLocalPct, UpdatedAt: time.Now().UTC()}
+  if len(meta.Notes) > maxPeerNotes {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("notes too long: max %d bytes", maxPeerNotes))
+    return
+  }
+  tags, err := normalizePeerTags(req.Tags)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  meta.Tags = tags
+  if meta.TargetLocalPct != nil && (*meta.TargetLocalPct < 0 || *meta.TargetLocalPct > 100) {
+    writeError(w, http.StatusBadRequest, "target_local_pct must be between 0 and 100")
+    return
+  }
+
+  peerMetadataMu.Lock()
+  defer peerMetadataMu.Unlock()
+  byPubkey, err := loadPeerMetadata()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load peer metadata: %v", err))
+    return
+  }
+  if meta.Notes == "" && len(meta.Tags) == 0 && meta.TargetLocalPct == nil {
+    delete(byPubkey, pubkey)
+  } else {
+    byPubkey[pubkey] = meta
+  }
+  if err := savePeerMetadata(byPubkey); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save peer metadata: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, meta)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+  "encoding/json"
+  "net/http"
+  "strings"
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestNormalizePeerTags(t *testing.T) {
+  tags, err := normalizePeerTags([]string{" LSP ", "friend", "lsp", ""})
+  if err != nil || len(tags) != 2 || tags[0] != "LSP" || tags[1] != "friend" {
+    t.Fatalf("tags %v: %v", tags, err)
+  }
+  if _, err := normalizePeerTags([]string{"a,b"}); err == nil {
+    t.Fatal("accepted a comma")
+  }
+  if _, err := normalizePeerTags(strings.Split("a b c d e f g h i j k", " ")); err == nil {
+    t.Fatal("accepted 11 tags")
+  }
+}
+
+func TestPeerMetadataMerge(t *testing.T) {
+  saved := peerMetadataPath
+  peerMetadataPath = t.TempDir() + "/peer-metadata.json"
+  t.Cleanup(func() { peerMetadataPath = saved })
+  s, fake := newFakeLNDServer(t)
+  lsp := "02" + strings.Repeat("aa", 32)
+  other := "03" + strings.Repeat("bb", 32)
+  fake.Peers = []lndclient.PeerInfo{{PubKey: lsp, Alias: "lsp"}, {PubKey: other, Alias: "other"}}
+  fake.Channels = []lndclient.ChannelInfo{
+    {ChannelPoint: "aa:0", RemotePubkey: lsp, CapacitySat: 1000, LocalBalanceSat: 700, Active: true},
+    {ChannelPoint: "bb:0", RemotePubkey: other, CapacitySat: 1000, LocalBalanceSat: 500, Active: true},
+  }
+
+  rec := serveAPI(s, http.MethodPost, "/api/peers/metadata/"+strings.ToUpper(lsp), `{"notes":"our LSP","tags":["LSP"],"target_local_pct":50}`)
+  if rec.Code != http.StatusOK {
+    t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+  }
+  if rec := serveAPI(s, http.MethodPost, "/api/peers/metadata/"+lsp, `{"target_local_pct":101}`); rec.Code != http.StatusBadRequest {
+    t.Fatalf("target out of range: status = %d", rec.Code)
+  }
+
+  rec = serveAPI(s, http.MethodGet, "/api/lnops/peers?tag=lsp", "")
+  var peers struct {
+    Peers []peerView `json:"peers"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &peers); err != nil {
+    t.Fatal(err)
+  }
+  if len(peers.Peers) != 1 || peers.Peers[0].Alias != "lsp" || peers.Peers[0].Notes != "our LSP" {
+    t.Fatalf("peers %+v", peers.Peers)
+  }
+
+  rec = serveAPI(s, http.MethodGet, "/api/lnops/channels", "")
+  var channels struct {
+    ActiveCount int `json:"active_count"`
+    Channels []channelView `json:"channels"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &channels); err != nil {
+    t.Fatal(err)
+  }
+  if channels.ActiveCount != 2 || channels.Channels[0].TargetDeviationPct == nil || *channels.Channels[0].TargetDeviationPct != 20 {
+    t.Fatalf("channels %+v", channels.Channels)
+  }
+  if channels.Channels[1].TargetDeviationPct != nil || len(channels.Channels[1].PeerTags) != 0 {
+    t.Fatalf("untagged channel %+v", channels.Channels[1])
+  }
+
+  // Clearing every field removes the entry.
+  serveAPI(s, http.MethodPost, "/api/peers/metadata/"+lsp, `{}`)
+  if byPubkey, err := loadPeerMetadata(); err != nil || len(byPubkey) != 0 {
+    t.Fatalf("metadata %+v: %v", byPubkey, err)
+  }
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+  "net"
+  "os"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+)
+
+const socketReachabilityTTL = 10 * time.Minute
+
+// socketReachability is which peer address families this node can dial.
+// TorOnly means clearnet targets also go through Tor (tor.active without
+// tor.skip-proxy-for-clearnet-targets), so onion sockets are preferred and
+// local IPv4/IPv6 routes do not matter.
+type socketReachability struct {
+  Tor bool `json:"tor"`
+  TorOnly bool `json:"tor_only"`
+  IPv4 bool `json:"ipv4"`
+  IPv6 bool `json:"ipv6"`
+}
+
+var (
+  socketReachabilityMu sync.Mutex
+  socketReachabilityCached socketReachability
+  socketReachabilityAt time.Time
+)
+
+// currentSocketReachability reads the Tor settings from lnd.conf and probes
+// for IPv4/IPv6 routes; the result is cached for a few minutes.
+func currentSocketReachability() socketReachability {
+  socketReachabilityMu.Lock()
+  defer socketReachabilityMu.Unlock()
+  if !socketReachabilityAt.IsZero() && time.Since(socketReachabilityAt) < socketReachabilityTTL {
+    return socketReachabilityCached
+  }
+  raw, _ := os.ReadFile(lndConfPath)
+  reach := torReachabilityFromConf(string(raw))
+  reach.IPv4 = hasRoute("udp4", "1.1.1.1:53")
+  reach.IPv6 = hasRoute("udp6", "[2606:4700:4700::1111]:53")
+  socketReachabilityCached = reach
+  socketReachabilityAt = time.Now()
+  return reach
+}
+
+func torReachabilityFromConf(raw string) socketReachability {
+  options := map[string]string{}
+  for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+    trimmed := strings.TrimSpace(line)
+    if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+      continue
+    }
+    key, value, ok := strings.Cut(trimmed, "=")
+    if !ok {
+      continue
+    }
+    options[strings.ToLower(strings.TrimSpace(key))] = strings.ToLower(strings.TrimSpace(value))
+  }
+  reach := socketReachability{}
+  reach.Tor = options["tor.active"] == "true" || options["tor.active"] == "1"
+  skipProxy := options["tor.skip-proxy-for-clearnet-targets"]
+  reach.TorOnly = reach.Tor && skipProxy != "true" && skipProxy != "1"
+  return reach
+}
+
+// hasRoute tells whether the kernel has a route for the address family.
+// Connecting a UDP socket sends nothing, it only selects a source address.
+func hasRoute(network string, addr string) bool {
+  conn, err := net.DialTimeout(network, addr, time.Second)
+  if err != nil {
+    return false
+  }
+  _ = conn.Close()
+  return true
+}
+
+type socketFamily int
+
+const (
+  socketIPv4 socketFamily = iota
+  socketIPv6
+  socketHostname
+  socketOnion
+)
+
+func classifySocket(socket string) socketFamily {
+  host := socket
+  if h, _, err := net.SplitHostPort(socket); err == nil {
+    host = h
+  }
+  host = strings.Trim(host, "[]")
+  if strings.HasSuffix(strings.ToLower(host), ".onion") {
+    return socketOnion
+  }
+  ip := net.ParseIP(host)
+  switch {
+  case ip == nil:
+    return socketHostname
+  case ip.To4() != nil:
+    return socketIPv4
+  default:
+    return socketIPv6
+  }
+}
+
+func (r socketReachability) reachable(family socketFamily) bool {
+  switch family {
+  case socketOnion:
+    return r.Tor
+  case socketIPv4:
+    return r.TorOnly || r.IPv4
+  case socketIPv6:
+    return r.TorOnly || r.IPv6
+  default:
+    return r.TorOnly || r.IPv4 || r.IPv6
+  }
+}
+
+// rank orders families best first. Clearnet is faster, so it leads unless
+// everything goes through Tor anyway, in which case onion avoids an exit hop.
+func (r socketReachability) rank(family socketFamily) int {
+  if r.TorOnly {
+    if family == socketOnion {
+      return 0
+    }
+    return int(family) + 1
+  }
+  return int(family)
+}
+
+// splitPeerSockets parses the comma separated sockets reported by mempool,
+// dropping any pubkey@ prefix and duplicates.
+func splitPeerSockets(raw string) []string {
+  sockets := []string{}
+  seen := map[string]bool{}
+  for _, part := range strings.Split(raw, ",") {
+    socket := strings.TrimSpace(part)
+    if at := strings.LastIndex(socket, "@"); at != -1 {
+      socket = strings.TrimSpace(socket[at+1:])
+    }
+    if socket == "" || seen[socket] {
+      continue
+    }
+    seen[socket] = true
+    sockets = append(sockets, socket)
+  }
+  return sockets
+}
+
+// rankPeerSockets returns the sockets this node can reach, best first.
+func rankPeerSockets(raw string, reach socketReachability) []string {
+  candidates := []string{}
+  for _, socket := range splitPeerSockets(raw) {
+    if reach.reachable(classifySocket(socket)) {
+      candidates = append(candidates, socket)
+    }
+  }
+  sort.SliceStable(candidates, func(i, j int) bool {
+    return reach.rank(classifySocket(candidates[i])) < reach.rank(classifySocket(candidates[j]))
+  })
+  return candidates
+}
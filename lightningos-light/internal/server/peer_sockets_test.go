@@ -0,0 +1,43 @@
+package server
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestRankPeerSockets(t *testing.T) {
+  raw := "abc.onion:9735, 203.0.113.5:9735,[2001:db8::1]:9735,node.example.com:9735,203.0.113.5:9735"
+
+  clearnetV4 := socketReachability{IPv4: true}
+  got := rankPeerSockets(raw, clearnetV4)
+  want := []string{"203.0.113.5:9735", "node.example.com:9735"}
+  if !reflect.DeepEqual(got, want) {
+    t.Fatalf("ipv4 only: got %v, want %v", got, want)
+  }
+
+  hybrid := socketReachability{Tor: true, IPv4: true, IPv6: true}
+  got = rankPeerSockets(raw, hybrid)
+  want = []string{"203.0.113.5:9735", "[2001:db8::1]:9735", "node.example.com:9735", "abc.onion:9735"}
+  if !reflect.DeepEqual(got, want) {
+    t.Fatalf("hybrid: got %v, want %v", got, want)
+  }
+
+  torOnly := socketReachability{Tor: true, TorOnly: true}
+  got = rankPeerSockets("pub@203.0.113.5:9735,abc.onion:9735", torOnly)
+  want = []string{"abc.onion:9735", "203.0.113.5:9735"}
+  if !reflect.DeepEqual(got, want) {
+    t.Fatalf("tor only: got %v, want %v", got, want)
+  }
+}
+
+func TestTorReachabilityFromConf(t *testing.T) {
+  if reach := torReachabilityFromConf("[tor]\ntor.active=true\ntor.skip-proxy-for-clearnet-targets=true\n"); !reach.Tor || reach.TorOnly {
+    t.Fatalf("hybrid config: %+v", reach)
+  }
+  if reach := torReachabilityFromConf("[tor]\ntor.active=true\n"); !reach.TorOnly {
+    t.Fatalf("tor config: %+v", reach)
+  }
+  if reach := torReachabilityFromConf("[tor]\n; tor.active=true\n"); reach.Tor {
+    t.Fatalf("commented option counted: %+v", reach)
+  }
+}
@@ -0,0 +1,618 @@
+package server
+
+import (
+  "context"
+  "crypto/sha256"
+  "crypto/subtle"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/config"
+
+  "github.com/go-chi/chi/v5"
+  "github.com/jackc/pgx/v5"
+)
+
+// A point-of-sale register is a tablet at a shop: it holds a token that can
+// only create invoices and read their settlement, never reach the admin API.
+// Registers live in a JSON file; the invoices they create are kept in
+// Postgres next to the notifications that record their settlement.
+const (
+  posRegistersPath = "/var/lib/lightningos/pos-registers.json"
+  posTerminalPrefix = "/api/pos/terminal/"
+  posTokenPrefix = "pos_"
+  maxPOSRegisters = 50
+  maxPOSRegisterName = 60
+  posInvoiceDefaultExpiry = 600
+  posInvoiceMaxExpiry = 24 * 3600
+  posTotalsDefaultDays = 30
+  posTotalsMaxDays = 366
+)
+
+type posRegister struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  NodeID string `json:"node_id"`
+  // MaxAmountSat caps a single invoice; 0 means no cap.
+  MaxAmountSat int64 `json:"max_amount_sat,omitempty"`
+  TokenHash string `json:"token_hash"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+// posRegisterView is a register as the admin API shows it, without the hash.
+type posRegisterView struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  NodeID string `json:"node_id"`
+  MaxAmountSat int64 `json:"max_amount_sat,omitempty"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+func (reg posRegister) view() posRegisterView {
+  return posRegisterView{ID: reg.ID, Name: reg.Name, NodeID: reg.NodeID, MaxAmountSat: reg.MaxAmountSat, CreatedAt: reg.CreatedAt}
+}
+
+var posRegistersMu sync.Mutex
+
+func loadPOSRegisters() ([]posRegister, error) {
+  raw, err := os.ReadFile(posRegistersPath)
+  if errors.Is(err, os.ErrNotExist) {
+    return []posRegister{}, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  registers := []posRegister{}
+  if err := json.Unmarshal(raw, &registers); err != nil {
+    return nil, fmt.Errorf("invalid %s: %w", posRegistersPath, err)
+  }
+  return registers, nil
+}
+
+func savePOSRegisters(registers []posRegister) error {
+  if err := os.MkdirAll(filepath.Dir(posRegistersPath), 0o750); err != nil {
+    return err
+  }
+  data, err := json.MarshalIndent(registers, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(posRegistersPath, data, 0o600)
+}
+
+func hashPOSToken(token string) string {
+  sum := sha256.Sum256([]byte(token))
+  return hex.EncodeToString(sum[:])
+}
+
+func newPOSToken() (string, error) {
+  token, err := randomToken(24)
+  if err != nil {
+    return "", err
+  }
+  return posTokenPrefix + token, nil
+}
+
+// matchPOSRegister finds the register a token belongs to. Every hash is
+// compared so the time taken does not depend on which one matches.
+func matchPOSRegister(registers []posRegister, token string) (posRegister, bool) {
+  if !strings.HasPrefix(token, posTokenPrefix) {
+    return posRegister{}, false
+  }
+  hash := []byte(hashPOSToken(token))
+  var (
+    found posRegister
+    ok bool
+  )
+  for _, reg := range registers {
+    if subtle.ConstantTimeCompare(hash, []byte(reg.TokenHash)) == 1 {
+      found, ok = reg, true
+    }
+  }
+  return found, ok
+}
+
+// posToken reads the bearer token, or ?token= for EventSource, which cannot
+// set headers.
+func posToken(r *http.Request) string {
+  if auth := strings.TrimSpace(r.Header.Get("Authorization")); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+    return strings.TrimSpace(auth[7:])
+  }
+  return strings.TrimSpace(r.URL.Query().Get("token"))
+}
+
+func isPOSTerminalPath(path string) bool {
+  return strings.HasPrefix(path, posTerminalPrefix)
+}
+
+type posRegisterKey struct{}
+
+// requirePOSToken authenticates terminal routes by register token. They skip
+// requireAuth, so failed tokens count towards the same per-IP lockout as
+// failed logins.
+func (s *Server) requirePOSToken(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    ip := clientIP(r)
+    now := time.Now()
+    if apiAuthLimited(ip, now) {
+      writeError(w, http.StatusTooManyRequests, "too many failed logins, try again later")
+      return
+    }
+    token := posToken(r)
+    posRegistersMu.Lock()
+    registers, err := loadPOSRegisters()
+    posRegistersMu.Unlock()
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load pos registers: %v", err))
+      return
+    }
+    reg, ok := matchPOSRegister(registers, token)
+    if !ok {
+      if token != "" {
+        recordAPIAuthResult(ip, false, now)
+      }
+      w.Header().Set("WWW-Authenticate", `Bearer realm="LightningOS PoS"`)
+      writeError(w, http.StatusUnauthorized, "invalid pos token")
+      return
+    }
+    next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), posRegisterKey{}, reg)))
+  })
+}
+
+func posRegisterFor(r *http.Request) posRegister {
+  reg, _ := r.Context().Value(posRegisterKey{}).(posRegister)
+  return reg
+}
+
+// posNotifier returns the notifier of the register's node. Settlement comes
+// from its invoice stream, so registers need notifications enabled.
+func (s *Server) posNotifier(w http.ResponseWriter, reg posRegister) *Notifier {
+  notifier, ok := s.notifierForNode(reg.NodeID)
+  if !ok {
+    writeError(w, http.StatusConflict, unknownNodeError(reg.NodeID).Error())
+    return nil
+  }
+  if notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+  }
+  return notifier
+}
+
+func (n *Notifier) ensurePOSSchema(ctx context.Context) error {
+  _, err := n.db.Exec(ctx, `
+create table if not exists pos_invoices (
+  payment_hash text primary key,
+  register_id text not null,
+  node_id text not null,
+  amount_sat bigint not null,
+  memo text not null default '',
+  created_at timestamptz not null,
+  expires_at timestamptz not null
+);
+create index if not exists pos_invoices_register_created_idx on pos_invoices (register_id, created_at desc);
+`)
+  return err
+}
+
+func (s *Server) handlePOSRegistersList(w http.ResponseWriter, r *http.Request) {
+  posRegistersMu.Lock()
+  registers, err := loadPOSRegisters()
+  posRegistersMu.Unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load pos registers: %v", err))
+    return
+  }
+  items := make([]posRegisterView, 0, len(registers))
+  for _, reg := range registers {
+    items = append(items, reg.view())
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handlePOSRegistersCreate adds a register and returns its token, which is
+// only stored hashed and never shown again.
+func (s *Server) handlePOSRegistersCreate(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Name string `json:"name"`
+    NodeID string `json:"node_id"`
+    MaxAmountSat int64 `json:"max_amount_sat"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  req.Name = strings.TrimSpace(req.Name)
+  req.NodeID = strings.TrimSpace(req.NodeID)
+  if req.Name == "" {
+    writeError(w, http.StatusBadRequest, "name required")
+    return
+  }
+  if len(req.Name) > maxPOSRegisterName {
+    writeError(w, http.StatusBadRequest, "name too long")
+    return
+  }
+  if req.NodeID == "" {
+    req.NodeID = config.DefaultNodeID
+  }
+  if _, ok := s.nodes[req.NodeID]; !ok {
+    writeError(w, http.StatusBadRequest, "unknown node")
+    return
+  }
+  if req.MaxAmountSat < 0 {
+    writeError(w, http.StatusBadRequest, "max_amount_sat must be zero or positive")
+    return
+  }
+
+  id, err := randomToken(6)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to create pos register")
+    return
+  }
+  token, err := newPOSToken()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to create pos register")
+    return
+  }
+  reg := posRegister{
+    ID: id,
+    Name: req.Name,
+    NodeID: req.NodeID,
+    MaxAmountSat: req.MaxAmountSat,
+    TokenHash: hashPOSToken(token),
+    CreatedAt: time.Now().UTC(),
+  }
+
+  posRegistersMu.Lock()
+  defer posRegistersMu.Unlock()
+  registers, err := loadPOSRegisters()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load pos registers: %v", err))
+    return
+  }
+  if len(registers) >= maxPOSRegisters {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("too many pos registers: max %d", maxPOSRegisters))
+    return
+  }
+  if err := savePOSRegisters(append(registers, reg)); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save pos registers: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"register": reg.view(), "token": token})
+}
+
+// handlePOSRegistersRotate replaces a register's token, locking out the
+// device holding the old one.
+func (s *Server) handlePOSRegistersRotate(w http.ResponseWriter, r *http.Request) {
+  id := chi.URLParam(r, "id")
+  token, err := newPOSToken()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to create pos register")
+    return
+  }
+  posRegistersMu.Lock()
+  defer posRegistersMu.Unlock()
+  registers, err := loadPOSRegisters()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load pos registers: %v", err))
+    return
+  }
+  for i := range registers {
+    if registers[i].ID != id {
+      continue
+    }
+    registers[i].TokenHash = hashPOSToken(token)
+    if err := savePOSRegisters(registers); err != nil {
+      writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save pos registers: %v", err))
+      return
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"register": registers[i].view(), "token": token})
+    return
+  }
+  writeError(w, http.StatusNotFound, "pos register not found")
+}
+
+// handlePOSRegistersDelete removes a register. Its invoices stay for the
+// accounting history.
+func (s *Server) handlePOSRegistersDelete(w http.ResponseWriter, r *http.Request) {
+  id := chi.URLParam(r, "id")
+  posRegistersMu.Lock()
+  defer posRegistersMu.Unlock()
+  registers, err := loadPOSRegisters()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load pos registers: %v", err))
+    return
+  }
+  kept := registers[:0]
+  for _, reg := range registers {
+    if reg.ID != id {
+      kept = append(kept, reg)
+    }
+  }
+  if len(kept) == len(registers) {
+    writeError(w, http.StatusNotFound, "pos register not found")
+    return
+  }
+  if err := savePOSRegisters(kept); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save pos registers: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func (s *Server) handlePOSInvoiceCreate(w http.ResponseWriter, r *http.Request) {
+  reg := posRegisterFor(r)
+  var req struct {
+    AmountSat int64 `json:"amount_sat"`
+    Memo string `json:"memo"`
+    ExpirySeconds int64 `json:"expiry_seconds"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  req.Memo = strings.TrimSpace(req.Memo)
+  if req.AmountSat <= 0 {
+    writeError(w, http.StatusBadRequest, "amount_sat must be positive")
+    return
+  }
+  if reg.MaxAmountSat > 0 && req.AmountSat > reg.MaxAmountSat {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("amount above register limit: max %d sat", reg.MaxAmountSat))
+    return
+  }
+  if len(req.Memo) > paymentRequestMaxMemo {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("memo too long: max %d bytes", paymentRequestMaxMemo))
+    return
+  }
+  if req.ExpirySeconds == 0 {
+    req.ExpirySeconds = posInvoiceDefaultExpiry
+  }
+  if req.ExpirySeconds < 60 || req.ExpirySeconds > posInvoiceMaxExpiry {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("expiry_seconds out of range: 60 to %d", posInvoiceMaxExpiry))
+    return
+  }
+  notifier := s.posNotifier(w, reg)
+  if notifier == nil {
+    return
+  }
+  lnd, ok := s.lndForNode(reg.NodeID)
+  if !ok {
+    writeError(w, http.StatusConflict, unknownNodeError(reg.NodeID).Error())
+    return
+  }
+  if msg := lndNotReadyMessage(lnd.Lifecycle()); msg != "" {
+    w.Header().Set("Retry-After", "5")
+    writeError(w, http.StatusServiceUnavailable, msg)
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  invoice, err := lnd.CreateInvoice(ctx, req.AmountSat, req.Memo, req.ExpirySeconds)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "invoice failed")
+    return
+  }
+  createdAt := time.Now().UTC()
+  expiresAt := createdAt.Add(time.Duration(req.ExpirySeconds) * time.Second)
+  _, err = notifier.db.Exec(ctx, `
+insert into pos_invoices (payment_hash, register_id, node_id, amount_sat, memo, created_at, expires_at)
+values ($1, $2, $3, $4, $5, $6, $7)
+`, normalizeHash(invoice.PaymentHash), reg.ID, notifier.nodeKey(), req.AmountSat, req.Memo, createdAt, expiresAt)
+  if err != nil {
+    // Without the row the sale would never show up in the totals, so the
+    // invoice is not handed out.
+    writeError(w, http.StatusInternalServerError, "failed to record pos invoice")
+    return
+  }
+  qr, err := renderQR(strings.ToUpper(invoice.PaymentRequest), "svg")
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to render qr code")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "payment_request": invoice.PaymentRequest,
+    "payment_hash": normalizeHash(invoice.PaymentHash),
+    "amount_sat": req.AmountSat,
+    "memo": req.Memo,
+    "created_at": createdAt,
+    "expires_at": expiresAt,
+    "qr": qr,
+  })
+}
+
+type posInvoiceStatus struct {
+  PaymentHash string `json:"payment_hash"`
+  AmountSat int64 `json:"amount_sat"`
+  Memo string `json:"memo"`
+  CreatedAt time.Time `json:"created_at"`
+  ExpiresAt time.Time `json:"expires_at"`
+  // Status is open, paid or expired.
+  Status string `json:"status"`
+  PaidAt *time.Time `json:"paid_at,omitempty"`
+  AmountPaidSat int64 `json:"amount_paid_sat"`
+}
+
+func (s *Server) handlePOSInvoiceGet(w http.ResponseWriter, r *http.Request) {
+  reg := posRegisterFor(r)
+  notifier := s.posNotifier(w, reg)
+  if notifier == nil {
+    return
+  }
+  hash := normalizeHash(chi.URLParam(r, "hash"))
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+
+  var (
+    item posInvoiceStatus
+    paidAmount *int64
+  )
+  err := notifier.db.QueryRow(ctx, `
+select p.payment_hash, p.amount_sat, p.memo, p.created_at, p.expires_at, n.occurred_at, n.amount_sat
+from pos_invoices p
+left join notifications n on n.payment_hash = p.payment_hash and n.node_id = p.node_id
+  and n.type = 'lightning' and n.status = 'SETTLED'
+where p.register_id = $1 and p.payment_hash = $2
+limit 1
+`, reg.ID, hash).Scan(&item.PaymentHash, &item.AmountSat, &item.Memo, &item.CreatedAt, &item.ExpiresAt, &item.PaidAt, &paidAmount)
+  if errors.Is(err, pgx.ErrNoRows) {
+    writeError(w, http.StatusNotFound, "pos invoice not found")
+    return
+  }
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load pos invoice")
+    return
+  }
+  switch {
+  case item.PaidAt != nil:
+    item.Status = "paid"
+    if paidAmount != nil {
+      item.AmountPaidSat = *paidAmount
+    }
+  case time.Now().After(item.ExpiresAt):
+    item.Status = "expired"
+  default:
+    item.Status = "open"
+  }
+  writeJSON(w, http.StatusOK, item)
+}
+
+// handlePOSEvents streams a "paid" event for each of the register's invoices
+// as it settles. A tablet that reconnects reads GET invoices/{hash} for the
+// one it is waiting on.
+func (s *Server) handlePOSEvents(w http.ResponseWriter, r *http.Request) {
+  reg := posRegisterFor(r)
+  notifier := s.posNotifier(w, reg)
+  if notifier == nil {
+    return
+  }
+  flusher, ok := w.(http.Flusher)
+  if !ok {
+    writeError(w, http.StatusInternalServerError, "stream not supported")
+    return
+  }
+
+  sub := notifier.subscribe("pos-"+reg.ID, clientIP(r), subscriberFilter{Types: []string{"lightning"}, IncludeMuted: true})
+  defer notifier.unsubscribe(sub)
+
+  w.Header().Set("Content-Type", "text/event-stream")
+  w.Header().Set("Cache-Control", "no-cache")
+  w.Header().Set("Connection", "keep-alive")
+  ready, _ := json.Marshal(map[string]any{"register_id": reg.ID})
+  _, _ = fmt.Fprintf(w, "event: ready\ndata: %s\n\n", ready)
+  flusher.Flush()
+
+  ticker := time.NewTicker(streamHeartbeat)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-r.Context().Done():
+      return
+    case <-sub.done:
+      _, _ = w.Write([]byte("event: overflow\ndata: {}\n\n"))
+      flusher.Flush()
+      return
+    case <-sub.wake:
+      for _, evt := range sub.take() {
+        if evt.Action != "received" || evt.Status != "SETTLED" || evt.PaymentHash == "" {
+          continue
+        }
+        ctx, cancel := s.requestContext(r, timeoutShort)
+        var memo string
+        err := notifier.db.QueryRow(ctx, `select memo from pos_invoices where register_id = $1 and payment_hash = $2`,
+          reg.ID, evt.PaymentHash).Scan(&memo)
+        cancel()
+        if err != nil {
+          continue
+        }
+        payload, _ := json.Marshal(map[string]any{
+          "payment_hash": evt.PaymentHash,
+          "amount_sat": evt.AmountSat,
+          "memo": memo,
+          "paid_at": evt.OccurredAt,
+        })
+        _, _ = fmt.Fprintf(w, "event: paid\ndata: %s\n\n", payload)
+      }
+      flusher.Flush()
+    case <-ticker.C:
+      _, _ = w.Write([]byte("event: heartbeat\ndata: {}\n\n"))
+      flusher.Flush()
+    }
+  }
+}
+
+type posDailyTotal struct {
+  Day string `json:"day"`
+  Payments int64 `json:"payments"`
+  AmountSat int64 `json:"amount_sat"`
+}
+
+// handlePOSTotals sums the register's settled invoices per UTC day of
+// settlement.
+func (s *Server) handlePOSTotals(w http.ResponseWriter, r *http.Request) {
+  reg := posRegisterFor(r)
+  days := posTotalsDefaultDays
+  if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > posTotalsMaxDays {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("days out of range: 1 to %d", posTotalsMaxDays))
+      return
+    }
+    days = parsed
+  }
+  notifier := s.posNotifier(w, reg)
+  if notifier == nil {
+    return
+  }
+  now := time.Now().UTC()
+  since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1-days)
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  rows, err := notifier.db.Query(ctx, `
+select to_char(n.occurred_at at time zone 'UTC', 'YYYY-MM-DD') as day, count(*), coalesce(sum(n.amount_sat), 0)
+from pos_invoices p
+join notifications n on n.payment_hash = p.payment_hash and n.node_id = p.node_id
+  and n.type = 'lightning' and n.status = 'SETTLED'
+where p.register_id = $1 and n.occurred_at >= $2
+group by 1
+order by 1
+`, reg.ID, since)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load pos totals")
+    return
+  }
+  defer rows.Close()
+  items := []posDailyTotal{}
+  var payments, amount int64
+  for rows.Next() {
+    var item posDailyTotal
+    if err := rows.Scan(&item.Day, &item.Payments, &item.AmountSat); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to load pos totals")
+      return
+    }
+    payments += item.Payments
+    amount += item.AmountSat
+    items = append(items, item)
+  }
+  if err := rows.Err(); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load pos totals")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "register": reg.view(),
+    "since": since,
+    "days": days,
+    "items": items,
+    "total_payments": payments,
+    "total_amount_sat": amount,
+  })
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+func TestMatchPOSRegister(t *testing.T) {
+  token, err := newPOSToken()
+  if err != nil {
+    t.Fatal(err)
+  }
+  registers := []posRegister{
+    {ID: "a", TokenHash: hashPOSToken("pos_other")},
+    {ID: "b", TokenHash: hashPOSToken(token)},
+  }
+  if reg, ok := matchPOSRegister(registers, token); !ok || reg.ID != "b" {
+    t.Fatalf("token not matched: %+v %v", reg, ok)
+  }
+  for _, bad := range []string{"", "pos_", token + "x", hashPOSToken(token)} {
+    if _, ok := matchPOSRegister(registers, bad); ok {
+      t.Fatalf("%q matched", bad)
+    }
+  }
+}
+
+func TestPOSToken(t *testing.T) {
+  r := httptest.NewRequest(http.MethodGet, "/api/pos/terminal/events?token=pos_query", nil)
+  if got := posToken(r); got != "pos_query" {
+    t.Fatalf("query token %q", got)
+  }
+  r.Header.Set("Authorization", "Bearer pos_header")
+  if got := posToken(r); got != "pos_header" {
+    t.Fatalf("header token %q", got)
+  }
+  if !isPOSTerminalPath("/api/pos/terminal/invoices") || isPOSTerminalPath("/api/pos/registers") {
+    t.Fatal("terminal path check")
+  }
+}
+
+func TestPOSTerminalRequiresToken(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  rec := serveAPI(s, http.MethodPost, "/api/pos/terminal/invoices", `{"amount_sat":1000}`)
+  if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+    t.Fatalf("terminal without token: %d", rec.Code)
+  }
+  if len(fake.Calls("CreateInvoice")) != 0 {
+    t.Fatal("invoice created without a token")
+  }
+}
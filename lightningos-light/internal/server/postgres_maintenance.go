@@ -0,0 +1,335 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "net/url"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "syscall"
+  "time"
+
+  "github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+  postgresBackupDir = "/var/lib/lightningos/backups/postgres"
+  postgresBackupKeep = 7
+  postgresBackupTimeout = 30 * time.Minute
+  // Telegram bots cannot upload documents larger than 50 MB.
+  postgresBackupTelegramMaxBytes = 50 * 1024 * 1024
+  postgresLongQueryThreshold = time.Minute
+)
+
+type postgresBackupState struct {
+  mu sync.Mutex
+  running bool
+  startedAt time.Time
+  finishedAt time.Time
+  lastError string
+}
+
+type postgresBackupStatus struct {
+  Running bool `json:"running"`
+  StartedAt *time.Time `json:"started_at,omitempty"`
+  FinishedAt *time.Time `json:"finished_at,omitempty"`
+  LastError string `json:"last_error,omitempty"`
+  Dir string `json:"dir"`
+}
+
+type postgresBackupFile struct {
+  Name string `json:"name"`
+  SizeBytes int64 `json:"size_bytes"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+type postgresLongQuery struct {
+  PID int32 `json:"pid"`
+  Database string `json:"database"`
+  State string `json:"state"`
+  DurationSec int64 `json:"duration_sec"`
+  Query string `json:"query"`
+}
+
+type postgresStorage struct {
+  WALSizeMB *int64 `json:"wal_size_mb,omitempty"`
+  DataDir string `json:"data_dir,omitempty"`
+  DiskTotalMB int64 `json:"disk_total_mb,omitempty"`
+  DiskFreeMB int64 `json:"disk_free_mb,omitempty"`
+  DiskUsedPercent float64 `json:"disk_used_percent,omitempty"`
+}
+
+func (b *postgresBackupState) status() postgresBackupStatus {
+  b.mu.Lock()
+  defer b.mu.Unlock()
+  status := postgresBackupStatus{Running: b.running, LastError: b.lastError, Dir: postgresBackupDir}
+  if !b.startedAt.IsZero() {
+    started := b.startedAt
+    status.StartedAt = &started
+  }
+  if !b.finishedAt.IsZero() {
+    finished := b.finishedAt
+    status.FinishedAt = &finished
+  }
+  return status
+}
+
+// postgresBackups lists the stored dumps of a database, newest first.
+func postgresBackups(dbName string) []postgresBackupFile {
+  entries, err := os.ReadDir(postgresBackupDir)
+  if err != nil {
+    return nil
+  }
+  prefix := dbName + "-"
+  var files []postgresBackupFile
+  for _, entry := range entries {
+    name := entry.Name()
+    if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".dump") {
+      continue
+    }
+    info, err := entry.Info()
+    if err != nil {
+      continue
+    }
+    files = append(files, postgresBackupFile{Name: name, SizeBytes: info.Size(), CreatedAt: info.ModTime().UTC()})
+  }
+  sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+  return files
+}
+
+func prunePostgresBackups(dbName string) {
+  files := postgresBackups(dbName)
+  for i := postgresBackupKeep; i < len(files); i++ {
+    _ = os.Remove(filepath.Join(postgresBackupDir, files[i].Name))
+  }
+}
+
+// postgresStorageStats reports WAL and data disk usage. Both need elevated
+// privileges (pg_monitor or superuser), so failures are left blank.
+func postgresStorageStats(ctx context.Context, pool *pgxpool.Pool) postgresStorage {
+  var storage postgresStorage
+  var walBytes int64
+  if err := pool.QueryRow(ctx, "select coalesce(sum(size), 0) from pg_ls_waldir()").Scan(&walBytes); err == nil {
+    walMB := walBytes / (1024 * 1024)
+    storage.WALSizeMB = &walMB
+  }
+  var dataDir string
+  if err := pool.QueryRow(ctx, "show data_directory").Scan(&dataDir); err != nil || strings.TrimSpace(dataDir) == "" {
+    dataDir = "/var/lib/postgresql"
+  }
+  storage.DataDir = dataDir
+  var stat syscall.Statfs_t
+  if err := syscall.Statfs(dataDir, &stat); err == nil {
+    total := int64(stat.Blocks) * int64(stat.Bsize)
+    free := int64(stat.Bavail) * int64(stat.Bsize)
+    storage.DiskTotalMB = total / (1024 * 1024)
+    storage.DiskFreeMB = free / (1024 * 1024)
+    if total > 0 {
+      storage.DiskUsedPercent = float64(total-free) * 100 / float64(total)
+    }
+  }
+  return storage
+}
+
+func postgresLongQueries(ctx context.Context, pool *pgxpool.Pool) []postgresLongQuery {
+  rows, err := pool.Query(ctx, `
+select pid, coalesce(datname, ''), coalesce(state, ''),
+  extract(epoch from now() - query_start)::bigint, left(query, 300)
+from pg_stat_activity
+where state <> 'idle'
+  and query_start is not null
+  and query_start < now() - $1::interval
+  and pid <> pg_backend_pid()
+order by query_start asc
+limit 20
+`, fmt.Sprintf("%d seconds", int(postgresLongQueryThreshold.Seconds())))
+  if err != nil {
+    return nil
+  }
+  defer rows.Close()
+  var items []postgresLongQuery
+  for rows.Next() {
+    var item postgresLongQuery
+    if err := rows.Scan(&item.PID, &item.Database, &item.State, &item.DurationSec, &item.Query); err != nil {
+      return items
+    }
+    items = append(items, item)
+  }
+  return items
+}
+
+type postgresBackupRequest struct {
+  Source string `json:"source"`
+  Telegram bool `json:"telegram"`
+  Passphrase string `json:"passphrase"`
+}
+
+func (s *Server) handlePostgresBackup(w http.ResponseWriter, r *http.Request) {
+  var req postgresBackupRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  source := strings.TrimSpace(req.Source)
+  var targets []postgresDSNEntry
+  for _, entry := range postgresDSNEntries() {
+    if source == "" || entry.Source == source {
+      targets = append(targets, entry)
+    }
+  }
+  if len(targets) == 0 {
+    writeError(w, http.StatusBadRequest, "no matching database configured")
+    return
+  }
+  if _, err := exec.LookPath("pg_dump"); err != nil {
+    writeError(w, http.StatusServiceUnavailable, "pg_dump not installed")
+    return
+  }
+  if req.Telegram && !readTelegramBackupConfig().configured() {
+    writeError(w, http.StatusBadRequest, "telegram backup not configured")
+    return
+  }
+  // Dumps hold the channel state and macaroon roots of LND; they only leave
+  // the machine encrypted.
+  if req.Telegram && req.Passphrase == "" {
+    writeError(w, http.StatusBadRequest, "passphrase required")
+    return
+  }
+  if req.Telegram && len(req.Passphrase) < scbEscrowMinPassphrase {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("passphrase must have at least %d characters", scbEscrowMinPassphrase))
+    return
+  }
+
+  s.pgBackup.mu.Lock()
+  if s.pgBackup.running {
+    s.pgBackup.mu.Unlock()
+    writeError(w, http.StatusConflict, "backup already running")
+    return
+  }
+  s.pgBackup.running = true
+  s.pgBackup.startedAt = time.Now().UTC()
+  s.pgBackup.lastError = ""
+  s.pgBackup.mu.Unlock()
+
+  passphrase := ""
+  if req.Telegram {
+    passphrase = req.Passphrase
+  }
+  go s.runPostgresBackup(targets, passphrase)
+  writeJSON(w, http.StatusAccepted, s.pgBackup.status())
+}
+
+// runPostgresBackup dumps each target; with a passphrase the dumps are also
+// sent to Telegram, sealed with it.
+func (s *Server) runPostgresBackup(targets []postgresDSNEntry, passphrase string) {
+  defer crashLog.capture("postgres/backup")
+  ctx, cancel := s.taskContextFor("postgres/backup", postgresBackupTimeout)
+  defer cancel()
+
+  var errs []string
+  for _, entry := range targets {
+    path, err := dumpPostgresDatabase(ctx, entry)
+    if err != nil {
+      s.logger.Printf("postgres backup %s failed: %v", entry.Source, err)
+      errs = append(errs, fmt.Sprintf("%s: %v", entry.Source, err))
+      continue
+    }
+    s.logger.Printf("postgres backup %s stored at %s", entry.Source, path)
+    if passphrase != "" {
+      if err := sendPostgresBackupTelegram(ctx, path, passphrase); err != nil {
+        s.logger.Printf("postgres backup %s telegram upload failed: %v", entry.Source, err)
+        errs = append(errs, fmt.Sprintf("%s telegram: %v", entry.Source, err))
+      }
+    }
+  }
+
+  s.pgBackup.mu.Lock()
+  s.pgBackup.running = false
+  s.pgBackup.finishedAt = time.Now().UTC()
+  s.pgBackup.lastError = strings.Join(errs, "; ")
+  s.pgBackup.mu.Unlock()
+}
+
+func dumpPostgresDatabase(ctx context.Context, entry postgresDSNEntry) (string, error) {
+  dbName := databaseNameFromDSN(entry.DSN)
+  if dbName == "" {
+    return "", errors.New("database name missing from dsn")
+  }
+  if err := os.MkdirAll(postgresBackupDir, 0o700); err != nil {
+    return "", err
+  }
+  name := fmt.Sprintf("%s-%s.dump", dbName, time.Now().UTC().Format("20060102-150405"))
+  path := filepath.Join(postgresBackupDir, name)
+  tmp := path + ".partial"
+
+  // The password goes in the environment, not on the command line where
+  // any local user can read it from ps.
+  dsn, password := splitDSNPassword(entry.DSN)
+  cmd := exec.CommandContext(ctx, "pg_dump", "--format=custom", "--file="+tmp, "--dbname="+dsn)
+  if password != "" {
+    cmd.Env = append(os.Environ(), "PGPASSWORD="+password)
+  }
+  if out, err := cmd.CombinedOutput(); err != nil {
+    _ = os.Remove(tmp)
+    msg := strings.TrimSpace(string(out))
+    if msg == "" {
+      msg = err.Error()
+    }
+    return "", errors.New(msg)
+  }
+  if err := os.Rename(tmp, path); err != nil {
+    _ = os.Remove(tmp)
+    return "", err
+  }
+  prunePostgresBackups(dbName)
+  return path, nil
+}
+
+// splitDSNPassword removes the password from a postgres:// DSN and returns it
+// separately.
+func splitDSNPassword(raw string) (string, string) {
+  parsed, err := url.Parse(raw)
+  if err != nil || parsed.User == nil {
+    return raw, ""
+  }
+  password, ok := parsed.User.Password()
+  if !ok {
+    return raw, ""
+  }
+  parsed.User = url.User(parsed.User.Username())
+  return parsed.String(), password
+}
+
+// sendPostgresBackupTelegram uploads a dump sealed like the secrets export,
+// so `lightningos-manager secrets-decrypt` opens it.
+func sendPostgresBackupTelegram(ctx context.Context, path, passphrase string) error {
+  info, err := os.Stat(path)
+  if err != nil {
+    return err
+  }
+  if info.Size() > postgresBackupTelegramMaxBytes {
+    return fmt.Errorf("dump is %d MB, above the telegram upload limit", info.Size()/(1024*1024))
+  }
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return err
+  }
+  sealed, err := sealSecretsExport(data, passphrase)
+  if err != nil {
+    return err
+  }
+  cfg := readTelegramBackupConfig()
+  name := filepath.Base(path) + ".enc"
+  caption := "LightningOS postgres backup " + name + " (encrypted; open with lightningos-manager secrets-decrypt)"
+  return sendTelegramDocument(ctx, cfg.BotToken, cfg.ChatID, name, sealed, caption)
+}
+
+func (s *Server) handlePostgresBackupStatus(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, s.pgBackup.status())
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "regexp"
+  "strings"
+  "time"
+
+  "github.com/jackc/pgx/v5"
+)
+
+var fiatCurrencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var preferenceDateLayouts = map[string]string{
+  "iso": "2006-01-02",
+  "dmy": "02/01/2006",
+  "mdy": "01/02/2006",
+}
+
+// UserPreferences is the display formatting shared by every client (UI tabs,
+// phones) and used when the manager renders amounts and dates itself, e.g. in
+// report notifications.
+type UserPreferences struct {
+  Unit string `json:"unit"`
+  FiatCurrency string `json:"fiat_currency"`
+  DateFormat string `json:"date_format"`
+  Timezone string `json:"timezone"`
+  UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type userPreferencesRequest struct {
+  Unit *string `json:"unit"`
+  FiatCurrency *string `json:"fiat_currency"`
+  DateFormat *string `json:"date_format"`
+  Timezone *string `json:"timezone"`
+}
+
+func defaultUserPreferences() UserPreferences {
+  return UserPreferences{Unit: "sats", FiatCurrency: "USD", DateFormat: "iso"}
+}
+
+// apply merges the fields set in req into prefs.
+func (req userPreferencesRequest) apply(prefs UserPreferences) (UserPreferences, error) {
+  if req.Unit != nil {
+    unit := strings.ToLower(strings.TrimSpace(*req.Unit))
+    if unit != "sats" && unit != "btc" {
+      return prefs, errors.New("unit must be sats or btc")
+    }
+    prefs.Unit = unit
+  }
+  if req.FiatCurrency != nil {
+    currency := strings.ToUpper(strings.TrimSpace(*req.FiatCurrency))
+    if !fiatCurrencyPattern.MatchString(currency) {
+      return prefs, errors.New("fiat_currency must be a 3-letter ISO 4217 code")
+    }
+    prefs.FiatCurrency = currency
+  }
+  if req.DateFormat != nil {
+    format := strings.ToLower(strings.TrimSpace(*req.DateFormat))
+    if _, ok := preferenceDateLayouts[format]; !ok {
+      return prefs, errors.New("date_format must be iso, dmy or mdy")
+    }
+    prefs.DateFormat = format
+  }
+  if req.Timezone != nil {
+    name := strings.TrimSpace(*req.Timezone)
+    if name != "" {
+      if _, err := time.LoadLocation(name); err != nil {
+        return prefs, errors.New("timezone must be an IANA zone name")
+      }
+    }
+    prefs.Timezone = name
+  }
+  return prefs, nil
+}
+
+// formatSats renders an amount in the preferred unit.
+func (p UserPreferences) formatSats(sat int64) string {
+  if p.Unit == "btc" {
+    return fmt.Sprintf("%.8f BTC", float64(sat)/1e8)
+  }
+  return fmt.Sprintf("%d sats", sat)
+}
+
+// formatDate renders a calendar day in the preferred format. Report days are
+// already local dates, so no timezone conversion happens here.
+func (p UserPreferences) formatDate(day time.Time) string {
+  layout, ok := preferenceDateLayouts[p.DateFormat]
+  if !ok {
+    layout = preferenceDateLayouts["iso"]
+  }
+  return day.Format(layout)
+}
+
+func (n *Notifier) ensurePreferencesSchema(ctx context.Context) error {
+  _, err := n.db.Exec(ctx, `
+create table if not exists user_preferences (
+  id smallint primary key default 1 check (id = 1),
+  unit text not null default 'sats',
+  fiat_currency text not null default 'USD',
+  date_format text not null default 'iso',
+  timezone text not null default '',
+  updated_at timestamptz not null default now()
+);
+`)
+  return err
+}
+
+func (n *Notifier) loadPreferences(ctx context.Context) error {
+  prefs := defaultUserPreferences()
+  var updatedAt time.Time
+  err := n.db.QueryRow(ctx, `
+select unit, fiat_currency, date_format, timezone, updated_at
+from user_preferences where id=1`).Scan(&prefs.Unit, &prefs.FiatCurrency, &prefs.DateFormat, &prefs.Timezone, &updatedAt)
+  if err == nil {
+    prefs.UpdatedAt = &updatedAt
+  } else if err != pgx.ErrNoRows {
+    return err
+  }
+  n.prefsMu.Lock()
+  n.prefs = prefs
+  n.prefsMu.Unlock()
+  return nil
+}
+
+func (n *Notifier) preferences() UserPreferences {
+  n.prefsMu.RLock()
+  defer n.prefsMu.RUnlock()
+  if n.prefs.Unit == "" {
+    return defaultUserPreferences()
+  }
+  return n.prefs
+}
+
+func (n *Notifier) savePreferences(ctx context.Context, prefs UserPreferences) (UserPreferences, error) {
+  var updatedAt time.Time
+  err := n.db.QueryRow(ctx, `
+insert into user_preferences (id, unit, fiat_currency, date_format, timezone, updated_at)
+values (1, $1, $2, $3, $4, now())
+on conflict (id) do update set unit=excluded.unit, fiat_currency=excluded.fiat_currency,
+  date_format=excluded.date_format, timezone=excluded.timezone, updated_at=excluded.updated_at
+returning updated_at`, prefs.Unit, prefs.FiatCurrency, prefs.DateFormat, prefs.Timezone).Scan(&updatedAt)
+  if err != nil {
+    return UserPreferences{}, err
+  }
+  prefs.UpdatedAt = &updatedAt
+  n.prefsMu.Lock()
+  n.prefs = prefs
+  n.prefsMu.Unlock()
+  return prefs, nil
+}
+
+func (s *Server) handlePreferencesGet(w http.ResponseWriter, r *http.Request) {
+  if s.notifier == nil {
+    writeJSON(w, http.StatusOK, defaultUserPreferences())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  if err := s.notifier.loadPreferences(ctx); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load preferences")
+    return
+  }
+  writeJSON(w, http.StatusOK, s.notifier.preferences())
+}
+
+func (s *Server) handlePreferencesPost(w http.ResponseWriter, r *http.Request) {
+  if s.notifier == nil {
+    writeError(w, http.StatusServiceUnavailable, "notifications disabled")
+    return
+  }
+  var req userPreferencesRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+
+  // Merge into the stored row, not the cache, so clients only overwrite the
+  // fields they send.
+  if err := s.notifier.loadPreferences(ctx); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load preferences")
+    return
+  }
+  prefs, err := req.apply(s.notifier.preferences())
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  saved, err := s.notifier.savePreferences(ctx, prefs)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store preferences")
+    return
+  }
+  writeJSON(w, http.StatusOK, saved)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+  "testing"
+  "time"
+
+  "lightningos-light/internal/reports"
+)
+
+func TestUserPreferencesApply(t *testing.T) {
+  unit := "BTC"
+  currency := "brl"
+  prefs, err := userPreferencesRequest{Unit: &unit, FiatCurrency: &currency}.apply(defaultUserPreferences())
+  if err != nil {
+    t.Fatalf("unexpected error: %v", err)
+  }
+  if prefs.Unit != "btc" || prefs.FiatCurrency != "BRL" || prefs.DateFormat != "iso" {
+    t.Fatalf("unexpected preferences: %+v", prefs)
+  }
+
+  bad := "Mars/Olympus"
+  if _, err := (userPreferencesRequest{Timezone: &bad}).apply(prefs); err == nil {
+    t.Fatalf("expected invalid timezone to fail")
+  }
+  format := "ymd"
+  if _, err := (userPreferencesRequest{DateFormat: &format}).apply(prefs); err == nil {
+    t.Fatalf("expected invalid date format to fail")
+  }
+}
+
+func TestReportAnomalyNotificationUsesPreferences(t *testing.T) {
+  anomaly := reports.Anomaly{
+    Kind: reports.AnomalyRevenueDrop,
+    Date: time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC),
+    CurrentMsat: 1500000,
+    BaselineMsat: 15000000,
+    ChangePct: -90,
+  }
+  evt := reportAnomalyNotification(anomaly, 7, defaultUserPreferences())
+  want := "Forward revenue on 2026-03-09 was 1500 sats, 90% below the 7-day average of 15000 sats"
+  if evt.Memo != want {
+    t.Fatalf("unexpected memo:\n%s", evt.Memo)
+  }
+
+  prefs := UserPreferences{Unit: "btc", DateFormat: "dmy"}
+  evt = reportAnomalyNotification(anomaly, 7, prefs)
+  want = "Forward revenue on 09/03/2026 was 0.00001500 BTC, 90% below the 7-day average of 0.00015000 BTC"
+  if evt.Memo != want {
+    t.Fatalf("unexpected memo:\n%s", evt.Memo)
+  }
+}
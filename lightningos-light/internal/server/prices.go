@@ -0,0 +1,380 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "math"
+  "net/http"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+// The price service is the one place BTC prices come from. Providers are
+// asked in order (mempool.space, CoinGecko, Kraken) until one quotes the
+// currency; a quote is reused for five minutes, and when every provider
+// fails the last one is served, marked stale, for up to a day. With
+// Postgres, the USD price and the preferred fiat currency's are stored
+// every hour for reports and charts.
+const (
+  priceCacheTTL = 5 * time.Minute
+  priceStaleTTL = 24 * time.Hour
+  priceSampleInterval = time.Hour
+  priceHistoryMaxDays = 365
+  priceFetchTimeout = 10 * time.Second
+)
+
+var (
+  coingeckoPriceURL = "https://api.coingecko.com/api/v3/simple/price"
+  krakenTickerURL = "https://api.kraken.com/0/public/Ticker"
+)
+
+type priceQuote struct {
+  Currency string `json:"currency"`
+  Price float64 `json:"price"`
+  Source string `json:"source"`
+  FetchedAt time.Time `json:"fetched_at"`
+  Stale bool `json:"stale,omitempty"`
+}
+
+type priceProvider struct {
+  name string
+  fetch func(ctx context.Context, currency string) (float64, error)
+}
+
+type priceService struct {
+  providers []priceProvider
+  now func() time.Time
+
+  mu sync.Mutex
+  latest map[string]priceQuote
+}
+
+func newPriceService(providers []priceProvider) *priceService {
+  return &priceService{providers: providers, now: time.Now, latest: map[string]priceQuote{}}
+}
+
+func (s *Server) defaultPriceProviders() []priceProvider {
+  client := &http.Client{Timeout: priceFetchTimeout}
+  return []priceProvider{
+    {name: "mempool", fetch: s.mempoolPrice},
+    {name: "coingecko", fetch: func(ctx context.Context, currency string) (float64, error) {
+      return coingeckoPrice(ctx, client, coingeckoPriceURL, currency)
+    }},
+    {name: "kraken", fetch: func(ctx context.Context, currency string) (float64, error) {
+      return krakenPrice(ctx, client, krakenTickerURL, currency)
+    }},
+  }
+}
+
+// quote returns the BTC price in currency (ISO 4217, upper case).
+func (p *priceService) quote(ctx context.Context, currency string) (priceQuote, error) {
+  p.mu.Lock()
+  cached, ok := p.latest[currency]
+  p.mu.Unlock()
+  now := p.now()
+  if ok && now.Sub(cached.FetchedAt) < priceCacheTTL {
+    return cached, nil
+  }
+  var errs []string
+  for _, provider := range p.providers {
+    price, err := provider.fetch(ctx, currency)
+    if err == nil && (price <= 0 || math.IsNaN(price) || math.IsInf(price, 0)) {
+      err = fmt.Errorf("implausible price %v", price)
+    }
+    if err != nil {
+      errs = append(errs, provider.name+": "+err.Error())
+      if ctx.Err() != nil {
+        break
+      }
+      continue
+    }
+    quote := priceQuote{Currency: currency, Price: price, Source: provider.name, FetchedAt: now.UTC()}
+    p.mu.Lock()
+    p.latest[currency] = quote
+    p.mu.Unlock()
+    return quote, nil
+  }
+  if ok && now.Sub(cached.FetchedAt) < priceStaleTTL {
+    cached.Stale = true
+    return cached, nil
+  }
+  return priceQuote{}, fmt.Errorf("price unavailable: %s", strings.Join(errs, "; "))
+}
+
+// mempoolPrice reads /api/v1/prices, which quotes a handful of currencies
+// (USD, EUR, GBP, CAD, CHF, AUD, JPY).
+func (s *Server) mempoolPrice(ctx context.Context, currency string) (float64, error) {
+  var prices map[string]float64
+  if err := s.mempool.get(ctx, "/prices", priceCacheTTL, &prices); err != nil {
+    return 0, err
+  }
+  price, ok := prices[currency]
+  if !ok {
+    return 0, errors.New("currency not quoted")
+  }
+  return price, nil
+}
+
+func coingeckoPrice(ctx context.Context, client *http.Client, endpoint string, currency string) (float64, error) {
+  code := strings.ToLower(currency)
+  var body map[string]map[string]float64
+  if err := fetchPriceJSON(ctx, client, endpoint+"?ids=bitcoin&vs_currencies="+code, &body); err != nil {
+    return 0, err
+  }
+  price, ok := body["bitcoin"][code]
+  if !ok {
+    return 0, errors.New("currency not quoted")
+  }
+  return price, nil
+}
+
+// krakenPrice reads the last trade of the XBT pair. Kraken names the pair
+// in its answer differently (XXBTZUSD for XBTUSD), so the single result is
+// taken whatever its key.
+func krakenPrice(ctx context.Context, client *http.Client, endpoint string, currency string) (float64, error) {
+  var body struct {
+    Error []string `json:"error"`
+    Result map[string]struct {
+      Last []string `json:"c"`
+    } `json:"result"`
+  }
+  if err := fetchPriceJSON(ctx, client, endpoint+"?pair=XBT"+currency, &body); err != nil {
+    return 0, err
+  }
+  if len(body.Error) > 0 {
+    return 0, errors.New(strings.Join(body.Error, "; "))
+  }
+  for _, ticker := range body.Result {
+    if len(ticker.Last) == 0 {
+      break
+    }
+    return strconv.ParseFloat(ticker.Last[0], 64)
+  }
+  return 0, errors.New("currency not quoted")
+}
+
+func fetchPriceJSON(ctx context.Context, client *http.Client, url string, dst any) error {
+  req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Accept", "application/json")
+  resp, err := client.Do(req)
+  if err != nil {
+    return err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return fmt.Errorf("http %d", resp.StatusCode)
+  }
+  return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+}
+
+func (s *Server) ensurePriceSchema(ctx context.Context) error {
+  if s.db == nil {
+    return errors.New("db not configured")
+  }
+  _, err := s.db.Exec(ctx, `
+create table if not exists btc_prices (
+  currency text not null,
+  hour timestamptz not null,
+  price double precision not null,
+  source text not null default '',
+  primary key (currency, hour)
+);
+`)
+  return err
+}
+
+func (s *Server) initPrices() {
+  if s.db == nil {
+    return
+  }
+  ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
+  defer cancel()
+  if err := s.ensurePriceSchema(ctx); err != nil {
+    s.logger.Printf("prices: failed to init schema: %v", err)
+    return
+  }
+  goSafe("prices", s.runPriceSamples)
+}
+
+// priceSampleCurrencies is USD plus the currency the user picked in the
+// preferences, when it differs.
+func (s *Server) priceSampleCurrencies() []string {
+  currencies := []string{"USD"}
+  if s.notifier != nil {
+    if preferred := s.notifier.preferences().FiatCurrency; preferred != "" && preferred != "USD" {
+      currencies = append(currencies, preferred)
+    }
+  }
+  return currencies
+}
+
+func (s *Server) runPriceSamples() {
+  for {
+    for _, currency := range s.priceSampleCurrencies() {
+      if err := s.samplePrice(currency); err != nil {
+        s.logger.Printf("prices: %s sample failed: %v", currency, err)
+      }
+    }
+    now := time.Now()
+    if !s.wait(now.Truncate(priceSampleInterval).Add(priceSampleInterval).Sub(now) + time.Minute) {
+      return
+    }
+  }
+}
+
+func (s *Server) samplePrice(currency string) error {
+  ctx, cancel := s.taskContext("prices", timeoutMedium)
+  defer cancel()
+  quote, err := s.prices.quote(ctx, currency)
+  if err != nil {
+    return err
+  }
+  if quote.Stale {
+    return errors.New("only a stale quote is available")
+  }
+  _, err = s.db.Exec(ctx, `
+insert into btc_prices (currency, hour, price, source)
+values ($1, $2, $3, $4)
+on conflict (currency, hour) do nothing`, currency, quote.FetchedAt.Truncate(time.Hour), quote.Price, quote.Source)
+  return err
+}
+
+type pricePoint struct {
+  At time.Time `json:"at"`
+  Price float64 `json:"price"`
+}
+
+// priceHistory returns the stored hourly prices in [from, to), oldest first.
+func (s *Server) priceHistory(ctx context.Context, currency string, from, to time.Time) ([]pricePoint, error) {
+  rows, err := s.db.Query(ctx, `
+select hour, price from btc_prices
+where currency = $1 and hour >= $2 and hour < $3
+order by hour`, currency, from, to)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  points := []pricePoint{}
+  for rows.Next() {
+    var point pricePoint
+    if err := rows.Scan(&point.At, &point.Price); err != nil {
+      return nil, err
+    }
+    points = append(points, point)
+  }
+  return points, rows.Err()
+}
+
+// dailyPriceAverages averages hourly prices per calendar day in loc, keyed
+// YYYY-MM-DD like report dates.
+func dailyPriceAverages(points []pricePoint, loc *time.Location) map[string]float64 {
+  sums := map[string]float64{}
+  counts := map[string]int{}
+  for _, point := range points {
+    day := point.At.In(loc).Format("2006-01-02")
+    sums[day] += point.Price
+    counts[day]++
+  }
+  averages := make(map[string]float64, len(sums))
+  for day, sum := range sums {
+    averages[day] = math.Round(sum/float64(counts[day])*100) / 100
+  }
+  return averages
+}
+
+func parsePriceCurrency(raw string, fallback string) (string, error) {
+  currency := strings.ToUpper(strings.TrimSpace(raw))
+  if currency == "" {
+    currency = fallback
+  }
+  if !fiatCurrencyPattern.MatchString(currency) {
+    return "", errors.New("currency must be a 3-letter ISO 4217 code")
+  }
+  return currency, nil
+}
+
+func (s *Server) preferredFiat() string {
+  if s.notifier == nil {
+    return defaultUserPreferences().FiatCurrency
+  }
+  return s.notifier.preferences().FiatCurrency
+}
+
+// handlePrice returns the current BTC price (?currency=, the preferred fiat
+// currency by default).
+func (s *Server) handlePrice(w http.ResponseWriter, r *http.Request) {
+  currency, err := parsePriceCurrency(r.URL.Query().Get("currency"), s.preferredFiat())
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  quote, err := s.prices.quote(ctx, currency)
+  if err != nil {
+    s.logger.Printf("prices: %v", err)
+    writeError(w, http.StatusBadGateway, "price unavailable")
+    return
+  }
+  writeJSON(w, http.StatusOK, quote)
+}
+
+// handlePriceHistory returns stored prices of the last days (?days=7, at
+// most 365): hourly up to 7 days, daily averages beyond.
+func (s *Server) handlePriceHistory(w http.ResponseWriter, r *http.Request) {
+  if s.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "price history needs postgres")
+    return
+  }
+  currency, err := parsePriceCurrency(r.URL.Query().Get("currency"), s.preferredFiat())
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  days := 7
+  if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > priceHistoryMaxDays {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("days out of range: 1 to %d", priceHistoryMaxDays))
+      return
+    }
+    days = parsed
+  }
+  now := time.Now().UTC()
+  from := now.Add(-time.Duration(days) * 24 * time.Hour).Truncate(time.Hour)
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  points, err := s.priceHistory(ctx, currency, from, now)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load prices")
+    return
+  }
+  bucket := "hour"
+  if days > 7 {
+    bucket = "day"
+    averages := dailyPriceAverages(points, time.UTC)
+    daily := []pricePoint{}
+    for _, point := range points {
+      day := point.At.UTC().Truncate(24 * time.Hour)
+      if len(daily) > 0 && daily[len(daily)-1].At.Equal(day) {
+        continue
+      }
+      daily = append(daily, pricePoint{At: day, Price: averages[day.Format("2006-01-02")]})
+    }
+    points = daily
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "currency": currency,
+    "bucket": bucket,
+    "points": points,
+  })
+}
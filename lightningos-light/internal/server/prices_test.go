@@ -0,0 +1,106 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+  "time"
+)
+
+func TestPriceServiceFallback(t *testing.T) {
+  mempoolDown := true
+  calls := map[string]int{}
+  svc := newPriceService([]priceProvider{
+    {name: "mempool", fetch: func(ctx context.Context, currency string) (float64, error) {
+      calls["mempool"]++
+      if mempoolDown {
+        return 0, errors.New("mempool api rate limited")
+      }
+      return 60000, nil
+    }},
+    {name: "coingecko", fetch: func(ctx context.Context, currency string) (float64, error) {
+      calls["coingecko"]++
+      if currency != "BRL" {
+        return 0, errors.New("currency not quoted")
+      }
+      return 350000.5, nil
+    }},
+  })
+  now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+  svc.now = func() time.Time { return now }
+  ctx := context.Background()
+
+  quote, err := svc.quote(ctx, "BRL")
+  if err != nil || quote.Source != "coingecko" || quote.Price != 350000.5 || quote.Stale {
+    t.Fatalf("quote = %+v, %v", quote, err)
+  }
+  if _, err := svc.quote(ctx, "BRL"); err != nil || calls["coingecko"] != 1 {
+    t.Fatalf("cached quote refetched: %v, %v", calls, err)
+  }
+  if _, err := svc.quote(ctx, "USD"); err == nil {
+    t.Fatal("a currency no provider quotes must fail")
+  }
+
+  // Every provider failing serves the last quote, marked stale, for a day.
+  now = now.Add(priceCacheTTL)
+  svc.providers = svc.providers[:1]
+  quote, err = svc.quote(ctx, "BRL")
+  if err != nil || !quote.Stale || quote.Price != 350000.5 {
+    t.Fatalf("stale quote = %+v, %v", quote, err)
+  }
+  now = now.Add(priceStaleTTL)
+  if _, err := svc.quote(ctx, "BRL"); err == nil {
+    t.Fatal("a quote older than a day was served")
+  }
+  mempoolDown = false
+  if quote, err := svc.quote(ctx, "BRL"); err != nil || quote.Source != "mempool" {
+    t.Fatalf("recovered quote = %+v, %v", quote, err)
+  }
+}
+
+func TestPriceProviders(t *testing.T) {
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    switch r.URL.Path {
+    case "/gecko":
+      if r.URL.Query().Get("vs_currencies") != "brl" {
+        t.Errorf("coingecko query = %s", r.URL.RawQuery)
+      }
+      w.Write([]byte(`{"bitcoin":{"brl":351234.7}}`))
+    case "/kraken":
+      if r.URL.Query().Get("pair") != "XBTEUR" {
+        w.Write([]byte(`{"error":["EQuery:Unknown asset pair"]}`))
+        return
+      }
+      w.Write([]byte(`{"error":[],"result":{"XXBTZEUR":{"c":["55321.10000","0.01"]}}}`))
+    }
+  }))
+  defer srv.Close()
+  ctx := context.Background()
+
+  if price, err := coingeckoPrice(ctx, srv.Client(), srv.URL+"/gecko", "BRL"); err != nil || price != 351234.7 {
+    t.Fatalf("coingecko = %v, %v", price, err)
+  }
+  if price, err := krakenPrice(ctx, srv.Client(), srv.URL+"/kraken", "EUR"); err != nil || price != 55321.1 {
+    t.Fatalf("kraken = %v, %v", price, err)
+  }
+  if _, err := krakenPrice(ctx, srv.Client(), srv.URL+"/kraken", "BRL"); err == nil {
+    t.Fatal("kraken error list ignored")
+  }
+}
+
+func TestDailyPriceAverages(t *testing.T) {
+  loc := time.FixedZone("BRT", -3*3600)
+  points := []pricePoint{
+    {At: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Price: 100},
+    {At: time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC), Price: 200},
+    // 01:00 UTC is still the previous day in BRT.
+    {At: time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC), Price: 600},
+    {At: time.Date(2026, 3, 2, 4, 0, 0, 0, time.UTC), Price: 50},
+  }
+  averages := dailyPriceAverages(points, loc)
+  if averages["2026-03-01"] != 300 || averages["2026-03-02"] != 50 || len(averages) != 2 {
+    t.Fatalf("averages = %v", averages)
+  }
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+  "bufio"
+  "bytes"
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/binary"
+  "encoding/hex"
+  "encoding/json"
+  "net"
+  "net/http"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+// Privacy mode hides what identifies the node or its money in API responses,
+// so the dashboard can be screenshotted or streamed. Amounts become 0;
+// pubkeys, txids, payment hashes and channel ids are replaced by stand-ins
+// derived from the session key, so the same value masks the same way for
+// the whole session (a peer stays recognizable across pages) but not across
+// sessions. Masked ids do not work as input: actions need privacy mode off.
+const (
+  privacyCookie = "los_privacy"
+  privacyHeader = "X-Privacy-Mode"
+)
+
+var privacyHexPattern = regexp.MustCompile(`\b(?:0[23][0-9a-fA-F]{64}|[0-9a-fA-F]{64})\b`)
+
+// privacyAmountSuffixes, privacyAmountWords and privacyAmountKeys name the
+// JSON fields that hold amounts or balances.
+var (
+  privacyAmountSuffixes = []string{"_sat", "_sats", "_msat", "_btc"}
+  privacyAmountWords = []string{"amount", "balance", "capacity"}
+  privacyAmountKeys = map[string]bool{"value": true, "fee": true, "fees": true, "total": true, "sat": true, "sats": true, "msat": true}
+)
+
+type privacyMasker struct {
+  key []byte
+}
+
+func (m privacyMasker) digest(value string) []byte {
+  mac := hmac.New(sha256.New, m.key)
+  mac.Write([]byte(strings.ToLower(value)))
+  return mac.Sum(nil)
+}
+
+// maskHex replaces a pubkey (keeping its 02/03 prefix) or a 32-byte hash.
+func (m privacyMasker) maskHex(value string) string {
+  masked := hex.EncodeToString(m.digest(value))
+  if len(value) == 66 {
+    return value[:2] + masked
+  }
+  return masked
+}
+
+func (m privacyMasker) maskString(value string) string {
+  return privacyHexPattern.ReplaceAllStringFunc(value, m.maskHex)
+}
+
+// maskChannelID keeps a channel id a plausible positive number that fits in
+// a JavaScript number.
+func (m privacyMasker) maskChannelID(value string) json.Number {
+  n := binary.BigEndian.Uint64(m.digest("chan:"+value)) >> 12
+  return json.Number(strconv.FormatUint(n, 10))
+}
+
+func privacyAmountKey(key string) bool {
+  key = strings.ToLower(key)
+  if privacyAmountKeys[key] {
+    return true
+  }
+  for _, suffix := range privacyAmountSuffixes {
+    if strings.HasSuffix(key, suffix) {
+      return true
+    }
+  }
+  for _, word := range privacyAmountWords {
+    if strings.Contains(key, word) {
+      return true
+    }
+  }
+  return false
+}
+
+func privacyChannelIDKey(key string) bool {
+  key = strings.ToLower(key)
+  return key == "scid" || strings.HasSuffix(key, "chan_id") || strings.HasSuffix(key, "channel_id")
+}
+
+// redact masks a decoded JSON value; key is the field it sits in.
+func (m privacyMasker) redact(key string, value any) any {
+  switch v := value.(type) {
+  case map[string]any:
+    for k, item := range v {
+      v[k] = m.redact(k, item)
+    }
+    return v
+  case []any:
+    for i, item := range v {
+      v[i] = m.redact(key, item)
+    }
+    return v
+  case json.Number:
+    if privacyAmountKey(key) {
+      return json.Number("0")
+    }
+    if privacyChannelIDKey(key) && v != "0" {
+      return m.maskChannelID(v.String())
+    }
+    return v
+  case string:
+    if privacyAmountKey(key) && isDecimalString(v) {
+      return "0"
+    }
+    if privacyChannelIDKey(key) && isDecimalString(v) && v != "0" {
+      return m.maskChannelID(v).String()
+    }
+    return m.maskString(v)
+  }
+  return value
+}
+
+func isDecimalString(value string) bool {
+  if value == "" {
+    return false
+  }
+  for i, c := range value {
+    if c == '-' && i == 0 || c == '.' || c >= '0' && c <= '9' {
+      continue
+    }
+    return false
+  }
+  return true
+}
+
+// redactJSON masks a JSON document; anything that does not parse is left as
+// it is.
+func (m privacyMasker) redactJSON(body []byte) []byte {
+  dec := json.NewDecoder(bytes.NewReader(body))
+  dec.UseNumber()
+  var value any
+  if err := dec.Decode(&value); err != nil {
+    return body
+  }
+  var buf bytes.Buffer
+  enc := json.NewEncoder(&buf)
+  enc.SetEscapeHTML(false)
+  if err := enc.Encode(m.redact("", value)); err != nil {
+    return body
+  }
+  return buf.Bytes()
+}
+
+// privacyKey is the session key: the privacy cookie set by POST
+// /api/privacy, or the X-Privacy-Mode header for clients without cookies.
+func privacyKey(r *http.Request) string {
+  if value := strings.TrimSpace(r.Header.Get(privacyHeader)); value != "" && value != "0" && value != "off" {
+    return value
+  }
+  if cookie, err := r.Cookie(privacyCookie); err == nil {
+    return cookie.Value
+  }
+  return ""
+}
+
+// withPrivacy redacts JSON bodies and the JSON data lines of event streams
+// for sessions in privacy mode. The settings endpoint itself is exempt.
+func (s *Server) withPrivacy() func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      key := privacyKey(r)
+      if key == "" || r.URL.Path == "/api/privacy" || r.Header.Get("Upgrade") != "" {
+        next.ServeHTTP(w, r)
+        return
+      }
+      w.Header().Set(privacyHeader, "on")
+      pw := &privacyWriter{ResponseWriter: w, masker: privacyMasker{key: []byte(key)}, status: http.StatusOK}
+      next.ServeHTTP(pw, r)
+      pw.close()
+    })
+  }
+}
+
+// privacyWriter holds JSON bodies until the handler is done, so they can be
+// parsed whole, and rewrites event stream lines as they pass.
+type privacyWriter struct {
+  http.ResponseWriter
+  masker privacyMasker
+  status int
+  headerSent bool
+  kind string
+  buf []byte
+}
+
+func (w *privacyWriter) mediaType() string {
+  if w.kind == "" {
+    mediaType := w.Header().Get("Content-Type")
+    if i := strings.Index(mediaType, ";"); i >= 0 {
+      mediaType = mediaType[:i]
+    }
+    switch strings.ToLower(strings.TrimSpace(mediaType)) {
+    case "application/json":
+      w.kind = "json"
+    case "text/event-stream":
+      w.kind = "events"
+    default:
+      w.kind = "other"
+    }
+  }
+  return w.kind
+}
+
+func (w *privacyWriter) WriteHeader(status int) {
+  if w.headerSent {
+    return
+  }
+  w.status = status
+  w.headerSent = true
+  if w.mediaType() == "json" {
+    w.Header().Del("Content-Length")
+    return
+  }
+  w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *privacyWriter) Write(p []byte) (int, error) {
+  if !w.headerSent {
+    w.WriteHeader(http.StatusOK)
+  }
+  switch w.mediaType() {
+  case "json":
+    w.buf = append(w.buf, p...)
+    return len(p), nil
+  case "events":
+    w.buf = append(w.buf, p...)
+    end := bytes.LastIndexByte(w.buf, '\n')
+    if end < 0 {
+      return len(p), nil
+    }
+    complete := w.buf[:end+1]
+    w.buf = append([]byte{}, w.buf[end+1:]...)
+    if _, err := w.ResponseWriter.Write(w.redactEvents(complete)); err != nil {
+      return 0, err
+    }
+    return len(p), nil
+  }
+  return w.ResponseWriter.Write(p)
+}
+
+func (w *privacyWriter) redactEvents(chunk []byte) []byte {
+  lines := bytes.Split(chunk, []byte("\n"))
+  for i, line := range lines {
+    if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+      lines[i] = append([]byte("data: "), bytes.TrimSuffix(w.masker.redactJSON(data), []byte("\n"))...)
+    }
+  }
+  return bytes.Join(lines, []byte("\n"))
+}
+
+func (w *privacyWriter) Flush() {
+  if w.mediaType() == "json" {
+    return
+  }
+  if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+    flusher.Flush()
+  }
+}
+
+func (w *privacyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+  if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+    return hijacker.Hijack()
+  }
+  return nil, nil, http.ErrNotSupported
+}
+
+func (w *privacyWriter) close() {
+  if !w.headerSent || w.kind != "json" {
+    if len(w.buf) > 0 {
+      _, _ = w.ResponseWriter.Write(w.buf)
+    }
+    return
+  }
+  w.ResponseWriter.WriteHeader(w.status)
+  if len(w.buf) > 0 {
+    _, _ = w.ResponseWriter.Write(w.masker.redactJSON(w.buf))
+  }
+}
+
+func (s *Server) handlePrivacyGet(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, http.StatusOK, map[string]bool{"enabled": privacyKey(r) != ""})
+}
+
+// handlePrivacyPost turns privacy mode on or off for the browser session.
+// Turning it on again starts a new masking.
+func (s *Server) handlePrivacyPost(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Enabled bool `json:"enabled"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  cookie := &http.Cookie{
+    Name: privacyCookie,
+    Path: "/",
+    HttpOnly: true,
+    Secure: r.TLS != nil,
+    SameSite: http.SameSiteStrictMode,
+  }
+  if req.Enabled {
+    key, err := randomToken(16)
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to enable privacy mode")
+      return
+    }
+    cookie.Value = key
+  } else {
+    cookie.MaxAge = -1
+  }
+  http.SetCookie(w, cookie)
+  writeJSON(w, http.StatusOK, map[string]bool{"enabled": req.Enabled})
+}
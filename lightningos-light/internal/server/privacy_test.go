@@ -0,0 +1,74 @@
+package server
+
+import (
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestPrivacyMaskerRedact(t *testing.T) {
+  m := privacyMasker{key: []byte("session")}
+  pubkey := "02" + strings.Repeat("ab", 32)
+  txid := strings.Repeat("cd", 32)
+  body := `{"local_balance_sat":1000,"amount":"2500","fee_rate_ppm":100,"channel_id":123456789,` +
+    `"remote_pubkey":"` + pubkey + `","channel_point":"` + txid + `:1","peers":[{"pub_key":"` + pubkey + `@1.2.3.4:9735"}]}`
+  var out map[string]any
+  if err := json.Unmarshal(m.redactJSON([]byte(body)), &out); err != nil {
+    t.Fatal(err)
+  }
+  if out["local_balance_sat"].(float64) != 0 || out["amount"] != "0" || out["fee_rate_ppm"].(float64) != 100 {
+    t.Fatalf("amounts %v", out)
+  }
+  if out["channel_id"].(float64) == 123456789 {
+    t.Fatal("channel id not masked")
+  }
+  masked := out["remote_pubkey"].(string)
+  if masked == pubkey || len(masked) != 66 || !strings.HasPrefix(masked, "02") {
+    t.Fatalf("pubkey %s", masked)
+  }
+  if peer := out["peers"].([]any)[0].(map[string]any)["pub_key"].(string); peer != masked+"@1.2.3.4:9735" {
+    t.Fatalf("same pubkey masked differently: %s", peer)
+  }
+  if point := out["channel_point"].(string); strings.Contains(point, txid) || !strings.HasSuffix(point, ":1") {
+    t.Fatalf("channel point %s", point)
+  }
+
+  other := privacyMasker{key: []byte("other session")}
+  if other.maskHex(pubkey) == masked {
+    t.Fatal("masking repeats across sessions")
+  }
+}
+
+func TestPrivacyMiddleware(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  pubkey := "03" + strings.Repeat("ef", 32)
+  fake.Peers = []lndclient.PeerInfo{{PubKey: pubkey, Alias: "alice"}}
+
+  req := httptest.NewRequest(http.MethodGet, "/api/lnops/peers", nil)
+  req.Header.Set(privacyHeader, "demo")
+  rec := httptest.NewRecorder()
+  s.routes().ServeHTTP(rec, req)
+  if rec.Code != http.StatusOK || rec.Header().Get(privacyHeader) != "on" {
+    t.Fatalf("status = %d, header %q", rec.Code, rec.Header().Get(privacyHeader))
+  }
+  if strings.Contains(rec.Body.String(), pubkey) || !strings.Contains(rec.Body.String(), "alice") {
+    t.Fatalf("body %s", rec.Body.String())
+  }
+
+  rec = serveAPI(s, http.MethodPost, "/api/privacy", `{"enabled":true}`)
+  cookies := rec.Result().Cookies()
+  if rec.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != privacyCookie || cookies[0].Value == "" {
+    t.Fatalf("status = %d, cookies %v", rec.Code, cookies)
+  }
+  req = httptest.NewRequest(http.MethodGet, "/api/lnops/peers", nil)
+  req.AddCookie(cookies[0])
+  rec = httptest.NewRecorder()
+  s.routes().ServeHTTP(rec, req)
+  if strings.Contains(rec.Body.String(), pubkey) {
+    t.Fatalf("cookie session not redacted: %s", rec.Body.String())
+  }
+}
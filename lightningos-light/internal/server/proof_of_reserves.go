@@ -0,0 +1,225 @@
+package server
+
+import (
+  "bytes"
+  "context"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "sort"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+// A proof of reserves shows a third party what the node holds at one block:
+// every confirmed UTXO with a signature by its address over the auditor's
+// challenge, and the channel balances. The whole report is then signed by
+// the node key, so the channel figures are attested by the node that
+// reported them.
+const (
+  proofOfReservesVersion = 1
+  proofOfReservesMaxMessage = 200
+  proofOfReservesMaxAddresses = 500
+  // proofOfReservesPrefix keeps the node signature from being reused as a
+  // signature over anything else.
+  proofOfReservesPrefix = "lightningos-proof-of-reserves:"
+)
+
+type reserveUtxo struct {
+  Outpoint string `json:"outpoint"`
+  Address string `json:"address"`
+  AmountSat int64 `json:"amount_sat"`
+  Confirmations int64 `json:"confirmations"`
+}
+
+type reserveAddressProof struct {
+  Address string `json:"address"`
+  AddressType string `json:"address_type"`
+  AmountSat int64 `json:"amount_sat"`
+  Signature string `json:"signature,omitempty"`
+  Error string `json:"error,omitempty"`
+}
+
+type reserveChannel struct {
+  ChannelPoint string `json:"channel_point"`
+  RemotePubkey string `json:"remote_pubkey"`
+  CapacitySat int64 `json:"capacity_sat"`
+  LocalBalanceSat int64 `json:"local_balance_sat"`
+  Active bool `json:"active"`
+}
+
+// proofOfReservesReport is the signed part. Field order is the serialized
+// order, which the digest depends on.
+type proofOfReservesReport struct {
+  Version int `json:"version"`
+  Network string `json:"network"`
+  NodePubkey string `json:"node_pubkey"`
+  BlockHeight int64 `json:"block_height"`
+  GeneratedAt time.Time `json:"generated_at"`
+  Message string `json:"message"`
+  Utxos []reserveUtxo `json:"utxos"`
+  AddressProofs []reserveAddressProof `json:"address_proofs"`
+  OnchainSat int64 `json:"onchain_sat"`
+  // Complete is false when some address could not sign; its UTXOs still
+  // count in OnchainSat but are not proven.
+  Complete bool `json:"complete"`
+  Channels []reserveChannel `json:"channels"`
+  ChannelLocalSat int64 `json:"channel_local_sat"`
+  TotalSat int64 `json:"total_sat"`
+}
+
+// proofDigest is the SHA-256 of the compact JSON of report, as `jq -cj
+// .report` prints it from the response.
+func proofDigest(report proofOfReservesReport) (string, error) {
+  var buf bytes.Buffer
+  enc := json.NewEncoder(&buf)
+  enc.SetEscapeHTML(false)
+  if err := enc.Encode(report); err != nil {
+    return "", err
+  }
+  sum := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+  return hex.EncodeToString(sum[:]), nil
+}
+
+// buildProofOfReserves collects and signs the report. Each address with
+// confirmed funds signs the challenge once, whatever its number of UTXOs.
+func (s *Server) buildProofOfReserves(ctx context.Context, lnd lndclient.API, message string, minConfs int32) (proofOfReservesReport, error) {
+  report := proofOfReservesReport{
+    Version: proofOfReservesVersion,
+    Network: s.cfg.Network,
+    GeneratedAt: time.Now().UTC().Truncate(time.Second),
+    Message: message,
+    Utxos: []reserveUtxo{},
+    AddressProofs: []reserveAddressProof{},
+    Channels: []reserveChannel{},
+    Complete: true,
+  }
+  status, err := lnd.GetStatus(ctx)
+  if err != nil {
+    return report, err
+  }
+  report.NodePubkey = status.Pubkey
+  report.BlockHeight = status.BlockHeight
+
+  utxos, err := lnd.ListOnchainUtxos(ctx, minConfs, int32(1<<30))
+  if err != nil {
+    return report, err
+  }
+  sort.Slice(utxos, func(i, j int) bool { return utxos[i].Outpoint < utxos[j].Outpoint })
+  byAddress := map[string]*reserveAddressProof{}
+  order := []string{}
+  for _, utxo := range utxos {
+    report.Utxos = append(report.Utxos, reserveUtxo{
+      Outpoint: utxo.Outpoint,
+      Address: utxo.Address,
+      AmountSat: utxo.AmountSat,
+      Confirmations: utxo.Confirmations,
+    })
+    report.OnchainSat += utxo.AmountSat
+    proof, ok := byAddress[utxo.Address]
+    if !ok {
+      proof = &reserveAddressProof{Address: utxo.Address, AddressType: utxo.AddressType}
+      byAddress[utxo.Address] = proof
+      order = append(order, utxo.Address)
+    }
+    proof.AmountSat += utxo.AmountSat
+  }
+  if len(order) > proofOfReservesMaxAddresses {
+    return report, fmt.Errorf("too many addresses: %d, max %d", len(order), proofOfReservesMaxAddresses)
+  }
+  sort.Strings(order)
+  for _, address := range order {
+    proof := byAddress[address]
+    signature, err := lnd.SignMessageWithAddress(ctx, address, message)
+    if err != nil {
+      proof.Error = lndRPCErrorMessage(err)
+      report.Complete = false
+    }
+    proof.Signature = signature
+    report.AddressProofs = append(report.AddressProofs, *proof)
+  }
+
+  channels, err := lnd.ListChannels(ctx)
+  if err != nil {
+    return report, err
+  }
+  sort.Slice(channels, func(i, j int) bool { return channels[i].ChannelPoint < channels[j].ChannelPoint })
+  for _, ch := range channels {
+    report.Channels = append(report.Channels, reserveChannel{
+      ChannelPoint: ch.ChannelPoint,
+      RemotePubkey: ch.RemotePubkey,
+      CapacitySat: ch.CapacitySat,
+      LocalBalanceSat: ch.LocalBalanceSat,
+      Active: ch.Active,
+    })
+    report.ChannelLocalSat += ch.LocalBalanceSat
+  }
+  report.TotalSat = report.OnchainSat + report.ChannelLocalSat
+  return report, nil
+}
+
+// handleProofOfReserves generates a proof of reserves over the auditor's
+// challenge message. The response is the artifact to hand over.
+func (s *Server) handleProofOfReserves(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Message string `json:"message"`
+    MinConfs int32 `json:"min_confs"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  req.Message = strings.TrimSpace(req.Message)
+  if req.Message == "" {
+    writeError(w, http.StatusBadRequest, "message required")
+    return
+  }
+  if len(req.Message) > proofOfReservesMaxMessage {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("message too long: max %d bytes", proofOfReservesMaxMessage))
+    return
+  }
+  if req.MinConfs == 0 {
+    req.MinConfs = 1
+  }
+  if req.MinConfs < 1 {
+    writeError(w, http.StatusBadRequest, "min_confs must be positive")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  lnd := s.lndFor(r)
+  report, err := s.buildProofOfReserves(ctx, lnd, req.Message, req.MinConfs)
+  if err != nil {
+    if strings.HasPrefix(err.Error(), "too many addresses") {
+      writeError(w, http.StatusBadRequest, err.Error())
+      return
+    }
+    writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
+    return
+  }
+  digest, err := proofDigest(report)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to build proof of reserves")
+    return
+  }
+  signature, err := lnd.SignMessage(ctx, proofOfReservesPrefix+digest)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
+    return
+  }
+
+  if r.URL.Query().Get("download") == "1" {
+    w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="lightningos-proof-of-reserves-%d.json"`, report.BlockHeight))
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "report": report,
+    "digest": digest,
+    "signed_message": proofOfReservesPrefix + digest,
+    "node_signature": signature,
+  })
+}
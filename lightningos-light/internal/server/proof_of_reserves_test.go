@@ -0,0 +1,68 @@
+package server
+
+import (
+  "encoding/json"
+  "errors"
+  "net/http"
+  "testing"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func TestProofOfReserves(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  fake.Utxos = []lndclient.OnchainUtxo{
+    {Outpoint: "bb:0", Address: "bc1qb", AddressType: "p2wkh", AmountSat: 5000, Confirmations: 10},
+    {Outpoint: "aa:1", Address: "bc1qa", AddressType: "p2tr", AmountSat: 3000, Confirmations: 3},
+    {Outpoint: "aa:0", Address: "bc1qa", AddressType: "p2tr", AmountSat: 2000, Confirmations: 3},
+  }
+  fake.Channels = []lndclient.ChannelInfo{
+    {ChannelPoint: "cc:0", RemotePubkey: "02cc", CapacitySat: 100000, LocalBalanceSat: 60000, Active: true},
+  }
+
+  rec := serveAPI(s, http.MethodPost, "/api/wallet/proof-of-reserves", `{"message":"audit 2026 nonce 42"}`)
+  if rec.Code != http.StatusOK {
+    t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+  }
+  var resp struct {
+    Report proofOfReservesReport `json:"report"`
+    Digest string `json:"digest"`
+    SignedMessage string `json:"signed_message"`
+    NodeSignature string `json:"node_signature"`
+  }
+  if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+    t.Fatal(err)
+  }
+  report := resp.Report
+  if !report.Complete || report.OnchainSat != 10000 || report.ChannelLocalSat != 60000 || report.TotalSat != 70000 {
+    t.Fatalf("report %+v", report)
+  }
+  if len(report.AddressProofs) != 2 || report.AddressProofs[0].Address != "bc1qa" || report.AddressProofs[0].AmountSat != 5000 || report.AddressProofs[0].Signature == "" {
+    t.Fatalf("address proofs %+v", report.AddressProofs)
+  }
+  if report.Utxos[0].Outpoint != "aa:0" {
+    t.Fatalf("utxos not sorted: %+v", report.Utxos)
+  }
+  // The digest covers the report as returned.
+  digest, err := proofDigest(report)
+  if err != nil || digest != resp.Digest || resp.SignedMessage != proofOfReservesPrefix+digest {
+    t.Fatalf("digest %s, response %s: %v", digest, resp.Digest, err)
+  }
+  calls := fake.Calls("SignMessage")
+  if len(calls) != 1 || calls[0].Args[0] != resp.SignedMessage || resp.NodeSignature == "" {
+    t.Fatalf("node signature calls %+v", calls)
+  }
+
+  fake.Fail("SignMessageWithAddress", errors.New("address not found"))
+  rec = serveAPI(s, http.MethodPost, "/api/wallet/proof-of-reserves", `{"message":"again"}`)
+  if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+    t.Fatal(err)
+  }
+  if resp.Report.Complete || resp.Report.AddressProofs[0].Error == "" {
+    t.Fatalf("failed signature not reported: %+v", resp.Report.AddressProofs)
+  }
+
+  if rec := serveAPI(s, http.MethodPost, "/api/wallet/proof-of-reserves", `{"message":" "}`); rec.Code != http.StatusBadRequest {
+    t.Fatalf("empty message: status = %d", rec.Code)
+  }
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+  "context"
+  "net"
+  "net/http"
+  "strings"
+)
+
+type forwardedKey struct{}
+
+// forwardedInfo is how the client reached us, as reported by a trusted
+// reverse proxy (or taken from the direct connection).
+type forwardedInfo struct {
+  ClientIP string
+  Scheme string
+  Host string
+}
+
+func normalizeBasePath(raw string) string {
+  trimmed := strings.Trim(strings.TrimSpace(raw), "/")
+  if trimmed == "" {
+    return ""
+  }
+  return "/" + trimmed
+}
+
+// basePath is the sub-path the manager is published under (server.base_path),
+// e.g. "/node" for https://host/node/ behind nginx or Caddy.
+func (s *Server) basePath() string {
+  return normalizeBasePath(s.cfg.Server.BasePath)
+}
+
+// parseTrustedProxies accepts single IPs and CIDRs; invalid entries are
+// skipped.
+func parseTrustedProxies(entries []string) []*net.IPNet {
+  nets := []*net.IPNet{}
+  for _, entry := range entries {
+    entry = strings.TrimSpace(entry)
+    if entry == "" {
+      continue
+    }
+    if !strings.Contains(entry, "/") {
+      if ip := net.ParseIP(entry); ip != nil {
+        bits := 128
+        if ip.To4() != nil {
+          ip = ip.To4()
+          bits = 32
+        }
+        nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+      }
+      continue
+    }
+    if _, network, err := net.ParseCIDR(entry); err == nil {
+      nets = append(nets, network)
+    }
+  }
+  return nets
+}
+
+func ipTrusted(ip net.IP, trusted []*net.IPNet) bool {
+  if ip == nil {
+    return false
+  }
+  for _, network := range trusted {
+    if network.Contains(ip) {
+      return true
+    }
+  }
+  return false
+}
+
+func remoteIP(remoteAddr string) string {
+  host, _, err := net.SplitHostPort(remoteAddr)
+  if err != nil {
+    return remoteAddr
+  }
+  return host
+}
+
+// resolveForwarded reads X-Forwarded-For/Proto/Host, but only when the
+// connection comes from a trusted proxy; otherwise anyone could spoof them.
+// The client is the right-most X-Forwarded-For hop that is not a proxy.
+func resolveForwarded(r *http.Request, trusted []*net.IPNet) forwardedInfo {
+  info := forwardedInfo{ClientIP: remoteIP(r.RemoteAddr), Scheme: "http", Host: r.Host}
+  if r.TLS != nil {
+    info.Scheme = "https"
+  }
+  if !ipTrusted(net.ParseIP(info.ClientIP), trusted) {
+    return info
+  }
+  if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+    hops := strings.Split(xff, ",")
+    for i := len(hops) - 1; i >= 0; i-- {
+      hop := strings.TrimSpace(hops[i])
+      ip := net.ParseIP(hop)
+      if ip == nil {
+        break
+      }
+      info.ClientIP = hop
+      if !ipTrusted(ip, trusted) {
+        break
+      }
+    }
+  }
+  if proto := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0])); proto == "http" || proto == "https" {
+    info.Scheme = proto
+  }
+  if host := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Host"), ",")[0]); host != "" {
+    info.Host = host
+  }
+  return info
+}
+
+// withForwarded records the real client for logging, rate limiting and
+// generated URLs.
+func (s *Server) withForwarded() func(http.Handler) http.Handler {
+  trusted := parseTrustedProxies(s.cfg.Server.TrustedProxies)
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      info := resolveForwarded(r, trusted)
+      next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), forwardedKey{}, info)))
+    })
+  }
+}
+
+func requestForwarded(r *http.Request) forwardedInfo {
+  if info, ok := r.Context().Value(forwardedKey{}).(forwardedInfo); ok {
+    return info
+  }
+  return resolveForwarded(r, nil)
+}
+
+func clientIP(r *http.Request) string {
+  return requestForwarded(r).ClientIP
+}
+
+// publicURL builds an absolute URL for p as the client sees the manager,
+// including the proxy's scheme, host and the base path.
+func (s *Server) publicURL(r *http.Request, p string) string {
+  info := requestForwarded(r)
+  return info.Scheme + "://" + info.Host + s.basePath() + p
+}
+
+// withBasePath serves the router under server.base_path. Requests with the
+// prefix have it stripped; requests without it (a proxy that strips the
+// prefix itself, or direct LAN access) are served as they are.
+func withBasePath(base string, next http.Handler) http.Handler {
+  if base == "" {
+    return next
+  }
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path == base {
+      target := base + "/"
+      if r.URL.RawQuery != "" {
+        target += "?" + r.URL.RawQuery
+      }
+      http.Redirect(w, r, target, http.StatusMovedPermanently)
+      return
+    }
+    if !strings.HasPrefix(r.URL.Path, base+"/") {
+      next.ServeHTTP(w, r)
+      return
+    }
+    r2 := r.Clone(r.Context())
+    r2.URL.Path = strings.TrimPrefix(r.URL.Path, base)
+    if r.URL.RawPath != "" {
+      r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, base)
+    }
+    next.ServeHTTP(w, r2)
+  })
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+func TestResolveForwarded(t *testing.T) {
+  trusted := parseTrustedProxies([]string{"127.0.0.1", "10.0.0.0/8", "bogus"})
+  if len(trusted) != 2 {
+    t.Fatalf("expected 2 trusted entries, got %d", len(trusted))
+  }
+
+  r := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+  r.RemoteAddr = "127.0.0.1:51000"
+  r.Host = "127.0.0.1:8443"
+  r.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.9, 10.0.0.2")
+  r.Header.Set("X-Forwarded-Proto", "https")
+  r.Header.Set("X-Forwarded-Host", "node.example.com")
+  info := resolveForwarded(r, trusted)
+  if info.ClientIP != "203.0.113.9" || info.Scheme != "https" || info.Host != "node.example.com" {
+    t.Fatalf("unexpected forwarded info: %+v", info)
+  }
+
+  r.RemoteAddr = "192.168.1.50:51000"
+  info = resolveForwarded(r, trusted)
+  if info.ClientIP != "192.168.1.50" || info.Host != "127.0.0.1:8443" || info.Scheme != "http" {
+    t.Fatalf("untrusted peer must not be able to spoof headers: %+v", info)
+  }
+}
+
+func TestWithBasePath(t *testing.T) {
+  var seen string
+  handler := withBasePath(normalizeBasePath("node/"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    seen = r.URL.Path
+  }))
+
+  for path, want := range map[string]string{
+    "/node/api/health": "/api/health",
+    "/node/": "/",
+    "/api/health": "/api/health",
+  } {
+    seen = ""
+    handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+    if seen != want {
+      t.Fatalf("%s routed as %q, want %q", path, seen, want)
+    }
+  }
+
+  rec := httptest.NewRecorder()
+  handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/node?x=1", nil))
+  if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/node/?x=1" {
+    t.Fatalf("expected redirect to /node/?x=1, got %d %q", rec.Code, rec.Header().Get("Location"))
+  }
+}
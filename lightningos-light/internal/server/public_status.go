@@ -0,0 +1,177 @@
+package server
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "html/template"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+// The public status page is the one view of the node anyone may read:
+// alias, uptime, channel count, LND version and the last block, for an
+// operator to link from a website or a node directory. Nothing about funds,
+// peers or the pubkey is shown. It is off unless public_status.enabled is
+// set in config.yaml, and answers 404 like any unknown path while off.
+const publicStatusPrefix = "/api/public/"
+
+type publicStatus struct {
+  Alias string `json:"alias"`
+  Network string `json:"network"`
+  Online bool `json:"online"`
+  Version string `json:"version,omitempty"`
+  SyncedToChain bool `json:"synced_to_chain"`
+  BlockHeight int64 `json:"block_height"`
+  BlockTime *time.Time `json:"block_time,omitempty"`
+  Channels int `json:"channels"`
+  ActiveChannels int `json:"active_channels"`
+  ManagerUptimeSec int64 `json:"manager_uptime_sec"`
+  LNDUptimeSec int64 `json:"lnd_uptime_sec"`
+  GeneratedAt time.Time `json:"generated_at"`
+}
+
+// publicStatusCache holds the last snapshot so that public traffic costs at
+// most one LND status call per cache period.
+var publicStatusCache struct {
+  mu sync.Mutex
+  at time.Time
+  status publicStatus
+}
+
+func isPublicStatusPath(path string) bool {
+  return strings.HasPrefix(path, publicStatusPrefix)
+}
+
+func buildPublicStatus(network string, st lndclient.Status, life lndclient.Lifecycle, managerStarted, now time.Time) publicStatus {
+  out := publicStatus{
+    Alias: st.Alias,
+    Network: network,
+    Online: st.ServiceActive,
+    Version: st.Version,
+    SyncedToChain: st.SyncedToChain,
+    BlockHeight: st.BlockHeight,
+    Channels: st.ChannelsActive + st.ChannelsInactive,
+    ActiveChannels: st.ChannelsActive,
+    GeneratedAt: now.UTC().Truncate(time.Second),
+  }
+  if !st.BestHeaderTime.IsZero() {
+    blockTime := st.BestHeaderTime
+    out.BlockTime = &blockTime
+  }
+  if !managerStarted.IsZero() {
+    out.ManagerUptimeSec = int64(now.Sub(managerStarted) / time.Second)
+  }
+  if life.State == lndclient.StateServerActive && !life.Since.IsZero() {
+    out.LNDUptimeSec = int64(now.Sub(life.Since) / time.Second)
+  }
+  return out
+}
+
+func (s *Server) currentPublicStatus(r *http.Request) publicStatus {
+  ttl := s.cfg.PublicStatus.CacheTTL()
+  publicStatusCache.mu.Lock()
+  defer publicStatusCache.mu.Unlock()
+  now := time.Now()
+  if !publicStatusCache.at.IsZero() && now.Sub(publicStatusCache.at) < ttl {
+    return publicStatusCache.status
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  // A failed call still yields a page: the node shows as offline.
+  st, _ := s.lnd.GetStatus(ctx)
+  publicStatusCache.status = buildPublicStatus(s.cfg.Network, st, s.lnd.Lifecycle(), s.metrics.started, now)
+  publicStatusCache.at = now
+  return publicStatusCache.status
+}
+
+var publicStatusPage = template.Must(template.New("status").Funcs(template.FuncMap{"duration": formatUptime}).Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Alias}}{{.Alias}}{{else}}Lightning node{{end}} status</title>
+<style>
+body{font-family:system-ui,sans-serif;max-width:32rem;margin:3rem auto;padding:0 1rem;color:#1f2933}
+h1{font-size:1.4rem}dt{color:#616e7c;margin-top:.6rem}dd{margin:0;font-size:1.1rem}
+.up{color:#1f9d55}.down{color:#cc1f1a}
+</style>
+</head>
+<body>
+<h1>{{if .Alias}}{{.Alias}}{{else}}Lightning node{{end}}</h1>
+<p class="{{if .Online}}up{{else}}down{{end}}">{{if .Online}}Online{{else}}Offline{{end}}{{if and .Online (not .SyncedToChain)}}, syncing{{end}}</p>
+<dl>
+<dt>Channels</dt><dd>{{.ActiveChannels}} active of {{.Channels}}</dd>
+<dt>Last block</dt><dd>{{.BlockHeight}}{{if .BlockTime}} ({{.BlockTime.Format "2006-01-02 15:04 UTC"}}){{end}}</dd>
+<dt>LND uptime</dt><dd>{{duration .LNDUptimeSec}}</dd>
+<dt>Manager uptime</dt><dd>{{duration .ManagerUptimeSec}}</dd>
+<dt>Version</dt><dd>{{if .Version}}{{.Version}}{{else}}unknown{{end}}</dd>
+<dt>Network</dt><dd>{{.Network}}</dd>
+</dl>
+<p><small>Updated {{.GeneratedAt.Format "2006-01-02 15:04:05 UTC"}}</small></p>
+</body>
+</html>
+`))
+
+// formatUptime renders seconds as "3d 4h" or "25m".
+func formatUptime(seconds int64) string {
+  if seconds <= 0 {
+    return "-"
+  }
+  d := time.Duration(seconds) * time.Second
+  days := int64(d / (24 * time.Hour))
+  hours := int64(d % (24 * time.Hour) / time.Hour)
+  minutes := int64(d % time.Hour / time.Minute)
+  switch {
+  case days > 0:
+    return fmt.Sprintf("%dd %dh", days, hours)
+  case hours > 0:
+    return fmt.Sprintf("%dh %dm", hours, minutes)
+  }
+  return fmt.Sprintf("%dm", minutes)
+}
+
+// handlePublicStatus serves the status as JSON, or as a small HTML page
+// with ?format=html. Responses carry Cache-Control and an ETag so proxies
+// and browsers can cache them.
+func (s *Server) handlePublicStatus(w http.ResponseWriter, r *http.Request) {
+  if !s.cfg.PublicStatus.Enabled {
+    http.NotFound(w, r)
+    return
+  }
+  status := s.currentPublicStatus(r)
+
+  var body bytes.Buffer
+  contentType := "application/json"
+  if r.URL.Query().Get("format") == "html" {
+    contentType = "text/html; charset=utf-8"
+    if err := publicStatusPage.Execute(&body, status); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to render status page")
+      return
+    }
+  } else if err := json.NewEncoder(&body).Encode(status); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to render status page")
+    return
+  }
+  sum := sha256.Sum256(body.Bytes())
+  etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+  h := w.Header()
+  h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.cfg.PublicStatus.CacheTTL()/time.Second)))
+  h.Set("ETag", etag)
+  h.Set("Access-Control-Allow-Origin", "*")
+  h.Set("X-Content-Type-Options", "nosniff")
+  if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+    w.WriteHeader(http.StatusNotModified)
+    return
+  }
+  h.Set("Content-Type", contentType)
+  w.WriteHeader(http.StatusOK)
+  _, _ = w.Write(body.Bytes())
+}
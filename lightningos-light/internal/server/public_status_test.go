@@ -0,0 +1,82 @@
+package server
+
+import (
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/lndclient"
+)
+
+func resetPublicStatusCache(t *testing.T) {
+  publicStatusCache.mu.Lock()
+  publicStatusCache.at = time.Time{}
+  publicStatusCache.mu.Unlock()
+  t.Cleanup(func() {
+    publicStatusCache.mu.Lock()
+    publicStatusCache.at = time.Time{}
+    publicStatusCache.mu.Unlock()
+  })
+}
+
+func TestPublicStatusDisabledByDefault(t *testing.T) {
+  resetPublicStatusCache(t)
+  s, _ := newFakeLNDServer(t)
+  if rec := serveAPI(s, http.MethodGet, "/api/public/status", ""); rec.Code != http.StatusNotFound {
+    t.Fatalf("status = %d", rec.Code)
+  }
+}
+
+func TestPublicStatus(t *testing.T) {
+  resetPublicStatusCache(t)
+  s, fake := newFakeLNDServer(t)
+  s.cfg.PublicStatus.Enabled = true
+  fake.Status.Alias = "brln <node>"
+  fake.Channels = []lndclient.ChannelInfo{{Active: true}, {Active: true}, {Active: true}, {}}
+  fake.Status.OnchainSat = 123456
+  fake.Status.BestHeaderTime = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+  rec := serveAPI(s, http.MethodGet, "/api/public/status", "")
+  if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Cache-Control"), "public, max-age=60") {
+    t.Fatalf("status = %d, cache %q", rec.Code, rec.Header().Get("Cache-Control"))
+  }
+  var status publicStatus
+  if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+    t.Fatal(err)
+  }
+  if status.Alias != "brln <node>" || status.Channels != 4 || status.ActiveChannels != 3 || status.BlockHeight != 850000 || status.BlockTime == nil {
+    t.Fatalf("status %+v", status)
+  }
+  if strings.Contains(rec.Body.String(), "123456") || strings.Contains(rec.Body.String(), fake.Status.Pubkey) {
+    t.Fatalf("leaks funds or pubkey: %s", rec.Body.String())
+  }
+
+  req := httptest.NewRequest(http.MethodGet, "/api/public/status", nil)
+  req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+  cached := httptest.NewRecorder()
+  s.routes().ServeHTTP(cached, req)
+  if cached.Code != http.StatusNotModified {
+    t.Fatalf("conditional status = %d", cached.Code)
+  }
+
+  html := serveAPI(s, http.MethodGet, "/api/public/status?format=html", "")
+  if !strings.Contains(html.Body.String(), "brln &lt;node&gt;") || !strings.Contains(html.Body.String(), "3 active of 4") {
+    t.Fatalf("html %s", html.Body.String())
+  }
+}
+
+func TestBuildPublicStatusUptime(t *testing.T) {
+  now := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+  life := lndclient.Lifecycle{State: lndclient.StateServerActive, Since: now.Add(-26 * time.Hour)}
+  status := buildPublicStatus("mainnet", lndclient.Status{ServiceActive: true}, life, now.Add(-time.Hour), now)
+  if status.LNDUptimeSec != 26*3600 || status.ManagerUptimeSec != 3600 || formatUptime(status.LNDUptimeSec) != "1d 2h" {
+    t.Fatalf("status %+v", status)
+  }
+  life.State = lndclient.StateRestarting
+  if status := buildPublicStatus("mainnet", lndclient.Status{}, life, now, now); status.LNDUptimeSec != 0 || status.Online {
+    t.Fatalf("restarting %+v", status)
+  }
+}
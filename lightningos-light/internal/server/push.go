@@ -0,0 +1,567 @@
+package server
+
+import (
+  "bytes"
+  "context"
+  "crypto/rand"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "net/url"
+  "os"
+  "path/filepath"
+  "regexp"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/config"
+
+  "github.com/go-chi/chi/v5"
+)
+
+const (
+  pushTopicsPath = "/var/lib/lightningos/push-topics.json"
+  pushProviderNtfy = "ntfy"
+  pushProviderUnifiedPush = "unifiedpush"
+  defaultNtfyServer = "https://ntfy.sh"
+  pushSendTimeout = 15 * time.Second
+  pushSentTTL = 24 * time.Hour
+)
+
+var ntfyTopicPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// pushTopic is one phone-facing destination: an ntfy topic (self-hosted or
+// ntfy.sh) or a UnifiedPush endpoint. The token and the endpoint are secrets
+// and never leave the server; GET only says whether they are set.
+type pushTopic struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  Provider string `json:"provider"`
+  Server string `json:"server,omitempty"`
+  Topic string `json:"topic,omitempty"`
+  Endpoint string `json:"endpoint,omitempty"`
+  Token string `json:"token,omitempty"`
+  Enabled bool `json:"enabled"`
+  // CriticalOnly limits the topic to channel closes, config drift and
+  // report anomalies.
+  CriticalOnly bool `json:"critical_only"`
+  Types []string `json:"types,omitempty"`
+  MinAmountSat int64 `json:"min_amount_sat,omitempty"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+type pushTopicView struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  Provider string `json:"provider"`
+  Server string `json:"server,omitempty"`
+  Topic string `json:"topic,omitempty"`
+  EndpointSet bool `json:"endpoint_set"`
+  TokenSet bool `json:"token_set"`
+  Enabled bool `json:"enabled"`
+  CriticalOnly bool `json:"critical_only"`
+  Types []string `json:"types,omitempty"`
+  MinAmountSat int64 `json:"min_amount_sat,omitempty"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+func (t pushTopic) view() pushTopicView {
+  return pushTopicView{
+    ID: t.ID,
+    Name: t.Name,
+    Provider: t.Provider,
+    Server: t.Server,
+    Topic: t.Topic,
+    EndpointSet: t.Endpoint != "",
+    TokenSet: t.Token != "",
+    Enabled: t.Enabled,
+    CriticalOnly: t.CriticalOnly,
+    Types: t.Types,
+    MinAmountSat: t.MinAmountSat,
+    CreatedAt: t.CreatedAt,
+  }
+}
+
+// notificationCritical marks the events worth waking someone up for.
+func notificationCritical(evt Notification) bool {
+  switch evt.Type {
+  case "channel":
+    return evt.Action == "close" || evt.Action == "closing"
+  case "config", "report", "alert", "scheduled_payment", "backup", "lnd_db", "security", "inbound_experiment":
+    return true
+  case "bitcoin":
+    return evt.Action == "behind"
+  }
+  return false
+}
+
+func (t pushTopic) matches(evt Notification) bool {
+  if !t.Enabled {
+    return false
+  }
+  if t.CriticalOnly && !notificationCritical(evt) {
+    return false
+  }
+  return subscriberFilter{Types: t.Types, MinAmountSat: t.MinAmountSat}.match(evt)
+}
+
+var pushTopicsMu sync.Mutex
+
+func loadPushTopics() ([]pushTopic, error) {
+  raw, err := os.ReadFile(pushTopicsPath)
+  if errors.Is(err, os.ErrNotExist) {
+    return []pushTopic{}, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  topics := []pushTopic{}
+  if err := json.Unmarshal(raw, &topics); err != nil {
+    return nil, fmt.Errorf("invalid %s: %w", pushTopicsPath, err)
+  }
+  return topics, nil
+}
+
+func savePushTopics(topics []pushTopic) error {
+  if err := os.MkdirAll(filepath.Dir(pushTopicsPath), 0o750); err != nil {
+    return err
+  }
+  data, err := json.MarshalIndent(topics, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(pushTopicsPath, data, 0o600)
+}
+
+func randomPushTopic() string {
+  buf := make([]byte, 12)
+  _, _ = rand.Read(buf)
+  return "lightningos-" + hex.EncodeToString(buf)
+}
+
+func newPushTopicID() string {
+  buf := make([]byte, 6)
+  _, _ = rand.Read(buf)
+  return hex.EncodeToString(buf)
+}
+
+func validPushURL(raw string) bool {
+  u, err := url.Parse(raw)
+  return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// pushTitle and pushBody render an event as a short phone notification.
+func pushTitle(evt Notification) string {
+  switch evt.Type {
+  case "channel":
+    switch {
+    case evt.Status == "FORCE_CLOSING":
+      return "Channel force-closing"
+    case evt.Action == "closing":
+      return "Channel closing"
+    case evt.Action == "close_broadcast":
+      return "Channel close broadcast"
+    case evt.Action == "close":
+      return "Channel closed"
+    case evt.Action == "opening":
+      return "Channel opening"
+    case evt.Action == "open":
+      return "Channel opened"
+    }
+    return "Channel update"
+  case "lightning":
+    if evt.Direction == "in" {
+      return "Payment received"
+    }
+    return "Payment sent"
+  case "onchain":
+    if evt.Direction == "in" {
+      return "On-chain deposit"
+    }
+    return "On-chain send"
+  case "forward":
+    return "Payment forwarded"
+  case "rebalance":
+    return "Rebalance done"
+  case "keysend":
+    return "Keysend message"
+  case "config":
+    return "Config changed outside the manager"
+  case "report":
+    return "Report anomaly"
+  case "backup":
+    return "Backup overdue"
+  case "lnd_db":
+    return "LND database problem"
+  case "security":
+    return "Repeated failed logins"
+  case "bitcoin":
+    if evt.Action == "behind" {
+      return "Bitcoin node behind explorers"
+    }
+    return "New block"
+  case "inbound_experiment":
+    return "Inbound fee not restored"
+  case "scheduled_payment":
+    if evt.Action == scheduledRunSkipped {
+      return "Scheduled payment limit reached"
+    }
+    if evt.Action == scheduledRunUnknown {
+      return "Scheduled payment outcome unknown"
+    }
+    return "Scheduled payment failed"
+  }
+  return "LightningOS"
+}
+
+func pushBody(evt Notification, nodeID string) string {
+  parts := []string{}
+  if evt.PeerAlias != "" {
+    parts = append(parts, evt.PeerAlias)
+  } else if evt.PeerPubkey != "" {
+    parts = append(parts, shortPubkey(evt.PeerPubkey))
+  }
+  if evt.AmountSat != 0 {
+    parts = append(parts, fmt.Sprintf("%d sat", evt.AmountSat))
+  }
+  if evt.Memo != "" {
+    parts = append(parts, evt.Memo)
+  }
+  if evt.Status != "" {
+    parts = append(parts, strings.ToLower(evt.Status))
+  }
+  if nodeID != "" && nodeID != config.DefaultNodeID {
+    parts = append(parts, "node "+nodeID)
+  }
+  if len(parts) == 0 {
+    return pushTitle(evt)
+  }
+  return strings.Join(parts, " · ")
+}
+
+func shortPubkey(pubkey string) string {
+  if len(pubkey) <= 16 {
+    return pubkey
+  }
+  return pubkey[:8] + "…" + pubkey[len(pubkey)-8:]
+}
+
+// sendPush delivers one message. ntfy gets its native headers; a UnifiedPush
+// endpoint gets a small JSON document, which distributors pass to the app as
+// is.
+func sendPush(ctx context.Context, client *http.Client, t pushTopic, title, body string, critical bool) error {
+  var req *http.Request
+  var err error
+  switch t.Provider {
+  case pushProviderNtfy:
+    server := strings.TrimRight(t.Server, "/")
+    if server == "" {
+      server = defaultNtfyServer
+    }
+    req, err = http.NewRequestWithContext(ctx, http.MethodPost, server+"/"+url.PathEscape(t.Topic), strings.NewReader(body))
+    if err != nil {
+      return err
+    }
+    req.Header.Set("Title", title)
+    req.Header.Set("Tags", "zap")
+    if critical {
+      req.Header.Set("Priority", "urgent")
+      req.Header.Set("Tags", "warning,zap")
+    }
+    if t.Token != "" {
+      req.Header.Set("Authorization", "Bearer "+t.Token)
+    }
+  case pushProviderUnifiedPush:
+    payload, _ := json.Marshal(map[string]any{"title": title, "message": body, "critical": critical})
+    req, err = http.NewRequestWithContext(ctx, http.MethodPost, t.Endpoint, bytes.NewReader(payload))
+    if err != nil {
+      return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if critical {
+      req.Header.Set("Urgency", "high")
+    }
+  default:
+    return fmt.Errorf("unknown push provider %q", t.Provider)
+  }
+  resp, err := client.Do(req)
+  if err != nil {
+    return err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+    msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+    return fmt.Errorf("push server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+  }
+  return nil
+}
+
+// markPushSent reports whether evt is new to the push gateway. Upserts send
+// the same row again on every status change; only the first of each status
+// goes out.
+func (n *Notifier) markPushSent(evt Notification) bool {
+  key := fmt.Sprintf("%d:%s", evt.ID, evt.Status)
+  now := time.Now()
+  n.pushMu.Lock()
+  defer n.pushMu.Unlock()
+  if n.pushSent == nil {
+    n.pushSent = map[string]time.Time{}
+  }
+  if _, ok := n.pushSent[key]; ok {
+    return false
+  }
+  for k, at := range n.pushSent {
+    if now.Sub(at) > pushSentTTL {
+      delete(n.pushSent, k)
+    }
+  }
+  n.pushSent[key] = now
+  return true
+}
+
+// triggerPush fans a stored notification out to the matching push topics.
+func (n *Notifier) triggerPush(evt Notification) {
+  n.sendPushes(evt, true)
+}
+
+// sendPushes posts evt to the matching push topics. With dedupe an event
+// already pushed in the last pushSentTTL is skipped; a replay asks for it
+// again and goes out regardless.
+func (n *Notifier) sendPushes(evt Notification, dedupe bool) {
+  pushTopicsMu.Lock()
+  topics, err := loadPushTopics()
+  pushTopicsMu.Unlock()
+  if err != nil {
+    n.logger.Printf("notifications: push topics unavailable: %v", err)
+    return
+  }
+  matched := []pushTopic{}
+  for _, t := range topics {
+    if t.matches(evt) {
+      matched = append(matched, t)
+    }
+  }
+  if len(matched) == 0 {
+    return
+  }
+  if dedupe && !n.markPushSent(evt) {
+    return
+  }
+  title, body, critical := pushTitle(evt), pushBody(evt, n.nodeKey()), notificationCritical(evt)
+  go func() {
+    for _, t := range matched {
+      ctx, cancel := context.WithTimeout(n.context(), pushSendTimeout)
+      if err := sendPush(ctx, http.DefaultClient, t, title, body, critical); err != nil {
+        n.logger.Printf("notifications: push to %s failed: %v", t.Name, err)
+      }
+      cancel()
+    }
+  }()
+}
+
+func (s *Server) handlePushTopicsList(w http.ResponseWriter, r *http.Request) {
+  pushTopicsMu.Lock()
+  topics, err := loadPushTopics()
+  pushTopicsMu.Unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load push topics: %v", err))
+    return
+  }
+  items := make([]pushTopicView, 0, len(topics))
+  for _, t := range topics {
+    items = append(items, t.view())
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handlePushTopicsSave creates a topic, or updates the one named by id. Empty
+// token/endpoint fields keep the stored secret; "clear_token" removes it.
+func (s *Server) handlePushTopicsSave(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    ID string `json:"id"`
+    Name string `json:"name"`
+    Provider string `json:"provider"`
+    Server string `json:"server"`
+    Topic string `json:"topic"`
+    Endpoint string `json:"endpoint"`
+    Token string `json:"token"`
+    ClearToken bool `json:"clear_token"`
+    Enabled *bool `json:"enabled"`
+    CriticalOnly *bool `json:"critical_only"`
+    Types []string `json:"types"`
+    MinAmountSat int64 `json:"min_amount_sat"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+
+  pushTopicsMu.Lock()
+  defer pushTopicsMu.Unlock()
+  topics, err := loadPushTopics()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load push topics: %v", err))
+    return
+  }
+
+  idx := -1
+  topic := pushTopic{ID: newPushTopicID(), Enabled: true, CriticalOnly: true, CreatedAt: time.Now().UTC()}
+  if id := strings.TrimSpace(req.ID); id != "" {
+    for i, t := range topics {
+      if t.ID == id {
+        idx, topic = i, t
+        break
+      }
+    }
+    if idx < 0 {
+      writeError(w, http.StatusNotFound, "push topic not found")
+      return
+    }
+  }
+
+  if name := strings.TrimSpace(req.Name); name != "" {
+    topic.Name = name
+  }
+  if provider := strings.ToLower(strings.TrimSpace(req.Provider)); provider != "" {
+    topic.Provider = provider
+  }
+  if req.Enabled != nil {
+    topic.Enabled = *req.Enabled
+  }
+  if req.CriticalOnly != nil {
+    topic.CriticalOnly = *req.CriticalOnly
+  }
+  if req.Types != nil {
+    topic.Types = []string{}
+    for _, t := range req.Types {
+      t = strings.ToLower(strings.TrimSpace(t))
+      if t == "" {
+        continue
+      }
+      if !notificationRuleTypes[t] {
+        writeError(w, http.StatusBadRequest, "invalid notification type")
+        return
+      }
+      topic.Types = append(topic.Types, t)
+    }
+  }
+  if req.MinAmountSat < 0 {
+    writeError(w, http.StatusBadRequest, "invalid min_amount_sat")
+    return
+  }
+  topic.MinAmountSat = req.MinAmountSat
+  if token := strings.TrimSpace(req.Token); token != "" {
+    topic.Token = token
+  } else if req.ClearToken {
+    topic.Token = ""
+  }
+
+  switch topic.Provider {
+  case pushProviderNtfy:
+    if server := strings.TrimSpace(req.Server); server != "" {
+      topic.Server = strings.TrimRight(server, "/")
+    }
+    if topic.Server == "" {
+      topic.Server = defaultNtfyServer
+    }
+    if !validPushURL(topic.Server) {
+      writeError(w, http.StatusBadRequest, "invalid push server url")
+      return
+    }
+    if t := strings.TrimSpace(req.Topic); t != "" {
+      topic.Topic = t
+    }
+    // On a public server the topic name is the only secret, so make one up
+    // that cannot be guessed.
+    if topic.Topic == "" {
+      topic.Topic = randomPushTopic()
+    }
+    if !ntfyTopicPattern.MatchString(topic.Topic) {
+      writeError(w, http.StatusBadRequest, "invalid ntfy topic")
+      return
+    }
+    topic.Endpoint = ""
+  case pushProviderUnifiedPush:
+    if endpoint := strings.TrimSpace(req.Endpoint); endpoint != "" {
+      topic.Endpoint = endpoint
+    }
+    if !validPushURL(topic.Endpoint) {
+      writeError(w, http.StatusBadRequest, "invalid push endpoint url")
+      return
+    }
+    topic.Server, topic.Topic, topic.Token = "", "", ""
+  default:
+    writeError(w, http.StatusBadRequest, "provider must be ntfy or unifiedpush")
+    return
+  }
+  if topic.Name == "" {
+    topic.Name = topic.Provider
+  }
+
+  if idx >= 0 {
+    topics[idx] = topic
+  } else {
+    topics = append(topics, topic)
+  }
+  if err := savePushTopics(topics); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store push topics: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, topic.view())
+}
+
+func (s *Server) handlePushTopicsDelete(w http.ResponseWriter, r *http.Request) {
+  id := chi.URLParam(r, "id")
+  pushTopicsMu.Lock()
+  defer pushTopicsMu.Unlock()
+  topics, err := loadPushTopics()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load push topics: %v", err))
+    return
+  }
+  kept := topics[:0]
+  for _, t := range topics {
+    if t.ID != id {
+      kept = append(kept, t)
+    }
+  }
+  if len(kept) == len(topics) {
+    writeError(w, http.StatusNotFound, "push topic not found")
+    return
+  }
+  if err := savePushTopics(kept); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store push topics: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) handlePushTopicsTest(w http.ResponseWriter, r *http.Request) {
+  id := chi.URLParam(r, "id")
+  pushTopicsMu.Lock()
+  topics, err := loadPushTopics()
+  pushTopicsMu.Unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load push topics: %v", err))
+    return
+  }
+  for _, t := range topics {
+    if t.ID != id {
+      continue
+    }
+    ctx, cancel := s.requestContext(r, timeoutMedium)
+    defer cancel()
+    body := fmt.Sprintf("Test notification sent %s", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+    if err := sendPush(ctx, http.DefaultClient, t, "LightningOS test", body, false); err != nil {
+      writeError(w, http.StatusBadGateway, fmt.Sprintf("push test failed: %v", err))
+      return
+    }
+    writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+    return
+  }
+  writeError(w, http.StatusNotFound, "push topic not found")
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+)
+
+func TestPushTopicMatches(t *testing.T) {
+  critical := pushTopic{Enabled: true, CriticalOnly: true}
+  if !critical.matches(Notification{Type: "channel", Action: "closing", Status: "FORCE_CLOSING"}) {
+    t.Fatal("force close not pushed")
+  }
+  if critical.matches(Notification{Type: "lightning", Direction: "in", AmountSat: 1000}) {
+    t.Fatal("payment pushed on a critical-only topic")
+  }
+  payments := pushTopic{Enabled: true, Types: []string{"lightning"}, MinAmountSat: 5000}
+  if payments.matches(Notification{Type: "lightning", AmountSat: 100}) || !payments.matches(Notification{Type: "lightning", AmountSat: 6000}) {
+    t.Fatal("amount filter not applied")
+  }
+  if (pushTopic{Enabled: false}).matches(Notification{Type: "config"}) {
+    t.Fatal("disabled topic matched")
+  }
+}
+
+func TestSendPushNtfy(t *testing.T) {
+  var got *http.Request
+  var body string
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    raw, _ := io.ReadAll(r.Body)
+    got, body = r, string(raw)
+  }))
+  defer srv.Close()
+
+  topic := pushTopic{Provider: pushProviderNtfy, Server: srv.URL, Topic: "node-alerts", Token: "tk_secret"}
+  if err := sendPush(context.Background(), srv.Client(), topic, "Channel force-closing", "bob · 50000 sat", true); err != nil {
+    t.Fatal(err)
+  }
+  if got.URL.Path != "/node-alerts" || got.Header.Get("Authorization") != "Bearer tk_secret" {
+    t.Fatalf("unexpected request: %s %v", got.URL.Path, got.Header)
+  }
+  if got.Header.Get("Title") != "Channel force-closing" || got.Header.Get("Priority") != "urgent" || body != "bob · 50000 sat" {
+    t.Fatalf("unexpected message: %v %q", got.Header, body)
+  }
+}
+
+func TestSendPushUnifiedPush(t *testing.T) {
+  var payload map[string]any
+  status := http.StatusCreated
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    _ = json.NewDecoder(r.Body).Decode(&payload)
+    w.WriteHeader(status)
+  }))
+  defer srv.Close()
+
+  topic := pushTopic{Provider: pushProviderUnifiedPush, Endpoint: srv.URL + "/up/abc"}
+  if err := sendPush(context.Background(), srv.Client(), topic, "Payment received", "2100 sat", false); err != nil {
+    t.Fatal(err)
+  }
+  if payload["title"] != "Payment received" || payload["message"] != "2100 sat" {
+    t.Fatalf("unexpected payload: %v", payload)
+  }
+
+  status = http.StatusGone
+  err := sendPush(context.Background(), srv.Client(), topic, "x", "y", false)
+  if err == nil || !strings.Contains(err.Error(), "410") {
+    t.Fatalf("expected endpoint error, got %v", err)
+  }
+}
+
+func TestMarkPushSent(t *testing.T) {
+  n := &Notifier{}
+  evt := Notification{ID: 7, Status: "PENDING"}
+  if !n.markPushSent(evt) || n.markPushSent(evt) {
+    t.Fatal("same status pushed twice")
+  }
+  evt.Status = "FORCE_CLOSING"
+  if !n.markPushSent(evt) {
+    t.Fatal("status change not pushed")
+  }
+}
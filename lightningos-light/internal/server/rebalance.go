@@ -0,0 +1,164 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/rebalance"
+  "lightningos-light/lnrpc"
+
+  "github.com/go-chi/chi/v5"
+)
+
+const jobKindRebalance = "rebalance"
+
+type rebalanceProgress struct {
+  Stage rebalance.Stage `json:"stage"`
+}
+
+// handleLNRebalance starts a rebalance job. One runs at a time per outgoing
+// channel, since two payments out of the same channel would compete for
+// its balance.
+func (s *Server) handleLNRebalance(w http.ResponseWriter, r *http.Request) {
+  var req rebalance.Request
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if err := req.Validate(); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  job, err := s.jobs.submit(s.nodeIDFor(r), jobKindRebalance, strconv.FormatUint(req.OutgoingChanID, 10), req)
+  if err != nil {
+    writeJobSubmitError(w, err)
+    return
+  }
+  writeJobStarted(w, job)
+}
+
+func (s *Server) handleLNRebalanceJobs(w http.ResponseWriter, r *http.Request) {
+  limit := jobListDefaultLimit
+  if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed <= 0 {
+      writeError(w, http.StatusBadRequest, "invalid limit")
+      return
+    }
+    limit = min(parsed, jobListMaxLimit)
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  items, err := s.jobs.list(ctx, s.nodeIDFor(r), jobKindRebalance, limit)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load jobs")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Server) handleLNRebalanceJob(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  job, ok, err := s.jobs.get(ctx, s.nodeIDFor(r), chi.URLParam(r, "id"))
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to load jobs")
+    return
+  }
+  if !ok || job.Kind != jobKindRebalance {
+    writeError(w, http.StatusNotFound, "job not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, job)
+}
+
+// runRebalanceJob makes one rebalance attempt. It is not resumable: after a
+// restart the payment may have gone through, and LND's payment history is
+// the place to check.
+func (s *Server) runRebalanceJob(ctx context.Context, job *jobHandle) (any, error) {
+  var req rebalance.Request
+  if err := job.Params(&req); err != nil {
+    return nil, err
+  }
+  lnd, ok := s.lndForNode(job.NodeID())
+  if !ok {
+    return nil, unknownNodeError(job.NodeID())
+  }
+  if err := lnd.WaitReady(ctx); err != nil {
+    return nil, err
+  }
+  conn, err := lnd.DialLightning(ctx)
+  if err != nil {
+    return nil, errors.New(lndDetailedErrorMessage(err))
+  }
+  defer conn.Close()
+
+  engine := rebalance.New(lnrpc.NewLightningClient(conn))
+  step := 0
+  engine.OnStage = func(stage rebalance.Stage) {
+    job.Progress(step, 3, rebalanceProgress{Stage: stage})
+    step++
+  }
+  result, err := engine.Run(ctx, req)
+  if err != nil {
+    return nil, err
+  }
+  job.Progress(3, 3, nil)
+  s.recordRebalance(ctx, job.NodeID(), result)
+  return result, nil
+}
+
+// recordRebalance stores the rebalance in the notifications the way the
+// pollers would: the settled invoice and the sent payment, which
+// reconcileRebalance folds into one rebalance event. When a poller got to it
+// first the event is already there and is left alone.
+func (s *Server) recordRebalance(ctx context.Context, nodeID string, result rebalance.Result) {
+  notifier, ok := s.notifierForNode(nodeID)
+  if !ok || notifier == nil || notifier.db == nil {
+    return
+  }
+  ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+  defer cancel()
+  if notifier.isRebalanceHash(ctx, result.PaymentHash) {
+    return
+  }
+  now := time.Now().UTC()
+  received := Notification{
+    OccurredAt: now,
+    Type: "lightning",
+    Action: "received",
+    Direction: "in",
+    Status: "SETTLED",
+    AmountSat: result.AmountSat,
+    PeerPubkey: result.IncomingPeer,
+    PaymentHash: result.PaymentHash,
+    Memo: result.Memo,
+  }
+  if _, err := notifier.upsertNotification(ctx, fmt.Sprintf("invoice:%s", result.PaymentHash), received); err != nil {
+    s.logger.Printf("rebalance: failed to record invoice %s: %v", result.PaymentHash, err)
+    return
+  }
+  sent := Notification{
+    OccurredAt: now,
+    Type: "lightning",
+    Action: "sent",
+    Direction: "out",
+    Status: "SUCCEEDED",
+    AmountSat: result.AmountSat,
+    FeeSat: result.FeeMsat / 1000,
+    FeeMsat: result.FeeMsat,
+    PeerPubkey: result.OutgoingPeer,
+    PaymentHash: result.PaymentHash,
+    Memo: result.Memo,
+  }
+  if _, err := notifier.upsertNotification(ctx, fmt.Sprintf("payment:%s", result.PaymentHash), sent); err != nil {
+    s.logger.Printf("rebalance: failed to record payment %s: %v", result.PaymentHash, err)
+    return
+  }
+  notifier.reconcileRebalance(ctx, result.PaymentHash)
+}
@@ -12,6 +12,9 @@ type reportsConfigPayload struct {
   LiveTimeoutSec *int `json:"live_timeout_sec,omitempty"`
   LiveLookbackHours *int `json:"live_lookback_hours,omitempty"`
   RunTimeoutSec *int `json:"run_timeout_sec,omitempty"`
+  AnomalyTrailingDays *int `json:"anomaly_trailing_days,omitempty"`
+  AnomalyRevenueDropPct *int `json:"anomaly_revenue_drop_pct,omitempty"`
+  AnomalyRebalanceSpikePct *int `json:"anomaly_rebalance_spike_pct,omitempty"`
 }
 
 func (s *Server) handleReportsConfigGet(w http.ResponseWriter, r *http.Request) {
@@ -19,6 +22,9 @@ func (s *Server) handleReportsConfigGet(w http.ResponseWriter, r *http.Request)
     LiveTimeoutSec: readEnvInt(secretsPath, "REPORTS_LIVE_TIMEOUT_SEC"),
     LiveLookbackHours: readEnvInt(secretsPath, "REPORTS_LIVE_LOOKBACK_HOURS"),
     RunTimeoutSec: readEnvInt(secretsPath, "REPORTS_RUN_TIMEOUT_SEC"),
+    AnomalyTrailingDays: readEnvInt(secretsPath, "REPORTS_ANOMALY_TRAILING_DAYS"),
+    AnomalyRevenueDropPct: readEnvInt(secretsPath, "REPORTS_ANOMALY_REVENUE_DROP_PCT"),
+    AnomalyRebalanceSpikePct: readEnvInt(secretsPath, "REPORTS_ANOMALY_REBALANCE_SPIKE_PCT"),
   }
   writeJSON(w, http.StatusOK, payload)
 }
@@ -47,6 +53,18 @@ func (s *Server) handleReportsConfigPost(w http.ResponseWriter, r *http.Request)
     writeError(w, http.StatusInternalServerError, "failed to update report timeout")
     return
   }
+  if err := applyEnvInt(secretsPath, "REPORTS_ANOMALY_TRAILING_DAYS", payload.AnomalyTrailingDays); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to update anomaly trailing days")
+    return
+  }
+  if err := applyEnvInt(secretsPath, "REPORTS_ANOMALY_REVENUE_DROP_PCT", payload.AnomalyRevenueDropPct); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to update anomaly revenue threshold")
+    return
+  }
+  if err := applyEnvInt(secretsPath, "REPORTS_ANOMALY_REBALANCE_SPIKE_PCT", payload.AnomalyRebalanceSpikePct); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to update anomaly rebalance threshold")
+    return
+  }
 
   writeJSON(w, http.StatusOK, payload)
 }
@@ -81,6 +99,8 @@ func applyEnvInt(path string, key string, value *int) error {
 }
 
 func removeEnvFileValue(path string, key string) error {
+  unlock := lockConfigFile(path)
+  defer unlock()
   data, err := os.ReadFile(path)
   if err != nil {
     if os.IsNotExist(err) {
@@ -102,5 +122,5 @@ func removeEnvFileValue(path string, key string) error {
     filtered = append(filtered, line)
   }
   output := strings.TrimRight(strings.Join(filtered, "\n"), "\n") + "\n"
-  return os.WriteFile(path, []byte(output), 0o660)
+  return writeFileAtomic(path, []byte(output), 0o660)
 }
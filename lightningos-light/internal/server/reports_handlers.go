@@ -2,13 +2,17 @@ package server
 
 import (
   "context"
+  "errors"
   "fmt"
+  "math"
   "net/http"
   "os"
   "strconv"
   "strings"
   "time"
 
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
   "lightningos-light/internal/reports"
 )
 
@@ -24,16 +28,26 @@ func (s *Server) handleReportsRange(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusServiceUnavailable, msg)
     return
   }
+  loc, tzLabel, err := s.reportsLocation(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  fiat, err := reportFiatCurrency(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
 
   key := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("range")))
   if key == "" {
     key = reports.RangeD1
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
   defer cancel()
 
-  items, _, err := svc.Range(ctx, key, time.Now(), time.Local)
+  items, _, err := svc.Range(ctx, key, time.Now(), loc)
   if err != nil {
     if strings.Contains(err.Error(), "invalid range") {
       writeError(w, http.StatusBadRequest, err.Error())
@@ -43,10 +57,12 @@ func (s *Server) handleReportsRange(w http.ResponseWriter, r *http.Request) {
     return
   }
 
+  series := mapSeries(items)
+  s.attachFiatPrices(ctx, fiat, loc, series)
   writeJSON(w, http.StatusOK, reportSeriesResponse{
     Range: key,
-    Timezone: reportsTimezoneLabel,
-    Series: mapSeries(items),
+    Timezone: tzLabel,
+    Series: series,
   })
 }
 
@@ -60,6 +76,16 @@ func (s *Server) handleReportsCustom(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusServiceUnavailable, msg)
     return
   }
+  loc, tzLabel, err := s.reportsLocation(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  fiat, err := reportFiatCurrency(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
 
   fromStr := strings.TrimSpace(r.URL.Query().Get("from"))
   toStr := strings.TrimSpace(r.URL.Query().Get("to"))
@@ -68,12 +94,12 @@ func (s *Server) handleReportsCustom(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  startDate, err := reports.ParseDate(fromStr, time.Local)
+  startDate, err := reports.ParseDate(fromStr, loc)
   if err != nil {
     writeError(w, http.StatusBadRequest, "from must be YYYY-MM-DD")
     return
   }
-  endDate, err := reports.ParseDate(toStr, time.Local)
+  endDate, err := reports.ParseDate(toStr, loc)
   if err != nil {
     writeError(w, http.StatusBadRequest, "to must be YYYY-MM-DD")
     return
@@ -87,7 +113,7 @@ func (s *Server) handleReportsCustom(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
   defer cancel()
 
   items, err := svc.CustomRange(ctx, startDate, endDate)
@@ -96,10 +122,12 @@ func (s *Server) handleReportsCustom(w http.ResponseWriter, r *http.Request) {
     return
   }
 
+  series := mapSeries(items)
+  s.attachFiatPrices(ctx, fiat, loc, series)
   writeJSON(w, http.StatusOK, reportSeriesResponse{
     Range: "custom",
-    Timezone: reportsTimezoneLabel,
-    Series: mapSeries(items),
+    Timezone: tzLabel,
+    Series: series,
   })
 }
 
@@ -113,16 +141,26 @@ func (s *Server) handleReportsSummary(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusServiceUnavailable, msg)
     return
   }
+  loc, tzLabel, err := s.reportsLocation(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  fiat, err := reportFiatCurrency(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
 
   key := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("range")))
   if key == "" {
     key = reports.RangeD1
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
   defer cancel()
 
-  summary, _, err := svc.Summary(ctx, key, time.Now(), time.Local)
+  summary, _, err := svc.Summary(ctx, key, time.Now(), loc)
   if err != nil {
     if strings.Contains(err.Error(), "invalid range") {
       writeError(w, http.StatusBadRequest, err.Error())
@@ -132,17 +170,23 @@ func (s *Server) handleReportsSummary(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  writeJSON(w, http.StatusOK, reportSummaryResponse{
+  resp := reportSummaryResponse{
     Range: key,
-    Timezone: reportsTimezoneLabel,
+    Timezone: tzLabel,
     Days: summary.Days,
     Totals: metricsPayload(summary.Totals),
     Averages: metricsPayload(summary.Averages),
-  })
+  }
+  if fiat != "" {
+    if quote, err := s.prices.quote(ctx, fiat); err == nil {
+      resp.Fiat = fiatTotals(quote, resp.Totals)
+    }
+  }
+  writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) handleReportsLive(w http.ResponseWriter, r *http.Request) {
-  svc, errMsg := s.reportsService()
+  svc, errMsg := s.reportsFor(r)
   if svc == nil {
     msg := strings.TrimSpace(errMsg)
     if msg == "" {
@@ -151,11 +195,16 @@ func (s *Server) handleReportsLive(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusServiceUnavailable, msg)
     return
   }
+  loc, tzLabel, err := s.reportsLocation(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
 
   ctx, cancel := context.WithTimeout(r.Context(), reportsLiveTimeout())
   defer cancel()
 
-  tr, metrics, err := svc.Live(ctx, time.Now(), time.Local, reportsLiveLookbackHours())
+  tr, metrics, err := svc.Live(ctx, time.Now(), loc, reportsLiveLookbackHours())
   if err != nil {
     writeError(w, http.StatusServiceUnavailable, "live report unavailable")
     return
@@ -164,11 +213,132 @@ func (s *Server) handleReportsLive(w http.ResponseWriter, r *http.Request) {
   payload := metricsPayload(metrics)
   payload.Start = tr.StartLocal.Format(time.RFC3339)
   payload.End = tr.EndLocal.Format(time.RFC3339)
-  payload.Timezone = reportsTimezoneLabel
+  payload.Timezone = tzLabel
 
   writeJSON(w, http.StatusOK, payload)
 }
 
+func (s *Server) handleReportsRebalancePairs(w http.ResponseWriter, r *http.Request) {
+  svc, errMsg := s.reportsService()
+  if svc == nil {
+    msg := strings.TrimSpace(errMsg)
+    if msg == "" {
+      msg = "reports unavailable"
+    }
+    writeError(w, http.StatusServiceUnavailable, msg)
+    return
+  }
+  loc, tzLabel, err := s.reportsLocation(r)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  key := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("range")))
+  if key == "" {
+    key = reports.RangeMonth
+  }
+  limit := 10
+  if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed <= 0 || parsed > 100 {
+      writeError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+      return
+    }
+    limit = parsed
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+
+  // With a tag filter every pair is loaded and the limit applied after
+  // filtering.
+  meta := currentPeerMetadata()
+  tag, tagged := peerTagFilter(r, meta)
+  fetchLimit := limit
+  if tag != "" {
+    fetchLimit = 0
+  }
+  pairs, err := svc.RebalancePairs(ctx, key, time.Now(), loc, fetchLimit)
+  if err != nil {
+    if strings.Contains(err.Error(), "invalid range") {
+      writeError(w, http.StatusBadRequest, err.Error())
+    } else {
+      writeError(w, http.StatusInternalServerError, "failed to load rebalance pairs")
+    }
+    return
+  }
+
+  // Channel names are best effort: closed channels keep only their id.
+  channels := map[uint64]lndclient.ChannelInfo{}
+  if list, err := s.lnd.ListChannels(ctx); err == nil {
+    for _, ch := range list {
+      channels[ch.ChannelID] = ch
+    }
+  }
+
+  items := make([]reportRebalancePair, 0, len(pairs))
+  for _, pair := range pairs {
+    out := channels[pair.OutChanID]
+    in := channels[pair.InChanID]
+    if tag != "" && !tagged(out.RemotePubkey) && !tagged(in.RemotePubkey) {
+      continue
+    }
+    if len(items) == limit {
+      break
+    }
+    items = append(items, reportRebalancePair{
+      OutChannelID: strconv.FormatUint(pair.OutChanID, 10),
+      OutChannelPoint: out.ChannelPoint,
+      OutPeerAlias: out.PeerAlias,
+      InChannelID: strconv.FormatUint(pair.InChanID, 10),
+      InChannelPoint: in.ChannelPoint,
+      InPeerAlias: in.PeerAlias,
+      FeeSat: float64(pair.FeeMsat) / 1000,
+      AmountSat: float64(pair.AmountMsat) / 1000,
+      Count: pair.Count,
+    })
+  }
+
+  writeJSON(w, http.StatusOK, map[string]any{
+    "range": key,
+    "timezone": tzLabel,
+    "items": items,
+  })
+}
+
+// reportsLocation resolves the reporting timezone from config.yaml, honoring a
+// per-request ?tz= override. Stored days keep the boundaries they were
+// computed with; the override only shifts which days a range selects.
+func (s *Server) reportsLocation(r *http.Request) (*time.Location, string, error) {
+  return s.reportsLocationNamed(r.URL.Query().Get("tz"))
+}
+
+// reportsLocationNamed resolves a tz override, falling back to the
+// configured reporting timezone when it is empty.
+func (s *Server) reportsLocationNamed(name string) (*time.Location, string, error) {
+  name = strings.TrimSpace(name)
+  if name == "" {
+    if s.cfg.Reports.Timezone == "" {
+      return time.Local, reportsTimezoneLabel, nil
+    }
+    return s.cfg.ReportsLocation(), s.cfg.Reports.Timezone, nil
+  }
+  loc, err := time.LoadLocation(name)
+  if err != nil {
+    return nil, "", fmt.Errorf("invalid tz: %s", name)
+  }
+  return loc, name, nil
+}
+
+func reportsRunTimeout() time.Duration {
+  raw := strings.TrimSpace(os.Getenv("REPORTS_RUN_TIMEOUT_SEC"))
+  if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+    return time.Duration(parsed) * time.Second
+  }
+  return 2 * time.Minute
+}
+
 func reportsLiveTimeout() time.Duration {
   raw := strings.TrimSpace(os.Getenv("REPORTS_LIVE_TIMEOUT_SEC"))
   if raw == "" {
@@ -208,6 +378,21 @@ type reportSeriesItem struct {
   OnchainBalanceSat *int64 `json:"onchain_balance_sats"`
   LightningBalanceSat *int64 `json:"lightning_balance_sats"`
   TotalBalanceSat *int64 `json:"total_balance_sats"`
+  // FiatPrice is the day's average BTC price in the ?fiat= currency, from
+  // the stored hourly prices.
+  FiatPrice *float64 `json:"fiat_price,omitempty"`
+}
+
+type reportRebalancePair struct {
+  OutChannelID string `json:"out_channel_id"`
+  OutChannelPoint string `json:"out_channel_point,omitempty"`
+  OutPeerAlias string `json:"out_peer_alias,omitempty"`
+  InChannelID string `json:"in_channel_id"`
+  InChannelPoint string `json:"in_channel_point,omitempty"`
+  InPeerAlias string `json:"in_peer_alias,omitempty"`
+  FeeSat float64 `json:"fee_sats"`
+  AmountSat float64 `json:"amount_sats"`
+  Count int64 `json:"rebalance_count"`
 }
 
 type reportSummaryResponse struct {
@@ -216,6 +401,18 @@ type reportSummaryResponse struct {
   Days int64 `json:"days"`
   Totals reportMetricsPayload `json:"totals"`
   Averages reportMetricsPayload `json:"averages"`
+  Fiat *reportFiatTotals `json:"fiat,omitempty"`
+}
+
+// reportFiatTotals converts the summary totals at the current price.
+type reportFiatTotals struct {
+  Currency string `json:"currency"`
+  Price float64 `json:"price"`
+  Source string `json:"source"`
+  Stale bool `json:"stale,omitempty"`
+  ForwardFeeRevenue float64 `json:"forward_fee_revenue"`
+  RebalanceFeeCost float64 `json:"rebalance_fee_cost"`
+  NetRoutingProfit float64 `json:"net_routing_profit"`
 }
 
 type reportMetricsPayload struct {
@@ -275,3 +472,169 @@ func metricSats(msat int64, sat int64) float64 {
   }
   return float64(sat)
 }
+
+// reportFiatCurrency reads ?fiat=, the currency to value a report in; empty
+// when it is not asked for.
+func reportFiatCurrency(r *http.Request) (string, error) {
+  raw := strings.TrimSpace(r.URL.Query().Get("fiat"))
+  if raw == "" {
+    return "", nil
+  }
+  return parsePriceCurrency(raw, "")
+}
+
+func fiatTotals(quote priceQuote, totals reportMetricsPayload) *reportFiatTotals {
+  value := func(sat float64) float64 {
+    return math.Round(sat/1e8*quote.Price*100) / 100
+  }
+  return &reportFiatTotals{
+    Currency: quote.Currency,
+    Price: quote.Price,
+    Source: quote.Source,
+    Stale: quote.Stale,
+    ForwardFeeRevenue: value(totals.ForwardFeeRevenueSat),
+    RebalanceFeeCost: value(totals.RebalanceFeeCostSat),
+    NetRoutingProfit: value(totals.NetRoutingProfitSat),
+  }
+}
+
+// attachFiatPrices sets each day's average price. It is best effort: days
+// without stored prices, or a failed lookup, leave fiat_price out.
+func (s *Server) attachFiatPrices(ctx context.Context, currency string, loc *time.Location, series []reportSeriesItem) {
+  if currency == "" || s.db == nil || len(series) == 0 {
+    return
+  }
+  first, err := time.ParseInLocation("2006-01-02", series[0].Date, loc)
+  if err != nil {
+    return
+  }
+  last, err := time.ParseInLocation("2006-01-02", series[len(series)-1].Date, loc)
+  if err != nil {
+    return
+  }
+  points, err := s.priceHistory(ctx, currency, first, last.AddDate(0, 0, 1))
+  if err != nil {
+    s.logger.Printf("reports: price history failed: %v", err)
+    return
+  }
+  averages := dailyPriceAverages(points, loc)
+  for i := range series {
+    if price, ok := averages[series[i].Date]; ok {
+      series[i].FiatPrice = &price
+    }
+  }
+}
+
+type reportsBackfillParams struct {
+  From string `json:"from"`
+  To string `json:"to"`
+  TZ string `json:"tz,omitempty"`
+  Workers int `json:"workers,omitempty"`
+  Restart bool `json:"restart,omitempty"`
+}
+
+type reportsBackfillProgress struct {
+  LastDay string `json:"last_day"`
+}
+
+// handleReportsBackfill computes stored daily reports for a past range as a
+// job; like the reports-backfill command it resumes where an earlier run over
+// the same range stopped.
+func (s *Server) handleReportsBackfill(w http.ResponseWriter, r *http.Request) {
+  svc, errMsg := s.reportsService()
+  if svc == nil {
+    msg := strings.TrimSpace(errMsg)
+    if msg == "" {
+      msg = "reports unavailable"
+    }
+    writeError(w, http.StatusServiceUnavailable, msg)
+    return
+  }
+  var req reportsBackfillParams
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  loc, _, err := s.reportsLocationNamed(req.TZ)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  req.From = strings.TrimSpace(req.From)
+  req.To = strings.TrimSpace(req.To)
+  if req.From == "" || req.To == "" {
+    writeError(w, http.StatusBadRequest, "from and to are required")
+    return
+  }
+  startDate, err := reports.ParseDate(req.From, loc)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, "from must be YYYY-MM-DD")
+    return
+  }
+  endDate, err := reports.ParseDate(req.To, loc)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, "to must be YYYY-MM-DD")
+    return
+  }
+  if err := reports.ValidateCustomRange(startDate, endDate); err != nil {
+    if strings.Contains(err.Error(), "large") {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("range too large (max %d days)", reports.CustomRangeDaysLimit()))
+    } else {
+      writeError(w, http.StatusBadRequest, "invalid range")
+    }
+    return
+  }
+
+  job, err := s.jobs.submit(config.DefaultNodeID, jobKindReportsBackfill, reports.BackfillRangeKey(startDate, endDate), req)
+  if err != nil {
+    writeJobSubmitError(w, err)
+    return
+  }
+  writeJobStarted(w, job)
+}
+
+func (s *Server) runReportsBackfillJob(ctx context.Context, job *jobHandle) (any, error) {
+  var req reportsBackfillParams
+  if err := job.Params(&req); err != nil {
+    return nil, err
+  }
+  svc, errMsg := s.reportsService()
+  if svc == nil {
+    return nil, errors.New(errMsg)
+  }
+  loc, _, err := s.reportsLocationNamed(req.TZ)
+  if err != nil {
+    return nil, err
+  }
+  startDate, err := reports.ParseDate(req.From, loc)
+  if err != nil {
+    return nil, err
+  }
+  endDate, err := reports.ParseDate(req.To, loc)
+  if err != nil {
+    return nil, err
+  }
+  if err := s.lnd.WaitReady(ctx); err != nil {
+    return nil, err
+  }
+  opts := reports.BackfillOptions{
+    Start: startDate,
+    End: endDate,
+    Loc: loc,
+    Workers: req.Workers,
+    Restart: req.Restart,
+    DayTimeout: reportsRunTimeout(),
+  }
+  if opts.Workers <= 0 {
+    opts.Workers = 2
+  }
+  stored := 0
+  err = svc.Backfill(ctx, opts, func(p reports.BackfillProgress) {
+    stored = p.Done
+    job.Progress(p.Done, p.Total, reportsBackfillProgress{LastDay: p.Row.ReportDate.Format("2006-01-02")})
+  })
+  if err != nil {
+    return nil, err
+  }
+  return map[string]any{"range": reports.BackfillRangeKey(startDate, endDate), "days_stored": stored}, nil
+}
@@ -5,6 +5,7 @@ import (
   "fmt"
   "time"
 
+  "lightningos-light/internal/config"
   "lightningos-light/internal/reports"
 
   "github.com/jackc/pgx/v5/pgxpool"
@@ -21,7 +22,7 @@ func (s *Server) initReports() {
 
     pool := s.db
     if pool == nil {
-      ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+      ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
       defer cancel()
       pool, err = pgxpool.New(ctx, dsn)
       if err != nil {
@@ -33,7 +34,7 @@ func (s *Server) initReports() {
     }
 
     svc := reports.NewService(pool, s.lnd, s.logger)
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
     defer cancel()
     if err := svc.EnsureSchema(ctx); err != nil {
       s.reportsErr = fmt.Sprintf("reports unavailable: failed to init schema: %v", err)
@@ -43,6 +44,14 @@ func (s *Server) initReports() {
 
     s.reports = svc
     s.reportsErr = ""
+    for _, id := range s.nodeIDs {
+      if id == config.DefaultNodeID {
+        continue
+      }
+      node := s.nodes[id]
+      node.reports = reports.NewService(pool, node.lnd, s.logger)
+      node.reports.SetNodeID(id)
+    }
   })
 }
 
@@ -2,29 +2,67 @@ package server
 
 import (
   "net/http"
-  "path/filepath"
-  "strings"
 
   "github.com/go-chi/chi/v5"
-  "github.com/go-chi/chi/v5/middleware"
 )
 
 func (s *Server) routes() http.Handler {
   r := chi.NewRouter()
-  r.Use(middleware.Recoverer)
-  r.Use(s.requestLogger())
+  r.Use(s.middlewareStack()...)
 
   r.Get("/api/health", s.handleHealth)
+  r.Get("/api/public/status", s.handlePublicStatus)
   r.Get("/api/amboss/health", s.handleAmbossHealthGet)
   r.Post("/api/amboss/health", s.handleAmbossHealthPost)
   r.Get("/api/system", s.handleSystem)
+  r.Get("/api/system/tasks", s.handleSystemTasks)
+  r.Get("/api/system/sudo-policy", s.handleSudoPolicy)
+  r.Get("/api/system/capabilities", s.handleCapabilities)
+  r.Get("/api/system/ssh", s.handleSSHAccess)
+  r.With(s.requireTOTP).Post("/api/system/ssh/keys", s.handleSSHKeyAdd)
+  r.With(s.requireTOTP).Delete("/api/system/ssh/keys", s.handleSSHKeyDelete)
+  r.With(s.requireTOTP).Post("/api/system/ssh/password-auth", s.handleSSHPasswordAuth)
+  r.Get("/api/firewall", s.handleFirewallGet)
+  r.With(s.requireTOTP).Post("/api/firewall/rules", s.handleFirewallRule)
+  r.With(s.requireTOTP).Post("/api/firewall/profile", s.handleFirewallProfile)
+  r.Get("/api/security/intrusions", s.handleIntrusions)
+  r.With(s.requireTOTP).Post("/api/security/intrusions/blocks", s.handleIntrusionBlock)
+  r.With(s.requireTOTP).Delete("/api/security/intrusions/blocks/{ip}", s.handleIntrusionUnblock)
+  r.Get("/api/network", s.handleNetwork)
   r.Get("/api/disk", s.handleDisk)
   r.Get("/api/postgres", s.handlePostgres)
+  r.Get("/api/postgres/backup", s.handlePostgresBackupStatus)
+  r.With(s.requireTOTP).Post("/api/postgres/backup", s.handlePostgresBackup)
+  r.Get("/api/backups/status", s.handleBackupsStatus)
+  r.With(s.requireTOTP).Post("/api/backups/secrets", s.handleBackupSecrets)
+  r.Get("/api/backups/scb-escrow", s.handleSCBEscrowGet)
+  r.With(s.requireTOTP).Post("/api/backups/scb-escrow", s.handleSCBEscrowPost)
+  r.Post("/api/backups/scb-escrow/push", s.handleSCBEscrowPush)
+  r.Get("/api/config/drift", s.handleConfigDrift)
+  r.Get("/api/config/drift/diff", s.handleConfigDriftDiff)
+  r.Post("/api/config/drift/accept", s.handleConfigDriftAccept)
+  r.Post("/api/config/drift/restore", s.handleConfigDriftRestore)
+  r.Get("/api/import/detect", s.handleNodeImportDetect)
+  r.Get("/api/auth/client-certs", s.handleClientCertsList)
+  r.With(s.requireTOTP).Post("/api/auth/client-certs", s.handleClientCertsCreate)
+  r.With(s.requireTOTP).Post("/api/auth/client-certs/{serial}/revoke", s.handleClientCertsRevoke)
+  r.Get("/api/auth/totp", s.handleTOTPStatus)
+  r.Post("/api/auth/totp/enroll", s.handleTOTPEnroll)
+  r.Post("/api/auth/totp/verify", s.handleTOTPVerify)
+  r.Post("/api/auth/totp/disable", s.handleTOTPDisable)
+  r.Get("/api/preferences", s.handlePreferencesGet)
+  r.Post("/api/preferences", s.handlePreferencesPost)
   r.Get("/api/bitcoin", s.handleBitcoin)
   r.Get("/api/bitcoin/active", s.handleBitcoinActive)
+  r.Get("/api/bitcoin/tip", s.handleBitcoinTip)
+  r.Get("/api/bitcoin/sync", s.handleBitcoinSync)
   r.Get("/api/bitcoin/source", s.handleBitcoinSourceGet)
   r.Post("/api/bitcoin/source", s.handleBitcoinSourcePost)
+  r.Post("/api/bitcoin/rpcauth", s.handleBitcoinRPCAuth)
   r.Get("/api/mempool/fees", s.handleMempoolFees)
+  r.Get("/api/mempool/status", s.handleMempoolStatus)
+  r.Get("/api/price", s.handlePrice)
+  r.Get("/api/price/history", s.handlePriceHistory)
   r.Get("/api/bitcoin-local/status", s.handleBitcoinLocalStatus)
   r.Get("/api/bitcoin-local/config", s.handleBitcoinLocalConfigGet)
   r.Post("/api/bitcoin-local/config", s.handleBitcoinLocalConfigPost)
@@ -35,89 +73,197 @@ func (s *Server) routes() http.Handler {
   r.Get("/api/lnd/config", s.handleLNDConfigGet)
   r.Get("/api/wizard/status", s.handleWizardStatus)
   r.Post("/api/wizard/bitcoin-remote", s.handleWizardBitcoinRemote)
+  r.Post("/api/wizard/identity", s.handleWizardIdentity)
+  r.Post("/api/wizard/network", s.handleWizardNetwork)
+  r.With(s.requireTOTP).Post("/api/wizard/auth", s.handleWizardAuth)
+  r.Post("/api/wizard/backup", s.handleWizardBackup)
+  r.With(s.requireTOTP).Post("/api/wizard/scb-restore", s.handleWizardSCBRestore)
+  r.Post("/api/wizard/validate", s.handleWizardValidate)
+  r.Post("/api/wizard/skip", s.handleWizardSkip)
   r.Post("/api/wizard/lnd/create-wallet", s.handleCreateWallet)
   r.Post("/api/wizard/lnd/init-wallet", s.handleInitWallet)
   r.Post("/api/wizard/lnd/unlock", s.handleUnlockWallet)
   r.Post("/api/actions/restart", s.handleRestart)
-  r.Post("/api/actions/system", s.handleSystemAction)
+  r.With(s.requireTOTP).Post("/api/actions/system", s.handleSystemAction)
   r.Get("/api/logs", s.handleLogs)
+  r.Get("/api/logs/download", s.handleLogDownload)
   r.Post("/api/lnd/config", s.handleLNDConfigPost)
-  r.Post("/api/lnd/config/raw", s.handleLNDConfigRaw)
+  r.With(s.requireTOTP).Post("/api/lnd/config/raw", s.handleLNDConfigRaw)
+  r.Get("/api/lnd/db", s.handleLNDDB)
+  r.Post("/api/lnd/db/compact", s.handleLNDDBCompact)
+  r.Get("/api/lnd/db/check", s.handleLNDDBCheckGet)
+  r.Post("/api/lnd/db/check", s.handleLNDDBCheckPost)
+  r.Get("/api/lnd/rescan", s.handleLNDRescanGet)
+  r.Post("/api/lnd/rescan", s.handleLNDRescanPost)
+  r.Get("/api/lnd/db/migrate-postgres", s.handleLNDPGMigrationGet)
+  r.Post("/api/lnd/db/migrate-postgres", s.handleLNDPGMigrationPost)
   r.Get("/api/apps", s.handleAppsList)
+  r.Get("/api/apps/catalog", s.handleAppsCatalog)
   r.Post("/api/apps/{id}/install", s.handleAppInstall)
   r.Post("/api/apps/{id}/uninstall", s.handleAppUninstall)
   r.Post("/api/apps/{id}/start", s.handleAppStart)
   r.Post("/api/apps/{id}/stop", s.handleAppStop)
-  r.Post("/api/apps/{id}/reset-admin", s.handleAppResetAdmin)
-  r.Get("/api/apps/{id}/admin-password", s.handleAppAdminPassword)
+  r.Post("/api/apps/{id}/restart-policy", s.handleAppRestartPolicy)
+  r.With(s.requireTOTP).Post("/api/apps/{id}/reset-admin", s.handleAppResetAdmin)
+  r.With(s.requireTOTP).Get("/api/apps/{id}/admin-password", s.handleAppAdminPassword)
   r.Get("/api/notifications", s.handleNotificationsList)
   r.Get("/api/notifications/stream", s.handleNotificationsStream)
+  r.Get("/api/notifications/subscribers", s.handleNotificationSubscribers)
+  r.Get("/api/activity/annotations", s.handleActivityAnnotationsList)
+  r.Post("/api/activity/annotations", s.handleActivityAnnotationsSave)
+  r.Get("/api/activity/journal", s.handleJournalList)
+  r.Post("/api/activity/journal", s.handleJournalSave)
+  r.Delete("/api/activity/journal/{id}", s.handleJournalDelete)
+  r.Get("/api/activity/export", s.handleActivityExport)
+  r.Get("/api/jobs", s.handleJobsList)
+  r.Get("/api/jobs/{id}", s.handleJobGet)
+  r.Get("/api/jobs/{id}/stream", s.handleJobStream)
+  r.Delete("/api/jobs/{id}", s.handleJobCancel)
+  r.Post("/api/notifications/replay", s.handleNotificationsReplay)
+  r.Get("/api/notifications/import", s.handleNotificationsImportGet)
+  r.Post("/api/notifications/import", s.handleNotificationsImportPost)
+  r.Get("/api/notifications/rules", s.handleNotificationRulesList)
+  r.Post("/api/notifications/rules", s.handleNotificationRulesCreate)
+  r.Delete("/api/notifications/rules/{id}", s.handleNotificationRulesDelete)
+  r.Get("/api/uptime", s.handleUptimeSummary)
+  r.Get("/api/uptime/report", s.handleUptimeReport)
+  r.Get("/api/incidents", s.handleIncidents)
+  r.Get("/api/incidents/timeline", s.handleIncidentTimeline)
+  r.Get("/api/alerts/metrics", s.handleAlertMetrics)
+  r.Get("/api/alerts/rules", s.handleAlertRulesList)
+  r.Post("/api/alerts/rules", s.handleAlertRulesCreate)
+  r.Delete("/api/alerts/rules/{id}", s.handleAlertRulesDelete)
   r.Get("/api/notifications/backup/telegram", s.handleTelegramBackupGet)
   r.Post("/api/notifications/backup/telegram", s.handleTelegramBackupPost)
   r.Post("/api/notifications/backup/telegram/test", s.handleTelegramBackupTest)
+  r.Get("/api/notifications/push", s.handlePushTopicsList)
+  r.Post("/api/notifications/push", s.handlePushTopicsSave)
+  r.Delete("/api/notifications/push/{id}", s.handlePushTopicsDelete)
+  r.Post("/api/notifications/push/{id}/test", s.handlePushTopicsTest)
   r.Get("/api/reports/range", s.handleReportsRange)
   r.Get("/api/reports/custom", s.handleReportsCustom)
   r.Get("/api/reports/summary", s.handleReportsSummary)
   r.Get("/api/reports/live", s.handleReportsLive)
+  r.Get("/api/reports/rebalance-pairs", s.handleReportsRebalancePairs)
+  r.Post("/api/reports/backfill", s.handleReportsBackfill)
+  r.Get("/api/reports/consolidated", s.handleReportsConsolidated)
+  r.Post("/api/reports/lndg-import", s.handleReportsLNDgImport)
   r.Get("/api/reports/config", s.handleReportsConfigGet)
   r.Post("/api/reports/config", s.handleReportsConfigPost)
   r.Get("/api/terminal/status", s.handleTerminalStatus)
+  r.Get("/api/crashes", s.handleCrashesList)
+  r.Get("/api/metrics", s.handleMetrics)
+  r.Get("/api/mqtt", s.handleMQTTGet)
+  r.With(s.requireTOTP).Post("/api/mqtt", s.handleMQTTPost)
+  r.Post("/api/mqtt/test", s.handleMQTTTest)
+  r.Get("/api/console", s.handleConsoleGet)
+  r.With(s.requireTOTP).Post("/api/console/config", s.handleConsoleConfig)
+  r.Post("/api/console/run", s.handleConsoleRun)
+  r.Get("/api/privacy", s.handlePrivacyGet)
+  r.Post("/api/privacy", s.handlePrivacyPost)
+  r.Get("/api/nodes", s.handleNodesList)
+
+  r.Route("/api/nodes/{nodeID}", func(r chi.Router) {
+    r.Use(s.withNode)
+    s.nodeRoutes(r)
+  })
 
   r.Route("/api/onchain", func(r chi.Router) {
+    r.Use(s.requireLNDReady)
     r.Get("/utxos", s.handleOnchainUtxos)
     r.Get("/transactions", s.handleOnchainTransactions)
   })
 
+  r.Get("/api/peers/metadata", s.handlePeerMetadataList)
+  r.Get("/api/peers/bandwidth", s.handlePeerBandwidth)
+  r.Get("/api/peers/bandwidth/monthly", s.handlePeerBandwidthMonthly)
+  r.Get("/api/peers/latency", s.handlePeerLatency)
+  r.Post("/api/peers/metadata/{pubkey}", s.handlePeerMetadataSave)
+  r.Get("/api/payments/allowlist", s.handlePaymentAllowlistList)
+  r.With(s.requireTOTP).Post("/api/payments/allowlist", s.handlePaymentAllowlistAdd)
+  r.With(s.requireTOTP).Delete("/api/payments/allowlist/{id}", s.handlePaymentAllowlistDelete)
+  r.Get("/api/payments/scheduled", s.handleScheduledPaymentsList)
+  r.With(s.requireTOTP).Post("/api/payments/scheduled", s.handleScheduledPaymentsCreate)
+  r.Get("/api/payments/scheduled/runs", s.handleScheduledPaymentRuns)
+  r.With(s.requireTOTP).Post("/api/payments/scheduled/{id}", s.handleScheduledPaymentsUpdate)
+  r.Delete("/api/payments/scheduled/{id}", s.handleScheduledPaymentsDelete)
+  r.With(s.requireTOTP).Post("/api/payments/scheduled/{id}/run", s.handleScheduledPaymentsRun)
+  r.Get("/api/payments/scheduled/{id}/runs", s.handleScheduledPaymentRuns)
+  r.Get("/api/pos/registers", s.handlePOSRegistersList)
+  r.With(s.requireTOTP).Post("/api/pos/registers", s.handlePOSRegistersCreate)
+  r.With(s.requireTOTP).Post("/api/pos/registers/{id}/rotate", s.handlePOSRegistersRotate)
+  r.Delete("/api/pos/registers/{id}", s.handlePOSRegistersDelete)
+  r.Route("/api/pos/terminal", func(r chi.Router) {
+    r.Use(s.requirePOSToken)
+    r.Post("/invoices", s.handlePOSInvoiceCreate)
+    r.Get("/invoices/{hash}", s.handlePOSInvoiceGet)
+    r.Get("/events", s.handlePOSEvents)
+    r.Get("/totals", s.handlePOSTotals)
+  })
+
   r.Route("/api/wallet", func(r chi.Router) {
     r.Get("/summary", s.handleWalletSummary)
-    r.Post("/address", s.handleWalletAddress)
-    r.Post("/invoice", s.handleWalletInvoice)
-    r.Post("/decode", s.handleWalletDecode)
-    r.Post("/pay", s.handleWalletPay)
-    r.Post("/send", s.handleWalletSend)
+    r.With(s.requireLNDReady).Get("/payments", s.handleWalletPayments)
+    r.Get("/auto-unlock", s.handleAutoUnlockGet)
+    r.With(s.requireTOTP).Post("/auto-unlock", s.handleAutoUnlockPost)
+    r.With(s.requireLNDReady).Get("/descriptors", s.handleWalletDescriptors)
+    r.With(s.requireLNDReady).Post("/proof-of-reserves", s.handleProofOfReserves)
+    r.With(s.requireLNDReady).Post("/address", s.handleWalletAddress)
+    r.With(s.requireLNDReady).Post("/invoice", s.handleWalletInvoice)
+    r.With(s.requireLNDReady).Post("/payment-request", s.handleWalletPaymentRequest)
+    r.With(s.requireLNDReady).Post("/decode", s.handleWalletDecode)
+    r.With(s.requireLNDReady, s.requireTOTP).Post("/pay", s.handleWalletPay)
+    r.With(s.requireLNDReady, s.requireTOTP).Post("/send", s.handleWalletSend)
   })
 
+  r.With(s.requireLNDReady).Get("/api/ln/forwards", s.handleLNForwards)
+  r.With(s.requireLNDReady, s.requireTOTP).Post("/api/ln/rebalance", s.handleLNRebalance)
+  r.Get("/api/ln/rebalance/jobs", s.handleLNRebalanceJobs)
+  r.Get("/api/ln/rebalance/jobs/{id}", s.handleLNRebalanceJob)
+
   r.Route("/api/lnops", func(r chi.Router) {
+    r.Use(s.requireLNDReady)
     r.Get("/channels", s.handleLNChannels)
     r.Get("/peers", s.handleLNPeers)
     r.Post("/peer", s.handleLNConnectPeer)
     r.Post("/peer/disconnect", s.handleLNDisconnectPeer)
     r.Post("/peers/boost", s.handleLNBoostPeers)
+    r.Get("/forward-failures", s.handleForwardFailures)
+    r.Get("/liquidity", s.handleLiquidity)
+    r.Get("/capacity", s.handleLNCapacity)
     r.Get("/channel/fees", s.handleLNChannelFees)
-    r.Post("/channel/open", s.handleLNOpenChannel)
-    r.Post("/channel/close", s.handleLNCloseChannel)
-    r.Post("/channel/fees", s.handleLNUpdateFees)
+    r.With(s.requireTOTP).Post("/channel/open", s.handleLNOpenChannel)
+    r.Post("/channel/open/preview", s.handleLNOpenChannelPreview)
+    r.Get("/channel/templates", s.handleChannelTemplatesList)
+    r.Post("/channel/templates", s.handleChannelTemplatesSave)
+    r.Delete("/channel/templates/{id}", s.handleChannelTemplatesDelete)
+    r.With(s.requireTOTP).Post("/channel/templates/{id}/open", s.handleChannelTemplateOpen)
+    r.Get("/groups", s.handleChannelGroupsList)
+    r.Post("/groups", s.handleChannelGroupsSave)
+    r.Delete("/groups/{id}", s.handleChannelGroupsDelete)
+    r.Get("/groups/stats", s.handleChannelGroupsStats)
+    r.With(s.requireTOTP).Post("/channel/reopen", s.handleChannelReopen)
+    r.Get("/splice", s.handleSpliceCapability)
+    r.With(s.requireTOTP).Post("/channel/splice", s.handleLNSplice)
+    r.With(s.requireTOTP).Post("/channel/close", s.handleLNCloseChannel)
+    r.With(s.requireTOTP).Post("/channel/fees", s.handleLNUpdateFees)
+    r.Post("/channel/fees/preview", s.handleLNChannelFeesPreview)
+    r.Get("/inbound-experiments", s.handleInboundExperimentsGet)
+    r.With(s.requireTOTP).Post("/inbound-experiments/start", s.handleInboundExperimentsStart)
+    r.Post("/inbound-experiments/stop", s.handleInboundExperimentsStop)
   })
 
   r.Route("/api/chat", func(r chi.Router) {
     r.Get("/inbox", s.handleChatInbox)
     r.Get("/messages", s.handleChatMessages)
-    r.Post("/send", s.handleChatSend)
+    r.With(s.requireLNDReady).Post("/send", s.handleChatSend)
   })
 
   r.HandleFunc("/terminal", s.handleTerminalProxy)
   r.HandleFunc("/terminal/ws", s.handleTerminalProxy)
   r.HandleFunc("/terminal/*", s.handleTerminalProxy)
 
-  staticDir := s.cfg.UI.StaticDir
-  r.Get("/*", s.handleSPA(staticDir))
+  r.Get("/*", s.handleSPA())
 
   return r
 }
-
-func (s *Server) handleSPA(staticDir string) http.HandlerFunc {
-  fileServer := http.FileServer(http.Dir(staticDir))
-  indexPath := filepath.Join(staticDir, "index.html")
-
-  return func(w http.ResponseWriter, r *http.Request) {
-    if r.URL.Path != "/" {
-      path := strings.TrimPrefix(r.URL.Path, "/")
-      if _, err := http.Dir(staticDir).Open(path); err == nil {
-        fileServer.ServeHTTP(w, r)
-        return
-      }
-    }
-
-    http.ServeFile(w, r, indexPath)
-  }
-}
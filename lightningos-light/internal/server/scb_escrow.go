@@ -0,0 +1,453 @@
+package server
+
+import (
+  "bytes"
+  "context"
+  "crypto/rand"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "net/url"
+  "strings"
+  "sync"
+  "time"
+
+  "golang.org/x/crypto/chacha20poly1305"
+  "golang.org/x/crypto/scrypt"
+
+  "lightningos-light/lnrpc"
+)
+
+// SCB escrow is an opt-in copy of the channel backup on a remote the user
+// picks (any URL that takes PUT and serves GET, e.g. a WebDAV share). The
+// blob is encrypted with a key derived from a passphrase the user chooses;
+// only the derived key is kept in secrets.env so channel changes can push
+// without asking, and restoring on a new machine needs the passphrase.
+//
+// Blob layout: scbEscrowMagic, 16-byte scrypt salt, 24-byte nonce, then the
+// XChaCha20-Poly1305 sealed JSON of scbEscrowPayload with the header as
+// additional data.
+const (
+  scbEscrowURLKey = "SCB_ESCROW_URL"
+  scbEscrowUserKey = "SCB_ESCROW_USER"
+  scbEscrowPasswordKey = "SCB_ESCROW_PASSWORD"
+  scbEscrowKeyKey = "SCB_ESCROW_KEY"
+  scbEscrowSaltKey = "SCB_ESCROW_SALT"
+
+  scbEscrowMagic = "LOSSCB01"
+  scbEscrowSaltSize = 16
+  scbEscrowMinPassphrase = 12
+  scbEscrowMaxBlob = 16 << 20
+  scbEscrowTimeout = 30 * time.Second
+)
+
+type scbEscrowConfig struct {
+  URL string
+  User string
+  Password string
+  Key []byte
+  Salt []byte
+}
+
+func (cfg scbEscrowConfig) enabled() bool {
+  return cfg.URL != "" && len(cfg.Key) == chacha20poly1305.KeySize && len(cfg.Salt) == scbEscrowSaltSize
+}
+
+type scbEscrowPayload struct {
+  CreatedAt time.Time `json:"created_at"`
+  Pubkey string `json:"pubkey"`
+  MultiChanBackup []byte `json:"multi_chan_backup"`
+}
+
+type scbEscrowState struct {
+  mu sync.Mutex
+  lastPushAt time.Time
+  lastReason string
+  lastError string
+}
+
+var scbEscrow scbEscrowState
+
+// record notes a push; a successful one also counts as an SCB backup.
+func (st *scbEscrowState) record(reason string, err error) {
+  st.mu.Lock()
+  defer st.mu.Unlock()
+  st.lastReason = reason
+  if err != nil {
+    st.lastError = err.Error()
+    return
+  }
+  st.lastError = ""
+  st.lastPushAt = time.Now().UTC()
+  recordBackup("scb", st.lastPushAt)
+}
+
+func readSCBEscrowConfig() scbEscrowConfig {
+  read := func(key string) string {
+    value, _ := readEnvFileValue(secretsPath, key)
+    return strings.TrimSpace(value)
+  }
+  cfg := scbEscrowConfig{URL: read(scbEscrowURLKey), User: read(scbEscrowUserKey), Password: read(scbEscrowPasswordKey)}
+  cfg.Key, _ = hex.DecodeString(read(scbEscrowKeyKey))
+  cfg.Salt, _ = hex.DecodeString(read(scbEscrowSaltKey))
+  return cfg
+}
+
+func storeSCBEscrowConfig(cfg scbEscrowConfig) error {
+  values := []struct{ key, value string }{
+    {scbEscrowURLKey, cfg.URL},
+    {scbEscrowUserKey, cfg.User},
+    {scbEscrowPasswordKey, cfg.Password},
+    {scbEscrowKeyKey, hex.EncodeToString(cfg.Key)},
+    {scbEscrowSaltKey, hex.EncodeToString(cfg.Salt)},
+  }
+  for _, v := range values {
+    if err := writeEnvFileValue(secretsPath, v.key, v.value); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func deriveSCBEscrowKey(passphrase string, salt []byte) ([]byte, error) {
+  return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, chacha20poly1305.KeySize)
+}
+
+func sealSCBEscrow(key, salt []byte, payload scbEscrowPayload) ([]byte, error) {
+  aead, err := chacha20poly1305.NewX(key)
+  if err != nil {
+    return nil, err
+  }
+  plain, err := json.Marshal(payload)
+  if err != nil {
+    return nil, err
+  }
+  nonce := make([]byte, chacha20poly1305.NonceSizeX)
+  if _, err := rand.Read(nonce); err != nil {
+    return nil, err
+  }
+  header := append(append([]byte(scbEscrowMagic), salt...), nonce...)
+  // Seal appends to its dst, which must not overlap the additional data.
+  additional := append([]byte(nil), header...)
+  return aead.Seal(header, nonce, plain, additional), nil
+}
+
+// openSCBEscrow decrypts a blob with the passphrase, deriving the key from
+// the salt the blob carries.
+func openSCBEscrow(blob []byte, passphrase string) (scbEscrowPayload, error) {
+  headerSize := len(scbEscrowMagic) + scbEscrowSaltSize + chacha20poly1305.NonceSizeX
+  if len(blob) <= headerSize || string(blob[:len(scbEscrowMagic)]) != scbEscrowMagic {
+    return scbEscrowPayload{}, errors.New("not a LightningOS SCB escrow blob")
+  }
+  header := blob[:headerSize]
+  salt := header[len(scbEscrowMagic) : len(scbEscrowMagic)+scbEscrowSaltSize]
+  nonce := header[len(scbEscrowMagic)+scbEscrowSaltSize:]
+  key, err := deriveSCBEscrowKey(passphrase, salt)
+  if err != nil {
+    return scbEscrowPayload{}, err
+  }
+  aead, err := chacha20poly1305.NewX(key)
+  if err != nil {
+    return scbEscrowPayload{}, err
+  }
+  plain, err := aead.Open(nil, nonce, blob[headerSize:], header)
+  if err != nil {
+    return scbEscrowPayload{}, errors.New("wrong passphrase or corrupted blob")
+  }
+  var payload scbEscrowPayload
+  if err := json.Unmarshal(plain, &payload); err != nil {
+    return scbEscrowPayload{}, err
+  }
+  if len(payload.MultiChanBackup) == 0 {
+    return scbEscrowPayload{}, errors.New("blob holds no channel backup")
+  }
+  return payload, nil
+}
+
+func validateSCBEscrowURL(raw string) (string, error) {
+  value := strings.TrimSpace(raw)
+  parsed, err := url.Parse(value)
+  if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+    return "", errors.New("url must be an http(s) address")
+  }
+  return value, nil
+}
+
+func scbEscrowRequest(ctx context.Context, method string, cfg scbEscrowConfig, body []byte) (*http.Response, error) {
+  var reader io.Reader
+  if body != nil {
+    reader = bytes.NewReader(body)
+  }
+  req, err := http.NewRequestWithContext(ctx, method, cfg.URL, reader)
+  if err != nil {
+    return nil, err
+  }
+  if body != nil {
+    req.Header.Set("Content-Type", "application/octet-stream")
+  }
+  if cfg.User != "" || cfg.Password != "" {
+    req.SetBasicAuth(cfg.User, cfg.Password)
+  }
+  client := &http.Client{Timeout: scbEscrowTimeout}
+  return client.Do(req)
+}
+
+// pushSCBEscrow exports the channel backup, encrypts it and uploads it over
+// the previous blob.
+func pushSCBEscrow(ctx context.Context, export func(context.Context) ([]byte, error), pubkey string, cfg scbEscrowConfig) error {
+  if !cfg.enabled() {
+    return errors.New("scb escrow not configured")
+  }
+  data, err := export(ctx)
+  if err != nil {
+    return err
+  }
+  now := time.Now().UTC()
+  blob, err := sealSCBEscrow(cfg.Key, cfg.Salt, scbEscrowPayload{CreatedAt: now, Pubkey: pubkey, MultiChanBackup: data})
+  if err != nil {
+    return err
+  }
+  resp, err := scbEscrowRequest(ctx, http.MethodPut, cfg, blob)
+  if err != nil {
+    return err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode < 200 || resp.StatusCode > 299 {
+    return fmt.Errorf("remote answered http %d", resp.StatusCode)
+  }
+  return nil
+}
+
+func pullSCBEscrow(ctx context.Context, cfg scbEscrowConfig) ([]byte, error) {
+  resp, err := scbEscrowRequest(ctx, http.MethodGet, cfg, nil)
+  if err != nil {
+    return nil, err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return nil, fmt.Errorf("remote answered http %d", resp.StatusCode)
+  }
+  return io.ReadAll(io.LimitReader(resp.Body, scbEscrowMaxBlob))
+}
+
+// triggerSCBEscrow pushes in the background after a channel change.
+func (n *Notifier) triggerSCBEscrow(reason string) {
+  cfg := readSCBEscrowConfig()
+  if !cfg.enabled() {
+    return
+  }
+  go func() {
+    ctx, cancel := context.WithTimeout(n.context(), scbEscrowTimeout)
+    defer cancel()
+    err := pushSCBEscrow(ctx, n.lnd.ExportAllChannelBackups, n.lnd.CachedPubkey(), cfg)
+    scbEscrow.record(reason, err)
+    if err != nil {
+      n.logger.Printf("notifications: scb escrow push failed: %v", err)
+    }
+  }()
+}
+
+func (s *Server) handleSCBEscrowGet(w http.ResponseWriter, r *http.Request) {
+  cfg := readSCBEscrowConfig()
+  scbEscrow.mu.Lock()
+  defer scbEscrow.mu.Unlock()
+  resp := map[string]any{
+    "enabled": cfg.enabled(),
+    "url": cfg.URL,
+    "user": cfg.User,
+    "password_set": cfg.Password != "",
+    "last_reason": scbEscrow.lastReason,
+    "last_error": scbEscrow.lastError,
+  }
+  if !scbEscrow.lastPushAt.IsZero() {
+    resp["last_push_at"] = scbEscrow.lastPushAt
+  }
+  writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSCBEscrowPost turns the escrow on (url and passphrase required; a
+// new passphrase rotates the key) or off, and pushes once to prove the
+// remote accepts the blob.
+func (s *Server) handleSCBEscrowPost(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Enabled bool `json:"enabled"`
+    URL string `json:"url"`
+    User string `json:"user"`
+    Password string `json:"password"`
+    Passphrase string `json:"passphrase"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if !req.Enabled {
+    if err := storeSCBEscrowConfig(scbEscrowConfig{}); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to store scb escrow config")
+      return
+    }
+    writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+    return
+  }
+
+  existing := readSCBEscrowConfig()
+  target, err := validateSCBEscrowURL(req.URL)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  cfg := scbEscrowConfig{URL: target, User: strings.TrimSpace(req.User), Password: req.Password, Key: existing.Key, Salt: existing.Salt}
+  if cfg.Password == "" && cfg.User == existing.User {
+    cfg.Password = existing.Password
+  }
+  if req.Passphrase != "" {
+    if len(req.Passphrase) < scbEscrowMinPassphrase {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("passphrase must have at least %d characters", scbEscrowMinPassphrase))
+      return
+    }
+    cfg.Salt = make([]byte, scbEscrowSaltSize)
+    if _, err := rand.Read(cfg.Salt); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to derive key")
+      return
+    }
+    if cfg.Key, err = deriveSCBEscrowKey(req.Passphrase, cfg.Salt); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to derive key")
+      return
+    }
+  }
+  if !cfg.enabled() {
+    writeError(w, http.StatusBadRequest, "passphrase required")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  err = pushSCBEscrow(ctx, s.lnd.ExportAllChannelBackups, s.lnd.CachedPubkey(), cfg)
+  scbEscrow.record("setup", err)
+  if err != nil {
+    writeError(w, http.StatusBadGateway, "scb escrow push failed: "+err.Error())
+    return
+  }
+  if err := storeSCBEscrowConfig(cfg); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store scb escrow config")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) handleSCBEscrowPush(w http.ResponseWriter, r *http.Request) {
+  cfg := readSCBEscrowConfig()
+  if !cfg.enabled() {
+    writeError(w, http.StatusBadRequest, "scb escrow not configured")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  err := pushSCBEscrow(ctx, s.lnd.ExportAllChannelBackups, s.lnd.CachedPubkey(), cfg)
+  scbEscrow.record("manual", err)
+  if err != nil {
+    writeError(w, http.StatusBadGateway, "scb escrow push failed: "+err.Error())
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleWizardSCBRestore pulls the escrowed blob, decrypts it with the
+// passphrase and hands it to LND, which then closes the channels with
+// their peers. The wallet has to be restored from the seed and unlocked
+// first. dry_run only decrypts, to check the passphrase.
+func (s *Server) handleWizardSCBRestore(w http.ResponseWriter, r *http.Request) {
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "channel backup restore is not available to client certificates")
+    return
+  }
+  var req struct {
+    URL string `json:"url"`
+    User string `json:"user"`
+    Password string `json:"password"`
+    Passphrase string `json:"passphrase"`
+    DryRun bool `json:"dry_run"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  target, err := validateSCBEscrowURL(req.URL)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  if req.Passphrase == "" {
+    writeError(w, http.StatusBadRequest, "passphrase required")
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  blob, err := pullSCBEscrow(ctx, scbEscrowConfig{URL: target, User: strings.TrimSpace(req.User), Password: req.Password})
+  if err != nil {
+    writeError(w, http.StatusBadGateway, "failed to fetch scb escrow: "+err.Error())
+    return
+  }
+  payload, err := openSCBEscrow(blob, req.Passphrase)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  resp := map[string]any{"created_at": payload.CreatedAt, "pubkey": payload.Pubkey, "restored": false}
+  if req.DryRun {
+    writeJSON(w, http.StatusOK, resp)
+    return
+  }
+
+  if !s.lnd.Ready() {
+    writeError(w, http.StatusConflict, "restore the wallet from its seed and unlock it first")
+    return
+  }
+  if pubkey := s.lnd.CachedPubkey(); pubkey != "" && payload.Pubkey != "" && pubkey != payload.Pubkey {
+    writeError(w, http.StatusConflict, "the backup belongs to another node")
+    return
+  }
+  // A restore force-closes every channel it names, so it is only for a node
+  // being rebuilt: still in the wizard and without channels of its own.
+  if _, current := wizardStepViews(); current == "complete" {
+    writeError(w, http.StatusConflict, "channel backups can only be restored during setup")
+    return
+  }
+  channels, err := s.lnd.ListChannels(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
+    return
+  }
+  pending, err := s.lnd.ListPendingChannels(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
+    return
+  }
+  if len(channels) > 0 || len(pending) > 0 {
+    writeError(w, http.StatusConflict, "the node already has channels; restoring a backup would force-close them")
+    return
+  }
+  conn, err := s.lnd.DialLightning(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
+    return
+  }
+  defer conn.Close()
+  _, err = lnrpc.NewLightningClient(conn).RestoreChannelBackups(ctx, &lnrpc.RestoreChanBackupRequest{
+    Backup: &lnrpc.RestoreChanBackupRequest_MultiChanBackup{MultiChanBackup: payload.MultiChanBackup},
+  })
+  if err != nil {
+    msg := lndRPCErrorMessage(err)
+    if msg == "" {
+      msg = "channel backup restore failed"
+    }
+    writeError(w, http.StatusInternalServerError, msg)
+    return
+  }
+  s.logger.Printf("wizard: restored channel backup of %s from scb escrow", payload.CreatedAt.Format(time.RFC3339))
+  resp["restored"] = true
+  writeJSON(w, http.StatusOK, resp)
+}
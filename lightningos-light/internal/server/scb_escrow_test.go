@@ -0,0 +1,64 @@
+package server
+
+import (
+  "context"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+  "time"
+)
+
+func TestSCBEscrowRoundTrip(t *testing.T) {
+  var stored []byte
+  remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if user, pass, ok := r.BasicAuth(); !ok || user != "node" || pass != "secret" {
+      w.WriteHeader(http.StatusUnauthorized)
+      return
+    }
+    switch r.Method {
+    case http.MethodPut:
+      stored, _ = io.ReadAll(r.Body)
+      w.WriteHeader(http.StatusCreated)
+    case http.MethodGet:
+      w.Write(stored)
+    }
+  }))
+  defer remote.Close()
+
+  salt := make([]byte, scbEscrowSaltSize)
+  key, err := deriveSCBEscrowKey("correct horse battery", salt)
+  if err != nil {
+    t.Fatal(err)
+  }
+  cfg := scbEscrowConfig{URL: remote.URL + "/scb.enc", User: "node", Password: "secret", Key: key, Salt: salt}
+  export := func(context.Context) ([]byte, error) { return []byte("multi-chan-backup"), nil }
+  ctx := context.Background()
+  if err := pushSCBEscrow(ctx, export, "02abc", cfg); err != nil {
+    t.Fatalf("push: %v", err)
+  }
+
+  blob, err := pullSCBEscrow(ctx, cfg)
+  if err != nil {
+    t.Fatalf("pull: %v", err)
+  }
+  payload, err := openSCBEscrow(blob, "correct horse battery")
+  if err != nil || string(payload.MultiChanBackup) != "multi-chan-backup" || payload.Pubkey != "02abc" {
+    t.Fatalf("payload = %+v, %v", payload, err)
+  }
+  if time.Since(payload.CreatedAt) > time.Minute {
+    t.Fatalf("created_at = %v", payload.CreatedAt)
+  }
+  if _, err := openSCBEscrow(blob, "wrong passphrase!"); err == nil {
+    t.Fatal("a wrong passphrase decrypted the blob")
+  }
+  blob[len(blob)-1] ^= 1
+  if _, err := openSCBEscrow(blob, "correct horse battery"); err == nil {
+    t.Fatal("a tampered blob decrypted")
+  }
+
+  cfg.Password = "nope"
+  if err := pushSCBEscrow(ctx, export, "02abc", cfg); err == nil {
+    t.Fatal("a rejected upload reported success")
+  }
+}
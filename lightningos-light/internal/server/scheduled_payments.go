@@ -0,0 +1,772 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/config"
+
+  "github.com/go-chi/chi/v5"
+  "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5/pgtype"
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/status"
+)
+
+// Scheduled payments pay a lightning address or keysend a node every week or
+// month, e.g. recurring donations or payroll. The loop pays each schedule at
+// most once per period: the next period is claimed before paying, so a crash
+// mid-payment never pays twice, and periods missed while the manager was down
+// are skipped rather than paid in a burst. A payment whose call to LND
+// timed out may still be in flight, so its run ends as unknown and is never
+// retried: a retry asks for a new invoice or preimage and could pay twice.
+const (
+  scheduledPaymentInterval = time.Minute
+  scheduledPaymentStartDelay = 30 * time.Second
+  scheduledPaymentAttempts = 3
+  scheduledPaymentRetry = 30 * time.Minute
+  maxScheduledPayments = 100
+  maxScheduledPaymentName = 60
+  maxScheduledPaymentMessage = 200
+  scheduledRunsDefaultLimit = 50
+  scheduledRunsMaxLimit = 500
+
+  scheduledKindLightningAddress = "lightning_address"
+  scheduledKindKeysend = "keysend"
+  scheduledEveryWeek = "weekly"
+  scheduledEveryMonth = "monthly"
+
+  scheduledRunRunning = "running"
+  scheduledRunSucceeded = "succeeded"
+  scheduledRunFailed = "failed"
+  scheduledRunSkipped = "skipped"
+  scheduledRunInterrupted = "interrupted"
+  scheduledRunUnknown = "unknown"
+)
+
+var errScheduledPaymentUnknown = errors.New("payment outcome unknown, check the payment history")
+
+// scheduledPaymentsMu keeps the loop and "run now" from paying the same
+// schedule at once.
+var scheduledPaymentsMu sync.Mutex
+
+type scheduledPayment struct {
+  ID int64 `json:"id"`
+  Name string `json:"name"`
+  Enabled bool `json:"enabled"`
+  NodeID string `json:"node_id"`
+  Kind string `json:"kind"`
+  Target string `json:"target"`
+  AmountSat int64 `json:"amount_sat"`
+  Every string `json:"every"`
+  Message string `json:"message,omitempty"`
+  // MaxTotalSat stops the schedule once the next payment would take the
+  // total paid above it; 0 means no limit.
+  MaxTotalSat int64 `json:"max_total_sat"`
+  StartAt time.Time `json:"start_at"`
+  NextRunAt time.Time `json:"next_run_at"`
+  Attempts int `json:"attempts"`
+  PaidTotalSat int64 `json:"paid_total_sat"`
+  LastStatus string `json:"last_status,omitempty"`
+  LastError string `json:"last_error,omitempty"`
+  LastRunAt *time.Time `json:"last_run_at,omitempty"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+type scheduledPaymentRun struct {
+  ID int64 `json:"id"`
+  ScheduleID int64 `json:"schedule_id"`
+  Manual bool `json:"manual"`
+  StartedAt time.Time `json:"started_at"`
+  FinishedAt *time.Time `json:"finished_at,omitempty"`
+  AmountSat int64 `json:"amount_sat"`
+  Status string `json:"status"`
+  PaymentHash string `json:"payment_hash,omitempty"`
+  Error string `json:"error,omitempty"`
+}
+
+type scheduledPaymentRequest struct {
+  Name string `json:"name"`
+  NodeID string `json:"node_id"`
+  Kind string `json:"kind"`
+  Target string `json:"target"`
+  AmountSat int64 `json:"amount_sat"`
+  Every string `json:"every"`
+  Message string `json:"message"`
+  MaxTotalSat int64 `json:"max_total_sat"`
+  StartAt *time.Time `json:"start_at"`
+}
+
+func (s *Server) parseScheduledPayment(req scheduledPaymentRequest, now time.Time) (scheduledPayment, error) {
+  sp := scheduledPayment{
+    Name: strings.TrimSpace(req.Name),
+    Enabled: true,
+    NodeID: strings.TrimSpace(req.NodeID),
+    Kind: strings.TrimSpace(req.Kind),
+    Target: strings.TrimSpace(req.Target),
+    AmountSat: req.AmountSat,
+    Every: strings.TrimSpace(req.Every),
+    Message: strings.TrimSpace(req.Message),
+    MaxTotalSat: req.MaxTotalSat,
+    StartAt: now,
+  }
+  if sp.Name == "" {
+    return sp, errors.New("name required")
+  }
+  if len(sp.Name) > maxScheduledPaymentName {
+    return sp, errors.New("name too long")
+  }
+  if sp.NodeID == "" {
+    sp.NodeID = config.DefaultNodeID
+  }
+  if _, ok := s.nodes[sp.NodeID]; !ok {
+    return sp, errors.New("unknown node")
+  }
+  switch sp.Kind {
+  case scheduledKindLightningAddress:
+    sp.Target = strings.TrimPrefix(strings.ToLower(sp.Target), "lightning:")
+    if !isLightningAddress(sp.Target) {
+      return sp, errors.New("invalid lightning address")
+    }
+  case scheduledKindKeysend:
+    sp.Target = strings.ToLower(sp.Target)
+    if !isValidPubkeyHex(sp.Target) {
+      return sp, errors.New("invalid pubkey")
+    }
+  default:
+    return sp, errors.New("kind must be lightning_address or keysend")
+  }
+  if sp.AmountSat <= 0 {
+    return sp, errors.New("amount_sat must be positive")
+  }
+  if sp.Every != scheduledEveryWeek && sp.Every != scheduledEveryMonth {
+    return sp, errors.New("every must be weekly or monthly")
+  }
+  if len(sp.Message) > maxScheduledPaymentMessage {
+    return sp, errors.New("message too long")
+  }
+  if sp.MaxTotalSat < 0 {
+    return sp, errors.New("max_total_sat must be zero or positive")
+  }
+  if sp.MaxTotalSat > 0 && sp.MaxTotalSat < sp.AmountSat {
+    return sp, errors.New("max_total_sat below amount_sat")
+  }
+  if req.StartAt != nil {
+    if req.StartAt.Before(now.Add(-time.Minute)) {
+      return sp, errors.New("start_at in the past")
+    }
+    sp.StartAt = req.StartAt.UTC()
+  }
+  sp.NextRunAt = sp.StartAt
+  return sp, nil
+}
+
+// addMonthsClamped moves t by months, keeping the day of month where the
+// target month has it and using its last day otherwise (Jan 31 -> Feb 28).
+func addMonthsClamped(t time.Time, months int) time.Time {
+  first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).AddDate(0, months, 0)
+  last := first.AddDate(0, 1, -1).Day()
+  day := t.Day()
+  if day > last {
+    day = last
+  }
+  return first.AddDate(0, 0, day-1)
+}
+
+// nextScheduledRun is the first period of the schedule after now.
+func nextScheduledRun(start time.Time, every string, now time.Time) time.Time {
+  if start.After(now) {
+    return start
+  }
+  if every == scheduledEveryWeek {
+    week := 7 * 24 * time.Hour
+    return start.Add((now.Sub(start)/week + 1) * week)
+  }
+  months := (now.Year()-start.Year())*12 + int(now.Month()-start.Month())
+  next := addMonthsClamped(start, months)
+  for !next.After(now) {
+    months++
+    next = addMonthsClamped(start, months)
+  }
+  return next
+}
+
+func (s *Server) ensureScheduledPaymentsSchema(ctx context.Context) error {
+  _, err := s.db.Exec(ctx, `
+create table if not exists scheduled_payments (
+  id bigserial primary key,
+  name text not null,
+  enabled boolean not null default true,
+  node_id text not null,
+  kind text not null,
+  target text not null,
+  amount_sat bigint not null,
+  every text not null,
+  message text not null default '',
+  max_total_sat bigint not null default 0,
+  start_at timestamptz not null,
+  next_run_at timestamptz not null,
+  attempts integer not null default 0,
+  paid_total_sat bigint not null default 0,
+  last_status text not null default '',
+  last_error text not null default '',
+  last_run_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create table if not exists scheduled_payment_runs (
+  id bigserial primary key,
+  schedule_id bigint not null,
+  manual boolean not null default false,
+  started_at timestamptz not null,
+  finished_at timestamptz,
+  amount_sat bigint not null,
+  status text not null,
+  payment_hash text not null default '',
+  error text not null default ''
+);
+create index if not exists scheduled_payment_runs_schedule_idx on scheduled_payment_runs (schedule_id, started_at desc);
+`)
+  return err
+}
+
+const scheduledPaymentColumns = `id, name, enabled, node_id, kind, target, amount_sat, every, message, max_total_sat,
+  start_at, next_run_at, attempts, paid_total_sat, last_status, last_error, last_run_at, created_at`
+
+func scanScheduledPayments(rows pgx.Rows) ([]scheduledPayment, error) {
+  defer rows.Close()
+  items := []scheduledPayment{}
+  for rows.Next() {
+    var sp scheduledPayment
+    var lastRun pgtype.Timestamptz
+    if err := rows.Scan(&sp.ID, &sp.Name, &sp.Enabled, &sp.NodeID, &sp.Kind, &sp.Target, &sp.AmountSat, &sp.Every,
+      &sp.Message, &sp.MaxTotalSat, &sp.StartAt, &sp.NextRunAt, &sp.Attempts, &sp.PaidTotalSat, &sp.LastStatus,
+      &sp.LastError, &lastRun, &sp.CreatedAt); err != nil {
+      return nil, err
+    }
+    sp.LastRunAt = optionalTime(lastRun)
+    items = append(items, sp)
+  }
+  return items, rows.Err()
+}
+
+func (s *Server) loadScheduledPayment(ctx context.Context, id int64) (scheduledPayment, bool, error) {
+  rows, err := s.db.Query(ctx, `select `+scheduledPaymentColumns+` from scheduled_payments where id=$1`, id)
+  if err != nil {
+    return scheduledPayment{}, false, err
+  }
+  items, err := scanScheduledPayments(rows)
+  if err != nil || len(items) == 0 {
+    return scheduledPayment{}, false, err
+  }
+  return items[0], true, nil
+}
+
+func (s *Server) initScheduledPayments() {
+  if s.db == nil {
+    return
+  }
+  ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
+  defer cancel()
+  if err := s.ensureScheduledPaymentsSchema(ctx); err != nil {
+    s.logger.Printf("scheduled payments: failed to init schema: %v", err)
+    return
+  }
+  // A run still marked running did not finish before the last shutdown. Its
+  // period was already claimed, so it is not retried; the user checks the
+  // payment history instead.
+  tag, err := s.db.Exec(ctx, `
+update scheduled_payment_runs set status=$1, finished_at=now(), error='manager stopped during the payment, check the payment history'
+where status=$2`, scheduledRunInterrupted, scheduledRunRunning)
+  if err == nil && tag.RowsAffected() > 0 {
+    s.logger.Printf("scheduled payments: %d runs interrupted by the last shutdown", tag.RowsAffected())
+  }
+  goSafe("scheduled-payments", s.runScheduledPayments)
+}
+
+func (s *Server) runScheduledPayments() {
+  if !s.wait(scheduledPaymentStartDelay) {
+    return
+  }
+  for {
+    s.payDueSchedules()
+    if !s.wait(scheduledPaymentInterval) {
+      return
+    }
+  }
+}
+
+func (s *Server) payDueSchedules() {
+  ctx, cancel := s.taskContext("scheduled-payments", timeoutShort)
+  rows, err := s.db.Query(ctx, `select `+scheduledPaymentColumns+` from scheduled_payments
+where enabled and next_run_at <= now() order by next_run_at`)
+  if err != nil {
+    cancel()
+    s.logger.Printf("scheduled payments: failed to load schedules: %v", err)
+    return
+  }
+  due, err := scanScheduledPayments(rows)
+  cancel()
+  if err != nil {
+    s.logger.Printf("scheduled payments: failed to load schedules: %v", err)
+    return
+  }
+  for _, sp := range due {
+    // A schedule whose node is gone still runs, so payScheduled fails it.
+    if lnd, ok := s.lndForNode(sp.NodeID); ok && lndNotReadyMessage(lnd.Lifecycle()) != "" {
+      continue
+    }
+    s.executeScheduledPayment(sp, false)
+  }
+}
+
+// executeScheduledPayment pays one period of sp, or right away for a manual
+// run, which leaves the schedule's next run where it was. sp was loaded
+// before taking the lock, so it is read again: another run may have paid
+// it since.
+func (s *Server) executeScheduledPayment(sp scheduledPayment, manual bool) scheduledPaymentRun {
+  scheduledPaymentsMu.Lock()
+  defer scheduledPaymentsMu.Unlock()
+
+  now := time.Now().UTC()
+  run := scheduledPaymentRun{ScheduleID: sp.ID, Manual: manual, StartedAt: now, AmountSat: sp.AmountSat, Status: scheduledRunRunning}
+  ctx, cancel := s.taskContext("scheduled-payments", timeoutShort)
+  defer cancel()
+
+  current, found, err := s.loadScheduledPayment(ctx, sp.ID)
+  if err != nil || !found {
+    run.Status, run.Error = scheduledRunSkipped, "scheduled payment not found"
+    return run
+  }
+  if !manual && (!current.Enabled || !current.NextRunAt.Equal(sp.NextRunAt)) {
+    run.Status, run.Error = scheduledRunSkipped, "schedule changed while due"
+    return run
+  }
+  sp = current
+  run.AmountSat = sp.AmountSat
+
+  if !manual {
+    // Claim the period before paying. Losing the race means another pass
+    // already took it.
+    tag, err := s.db.Exec(ctx, `
+update scheduled_payments set next_run_at=$3, attempts=attempts+1
+where id=$1 and enabled and next_run_at=$2`, sp.ID, sp.NextRunAt, nextScheduledRun(sp.StartAt, sp.Every, now))
+    if err != nil || tag.RowsAffected() == 0 {
+      run.Status, run.Error = scheduledRunSkipped, "schedule changed while due"
+      return run
+    }
+    sp.Attempts++
+  }
+
+  if sp.MaxTotalSat > 0 && sp.PaidTotalSat+sp.AmountSat > sp.MaxTotalSat {
+    run.Status = scheduledRunSkipped
+    run.Error = fmt.Sprintf("limit reached: %d of %d sat paid", sp.PaidTotalSat, sp.MaxTotalSat)
+    s.finishScheduledRun(sp, &run, manual)
+    return run
+  }
+
+  if err := s.db.QueryRow(ctx, `
+insert into scheduled_payment_runs (schedule_id, manual, started_at, amount_sat, status)
+values ($1, $2, $3, $4, $5) returning id`, sp.ID, manual, now, sp.AmountSat, scheduledRunRunning).Scan(&run.ID); err != nil {
+    s.logger.Printf("scheduled payments: %d: failed to record run: %v", sp.ID, err)
+  }
+
+  payCtx, payCancel := s.taskContext("scheduled-payments/pay", timeoutLNDWrite)
+  hash, err := s.payScheduled(payCtx, sp)
+  payCancel()
+  run.PaymentHash = hash
+  switch {
+  case errors.Is(err, errScheduledPaymentUnknown):
+    run.Status, run.Error = scheduledRunUnknown, err.Error()
+  case err != nil:
+    run.Status, run.Error = scheduledRunFailed, err.Error()
+  default:
+    run.Status = scheduledRunSucceeded
+  }
+  s.finishScheduledRun(sp, &run, manual)
+  return run
+}
+
+// allowKind is the payment allowlist kind that covers the schedule's target.
+func (sp scheduledPayment) allowKind() string {
+  if sp.Kind == scheduledKindKeysend {
+    return allowKindNode
+  }
+  return allowKindLightningAddress
+}
+
+func (s *Server) payScheduled(ctx context.Context, sp scheduledPayment) (string, error) {
+  if err := checkAutomatedDestination(sp.allowKind(), sp.Target); err != nil {
+    return "", err
+  }
+  lnd, ok := s.lndForNode(sp.NodeID)
+  if !ok {
+    return "", unknownNodeError(sp.NodeID)
+  }
+  if sp.Kind == scheduledKindKeysend {
+    hash, err := lnd.SendKeysendMessage(ctx, sp.Target, sp.AmountSat, sp.Message)
+    if err != nil {
+      return hash, scheduledPayError(ctx, err)
+    }
+    return hash, nil
+  }
+  invoice, err := resolveLightningAddress(ctx, sp.Target, sp.AmountSat, sp.Message)
+  if err != nil {
+    return "", fmt.Errorf("lightning address error: %w", err)
+  }
+  decoded, err := lnd.DecodeInvoice(ctx, invoice)
+  if err != nil {
+    return "", fmt.Errorf("invalid invoice from %s: %w", sp.Target, err)
+  }
+  // The address's server picks the invoice; never pay more than scheduled.
+  if decoded.AmountSat != sp.AmountSat {
+    return decoded.PaymentHash, fmt.Errorf("invoice from %s is for %d sat, expected %d", sp.Target, decoded.AmountSat, sp.AmountSat)
+  }
+  if err := lnd.PayInvoice(ctx, invoice, 0); err != nil {
+    return decoded.PaymentHash, scheduledPayError(ctx, err)
+  }
+  return decoded.PaymentHash, nil
+}
+
+// scheduledPayError wraps a failed payment call. When the call ran out of
+// time or the RPC broke off (Unavailable, or Unknown from a dropped stream)
+// LND may still be sending the payment, so the outcome is unknown. Only a
+// payment error LND answered with is a plain failure that can be retried.
+func scheduledPayError(ctx context.Context, err error) error {
+  msg := lndRPCErrorMessage(err)
+  if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+    return fmt.Errorf("%w: %s", errScheduledPaymentUnknown, msg)
+  }
+  if st, ok := status.FromError(err); ok {
+    switch st.Code() {
+    case codes.DeadlineExceeded, codes.Canceled, codes.Unavailable, codes.Unknown:
+      return fmt.Errorf("%w: %s", errScheduledPaymentUnknown, msg)
+    }
+  }
+  return errors.New(msg)
+}
+
+// finishScheduledRun stores the run and the schedule's outcome. A failed
+// scheduled run comes back after scheduledPaymentRetry until its attempts
+// are used up; then the period is given up and an alert raised. An unknown
+// run is never retried and always raises an alert.
+func (s *Server) finishScheduledRun(sp scheduledPayment, run *scheduledPaymentRun, manual bool) {
+  finished := time.Now().UTC()
+  run.FinishedAt = &finished
+  ctx, cancel := s.taskContext("scheduled-payments", timeoutShort)
+  defer cancel()
+
+  if run.ID > 0 {
+    _, _ = s.db.Exec(ctx, `update scheduled_payment_runs set status=$2, finished_at=$3, payment_hash=$4, error=$5 where id=$1`,
+      run.ID, run.Status, finished, run.PaymentHash, run.Error)
+  } else {
+    _ = s.db.QueryRow(ctx, `
+insert into scheduled_payment_runs (schedule_id, manual, started_at, finished_at, amount_sat, status, payment_hash, error)
+values ($1, $2, $3, $4, $5, $6, $7, $8) returning id`, sp.ID, manual, run.StartedAt, finished, run.AmountSat, run.Status,
+      run.PaymentHash, run.Error).Scan(&run.ID)
+  }
+
+  alert := false
+  switch {
+  case run.Status == scheduledRunSucceeded:
+    _, _ = s.db.Exec(ctx, `
+update scheduled_payments set paid_total_sat=paid_total_sat+$2, attempts=0, last_status=$3, last_error='', last_run_at=$4
+where id=$1`, sp.ID, sp.AmountSat, run.Status, finished)
+  case run.Status == scheduledRunSkipped:
+    // Over the limit: stop until the user raises it.
+    _, _ = s.db.Exec(ctx, `update scheduled_payments set enabled=false, attempts=0, last_status=$2, last_error=$3, last_run_at=$4 where id=$1`,
+      sp.ID, run.Status, run.Error, finished)
+    alert = true
+  case run.Status == scheduledRunUnknown:
+    _, _ = s.db.Exec(ctx, `update scheduled_payments set attempts=0, last_status=$2, last_error=$3, last_run_at=$4 where id=$1`,
+      sp.ID, run.Status, run.Error, finished)
+    alert = true
+  case manual:
+    _, _ = s.db.Exec(ctx, `update scheduled_payments set last_status=$2, last_error=$3, last_run_at=$4 where id=$1`,
+      sp.ID, run.Status, run.Error, finished)
+  case sp.Attempts < scheduledPaymentAttempts:
+    _, _ = s.db.Exec(ctx, `update scheduled_payments set next_run_at=$2, last_status=$3, last_error=$4, last_run_at=$5 where id=$1`,
+      sp.ID, finished.Add(scheduledPaymentRetry), run.Status, run.Error, finished)
+  default:
+    _, _ = s.db.Exec(ctx, `update scheduled_payments set attempts=0, last_status=$2, last_error=$3, last_run_at=$4 where id=$1`,
+      sp.ID, run.Status, run.Error, finished)
+    alert = true
+  }
+  if run.Status != scheduledRunSucceeded {
+    s.logger.Printf("scheduled payments: %d (%s) %s: %s", sp.ID, sp.Name, run.Status, run.Error)
+  }
+  if alert {
+    s.scheduledPaymentAlert(ctx, sp, *run)
+  }
+}
+
+func (s *Server) scheduledPaymentAlert(ctx context.Context, sp scheduledPayment, run scheduledPaymentRun) {
+  notifier, ok := s.notifierForNode(sp.NodeID)
+  if !ok {
+    // The node is gone; the primary node's feed still tells the operator.
+    notifier = s.notifier
+  }
+  if notifier == nil {
+    return
+  }
+  evt := Notification{
+    OccurredAt: time.Now().UTC(),
+    Type: "scheduled_payment",
+    Action: run.Status,
+    Direction: "out",
+    Status: strings.ToUpper(run.Status),
+    AmountSat: sp.AmountSat,
+    PaymentHash: run.PaymentHash,
+    Memo: fmt.Sprintf("%s to %s: %s", sp.Name, sp.Target, run.Error),
+  }
+  if _, err := notifier.upsertNotification(ctx, notifier.scopedKey(fmt.Sprintf("scheduled_payment:%d:%d", sp.ID, run.ID)), evt); err != nil {
+    s.logger.Printf("scheduled payments: %d: failed to raise alert: %v", sp.ID, err)
+  }
+}
+
+func (s *Server) scheduledPaymentsReady(w http.ResponseWriter) bool {
+  if s.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "scheduled payments unavailable: database not configured")
+    return false
+  }
+  return true
+}
+
+func scheduledPaymentID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+  id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+  if err != nil || id <= 0 {
+    writeError(w, http.StatusBadRequest, "invalid schedule id")
+    return 0, false
+  }
+  return id, true
+}
+
+func (s *Server) handleScheduledPaymentsList(w http.ResponseWriter, r *http.Request) {
+  if !s.scheduledPaymentsReady(w) {
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  rows, err := s.db.Query(ctx, `select `+scheduledPaymentColumns+` from scheduled_payments order by id`)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load scheduled payments: %v", err))
+    return
+  }
+  items, err := scanScheduledPayments(rows)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load scheduled payments: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Server) handleScheduledPaymentsCreate(w http.ResponseWriter, r *http.Request) {
+  if !s.scheduledPaymentsReady(w) {
+    return
+  }
+  var req scheduledPaymentRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  sp, err := s.parseScheduledPayment(req, time.Now().UTC())
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  if err := checkAutomatedDestination(sp.allowKind(), sp.Target); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  var count int
+  if err := s.db.QueryRow(ctx, `select count(*) from scheduled_payments`).Scan(&count); err == nil && count >= maxScheduledPayments {
+    writeError(w, http.StatusBadRequest, fmt.Sprintf("too many scheduled payments: max %d", maxScheduledPayments))
+    return
+  }
+  err = s.db.QueryRow(ctx, `
+insert into scheduled_payments (name, enabled, node_id, kind, target, amount_sat, every, message, max_total_sat, start_at, next_run_at)
+values ($1, true, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id, created_at`,
+    sp.Name, sp.NodeID, sp.Kind, sp.Target, sp.AmountSat, sp.Every, sp.Message, sp.MaxTotalSat, sp.StartAt, sp.NextRunAt).Scan(&sp.ID, &sp.CreatedAt)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save scheduled payment: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, sp)
+}
+
+// handleScheduledPaymentsUpdate pauses or resumes a schedule, or changes its
+// limit. Resuming continues from the next period, not the missed ones.
+func (s *Server) handleScheduledPaymentsUpdate(w http.ResponseWriter, r *http.Request) {
+  if !s.scheduledPaymentsReady(w) {
+    return
+  }
+  id, ok := scheduledPaymentID(w, r)
+  if !ok {
+    return
+  }
+  var req struct {
+    Enabled *bool `json:"enabled"`
+    MaxTotalSat *int64 `json:"max_total_sat"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if req.MaxTotalSat != nil && *req.MaxTotalSat < 0 {
+    writeError(w, http.StatusBadRequest, "max_total_sat must be zero or positive")
+    return
+  }
+
+  scheduledPaymentsMu.Lock()
+  defer scheduledPaymentsMu.Unlock()
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  sp, found, err := s.loadScheduledPayment(ctx, id)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load scheduled payments: %v", err))
+    return
+  }
+  if !found {
+    writeError(w, http.StatusNotFound, "scheduled payment not found")
+    return
+  }
+  if req.MaxTotalSat != nil {
+    sp.MaxTotalSat = *req.MaxTotalSat
+  }
+  if req.Enabled != nil {
+    if *req.Enabled && !sp.Enabled {
+      sp.NextRunAt = nextScheduledRun(sp.StartAt, sp.Every, time.Now().UTC())
+      sp.Attempts = 0
+    }
+    sp.Enabled = *req.Enabled
+  }
+  _, err = s.db.Exec(ctx, `update scheduled_payments set enabled=$2, max_total_sat=$3, next_run_at=$4, attempts=$5 where id=$1`,
+    sp.ID, sp.Enabled, sp.MaxTotalSat, sp.NextRunAt, sp.Attempts)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save scheduled payment: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, sp)
+}
+
+// handleScheduledPaymentsDelete removes a schedule; its run history stays.
+func (s *Server) handleScheduledPaymentsDelete(w http.ResponseWriter, r *http.Request) {
+  if !s.scheduledPaymentsReady(w) {
+    return
+  }
+  id, ok := scheduledPaymentID(w, r)
+  if !ok {
+    return
+  }
+  scheduledPaymentsMu.Lock()
+  defer scheduledPaymentsMu.Unlock()
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  tag, err := s.db.Exec(ctx, `delete from scheduled_payments where id=$1`, id)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to delete scheduled payment: %v", err))
+    return
+  }
+  if tag.RowsAffected() == 0 {
+    writeError(w, http.StatusNotFound, "scheduled payment not found")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleScheduledPaymentsRun pays a schedule now, outside its periods.
+func (s *Server) handleScheduledPaymentsRun(w http.ResponseWriter, r *http.Request) {
+  if !s.scheduledPaymentsReady(w) {
+    return
+  }
+  id, ok := scheduledPaymentID(w, r)
+  if !ok {
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  sp, found, err := s.loadScheduledPayment(ctx, id)
+  cancel()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load scheduled payments: %v", err))
+    return
+  }
+  if !found {
+    writeError(w, http.StatusNotFound, "scheduled payment not found")
+    return
+  }
+  if lnd, ok := s.lndForNode(sp.NodeID); ok {
+    if msg := lndNotReadyMessage(lnd.Lifecycle()); msg != "" {
+      w.Header().Set("Retry-After", "5")
+      writeError(w, http.StatusServiceUnavailable, msg)
+      return
+    }
+  }
+  run := s.executeScheduledPayment(sp, true)
+  status := http.StatusOK
+  if run.Status != scheduledRunSucceeded {
+    status = http.StatusBadGateway
+  }
+  writeJSON(w, status, run)
+}
+
+func (s *Server) handleScheduledPaymentRuns(w http.ResponseWriter, r *http.Request) {
+  if !s.scheduledPaymentsReady(w) {
+    return
+  }
+  limit := scheduledRunsDefaultLimit
+  if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > scheduledRunsMaxLimit {
+      writeError(w, http.StatusBadRequest, fmt.Sprintf("limit out of range: 1 to %d", scheduledRunsMaxLimit))
+      return
+    }
+    limit = parsed
+  }
+  var scheduleID int64
+  if chi.URLParam(r, "id") != "" {
+    id, ok := scheduledPaymentID(w, r)
+    if !ok {
+      return
+    }
+    scheduleID = id
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutShort)
+  defer cancel()
+  rows, err := s.db.Query(ctx, `
+select id, schedule_id, manual, started_at, finished_at, amount_sat, status, payment_hash, error
+from scheduled_payment_runs
+where $1 = 0 or schedule_id = $1
+order by started_at desc, id desc
+limit $2`, scheduleID, limit)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load scheduled payment runs: %v", err))
+    return
+  }
+  defer rows.Close()
+  items := []scheduledPaymentRun{}
+  for rows.Next() {
+    var run scheduledPaymentRun
+    var finished pgtype.Timestamptz
+    if err := rows.Scan(&run.ID, &run.ScheduleID, &run.Manual, &run.StartedAt, &finished, &run.AmountSat, &run.Status,
+      &run.PaymentHash, &run.Error); err != nil {
+      writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load scheduled payment runs: %v", err))
+      return
+    }
+    run.FinishedAt = optionalTime(finished)
+    items = append(items, run)
+  }
+  if err := rows.Err(); err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load scheduled payment runs: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "net/http"
+  "strings"
+  "testing"
+  "time"
+
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/status"
+)
+
+func TestNextScheduledRun(t *testing.T) {
+  start := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+  for _, tc := range []struct {
+    every string
+    now time.Time
+    want time.Time
+  }{
+    {scheduledEveryMonth, start.Add(-time.Hour), start},
+    {scheduledEveryMonth, start, time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC)},
+    {scheduledEveryMonth, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)},
+    // Missed months are skipped, and February does not shift later months.
+    {scheduledEveryMonth, time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC), time.Date(2026, 6, 30, 12, 0, 0, 0, time.UTC)},
+    {scheduledEveryMonth, time.Date(2027, 2, 28, 12, 0, 0, 0, time.UTC), time.Date(2027, 3, 31, 12, 0, 0, 0, time.UTC)},
+    {scheduledEveryWeek, start, start.AddDate(0, 0, 7)},
+    {scheduledEveryWeek, start.AddDate(0, 0, 20), start.AddDate(0, 0, 21)},
+  } {
+    if got := nextScheduledRun(start, tc.every, tc.now); !got.Equal(tc.want) {
+      t.Fatalf("%s at %s: got %s, want %s", tc.every, tc.now, got, tc.want)
+    }
+  }
+}
+
+func TestParseScheduledPayment(t *testing.T) {
+  s, _ := newFakeLNDServer(t)
+  now := time.Now().UTC()
+  pubkey := "02" + strings.Repeat("ab", 32)
+
+  sp, err := s.parseScheduledPayment(scheduledPaymentRequest{
+    Name: " Donation ", Kind: scheduledKindLightningAddress, Target: "Lightning:Tips@Example.com",
+    AmountSat: 1000, Every: scheduledEveryMonth,
+  }, now)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if sp.Name != "Donation" || sp.Target != "tips@example.com" || !sp.NextRunAt.Equal(now) {
+    t.Fatalf("unexpected schedule %+v", sp)
+  }
+
+  for _, tc := range []struct {
+    req scheduledPaymentRequest
+    want string
+  }{
+    {scheduledPaymentRequest{Kind: scheduledKindKeysend, Target: pubkey, AmountSat: 1, Every: scheduledEveryWeek}, "name required"},
+    {scheduledPaymentRequest{Name: "a", NodeID: "nope", Kind: scheduledKindKeysend, Target: pubkey, AmountSat: 1, Every: scheduledEveryWeek}, "unknown node"},
+    {scheduledPaymentRequest{Name: "a", Kind: "bolt11", Target: pubkey, AmountSat: 1, Every: scheduledEveryWeek}, "kind must"},
+    {scheduledPaymentRequest{Name: "a", Kind: scheduledKindKeysend, Target: "02ab", AmountSat: 1, Every: scheduledEveryWeek}, "invalid pubkey"},
+    {scheduledPaymentRequest{Name: "a", Kind: scheduledKindKeysend, Target: pubkey, AmountSat: 0, Every: scheduledEveryWeek}, "amount_sat"},
+    {scheduledPaymentRequest{Name: "a", Kind: scheduledKindKeysend, Target: pubkey, AmountSat: 1, Every: "daily"}, "every must"},
+    {scheduledPaymentRequest{Name: "a", Kind: scheduledKindKeysend, Target: pubkey, AmountSat: 10, Every: scheduledEveryWeek, MaxTotalSat: 5}, "below amount_sat"},
+  } {
+    if _, err := s.parseScheduledPayment(tc.req, now); err == nil || !strings.Contains(err.Error(), tc.want) {
+      t.Fatalf("%+v: got %v, want %q", tc.req, err, tc.want)
+    }
+  }
+}
+
+func TestPayScheduledKeysend(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  pubkey := "02" + strings.Repeat("ab", 32)
+  saved := paymentAllowlistPath
+  paymentAllowlistPath = t.TempDir() + "/payment-allowlist.json"
+  t.Cleanup(func() { paymentAllowlistPath = saved })
+  sp := scheduledPayment{NodeID: "default", Kind: scheduledKindKeysend, Target: pubkey, AmountSat: 2100, Message: "payroll"}
+  if _, err := s.payScheduled(context.Background(), sp); err == nil || !strings.Contains(err.Error(), "allowlist") {
+    t.Fatalf("paid a destination outside the allowlist: %v", err)
+  }
+  if err := savePaymentAllowlist([]allowedDestination{{ID: "a", Kind: allowKindNode, Value: pubkey}}); err != nil {
+    t.Fatal(err)
+  }
+  if _, err := s.payScheduled(context.Background(), sp); err != nil {
+    t.Fatal(err)
+  }
+  calls := fake.Calls("SendKeysendMessage")
+  if len(calls) != 1 || calls[0].Args[0] != pubkey || calls[0].Args[1] != int64(2100) || calls[0].Args[2] != "payroll" {
+    t.Fatalf("calls %+v", calls)
+  }
+}
+
+func TestPayScheduledTimeoutIsUnknown(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  pubkey := "02" + strings.Repeat("cd", 32)
+  saved := paymentAllowlistPath
+  paymentAllowlistPath = t.TempDir() + "/payment-allowlist.json"
+  t.Cleanup(func() { paymentAllowlistPath = saved })
+  if err := savePaymentAllowlist([]allowedDestination{{ID: "a", Kind: allowKindNode, Value: pubkey}}); err != nil {
+    t.Fatal(err)
+  }
+  sp := scheduledPayment{NodeID: "default", Kind: scheduledKindKeysend, Target: pubkey, AmountSat: 1000}
+
+  fake.Fail("SendKeysendMessage", status.Error(codes.DeadlineExceeded, "context deadline exceeded"))
+  if _, err := s.payScheduled(context.Background(), sp); !errors.Is(err, errScheduledPaymentUnknown) {
+    t.Fatalf("timeout = %v, want unknown", err)
+  }
+  fake.Fail("SendKeysendMessage", status.Error(codes.Unavailable, "connection reset"))
+  if _, err := s.payScheduled(context.Background(), sp); !errors.Is(err, errScheduledPaymentUnknown) {
+    t.Fatalf("unavailable = %v, want unknown", err)
+  }
+  fake.Fail("SendKeysendMessage", status.Error(codes.Unknown, "transport is closing"))
+  if _, err := s.payScheduled(context.Background(), sp); !errors.Is(err, errScheduledPaymentUnknown) {
+    t.Fatalf("rpc unknown = %v, want unknown", err)
+  }
+  fake.Fail("SendKeysendMessage", errors.New("insufficient local balance"))
+  if _, err := s.payScheduled(context.Background(), sp); err == nil || errors.Is(err, errScheduledPaymentUnknown) {
+    t.Fatalf("failure = %v, want failed", err)
+  }
+}
+
+func TestPayScheduledUnknownNode(t *testing.T) {
+  s, fake := newFakeLNDServer(t)
+  pubkey := "02" + strings.Repeat("cd", 32)
+  saved := paymentAllowlistPath
+  paymentAllowlistPath = t.TempDir() + "/payment-allowlist.json"
+  t.Cleanup(func() { paymentAllowlistPath = saved })
+  if err := savePaymentAllowlist([]allowedDestination{{ID: "a", Kind: allowKindNode, Value: pubkey}}); err != nil {
+    t.Fatal(err)
+  }
+  sp := scheduledPayment{NodeID: "removed", Kind: scheduledKindKeysend, Target: pubkey, AmountSat: 1000}
+  if _, err := s.payScheduled(context.Background(), sp); err == nil || !strings.Contains(err.Error(), "unknown node") {
+    t.Fatalf("err = %v, want unknown node", err)
+  }
+  if calls := fake.Calls("SendKeysendMessage"); len(calls) != 0 {
+    t.Fatalf("paid from the primary node: %v", calls)
+  }
+}
+
+func TestScheduledPaymentsNeedDatabase(t *testing.T) {
+  s, _ := newFakeLNDServer(t)
+  rec := serveAPI(s, http.MethodGet, "/api/payments/scheduled", "")
+  if rec.Code != http.StatusServiceUnavailable {
+    t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+  }
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+  "crypto/tls"
+  "net"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/system"
+)
+
+// sdWatchdog pings the systemd watchdog while the HTTPS listener still
+// completes TLS handshakes, so systemd restarts a manager that stopped
+// serving instead of one that is merely busy.
+type sdWatchdog struct {
+  mu sync.Mutex
+  notify bool
+  ready bool
+  interval time.Duration
+  lastPing time.Time
+  lastCheck time.Time
+  lastError string
+  failures int
+}
+
+type sdWatchdogStatus struct {
+  Notify bool `json:"notify"`
+  Ready bool `json:"ready"`
+  Enabled bool `json:"enabled"`
+  IntervalSec int64 `json:"interval_sec,omitempty"`
+  LastPing *time.Time `json:"last_ping,omitempty"`
+  LastCheck *time.Time `json:"last_check,omitempty"`
+  LastError string `json:"last_error,omitempty"`
+  ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+func (d *sdWatchdog) status() sdWatchdogStatus {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  st := sdWatchdogStatus{
+    Notify: d.notify,
+    Ready: d.ready,
+    Enabled: d.interval > 0,
+    IntervalSec: int64(d.interval / time.Second),
+    LastError: d.lastError,
+    ConsecutiveFailures: d.failures,
+  }
+  if !d.lastPing.IsZero() {
+    lastPing := d.lastPing.UTC()
+    st.LastPing = &lastPing
+  }
+  if !d.lastCheck.IsZero() {
+    lastCheck := d.lastCheck.UTC()
+    st.LastCheck = &lastCheck
+  }
+  return st
+}
+
+// notifyReady tells systemd the listener is up and starts the watchdog
+// pings when the unit sets WatchdogSec.
+func (s *Server) notifyReady(ln net.Listener) {
+  sent, err := system.SdNotify("READY=1\nSTATUS=listening on " + ln.Addr().String())
+  if err != nil {
+    s.logger.Printf("sd_notify: %v", err)
+  }
+  interval := system.WatchdogInterval()
+  s.sdWatchdog.mu.Lock()
+  s.sdWatchdog.notify = sent
+  s.sdWatchdog.ready = sent
+  if sent {
+    s.sdWatchdog.interval = interval
+  }
+  s.sdWatchdog.mu.Unlock()
+  if !sent || interval <= 0 {
+    return
+  }
+  s.logger.Printf("sd_notify: watchdog enabled (every %s)", interval)
+  goSafe("systemd/watchdog", func() { s.runSDWatchdog(ln.Addr().String(), interval) })
+}
+
+// runSDWatchdog pings at half the interval, as systemd recommends. A failed
+// self-check skips the ping; after enough misses systemd restarts us.
+func (s *Server) runSDWatchdog(addr string, interval time.Duration) {
+  ticker := time.NewTicker(interval / 2)
+  defer ticker.Stop()
+  for range ticker.C {
+    err := selfCheckListener(addr, interval/4)
+    now := time.Now()
+    s.sdWatchdog.mu.Lock()
+    s.sdWatchdog.lastCheck = now
+    if err != nil {
+      s.sdWatchdog.failures++
+      s.sdWatchdog.lastError = err.Error()
+      s.sdWatchdog.mu.Unlock()
+      s.logger.Printf("sd_notify: self-check failed, skipping watchdog ping: %v", err)
+      continue
+    }
+    s.sdWatchdog.failures = 0
+    s.sdWatchdog.lastError = ""
+    s.sdWatchdog.mu.Unlock()
+
+    if _, err := system.SdNotify("WATCHDOG=1"); err != nil {
+      s.logger.Printf("sd_notify: watchdog ping failed: %v", err)
+      continue
+    }
+    s.sdWatchdog.mu.Lock()
+    s.sdWatchdog.lastPing = now
+    s.sdWatchdog.mu.Unlock()
+  }
+}
+
+// selfCheckListener completes a TLS handshake with our own listener. A
+// wildcard bind is checked over loopback.
+func selfCheckListener(addr string, timeout time.Duration) error {
+  host, port, err := net.SplitHostPort(addr)
+  if err != nil {
+    return err
+  }
+  if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+    host = "127.0.0.1"
+    if ip != nil && ip.To4() == nil {
+      host = "::1"
+    }
+  }
+  dialer := &net.Dialer{Timeout: timeout}
+  conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), &tls.Config{
+    InsecureSkipVerify: true,
+  })
+  if err != nil {
+    return err
+  }
+  return conn.Close()
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+  "net"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/system"
+)
+
+func TestSdNotifyWritesToSocket(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "notify.sock")
+  conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+  if err != nil {
+    t.Skipf("unixgram unavailable: %v", err)
+  }
+  defer conn.Close()
+
+  t.Setenv("NOTIFY_SOCKET", "")
+  if sent, err := system.SdNotify("READY=1"); sent || err != nil {
+    t.Fatalf("expected no-op without NOTIFY_SOCKET, got %t, %v", sent, err)
+  }
+
+  t.Setenv("NOTIFY_SOCKET", path)
+  sent, err := system.SdNotify("WATCHDOG=1")
+  if !sent || err != nil {
+    t.Fatalf("SdNotify: %t, %v", sent, err)
+  }
+  buf := make([]byte, 64)
+  _ = conn.SetReadDeadline(time.Now().Add(time.Second))
+  n, err := conn.Read(buf)
+  if err != nil || string(buf[:n]) != "WATCHDOG=1" {
+    t.Fatalf("read %q, %v", buf[:n], err)
+  }
+}
+
+func TestWatchdogInterval(t *testing.T) {
+  t.Setenv("WATCHDOG_PID", "")
+  t.Setenv("WATCHDOG_USEC", "60000000")
+  if got := system.WatchdogInterval(); got != time.Minute {
+    t.Fatalf("interval = %s", got)
+  }
+  t.Setenv("WATCHDOG_PID", "1")
+  if got := system.WatchdogInterval(); got != 0 {
+    t.Fatalf("watchdog for another pid must be ignored, got %s", got)
+  }
+}
+
+func TestSelfCheckListener(t *testing.T) {
+  srv := httptest.NewTLSServer(http.NotFoundHandler())
+  addr := srv.Listener.Addr().String()
+  if err := selfCheckListener(addr, time.Second); err != nil {
+    t.Fatalf("self-check: %v", err)
+  }
+  srv.Close()
+  if err := selfCheckListener(addr, time.Second); err == nil {
+    t.Fatal("expected self-check to fail on a closed listener")
+  }
+}
@@ -3,23 +3,33 @@ package server
 import (
   "context"
   "crypto/tls"
+  "errors"
   "fmt"
   "log"
+  "net"
   "net/http"
+  "os/signal"
   "sync"
+  "sync/atomic"
+  "syscall"
   "time"
 
   "lightningos-light/internal/config"
   "lightningos-light/internal/lndclient"
   "lightningos-light/internal/reports"
+  "lightningos-light/internal/system"
 
   "github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Server struct {
+  // root is cancelled on shutdown; background work derives from it.
+  root context.Context
+  stop context.CancelFunc
+  tasks *taskRegistry
   cfg    *config.Config
   logger *log.Logger
-  lnd    *lndclient.Client
+  lnd    lndclient.API
   db     *pgxpool.Pool
   notifier *Notifier
   notifierErr string
@@ -28,47 +38,142 @@ type Server struct {
   reports *reports.Service
   reportsErr string
   reportsOnce sync.Once
-  lndRestartMu sync.RWMutex
-  lastLNDRestart time.Time
   walletActivityMu sync.Mutex
+  nodes map[string]*lightningNode
+  nodeIDs []string
+  pgBackup postgresBackupState
+  lndDBCompact lndDBCompaction
+  lndPGMigrate lndPGMigration
+  jobs *jobManager
+  sdWatchdog sdWatchdog
+  metrics *httpMetrics
+  mqtt *mqttBridge
+  liquidity *liquidityTracker
+  chainTip *chainTipTracker
+  bitcoinSync *bitcoinSyncTracker
+  appHealth *appHealthTracker
+  appsCatalog *appsCatalogCache
+  intrusions *intrusionMonitor
+  mempool *mempoolClient
+  prices *priceService
+  timeoutsCfg atomic.Pointer[config.TimeoutsConfig]
 }
 
 func New(cfg *config.Config, logger *log.Logger) *Server {
+  return NewWithLND(cfg, logger, lndclient.New(cfg, logger))
+}
+
+// NewWithLND builds a server around an existing LND client.
+func NewWithLND(cfg *config.Config, logger *log.Logger, lnd lndclient.API) *Server {
+  setActiveNetwork(cfg.Network)
+  setContainerRuntime(cfg.Apps.ContainerRuntime)
+  system.SetPrivilegeMode(cfg.Server.PrivilegeMode)
+  root, stop := context.WithCancel(context.Background())
   srv := &Server{
+    root: root,
+    stop: stop,
+    tasks: newTaskRegistry(),
     cfg:    cfg,
     logger: logger,
-    lnd:    lndclient.New(cfg, logger),
+    lnd:    lnd,
+    metrics: newHTTPMetrics(),
+    mqtt: newMQTTBridge(),
+    liquidity: newLiquidityTracker(),
+    chainTip: newChainTipTracker(),
+    bitcoinSync: newBitcoinSyncTracker(bitcoinSyncPath),
+    appHealth: newAppHealthTracker(appHealthStatePath),
+    appsCatalog: newAppsCatalogCache(appsCatalogCachePath),
+    intrusions: newIntrusionMonitor(intrusionBlocksPath),
+  }
+  srv.setTimeouts(cfg.Timeouts)
+  srv.mempool = newMempoolClient(cfg.Mempool, activeNetwork())
+  srv.mempool.taskContext = srv.taskContext
+  srv.prices = newPriceService(srv.defaultPriceProviders())
+  if err := configureBitcoinRPC(cfg.BitcoinRemote); err != nil {
+    logger.Printf("bitcoin remote rpc: %v (using a direct connection)", err)
   }
+  if err := crashLog.configure(logger, cfg.CrashReporting.SentryDSN, cfg.CrashReporting.Environment); err != nil {
+    logger.Printf("crash reporting disabled: %v", err)
+  }
+  srv.jobs = newJobManager(root, logger)
+  srv.registerJobKinds()
+  srv.initNodes()
   srv.chat = NewChatService(srv.lnd, logger)
   srv.amboss = NewAmbossHealthChecker(srv.lnd, logger)
+  srv.amboss.taskContext = srv.taskContext
   return srv
 }
 
 func (s *Server) Run() error {
+  s.startLNDStateWatchers()
   s.initNotifications()
   s.initReports()
+  crashLog.attach(s.db)
+  s.jobs.attach(s.db)
+  s.initAlerts()
+  s.initScheduledPayments()
+  s.initUptime()
+  s.initPeerSamples()
+  s.initPrices()
+  s.initBackupChecks()
+  s.initLNDDBCheck()
+  s.initLNDRescan()
+  s.initInboundExperiments()
+  s.initLiquidity()
+  s.initChainTipEvents()
+  s.initBitcoinSync()
+  s.initAppHealth()
+  s.initIntrusionMonitor()
   if s.chat != nil {
-    s.chat.Start()
+    s.chat.Start(s.root)
   }
   if s.amboss != nil {
     s.amboss.Start()
   }
+  s.startLNDDBSampler()
+  s.watchTimeouts()
+  goSafe("mqtt/bridge", s.runMQTT)
 
   addr := fmt.Sprintf("%s:%d", s.cfg.Server.Host, s.cfg.Server.Port)
 
-  tlsCfg := &tls.Config{
-    MinVersion: tls.VersionTLS12,
+  serverCert, err := tls.LoadX509KeyPair(s.cfg.Server.TLSCert, s.cfg.Server.TLSKey)
+  if err != nil {
+    return err
   }
+  tlsCfg := withClientCertTLS(&tls.Config{
+    MinVersion: tls.VersionTLS12,
+    Certificates: []tls.Certificate{serverCert},
+  })
 
   httpServer := &http.Server{
     Addr:              addr,
-    Handler:           s.routes(),
+    Handler:           withBasePath(s.basePath(), s.routes()),
     ReadHeaderTimeout: 10 * time.Second,
     TLSConfig:         tlsCfg,
+    // Requests (event streams included) end with the root context.
+    BaseContext: func(net.Listener) context.Context { return s.root },
+  }
+
+  ln, err := net.Listen("tcp", addr)
+  if err != nil {
+    return err
   }
+  signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+  defer stopSignals()
+  stopped := make(chan struct{})
+  go func() {
+    defer close(stopped)
+    <-signals.Done()
+    s.shutdown(httpServer)
+  }()
 
   s.logger.Printf("listening on https://%s", addr)
-  return httpServer.ListenAndServeTLS(s.cfg.Server.TLSCert, s.cfg.Server.TLSKey)
+  s.notifyReady(ln)
+  if err := httpServer.ServeTLS(ln, "", ""); !errors.Is(err, http.ErrServerClosed) {
+    return err
+  }
+  <-stopped
+  return nil
 }
 
 func (s *Server) initNotifications() {
@@ -79,7 +184,7 @@ func (s *Server) initNotifications() {
     return
   }
 
-  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
   defer cancel()
 
   pool, err := pgxpool.New(ctx, dsn)
@@ -92,8 +197,16 @@ func (s *Server) initNotifications() {
   s.db = pool
   s.notifier = NewNotifier(pool, s.lnd, s.logger)
   s.notifierErr = ""
-  s.notifier.Start()
+  s.notifier.Start(s.root)
   if s.chat != nil {
     s.chat.AttachNotifier(s.notifier)
   }
+  for _, id := range s.nodeIDs {
+    if id == config.DefaultNodeID {
+      continue
+    }
+    node := s.nodes[id]
+    node.notifier = NewNodeNotifier(id, pool, node.lnd, s.logger)
+    node.notifier.Start(s.root)
+  }
 }
@@ -0,0 +1,247 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+)
+
+const (
+  splicesPath = "/var/lib/lightningos/splices.json"
+  splicePollInterval = 2 * time.Minute
+)
+
+var splicesMu sync.Mutex
+
+// spliceRecord is a splice started from the manager, kept until its
+// transaction confirms so channel details can show it as pending.
+type spliceRecord struct {
+  NodeID string `json:"node_id"`
+  ChannelPoint string `json:"channel_point"`
+  Direction string `json:"direction"`
+  AmountSat int64 `json:"amount_sat"`
+  Txid string `json:"txid"`
+  StartedAt time.Time `json:"started_at"`
+  ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+}
+
+func readSplices() []spliceRecord {
+  items := []spliceRecord{}
+  raw, err := os.ReadFile(splicesPath)
+  if err != nil {
+    return items
+  }
+  _ = json.Unmarshal(raw, &items)
+  return items
+}
+
+func updateSplices(fn func([]spliceRecord) []spliceRecord) error {
+  splicesMu.Lock()
+  defer splicesMu.Unlock()
+  items := fn(readSplices())
+  if err := os.MkdirAll(filepath.Dir(splicesPath), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.MarshalIndent(items, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(splicesPath, raw, 0o640)
+}
+
+func pendingSplicesIn(items []spliceRecord, nodeID string) []spliceRecord {
+  pending := []spliceRecord{}
+  for _, item := range items {
+    if item.NodeID == nodeID && item.ConfirmedAt == nil {
+      pending = append(pending, item)
+    }
+  }
+  return pending
+}
+
+func pendingSplices(nodeID string) []spliceRecord {
+  return pendingSplicesIn(readSplices(), nodeID)
+}
+
+func markSpliceConfirmed(items []spliceRecord, nodeID string, txid string, at time.Time) []spliceRecord {
+  for i := range items {
+    if items[i].NodeID == nodeID && items[i].Txid == txid && items[i].ConfirmedAt == nil {
+      confirmed := at
+      items[i].ConfirmedAt = &confirmed
+    }
+  }
+  return items
+}
+
+func (s *Server) nodeIDFor(r *http.Request) string {
+  if node := s.nodeFor(r); node != nil {
+    return node.ID
+  }
+  return config.DefaultNodeID
+}
+
+func (s *Server) handleSpliceCapability(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutLNDRPC)
+  defer cancel()
+  capability, err := s.lndFor(r).SpliceCapability(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
+    return
+  }
+  capability.Reason = localize(w, capability.Reason)
+  writeJSON(w, http.StatusOK, capability)
+}
+
+func (s *Server) handleLNSplice(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    ChannelPoint string `json:"channel_point"`
+    Direction string `json:"direction"`
+    AmountSat int64 `json:"amount_sat"`
+    Address string `json:"address"`
+    SatPerVbyte int64 `json:"sat_per_vbyte"`
+    AllowHighFee bool `json:"allow_high_fee"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  channelPoint := strings.TrimSpace(req.ChannelPoint)
+  if channelPoint == "" {
+    writeError(w, http.StatusBadRequest, "channel_point required")
+    return
+  }
+  direction := strings.ToLower(strings.TrimSpace(req.Direction))
+  if direction != "in" && direction != "out" {
+    writeError(w, http.StatusBadRequest, "direction must be in or out")
+    return
+  }
+  if req.AmountSat <= 0 {
+    writeError(w, http.StatusBadRequest, "amount_sat must be positive")
+    return
+  }
+  req.Address = strings.TrimSpace(req.Address)
+  if direction == "out" {
+    if err := checkAutomatedCloseAddress(r, req.Address); err != nil {
+      writeError(w, http.StatusForbidden, err.Error())
+      return
+    }
+  }
+  // Splicing in funds the channel like an open; splicing out pays out like a
+  // send.
+  operation := feeGuardOpen
+  if direction == "out" {
+    operation = feeGuardSend
+  }
+  if !s.guardFeeRate(w, r, operation, req.SatPerVbyte, req.AllowHighFee) {
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutLong)
+  defer cancel()
+  lnd := s.lndFor(r)
+
+  capability, err := lnd.SpliceCapability(ctx)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndStatusMessage(err))
+    return
+  }
+  if !capability.Supported {
+    writeError(w, http.StatusNotImplemented, lndclient.ErrSpliceUnsupported.Error())
+    return
+  }
+
+  amount := req.AmountSat
+  if direction == "out" {
+    amount = -amount
+  }
+  result, err := lnd.Splice(ctx, lndclient.SpliceRequest{
+    ChannelPoint: channelPoint,
+    AmountSat: amount,
+    Address: req.Address,
+    SatPerVbyte: req.SatPerVbyte,
+  })
+  if errors.Is(err, lndclient.ErrSpliceUnsupported) {
+    writeError(w, http.StatusNotImplemented, err.Error())
+    return
+  }
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, lndDetailedErrorMessage(err))
+    return
+  }
+
+  record := spliceRecord{
+    NodeID: s.nodeIDFor(r),
+    ChannelPoint: channelPoint,
+    Direction: direction,
+    AmountSat: req.AmountSat,
+    Txid: result.Txid,
+    StartedAt: time.Now().UTC(),
+  }
+  if err := updateSplices(func(items []spliceRecord) []spliceRecord { return append(items, record) }); err != nil {
+    s.logger.Printf("splice: failed to record %s: %v", result.Txid, err)
+  }
+  writeJSON(w, http.StatusOK, record)
+}
+
+// runSplices announces splices of this node once their transaction confirms.
+func (n *Notifier) runSplices() {
+  for {
+    select {
+    case <-n.stop:
+      return
+    case <-time.After(splicePollInterval):
+    }
+    if !n.waitLNDReady() {
+      return
+    }
+
+    pending := pendingSplices(n.nodeID)
+    if len(pending) == 0 {
+      continue
+    }
+    ctx, cancel := context.WithTimeout(n.context(), 15*time.Second)
+    txs, err := n.lnd.ListOnchainTransactions(ctx, 0)
+    cancel()
+    if err != nil {
+      n.logger.Printf("notifications: splice poll failed: %v", err)
+      continue
+    }
+    confirmed := map[string]bool{}
+    for _, tx := range txs {
+      if tx.Confirmations > 0 {
+        confirmed[tx.Txid] = true
+      }
+    }
+    for _, item := range pending {
+      if !confirmed[item.Txid] {
+        continue
+      }
+      now := time.Now().UTC()
+      _ = updateSplices(func(items []spliceRecord) []spliceRecord {
+        return markSpliceConfirmed(items, item.NodeID, item.Txid, now)
+      })
+      evt := Notification{
+        OccurredAt: now,
+        Type: "channel",
+        Action: "splice_confirmed",
+        Direction: item.Direction,
+        Status: "CONFIRMED",
+        AmountSat: item.AmountSat,
+        ChannelPoint: item.ChannelPoint,
+        Txid: item.Txid,
+      }
+      ctx, cancel := context.WithTimeout(n.context(), 5*time.Second)
+      _, _ = n.upsertNotification(ctx, "splice:"+item.Txid, evt)
+      cancel()
+    }
+  }
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+  "testing"
+  "time"
+)
+
+func TestSpliceRegistry(t *testing.T) {
+  items := []spliceRecord{
+    {NodeID: "main", ChannelPoint: "aa:0", Direction: "in", AmountSat: 100_000, Txid: "t1"},
+    {NodeID: "main", ChannelPoint: "bb:1", Direction: "out", AmountSat: 50_000, Txid: "t2"},
+    {NodeID: "other", ChannelPoint: "cc:0", Direction: "in", AmountSat: 10_000, Txid: "t3"},
+  }
+  if pending := pendingSplicesIn(items, "main"); len(pending) != 2 {
+    t.Fatalf("expected two pending splices, got %+v", pending)
+  }
+
+  at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+  items = markSpliceConfirmed(items, "main", "t1", at)
+  pending := pendingSplicesIn(items, "main")
+  if len(pending) != 1 || pending[0].Txid != "t2" {
+    t.Fatalf("unexpected pending after confirmation: %+v", pending)
+  }
+  if items[0].ConfirmedAt == nil || !items[0].ConfirmedAt.Equal(at) {
+    t.Fatalf("confirmation time not recorded: %+v", items[0])
+  }
+
+  // A txid from another node must not be marked.
+  items = markSpliceConfirmed(items, "main", "t3", at)
+  if items[2].ConfirmedAt != nil {
+    t.Fatalf("splice of another node was marked confirmed")
+  }
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+  "context"
+  "encoding/base64"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "strings"
+
+  "lightningos-light/internal/system"
+
+  "golang.org/x/crypto/ssh"
+)
+
+// SSH access is managed for the operator user (TERMINAL_OPERATOR_USER, the
+// account the installer creates for logging in to the node). The root side
+// lives in sshAccessScript, run through sudo: it reads and edits the user's
+// authorized_keys and sets PasswordAuthentication in an sshd drop-in. Keys
+// are parsed and checked here. Neither change may leave the node without a
+// way in: password logins cannot be turned off without a key, and the last
+// key cannot be removed while they are off.
+const (
+  sshAccessScript = "/usr/local/sbin/lightningos-ssh-access"
+  sshDefaultOperator = "losop"
+)
+
+var (
+  errSSHNoKeys = errors.New("add an ssh key before turning password logins off")
+  errSSHLastKey = errors.New("cannot remove the last ssh key while password logins are off")
+)
+
+type sshKey struct {
+  Type string `json:"type"`
+  Fingerprint string `json:"fingerprint"`
+  Comment string `json:"comment,omitempty"`
+  Options []string `json:"options,omitempty"`
+  // blob is the base64 key as it appears in authorized_keys.
+  blob string
+}
+
+type sshAccessStatus struct {
+  User string `json:"user"`
+  Keys []sshKey `json:"keys"`
+  PasswordAuth bool `json:"password_auth"`
+}
+
+func sshOperatorUser() string {
+  if user := strings.TrimSpace(os.Getenv("TERMINAL_OPERATOR_USER")); user != "" {
+    return user
+  }
+  return sshDefaultOperator
+}
+
+func sshKeyFrom(pub ssh.PublicKey, comment string, options []string) sshKey {
+  return sshKey{
+    Type: pub.Type(),
+    Fingerprint: ssh.FingerprintSHA256(pub),
+    Comment: comment,
+    Options: options,
+    blob: base64.StdEncoding.EncodeToString(pub.Marshal()),
+  }
+}
+
+// parseAuthorizedKeys reads the keys of an authorized_keys file, skipping
+// comments and lines that do not parse.
+func parseAuthorizedKeys(content string) []sshKey {
+  keys := []sshKey{}
+  rest := []byte(content)
+  for len(rest) > 0 {
+    pub, comment, options, next, err := ssh.ParseAuthorizedKey(rest)
+    if err != nil {
+      break
+    }
+    keys = append(keys, sshKeyFrom(pub, comment, options))
+    rest = next
+  }
+  return keys
+}
+
+// parseNewSSHKey checks a public key to add and returns it with the line
+// to write: type, key and comment, nothing else.
+func parseNewSSHKey(raw string) (sshKey, string, error) {
+  raw = strings.TrimSpace(raw)
+  if raw == "" || strings.ContainsAny(raw, "\r\n") {
+    return sshKey{}, "", errors.New("key must be one public key line")
+  }
+  pub, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(raw))
+  if err != nil {
+    return sshKey{}, "", errors.New("invalid ssh public key")
+  }
+  if len(options) > 0 {
+    return sshKey{}, "", errors.New("key options are not supported")
+  }
+  line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+  comment = strings.TrimSpace(comment)
+  if comment != "" {
+    line += " " + comment
+  }
+  return sshKeyFrom(pub, comment, nil), line, nil
+}
+
+func runSSHAccess(ctx context.Context, args ...string) (string, error) {
+  out, err := system.RunCommandWithSudo(ctx, sshAccessScript, args...)
+  if err != nil {
+    if isSudoDenied(err) {
+      return out, err
+    }
+    return out, fmt.Errorf("%s failed: %s", args[0], strings.TrimSpace(out))
+  }
+  return out, nil
+}
+
+func sshAccess(ctx context.Context) (sshAccessStatus, error) {
+  status := sshAccessStatus{User: sshOperatorUser()}
+  out, err := runSSHAccess(ctx, "list", status.User)
+  if err != nil {
+    return status, err
+  }
+  status.Keys = parseAuthorizedKeys(out)
+  out, err = runSSHAccess(ctx, "status")
+  if err != nil {
+    return status, err
+  }
+  status.PasswordAuth = parseSSHPasswordAuth(out)
+  return status, nil
+}
+
+// parseSSHPasswordAuth reads sshd -T's passwordauthentication line.
+func parseSSHPasswordAuth(out string) bool {
+  for _, line := range strings.Split(out, "\n") {
+    fields := strings.Fields(strings.ToLower(line))
+    if len(fields) == 2 && fields[0] == "passwordauthentication" {
+      return fields[1] != "no"
+    }
+  }
+  return true
+}
+
+func writeSSHAccessError(w http.ResponseWriter, err error) {
+  writeError(w, http.StatusInternalServerError, "ssh access failed: "+err.Error())
+}
+
+// handleSSHAccess serves GET /api/system/ssh.
+func (s *Server) handleSSHAccess(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  status, err := sshAccess(ctx)
+  if err != nil {
+    writeSSHAccessError(w, err)
+    return
+  }
+  writeJSON(w, http.StatusOK, status)
+}
+
+type sshKeyRequest struct {
+  Key string `json:"key"`
+}
+
+// handleSSHKeyAdd serves POST /api/system/ssh/keys.
+func (s *Server) handleSSHKeyAdd(w http.ResponseWriter, r *http.Request) {
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "client certificates cannot change SSH access")
+    return
+  }
+  var req sshKeyRequest
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  key, line, err := parseNewSSHKey(req.Key)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  status, err := sshAccess(ctx)
+  if err != nil {
+    writeSSHAccessError(w, err)
+    return
+  }
+  for _, existing := range status.Keys {
+    if existing.blob == key.blob {
+      writeError(w, http.StatusConflict, "ssh key already added")
+      return
+    }
+  }
+  if _, err := runSSHAccess(ctx, "add", status.User, line); err != nil {
+    writeSSHAccessError(w, err)
+    return
+  }
+  s.handleSSHAccess(w, r)
+}
+
+// handleSSHKeyDelete serves DELETE /api/system/ssh/keys?fingerprint=. The
+// fingerprint goes in the query since its base64 may hold a slash.
+func (s *Server) handleSSHKeyDelete(w http.ResponseWriter, r *http.Request) {
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "client certificates cannot change SSH access")
+    return
+  }
+  fingerprint := strings.TrimSpace(r.URL.Query().Get("fingerprint"))
+  if fingerprint == "" {
+    writeError(w, http.StatusBadRequest, "fingerprint required")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  status, err := sshAccess(ctx)
+  if err != nil {
+    writeSSHAccessError(w, err)
+    return
+  }
+  var target *sshKey
+  for i := range status.Keys {
+    if status.Keys[i].Fingerprint == fingerprint {
+      target = &status.Keys[i]
+      break
+    }
+  }
+  if target == nil {
+    writeError(w, http.StatusNotFound, "ssh key not found")
+    return
+  }
+  remaining := 0
+  for _, key := range status.Keys {
+    if key.blob != target.blob {
+      remaining++
+    }
+  }
+  if remaining == 0 && !status.PasswordAuth {
+    writeError(w, http.StatusConflict, errSSHLastKey.Error())
+    return
+  }
+  if _, err := runSSHAccess(ctx, "remove", status.User, target.blob); err != nil {
+    writeSSHAccessError(w, err)
+    return
+  }
+  s.handleSSHAccess(w, r)
+}
+
+type sshPasswordAuthRequest struct {
+  Enabled *bool `json:"enabled"`
+}
+
+// handleSSHPasswordAuth serves POST /api/system/ssh/password-auth.
+func (s *Server) handleSSHPasswordAuth(w http.ResponseWriter, r *http.Request) {
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "client certificates cannot change SSH access")
+    return
+  }
+  var req sshPasswordAuthRequest
+  if err := readJSON(r, &req); err != nil || req.Enabled == nil {
+    writeError(w, http.StatusBadRequest, "enabled required")
+    return
+  }
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  status, err := sshAccess(ctx)
+  if err != nil {
+    writeSSHAccessError(w, err)
+    return
+  }
+  if !*req.Enabled && len(status.Keys) == 0 {
+    writeError(w, http.StatusConflict, errSSHNoKeys.Error())
+    return
+  }
+  value := "no"
+  if *req.Enabled {
+    value = "yes"
+  }
+  if _, err := runSSHAccess(ctx, "password-auth", value); err != nil {
+    writeSSHAccessError(w, err)
+    return
+  }
+  out, err := runSSHAccess(ctx, "status")
+  if err != nil {
+    writeSSHAccessError(w, err)
+    return
+  }
+  // Another sshd setting (a Match block, say) may still win.
+  if parseSSHPasswordAuth(out) != *req.Enabled {
+    writeError(w, http.StatusConflict, "sshd still uses the old password setting; check /etc/ssh/sshd_config")
+    return
+  }
+  s.handleSSHAccess(w, r)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+  "crypto/ed25519"
+  "strings"
+  "testing"
+
+  "golang.org/x/crypto/ssh"
+)
+
+func testSSHKey(t *testing.T) (ssh.PublicKey, string) {
+  t.Helper()
+  pub, _, err := ed25519.GenerateKey(nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  key, err := ssh.NewPublicKey(pub)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return key, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestParseAuthorizedKeys(t *testing.T) {
+  first, firstLine := testSSHKey(t)
+  _, secondLine := testSSHKey(t)
+  content := "# laptop\n" + firstLine + " alice@laptop\n\nnot a key\n" + `from="192.168.1.0/24" ` + secondLine + "\n"
+
+  keys := parseAuthorizedKeys(content)
+  if len(keys) != 2 {
+    t.Fatalf("got %d keys: %+v", len(keys), keys)
+  }
+  if keys[0].Type != ssh.KeyAlgoED25519 || keys[0].Comment != "alice@laptop" || keys[0].Fingerprint != ssh.FingerprintSHA256(first) {
+    t.Fatalf("first key = %+v", keys[0])
+  }
+  if keys[0].blob != strings.Fields(firstLine)[1] {
+    t.Fatalf("blob %q does not match the file", keys[0].blob)
+  }
+  if len(keys[1].Options) != 1 || keys[1].Options[0] != `from="192.168.1.0/24"` {
+    t.Fatalf("second key options = %v", keys[1].Options)
+  }
+}
+
+func TestParseNewSSHKey(t *testing.T) {
+  _, line := testSSHKey(t)
+  key, normalized, err := parseNewSSHKey("  " + line + "   bob@desk  ")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if normalized != line+" bob@desk" || key.Comment != "bob@desk" {
+    t.Fatalf("normalized = %q, key = %+v", normalized, key)
+  }
+  for _, bad := range []string{"", "ssh-ed25519 AAAA", line + "\n" + line, `command="sh" ` + line} {
+    if _, _, err := parseNewSSHKey(bad); err == nil {
+      t.Errorf("accepted %q", bad)
+    }
+  }
+}
+
+func TestParseSSHPasswordAuth(t *testing.T) {
+  if parseSSHPasswordAuth("passwordauthentication no\n") {
+    t.Fatal("no read as enabled")
+  }
+  if !parseSSHPasswordAuth("passwordauthentication yes\n") || !parseSSHPasswordAuth("") {
+    t.Fatal("yes or missing read as disabled")
+  }
+}
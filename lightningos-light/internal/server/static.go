@@ -0,0 +1,72 @@
+package server
+
+import (
+  "errors"
+  "io/fs"
+  "net/http"
+  "os"
+  "path"
+  "strings"
+
+  "lightningos-light/ui"
+)
+
+// uiAssets picks where the UI is served from: the assets embedded in the
+// binary, unless ui.serve_from_disk is set (development) or the binary was
+// built without them, in which case ui.static_dir is used.
+func (s *Server) uiAssets() (fs.FS, string) {
+  if !s.cfg.UI.ServeFromDisk {
+    if assets := ui.Assets(); assets != nil {
+      if _, err := fs.Stat(assets, "index.html"); err == nil {
+        return assets, "embedded"
+      }
+    }
+  }
+  return os.DirFS(s.cfg.UI.StaticDir), s.cfg.UI.StaticDir
+}
+
+// uiCacheControl keeps hashed build output cached for good and makes the
+// browser revalidate everything else, index.html above all, so a new build
+// is picked up on the next load.
+func uiCacheControl(name string) string {
+  if strings.HasPrefix(name, "assets/") {
+    return "public, max-age=31536000, immutable"
+  }
+  return "no-cache"
+}
+
+func (s *Server) handleSPA() http.HandlerFunc {
+  assets, source := s.uiAssets()
+  s.logger.Printf("ui: serving from %s", source)
+  fileServer := http.FileServerFS(assets)
+
+  return func(w http.ResponseWriter, r *http.Request) {
+    if strings.HasPrefix(r.URL.Path, "/api/") {
+      writeError(w, http.StatusNotFound, "not found")
+      return
+    }
+    name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+    if name != "" && name != "index.html" {
+      info, err := fs.Stat(assets, name)
+      if err == nil && !info.IsDir() {
+        w.Header().Set("Cache-Control", uiCacheControl(name))
+        fileServer.ServeHTTP(w, r)
+        return
+      }
+      // Missing files are 404s; only extension-less paths are client routes.
+      if errors.Is(err, fs.ErrNotExist) && path.Ext(name) != "" {
+        http.NotFound(w, r)
+        return
+      }
+    }
+
+    index, err := fs.ReadFile(assets, "index.html")
+    if err != nil {
+      http.Error(w, "UI not built", http.StatusNotFound)
+      return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Cache-Control", uiCacheControl("index.html"))
+    _, _ = w.Write(index)
+  }
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+  "io"
+  "log"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "lightningos-light/internal/config"
+)
+
+func TestHandleSPA(t *testing.T) {
+  dir := t.TempDir()
+  if err := os.MkdirAll(filepath.Join(dir, "assets"), 0o755); err != nil {
+    t.Fatal(err)
+  }
+  if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0o644); err != nil {
+    t.Fatal(err)
+  }
+  if err := os.WriteFile(filepath.Join(dir, "assets", "index-abc.js"), []byte("console.log(1)"), 0o644); err != nil {
+    t.Fatal(err)
+  }
+  s := &Server{
+    cfg: &config.Config{UI: config.UIConfig{StaticDir: dir, ServeFromDisk: true}},
+    logger: log.New(io.Discard, "", 0),
+  }
+  handler := s.handleSPA()
+
+  cases := []struct {
+    path string
+    status int
+    cache string
+    body string
+  }{
+    {"/", http.StatusOK, "no-cache", "app"},
+    {"/wallet/send", http.StatusOK, "no-cache", "app"},
+    {"/assets/index-abc.js", http.StatusOK, "immutable", "console.log"},
+    {"/assets/missing.js", http.StatusNotFound, "", ""},
+    {"/api/unknown", http.StatusNotFound, "", "not found"},
+  }
+  for _, tc := range cases {
+    rec := httptest.NewRecorder()
+    handler(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+    if rec.Code != tc.status {
+      t.Fatalf("%s: status %d, want %d", tc.path, rec.Code, tc.status)
+    }
+    if !strings.Contains(rec.Header().Get("Cache-Control"), tc.cache) {
+      t.Fatalf("%s: cache-control %q, want %q", tc.path, rec.Header().Get("Cache-Control"), tc.cache)
+    }
+    if !strings.Contains(rec.Body.String(), tc.body) {
+      t.Fatalf("%s: unexpected body %q", tc.path, rec.Body.String())
+    }
+  }
+}
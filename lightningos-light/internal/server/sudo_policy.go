@@ -0,0 +1,204 @@
+package server
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+  "os/exec"
+  "os/user"
+  "strings"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/system"
+)
+
+// The sudo policy lists every command the manager runs through sudo -n,
+// narrowed to the exact arguments where they are fixed (service restarts,
+// power actions, package installs, ufw rules) and left open where the
+// arguments vary (the container runtime, systemd-run). The open rules are
+// flagged root-equivalent in the report and the sudoers comments. It can be
+// rendered as a sudoers file and checked against what sudo currently allows.
+
+// sudoRule is one sudoers command spec. Args empty means any arguments.
+type sudoRule struct {
+  Command string `json:"command"`
+  Args []string `json:"args,omitempty"`
+  Purpose string `json:"purpose"`
+  // Feature is the capability the rule is part of.
+  Feature string `json:"feature"`
+  // RootEquivalent marks rules that let the manager run anything as root
+  // (a shell under systemd-run, a privileged container).
+  RootEquivalent bool `json:"root_equivalent,omitempty"`
+}
+
+// sudoers renders the command spec; "*" in Args is sudo's wildcard.
+func (r sudoRule) sudoers() string {
+  if len(r.Args) == 0 {
+    return r.Command + " *"
+  }
+  return r.Command + " " + strings.Join(r.Args, " ")
+}
+
+type sudoRuleStatus struct {
+  sudoRule
+  Line string `json:"line"`
+  Allowed bool `json:"allowed"`
+  Error string `json:"error,omitempty"`
+}
+
+// SudoPolicyReport is the generated sudoers file with each rule checked.
+type SudoPolicyReport struct {
+  User string `json:"user"`
+  Path string `json:"path"`
+  Rules []sudoRuleStatus `json:"rules"`
+  Missing int `json:"missing"`
+  Sudoers string `json:"sudoers"`
+}
+
+// runtimeAptPackages are the packages each runtime's Ensure installs.
+var runtimeAptPackages = map[string][]string{
+  containerRuntimeDocker: {"docker.io", "docker-compose-plugin", "docker-compose", "curl"},
+  containerRuntimePodman: {"podman", "podman-compose"},
+}
+
+func sudoPolicyRules(rt containerRuntime, resolve func(string) string) []sudoRule {
+  systemctl := resolve("systemctl")
+  rules := []sudoRule{
+    {Command: systemctl, Args: []string{"restart", "lnd"}, Purpose: "restart LND", Feature: capabilityLNDService},
+    {Command: systemctl, Args: []string{"stop", "lnd"}, Purpose: "stop LND for migrations, imports and shutdown", Feature: capabilityLNDService},
+    {Command: systemctl, Args: []string{"start", "lnd"}, Purpose: "start LND", Feature: capabilityLNDService},
+    {Command: systemctl, Args: []string{"restart", "lightningos-manager"}, Purpose: "restart the manager", Feature: capabilityManagerRestart},
+    {Command: systemctl, Args: []string{"restart", "postgresql"}, Purpose: "restart Postgres", Feature: capabilityPostgresRestart},
+    {Command: systemctl, Args: []string{"reboot"}, Purpose: "reboot", Feature: capabilityPower},
+    {Command: systemctl, Args: []string{"poweroff"}, Purpose: "shutdown", Feature: capabilityPower},
+    {Command: systemctl, Args: []string{"enable", "--now", "docker"}, Purpose: "start Docker for the apps", Feature: capabilityApps},
+    {Command: lndFixPermsScript, Purpose: "fix LND file permissions", Feature: capabilityLNDFiles},
+    {Command: sshAccessScript, Purpose: "manage SSH keys and password logins", Feature: capabilitySSH},
+    {Command: resolve("smartctl"), Args: []string{"-a", "*"}, Purpose: "disk health", Feature: capabilityDiskHealth},
+    {Command: resolve("rm"), Args: []string{"-f", "/data/lnd/tls.cert", "/data/lnd/tls.key"}, Purpose: "regenerate LND's TLS cert for LNDg", Feature: capabilityLNDFiles},
+    {Command: resolve("systemd-run"), Purpose: "run installs and app service restarts outside the manager's sandbox", Feature: capabilityApps, RootEquivalent: true},
+  }
+  apt := resolve("apt-get")
+  rules = append(rules, sudoRule{Command: apt, Args: []string{"update"}, Purpose: "refresh the package lists", Feature: capabilityApps})
+  packages := runtimeAptPackages[containerRuntimeDocker]
+  if rt.Name() == containerRuntimePodman {
+    packages = runtimeAptPackages[containerRuntimePodman]
+  }
+  for _, pkg := range packages {
+    rules = append(rules, sudoRule{Command: apt, Args: []string{"install", "-y", pkg}, Purpose: "install app dependencies", Feature: capabilityApps})
+  }
+  ufw := resolve("ufw")
+  rules = append(rules,
+    sudoRule{Command: ufw, Args: []string{"status"}, Purpose: "read the firewall state", Feature: capabilityFirewall},
+    sudoRule{Command: ufw, Args: []string{"status", "verbose"}, Purpose: "read the firewall state", Feature: capabilityFirewall},
+    sudoRule{Command: ufw, Args: []string{"show", "added"}, Purpose: "read the firewall rules", Feature: capabilityFirewall},
+    sudoRule{Command: ufw, Args: []string{"reload"}, Purpose: "apply firewall rules", Feature: capabilityFirewall},
+    sudoRule{Command: ufw, Args: []string{"allow", "in", "on", "*", "to", "any", "port", "10009", "proto", "tcp"}, Purpose: "let LNDg reach LND's gRPC port", Feature: capabilityFirewall},
+    sudoRule{Command: ufw, Args: []string{"status", "numbered"}, Purpose: "read the firewall rules", Feature: capabilityFirewall},
+  )
+  // Node ports are only ever opened to the private ranges, over TCP.
+  for _, source := range firewallLANSources {
+    rules = append(rules, sudoRule{Command: ufw, Args: []string{"allow", "from", source, "to", "any", "port", "*", "proto", "tcp"}, Purpose: "open a node port to the LAN", Feature: capabilityFirewall})
+  }
+  rules = append(rules,
+    sudoRule{Command: ufw, Args: []string{"prepend", "deny", "from", "*", "to", "any", "port", "*", "proto", "*"}, Purpose: "close a node port", Feature: capabilityFirewall},
+    sudoRule{Command: ufw, Args: []string{"--force", "delete", "*"}, Purpose: "remove a firewall rule", Feature: capabilityFirewall},
+    sudoRule{Command: ufw, Args: []string{"--force", "enable"}, Purpose: "turn the firewall on", Feature: capabilityFirewall},
+    sudoRule{Command: ufw, Args: []string{"prepend", "deny", "from", "*"}, Purpose: "block an address after failed logins", Feature: capabilityFirewall},
+  )
+  if ufw == "ufw" {
+    // Not installed: the firewall is read from nftables instead.
+    rules = append(rules, sudoRule{Command: resolve("nft"), Args: []string{"list", "ruleset"}, Purpose: "read the firewall rules", Feature: capabilityFirewall})
+  }
+  switch rt.Name() {
+  case containerRuntimePodman:
+    rules = append(rules,
+      sudoRule{Command: resolve("podman"), Purpose: "run the container apps", Feature: capabilityApps, RootEquivalent: true},
+      sudoRule{Command: resolve("podman-compose"), Purpose: "manage the container apps", Feature: capabilityApps, RootEquivalent: true},
+    )
+  default:
+    rules = append(rules,
+      sudoRule{Command: resolve("docker"), Purpose: "run the container apps", Feature: capabilityApps, RootEquivalent: true},
+      sudoRule{Command: resolve("docker-compose"), Purpose: "manage the container apps (compose v1)", Feature: capabilityApps, RootEquivalent: true},
+    )
+  }
+  return rules
+}
+
+// renderSudoers writes the rules as a sudoers.d file for user.
+func renderSudoers(username string, rules []sudoRule) string {
+  var b strings.Builder
+  b.WriteString("# Generated by lightningos-manager sudo-policy.\n")
+  fmt.Fprintf(&b, "Defaults:%s !requiretty\n", username)
+  for _, rule := range rules {
+    if rule.RootEquivalent {
+      fmt.Fprintf(&b, "# %s (ROOT-EQUIVALENT: runs arbitrary commands as root)\n", rule.Purpose)
+    } else {
+      fmt.Fprintf(&b, "# %s\n", rule.Purpose)
+    }
+    fmt.Fprintf(&b, "%s ALL=(root) NOPASSWD: %s\n", username, rule.sudoers())
+  }
+  return b.String()
+}
+
+// managerUser is the user the manager runs as, or the one it is installed
+// to run as when started by root (the CLI under sudo).
+func managerUser() string {
+  if current, err := user.Current(); err == nil && current.Username != "root" {
+    return current.Username
+  }
+  return "lightningos"
+}
+
+// BuildSudoPolicy generates the policy for the user running the manager
+// and asks sudo which rules it allows already.
+func BuildSudoPolicy(ctx context.Context, cfg *config.Config) SudoPolicyReport {
+  username := managerUser()
+  rt := pickContainerRuntime(strings.ToLower(strings.TrimSpace(cfg.Apps.ContainerRuntime)), exec.LookPath)
+  rules := sudoPolicyRules(rt, system.ResolveCommand)
+  report := SudoPolicyReport{
+    User: username,
+    Path: system.SudoersPath,
+    Rules: make([]sudoRuleStatus, 0, len(rules)),
+    Sudoers: renderSudoers(username, rules),
+  }
+  for _, rule := range rules {
+    status := sudoRuleStatus{sudoRule: rule, Line: rule.sudoers()}
+    // A wildcard is checked with a harmless sample argument.
+    args := make([]string, 0, len(rule.Args))
+    for _, arg := range rule.Args {
+      if arg == "*" {
+        arg = "x"
+      }
+      args = append(args, arg)
+    }
+    if len(rule.Args) == 0 {
+      args = []string{"--version"}
+    }
+    allowed, err := system.SudoAllowed(ctx, rule.Command, args...)
+    status.Allowed = allowed
+    if err != nil {
+      status.Error = err.Error()
+    }
+    if !allowed {
+      report.Missing++
+    }
+    report.Rules = append(report.Rules, status)
+  }
+  return report
+}
+
+// handleSudoPolicy serves GET /api/system/sudo-policy; ?format=sudoers
+// returns just the file.
+func (s *Server) handleSudoPolicy(w http.ResponseWriter, r *http.Request) {
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  report := BuildSudoPolicy(ctx, s.cfg)
+  if r.URL.Query().Get("format") == "sudoers" {
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.WriteHeader(http.StatusOK)
+    _, _ = w.Write([]byte(report.Sudoers))
+    return
+  }
+  writeJSON(w, http.StatusOK, report)
+}
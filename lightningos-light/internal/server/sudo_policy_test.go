@@ -0,0 +1,34 @@
+package server
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestSudoPolicyRules(t *testing.T) {
+  resolve := func(name string) string { return "/usr/bin/" + name }
+  sudoers := renderSudoers("lightningos", sudoPolicyRules(dockerRuntime{}, resolve))
+  for _, want := range []string{
+    "Defaults:lightningos !requiretty\n",
+    "lightningos ALL=(root) NOPASSWD: /usr/bin/systemctl restart lnd\n",
+    "lightningos ALL=(root) NOPASSWD: /usr/bin/systemctl poweroff\n",
+    "lightningos ALL=(root) NOPASSWD: /usr/bin/smartctl -a *\n",
+    "lightningos ALL=(root) NOPASSWD: /usr/bin/docker *\n",
+    "lightningos ALL=(root) NOPASSWD: /usr/bin/ufw allow in on * to any port 10009 proto tcp\n",
+    "lightningos ALL=(root) NOPASSWD: /usr/bin/ufw allow from 192.168.0.0/16 to any port * proto tcp\n",
+    "lightningos ALL=(root) NOPASSWD: /usr/bin/apt-get install -y docker.io\n",
+    "# run the container apps (ROOT-EQUIVALENT: runs arbitrary commands as root)\nlightningos ALL=(root) NOPASSWD: /usr/bin/docker *\n",
+  } {
+    if !strings.Contains(sudoers, want) {
+      t.Fatalf("sudoers missing %q:\n%s", want, sudoers)
+    }
+  }
+  if strings.Contains(sudoers, "systemctl *") || strings.Contains(sudoers, "podman") || strings.Contains(sudoers, "apt-get *") || strings.Contains(sudoers, "allow from * to") {
+    t.Fatalf("sudoers broader than needed:\n%s", sudoers)
+  }
+
+  podman := renderSudoers("lightningos", sudoPolicyRules(podmanRuntime{}, resolve))
+  if !strings.Contains(podman, "NOPASSWD: /usr/bin/podman-compose *\n") || strings.Contains(podman, "/usr/bin/docker") {
+    t.Fatalf("podman policy:\n%s", podman)
+  }
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/system"
+)
+
+const (
+  jobKindSystemPower = "system_power"
+  powerHardTimeout = 5 * time.Minute
+  powerLNDStopTimeout = 3 * time.Minute
+  powerAppStopTimeout = 90 * time.Second
+  powerPollInterval = 2 * time.Second
+)
+
+var powerSteps = []string{"stopping_lnd", "stopping_apps", "stopping_bitcoind", "powering_off"}
+
+type systemPowerParams struct {
+  Action string `json:"action"`
+  Force bool `json:"force,omitempty"`
+}
+
+type systemPowerProgress struct {
+  Step string `json:"step"`
+  Warnings []string `json:"warnings,omitempty"`
+}
+
+func (s *Server) handleSystemAction(w http.ResponseWriter, r *http.Request) {
+  var req systemPowerParams
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+
+  action := strings.ToLower(strings.TrimSpace(req.Action))
+  switch action {
+  case "restart":
+    action = "reboot"
+  case "shutdown":
+    action = "poweroff"
+  }
+  if action != "reboot" && action != "poweroff" {
+    writeError(w, http.StatusBadRequest, "unsupported action")
+    return
+  }
+
+  // force keeps the old behaviour for a node whose services hang.
+  if req.Force {
+    ctx, cancel := s.requestContext(r, timeoutShort)
+    defer cancel()
+    if err := system.SystemctlPower(ctx, action); err != nil {
+      writeError(w, http.StatusInternalServerError, "system action failed")
+      return
+    }
+    writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+    return
+  }
+
+  job, err := s.jobs.submit(config.DefaultNodeID, jobKindSystemPower, "", systemPowerParams{Action: action})
+  if err != nil {
+    writeJobSubmitError(w, err)
+    return
+  }
+  writeJobStarted(w, job)
+}
+
+// runSystemPowerJob stops LND first and waits for it to exit, so its
+// databases are closed cleanly, then the apps and bitcoind, and only then
+// reboots or powers off. Every step is bounded by what is left of
+// powerHardTimeout; a step that fails or runs out of time is recorded as a
+// warning and the power action still happens.
+func (s *Server) runSystemPowerJob(ctx context.Context, job *jobHandle) (any, error) {
+  var params systemPowerParams
+  if err := job.Params(&params); err != nil {
+    return nil, err
+  }
+  deadline := time.Now().Add(powerHardTimeout)
+  progress := systemPowerProgress{}
+  step := func(index int) {
+    progress.Step = powerSteps[index]
+    job.Progress(index, len(powerSteps), progress)
+  }
+  warn := func(format string, args ...any) {
+    msg := fmt.Sprintf(format, args...)
+    s.logger.Printf("system %s: %s", params.Action, msg)
+    progress.Warnings = append(progress.Warnings, msg)
+  }
+  bounded := func(limit time.Duration) (context.Context, context.CancelFunc) {
+    if remaining := time.Until(deadline); remaining < limit {
+      limit = remaining
+    }
+    return context.WithTimeout(ctx, limit)
+  }
+
+  step(0)
+  stepCtx, cancel := bounded(powerLNDStopTimeout)
+  if err := s.stopLNDCleanly(stepCtx); err != nil {
+    warn("lnd did not stop cleanly: %v", err)
+  }
+  cancel()
+  if ctx.Err() != nil {
+    return nil, ctx.Err()
+  }
+
+  apps, err := s.appRegistry()
+  if err != nil {
+    warn("failed to list apps: %v", err)
+  }
+  var bitcoind appHandler
+  step(1)
+  for i := len(apps) - 1; i >= 0; i-- {
+    app := apps[i]
+    if app.Definition().ID == "bitcoincore" {
+      bitcoind = app
+      continue
+    }
+    stepCtx, cancel := bounded(powerAppStopTimeout)
+    if err := stopInstalledApp(stepCtx, app); err != nil {
+      warn("%s did not stop: %v", app.Definition().ID, err)
+    }
+    cancel()
+  }
+  if ctx.Err() != nil {
+    return nil, ctx.Err()
+  }
+
+  step(2)
+  if bitcoind != nil {
+    stepCtx, cancel := bounded(powerAppStopTimeout)
+    if err := stopInstalledApp(stepCtx, bitcoind); err != nil {
+      warn("bitcoind did not stop: %v", err)
+    }
+    cancel()
+  }
+  if ctx.Err() != nil {
+    return nil, ctx.Err()
+  }
+
+  // The power action gets its own short timeout: it must run even when the
+  // steps above used up the hard limit.
+  step(3)
+  powerCtx, powerCancel := context.WithTimeout(context.Background(), timeoutShort.duration(s.timeouts()))
+  defer powerCancel()
+  if err := system.SystemctlPower(powerCtx, params.Action); err != nil {
+    return nil, errors.New("system action failed")
+  }
+  return map[string]any{"action": params.Action, "warnings": progress.Warnings}, nil
+}
+
+// stopLNDCleanly asks LND to stop and waits for the service to go inactive,
+// falling back to systemctl stop when the RPC is not available.
+func (s *Server) stopLNDCleanly(ctx context.Context) error {
+  if !system.SystemctlIsActive(ctx, "lnd") {
+    return nil
+  }
+  s.markLNDRestart()
+  if err := s.lnd.StopDaemon(ctx); err != nil {
+    s.logger.Printf("system power: lnd StopDaemon failed, using systemctl stop: %v", err)
+    return system.SystemctlStop(ctx, "lnd")
+  }
+  ticker := time.NewTicker(powerPollInterval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ctx.Done():
+      // Out of time: let systemd finish the job with its own stop timeout.
+      stopCtx, cancel := context.WithTimeout(context.Background(), timeoutLong.duration(s.timeouts()))
+      defer cancel()
+      if err := system.SystemctlStop(stopCtx, "lnd"); err != nil {
+        return err
+      }
+      return ctx.Err()
+    case <-ticker.C:
+      if !system.SystemctlIsActive(ctx, "lnd") {
+        return nil
+      }
+    }
+  }
+}
+
+func stopInstalledApp(ctx context.Context, app appHandler) error {
+  info, err := app.Info(ctx)
+  if err != nil && info.ID == "" {
+    return err
+  }
+  if !info.Installed {
+    return nil
+  }
+  return app.Stop(ctx)
+}
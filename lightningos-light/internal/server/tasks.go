@@ -0,0 +1,140 @@
+package server
+
+import (
+  "context"
+  "net/http"
+  "sort"
+  "sync"
+  "time"
+)
+
+// Background work hangs off the server's root context, which is cancelled
+// when the manager gets SIGTERM or SIGINT: pollers stop between rounds and
+// in-flight RPCs and queries end instead of running past the process. Each
+// bounded step of a scheduler is registered as a task while it runs, so
+// /api/system/tasks shows what a shutdown would cut short.
+const (
+  shutdownGrace = 15 * time.Second
+  shutdownPoll = 100 * time.Millisecond
+)
+
+type serverTask struct {
+  ID uint64 `json:"id"`
+  Name string `json:"name"`
+  StartedAt time.Time `json:"started_at"`
+  Deadline time.Time `json:"deadline"`
+}
+
+type taskRegistry struct {
+  mu sync.Mutex
+  next uint64
+  running map[uint64]serverTask
+}
+
+func newTaskRegistry() *taskRegistry {
+  return &taskRegistry{running: map[uint64]serverTask{}}
+}
+
+func (t *taskRegistry) begin(name string, deadline time.Time) uint64 {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  t.next++
+  t.running[t.next] = serverTask{ID: t.next, Name: name, StartedAt: time.Now().UTC(), Deadline: deadline.UTC()}
+  return t.next
+}
+
+func (t *taskRegistry) end(id uint64) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  delete(t.running, id)
+}
+
+func (t *taskRegistry) list() []serverTask {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  items := make([]serverTask, 0, len(t.running))
+  for _, task := range t.running {
+    items = append(items, task)
+  }
+  sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+  return items
+}
+
+// taskContext bounds one step of a background loop by a timeout class,
+// under the root context, and lists it as a task until cancel is called.
+func (s *Server) taskContext(name string, class timeoutClass) (context.Context, context.CancelFunc) {
+  return s.taskContextFor(name, class.duration(s.timeouts()))
+}
+
+// taskContextFor is taskContext for a step whose limit is fixed rather than
+// a timeout class, like a Postgres dump.
+func (s *Server) taskContextFor(name string, timeout time.Duration) (context.Context, context.CancelFunc) {
+  ctx, cancel := context.WithTimeout(s.root, timeout)
+  deadline, _ := ctx.Deadline()
+  id := s.tasks.begin(name, deadline)
+  return ctx, func() {
+    s.tasks.end(id)
+    cancel()
+  }
+}
+
+// wait sleeps for d and reports false instead when the server shuts down
+// first, so a loop can return.
+func (s *Server) wait(d time.Duration) bool {
+  timer := time.NewTimer(d)
+  defer timer.Stop()
+  select {
+  case <-s.root.Done():
+    return false
+  case <-timer.C:
+    return true
+  }
+}
+
+// shutdown cancels the root context, stops accepting requests and gives
+// tasks and jobs shutdownGrace to return. Jobs cut short stay running in
+// Postgres, so the next start resumes them or marks them interrupted.
+func (s *Server) shutdown(httpServer *http.Server) {
+  s.logger.Printf("shutting down: %d task(s), %d job(s) in flight", len(s.tasks.list()), len(s.jobs.running()))
+  s.stop()
+  if s.amboss != nil {
+    s.amboss.Stop()
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+  defer cancel()
+  if err := httpServer.Shutdown(ctx); err != nil {
+    s.logger.Printf("shutdown: http server: %v", err)
+  }
+  for len(s.tasks.list()) > 0 || len(s.jobs.running()) > 0 {
+    select {
+    case <-ctx.Done():
+      for _, task := range s.tasks.list() {
+        s.logger.Printf("shutdown: task %s still running", task.Name)
+      }
+      for _, job := range s.jobs.running() {
+        s.logger.Printf("shutdown: job %s %s still running", job.Kind, job.ID)
+      }
+      return
+    case <-time.After(shutdownPoll):
+    }
+  }
+}
+
+func (s *Server) handleSystemTasks(w http.ResponseWriter, r *http.Request) {
+  now := time.Now()
+  tasks := []map[string]any{}
+  for _, task := range s.tasks.list() {
+    tasks = append(tasks, map[string]any{
+      "id": task.ID,
+      "name": task.Name,
+      "started_at": task.StartedAt,
+      "deadline": task.Deadline,
+      "running_sec": int64(now.Sub(task.StartedAt) / time.Second),
+    })
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "shutting_down": s.root.Err() != nil,
+    "tasks": tasks,
+    "jobs": s.jobs.running(),
+  })
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+  "context"
+  "io"
+  "log"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+)
+
+func TestTaskContextAndShutdown(t *testing.T) {
+  root, stop := context.WithCancel(context.Background())
+  s := &Server{root: root, stop: stop, tasks: newTaskRegistry(), cfg: &config.Config{}}
+  s.jobs = newJobManager(root, log.New(io.Discard, "", 0))
+
+  ctx, done := s.taskContext("test/step", timeoutShort)
+  tasks := s.tasks.list()
+  if len(tasks) != 1 || tasks[0].Name != "test/step" || tasks[0].Deadline.IsZero() {
+    t.Fatalf("tasks = %+v", tasks)
+  }
+  started := make(chan struct{})
+  s.jobs.register("test", jobKind{run: func(ctx context.Context, job *jobHandle) (any, error) {
+    close(started)
+    <-ctx.Done()
+    return nil, ctx.Err()
+  }})
+  if _, err := s.jobs.submit("main", "test", "", nil); err != nil {
+    t.Fatal(err)
+  }
+  <-started
+
+  stop()
+  if ctx.Err() == nil {
+    t.Fatal("task context outlived the root")
+  }
+  if s.wait(time.Hour) {
+    t.Fatal("wait slept through the shutdown")
+  }
+  done()
+  if len(s.tasks.list()) != 0 {
+    t.Fatalf("finished task still listed: %+v", s.tasks.list())
+  }
+  // A job cut short by the shutdown is dropped rather than marked
+  // cancelled, so its row is left for the next start.
+  deadline := time.Now().Add(2 * time.Second)
+  for len(s.jobs.running()) > 0 {
+    if time.Now().After(deadline) {
+      t.Fatalf("job still running: %+v", s.jobs.running())
+    }
+    time.Sleep(10 * time.Millisecond)
+  }
+}
@@ -127,7 +127,7 @@ func (s *Server) handleTelegramBackupTest(w http.ResponseWriter, r *http.Request
     return
   }
 
-  ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+  ctx, cancel := s.requestContext(r, timeoutLong)
   defer cancel()
 
   data, err := s.lnd.ExportAllChannelBackups(ctx)
@@ -140,11 +140,13 @@ func (s *Server) handleTelegramBackupTest(w http.ResponseWriter, r *http.Request
     return
   }
 
-  filename, caption := telegramBackupPayload("test", "", time.Now().UTC())
+  now := time.Now().UTC()
+  filename, caption := telegramBackupPayload("test", "", now)
   if err := sendTelegramDocument(ctx, cfg.BotToken, cfg.ChatID, filename, data, caption); err != nil {
     writeError(w, http.StatusInternalServerError, err.Error())
     return
   }
+  recordBackup("scb", now)
 
   writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
@@ -186,13 +188,14 @@ func (n *Notifier) triggerTelegramBackup(reason, channelPoint string) {
   if !n.shouldSendTelegramBackup(reason, channelPoint) {
     return
   }
+  n.triggerSCBEscrow(reason)
   cfg := readTelegramBackupConfig()
   if !cfg.configured() {
     return
   }
 
   go func() {
-    ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+    ctx, cancel := context.WithTimeout(n.context(), 15*time.Second)
     defer cancel()
     if err := n.sendTelegramBackup(ctx, cfg, reason, channelPoint); err != nil {
       n.logger.Printf("notifications: telegram backup failed: %v", err)
@@ -229,9 +232,13 @@ func (n *Notifier) sendTelegramBackup(ctx context.Context, cfg telegramBackupCon
     return errors.New("empty channel backup")
   }
 
-  filename, caption := telegramBackupPayload(reason, channelPoint, time.Now().UTC())
-
-  return sendTelegramDocument(ctx, cfg.BotToken, cfg.ChatID, filename, data, caption)
+  now := time.Now().UTC()
+  filename, caption := telegramBackupPayload(reason, channelPoint, now)
+  if err := sendTelegramDocument(ctx, cfg.BotToken, cfg.ChatID, filename, data, caption); err != nil {
+    return err
+  }
+  recordBackup("scb", now)
+  return nil
 }
 
 func telegramBackupPayload(reason, channelPoint string, when time.Time) (string, string) {
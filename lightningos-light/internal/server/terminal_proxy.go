@@ -43,10 +43,11 @@ func (s *Server) handleTerminalProxy(w http.ResponseWriter, r *http.Request) {
   }
 
   if r.URL.Path == terminalProxyPrefix {
-    http.Redirect(w, r, terminalProxyPrefix+"/", http.StatusTemporaryRedirect)
+    http.Redirect(w, r, s.publicURL(r, terminalProxyPrefix+"/"), http.StatusTemporaryRedirect)
     return
   }
 
+  forwarded := requestForwarded(r)
   target := terminalProxyTarget()
   authHeader := basicAuthHeader(terminalCredential())
   proxy := httputil.NewSingleHostReverseProxy(target)
@@ -65,11 +66,12 @@ func (s *Server) handleTerminalProxy(w http.ResponseWriter, r *http.Request) {
     if authHeader != "" {
       req.Header.Set("Authorization", authHeader)
     }
-    if req.Header.Get("X-Forwarded-Host") == "" && r.Host != "" {
-      req.Header.Set("X-Forwarded-Host", r.Host)
+    if forwarded.Host != "" {
+      req.Header.Set("X-Forwarded-Host", forwarded.Host)
     }
-    if req.Header.Get("X-Forwarded-Proto") == "" {
-      req.Header.Set("X-Forwarded-Proto", "https")
+    req.Header.Set("X-Forwarded-Proto", forwarded.Scheme)
+    if prefix := s.basePath(); prefix != "" {
+      req.Header.Set("X-Forwarded-Prefix", prefix+terminalProxyPrefix)
     }
   }
   proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
@@ -0,0 +1,146 @@
+package server
+
+import (
+  "context"
+  "net/http"
+  "os"
+  "time"
+
+  "lightningos-light/internal/config"
+  "lightningos-light/internal/lndclient"
+
+  "github.com/go-chi/chi/v5"
+)
+
+// timeoutClass names the kind of work a handler does; the duration for each
+// class comes from the timeouts section of the config.
+type timeoutClass int
+
+const (
+  timeoutProbe timeoutClass = iota
+  timeoutShort
+  timeoutMedium
+  timeoutLNDRPC
+  timeoutLong
+  timeoutLNDWrite
+)
+
+const timeoutsReloadInterval = 10 * time.Second
+
+// adaptiveHeadroom is how much longer than the recent slowest request an
+// adaptive deadline allows.
+const adaptiveHeadroom = 1.5
+
+func (c timeoutClass) duration(t config.TimeoutsConfig) time.Duration {
+  defaults := config.DefaultTimeouts()
+  pick := func(value, fallback time.Duration) time.Duration {
+    if value <= 0 {
+      return fallback
+    }
+    return value
+  }
+  switch c {
+  case timeoutProbe:
+    return pick(t.Probe, defaults.Probe)
+  case timeoutShort:
+    return pick(t.Short, defaults.Short)
+  case timeoutMedium:
+    return pick(t.Medium, defaults.Medium)
+  case timeoutLong:
+    return pick(t.Long, defaults.Long)
+  case timeoutLNDWrite:
+    return pick(t.LNDWrite, defaults.LNDWrite)
+  default:
+    return pick(t.LNDRPC, defaults.LNDRPC)
+  }
+}
+
+func (s *Server) timeouts() config.TimeoutsConfig {
+  if current := s.timeoutsCfg.Load(); current != nil {
+    return *current
+  }
+  return s.cfg.Timeouts
+}
+
+func (s *Server) setTimeouts(t config.TimeoutsConfig) {
+  s.timeoutsCfg.Store(&t)
+  lndclient.SetReadRetry(t.RetryReadsEnabled())
+}
+
+// requestTimeout is the deadline for a handler: a timeouts.endpoints entry
+// for the matched route when there is one, otherwise the class default,
+// stretched for slow routes when adaptive timeouts are on.
+func (s *Server) requestTimeout(r *http.Request, class timeoutClass) time.Duration {
+  t := s.timeouts()
+  pattern := ""
+  if rctx := chi.RouteContext(r.Context()); rctx != nil {
+    pattern = rctx.RoutePattern()
+  }
+  if pattern != "" && len(t.Endpoints) > 0 {
+    if value, ok := t.Endpoint(r.Method, pattern); ok {
+      return value
+    }
+  }
+  base := class.duration(t)
+  if pattern == "" || s.metrics == nil || !t.AdaptiveEnabled() || (class != timeoutLNDRPC && class != timeoutLong) {
+    return base
+  }
+  return adaptiveDeadline(base, s.metrics.recentPeak(r.Method, pattern, time.Now()), t.AdaptiveMax)
+}
+
+// adaptiveDeadline gives a route headroom over its recent slowest request,
+// never less than base nor more than max. A request cut off at the deadline
+// counts as that slow, so repeated timeouts keep stretching it.
+func adaptiveDeadline(base, peak, max time.Duration) time.Duration {
+  stretched := time.Duration(float64(peak) * adaptiveHeadroom)
+  if stretched <= base {
+    return base
+  }
+  if max < base {
+    max = base
+  }
+  if stretched > max {
+    return max
+  }
+  return stretched
+}
+
+func (s *Server) requestContext(r *http.Request, class timeoutClass) (context.Context, context.CancelFunc) {
+  return context.WithTimeout(r.Context(), s.requestTimeout(r, class))
+}
+
+// operationContext bounds one step of a background job by a timeout class.
+func (s *Server) operationContext(parent context.Context, class timeoutClass) (context.Context, context.CancelFunc) {
+  return context.WithTimeout(parent, class.duration(s.timeouts()))
+}
+
+// watchTimeouts reloads the timeouts section when the config file changes,
+// so they can be tuned without restarting the manager.
+func (s *Server) watchTimeouts() {
+  path := s.cfg.Path
+  if path == "" {
+    return
+  }
+  var lastMod time.Time
+  if info, err := os.Stat(path); err == nil {
+    lastMod = info.ModTime()
+  }
+  goSafe("config/timeouts_watch", func() {
+    ticker := time.NewTicker(timeoutsReloadInterval)
+    defer ticker.Stop()
+    for range ticker.C {
+      info, err := os.Stat(path)
+      if err != nil || !info.ModTime().After(lastMod) {
+        continue
+      }
+      lastMod = info.ModTime()
+      t, err := config.LoadTimeouts(path)
+      if err != nil {
+        s.logger.Printf("timeouts: reload failed, keeping previous values: %v", err)
+        continue
+      }
+      s.setTimeouts(t)
+      s.logger.Printf("timeouts: reloaded from %s", path)
+    }
+  })
+}
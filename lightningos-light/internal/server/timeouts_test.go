@@ -0,0 +1,142 @@
+package server
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "lightningos-light/internal/config"
+
+  "github.com/go-chi/chi/v5"
+)
+
+func TestLoadTimeouts(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "config.yaml")
+  body := `
+timeouts:
+  probe: 2s
+  lnd_write: 90s
+  retry_reads: false
+  endpoints:
+    "post /api/wallet/pay": 2m
+    /api/lnd/channels: 20s
+`
+  if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+    t.Fatalf("write config: %v", err)
+  }
+  got, err := config.LoadTimeouts(path)
+  if err != nil {
+    t.Fatalf("load: %v", err)
+  }
+  if got.Probe != 2*time.Second || got.LNDWrite != 90*time.Second || got.LNDRPC != 15*time.Second {
+    t.Fatalf("unexpected timeouts: %+v", got)
+  }
+  if got.RetryReadsEnabled() {
+    t.Fatalf("retry_reads: false was ignored")
+  }
+  if value, ok := got.Endpoint(http.MethodPost, "/api/wallet/pay"); !ok || value != 2*time.Minute {
+    t.Fatalf("method override not found: %v %v", value, ok)
+  }
+
+  if err := os.WriteFile(path, []byte("timeouts:\n  short: -1s\n"), 0o600); err != nil {
+    t.Fatalf("write config: %v", err)
+  }
+  if _, err := config.LoadTimeouts(path); err == nil {
+    t.Fatalf("expected negative timeout to be rejected")
+  }
+}
+
+func TestRequestTimeout(t *testing.T) {
+  s := &Server{cfg: &config.Config{}}
+  s.setTimeouts(config.TimeoutsConfig{
+    Medium: 9 * time.Second,
+    Endpoints: map[string]time.Duration{
+      "POST /api/wallet/pay": 2 * time.Minute,
+      "/api/lnd/channels": 20 * time.Second,
+    },
+  })
+  defer s.setTimeouts(config.DefaultTimeouts())
+
+  var got time.Duration
+  router := chi.NewRouter()
+  capture := func(class timeoutClass) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+      got = s.requestTimeout(r, class)
+    }
+  }
+  router.Post("/api/wallet/pay", capture(timeoutLNDWrite))
+  router.Get("/api/wallet/pay", capture(timeoutLNDWrite))
+  router.Get("/api/lnd/channels", capture(timeoutLNDRPC))
+  router.Get("/api/lnd/status", capture(timeoutMedium))
+  router.Get("/api/health", capture(timeoutProbe))
+
+  cases := []struct {
+    method string
+    path string
+    want time.Duration
+  }{
+    {http.MethodPost, "/api/wallet/pay", 2 * time.Minute},
+    {http.MethodGet, "/api/wallet/pay", 45 * time.Second},
+    {http.MethodGet, "/api/lnd/channels", 20 * time.Second},
+    {http.MethodGet, "/api/lnd/status", 9 * time.Second},
+    {http.MethodGet, "/api/health", 4 * time.Second},
+  }
+  for _, tc := range cases {
+    router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+    if got != tc.want {
+      t.Fatalf("%s %s: expected %s, got %s", tc.method, tc.path, tc.want, got)
+    }
+  }
+}
+
+func TestAdaptiveTimeout(t *testing.T) {
+  if got := adaptiveDeadline(15*time.Second, 4*time.Second, 90*time.Second); got != 15*time.Second {
+    t.Fatalf("fast route stretched to %s", got)
+  }
+  if got := adaptiveDeadline(15*time.Second, 20*time.Second, 90*time.Second); got != 30*time.Second {
+    t.Fatalf("slow route got %s", got)
+  }
+  if got := adaptiveDeadline(15*time.Second, 80*time.Second, 90*time.Second); got != 90*time.Second {
+    t.Fatalf("cap ignored: %s", got)
+  }
+  if got := decayedPeak(20*time.Second, latencyPeakHalfLife); got != 10*time.Second {
+    t.Fatalf("decay %s", got)
+  }
+
+  s := &Server{cfg: &config.Config{}, metrics: newHTTPMetrics()}
+  s.setTimeouts(config.DefaultTimeouts())
+  s.metrics.observe(http.MethodGet, "/api/lnd/channels", http.StatusGatewayTimeout, 15*time.Second)
+
+  var got time.Duration
+  router := chi.NewRouter()
+  capture := func(class timeoutClass) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+      got = s.requestTimeout(r, class)
+    }
+  }
+  router.Get("/api/lnd/channels", capture(timeoutLNDRPC))
+  router.Get("/api/lnd/status", capture(timeoutProbe))
+  router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/lnd/channels", nil))
+  if got < 22*time.Second || got > 23*time.Second {
+    t.Fatalf("timed-out route got %s", got)
+  }
+
+  s.metrics.observe(http.MethodGet, "/api/lnd/status", http.StatusOK, 10*time.Second)
+  router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/lnd/status", nil))
+  if got != 4*time.Second {
+    t.Fatalf("probe class stretched to %s", got)
+  }
+
+  off := false
+  cfg := config.DefaultTimeouts()
+  cfg.Adaptive = &off
+  s.setTimeouts(cfg)
+  defer s.setTimeouts(config.DefaultTimeouts())
+  router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/lnd/channels", nil))
+  if got != 15*time.Second {
+    t.Fatalf("adaptive off got %s", got)
+  }
+}
@@ -0,0 +1,408 @@
+package server
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "math"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/jackc/pgx/v5/pgtype"
+)
+
+// Uptime tracking turns the periodic health check into up and down
+// intervals per component, so availability can be reported per month the
+// way an LSP promises it. The manager's own downtime is the gap it finds at
+// startup after its last heartbeat; while it is down the other components
+// are not observed, and that time counts as unmonitored rather than down.
+const (
+  uptimeSampleInterval = time.Minute
+  // uptimeConfirmSamples is how many consecutive checks must agree before a
+  // state change is recorded, so one slow RPC is not an outage.
+  uptimeConfirmSamples = 2
+  uptimeMaxIncidents = 200
+
+  uptimeComponentManager = "manager"
+  uptimeComponentLND = "lnd"
+  uptimeComponentBitcoind = "bitcoind"
+
+  uptimeStateUp = "up"
+  uptimeStateDown = "down"
+)
+
+var uptimeComponents = []string{uptimeComponentManager, uptimeComponentLND, uptimeComponentBitcoind}
+
+type uptimeInterval struct {
+  ID int64 `json:"id"`
+  Component string `json:"component"`
+  State string `json:"state"`
+  StartedAt time.Time `json:"started_at"`
+  LastSeenAt time.Time `json:"last_seen_at"`
+  EndedAt *time.Time `json:"ended_at,omitempty"`
+  Reason string `json:"reason,omitempty"`
+}
+
+func (s *Server) ensureUptimeSchema(ctx context.Context) error {
+  if s.db == nil {
+    return errors.New("db not configured")
+  }
+  _, err := s.db.Exec(ctx, `
+create table if not exists uptime_intervals (
+  id bigserial primary key,
+  component text not null,
+  state text not null,
+  started_at timestamptz not null,
+  last_seen_at timestamptz not null,
+  ended_at timestamptz,
+  reason text not null default ''
+);
+create index if not exists uptime_intervals_component_started_idx on uptime_intervals (component, started_at);
+`)
+  return err
+}
+
+func (s *Server) initUptime() {
+  if s.db == nil {
+    return
+  }
+  ctx, cancel := context.WithTimeout(s.root, 5*time.Second)
+  defer cancel()
+  if err := s.ensureUptimeSchema(ctx); err != nil {
+    s.logger.Printf("uptime: failed to init schema: %v", err)
+    return
+  }
+  if err := s.recordManagerStart(ctx, time.Now().UTC()); err != nil {
+    s.logger.Printf("uptime: failed to record start: %v", err)
+  }
+  goSafe("uptime", s.runUptime)
+}
+
+// recordManagerStart closes what the previous run left open at its last
+// heartbeat and, when the gap is longer than a couple of samples, records
+// it as manager downtime.
+func (s *Server) recordManagerStart(ctx context.Context, now time.Time) error {
+  var lastSeen pgtype.Timestamptz
+  if err := s.db.QueryRow(ctx, `select max(last_seen_at) from uptime_intervals where ended_at is null`).Scan(&lastSeen); err != nil {
+    return err
+  }
+  if _, err := s.db.Exec(ctx, `update uptime_intervals set ended_at=last_seen_at where ended_at is null`); err != nil {
+    return err
+  }
+  if lastSeen.Valid && now.Sub(lastSeen.Time) > uptimeConfirmSamples*uptimeSampleInterval {
+    if _, err := s.db.Exec(ctx, `
+insert into uptime_intervals (component, state, started_at, last_seen_at, ended_at, reason)
+values ($1, $2, $3, $4, $4, 'manager not running')`, uptimeComponentManager, uptimeStateDown, lastSeen.Time, now); err != nil {
+      return err
+    }
+  }
+  _, err := s.db.Exec(ctx, `
+insert into uptime_intervals (component, state, started_at, last_seen_at)
+values ($1, $2, $3, $3)`, uptimeComponentManager, uptimeStateUp, now)
+  return err
+}
+
+// uptimeObservation is what one health check says about a component.
+type uptimeObservation struct {
+  State string
+  Reason string
+}
+
+// observeUptime reads the component states out of a health report: a
+// component is down when the check reports an error for it.
+func observeUptime(health healthResponse) map[string]uptimeObservation {
+  out := map[string]uptimeObservation{
+    uptimeComponentManager: {State: uptimeStateUp},
+    uptimeComponentLND: {State: uptimeStateUp},
+    uptimeComponentBitcoind: {State: uptimeStateUp},
+  }
+  for _, issue := range health.Issues {
+    if issue.Level != "ERR" {
+      continue
+    }
+    component := ""
+    switch issue.Component {
+    case "lnd":
+      component = uptimeComponentLND
+    case "bitcoin":
+      component = uptimeComponentBitcoind
+    default:
+      continue
+    }
+    if out[component].State == uptimeStateDown {
+      continue
+    }
+    out[component] = uptimeObservation{State: uptimeStateDown, Reason: issue.Message}
+  }
+  return out
+}
+
+// uptimeTracker debounces observations: current is the recorded state,
+// pending a different state seen on consecutive checks but not yet enough.
+type uptimeTracker struct {
+  s *Server
+  current map[string]string
+  pending map[string]uptimePending
+}
+
+type uptimePending struct {
+  obs uptimeObservation
+  since time.Time
+  count int
+}
+
+func (s *Server) runUptime() {
+  tracker := &uptimeTracker{s: s, current: map[string]string{uptimeComponentManager: uptimeStateUp}, pending: map[string]uptimePending{}}
+  for {
+    if !s.wait(uptimeSampleInterval) {
+      return
+    }
+    health := s.healthReport(func(class timeoutClass) (context.Context, context.CancelFunc) {
+      return s.taskContext("uptime", class)
+    })
+    tracker.observe(observeUptime(health), time.Now().UTC())
+  }
+}
+
+// uptimeTransition is a confirmed state change to record.
+type uptimeTransition struct {
+  Component string
+  Obs uptimeObservation
+  Since time.Time
+}
+
+// step applies one round of observations and returns the confirmed
+// changes. A change takes effect from the first check that saw it.
+func (t *uptimeTracker) step(observed map[string]uptimeObservation, now time.Time) []uptimeTransition {
+  changes := []uptimeTransition{}
+  for _, component := range uptimeComponents {
+    obs := observed[component]
+    if obs.State == t.current[component] {
+      delete(t.pending, component)
+      continue
+    }
+    p, ok := t.pending[component]
+    if !ok || p.obs.State != obs.State {
+      p = uptimePending{obs: obs, since: now}
+    }
+    p.count++
+    // The first observation of a component has nothing to debounce against.
+    if p.count >= uptimeConfirmSamples || t.current[component] == "" {
+      changes = append(changes, uptimeTransition{Component: component, Obs: p.obs, Since: p.since})
+      t.current[component] = obs.State
+      delete(t.pending, component)
+      continue
+    }
+    t.pending[component] = p
+  }
+  return changes
+}
+
+func (t *uptimeTracker) observe(observed map[string]uptimeObservation, now time.Time) {
+  changes := t.step(observed, now)
+  ctx, cancel := t.s.taskContext("uptime", timeoutShort)
+  defer cancel()
+  for _, change := range changes {
+    if _, err := t.s.db.Exec(ctx, `
+update uptime_intervals set ended_at=$2, last_seen_at=$2 where component=$1 and ended_at is null`, change.Component, change.Since); err != nil {
+      t.s.logger.Printf("uptime: failed to close %s interval: %v", change.Component, err)
+      continue
+    }
+    if _, err := t.s.db.Exec(ctx, `
+insert into uptime_intervals (component, state, started_at, last_seen_at, reason)
+values ($1, $2, $3, $4, $5)`, change.Component, change.Obs.State, change.Since, now, change.Obs.Reason); err != nil {
+      t.s.logger.Printf("uptime: failed to open %s interval: %v", change.Component, err)
+    }
+  }
+  if _, err := t.s.db.Exec(ctx, `update uptime_intervals set last_seen_at=$1 where ended_at is null`, now); err != nil {
+    t.s.logger.Printf("uptime: heartbeat failed: %v", err)
+  }
+}
+
+type uptimeIncident struct {
+  StartedAt time.Time `json:"started_at"`
+  EndedAt *time.Time `json:"ended_at,omitempty"`
+  DurationSec int64 `json:"duration_sec"`
+  Reason string `json:"reason,omitempty"`
+  Ongoing bool `json:"ongoing"`
+}
+
+type uptimeComponentReport struct {
+  Component string `json:"component"`
+  MonitoredSec int64 `json:"monitored_sec"`
+  UpSec int64 `json:"up_sec"`
+  DownSec int64 `json:"down_sec"`
+  // AvailabilityPct is up time over monitored time; nil when nothing was
+  // monitored in the period.
+  AvailabilityPct *float64 `json:"availability_pct"`
+  IncidentCount int `json:"incident_count"`
+  Incidents []uptimeIncident `json:"incidents"`
+}
+
+// buildUptimeReport clips the intervals to [from, to). Open intervals run
+// to now, the last point anything is known about.
+func buildUptimeReport(intervals []uptimeInterval, from, to, now time.Time) []uptimeComponentReport {
+  byComponent := map[string]*uptimeComponentReport{}
+  reports := make([]uptimeComponentReport, len(uptimeComponents))
+  for i, component := range uptimeComponents {
+    reports[i] = uptimeComponentReport{Component: component, Incidents: []uptimeIncident{}}
+    byComponent[component] = &reports[i]
+  }
+  for _, iv := range intervals {
+    report := byComponent[iv.Component]
+    if report == nil {
+      continue
+    }
+    end := now
+    if iv.EndedAt != nil {
+      end = *iv.EndedAt
+    }
+    start, stop := iv.StartedAt, end
+    if start.Before(from) {
+      start = from
+    }
+    if stop.After(to) {
+      stop = to
+    }
+    if !stop.After(start) {
+      continue
+    }
+    seconds := int64(stop.Sub(start) / time.Second)
+    report.MonitoredSec += seconds
+    if iv.State == uptimeStateUp {
+      report.UpSec += seconds
+      continue
+    }
+    report.DownSec += seconds
+    report.IncidentCount++
+    if len(report.Incidents) < uptimeMaxIncidents {
+      report.Incidents = append(report.Incidents, uptimeIncident{
+        StartedAt: iv.StartedAt,
+        EndedAt: iv.EndedAt,
+        DurationSec: int64(end.Sub(iv.StartedAt) / time.Second),
+        Reason: iv.Reason,
+        Ongoing: iv.EndedAt == nil,
+      })
+    }
+  }
+  for i := range reports {
+    if reports[i].MonitoredSec > 0 {
+      pct := math.Round(float64(reports[i].UpSec)*1e6/float64(reports[i].MonitoredSec)) / 1e4
+      reports[i].AvailabilityPct = &pct
+    }
+    sort.Slice(reports[i].Incidents, func(a, b int) bool {
+      return reports[i].Incidents[a].StartedAt.Before(reports[i].Incidents[b].StartedAt)
+    })
+  }
+  return reports
+}
+
+func (s *Server) uptimeIntervals(ctx context.Context, from, to time.Time) ([]uptimeInterval, error) {
+  rows, err := s.db.Query(ctx, `
+select id, component, state, started_at, last_seen_at, ended_at, reason
+from uptime_intervals
+where started_at < $2 and coalesce(ended_at, now()) > $1
+order by started_at, id`, from, to)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+  items := []uptimeInterval{}
+  for rows.Next() {
+    var iv uptimeInterval
+    var ended pgtype.Timestamptz
+    if err := rows.Scan(&iv.ID, &iv.Component, &iv.State, &iv.StartedAt, &iv.LastSeenAt, &ended, &iv.Reason); err != nil {
+      return nil, err
+    }
+    iv.EndedAt = optionalTime(ended)
+    items = append(items, iv)
+  }
+  return items, rows.Err()
+}
+
+// parseUptimeMonth reads YYYY-MM (UTC), defaulting to the current month.
+func parseUptimeMonth(raw string, now time.Time) (time.Time, error) {
+  raw = strings.TrimSpace(raw)
+  if raw == "" {
+    return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+  }
+  month, err := time.Parse("2006-01", raw)
+  if err != nil {
+    return time.Time{}, errors.New("month must be YYYY-MM")
+  }
+  return month, nil
+}
+
+// handleUptimeReport returns availability and downtime incidents per
+// component for one month (?month=YYYY-MM, UTC).
+func (s *Server) handleUptimeReport(w http.ResponseWriter, r *http.Request) {
+  if s.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "uptime tracking needs postgres")
+    return
+  }
+  now := time.Now().UTC()
+  from, err := parseUptimeMonth(r.URL.Query().Get("month"), now)
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  to := from.AddDate(0, 1, 0)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  intervals, err := s.uptimeIntervals(ctx, from, to)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load uptime: %v", err))
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{
+    "month": from.Format("2006-01"),
+    "from": from,
+    "to": to,
+    "components": buildUptimeReport(intervals, from, to, now),
+  })
+}
+
+// handleUptimeSummary returns the monthly availability of the last months
+// (?months=12, at most 36), newest first, without incident lists.
+func (s *Server) handleUptimeSummary(w http.ResponseWriter, r *http.Request) {
+  if s.db == nil {
+    writeError(w, http.StatusServiceUnavailable, "uptime tracking needs postgres")
+    return
+  }
+  months := 12
+  if raw := strings.TrimSpace(r.URL.Query().Get("months")); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > 36 {
+      writeError(w, http.StatusBadRequest, "months out of range: 1 to 36")
+      return
+    }
+    months = parsed
+  }
+  now := time.Now().UTC()
+  current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+  oldest := current.AddDate(0, -(months - 1), 0)
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  intervals, err := s.uptimeIntervals(ctx, oldest, current.AddDate(0, 1, 0))
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load uptime: %v", err))
+    return
+  }
+
+  type monthSummary struct {
+    Month string `json:"month"`
+    Components []uptimeComponentReport `json:"components"`
+  }
+  items := []monthSummary{}
+  for month := current; !month.Before(oldest); month = month.AddDate(0, -1, 0) {
+    reports := buildUptimeReport(intervals, month, month.AddDate(0, 1, 0), now)
+    for i := range reports {
+      reports[i].Incidents = []uptimeIncident{}
+    }
+    items = append(items, monthSummary{Month: month.Format("2006-01"), Components: reports})
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
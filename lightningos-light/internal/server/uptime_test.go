@@ -0,0 +1,66 @@
+package server
+
+import (
+  "testing"
+  "time"
+)
+
+func TestUptimeTrackerDebounce(t *testing.T) {
+  tracker := &uptimeTracker{current: map[string]string{uptimeComponentManager: uptimeStateUp}, pending: map[string]uptimePending{}}
+  t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+  up := observeUptime(healthResponse{})
+  down := observeUptime(healthResponse{Issues: []healthIssue{
+    {Component: "lnd", Level: "ERR", Message: "LND wallet locked"},
+    {Component: "bitcoin", Level: "WARN", Message: "Bitcoin ZMQ unreachable"},
+  }})
+  if down[uptimeComponentLND].State != uptimeStateDown || down[uptimeComponentBitcoind].State != uptimeStateUp {
+    t.Fatalf("observed %+v", down)
+  }
+
+  if changes := tracker.step(up, t0); len(changes) != 2 {
+    t.Fatalf("first round: %+v", changes)
+  }
+  if changes := tracker.step(down, t0.Add(time.Minute)); len(changes) != 0 {
+    t.Fatalf("single failure recorded: %+v", changes)
+  }
+  if changes := tracker.step(up, t0.Add(2*time.Minute)); len(changes) != 0 {
+    t.Fatalf("recovered blip recorded: %+v", changes)
+  }
+  tracker.step(down, t0.Add(3*time.Minute))
+  changes := tracker.step(down, t0.Add(4*time.Minute))
+  if len(changes) != 1 || changes[0].Component != uptimeComponentLND || !changes[0].Since.Equal(t0.Add(3*time.Minute)) || changes[0].Obs.Reason != "LND wallet locked" {
+    t.Fatalf("outage: %+v", changes)
+  }
+}
+
+func TestBuildUptimeReport(t *testing.T) {
+  from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+  to := from.AddDate(0, 1, 0)
+  at := func(day, hour int) time.Time { return from.AddDate(0, 0, day-1).Add(time.Duration(hour) * time.Hour) }
+  ended := func(tm time.Time) *time.Time { return &tm }
+  intervals := []uptimeInterval{
+    // Starts in August: only the September part counts.
+    {Component: uptimeComponentLND, State: uptimeStateUp, StartedAt: from.Add(-48 * time.Hour), EndedAt: ended(at(10, 0))},
+    {Component: uptimeComponentLND, State: uptimeStateDown, StartedAt: at(10, 0), EndedAt: ended(at(10, 6)), Reason: "LND wallet locked"},
+    {Component: uptimeComponentLND, State: uptimeStateUp, StartedAt: at(10, 6)},
+    {Component: uptimeComponentManager, State: uptimeStateUp, StartedAt: at(20, 0)},
+  }
+  now := at(30, 0)
+  reports := buildUptimeReport(intervals, from, to, now)
+  lnd := reports[1]
+  if lnd.Component != uptimeComponentLND || lnd.DownSec != 6*3600 || lnd.MonitoredSec != 29*24*3600 {
+    t.Fatalf("lnd %+v", lnd)
+  }
+  if lnd.AvailabilityPct == nil || *lnd.AvailabilityPct != 99.1379 {
+    t.Fatalf("availability %v", *lnd.AvailabilityPct)
+  }
+  if lnd.IncidentCount != 1 || lnd.Incidents[0].DurationSec != 6*3600 || lnd.Incidents[0].Ongoing {
+    t.Fatalf("incidents %+v", lnd.Incidents)
+  }
+  if manager := reports[0]; manager.MonitoredSec != 10*24*3600 || *manager.AvailabilityPct != 100 {
+    t.Fatalf("manager %+v", manager)
+  }
+  if bitcoind := reports[2]; bitcoind.AvailabilityPct != nil || len(bitcoind.Incidents) != 0 {
+    t.Fatalf("unmonitored bitcoind %+v", bitcoind)
+  }
+}
@@ -7,8 +7,10 @@ import (
   "strings"
 )
 
+// walletActivityPath is a variable so tests can keep it out of /var/lib.
+var walletActivityPath = "/var/lib/lightningos/wallet-activity.json"
+
 const (
-  walletActivityPath = "/var/lib/lightningos/wallet-activity.json"
   walletActivityLimit = 200
   walletActivityFetchLimit = 1000
 )
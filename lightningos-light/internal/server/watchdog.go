@@ -0,0 +1,206 @@
+package server
+
+import (
+  "context"
+  "sort"
+  "sync"
+  "time"
+)
+
+const (
+  watchdogInterval = 30 * time.Second
+  // Streams can be quiet for a long time on a small node; resubscribing after
+  // an hour of silence is cheap and catches a connection that died without
+  // an error.
+  streamMaxQuiet = time.Hour
+)
+
+// pollerWatch tracks one notifier loop. A loop brackets each connection or
+// poll with begin/done and calls beat whenever it makes progress; while a
+// loop is inside begin and has not beaten for maxLag, the watchdog cancels
+// its context so it reconnects.
+type pollerWatch struct {
+  name string
+  maxLag time.Duration
+
+  mu sync.Mutex
+  lastOK time.Time
+  lastError string
+  restarts int
+  lastRestart time.Time
+  activeSince time.Time
+  cancel context.CancelFunc
+}
+
+type pollerStatus struct {
+  Name string `json:"name"`
+  State string `json:"state"`
+  LastOK *time.Time `json:"last_ok,omitempty"`
+  LagSeconds int64 `json:"lag_seconds"`
+  MaxLagSeconds int64 `json:"max_lag_seconds"`
+  Stalled bool `json:"stalled"`
+  Restarts int `json:"restarts"`
+  LastRestart *time.Time `json:"last_restart,omitempty"`
+  LastError string `json:"last_error,omitempty"`
+}
+
+// begin returns the context for one connection or poll, derived from
+// parent, and the func that ends it.
+func (w *pollerWatch) begin(parent context.Context) (context.Context, func()) {
+  ctx, cancel := context.WithCancel(parent)
+  w.mu.Lock()
+  w.activeSince = time.Now()
+  w.cancel = cancel
+  w.mu.Unlock()
+  return ctx, func() {
+    w.mu.Lock()
+    w.cancel = nil
+    w.mu.Unlock()
+    cancel()
+  }
+}
+
+func (w *pollerWatch) beat() {
+  w.mu.Lock()
+  w.lastOK = time.Now()
+  w.lastError = ""
+  w.mu.Unlock()
+}
+
+func (w *pollerWatch) fail(err error) {
+  if err == nil {
+    return
+  }
+  w.mu.Lock()
+  w.lastError = err.Error()
+  w.mu.Unlock()
+}
+
+// check cancels the loop's current context when it has been active without
+// progress for longer than maxLag. It reports whether it did.
+func (w *pollerWatch) check(now time.Time) bool {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  if w.cancel == nil {
+    return false
+  }
+  since := w.activeSince
+  if w.lastOK.After(since) {
+    since = w.lastOK
+  }
+  if now.Sub(since) < w.maxLag {
+    return false
+  }
+  w.cancel()
+  w.cancel = nil
+  w.restarts++
+  w.lastRestart = now
+  return true
+}
+
+func (w *pollerWatch) status(now time.Time) pollerStatus {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  st := pollerStatus{
+    Name: w.name,
+    State: "idle",
+    MaxLagSeconds: int64(w.maxLag / time.Second),
+    Restarts: w.restarts,
+    LastError: w.lastError,
+  }
+  if w.cancel != nil {
+    st.State = "running"
+  }
+  since := w.activeSince
+  if !w.lastOK.IsZero() {
+    lastOK := w.lastOK.UTC()
+    st.LastOK = &lastOK
+    since = w.lastOK
+  }
+  if !since.IsZero() {
+    st.LagSeconds = int64(now.Sub(since) / time.Second)
+    st.Stalled = now.Sub(since) >= w.maxLag
+  }
+  if !w.lastRestart.IsZero() {
+    lastRestart := w.lastRestart.UTC()
+    st.LastRestart = &lastRestart
+  }
+  return st
+}
+
+type pollerWatchdog struct {
+  mu sync.Mutex
+  watches map[string]*pollerWatch
+}
+
+func newPollerWatchdog() *pollerWatchdog {
+  return &pollerWatchdog{watches: map[string]*pollerWatch{}}
+}
+
+// watch returns the tracker for name, creating it on first use.
+func (d *pollerWatchdog) watch(name string, maxLag time.Duration) *pollerWatch {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  if w, ok := d.watches[name]; ok {
+    return w
+  }
+  w := &pollerWatch{name: name, maxLag: maxLag}
+  d.watches[name] = w
+  return w
+}
+
+// sweep checks every loop and returns the names it restarted.
+func (d *pollerWatchdog) sweep(now time.Time) []string {
+  d.mu.Lock()
+  watches := make([]*pollerWatch, 0, len(d.watches))
+  for _, w := range d.watches {
+    watches = append(watches, w)
+  }
+  d.mu.Unlock()
+  restarted := []string{}
+  for _, w := range watches {
+    if w.check(now) {
+      restarted = append(restarted, w.name)
+    }
+  }
+  sort.Strings(restarted)
+  return restarted
+}
+
+func (d *pollerWatchdog) statuses(now time.Time) []pollerStatus {
+  d.mu.Lock()
+  watches := make([]*pollerWatch, 0, len(d.watches))
+  for _, w := range d.watches {
+    watches = append(watches, w)
+  }
+  d.mu.Unlock()
+  out := make([]pollerStatus, 0, len(watches))
+  for _, w := range watches {
+    out = append(out, w.status(now))
+  }
+  sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+  return out
+}
+
+func (n *Notifier) runWatchdog() {
+  ticker := time.NewTicker(watchdogInterval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-n.stop:
+      return
+    case now := <-ticker.C:
+      for _, name := range n.watchdog.sweep(now) {
+        n.logger.Printf("notifications: %s made no progress, restarting it", name)
+      }
+    }
+  }
+}
+
+// pollerStatuses reports every watched notifier loop for /health.
+func (n *Notifier) pollerStatuses() []pollerStatus {
+  if n == nil || n.watchdog == nil {
+    return nil
+  }
+  return n.watchdog.statuses(time.Now())
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+  "context"
+  "testing"
+  "time"
+)
+
+func TestPollerWatchRestartsStuckLoop(t *testing.T) {
+  d := newPollerWatchdog()
+  w := d.watch("forwards", time.Minute)
+  if d.watch("forwards", time.Hour) != w {
+    t.Fatal("watch must be reused by name")
+  }
+
+  ctx, done := w.begin(context.Background())
+  defer done()
+  now := time.Now()
+  if restarted := d.sweep(now.Add(30 * time.Second)); len(restarted) != 0 {
+    t.Fatalf("restarted too early: %v", restarted)
+  }
+  w.beat()
+  if restarted := d.sweep(time.Now().Add(59 * time.Second)); len(restarted) != 0 {
+    t.Fatalf("beat must reset the lag: %v", restarted)
+  }
+  restarted := d.sweep(time.Now().Add(2 * time.Minute))
+  if len(restarted) != 1 || restarted[0] != "forwards" {
+    t.Fatalf("expected forwards restart, got %v", restarted)
+  }
+  select {
+  case <-ctx.Done():
+  default:
+    t.Fatal("loop context not cancelled")
+  }
+
+  st := w.status(time.Now())
+  if st.Restarts != 1 || st.State != "idle" || st.LastRestart == nil || st.LastOK == nil {
+    t.Fatalf("unexpected status: %+v", st)
+  }
+}
+
+func TestPollerWatchIdleLoopNotRestarted(t *testing.T) {
+  d := newPollerWatchdog()
+  w := d.watch("payments", time.Minute)
+  _, done := w.begin(context.Background())
+  w.beat()
+  done()
+  if restarted := d.sweep(time.Now().Add(time.Hour)); len(restarted) != 0 {
+    t.Fatalf("idle loop restarted: %v", restarted)
+  }
+  st := w.status(time.Now().Add(time.Hour))
+  if !st.Stalled || st.LagSeconds < 3600 {
+    t.Fatalf("expected stalled idle loop, got %+v", st)
+  }
+}
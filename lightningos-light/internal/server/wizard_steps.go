@@ -0,0 +1,497 @@
+package server
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "net"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+
+  "lightningos-light/internal/system"
+)
+
+const wizardStatePath = "/var/lib/lightningos/wizard.json"
+
+// wizardSteps is the onboarding order; the first step that is neither done
+// nor skipped is where the wizard resumes.
+var wizardSteps = []string{"bitcoin", "wallet", "identity", "network", "auth", "backup", "validate"}
+
+// Steps that can be skipped; bitcoin and wallet are required for a node.
+var wizardOptionalSteps = map[string]bool{
+  "identity": true,
+  "network": true,
+  "auth": true,
+  "backup": true,
+}
+
+type wizardStepState struct {
+  Status string `json:"status"`
+  At time.Time `json:"at"`
+}
+
+type wizardState struct {
+  Steps map[string]wizardStepState `json:"steps"`
+}
+
+type wizardStepView struct {
+  ID string `json:"id"`
+  Status string `json:"status"`
+  Optional bool `json:"optional"`
+  At *time.Time `json:"at,omitempty"`
+}
+
+type wizardCheck struct {
+  ID string `json:"id"`
+  Status string `json:"status"`
+  Detail string `json:"detail,omitempty"`
+}
+
+var wizardStateMu sync.Mutex
+
+func readWizardState() wizardState {
+  state := wizardState{Steps: map[string]wizardStepState{}}
+  raw, err := os.ReadFile(wizardStatePath)
+  if err != nil {
+    return state
+  }
+  _ = json.Unmarshal(raw, &state)
+  if state.Steps == nil {
+    state.Steps = map[string]wizardStepState{}
+  }
+  return state
+}
+
+func markWizardStep(step string, status string) error {
+  wizardStateMu.Lock()
+  defer wizardStateMu.Unlock()
+  state := readWizardState()
+  state.Steps[step] = wizardStepState{Status: status, At: time.Now().UTC()}
+  if err := os.MkdirAll(filepath.Dir(wizardStatePath), 0o750); err != nil {
+    return err
+  }
+  raw, err := json.Marshal(state)
+  if err != nil {
+    return err
+  }
+  return writeFileAtomic(wizardStatePath, raw, 0o640)
+}
+
+func wizardStepViews() ([]wizardStepView, string) {
+  wizardStateMu.Lock()
+  state := readWizardState()
+  wizardStateMu.Unlock()
+  views := make([]wizardStepView, 0, len(wizardSteps))
+  current := ""
+  for _, id := range wizardSteps {
+    view := wizardStepView{ID: id, Status: "pending", Optional: wizardOptionalSteps[id]}
+    if stored, ok := state.Steps[id]; ok {
+      view.Status = stored.Status
+      at := stored.At
+      view.At = &at
+    }
+    // Setups done outside the wizard (installer, app store) still count.
+    if view.Status == "pending" {
+      switch id {
+      case "bitcoin":
+        if user, _ := readBitcoinSecrets(); user != "" || readBitcoinSource() == "local" {
+          view.Status = "done"
+        }
+      case "wallet":
+        if walletExists() {
+          view.Status = "done"
+        }
+      }
+    }
+    if current == "" && view.Status == "pending" {
+      current = id
+    }
+    views = append(views, view)
+  }
+  if current == "" {
+    current = "complete"
+  }
+  return views, current
+}
+
+func (s *Server) handleWizardStatus(w http.ResponseWriter, r *http.Request) {
+  steps, current := wizardStepViews()
+  writeJSON(w, http.StatusOK, map[string]any{
+    "wallet_exists": walletExists(),
+    "steps": steps,
+    "current_step": current,
+    "auth_configured": apiAuthConfigured(),
+  })
+}
+
+func (s *Server) handleWizardSkip(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Step string `json:"step"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if !wizardOptionalSteps[req.Step] {
+    writeError(w, http.StatusBadRequest, "step cannot be skipped")
+    return
+  }
+  if err := markWizardStep(req.Step, "skipped"); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store wizard state")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) handleWizardIdentity(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Alias string `json:"alias"`
+    Color string `json:"color"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  alias := strings.TrimSpace(req.Alias)
+  if alias == "" || len(alias) > 32 {
+    writeError(w, http.StatusBadRequest, "alias must be 1 to 32 characters")
+    return
+  }
+  values := map[string]string{"alias": alias}
+  if color := strings.TrimSpace(req.Color); color != "" {
+    if !isHexColor(color) {
+      writeError(w, http.StatusBadRequest, "color must be hex (#RRGGBB)")
+      return
+    }
+    values["color"] = color
+  }
+  if err := updateLNDConfAppOptions(values); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to write lnd.conf")
+    return
+  }
+  if err := markWizardStep("identity", "done"); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store wizard state")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"ok": true, "restart_required": true})
+}
+
+// handleWizardNetwork picks how the node is reachable: tor only, clearnet
+// only, or hybrid (onion plus a clearnet address).
+func (s *Server) handleWizardNetwork(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Mode string `json:"mode"`
+    ExternalIP string `json:"external_ip"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  external := strings.TrimSpace(req.ExternalIP)
+  if external != "" {
+    normalized, err := normalizeExternalAddress(external)
+    if err != nil {
+      writeError(w, http.StatusBadRequest, err.Error())
+      return
+    }
+    external = normalized
+  }
+
+  app := map[string]string{}
+  remove := []string{}
+  var tor map[string]string
+  switch req.Mode {
+  case "tor":
+    app["listen"] = "localhost:9735"
+    remove = append(remove, "externalip")
+    tor = map[string]string{
+      "tor.active": "true",
+      "tor.v3": "true",
+      "tor.streamisolation": "true",
+      "tor.skip-proxy-for-clearnet-targets": "false",
+    }
+  case "hybrid":
+    if external == "" {
+      writeError(w, http.StatusBadRequest, "external_ip required for hybrid mode")
+      return
+    }
+    app["listen"] = "0.0.0.0:9735"
+    app["externalip"] = external
+    // LND refuses stream isolation together with clearnet bypass.
+    tor = map[string]string{
+      "tor.active": "true",
+      "tor.v3": "true",
+      "tor.streamisolation": "false",
+      "tor.skip-proxy-for-clearnet-targets": "true",
+    }
+  case "clearnet":
+    if external == "" {
+      writeError(w, http.StatusBadRequest, "external_ip required for clearnet mode")
+      return
+    }
+    app["listen"] = "0.0.0.0:9735"
+    app["externalip"] = external
+    tor = map[string]string{"tor.active": "false"}
+  default:
+    writeError(w, http.StatusBadRequest, "mode must be tor, hybrid or clearnet")
+    return
+  }
+
+  unlock := lockConfigFile(lndConfPath)
+  raw, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    unlock()
+    writeError(w, http.StatusInternalServerError, "failed to read lnd.conf")
+    return
+  }
+  updated := removeLNDConfOptions(string(raw), remove...)
+  updated = setLNDConfSectionOptions(updated, "Application Options", app)
+  updated = setLNDConfSectionOptions(updated, "tor", tor)
+  err = writeFileAtomic(lndConfPath, []byte(updated), 0660)
+  unlock()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to write lnd.conf")
+    return
+  }
+  if err := markWizardStep("network", "done"); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store wizard state")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"ok": true, "restart_required": true})
+}
+
+func normalizeExternalAddress(value string) (string, error) {
+  host, port, err := net.SplitHostPort(value)
+  if err != nil {
+    host, port = value, "9735"
+  }
+  if strings.TrimSpace(host) == "" || strings.ContainsAny(host, " /") {
+    return "", fmt.Errorf("invalid external_ip")
+  }
+  return net.JoinHostPort(host, port), nil
+}
+
+func updateLNDConfAppOptions(values map[string]string) error {
+  unlock := lockConfigFile(lndConfPath)
+  defer unlock()
+  raw, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    return err
+  }
+  updated := setLNDConfSectionOptions(string(raw), "Application Options", values)
+  return writeFileAtomic(lndConfPath, []byte(updated), 0660)
+}
+
+// handleWizardAuth sets the manager's credentials. Once they are set and the
+// step is done, changing them needs the current password; a client cert,
+// which stands in for the password, can never change them.
+func (s *Server) handleWizardAuth(w http.ResponseWriter, r *http.Request) {
+  if isAutomatedRequest(r) {
+    writeError(w, http.StatusForbidden, "credentials cannot be changed with a client certificate")
+    return
+  }
+  var req struct {
+    Username string `json:"username"`
+    Password string `json:"password"`
+    CurrentPassword string `json:"current_password"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  // Once a password is stored, only someone who knows it may replace it,
+  // whatever the wizard state says.
+  if creds := loadAPICredentials(); creds.hash != "" {
+    ip := clientIP(r)
+    now := time.Now()
+    if apiAuthLimited(ip, now) {
+      writeError(w, http.StatusTooManyRequests, "too many failed logins, try again later")
+      return
+    }
+    if req.CurrentPassword == "" {
+      writeError(w, http.StatusForbidden, "current_password required")
+      return
+    }
+    if !checkAPICredentials(creds, creds.user, req.CurrentPassword) {
+      recordAPIAuthResult(ip, false, now)
+      s.recordAuthFailure(ip, creds.user, now)
+      writeError(w, http.StatusForbidden, "current password is incorrect")
+      return
+    }
+  }
+  if err := storeAPICredentials(req.Username, req.Password); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  if err := markWizardStep("auth", "done"); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store wizard state")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) handleWizardBackup(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    Target string `json:"target"`
+    BotToken string `json:"bot_token"`
+    ChatID string `json:"chat_id"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  switch req.Target {
+  case "telegram":
+    token := strings.TrimSpace(req.BotToken)
+    chatID := strings.TrimSpace(req.ChatID)
+    if token != "" || chatID != "" {
+      if token == "" || chatID == "" {
+        writeError(w, http.StatusBadRequest, "bot_token and chat_id required")
+        return
+      }
+      if err := storeTelegramBackupConfig(token, chatID); err != nil {
+        writeError(w, http.StatusInternalServerError, "failed to store telegram config")
+        return
+      }
+    } else if !readTelegramBackupConfig().configured() {
+      writeError(w, http.StatusBadRequest, "telegram backup is not configured")
+      return
+    }
+  case "local":
+  default:
+    writeError(w, http.StatusBadRequest, "target must be telegram or local")
+    return
+  }
+  if err := writeEnvFileValue(secretsPath, "BACKUP_TARGET", req.Target); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store backup target")
+    return
+  }
+  if err := markWizardStep("backup", "done"); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to store wizard state")
+    return
+  }
+  writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleWizardValidate restarts LND when asked (identity and network changes
+// need it) and runs the end-to-end checks. Failures keep the step pending;
+// warnings do not.
+func (s *Server) handleWizardValidate(w http.ResponseWriter, r *http.Request) {
+  var req struct {
+    RestartLND bool `json:"restart_lnd"`
+  }
+  if err := readJSON(r, &req); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if req.RestartLND {
+    ctx, cancel := s.requestContext(r, timeoutLong)
+    err := system.SystemctlRestart(ctx, "lnd")
+    cancel()
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, "lnd restart failed")
+      return
+    }
+    s.markLNDRestart()
+    writeJSON(w, http.StatusAccepted, map[string]any{"ok": true, "restarting": true})
+    return
+  }
+
+  ctx, cancel := s.requestContext(r, timeoutMedium)
+  defer cancel()
+  checks := s.wizardChecks(ctx)
+  passed := true
+  for _, check := range checks {
+    if check.Status == "fail" {
+      passed = false
+    }
+  }
+  if passed {
+    if err := markWizardStep("validate", "done"); err != nil {
+      writeError(w, http.StatusInternalServerError, "failed to store wizard state")
+      return
+    }
+  }
+  writeJSON(w, http.StatusOK, map[string]any{"passed": passed, "checks": checks})
+}
+
+func (s *Server) wizardChecks(ctx context.Context) []wizardCheck {
+  checks := []wizardCheck{}
+
+  var btc bitcoinStatus
+  var err error
+  if readBitcoinSource() == "local" {
+    btc, err = s.bitcoinLocalStatusActive(ctx)
+  } else {
+    btc, err = s.bitcoinStatus(ctx)
+  }
+  switch {
+  case err != nil || !btc.RPCOk:
+    checks = append(checks, wizardCheck{ID: "bitcoin_rpc", Status: "fail", Detail: "bitcoin RPC unreachable"})
+  case btc.InitialBlockDownload:
+    checks = append(checks, wizardCheck{ID: "bitcoin_rpc", Status: "warn", Detail: "bitcoin is still syncing"})
+  default:
+    checks = append(checks, wizardCheck{ID: "bitcoin_rpc", Status: "ok"})
+  }
+  if btc.ZMQRawBlockOk && btc.ZMQRawTxOk {
+    checks = append(checks, wizardCheck{ID: "bitcoin_zmq", Status: "ok"})
+  } else {
+    detail := strings.TrimSpace(btc.ZMQRawBlockError + " " + btc.ZMQRawTxError)
+    checks = append(checks, wizardCheck{ID: "bitcoin_zmq", Status: "fail", Detail: detail})
+  }
+
+  status, err := s.lnd.GetStatus(ctx)
+  switch {
+  case err != nil || !status.ServiceActive:
+    checks = append(checks, wizardCheck{ID: "lnd", Status: "fail", Detail: "LND is not running"})
+  case status.WalletState != "unlocked":
+    checks = append(checks, wizardCheck{ID: "lnd", Status: "fail", Detail: "wallet is " + status.WalletState})
+  case !status.SyncedToChain:
+    checks = append(checks, wizardCheck{ID: "lnd", Status: "warn", Detail: "LND is not synced to chain yet"})
+  default:
+    checks = append(checks, wizardCheck{ID: "lnd", Status: "ok", Detail: status.Pubkey})
+  }
+
+  if value := lndConfValue("alias"); value != "" {
+    checks = append(checks, wizardCheck{ID: "identity", Status: "ok", Detail: value})
+  } else {
+    checks = append(checks, wizardCheck{ID: "identity", Status: "warn", Detail: "no alias set"})
+  }
+
+  target, _ := readEnvFileValue(secretsPath, "BACKUP_TARGET")
+  switch {
+  case target == "telegram" && !readTelegramBackupConfig().configured():
+    checks = append(checks, wizardCheck{ID: "backup", Status: "fail", Detail: "telegram backup selected but not configured"})
+  case target == "":
+    checks = append(checks, wizardCheck{ID: "backup", Status: "warn", Detail: "no backup target selected"})
+  default:
+    checks = append(checks, wizardCheck{ID: "backup", Status: "ok", Detail: target})
+  }
+
+  if apiAuthConfigured() {
+    checks = append(checks, wizardCheck{ID: "auth", Status: "ok"})
+  } else {
+    checks = append(checks, wizardCheck{ID: "auth", Status: "warn", Detail: "API is open to anyone on the network"})
+  }
+  return checks
+}
+
+// lndConfValue returns the last uncommented value of key in lnd.conf.
+func lndConfValue(key string) string {
+  raw, err := os.ReadFile(lndConfPath)
+  if err != nil {
+    return ""
+  }
+  value := ""
+  for _, line := range strings.Split(string(raw), "\n") {
+    parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+    if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+      value = strings.TrimSpace(parts[1])
+    }
+  }
+  return value
+}
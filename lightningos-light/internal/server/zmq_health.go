@@ -0,0 +1,95 @@
+package server
+
+import (
+  "sync"
+  "time"
+
+  "lightningos-light/internal/zmqprobe"
+)
+
+const (
+  zmqRawTxWindow = 10 * time.Minute
+  zmqRawBlockWindow = 2 * time.Hour
+  zmqMonitorIdle = time.Hour
+)
+
+type zmqMonitorEntry struct {
+  monitor *zmqprobe.Monitor
+  lastUsed time.Time
+}
+
+var (
+  zmqMonitorsMu sync.Mutex
+  zmqMonitors = map[string]*zmqMonitorEntry{}
+)
+
+// zmqStatus returns the subscription status for endpoint/topic, starting a
+// monitor on first use and dropping monitors nobody asked about for an hour
+// (e.g. after switching bitcoin source).
+func zmqStatus(endpoint string, topic string) zmqprobe.Status {
+  key := endpoint + "|" + topic
+  now := time.Now()
+  zmqMonitorsMu.Lock()
+  for k, entry := range zmqMonitors {
+    if k != key && now.Sub(entry.lastUsed) > zmqMonitorIdle {
+      entry.monitor.Stop()
+      delete(zmqMonitors, k)
+    }
+  }
+  entry, ok := zmqMonitors[key]
+  if !ok {
+    entry = &zmqMonitorEntry{monitor: zmqprobe.NewMonitor(endpoint, topic)}
+    entry.monitor.Start()
+    zmqMonitors[key] = entry
+  }
+  entry.lastUsed = now
+  zmqMonitorsMu.Unlock()
+  return entry.monitor.Status()
+}
+
+// zmqWindow is how long a topic may stay silent; only mainnet has enough
+// traffic to treat silence as a fault.
+func zmqWindow(topic string) time.Duration {
+  if !activeNetwork().Mainnet {
+    return 0
+  }
+  if topic == "rawblock" {
+    return zmqRawBlockWindow
+  }
+  return zmqRawTxWindow
+}
+
+func checkZMQ(endpoint string, topic string) (bool, *time.Time, string) {
+  if endpoint == "" {
+    return false, nil, ""
+  }
+  status := zmqStatus(endpoint, topic)
+  var lastAt *time.Time
+  if !status.LastMessageAt.IsZero() {
+    last := status.LastMessageAt.UTC()
+    lastAt = &last
+  }
+  if !status.Connected && status.LastError == "" {
+    // The monitor has not finished its first handshake yet.
+    return testTCP(endpoint), lastAt, ""
+  }
+  now := time.Now()
+  ok := status.Healthy(now, zmqWindow(topic))
+  errMsg := status.LastError
+  if status.Connected && !ok {
+    errMsg = "connected but no " + topic + " messages since " + lastMessageLabel(status)
+  }
+  return ok, lastAt, errMsg
+}
+
+func lastMessageLabel(status zmqprobe.Status) string {
+  if status.LastMessageAt.IsZero() {
+    return status.ConnectedSince.UTC().Format(time.RFC3339) + " (connected)"
+  }
+  return status.LastMessageAt.UTC().Format(time.RFC3339)
+}
+
+func applyZMQStatus(status *bitcoinStatus) {
+  status.ZMQRawBlockOk, status.ZMQRawBlockLastAt, status.ZMQRawBlockError = checkZMQ(status.ZMQRawBlock, "rawblock")
+  status.ZMQRawTxOk, status.ZMQRawTxLastAt, status.ZMQRawTxError = checkZMQ(status.ZMQRawTx, "rawtx")
+}
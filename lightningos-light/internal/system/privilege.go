@@ -0,0 +1,186 @@
+package system
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "os"
+  "os/exec"
+  "strconv"
+  "strings"
+  "sync"
+)
+
+// The manager gets root for its system work one of two ways. In sudo mode
+// (the default) a command that fails is retried with sudo -n under the
+// sudoers rules. In polkit mode the manager never calls sudo: systemctl
+// asks systemd over D-Bus, and polkit rules let the manager's user start,
+// stop and restart its own units and reboot or power off. Everything else
+// that needs root (apps, apt, the firewall, SMART, SSH access) is
+// unavailable then.
+const (
+  PrivilegeSudo = "sudo"
+  PrivilegePolkit = "polkit"
+
+  // PolkitRulesPath is where the polkit rules for the manager go.
+  PolkitRulesPath = "/etc/polkit-1/rules.d/50-lightningos.rules"
+
+  PolkitActionManageUnits = "org.freedesktop.systemd1.manage-units"
+  PolkitActionReboot = "org.freedesktop.login1.reboot"
+  PolkitActionPowerOff = "org.freedesktop.login1.power-off"
+)
+
+var privilegeSetting struct {
+  mu sync.RWMutex
+  mode string
+}
+
+func SetPrivilegeMode(mode string) {
+  mode = strings.ToLower(strings.TrimSpace(mode))
+  if mode != PrivilegePolkit {
+    mode = PrivilegeSudo
+  }
+  privilegeSetting.mu.Lock()
+  privilegeSetting.mode = mode
+  privilegeSetting.mu.Unlock()
+}
+
+func PrivilegeMode() string {
+  privilegeSetting.mu.RLock()
+  defer privilegeSetting.mu.RUnlock()
+  if privilegeSetting.mode == "" {
+    return PrivilegeSudo
+  }
+  return privilegeSetting.mode
+}
+
+func usePolkit() bool {
+  return PrivilegeMode() == PrivilegePolkit && os.Geteuid() != 0
+}
+
+// PrivilegeError means a command needed root while the manager runs
+// unprivileged in polkit mode.
+type PrivilegeError struct {
+  Command string
+}
+
+func (e *PrivilegeError) Error() string {
+  return fmt.Sprintf("%s needs root, which the manager does not have in polkit mode", e.Command)
+}
+
+// PolkitError means polkit refused a systemctl call: the rules in
+// PolkitRulesPath are missing or do not cover it.
+type PolkitError struct {
+  Args []string
+}
+
+func (e *PolkitError) Error() string {
+  return fmt.Sprintf("polkit does not allow \"systemctl %s\"; install the rules from lightningos-manager polkit-rules to %s",
+    strings.Join(e.Args, " "), PolkitRulesPath)
+}
+
+// IsPrivilegeDenied reports whether err is sudo, polkit or the lack of root
+// refusing a command, which no retry will fix.
+func IsPrivilegeDenied(err error) bool {
+  var sudoErr *SudoError
+  var privErr *PrivilegeError
+  var polkitErr *PolkitError
+  return errors.As(err, &sudoErr) || errors.As(err, &privErr) || errors.As(err, &polkitErr)
+}
+
+func isPermissionOutput(out string) bool {
+  lower := strings.ToLower(out)
+  for _, marker := range []string{"permission denied", "operation not permitted", "are you root", "must be root", "must be run as root", "requires root"} {
+    if strings.Contains(lower, marker) {
+      return true
+    }
+  }
+  return false
+}
+
+func isPolkitDenied(out string) bool {
+  return strings.Contains(out, "Access denied") || strings.Contains(out, "Interactive authentication required")
+}
+
+// runSystemctl runs systemctl as the manager and, when that fails, as root:
+// through sudo -n in sudo mode; in polkit mode systemd already asked polkit,
+// so a refusal is reported as such.
+func runSystemctl(ctx context.Context, args ...string) error {
+  systemctl := systemctlPath()
+  out, err := RunCommand(ctx, systemctl, args...)
+  if err == nil {
+    return nil
+  }
+  if usePolkit() {
+    if isPolkitDenied(out) {
+      return &PolkitError{Args: args}
+    }
+    return fmt.Errorf("systemctl %s failed: %s", strings.Join(args, " "), strings.TrimSpace(out))
+  }
+  sudoPath, sudoErr := exec.LookPath("sudo")
+  if sudoErr != nil {
+    return err
+  }
+  sudoOut, sudoErr := RunCommand(ctx, sudoPath, append([]string{"-n", systemctl}, args...)...)
+  if sudoErr == nil {
+    return nil
+  }
+  if isSudoPasswordPrompt(sudoOut) {
+    return &SudoError{Command: systemctl, Args: args}
+  }
+  return fmt.Errorf("systemctl %s failed: %w; sudo %s failed: %v", args[0], err, args[0], sudoErr)
+}
+
+// PolkitAllowed asks polkit whether the manager may perform an action with
+// the given details (unit and verb for manage-units), without performing
+// it.
+func PolkitAllowed(ctx context.Context, actionID string, details map[string]string) (bool, error) {
+  pkcheck, err := exec.LookPath("pkcheck")
+  if err != nil {
+    return false, fmt.Errorf("pkcheck not installed")
+  }
+  args := []string{"--action-id", actionID, "--process", strconv.Itoa(os.Getpid())}
+  for key, value := range details {
+    args = append(args, "--detail", key, value)
+  }
+  cmd := exec.CommandContext(ctx, pkcheck, args...)
+  out, err := cmd.CombinedOutput()
+  if err == nil {
+    return true, nil
+  }
+  var exitErr *exec.ExitError
+  if errors.As(err, &exitErr) {
+    switch exitErr.ExitCode() {
+    case 1, 2, 3:
+      // Not authorized, or only after a password prompt.
+      return false, nil
+    }
+  }
+  return false, fmt.Errorf("pkcheck failed: %s", strings.TrimSpace(string(out)))
+}
+
+// RenderPolkitRules writes the rules that let user manage units (start,
+// stop, restart) and reboot or power off the machine.
+func RenderPolkitRules(user string, units []string) string {
+  quoted := make([]string, 0, len(units))
+  for _, unit := range units {
+    if !strings.Contains(unit, ".") {
+      unit += ".service"
+    }
+    quoted = append(quoted, strconv.Quote(unit))
+  }
+  var b strings.Builder
+  b.WriteString("// Generated by lightningos-manager polkit-rules.\n")
+  b.WriteString("polkit.addRule(function(action, subject) {\n")
+  fmt.Fprintf(&b, "  if (subject.user !== %s) {\n    return polkit.Result.NOT_HANDLED;\n  }\n", strconv.Quote(user))
+  fmt.Fprintf(&b, "  if (action.id === %s) {\n", strconv.Quote(PolkitActionManageUnits))
+  fmt.Fprintf(&b, "    var units = [%s];\n", strings.Join(quoted, ", "))
+  b.WriteString("    var verbs = [\"start\", \"stop\", \"restart\"];\n")
+  b.WriteString("    if (units.indexOf(action.lookup(\"unit\")) >= 0 && verbs.indexOf(action.lookup(\"verb\")) >= 0) {\n")
+  b.WriteString("      return polkit.Result.YES;\n    }\n  }\n")
+  fmt.Fprintf(&b, "  if (action.id === %s || action.id === %s ||\n", strconv.Quote(PolkitActionReboot), strconv.Quote(PolkitActionPowerOff))
+  fmt.Fprintf(&b, "      action.id === %s || action.id === %s) {\n", strconv.Quote(PolkitActionReboot+"-multiple-sessions"), strconv.Quote(PolkitActionPowerOff+"-multiple-sessions"))
+  b.WriteString("    return polkit.Result.YES;\n  }\n")
+  b.WriteString("  return polkit.Result.NOT_HANDLED;\n});\n")
+  return b.String()
+}
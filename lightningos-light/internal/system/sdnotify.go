@@ -0,0 +1,47 @@
+package system
+
+import (
+  "net"
+  "os"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// SdNotify sends state (e.g. "READY=1", "WATCHDOG=1") to the systemd
+// notification socket. It reports false without error when the process was
+// not started by a Type=notify unit.
+func SdNotify(state string) (bool, error) {
+  socket := strings.TrimSpace(os.Getenv("NOTIFY_SOCKET"))
+  if socket == "" {
+    return false, nil
+  }
+  // A leading @ is an abstract socket.
+  if strings.HasPrefix(socket, "@") {
+    socket = "\x00" + socket[1:]
+  }
+  conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+  if err != nil {
+    return false, err
+  }
+  defer conn.Close()
+  if _, err := conn.Write([]byte(state)); err != nil {
+    return false, err
+  }
+  return true, nil
+}
+
+// WatchdogInterval returns the WatchdogSec systemd set for this process, or
+// zero when the watchdog is off or meant for another process.
+func WatchdogInterval() time.Duration {
+  usec, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("WATCHDOG_USEC")), 10, 64)
+  if err != nil || usec <= 0 {
+    return 0
+  }
+  if rawPID := strings.TrimSpace(os.Getenv("WATCHDOG_PID")); rawPID != "" {
+    if pid, err := strconv.Atoi(rawPID); err != nil || pid != os.Getpid() {
+      return 0
+    }
+  }
+  return time.Duration(usec) * time.Microsecond
+}
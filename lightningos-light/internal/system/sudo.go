@@ -0,0 +1,53 @@
+package system
+
+import (
+  "context"
+  "fmt"
+  "os/exec"
+  "strings"
+)
+
+// SudoersPath is where the installers write the manager's sudo rules.
+const SudoersPath = "/etc/sudoers.d/lightningos"
+
+// SudoError means sudo wanted a password: the command is missing from the
+// manager's sudoers rules. Its message names the line to add.
+type SudoError struct {
+  Command string
+  Args []string
+}
+
+func (e *SudoError) Error() string {
+  return fmt.Sprintf("sudo is not allowed to run %q without a password; add a rule for it to %s (lightningos-manager sudo-policy prints them)",
+    strings.Join(append([]string{e.Command}, e.Args...), " "), SudoersPath)
+}
+
+func isSudoPasswordPrompt(out string) bool {
+  return strings.Contains(out, "a password is required") || strings.Contains(out, "a terminal is required")
+}
+
+// ResolveCommand returns the absolute path sudo matches rules against, or
+// the name when it is not on PATH.
+func ResolveCommand(name string) string {
+  if path, err := exec.LookPath(name); err == nil {
+    return path
+  }
+  return name
+}
+
+// SudoAllowed asks sudo whether the command may run without a password
+// (sudo -n -l), without running it.
+func SudoAllowed(ctx context.Context, command string, args ...string) (bool, error) {
+  sudoPath, err := exec.LookPath("sudo")
+  if err != nil {
+    return false, fmt.Errorf("sudo not installed")
+  }
+  out, err := RunCommand(ctx, sudoPath, append([]string{"-n", "-l", command}, args...)...)
+  if err == nil {
+    return true, nil
+  }
+  if isSudoPasswordPrompt(out) || strings.TrimSpace(out) == "" {
+    return false, nil
+  }
+  return false, fmt.Errorf("sudo -l failed: %s", strings.TrimSpace(out))
+}
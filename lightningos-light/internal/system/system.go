@@ -4,6 +4,7 @@ import (
   "bufio"
   "bytes"
   "context"
+  "encoding/json"
   "errors"
   "fmt"
   "os"
@@ -242,6 +243,12 @@ func RunCommandWithSudo(ctx context.Context, name string, args ...string) (strin
   if err == nil {
     return out, nil
   }
+  if usePolkit() {
+    if isPermissionOutput(out) {
+      return out, &PrivilegeError{Command: name}
+    }
+    return out, err
+  }
   sudoPath, sudoErr := exec.LookPath("sudo")
   if sudoErr != nil {
     return out, err
@@ -251,6 +258,9 @@ func RunCommandWithSudo(ctx context.Context, name string, args ...string) (strin
   if sudoErr == nil {
     return sudoOut, nil
   }
+  if isSudoPasswordPrompt(sudoOut) {
+    return sudoOut, &SudoError{Command: ResolveCommand(name), Args: args}
+  }
   return sudoOut, fmt.Errorf("%s failed: %w; sudo failed: %v", name, err, sudoErr)
 }
 
@@ -270,25 +280,28 @@ func SystemctlIsActive(ctx context.Context, service string) bool {
 }
 
 func SystemctlRestart(ctx context.Context, service string) error {
-  systemctl := systemctlPath()
-  _, err := RunCommand(ctx, systemctl, "restart", service)
-  if err == nil {
-    return nil
-  }
-  sudoPath, sudoErr := exec.LookPath("sudo")
-  if sudoErr != nil {
-    return err
-  }
-  if _, sudoErr = RunCommand(ctx, sudoPath, "-n", systemctl, "restart", service); sudoErr == nil {
-    return nil
-  }
-  return fmt.Errorf("systemctl restart failed: %w; sudo restart failed: %v", err, sudoErr)
+  return systemctlAction(ctx, "restart", service)
+}
+
+func SystemctlStop(ctx context.Context, service string) error {
+  return systemctlAction(ctx, "stop", service)
+}
+
+func SystemctlStart(ctx context.Context, service string) error {
+  return systemctlAction(ctx, "start", service)
+}
+
+func systemctlAction(ctx context.Context, action string, service string) error {
+  return runSystemctl(ctx, action, service)
 }
 
 func SystemctlPower(ctx context.Context, action string) error {
   if action != "reboot" && action != "poweroff" {
     return fmt.Errorf("unsupported system action")
   }
+  if usePolkit() {
+    return runSystemctl(ctx, action)
+  }
   systemctl := systemctlPath()
   if _, err := RunCommandWithSudo(ctx, systemctl, action); err != nil {
     return fmt.Errorf("systemctl %s failed: %w", action, err)
@@ -314,3 +327,70 @@ func JournalTail(ctx context.Context, service string, lines int) ([]string, erro
   }
   return trimmed, nil
 }
+
+type JournalEntry struct {
+  Time time.Time `json:"time"`
+  Message string `json:"message"`
+}
+
+// JournalRange returns up to max journal entries of a unit logged in
+// [since, until), oldest first. When there are more, the latest ones win.
+// A zero since or until leaves that side open.
+func JournalRange(ctx context.Context, service string, since, until time.Time, max int) ([]JournalEntry, error) {
+  if max <= 0 {
+    max = 1000
+  }
+  const layout = "2006-01-02 15:04:05"
+  args := []string{"-u", service, "--utc"}
+  if !since.IsZero() {
+    args = append(args, "--since", since.UTC().Format(layout))
+  }
+  if !until.IsZero() {
+    args = append(args, "--until", until.UTC().Format(layout))
+  }
+  args = append(args, "-n", strconv.Itoa(max), "-o", "json", "--output-fields=MESSAGE", "--no-pager")
+  out, err := RunCommand(ctx, "journalctl", args...)
+  if err != nil {
+    return nil, err
+  }
+  var entries []JournalEntry
+  scanner := bufio.NewScanner(strings.NewReader(out))
+  scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+  for scanner.Scan() {
+    var raw struct {
+      Realtime string `json:"__REALTIME_TIMESTAMP"`
+      Message json.RawMessage `json:"MESSAGE"`
+    }
+    if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+      continue
+    }
+    usec, err := strconv.ParseInt(raw.Realtime, 10, 64)
+    if err != nil {
+      continue
+    }
+    message, ok := journalMessage(raw.Message)
+    if !ok || strings.TrimSpace(message) == "" {
+      continue
+    }
+    entries = append(entries, JournalEntry{Time: time.UnixMicro(usec).UTC(), Message: message})
+  }
+  return entries, scanner.Err()
+}
+
+// journalMessage reads MESSAGE, which journalctl prints as an array of bytes
+// when it is not valid UTF-8.
+func journalMessage(raw json.RawMessage) (string, bool) {
+  var text string
+  if err := json.Unmarshal(raw, &text); err == nil {
+    return text, true
+  }
+  var data []byte
+  var ints []int
+  if err := json.Unmarshal(raw, &ints); err != nil {
+    return "", false
+  }
+  for _, b := range ints {
+    data = append(data, byte(b))
+  }
+  return strings.ToValidUTF8(string(data), "?"), true
+}
@@ -0,0 +1,139 @@
+package zmqprobe
+
+import (
+  "context"
+  "sync"
+  "time"
+)
+
+const (
+  dialTimeout = 3 * time.Second
+  retryDelay = 15 * time.Second
+)
+
+// Status is what a Monitor has observed on one endpoint/topic.
+type Status struct {
+  Endpoint string
+  Topic string
+  Connected bool
+  ConnectedSince time.Time
+  LastMessageAt time.Time
+  Messages int64
+  LastError string
+}
+
+// Healthy reports whether the publisher handshakes and, once window has
+// elapsed since connecting, delivered a message within window. A zero window
+// only requires the handshake.
+func (s Status) Healthy(now time.Time, window time.Duration) bool {
+  if !s.Connected {
+    return false
+  }
+  if window <= 0 || now.Sub(s.ConnectedSince) < window {
+    return true
+  }
+  return now.Sub(s.LastMessageAt) <= window
+}
+
+// Monitor keeps a subscription open and records when messages arrive,
+// reconnecting after errors.
+type Monitor struct {
+  endpoint string
+  topic string
+
+  mu sync.Mutex
+  status Status
+  stop chan struct{}
+}
+
+func NewMonitor(endpoint string, topic string) *Monitor {
+  return &Monitor{
+    endpoint: endpoint,
+    topic: topic,
+    status: Status{Endpoint: endpoint, Topic: topic},
+  }
+}
+
+func (m *Monitor) Start() {
+  m.mu.Lock()
+  if m.stop != nil {
+    m.mu.Unlock()
+    return
+  }
+  m.stop = make(chan struct{})
+  stop := m.stop
+  m.mu.Unlock()
+  go m.run(stop)
+}
+
+func (m *Monitor) Stop() {
+  m.mu.Lock()
+  if m.stop != nil {
+    close(m.stop)
+    m.stop = nil
+  }
+  m.mu.Unlock()
+}
+
+func (m *Monitor) Status() Status {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  return m.status
+}
+
+func (m *Monitor) run(stop chan struct{}) {
+  for {
+    m.session(stop)
+    select {
+    case <-stop:
+      return
+    case <-time.After(retryDelay):
+    }
+  }
+}
+
+func (m *Monitor) session(stop chan struct{}) {
+  ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+  sub, err := Dial(ctx, m.endpoint, m.topic)
+  cancel()
+  if err != nil {
+    m.mu.Lock()
+    m.status.Connected = false
+    m.status.LastError = err.Error()
+    m.mu.Unlock()
+    return
+  }
+  done := make(chan struct{})
+  defer close(done)
+  go func() {
+    select {
+    case <-stop:
+    case <-done:
+    }
+    _ = sub.Close()
+  }()
+
+  m.mu.Lock()
+  m.status.Connected = true
+  m.status.ConnectedSince = time.Now()
+  m.status.LastError = ""
+  m.mu.Unlock()
+
+  for {
+    msg, err := sub.Next(time.Time{})
+    if err != nil {
+      m.mu.Lock()
+      m.status.Connected = false
+      m.status.LastError = err.Error()
+      m.mu.Unlock()
+      return
+    }
+    if msg.Topic != m.topic {
+      continue
+    }
+    m.mu.Lock()
+    m.status.LastMessageAt = time.Now()
+    m.status.Messages++
+    m.mu.Unlock()
+  }
+}
@@ -0,0 +1,232 @@
+// Package zmqprobe speaks just enough ZMTP 3.0 (NULL mechanism, SUB socket)
+// to subscribe to bitcoind's ZMQ publishers and observe their messages.
+package zmqprobe
+
+import (
+  "bytes"
+  "context"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "io"
+  "net"
+  "strings"
+  "time"
+)
+
+const (
+  flagMore = 0x01
+  flagLong = 0x02
+  flagCommand = 0x04
+
+  maxFrameSize = 64 << 20
+)
+
+var ErrNotZMQ = errors.New("peer did not answer with a ZMTP 3 greeting")
+
+// Message is one multipart publication, e.g. rawtx, payload and sequence.
+type Message struct {
+  Topic string
+  Parts [][]byte
+}
+
+// Subscriber is a connected SUB socket.
+type Subscriber struct {
+  conn net.Conn
+  SocketType string
+}
+
+// Dial connects to a tcp:// endpoint, performs the ZMTP handshake and
+// subscribes to topic.
+func Dial(ctx context.Context, endpoint string, topic string) (*Subscriber, error) {
+  addr := strings.TrimPrefix(strings.TrimSpace(endpoint), "tcp://")
+  if addr == "" || strings.Contains(addr, "://") {
+    return nil, fmt.Errorf("invalid zmq endpoint %q", endpoint)
+  }
+  var d net.Dialer
+  conn, err := d.DialContext(ctx, "tcp", addr)
+  if err != nil {
+    return nil, err
+  }
+  if deadline, ok := ctx.Deadline(); ok {
+    _ = conn.SetDeadline(deadline)
+  }
+  sub, err := handshake(conn, topic)
+  if err != nil {
+    _ = conn.Close()
+    return nil, err
+  }
+  _ = conn.SetDeadline(time.Time{})
+  return sub, nil
+}
+
+func handshake(conn net.Conn, topic string) (*Subscriber, error) {
+  if _, err := conn.Write(greeting()); err != nil {
+    return nil, err
+  }
+  peer := make([]byte, 64)
+  if _, err := io.ReadFull(conn, peer); err != nil {
+    return nil, ErrNotZMQ
+  }
+  if peer[0] != 0xff || peer[9] != 0x7f || peer[10] < 3 {
+    return nil, ErrNotZMQ
+  }
+  if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+    return nil, fmt.Errorf("unsupported zmq mechanism %q", mechanism)
+  }
+
+  if err := writeFrame(conn, flagCommand, readyCommand("SUB")); err != nil {
+    return nil, err
+  }
+  flags, body, err := readFrame(conn)
+  if err != nil {
+    return nil, err
+  }
+  if flags&flagCommand == 0 {
+    return nil, errors.New("expected READY command from peer")
+  }
+  name, props, err := parseCommand(body)
+  if err != nil {
+    return nil, err
+  }
+  if name == "ERROR" {
+    return nil, fmt.Errorf("zmq peer rejected handshake")
+  }
+  if name != "READY" {
+    return nil, fmt.Errorf("unexpected zmq command %q", name)
+  }
+  socketType := props["Socket-Type"]
+  if socketType != "PUB" && socketType != "XPUB" {
+    return nil, fmt.Errorf("zmq peer is a %s socket, not a publisher", socketType)
+  }
+
+  // ZMTP 3.0 subscriptions are plain messages prefixed with 0x01.
+  if err := writeFrame(conn, 0, append([]byte{0x01}, topic...)); err != nil {
+    return nil, err
+  }
+  return &Subscriber{conn: conn, SocketType: socketType}, nil
+}
+
+// Next blocks until a full message arrives or the deadline passes.
+func (s *Subscriber) Next(deadline time.Time) (Message, error) {
+  _ = s.conn.SetReadDeadline(deadline)
+  var parts [][]byte
+  for {
+    flags, body, err := readFrame(s.conn)
+    if err != nil {
+      return Message{}, err
+    }
+    if flags&flagCommand != 0 {
+      continue
+    }
+    parts = append(parts, body)
+    if flags&flagMore == 0 {
+      break
+    }
+  }
+  msg := Message{Parts: parts}
+  if len(parts) > 0 {
+    msg.Topic = string(parts[0])
+  }
+  return msg, nil
+}
+
+func (s *Subscriber) Close() error {
+  return s.conn.Close()
+}
+
+func greeting() []byte {
+  g := make([]byte, 64)
+  g[0] = 0xff
+  g[9] = 0x7f
+  g[10] = 3
+  g[11] = 0
+  copy(g[12:32], "NULL")
+  return g
+}
+
+func readyCommand(socketType string) []byte {
+  var buf bytes.Buffer
+  buf.WriteByte(byte(len("READY")))
+  buf.WriteString("READY")
+  buf.WriteByte(byte(len("Socket-Type")))
+  buf.WriteString("Socket-Type")
+  var size [4]byte
+  binary.BigEndian.PutUint32(size[:], uint32(len(socketType)))
+  buf.Write(size[:])
+  buf.WriteString(socketType)
+  return buf.Bytes()
+}
+
+func parseCommand(body []byte) (string, map[string]string, error) {
+  if len(body) < 1 || len(body) < 1+int(body[0]) {
+    return "", nil, errors.New("malformed zmq command")
+  }
+  nameLen := int(body[0])
+  name := string(body[1 : 1+nameLen])
+  props := map[string]string{}
+  rest := body[1+nameLen:]
+  if name != "READY" {
+    return name, props, nil
+  }
+  for len(rest) > 0 {
+    keyLen := int(rest[0])
+    if len(rest) < 1+keyLen+4 {
+      return "", nil, errors.New("malformed zmq READY property")
+    }
+    key := string(rest[1 : 1+keyLen])
+    valueLen := int(binary.BigEndian.Uint32(rest[1+keyLen:]))
+    rest = rest[1+keyLen+4:]
+    if len(rest) < valueLen {
+      return "", nil, errors.New("malformed zmq READY property")
+    }
+    props[key] = string(rest[:valueLen])
+    rest = rest[valueLen:]
+  }
+  return name, props, nil
+}
+
+func writeFrame(w io.Writer, flags byte, body []byte) error {
+  var header []byte
+  if len(body) > 255 {
+    header = make([]byte, 9)
+    header[0] = flags | flagLong
+    binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+  } else {
+    header = []byte{flags, byte(len(body))}
+  }
+  if _, err := w.Write(append(header, body...)); err != nil {
+    return err
+  }
+  return nil
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+  var head [1]byte
+  if _, err := io.ReadFull(r, head[:]); err != nil {
+    return 0, nil, err
+  }
+  flags := head[0]
+  var size uint64
+  if flags&flagLong != 0 {
+    var raw [8]byte
+    if _, err := io.ReadFull(r, raw[:]); err != nil {
+      return 0, nil, err
+    }
+    size = binary.BigEndian.Uint64(raw[:])
+  } else {
+    var raw [1]byte
+    if _, err := io.ReadFull(r, raw[:]); err != nil {
+      return 0, nil, err
+    }
+    size = uint64(raw[0])
+  }
+  if size > maxFrameSize {
+    return 0, nil, fmt.Errorf("zmq frame too large (%d bytes)", size)
+  }
+  body := make([]byte, size)
+  if _, err := io.ReadFull(r, body); err != nil {
+    return 0, nil, err
+  }
+  return flags, body, nil
+}
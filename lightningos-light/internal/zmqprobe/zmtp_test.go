@@ -0,0 +1,114 @@
+package zmqprobe
+
+import (
+  "context"
+  "io"
+  "net"
+  "testing"
+  "time"
+)
+
+// fakePublisher accepts one SUB connection, checks the subscription and
+// publishes a bitcoind-style three part message.
+func fakePublisher(t *testing.T, socketType string, payload []byte) string {
+  t.Helper()
+  ln, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatalf("listen: %v", err)
+  }
+  t.Cleanup(func() { _ = ln.Close() })
+  go func() {
+    conn, err := ln.Accept()
+    if err != nil {
+      return
+    }
+    defer conn.Close()
+    peer := make([]byte, 64)
+    if _, err := io.ReadFull(conn, peer); err != nil {
+      return
+    }
+    _, _ = conn.Write(greeting())
+    if _, _, err := readFrame(conn); err != nil {
+      return
+    }
+    _ = writeFrame(conn, flagCommand, readyCommand(socketType))
+    _, sub, err := readFrame(conn)
+    if err != nil || len(sub) == 0 || sub[0] != 0x01 {
+      return
+    }
+    topic := sub[1:]
+    _ = writeFrame(conn, flagMore, topic)
+    _ = writeFrame(conn, flagMore, payload)
+    _ = writeFrame(conn, 0, []byte{1, 0, 0, 0})
+    time.Sleep(200 * time.Millisecond)
+  }()
+  return "tcp://" + ln.Addr().String()
+}
+
+func TestSubscriberReceivesMessage(t *testing.T) {
+  payload := make([]byte, 1000)
+  endpoint := fakePublisher(t, "PUB", payload)
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  defer cancel()
+  sub, err := Dial(ctx, endpoint, "rawtx")
+  if err != nil {
+    t.Fatalf("dial: %v", err)
+  }
+  defer sub.Close()
+  msg, err := sub.Next(time.Now().Add(2 * time.Second))
+  if err != nil {
+    t.Fatalf("next: %v", err)
+  }
+  if msg.Topic != "rawtx" || len(msg.Parts) != 3 || len(msg.Parts[1]) != len(payload) {
+    t.Fatalf("unexpected message: topic=%q parts=%d", msg.Topic, len(msg.Parts))
+  }
+}
+
+func TestDialRejectsNonPublisher(t *testing.T) {
+  endpoint := fakePublisher(t, "REP", nil)
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  defer cancel()
+  if _, err := Dial(ctx, endpoint, "rawblock"); err == nil {
+    t.Fatalf("expected error for REP socket")
+  }
+}
+
+func TestDialRejectsNonZMQ(t *testing.T) {
+  ln, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatalf("listen: %v", err)
+  }
+  defer ln.Close()
+  go func() {
+    conn, err := ln.Accept()
+    if err != nil {
+      return
+    }
+    _, _ = io.ReadFull(conn, make([]byte, 64))
+    _, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+    _ = conn.Close()
+  }()
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  defer cancel()
+  if _, err := Dial(ctx, "tcp://"+ln.Addr().String(), "rawblock"); err != ErrNotZMQ {
+    t.Fatalf("expected ErrNotZMQ, got %v", err)
+  }
+}
+
+func TestStatusHealthy(t *testing.T) {
+  now := time.Now()
+  status := Status{Connected: true, ConnectedSince: now.Add(-time.Hour)}
+  if status.Healthy(now, 10*time.Minute) {
+    t.Fatalf("silent publisher should be unhealthy")
+  }
+  status.LastMessageAt = now.Add(-time.Minute)
+  if !status.Healthy(now, 10*time.Minute) {
+    t.Fatalf("recent message should be healthy")
+  }
+  if !(Status{Connected: true, ConnectedSince: now}).Healthy(now, 10*time.Minute) {
+    t.Fatalf("fresh connection should be healthy until the window passes")
+  }
+  if (Status{}).Healthy(now, 0) {
+    t.Fatalf("disconnected should be unhealthy")
+  }
+}
@@ -0,0 +1,95 @@
+#!/usr/bin/env bash
+set -Eeuo pipefail
+
+# Manages SSH access for the node's operator user on behalf of the manager:
+#   list <user>               print the user's authorized_keys
+#   add <user> <key>          append a public key (one authorized_keys line)
+#   remove <user> <blob>      remove the keys with this base64 key blob
+#   password-auth yes|no      allow or refuse password logins
+#   status                    print sshd's effective passwordauthentication
+
+# sshd keeps the first value it reads, so the drop-in sorts ahead of others
+# (50-cloud-init.conf sets PasswordAuthentication on some images).
+SSHD_DROPIN="/etc/ssh/sshd_config.d/10-lightningos.conf"
+
+die() {
+  echo "$*" >&2
+  exit 1
+}
+
+user_home() {
+  local user="$1"
+  [[ "$user" =~ ^[a-z_][a-z0-9_-]*$ ]] || die "invalid user"
+  [[ "$user" != "root" ]] || die "refusing to manage root"
+  local home
+  home=$(getent passwd "$user" | cut -d: -f6)
+  [[ -n "$home" && -d "$home" ]] || die "user $user has no home directory"
+  echo "$home"
+}
+
+keys_file() {
+  local user="$1"
+  local home
+  home=$(user_home "$user")
+  local dir="$home/.ssh"
+  if [[ ! -d "$dir" ]]; then
+    install -d -m 700 -o "$user" -g "$(id -gn "$user")" "$dir"
+  fi
+  local file="$dir/authorized_keys"
+  if [[ ! -f "$file" ]]; then
+    install -m 600 -o "$user" -g "$(id -gn "$user")" /dev/null "$file"
+  fi
+  echo "$file"
+}
+
+reload_sshd() {
+  local sshd
+  sshd=$(command -v sshd || echo /usr/sbin/sshd)
+  "$sshd" -t || die "sshd rejected the configuration"
+  systemctl reload ssh 2>/dev/null || systemctl reload sshd
+}
+
+# Without root, fail so the manager retries through sudo.
+[[ $EUID -eq 0 ]] || die "must be run as root"
+
+cmd="${1:-}"
+case "$cmd" in
+  list)
+    [[ $# -eq 2 ]] || die "usage: list <user>"
+    cat "$(keys_file "$2")"
+    ;;
+  add)
+    [[ $# -eq 3 ]] || die "usage: add <user> <key>"
+    key="$3"
+    [[ "$key" != *$'\n'* ]] || die "key must be one line"
+    file=$(keys_file "$2")
+    printf '%s\n' "$key" >> "$file"
+    ;;
+  remove)
+    [[ $# -eq 3 ]] || die "usage: remove <user> <blob>"
+    file=$(keys_file "$2")
+    tmp=$(mktemp "${file}.XXXXXX")
+    awk -v blob="$3" '{ for (i = 1; i <= NF; i++) if ($i == blob) next; print }' "$file" > "$tmp"
+    chown --reference="$file" "$tmp"
+    chmod 600 "$tmp"
+    mv "$tmp" "$file"
+    ;;
+  password-auth)
+    [[ $# -eq 2 && ( "$2" == "yes" || "$2" == "no" ) ]] || die "usage: password-auth yes|no"
+    mkdir -p "$(dirname "$SSHD_DROPIN")"
+    cat > "$SSHD_DROPIN" <<EOF
+# Managed by LightningOS (SSH access in the dashboard).
+PasswordAuthentication $2
+KbdInteractiveAuthentication $2
+EOF
+    chmod 644 "$SSHD_DROPIN"
+    reload_sshd
+    ;;
+  status)
+    sshd=$(command -v sshd || echo /usr/sbin/sshd)
+    "$sshd" -T 2>/dev/null | grep -i '^passwordauthentication ' || echo "passwordauthentication yes"
+    ;;
+  *)
+    die "usage: $0 list|add|remove|password-auth|status"
+    ;;
+esac
@@ -0,0 +1,22 @@
+//go:build embedui
+
+// Package ui exposes the built web UI (ui/dist) to the manager binary. Build
+// the UI first, then compile with -tags embedui.
+package ui
+
+import (
+  "embed"
+  "io/fs"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// Assets returns the embedded UI, rooted at dist.
+func Assets() fs.FS {
+  sub, err := fs.Sub(dist, "dist")
+  if err != nil {
+    return nil
+  }
+  return sub
+}
@@ -0,0 +1,11 @@
+//go:build !embedui
+
+package ui
+
+import "io/fs"
+
+// Assets returns nil when the binary was built without -tags embedui; the
+// manager then serves ui.static_dir from disk.
+func Assets() fs.FS {
+  return nil
+}
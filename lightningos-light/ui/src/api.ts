@@ -1,10 +1,16 @@
-const base = ''
+import i18n from './i18n'
 
-async function request(path: string, options?: RequestInit) {
+// The UI uses hash routing, so the page path is always the manager's base
+// path ('' or e.g. '/node' behind a reverse proxy).
+export const apiBase = window.location.pathname.replace(/\/[^/]*$/, '')
+const base = apiBase
+
+async function send(path: string, options?: RequestInit) {
   const res = await fetch(`${base}${path}`, {
     ...options,
     headers: {
       'Content-Type': 'application/json',
+      'Accept-Language': i18n.language || 'en',
       ...(options?.headers || {})
     }
   })
@@ -23,10 +29,19 @@ async function request(path: string, options?: RequestInit) {
     }
     throw new Error('Request failed')
   }
+  return res
+}
+
+async function request(path: string, options?: RequestInit) {
+  const res = await send(path, options)
   if (res.status === 204) return null
   return res.json()
 }
 
+// Sensitive actions need a TOTP code once two-factor auth is enabled.
+const totpHeaders = (totpCode?: string): Record<string, string> =>
+  totpCode ? { 'X-TOTP-Code': totpCode } : {}
+
 const buildQuery = (params?: Record<string, string | number | boolean | undefined | null>) => {
   if (!params) return ''
   const query = Object.entries(params)
@@ -36,18 +51,78 @@ const buildQuery = (params?: Record<string, string | number | boolean | undefine
   return query ? `?${query}` : ''
 }
 
+export const getJobs = (params?: { kind?: string; limit?: number }) => request(`/api/jobs${buildQuery(params)}`)
+export const getJob = (id: string) => request(`/api/jobs/${encodeURIComponent(id)}`)
+export const cancelJob = (id: string) => request(`/api/jobs/${encodeURIComponent(id)}`, { method: 'DELETE' })
+
+// waitForJob polls a job started by an endpoint that answered 202 until it
+// finishes; failed and interrupted jobs are thrown as errors.
+export const waitForJob = async (job: any, onProgress?: (job: any) => void) => {
+  let current = job
+  while (current?.state === 'running') {
+    onProgress?.(current)
+    await new Promise((resolve) => setTimeout(resolve, 2000))
+    current = await getJob(current.id)
+  }
+  if (current?.state === 'failed' || current?.state === 'interrupted') {
+    throw new Error(current?.error || 'Job failed')
+  }
+  return current
+}
+
 export const getHealth = () => request('/api/health')
 export const getAmbossHealth = () => request('/api/amboss/health')
 export const updateAmbossHealth = (payload: { enabled: boolean }) =>
   request('/api/amboss/health', { method: 'POST', body: JSON.stringify(payload) })
+export const getPublicStatus = () => request('/api/public/status')
+export const getUptimeSummary = (months = 12) => request(`/api/uptime?months=${months}`)
+export const getUptimeReport = (month?: string) =>
+  request(`/api/uptime/report${month ? `?month=${encodeURIComponent(month)}` : ''}`)
+export const getIncidents = (days = 7) => request(`/api/incidents?days=${days}`)
+export const getIncidentTimeline = (from: string, to?: string) =>
+  request(`/api/incidents/timeline?from=${encodeURIComponent(from)}${to ? `&to=${encodeURIComponent(to)}` : ''}`)
+export const getPrivacyMode = () => request('/api/privacy')
+export const setPrivacyMode = (enabled: boolean) =>
+  request('/api/privacy', { method: 'POST', body: JSON.stringify({ enabled }) })
+export const getPreferences = () => request('/api/preferences')
+export const updatePreferences = (payload: {
+  unit?: 'sats' | 'btc'
+  fiat_currency?: string
+  date_format?: 'iso' | 'dmy' | 'mdy'
+  timezone?: string
+}) => request('/api/preferences', { method: 'POST', body: JSON.stringify(payload) })
 export const getSystem = () => request('/api/system')
+export const getSystemTasks = () => request('/api/system/tasks')
+export const getSSHAccess = () => request('/api/system/ssh')
+export const addSSHKey = (key: string, totpCode?: string) =>
+  request('/api/system/ssh/keys', { method: 'POST', body: JSON.stringify({ key }), headers: totpHeaders(totpCode) })
+export const removeSSHKey = (fingerprint: string, totpCode?: string) =>
+  request(`/api/system/ssh/keys${buildQuery({ fingerprint })}`, { method: 'DELETE', headers: totpHeaders(totpCode) })
+export const setSSHPasswordAuth = (enabled: boolean, totpCode?: string) =>
+  request('/api/system/ssh/password-auth', { method: 'POST', body: JSON.stringify({ enabled }), headers: totpHeaders(totpCode) })
+export const getFirewall = () => request('/api/firewall')
+export const updateFirewallRule = (
+  payload: { action: 'allow' | 'deny' | 'delete'; port: number; proto?: 'tcp' | 'udp'; from?: string },
+  totpCode?: string
+) => request('/api/firewall/rules', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const applyFirewallProfile = (payload: { profile: 'lan_only'; enable?: boolean }, totpCode?: string) =>
+  request('/api/firewall/profile', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const getIntrusions = (hours?: number) => request(`/api/security/intrusions${buildQuery({ hours })}`)
+export const blockIntrusionAddress = (payload: { ip: string; minutes?: number }, totpCode?: string) =>
+  request('/api/security/intrusions/blocks', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const unblockIntrusionAddress = (ip: string, totpCode?: string) =>
+  request(`/api/security/intrusions/blocks/${encodeURIComponent(ip)}`, { method: 'DELETE', headers: totpHeaders(totpCode) })
 export const getDisk = () => request('/api/disk')
 export const getPostgres = () => request('/api/postgres')
 export const getBitcoin = () => request('/api/bitcoin')
 export const getBitcoinActive = () => request('/api/bitcoin/active')
+export const getBitcoinTip = () => request('/api/bitcoin/tip')
+export const getBitcoinSync = (hours?: number) => request(`/api/bitcoin/sync${buildQuery({ hours })}`)
 export const getBitcoinSource = () => request('/api/bitcoin/source')
 export const setBitcoinSource = (payload: { source: 'local' | 'remote' }) =>
   request('/api/bitcoin/source', { method: 'POST', body: JSON.stringify(payload) })
+export const generateBitcoinRPCAuth = (payload: { user: string; password?: string }) =>
+  request('/api/bitcoin/rpcauth', { method: 'POST', body: JSON.stringify(payload) })
 export const getBitcoinLocalStatus = () => request('/api/bitcoin-local/status')
 export const getBitcoinLocalConfig = () => request('/api/bitcoin-local/config')
 export const updateBitcoinLocalConfig = (payload: {
@@ -61,6 +136,8 @@ export const setElementsMainchain = (payload: { source: 'local' | 'remote' }) =>
   request('/api/elements/mainchain', { method: 'POST', body: JSON.stringify(payload) })
 export const getLndStatus = () => request('/api/lnd/status')
 export const getLndConfig = () => request('/api/lnd/config')
+export const getLndRescan = () => request('/api/lnd/rescan')
+export const startLndRescan = () => request('/api/lnd/rescan', { method: 'POST' })
 export const getWizardStatus = () => request('/api/wizard/status')
 
 export const postBitcoinRemote = (payload: { rpcuser: string; rpcpass: string }) =>
@@ -75,14 +152,39 @@ export const initWallet = (payload: { wallet_password: string; seed_words: strin
 export const unlockWallet = (payload: { wallet_password: string }) =>
   request('/api/wizard/lnd/unlock', { method: 'POST', body: JSON.stringify(payload) })
 
+export const restoreSCBEscrow = (
+  payload: { url: string; user?: string; password?: string; passphrase: string; dry_run?: boolean },
+  totpCode?: string
+) => request('/api/wizard/scb-restore', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+
 export const restartService = (payload: { service: string }) =>
   request('/api/actions/restart', { method: 'POST', body: JSON.stringify(payload) })
 
-export const runSystemAction = (payload: { action: 'reboot' | 'shutdown' }) =>
-  request('/api/actions/system', { method: 'POST', body: JSON.stringify(payload) })
+export const runSystemAction = (payload: { action: 'reboot' | 'shutdown' }, totpCode?: string) =>
+  request('/api/actions/system', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+
+export const getTOTPStatus = () => request('/api/auth/totp')
+export const enrollTOTP = () => request('/api/auth/totp/enroll', { method: 'POST' })
+export const verifyTOTP = (payload: { code: string }) =>
+  request('/api/auth/totp/verify', { method: 'POST', body: JSON.stringify(payload) })
+export const disableTOTP = (payload: { code: string }) =>
+  request('/api/auth/totp/disable', { method: 'POST', body: JSON.stringify(payload) })
 
 export const getLogs = (service: string, lines: number) =>
   request(`/api/logs?service=${service}&lines=${lines}`)
+type LogSearchParams = {
+  services: string[]
+  q?: string
+  re?: string
+  since?: string
+  until?: string
+  level?: string
+  lines?: number
+}
+export const searchLogs = ({ services, ...params }: LogSearchParams) =>
+  request(`/api/logs${buildQuery({ services: services.join(','), ...params })}`)
+export const logDownloadUrl = ({ services, ...params }: Omit<LogSearchParams, 'lines'>) =>
+  `${base}/api/logs/download${buildQuery({ services: services.join(','), ...params })}`
 
 export const updateLndConfig = (payload: {
   alias: string
@@ -92,24 +194,104 @@ export const updateLndConfig = (payload: {
   apply_now: boolean
 }) => request('/api/lnd/config', { method: 'POST', body: JSON.stringify(payload) })
 
-export const updateLndRawConfig = (payload: { raw_user_conf: string; apply_now: boolean }) =>
-  request('/api/lnd/config/raw', { method: 'POST', body: JSON.stringify(payload) })
+export const updateLndRawConfig = (payload: { raw_user_conf: string; apply_now: boolean }, totpCode?: string) =>
+  request('/api/lnd/config/raw', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
 
+export type NetworkInfo = {
+  network: string
+  rpc_port: number
+  mempool_url?: string
+  explorer_tx_url?: string
+  explorer_address_url?: string
+  explorer_node_url?: string
+  mainnet: boolean
+}
+export const getNetwork = (): Promise<NetworkInfo> => request('/api/network')
 export const getMempoolFees = () => request('/api/mempool/fees')
+export const getMempoolStatus = () => request('/api/mempool/status')
+export const getBackupsStatus = () => request('/api/backups/status')
+// The secrets export is encrypted with the passphrase; decrypt it with
+// lightningos-manager secrets-decrypt.
+export const downloadBackupSecrets = async (passphrase: string, totpCode?: string) => {
+  const res = await send('/api/backups/secrets', { method: 'POST', body: JSON.stringify({ passphrase }), headers: totpHeaders(totpCode) })
+  return res.blob()
+}
+export const getSCBEscrow = () => request('/api/backups/scb-escrow')
+export const updateSCBEscrow = (
+  payload: { enabled: boolean; url?: string; user?: string; password?: string; passphrase?: string },
+  totpCode?: string
+) => request('/api/backups/scb-escrow', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const pushSCBEscrow = () => request('/api/backups/scb-escrow/push', { method: 'POST' })
+export const getPrice = (currency?: string) => request(`/api/price${buildQuery({ currency })}`)
+export const getPriceHistory = (params?: { currency?: string; days?: number }) =>
+  request(`/api/price/history${buildQuery(params)}`)
 
 export const getWalletSummary = () => request('/api/wallet/summary')
+export const getWalletPayments = (params?: { status?: 'succeeded' | 'failed' | 'in_flight'; limit?: number; cursor?: string }) =>
+  request(`/api/wallet/payments${buildQuery(params)}`)
 export const getWalletAddress = () => request('/api/wallet/address', { method: 'POST' })
-export const sendOnchain = (payload: { address: string; amount_sat?: number; sat_per_vbyte?: number; sweep_all?: boolean }) =>
-  request('/api/wallet/send', { method: 'POST', body: JSON.stringify(payload) })
+export const getWalletDescriptors = () => request('/api/wallet/descriptors')
+export const createProofOfReserves = (payload: { message: string; min_confs?: number }) =>
+  request('/api/wallet/proof-of-reserves', { method: 'POST', body: JSON.stringify(payload) })
+export const sendOnchain = (payload: { address: string; amount_sat?: number; sat_per_vbyte?: number; sweep_all?: boolean; allow_high_fee?: boolean }, totpCode?: string) =>
+  request('/api/wallet/send', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
 export const createInvoice = (payload: { amount_sat: number; memo: string }) =>
   request('/api/wallet/invoice', { method: 'POST', body: JSON.stringify(payload) })
+export const getPOSRegisters = () => request('/api/pos/registers')
+export const createPOSRegister = (payload: { name: string; node_id?: string; max_amount_sat?: number }, totpCode?: string) =>
+  request('/api/pos/registers', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const rotatePOSRegister = (id: string, totpCode?: string) =>
+  request(`/api/pos/registers/${encodeURIComponent(id)}/rotate`, { method: 'POST', headers: totpHeaders(totpCode) })
+export const deletePOSRegister = (id: string) =>
+  request(`/api/pos/registers/${encodeURIComponent(id)}`, { method: 'DELETE' })
+export const getPaymentAllowlist = () => request('/api/payments/allowlist')
+export const addPaymentAllowlist = (payload: { kind: 'lightning_address' | 'node' | 'onchain'; value: string; label?: string }, totpCode?: string) =>
+  request('/api/payments/allowlist', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const deletePaymentAllowlist = (id: string, totpCode?: string) =>
+  request(`/api/payments/allowlist/${encodeURIComponent(id)}`, { method: 'DELETE', headers: totpHeaders(totpCode) })
+export const getScheduledPayments = () => request('/api/payments/scheduled')
+export const createScheduledPayment = (payload: {
+  name: string
+  node_id?: string
+  kind: 'lightning_address' | 'keysend'
+  target: string
+  amount_sat: number
+  every: 'weekly' | 'monthly'
+  message?: string
+  max_total_sat?: number
+  start_at?: string
+}, totpCode?: string) => request('/api/payments/scheduled', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const updateScheduledPayment = (id: number, payload: { enabled?: boolean; max_total_sat?: number }, totpCode?: string) =>
+  request(`/api/payments/scheduled/${id}`, { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const deleteScheduledPayment = (id: number) =>
+  request(`/api/payments/scheduled/${id}`, { method: 'DELETE' })
+export const runScheduledPayment = (id: number, totpCode?: string) =>
+  request(`/api/payments/scheduled/${id}/run`, { method: 'POST', headers: totpHeaders(totpCode) })
+export const getScheduledPaymentRuns = (id?: number, limit?: number) =>
+  request(`/api/payments/scheduled${id ? `/${id}` : ''}/runs${limit ? `?limit=${limit}` : ''}`)
+export const createPaymentRequest = (payload: {
+  amount_sat: number
+  memo?: string
+  expiry_seconds?: number
+  format?: 'svg' | 'png'
+}) => request('/api/wallet/payment-request', { method: 'POST', body: JSON.stringify(payload) })
 export const decodeInvoice = (payload: { payment_request: string }) =>
   request('/api/wallet/decode', { method: 'POST', body: JSON.stringify(payload) })
-export const payInvoice = (payload: { payment_request: string; channel_point?: string; amount_sat?: number }) =>
-  request('/api/wallet/pay', { method: 'POST', body: JSON.stringify(payload) })
+export const payInvoice = (payload: { payment_request: string; channel_point?: string; amount_sat?: number }, totpCode?: string) =>
+  request('/api/wallet/pay', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
 
-export const getLnChannels = () => request('/api/lnops/channels')
-export const getLnPeers = () => request('/api/lnops/peers')
+export const getLnChannels = (tag?: string) =>
+  request(`/api/lnops/channels${tag ? `?tag=${encodeURIComponent(tag)}` : ''}`)
+export const getLnPeers = (tag?: string) =>
+  request(`/api/lnops/peers${tag ? `?tag=${encodeURIComponent(tag)}` : ''}`)
+export const getPeerMetadata = () => request('/api/peers/metadata')
+export const savePeerMetadata = (pubkey: string, payload: { notes?: string; tags?: string[]; target_local_pct?: number }) =>
+  request(`/api/peers/metadata/${encodeURIComponent(pubkey)}`, { method: 'POST', body: JSON.stringify(payload) })
+export const getPeerBandwidth = (params?: { days?: number; pubkey?: string }) =>
+  request(`/api/peers/bandwidth${buildQuery(params)}`)
+export const getPeerBandwidthMonthly = (months = 12) => request(`/api/peers/bandwidth/monthly?months=${months}`)
+export const getPeerLatency = (pubkey: string, hours = 24) =>
+  request(`/api/peers/latency${buildQuery({ pubkey, hours })}`)
 export const getLnChannelFees = (channelPoint: string) =>
   request(`/api/lnops/channel/fees?channel_point=${encodeURIComponent(channelPoint)}`)
 export const connectPeer = (payload: { address?: string; pubkey?: string; host?: string; perm?: boolean }) =>
@@ -118,15 +300,105 @@ export const disconnectPeer = (payload: { pubkey: string }) =>
   request('/api/lnops/peer/disconnect', { method: 'POST', body: JSON.stringify(payload) })
 export const boostPeers = (payload?: { limit?: number }) =>
   request('/api/lnops/peers/boost', { method: 'POST', body: JSON.stringify(payload ?? {}) })
+export const getForwardFailures = (hours?: number, tag?: string) => {
+  const params = new URLSearchParams()
+  if (hours) params.set('hours', String(hours))
+  if (tag) params.set('tag', tag)
+  const query = params.toString()
+  return request(`/api/lnops/forward-failures${query ? `?${query}` : ''}`)
+}
+export const getForwards = (params?: { start?: string | number; end?: string | number; peer?: string; chan_id?: string; limit?: number; cursor?: string }) =>
+  request(`/api/ln/forwards${buildQuery(params)}`)
+export const startRebalance = (payload: { outgoing_chan_id: number; incoming_chan_id: number; amount_sat: number; max_fee_ppm: number }, totpCode?: string) =>
+  request('/api/ln/rebalance', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const getRebalanceJobs = (limit?: number) => request(`/api/ln/rebalance/jobs${buildQuery({ limit })}`)
+export const getRebalanceJob = (id: string) => request(`/api/ln/rebalance/jobs/${encodeURIComponent(id)}`)
+export const getLiquidity = (days?: number) =>
+  request(`/api/lnops/liquidity${days !== undefined ? `?days=${days}` : ''}`)
+export const getLNCapacity = (maxParts?: number) =>
+  request(`/api/lnops/capacity${maxParts !== undefined ? `?max_parts=${maxParts}` : ''}`)
 export const openChannel = (payload: {
   peer_address: string
   local_funding_sat: number
   close_address?: string
   sat_per_vbyte?: number
   private?: boolean
-}) => request('/api/lnops/channel/open', { method: 'POST', body: JSON.stringify(payload) })
-export const closeChannel = (payload: { channel_point: string; force?: boolean; sat_per_vbyte?: number }) =>
-  request('/api/lnops/channel/close', { method: 'POST', body: JSON.stringify(payload) })
+  allow_high_fee?: boolean
+}, totpCode?: string) => request('/api/lnops/channel/open', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const previewChannelOpen = (payload: { local_funding_sat: number; sat_per_vbyte?: number }) =>
+  request('/api/lnops/channel/open/preview', { method: 'POST', body: JSON.stringify(payload) })
+export type ChannelFeeProfile = {
+  base_fee_msat: number
+  fee_rate_ppm: number
+  time_lock_delta?: number
+  inbound_enabled?: boolean
+  inbound_base_msat?: number
+  inbound_fee_rate_ppm?: number
+}
+export type ChannelTemplatePayload = {
+  id?: string
+  name: string
+  local_funding_sat: number
+  private?: boolean
+  sat_per_vbyte?: number
+  close_address?: string
+  fee_profile?: ChannelFeeProfile
+}
+export const getChannelTemplates = () => request('/api/lnops/channel/templates')
+export const saveChannelTemplate = (payload: ChannelTemplatePayload) =>
+  request('/api/lnops/channel/templates', { method: 'POST', body: JSON.stringify(payload) })
+export const deleteChannelTemplate = (id: string) =>
+  request(`/api/lnops/channel/templates/${encodeURIComponent(id)}`, { method: 'DELETE' })
+export const openChannelFromTemplate = (id: string, payload: { peer_address: string; local_funding_sat?: number; sat_per_vbyte?: number; allow_high_fee?: boolean }, totpCode?: string) =>
+  request(`/api/lnops/channel/templates/${encodeURIComponent(id)}/open`, { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export type ChannelGroupPayload = {
+  id?: string
+  name: string
+  purpose?: string
+  tags?: string[]
+  peers?: string[]
+  channels?: string[]
+}
+export const getChannelGroups = () => request('/api/lnops/groups')
+export const saveChannelGroup = (payload: ChannelGroupPayload) =>
+  request('/api/lnops/groups', { method: 'POST', body: JSON.stringify(payload) })
+export const deleteChannelGroup = (id: string) =>
+  request(`/api/lnops/groups/${encodeURIComponent(id)}`, { method: 'DELETE' })
+export const getChannelGroupStats = (days = 30) => request(`/api/lnops/groups/stats?days=${days}`)
+export const reopenChannel = (payload: {
+  channel_point: string
+  template_id?: string
+  peer_address?: string
+  local_funding_sat?: number
+  sat_per_vbyte?: number
+  private?: boolean
+  close_address?: string
+  allow_high_fee?: boolean
+}, totpCode?: string) => request('/api/lnops/channel/reopen', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const getSpliceCapability = () => request('/api/lnops/splice')
+export const spliceChannel = (payload: {
+  channel_point: string
+  direction: 'in' | 'out'
+  amount_sat: number
+  address?: string
+  sat_per_vbyte?: number
+  allow_high_fee?: boolean
+}, totpCode?: string) => request('/api/lnops/channel/splice', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const closeChannel = async (payload: {
+  channel_point: string
+  force?: boolean
+  sat_per_vbyte?: number
+  target_conf?: number
+  delivery_address?: string
+  max_fee_per_vbyte?: number
+  wait_for_txid?: boolean
+  allow_high_fee?: boolean
+}, totpCode?: string) => {
+  // The close job runs until the closing transaction confirms; a running
+  // job means LND accepted the close, so only a finished one is waited on.
+  const job = await request('/api/lnops/channel/close', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+  return job?.state === 'running' ? job : waitForJob(job)
+}
 export const updateChannelFees = (payload: {
   channel_point?: string
   apply_all?: boolean
@@ -136,7 +408,22 @@ export const updateChannelFees = (payload: {
   inbound_enabled?: boolean
   inbound_base_msat?: number
   inbound_fee_rate_ppm?: number
-}) => request('/api/lnops/channel/fees', { method: 'POST', body: JSON.stringify(payload) })
+}, totpCode?: string) => request('/api/lnops/channel/fees', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const previewChannelFees = (payload: {
+  channel_point: string
+  base_fee_msat?: number
+  fee_rate_ppm?: number
+  time_lock_delta?: number
+  inbound_enabled?: boolean
+  inbound_base_msat?: number
+  inbound_fee_rate_ppm?: number
+  days?: number
+}) => request('/api/lnops/channel/fees/preview', { method: 'POST', body: JSON.stringify(payload) })
+export const getInboundExperiments = () => request('/api/lnops/inbound-experiments')
+export const startInboundExperiment = (totpCode?: string) =>
+  request('/api/lnops/inbound-experiments/start', { method: 'POST', headers: totpHeaders(totpCode) })
+export const stopInboundExperiment = () =>
+  request('/api/lnops/inbound-experiments/stop', { method: 'POST' })
 
 export const getChatMessages = (peerPubkey: string, limit = 200) =>
   request(`/api/chat/messages?peer_pubkey=${encodeURIComponent(peerPubkey)}&limit=${limit}`)
@@ -150,6 +437,112 @@ export const sendChatMessage = (payload: { peer_pubkey: string; message: string
 export const getNotifications = (limit = 200) =>
   request(`/api/notifications?limit=${limit}`)
 
+export const getNotificationsSince = (sinceId: number, limit = 1000) =>
+  request(`/api/notifications?since_id=${sinceId}&limit=${limit}`)
+
+export const getActivityAnnotations = () => request('/api/activity/annotations')
+export const saveActivityAnnotation = (payload: { kind: 'notification' | 'payment' | 'transaction'; ref: string; note: string }) =>
+  request('/api/activity/annotations', { method: 'POST', body: JSON.stringify(payload) })
+export type JournalEntryPayload = {
+  id?: number
+  occurred_at?: string
+  direction: 'in' | 'out'
+  amount_sat: number
+  fee_sat?: number
+  category?: string
+  memo: string
+}
+export const getJournal = (from?: string, to?: string) => {
+  const params = new URLSearchParams()
+  if (from) params.set('from', from)
+  if (to) params.set('to', to)
+  const query = params.toString()
+  return request(`/api/activity/journal${query ? `?${query}` : ''}`)
+}
+export const saveJournalEntry = (payload: JournalEntryPayload) =>
+  request('/api/activity/journal', { method: 'POST', body: JSON.stringify(payload) })
+export const deleteJournalEntry = (id: number) =>
+  request(`/api/activity/journal/${id}`, { method: 'DELETE' })
+export const activityExportUrl = (from: string, to: string, format: 'csv' | 'json' = 'csv') =>
+  `/api/activity/export?from=${from}&to=${to}&format=${format}`
+
+export type PushTopicPayload = {
+  id?: string
+  name?: string
+  provider?: 'ntfy' | 'unifiedpush'
+  server?: string
+  topic?: string
+  endpoint?: string
+  token?: string
+  clear_token?: boolean
+  enabled?: boolean
+  critical_only?: boolean
+  types?: string[]
+  min_amount_sat?: number
+}
+
+export const getPushTopics = () =>
+  request('/api/notifications/push')
+
+export const savePushTopic = (payload: PushTopicPayload) =>
+  request('/api/notifications/push', { method: 'POST', body: JSON.stringify(payload) })
+
+export const deletePushTopic = (id: string) =>
+  request(`/api/notifications/push/${encodeURIComponent(id)}`, { method: 'DELETE' })
+
+export const testPushTopic = (id: string) =>
+  request(`/api/notifications/push/${encodeURIComponent(id)}/test`, { method: 'POST' })
+
+export type AlertAction = {
+  type: 'notify' | 'webhook' | 'job'
+  url?: string
+  job_kind?: string
+  job_params?: unknown
+}
+
+export type AlertRulePayload = {
+  name?: string
+  enabled?: boolean
+  metric: string
+  operator: '>' | '>=' | '<' | '<=' | '==' | '!='
+  threshold: number
+  for_seconds?: number
+  notification_type?: string
+  window_seconds?: number
+  actions: AlertAction[]
+}
+
+export const getAlertMetrics = () =>
+  request('/api/alerts/metrics')
+
+export const getAlertRules = () =>
+  request('/api/alerts/rules')
+
+export const createAlertRule = (payload: AlertRulePayload) =>
+  request('/api/alerts/rules', { method: 'POST', body: JSON.stringify(payload) })
+
+export const deleteAlertRule = (id: number) =>
+  request(`/api/alerts/rules/${id}`, { method: 'DELETE' })
+
+export type MQTTConfigPayload = {
+  enabled?: boolean
+  broker?: string
+  username?: string
+  password?: string
+  topic_prefix?: string
+  discovery_prefix?: string
+  tls_insecure?: boolean
+}
+
+export const getMQTTConfig = () =>
+  request('/api/mqtt')
+
+export const updateMQTTConfig = (payload: MQTTConfigPayload, totpCode?: string) =>
+  request('/api/mqtt', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+
+export const testMQTT = () =>
+  request('/api/mqtt/test', { method: 'POST' })
+
 export const getTelegramBackupConfig = () =>
   request('/api/notifications/backup/telegram')
 
@@ -160,6 +553,17 @@ export const testTelegramBackup = () =>
   request('/api/notifications/backup/telegram/test', { method: 'POST' })
 
 export const getTerminalStatus = () => request('/api/terminal/status')
+export const getCrashes = (limit?: number) => request(`/api/crashes${limit ? `?limit=${limit}` : ''}`)
+export const getConsole = () => request('/api/console')
+export const updateConsoleConfig = (payload: { enabled?: boolean; pin?: string; current_pin?: string }, totpCode?: string) =>
+  request('/api/console/config', { method: 'POST', body: JSON.stringify(payload), headers: totpHeaders(totpCode) })
+export const runConsoleCommand = (payload: {
+  command: string
+  pin: string
+  service?: string
+  pattern?: string
+  lines?: number
+}) => request('/api/console/run', { method: 'POST', body: JSON.stringify(payload) })
 
 export const getOnchainUtxos = (params?: {
   min_conf?: number
@@ -175,13 +579,17 @@ export const getOnchainTransactions = (params?: {
   limit?: number
 }) => request(`/api/onchain/transactions${buildQuery(params)}`)
 
-export const getReportsRange = (range: string) =>
-  request(`/api/reports/range?range=${encodeURIComponent(range)}`)
-export const getReportsCustom = (from: string, to: string) =>
-  request(`/api/reports/custom?from=${encodeURIComponent(from)}&to=${encodeURIComponent(to)}`)
-export const getReportsSummary = (range: string) =>
-  request(`/api/reports/summary?range=${encodeURIComponent(range)}`)
+export const getReportsRange = (range: string, fiat?: string) =>
+  request(`/api/reports/range${buildQuery({ range, fiat })}`)
+export const getReportsCustom = (from: string, to: string, fiat?: string) =>
+  request(`/api/reports/custom${buildQuery({ from, to, fiat })}`)
+export const getReportsSummary = (range: string, fiat?: string) =>
+  request(`/api/reports/summary${buildQuery({ range, fiat })}`)
 export const getReportsLive = () => request('/api/reports/live')
+export const getReportsConsolidated = (range: string) =>
+  request(`/api/reports/consolidated${buildQuery({ range })}`)
+export const importLNDgReports = async (days?: number) =>
+  waitForJob(await request('/api/reports/lndg-import', { method: 'POST', body: JSON.stringify({ days }) }))
 export const getReportsConfig = () => request('/api/reports/config')
 export const updateReportsConfig = (payload: {
   live_timeout_sec?: number | null
@@ -190,9 +598,16 @@ export const updateReportsConfig = (payload: {
 }) => request('/api/reports/config', { method: 'POST', body: JSON.stringify(payload) })
 
 export const getApps = () => request('/api/apps')
-export const getAppAdminPassword = (id: string) => request(`/api/apps/${id}/admin-password`)
-export const installApp = (id: string) => request(`/api/apps/${id}/install`, { method: 'POST' })
-export const uninstallApp = (id: string) => request(`/api/apps/${id}/uninstall`, { method: 'POST' })
+export const getAppsCatalog = (refresh = false) => request(`/api/apps/catalog${refresh ? '?refresh=1' : ''}`)
+export const getAppAdminPassword = (id: string, totpCode?: string) =>
+  request(`/api/apps/${id}/admin-password`, { headers: totpHeaders(totpCode) })
+export const installApp = async (id: string) =>
+  waitForJob(await request(`/api/apps/${id}/install`, { method: 'POST' }))
+export const uninstallApp = async (id: string) =>
+  waitForJob(await request(`/api/apps/${id}/uninstall`, { method: 'POST' }))
 export const startApp = (id: string) => request(`/api/apps/${id}/start`, { method: 'POST' })
 export const stopApp = (id: string) => request(`/api/apps/${id}/stop`, { method: 'POST' })
-export const resetAppAdmin = (id: string) => request(`/api/apps/${id}/reset-admin`, { method: 'POST' })
+export const setAppRestartPolicy = (id: string, payload: { auto_restart: boolean; max_retries?: number; backoff_sec?: number }) =>
+  request(`/api/apps/${id}/restart-policy`, { method: 'POST', body: JSON.stringify(payload) })
+export const resetAppAdmin = (id: string, totpCode?: string) =>
+  request(`/api/apps/${id}/reset-admin`, { method: 'POST', headers: totpHeaders(totpCode) })
@@ -49,7 +49,7 @@ export default function Sidebar({ routes, allRoutes, menuConfig, onMenuConfigCha
 
   useEffect(() => {
     let active = true
-    fetch('/version.txt', { cache: 'no-store' })
+    fetch('version.txt', { cache: 'no-store' })
       .then((res) => (res.ok ? res.text() : ''))
       .then((text) => {
         if (!active) return
@@ -1,6 +1,6 @@
 import { useEffect, useState } from 'react'
 import { useTranslation } from 'react-i18next'
-import { getBitcoinActive, getDisk, getLndStatus, getPostgres, getSystem, restartService, runSystemAction } from '../api'
+import { getBitcoinActive, getDisk, getLndStatus, getPostgres, getSystem, restartService, runSystemAction, waitForJob } from '../api'
 import { getLocale } from '../i18n'
 
 export default function Dashboard() {
@@ -19,6 +19,7 @@ export default function Dashboard() {
   const [systemAction, setSystemAction] = useState<'restart' | 'shutdown' | null>(null)
   const [systemActionBusy, setSystemActionBusy] = useState(false)
   const [systemActionError, setSystemActionError] = useState<string | null>(null)
+  const [systemActionStep, setSystemActionStep] = useState('')
 
   const wearWarnThreshold = 75
   const tempWarnThreshold = 70
@@ -152,13 +153,24 @@ export default function Dashboard() {
     if (!systemAction) return
     setSystemActionBusy(true)
     setSystemActionError(null)
+    let step = ''
     try {
-      await runSystemAction({ action: systemAction === 'restart' ? 'reboot' : 'shutdown' })
+      const started = await runSystemAction({ action: systemAction === 'restart' ? 'reboot' : 'shutdown' })
+      try {
+        await waitForJob(started, (job) => {
+          step = job?.progress?.step || ''
+          setSystemActionStep(step)
+        })
+      } catch (err) {
+        // The manager goes down with the node once the power action is sent.
+        if (step !== 'powering_off') throw err
+      }
       setSystemAction(null)
     } catch (err) {
       setSystemActionError(err instanceof Error ? err.message : t('common.fail'))
     } finally {
       setSystemActionBusy(false)
+      setSystemActionStep('')
     }
   }
 
@@ -472,6 +484,9 @@ export default function Dashboard() {
           >
             <h4 id="system-action-title" className="text-lg font-semibold">{systemActionTitle}</h4>
             <p className="mt-2 text-sm text-fog/70">{systemActionBody}</p>
+            {systemActionStep && (
+              <p className="mt-3 text-sm text-brass">{t(`dashboard.powerSteps.${systemActionStep}`)}</p>
+            )}
             {systemActionError && (
               <p className="mt-3 text-sm text-rose-200">{systemActionError}</p>
             )}
@@ -1,6 +1,7 @@
 import { useEffect, useMemo, useState } from 'react'
 import { useTranslation } from 'react-i18next'
-import { boostPeers, closeChannel, connectPeer, disconnectPeer, getAmbossHealth, getLnChannelFees, getLnChannels, getLnPeers, getMempoolFees, openChannel, updateAmbossHealth, updateChannelFees } from '../api'
+import { boostPeers, cancelJob, closeChannel, connectPeer, disconnectPeer, getAmbossHealth, getLnChannelFees, getLnChannels, getLnPeers, getMempoolFees, openChannel, updateAmbossHealth, updateChannelFees, waitForJob } from '../api'
+import { useExplorer } from '../utils/explorer'
 
 type Channel = {
   channel_point: string
@@ -60,6 +61,7 @@ type AmbossHealthStatus = {
 
 export default function LightningOps() {
   const { t } = useTranslation()
+  const { outpointUrl } = useExplorer()
   const [channels, setChannels] = useState<Channel[]>([])
   const [activeCount, setActiveCount] = useState(0)
   const [inactiveCount, setInactiveCount] = useState(0)
@@ -78,6 +80,7 @@ export default function LightningOps() {
   const [peerTemporary, setPeerTemporary] = useState(false)
   const [peerStatus, setPeerStatus] = useState('')
   const [boostStatus, setBoostStatus] = useState('')
+  const [boostJobId, setBoostJobId] = useState('')
   const [boostRunning, setBoostRunning] = useState(false)
   const [peers, setPeers] = useState<Peer[]>([])
   const [peerListStatus, setPeerListStatus] = useState('')
@@ -397,16 +400,35 @@ export default function LightningOps() {
     setBoostRunning(true)
     setBoostStatus(t('lightningOps.boostingPeers'))
     try {
-      const res = await boostPeers({ limit: 25 })
-      const connected = res?.connected ?? 0
-      const skipped = res?.skipped ?? 0
-      const failed = res?.failed ?? 0
-      setBoostStatus(t('lightningOps.boostComplete', { connected, skipped, failed }))
+      const started = await boostPeers({ limit: 25 })
+      setBoostJobId(started?.id || '')
+      const job = await waitForJob(started, (current) => {
+        setBoostStatus(t('lightningOps.boostProgress', { done: current?.done ?? 0, total: current?.total ?? 0 }))
+      })
+      const counts = job?.result ?? job?.progress ?? {}
+      const connected = counts?.connected ?? 0
+      const skipped = counts?.skipped ?? 0
+      const failed = counts?.failed ?? 0
+      if (job?.state === 'cancelled') {
+        setBoostStatus(t('lightningOps.boostCancelled', { connected, skipped, failed }))
+      } else {
+        setBoostStatus(t('lightningOps.boostComplete', { connected, skipped, failed }))
+      }
       load()
     } catch (err: any) {
       setBoostStatus(err?.message || t('lightningOps.boostFailed'))
     } finally {
       setBoostRunning(false)
+      setBoostJobId('')
+    }
+  }
+
+  const handleCancelBoost = async () => {
+    if (!boostJobId) return
+    try {
+      await cancelJob(boostJobId)
+    } catch (err: any) {
+      setBoostStatus(err?.message || t('lightningOps.boostFailed'))
     }
   }
 
@@ -457,11 +479,7 @@ export default function LightningOps() {
     }
   }
 
-  const mempoolLink = (channelPoint: string) => {
-    const parts = channelPoint.split(':')
-    if (parts.length !== 2) return ''
-    return `https://mempool.space/pt/tx/${parts[0]}#vout=${parts[1]}`
-  }
+  const mempoolLink = (channelPoint: string) => outpointUrl(channelPoint)
 
   const handleCloseChannel = async () => {
     setCloseStatus(t('lightningOps.closingChannel'))
@@ -785,6 +803,9 @@ export default function LightningOps() {
             >
               {boostRunning ? t('lightningOps.boosting') : t('lightningOps.boostPeers')}
             </button>
+            {boostRunning && boostJobId && (
+              <button className="btn-secondary" onClick={handleCancelBoost}>{t('lightningOps.boostCancel')}</button>
+            )}
           </div>
           {peerStatus && <p className="text-sm text-brass">{peerStatus}</p>}
           {boostStatus && <p className="text-sm text-brass">{boostStatus}</p>}
@@ -1,7 +1,8 @@
 import { useEffect, useMemo, useRef, useState } from 'react'
 import { useTranslation } from 'react-i18next'
-import { getNotifications, getTelegramBackupConfig, testTelegramBackup, updateTelegramBackupConfig } from '../api'
+import { apiBase, getNotifications, getNotificationsSince, getTelegramBackupConfig, testTelegramBackup, updateTelegramBackupConfig } from '../api'
 import { getLocale } from '../i18n'
+import { useExplorer } from '../utils/explorer'
 
 type Notification = {
   id: number
@@ -68,20 +69,9 @@ const formatFeeRate = (amount: number, feeSat: number, feeMsat?: number) => {
   return `${percent}% ${ppm}ppm`
 }
 
-const mempoolLinkFromChannelPoint = (channelPoint?: string) => {
-  if (!channelPoint) return ''
-  const parts = channelPoint.split(':')
-  if (parts.length !== 2) return ''
-  return `https://mempool.space/pt/tx/${parts[0]}#vout=${parts[1]}`
-}
-
-const mempoolTxLink = (txid?: string) => {
-  if (!txid) return ''
-  return `https://mempool.space/tx/${txid}`
-}
-
 export default function Notifications() {
   const { t, i18n } = useTranslation()
+  const { txUrl: mempoolTxLink, outpointUrl: mempoolLinkFromChannelPoint } = useExplorer()
   const locale = getLocale(i18n.language)
   const limitOptions = [200, 500, 1000]
 
@@ -154,6 +144,7 @@ export default function Notifications() {
   const [filter, setFilter] = useState<'all' | 'onchain' | 'lightning' | 'keysend' | 'channel' | 'forward' | 'rebalance'>('all')
   const [limit, setLimit] = useState(200)
   const limitRef = useRef(limit)
+  const lastIdRef = useRef(0)
   const [telegramConfig, setTelegramConfig] = useState<TelegramBackupConfig | null>(null)
   const [telegramToken, setTelegramToken] = useState('')
   const [telegramChatId, setTelegramChatId] = useState('')
@@ -173,7 +164,9 @@ export default function Notifications() {
       try {
         const res = await getNotifications(limit)
         if (!mounted) return
-        setItems(Array.isArray(res?.items) ? res.items : [])
+        const loaded: Notification[] = Array.isArray(res?.items) ? res.items : []
+        lastIdRef.current = loaded.reduce((max, item) => Math.max(max, item.id), lastIdRef.current)
+        setItems(loaded)
         setStatus('')
       } catch (err: any) {
         if (!mounted) return
@@ -202,13 +195,53 @@ export default function Notifications() {
   }, [])
 
   useEffect(() => {
-    const stream = new EventSource('/api/notifications/stream')
+    const stream = new EventSource(`${apiBase}/api/notifications/stream?client=ui`)
+    let connected = false
+    const merge = (incoming: Notification[]) => {
+      if (incoming.length === 0) return
+      lastIdRef.current = incoming.reduce((max, item) => Math.max(max, item.id), lastIdRef.current)
+      setItems((prev) => {
+        const ids = new Set(incoming.map((item) => item.id))
+        const next = [...incoming, ...prev.filter((item) => !ids.has(item.id))]
+        next.sort((a, b) => new Date(b.occurred_at).getTime() - new Date(a.occurred_at).getTime())
+        return next.slice(0, limitRef.current)
+      })
+    }
+    // After a reconnect the server replays from Last-Event-ID. Fetch the gap
+    // here only when it could not: no event was seen yet, or the replay was
+    // cut short.
+    const catchUp = async () => {
+      let sinceId = lastIdRef.current
+      if (sinceId <= 0) return
+      try {
+        for (let page = 0; page < 10; page += 1) {
+          const res = await getNotificationsSince(sinceId)
+          merge(Array.isArray(res?.items) ? res.items : [])
+          if (!res?.has_more) break
+          sinceId = res.last_id
+        }
+      } catch {
+        // the next reconnect tries again
+      }
+    }
     const markWaiting = () => {
       streamErrors.current = 0
       setStreamState('waiting')
     }
     stream.onopen = markWaiting
-    stream.addEventListener('ready', markWaiting)
+    stream.addEventListener('ready', (event) => {
+      markWaiting()
+      let info: { resumed_from?: number; truncated?: boolean } = {}
+      try {
+        info = JSON.parse((event as MessageEvent).data || '{}')
+      } catch {
+        // older servers send an empty object
+      }
+      if (connected && (!info.resumed_from || info.truncated)) {
+        catchUp()
+      }
+      connected = true
+    })
     stream.addEventListener('heartbeat', () => {
       setStreamState((prev) => (prev === 'idle' ? prev : 'waiting'))
     })
@@ -218,11 +251,7 @@ export default function Notifications() {
         if (!payload || !payload.id) return
         streamErrors.current = 0
         setStreamState('idle')
-        setItems((prev) => {
-          const next = [payload, ...prev.filter((item) => item.id !== payload.id)]
-          next.sort((a, b) => new Date(b.occurred_at).getTime() - new Date(a.occurred_at).getTime())
-          return next.slice(0, limitRef.current)
-        })
+        merge([payload])
       } catch {
         // ignore malformed payloads
       }
@@ -3,6 +3,7 @@ import { useTranslation } from 'react-i18next'
 import { getMempoolFees, getOnchainTransactions, getOnchainUtxos, getWalletSummary } from '../api'
 import { getLocale } from '../i18n'
 import clsx from '../utils/clsx'
+import { useExplorer } from '../utils/explorer'
 
 const emptySummary = {
   balances: {
@@ -44,10 +45,9 @@ type MempoolFeeHint = {
   hour?: number
 }
 
-const explorerBase = 'https://mempool.space'
-
 export default function OnchainHub() {
   const { t, i18n } = useTranslation()
+  const { txUrl } = useExplorer()
   const locale = getLocale(i18n.language)
   const satFormatter = new Intl.NumberFormat(locale, { maximumFractionDigits: 0 })
   const [summary, setSummary] = useState<any>(emptySummary)
@@ -446,10 +446,10 @@ export default function OnchainHub() {
                   </div>
                   <div className="text-xs text-fog/70 break-all">{item.address || '-'}</div>
                   <div className="flex items-center gap-2 text-xs text-fog/70 break-all">
-                    {item.txid ? (
+                    {txUrl(item.txid) ? (
                       <a
                         className="onchain-link"
-                        href={`${explorerBase}/tx/${item.txid}`}
+                        href={txUrl(item.txid)}
                         target="_blank"
                         rel="noreferrer"
                         title={t('onchainHub.viewExternal')}
@@ -587,15 +587,19 @@ export default function OnchainHub() {
                     )}
                   </div>
                   <div className="flex items-center gap-2 text-xs text-fog/70 break-all">
-                    <a
-                      className="onchain-link"
-                      href={`${explorerBase}/tx/${item.txid}`}
-                      target="_blank"
-                      rel="noreferrer"
-                      title={t('onchainHub.viewExternal')}
-                    >
-                      {item.txid}
-                    </a>
+                    {txUrl(item.txid) ? (
+                      <a
+                        className="onchain-link"
+                        href={txUrl(item.txid)}
+                        target="_blank"
+                        rel="noreferrer"
+                        title={t('onchainHub.viewExternal')}
+                      >
+                        {item.txid}
+                      </a>
+                    ) : (
+                      <span>{item.txid}</span>
+                    )}
                   </div>
                 </div>
               ))}
@@ -87,7 +87,7 @@ export default function Terminal() {
               </div>
             )}
           </div>
-          <a className="btn-secondary" href="/terminal/" target="_blank" rel="noreferrer">
+          <a className="btn-secondary" href="terminal/" target="_blank" rel="noreferrer">
             {t('terminal.openNewTab')}
           </a>
         </div>
@@ -96,7 +96,7 @@ export default function Terminal() {
       <div className="rounded-3xl border border-white/10 bg-ink/70 shadow-panel overflow-hidden">
         <iframe
           title={t('terminal.title')}
-          src="/terminal/"
+          src="terminal/"
           className="w-full h-[70vh] bg-black"
         />
       </div>
@@ -0,0 +1,47 @@
+import { useEffect, useState } from 'react'
+import { getNetwork } from '../api'
+import type { NetworkInfo } from '../api'
+
+// Explorer links follow the node's network (GET /api/network). Regtest has
+// no explorer, so its links, like those before the network loads, are empty.
+let networkRequest: Promise<NetworkInfo | null> | null = null
+
+const loadNetwork = () => {
+  if (!networkRequest) {
+    networkRequest = getNetwork().catch(() => {
+      networkRequest = null
+      return null
+    })
+  }
+  return networkRequest
+}
+
+export function useExplorer() {
+  const [network, setNetwork] = useState<NetworkInfo | null>(null)
+
+  useEffect(() => {
+    let active = true
+    loadNetwork().then((info) => {
+      if (active) setNetwork(info)
+    })
+    return () => {
+      active = false
+    }
+  }, [])
+
+  const txUrl = (txid?: string) => {
+    const template = network?.explorer_tx_url
+    if (!txid || !template) return ''
+    return template.replace('{txid}', txid)
+  }
+
+  const outpointUrl = (channelPoint?: string) => {
+    if (!channelPoint) return ''
+    const parts = channelPoint.split(':')
+    if (parts.length !== 2) return ''
+    const url = txUrl(parts[0])
+    return url ? `${url}#vout=${parts[1]}` : ''
+  }
+
+  return { txUrl, outpointUrl }
+}
@@ -2,6 +2,8 @@ import { defineConfig } from 'vite'
 import react from '@vitejs/plugin-react'
 
 export default defineConfig({
+  // Relative asset URLs so the same build works under a reverse proxy sub-path.
+  base: './',
   plugins: [react()],
   server: {
     port: 5173,